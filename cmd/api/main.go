@@ -1,29 +1,56 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linskybing/platform-go/internal/api/middleware"
 	"github.com/linskybing/platform-go/internal/api/routes"
+	"github.com/linskybing/platform-go/internal/application"
+	jobapp "github.com/linskybing/platform-go/internal/application/job"
+	"github.com/linskybing/platform-go/internal/application/scheduler"
 	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/config/db"
 	"github.com/linskybing/platform-go/internal/cron"
+	"github.com/linskybing/platform-go/internal/domain/activity"
 	"github.com/linskybing/platform-go/internal/domain/audit"
 	"github.com/linskybing/platform-go/internal/domain/configfile"
 	"github.com/linskybing/platform-go/internal/domain/form"
+	"github.com/linskybing/platform-go/internal/domain/gpu"
 	"github.com/linskybing/platform-go/internal/domain/group"
 	"github.com/linskybing/platform-go/internal/domain/image"
 	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/maintenance"
 	"github.com/linskybing/platform-go/internal/domain/project"
 	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/domain/setting"
 	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/internal/scheduler/executor"
 	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/logger"
+	"github.com/linskybing/platform-go/pkg/storage"
 )
 
+// @title Platform API
+// @version 1.0
+// @description GPU platform for projects, config files, jobs and storage. The unversioned paths are deprecated aliases of the same routes.
+// @BasePath /api/v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
 	// Load configuration from environment variables and .env file
 	config.LoadConfig()
+	if err := logger.Configure(config.LogLevel, config.LogLevels); err != nil {
+		log.Printf("Warning: %v; logging at info", err)
+	}
 
 	// Initialize JWT signing key
 	middleware.Init()
@@ -34,18 +61,37 @@ func main() {
 	// Initialize database connection
 	db.Init()
 	k8s.Init()
+	// Object storage backs job artifacts; the API still starts without it
+	if err := storage.Connect(); err != nil {
+		log.Printf("Warning: object storage unavailable, job artifacts disabled: %v", err)
+	}
 
 	// Auto migrate database schemas
 	if err := db.DB.AutoMigrate(
 		&user.User{},
+		&user.APIToken{},
 		&group.Group{},
 		&group.UserGroup{},
 		&project.Project{},
+		&project.ProjectEnvDefault{},
+		&project.SchedulingPolicy{},
+		&project.RegistryCredential{},
+		&project.ProjectDeletion{},
+		&project.UsageSample{},
 		&configfile.ConfigFile{},
+		&configfile.ConfigTemplate{},
+		&configfile.BootstrapBundle{},
+		&configfile.ProjectBootstrapBundle{},
+		&configfile.BootstrapObject{},
 		&resource.Resource{},
+		&resource.DeployedObject{},
+		&configfile.InstanceApprovalRequest{},
 		&job.Job{},
 		&job.JobLog{},
 		&job.JobCheckpoint{},
+		&job.JobEvent{},
+		&job.JobEventSeen{},
+		&job.JobTemplate{},
 		&form.Form{},
 		&form.FormMessage{},
 		&audit.AuditLog{},
@@ -54,16 +100,111 @@ func main() {
 		&image.ImageAllowList{},
 		&image.ImageRequest{},
 		&image.ClusterImageStatus{},
+		&maintenance.Maintenance{},
+		&gpu.GPURequest{},
+		&setting.Setting{},
+		&activity.UserActivity{},
+		&activity.Favorite{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
+	db.CreateSearchIndexes()
+
+	// Projects created before storage namespaces were stored keep the namespace derived from their current name
+	if n, err := application.NewProjectService(repository.NewRepositories(db.DB)).BackfillStorageNamespaces(); err != nil {
+		log.Fatalf("Failed to backfill project storage namespaces: %v", err)
+	} else if n > 0 {
+		log.Printf("Backfilled the storage namespace of %d projects", n)
+	}
+	// Job logs written before they were dated would never age out
+	if n, err := repository.NewJobRepo(db.DB).BackfillLogTimestamps(); err != nil {
+		log.Printf("Warning: Failed to date job logs: %v", err)
+	} else if n > 0 {
+		log.Printf("Dated %d job logs written before they had a timestamp", n)
+	}
+	// Names stored before they were validated are reported, not rewritten
+	if issues, err := application.NewProjectService(repository.NewRepositories(db.DB)).ReportInvalidNames(); err != nil {
+		log.Printf("Warning: Failed to check stored names: %v", err)
+	} else {
+		for _, issue := range issues {
+			log.Printf("Warning: %s %d named %q: %s", issue.Entity, issue.ID, issue.Name, issue.Problem)
+		}
+	}
+	// Users whose K8s name changed get their project namespaces under the new name
+	if n, err := application.NewUserGroupService(repository.NewRepositories(db.DB)).MigrateUserK8sNames(); err != nil {
+		log.Printf("Warning: Failed to migrate user namespaces: %v", err)
+	} else if n > 0 {
+		log.Printf("Provisioned the namespaces of %d users under their new K8s name", n)
+	}
 
 	// Initialize Docker cleanup CronJob
 	if err := cron.CreateDockerCleanupCronJob(); err != nil {
-		log.Printf("Warning: Failed to create Docker cleanup CronJob: %v", err)
+		log.Printf("Warning: %v", err)
 		// Don't fail startup if CronJob creation fails
 	}
 
+	// Every object created for a project carries its cost center and cost tags
+	k8s.ProjectLabels = application.ProjectChargebackLabels(repository.NewRepositories(db.DB))
+
+	// Make sure the PriorityClasses referenced by jobs exist on fresh clusters
+	if err := application.NewK8sService(repository.NewRepositories(db.DB)).ReconcilePriorityClasses(context.Background()); err != nil {
+		log.Printf("Warning: Failed to reconcile priority classes: %v", err)
+	}
+
+	// Legacy project namespaces get the labels project lookups select on, once rather than per lookup
+	if updated, err := application.NewK8sService(repository.NewRepositories(db.DB)).BackfillNamespaceLabels(context.Background()); err != nil {
+		log.Printf("Warning: Failed to label legacy project namespaces: %v", err)
+	} else if len(updated) > 0 {
+		log.Printf("Labeled %d legacy project namespaces", len(updated))
+	}
+
+	// Image pull jobs run in their own namespace, which needs the Harbor credentials
+	if err := application.NewImageService(repository.NewRepositories(db.DB).Image).EnsurePullNamespace(context.Background()); err != nil {
+		log.Printf("Warning: Failed to prepare image pull namespace: %v", err)
+	}
+
+	// Report missing cluster prerequisites now rather than as confusing runtime failures
+	if report := application.NewK8sService(repository.NewRepositories(db.DB)).LogPreflight(context.Background()); report.Failed() && config.PreflightStrict {
+		log.Fatalf("Preflight checks failed and PREFLIGHT_STRICT is set, see GET /admin/preflight")
+	}
+
+	// Cancelled on SIGINT/SIGTERM to start the graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Dispatch queued jobs, such as those waiting on run-after dependencies
+	repos := repository.NewRepositories(db.DB)
+	registry := executor.NewExecutorRegistry()
+	jobNotifier := application.NewJobNotifier(repos)
+	jobService := jobapp.NewService(repos.Job, repos.User, repos.Project)
+	k8sExecutor := executor.NewK8sExecutor(repos.Job, application.NewImageService(repos.Image)).
+		WithGangGate(application.NewK8sService(repos).CheckGangPlacement).
+		WithTimeoutHook(jobNotifier.JobTimedOut).
+		WithEvictionHooks(jobNotifier.JobInterrupted, func(ctx context.Context, j *job.Job) error {
+			return jobService.RestartJob(ctx, j.ID, nil)
+		})
+	registry.Register(job.JobTypeNormal, k8sExecutor)
+	registry.Register(job.JobTypeGPU, k8sExecutor)
+	if config.ExternalExecutorURL != "" {
+		registry.Register(job.JobTypeExternal, executor.NewHTTPExecutor(repos.Job, config.ExternalExecutorURL, config.ExternalExecutorToken))
+	}
+	// Cancelling a job stops it wherever its executor runs it
+	jobapp.StopJob = func(ctx context.Context, j *job.Job) error {
+		if err := registry.Cancel(ctx, j); err != nil && !errors.Is(err, executor.ErrExecutorNotFound) {
+			return err
+		}
+		return nil
+	}
+	// Jobs whose pods are evicted, e.g. by a node drain, are interrupted and possibly resubmitted
+	go k8sExecutor.WatchEvictions(ctx)
+	go func() {
+		_ = scheduler.NewScheduler(registry, repos.Job).
+			WithPauseGate(application.NewMaintenanceService(repos).Active).
+			WithDispatchGate(application.NewK8sService(repos).CheckJobDispatch).
+			WithRuntimeLimits(application.NewK8sService(repos).JobRuntimeLimit, jobNotifier.JobTimedOut).
+			Start(ctx)
+	}()
+
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 
@@ -73,8 +214,22 @@ func main() {
 	routes.RegisterRoutes(router, db.DB)
 
 	port := ":" + config.ServerPort
-	log.Printf("Starting API server on %s", port)
-	if err := router.Run(port); err != nil {
-		log.Fatalf("Failed to start: %v", err)
+	srv := &http.Server{Addr: port, Handler: router}
+	go func() {
+		log.Printf("Starting API server on %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down API server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: HTTP server shutdown: %v", err)
+	}
+	if !application.ShutdownPullMonitors(config.ShutdownTimeout) {
+		log.Printf("Warning: image pull monitors did not stop within %s", config.ShutdownTimeout)
 	}
 }
@@ -7,11 +7,17 @@ CREATE TYPE user_type AS ENUM ('origin','oauth2');
 CREATE TYPE user_status AS ENUM ('online','offline','delete');
 CREATE TYPE user_role AS ENUM ('admin','manager','user');
 
+-- Trigram indexes for the search API
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 -- group_list
 CREATE TABLE group_list (
   g_id SERIAL PRIMARY KEY,
   group_name VARCHAR(100) NOT NULL,
   description TEXT,
+  hub_storage_size VARCHAR(20),
+  storage_class_name VARCHAR(100),
+  project_storage_size VARCHAR(20),
   create_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   update_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -22,9 +28,80 @@ CREATE TABLE projects (
   project_name VARCHAR(100) NOT NULL,
   description TEXT,
   g_id INTEGER NOT NULL REFERENCES group_list(g_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  storage_namespace VARCHAR(63),
+  namespace_mode VARCHAR(20) DEFAULT 'per-user',
+  max_concurrent_jobs INTEGER NOT NULL DEFAULT 0,
+  max_concurrent_jobs_per_user INTEGER NOT NULL DEFAULT 0,
+  max_job_runtime_minutes INTEGER NOT NULL DEFAULT 0,
+  strict_volume_checks BOOLEAN NOT NULL DEFAULT FALSE,
+  cost_center VARCHAR(63),
+  cost_tags JSONB,
+  allowed_host_paths JSONB,
+  default_cpu_request VARCHAR(32),
+  default_memory_request VARCHAR(32),
+  resource_limit_multiplier DOUBLE PRECISION NOT NULL DEFAULT 0,
+  resource_defaults_disabled BOOLEAN NOT NULL DEFAULT FALSE,
+  require_instance_approval BOOLEAN NOT NULL DEFAULT FALSE,
   create_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   update_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+CREATE INDEX idx_project_list_name_trgm ON projects USING gin (lower(project_name) gin_trgm_ops);
+CREATE INDEX idx_project_list_description_trgm ON projects USING gin (lower(description) gin_trgm_ops);
+
+-- project_deletions (no foreign key: the record outlives the project)
+CREATE TABLE project_deletions (
+  id SERIAL PRIMARY KEY,
+  p_id INTEGER NOT NULL,
+  project_name VARCHAR(100) NOT NULL,
+  storage_namespace VARCHAR(63),
+  requested_by INTEGER,
+  force BOOLEAN DEFAULT FALSE,
+  status VARCHAR(20) NOT NULL,
+  steps JSONB,
+  error TEXT,
+  create_at TIMESTAMP DEFAULT NOW(),
+  update_at TIMESTAMP DEFAULT NOW(),
+  finished_at TIMESTAMP
+);
+CREATE INDEX idx_project_deletions_p_id ON project_deletions(p_id);
+CREATE INDEX idx_project_deletions_status ON project_deletions(status);
+
+-- project_env_defaults: env vars injected into every workload of a project
+CREATE TABLE project_env_defaults (
+  id SERIAL PRIMARY KEY,
+  p_id INTEGER NOT NULL REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  key VARCHAR(255) NOT NULL,
+  value TEXT,
+  secret BOOLEAN DEFAULT FALSE,
+  create_at TIMESTAMP DEFAULT NOW(),
+  update_at TIMESTAMP DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_project_env_key ON project_env_defaults(p_id, key);
+
+-- project_scheduling_policies
+CREATE TABLE project_scheduling_policies (
+  id SERIAL PRIMARY KEY,
+  p_id INTEGER NOT NULL UNIQUE REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  tolerations JSONB,
+  node_selector JSONB,
+  affinity JSONB,
+  force BOOLEAN DEFAULT FALSE,
+  create_at TIMESTAMP DEFAULT NOW(),
+  update_at TIMESTAMP DEFAULT NOW()
+);
+
+-- project_registry_credentials
+CREATE TABLE project_registry_credentials (
+  id SERIAL PRIMARY KEY,
+  p_id INTEGER NOT NULL REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  registry VARCHAR(255) NOT NULL,
+  username VARCHAR(255) NOT NULL,
+  encrypted_token TEXT NOT NULL,
+  created_by INTEGER,
+  create_at TIMESTAMP DEFAULT NOW(),
+  update_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE (p_id, registry)
+);
 
 -- config_file
 CREATE TABLE config_files (
@@ -32,13 +109,69 @@ CREATE TABLE config_files (
   filename VARCHAR(200) NOT NULL,
   content VARCHAR(5000),
   project_id INTEGER NOT NULL REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  create_at TIMESTAMP DEFAULT NOW(),
+  update_at TIMESTAMP DEFAULT NOW(),
+  version INTEGER NOT NULL DEFAULT 1,
+  template_id INTEGER,
+  template_version INTEGER,
+  deleted_at TIMESTAMP
+);
+CREATE INDEX idx_config_files_deleted_at ON config_files(deleted_at);
+CREATE INDEX idx_config_files_filename_trgm ON config_files USING gin (lower(filename) gin_trgm_ops);
+CREATE INDEX idx_config_files_content_trgm ON config_files USING gin (lower(content) gin_trgm_ops);
+
+-- config_templates
+CREATE TABLE config_templates (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) NOT NULL UNIQUE,
+  description TEXT,
+  category VARCHAR(50),
+  content VARCHAR(10000),
+  requires_gpu BOOLEAN DEFAULT FALSE,
+  version INTEGER DEFAULT 1,
+  create_at TIMESTAMP DEFAULT NOW(),
+  update_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX idx_config_templates_category ON config_templates (category);
+
+-- bootstrap_bundles: manifests applied to the member namespaces of the projects selecting them
+CREATE TABLE bootstrap_bundles (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) NOT NULL UNIQUE,
+  description TEXT,
+  content VARCHAR(10000),
+  version INTEGER DEFAULT 1,
+  create_at TIMESTAMP DEFAULT NOW(),
+  update_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE project_bootstrap_bundles (
+  p_id INTEGER NOT NULL REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  bundle_id INTEGER NOT NULL REFERENCES bootstrap_bundles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  create_at TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (p_id, bundle_id)
+);
+
+-- bootstrap_objects: objects a bundle created in a namespace, at the bundle version applied
+CREATE TABLE bootstrap_objects (
+  id SERIAL PRIMARY KEY,
+  bundle_id INTEGER NOT NULL REFERENCES bootstrap_bundles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  p_id INTEGER NOT NULL REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  namespace VARCHAR(63) NOT NULL,
+  kind VARCHAR(100) NOT NULL,
+  name VARCHAR(253) NOT NULL,
+  manifest JSONB,
+  bundle_version INTEGER NOT NULL,
   create_at TIMESTAMP DEFAULT NOW()
 );
+CREATE UNIQUE INDEX idx_bootstrap_object ON bootstrap_objects (bundle_id, namespace, kind, name);
+CREATE INDEX idx_bootstrap_objects_p_id ON bootstrap_objects (p_id);
 
 -- resource
 CREATE TABLE resources (
   r_id SERIAL PRIMARY KEY,
   cf_id INTEGER NOT NULL REFERENCES config_files(cf_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  cf_version INTEGER NOT NULL DEFAULT 1,
   type resource_type NOT NULL,
   name VARCHAR(50) NOT NULL,
   parsed_yaml JSONB NOT NULL,
@@ -46,6 +179,46 @@ CREATE TABLE resources (
   create_at TIMESTAMP DEFAULT NOW()
 );
 
+-- instance_approval_requests: rendered instances waiting for a project manager
+CREATE TABLE instance_approval_requests (
+  id SERIAL PRIMARY KEY,
+  cf_id INTEGER NOT NULL REFERENCES config_files(cf_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  p_id INTEGER NOT NULL REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  requester_id INTEGER NOT NULL,
+  requester_username VARCHAR(100) NOT NULL,
+  namespace VARCHAR(63) NOT NULL,
+  documents JSONB,
+  uses_harbor_image BOOLEAN DEFAULT FALSE,
+  uses_project_registry BOOLEAN DEFAULT FALSE,
+  status VARCHAR(20) DEFAULT 'pending',
+  reviewer_id INTEGER,
+  note TEXT,
+  create_at TIMESTAMP DEFAULT NOW(),
+  expires_at TIMESTAMP,
+  decided_at TIMESTAMP
+);
+CREATE INDEX idx_instance_approval_requests_cf_id ON instance_approval_requests (cf_id);
+CREATE INDEX idx_instance_approval_project_status ON instance_approval_requests (p_id, status);
+CREATE INDEX idx_instance_approval_requests_expires_at ON instance_approval_requests (expires_at);
+
+-- platform_maintenance: single row holding the platform-wide maintenance flag
+CREATE TABLE platform_maintenance (
+  id SERIAL PRIMARY KEY,
+  active BOOLEAN NOT NULL DEFAULT FALSE,
+  message TEXT,
+  ends_at TIMESTAMP,
+  updated_by INTEGER,
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- platform_settings: runtime settings admins changed from their environment defaults
+CREATE TABLE platform_settings (
+  key VARCHAR(100) PRIMARY KEY,
+  value TEXT NOT NULL,
+  updated_by INTEGER,
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
 -- users
 CREATE TABLE users (
   u_id SERIAL PRIMARY KEY,
@@ -55,9 +228,32 @@ CREATE TABLE users (
   full_name VARCHAR(50),
   type user_type NOT NULL DEFAULT 'origin',
   status user_status NOT NULL DEFAULT 'offline',
+  auth_provider VARCHAR(20) NOT NULL DEFAULT 'local',
+  external_subject VARCHAR(255),
+  ssh_public_keys TEXT NOT NULL DEFAULT '',
+  storage_id VARCHAR(63) NOT NULL DEFAULT '',
   create_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   update_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+CREATE UNIQUE INDEX idx_users_external_subject ON users (auth_provider, external_subject);
+CREATE UNIQUE INDEX idx_users_storage_id ON users (storage_id) WHERE storage_id <> '';
+
+-- api_tokens
+CREATE TABLE api_tokens (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(u_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  project_id INTEGER REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  prefix VARCHAR(16) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL UNIQUE,
+  scopes TEXT NOT NULL,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_api_tokens_user_id ON api_tokens (user_id);
+CREATE INDEX idx_api_tokens_project_id ON api_tokens (project_id);
 
 -- user_group
 CREATE TABLE user_group (
@@ -75,6 +271,7 @@ CREATE TABLE user_group (
 CREATE TABLE audit_logs (
   id SERIAL PRIMARY KEY,
   user_id INT NOT NULL,
+  impersonator_id INT,
   action VARCHAR(20) NOT NULL,
   resource_type VARCHAR(50) NOT NULL,
   resource_id VARCHAR NOT NULL,
@@ -86,10 +283,43 @@ CREATE TABLE audit_logs (
   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- job_logs: the other job tables are created by AutoMigrate. created_at defaults to the insert
+-- time so every log ages out after JOB_LOG_RETENTION_DAYS; the API dates older rows on startup.
+CREATE TABLE job_logs (
+  id SERIAL PRIMARY KEY,
+  job_id INT NOT NULL,
+  content TEXT,
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_job_logs_job_id ON job_logs(job_id);
+CREATE INDEX idx_job_logs_created_at ON job_logs(created_at);
+
+-- user_activities: recent items of each user, pruned after USER_ACTIVITY_RETENTION_DAYS
+CREATE TABLE user_activities (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL,
+  entity_type VARCHAR(20) NOT NULL,
+  entity_id INT NOT NULL,
+  action VARCHAR(20) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_user_activity_user_entity ON user_activities(user_id, entity_type, entity_id);
+CREATE INDEX idx_user_activities_created_at ON user_activities(created_at);
+
+-- user_favorites: entities a user starred
+CREATE TABLE user_favorites (
+  user_id INT NOT NULL,
+  entity_type VARCHAR(20) NOT NULL,
+  entity_id INT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, entity_type, entity_id)
+);
+
 -- jobs
 CREATE TABLE jobs (
   id SERIAL PRIMARY KEY,
   user_id INTEGER NOT NULL REFERENCES users(u_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  project_id INTEGER,
   name VARCHAR(100) NOT NULL,
   namespace VARCHAR(100) NOT NULL,
   image VARCHAR(255) NOT NULL,
@@ -98,6 +328,40 @@ CREATE TABLE jobs (
   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+-- Concurrent job limits count the unfinished jobs of a project
+CREATE INDEX idx_jobs_project_status ON jobs (project_id, status);
+CREATE INDEX idx_jobs_name_trgm ON jobs USING gin (lower(name) gin_trgm_ops);
+CREATE INDEX idx_jobs_image_trgm ON jobs USING gin (lower(image) gin_trgm_ops);
+-- Queue statistics select jobs by submission time
+CREATE INDEX idx_jobs_created_at ON jobs (created_at);
+
+-- job_templates
+CREATE TABLE job_templates (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(u_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  project_id INTEGER REFERENCES projects(p_id) ON DELETE SET NULL ON UPDATE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  submission JSONB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (user_id, name)
+);
+CREATE INDEX idx_job_templates_project_id ON job_templates (project_id);
+
+-- gpu_requests
+CREATE TABLE gpu_requests (
+  id SERIAL PRIMARY KEY,
+  project_id INTEGER NOT NULL REFERENCES projects(p_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  requester_id INTEGER NOT NULL REFERENCES users(u_id) ON DELETE CASCADE ON UPDATE CASCADE,
+  type VARCHAR(20) NOT NULL,
+  requested_quota INTEGER DEFAULT 0,
+  requested_access_type VARCHAR(20),
+  reason TEXT,
+  status VARCHAR(20) DEFAULT 'pending',
+  urgent BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
 
 -- View: project_group_views
 CREATE OR REPLACE VIEW project_group_views AS
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/activity"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+type ActivityHandler struct {
+	svc *application.ActivityService
+}
+
+func NewActivityHandler(svc *application.ActivityService) *ActivityHandler {
+	return &ActivityHandler{svc: svc}
+}
+
+// GetRecent godoc
+// @Summary List recently used items
+// @Description Projects, config files and jobs the caller viewed or created, one entry per item, most recent first and at most 20.
+// @Tags me
+// @Security BearerAuth
+// @Produce json
+// @Param type query string false "project, configfile or job"
+// @Success 200 {array} activity.Item
+// @Failure 400 {object} response.ErrorResponse "Unknown type"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /me/recent [get]
+func (h *ActivityHandler) GetRecent(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	items, err := h.svc.Recent(uid, c.Query("type"))
+	if err != nil {
+		respondActivityError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// GetFavorites godoc
+// @Summary List starred items
+// @Tags me
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} activity.Item
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /me/favorites [get]
+func (h *ActivityHandler) GetFavorites(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	items, err := h.svc.Favorites(uid)
+	if err != nil {
+		respondActivityError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// AddFavorite godoc
+// @Summary Star an item
+// @Description Starring an item twice keeps it once.
+// @Tags me
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body activity.FavoriteInput true "Item to star"
+// @Success 201 {object} activity.Item
+// @Failure 400 {object} response.ErrorResponse "Unknown type"
+// @Failure 404 {object} response.ErrorResponse "Item not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /me/favorites [post]
+func (h *ActivityHandler) AddFavorite(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	var input activity.FavoriteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	item, err := h.svc.AddFavorite(uid, input)
+	if err != nil {
+		respondActivityError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+// RemoveFavorite godoc
+// @Summary Unstar an item
+// @Tags me
+// @Security BearerAuth
+// @Param type query string true "project, configfile or job"
+// @Param id query int true "Item ID"
+// @Success 204
+// @Failure 400 {object} response.ErrorResponse "Unknown type or invalid id"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /me/favorites [delete]
+func (h *ActivityHandler) RemoveFavorite(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	id, err := utils.ParseQueryUintParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid id"})
+		return
+	}
+	if err := h.svc.RemoveFavorite(uid, c.Query("type"), id); err != nil {
+		respondActivityError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondActivityError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, application.ErrInvalidEntityType):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, application.ErrEntityNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+type APITokenHandler struct {
+	svc *application.APITokenService
+}
+
+func NewAPITokenHandler(svc *application.APITokenService) *APITokenHandler {
+	return &APITokenHandler{svc: svc}
+}
+
+// CreateAPIToken godoc
+// @Summary Create an API token
+// @Description Issues a scoped token for automation. The token is only returned by this call.
+// @Tags api-tokens
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body user.CreateAPITokenInput true "Token settings"
+// @Success 201 {object} user.CreatedAPITokenDTO
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 403 {object} response.ErrorResponse "Not a manager of the project"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api-tokens [post]
+func (h *APITokenHandler) CreateAPIToken(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	var input user.CreateAPITokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	token, err := h.svc.CreateToken(c, uid, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrInvalidTokenScope):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		case errors.Is(err, application.ErrProjectTokenDenied):
+			c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, token)
+}
+
+// ListAPITokens godoc
+// @Summary List my API tokens
+// @Tags api-tokens
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} user.APITokenDTO
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api-tokens [get]
+func (h *APITokenHandler) ListAPITokens(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	tokens, err := h.svc.ListTokens(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeAPIToken godoc
+// @Summary Revoke an API token
+// @Tags api-tokens
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Token ID"
+// @Success 200 {object} response.MessageResponse "Token revoked"
+// @Failure 403 {object} response.ErrorResponse "Not the token owner"
+// @Failure 404 {object} response.ErrorResponse "Token not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /api-tokens/{id} [delete]
+func (h *APITokenHandler) RevokeAPIToken(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid token id"})
+		return
+	}
+
+	if err := h.svc.RevokeToken(c, uid, id); err != nil {
+		switch {
+		case errors.Is(err, application.ErrAPITokenNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrAPITokenForbidden):
+			c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "api token revoked"})
+}
@@ -111,3 +111,25 @@ func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
 
 	c.JSON(http.StatusOK, logs)
 }
+
+// PreviewRetention godoc
+// @Summary      Preview log retention
+// @Description  Report how many rows per table are past their retention window and would be removed by the next pruning run. Nothing is deleted.
+// @Tags         audit
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200 {object}  response.SuccessResponse{data=[]application.RetentionReport} "Rows that would be pruned"
+// @Failure      500 {object}  response.ErrorResponse "Internal server error"
+// @Router       /audit/retention [get]
+func (h *AuditHandler) PreviewRetention(c *gin.Context) {
+	reports, err := h.svc.PruneExpired(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data:    reports,
+	})
+}
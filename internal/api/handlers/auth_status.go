@@ -15,5 +15,10 @@ func AuthStatusHandler(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "token expired"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "valid", "user_id": uid})
+	status := gin.H{"status": "valid", "user_id": uid}
+	if impersonatorID := utils.GetImpersonatorIDFromContext(c); impersonatorID != nil {
+		status["impersonated"] = true
+		status["impersonator_id"] = *impersonatorID
+	}
+	c.JSON(http.StatusOK, status)
 }
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// ListBootstrapBundlesHandler godoc
+// @Summary List bootstrap bundles
+// @Tags bootstrap_bundles
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} configfile.BootstrapBundle
+// @Failure 500 {object} response.ErrorResponse
+// @Router /bootstrap-bundles [get]
+func (h *ConfigFileHandler) ListBootstrapBundlesHandler(c *gin.Context) {
+	bundles, err := h.svc.ListBootstrapBundles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, bundles)
+}
+
+// GetBootstrapBundleHandler godoc
+// @Summary Get a bootstrap bundle by ID
+// @Tags bootstrap_bundles
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Bundle ID"
+// @Success 200 {object} configfile.BootstrapBundle
+// @Failure 400 {object} response.ErrorResponse "Invalid ID"
+// @Failure 404 {object} response.ErrorResponse "Not Found"
+// @Router /bootstrap-bundles/{id} [get]
+func (h *ConfigFileHandler) GetBootstrapBundleHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid bundle ID"})
+		return
+	}
+
+	b, err := h.svc.GetBootstrapBundle(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// CreateBootstrapBundleHandler godoc
+// @Summary Create a bootstrap bundle
+// @Description Register manifests applied to the member namespaces of the projects selecting the bundle. The YAML may use the {{namespace}}, {{projectId}} and {{username}} placeholders; every object must be named.
+// @Tags bootstrap_bundles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body configfile.CreateBootstrapBundleInput true "Bundle"
+// @Success 201 {object} configfile.BootstrapBundle
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Router /bootstrap-bundles [post]
+func (h *ConfigFileHandler) CreateBootstrapBundleHandler(c *gin.Context) {
+	var input configfile.CreateBootstrapBundleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: fmt.Sprintf("Invalid input: %v", err)})
+		return
+	}
+	if err := checkConfigContentSize(input.RawYaml); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	b, err := h.svc.CreateBootstrapBundle(c, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, b)
+}
+
+// UpdateBootstrapBundleHandler godoc
+// @Summary Update a bootstrap bundle
+// @Description Changing the YAML bumps the bundle version; namespaces get the new version the next time they are provisioned.
+// @Tags bootstrap_bundles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Bundle ID"
+// @Param input body configfile.UpdateBootstrapBundleInput true "Fields to change"
+// @Success 200 {object} configfile.BootstrapBundle
+// @Failure 400 {object} response.ErrorResponse "Bad Request"
+// @Failure 404 {object} response.ErrorResponse "Not Found"
+// @Router /bootstrap-bundles/{id} [put]
+func (h *ConfigFileHandler) UpdateBootstrapBundleHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid bundle ID"})
+		return
+	}
+
+	var input configfile.UpdateBootstrapBundleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if input.RawYaml != nil {
+		if err := checkConfigContentSize(*input.RawYaml); err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	b, err := h.svc.UpdateBootstrapBundle(c, id, input)
+	if err != nil {
+		if errors.Is(err, application.ErrBootstrapBundleNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// DeleteBootstrapBundleHandler godoc
+// @Summary Delete a bootstrap bundle
+// @Description Only bundles no project selects can be deleted.
+// @Tags bootstrap_bundles
+// @Security BearerAuth
+// @Param id path int true "Bundle ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "Selected by projects"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /bootstrap-bundles/{id} [delete]
+func (h *ConfigFileHandler) DeleteBootstrapBundleHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid bundle ID"})
+		return
+	}
+
+	if err := h.svc.DeleteBootstrapBundle(c, id); err != nil {
+		switch {
+		case errors.Is(err, application.ErrBootstrapBundleNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrBootstrapBundleInUse):
+			c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListProjectBootstrapBundlesHandler godoc
+// @Summary List the bootstrap bundles of a project
+// @Tags bootstrap_bundles
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {array} configfile.BootstrapBundle
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Router /projects/{id}/bootstrap-bundles [get]
+func (h *ConfigFileHandler) ListProjectBootstrapBundlesHandler(c *gin.Context) {
+	projectID, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project ID"})
+		return
+	}
+
+	bundles, err := h.svc.ListProjectBootstrapBundles(projectID)
+	if err != nil {
+		if errors.Is(err, application.ErrProjectNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, bundles)
+}
+
+// AddProjectBootstrapBundleHandler godoc
+// @Summary Select a bootstrap bundle for a project
+// @Description The bundle is applied to the member namespaces that exist and to those provisioned later.
+// @Tags bootstrap_bundles
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Param bundle_id path int true "Bundle ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} response.ErrorResponse "Project or bundle not found"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /projects/{id}/bootstrap-bundles/{bundle_id} [put]
+func (h *ConfigFileHandler) AddProjectBootstrapBundleHandler(c *gin.Context) {
+	projectID, bundleID, ok := parseProjectBundleParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.AddProjectBootstrapBundle(c, projectID, bundleID); err != nil {
+		writeProjectBundleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveProjectBootstrapBundleHandler godoc
+// @Summary Remove a bootstrap bundle from a project
+// @Description With prune=true the objects the bundle created in the project's namespaces are deleted; otherwise they are left in place.
+// @Tags bootstrap_bundles
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param bundle_id path int true "Bundle ID"
+// @Param prune query bool false "Delete the objects the bundle created"
+// @Success 200 {object} map[string][]string "deleted: namespace/Kind/name of the deleted objects"
+// @Failure 404 {object} response.ErrorResponse "Project or bundle not found"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /projects/{id}/bootstrap-bundles/{bundle_id} [delete]
+func (h *ConfigFileHandler) RemoveProjectBootstrapBundleHandler(c *gin.Context) {
+	projectID, bundleID, ok := parseProjectBundleParams(c)
+	if !ok {
+		return
+	}
+	prune, _ := strconv.ParseBool(c.Query("prune"))
+
+	deleted, err := h.svc.RemoveProjectBootstrapBundle(c, projectID, bundleID, prune)
+	if err != nil {
+		writeProjectBundleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+func parseProjectBundleParams(c *gin.Context) (uint, uint, bool) {
+	projectID, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project ID"})
+		return 0, 0, false
+	}
+	bundleID, err := utils.ParseIDParam(c, "bundle_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid bundle ID"})
+		return 0, 0, false
+	}
+	return projectID, bundleID, true
+}
+
+func writeProjectBundleError(c *gin.Context, err error) {
+	if errors.Is(err, application.ErrProjectNotFound) || errors.Is(err, application.ErrBootstrapBundleNotFound) {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+const tarContentType = "application/x-tar"
+
+// ExportConfigFilesHandler godoc
+// @Summary Export the config files of a project
+// @Description Returns every config file of the project as a bundle of filenames and raw YAML, without IDs or resources, which another project can import. format=tar returns a tar archive with one entry per file instead of JSON.
+// @Tags config_files
+// @Security BearerAuth
+// @Produce json
+// @Produce application/x-tar
+// @Param id path int true "Project ID"
+// @Param format query string false "json (default) or tar"
+// @Success 200 {object} configfile.ConfigFileBundle
+// @Failure 400 {object} response.ErrorResponse "Bad Request"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Router /projects/{id}/config-files/export [get]
+func (h *ConfigFileHandler) ExportConfigFilesHandler(c *gin.Context) {
+	projectID, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project ID"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "tar" {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "format must be json or tar"})
+		return
+	}
+
+	bundle, err := h.svc.ExportConfigFiles(projectID)
+	if err != nil {
+		if errors.Is(err, application.ErrProjectNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, bundle)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := application.WriteConfigFileBundleTar(&buf, bundle); err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="project-%d-config-files.tar"`, projectID))
+	c.Data(http.StatusOK, tarContentType, buf.Bytes())
+}
+
+// ImportConfigFilesHandler godoc
+// @Summary Import config files into a project
+// @Description Creates the files of an exported bundle, sent as JSON or as a tar archive (Content-Type application/x-tar), through the checks of a new config file. Each file is reported on its own with the images the project must request before launching it. on_conflict decides what happens to a file whose filename the project already uses: skip (default) or rename to <name>-<n>. dry_run=true only validates.
+// @Tags config_files
+// @Security BearerAuth
+// @Accept json
+// @Accept application/x-tar
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param dry_run query bool false "Validate without creating"
+// @Param on_conflict query string false "skip (default) or rename"
+// @Param input body configfile.ConfigFileBundle true "Bundle returned by the export"
+// @Success 200 {object} application.ConfigFileImportReport "Dry run"
+// @Success 201 {object} application.ConfigFileImportReport
+// @Failure 400 {object} response.ErrorResponse "Invalid bundle or on_conflict"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Router /projects/{id}/config-files/import [post]
+func (h *ConfigFileHandler) ImportConfigFilesHandler(c *gin.Context) {
+	projectID, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project ID"})
+		return
+	}
+
+	var bundle configfile.ConfigFileBundle
+	if strings.HasPrefix(c.ContentType(), tarContentType) {
+		read, err := application.ReadConfigFileBundleTar(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		bundle = *read
+	} else if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: fmt.Sprintf("Invalid input: %v", err)})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	report, err := h.svc.ImportConfigFiles(c, projectID, bundle, c.Query("on_conflict"), dryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrInvalidConfigFileBundle):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
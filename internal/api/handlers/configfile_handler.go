@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/api/middleware"
 	"github.com/linskybing/platform-go/internal/application"
 	"github.com/linskybing/platform-go/internal/domain/configfile"
 	"github.com/linskybing/platform-go/pkg/response"
@@ -38,6 +42,7 @@ func (h *ConfigFileHandler) ListConfigFilesHandler(c *gin.Context) {
 
 // GetConfigFile godoc
 // @Summary Get a config file by ID
+// @Description drift counts the objects of the caller's instance modified outside the platform.
 // @Tags config_files
 // @Security BearerAuth
 // @Produce json
@@ -58,6 +63,10 @@ func (h *ConfigFileHandler) GetConfigFileHandler(c *gin.Context) {
 		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "config file not found"})
 		return
 	}
+	// The drift summary is best effort; the file is still returned when the cluster is unreachable
+	if drift, err := h.svc.InstanceDrift(c, configFile); err == nil {
+		configFile.Drift = drift
+	}
 	c.JSON(http.StatusOK, configFile)
 }
 
@@ -71,7 +80,8 @@ func (h *ConfigFileHandler) GetConfigFileHandler(c *gin.Context) {
 // @Param raw_yaml formData string true "Raw YAML content"
 // @Param project_id formData int true "Project ID"
 // @Success 201 {object} models.ConfigFile
-// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 400 {object} response.ErrorResponse "Bad request; YAML problems are also listed per document in errors"
+// @Failure 403 {object} map[string]interface{} "Non-admin workload with host access; carries code and violations"
 // @Failure 500 {object} response.ErrorResponse "Internal server error"
 // @Router /config-files [post]
 func (h *ConfigFileHandler) CreateConfigFileHandler(c *gin.Context) {
@@ -85,12 +95,25 @@ func (h *ConfigFileHandler) CreateConfigFileHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "filename, raw_yaml, and project_id are required"})
 		return
 	}
+	if err := checkConfigContentSize(input.RawYaml); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+		return
+	}
 
 	configFile, err := h.svc.CreateConfigFile(c, input)
 	if err != nil {
+		if respondPodSecurityError(c, err) {
+			return
+		}
+		var yamlErr *application.YAMLValidationError
+		if errors.As(err, &yamlErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "errors": yamlErr.Errors})
+			return
+		}
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
+	middleware.SetActivityEntity(c, configFile.CFID)
 
 	c.JSON(http.StatusCreated, configFile)
 }
@@ -104,9 +127,12 @@ func (h *ConfigFileHandler) CreateConfigFileHandler(c *gin.Context) {
 // @Param id path int true "Config File ID"
 // @Param filename formData string false "Filename"
 // @Param raw_yaml formData string false "Raw YAML content"
+// @Param version formData int true "Version the edit is based on, as returned by GET"
 // @Success 200 {object} models.ConfigFile
 // @Failure 400 {object} response.ErrorResponse "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Non-admin workload with host access; carries code and violations"
 // @Failure 404 {object} response.ErrorResponse "Not Found"
+// @Failure 409 {object} map[string]interface{} "Modified since the given version; carries current_version and current"
 // @Failure 500 {object} response.ErrorResponse "Internal Server Error"
 // @Router /config-files/{id} [put]
 func (h *ConfigFileHandler) UpdateConfigFileHandler(c *gin.Context) {
@@ -121,9 +147,32 @@ func (h *ConfigFileHandler) UpdateConfigFileHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
+	if input.RawYaml != nil {
+		if err := checkConfigContentSize(*input.RawYaml); err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
 
 	updatedConfigFile, err := h.svc.UpdateConfigFile(c, uint(id), input)
 	if err != nil {
+		var conflict *application.ConfigFileVersionConflictError
+		if errors.As(err, &conflict) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":           err.Error(),
+				"current_version": conflict.Current.Version,
+				"current":         conflict.Current,
+			})
+			return
+		}
+		if respondPodSecurityError(c, err) {
+			return
+		}
+		var yamlErr *application.YAMLValidationError
+		if errors.As(err, &yamlErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "errors": yamlErr.Errors})
+			return
+		}
 		if err == application.ErrConfigFileNotFound {
 			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "config file not found"})
 		} else {
@@ -136,7 +185,8 @@ func (h *ConfigFileHandler) UpdateConfigFileHandler(c *gin.Context) {
 }
 
 // DeleteConfigFile godoc
-// @Summary Delete a config file
+// @Summary Move a config file to the trash
+// @Description Deployed instances keep running until the file is purged after the trash retention period.
 // @Tags config_files
 // @Security BearerAuth
 // @Param id path int true "ConfigFile ID"
@@ -165,6 +215,61 @@ func (h *ConfigFileHandler) DeleteConfigFileHandler(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// ListTrashedConfigFiles godoc
+// @Summary List trashed config files of a project
+// @Tags config_files
+// @Security BearerAuth
+// @Produce json
+// @Param project_id query int true "Project ID"
+// @Success 200 {array} models.ConfigFile
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /config-files/trash [get]
+func (h *ConfigFileHandler) ListTrashedConfigFilesHandler(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Query("project_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project_id"})
+		return
+	}
+
+	configFiles, err := h.svc.ListTrashedConfigFiles(uint(projectID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, configFiles)
+}
+
+// RestoreConfigFile godoc
+// @Summary Restore a trashed config file
+// @Tags config_files
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "ConfigFile ID"
+// @Success 200 {object} models.ConfigFile
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /config-files/{id}/restore [post]
+func (h *ConfigFileHandler) RestoreConfigFileHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid config file ID"})
+		return
+	}
+
+	cf, err := h.svc.RestoreConfigFile(c, id)
+	if err != nil {
+		if errors.Is(err, application.ErrConfigFileNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "config file not found in trash"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, cf)
+}
+
 // ListConfigFilesByProjectID godoc
 // @Summary List config files by project ID
 // @Tags config_files
@@ -200,8 +305,12 @@ func (h *ConfigFileHandler) ListConfigFilesByProjectIDHandler(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Config File ID"
 // @Success 200 {object} response.MessageResponse "Instance created successfully"
-// @Failure 400 {object} response.ErrorResponse "Invalid config file ID or validation error"
-// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Success 202 {object} configfile.InstanceApprovalRequest "The project reviews instances; the request waits for a project manager"
+// @Failure 400 {object} map[string]interface{} "Invalid config file ID or validation error; failures lists the failing documents with their index, resource and step"
+// @Failure 403 {object} map[string]interface{} "Non-admin workload with host access; carries code and violations"
+// @Failure 409 {object} response.ErrorResponse "An instance of the config file is already waiting for approval"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error, or a document could not be created; failures lists it and the instance was rolled back"
+// @Failure 503 {object} response.ErrorResponse "The caller's storage hub is degraded"
 // @Router /instance/{id} [post]
 func (h *ConfigFileHandler) CreateInstanceHandler(c *gin.Context) {
 	id, err := utils.ParseIDParam(c, "id")
@@ -209,14 +318,197 @@ func (h *ConfigFileHandler) CreateInstanceHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid config id"})
 		return
 	}
-	err = h.svc.CreateInstance(c, id)
+	approval, err := h.svc.CreateInstance(c, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		if respondPodSecurityError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, application.ErrApprovalAlreadyPending):
+			c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrConfigDataLimitExceeded):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrImageNotAllowed):
+			respondError(c, http.StatusForbidden, response.CodeImageNotAllowed, err)
+		case errors.Is(err, application.ErrStorageDegraded):
+			respondError(c, http.StatusServiceUnavailable, response.CodeStorageDegraded, err)
+		case isNamespaceNotFound(err):
+			respondError(c, http.StatusNotFound, response.CodeNamespaceNotFound, err)
+		default:
+			if !respondInstanceError(c, err) {
+				respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+			}
+		}
+		return
+	}
+	if approval != nil {
+		c.JSON(http.StatusAccepted, approval)
 		return
 	}
 	c.JSON(http.StatusOK, response.MessageResponse{Message: "create successfully"})
 }
 
+// ListInstanceApprovalsHandler godoc
+// @Summary List the instance approval requests of a project
+// @Description Instances of members in projects with require_instance_approval wait here for a project manager. Each request carries the rendered documents that an approval applies.
+// @Tags Instance
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param status query string false "pending, approved, rejected or expired; all when omitted"
+// @Success 200 {array} configfile.InstanceApprovalRequest
+// @Failure 400 {object} response.ErrorResponse "Invalid project ID"
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Router /projects/{id}/instance-approvals [get]
+func (h *ConfigFileHandler) ListInstanceApprovalsHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	reqs, err := h.svc.ListInstanceApprovals(id, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reqs)
+}
+
+// ApproveInstanceHandler godoc
+// @Summary Approve an instance
+// @Description Applies the documents of the request exactly as they were rendered when it was submitted, into the requester's namespace and owned by the requester, and emails the requester. When the apply fails the request stays pending.
+// @Tags Instance
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param request_id path int true "Approval request ID"
+// @Success 200 {object} configfile.InstanceApprovalRequest
+// @Failure 400 {object} map[string]interface{} "Invalid ID or a ConfigMap/Secret limit is exceeded"
+// @Failure 404 {object} response.ErrorResponse "Request or config file not found"
+// @Failure 409 {object} response.ErrorResponse "The request was already decided or expired"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error, or a document could not be created; failures lists it"
+// @Router /projects/{id}/instance-approvals/{request_id}/approve [post]
+func (h *ConfigFileHandler) ApproveInstanceHandler(c *gin.Context) {
+	projectID, reqID, ok := parseApprovalParams(c)
+	if !ok {
+		return
+	}
+	req, err := h.svc.ApproveInstance(c, projectID, reqID)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrConfigDataLimitExceeded):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		case isNamespaceNotFound(err):
+			respondError(c, http.StatusNotFound, response.CodeNamespaceNotFound, err)
+		default:
+			if !respondApprovalError(c, err) && !respondInstanceError(c, err) {
+				respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+			}
+		}
+		return
+	}
+	c.JSON(http.StatusOK, req)
+}
+
+// RejectInstanceHandler godoc
+// @Summary Reject an instance
+// @Description Turns the request down with an optional note and emails the requester. Nothing is deployed.
+// @Tags Instance
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param request_id path int true "Approval request ID"
+// @Param body body configfile.RejectInstanceInput false "Note for the requester"
+// @Success 200 {object} configfile.InstanceApprovalRequest
+// @Failure 400 {object} response.ErrorResponse "Invalid ID or body"
+// @Failure 404 {object} response.ErrorResponse "Request not found"
+// @Failure 409 {object} response.ErrorResponse "The request was already decided or expired"
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Router /projects/{id}/instance-approvals/{request_id}/reject [post]
+func (h *ConfigFileHandler) RejectInstanceHandler(c *gin.Context) {
+	projectID, reqID, ok := parseApprovalParams(c)
+	if !ok {
+		return
+	}
+	var input configfile.RejectInstanceInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	req, err := h.svc.RejectInstance(c, projectID, reqID, strings.TrimSpace(input.Note))
+	if err != nil {
+		if !respondApprovalError(c, err) {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, req)
+}
+
+func parseApprovalParams(c *gin.Context) (uint, uint, bool) {
+	projectID, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return 0, 0, false
+	}
+	reqID, err := utils.ParseIDParam(c, "request_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid request id"})
+		return 0, 0, false
+	}
+	return projectID, reqID, true
+}
+
+// respondApprovalError reports the errors of deciding on an approval request. It returns false
+// for other errors.
+func respondApprovalError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, application.ErrApprovalNotFound), errors.Is(err, application.ErrConfigFileNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, application.ErrApprovalDecided), errors.Is(err, application.ErrApprovalExpired):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+// RenderInstanceHandler godoc
+// @Summary Render a config file instance
+// @Description Returns the manifests an instance of the config file would be created from, with the project's env defaults and scheduling policy injected. Nothing is deployed. Fields of the live objects modified outside the platform, e.g. with kubectl edit, are listed in external_changes.
+// @Tags Instance
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Config File ID"
+// @Success 200 {object} application.RenderedInstance
+// @Failure 400 {object} map[string]interface{} "Invalid config file ID, or a document failed a pipeline step"
+// @Failure 403 {object} response.ErrorResponse "Image not allowed"
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Router /config-files/{id}/rendered [get]
+func (h *ConfigFileHandler) RenderInstanceHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid config id"})
+		return
+	}
+	rendered, err := h.svc.RenderInstance(c, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrImageNotAllowed):
+			respondError(c, http.StatusForbidden, response.CodeImageNotAllowed, err)
+		default:
+			if !respondInstanceError(c, err) {
+				respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+			}
+		}
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
+
 // Destruce ConfigFile Instance godoc
 // @Summary Destruct a config file instance
 // @Tags Instance
@@ -224,6 +516,7 @@ func (h *ConfigFileHandler) CreateInstanceHandler(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Config File ID"
 // @Success 204 "No content"
+// @Success 207 {object} response.SuccessResponse{data=application.InstanceDeletionReport} "Some objects failed to delete"
 // @Failure 400 {object} response.ErrorResponse "Invalid ID"
 // @Failure 500 {object} response.ErrorResponse "Internal Server Error"
 // @Router /instance/{id} [delete]
@@ -233,10 +526,43 @@ func (h *ConfigFileHandler) DestructInstanceHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid config id"})
 		return
 	}
-	err = h.svc.DeleteInstance(c, id)
+	report, err := h.svc.DeleteInstance(c, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
 		return
 	}
+	if report.Partial() {
+		c.JSON(http.StatusMultiStatus, response.SuccessResponse{Code: 0, Message: fmt.Sprintf("%d objects failed to delete", len(report.Failed)), Data: report})
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
+
+// respondInstanceError writes the failing documents of an *application.InstanceError and
+// reports whether it did: a 500 when creating one failed, otherwise a 400.
+func respondInstanceError(c *gin.Context, err error) bool {
+	var instErr *application.InstanceError
+	if !errors.As(err, &instErr) {
+		return false
+	}
+	status := http.StatusBadRequest
+	for _, f := range instErr.Failures {
+		if f.Step == application.InstanceStepApply {
+			status = http.StatusInternalServerError
+		}
+	}
+	reportError(c, status, response.CodeInternal, err)
+	c.JSON(status, gin.H{"error": err.Error(), "failures": instErr.Failures})
+	return true
+}
+
+// respondPodSecurityError writes a 403 listing the offending fields when err is a
+// *application.PodSecurityError and reports whether it did.
+func respondPodSecurityError(c *gin.Context, err error) bool {
+	var secErr *application.PodSecurityError
+	if !errors.As(err, &secErr) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": response.CodePodSecurity, "violations": secErr.Violations})
+	return true
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// ListTemplatesHandler godoc
+// @Summary List config templates
+// @Description List the template catalog, optionally filtered by category.
+// @Tags config_templates
+// @Security BearerAuth
+// @Produce json
+// @Param category query string false "Category to filter by" example("jupyter")
+// @Success 200 {array} configfile.ConfigTemplate
+// @Failure 500 {object} response.ErrorResponse
+// @Router /config-templates [get]
+func (h *ConfigFileHandler) ListTemplatesHandler(c *gin.Context) {
+	templates, err := h.svc.ListTemplates(c.Query("category"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplateHandler godoc
+// @Summary Get a config template by ID
+// @Tags config_templates
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Template ID"
+// @Success 200 {object} configfile.ConfigTemplate
+// @Failure 400 {object} response.ErrorResponse "Invalid ID"
+// @Failure 404 {object} response.ErrorResponse "Not Found"
+// @Router /config-templates/{id} [get]
+func (h *ConfigFileHandler) GetTemplateHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid template ID"})
+		return
+	}
+
+	t, err := h.svc.GetTemplate(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// CreateTemplateHandler godoc
+// @Summary Create a config template
+// @Description Add a template to the catalog. The YAML may use the same {{placeholders}} as config files.
+// @Tags config_templates
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body configfile.CreateConfigTemplateInput true "Template"
+// @Success 201 {object} configfile.ConfigTemplate
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Router /config-templates [post]
+func (h *ConfigFileHandler) CreateTemplateHandler(c *gin.Context) {
+	var input configfile.CreateConfigTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: fmt.Sprintf("Invalid input: %v", err)})
+		return
+	}
+	if err := checkConfigContentSize(input.RawYaml); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	t, err := h.svc.CreateTemplate(c, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// UpdateTemplateHandler godoc
+// @Summary Update a config template
+// @Description Changing the YAML bumps the template version; existing copies are not touched.
+// @Tags config_templates
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param input body configfile.UpdateConfigTemplateInput true "Fields to change"
+// @Success 200 {object} configfile.ConfigTemplate
+// @Failure 400 {object} response.ErrorResponse "Bad Request"
+// @Failure 404 {object} response.ErrorResponse "Not Found"
+// @Router /config-templates/{id} [put]
+func (h *ConfigFileHandler) UpdateTemplateHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid template ID"})
+		return
+	}
+
+	var input configfile.UpdateConfigTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if input.RawYaml != nil {
+		if err := checkConfigContentSize(*input.RawYaml); err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	t, err := h.svc.UpdateTemplate(c, id, input)
+	if err != nil {
+		if errors.Is(err, application.ErrTemplateNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// DeleteTemplateHandler godoc
+// @Summary Delete a config template
+// @Tags config_templates
+// @Security BearerAuth
+// @Param id path int true "Template ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /config-templates/{id} [delete]
+func (h *ConfigFileHandler) DeleteTemplateHandler(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid template ID"})
+		return
+	}
+
+	if err := h.svc.DeleteTemplate(c, id); err != nil {
+		if errors.Is(err, application.ErrTemplateNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// InstantiateTemplateHandler godoc
+// @Summary Create a config file from a template
+// @Description Copy a catalog template into the project as a config file and optionally launch it right away.
+// @Tags config_templates
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param template_id path int true "Template ID"
+// @Param input body configfile.InstantiateTemplateInput false "Filename and launch flag"
+// @Success 201 {object} configfile.InstantiateTemplateOutput
+// @Failure 400 {object} response.ErrorResponse "Validation failed or project lacks GPU quota"
+// @Failure 404 {object} response.ErrorResponse "Template or project not found"
+// @Router /projects/{id}/config-templates/{template_id} [post]
+func (h *ConfigFileHandler) InstantiateTemplateHandler(c *gin.Context) {
+	projectID, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project ID"})
+		return
+	}
+	templateID, err := utils.ParseIDParam(c, "template_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid template ID"})
+		return
+	}
+
+	var input configfile.InstantiateTemplateInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	out, err := h.svc.InstantiateTemplate(c, projectID, templateID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrTemplateNotFound), errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, out)
+}
@@ -7,34 +7,49 @@ import (
 )
 
 type Handlers struct {
-	Audit      *AuditHandler
-	ConfigFile *ConfigFileHandler
-	Group      *GroupHandler
-	Project    *ProjectHandler
-	Resource   *ResourceHandler
-	UserGroup  *UserGroupHandler
-	User       *UserHandler
-	K8s        *K8sHandler
-	Form       *FormHandler
-	Job        *JobHandler
-	Image      *ImageHandler
-	Router     *gin.Engine
+	Audit       *AuditHandler
+	ConfigFile  *ConfigFileHandler
+	Group       *GroupHandler
+	Project     *ProjectHandler
+	Resource    *ResourceHandler
+	UserGroup   *UserGroupHandler
+	User        *UserHandler
+	UserImport  *UserImportHandler
+	K8s         *K8sHandler
+	Form        *FormHandler
+	Job         *JobHandler
+	JobProgress *JobProgressHandler
+	Image       *ImageHandler
+	APIToken    *APITokenHandler
+	Maintenance *MaintenanceHandler
+	Settings    *SettingsHandler
+	Activity    *ActivityHandler
+	Search      *SearchHandler
+	Router      *gin.Engine
 }
 
 func New(svc *application.Services, repos *repository.Repos, router *gin.Engine) *Handlers {
+	errorAudit = repos.Audit
 	h := &Handlers{
-		Audit:      NewAuditHandler(svc.Audit),
-		ConfigFile: NewConfigFileHandler(svc.ConfigFile),
-		Group:      NewGroupHandler(svc.Group),
-		Project:    NewProjectHandler(svc.Project),
-		Resource:   NewResourceHandler(svc.Resource),
-		UserGroup:  NewUserGroupHandler(svc.UserGroup),
-		User:       NewUserHandler(svc.User),
-		K8s:        NewK8sHandler(svc.K8s, svc.User, svc.Project),
-		Form:       NewFormHandler(svc.Form),
-		Job:        NewJobHandler(svc.Job, repos),
-		Image:      NewImageHandler(svc.Image),
-		Router:     router,
+		Audit:       NewAuditHandler(svc.Audit),
+		ConfigFile:  NewConfigFileHandler(svc.ConfigFile),
+		Group:       NewGroupHandler(svc.Group),
+		Project:     NewProjectHandler(svc.Project),
+		Resource:    NewResourceHandler(svc.Resource),
+		UserGroup:   NewUserGroupHandler(svc.UserGroup),
+		User:        NewUserHandler(svc.User),
+		UserImport:  NewUserImportHandler(svc.UserImport),
+		K8s:         NewK8sHandler(svc.K8s, svc.User, svc.Project),
+		Form:        NewFormHandler(svc.Form),
+		Job:         NewJobHandler(svc.Job, repos),
+		JobProgress: NewJobProgressHandler(svc.JobProgress),
+		Image:       NewImageHandler(svc.Image),
+		APIToken:    NewAPITokenHandler(svc.APIToken),
+		Maintenance: NewMaintenanceHandler(svc.Maintenance),
+		Settings:    NewSettingsHandler(svc.Settings),
+		Activity:    NewActivityHandler(svc.Activity),
+		Search:      NewSearchHandler(svc.Search),
+		Router:      router,
 	}
 	return h
 }
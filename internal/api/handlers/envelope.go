@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/response"
+)
+
+// legacyBody is what an endpoint answered before it moved to the SuccessResponse envelope. It
+// is served instead while config.LegacyResponseBodies is set.
+type legacyBody struct {
+	status int
+	body   interface{}
+}
+
+// respondSuccess answers with data in the SuccessResponse envelope: 200 for reads and updates,
+// 201 when something was created and 202 when the work goes on in the background. legacy,
+// when not nil, is the endpoint's previous answer.
+func respondSuccess(c *gin.Context, status int, message string, data interface{}, legacy *legacyBody) {
+	if legacy != nil && config.LegacyResponseBodies {
+		c.JSON(legacy.status, legacy.body)
+		return
+	}
+	c.JSON(status, response.SuccessResponse{Code: 0, Message: message, Data: data})
+}
+
+// respondNoContent answers a delete that has nothing to return with 204.
+func respondNoContent(c *gin.Context, legacy *legacyBody) {
+	if legacy != nil && config.LegacyResponseBodies {
+		c.JSON(legacy.status, legacy.body)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
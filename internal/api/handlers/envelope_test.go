@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/response"
+)
+
+func serveEnvelope(t *testing.T, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", handler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+func TestRespondSuccessWrapsData(t *testing.T) {
+	orig := config.LegacyResponseBodies
+	t.Cleanup(func() { config.LegacyResponseBodies = orig })
+	config.LegacyResponseBodies = false
+	list := []string{"a", "b"}
+
+	w := serveEnvelope(t, func(c *gin.Context) {
+		respondSuccess(c, http.StatusCreated, "created", list, &legacyBody{http.StatusOK, list})
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	var body struct {
+		response.SuccessResponse
+		Data []string `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Message != "created" || len(body.Data) != 2 {
+		t.Fatalf("expected the enveloped list, got %s", w.Body.String())
+	}
+
+	w = serveEnvelope(t, func(c *gin.Context) {
+		respondNoContent(c, &legacyBody{http.StatusOK, response.MessageResponse{Message: "deleted"}})
+	})
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("expected an empty 204, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRespondSuccessServesLegacyBodies(t *testing.T) {
+	orig := config.LegacyResponseBodies
+	t.Cleanup(func() { config.LegacyResponseBodies = orig })
+	config.LegacyResponseBodies = true
+	list := []string{"a", "b"}
+
+	w := serveEnvelope(t, func(c *gin.Context) {
+		respondSuccess(c, http.StatusCreated, "created", list, &legacyBody{http.StatusOK, list})
+	})
+	var bare []string
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &bare) != nil || len(bare) != 2 {
+		t.Fatalf("expected the bare list with 200, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveEnvelope(t, func(c *gin.Context) {
+		respondNoContent(c, &legacyBody{http.StatusOK, response.MessageResponse{Message: "deleted"}})
+	})
+	var msg response.MessageResponse
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &msg) != nil || msg.Message != "deleted" {
+		t.Fatalf("expected the old message with 200, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Endpoints that always used the envelope have no legacy body to fall back to
+	w = serveEnvelope(t, func(c *gin.Context) {
+		respondSuccess(c, http.StatusOK, "success", list, nil)
+	})
+	if w.Code != http.StatusOK || !json.Valid(w.Body.Bytes()) || w.Body.String()[0] != '{' {
+		t.Fatalf("expected the envelope without a legacy body, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// errorAudit receives the internal error behind coded responses. It is set by New; handlers
+// built directly, as in tests, only log it.
+var errorAudit repository.AuditRepo
+
+// respondError answers with the message for code in the caller's language. err may carry
+// database errors or cluster object names, so it only goes to the server log and the audit
+// trail, never into the response.
+func respondError(c *gin.Context, status int, code response.ErrorCode, err error) {
+	reportError(c, status, code, err)
+	c.JSON(status, response.NewErrorResponse(code, c.GetHeader("Accept-Language")))
+}
+
+// respondPodNotReady is respondError for a workload whose pod did not start, listing the
+// reasons taken from its events so the user sees why.
+func respondPodNotReady(c *gin.Context, code response.ErrorCode, err error) {
+	reportError(c, http.StatusServiceUnavailable, code, err)
+	body := response.NewErrorResponse(code, c.GetHeader("Accept-Language"))
+	var notReady *k8s.PodNotReadyError
+	if errors.As(err, &notReady) {
+		body.Reasons = notReady.Reasons
+	}
+	c.JSON(http.StatusServiceUnavailable, body)
+}
+
+// reportError sends err to the server log and the audit trail.
+func reportError(c *gin.Context, status int, code response.ErrorCode, err error) {
+	if err == nil {
+		return
+	}
+	log.Printf("[API] %s %s -> %d %s: %v", c.Request.Method, c.Request.URL.Path, status, code, err)
+	if errorAudit != nil {
+		utils.LogAuditWithConsole(c, "error", "api", c.Request.URL.Path, nil, nil, string(code)+": "+err.Error(), errorAudit)
+	}
+}
+
+// isNamespaceNotFound reports whether err is the API server rejecting a request because the
+// target namespace does not exist.
+func isNamespaceNotFound(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || !apierrors.IsNotFound(err) {
+		return false
+	}
+	details := status.Status().Details
+	return details != nil && details.Kind == "namespaces"
+}
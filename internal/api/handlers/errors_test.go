@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/types"
+	"gorm.io/gorm"
+)
+
+func submitImageRequest(t *testing.T, db *gorm.DB, acceptLanguage string) (*httptest.ResponseRecorder, response.ErrorResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := NewImageHandler(application.NewImageService(repository.NewImageRepo(db)))
+	r := gin.New()
+	r.POST("/images/requests", func(c *gin.Context) {
+		c.Set("claims", &types.Claims{UserID: memberID})
+		h.SubmitRequest(c)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/images/requests", strings.NewReader(`{"name":"pytorch/pytorch","tag":"2.3"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", acceptLanguage)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body response.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not an error body: %s", w.Body.String())
+	}
+	return w, body
+}
+
+func openImageDB(t *testing.T, migrate bool) *gorm.DB {
+	t.Helper()
+	if !migrate {
+		return dbtest.Open(t)
+	}
+	return dbtest.Open(t, &image.ContainerRepository{}, &image.ContainerTag{}, &image.ImageAllowList{}, &image.ImageRequest{})
+}
+
+func TestRespondErrorLocalizesKnownCode(t *testing.T) {
+	db := openImageDB(t, true)
+	repo := image.ContainerRepository{Name: "pytorch", Namespace: "pytorch", FullName: "pytorch/pytorch"}
+	db.Create(&repo)
+	db.Create(&image.ImageAllowList{RepositoryID: repo.ID, IsEnabled: true})
+
+	w, body := submitImageRequest(t, db, "zh-TW,zh;q=0.9,en;q=0.8")
+	if w.Code != http.StatusBadRequest || body.Code != response.CodeImageAlreadyAllowed {
+		t.Fatalf("expected %s, got %d %+v", response.CodeImageAlreadyAllowed, w.Code, body)
+	}
+	if body.Error != response.Localize(response.CodeImageAlreadyAllowed, response.LangTraditionalChinese) {
+		t.Fatalf("expected the zh-TW message, got %q", body.Error)
+	}
+
+	_, body = submitImageRequest(t, db, "")
+	if body.Error != response.Localize(response.CodeImageAlreadyAllowed, response.LangEnglish) {
+		t.Fatalf("expected English without Accept-Language, got %q", body.Error)
+	}
+}
+
+func TestRespondErrorHidesDatabaseErrors(t *testing.T) {
+	// Without the image tables every query fails inside GORM
+	db := openImageDB(t, false)
+
+	w, body := submitImageRequest(t, db, "en")
+	if w.Code != http.StatusInternalServerError || body.Code != response.CodeInternal {
+		t.Fatalf("expected %s, got %d %+v", response.CodeInternal, w.Code, body)
+	}
+	for _, leak := range []string{"no such table", "image_allow_lists", "SELECT", "container_repositories"} {
+		if strings.Contains(w.Body.String(), leak) {
+			t.Fatalf("response leaks the database error (%q): %s", leak, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// projectRoleTTL bounds how long the storage proxy trusts a cached project role, so a demoted
+// member loses write access within this window without a lookup on every proxied request.
+const projectRoleTTL = 30 * time.Second
+
+// fileBrowserServiceURL is the in-cluster address of a FileBrowser service; tests override it.
+var fileBrowserServiceURL = func(serviceName, namespace string) string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local:80", serviceName, namespace)
+}
+
+// fileBrowserSessionPaths are POST endpoints FileBrowser calls to issue its own session token.
+// They do not modify files, so read-only users must still reach them.
+var fileBrowserSessionPaths = []string{"/api/login", "/api/renew"}
+
+type projectRoleKey struct {
+	uid uint
+	pid uint
+}
+
+type projectRoleEntry struct {
+	role      string
+	expiresAt time.Time
+}
+
+// projectRoleCache memoizes GetUserRoleInProjectGroup lookups for the storage proxy.
+type projectRoleCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[projectRoleKey]projectRoleEntry
+}
+
+func newProjectRoleCache(ttl time.Duration) *projectRoleCache {
+	return &projectRoleCache{ttl: ttl, entries: make(map[projectRoleKey]projectRoleEntry)}
+}
+
+func (rc *projectRoleCache) get(uid, pid uint, lookup func(uid, pid uint) (string, error)) (string, error) {
+	key := projectRoleKey{uid: uid, pid: pid}
+	now := time.Now()
+
+	rc.mu.Lock()
+	if e, ok := rc.entries[key]; ok && now.Before(e.expiresAt) {
+		rc.mu.Unlock()
+		return e.role, nil
+	}
+	rc.mu.Unlock()
+
+	role, err := lookup(uid, pid)
+	if err != nil {
+		return "", err
+	}
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		role = "user"
+	}
+
+	rc.mu.Lock()
+	rc.entries[key] = projectRoleEntry{role: role, expiresAt: now.Add(rc.ttl)}
+	rc.mu.Unlock()
+	return role, nil
+}
+
+// canWriteProjectStorage reports whether a project role may modify files in the project drive.
+func canWriteProjectStorage(role string) bool {
+	return role == "admin" || role == "manager"
+}
+
+// fileBrowserRequestAllowed reports whether a proxied FileBrowser request is permitted for the
+// caller. Read-only callers may only issue safe methods and FileBrowser's session endpoints.
+func fileBrowserRequestAllowed(method, path string, readOnly bool) bool {
+	if !readOnly {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		for _, p := range fileBrowserSessionPaths {
+			if path == p {
+				return true
+			}
+		}
+	}
+	return false
+}
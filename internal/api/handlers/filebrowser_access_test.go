@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/internal/repository/mock"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+const (
+	memberID  = 2
+	managerID = 3
+)
+
+func setupStorageProxy(t *testing.T) (*httptest.Server, *int32, *int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+
+	projects := mock.NewMockProjectRepo(ctrl)
+	projects.EXPECT().GetProjectByID(uint(7)).Return(project.Project{PID: 7, ProjectName: "vision", GID: 5}, nil).AnyTimes()
+	projects.EXPECT().GetGroupIDByProjectID(uint(7)).Return(uint(5), nil).AnyTimes()
+
+	var lookups int32
+	userGroups := mock.NewMockUserGroupRepo(ctrl)
+	userGroups.EXPECT().GetUserRoleInGroup(gomock.Any(), uint(5)).DoAndReturn(func(uid, gid uint) (string, error) {
+		atomic.AddInt32(&lookups, 1)
+		if uid == managerID {
+			return "Manager", nil
+		}
+		return "user", nil
+	}).AnyTimes()
+
+	var forwarded int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&forwarded, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(backend.Close)
+	origURL := fileBrowserServiceURL
+	t.Cleanup(func() { fileBrowserServiceURL = origURL })
+	fileBrowserServiceURL = func(string, string) string { return backend.URL }
+
+	repos := &repository.Repos{Project: projects, UserGroup: userGroups}
+	h := NewK8sHandler(nil, nil, application.NewProjectService(repos))
+
+	r := gin.New()
+	r.Any("/k8s/storage/projects/:id/proxy/*path", func(c *gin.Context) {
+		var uid uint = memberID
+		if c.GetHeader("X-Test-User") == "manager" {
+			uid = managerID
+		}
+		c.Set("claims", &types.Claims{UserID: uid})
+	}, h.ProjectStorageProxy)
+
+	// A real server: the reverse proxy needs a ResponseWriter that supports CloseNotify
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, &forwarded, &lookups
+}
+
+func proxyRequest(t *testing.T, srv *httptest.Server, method, path, user string) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, srv.URL+"/k8s/storage/projects/7/proxy"+path, strings.NewReader("data"))
+	req.Header.Set("X-Test-User", user)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestProjectStorageProxyBlocksMemberWrites(t *testing.T) {
+	srv, forwarded, lookups := setupStorageProxy(t)
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete, http.MethodPost, http.MethodPatch} {
+		if code := proxyRequest(t, srv, method, "/api/resources/train.py", "member"); code != http.StatusForbidden {
+			t.Fatalf("member %s should be forbidden, got %d", method, code)
+		}
+	}
+	if n := atomic.LoadInt32(forwarded); n != 0 {
+		t.Fatalf("blocked requests must not reach FileBrowser, %d forwarded", n)
+	}
+
+	// Reads and FileBrowser's own session endpoint stay available to members
+	if code := proxyRequest(t, srv, http.MethodGet, "/api/resources/train.py", "member"); code != http.StatusOK {
+		t.Fatalf("member GET should pass, got %d", code)
+	}
+	if code := proxyRequest(t, srv, http.MethodPost, "/api/login", "member"); code != http.StatusOK {
+		t.Fatalf("member login should pass, got %d", code)
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		if code := proxyRequest(t, srv, method, "/api/resources/train.py", "manager"); code != http.StatusOK {
+			t.Fatalf("manager %s should pass, got %d", method, code)
+		}
+	}
+
+	// One lookup per user thanks to the role cache
+	if n := atomic.LoadInt32(lookups); n != 2 {
+		t.Fatalf("expected roles to be cached per user, got %d lookups", n)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/internal/repository/mock"
+)
+
+func TestGetGPUConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	projects := mock.NewMockProjectRepo(ctrl)
+	projects.EXPECT().GetProjectByID(uint(7)).Return(project.Project{PID: 7, GPUQuota: 8, MPSMemory: 4096}, nil).AnyTimes()
+	// 10 pods of 8000MB do not fit in the 48000MB of a GPU
+	projects.EXPECT().GetProjectByID(uint(8)).Return(project.Project{PID: 8, GPUQuota: 4, MPSMemory: 8000}, nil).AnyTimes()
+	projects.EXPECT().GetProjectByID(uint(9)).Return(project.Project{}, errors.New("record not found")).AnyTimes()
+
+	h := NewProjectHandler(application.NewProjectService(&repository.Repos{Project: projects}))
+	r := gin.New()
+	r.GET("/projects/:id/gpu-config", h.GetGPUConfig)
+	get := func(url string) (int, application.GPUConfig) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var cfg application.GPUConfig
+		_ = json.Unmarshal(w.Body.Bytes(), &cfg)
+		return w.Code, cfg
+	}
+
+	code, cfg := get("/projects/7/gpu-config?units=3")
+	if code != http.StatusOK || !cfg.Valid || cfg.Units != 3 || cfg.ThreadPercentage != 30 {
+		t.Fatalf("expected 30%% of the SMs for 3 units, got %d %+v", code, cfg)
+	}
+	if cfg.Env["CUDA_MPS_ACTIVE_THREAD_PERCENTAGE"] != "30" || cfg.Env["CUDA_MPS_PINNED_DEVICE_MEM_LIMIT"] != "4294967296" {
+		t.Fatalf("expected the thread percentage and memory limit in the env, got %v", cfg.Env)
+	}
+	if _, cfg = get("/projects/7/gpu-config"); cfg.Units != 8 || cfg.ThreadPercentage != 80 {
+		t.Fatalf("expected the whole quota by default, got %+v", cfg)
+	}
+	if _, cfg = get("/projects/7/gpu-config?units=50"); cfg.Units != 8 {
+		t.Fatalf("expected a request over the quota capped at it, got %+v", cfg)
+	}
+
+	// A limit stored before it was checked is reported, not rejected
+	code, cfg = get("/projects/8/gpu-config")
+	if code != http.StatusOK || !cfg.Valid || len(cfg.Warnings) != 1 {
+		t.Fatalf("expected the oversubscribed VRAM reported as a warning, got %d %+v", code, cfg)
+	}
+
+	if code, _ = get("/projects/7/gpu-config?units=abc"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid units, got %d", code)
+	}
+	if code, _ = get("/projects/9/gpu-config"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown project, got %d", code)
+	}
+}
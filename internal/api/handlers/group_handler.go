@@ -105,6 +105,9 @@ func (h *GroupHandler) CreateGroup(c *gin.Context) {
 // @Param id path int true "Group ID"
 // @Param group_name formData string false "Group name"
 // @Param description formData string false "Description"
+// @Param hub_storage_size formData string false "Default personal hub size for members, e.g. 500Gi"
+// @Param storage_class_name formData string false "Default storage class for members' hubs and project storages"
+// @Param project_storage_size formData string false "Default capacity of new project storages"
 // @Success 200 {object} models.Group
 // @Failure 400 {object} response.ErrorResponse "Bad request"
 // @Failure 404 {object} response.ErrorResponse "Group not found"
@@ -127,6 +130,8 @@ func (h *GroupHandler) UpdateGroup(c *gin.Context) {
 	if err != nil {
 		if err == application.ErrReservedGroupName {
 			c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+		} else if errors.Is(err, application.ErrInvalidStorageSize) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		} else if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "group not found"})
 		} else {
@@ -171,3 +176,35 @@ func (h *GroupHandler) DeleteGroup(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response.MessageResponse{Message: "Group deleted"})
 }
+
+// GetGroupDashboard godoc
+// @Summary Group dashboard
+// @Description Job counts, GPU usage against quota, provisioned project storage and the latest audit events over all projects of the group
+// @Tags groups
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Group ID"
+// @Success 200 {object} group.GroupDashboardDTO
+// @Failure 400 {object} response.ErrorResponse "Invalid group id"
+// @Failure 403 {object} response.ErrorResponse "Not a manager of the group"
+// @Failure 404 {object} response.ErrorResponse "Group not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /groups/{id}/dashboard [get]
+func (h *GroupHandler) GetGroupDashboard(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid group id"})
+		return
+	}
+
+	dash, err := h.svc.GetGroupDashboard(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "group not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, dash)
+}
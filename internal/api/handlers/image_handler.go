@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	imageref "github.com/linskybing/platform-go/pkg/image"
+	"github.com/linskybing/platform-go/pkg/pagination"
 	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/types"
 	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
 )
 
 type ImageHandler struct {
@@ -51,11 +57,15 @@ func (h *ImageHandler) SubmitRequest(c *gin.Context) {
 	req, err := h.service.SubmitRequest(uid, payload.Registry, payload.Name, payload.Tag, payload.ProjectID)
 	if err != nil {
 		// If image is already allowed, return 400 Bad Request so frontend can show a proper message
-		if strings.Contains(err.Error(), "already allowed") {
-			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		if errors.Is(err, application.ErrImageAlreadyAllowed) {
+			respondError(c, http.StatusBadRequest, response.CodeImageAlreadyAllowed, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		if errors.Is(err, imageref.ErrInvalidReference) {
+			respondError(c, http.StatusBadRequest, response.CodeInvalidImage, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 		return
 	}
 
@@ -82,7 +92,12 @@ func (h *ImageHandler) SubmitRequest(c *gin.Context) {
 // @Produce json
 // @Param status query string false "Filter by status (pending, approved, rejected)"
 // @Param project_id query int false "Filter by Project ID"
+// @Param limit query int false "Page size; returns a page envelope (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param total query bool false "Include the total count in a page envelope"
 // @Success 200 {object} response.SuccessResponse{data=[]image.ImageRequest}
+// @Failure 400 {object} response.ErrorResponse "Invalid cursor"
+// @Failure 403 {object} response.ErrorResponse "Not a member of the project"
 // @Failure 500 {object} response.ErrorResponse
 // @Router /images/requests [get]
 func (h *ImageHandler) ListRequests(c *gin.Context) {
@@ -95,30 +110,11 @@ func (h *ImageHandler) ListRequests(c *gin.Context) {
 			projectID = &pid
 		}
 	}
-
-	reqs, err := h.service.ListRequests(projectID, status)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+	if !h.authorizeProject(c, projectID) {
 		return
 	}
 
-	// Normalize backend domain fields to frontend-friendly keys
-	out := make([]map[string]interface{}, 0, len(reqs))
-	for _, r := range reqs {
-		created := r.CreatedAt
-		out = append(out, map[string]interface{}{
-			"ID":        r.ID,
-			"UserID":    r.UserID,
-			"Name":      r.InputImageName,
-			"Tag":       r.InputTag,
-			"ProjectID": r.ProjectID,
-			"Status":    r.Status,
-			"Note":      r.ReviewerNote,
-			"CreatedAt": created.Format(time.RFC3339),
-		})
-	}
-
-	c.JSON(http.StatusOK, response.SuccessResponse{Data: out})
+	h.respondRequests(c, projectID, status)
 }
 
 // ListRequestsByProject lists image requests for a specific project (project members)
@@ -141,30 +137,56 @@ func (h *ImageHandler) ListRequestsByProject(c *gin.Context) {
 		status = "pending"
 	}
 	pid := uint(id)
-	reqs, err := h.service.ListRequests(&pid, status)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+	if !h.authorizeProject(c, &pid) {
 		return
 	}
+	h.respondRequests(c, &pid, status)
+}
 
+// respondRequests answers the image request listings. Clients sending ?limit= or ?cursor= get
+// a page envelope, the others the whole list.
+func (h *ImageHandler) respondRequests(c *gin.Context, projectID *uint, status string) {
+	if pagination.Requested(c) {
+		p, err := pagination.FromRequest(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, response.CodeInvalidCursor, err)
+			return
+		}
+		page, err := h.service.ListRequestsPage(projectID, status, p)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+			return
+		}
+		c.JSON(http.StatusOK, response.SuccessResponse{Data: pagination.Map(page, imageRequestView)})
+		return
+	}
+
+	reqs, err := h.service.ListRequests(projectID, status)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
 	out := make([]map[string]interface{}, 0, len(reqs))
 	for _, r := range reqs {
-		created := r.CreatedAt
-		out = append(out, map[string]interface{}{
-			"ID":        r.ID,
-			"UserID":    r.UserID,
-			"Name":      r.InputImageName,
-			"Tag":       r.InputTag,
-			"ProjectID": r.ProjectID,
-			"Status":    r.Status,
-			"Note":      r.ReviewerNote,
-			"CreatedAt": created.Format(time.RFC3339),
-		})
+		out = append(out, imageRequestView(r))
 	}
-
 	c.JSON(http.StatusOK, response.SuccessResponse{Data: out})
 }
 
+// imageRequestView normalizes backend domain fields to frontend-friendly keys
+func imageRequestView(r image.ImageRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"ID":        r.ID,
+		"UserID":    r.UserID,
+		"Name":      r.InputImageName,
+		"Tag":       r.InputTag,
+		"ProjectID": r.ProjectID,
+		"Status":    r.Status,
+		"Note":      r.ReviewerNote,
+		"CreatedAt": r.CreatedAt.Format(time.RFC3339),
+	}
+}
+
 // @Summary Approve image request
 // @Description Admin approves an image request
 // @Tags Images
@@ -186,6 +208,10 @@ func (h *ImageHandler) ApproveRequest(c *gin.Context) {
 	var payload struct {
 		Note     string `json:"note"`
 		IsGlobal bool   `json:"is_global"`
+		Verify   bool   `json:"verify"`
+		Force    bool   `json:"force"`
+		// Warm the GPU nodes' image cache after each pull; omitted follows the server default
+		Preload *bool `json:"preload"`
 	}
 	_ = c.ShouldBindJSON(&payload)
 
@@ -195,11 +221,67 @@ func (h *ImageHandler) ApproveRequest(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.ApproveRequest(uint(id), payload.Note, payload.IsGlobal, approverID); err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+	if !payload.Verify {
+		if err := h.service.ApproveRequest(uint(id), payload.Note, payload.IsGlobal, approverID, payload.Preload); err != nil {
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+			return
+		}
+		c.JSON(http.StatusOK, response.SuccessResponse{Message: "Request approved"})
 		return
 	}
-	c.JSON(http.StatusOK, response.SuccessResponse{Message: "Request approved"})
+
+	req, err := h.service.ApproveVerifiedRequest(c.Request.Context(), uint(id), payload.Note, payload.IsGlobal, approverID, payload.Force, payload.Preload)
+	if err != nil {
+		if errors.Is(err, application.ErrImageNotFoundUpstream) {
+			c.JSON(http.StatusUnprocessableEntity, response.ErrorResponse{Error: fmt.Sprintf("%s:%s was not found upstream; approve with force to override", req.InputImageName, req.InputTag)})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	msg := "Request approved"
+	if req.VerifyStatus != application.VerifyStatusVerified {
+		msg = fmt.Sprintf("Request approved, but the image could not be verified (%s)", req.VerifyStatus)
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Message: msg, Data: verificationOutput(req)})
+}
+
+// @Summary Verify image request
+// @Description Admin checks that the requested image exists in its upstream registry and stores the digest and size on the request
+// @Tags Images
+// @Produce json
+// @Param id path int true "Request ID"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /image-requests/{id}/verify [post]
+func (h *ImageHandler) VerifyRequest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid id"})
+		return
+	}
+	req, err := h.service.VerifyRequest(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: verificationOutput(req)})
+}
+
+func verificationOutput(req *image.ImageRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"ID":         req.ID,
+		"Name":       req.InputImageName,
+		"Tag":        req.InputTag,
+		"Status":     req.Status,
+		"Exists":     req.VerifyStatus == application.VerifyStatusVerified,
+		"Verify":     req.VerifyStatus,
+		"Digest":     req.VerifiedDigest,
+		"Size":       req.VerifiedSize,
+		"Error":      req.VerifyError,
+		"VerifiedAt": req.VerifiedAt,
+	}
 }
 
 // @Summary Reject image request
@@ -233,7 +315,7 @@ func (h *ImageHandler) RejectRequest(c *gin.Context) {
 
 	req, err := h.service.RejectRequest(uint(id), payload.Note, approverID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 		return
 	}
 
@@ -253,14 +335,24 @@ func (h *ImageHandler) RejectRequest(c *gin.Context) {
 	c.JSON(http.StatusOK, response.SuccessResponse{Data: out})
 }
 
+// AllowedImagesView is the allowed images listing with the caller's own pending requests.
+type AllowedImagesView struct {
+	Images          []image.AllowedImageDTO  `json:"images"`
+	PendingRequests []map[string]interface{} `json:"pending_requests"`
+}
+
 // @Summary List allowed images
-// @Description List allowed images for a project or globally
+// @Description List allowed images for a project or globally. Users other than admins may only list the projects they are members of; without a project they see the global images only.
 // @Tags Images
 // @Accept json
 // @Produce json
 // @Param id path int false "Project ID (optional path param)"
 // @Param project_id query int false "Project ID (optional query param)"
+// @Param search query string false "Only images whose name contains this, ignoring case"
+// @Param include_pending query bool false "Return {images, pending_requests} with the caller's own pending requests"
 // @Success 200 {object} response.SuccessResponse{data=[]image.AllowedImageDTO}
+// @Failure 403 {object} response.ErrorResponse "Not a member of the project"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
 // @Failure 500 {object} response.ErrorResponse
 // @Router /images/allowed [get]
 func (h *ImageHandler) ListAllowed(c *gin.Context) {
@@ -276,13 +368,61 @@ func (h *ImageHandler) ListAllowed(c *gin.Context) {
 			projectID = &pid
 		}
 	}
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
 
-	imgs, err := h.service.ListAllowedImages(projectID)
+	search := c.Query("search")
+	imgs, err := h.service.ListAllowedImagesFor(uid, projectID, search)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		respondProjectAccessError(c, err)
+		return
+	}
+	if c.Query("include_pending") != "true" {
+		c.JSON(http.StatusOK, response.SuccessResponse{Data: imgs})
 		return
 	}
-	c.JSON(http.StatusOK, response.SuccessResponse{Data: imgs})
+
+	pending, err := h.service.ListPendingRequestsOf(uid, projectID, search)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	view := AllowedImagesView{Images: imgs, PendingRequests: make([]map[string]interface{}, 0, len(pending))}
+	if view.Images == nil {
+		view.Images = []image.AllowedImageDTO{}
+	}
+	for _, r := range pending {
+		view.PendingRequests = append(view.PendingRequests, imageRequestView(r))
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: view})
+}
+
+// authorizeProject answers 403 unless the caller may list the requests of projectID.
+func (h *ImageHandler) authorizeProject(c *gin.Context, projectID *uint) bool {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return false
+	}
+	if err := h.service.CheckProjectAccess(uid, projectID); err != nil {
+		respondProjectAccessError(c, err)
+		return false
+	}
+	return true
+}
+
+func respondProjectAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, application.ErrImageProjectDenied):
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, application.ErrProjectNotFound):
+		respondError(c, http.StatusNotFound, response.CodeNotFound, err)
+	default:
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+	}
 }
 
 // @Summary Add image to project
@@ -322,7 +462,7 @@ func (h *ImageHandler) AddProjectImage(c *gin.Context) {
 
 	err = h.service.AddProjectImage(uid, uint(projectID), payload.Name, payload.Tag)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 		return
 	}
 	c.JSON(http.StatusCreated, response.SuccessResponse{
@@ -349,7 +489,7 @@ func (h *ImageHandler) RemoveProjectImage(c *gin.Context) {
 	}
 
 	if err := h.service.DisableAllowListRule(uint(imageID)); err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 		return
 	}
 	c.JSON(http.StatusOK, response.SuccessResponse{Message: "image rule disabled"})
@@ -368,6 +508,8 @@ func (h *ImageHandler) RemoveProjectImage(c *gin.Context) {
 func (h *ImageHandler) PullImage(c *gin.Context) {
 	var payload struct {
 		Names []string `json:"names" binding:"required"`
+		// Pull with the registry credentials of this project
+		ProjectID uint `json:"project_id"`
 	}
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
@@ -381,25 +523,25 @@ func (h *ImageHandler) PullImage(c *gin.Context) {
 
 	var requests []PullRequest
 	for _, fullImage := range payload.Names {
-		var name, tag string
-
-		lastColon := strings.LastIndex(fullImage, ":")
-		if lastColon > 0 {
-			name = fullImage[:lastColon]
-			tag = fullImage[lastColon+1:]
-		} else {
-			name = fullImage
-			tag = "latest"
+		ref, err := imageref.Parse(fullImage)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, response.CodeInvalidImage, err)
+			return
 		}
+		requests = append(requests, PullRequest{Name: ref.FamiliarName(), Tag: ref.Version()})
+	}
 
-		requests = append(requests, PullRequest{Name: name, Tag: tag})
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
 	}
 
 	var jobIDs []string
 	for _, req := range requests {
-		jobID, err := h.service.PullImageAsync(req.Name, req.Tag)
+		jobID, err := h.service.PullProjectImageAsync(req.Name, req.Tag, uid, payload.ProjectID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 			return
 		}
 		jobIDs = append(jobIDs, jobID)
@@ -437,6 +579,41 @@ func (h *ImageHandler) GetPullJobStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, response.SuccessResponse{Data: status})
 }
 
+// @Summary Cancel pull job
+// @Description Cancel an in-progress or queued image pull (admin or the original requester)
+// @Tags Images
+// @Produce json
+// @Param job_id path string true "Job ID"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /images/pulls/{job_id} [delete]
+func (h *ImageHandler) CancelPullJob(c *gin.Context) {
+	jobID := c.Param("job_id")
+	claimsVal, exists := c.Get("claims")
+	claims, ok := claimsVal.(*types.Claims)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	err := h.service.CancelPullJob(c.Request.Context(), jobID, claims.UserID, claims.IsAdmin)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, response.SuccessResponse{Message: "pull job cancelled"})
+	case errors.Is(err, application.ErrPullJobNotFound):
+		respondError(c, http.StatusNotFound, response.CodePullJobNotFound, err)
+	case errors.Is(err, application.ErrPullJobFinished):
+		respondError(c, http.StatusConflict, response.CodePullJobFinished, err)
+	case errors.Is(err, application.ErrPullJobForbidden):
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+	default:
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+	}
+}
+
 // @Summary List failed pull jobs
 // @Description Get a list of recently failed image pull jobs
 // @Tags Images
@@ -469,6 +646,39 @@ func (h *ImageHandler) GetActivePullJobs(c *gin.Context) {
 	c.JSON(http.StatusOK, response.SuccessResponse{Data: activeJobs})
 }
 
+// @Summary Settle stale pull jobs
+// @Description Runs the pull janitor now: tracked pulls without a status update for a while are checked against their Job, failed as lost when it is gone and given its outcome when it finished.
+// @Tags Images
+// @Produce json
+// @Success 200 {object} response.SuccessResponse{data=[]application.PullSweepResult}
+// @Failure 500 {object} response.ErrorResponse
+// @Router /images/pulls/janitor [post]
+func (h *ImageHandler) SweepPullJobs(c *gin.Context) {
+	results, err := h.service.SweepPullJobs(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: results})
+}
+
+// @Summary List image usage
+// @Description Lists every allow-listed image with its pull status, when running pods last used it and how many pods ran it at the last scan.
+// @Tags Images
+// @Produce json
+// @Param sort query string false "name (default), stale (least recently seen first) or recent"
+// @Success 200 {object} response.SuccessResponse{data=[]image.ImageUsageDTO}
+// @Failure 500 {object} response.ErrorResponse
+// @Router /images/usage [get]
+func (h *ImageHandler) ListImageUsage(c *gin.Context) {
+	usage, err := h.service.ListImageUsage(c.DefaultQuery("sort", application.ImageUsageSortName))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: usage})
+}
+
 // @Summary Delete allowed image rule
 // @Description Disable a global allowed image rule
 // @Tags Images
@@ -477,6 +687,8 @@ func (h *ImageHandler) GetActivePullJobs(c *gin.Context) {
 // @Param id path int true "Allow List Rule ID"
 // @Success 200 {object} response.SuccessResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "Image seen running within the in-use window"
 // @Failure 500 {object} response.ErrorResponse
 // @Router /images/allowed/{id} [delete]
 func (h *ImageHandler) DeleteAllowedImage(c *gin.Context) {
@@ -486,7 +698,14 @@ func (h *ImageHandler) DeleteAllowedImage(c *gin.Context) {
 		return
 	}
 	if err := h.service.DisableAllowListRule(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			respondError(c, http.StatusNotFound, response.CodeNotFound, err)
+		case errors.Is(err, application.ErrImageInUse):
+			respondError(c, http.StatusConflict, response.CodeImageInUse, err)
+		default:
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		}
 		return
 	}
 	c.JSON(http.StatusOK, response.SuccessResponse{Message: "image rule disabled"})
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/internal/repository/mock"
+	"github.com/linskybing/platform-go/pkg/types"
+	"gorm.io/gorm"
+)
+
+// setupImageListing serves the image listings to memberID, a member of project 7 but not of
+// project 8. Both projects have a private image next to the global nginx.
+func setupImageListing(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := openImageDB(t, true)
+	for i, name := range []string{"nginx", "vision/detector", "finance/ledger"} {
+		repo := image.ContainerRepository{Name: name, FullName: name}
+		db.Create(&repo)
+		rule := image.ImageAllowList{RepositoryID: repo.ID, IsEnabled: true}
+		if i > 0 {
+			pid := uint(6 + i)
+			rule.ProjectID = &pid
+		}
+		db.Create(&rule)
+	}
+	vision := uint(7)
+	db.Create(&image.ImageRequest{UserID: memberID, ProjectID: &vision, InputImageName: "vision/tracker", InputTag: "1", Status: "pending"})
+	db.Create(&image.ImageRequest{UserID: managerID, ProjectID: &vision, InputImageName: "vision/segmenter", InputTag: "1", Status: "pending"})
+
+	ctrl := gomock.NewController(t)
+	projects := mock.NewMockProjectRepo(ctrl)
+	projects.EXPECT().GetGroupIDByProjectID(uint(7)).Return(uint(5), nil).AnyTimes()
+	projects.EXPECT().GetGroupIDByProjectID(uint(8)).Return(uint(6), nil).AnyTimes()
+	userGroups := mock.NewMockUserGroupRepo(ctrl)
+	userGroups.EXPECT().IsSuperAdmin(uint(memberID)).Return(false, nil).AnyTimes()
+	userGroups.EXPECT().GetUserRoleInGroup(uint(memberID), uint(5)).Return("user", nil).AnyTimes()
+	userGroups.EXPECT().GetUserRoleInGroup(uint(memberID), uint(6)).Return("", gorm.ErrRecordNotFound).AnyTimes()
+
+	h := NewImageHandler(application.NewImageService(repository.NewImageRepo(db)).WithProjectAccess(projects, userGroups))
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("claims", &types.Claims{UserID: memberID})
+	})
+	r.GET("/images/allowed", h.ListAllowed)
+	r.GET("/projects/:id/image-requests", h.ListRequestsByProject)
+	return r
+}
+
+func getImageListing(t *testing.T, r *gin.Engine, path string, data interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code == http.StatusOK && data != nil {
+		body := struct {
+			Data interface{} `json:"data"`
+		}{Data: data}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid body %s: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestListAllowedDeniesOtherProjects(t *testing.T) {
+	r := setupImageListing(t)
+
+	if code := getImageListing(t, r, "/images/allowed?project_id=8", nil); code != http.StatusForbidden {
+		t.Fatalf("expected the images of another project to be denied, got %d", code)
+	}
+	if code := getImageListing(t, r, "/projects/8/image-requests", nil); code != http.StatusForbidden {
+		t.Fatalf("expected the requests of another project to be denied, got %d", code)
+	}
+
+	var global []image.AllowedImageDTO
+	if code := getImageListing(t, r, "/images/allowed", &global); code != http.StatusOK || len(global) != 1 || global[0].ImageName != "nginx" {
+		t.Fatalf("expected only the global image without a project, got %d %+v", code, global)
+	}
+	var own []image.AllowedImageDTO
+	if code := getImageListing(t, r, "/images/allowed?project_id=7", &own); code != http.StatusOK || len(own) != 2 {
+		t.Fatalf("expected the global and own project images, got %d %+v", code, own)
+	}
+}
+
+func TestListAllowedSearchAndPendingRequests(t *testing.T) {
+	r := setupImageListing(t)
+
+	var found []image.AllowedImageDTO
+	if code := getImageListing(t, r, "/images/allowed?project_id=7&search=DETECT", &found); code != http.StatusOK || len(found) != 1 || found[0].ImageName != "vision/detector" {
+		t.Fatalf("expected the search to match the detector only, got %d %+v", code, found)
+	}
+
+	var view AllowedImagesView
+	if code := getImageListing(t, r, "/images/allowed?project_id=7&search=vision&include_pending=true", &view); code != http.StatusOK {
+		t.Fatalf("expected the listing with pending requests, got %d", code)
+	}
+	if len(view.Images) != 1 || len(view.PendingRequests) != 1 || view.PendingRequests[0]["Name"] != "vision/tracker" {
+		t.Fatalf("expected the detector and only the caller's own pending request, got %+v", view)
+	}
+}
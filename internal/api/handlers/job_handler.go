@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/linskybing/platform-go/internal/api/middleware"
+	"github.com/linskybing/platform-go/internal/application"
 	appjob "github.com/linskybing/platform-go/internal/application/job"
 	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/pagination"
 	"github.com/linskybing/platform-go/pkg/response"
 	"github.com/linskybing/platform-go/pkg/utils"
 )
@@ -44,11 +48,13 @@ func (h *JobHandler) CreateJob(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
+	middleware.SetActivityEntity(c, job.ID)
 
 	c.JSON(http.StatusCreated, response.SuccessResponse{Code: 0, Message: "created", Data: job})
 }
 
-// ListJobs lists jobs for the user; super admin can view all.
+// ListJobs lists jobs for the user; super admin can view all. ?limit= or ?cursor= return a page
+// envelope newest first instead of the whole list.
 func (h *JobHandler) ListJobs(c *gin.Context) {
 	uid, err := utils.GetUserIDFromContext(c)
 	if err != nil {
@@ -62,6 +68,21 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 		return
 	}
 
+	if pagination.Requested(c) {
+		p, err := pagination.FromRequest(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, response.CodeInvalidCursor, err)
+			return
+		}
+		page, err := h.svc.ListJobsPage(c.Request.Context(), uid, isAdmin, p)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: page})
+		return
+	}
+
 	jobs, err := h.svc.ListJobs(c.Request.Context(), uid, isAdmin)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
@@ -116,11 +137,13 @@ func (h *JobHandler) CancelJob(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
+	utils.LogAuditWithConsole(c, "cancel", application.JobAuditResource, application.JobAuditID(id), nil, nil, "cancelled by an admin", h.repos.Audit)
 
 	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "cancelled"})
 }
 
-// RestartJob restarts a job, optionally from a checkpoint.
+// RestartJob puts a job back in the queue, optionally from a checkpoint; the scheduler starts it
+// later, hence 202.
 func (h *JobHandler) RestartJob(c *gin.Context) {
 	id, err := utils.ParseIDParam(c, "id")
 	if err != nil {
@@ -143,8 +166,14 @@ func (h *JobHandler) RestartJob(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
+	msg := "requeued"
+	if checkpointID != nil {
+		msg = fmt.Sprintf("requeued from checkpoint %d", *checkpointID)
+	}
+	utils.LogAuditWithConsole(c, "restart", application.JobAuditResource, application.JobAuditID(id), nil, nil, msg, h.repos.Audit)
 
-	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "restarted"})
+	respondSuccess(c, http.StatusAccepted, "restarted", nil,
+		&legacyBody{http.StatusOK, response.SuccessResponse{Code: 0, Message: "restarted"}})
 }
 
 // GetJobLogs returns job logs.
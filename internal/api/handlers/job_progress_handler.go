@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+type JobProgressHandler struct {
+	svc *application.JobProgressService
+}
+
+func NewJobProgressHandler(svc *application.JobProgressService) *JobProgressHandler {
+	return &JobProgressHandler{svc: svc}
+}
+
+// JobEvent is one message of the job events stream.
+type JobEvent struct {
+	Type     string        `json:"type"`
+	Progress *job.Progress `json:"progress,omitempty"`
+}
+
+// ReportProgress godoc
+// @Summary Report job progress
+// @Description Called by the job itself with the token in its PLATFORM_JOB_TOKEN environment variable. Each report replaces the previous one. The percent is derived from step and total_steps when omitted. At most one report is accepted every 10 seconds per job.
+// @Tags k8s
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <PLATFORM_JOB_TOKEN>"
+// @Param id path int true "Job ID"
+// @Param progress body job.Progress true "Progress"
+// @Success 200 {object} response.SuccessResponse{data=job.Progress}
+// @Failure 400 {object} response.ErrorResponse "Invalid progress"
+// @Failure 401 {object} response.ErrorResponse "Invalid job token"
+// @Failure 409 {object} response.ErrorResponse "Job already finished"
+// @Failure 429 {object} response.ErrorResponse "Reported too frequently"
+// @Router /k8s/jobs/{id}/progress [post]
+func (h *JobProgressHandler) ReportProgress(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid job id"})
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "job token is required"})
+		return
+	}
+	var input job.Progress
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	progress, err := h.svc.Report(id, token, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrJobTokenInvalid):
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrJobProgressInvalid):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrJobFinished):
+			c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrJobProgressTooFrequent):
+			c.Header("Retry-After", strconv.Itoa(int(application.JobProgressInterval.Seconds())))
+			c.JSON(http.StatusTooManyRequests, response.ErrorResponse{Error: err.Error()})
+		default:
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: progress})
+}
+
+// StreamJobEvents godoc
+// @Summary Stream job events
+// @Description WebSocket sending the latest progress of the job on connect, then every progress report as a {"type":"progress"} event. Only the job owner and admins may subscribe.
+// @Tags k8s
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 101 {object} JobEvent
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /k8s/jobs/{id}/events [get]
+func (h *JobProgressHandler) StreamJobEvents(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid job id"})
+		return
+	}
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	j, err := h.svc.Repos.Job.FindByID(id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, response.CodeNotFound, err)
+		return
+	}
+	if j.UserID != uid {
+		if isAdmin, err := utils.IsSuperAdmin(uid, h.svc.Repos.UserGroup); err != nil || !isAdmin {
+			c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "not the owner of this job"})
+			return
+		}
+	}
+
+	// Subscribe before upgrading so no report sent in between is missed
+	updates, cancel := h.svc.Subscribe(id)
+	defer cancel()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "websocket upgrade failed: " + err.Error()})
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(event JobEvent) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteJSON(event) == nil
+	}
+	if latest := storedProgress(j); latest != nil && !send(JobEvent{Type: "progress", Progress: latest}) {
+		return
+	}
+
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-done:
+			return
+		case p := <-updates:
+			if !send(JobEvent{Type: "progress", Progress: &p}) {
+				return
+			}
+		case <-ping.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// storedProgress decodes the last progress report saved on j, nil when there is none.
+func storedProgress(j *job.Job) *job.Progress {
+	if len(j.Progress) == 0 {
+		return nil
+	}
+	var p job.Progress
+	if err := json.Unmarshal(j.Progress, &p); err != nil {
+		return nil
+	}
+	return &p
+}
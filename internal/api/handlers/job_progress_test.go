@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/types"
+	"gorm.io/gorm"
+)
+
+// setupJobProgressServer serves the progress routes over a running job 1 and a completed job 2
+// of memberID.
+func setupJobProgressServer(t *testing.T) (*httptest.Server, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := dbtest.Open(t, &job.Job{})
+	db.Create(&job.Job{ID: 1, UserID: memberID, Name: "train", Namespace: "ns", Image: "img", K8sJobName: "train", Status: "running"})
+	db.Create(&job.Job{ID: 2, UserID: memberID, Name: "done", Namespace: "ns", Image: "img", K8sJobName: "done", Status: "completed"})
+
+	h := NewJobProgressHandler(application.NewJobProgressService(&repository.Repos{Job: repository.NewJobRepo(db)}))
+	r := gin.New()
+	r.POST("/k8s/jobs/:id/progress", h.ReportProgress)
+	r.GET("/k8s/jobs/:id/events", func(c *gin.Context) {
+		c.Set("claims", &types.Claims{UserID: memberID})
+		c.Next()
+	}, h.StreamJobEvents)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, db
+}
+
+func postProgress(t *testing.T, srv *httptest.Server, jobID uint, token, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/k8s/jobs/%d/progress", srv.URL, jobID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestReportProgressPushesToSubscriber(t *testing.T) {
+	srv, db := setupJobProgressServer(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/k8s/jobs/1/events", nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer conn.Close()
+
+	if resp := postProgress(t, srv, 1, application.JobToken(1), `{"step":30,"total_steps":120,"metrics":{"loss":0.42}}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the report to be accepted, got %d", resp.StatusCode)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var event JobEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("no event received: %v", err)
+	}
+	if event.Type != "progress" || event.Progress == nil || event.Progress.Percent != 25 || event.Progress.Metrics["loss"] != 0.42 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	var stored job.Job
+	db.First(&stored, 1)
+	if stored.ProgressPercent == nil || *stored.ProgressPercent != 25 || len(stored.Progress) == 0 {
+		t.Fatalf("expected the progress on the job row, got %v %s", stored.ProgressPercent, stored.Progress)
+	}
+}
+
+func TestReportProgressRejections(t *testing.T) {
+	srv, _ := setupJobProgressServer(t)
+
+	if resp := postProgress(t, srv, 1, application.JobToken(2), `{"percent":10}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("a token of another job must be rejected, got %d", resp.StatusCode)
+	}
+	if resp := postProgress(t, srv, 1, application.JobToken(1), `{"percent":10}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the first report to be accepted, got %d", resp.StatusCode)
+	}
+	resp := postProgress(t, srv, 1, application.JobToken(1), `{"percent":11}`)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "10" {
+		t.Fatalf("expected a second report within 10s to be limited, got %d (Retry-After %q)", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if resp := postProgress(t, srv, 2, application.JobToken(2), `{"percent":100}`); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected the report of a completed job to be rejected, got %d", resp.StatusCode)
+	}
+
+	metrics := make([]string, 0, application.MaxProgressMetrics+1)
+	for i := 0; i <= application.MaxProgressMetrics; i++ {
+		metrics = append(metrics, fmt.Sprintf(`"m%d":1`, i))
+	}
+	if resp := postProgress(t, srv, 1, application.JobToken(1), `{"percent":50,"metrics":{`+strings.Join(metrics, ",")+`}}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected too many metrics to be rejected, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// @Summary List the caller's job templates
+// @Tags k8s
+// @Produce json
+// @Param project_id query int false "Only templates scoped to this project"
+// @Success 200 {object} response.SuccessResponse{data=[]job.JobTemplate}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/job-templates [get]
+func (h *K8sHandler) ListJobTemplates(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var projectID *uint
+	if c.Query("project_id") != "" {
+		pid, err := utils.ParseQueryUintParam(c, "project_id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project_id"})
+			return
+		}
+		projectID = &pid
+	}
+
+	templates, err := h.K8sService.ListJobTemplates(uid, projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: templates})
+}
+
+// @Summary Get one of the caller's job templates
+// @Tags k8s
+// @Produce json
+// @Param id path int true "Template ID"
+// @Success 200 {object} response.SuccessResponse{data=job.JobTemplate}
+// @Failure 404 {object} response.ErrorResponse
+// @Router /k8s/job-templates/{id} [get]
+func (h *K8sHandler) GetJobTemplate(c *gin.Context) {
+	uid, id, ok := jobTemplateParams(c)
+	if !ok {
+		return
+	}
+	t, err := h.K8sService.GetJobTemplate(uid, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: t})
+}
+
+// @Summary Save a job submission as a template
+// @Tags k8s
+// @Accept json
+// @Produce json
+// @Param body body job.JobTemplateInput true "Template"
+// @Success 201 {object} response.SuccessResponse{data=job.JobTemplate}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/job-templates [post]
+func (h *K8sHandler) CreateJobTemplate(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var input job.JobTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := checkJobSubmissionSize(input.Submission); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	t, err := h.K8sService.CreateJobTemplate(uid, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, response.SuccessResponse{Code: 0, Message: "Job template created", Data: t})
+}
+
+// @Summary Replace a job template
+// @Tags k8s
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param body body job.JobTemplateInput true "Template"
+// @Success 200 {object} response.SuccessResponse{data=job.JobTemplate}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /k8s/job-templates/{id} [put]
+func (h *K8sHandler) UpdateJobTemplate(c *gin.Context) {
+	uid, id, ok := jobTemplateParams(c)
+	if !ok {
+		return
+	}
+
+	var input job.JobTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := checkJobSubmissionSize(input.Submission); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	t, err := h.K8sService.UpdateJobTemplate(uid, id, input)
+	if err != nil {
+		if errors.Is(err, application.ErrJobTemplateNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "Job template updated", Data: t})
+}
+
+// @Summary Delete a job template
+// @Tags k8s
+// @Param id path int true "Template ID"
+// @Success 204
+// @Failure 404 {object} response.ErrorResponse
+// @Router /k8s/job-templates/{id} [delete]
+func (h *K8sHandler) DeleteJobTemplate(c *gin.Context) {
+	uid, id, ok := jobTemplateParams(c)
+	if !ok {
+		return
+	}
+	if err := h.K8sService.DeleteJobTemplate(uid, id); err != nil {
+		if errors.Is(err, application.ErrJobTemplateNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	respondNoContent(c, &legacyBody{http.StatusOK, response.MessageResponse{Message: "Job template deleted"}})
+}
+
+func jobTemplateParams(c *gin.Context) (uint, uint, bool) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return 0, 0, false
+	}
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid template ID"})
+		return 0, 0, false
+	}
+	return uid, id, true
+}
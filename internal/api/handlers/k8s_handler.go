@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"strconv"
 	"strings"
@@ -14,7 +14,9 @@ import (
 	"github.com/linskybing/platform-go/internal/application"
 	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/job"
+	imageref "github.com/linskybing/platform-go/pkg/image"
 	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/pagination"
 	"github.com/linskybing/platform-go/pkg/response"
 	"github.com/linskybing/platform-go/pkg/types"
 	"github.com/linskybing/platform-go/pkg/utils"
@@ -22,30 +24,42 @@ import (
 	"io"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 type K8sHandler struct {
 	K8sService     *application.K8sService
 	UserService    *application.UserService
 	ProjectService *application.ProjectService
+
+	projectRoles *projectRoleCache
 }
 
 func NewK8sHandler(K8sService *application.K8sService, UserService *application.UserService, ProjectService *application.ProjectService) *K8sHandler {
-	return &K8sHandler{K8sService: K8sService, UserService: UserService, ProjectService: ProjectService}
+	return &K8sHandler{
+		K8sService:     K8sService,
+		UserService:    UserService,
+		ProjectService: ProjectService,
+		projectRoles:   newProjectRoleCache(projectRoleTTL),
+	}
 }
 
 // @Summary Create a Kubernetes Job
 // @Tags k8s
 // @Accept json
 // @Produce json
-// @Param body body job.CreateJobDTO true "Job Specification"
-// @Success 201 {object} response.SuccessResponse
-// @Failure 400 {object} response.ErrorResponse
+// @Description Submit a job inline, or pass {"template_id": N, "overrides": {...}} to run a saved job template. data.warnings lists findings that did not stop the job, each with a code, message and the submission field it concerns: mounted PVCs that are not Bound (volume_unbound, unless their class binds on first consumer) or are ReadWriteOnce and already mounted by a running pod (volume_rwo_in_use), and the queued jobs a scheduled job waits behind (queued). Projects with strict volume checks reject jobs with volume warnings with 409. The warnings are also kept on the job, with those the scheduler adds while it holds the job.
+// @Param body body job.JobSubmissionRequest true "Job Specification"
+// @Success 201 {object} response.SuccessResponse{data=object{warnings=[]job.Warning}} "Created; warnings lists the findings that did not stop the job"
+// @Failure 400 {object} response.ErrorResponse "Invalid submission or PVC not found"
+// @Failure 403 {object} response.ErrorResponse "Priority or job type not allowed for the caller's role, or the job pods would get node access"
+// @Failure 404 {object} response.ErrorResponse "Job template not found"
+// @Failure 409 {object} object{error=string,warnings=[]application.VolumeWarning} "A PVC cannot be mounted and the project checks volumes strictly"
 // @Failure 500 {object} response.ErrorResponse
 // @Router /k8s/jobs [post]
 func (h *K8sHandler) CreateJob(c *gin.Context) {
-	var input job.JobSubmission
-	if err := c.ShouldBindJSON(&input); err != nil {
+	var req job.JobSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -56,22 +70,342 @@ func (h *K8sHandler) CreateJob(c *gin.Context) {
 		return
 	}
 
-	if err := h.K8sService.CreateJob(c.Request.Context(), uid, input); err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+	input, err := h.K8sService.ResolveJobSubmission(uid, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrJobTemplateNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	if err := checkJobSubmissionSize(input); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	warnings, err := h.K8sService.CreateJob(c.Request.Context(), uid, input)
+	if err != nil {
+		if respondPodSecurityError(c, err) {
+			return
+		}
+		var volumeErr *application.VolumeCheckError
+		if errors.As(err, &volumeErr) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "warnings": volumeErr.Warnings})
+			return
+		}
+		var priorityErr *application.PriorityNotAllowedError
+		if errors.As(err, &priorityErr) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "allowed": priorityErr.Allowed})
+			return
+		}
+		var typeErr *application.JobTypeNotAllowedError
+		if errors.As(err, &typeErr) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "allowed": typeErr.Allowed})
+			return
+		}
+		var limitErr *application.JobLimitError
+		if errors.As(err, &limitErr) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "scope": limitErr.Scope, "current": limitErr.Current, "limit": limitErr.Limit})
+			return
+		}
+		switch {
+		case errors.Is(err, application.ErrInvalidJobDependency), errors.Is(err, application.ErrJobDependencyCycle),
+			errors.Is(err, application.ErrInvalidArtifactUpload), errors.Is(err, application.ErrInvalidMaxRuntime),
+			errors.Is(err, application.ErrJobTypeUnavailable), errors.Is(err, application.ErrJobVolumeNotFound),
+			errors.Is(err, application.ErrInvalidMPSConfig):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, imageref.ErrInvalidReference):
+			respondError(c, http.StatusBadRequest, response.CodeInvalidImage, err)
+		case errors.Is(err, application.ErrGPUQuotaExceeded):
+			respondError(c, http.StatusForbidden, response.CodeGPUQuotaExceeded, err)
+		case errors.Is(err, application.ErrGPUAccessNotAllowed):
+			respondError(c, http.StatusForbidden, response.CodeGPUAccessDenied, err)
+		case isNamespaceNotFound(err):
+			respondError(c, http.StatusNotFound, response.CodeNamespaceNotFound, err)
+		default:
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		}
 		return
 	}
 
+	if warnings == nil {
+		warnings = []job.Warning{}
+	}
 	c.JSON(http.StatusCreated, response.SuccessResponse{
 		Code:    0,
 		Message: "Job created successfully",
+		Data:    gin.H{"warnings": warnings},
 	})
 }
 
-// @Summary List Jobs
+// @Summary List priority classes available for job submission
+// @Tags k8s
+// @Produce json
+// @Param project_id query int false "Project ID used to resolve the caller's role"
+// @Success 200 {object} response.SuccessResponse{data=[]job.PriorityClassOption}
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/priority-classes [get]
+func (h *K8sHandler) ListPriorityClasses(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var projectID uint
+	if c.Query("project_id") != "" {
+		projectID, err = utils.ParseQueryUintParam(c, "project_id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project_id"})
+			return
+		}
+	}
+
+	options, err := h.K8sService.ListPriorityClasses(c.Request.Context(), uid, projectID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data:    options,
+	})
+}
+
+// @Summary List storage classes available for project storage
+// @Description Each class carries whether users may request it and the size bounds of a request.
+// @Tags k8s
+// @Produce json
+// @Success 200 {object} response.SuccessResponse{data=[]job.StorageClassOption}
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/storage-classes [get]
+func (h *K8sHandler) ListStorageClasses(c *gin.Context) {
+	options, err := h.K8sService.ListStorageClasses(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data:    options,
+	})
+}
+
+// @Summary Check the cluster prerequisites of the platform
+// @Description Verifies the Harbor pull secret in the image pull namespace, the priority classes, the default storage class, allocatable nvidia.com/gpu on at least one node and the storage browser service name. Each check passes, warns or fails, with a remediation hint; the same checks run at startup.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} response.SuccessResponse{data=application.PreflightReport}
+// @Router /admin/preflight [get]
+func (h *K8sHandler) GetPreflight(c *gin.Context) {
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data:    h.K8sService.Preflight(c.Request.Context()),
+	})
+}
+
+// @Summary Queue and wait-time statistics
+// @Description Aggregates the jobs submitted in [from, to) per bucket: jobs submitted and started, median and 95th percentile wait from submission to start, and GPU-hours requested (from submission) and granted (from start). Also returns the current queue depth by priority and the GPUs of running jobs. The range is at most 90 days and defaults to the last 30.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "Start, RFC3339 (default: 30 days before to)"
+// @Param to query string false "End, RFC3339 (default: now)"
+// @Param bucket query string false "hour, day (default) or week"
+// @Success 200 {object} response.SuccessResponse{data=job.QueueStats}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/admin/stats/queue [get]
+func (h *K8sHandler) GetQueueStats(c *gin.Context) {
+	var from, to time.Time
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid " + name})
+			return
+		}
+		*dst = t
+	}
+	stats, err := h.K8sService.GetQueueStats(from, to, c.Query("bucket"))
+	if err != nil {
+		if errors.Is(err, application.ErrInvalidStatsRange) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data:    stats,
+	})
+}
+
+// @Summary List the pods the platform runs
+// @Description Lists the platform-created pods of every namespace, attributed to their user, project and source (job, configfile, filebrowser, nfs or other), with status, node, age, GPU request and restart count. Pods an admin can stop through an existing endpoint carry deletable and the path of that endpoint. Paginated with limit and cursor.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param user query string false "Username of the owner"
+// @Param project query int false "Project ID"
+// @Param status query string false "Pod phase, e.g. Running or Pending"
+// @Param node query string false "Node name"
+// @Param sort query string false "age (newest first, default) or restarts (most first)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param total query bool false "Include the number of matching pods"
+// @Success 200 {object} response.SuccessResponse{data=pagination.Page[application.PlatformPod]}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/admin/pods [get]
+func (h *K8sHandler) ListPlatformPods(c *gin.Context) {
+	p, err := pagination.FromRequest(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, response.CodeInvalidCursor, err)
+		return
+	}
+	filter := application.PodFilter{
+		User:   c.Query("user"),
+		Status: c.Query("status"),
+		Node:   c.Query("node"),
+		Sort:   c.Query("sort"),
+	}
+	if raw := c.Query("project"); raw != "" {
+		pid, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid project"})
+			return
+		}
+		filter.ProjectID = uint(pid)
+	}
+	page, err := h.K8sService.ListPlatformPods(c.Request.Context(), filter, p)
+	if err != nil {
+		if errors.Is(err, application.ErrInvalidPodSort) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data:    page,
+	})
+}
+
+// @Summary Snapshot the resources of a namespace
+// @Description Lists the platform pods, services and deployments of a namespace once, with the same fields as the events of the /ws/monitoring/{namespace} watch, for clients that do not want a WebSocket. The resource versions can be used to continue with a watch.
+// @Tags k8s
+// @Security BearerAuth
+// @Produce json
+// @Param ns path string true "Namespace"
+// @Param kinds query string false "Comma-separated resources to include: pods, services, deployments (default: all)"
+// @Success 200 {object} response.SuccessResponse{data=k8s.NamespaceSnapshot}
+// @Failure 400 {object} response.ErrorResponse "Unknown kind"
+// @Failure 403 {object} response.ErrorResponse "Permission denied for this namespace"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/namespaces/{ns}/snapshot [get]
+func (h *K8sHandler) GetNamespaceSnapshot(c *gin.Context) {
+	var kinds []string
+	if raw := c.Query("kinds"); raw != "" {
+		kinds = strings.Split(raw, ",")
+	}
+	var viewerID uint
+	if claims, ok := c.Get("claims"); ok {
+		if cl, ok := claims.(*types.Claims); ok {
+			viewerID = cl.UserID
+		}
+	}
+	snap, err := k8s.SnapshotNamespace(c.Request.Context(), c.Param("ns"), kinds, viewerID)
+	if err != nil {
+		if errors.Is(err, k8s.ErrUnknownKind) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, "success", snap, nil)
+}
+
+// @Summary Backfill project labels onto legacy namespaces
+// @Description One-time migration that labels proj-<pid>-<user> namespaces so label selectors can find them.
+// @Tags k8s
+// @Produce json
+// @Success 200 {object} response.SuccessResponse{data=[]string}
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/namespaces/backfill-labels [post]
+func (h *K8sHandler) BackfillNamespaceLabels(c *gin.Context) {
+	updated, err := h.K8sService.BackfillNamespaceLabels(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: fmt.Sprintf("labeled %d namespaces", len(updated)),
+		Data:    updated,
+	})
+}
+
+// @Summary Backfill ownership labels onto existing platform objects
+// @Description One-time migration that adds the platform.linskybing.io ownership labels to namespaces, PVCs, pods, services, deployments and jobs found in project namespaces.
+// @Tags k8s
+// @Produce json
+// @Success 200 {object} response.SuccessResponse{data=[]string}
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/labels/backfill [post]
+func (h *K8sHandler) BackfillOwnershipLabels(c *gin.Context) {
+	updated, err := h.K8sService.BackfillOwnershipLabels(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: fmt.Sprintf("labeled %d objects", len(updated)),
+		Data:    updated,
+	})
+}
+
+// @Summary Create missing platform priority classes
 // @Tags k8s
 // @Produce json
 // @Success 200 {object} response.SuccessResponse
 // @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/priority-classes/reconcile [post]
+func (h *K8sHandler) ReconcilePriorityClasses(c *gin.Context) {
+	if err := h.K8sService.ReconcilePriorityClasses(c.Request.Context()); err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, "priority classes reconciled", nil,
+		&legacyBody{http.StatusOK, response.MessageResponse{Message: "priority classes reconciled"}})
+}
+
+// @Summary List Jobs
+// @Tags k8s
+// @Produce json
+// @Param limit query int false "Page size; returns a page envelope (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param total query bool false "Include the total count in a page envelope"
+// @Success 200 {object} response.SuccessResponse{data=[]job.Job}
+// @Failure 400 {object} response.ErrorResponse "Invalid cursor"
+// @Failure 500 {object} response.ErrorResponse
 // @Router /k8s/jobs [get]
 func (h *K8sHandler) ListJobs(c *gin.Context) {
 	uid, err := utils.GetUserIDFromContext(c)
@@ -92,20 +426,35 @@ func (h *K8sHandler) ListJobs(c *gin.Context) {
 	// The middleware sets "userID".
 	// Let's just list for the user.
 
+	if pagination.Requested(c) {
+		p, err := pagination.FromRequest(c)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, response.CodeInvalidCursor, err)
+			return
+		}
+		page, err := h.K8sService.ListJobsPage(uid, false, p)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+			return
+		}
+		respondSuccess(c, http.StatusOK, "success", page, &legacyBody{http.StatusOK, page})
+		return
+	}
+
 	jobs, err := h.K8sService.ListJobs(uid, false) // false for isAdmin for now
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, jobs)
+	respondSuccess(c, http.StatusOK, "success", jobs, &legacyBody{http.StatusOK, jobs})
 }
 
 // @Summary Get Job
 // @Tags k8s
 // @Produce json
 // @Param id path int true "Job ID"
-// @Success 200 {object} response.SuccessResponse
+// @Success 200 {object} response.SuccessResponse{data=job.JobDetail}
 // @Failure 404 {object} response.ErrorResponse
 // @Router /k8s/jobs/{id} [get]
 func (h *K8sHandler) GetJob(c *gin.Context) {
@@ -116,9 +465,9 @@ func (h *K8sHandler) GetJob(c *gin.Context) {
 		return
 	}
 
-	job, err := h.K8sService.GetJob(uint(id))
+	job, err := h.K8sService.GetJobDetail(uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		respondError(c, http.StatusNotFound, response.CodeNotFound, err)
 		return
 	}
 
@@ -129,11 +478,123 @@ func (h *K8sHandler) GetJob(c *gin.Context) {
 	})
 }
 
+// ListJobArtifacts godoc
+// @Summary List uploaded job artifacts
+// @Description Objects uploaded by the job's artifact uploader, with presigned download URLs.
+// @Tags k8s
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} response.SuccessResponse{data=[]application.JobArtifact}
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 503 {object} response.ErrorResponse "Object storage not configured"
+// @Router /k8s/jobs/{id}/artifacts [get]
+func (h *K8sHandler) ListJobArtifacts(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	j, err := h.K8sService.GetJob(id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, response.CodeNotFound, err)
+		return
+	}
+	if j.UserID != uid {
+		isAdmin, err := utils.IsSuperAdmin(uid, h.UserService.Repos.UserGroup)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+			return
+		}
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "not allowed to access this job"})
+			return
+		}
+	}
+
+	artifacts, err := h.K8sService.ListJobArtifacts(c.Request.Context(), j.ID)
+	if err != nil {
+		if errors.Is(err, application.ErrArtifactStoreDisabled) {
+			c.JSON(http.StatusServiceUnavailable, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data:    artifacts,
+	})
+}
+
+// GetJobTimeline godoc
+// @Summary Get the timeline of a job
+// @Description Chronological list of what happened to the job with the source and severity of each event: platform events such as its submission and dispatch, audited actions such as restarts, Kubernetes events of its Job and pods, and its status transitions. Repeated Kubernetes events, such as BackOff, are merged into one entry with their count. Only the job owner and admins may read it.
+// @Tags k8s
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} response.SuccessResponse{data=[]application.TimelineEntry}
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /k8s/jobs/{id}/timeline [get]
+func (h *K8sHandler) GetJobTimeline(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	j, err := h.K8sService.GetJob(id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, response.CodeNotFound, err)
+		return
+	}
+	if j.UserID != uid {
+		isAdmin, err := utils.IsSuperAdmin(uid, h.UserService.Repos.UserGroup)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+			return
+		}
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "not allowed to access this job"})
+			return
+		}
+	}
+
+	timeline, err := h.K8sService.JobTimeline(j)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data:    timeline,
+	})
+}
+
 // GetUserStorageStatus godoc
-// @Summary Check if user storage exists
+// @Summary Check user storage health
+// @Description Reports whether the user's storage hub exists and can serve project instances: the hub PVC phase and capacity, the available replicas of the NFS deployment, the ClusterIP of the NFS service and the most recent warning event, with a Healthy, Degraded or Missing verdict.
 // @Tags k8s
 // @Param username path string true "Username"
-// @Success 200 {object} map[string]bool "exists: true/false"
+// @Success 200 {object} response.SuccessResponse{data=k8s.HubHealth} "data.exists is kept for older clients"
 // @Router /k8s/users/{username}/storage/status [get]
 func (h *K8sHandler) GetUserStorageStatus(c *gin.Context) {
 	username := c.Param("username")
@@ -142,49 +603,78 @@ func (h *K8sHandler) GetUserStorageStatus(c *gin.Context) {
 		return
 	}
 
-	exists, err := h.K8sService.CheckUserStorageExists(c, username)
+	health, err := h.K8sService.GetUserStorageHealth(c.Request.Context(), username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"exists": exists,
-	})
+	respondSuccess(c, http.StatusOK, "success", health, &legacyBody{http.StatusOK, health})
+}
+
+// GetUserStorageDetail godoc
+// @Summary Get user storage hub details
+// @Description Reports the state of each component of the user's storage hub (namespace, PVC, deployment, service).
+// @Tags admin
+// @Produce json
+// @Param username path string true "Username"
+// @Success 200 {object} response.SuccessResponse{data=k8s.HubStatus}
+// @Router /k8s/users/{username}/storage/detail [get]
+func (h *K8sHandler) GetUserStorageDetail(c *gin.Context) {
+	status := h.K8sService.GetUserStorageHubStatus(c.Request.Context(), c.Param("username"))
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: status})
 }
 
 // InitializeStorage godoc
 // @Summary Manually initialize user storage
-// @Description Force creation of K8s storage resources (Namespace, PVC, NFS) for a specific user. Useful for recovery.
+// @Description Creates the missing K8s storage resources (Namespace, PVC, Deployment, Service) for a specific user. Components that already exist are left alone, so it is safe to re-run after a partial failure.
 // @Tags admin
 // @Accept json
 // @Produce json
 // @Param username path string true "Username to initialize"
-// @Success 200 {object} response.MessageResponse "Storage initialized successfully"
-// @Failure 404 {object} response.ErrorResponse "User not found"
-// @Failure 500 {object} response.ErrorResponse "Failed to initialize storage"
+// @Success 201 {object} response.SuccessResponse{data=k8s.HubStatus} "Storage initialized successfully"
+// @Success 207 {object} response.SuccessResponse{data=k8s.HubStatus} "Some components failed"
 // @Router /k8s/users/{username}/storage/init [post]
 func (h *K8sHandler) InitializeUserStorage(c *gin.Context) {
 	username := c.Param("username")
 
-	err := h.K8sService.InitializeUserStorageHub(username)
+	status, err := h.K8sService.InitializeUserStorageHub(c.Request.Context(), username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: fmt.Sprintf("Failed to init storage: %v", err)})
+		reportError(c, http.StatusMultiStatus, response.CodeInternal, err)
+		c.JSON(http.StatusMultiStatus, response.SuccessResponse{Code: 0, Message: fmt.Sprintf("Storage partially initialized: %v", err), Data: status})
 		return
 	}
 
-	c.JSON(http.StatusOK, response.MessageResponse{Message: "Storage initialized successfully"})
+	ok := response.SuccessResponse{Code: 0, Message: "Storage initialized successfully", Data: status}
+	respondSuccess(c, http.StatusCreated, ok.Message, status, &legacyBody{http.StatusOK, ok})
+}
+
+// ListStorageEntitlementGaps godoc
+// @Summary List hubs below their entitlement
+// @Description Lists users whose storage hub is smaller than the largest hub size granted by their groups. Hubs are not resized automatically; expand them with the expand endpoint.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} response.SuccessResponse{data=[]application.HubEntitlementGap}
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Router /k8s/users/storage/entitlement-gaps [get]
+func (h *K8sHandler) ListStorageEntitlementGaps(c *gin.Context) {
+	gaps, err := h.K8sService.ListHubEntitlementGaps(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: gaps})
 }
 
 // ExpandUserStorage godoc
 // @Summary Expand user storage capacity
-// @Description Increases the size of the underlying PVC for a specific user's storage hub.
+// @Description Increases the size of the underlying PVC for a specific user's storage hub. The storage driver resizes the volume in the background.
 // @Tags k8s
 // @Accept json
 // @Produce json
 // @Param username path string true "Target Username"
 // @Param input body job.ExpandStorageInput true "Expansion details"
-// @Success 200 {object} response.MessageResponse "Storage expanded successfully"
+// @Success 202 {object} response.SuccessResponse "Storage expansion requested"
 // @Failure 400 {object} response.ErrorResponse "Invalid input"
 // @Failure 500 {object} response.ErrorResponse "Internal Server Error"
 // @Router /k8s/users/{username}/storage/expand [put]
@@ -206,14 +696,13 @@ func (h *K8sHandler) ExpandUserStorage(c *gin.Context) {
 	// 3. Call the service to perform the expansion.
 	err := h.K8sService.ExpandUserStorageHub(targetUsername, input.NewSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to expand storage: " + err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to expand storage: %w", err))
 		return
 	}
 
 	// 4. Return success response.
-	c.JSON(http.StatusOK, response.MessageResponse{
-		Message: fmt.Sprintf("Storage for user '%s' expanded to %s successfully", targetUsername, input.NewSize),
-	})
+	msg := fmt.Sprintf("Storage for user '%s' expanded to %s successfully", targetUsername, input.NewSize)
+	respondSuccess(c, http.StatusAccepted, msg, nil, &legacyBody{http.StatusOK, response.MessageResponse{Message: msg}})
 }
 
 // OpenMyDrive godoc
@@ -222,7 +711,7 @@ func (h *K8sHandler) ExpandUserStorage(c *gin.Context) {
 // @Tags user
 // @Accept json
 // @Produce json
-// @Success 200 {object} map[string]interface{} "Returns nodePort"
+// @Success 200 {object} response.SuccessResponse "User file browser ready"
 // @Failure 401 {object} response.ErrorResponse "Unauthorized"
 // @Failure 404 {object} response.ErrorResponse "User not found"
 // @Failure 500 {object} response.ErrorResponse "Internal Server Error"
@@ -238,19 +727,18 @@ func (h *K8sHandler) OpenMyDrive(c *gin.Context) {
 
 	user, err := h.UserService.FindUserByID(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "User not found: " + err.Error()})
+		respondError(c, http.StatusNotFound, response.CodeNotFound, fmt.Errorf("user not found: %w", err))
 		return
 	}
 
 	_, err = h.K8sService.OpenUserGlobalFileBrowser(c, user.Username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to start file browser: " + err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to start file browser: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "User file browser ready",
-	})
+	respondSuccess(c, http.StatusOK, "User file browser ready", nil,
+		&legacyBody{http.StatusOK, gin.H{"message": "User file browser ready"}})
 }
 
 // @Summary Get Pod Logs
@@ -287,7 +775,14 @@ func (h *K8sHandler) GetPodLogs(c *gin.Context) {
 
 	stream, err := req.Stream(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		switch {
+		case isNamespaceNotFound(err):
+			respondError(c, http.StatusNotFound, response.CodeNamespaceNotFound, err)
+		case apierrors.IsNotFound(err):
+			respondError(c, http.StatusNotFound, response.CodePodNotFound, err)
+		default:
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		}
 		return
 	}
 	defer func() { _ = stream.Close() }()
@@ -314,13 +809,42 @@ func (h *K8sHandler) GetPodLogs(c *gin.Context) {
 
 }
 
+// @Summary Get pod resource usage
+// @Description Per-container CPU (millicores) and memory (bytes) usage from metrics-server, with the requests and limits from the pod spec.
+// @Tags k8s
+// @Produce json
+// @Param namespace path string true "Namespace"
+// @Param pod path string true "Pod name"
+// @Success 200 {object} response.SuccessResponse{data=k8s.PodResourceUsage}
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 501 {object} response.ErrorResponse "metrics-server is not installed"
+// @Router /k8s/pods/{namespace}/{pod}/metrics [get]
+func (h *K8sHandler) GetPodMetrics(c *gin.Context) {
+	usage, err := k8s.GetPodResourceUsage(c.Request.Context(), c.Param("namespace"), c.Param("pod"))
+	if err != nil {
+		switch {
+		case errors.Is(err, k8s.ErrMetricsUnavailable):
+			c.JSON(http.StatusNotImplemented, response.ErrorResponse{Error: err.Error()})
+		case isNamespaceNotFound(err):
+			respondError(c, http.StatusNotFound, response.CodeNamespaceNotFound, err)
+		case apierrors.IsNotFound(err):
+			respondError(c, http.StatusNotFound, response.CodePodNotFound, err)
+		default:
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: usage})
+}
+
 // StopMyDrive godoc
 // @Summary Stop user's global file browser
 // @Description Terminates the temporary FileBrowser pod and service for the user's storage hub.
 // @Tags user
 // @Accept json
 // @Produce json
-// @Success 200 {object} response.MessageResponse "Resources cleaned up"
+// @Success 204 "Resources cleaned up"
 // @Failure 401 {object} response.ErrorResponse "Unauthorized"
 // @Failure 404 {object} response.ErrorResponse "User not found"
 // @Failure 500 {object} response.ErrorResponse "Internal Server Error"
@@ -336,39 +860,119 @@ func (h *K8sHandler) StopMyDrive(c *gin.Context) {
 
 	user, err := h.UserService.FindUserByID(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "User not found: " + err.Error()})
+		respondError(c, http.StatusNotFound, response.CodeNotFound, fmt.Errorf("user not found: %w", err))
 		return
 	}
 
 	err = h.K8sService.StopUserGlobalFileBrowser(c, user.Username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to stop file browser: " + err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to stop file browser: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.MessageResponse{
+	respondNoContent(c, &legacyBody{http.StatusOK, response.MessageResponse{
 		Message: "User file browser stopped successfully",
-	})
+	}})
+}
+
+// StartMySFTP godoc
+// @Summary Start the SFTP server of the caller's storage hub
+// @Description Serves the caller's storage hub over SFTP for bulk transfers, with the keys set through PUT /me/ssh-keys. Files are under data/ after login. Calling it again after changing the keys restarts the server with them; the port stays the same.
+// @Tags user
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} response.SuccessResponse{data=k8s.SFTPEndpoint} "SFTP server ready"
+// @Failure 400 {object} response.ErrorResponse "No SSH keys registered"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "User or storage hub not found"
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} response.ErrorResponse "The server did not start in time, or no SFTP port is free"
+// @Router /k8s/user-storage/sftp [post]
+func (h *K8sHandler) StartMySFTP(c *gin.Context) {
+	claimsVal, _ := c.Get("claims")
+	claims := claimsVal.(*types.Claims)
+	if claims.UserID == 0 {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	endpoint, err := h.K8sService.StartUserSFTP(c, claims.Username)
+	switch {
+	case err == nil:
+	case errors.Is(err, application.ErrUserNotFound):
+		respondError(c, http.StatusNotFound, response.CodeNotFound, err)
+		return
+	case errors.Is(err, application.ErrNoSSHKeys):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "register an SSH public key with PUT /me/ssh-keys first"})
+		return
+	case errors.Is(err, application.ErrStorageNotReady):
+		respondPodNotReady(c, response.CodeStorageNotReady, err)
+		return
+	case errors.Is(err, k8s.ErrNoSFTPPort), errors.Is(err, k8s.ErrPodDeleteTimeout):
+		respondError(c, http.StatusServiceUnavailable, response.CodeStorageNotReady, err)
+		return
+	case isNamespaceNotFound(err):
+		respondError(c, http.StatusNotFound, response.CodeStorageNotFound, err)
+		return
+	default:
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to start sftp server: %w", err))
+		return
+	}
+
+	respondSuccess(c, http.StatusOK, "SFTP server ready", endpoint, nil)
+}
+
+// StopMySFTP godoc
+// @Summary Stop the SFTP server of the caller's storage hub
+// @Tags user
+// @Security BearerAuth
+// @Produce json
+// @Success 204 "Resources cleaned up"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Router /k8s/user-storage/sftp [delete]
+func (h *K8sHandler) StopMySFTP(c *gin.Context) {
+	claimsVal, _ := c.Get("claims")
+	claims := claimsVal.(*types.Claims)
+	if claims.UserID == 0 {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	if err := h.K8sService.StopUserSFTP(c, claims.Username); err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to stop sftp server: %w", err))
+		return
+	}
+	respondNoContent(c, nil)
 }
 
 // DeleteUserStorage handles the deletion of a user's storage hub resources.
+// @Description The first DELETE answers 202 with a confirmation token and what would be destroyed; repeat it with ?confirm=<token> within two minutes to delete. ?force=true skips the confirmation.
+// @Param confirm query string false "Confirmation token from the first DELETE"
+// @Param force query bool false "Delete without confirmation; API tokens need the storage:delete scope"
+// @Success 202 {object} response.SuccessResponse{data=application.StorageDeleteConfirmation}
+// @Success 204
+// @Router /k8s/users/{username}/storage [delete]
 func (h *K8sHandler) DeleteUserStorage(c *gin.Context) {
 	targetUsername := c.Param("username")
 	if targetUsername == "" {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Username is required"})
 		return
 	}
+	if !h.confirmStorageDelete(c, application.UserStorageDeleteTarget(targetUsername, h.K8sService.UserStorageID(targetUsername))) {
+		return
+	}
 
 	// Call service to remove Namespace, PVC, and NFS deployments
 	err := h.K8sService.DeleteUserStorageHub(c, targetUsername)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to delete storage: " + err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to delete storage: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.MessageResponse{
+	respondNoContent(c, &legacyBody{http.StatusOK, response.MessageResponse{
 		Message: fmt.Sprintf("Storage for user '%s' has been completely removed", targetUsername),
-	})
+	}})
 }
 
 // UserStorageProxy 處理所有通往 FileBrowser 的流量
@@ -385,12 +989,17 @@ func (h *K8sHandler) UserStorageProxy(c *gin.Context) {
 	// 2. 找出該使用者的 Service 內部位址
 	// 假設你有一個 Helper function 可以組出 K8s 內部的 DNS 名稱
 	// 格式通常是: http://{service-name}.{namespace}.svc.cluster.local:{port}
-	user, _ := h.UserService.FindUserByID(userID)
-	safeUsername := strings.ToLower(user.Username)
+	user, err := h.UserService.FindUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	// The hub is named after the identifier stored when it was initialized, not the username
+	storageID := h.K8sService.UserStorageID(user.Username)
 
 	// 根據我們之前的命名規則
-	serviceName := fmt.Sprintf("fb-hub-svc-%s", safeUsername)
-	namespace := fmt.Sprintf("user-%s-storage", safeUsername)
+	_, serviceName := utils.UserHubBrowserNames(storageID)
+	namespace := k8s.UserHubNamespace(storageID)
 	targetStr := fmt.Sprintf("http://%s.%s.svc.cluster.local:80", serviceName, namespace)
 
 	remote, err := url.Parse(targetStr)
@@ -399,8 +1008,8 @@ func (h *K8sHandler) UserStorageProxy(c *gin.Context) {
 		return
 	}
 
-	// 3. 建立反向代理
-	proxy := httputil.NewSingleHostReverseProxy(remote)
+	// 3. 建立反向代理 (streaming, no response buffering)
+	proxy := utils.NewStreamingProxy(remote)
 
 	// 4. 修改請求路徑 (Path Rewriting)
 	// 前端呼叫: /k8s/user-storage/proxy/files/...
@@ -413,14 +1022,24 @@ func (h *K8sHandler) UserStorageProxy(c *gin.Context) {
 
 		// 移除 Gin 的路由前綴，讓後面的 FileBrowser 收到正確的路徑
 		// 假設你的路由群組是 /k8s/user-storage/proxy
-		req.URL.Path = strings.TrimPrefix(req.URL.Path, "/k8s/user-storage/proxy")
+		req.URL.Path = strings.TrimPrefix(config.FileBrowserUpstreamPath(req.URL.Path), "/k8s/user-storage/proxy")
 
 		// 確保 Header 正確
 		req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
 		req.Header.Set("X-Forwarded-Proto", "http")
 	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		apiLog.Warn("storage proxy request failed", "target", targetStr, "path", r.URL.Path, "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(utils.ProxyErrorStatus(err))
+		// The target is an in-cluster service address; it stays in the log above
+		_, _ = fmt.Fprint(w, `{"error": "Storage proxy request failed"}`)
+	}
 
 	// 5. 執行代理 (直接接管 ResponseWriter)
+	if !utils.LimitUploadBody(c.Writer, c.Request, config.StorageProxyMaxUploadBytes) {
+		return
+	}
 	proxy.ServeHTTP(c.Writer, c.Request)
 }
 
@@ -430,7 +1049,7 @@ func (h *K8sHandler) UserStorageProxy(c *gin.Context) {
 // @Tags K8s/ProjectStorage
 // @Accept json
 // @Produce json
-// @Success 200 {array} job.ProjectPVCOutput
+// @Success 200 {object} response.SuccessResponse{data=[]job.ProjectPVCOutput}
 // @Failure 500 {object} map[string]string "Internal Server Error"
 // @Router /k8s/storage/projects [get]
 func (h *K8sHandler) ListProjectStorages(c *gin.Context) {
@@ -441,15 +1060,51 @@ func (h *K8sHandler) ListProjectStorages(c *gin.Context) {
 	// 2. Call Service
 	list, err := h.K8sService.ListAllProjectStorages(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to fetch project storages",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to fetch project storages: %w", err))
 		return
 	}
 
 	// 3. Return Result
-	c.JSON(http.StatusOK, list)
+	respondSuccess(c, http.StatusOK, "success", list, &legacyBody{http.StatusOK, list})
+}
+
+// ListPVCsByProject godoc
+// @Summary List the caller's PVCs in a project
+// @Description PVCs in the namespace the caller's instances of the project run in. Only members of the project's group may list them.
+// @Tags k8s
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} response.SuccessResponse{data=[]job.PVC}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/pvc/by-project/{id} [get]
+func (h *K8sHandler) ListPVCsByProject(c *gin.Context) {
+	projectID, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid Project ID"})
+		return
+	}
+	username, err := utils.GetUserNameFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+	project, err := h.ProjectService.GetProject(projectID)
+	if err != nil || project == nil {
+		respondError(c, http.StatusNotFound, response.CodeNotFound, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	pvcs, err := h.K8sService.ListPVCsByProject(ctx, project, username)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to list PVCs: %w", err))
+		return
+	}
+	respondSuccess(c, http.StatusOK, "success", pvcs, nil)
 }
 
 // GetUserProjectStorages godoc
@@ -457,7 +1112,7 @@ func (h *K8sHandler) ListProjectStorages(c *gin.Context) {
 // @Description Fetches all PVCs for projects where the current user is a member.
 // @Tags k8s
 // @Produce json
-// @Success 200 {array} job.ProjectPVCOutput
+// @Success 200 {object} response.SuccessResponse{data=[]job.ProjectPVCOutput}
 // @Failure 401 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /k8s/projects/my-storages [get]
@@ -472,22 +1127,24 @@ func (h *K8sHandler) GetUserProjectStorages(c *gin.Context) {
 	// 2. Fetch projects with Roles using the updated View
 	projects, err := h.ProjectService.GetProjectsByUser(uid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to fetch user projects: " + err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to fetch user projects: %w", err))
 		return
 	}
 
 	// 3. Create a map to store ProjectID -> Role for quick lookup
 	userProjectRoles := make(map[uint]string)
+	projectIDs := make([]uint, 0, len(projects))
 	for _, p := range projects {
 		userProjectRoles[p.PID] = p.Role
+		projectIDs = append(projectIDs, p.PID)
 	}
 
 	// 4. Setup Context for K8s operations
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
-	// 5. Get all storage info from K8s
-	allStorages, err := h.K8sService.ListAllProjectStorages(ctx)
+	// 5. Get the storages of the user's projects from K8s
+	allStorages, err := h.K8sService.ListProjectStoragesByIDs(ctx, projectIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to fetch storage status"})
 		return
@@ -500,16 +1157,18 @@ func (h *K8sHandler) GetUserProjectStorages(c *gin.Context) {
 		if _, exists := userProjectRoles[s.ProjectID]; exists {
 			role := userProjectRoles[s.ProjectID]
 			output := job.ProjectPVCOutput{
-				ID:          s.ID,
-				ProjectID:   s.ProjectID,
-				ProjectName: s.ProjectName,
-				Namespace:   s.Namespace,
-				Name:        s.Name,
-				Capacity:    s.Size,
-				Status:      s.Status,
-				AccessMode:  s.AccessMode,
-				CreatedAt:   s.CreatedAt,
-				Role:        role,
+				ID:            s.ID,
+				ProjectID:     s.ProjectID,
+				ProjectName:   s.ProjectName,
+				Namespace:     s.Namespace,
+				Name:          s.Name,
+				StorageName:   s.StorageName,
+				Capacity:      s.Size,
+				CapacityBytes: s.CapacityBytes,
+				Status:        s.Status,
+				AccessMode:    s.AccessMode,
+				CreatedAt:     s.CreatedAt,
+				Role:          role,
 			}
 			userStorages = append(userStorages, output)
 		}
@@ -518,24 +1177,22 @@ func (h *K8sHandler) GetUserProjectStorages(c *gin.Context) {
 	if userStorages == nil {
 		userStorages = []job.ProjectPVCOutput{}
 	}
-	c.JSON(http.StatusOK, userStorages)
+	respondSuccess(c, http.StatusOK, "success", userStorages, &legacyBody{http.StatusOK, userStorages})
 }
 
 // CreateProjectStorage provisions a new shared storage (PVC) for a project.
 // @Summary Create project storage
-// @Description Provisions a Namespace and PVC for the specified project. Auto-generates labels for management.
+// @Description Provisions a Namespace and a named PVC (project-{id}-{name}) for the specified project. A project may hold several storages.
 // @Tags K8s/ProjectStorage
 // @Accept json
 // @Produce json
 // @Param request body job.CreateProjectStorageRequest true "Project Storage Request"
-// @Success 200 {object} map[string]interface{} "Storage created successfully"
+// @Success 201 {object} response.SuccessResponse "Storage created successfully"
 // @Failure 400 {object} map[string]string "Invalid request parameters"
 // @Failure 409 {object} map[string]string "Storage already exists"
 // @Failure 500 {object} map[string]string "Internal Server Error"
 // @Router /k8s/storage/projects [post]
 func (h *K8sHandler) CreateProjectStorage(c *gin.Context) {
-	// TODO: Enable support for multiple project storage locations.
-
 	// Bind JSON Payload
 	var req job.CreateProjectStorageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -556,41 +1213,69 @@ func (h *K8sHandler) CreateProjectStorage(c *gin.Context) {
 		return
 	}
 
+	// A zero capacity uses the default of the project's group
+	capacity := ""
+	if req.Capacity > 0 {
+		capacity = fmt.Sprintf("%dGi", req.Capacity)
+	}
+
 	// Convert request to VolumeSpec
 	volumeSpec := job.VolumeSpec{
 		ProjectID:        req.ProjectID,
-		ProjectName:      k8s.ToSafeK8sName(req.ProjectName),
+		ProjectName:      project.ProjectName,
+		Namespace:        application.ProjectStorageNamespace(project),
 		Name:             req.Name,
-		Size:             fmt.Sprintf("%dGi", req.Capacity),
+		Size:             capacity,
 		StorageClassName: req.StorageClass,
+		AccessMode:       req.AccessMode,
 	}
 
 	createdPVC, err := h.K8sService.CreateProjectPVC(ctx, volumeSpec)
 	if err != nil {
-		// Check for specific errors (e.g., already exists)
-		if strings.Contains(err.Error(), "already exists") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Storage for this project already exists"})
+		var storageErr *application.StorageRequestError
+		if errors.As(err, &storageErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    err.Error(),
+				"code":     response.CodeInvalidStorage,
+				"field":    storageErr.Field,
+				"allowed":  storageErr.Allowed,
+				"min_size": storageErr.MinSize,
+				"max_size": storageErr.MaxSize,
+			})
 			return
 		}
-		fmt.Printf("Error creating project storage: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to provision storage",
-			"details": err.Error(),
-		})
+		switch {
+		case errors.Is(err, application.ErrUnsupportedAccessMode), errors.Is(err, application.ErrInvalidStorageName),
+			errors.Is(err, application.ErrInvalidStorageRequest):
+			respondError(c, http.StatusBadRequest, response.CodeInvalidStorage, err)
+		case apierrors.IsAlreadyExists(err), strings.Contains(err.Error(), "already exists"):
+			respondError(c, http.StatusConflict, response.CodeStorageConflict, err)
+		default:
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to provision storage: %w", err))
+		}
 		return
 	}
 
-	// Return Success Response (200 aligns with integration tests)
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Project storage created successfully",
-		"id":        req.ProjectID,
-		"pvcName":   createdPVC.Name,
-		"namespace": createdPVC.Namespace,
-		"capacity":  req.Capacity,
-		"createdAt": createdPVC.CreationTimestamp,
-	})
+	created := gin.H{
+		"id":          req.ProjectID,
+		"pvcName":     createdPVC.Name,
+		"storageName": k8s.ProjectStorageName(createdPVC),
+		"namespace":   createdPVC.Namespace,
+		"capacity":    req.Capacity,
+		"createdAt":   createdPVC.CreationTimestamp,
+	}
+	legacy := gin.H{"message": "Project storage created successfully"}
+	for k, v := range created {
+		legacy[k] = v
+	}
+	respondSuccess(c, http.StatusCreated, "Project storage created successfully", created, &legacyBody{http.StatusOK, legacy})
 }
 
+// @Description Two-phase like DeleteUserStorage: the first DELETE answers 202 with a confirmation token, ?confirm=<token> deletes, ?force=true skips the confirmation.
+// @Param confirm query string false "Confirmation token from the first DELETE"
+// @Param force query bool false "Delete without confirmation; API tokens need the storage:delete scope"
+// @Success 202 {object} response.SuccessResponse{data=application.StorageDeleteConfirmation}
+// @Success 204
 // @Router /k8s/storage/projects/{project id} [delete]
 func (h *K8sHandler) DeleteProjectStorage(c *gin.Context) {
 	// 1. Get Project ID from URL
@@ -607,18 +1292,80 @@ func (h *K8sHandler) DeleteProjectStorage(c *gin.Context) {
 		return
 	}
 
+	if !h.confirmStorageDelete(c, application.ProjectStorageDeleteTarget(project)) {
+		return
+	}
+
 	// 1. Setup Context with Timeout
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	if err := h.K8sService.DeleteProjectAllPVC(ctx, project.ProjectName, project.PID); err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to delete storage: " + err.Error()})
+	if err := h.K8sService.DeleteProjectAllPVC(ctx, project); err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to delete storage: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.MessageResponse{
+	respondNoContent(c, &legacyBody{http.StatusOK, response.MessageResponse{
 		Message: fmt.Sprintf("Storage for project '%d' has been completely removed", project.PID),
-	})
+	}})
+}
+
+// DeleteProjectStorageByName removes one named storage of a project.
+// @Summary Delete a named project storage
+// @Description Deletes the project-{id}-{name} PVC. The project namespace is kept while other storages remain. The first DELETE answers 202 with a confirmation token; repeat it with ?confirm=<token> within two minutes, or pass ?force=true.
+// @Tags K8s/ProjectStorage
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param name path string true "Storage name"
+// @Param confirm query string false "Confirmation token from the first DELETE"
+// @Param force query bool false "Delete without confirmation; API tokens need the storage:delete scope"
+// @Success 202 {object} response.SuccessResponse{data=application.StorageDeleteConfirmation}
+// @Success 204
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/storage/projects/{id}/storages/{name} [delete]
+func (h *K8sHandler) DeleteProjectStorageByName(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid Project ID"})
+		return
+	}
+
+	project, err := h.ProjectService.GetProject(uint(projectID))
+	if err != nil || project == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Project not found"})
+		return
+	}
+
+	storageName := c.Param("name")
+	target, err := application.NamedProjectStorageDeleteTarget(project, storageName)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, response.CodeInvalidStorage, err)
+		return
+	}
+	if !h.confirmStorageDelete(c, target) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.K8sService.DeleteProjectStorage(ctx, project, storageName); err != nil {
+		switch {
+		case errors.Is(err, application.ErrInvalidStorageName):
+			respondError(c, http.StatusBadRequest, response.CodeInvalidStorage, err)
+		case errors.Is(err, application.ErrStorageNotFound):
+			respondError(c, http.StatusNotFound, response.CodeStorageNotFound, err)
+		default:
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to delete storage: %w", err))
+		}
+		return
+	}
+
+	respondNoContent(c, &legacyBody{http.StatusOK, response.MessageResponse{
+		Message: fmt.Sprintf("Storage '%s' of project '%d' has been removed", storageName, project.PID),
+	}})
 }
 
 // ProjectStorageProxy forwards traffic to the FileBrowser instance of a specific project.
@@ -644,23 +1391,29 @@ func (h *K8sHandler) ProjectStorageProxy(c *gin.Context) {
 		return
 	}
 
-	// 2. Fetch Project Details to reconstruct Namespace name
-	// We need the Project Name to recreate the hashed namespace via Utils.
+	// 2. Fetch Project Details for its stored storage namespace
 	project, err := h.ProjectService.GetProject(uint(projectID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
 	}
 
-	// 1. Reconstruct Namespace (Matches your previous logic)
-	targetNamespace := k8s.GenerateSafeResourceName("project", project.ProjectName, project.PID)
+	// The drive pod is shared by the whole project and always writable, so write
+	// access is decided here per caller instead of by whoever started the pod
+	if !h.fileBrowserAccessAllowed(c, project.PID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Read-only access: your project role cannot modify files"})
+		return
+	}
+
+	// 1. Resolve the storage namespace recorded on the project
+	targetNamespace := application.ProjectStorageNamespace(project)
 
 	// 2. Use the new shared service name (PVC-agnostic)
 	serviceName := config.ProjectStorageBrowserSVCName
 
 	// 3. Construct the internal K8s Cluster DNS URL
 	// targetURL will now be: http://storage-svc.<namespace>.svc.cluster.local:80
-	targetURL := fmt.Sprintf("http://%s.%s.svc.cluster.local:80", serviceName, targetNamespace)
+	targetURL := fileBrowserServiceURL(serviceName, targetNamespace)
 
 	remote, err := url.Parse(targetURL)
 	if err != nil {
@@ -668,8 +1421,8 @@ func (h *K8sHandler) ProjectStorageProxy(c *gin.Context) {
 		return
 	}
 
-	// 4. Setup Reverse Proxy
-	proxy := httputil.NewSingleHostReverseProxy(remote)
+	// 4. Setup Reverse Proxy (streaming, no response buffering)
+	proxy := utils.NewStreamingProxy(remote)
 
 	// 5. Rewrite Path (Director)
 	// The path sent to K8s should not contain the proxy prefix.
@@ -678,29 +1431,68 @@ func (h *K8sHandler) ProjectStorageProxy(c *gin.Context) {
 		originalDirector(req)
 
 		// English Comment: Set headers so FileBrowser understands its location
+		basePath := config.FileBrowserBasePath(fmt.Sprintf("/k8s/storage/projects/%d/proxy", projectID))
+		req.URL.Path = config.FileBrowserUpstreamPath(req.URL.Path)
 		req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
-		req.Header.Set("X-Forwarded-Prefix", fmt.Sprintf("/k8s/storage/projects/%d/proxy", projectID))
+		req.Header.Set("X-Forwarded-Prefix", basePath)
 		req.Header.Set("X-Forwarded-Proto", "http")
 	}
 
 	// 6. Error Handler for Proxy
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 		// This usually happens if the Pod is not running or Service is unreachable
-		fmt.Printf("[Proxy Error] Target: %s, Error: %v\n", targetURL, err)
+		apiLog.Warn("file browser proxy request failed", "target", targetURL, "path", r.URL.Path, "error", err)
 
+		status := utils.ProxyErrorStatus(err)
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		_, _ = fmt.Fprintf(w, `{"error": "Storage service unreachable. Is the drive started?", "details": "%v"}`, err)
+		w.WriteHeader(status)
+		if status == http.StatusRequestEntityTooLarge {
+			_, _ = fmt.Fprintf(w, `{"error": "Upload exceeds the %d byte limit"}`, config.StorageProxyMaxUploadBytes)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"error": "Storage service unreachable. Is the drive started?"}`)
 	}
 
 	// 7. Serve Content
+	if !utils.LimitUploadBody(c.Writer, c.Request, config.StorageProxyMaxUploadBytes) {
+		return
+	}
 	proxy.ServeHTTP(c.Writer, c.Request)
 }
 
+// fileBrowserAccessAllowed resolves the caller's (cached) project role and rejects file
+// modifications through the proxy for roles that only have read access.
+func (h *K8sHandler) fileBrowserAccessAllowed(c *gin.Context, projectID uint) bool {
+	if isSuperAdmin(c) {
+		return true
+	}
+	uID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return false
+	}
+	role, err := h.projectRoles.get(uID, projectID, h.ProjectService.GetUserRoleInProjectGroup)
+	if err != nil {
+		return false
+	}
+	return fileBrowserRequestAllowed(c.Request.Method, c.Param("path"), !canWriteProjectStorage(role))
+}
+
+func isSuperAdmin(c *gin.Context) bool {
+	claimsVal, ok := c.Get("claims")
+	if !ok {
+		return false
+	}
+	claims, ok := claimsVal.(*types.Claims)
+	return ok && claims.IsAdmin
+}
+
 // StartProjectFileBrowser godoc
 // @Summary Start project file browser with Group Role RBAC
 // @Description Users with 'admin' or 'manager' roles in the project's owning group get RW access.
+// @Description A running drive is reused while it mounts the project's current storages; restart=true recreates it anyway.
 // @Tags k8s
+// @Param restart query bool false "Restart the drive even if it is up to date"
+// @Failure 503 {object} response.ErrorResponse "The pod did not become ready, reasons lists why, or the previous pod is still terminating"
 // @Router /k8s/storage/projects/{id}/start [post]
 func (h *K8sHandler) StartProjectFileBrowser(c *gin.Context) {
 	pIDStr := c.Param("id")
@@ -708,20 +1500,15 @@ func (h *K8sHandler) StartProjectFileBrowser(c *gin.Context) {
 	uID, _ := utils.GetUserIDFromContext(c)
 
 	// 1. Determine user's role based on Group ownership of the project
-	role, err := h.ProjectService.GetUserRoleInProjectGroup(uID, uint(pID))
+	normalizedRole, err := h.projectRoles.get(uID, uint(pID), h.ProjectService.GetUserRoleInProjectGroup)
 	if err != nil {
 		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "Access denied: role not found"})
 		return
 	}
 
-	// Normalize role for comparison (DB may return mixed-case values)
-	normalizedRole := strings.ToLower(strings.TrimSpace(role))
-	if normalizedRole == "" {
-		normalizedRole = "user"
-	}
-
-	// 2. Permission Logic: Only higher roles get Write access
-	isReadOnly := normalizedRole != "admin" && normalizedRole != "manager"
+	// 2. Permission Logic: Only higher roles get Write access. The shared pod itself is
+	// always started read-write; ProjectStorageProxy enforces this per request.
+	isReadOnly := !canWriteProjectStorage(normalizedRole) && !isSuperAdmin(c)
 
 	// 3. Metadata for K8s & ensure project hub exists
 	project, err := h.ProjectService.GetProject(uint(pID))
@@ -730,7 +1517,7 @@ func (h *K8sHandler) StartProjectFileBrowser(c *gin.Context) {
 		return
 	}
 
-	targetNamespace := k8s.GenerateSafeResourceName("project", project.ProjectName, project.PID)
+	targetNamespace := application.ProjectStorageNamespace(project)
 
 	// 4. Collect all project PVCs in this namespace for multi-mount gateway
 	pvcNames, err := h.K8sService.GetProjectPVCNames(c.Request.Context(), targetNamespace)
@@ -748,11 +1535,20 @@ func (h *K8sHandler) StartProjectFileBrowser(c *gin.Context) {
 		pvcNames = []string{}
 	}
 
-	baseURL := fmt.Sprintf("/k8s/storage/projects/%d/proxy", pID)
+	baseURL := config.FileBrowserBasePath(fmt.Sprintf("/k8s/storage/projects/%d/proxy", pID))
 	// 5. Start FileBrowser with the calculated readOnly flag and all PVCs mounted
-	_, err = h.K8sService.StartFileBrowser(c.Request.Context(), targetNamespace, pvcNames, isReadOnly, baseURL)
+	restart := c.Query("restart") == "true"
+	_, err = h.K8sService.StartFileBrowser(c.Request.Context(), project.PID, targetNamespace, pvcNames, false, baseURL, restart)
+	if errors.Is(err, application.ErrStorageNotReady) {
+		respondPodNotReady(c, response.CodeStorageNotReady, err)
+		return
+	}
+	if errors.Is(err, k8s.ErrPodDeleteTimeout) {
+		respondError(c, http.StatusServiceUnavailable, response.CodeStorageNotReady, err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 		return
 	}
 
@@ -791,11 +1587,11 @@ func (h *K8sHandler) StopProjectFileBrowser(c *gin.Context) {
 		return
 	}
 
-	targetNamespace := k8s.GenerateSafeResourceName("project", project.ProjectName, project.PID)
+	targetNamespace := application.ProjectStorageNamespace(project)
 
 	err = h.K8sService.StopFileBrowser(c.Request.Context(), targetNamespace)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
 		return
 	}
 
@@ -42,7 +42,7 @@ func PodLogHandler(c *gin.Context) {
 	// Ensure the connection is closed when the function exits and log any error
 	defer func() {
 		if err := conn.Close(); err != nil {
-			fmt.Printf("websocket close error: %v\n", err)
+			apiLog.Debug("failed to close websocket", "namespace", namespace, "pod", podName, "error", err)
 		}
 	}()
 
@@ -81,7 +81,7 @@ func PodLogHandler(c *gin.Context) {
 	// Ensure the stream is closed when the function exits and log any error
 	defer func() {
 		if err := stream.Close(); err != nil {
-			fmt.Printf("stream close error: %v\n", err)
+			apiLog.Debug("failed to close log stream", "namespace", namespace, "pod", podName, "error", err)
 		}
 	}()
 
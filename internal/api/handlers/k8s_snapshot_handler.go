@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// snapshotProject resolves the :id project; it writes the error response and returns nil on failure.
+func (h *K8sHandler) snapshotProject(c *gin.Context) *project.Project {
+	projectID, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid Project ID"})
+		return nil
+	}
+	p, err := h.ProjectService.GetProject(projectID)
+	if err != nil || p == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Project not found"})
+		return nil
+	}
+	return p
+}
+
+func writeSnapshotError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, application.ErrSnapshotNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, application.ErrStorageNotFound):
+		respondError(c, http.StatusNotFound, response.CodeStorageNotFound, err)
+	case errors.Is(err, application.ErrSnapshotNotReady):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, application.ErrRestoreTargetExists):
+		respondError(c, http.StatusConflict, response.CodeStorageConflict, err)
+	case errors.Is(err, k8s.ErrSnapshotsUnavailable):
+		c.JSON(http.StatusServiceUnavailable, response.ErrorResponse{Error: err.Error()})
+	default:
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+	}
+}
+
+// CreateProjectSnapshot snapshots every storage of a project.
+// @Summary Snapshot project storage
+// @Description Creates a VolumeSnapshot of each project PVC. The oldest snapshots beyond the per-project cap are pruned.
+// @Tags K8s/ProjectStorage
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 201 {object} response.SuccessResponse{data=[]k8s.VolumeSnapshotInfo}
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/storage/projects/{id}/snapshots [post]
+func (h *K8sHandler) CreateProjectSnapshot(c *gin.Context) {
+	p := h.snapshotProject(c)
+	if p == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	snaps, err := h.K8sService.CreateProjectSnapshots(ctx, p)
+	if err != nil {
+		writeSnapshotError(c, err)
+		return
+	}
+	respondSuccess(c, http.StatusCreated, "snapshot created", snaps, &legacyBody{http.StatusCreated, snaps})
+}
+
+// ListProjectSnapshots lists the snapshots of a project.
+// @Summary List project storage snapshots
+// @Tags K8s/ProjectStorage
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} response.SuccessResponse{data=[]k8s.VolumeSnapshotInfo}
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/storage/projects/{id}/snapshots [get]
+func (h *K8sHandler) ListProjectSnapshots(c *gin.Context) {
+	p := h.snapshotProject(c)
+	if p == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	snaps, err := h.K8sService.ListProjectSnapshots(ctx, p)
+	if err != nil {
+		writeSnapshotError(c, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, "success", snaps, &legacyBody{http.StatusOK, snaps})
+}
+
+// DeleteProjectSnapshot removes a snapshot.
+// @Summary Delete a project storage snapshot
+// @Tags K8s/ProjectStorage
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param name path string true "Snapshot name"
+// @Success 204
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/storage/projects/{id}/snapshots/{name} [delete]
+func (h *K8sHandler) DeleteProjectSnapshot(c *gin.Context) {
+	p := h.snapshotProject(c)
+	if p == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.K8sService.DeleteProjectSnapshot(ctx, p, c.Param("name")); err != nil {
+		writeSnapshotError(c, err)
+		return
+	}
+	respondNoContent(c, &legacyBody{http.StatusOK, response.MessageResponse{Message: "snapshot deleted"}})
+}
+
+// RestoreProjectSnapshot restores a snapshot into a new storage.
+// @Summary Restore a project storage snapshot
+// @Description Creates a new PVC from the snapshot with a "-restored" suffix; the original storage is left as is.
+// @Tags K8s/ProjectStorage
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param name path string true "Snapshot name"
+// @Success 201 {object} response.SuccessResponse{data=map[string]string}
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "Snapshot not ready or target exists"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /k8s/storage/projects/{id}/snapshots/{name}/restore [post]
+func (h *K8sHandler) RestoreProjectSnapshot(c *gin.Context) {
+	p := h.snapshotProject(c)
+	if p == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	pvc, err := h.K8sService.RestoreProjectSnapshot(ctx, p, c.Param("name"))
+	if err != nil {
+		writeSnapshotError(c, err)
+		return
+	}
+	restored := gin.H{
+		"pvcName":     pvc.Name,
+		"namespace":   pvc.Namespace,
+		"storageName": k8s.ProjectStorageName(pvc),
+	}
+	respondSuccess(c, http.StatusCreated, "snapshot restored", restored, &legacyBody{http.StatusCreated, restored})
+}
@@ -0,0 +1,5 @@
+package handlers
+
+import "github.com/linskybing/platform-go/pkg/logger"
+
+var apiLog = logger.For(logger.ComponentAPI)
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/maintenance"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+type MaintenanceHandler struct {
+	svc *application.MaintenanceService
+}
+
+func NewMaintenanceHandler(svc *application.MaintenanceService) *MaintenanceHandler {
+	return &MaintenanceHandler{svc: svc}
+}
+
+// SetMaintenance godoc
+// @Summary Turn platform maintenance on or off
+// @Description While active, job submissions and instance creation return 503 and the scheduler starts no queued job. Running jobs are left alone. Admins can still submit with ?override_maintenance=true.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body maintenance.SetMaintenanceInput true "Maintenance flag"
+// @Success 200 {object} maintenance.Maintenance
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /admin/maintenance [post]
+func (h *MaintenanceHandler) SetMaintenance(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	var input maintenance.SetMaintenanceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	m, err := h.svc.Set(c, uid, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// GetMaintenance godoc
+// @Summary Get the platform maintenance flag
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} maintenance.Maintenance
+// @Router /admin/maintenance [get]
+func (h *MaintenanceHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, h.svc.Current())
+}
+
+// GetStatus godoc
+// @Summary Platform status
+// @Description Unauthenticated; reports the maintenance banner for the frontend.
+// @Tags status
+// @Produce json
+// @Success 200 {object} maintenance.StatusDTO
+// @Router /status [get]
+func (h *MaintenanceHandler) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.svc.Status())
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func setupSnapshotRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	pod := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "train-0", "namespace": "proj-1-alice"},
+		"status":     map[string]interface{}{"phase": "Running"},
+	}}
+	pod.SetLabels(k8s.Ownership{ProjectID: 1, UserID: 5}.Labels())
+
+	orig := k8s.DynamicClient
+	t.Cleanup(func() { k8s.DynamicClient = orig })
+	k8s.DynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "pods"}:                       "PodList",
+		{Version: "v1", Resource: "services"}:                   "ServiceList",
+		{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+	}, pod)
+
+	h := NewK8sHandler(nil, nil, nil)
+	r := gin.New()
+	r.GET("/k8s/namespaces/:ns/snapshot", func(c *gin.Context) {
+		c.Set("claims", &types.Claims{UserID: 5})
+		c.Next()
+	}, h.GetNamespaceSnapshot)
+	return r
+}
+
+func TestGetNamespaceSnapshot(t *testing.T) {
+	r := setupSnapshotRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/k8s/namespaces/proj-1-alice/snapshot?kinds=pods,services", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data k8s.NamespaceSnapshot `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	snap := body.Data
+	if snap.Namespace != "proj-1-alice" || len(snap.Resources) != 2 || len(snap.Resources["services"]) != 0 {
+		t.Fatalf("expected pods and services only, got %+v", snap)
+	}
+	pods := snap.Resources["pods"]
+	if len(pods) != 1 || pods[0]["name"] != "train-0" || pods[0]["status"] != "Running" || pods[0]["mine"] != true {
+		t.Fatalf("unexpected pods %v", pods)
+	}
+}
+
+func TestGetNamespaceSnapshotRejectsUnknownKind(t *testing.T) {
+	r := setupSnapshotRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/k8s/namespaces/proj-1-alice/snapshot?kinds=secrets", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	appjob "github.com/linskybing/platform-go/internal/application/job"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/internal/repository/mock"
+	"github.com/linskybing/platform-go/pkg/pagination"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+// setupJobListRouter serves ListJobs to memberID over seven jobs of the member, three of them
+// created in the same second, and one job of another user.
+func setupJobListRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := dbtest.Open(t, &job.Job{})
+	base := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{0, time.Minute, 2 * time.Minute, 2 * time.Minute, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute}
+	for i, offset := range offsets {
+		j := job.Job{UserID: memberID, Name: "train", Namespace: "ns", Image: "img", K8sJobName: "train", CreatedAt: base.Add(offset)}
+		if err := db.Create(&j).Error; err != nil {
+			t.Fatalf("failed to seed job %d: %v", i, err)
+		}
+	}
+	db.Create(&job.Job{UserID: managerID, Name: "other", Namespace: "ns", Image: "img", K8sJobName: "other", CreatedAt: base})
+
+	ctrl := gomock.NewController(t)
+	userGroups := mock.NewMockUserGroupRepo(ctrl)
+	userGroups.EXPECT().IsSuperAdmin(uint(memberID)).Return(false, nil).AnyTimes()
+	jobs := repository.NewJobRepo(db)
+	h := NewJobHandler(appjob.NewService(jobs, nil, nil), &repository.Repos{Job: jobs, UserGroup: userGroups})
+
+	r := gin.New()
+	r.GET("/jobs", func(c *gin.Context) {
+		c.Set("claims", &types.Claims{UserID: memberID})
+		c.Next()
+	}, h.ListJobs)
+	return r
+}
+
+func listJobs(t *testing.T, r *gin.Engine, query url.Values) (*httptest.ResponseRecorder, pagination.Page[job.Job]) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs?"+query.Encode(), nil))
+	var body struct {
+		Data pagination.Page[job.Job] `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid body: %v", err)
+		}
+	}
+	return w, body.Data
+}
+
+func TestListJobsPagesThroughCursor(t *testing.T) {
+	r := setupJobListRouter(t)
+
+	var ids []uint
+	query := url.Values{"limit": {"3"}, "total": {"true"}}
+	for n := 1; ; n++ {
+		w, page := listJobs(t, r, query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("page %d: expected 200, got %d: %s", n, w.Code, w.Body.String())
+		}
+		if page.Total == nil || *page.Total != 7 || page.TotalEstimated {
+			t.Fatalf("page %d: expected an exact total of 7, got %v", n, page.Total)
+		}
+		for _, j := range page.Items {
+			ids = append(ids, j.ID)
+		}
+		if page.NextCursor == "" {
+			if n != 3 || len(page.Items) != 1 {
+				t.Fatalf("expected the last page to be the third with one job, got page %d with %d", n, len(page.Items))
+			}
+			break
+		}
+		if len(page.Items) != 3 {
+			t.Fatalf("page %d: expected 3 jobs, got %d", n, len(page.Items))
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+
+	// Newest first, ties on created_at broken by the highest id
+	want := []uint{7, 6, 5, 4, 3, 2, 1}
+	if len(ids) != len(want) {
+		t.Fatalf("expected jobs %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("expected jobs %v, got %v", want, ids)
+		}
+	}
+}
+
+func TestListJobsRejectsInvalidCursor(t *testing.T) {
+	r := setupJobListRouter(t)
+
+	w, _ := listJobs(t, r, url.Values{"cursor": {"bm90LWEtY3Vyc29y"}})
+	var body response.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || body.Code != response.CodeInvalidCursor {
+		t.Fatalf("expected 400 %s, got %d %+v", response.CodeInvalidCursor, w.Code, body)
+	}
+}
+
+func TestListJobsWithoutPaginationReturnsArray(t *testing.T) {
+	r := setupJobListRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	var body struct {
+		Data []job.Job `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a plain list without limit or cursor: %v", err)
+	}
+	if len(body.Data) != 7 {
+		t.Fatalf("expected the member's 7 jobs, got %d", len(body.Data))
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+)
+
+// checkConfigContentSize rejects config file and template YAML above config.ConfigFileMaxContentBytes.
+func checkConfigContentSize(content string) error {
+	if len(content) > config.ConfigFileMaxContentBytes {
+		return fmt.Errorf("raw_yaml is %d bytes, exceeding the %d byte limit", len(content), config.ConfigFileMaxContentBytes)
+	}
+	return nil
+}
+
+// checkJobSubmissionSize bounds the command, args and env of a job, which end up in the pod spec.
+func checkJobSubmissionSize(s job.JobSubmission) error {
+	command := 0
+	for _, part := range append(append([]string(nil), s.Command...), s.Args...) {
+		command += len(part)
+	}
+	if command > config.JobCommandMaxBytes {
+		return fmt.Errorf("command and args are %d bytes, exceeding the %d byte limit", command, config.JobCommandMaxBytes)
+	}
+	env := 0
+	for k, v := range s.Env {
+		env += len(k) + len(v)
+	}
+	if env > config.JobEnvMaxBytes {
+		return fmt.Errorf("env is %d bytes, exceeding the %d byte limit", env, config.JobEnvMaxBytes)
+	}
+	return nil
+}
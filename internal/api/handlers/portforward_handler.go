@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"k8s.io/client-go/kubernetes"
+)
+
+// tunnelLimiter caps the number of concurrent port-forward tunnels per user
+type tunnelLimiter struct {
+	mu     sync.Mutex
+	counts map[uint]int
+}
+
+var portForwardTunnels = &tunnelLimiter{counts: make(map[uint]int)}
+
+func (l *tunnelLimiter) acquire(uid uint, max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if max > 0 && l.counts[uid] >= max {
+		return false
+	}
+	l.counts[uid]++
+	return true
+}
+
+func (l *tunnelLimiter) release(uid uint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[uid]--
+	if l.counts[uid] <= 0 {
+		delete(l.counts, uid)
+	}
+}
+
+// PortForwardWebSocketHandler tunnels a single TCP stream to a pod port over WebSocket
+// @Summary Port-forward to a pod over WebSocket
+// @Tags k8s
+// @Param namespace path string true "Namespace"
+// @Param pod path string true "Pod name"
+// @Param port path int true "Container port"
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /k8s/pods/{namespace}/{pod}/portforward/{port} [get]
+func PortForwardWebSocketHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	podName := c.Param("pod")
+	port, err := strconv.Atoi(c.Param("port"))
+	if err != nil || port <= 0 || port > 65535 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid port"})
+		return
+	}
+
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	cs, ok := k8s.Clientset.(*kubernetes.Clientset)
+	if !ok || cs == nil {
+		c.JSON(http.StatusServiceUnavailable, response.ErrorResponse{Error: "k8s client not available"})
+		return
+	}
+
+	if !portForwardTunnels.acquire(uid, config.IntSetting(config.SettingPortForwardMaxTunnelsPerUser)) {
+		c.JSON(http.StatusTooManyRequests, response.ErrorResponse{Error: "too many open port-forward tunnels"})
+		return
+	}
+	defer portForwardTunnels.release(uid)
+
+	dialer, err := k8s.NewPodPortForwardDialer(k8s.Config, cs, namespace, podName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "websocket upgrade failed: " + err.Error()})
+		return
+	}
+
+	if err := k8s.PortForwardViaWebSocket(conn, dialer, port, config.DurationSetting(config.SettingPortForwardIdleTimeout)); err != nil {
+		// Close frame payloads are limited to 125 bytes
+		reason := err.Error()
+		if len(reason) > 120 {
+			reason = reason[:120]
+		}
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, reason),
+			time.Now().Add(writeWait))
+		_ = conn.Close()
+	}
+}
@@ -3,8 +3,10 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/api/middleware"
 	"github.com/linskybing/platform-go/internal/application"
 	"github.com/linskybing/platform-go/internal/domain/project"
 	"github.com/linskybing/platform-go/pkg/response"
@@ -149,9 +151,14 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 
 	project, err := h.svc.CreateProject(c, input)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidName) || errors.Is(err, application.ErrInvalidMPSConfig) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
 		return
 	}
+	middleware.SetActivityEntity(c, project.PID)
 
 	c.JSON(http.StatusOK, project)
 }
@@ -195,6 +202,8 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 	if err != nil {
 		if errors.Is(err, application.ErrProjectNotFound) {
 			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		} else if errors.Is(err, utils.ErrInvalidName) || errors.Is(err, application.ErrInvalidMPSConfig) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
 		}
@@ -209,10 +218,18 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 // @Tags projects
 // @Security BearerAuth
 // @Produce json
+// @Description With cascade=true (super admin only) config files, storage, member namespaces,
+// @Description jobs and project image rules are removed too, step by step; repeating the call
+// @Description after a failure resumes the deletion. Running jobs block it unless force=true.
 // @Param id path uint true "Project ID"
+// @Param cascade query bool false "Delete everything belonging to the project"
+// @Param force query bool false "Cancel running jobs instead of refusing"
 // @Success 200 {object} response.MessageResponse "Project deleted"
+// @Success 200 {object} project.ProjectDeletion "Cascading deletion finished"
 // @Failure 400 {object} response.ErrorResponse "Invalid project id"
+// @Failure 403 {object} response.ErrorResponse "Cascade requires super admin"
 // @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 409 {object} response.ErrorResponse "Project has running jobs"
 // @Failure 500 {object} response.ErrorResponse "Internal server error"
 // @Router /projects/{id} [delete]
 func (h *ProjectHandler) DeleteProject(c *gin.Context) {
@@ -222,6 +239,11 @@ func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 		return
 	}
 
+	if c.Query("cascade") == "true" {
+		h.deleteProjectCascade(c, id)
+		return
+	}
+
 	err = h.svc.DeleteProject(c, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
@@ -229,3 +251,587 @@ func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, response.MessageResponse{Message: "project deleted"})
 }
+
+func (h *ProjectHandler) deleteProjectCascade(c *gin.Context, id uint) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	isAdmin, err := utils.IsSuperAdmin(uid, h.svc.Repos.UserGroup)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "cascading delete requires super admin"})
+		return
+	}
+
+	deletion, err := h.svc.DeleteProjectCascade(c, id, c.Query("force") == "true")
+	switch {
+	case errors.Is(err, application.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+	case errors.Is(err, application.ErrProjectHasRunningJobs):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+	default:
+		c.JSON(http.StatusOK, deletion)
+	}
+}
+
+// ListEnvDefaults godoc
+// @Summary List project environment defaults
+// @Description Secret values are masked.
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Success 200 {array} project.ProjectEnvDefault
+// @Failure 400 {object} response.ErrorResponse "Invalid project id"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/env [get]
+func (h *ProjectHandler) ListEnvDefaults(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	envs, err := h.svc.ListEnvDefaults(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, envs)
+}
+
+// GetUsageHistory godoc
+// @Summary Project usage history
+// @Description What the running pods of the project requested over the last hours, downsampled for a sparkline. Each point holds the max and avg of the project totals sampled in its bucket; buckets without samples have null values.
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param hours query int false "Hours to look back (default 24)"
+// @Param metric query string false "gpu (default), cpu (millicores) or memory (bytes)"
+// @Success 200 {object} project.UsageHistory
+// @Failure 400 {object} response.ErrorResponse "Invalid metric or range"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/usage/history [get]
+func (h *ProjectHandler) GetUsageHistory(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid hours"})
+		return
+	}
+	history, err := h.svc.UsageHistory(id, hours, c.Query("metric"))
+	if err != nil {
+		if errors.Is(err, application.ErrUsageMetricInvalid) || errors.Is(err, application.ErrUsageRangeInvalid) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetGPUConfig godoc
+// @Summary Effective MPS settings of the project's shared-GPU pods
+// @Description The environment a pod of the project sharing a GPU through MPS receives: CUDA_MPS_ACTIVE_THREAD_PERCENTAGE derived from the units it holds, and the pinned memory limit. valid is false, with the reason, when the project has no GPU quota or its memory limit times the pods sharing a GPU exceeds the GPU's VRAM.
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param units query int false "Quota units the pod requests (default the whole quota)"
+// @Success 200 {object} application.GPUConfig
+// @Failure 400 {object} response.ErrorResponse "Invalid units"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Router /projects/{id}/gpu-config [get]
+func (h *ProjectHandler) GetGPUConfig(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	units, err := strconv.Atoi(c.DefaultQuery("units", "0"))
+	if err != nil || units < 0 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid units"})
+		return
+	}
+	cfg, err := h.svc.GPUConfig(id, units)
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
+
+// SetEnvDefault godoc
+// @Summary Create or update a project environment default
+// @Description Injected into every instance and job of the project unless the container already defines the key.
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param body body project.UpsertProjectEnvDefaultDTO true "Env default"
+// @Success 200 {object} project.ProjectEnvDefault
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/env [put]
+func (h *ProjectHandler) SetEnvDefault(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	var input project.UpsertProjectEnvDefaultDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	env, err := h.svc.SetEnvDefault(c, id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		case errors.Is(err, application.ErrInvalidEnvKey):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, env)
+}
+
+// DeleteEnvDefault godoc
+// @Summary Delete a project environment default
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param key path string true "Env key"
+// @Success 200 {object} response.MessageResponse "Env default deleted"
+// @Failure 400 {object} response.ErrorResponse "Invalid project id"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/env/{key} [delete]
+func (h *ProjectHandler) DeleteEnvDefault(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	if err := h.svc.DeleteEnvDefault(c, id, c.Param("key")); err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "env default deleted"})
+}
+
+// GetSchedulingPolicy godoc
+// @Summary Get a project scheduling policy
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Success 200 {object} project.SchedulingPolicy
+// @Failure 400 {object} response.ErrorResponse "Invalid project id"
+// @Failure 404 {object} response.ErrorResponse "No scheduling policy"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/scheduling [get]
+func (h *ProjectHandler) GetSchedulingPolicy(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	policy, err := h.svc.GetSchedulingPolicy(id)
+	if err != nil {
+		if errors.Is(err, application.ErrSchedulingPolicyNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// SetSchedulingPolicy godoc
+// @Summary Create or replace a project scheduling policy
+// @Description Tolerations, node selector and affinity injected into the pods of the project's instances and jobs. Fields a workload sets itself win unless force is set.
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param body body project.SchedulingPolicyDTO true "Scheduling policy"
+// @Success 200 {object} project.SchedulingPolicy
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/scheduling [put]
+func (h *ProjectHandler) SetSchedulingPolicy(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	var input project.SchedulingPolicyDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	policy, err := h.svc.SetSchedulingPolicy(c, id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		case errors.Is(err, application.ErrInvalidSchedulingPolicy):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// SetJobLimits godoc
+// @Summary Set the job limits of a project
+// @Description Unfinished jobs allowed in the project and per member, GPU or not, where 0 means unlimited, and the longest a member's job may run, where 0 means the platform default. With strict_volume_checks, jobs mounting a PVC that is unbound or a ReadWriteOnce claim already in use are rejected instead of created with a warning. Omitted fields are kept.
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param body body project.JobLimitsDTO true "Job limits"
+// @Success 200 {object} project.Project
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/job-limits [put]
+func (h *ProjectHandler) SetJobLimits(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	var input project.JobLimitsDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	p, err := h.svc.SetJobLimits(c, id, input)
+	if err != nil {
+		if errors.Is(err, application.ErrProjectNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// SetChargeback godoc
+// @Summary Set the cost center and cost tags of a project
+// @Description Labels every namespace, PVC, job and pod the platform creates for the project with its cost center and free-form cost tags, so cluster costs can be attributed to grants. Existing namespaces and PVCs are relabelled in the background. Omitted fields are kept; an empty cost center or an empty tag map clears them.
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param body body project.ChargebackDTO true "Cost center and tags"
+// @Success 200 {object} project.Project
+// @Failure 400 {object} response.ErrorResponse "Invalid cost center or tags"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/chargeback [put]
+func (h *ProjectHandler) SetChargeback(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	var input project.ChargebackDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	p, err := h.svc.SetChargeback(c, id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		case errors.Is(err, application.ErrInvalidChargeback):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// SetResourceDefaults godoc
+// @Summary Set the CPU and memory defaults of a project's instances
+// @Description Containers of the project's instances that request or limit neither CPU nor memory get these requests, and limits of the multiplier times them; GPU requests are left alone. An empty request or a multiplier of 0 uses the platform default, and disabled turns the injection off for projects whose members size their containers themselves. Omitted fields are kept.
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param body body project.ResourceDefaultsDTO true "Resource defaults"
+// @Success 200 {object} project.Project
+// @Failure 400 {object} response.ErrorResponse "Invalid quantity or multiplier"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/resource-defaults [put]
+func (h *ProjectHandler) SetResourceDefaults(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	var input project.ResourceDefaultsDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	p, err := h.svc.SetResourceDefaults(c, id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		case errors.Is(err, application.ErrInvalidResourceDefaults):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// SetHostPathExceptions godoc
+// @Summary Set the host paths members of a project may mount
+// @Description Config files of non-admins may not mount hostPath volumes, use the host network, PID or IPC namespaces or run privileged containers. The host paths listed here, and their subdirectories, are exempt for the project, e.g. /dev/infiniband for RDMA. The list replaces the previous one; an empty list revokes every exception.
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param body body project.HostPathExceptionsDTO true "Allowed host paths"
+// @Success 200 {object} project.Project
+// @Failure 400 {object} response.ErrorResponse "Invalid path"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/host-path-exceptions [put]
+func (h *ProjectHandler) SetHostPathExceptions(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	var input project.HostPathExceptionsDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	p, err := h.svc.SetHostPathExceptions(c, id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		case errors.Is(err, application.ErrInvalidHostPath):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// DeleteSchedulingPolicy godoc
+// @Summary Delete a project scheduling policy
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Success 200 {object} response.MessageResponse "Scheduling policy deleted"
+// @Failure 400 {object} response.ErrorResponse "Invalid project id"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/scheduling [delete]
+func (h *ProjectHandler) DeleteSchedulingPolicy(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	if err := h.svc.DeleteSchedulingPolicy(c, id); err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "scheduling policy deleted"})
+}
+
+// ListRegistryCredentials godoc
+// @Summary List a project's registry credentials
+// @Description Tokens are masked.
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Success 200 {array} project.RegistryCredentialDTO
+// @Failure 400 {object} response.ErrorResponse "Invalid project id"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/registry-credentials [get]
+func (h *ProjectHandler) ListRegistryCredentials(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	creds, err := h.svc.ListRegistryCredentials(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, creds)
+}
+
+// SetRegistryCredential godoc
+// @Summary Create or update a project registry credential
+// @Description Stores the token encrypted. Instances, jobs and image pulls of the project use it for images on that registry.
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param body body project.UpsertRegistryCredentialDTO true "Registry credential"
+// @Success 200 {object} project.RegistryCredentialDTO
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 404 {object} response.ErrorResponse "Project not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/registry-credentials [put]
+func (h *ProjectHandler) SetRegistryCredential(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	var input project.UpsertRegistryCredentialDTO
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	cred, err := h.svc.SetRegistryCredential(c, id, uid, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "project not found"})
+		case errors.Is(err, application.ErrInvalidRegistryHost):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, cred)
+}
+
+// DeleteRegistryCredential godoc
+// @Summary Delete a project registry credential
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param cred_id path uint true "Credential ID"
+// @Success 200 {object} response.MessageResponse "Registry credential deleted"
+// @Failure 400 {object} response.ErrorResponse "Invalid id"
+// @Failure 404 {object} response.ErrorResponse "Credential not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /projects/{id}/registry-credentials/{cred_id} [delete]
+func (h *ProjectHandler) DeleteRegistryCredential(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	credID, err := utils.ParseIDParam(c, "cred_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid credential id"})
+		return
+	}
+	if err := h.svc.DeleteRegistryCredential(c, id, credID); err != nil {
+		if errors.Is(err, application.ErrRegistryCredentialNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "registry credential deleted"})
+}
+
+// ValidateRegistryCredential godoc
+// @Summary Test a project registry credential
+// @Description Logs in to the registry with the stored credential.
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Project ID"
+// @Param cred_id path uint true "Credential ID"
+// @Success 200 {object} response.MessageResponse "Credential accepted"
+// @Failure 400 {object} response.ErrorResponse "Invalid id"
+// @Failure 404 {object} response.ErrorResponse "Credential not found"
+// @Failure 422 {object} response.ErrorResponse "Registry rejected the credential"
+// @Failure 502 {object} response.ErrorResponse "Registry unreachable"
+// @Router /projects/{id}/registry-credentials/{cred_id}/validate [post]
+func (h *ProjectHandler) ValidateRegistryCredential(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid project id"})
+		return
+	}
+	credID, err := utils.ParseIDParam(c, "cred_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid credential id"})
+		return
+	}
+	if err := h.svc.ValidateRegistryCredential(c.Request.Context(), id, credID); err != nil {
+		switch {
+		case errors.Is(err, application.ErrRegistryCredentialNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrRegistryLoginFailed):
+			c.JSON(http.StatusUnprocessableEntity, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "registry accepted the credential"})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/search"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+type SearchHandler struct {
+	svc *application.SearchService
+}
+
+func NewSearchHandler(svc *application.SearchService) *SearchHandler {
+	return &SearchHandler{svc: svc}
+}
+
+// Search godoc
+// @Summary Search projects, config files, jobs and images
+// @Description Case-insensitive substring search over the projects the caller is a member of (name, description), the config files of those projects (file name, YAML), the caller's jobs (name, image) and the allow-listed images of those projects and of the platform. Admins search everything. Hits are listed per kind, most recently updated first and at most 20 per kind, with a snippet around the match and the character ranges to highlight in it. A kind's next_cursor continues that kind alone.
+// @Tags search
+// @Security BearerAuth
+// @Produce json
+// @Param q query string true "Text to find, 2 to 100 characters"
+// @Param types query string false "Comma-separated kinds: project, configfile, job, image; all by default"
+// @Param cursor query string false "next_cursor of a previous result"
+// @Success 200 {object} search.Result
+// @Failure 400 {object} response.ErrorResponse "Invalid query, type or cursor"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	var types []string
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	scope := search.Scope{UserID: uid, All: isSuperAdmin(c)}
+	result, err := h.svc.Search(scope, c.Query("q"), types, c.Query("cursor"))
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrInvalidSearchQuery),
+			errors.Is(err, application.ErrInvalidSearchType),
+			errors.Is(err, application.ErrInvalidSearchCursor):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/setting"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+type SettingsHandler struct {
+	svc *application.SettingsService
+}
+
+func NewSettingsHandler(svc *application.SettingsService) *SettingsHandler {
+	return &SettingsHandler{svc: svc}
+}
+
+// GetSettings godoc
+// @Summary List runtime settings
+// @Description Tunable settings with the value in effect and the environment default it overrides.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} setting.SettingDTO
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /admin/settings [get]
+func (h *SettingsHandler) GetSettings(c *gin.Context) {
+	settings, err := h.svc.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings godoc
+// @Summary Change runtime settings
+// @Description Values are checked against each setting's type; a null value returns the setting to its environment default. Other API processes apply the change within the settings refresh interval.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body setting.UpdateSettingsInput true "Settings by key"
+// @Success 200 {array} setting.SettingDTO
+// @Failure 400 {object} response.ErrorResponse "Unknown setting or invalid value"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /admin/settings [put]
+func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	var input setting.UpdateSettingsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	settings, err := h.svc.Update(c, uid, input)
+	if err != nil {
+		if errors.Is(err, application.ErrUnknownSetting) || errors.Is(err, config.ErrInvalidSetting) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
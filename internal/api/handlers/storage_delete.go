@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+// confirmStorageDelete runs the two-phase confirmation of a destructive storage endpoint and
+// reports whether the delete may go ahead. Without a token it answers 202 with a confirmation
+// token and a summary of what would be destroyed; the client repeats the DELETE with
+// ?confirm=<token> to carry it out. ?force=true skips the confirmation for automation, which
+// needs the storage:delete scope when it uses an API token.
+func (h *K8sHandler) confirmStorageDelete(c *gin.Context, target application.StorageDeleteTarget) bool {
+	claimsVal, _ := c.Get("claims")
+	claims, _ := claimsVal.(*types.Claims)
+	if claims == nil || claims.UserID == 0 {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return false
+	}
+
+	if c.Query("force") == "true" {
+		if !claims.HasScope(user.ScopeStorageDelete) {
+			c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "force delete requires the " + user.ScopeStorageDelete + " scope"})
+			return false
+		}
+		h.K8sService.ForceStorageDelete(c, target)
+		return true
+	}
+
+	token := c.Query("confirm")
+	if token == "" {
+		conf, err := h.K8sService.RequestStorageDelete(c, target, claims.UserID)
+		if err != nil {
+			if errors.Is(err, application.ErrStorageNotFound) {
+				respondError(c, http.StatusNotFound, response.CodeStorageNotFound, err)
+				return false
+			}
+			respondError(c, http.StatusInternalServerError, response.CodeInternal, fmt.Errorf("failed to prepare delete: %w", err))
+			return false
+		}
+		respondSuccess(c, http.StatusAccepted, "Repeat the request with the confirmation token to delete", conf, nil)
+		return false
+	}
+
+	if err := h.K8sService.ConfirmStorageDelete(c, target, claims.UserID, token); err != nil {
+		code := response.CodeConfirmInvalid
+		if errors.Is(err, application.ErrDeleteConfirmationExpired) {
+			code = response.CodeConfirmExpired
+		}
+		respondError(c, http.StatusBadRequest, code, err)
+		return false
+	}
+	return true
+}
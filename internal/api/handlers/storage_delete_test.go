@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	"github.com/linskybing/platform-go/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// setupStorageDeleteRouter serves DeleteUserStorage for a session of claims over a cluster
+// holding the hubs of alice and bob. It returns the audit actions logged.
+func setupStorageDeleteRouter(t *testing.T, claims *types.Claims) (*gin.Engine, *[]string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	origClient, origLog := k8s.Clientset, utils.LogAuditWithConsole
+	t.Cleanup(func() { k8s.Clientset, utils.LogAuditWithConsole = origClient, origLog })
+
+	var objs []runtime.Object
+	for _, name := range []string{"alice", "bob"} {
+		ns := "user-" + name + "-storage"
+		objs = append(objs,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}},
+			&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "user-" + name + "-disk", Namespace: ns},
+				Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("100Gi")},
+				}},
+			},
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nfs-server", Namespace: ns}},
+		)
+	}
+	k8s.Clientset = k8sfake.NewSimpleClientset(objs...)
+	var audited []string
+	utils.LogAuditWithConsole = func(c *gin.Context, action, resourceType, resourceID string, oldData, newData interface{}, msg string, repos repository.AuditRepo) {
+		audited = append(audited, action+" "+resourceType+" "+resourceID)
+	}
+
+	h := NewK8sHandler(application.NewK8sService(&repository.Repos{}), nil, nil)
+	r := gin.New()
+	r.DELETE("/k8s/users/:username/storage", func(c *gin.Context) {
+		c.Set("claims", claims)
+		c.Next()
+	}, h.DeleteUserStorage)
+	return r, &audited
+}
+
+func deleteStorage(r *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, path, nil))
+	return w
+}
+
+func namespaceExists(t *testing.T, name string) bool {
+	t.Helper()
+	_, err := k8s.Clientset.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+	return err == nil
+}
+
+func TestDeleteUserStorageRequiresConfirmation(t *testing.T) {
+	r, audited := setupStorageDeleteRouter(t, &types.Claims{UserID: 1, Username: "admin"})
+
+	w := deleteStorage(r, "/k8s/users/alice/storage")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data application.StorageDeleteConfirmation `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	conf := body.Data
+	if conf.Token == "" || !conf.Summary.DeletesNamespace || conf.Summary.Pods != 1 ||
+		len(conf.Summary.PVCs) != 1 || conf.Summary.PVCs[0].Capacity != "100Gi" {
+		t.Fatalf("unexpected confirmation %+v", conf)
+	}
+	if !namespaceExists(t, "user-alice-storage") {
+		t.Fatal("the first DELETE must not delete anything")
+	}
+
+	// The token of alice's hub cannot delete bob's
+	if w := deleteStorage(r, "/k8s/users/bob/storage?confirm="+conf.Token); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a token of another resource, got %d", w.Code)
+	}
+	if !namespaceExists(t, "user-bob-storage") {
+		t.Fatal("a mismatched token must not delete anything")
+	}
+
+	if w := deleteStorage(r, "/k8s/users/alice/storage?confirm="+conf.Token); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if namespaceExists(t, "user-alice-storage") {
+		t.Fatal("expected the confirmed delete to remove the namespace")
+	}
+	want := []string{"delete_request user_storage user-storage/alice", "delete_confirm user_storage user-storage/alice"}
+	if len(*audited) != 2 || (*audited)[0] != want[0] || (*audited)[1] != want[1] {
+		t.Fatalf("expected the request and the confirmation to be audited, got %v", *audited)
+	}
+}
+
+func TestDeleteUserStorageForce(t *testing.T) {
+	token := &types.Claims{UserID: 1, Username: "admin", TokenID: 3, Scopes: []string{user.ScopeJobsWrite}}
+	r, _ := setupStorageDeleteRouter(t, token)
+	if w := deleteStorage(r, "/k8s/users/alice/storage?force=true"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an API token without storage:delete, got %d", w.Code)
+	}
+	if !namespaceExists(t, "user-alice-storage") {
+		t.Fatal("a rejected force delete must not delete anything")
+	}
+
+	token.Scopes = append(token.Scopes, user.ScopeStorageDelete)
+	if w := deleteStorage(r, "/k8s/users/alice/storage?force=true"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if namespaceExists(t, "user-alice-storage") {
+		t.Fatal("expected the forced delete to remove the namespace")
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/linskybing/platform-go/internal/api/middleware"
 	"github.com/linskybing/platform-go/internal/application"
 	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/user"
@@ -124,6 +127,10 @@ func (h *UserHandler) Login(c *gin.Context) {
 	}
 
 	user, token, isAdmin, err := h.svc.LoginUser(req.Username, req.Password)
+	if errors.Is(err, application.ErrLocalLoginDisabled) {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Invalid username or password"})
 		return
@@ -148,6 +155,155 @@ func (h *UserHandler) Login(c *gin.Context) {
 	})
 }
 
+const oidcStateCookie = "oidc_state"
+
+// OIDCLogin godoc
+// @Summary Start single sign-on
+// @Description Redirects the browser to the configured OIDC issuer.
+// @Tags auth
+// @Success 302 {string} string "Redirect to the identity provider"
+// @Failure 404 {object} response.ErrorResponse "OIDC login is not enabled"
+// @Failure 502 {object} response.ErrorResponse "Identity provider unreachable"
+// @Router /auth/oidc/login [get]
+func (h *UserHandler) OIDCLogin(c *gin.Context) {
+	state, nonce := randomToken(), randomToken()
+	authURL, err := h.svc.OIDCLoginURL(c.Request.Context(), state, nonce)
+	if err != nil {
+		if errors.Is(err, application.ErrOIDCDisabled) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, response.ErrorResponse{Error: "Identity provider unavailable: " + err.Error()})
+		return
+	}
+
+	// State and nonce are bound to this browser for the short duration of the login
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state+"."+nonce, 300, "/auth/oidc", "", config.IsProduction, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback godoc
+// @Summary Complete single sign-on
+// @Description Validates the authorization code, maps the identity to a platform user and issues the platform JWT.
+// @Tags auth
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State returned by the issuer"
+// @Success 200 {object} response.TokenResponse "JWT token and user info"
+// @Failure 400 {object} response.ErrorResponse "Invalid state or code"
+// @Failure 401 {object} response.ErrorResponse "Identity could not be verified"
+// @Failure 403 {object} response.ErrorResponse "No platform account for this identity"
+// @Failure 409 {object} response.ErrorResponse "An unlinked account has this username; link it from a session"
+// @Router /auth/oidc/callback [get]
+func (h *UserHandler) OIDCCallback(c *gin.Context) {
+	if errMsg := c.Query("error"); errMsg != "" {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Identity provider error: " + errMsg})
+		return
+	}
+
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", config.IsProduction, true)
+	state, nonce, ok := strings.Cut(cookie, ".")
+	code := c.Query("code")
+	if !ok || state == "" || state != c.Query("state") || code == "" {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid or expired login state"})
+		return
+	}
+
+	if h.svc.IsOIDCLink(state) {
+		h.completeOIDCLink(c, state, code, nonce)
+		return
+	}
+
+	user, token, isAdmin, err := h.svc.LoginWithOIDC(c.Request.Context(), code, nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrOIDCDisabled):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrOIDCLinkRequired):
+			c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrOIDCUserNotProvisioned), errors.Is(err, application.ErrOIDCAccountConflict):
+			c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Single sign-on failed: " + err.Error()})
+		}
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("token", token, 3600, "/", "", config.IsProduction, true)
+
+	if config.OIDCPostLoginRedirect != "" {
+		c.Redirect(http.StatusFound, config.OIDCPostLoginRedirect)
+		return
+	}
+	c.JSON(http.StatusOK, response.TokenResponse{
+		Token:    token,
+		UID:      user.UID,
+		Username: user.Username,
+		IsAdmin:  isAdmin,
+	})
+}
+
+// completeOIDCLink finishes a link started with StartOIDCLink. The session is not replaced.
+func (h *UserHandler) completeOIDCLink(c *gin.Context, state, code, nonce string) {
+	if _, err := h.svc.CompleteOIDCLink(c.Request.Context(), state, code, nonce); err != nil {
+		switch {
+		case errors.Is(err, application.ErrOIDCLinkExpired):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrOIDCAccountConflict):
+			c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Identity link failed: " + err.Error()})
+		}
+		return
+	}
+	if config.OIDCPostLoginRedirect != "" {
+		c.Redirect(http.StatusFound, config.OIDCPostLoginRedirect)
+		return
+	}
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "Identity linked"})
+}
+
+// StartOIDCLink godoc
+// @Summary Link a single sign-on identity to the caller
+// @Description Returns the issuer URL to open in the browser. After signing in there, the callback links the identity to the caller's account, which is required when an account with the same username exists but the issuer did not verify a matching email.
+// @Tags me
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string "auth_url"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "OIDC login is not enabled"
+// @Failure 502 {object} response.ErrorResponse "Identity provider unreachable"
+// @Router /me/oidc/link [post]
+func (h *UserHandler) StartOIDCLink(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	state, nonce := randomToken(), randomToken()
+	authURL, err := h.svc.StartOIDCLink(c.Request.Context(), uid, state, nonce)
+	if err != nil {
+		if errors.Is(err, application.ErrOIDCDisabled) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, response.ErrorResponse{Error: "Identity provider unavailable: " + err.Error()})
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state+"."+nonce, 300, "/auth/oidc", "", config.IsProduction, true)
+	c.JSON(http.StatusOK, gin.H{"auth_url": authURL})
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // Logout godoc
 // @Summary User logout
 // @Tags auth
@@ -328,6 +484,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 // @Success 200 {object} user.UserDTO "Updated user info"
 // @Failure 400 {object} response.ErrorResponse "Bad request error"
 // @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 403 {object} response.ErrorResponse "Password change in an impersonated session"
 // @Failure 404 {object} response.ErrorResponse "User not found"
 // @Failure 500 {object} response.ErrorResponse "Internal server error"
 // @Router /users/{id} [put]
@@ -343,6 +500,10 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
+	if input.Password != nil && middleware.IsImpersonated(c) {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "cannot change the password while impersonating a user"})
+		return
+	}
 
 	updatedUser, err := h.svc.UpdateUser(id, input)
 	if err != nil {
@@ -384,3 +545,39 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// Impersonate godoc
+// @Summary Impersonate a user
+// @Description Issues a short-lived token with the user's claims for debugging. Audit rows written with it record the admin as impersonator; credential, token and storage deletion endpoints reject it.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param userID path int true "User ID"
+// @Success 200 {object} application.Impersonation
+// @Failure 400 {object} response.ErrorResponse "Invalid user id"
+// @Failure 403 {object} response.ErrorResponse "Admin only"
+// @Failure 404 {object} response.ErrorResponse "User not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /admin/impersonate/{userID} [post]
+func (h *UserHandler) Impersonate(c *gin.Context) {
+	id, err := utils.ParseIDParam(c, "userID")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid user id"})
+		return
+	}
+
+	imp, err := h.svc.Impersonate(c, id)
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, application.ErrImpersonateSelf), errors.Is(err, application.ErrImpersonateDeleted):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
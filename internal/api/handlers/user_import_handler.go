@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/pkg/response"
+)
+
+type UserImportHandler struct {
+	svc *application.UserImportService
+}
+
+func NewUserImportHandler(svc *application.UserImportService) *UserImportHandler {
+	return &UserImportHandler{svc: svc}
+}
+
+// ImportUsers godoc
+// @Summary Import users from CSV
+// @Description Creates accounts from a CSV of username, email, full name, group name and role, either in that order or under a header row. Without mode=execute every row is only validated. Executed imports create the users with generated passwords, returned once in the report unless emailed, and add them to their group. Rows fail independently; the report lists the status of each.
+// @Tags admin
+// @Security BearerAuth
+// @Accept multipart/form-data,text/csv
+// @Produce json
+// @Param file formData file false "CSV file; the request body is read as CSV when absent"
+// @Param mode query string false "dry_run (default) or execute"
+// @Param email_passwords query bool false "Email the initial passwords to users with an email"
+// @Param init_hubs query bool false "Create the storage hubs of the created users in the background"
+// @Success 200 {object} response.SuccessResponse{data=application.UserImportReport}
+// @Failure 400 {object} response.ErrorResponse "Unreadable CSV"
+// @Failure 500 {object} response.ErrorResponse "Internal Server Error"
+// @Router /admin/users/import [post]
+func (h *UserImportHandler) ImportUsers(c *gin.Context) {
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "file is required"})
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "failed to read file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	mode := c.DefaultQuery("mode", "dry_run")
+	if mode != "dry_run" && mode != "execute" {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "mode must be dry_run or execute"})
+		return
+	}
+	rows, err := application.ParseUserImportCSV(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	report, err := h.svc.ImportUsers(c, rows, application.UserImportOptions{
+		Execute:        mode == "execute",
+		EmailPasswords: c.Query("email_passwords") == "true",
+		InitHubs:       c.Query("init_hubs") == "true",
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: report})
+}
+
+// GetImportHubProgress godoc
+// @Summary Storage hub creation of an import
+// @Description Reports the storage hubs created so far for the users of an import run with init_hubs.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "hub_import_id of the import report"
+// @Success 200 {object} response.SuccessResponse{data=application.UserHubImportProgress}
+// @Failure 404 {object} response.ErrorResponse "Unknown import"
+// @Router /admin/users/import/{id} [get]
+func (h *UserImportHandler) GetImportHubProgress(c *gin.Context) {
+	progress, err := h.svc.HubProgress(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, application.ErrUserImportNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, response.CodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{Code: 0, Message: "success", Data: progress})
+}
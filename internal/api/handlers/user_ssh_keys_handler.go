@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// GetMySSHKeys godoc
+// @Summary List the caller's SSH public keys
+// @Description The keys allowed to log in to the caller's SFTP server.
+// @Tags me
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} user.SSHKey
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "User not found"
+// @Router /me/ssh-keys [get]
+func (h *UserHandler) GetMySSHKeys(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	keys, err := h.svc.GetSSHKeys(uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// PutMySSHKeys godoc
+// @Summary Replace the caller's SSH public keys
+// @Description Stores OpenSSH public keys (authorized_keys lines without options) for the caller's SFTP server. DSA and RSA keys under 2048 bits are rejected. A running SFTP server uses the new keys once it is started again.
+// @Tags me
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body user.SSHKeysInput true "Public keys"
+// @Success 200 {array} user.SSHKey
+// @Failure 400 {object} response.ErrorResponse "Invalid key or too many keys"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "User not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /me/ssh-keys [put]
+func (h *UserHandler) PutMySSHKeys(c *gin.Context) {
+	uid, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	var input user.SSHKeysInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	keys, err := h.svc.SetSSHKeys(uid, input.Keys)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrInvalidSSHKey), errors.Is(err, application.ErrTooManySSHKeys):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, application.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/linskybing/platform-go/internal/api/middleware"
 	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/pkg/k8s"
 	"github.com/linskybing/platform-go/pkg/response"
+	"github.com/linskybing/platform-go/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -40,8 +44,14 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// ExecWebSocketHandler handles "kubectl exec" style terminal sessions
+// ExecWebSocketHandler handles "kubectl exec" style terminal sessions.
+// Clients connecting with ?share_token= attach to an existing session as read-only observers.
 func ExecWebSocketHandler(c *gin.Context) {
+	if token := c.Query("share_token"); token != "" {
+		observeTerminalSession(c, token)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "websocket upgrade failed: " + err.Error()})
@@ -54,6 +64,13 @@ func ExecWebSocketHandler(c *gin.Context) {
 		return
 	}
 
+	// Sessions are shareable only by the authenticated owner (or an admin)
+	var ownerID uint
+	if claims := optionalClaims(c); claims != nil {
+		ownerID = claims.UserID
+	}
+	session := k8s.NewTerminalSession(ownerID, c.Query("namespace"), c.Query("pod"), c.Query("container"))
+
 	// k8s.ExecToPodViaWebSocket typically manages its own stream copy loops
 	err = k8s.ExecToPodViaWebSocket(
 		conn,
@@ -64,6 +81,7 @@ func ExecWebSocketHandler(c *gin.Context) {
 		c.Query("container"),
 		[]string{c.DefaultQuery("command", "/bin/bash")},
 		c.DefaultQuery("tty", "true") == "true",
+		session,
 	)
 
 	if err != nil {
@@ -78,8 +96,94 @@ func ExecWebSocketHandler(c *gin.Context) {
 	}
 }
 
+// observeTerminalSession attaches the connection to a shared session; anything it sends is ignored.
+func observeTerminalSession(c *gin.Context, token string) {
+	session, err := k8s.SessionForShareToken(token)
+	if err != nil {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+
+	name := ""
+	if claims := optionalClaims(c); claims != nil {
+		name = claims.Username
+	}
+	done, err := session.AddObserver(conn, name)
+	if err != nil {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()), time.Now().Add(writeWait))
+		_ = conn.Close()
+		return
+	}
+	<-done
+}
+
+// ShareTerminalSessionHandler issues a short-lived token observers use to watch a terminal session
+// @Summary Share a terminal session read-only
+// @Tags k8s
+// @Produce json
+// @Param id path string true "Terminal session ID"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /k8s/terminal-sessions/{id}/share [post]
+func ShareTerminalSessionHandler(c *gin.Context) {
+	claims, _ := c.MustGet("claims").(*types.Claims)
+
+	session, err := k8s.GetTerminalSession(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if claims == nil || (!claims.IsAdmin && (session.OwnerID == 0 || session.OwnerID != claims.UserID)) {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "only the session owner can share this terminal"})
+		return
+	}
+
+	token, expiresAt, err := k8s.CreateShareToken(session.ID, config.DurationSetting(config.SettingTerminalShareTokenTTL))
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		Code:    0,
+		Message: "success",
+		Data: gin.H{
+			"token":      token,
+			"expires_at": expiresAt,
+			"url":        config.APIV1Prefix + "/ws/exec?share_token=" + token,
+		},
+	})
+}
+
+// optionalClaims parses the platform token if the client sent one; /ws/exec itself is not behind JWT auth.
+func optionalClaims(c *gin.Context) *types.Claims {
+	tokenStr := ""
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		tokenStr = strings.TrimPrefix(auth, "Bearer ")
+	} else if cookie, err := c.Cookie("token"); err == nil {
+		tokenStr = cookie
+	}
+	if tokenStr == "" {
+		return nil
+	}
+	claims, err := middleware.ParseToken(tokenStr)
+	if err != nil || (claims.ExpiresAt != nil && time.Now().After(claims.ExpiresAt.Time)) {
+		return nil
+	}
+	return claims
+}
+
 // WatchNamespaceHandler monitors resources for a specific namespace
 // Features: Heartbeat, Message Batching, Context Cancellation
+// With streamLogs=pod/container, or a {"type":"streamLogs","pod":...,"container":...} message,
+// the log lines of that container are sent as {"type":"LOG","pod":...,"line":...} messages;
+// one log stream per connection, an empty pod stops it.
 func WatchNamespaceHandler(c *gin.Context) {
 	namespace := c.Param("namespace")
 	if namespace == "" {
@@ -105,7 +209,8 @@ func WatchNamespaceHandler(c *gin.Context) {
 		return nil
 	})
 
-	writeChan := make(chan []byte, 200)
+	// Bounded: the watchers drop, count and resync instead of blocking on a slow client
+	writeChan := make(chan []byte, config.WatchBufferSize)
 
 	// Writer Goroutine: Handles Batching, Pings, and sending data to client
 	go func() {
@@ -178,17 +283,44 @@ func WatchNamespaceHandler(c *gin.Context) {
 	}()
 
 	// Start K8s Watcher
-	go k8s.WatchNamespaceResources(ctx, writeChan, namespace)
+	var viewerID uint
+	if claims, ok := c.Get("claims"); ok {
+		if cl, ok := claims.(*types.Claims); ok {
+			viewerID = cl.UserID
+		}
+	}
+	go k8s.WatchNamespaceResourcesFor(ctx, writeChan, namespace, viewerID)
+
+	// Optional log stream of one container, multiplexed as LOG messages
+	logs := newWatchLogStreamer(ctx, namespace, writeChan)
+	defer logs.Stop()
+	reportLogError := func(pod string, err error) {
+		msg, _ := json.Marshal(watchLogMessage{Type: "LOG", Pod: pod, Error: err.Error()})
+		select {
+		case writeChan <- msg:
+		default:
+		}
+	}
+	if target := c.Query("streamLogs"); target != "" {
+		pod, container := parseLogTarget(target)
+		if err := logs.Switch(pod, container); err != nil {
+			reportLogError(pod, err)
+		}
+	}
 
 	// Reader Loop (Blocking)
-	// Essential for processing Control Frames (Ping/Pong/Close)
+	// Essential for processing Control Frames (Ping/Pong/Close) and streamLogs commands
 	for {
-		if _, _, err := conn.ReadMessage(); err != nil {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		if err := logs.handleControl(data); err != nil {
+			reportLogError("", err)
+		}
 	}
 }
 
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// watchLogTailLines is how much history a log stream attached to a watch connection starts with.
+const watchLogTailLines = 100
+
+// logFollowFunc follows the log of one container, calling onLine per line until ctx is done.
+type logFollowFunc func(ctx context.Context, ns, pod, container string, tailLines int64, onLine func(string) error) error
+
+// watchLogMessage is one log line multiplexed into the namespace watch stream. A message with
+// Error set ends the stream of that pod.
+type watchLogMessage struct {
+	Type      string `json:"type"`
+	Pod       string `json:"pod"`
+	Container string `json:"container,omitempty"`
+	Line      string `json:"line,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// watchControlMessage is a command sent by the client over the watch connection.
+type watchControlMessage struct {
+	Type      string `json:"type"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+}
+
+// watchLogStreamer follows at most one container log per watch connection. Starting another
+// stream, or closing the connection, cancels the previous one and waits for it to end.
+type watchLogStreamer struct {
+	ctx       context.Context
+	namespace string
+	out       chan<- []byte
+	follow    logFollowFunc
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newWatchLogStreamer(ctx context.Context, namespace string, out chan<- []byte) *watchLogStreamer {
+	return &watchLogStreamer{ctx: ctx, namespace: namespace, out: out, follow: k8s.FollowPodLogs}
+}
+
+// parseLogTarget splits the pod/container value of the streamLogs query parameter.
+func parseLogTarget(value string) (pod, container string) {
+	pod, container, _ = strings.Cut(value, "/")
+	return pod, container
+}
+
+// Switch replaces the current log stream with one of pod and container; an empty pod only stops it.
+func (s *watchLogStreamer) Switch(pod, container string) error {
+	if pod != "" {
+		if errs := validation.IsDNS1123Subdomain(pod); len(errs) > 0 {
+			return fmt.Errorf("invalid pod name %q", pod)
+		}
+		if container != "" {
+			if errs := validation.IsDNS1123Label(container); len(errs) > 0 {
+				return fmt.Errorf("invalid container name %q", container)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+	if pod == "" || s.ctx.Err() != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	go func() {
+		defer close(done)
+		err := s.follow(ctx, s.namespace, pod, container, watchLogTailLines, func(line string) error {
+			return s.send(ctx, watchLogMessage{Type: "LOG", Pod: pod, Container: container, Line: line})
+		})
+		if err != nil && ctx.Err() == nil {
+			_ = s.send(ctx, watchLogMessage{Type: "LOG", Pod: pod, Container: container, Error: err.Error()})
+		}
+	}()
+	return nil
+}
+
+// Stop cancels the current log stream and waits for its goroutine to end.
+func (s *watchLogStreamer) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+}
+
+func (s *watchLogStreamer) stopLocked() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.cancel, s.done = nil, nil
+}
+
+// send waits for room in the write channel: unlike watch events, log lines cannot be recovered
+// by a resync, so they slow the follow down instead of being dropped.
+func (s *watchLogStreamer) send(ctx context.Context, msg watchLogMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case s.out <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleControl applies a control message read from the watch connection. Unknown types are ignored.
+func (s *watchLogStreamer) handleControl(data []byte) error {
+	var msg watchControlMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "streamLogs" {
+		return nil
+	}
+	return s.Switch(msg.Pod, msg.Container)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeLogFollow emits one line per followed pod and blocks until cancelled, counting the
+// goroutines that are following and those that were cancelled.
+type fakeLogFollow struct {
+	active    atomic.Int32
+	cancelled atomic.Int32
+}
+
+func (f *fakeLogFollow) follow(ctx context.Context, ns, pod, container string, tailLines int64, onLine func(string) error) error {
+	f.active.Add(1)
+	defer f.active.Add(-1)
+	if err := onLine("hello from " + pod); err != nil {
+		f.cancelled.Add(1)
+		return err
+	}
+	<-ctx.Done()
+	f.cancelled.Add(1)
+	return nil
+}
+
+func readLogMessage(t *testing.T, out <-chan []byte) watchLogMessage {
+	t.Helper()
+	select {
+	case data := <-out:
+		var msg watchLogMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("invalid message %s: %v", data, err)
+		}
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no log message received")
+	}
+	return watchLogMessage{}
+}
+
+func TestWatchLogStreamerSwitchCancelsPreviousStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan []byte, 8)
+	fake := &fakeLogFollow{}
+	s := newWatchLogStreamer(ctx, "proj-1", out)
+	s.follow = fake.follow
+
+	if err := s.Switch("train-0", "main"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if msg := readLogMessage(t, out); msg.Type != "LOG" || msg.Pod != "train-0" || msg.Container != "main" || msg.Line != "hello from train-0" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	if err := s.handleControl([]byte(`{"type":"streamLogs","pod":"train-1","container":"main"}`)); err != nil {
+		t.Fatalf("handleControl: %v", err)
+	}
+	if msg := readLogMessage(t, out); msg.Pod != "train-1" {
+		t.Fatalf("expected a line of train-1, got %+v", msg)
+	}
+	if got := fake.cancelled.Load(); got != 1 {
+		t.Fatalf("switching should cancel the first stream, %d cancelled", got)
+	}
+	if got := fake.active.Load(); got != 1 {
+		t.Fatalf("expected one active stream, got %d", got)
+	}
+
+	s.Stop()
+	if got := fake.cancelled.Load(); got != 2 || fake.active.Load() != 0 {
+		t.Fatalf("Stop should end the stream: %d cancelled, %d active", got, fake.active.Load())
+	}
+}
+
+func TestWatchLogStreamerStopsWithConnection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan []byte, 8)
+	fake := &fakeLogFollow{}
+	s := newWatchLogStreamer(ctx, "proj-1", out)
+	s.follow = fake.follow
+
+	if err := s.Switch("train-0", ""); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	readLogMessage(t, out)
+	cancel()
+	s.Stop()
+	if fake.active.Load() != 0 || fake.cancelled.Load() != 1 {
+		t.Fatalf("disconnect should cancel the stream: %d active, %d cancelled", fake.active.Load(), fake.cancelled.Load())
+	}
+
+	// A closed connection starts no new stream
+	if err := s.Switch("train-1", ""); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if fake.active.Load() != 0 {
+		t.Fatal("no stream should start after the connection closed")
+	}
+}
+
+func TestWatchLogStreamerRejectsInvalidTarget(t *testing.T) {
+	s := newWatchLogStreamer(context.Background(), "proj-1", make(chan []byte, 1))
+	s.follow = (&fakeLogFollow{}).follow
+	if err := s.Switch("../etc", ""); err == nil {
+		t.Fatal("expected an invalid pod name to be rejected")
+	}
+	if err := s.Switch("train-0", "Main_Container"); err == nil {
+		t.Fatal("expected an invalid container name to be rejected")
+	}
+	if pod, container := parseLogTarget("train-0/main"); pod != "train-0" || container != "main" {
+		t.Fatalf("parseLogTarget = %q, %q", pod, container)
+	}
+	if err := s.handleControl([]byte(`{"type":"other"}`)); err != nil {
+		t.Fatalf("unknown control messages should be ignored: %v", err)
+	}
+}
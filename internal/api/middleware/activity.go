@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/activity"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// activityEntityKey holds the ID of the entity a create handler made.
+const activityEntityKey = "activity_entity_id"
+
+// ActivityRecorder queues a use of an entity; it must not block.
+type ActivityRecorder func(userID uint, entityType string, entityID uint, action string)
+
+// SetActivityEntity tells TrackActivity which entity a create handler made.
+func SetActivityEntity(c *gin.Context, id uint) {
+	c.Set(activityEntityKey, id)
+}
+
+// TrackActivity records a successful request as an action of the caller on an entity. A view
+// takes the entity from the :id route param; a create takes the ID the handler passed to
+// SetActivityEntity. Failed requests and requests without an entity are not recorded.
+func TrackActivity(record ActivityRecorder, entityType, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if status := c.Writer.Status(); status < 200 || status >= 300 {
+			return
+		}
+		userID, err := utils.GetUserIDFromContext(c)
+		if err != nil {
+			return
+		}
+		var entityID uint
+		if action == activity.ActionView {
+			if entityID, err = utils.ParseIDParam(c, "id"); err != nil {
+				return
+			}
+		} else {
+			entityID = c.GetUint(activityEntityKey)
+		}
+		if entityID != 0 {
+			record(userID, entityType, entityID, action)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/activity"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+func TestTrackActivity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var recorded []string
+	record := func(userID uint, entityType string, entityID uint, action string) {
+		recorded = append(recorded, fmt.Sprintf("%d %s %s %d", userID, action, entityType, entityID))
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("claims", &types.Claims{UserID: 2})
+	})
+	r.GET("/projects/:id", TrackActivity(record, activity.EntityProject, activity.ActionView), func(c *gin.Context) {
+		if c.Param("id") == "9" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	r.POST("/projects", TrackActivity(record, activity.EntityProject, activity.ActionCreate), func(c *gin.Context) {
+		SetActivityEntity(c, 7)
+		c.Status(http.StatusCreated)
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/projects/3", nil),
+		httptest.NewRequest(http.MethodGet, "/projects/9", nil),
+		httptest.NewRequest(http.MethodPost, "/projects", nil),
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(recorded) != 2 || recorded[0] != "2 view project 3" || recorded[1] != "2 create project 7" {
+		t.Fatalf("expected the successful view and create only, got %v", recorded)
+	}
+}
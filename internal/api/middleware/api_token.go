@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// ProjectResolver returns the project a request acts on, used to confine project-scoped tokens.
+type ProjectResolver func(c *gin.Context, repos *repository.Repos) (uint, error)
+
+// tokenRoute is the scope an API token needs for a route and how to find the route's project.
+// A nil project resolver means the route is not tied to one project and rejects project-scoped tokens.
+type tokenRoute struct {
+	scope   string
+	project ProjectResolver
+}
+
+// apiTokenRoutes lists the routes reachable with an API token, keyed by method and route pattern.
+// Every other route, including token management, only accepts login sessions.
+var apiTokenRoutes = map[string]tokenRoute{
+	"POST /projects/:id/jobs":   {user.ScopeJobsWrite, projectFromIDParam},
+	"POST /k8s/jobs":            {user.ScopeJobsWrite, nil},
+	"POST /jobs":                {user.ScopeJobsWrite, projectFromJobPayload},
+	"GET /jobs":                 {user.ScopeJobsRead, nil},
+	"GET /jobs/:id":             {user.ScopeJobsRead, projectFromJobParam},
+	"GET /jobs/:id/logs":        {user.ScopeJobsRead, projectFromJobParam},
+	"GET /jobs/:id/checkpoints": {user.ScopeJobsRead, projectFromJobParam},
+	"DELETE /jobs/:id":          {user.ScopeJobsWrite, projectFromJobParam},
+	"POST /jobs/:id/restart":    {user.ScopeJobsWrite, projectFromJobParam},
+
+	"GET /projects/:id/config-files":  {user.ScopeConfigFilesRead, projectFromIDParam},
+	"GET /config-files/:id":           {user.ScopeConfigFilesRead, projectFromConfigFileParam},
+	"GET /config-files/:id/resources": {user.ScopeConfigFilesRead, projectFromConfigFileParam},
+	"POST /config-files":              {user.ScopeConfigFilesWrite, projectFromConfigFilePayload},
+	"PUT /config-files/:id":           {user.ScopeConfigFilesWrite, projectFromConfigFileParam},
+	"DELETE /config-files/:id":        {user.ScopeConfigFilesWrite, projectFromConfigFileParam},
+
+	"POST /instance/:id":   {user.ScopeInstancesWrite, projectFromConfigFileParam},
+	"DELETE /instance/:id": {user.ScopeInstancesWrite, projectFromConfigFileParam},
+
+	"DELETE /k8s/storage/projects/:id":                {user.ScopeStorageDelete, nil},
+	"DELETE /k8s/storage/projects/:id/storages/:name": {user.ScopeStorageDelete, nil},
+	"DELETE /k8s/users/:username/storage":             {user.ScopeStorageDelete, nil},
+}
+
+var (
+	errAPITokenInvalid = errors.New("invalid api token")
+	errAPITokenRevoked = errors.New("api token revoked")
+	errAPITokenExpired = errors.New("api token expired")
+)
+
+// Authenticate accepts either a login JWT or an API token ("Bearer pat_..."). API tokens are
+// resolved to claims carrying the token's scopes and may only reach the routes in apiTokenRoutes.
+func (a *Auth) Authenticate() gin.HandlerFunc {
+	jwtAuth := JWTAuthMiddleware()
+	return func(c *gin.Context) {
+		raw, ok := bearerAPIToken(c)
+		if !ok {
+			jwtAuth(c)
+			return
+		}
+
+		claims, err := a.authenticateAPIToken(raw, time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set("claims", claims)
+
+		rule, ok := apiTokenRoutes[c.Request.Method+" "+RoutePath(c)]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this endpoint does not accept api tokens"})
+			return
+		}
+		if !claims.HasScope(rule.scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api token lacks scope " + rule.scope})
+			return
+		}
+		if claims.ProjectID != nil {
+			if rule.project == nil {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "endpoint is not available to project-scoped tokens"})
+				return
+			}
+			pid, err := rule.project(c, a.repos)
+			if err != nil || pid != *claims.ProjectID {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api token is not valid for this project"})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// bearerAPIToken returns the API token from the Authorization header, if one is presented.
+func bearerAPIToken(c *gin.Context) (string, bool) {
+	raw, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(raw, user.APITokenPrefix) {
+		return "", false
+	}
+	return raw, true
+}
+
+func (a *Auth) authenticateAPIToken(raw string, now time.Time) (*types.Claims, error) {
+	t, err := a.repos.APIToken.GetByHash(user.HashAPIToken(raw))
+	if err != nil {
+		return nil, errAPITokenInvalid
+	}
+	if t.RevokedAt != nil {
+		return nil, errAPITokenRevoked
+	}
+	if !t.Active(now) {
+		return nil, errAPITokenExpired
+	}
+	u, err := a.repos.User.GetUserRawByID(t.UserID)
+	if err != nil || u.Status == string(user.UserStatusDelete) {
+		return nil, errAPITokenInvalid
+	}
+	if err := a.repos.APIToken.TouchLastUsed(t.ID, now); err != nil {
+		return nil, err
+	}
+
+	return &types.Claims{
+		UserID:    u.UID,
+		Username:  u.Username,
+		Scopes:    t.ScopeList(),
+		TokenID:   t.ID,
+		ProjectID: t.ProjectID,
+	}, nil
+}
+
+func projectFromIDParam(c *gin.Context, repos *repository.Repos) (uint, error) {
+	return utils.ParseIDParam(c, "id")
+}
+
+func projectFromConfigFileParam(c *gin.Context, repos *repository.Repos) (uint, error) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		return 0, err
+	}
+	cf, err := repos.ConfigFile.GetConfigFileByID(id)
+	if err != nil {
+		return 0, err
+	}
+	return cf.ProjectID, nil
+}
+
+func projectFromConfigFilePayload(c *gin.Context, repos *repository.Repos) (uint, error) {
+	return projectIDFromPayload(c, configfile.CreateConfigFileInput{})
+}
+
+// projectFromJobParam uses the job's project, falling back to its proj-<pid>-<user> namespace
+// for jobs recorded without one.
+func projectFromJobParam(c *gin.Context, repos *repository.Repos) (uint, error) {
+	id, err := utils.ParseIDParam(c, "id")
+	if err != nil {
+		return 0, err
+	}
+	j, err := repos.Job.FindByID(id)
+	if err != nil {
+		return 0, err
+	}
+	if j.ProjectID != nil {
+		return *j.ProjectID, nil
+	}
+	return projectFromNamespace(j.Namespace)
+}
+
+func projectFromJobPayload(c *gin.Context, repos *repository.Repos) (uint, error) {
+	bodyBytes, err := c.GetRawData()
+	if err != nil {
+		return 0, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	var payload struct {
+		Namespace string `json:"namespace"`
+	}
+	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
+		return 0, err
+	}
+	return projectFromNamespace(payload.Namespace)
+}
+
+func projectFromNamespace(ns string) (uint, error) {
+	pid, _, ok := k8s.ParseProjectNamespace(ns)
+	if !ok {
+		return 0, errors.New("namespace does not belong to a project")
+	}
+	return pid, nil
+}
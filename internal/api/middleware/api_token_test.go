@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+func setupTokenRouter(t *testing.T) (*gin.Engine, *repository.Repos) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := dbtest.Open(t, &user.User{}, &user.APIToken{}, &configfile.ConfigFile{})
+	if err := db.Exec("INSERT INTO users (u_id, username, password, type, status) VALUES (5, 'ci-user', 'x', 'origin', 'online')").Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	repos := repository.NewRepositories(db)
+	if err := repos.ConfigFile.CreateConfigFile(&configfile.ConfigFile{CFID: 11, Filename: "train.yaml", ProjectID: 3}); err != nil {
+		t.Fatalf("failed to seed config file: %v", err)
+	}
+
+	ok := func(c *gin.Context) {
+		claims := c.MustGet("claims").(*types.Claims)
+		c.JSON(http.StatusOK, gin.H{"user": claims.Username})
+	}
+	r := gin.New()
+	auth := r.Group("/")
+	auth.Use(NewAuth(repos).Authenticate())
+	auth.POST("/projects/:id/jobs", ok)
+	auth.GET("/config-files/:id", ok)
+	auth.GET("/api-tokens", ok)
+	return r, repos
+}
+
+func seedToken(t *testing.T, repos *repository.Repos, raw string, tok user.APIToken) {
+	t.Helper()
+	tok.UserID = 5
+	tok.Name = "ci"
+	tok.Prefix = raw[:8]
+	tok.TokenHash = user.HashAPIToken(raw)
+	if err := repos.APIToken.Create(&tok); err != nil {
+		t.Fatalf("failed to seed token: %v", err)
+	}
+}
+
+func doTokenRequest(r *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPITokenScopeEnforcement(t *testing.T) {
+	r, repos := setupTokenRouter(t)
+	seedToken(t, repos, "pat_readonly", user.APIToken{Scopes: user.ScopeConfigFilesRead})
+	seedToken(t, repos, "pat_submitter", user.APIToken{Scopes: user.ScopeJobsWrite})
+
+	cases := []struct {
+		name, method, path, token string
+		want                      int
+	}{
+		{"scope granted", http.MethodGet, "/config-files/11", "pat_readonly", http.StatusOK},
+		{"scope missing", http.MethodPost, "/projects/3/jobs", "pat_readonly", http.StatusForbidden},
+		{"job submission", http.MethodPost, "/projects/3/jobs", "pat_submitter", http.StatusOK},
+		{"route not open to tokens", http.MethodGet, "/api-tokens", "pat_submitter", http.StatusForbidden},
+		{"unknown token", http.MethodGet, "/config-files/11", "pat_unknown", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := doTokenRequest(r, tc.method, tc.path, tc.token); w.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.want, w.Body.String())
+			}
+		})
+	}
+
+	tok, err := repos.APIToken.GetByHash(user.HashAPIToken("pat_submitter"))
+	if err != nil {
+		t.Fatalf("GetByHash: %v", err)
+	}
+	if tok.LastUsedAt == nil {
+		t.Fatal("expected last used timestamp to be recorded")
+	}
+}
+
+func TestAPITokenRejectsRevokedAndExpired(t *testing.T) {
+	r, repos := setupTokenRouter(t)
+	past := time.Now().Add(-time.Hour)
+	seedToken(t, repos, "pat_revoked", user.APIToken{Scopes: user.ScopeJobsWrite, RevokedAt: &past})
+	seedToken(t, repos, "pat_expired", user.APIToken{Scopes: user.ScopeJobsWrite, ExpiresAt: &past})
+
+	for _, token := range []string{"pat_revoked", "pat_expired"} {
+		w := doTokenRequest(r, http.MethodPost, "/projects/3/jobs", token)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: status = %d, want 401", token, w.Code)
+		}
+	}
+}
+
+func TestProjectScopedTokenConfinedToProject(t *testing.T) {
+	r, repos := setupTokenRouter(t)
+	pid := uint(3)
+	seedToken(t, repos, "pat_project3", user.APIToken{Scopes: user.ScopeJobsWrite + "," + user.ScopeConfigFilesRead, ProjectID: &pid})
+
+	if w := doTokenRequest(r, http.MethodPost, "/projects/3/jobs", "pat_project3"); w.Code != http.StatusOK {
+		t.Fatalf("own project: status = %d", w.Code)
+	}
+	if w := doTokenRequest(r, http.MethodGet, "/config-files/11", "pat_project3"); w.Code != http.StatusOK {
+		t.Fatalf("config file of own project: status = %d", w.Code)
+	}
+	if w := doTokenRequest(r, http.MethodPost, "/projects/4/jobs", "pat_project3"); w.Code != http.StatusForbidden {
+		t.Fatalf("other project: status = %d, want 403", w.Code)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/linskybing/platform-go/internal/domain/project"
 	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
 	"github.com/linskybing/platform-go/pkg/response"
 	"github.com/linskybing/platform-go/pkg/types"
 	"github.com/linskybing/platform-go/pkg/utils"
@@ -75,38 +76,47 @@ func FromPayload(dtoType any) GIDExtractor {
 // FromProjectIDInPayload creates an extractor that gets GID from ProjectID in payload
 func FromProjectIDInPayload(dtoType any) GIDExtractor {
 	return func(c *gin.Context, repos *repository.Repos) (uint, error) {
-		// Dynamically create a new DTO instance
-		dtoValue := reflect.New(reflect.TypeOf(dtoType)).Interface()
-
-		// Read and preserve the raw body for downstream handlers.
-		bodyBytes, err := c.GetRawData()
+		projectID, err := projectIDFromPayload(c, dtoType)
 		if err != nil {
 			return 0, err
 		}
+		// Get GID from ProjectID
+		return repos.Project.GetGroupIDByProjectID(projectID)
+	}
+}
 
-		// Restore body for form data binding
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+// projectIDFromPayload binds the request into a new dtoType and returns its project ID. The body
+// is restored so the handler can bind it again.
+func projectIDFromPayload(c *gin.Context, dtoType any) (uint, error) {
+	// Dynamically create a new DTO instance
+	dtoValue := reflect.New(reflect.TypeOf(dtoType)).Interface()
 
-		// Bind form data directly
-		if err := c.ShouldBind(dtoValue); err != nil {
-			return 0, err
-		}
+	// Read and preserve the raw body for downstream handlers.
+	bodyBytes, err := c.GetRawData()
+	if err != nil {
+		return 0, err
+	}
 
-		// Restore body again so the handler can bind again.
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	// Restore body for form data binding
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-		// Check if DTO has GetProjectID method
-		type ProjectIDGetter interface {
-			GetProjectID() uint
-		}
+	// Bind form data directly
+	if err := c.ShouldBind(dtoValue); err != nil {
+		return 0, err
+	}
 
-		if getter, ok := dtoValue.(ProjectIDGetter); ok {
-			projectID := getter.GetProjectID()
-			// Get GID from ProjectID
-			return repos.Project.GetGroupIDByProjectID(projectID)
-		}
-		return 0, errors.New("DTO does not implement GetProjectID")
+	// Restore body again so the handler can bind again.
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	// Check if DTO has GetProjectID method
+	type ProjectIDGetter interface {
+		GetProjectID() uint
+	}
+
+	if getter, ok := dtoValue.(ProjectIDGetter); ok {
+		return getter.GetProjectID(), nil
 	}
+	return 0, errors.New("DTO does not implement GetProjectID")
 }
 
 // FromIDParam creates an extractor that gets group ID from URL parameter
@@ -132,6 +142,17 @@ func FromProjectIDInParam() GIDExtractor {
 	}
 }
 
+// FromProjectIDInQuery creates an extractor that gets GID from the project_id query parameter
+func FromProjectIDInQuery() GIDExtractor {
+	return func(c *gin.Context, repos *repository.Repos) (uint, error) {
+		projectID, err := strconv.ParseUint(c.Query("project_id"), 10, 32)
+		if err != nil {
+			return 0, errors.New("invalid project_id query parameter")
+		}
+		return repos.Project.GetGroupIDByProjectID(uint(projectID))
+	}
+}
+
 // --- Middleware Methods ---
 
 // Admin checks if user is a super admin
@@ -318,6 +339,60 @@ func (a *Auth) GroupAdmin(extractor GIDExtractor) gin.HandlerFunc {
 	}
 }
 
+// NamespaceAccess checks that the user may reach workloads in the namespace given by the URL parameter.
+// Super admins may access any namespace. Users may access their own proj-<pid>-<user> namespace while
+// they are members of the project's group, and group managers may access any member namespace. The
+// storage namespace of a project in shared namespace mode holds the workloads of every member, so
+// any member of the project's group may access it.
+func (a *Auth) NamespaceAccess(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.MustGet("claims").(*types.Claims)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+
+		isAdmin, err := utils.IsSuperAdmin(claims.UserID, a.repos.UserGroup)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		if isAdmin {
+			c.Next()
+			return
+		}
+
+		var permitted bool
+		ns := c.Param(param)
+		if pid, owner, ok := k8s.ParseProjectNamespace(ns); ok {
+			gid, err := a.repos.Project.GetGroupIDByProjectID(pid)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusForbidden, response.ErrorResponse{Error: "Permission denied for this namespace"})
+				return
+			}
+			if owner == k8s.ToSafeK8sName(claims.Username) {
+				permitted, err = utils.CheckGroupPermission(claims.UserID, gid, a.repos.UserGroup)
+			} else {
+				permitted, err = utils.CheckGroupManagePermission(claims.UserID, gid, a.repos.UserGroup)
+			}
+			if err != nil {
+				permitted = false
+			}
+		} else if p, err := a.repos.Project.GetProjectByStorageNamespace(ns); err == nil && p.SharesNamespace() {
+			permitted, err = utils.CheckGroupPermission(claims.UserID, p.GID, a.repos.UserGroup)
+			if err != nil {
+				permitted = false
+			}
+		}
+		if !permitted {
+			c.AbortWithStatusJSON(http.StatusForbidden, response.ErrorResponse{Error: "Permission denied for this namespace"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // AuthMiddleware validates request (placeholder for future auth)
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
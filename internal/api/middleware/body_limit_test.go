@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/pkg/types"
+	"gorm.io/gorm"
+)
+
+func setupBodyLimitRouter(t *testing.T, admin bool) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := dbtest.Open(t, &configfile.ConfigFile{})
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("claims", &types.Claims{UserID: 2, IsAdmin: admin})
+	})
+	r.POST("/config-files", BodyLimit(1024), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		db.Create(&configfile.ConfigFile{Filename: "a.yaml", Content: string(body), ProjectID: 1})
+		c.Status(http.StatusCreated)
+	})
+	return r, db
+}
+
+func configFileRows(db *gorm.DB) int64 {
+	var n int64
+	db.Model(&configfile.ConfigFile{}).Count(&n)
+	return n
+}
+
+func TestBodyLimitRejectsOversizedBody(t *testing.T) {
+	r, db := setupBodyLimitRouter(t, false)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config-files", strings.NewReader(strings.Repeat("a", 2048))))
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "1024 byte limit") {
+		t.Fatalf("expected 413 naming the limit, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Without a Content-Length the body is only caught while being read
+	req := httptest.NewRequest(http.MethodPost, "/config-files", io.MultiReader(strings.NewReader(strings.Repeat("a", 2048))))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a chunked body, got %d", w.Code)
+	}
+	if n := configFileRows(db); n != 0 {
+		t.Fatalf("the handler must not run for oversized bodies, found %d rows", n)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config-files", strings.NewReader("kind: Pod")))
+	if w.Code != http.StatusCreated || configFileRows(db) != 1 {
+		t.Fatalf("expected a small body to pass, got %d", w.Code)
+	}
+}
+
+func TestBodyLimitAdminExemption(t *testing.T) {
+	orig := config.BodyLimitExemptRoutes
+	t.Cleanup(func() { config.BodyLimitExemptRoutes = orig })
+	config.BodyLimitExemptRoutes = map[string]bool{"POST /config-files": true}
+	big := strings.Repeat("a", 2048)
+
+	r, db := setupBodyLimitRouter(t, false)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config-files", strings.NewReader(big)))
+	if w.Code != http.StatusRequestEntityTooLarge || configFileRows(db) != 0 {
+		t.Fatalf("the exemption must only apply to admins, got %d", w.Code)
+	}
+
+	r, db = setupBodyLimitRouter(t, true)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config-files", strings.NewReader(big)))
+	if w.Code != http.StatusCreated || configFileRows(db) != 1 {
+		t.Fatalf("expected an admin to bypass the limit on an exempt route, got %d", w.Code)
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+)
+
+// Deprecated marks the responses of the unversioned route aliases as deprecated, pointing
+// clients to the same path under config.APIV1Prefix and announcing the removal date.
+func Deprecated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		if config.LegacyRoutesSunset != "" {
+			c.Header("Sunset", config.LegacyRoutesSunset)
+		}
+		c.Header("Link", "<"+config.APIV1Prefix+c.Request.URL.Path+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
+
+// RoutePath returns the route pattern of the request without the version prefix, so route
+// tables keyed by pattern match both the versioned routes and their legacy aliases.
+func RoutePath(c *gin.Context) string {
+	return strings.TrimPrefix(c.FullPath(), config.APIV1Prefix)
+}
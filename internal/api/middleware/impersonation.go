@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+// NoImpersonation rejects impersonated sessions. It guards actions an admin acting as another
+// user must not take on their behalf: changing credentials, managing tokens and deleting storage.
+func NoImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsImpersonated(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating a user"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsImpersonated reports whether the request was authenticated with an impersonation token.
+func IsImpersonated(c *gin.Context) bool {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return false
+	}
+	cl, ok := claims.(*types.Claims)
+	return ok && cl.IsImpersonated()
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/audit"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/internal/repository/mock"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+func setupImpersonationRouter(t *testing.T) (*gin.Engine, *gorm.DB, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := dbtest.Open(t, &audit.AuditLog{})
+	repos := repository.NewRepositories(db)
+	userGroups := mock.NewMockUserGroupRepo(gomock.NewController(t))
+	userGroups.EXPECT().IsSuperAdmin(gomock.Any()).Return(false, nil).AnyTimes()
+
+	origKey := jwtKey
+	t.Cleanup(func() { jwtKey = origKey })
+	jwtKey = []byte("test-secret")
+	token, claims, err := GenerateImpersonationToken(5, "alice", 1, time.Minute, userGroups)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if !claims.Impersonated || claims.ImpersonatorID != 1 || claims.UserID != 5 {
+		t.Fatalf("unexpected impersonation claims %+v", claims)
+	}
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r := gin.New()
+	auth := r.Group("/")
+	auth.Use(NewAuth(repos).Authenticate())
+	auth.PUT("/config-files/:id", func(c *gin.Context) {
+		utils.LogAuditWithConsole(c, "update", "config_file", "cf_id=3", nil, nil, "", repos.Audit)
+		c.Status(http.StatusOK)
+	})
+	auth.GET("/api-tokens", NoImpersonation(), ok)
+	auth.DELETE("/k8s/users/:username/storage", NoImpersonation(), ok)
+	return r, db, token
+}
+
+func TestImpersonatedSessionRecordsImpersonatorOnAuditRows(t *testing.T) {
+	r, db, token := setupImpersonationRouter(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/config-files/3", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The audit row is written in the background
+	var row audit.AuditLog
+	deadline := time.Now().Add(2 * time.Second)
+	for db.First(&row).Error != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("audit row was not written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if row.UserID != 5 {
+		t.Fatalf("expected the row to be attributed to the impersonated user, got %d", row.UserID)
+	}
+	if row.ImpersonatorID == nil || *row.ImpersonatorID != 1 {
+		t.Fatalf("expected impersonator 1 on the audit row, got %v", row.ImpersonatorID)
+	}
+}
+
+func TestImpersonatedSessionRejectedBySensitiveEndpoints(t *testing.T) {
+	r, _, token := setupImpersonationRouter(t)
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/api-tokens"},
+		{http.MethodDelete, "/k8s/users/alice/storage"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: expected 403, got %d", tc.method, tc.path, w.Code)
+		}
+	}
+}
@@ -27,18 +27,13 @@ var GenerateToken = func(userID uint, username string, expireDuration time.Durat
 		return "", false, err
 	}
 	claims := &types.Claims{
-		UserID:   userID,
-		Username: username,
-		IsAdmin:  isAdmin,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    config.Issuer,
-		},
+		UserID:           userID,
+		Username:         username,
+		IsAdmin:          isAdmin,
+		RegisteredClaims: registeredClaims(expireDuration),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(jwtKey)
+	signedToken, err := signClaims(claims)
 	if err != nil {
 		return "", false, err
 	}
@@ -46,6 +41,43 @@ var GenerateToken = func(userID uint, username string, expireDuration time.Durat
 	return signedToken, isAdmin, nil
 }
 
+// GenerateImpersonationToken issues a token carrying the target user's claims for impersonatorID.
+// The claims mark the session as impersonated so audit rows record the admin and clients can
+// show a banner.
+var GenerateImpersonationToken = func(userID uint, username string, impersonatorID uint, expireDuration time.Duration, repos repository.UserGroupRepo) (string, *types.Claims, error) {
+	isAdmin, err := utils.IsSuperAdmin(userID, repos)
+	if err != nil {
+		return "", nil, err
+	}
+	claims := &types.Claims{
+		UserID:           userID,
+		Username:         username,
+		IsAdmin:          isAdmin,
+		ImpersonatorID:   impersonatorID,
+		Impersonated:     true,
+		RegisteredClaims: registeredClaims(expireDuration),
+	}
+
+	signedToken, err := signClaims(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signedToken, claims, nil
+}
+
+func registeredClaims(expireDuration time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(expireDuration)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Issuer:    config.Issuer,
+	}
+}
+
+func signClaims(claims *types.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+}
+
 // ParseToken validates and extracts claims.
 func ParseToken(tokenStr string) (*types.Claims, error) {
 	claims := &types.Claims{}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/maintenance"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+// MaintenanceOverrideParam is the query flag admins set to submit work during maintenance.
+const MaintenanceOverrideParam = "override_maintenance"
+
+// MaintenanceGate refuses new submissions with 503 while maintenance is in effect. status is
+// the cached maintenance state. Admins get through only by setting ?override_maintenance=true.
+func MaintenanceGate(status func() maintenance.StatusDTO) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := status()
+		if !st.Maintenance {
+			c.Next()
+			return
+		}
+		if maintenanceOverride(c) {
+			log.Printf("[MAINTENANCE] admin override for %s %s", c.Request.Method, c.Request.URL.Path)
+			c.Next()
+			return
+		}
+		if st.EndsAt != nil {
+			if wait := time.Until(*st.EndsAt); wait > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": st.Message, "maintenance": st})
+	}
+}
+
+func maintenanceOverride(c *gin.Context) bool {
+	if override, _ := strconv.ParseBool(c.Query(MaintenanceOverrideParam)); !override {
+		return false
+	}
+	claims, ok := c.Get("claims")
+	if !ok {
+		return false
+	}
+	cl, ok := claims.(*types.Claims)
+	return ok && cl.IsAdmin
+}
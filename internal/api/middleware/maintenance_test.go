@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/maintenance"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+func setupMaintenanceRouter(st *maintenance.StatusDTO, admin bool) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	submitted := 0
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("claims", &types.Claims{UserID: 2, IsAdmin: admin})
+	})
+	r.POST("/k8s/jobs", MaintenanceGate(func() maintenance.StatusDTO { return *st }), func(c *gin.Context) {
+		submitted++
+		c.Status(http.StatusCreated)
+	})
+	return r, &submitted
+}
+
+func TestMaintenanceGateBlocksSubmissions(t *testing.T) {
+	ends := time.Now().Add(time.Hour)
+	st := &maintenance.StatusDTO{Maintenance: true, Message: "Cluster upgrade until 18:00", EndsAt: &ends}
+	r, submitted := setupMaintenanceRouter(st, false)
+
+	for _, path := range []string{"/k8s/jobs", "/k8s/jobs?override_maintenance=true"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "Cluster upgrade until 18:00") {
+			t.Fatalf("%s: expected 503 with the maintenance message, got %d %s", path, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Fatalf("%s: expected a Retry-After header for a scheduled end", path)
+		}
+	}
+	if *submitted != 0 {
+		t.Fatalf("no submission may reach the handler, got %d", *submitted)
+	}
+
+	st.Maintenance = false
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/k8s/jobs", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected submissions to pass outside maintenance, got %d", w.Code)
+	}
+}
+
+func TestMaintenanceGateAdminOverride(t *testing.T) {
+	st := &maintenance.StatusDTO{Maintenance: true, Message: "upgrade"}
+	r, submitted := setupMaintenanceRouter(st, true)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/k8s/jobs", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("admins must opt in explicitly, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/k8s/jobs?override_maintenance=true", nil))
+	if w.Code != http.StatusCreated || *submitted != 1 {
+		t.Fatalf("expected the admin override to submit, got %d", w.Code)
+	}
+}
@@ -3,17 +3,19 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/linskybing/platform-go/internal/api/handlers"
+	"github.com/linskybing/platform-go/internal/domain/activity"
 )
 
-// JobRoutes registers job endpoints
-func JobRoutes(rg *gin.RouterGroup, h *handlers.JobHandler) {
+// JobRoutes registers job endpoints. submitGate guards the endpoints that start new work and
+// track records the actions shown in the recent items of the dashboard.
+func JobRoutes(rg *gin.RouterGroup, h *handlers.JobHandler, submitGate gin.HandlerFunc, track func(entityType, action string) gin.HandlerFunc) {
 	jobs := rg.Group("/jobs")
 	{
-		jobs.POST("", h.CreateJob)
+		jobs.POST("", submitGate, track(activity.EntityJob, activity.ActionCreate), h.CreateJob)
 		jobs.GET("", h.ListJobs)
-		jobs.GET("/:id", h.GetJob)
+		jobs.GET("/:id", track(activity.EntityJob, activity.ActionView), h.GetJob)
 		jobs.DELETE("/:id", h.CancelJob)
-		jobs.POST("/:id/restart", h.RestartJob)
+		jobs.POST("/:id/restart", submitGate, h.RestartJob)
 		jobs.GET("/:id/logs", h.GetJobLogs)
 		jobs.GET("/:id/checkpoints", h.GetJobCheckpoints)
 	}
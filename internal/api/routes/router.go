@@ -5,34 +5,75 @@ import (
 	"github.com/linskybing/platform-go/internal/api/handlers"
 	"github.com/linskybing/platform-go/internal/api/middleware"
 	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/cron"
+	"github.com/linskybing/platform-go/internal/domain/activity"
 	"github.com/linskybing/platform-go/internal/domain/configfile"
 	"github.com/linskybing/platform-go/internal/domain/group"
 	"github.com/linskybing/platform-go/internal/repository"
 	"gorm.io/gorm"
 )
 
+// RegisterRoutes builds the route table once and mounts it under config.APIV1Prefix and, while
+// legacy routes are enabled, at the root as deprecated aliases.
 func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
-	// --- JWT-protected routes ---
-	// Token status check endpoint (no group, but with JWT middleware)
-	r.GET("/auth/status", middleware.JWTAuthMiddleware(), handlers.AuthStatusHandler)
 	// init
 	repos_instance := repository.NewRepositories(db)
 	services_instance := application.New(repos_instance)
 	handlers_instance := handlers.New(services_instance, repos_instance, r)
 	authMiddleware := middleware.NewAuth(repos_instance)
 
-	// Start background tasks
+	// Start background tasks; settings load first so the others start with the stored values
+	cron.StartSettingsRefresh(services_instance.Settings)
 	cron.StartCleanupTask(services_instance.Audit)
+	cron.StartUserHubBindingSweep(services_instance.UserGroup)
+	cron.StartConfigFileTrashPurge(services_instance.ConfigFile)
+	cron.StartInstanceApprovalExpiry(services_instance.ConfigFile)
+	cron.StartImageUsageScan(services_instance.Image)
+	cron.StartImagePullJanitor(services_instance.Image)
+	cron.StartUsageSampler(services_instance.Project)
+	cron.StartApprovalDigest(services_instance.Approvals)
+
+	MountVersioned(r, func(r *gin.RouterGroup) {
+		registerAPI(r, repos_instance, services_instance, handlers_instance, authMiddleware)
+	})
+}
+
+// MountVersioned mounts the routes added by register under config.APIV1Prefix and, unless
+// config.LegacyRoutesEnabled is off, again at the root with the Deprecated headers. Both trees
+// get the same handlers and per-route middleware; engine-level middleware applies to both.
+func MountVersioned(r *gin.Engine, register func(r *gin.RouterGroup)) {
+	register(r.Group(config.APIV1Prefix))
+	if config.LegacyRoutesEnabled {
+		register(r.Group("", middleware.Deprecated()))
+	}
+}
+
+func registerAPI(r *gin.RouterGroup, repos_instance *repository.Repos, services_instance *application.Services, handlers_instance *handlers.Handlers, authMiddleware *middleware.Auth) {
+	// --- JWT-protected routes ---
+	// Token status check endpoint (no group, but with JWT middleware)
+	r.GET("/auth/status", middleware.JWTAuthMiddleware(), handlers.AuthStatusHandler)
 
 	// setup
-	r.POST("/register", handlers_instance.User.Register)
-	r.POST("/login", handlers_instance.User.Login)
-	r.POST("/logout", handlers_instance.User.Logout)
-	r.POST("/forgot-password", handlers_instance.User.ForgotPassword)
+	smallBody := middleware.BodyLimit(config.BodyLimitSmall)
+	mediumBody := middleware.BodyLimit(config.BodyLimitMedium)
+	r.POST("/register", smallBody, handlers_instance.User.Register)
+	r.POST("/login", smallBody, handlers_instance.User.Login)
+	r.GET("/auth/oidc/login", handlers_instance.User.OIDCLogin)
+	r.GET("/auth/oidc/callback", handlers_instance.User.OIDCCallback)
+	r.POST("/logout", smallBody, handlers_instance.User.Logout)
+	r.POST("/forgot-password", smallBody, handlers_instance.User.ForgotPassword)
 	r.GET("/ws/exec", handlers.ExecWebSocketHandler)
+	r.GET("/status", handlers_instance.Maintenance.GetStatus)
+	// Jobs report their own progress with the token in their environment, not a login
+	r.POST("/k8s/jobs/:id/progress", smallBody, handlers_instance.JobProgress.ReportProgress)
+	maintenanceGate := middleware.MaintenanceGate(services_instance.Maintenance.Status)
+	track := func(entityType, action string) gin.HandlerFunc {
+		return middleware.TrackActivity(services_instance.Activity.Record, entityType, action)
+	}
 	auth := r.Group("/")
-	auth.Use(middleware.JWTAuthMiddleware())
+	// Accepts login JWTs and scoped API tokens
+	auth.Use(authMiddleware.Authenticate())
 	{
 		websockets := auth.Group("/ws")
 		{
@@ -56,14 +97,18 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 			imageReq.GET("", authMiddleware.Admin(), handlers_instance.Image.ListRequests)
 			imageReq.PUT("/:id/approve", authMiddleware.Admin(), handlers_instance.Image.ApproveRequest)
 			imageReq.PUT("/:id/reject", authMiddleware.Admin(), handlers_instance.Image.RejectRequest)
+			imageReq.POST("/:id/verify", authMiddleware.Admin(), handlers_instance.Image.VerifyRequest)
 		}
 
 		images := auth.Group("/images")
 		{
 			images.GET("/allowed", handlers_instance.Image.ListAllowed)
+			images.GET("/usage", authMiddleware.Admin(), handlers_instance.Image.ListImageUsage)
 			images.GET("/pull-active", authMiddleware.Admin(), handlers_instance.Image.GetActivePullJobs)
 			images.GET("/pull-failed", authMiddleware.Admin(), handlers_instance.Image.GetFailedPullJobs)
 			images.POST("/pull", authMiddleware.Admin(), handlers_instance.Image.PullImage)
+			images.DELETE("/pulls/:job_id", handlers_instance.Image.CancelPullJob)
+			images.POST("/pulls/janitor", authMiddleware.Admin(), handlers_instance.Image.SweepPullJobs)
 			images.DELETE("/allowed/:id", authMiddleware.Admin(), handlers_instance.Image.DeleteAllowedImage)
 		}
 
@@ -82,10 +127,13 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		{
 			projects.GET("", handlers_instance.Project.GetProjects)
 			projects.GET("/by-user", handlers_instance.Project.GetProjectsByUser)
-			projects.GET("/:id", handlers_instance.Project.GetProjectByID)
+			projects.GET("/:id", track(activity.EntityProject, activity.ActionView), handlers_instance.Project.GetProjectByID)
 			projects.GET("/:id/config-files", handlers_instance.ConfigFile.ListConfigFilesByProjectIDHandler)
+			// Copy the config files of a project into another, e.g. a new semester's project
+			projects.GET("/:id/config-files/export", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.ExportConfigFilesHandler)
+			projects.POST("/:id/config-files/import", mediumBody, authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.ImportConfigFilesHandler)
 			projects.GET("/:id/resources", handlers_instance.Resource.ListResourcesByProjectID)
-			projects.POST("", authMiddleware.Admin(), handlers_instance.Project.CreateProject)
+			projects.POST("", authMiddleware.Admin(), track(activity.EntityProject, activity.ActionCreate), handlers_instance.Project.CreateProject)
 			projects.PUT("/:id", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.UpdateProject)
 			projects.DELETE("/:id", authMiddleware.GroupAdmin(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.DeleteProject)
 
@@ -96,32 +144,129 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 			// Project-scoped image requests (list requests for a specific project)
 			projects.GET("/:id/image-requests", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Image.ListRequestsByProject)
 
+			// Project-level env defaults injected into instances and jobs
+			projects.GET("/:id/env", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.ListEnvDefaults)
+			projects.PUT("/:id/env", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.SetEnvDefault)
+			projects.DELETE("/:id/env/:key", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.DeleteEnvDefault)
+
+			// Downsampled resource usage for the project dashboard
+			projects.GET("/:id/usage/history", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.GetUsageHistory)
+
+			// MPS settings a shared-GPU pod of the project receives
+			projects.GET("/:id/gpu-config", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.GetGPUConfig)
+
+			// Project credentials for private upstream registries, turned into image pull secrets
+			projects.GET("/:id/registry-credentials", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.ListRegistryCredentials)
+			projects.PUT("/:id/registry-credentials", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.SetRegistryCredential)
+			projects.DELETE("/:id/registry-credentials/:cred_id", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.DeleteRegistryCredential)
+			projects.POST("/:id/registry-credentials/:cred_id/validate", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.ValidateRegistryCredential)
+
+			// Project scheduling policy (tolerations, node selector, affinity), managed by admins
+			projects.GET("/:id/scheduling", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.GetSchedulingPolicy)
+			projects.PUT("/:id/scheduling", authMiddleware.Admin(), handlers_instance.Project.SetSchedulingPolicy)
+			projects.DELETE("/:id/scheduling", authMiddleware.Admin(), handlers_instance.Project.DeleteSchedulingPolicy)
+			// Concurrent job limits, independent of the GPU quota
+			projects.PUT("/:id/job-limits", authMiddleware.Admin(), handlers_instance.Project.SetJobLimits)
+			projects.PUT("/:id/chargeback", authMiddleware.Admin(), handlers_instance.Project.SetChargeback)
+			// Host paths exempt from the pod security check of the project's config files
+			projects.PUT("/:id/host-path-exceptions", authMiddleware.Admin(), handlers_instance.Project.SetHostPathExceptions)
+			// CPU/memory defaults of instance containers; managers may turn them off for their project
+			projects.PUT("/:id/resource-defaults", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.Project.SetResourceDefaults)
+
 			// Project-scoped Job creation: allow project members to submit jobs for this project
-			projects.POST("/:id/jobs", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.K8s.CreateJob)
+			projects.POST("/:id/jobs", mediumBody, maintenanceGate, authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.K8s.CreateJob)
+
+			// Instances held for review in projects requiring approval
+			projects.GET("/:id/instance-approvals", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.ListInstanceApprovalsHandler)
+			projects.POST("/:id/instance-approvals/:request_id/approve", maintenanceGate, authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.ApproveInstanceHandler)
+			projects.POST("/:id/instance-approvals/:request_id/reject", smallBody, authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.RejectInstanceHandler)
+
+			// Copy a catalog template into the project as a config file
+			projects.POST("/:id/config-templates/:template_id", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.InstantiateTemplateHandler)
+
+			// Bootstrap bundles applied to the member namespaces of the project
+			projects.GET("/:id/bootstrap-bundles", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.ListProjectBootstrapBundlesHandler)
+			projects.PUT("/:id/bootstrap-bundles/:bundle_id", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.AddProjectBootstrapBundleHandler)
+			projects.DELETE("/:id/bootstrap-bundles/:bundle_id", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.ConfigFile.RemoveProjectBootstrapBundleHandler)
+		}
+
+		// Recent and starred items of the caller for the dashboard
+		me := auth.Group("/me", smallBody)
+		{
+			me.GET("/recent", handlers_instance.Activity.GetRecent)
+			me.GET("/favorites", handlers_instance.Activity.GetFavorites)
+			me.POST("/favorites", handlers_instance.Activity.AddFavorite)
+			me.DELETE("/favorites", handlers_instance.Activity.RemoveFavorite)
+			me.GET("/ssh-keys", handlers_instance.User.GetMySSHKeys)
+			me.PUT("/ssh-keys", middleware.NoImpersonation(), handlers_instance.User.PutMySSHKeys)
+			me.POST("/oidc/link", middleware.NoImpersonation(), handlers_instance.User.StartOIDCLink)
+		}
+
+		// Search across the caller's projects, config files, jobs and allowed images
+		auth.GET("/search", handlers_instance.Search.Search)
+
+		// API tokens for automation; managed with a login session only
+		apiTokens := auth.Group("/api-tokens", middleware.NoImpersonation(), smallBody)
+		{
+			apiTokens.GET("", handlers_instance.APIToken.ListAPITokens)
+			apiTokens.POST("", handlers_instance.APIToken.CreateAPIToken)
+			apiTokens.DELETE("/:id", handlers_instance.APIToken.RevokeAPIToken)
+		}
+
+		admin := auth.Group("/admin")
+		{
+			admin.POST("/impersonate/:userID", middleware.NoImpersonation(), authMiddleware.Admin(), handlers_instance.User.Impersonate)
+			admin.GET("/maintenance", authMiddleware.Admin(), handlers_instance.Maintenance.GetMaintenance)
+			admin.POST("/maintenance", smallBody, authMiddleware.Admin(), handlers_instance.Maintenance.SetMaintenance)
+			admin.GET("/settings", authMiddleware.Admin(), handlers_instance.Settings.GetSettings)
+			admin.PUT("/settings", smallBody, authMiddleware.Admin(), handlers_instance.Settings.UpdateSettings)
+			admin.GET("/preflight", authMiddleware.Admin(), handlers_instance.K8s.GetPreflight)
+			admin.POST("/users/import", mediumBody, authMiddleware.Admin(), handlers_instance.UserImport.ImportUsers)
+			admin.GET("/users/import/:id", authMiddleware.Admin(), handlers_instance.UserImport.GetImportHubProgress)
 		}
 
 		audit := auth.Group("/audit/logs")
 		{
 			audit.GET("", handlers_instance.Audit.GetAuditLogs)
 		}
+		auth.GET("/audit/retention", authMiddleware.Admin(), handlers_instance.Audit.PreviewRetention)
 
 		// Job management
-		JobRoutes(auth, handlers_instance.Job)
+		JobRoutes(auth, handlers_instance.Job, maintenanceGate, track)
 		instances := auth.Group("/instance")
 		{
-			instances.POST("/:id", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.ConfigFile.CreateInstanceHandler)
+			instances.POST("/:id", maintenanceGate, authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.ConfigFile.CreateInstanceHandler)
 			instances.DELETE("/:id", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.ConfigFile.DestructInstanceHandler)
 		}
-		configFiles := auth.Group("/config-files")
+		configFiles := auth.Group("/config-files", mediumBody)
 		{
 			configFiles.GET("", authMiddleware.Admin(), handlers_instance.ConfigFile.ListConfigFilesHandler)
-			configFiles.GET("/:id", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.ConfigFile.GetConfigFileHandler)
+			configFiles.GET("/trash", authMiddleware.GroupManager(middleware.FromProjectIDInQuery()), handlers_instance.ConfigFile.ListTrashedConfigFilesHandler)
+			configFiles.POST("/:id/restore", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.ConfigFile.RestoreConfigFileHandler)
+			configFiles.GET("/:id", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), track(activity.EntityConfigFile, activity.ActionView), handlers_instance.ConfigFile.GetConfigFileHandler)
 			configFiles.GET("/:id/resources", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.Resource.ListResourcesByConfigFileID)
-			configFiles.POST("", authMiddleware.GroupManager(middleware.FromProjectIDInPayload(configfile.CreateConfigFileInput{})), handlers_instance.ConfigFile.CreateConfigFileHandler)
+			configFiles.GET("/:id/rendered", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.ConfigFile.RenderInstanceHandler)
+			configFiles.POST("", authMiddleware.GroupManager(middleware.FromProjectIDInPayload(configfile.CreateConfigFileInput{})), track(activity.EntityConfigFile, activity.ActionCreate), handlers_instance.ConfigFile.CreateConfigFileHandler)
 			configFiles.PUT("/:id", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.ConfigFile.UpdateConfigFileHandler)
 			configFiles.DELETE("/:id", authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.ConfigFile.GetGroupIDByConfigFileID)), handlers_instance.ConfigFile.DeleteConfigFileHandler)
 		}
-		users := auth.Group("/users")
+		configTemplates := auth.Group("/config-templates", mediumBody)
+		{
+			configTemplates.GET("", handlers_instance.ConfigFile.ListTemplatesHandler)
+			configTemplates.GET("/:id", handlers_instance.ConfigFile.GetTemplateHandler)
+			configTemplates.POST("", authMiddleware.Admin(), handlers_instance.ConfigFile.CreateTemplateHandler)
+			configTemplates.PUT("/:id", authMiddleware.Admin(), handlers_instance.ConfigFile.UpdateTemplateHandler)
+			configTemplates.DELETE("/:id", authMiddleware.Admin(), handlers_instance.ConfigFile.DeleteTemplateHandler)
+		}
+		bootstrapBundles := auth.Group("/bootstrap-bundles", mediumBody, authMiddleware.Admin())
+		{
+			bootstrapBundles.GET("", handlers_instance.ConfigFile.ListBootstrapBundlesHandler)
+			bootstrapBundles.GET("/:id", handlers_instance.ConfigFile.GetBootstrapBundleHandler)
+			bootstrapBundles.POST("", handlers_instance.ConfigFile.CreateBootstrapBundleHandler)
+			bootstrapBundles.PUT("/:id", handlers_instance.ConfigFile.UpdateBootstrapBundleHandler)
+			bootstrapBundles.DELETE("/:id", handlers_instance.ConfigFile.DeleteBootstrapBundleHandler)
+		}
+		users := auth.Group("/users", smallBody)
 		{
 			users.GET("", handlers_instance.User.GetUsers)
 			users.GET("/paging", handlers_instance.User.ListUsersPaging)
@@ -133,20 +278,51 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		{
 			groups.GET("", handlers_instance.Group.GetGroups)
 			groups.GET("/:id", handlers_instance.Group.GetGroupByID)
+			groups.GET("/:id/dashboard", authMiddleware.GroupManager(middleware.FromIDParam(func(gid uint) (uint, error) { return gid, nil })), handlers_instance.Group.GetGroupDashboard)
 			groups.POST("", authMiddleware.Admin(), handlers_instance.Group.CreateGroup)
 			groups.PUT("/:id", authMiddleware.Admin(), handlers_instance.Group.UpdateGroup)
 			groups.DELETE("/:id", authMiddleware.Admin(), handlers_instance.Group.DeleteGroup)
 		}
 		k8s := auth.Group("/k8s")
 		{
-			Jobs := k8s.Group("/jobs")
+			Jobs := k8s.Group("/jobs", mediumBody)
 			{
-				Jobs.POST("", authMiddleware.Admin(), handlers_instance.K8s.CreateJob)
+				Jobs.POST("", authMiddleware.Admin(), maintenanceGate, handlers_instance.K8s.CreateJob)
 				Jobs.GET("", handlers_instance.K8s.ListJobs)
-				Jobs.GET("/:id", handlers_instance.K8s.GetJob)
+				Jobs.GET("/:id", track(activity.EntityJob, activity.ActionView), handlers_instance.K8s.GetJob)
+				Jobs.GET("/:id/artifacts", handlers_instance.K8s.ListJobArtifacts)
+				Jobs.GET("/:id/timeline", handlers_instance.K8s.GetJobTimeline)
+				Jobs.GET("/:id/events", handlers_instance.JobProgress.StreamJobEvents)
 			}
+			jobTemplates := k8s.Group("/job-templates", mediumBody)
+			{
+				jobTemplates.GET("", handlers_instance.K8s.ListJobTemplates)
+				jobTemplates.POST("", handlers_instance.K8s.CreateJobTemplate)
+				jobTemplates.GET("/:id", handlers_instance.K8s.GetJobTemplate)
+				jobTemplates.PUT("/:id", handlers_instance.K8s.UpdateJobTemplate)
+				jobTemplates.DELETE("/:id", handlers_instance.K8s.DeleteJobTemplate)
+			}
+			k8s.GET("/priority-classes", handlers_instance.K8s.ListPriorityClasses)
+			k8s.POST("/priority-classes/reconcile", authMiddleware.Admin(), handlers_instance.K8s.ReconcilePriorityClasses)
+			k8s.GET("/storage-classes", handlers_instance.K8s.ListStorageClasses)
+			k8s.POST("/namespaces/backfill-labels", authMiddleware.Admin(), handlers_instance.K8s.BackfillNamespaceLabels)
+			k8s.POST("/labels/backfill", authMiddleware.Admin(), handlers_instance.K8s.BackfillOwnershipLabels)
+			// Capacity planning: queue wait times and GPU-hours over time
+			k8s.GET("/admin/stats/queue", authMiddleware.Admin(), handlers_instance.K8s.GetQueueStats)
+			// Every pod the platform runs, for the admin console
+			k8s.GET("/admin/pods", authMiddleware.Admin(), handlers_instance.K8s.ListPlatformPods)
 			// Pod logs
 			k8s.GET("/namespaces/:ns/pods/:name/logs", handlers_instance.K8s.GetPodLogs)
+			// One-time state of a namespace for clients that do not use the watch WebSocket
+			k8s.GET("/namespaces/:ns/snapshot", authMiddleware.NamespaceAccess("ns"), handlers_instance.K8s.GetNamespaceSnapshot)
+			// Pod port-forward over WebSocket
+			k8s.GET("/pods/:namespace/:pod/portforward/:port", authMiddleware.NamespaceAccess("namespace"), handlers.PortForwardWebSocketHandler)
+			k8s.GET("/pods/:namespace/:pod/metrics", authMiddleware.NamespaceAccess("namespace"), handlers_instance.K8s.GetPodMetrics)
+			// Read-only sharing of exec terminal sessions
+			k8s.POST("/terminal-sessions/:id/share", handlers.ShareTerminalSessionHandler)
+
+			// PVCs of the caller's namespace in a project; the group member check reads live membership
+			k8s.GET("/pvc/by-project/:id", authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)), handlers_instance.K8s.ListPVCsByProject)
 
 			// Base URL: /k8s/storage/projects
 			projectStorage := k8s.Group("/storage/projects")
@@ -161,7 +337,23 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 					authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)),
 					handlers_instance.K8s.StartProjectFileBrowser)
 
-				projectStorage.DELETE("/:id", authMiddleware.Admin(), handlers_instance.K8s.DeleteProjectStorage)
+				projectStorage.DELETE("/:id", middleware.NoImpersonation(), authMiddleware.Admin(), handlers_instance.K8s.DeleteProjectStorage)
+				projectStorage.DELETE("/:id/storages/:name", middleware.NoImpersonation(), authMiddleware.Admin(), handlers_instance.K8s.DeleteProjectStorageByName)
+
+				// Snapshots of the project storages, managed by project managers
+				projectStorage.POST("/:id/snapshots",
+					authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)),
+					handlers_instance.K8s.CreateProjectSnapshot)
+				projectStorage.GET("/:id/snapshots",
+					authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)),
+					handlers_instance.K8s.ListProjectSnapshots)
+				projectStorage.DELETE("/:id/snapshots/:name",
+					middleware.NoImpersonation(),
+					authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)),
+					handlers_instance.K8s.DeleteProjectSnapshot)
+				projectStorage.POST("/:id/snapshots/:name/restore",
+					authMiddleware.GroupManager(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)),
+					handlers_instance.K8s.RestoreProjectSnapshot)
 
 				projectStorage.DELETE("/:id/stop",
 					authMiddleware.GroupMember(middleware.FromIDParam(repos_instance.Project.GetGroupIDByProjectID)),
@@ -176,17 +368,22 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 			}
 			userStorageGroup := k8s.Group("/users")
 			{
+				userStorageGroup.GET("/storage/entitlement-gaps", authMiddleware.Admin(), handlers_instance.K8s.ListStorageEntitlementGaps)
 				userStorageGroup.GET("/:username/storage/status", handlers_instance.K8s.GetUserStorageStatus)
+				userStorageGroup.GET("/:username/storage/detail", authMiddleware.Admin(), handlers_instance.K8s.GetUserStorageDetail)
 				userStorageGroup.POST("/:username/storage/init", authMiddleware.Admin(), handlers_instance.K8s.InitializeUserStorage)
 				userStorageGroup.PUT("/:username/storage/expand", authMiddleware.Admin(), handlers_instance.K8s.ExpandUserStorage)
-				userStorageGroup.DELETE("/:username/storage", authMiddleware.Admin(), handlers_instance.K8s.DeleteUserStorage)
+				userStorageGroup.DELETE("/:username/storage", middleware.NoImpersonation(), authMiddleware.Admin(), handlers_instance.K8s.DeleteUserStorage)
 				userStorageGroup.POST("/browse", handlers_instance.K8s.OpenMyDrive)
 				userStorageGroup.DELETE("/browse", handlers_instance.K8s.StopMyDrive)
 				userStorageGroup.Any("/proxy/*path", handlers_instance.K8s.UserStorageProxy)
 			}
+			// SFTP access to the caller's storage hub for bulk transfers
+			k8s.POST("/user-storage/sftp", handlers_instance.K8s.StartMySFTP)
+			k8s.DELETE("/user-storage/sftp", handlers_instance.K8s.StopMySFTP)
 		}
 
-		forms := auth.Group("/forms")
+		forms := auth.Group("/forms", smallBody)
 		{
 			forms.POST("", handlers_instance.Form.CreateForm)
 			forms.GET("/my", handlers_instance.Form.GetMyForms)
@@ -0,0 +1,157 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/api/middleware"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+func versionedTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	MountVersioned(r, func(r *gin.RouterGroup) {
+		guarded := r.Group("/things", func(c *gin.Context) {
+			if c.GetHeader("Authorization") == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			c.Next()
+		})
+		guarded.GET("/:id", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+		})
+	})
+	return r
+}
+
+func serve(r *gin.Engine, path string, auth bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if auth {
+		req.Header.Set("Authorization", "Bearer token")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMountVersionedServesBothTrees(t *testing.T) {
+	r := versionedTestRouter()
+
+	versioned := serve(r, config.APIV1Prefix+"/things/7", true)
+	legacy := serve(r, "/things/7", true)
+	if versioned.Code != http.StatusOK || legacy.Code != http.StatusOK {
+		t.Fatalf("expected 200 on both trees, got %d and %d", versioned.Code, legacy.Code)
+	}
+	if versioned.Body.String() != legacy.Body.String() {
+		t.Fatalf("bodies differ: %s vs %s", versioned.Body.String(), legacy.Body.String())
+	}
+	if versioned.Header().Get("Deprecation") != "" {
+		t.Fatal("versioned routes must not be marked deprecated")
+	}
+	if legacy.Header().Get("Deprecation") != "true" || legacy.Header().Get("Sunset") != config.LegacyRoutesSunset {
+		t.Fatalf("legacy route lacks deprecation headers: %v", legacy.Header())
+	}
+	if got, want := legacy.Header().Get("Link"), `<`+config.APIV1Prefix+`/things/7>; rel="successor-version"`; got != want {
+		t.Fatalf("Link = %q, want %q", got, want)
+	}
+
+	// Group middleware runs on both trees
+	for _, path := range []string{config.APIV1Prefix + "/things/7", "/things/7"} {
+		if w := serve(r, path, false); w.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401 without credentials, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestMountVersionedWithoutLegacyRoutes(t *testing.T) {
+	orig := config.LegacyRoutesEnabled
+	t.Cleanup(func() { config.LegacyRoutesEnabled = orig })
+	config.LegacyRoutesEnabled = false
+
+	r := versionedTestRouter()
+	if w := serve(r, config.APIV1Prefix+"/things/7", true); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 on the versioned route, got %d", w.Code)
+	}
+	if w := serve(r, "/things/7", true); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 on the disabled legacy route, got %d", w.Code)
+	}
+}
+
+// namespaceTestRouter mounts the namespace-scoped pod routes behind NamespaceAccess, with the
+// caller taken from the X-User header instead of a token. Alice (5) and bob (6) are members of
+// group 1, which owns "course" in shared namespace mode and "lab" per user.
+func namespaceTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gdb := dbtest.Open(t, &group.Group{}, &group.UserGroup{}, &project.Project{})
+	orig := db.DB
+	t.Cleanup(func() { db.DB = orig })
+	db.DB = gdb
+
+	gdb.Create(&group.Group{GID: 1, GroupName: "ml-101"})
+	gdb.Create(&group.UserGroup{UID: 5, GID: 1, Role: "user"})
+	gdb.Create(&group.UserGroup{UID: 6, GID: 1, Role: "user"})
+	gdb.Create(&project.Project{PID: 1, ProjectName: "course", GID: 1, NamespaceMode: project.NamespaceModeShared, StorageNamespace: "project-course-1"})
+	gdb.Create(&project.Project{PID: 2, ProjectName: "lab", GID: 1, NamespaceMode: project.NamespaceModePerUser, StorageNamespace: "project-lab-2"})
+
+	users := map[string]uint{"alice": 5, "bob": 6, "carol": 7}
+	auth := middleware.NewAuth(repository.NewRepositories(gdb))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r := gin.New()
+	k8s := r.Group("/k8s", func(c *gin.Context) {
+		name := c.GetHeader("X-User")
+		c.Set("claims", &types.Claims{UserID: users[name], Username: name})
+		c.Next()
+	})
+	k8s.GET("/namespaces/:ns/snapshot", auth.NamespaceAccess("ns"), ok)
+	k8s.GET("/pods/:namespace/:pod/portforward/:port", auth.NamespaceAccess("namespace"), ok)
+	k8s.GET("/pods/:namespace/:pod/metrics", auth.NamespaceAccess("namespace"), ok)
+	return r
+}
+
+func TestNamespaceAccessAdmitsMembersOfASharedNamespace(t *testing.T) {
+	r := namespaceTestRouter(t)
+	get := func(user, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, path := range []string{
+		"/k8s/namespaces/project-course-1/snapshot",
+		"/k8s/pods/project-course-1/alice-notebook/portforward/8888",
+		"/k8s/pods/project-course-1/bob-train/metrics",
+	} {
+		for _, user := range []string{"alice", "bob"} {
+			if code := get(user, path); code != http.StatusOK {
+				t.Fatalf("%s: expected member %s to be admitted, got %d", path, user, code)
+			}
+		}
+		if code := get("carol", path); code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403 for a non-member, got %d", path, code)
+		}
+	}
+
+	// Members of a per-user project reach their own namespace, not the storage namespace
+	if code := get("alice", "/k8s/pods/proj-2-alice/train/metrics"); code != http.StatusOK {
+		t.Fatalf("expected alice to reach the proj-2-alice namespace, got %d", code)
+	}
+	if code := get("alice", "/k8s/pods/project-lab-2/filebrowser/metrics"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 on the storage namespace of a per-user project, got %d", code)
+	}
+	if code := get("alice", "/k8s/pods/proj-2-bob/train/metrics"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 on another member's namespace, got %d", code)
+	}
+}
@@ -0,0 +1,184 @@
+package application
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/activity"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+var (
+	ErrInvalidEntityType = errors.New("entity type must be project, configfile or job")
+	ErrEntityNotFound    = errors.New("entity not found")
+)
+
+const (
+	// RecentItemsMax caps the recent items listed per request
+	RecentItemsMax = 20
+	// Activity rows queued for the writer; when it falls behind further rows are dropped
+	activityQueueSize = 1024
+	// Rows written per insert and the longest a queued row waits for one
+	activityBatchSize     = 100
+	activityFlushInterval = 2 * time.Second
+)
+
+// ActivityService keeps the recently used and starred entities of each user for the dashboard.
+// Activity is recorded from the request path, so it is queued and written in batches in the
+// background, like audit logs, and never slows down or fails the request.
+type ActivityService struct {
+	Repos *repository.Repos
+
+	queue chan activity.UserActivity
+	start sync.Once
+}
+
+func NewActivityService(repos *repository.Repos) *ActivityService {
+	return &ActivityService{Repos: repos, queue: make(chan activity.UserActivity, activityQueueSize)}
+}
+
+// Record queues a use of an entity by a user. It does not block: when the queue is full the row
+// is dropped, a recent list being slightly stale is better than a slow request.
+func (s *ActivityService) Record(userID uint, entityType string, entityID uint, action string) {
+	s.start.Do(func() { go s.writeLoop() })
+	row := activity.UserActivity{UserID: userID, EntityType: entityType, EntityID: entityID, Action: action, CreatedAt: time.Now()}
+	select {
+	case s.queue <- row:
+	default:
+		log.Printf("[Activity] queue full, dropped %s %s %d of user %d", action, entityType, entityID, userID)
+	}
+}
+
+func (s *ActivityService) writeLoop() {
+	ticker := time.NewTicker(activityFlushInterval)
+	defer ticker.Stop()
+	batch := make([]activity.UserActivity, 0, activityBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.Repos.Activity.Record(batch); err != nil {
+			log.Printf("[Activity] failed to write %d rows: %v", len(batch), err)
+		}
+		batch = make([]activity.UserActivity, 0, activityBatchSize)
+	}
+	for {
+		select {
+		case row := <-s.queue:
+			batch = append(batch, row)
+			if len(batch) >= activityBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Recent lists the entities the user used last, one entry per entity, most recent first and at
+// most RecentItemsMax. An empty entityType lists every kind. Entities deleted since are left out.
+func (s *ActivityService) Recent(userID uint, entityType string) ([]activity.Item, error) {
+	if entityType != "" && !activity.ValidEntityType(entityType) {
+		return nil, ErrInvalidEntityType
+	}
+	items := []activity.Item{}
+	// Fetch extra rows so deleted entities do not shorten the list
+	rows, err := s.Repos.Activity.ListRecent(userID, entityType, 2*RecentItemsMax)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		item, err := s.resolve(row.EntityType, row.EntityID)
+		if err != nil {
+			continue
+		}
+		item.Action, item.At = row.Action, row.CreatedAt
+		if items = append(items, item); len(items) == RecentItemsMax {
+			break
+		}
+	}
+	return items, nil
+}
+
+// AddFavorite stars an entity for the user. The entity must exist.
+func (s *ActivityService) AddFavorite(userID uint, input activity.FavoriteInput) (activity.Item, error) {
+	if !activity.ValidEntityType(input.EntityType) {
+		return activity.Item{}, ErrInvalidEntityType
+	}
+	item, err := s.resolve(input.EntityType, input.EntityID)
+	if err != nil {
+		return activity.Item{}, err
+	}
+	f := activity.Favorite{UserID: userID, EntityType: input.EntityType, EntityID: input.EntityID, CreatedAt: time.Now()}
+	if err := s.Repos.Activity.AddFavorite(&f); err != nil {
+		return activity.Item{}, err
+	}
+	item.At = f.CreatedAt
+	return item, nil
+}
+
+func (s *ActivityService) RemoveFavorite(userID uint, entityType string, entityID uint) error {
+	if !activity.ValidEntityType(entityType) {
+		return ErrInvalidEntityType
+	}
+	return s.Repos.Activity.RemoveFavorite(userID, entityType, entityID)
+}
+
+// Favorites lists the starred entities of the user. Favorites of entities deleted since are
+// removed instead of being listed as dangling references.
+func (s *ActivityService) Favorites(userID uint) ([]activity.Item, error) {
+	favorites, err := s.Repos.Activity.ListFavorites(userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]activity.Item, 0, len(favorites))
+	for _, f := range favorites {
+		item, err := s.resolve(f.EntityType, f.EntityID)
+		if errors.Is(err, ErrEntityNotFound) {
+			if err := s.Repos.Activity.RemoveFavorite(userID, f.EntityType, f.EntityID); err != nil {
+				log.Printf("[Activity] failed to remove favorite %s %d of user %d: %v", f.EntityType, f.EntityID, userID, err)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		item.At = f.CreatedAt
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// resolve looks the entity up for its current name. It returns ErrEntityNotFound when the
+// entity was deleted; a trashed config file counts as deleted.
+func (s *ActivityService) resolve(entityType string, id uint) (activity.Item, error) {
+	item := activity.Item{EntityType: entityType, EntityID: id}
+	switch entityType {
+	case activity.EntityProject:
+		p, err := s.Repos.Project.GetProjectByID(id)
+		if err != nil {
+			return item, ErrEntityNotFound
+		}
+		item.Name, item.ProjectID = p.ProjectName, p.PID
+	case activity.EntityConfigFile:
+		cf, err := s.Repos.ConfigFile.GetConfigFileByID(id)
+		if err != nil {
+			return item, ErrEntityNotFound
+		}
+		item.Name, item.ProjectID = cf.Filename, cf.ProjectID
+	case activity.EntityJob:
+		j, err := s.Repos.Job.FindByID(id)
+		if err != nil {
+			return item, ErrEntityNotFound
+		}
+		item.Name = j.Name
+		if j.ProjectID != nil {
+			item.ProjectID = *j.ProjectID
+		}
+	default:
+		return item, ErrInvalidEntityType
+	}
+	return item, nil
+}
@@ -0,0 +1,92 @@
+package application
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/activity"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func newActivityService(t *testing.T) (*ActivityService, *repository.Repos) {
+	t.Helper()
+	db := dbtest.Open(t, &project.Project{}, &configfile.ConfigFile{}, &job.Job{}, &activity.UserActivity{}, &activity.Favorite{})
+	for _, p := range []project.Project{{PID: 1, ProjectName: "vision", GID: 1}, {PID: 2, ProjectName: "speech", GID: 1}} {
+		db.Create(&p)
+	}
+	db.Create(&configfile.ConfigFile{CFID: 5, Filename: "train.yaml", ProjectID: 1})
+	repos := repository.NewRepositories(db)
+	return NewActivityService(repos), repos
+}
+
+func TestRecentDedupesMostRecentFirst(t *testing.T) {
+	svc, repos := newActivityService(t)
+	rows := []activity.UserActivity{
+		{UserID: 2, EntityType: activity.EntityProject, EntityID: 1, Action: activity.ActionView},
+		{UserID: 2, EntityType: activity.EntityProject, EntityID: 2, Action: activity.ActionView},
+		{UserID: 2, EntityType: activity.EntityConfigFile, EntityID: 5, Action: activity.ActionCreate},
+		{UserID: 3, EntityType: activity.EntityProject, EntityID: 2, Action: activity.ActionView},
+		{UserID: 2, EntityType: activity.EntityProject, EntityID: 1, Action: activity.ActionView},
+		// Deleted since
+		{UserID: 2, EntityType: activity.EntityProject, EntityID: 9, Action: activity.ActionView},
+	}
+	if err := repos.Activity.Record(rows); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+
+	items, err := svc.Recent(2, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"vision", "train.yaml", "speech"}
+	if len(items) != len(want) {
+		t.Fatalf("expected %v, got %+v", want, items)
+	}
+	for i, name := range want {
+		if items[i].Name != name {
+			t.Fatalf("item %d: expected %s, got %+v", i, name, items)
+		}
+	}
+
+	projects, _ := svc.Recent(2, activity.EntityProject)
+	if len(projects) != 2 || projects[0].EntityID != 1 || projects[1].EntityID != 2 {
+		t.Fatalf("expected the two projects, latest first, got %+v", projects)
+	}
+	if _, err := svc.Recent(2, "group"); !errors.Is(err, ErrInvalidEntityType) {
+		t.Fatalf("expected an unknown type to be rejected, got %v", err)
+	}
+}
+
+func TestFavoritesOfDeletedEntities(t *testing.T) {
+	svc, repos := newActivityService(t)
+
+	if _, err := svc.AddFavorite(2, activity.FavoriteInput{EntityType: activity.EntityJob, EntityID: 42}); !errors.Is(err, ErrEntityNotFound) {
+		t.Fatalf("expected starring a missing job to be not found, got %v", err)
+	}
+	for _, id := range []uint{1, 2, 2} {
+		if _, err := svc.AddFavorite(2, activity.FavoriteInput{EntityType: activity.EntityProject, EntityID: id}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if items, _ := svc.Favorites(2); len(items) != 2 {
+		t.Fatalf("expected two favorites, got %+v", items)
+	}
+
+	if err := repos.Project.DeleteProject(2); err != nil {
+		t.Fatalf("failed to delete project: %v", err)
+	}
+	items, err := svc.Favorites(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 1 || items[0].Name != "vision" {
+		t.Fatalf("expected only the remaining project, got %+v", items)
+	}
+	if stored, _ := repos.Activity.ListFavorites(2); len(stored) != 1 {
+		t.Fatalf("expected the dangling favorite to be removed, got %+v", stored)
+	}
+}
@@ -0,0 +1,220 @@
+package application
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/pagination"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var ErrInvalidPodSort = errors.New("invalid pod sort")
+
+// Sources of a platform pod, telling what created it.
+const (
+	PodSourceJob         = "job"
+	PodSourceConfigFile  = "configfile"
+	PodSourceFileBrowser = "filebrowser"
+	PodSourceNFS         = "nfs"
+	PodSourceOther       = "other"
+)
+
+// PlatformPod is one row of the admin listing of the pods the platform runs.
+type PlatformPod struct {
+	Namespace   string    `json:"namespace"`
+	Name        string    `json:"name"`
+	UserID      uint      `json:"user_id,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	ProjectID   uint      `json:"project_id,omitempty"`
+	ProjectName string    `json:"project_name,omitempty"`
+	Source      string    `json:"source"`
+	JobID       uint      `json:"job_id,omitempty"`
+	Status      string    `json:"status"`
+	Node        string    `json:"node,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Age         string    `json:"age"`
+	// GPU is the number of whole GPUs and SharedGPU the MPS shares the containers request
+	GPU       int64 `json:"gpu"`
+	SharedGPU int64 `json:"shared_gpu"`
+	Restarts  int32 `json:"restarts"`
+	// DeletePath is the existing endpoint an admin stops the pod with; empty when it has none
+	Deletable  bool   `json:"deletable"`
+	DeletePath string `json:"delete_path,omitempty"`
+
+	uid string
+}
+
+// PodFilter narrows the admin pod listing; zero fields match every pod. Sort is "age"
+// (newest first, the default) or "restarts" (most first).
+type PodFilter struct {
+	User      string
+	ProjectID uint
+	Status    string
+	Node      string
+	Sort      string
+}
+
+// ListPlatformPods lists the pods the platform created in every namespace with one cluster-wide
+// request on the managed-by label, attributes them to their user, project and source, and
+// returns the page p selects.
+func (s *K8sService) ListPlatformPods(ctx context.Context, f PodFilter, p pagination.Params) (*pagination.Page[PlatformPod], error) {
+	if f.Sort == "" {
+		f.Sort = "age"
+	}
+	if f.Sort != "age" && f.Sort != "restarts" {
+		return nil, fmt.Errorf("%w: sort must be age or restarts", ErrInvalidPodSort)
+	}
+	if k8s.Clientset == nil {
+		return nil, fmt.Errorf("kubernetes client not initialized")
+	}
+	list, err := k8s.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: k8s.ManagedSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list platform pods: %w", err)
+	}
+
+	names := newOwnerNames(s)
+	now := time.Now()
+	pods := make([]PlatformPod, 0, len(list.Items))
+	for i := range list.Items {
+		row := platformPod(&list.Items[i], now)
+		names.resolve(&row)
+		if f.matches(&row) {
+			pods = append(pods, row)
+		}
+	}
+
+	slices.SortFunc(pods, func(a, b PlatformPod) int {
+		if f.Sort == "restarts" {
+			if c := cmp.Compare(b.Restarts, a.Restarts); c != 0 {
+				return c
+			}
+		}
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(podCursorID(b.uid), podCursorID(a.uid))
+	})
+	return pagination.Slice(pods, p, func(pod *PlatformPod) pagination.Cursor {
+		cursor := pagination.Cursor{CreatedAt: pod.CreatedAt, ID: podCursorID(pod.uid)}
+		if f.Sort == "restarts" {
+			cursor.Rank = int64(pod.Restarts)
+		}
+		return cursor
+	}), nil
+}
+
+func (f PodFilter) matches(pod *PlatformPod) bool {
+	return (f.User == "" || pod.Username == f.User) &&
+		(f.ProjectID == 0 || pod.ProjectID == f.ProjectID) &&
+		(f.Status == "" || strings.EqualFold(pod.Status, f.Status)) &&
+		(f.Node == "" || pod.Node == f.Node)
+}
+
+// platformPod builds the row of a pod from its labels and status; names are resolved later.
+func platformPod(pod *corev1.Pod, now time.Time) PlatformPod {
+	labels := pod.Labels
+	row := PlatformPod{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Status:    string(pod.Status.Phase),
+		Node:      pod.Spec.NodeName,
+		CreatedAt: pod.CreationTimestamp.Time,
+		Age:       now.Sub(pod.CreationTimestamp.Time).Truncate(time.Second).String(),
+		UserID:    labelID(labels, k8s.LabelUserID),
+		ProjectID: labelID(labels, k8s.LabelProjectID),
+		JobID:     labelID(labels, k8s.LabelJobID),
+		uid:       string(pod.UID),
+	}
+	if pid, username, ok := k8s.ParseProjectNamespace(pod.Namespace); ok {
+		if row.ProjectID == 0 {
+			row.ProjectID = pid
+		}
+		row.Username = username
+	}
+
+	for _, c := range pod.Spec.Containers {
+		if q, ok := c.Resources.Requests[k8s.GPUResource]; ok {
+			row.GPU += q.Value()
+		}
+		if q, ok := c.Resources.Requests[k8s.SharedGPUResource]; ok {
+			row.SharedGPU += q.Value()
+		}
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		row.Restarts += cs.RestartCount
+	}
+
+	app := labels["app"]
+	switch {
+	case row.JobID != 0:
+		row.Source = PodSourceJob
+		row.Deletable, row.DeletePath = true, fmt.Sprintf("/jobs/%d", row.JobID)
+	case labels[k8s.LabelConfigFileID] != "":
+		row.Source = PodSourceConfigFile
+	case app == "filebrowser" || strings.HasPrefix(app, "fb-"):
+		row.Source = PodSourceFileBrowser
+		if labels["role"] == "project-storage" && row.ProjectID != 0 {
+			row.Deletable, row.DeletePath = true, fmt.Sprintf("/k8s/storage/projects/%d/stop", row.ProjectID)
+		}
+	case app == "storage-hub":
+		row.Source = PodSourceNFS
+	default:
+		row.Source = PodSourceOther
+	}
+	return row
+}
+
+// labelID returns the ID stored in an ownership label, or 0 when it is missing.
+func labelID(labels map[string]string, key string) uint {
+	id, err := strconv.ParseUint(labels[key], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// podCursorID turns a pod UID into the non-zero ID of its pagination cursor.
+func podCursorID(uid string) uint {
+	return uint(crc32.ChecksumIEEE([]byte(uid))) | 1
+}
+
+// ownerNames looks up each user and project name once per listing.
+type ownerNames struct {
+	s        *K8sService
+	users    map[uint]string
+	projects map[uint]string
+}
+
+func newOwnerNames(s *K8sService) *ownerNames {
+	return &ownerNames{s: s, users: map[uint]string{}, projects: map[uint]string{}}
+}
+
+func (n *ownerNames) resolve(pod *PlatformPod) {
+	if pod.Username == "" && pod.UserID != 0 {
+		name, ok := n.users[pod.UserID]
+		if !ok {
+			name, _ = n.s.repos.User.GetUsernameByID(pod.UserID)
+			n.users[pod.UserID] = name
+		}
+		pod.Username = name
+	}
+	if pod.ProjectID != 0 {
+		name, ok := n.projects[pod.ProjectID]
+		if !ok {
+			if p, err := n.s.repos.Project.GetProjectByID(pod.ProjectID); err == nil {
+				name = p.ProjectName
+			}
+			n.projects[pod.ProjectID] = name
+		}
+		pod.ProjectName = name
+	}
+}
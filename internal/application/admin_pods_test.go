@@ -0,0 +1,189 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/pagination"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// setupPlatformPods seeds platform pods across three namespaces: a job and a config file pod of
+// alice in project 1, a job pod of bob in project 1, the project file browser and an NFS hub,
+// plus one pod the platform did not create.
+func setupPlatformPods(t *testing.T) *K8sService {
+	t.Helper()
+	db := dbtest.Open(t, &user.User{}, &project.Project{})
+	db.Create(&user.User{UID: 7, Username: "alice"})
+	db.Create(&project.Project{PID: 1, ProjectName: "vision", GID: 1})
+
+	base := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	pod := func(ns, name string, age time.Duration, restarts int32, node string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns, Name: name, UID: types.UID(ns + "/" + name), Labels: labels,
+				CreationTimestamp: metav1.NewTime(base.Add(-age)),
+			},
+			Spec: corev1.PodSpec{NodeName: node, Containers: []corev1.Container{{
+				Name:      "main",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{k8s.GPUResource: *resource.NewQuantity(1, resource.DecimalSI)}},
+			}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{RestartCount: restarts}}},
+		}
+	}
+	orig := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = orig })
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		pod("proj-1-alice", "train-a", time.Hour, 0, "gpu-a", k8s.Ownership{ProjectID: 1, UserID: 7, JobID: 11}.Labels()),
+		pod("proj-1-alice", "notebook", 3*time.Hour, 5, "gpu-b", k8s.Ownership{ProjectID: 1, ConfigFileID: 4}.Labels()),
+		pod("proj-1-bob", "train-b", 2*time.Hour, 2, "gpu-a", k8s.Ownership{ProjectID: 1, JobID: 12}.Labels()),
+		pod("vision-storage", "filebrowser-project", 30*time.Minute, 1, "cpu-a",
+			k8s.MergeLabels(map[string]string{"app": "filebrowser", "role": "project-storage"}, k8s.Ownership{ProjectID: 1}.Labels())),
+		pod("vision-storage", "hub-0", 5*time.Hour, 0, "cpu-a",
+			k8s.MergeLabels(map[string]string{"app": "storage-hub"}, k8s.Ownership{}.Labels())),
+		pod("kube-system", "coredns", 9*time.Hour, 0, "cpu-a", map[string]string{"app": "coredns"}),
+	)
+	return NewK8sService(repository.NewRepositories(db))
+}
+
+func podNames(pods []PlatformPod) []string {
+	names := make([]string, 0, len(pods))
+	for _, p := range pods {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestListPlatformPodsAttributesOwners(t *testing.T) {
+	svc := setupPlatformPods(t)
+
+	page, err := svc.ListPlatformPods(context.Background(), PodFilter{}, pagination.Params{WithTotal: true})
+	if err != nil {
+		t.Fatalf("ListPlatformPods: %v", err)
+	}
+	if page.Total == nil || *page.Total != 5 {
+		t.Fatalf("expected the 5 platform pods, got %v", podNames(page.Items))
+	}
+	byName := map[string]PlatformPod{}
+	for _, p := range page.Items {
+		byName[p.Name] = p
+	}
+
+	train := byName["train-a"]
+	if train.Source != PodSourceJob || train.Username != "alice" || train.ProjectName != "vision" ||
+		train.GPU != 1 || !train.Deletable || train.DeletePath != "/jobs/11" {
+		t.Fatalf("unexpected job pod row %+v", train)
+	}
+	if nb := byName["notebook"]; nb.Source != PodSourceConfigFile || nb.Deletable || nb.Restarts != 5 {
+		t.Fatalf("unexpected config file pod row %+v", nb)
+	}
+	if fb := byName["filebrowser-project"]; fb.Source != PodSourceFileBrowser || fb.DeletePath != "/k8s/storage/projects/1/stop" {
+		t.Fatalf("unexpected file browser pod row %+v", fb)
+	}
+	if hub := byName["hub-0"]; hub.Source != PodSourceNFS || hub.Deletable {
+		t.Fatalf("unexpected NFS pod row %+v", hub)
+	}
+}
+
+func TestListPlatformPodsFiltersByUser(t *testing.T) {
+	svc := setupPlatformPods(t)
+
+	page, err := svc.ListPlatformPods(context.Background(), PodFilter{User: "alice"}, pagination.Params{})
+	if err != nil {
+		t.Fatalf("ListPlatformPods: %v", err)
+	}
+	got := podNames(page.Items)
+	if len(got) != 2 || got[0] != "train-a" || got[1] != "notebook" {
+		t.Fatalf("expected alice's pods newest first, got %v", got)
+	}
+
+	page, err = svc.ListPlatformPods(context.Background(), PodFilter{Node: "gpu-a", ProjectID: 1}, pagination.Params{})
+	if err != nil || len(page.Items) != 2 {
+		t.Fatalf("expected the two project pods on gpu-a, got %v (%v)", podNames(page.Items), err)
+	}
+}
+
+func TestListPlatformPodsSortsAndPages(t *testing.T) {
+	svc := setupPlatformPods(t)
+	ctx := context.Background()
+
+	page, err := svc.ListPlatformPods(ctx, PodFilter{}, pagination.Params{Limit: 2})
+	if err != nil {
+		t.Fatalf("ListPlatformPods: %v", err)
+	}
+	if got := podNames(page.Items); got[0] != "filebrowser-project" || got[1] != "train-a" || page.NextCursor == "" {
+		t.Fatalf("expected the newest pods first, got %v", got)
+	}
+
+	var names []string
+	p := pagination.Params{Limit: 2}
+	for {
+		page, err := svc.ListPlatformPods(ctx, PodFilter{Sort: "restarts"}, p)
+		if err != nil {
+			t.Fatalf("ListPlatformPods: %v", err)
+		}
+		names = append(names, podNames(page.Items)...)
+		if page.NextCursor == "" {
+			break
+		}
+		if p.After, err = pagination.Decode(page.NextCursor); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+	}
+	// Most restarts first, ties newest first
+	want := []string{"notebook", "train-b", "filebrowser-project", "train-a", "hub-0"}
+	if len(names) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, names)
+		}
+	}
+
+	if _, err := svc.ListPlatformPods(ctx, PodFilter{Sort: "gpu"}, pagination.Params{}); !errors.Is(err, ErrInvalidPodSort) {
+		t.Fatalf("expected ErrInvalidPodSort, got %v", err)
+	}
+}
+
+// TestListPlatformPodsResumesWhenTheCursorPodIsGone deletes the last pod of a page sorted by
+// restarts before the next page is read; the listing continues with the pods that followed it.
+func TestListPlatformPodsResumesWhenTheCursorPodIsGone(t *testing.T) {
+	svc := setupPlatformPods(t)
+	ctx := context.Background()
+	f := PodFilter{Sort: "restarts"}
+
+	first, err := svc.ListPlatformPods(ctx, f, pagination.Params{Limit: 2})
+	if err != nil {
+		t.Fatalf("ListPlatformPods: %v", err)
+	}
+	if got := podNames(first.Items); got[0] != "notebook" || got[1] != "train-b" {
+		t.Fatalf("expected notebook and train-b first, got %v", got)
+	}
+	if err := k8s.Clientset.CoreV1().Pods("proj-1-bob").Delete(ctx, "train-b", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	after, err := pagination.Decode(first.NextCursor)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	second, err := svc.ListPlatformPods(ctx, f, pagination.Params{Limit: 2, After: after})
+	if err != nil {
+		t.Fatalf("ListPlatformPods: %v", err)
+	}
+	if got := podNames(second.Items); len(got) != 2 || got[0] != "filebrowser-project" || got[1] != "train-a" {
+		t.Fatalf("expected filebrowser-project and train-a next, got %v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package application
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+var (
+	ErrInvalidTokenScope  = errors.New("invalid token scope")
+	ErrAPITokenNotFound   = errors.New("api token not found")
+	ErrAPITokenForbidden  = errors.New("not allowed to manage this api token")
+	ErrProjectTokenDenied = errors.New("project-scoped tokens can only be created by project managers")
+)
+
+// apiTokenBytes is the size of the random secret behind each token.
+const apiTokenBytes = 32
+
+type APITokenService struct {
+	Repos *repository.Repos
+	now   func() time.Time
+}
+
+func NewAPITokenService(repos *repository.Repos) *APITokenService {
+	return &APITokenService{
+		Repos: repos,
+		now:   time.Now,
+	}
+}
+
+// CreateToken issues a token for uid. A project-scoped token acts on that project only and
+// requires the creator to manage the project's group.
+func (s *APITokenService) CreateToken(c *gin.Context, uid uint, input user.CreateAPITokenInput) (*user.CreatedAPITokenDTO, error) {
+	scopes, err := normalizeScopes(input.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if input.ProjectID != nil {
+		gid, err := s.Repos.Project.GetGroupIDByProjectID(*input.ProjectID)
+		if err != nil {
+			return nil, ErrProjectNotFound
+		}
+		if ok, err := utils.CheckGroupManagePermission(uid, gid, s.Repos.UserGroup); err != nil || !ok {
+			return nil, ErrProjectTokenDenied
+		}
+	}
+
+	raw, err := generateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+	t := &user.APIToken{
+		UserID:    uid,
+		ProjectID: input.ProjectID,
+		Name:      input.Name,
+		Prefix:    raw[:len(user.APITokenPrefix)+6],
+		TokenHash: user.HashAPIToken(raw),
+		Scopes:    strings.Join(scopes, ","),
+	}
+	if input.ExpiresInDays > 0 {
+		exp := s.now().AddDate(0, 0, input.ExpiresInDays)
+		t.ExpiresAt = &exp
+	}
+	if err := s.Repos.APIToken.Create(t); err != nil {
+		return nil, err
+	}
+
+	utils.LogAuditWithConsole(c, "create", "api_token", fmt.Sprintf("id=%d", t.ID), nil, t.ToDTO(), "", s.Repos.Audit)
+	return &user.CreatedAPITokenDTO{APITokenDTO: t.ToDTO(), Token: raw}, nil
+}
+
+// ListTokens returns the tokens of uid, including revoked and expired ones.
+func (s *APITokenService) ListTokens(uid uint) ([]user.APITokenDTO, error) {
+	tokens, err := s.Repos.APIToken.ListByUser(uid)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]user.APITokenDTO, 0, len(tokens))
+	for i := range tokens {
+		out = append(out, tokens[i].ToDTO())
+	}
+	return out, nil
+}
+
+// RevokeToken revokes a token. Owners revoke their own tokens; super admins may revoke any.
+func (s *APITokenService) RevokeToken(c *gin.Context, uid, id uint) error {
+	t, err := s.Repos.APIToken.GetByID(id)
+	if err != nil {
+		return ErrAPITokenNotFound
+	}
+	if t.UserID != uid {
+		isAdmin, err := utils.IsSuperAdmin(uid, s.Repos.UserGroup)
+		if err != nil {
+			return err
+		}
+		if !isAdmin {
+			return ErrAPITokenForbidden
+		}
+	}
+	if err := s.Repos.APIToken.Revoke(id, s.now()); err != nil {
+		return err
+	}
+	utils.LogAuditWithConsole(c, "delete", "api_token", fmt.Sprintf("id=%d", id), t.ToDTO(), nil, "", s.Repos.Audit)
+	return nil
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	out := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		sc = strings.TrimSpace(sc)
+		if !slices.Contains(user.APITokenScopes, sc) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTokenScope, sc)
+		}
+		if !slices.Contains(out, sc) {
+			out = append(out, sc)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidTokenScope)
+	}
+	return out, nil
+}
+
+func generateAPIToken() (string, error) {
+	b := make([]byte, apiTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return user.APITokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,85 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+func setupAPITokenService(t *testing.T) *APITokenService {
+	t.Helper()
+	db := dbtest.Open(t, &user.APIToken{}, &group.Group{}, &group.UserGroup{})
+	origLog := utils.LogAuditWithConsole
+	t.Cleanup(func() { utils.LogAuditWithConsole = origLog })
+	utils.LogAuditWithConsole = func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo) {}
+	return NewAPITokenService(repository.NewRepositories(db))
+}
+
+func TestCreateAPITokenStoresOnlyHash(t *testing.T) {
+	svc := setupAPITokenService(t)
+
+	created, err := svc.CreateToken(nil, 5, user.CreateAPITokenInput{
+		Name:          "ci",
+		Scopes:        []string{user.ScopeJobsWrite, user.ScopeJobsWrite, user.ScopeConfigFilesRead},
+		ExpiresInDays: 30,
+	})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if !strings.HasPrefix(created.Token, user.APITokenPrefix) || !strings.HasPrefix(created.Token, created.Prefix) {
+		t.Fatalf("unexpected token %q with prefix %q", created.Token, created.Prefix)
+	}
+	if got := strings.Join(created.Scopes, ","); got != "jobs:write,configfiles:read" {
+		t.Fatalf("scopes = %q", got)
+	}
+	if created.ExpiresAt == nil {
+		t.Fatal("expected an expiry")
+	}
+
+	stored, err := svc.Repos.APIToken.GetByHash(user.HashAPIToken(created.Token))
+	if err != nil {
+		t.Fatalf("token not found by hash: %v", err)
+	}
+	if strings.Contains(stored.TokenHash, created.Token) {
+		t.Fatal("raw token stored")
+	}
+}
+
+func TestCreateAPITokenRejectsUnknownScope(t *testing.T) {
+	svc := setupAPITokenService(t)
+
+	_, err := svc.CreateToken(nil, 5, user.CreateAPITokenInput{Name: "ci", Scopes: []string{"admin:all"}})
+	if !errors.Is(err, ErrInvalidTokenScope) {
+		t.Fatalf("expected ErrInvalidTokenScope, got %v", err)
+	}
+}
+
+func TestRevokeAPITokenOwnerOnly(t *testing.T) {
+	svc := setupAPITokenService(t)
+	created, err := svc.CreateToken(nil, 5, user.CreateAPITokenInput{Name: "ci", Scopes: []string{user.ScopeJobsRead}})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	if err := svc.RevokeToken(nil, 6, created.ID); !errors.Is(err, ErrAPITokenForbidden) {
+		t.Fatalf("expected ErrAPITokenForbidden, got %v", err)
+	}
+	if err := svc.RevokeToken(nil, 5, created.ID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	tokens, err := svc.ListTokens(5)
+	if err != nil {
+		t.Fatalf("ListTokens: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].RevokedAt == nil {
+		t.Fatalf("expected one revoked token, got %+v", tokens)
+	}
+}
@@ -0,0 +1,234 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"text/template"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/form"
+	"github.com/linskybing/platform-go/internal/domain/gpu"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/mail"
+)
+
+// Kinds of requests waiting for an admin
+const (
+	ApprovalKindImage = "Image requests"
+	ApprovalKindGPU   = "GPU requests"
+	ApprovalKindForm  = "Open forms"
+)
+
+// approvalSendTimeout bounds a single email so a stuck relay cannot hold up the others.
+const approvalSendTimeout = 30 * time.Second
+
+// PendingApproval is one request listed in an admin email.
+type PendingApproval struct {
+	Kind      string
+	ID        uint
+	Summary   string
+	CreatedAt time.Time
+	Link      string
+}
+
+type approvalSection struct {
+	Kind  string
+	Items []PendingApproval
+}
+
+type approvalEmail struct {
+	Name     string
+	MinAge   time.Duration
+	Sections []approvalSection
+	Urgent   *PendingApproval
+}
+
+var approvalHTML = htmltemplate.Must(htmltemplate.New("approvals").Parse(`<p>Hello {{.Name}},</p>
+{{if .Urgent}}<p>An urgent request needs your decision:</p>
+<p><a href="{{.Urgent.Link}}">{{.Urgent.Summary}}</a>, submitted {{.Urgent.CreatedAt.Format "2006-01-02 15:04"}}</p>
+{{else}}<p>These requests have been waiting for more than {{.MinAge}}:</p>
+{{range .Sections}}<h3>{{.Kind}} ({{len .Items}})</h3>
+<ul>
+{{range .Items}}<li><a href="{{.Link}}">{{.Summary}}</a>, submitted {{.CreatedAt.Format "2006-01-02 15:04"}}</li>
+{{end}}</ul>
+{{end}}{{end}}`))
+
+var approvalText = template.Must(template.New("approvals").Parse(`Hello {{.Name}},
+
+{{if .Urgent}}An urgent request needs your decision:
+
+{{.Urgent.Summary}}, submitted {{.Urgent.CreatedAt.Format "2006-01-02 15:04"}}
+{{.Urgent.Link}}
+{{else}}These requests have been waiting for more than {{.MinAge}}:
+{{range .Sections}}
+{{.Kind}} ({{len .Items}})
+{{range .Items}}- {{.Summary}}, submitted {{.CreatedAt.Format "2006-01-02 15:04"}}
+  {{.Link}}
+{{end}}{{end}}{{end}}`))
+
+// ApprovalNotifier emails admins about requests waiting for their decision: a periodic digest
+// of everything older than the approval_digest_min_age setting, and an immediate email for urgent ones.
+// A failed email is logged and skipped; it never stops the other recipients.
+type ApprovalNotifier struct {
+	Repos  *repository.Repos
+	sender mail.Sender
+	now    func() time.Time
+}
+
+// NewApprovalNotifier sends through sender; a nil sender disables the emails.
+func NewApprovalNotifier(repos *repository.Repos, sender mail.Sender) *ApprovalNotifier {
+	return &ApprovalNotifier{Repos: repos, sender: sender, now: time.Now}
+}
+
+// configuredMailSender is the SMTP relay from config, or nil while email is not set up.
+func configuredMailSender() mail.Sender {
+	if config.SMTPHost == "" {
+		return nil
+	}
+	return mail.NewSMTPSender(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.SMTPFrom)
+}
+
+func approvalLink(format string, id uint) string {
+	return config.PlatformURL + fmt.Sprintf(format, id)
+}
+
+// pendingApprovals lists the image requests, GPU requests and open forms submitted at least
+// minAge ago, oldest first within each kind.
+func (n *ApprovalNotifier) pendingApprovals(minAge time.Duration) ([]approvalSection, error) {
+	cutoff := n.now().Add(-minAge)
+	var sections []approvalSection
+
+	images, err := n.Repos.Image.ListRequests(nil, "pending")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list image requests: %w", err)
+	}
+	var items []PendingApproval
+	for i := len(images) - 1; i >= 0; i-- { // listed newest first
+		r := images[i]
+		if r.CreatedAt.After(cutoff) {
+			continue
+		}
+		name := r.InputImageName + ":" + r.InputTag
+		if r.InputRegistry != "" {
+			name = r.InputRegistry + "/" + name
+		}
+		items = append(items, PendingApproval{Kind: ApprovalKindImage, ID: r.ID, Summary: name, CreatedAt: r.CreatedAt,
+			Link: approvalLink("/admin/image-requests/%d", r.ID)})
+	}
+	sections = appendSection(sections, ApprovalKindImage, items)
+
+	gpuReqs, err := n.Repos.GPURequest.ListByStatus(gpu.GPURequestStatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GPU requests: %w", err)
+	}
+	items = nil
+	for _, r := range gpuReqs {
+		if !r.CreatedAt.After(cutoff) {
+			items = append(items, gpuRequestApproval(r))
+		}
+	}
+	sections = appendSection(sections, ApprovalKindGPU, items)
+
+	forms, err := n.Repos.Form.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list forms: %w", err)
+	}
+	items = nil
+	for _, f := range forms {
+		open := f.Status == form.FormStatusPending || f.Status == form.FormStatusProcessing
+		if open && !f.CreatedAt.After(cutoff) {
+			items = append(items, PendingApproval{Kind: ApprovalKindForm, ID: f.ID, Summary: f.Title, CreatedAt: f.CreatedAt,
+				Link: approvalLink("/forms/%d", f.ID)})
+		}
+	}
+	return appendSection(sections, ApprovalKindForm, items), nil
+}
+
+func appendSection(sections []approvalSection, kind string, items []PendingApproval) []approvalSection {
+	if len(items) == 0 {
+		return sections
+	}
+	return append(sections, approvalSection{Kind: kind, Items: items})
+}
+
+func gpuRequestApproval(r gpu.GPURequest) PendingApproval {
+	summary := fmt.Sprintf("GPU access %q for project %d", r.RequestedAccessType, r.ProjectID)
+	if r.Type == gpu.GPURequestTypeQuota {
+		summary = fmt.Sprintf("GPU quota of %d for project %d", r.RequestedQuota, r.ProjectID)
+	}
+	return PendingApproval{Kind: ApprovalKindGPU, ID: r.ID, Summary: summary, CreatedAt: r.CreatedAt,
+		Link: approvalLink("/admin/gpu-requests/%d", r.ID)}
+}
+
+// SendDigest emails every admin the requests waiting longer than the approval_digest_min_age setting
+// and returns how many emails went out. Nothing is sent when nothing is waiting.
+func (n *ApprovalNotifier) SendDigest(ctx context.Context) (int, error) {
+	if n.sender == nil {
+		return 0, nil
+	}
+	minAge := config.DurationSetting(config.SettingApprovalDigestMinAge)
+	sections, err := n.pendingApprovals(minAge)
+	if err != nil || len(sections) == 0 {
+		return 0, err
+	}
+	total := 0
+	for _, s := range sections {
+		total += len(s.Items)
+	}
+	subject := fmt.Sprintf("%d requests are waiting for approval", total)
+	return n.sendToAdmins(ctx, subject, approvalEmail{MinAge: minAge, Sections: sections})
+}
+
+// GPURequestCreated emails the admins at once about an urgent GPU request; other requests wait
+// for the digest.
+func (n *ApprovalNotifier) GPURequestCreated(ctx context.Context, req gpu.GPURequest) {
+	if n.sender == nil || !req.Urgent {
+		return
+	}
+	item := gpuRequestApproval(req)
+	if _, err := n.sendToAdmins(ctx, "Urgent: "+item.Summary, approvalEmail{Urgent: &item}); err != nil {
+		log.Printf("Failed to notify admins about GPU request %d: %v", req.ID, err)
+	}
+}
+
+func (n *ApprovalNotifier) sendToAdmins(ctx context.Context, subject string, data approvalEmail) (int, error) {
+	admins, err := n.Repos.User.ListSuperAdmins()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list admins: %w", err)
+	}
+	sent := 0
+	for _, admin := range admins {
+		if admin.Email == nil || *admin.Email == "" {
+			continue
+		}
+		data.Name = admin.Username
+		msg, err := renderApprovalEmail(*admin.Email, subject, data)
+		if err != nil {
+			return sent, err
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, approvalSendTimeout)
+		err = n.sender.Send(sendCtx, msg)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to email %s: %v", admin.Username, err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func renderApprovalEmail(to, subject string, data approvalEmail) (mail.Message, error) {
+	var html, text bytes.Buffer
+	if err := approvalHTML.Execute(&html, data); err != nil {
+		return mail.Message{}, fmt.Errorf("failed to render email: %w", err)
+	}
+	if err := approvalText.Execute(&text, data); err != nil {
+		return mail.Message{}, fmt.Errorf("failed to render email: %w", err)
+	}
+	return mail.Message{To: []string{to}, Subject: subject, HTML: html.String(), Text: text.String()}, nil
+}
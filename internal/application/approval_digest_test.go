@@ -0,0 +1,128 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/form"
+	"github.com/linskybing/platform-go/internal/domain/gpu"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/mail"
+	"gorm.io/gorm"
+)
+
+// recordingSender keeps the emails it is asked to send; sends to failFor fail.
+type recordingSender struct {
+	sent    []mail.Message
+	failFor string
+}
+
+func (r *recordingSender) Send(_ context.Context, msg mail.Message) error {
+	if msg.To[0] == r.failFor {
+		return errors.New("relay refused the recipient")
+	}
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func setupApprovalNotifier(t *testing.T, sender mail.Sender) (*ApprovalNotifier, *gorm.DB, time.Time) {
+	t.Helper()
+	db := dbtest.Open(t, &user.User{}, &group.Group{}, &group.UserGroup{}, &image.ImageRequest{}, &gpu.GPURequest{}, &form.Form{})
+	email := func(s string) *string { return &s }
+	db.Create(&user.User{UID: 1, Username: "root", Password: "x", Email: email("root@example.com")})
+	db.Create(&user.User{UID: 2, Username: "ops", Password: "x", Email: email("ops@example.com")})
+	db.Create(&user.User{UID: 3, Username: "student", Password: "x", Email: email("student@example.com")})
+	db.Create(&group.Group{GID: 1, GroupName: "super"})
+	db.Create(&group.UserGroup{UID: 2, GID: 1, Role: "admin"})
+
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	n := NewApprovalNotifier(repository.NewRepositories(db), sender)
+	n.now = func() time.Time { return now }
+	return n, db, now
+}
+
+func TestSendDigestListsOldPendingRequests(t *testing.T) {
+	origURL, origAge := config.PlatformURL, config.ApprovalDigestMinAge
+	t.Cleanup(func() { config.PlatformURL, config.ApprovalDigestMinAge = origURL, origAge })
+	config.PlatformURL, config.ApprovalDigestMinAge = "https://platform.example.com", 24*time.Hour
+
+	sender := &recordingSender{}
+	n, db, now := setupApprovalNotifier(t, sender)
+	old, fresh := now.Add(-72*time.Hour), now.Add(-time.Hour)
+	oldImage := image.ImageRequest{InputImageName: "pytorch/pytorch", InputTag: "2.1", Status: "pending"}
+	oldImage.CreatedAt = old
+	freshImage := image.ImageRequest{InputImageName: "fresh/image", InputTag: "1", Status: "pending"}
+	freshImage.CreatedAt = fresh
+	db.Create(&oldImage)
+	db.Create(&freshImage)
+	db.Create(&gpu.GPURequest{ProjectID: 4, RequesterID: 3, Type: gpu.GPURequestTypeQuota, RequestedQuota: 8, Status: gpu.GPURequestStatusPending, CreatedAt: old})
+	db.Create(&gpu.GPURequest{ProjectID: 4, RequesterID: 3, Type: gpu.GPURequestTypeAccess, Status: gpu.GPURequestStatusApproved, CreatedAt: old})
+	openForm := form.Form{UserID: 3, Title: "Need <more> storage", Status: form.FormStatusPending}
+	openForm.CreatedAt = old
+	db.Create(&openForm)
+
+	sent, err := n.SendDigest(context.Background())
+	if err != nil || sent != 2 {
+		t.Fatalf("expected the digest to reach both admins, got %d (%v)", sent, err)
+	}
+	for _, msg := range sender.sent {
+		if msg.To[0] == "student@example.com" {
+			t.Fatalf("only admins receive the digest")
+		}
+		if msg.Subject != "3 requests are waiting for approval" {
+			t.Fatalf("unexpected subject %q", msg.Subject)
+		}
+		for _, want := range []string{"pytorch/pytorch:2.1", "GPU quota of 8 for project 4", "https://platform.example.com/forms/"} {
+			if !strings.Contains(msg.Text, want) || !strings.Contains(msg.HTML, want) {
+				t.Fatalf("both bodies should list %q:\n%s\n%s", want, msg.Text, msg.HTML)
+			}
+		}
+		if strings.Contains(msg.Text, "fresh/image") {
+			t.Fatalf("requests younger than the minimum age must wait for the next digest")
+		}
+		if !strings.Contains(msg.HTML, "Need &lt;more&gt; storage") || !strings.Contains(msg.Text, "Need <more> storage") {
+			t.Fatalf("the HTML body must escape user input and the text body keep it:\n%s\n%s", msg.HTML, msg.Text)
+		}
+	}
+}
+
+func TestSendDigestSkipsFailedRecipients(t *testing.T) {
+	sender := &recordingSender{failFor: "root@example.com"}
+	n, db, now := setupApprovalNotifier(t, sender)
+	db.Create(&gpu.GPURequest{ProjectID: 4, RequesterID: 3, Type: gpu.GPURequestTypeQuota, Status: gpu.GPURequestStatusPending, CreatedAt: now.Add(-48 * time.Hour)})
+
+	sent, err := n.SendDigest(context.Background())
+	if err != nil || sent != 1 || sender.sent[0].To[0] != "ops@example.com" {
+		t.Fatalf("a failed email must not stop the others, got %d %v (%v)", sent, sender.sent, err)
+	}
+}
+
+func TestSendDigestWithNothingPending(t *testing.T) {
+	sender := &recordingSender{}
+	n, _, _ := setupApprovalNotifier(t, sender)
+	if sent, err := n.SendDigest(context.Background()); err != nil || sent != 0 || len(sender.sent) != 0 {
+		t.Fatalf("no email is expected when nothing waits, got %d (%v)", sent, err)
+	}
+}
+
+func TestGPURequestCreatedNotifiesUrgentOnly(t *testing.T) {
+	sender := &recordingSender{}
+	n, _, now := setupApprovalNotifier(t, sender)
+
+	n.GPURequestCreated(context.Background(), gpu.GPURequest{ID: 9, ProjectID: 4, Type: gpu.GPURequestTypeQuota, RequestedQuota: 2, CreatedAt: now})
+	if len(sender.sent) != 0 {
+		t.Fatalf("regular requests wait for the digest, got %d emails", len(sender.sent))
+	}
+	n.GPURequestCreated(context.Background(), gpu.GPURequest{ID: 10, ProjectID: 4, Type: gpu.GPURequestTypeQuota, RequestedQuota: 2, Urgent: true, CreatedAt: now})
+	if len(sender.sent) != 2 || !strings.HasPrefix(sender.sent[0].Subject, "Urgent: ") || !strings.Contains(sender.sent[0].Text, "/admin/gpu-requests/10") {
+		t.Fatalf("urgent requests should be emailed at once, got %+v", sender.sent)
+	}
+}
@@ -0,0 +1,376 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/datatypes"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	ErrBootstrapBundleNotFound = errors.New("bootstrap bundle not found")
+	ErrBootstrapBundleInUse    = errors.New("bootstrap bundle is selected by projects")
+	ErrInvalidBootstrapBundle  = errors.New("invalid bootstrap bundle")
+)
+
+// EnsureMemberNamespace creates the namespace a member of p deploys into when it is missing and
+// applies the bootstrap bundles the project selected. username is empty for the namespace the
+// members of a project share. A bundle failing to apply does not fail the namespace; it is
+// logged and retried the next time the namespace is provisioned.
+func EnsureMemberNamespace(repos *repository.Repos, p *project.Project, ns, username string) error {
+	if err := k8s.EnsureNamespaceExists(ns); err != nil {
+		return err
+	}
+	if err := applyBootstrapBundles(repos.Bootstrap, p.PID, ns, username); err != nil {
+		log.Printf("[Bootstrap] Failed to bootstrap namespace %s: %v", ns, err)
+	}
+	return nil
+}
+
+// applyBootstrapBundles applies every bundle the project selected to ns.
+func applyBootstrapBundles(repo repository.BootstrapBundleRepo, projectID uint, ns, username string) error {
+	if repo == nil {
+		return nil
+	}
+	bundles, err := repo.ListProjectBundles(projectID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range bundles {
+		if err := applyBootstrapBundle(repo, &bundles[i], projectID, ns, username); err != nil {
+			errs = append(errs, fmt.Errorf("bundle %s: %w", bundles[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyBootstrapBundle creates the objects of b missing from ns and replaces those recorded from
+// an older version of b. Objects already recorded at the bundle's version are skipped, so
+// provisioning a namespace again changes nothing. Recorded objects the bundle no longer renders
+// are deleted like a prune. An object of the same name the bundle did not create is left alone.
+func applyBootstrapBundle(repo repository.BootstrapBundleRepo, b *configfile.BootstrapBundle, projectID uint, ns, username string) error {
+	recorded, err := repo.ListObjects(b.ID, ns)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]configfile.BootstrapObject, len(recorded))
+	for _, obj := range recorded {
+		current[obj.Kind+"/"+obj.Name] = obj
+	}
+	docs, err := renderBootstrapBundle(b, projectID, bootstrapValues(projectID, ns, username))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, d := range docs {
+		key := d.kind + "/" + d.name
+		rec, ok := current[key]
+		delete(current, key)
+		if ok && rec.BundleVersion == b.Version {
+			continue
+		}
+		if ok {
+			_, err = k8s.UpdateByJson(d.manifest, ns, d.name)
+		}
+		// Objects deleted since they were recorded are created again
+		if !ok || apierrors.IsNotFound(err) {
+			_, err = k8s.CreateByJson(d.manifest, ns, nil)
+			if apierrors.IsAlreadyExists(err) {
+				log.Printf("[Bootstrap] %s/%s already exists in %s and is not from bundle %s, leaving it", d.kind, d.name, ns, b.Name)
+				continue
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", d.kind, d.name, err))
+			continue
+		}
+		if err := repo.SaveObject(&configfile.BootstrapObject{
+			BundleID:      b.ID,
+			ProjectID:     projectID,
+			Namespace:     ns,
+			Kind:          d.kind,
+			Name:          d.name,
+			Manifest:      datatypes.JSON(d.manifest),
+			BundleVersion: b.Version,
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to record %s/%s: %w", d.kind, d.name, err))
+		}
+	}
+	for _, stale := range current {
+		if _, err := k8s.DeleteByJsonIfExists(stale.Manifest, ns, stale.Name); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", stale.Kind, stale.Name, err))
+			continue
+		}
+		if err := repo.DeleteObject(stale.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to forget %s/%s: %w", stale.Kind, stale.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// bootstrapValues are the placeholders of config files that depend on the namespace only. The
+// username placeholders are left unset in the shared namespace of a project.
+func bootstrapValues(projectID uint, ns, username string) map[string]string {
+	values := map[string]string{
+		"namespace": ns,
+		"projectId": strconv.FormatUint(uint64(projectID), 10),
+	}
+	if username != "" {
+		values["username"] = k8s.ToSafeK8sName(username)
+		values["safeUsername"] = k8s.ToSafeK8sName(username)
+		values["originalUsername"] = username
+	}
+	return values
+}
+
+// bootstrapDoc is one rendered object of a bundle.
+type bootstrapDoc struct {
+	kind     string
+	name     string
+	manifest []byte
+}
+
+// renderBootstrapBundle runs the documents of b through the template and decode steps of the
+// instance pipeline and labels them as objects of the project. Failing documents are reported
+// together as an *InstanceError.
+func renderBootstrapBundle(b *configfile.BootstrapBundle, projectID uint, values map[string]string) ([]bootstrapDoc, error) {
+	resources, err := parseManifests(b.Content)
+	if err != nil {
+		return nil, err
+	}
+	steps := []instanceStep{templateStep{}, decodeStep{}}
+	labels := k8s.Ownership{ProjectID: projectID}.Labels()
+
+	docs := make([]bootstrapDoc, 0, len(resources))
+	var failures []*InstanceStepError
+	for i, res := range resources {
+		dc := &instanceDocContext{Index: i, Resource: string(res.Type) + "/" + res.Name, Values: values}
+		doc, failure := runInstanceSteps(steps, res.ParsedYAML, dc)
+		if failure != nil {
+			failures = append(failures, failure)
+			continue
+		}
+		obj := unstructured.Unstructured{}
+		if err := json.Unmarshal(doc, &obj.Object); err != nil {
+			return nil, err
+		}
+		k8s.ApplyOwnershipLabels(obj.Object, labels)
+		manifest, err := json.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, bootstrapDoc{kind: obj.GetKind(), name: obj.GetName(), manifest: manifest})
+	}
+	if len(failures) > 0 {
+		return nil, &InstanceError{Failures: failures}
+	}
+	return docs, nil
+}
+
+// validateBootstrapContent checks the YAML of a bundle like a config file. Every object must be
+// named, since re-provisioning finds the objects of a bundle by name.
+func validateBootstrapContent(rawYaml string) error {
+	resources, err := parseManifests(rawYaml)
+	if err != nil {
+		return err
+	}
+	for i, res := range resources {
+		var obj unstructured.Unstructured
+		if err := json.Unmarshal(res.ParsedYAML, &obj.Object); err != nil {
+			return err
+		}
+		if obj.GetName() == "" {
+			return fmt.Errorf("%w: document %d (%s) must set metadata.name", ErrInvalidBootstrapBundle, i+1, obj.GetKind())
+		}
+	}
+	return nil
+}
+
+func (s *ConfigFileService) ListBootstrapBundles() ([]configfile.BootstrapBundle, error) {
+	return s.Repos.Bootstrap.ListBundles()
+}
+
+func (s *ConfigFileService) GetBootstrapBundle(id uint) (*configfile.BootstrapBundle, error) {
+	b, err := s.Repos.Bootstrap.GetBundleByID(id)
+	if err != nil {
+		return nil, ErrBootstrapBundleNotFound
+	}
+	return b, nil
+}
+
+func (s *ConfigFileService) CreateBootstrapBundle(c *gin.Context, input configfile.CreateBootstrapBundleInput) (*configfile.BootstrapBundle, error) {
+	if err := validateBootstrapContent(input.RawYaml); err != nil {
+		return nil, err
+	}
+	b := &configfile.BootstrapBundle{
+		Name:        input.Name,
+		Description: input.Description,
+		Content:     input.RawYaml,
+		Version:     1,
+	}
+	if err := s.Repos.Bootstrap.CreateBundle(b); err != nil {
+		return nil, err
+	}
+
+	utils.LogAuditWithConsole(c, "create", "bootstrap_bundle", fmt.Sprintf("bundle_id=%d", b.ID), nil, *b, "", s.Repos.Audit)
+	return b, nil
+}
+
+// UpdateBootstrapBundle applies the changed fields and bumps the version when the content
+// changes. Namespaces already provisioned get the new content the next time they are.
+func (s *ConfigFileService) UpdateBootstrapBundle(c *gin.Context, id uint, input configfile.UpdateBootstrapBundleInput) (*configfile.BootstrapBundle, error) {
+	b, err := s.Repos.Bootstrap.GetBundleByID(id)
+	if err != nil {
+		return nil, ErrBootstrapBundleNotFound
+	}
+	old := *b
+
+	if input.Name != nil {
+		b.Name = *input.Name
+	}
+	if input.Description != nil {
+		b.Description = *input.Description
+	}
+	if input.RawYaml != nil && *input.RawYaml != b.Content {
+		if err := validateBootstrapContent(*input.RawYaml); err != nil {
+			return nil, err
+		}
+		b.Content = *input.RawYaml
+		b.Version++
+	}
+	if err := s.Repos.Bootstrap.UpdateBundle(b); err != nil {
+		return nil, err
+	}
+
+	utils.LogAuditWithConsole(c, "update", "bootstrap_bundle", fmt.Sprintf("bundle_id=%d", b.ID), old, *b, "", s.Repos.Audit)
+	return b, nil
+}
+
+// DeleteBootstrapBundle deletes a bundle no project selects anymore, with the records of the
+// objects it created. Objects left in place by a removal without prune stay in their namespaces.
+func (s *ConfigFileService) DeleteBootstrapBundle(c *gin.Context, id uint) error {
+	b, err := s.Repos.Bootstrap.GetBundleByID(id)
+	if err != nil {
+		return ErrBootstrapBundleNotFound
+	}
+	n, err := s.Repos.Bootstrap.CountBundleProjects(id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: remove it from its %d projects first", ErrBootstrapBundleInUse, n)
+	}
+	if err := s.Repos.Bootstrap.DeleteBundle(id); err != nil {
+		return err
+	}
+
+	utils.LogAuditWithConsole(c, "delete", "bootstrap_bundle", fmt.Sprintf("bundle_id=%d", b.ID), *b, nil, "", s.Repos.Audit)
+	return nil
+}
+
+func (s *ConfigFileService) ListProjectBootstrapBundles(projectID uint) ([]configfile.BootstrapBundle, error) {
+	if _, err := s.Repos.Project.GetProjectByID(projectID); err != nil {
+		return nil, ErrProjectNotFound
+	}
+	return s.Repos.Bootstrap.ListProjectBundles(projectID)
+}
+
+// AddProjectBootstrapBundle selects a bundle for the project and applies it to the member
+// namespaces that exist already; the others get it when they are provisioned.
+func (s *ConfigFileService) AddProjectBootstrapBundle(c *gin.Context, projectID, bundleID uint) error {
+	p, err := s.Repos.Project.GetProjectByID(projectID)
+	if err != nil {
+		return ErrProjectNotFound
+	}
+	b, err := s.Repos.Bootstrap.GetBundleByID(bundleID)
+	if err != nil {
+		return ErrBootstrapBundleNotFound
+	}
+	if err := s.Repos.Bootstrap.AddProjectBundle(projectID, bundleID); err != nil {
+		return err
+	}
+	utils.LogAuditWithConsole(c, "add_bootstrap_bundle", "project", fmt.Sprintf("p_id=%d", projectID), nil, map[string]interface{}{"bundle_id": bundleID, "bundle": b.Name}, "", s.Repos.Audit)
+
+	targets, err := s.memberNamespaceOwners(&p)
+	if err != nil {
+		return err
+	}
+	for ns, username := range targets {
+		if exists, err := k8s.CheckNamespaceExists(ns); err != nil || !exists {
+			continue
+		}
+		if err := applyBootstrapBundle(s.Repos.Bootstrap, b, projectID, ns, username); err != nil {
+			log.Printf("[Bootstrap] Failed to apply bundle %s to %s: %v", b.Name, ns, err)
+		}
+	}
+	return nil
+}
+
+// memberNamespaceOwners returns the namespaces the members of p deploy into, with the member
+// each belongs to; the shared namespace of a project belongs to none.
+func (s *ConfigFileService) memberNamespaceOwners(p *project.Project) (map[string]string, error) {
+	if p.SharesNamespace() {
+		return map[string]string{ProjectStorageNamespace(p): ""}, nil
+	}
+	users, err := s.Repos.User.ListUsersByProjectID(p.PID)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]string, len(users))
+	for _, u := range users {
+		owners[k8s.FormatNamespaceName(p.PID, k8s.ToSafeK8sName(u.Username))] = u.Username
+	}
+	return owners, nil
+}
+
+// RemoveProjectBootstrapBundle stops applying a bundle to the project's namespaces. With prune
+// the objects it created there are deleted too, and their namespace/Kind/name returned;
+// otherwise they stay in place.
+func (s *ConfigFileService) RemoveProjectBootstrapBundle(c *gin.Context, projectID, bundleID uint, prune bool) ([]string, error) {
+	if _, err := s.Repos.Project.GetProjectByID(projectID); err != nil {
+		return nil, ErrProjectNotFound
+	}
+	b, err := s.Repos.Bootstrap.GetBundleByID(bundleID)
+	if err != nil {
+		return nil, ErrBootstrapBundleNotFound
+	}
+	if err := s.Repos.Bootstrap.RemoveProjectBundle(projectID, bundleID); err != nil {
+		return nil, err
+	}
+	utils.LogAuditWithConsole(c, "remove_bootstrap_bundle", "project", fmt.Sprintf("p_id=%d", projectID), map[string]interface{}{"bundle_id": bundleID, "bundle": b.Name}, map[string]interface{}{"prune": prune}, "", s.Repos.Audit)
+
+	deleted := []string{}
+	if !prune {
+		return deleted, nil
+	}
+	objs, err := s.Repos.Bootstrap.ListProjectObjects(projectID, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, obj := range objs {
+		if _, err := k8s.DeleteByJsonIfExists(obj.Manifest, obj.Namespace, obj.Name); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s in %s: %w", obj.Kind, obj.Name, obj.Namespace, err))
+			continue
+		}
+		if err := s.Repos.Bootstrap.DeleteObject(obj.ID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted = append(deleted, obj.Namespace+"/"+obj.Kind+"/"+obj.Name)
+	}
+	return deleted, errors.Join(errs...)
+}
@@ -0,0 +1,187 @@
+package application_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const welcomeBundle = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: welcome
+data:
+  namespace: "{{namespace}}"
+  project: "{{projectId}}"
+  user: "{{username}}"
+  docs: https://docs.example.edu/cluster
+`
+
+func writeActions(actions []k8stesting.Action) []string {
+	var verbs []string
+	for _, a := range actions {
+		if a.GetVerb() != "get" && a.GetVerb() != "list" && a.GetVerb() != "watch" {
+			verbs = append(verbs, a.GetVerb())
+		}
+	}
+	return verbs
+}
+
+var cmGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// setupBootstrapCluster serves ConfigMaps from a fake dynamic client.
+func setupBootstrapCluster(t *testing.T) *dynamicfake.FakeDynamicClient {
+	t.Helper()
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.AddSpecific(cmGVR.GroupVersion().WithKind("ConfigMap"), cmGVR, cmGVR.GroupVersion().WithResource("configmap"), meta.RESTScopeNamespace)
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	origMapper, origDyn := k8s.Mapper, k8s.DynamicClient
+	t.Cleanup(func() { k8s.Mapper, k8s.DynamicClient = origMapper, origDyn })
+	k8s.Mapper, k8s.DynamicClient = mapper, dyn
+	return dyn
+}
+
+func TestBootstrapBundlesAreAppliedOncePerVersion(t *testing.T) {
+	svc, _, _, _, _, mockProject, _, c := setupMocks(t)
+	dyn := setupBootstrapCluster(t)
+
+	p := project.Project{PID: 7, GID: 10}
+	mockProject.EXPECT().GetProjectByID(uint(7)).Return(p, nil).AnyTimes()
+
+	b, err := svc.CreateBootstrapBundle(c, configfile.CreateBootstrapBundleInput{Name: "welcome", RawYaml: welcomeBundle})
+	if err != nil {
+		t.Fatalf("create bundle: %v", err)
+	}
+	if err := svc.Repos.Bootstrap.AddProjectBundle(7, b.ID); err != nil {
+		t.Fatalf("select bundle: %v", err)
+	}
+
+	ns := k8s.FormatNamespaceName(7, "alice")
+	if err := application.EnsureMemberNamespace(svc.Repos, &p, ns, "alice"); err != nil {
+		t.Fatalf("provision namespace: %v", err)
+	}
+	live, err := dyn.Resource(cmGVR).Namespace(ns).Get(context.Background(), "welcome", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the bundle applied to the namespace, got %v", err)
+	}
+	data := live.Object["data"].(map[string]interface{})
+	if data["namespace"] != ns || data["project"] != "7" || data["user"] != "alice" {
+		t.Fatalf("expected the placeholders rendered for the namespace, got %v", data)
+	}
+	if live.GetLabels()[k8s.LabelProjectID] != "7" {
+		t.Fatalf("expected the object labelled with its project, got %v", live.GetLabels())
+	}
+
+	// Provisioning again applies nothing
+	dyn.ClearActions()
+	if err := application.EnsureMemberNamespace(svc.Repos, &p, ns, "alice"); err != nil {
+		t.Fatalf("provision namespace again: %v", err)
+	}
+	if writes := writeActions(dyn.Actions()); len(writes) != 0 {
+		t.Fatalf("expected re-provisioning to be idempotent, got %v", writes)
+	}
+
+	// A new version replaces the object
+	updated := welcomeBundle + "  motd: hello\n"
+	if _, err := svc.UpdateBootstrapBundle(c, b.ID, configfile.UpdateBootstrapBundleInput{RawYaml: &updated}); err != nil {
+		t.Fatalf("update bundle: %v", err)
+	}
+	if err := application.EnsureMemberNamespace(svc.Repos, &p, ns, "alice"); err != nil {
+		t.Fatalf("provision namespace after update: %v", err)
+	}
+	live, _ = dyn.Resource(cmGVR).Namespace(ns).Get(context.Background(), "welcome", metav1.GetOptions{})
+	if live.Object["data"].(map[string]interface{})["motd"] != "hello" {
+		t.Fatalf("expected the new version applied, got %v", live.Object["data"])
+	}
+	objs, _ := svc.Repos.Bootstrap.ListObjects(b.ID, ns)
+	if len(objs) != 1 || objs[0].BundleVersion != 2 {
+		t.Fatalf("expected one object recorded at version 2, got %+v", objs)
+	}
+
+	if err := svc.DeleteBootstrapBundle(c, b.ID); err == nil {
+		t.Fatal("expected a selected bundle not to be deleted")
+	}
+	deleted, err := svc.RemoveProjectBootstrapBundle(c, 7, b.ID, true)
+	if err != nil {
+		t.Fatalf("remove bundle: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != ns+"/ConfigMap/welcome" {
+		t.Fatalf("expected the object pruned, got %v", deleted)
+	}
+	if _, err := dyn.Resource(cmGVR).Namespace(ns).Get(context.Background(), "welcome", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected the ConfigMap deleted")
+	}
+}
+
+// TestBootstrapBundleDropsStaleObjects checks objects a new version no longer has are deleted, and
+// that deleting a bundle removed without prune drops the records of what it left behind.
+func TestBootstrapBundleDropsStaleObjects(t *testing.T) {
+	svc, _, _, _, _, mockProject, _, c := setupMocks(t)
+	dyn := setupBootstrapCluster(t)
+	p := project.Project{PID: 7, GID: 10}
+	mockProject.EXPECT().GetProjectByID(uint(7)).Return(p, nil).AnyTimes()
+
+	rules := welcomeBundle + "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: rules\n"
+	b, err := svc.CreateBootstrapBundle(c, configfile.CreateBootstrapBundleInput{Name: "welcome", RawYaml: rules})
+	if err != nil {
+		t.Fatalf("create bundle: %v", err)
+	}
+	if err := svc.Repos.Bootstrap.AddProjectBundle(7, b.ID); err != nil {
+		t.Fatalf("select bundle: %v", err)
+	}
+	ns := k8s.FormatNamespaceName(7, "alice")
+	if err := application.EnsureMemberNamespace(svc.Repos, &p, ns, "alice"); err != nil {
+		t.Fatalf("provision namespace: %v", err)
+	}
+
+	// Version 2 drops the rules ConfigMap
+	welcome := welcomeBundle
+	if _, err := svc.UpdateBootstrapBundle(c, b.ID, configfile.UpdateBootstrapBundleInput{RawYaml: &welcome}); err != nil {
+		t.Fatalf("update bundle: %v", err)
+	}
+	if err := application.EnsureMemberNamespace(svc.Repos, &p, ns, "alice"); err != nil {
+		t.Fatalf("provision namespace after update: %v", err)
+	}
+	if _, err := dyn.Resource(cmGVR).Namespace(ns).Get(context.Background(), "rules", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected the ConfigMap the new version dropped to be deleted")
+	}
+	objs, _ := svc.Repos.Bootstrap.ListObjects(b.ID, ns)
+	if len(objs) != 1 || objs[0].Name != "welcome" {
+		t.Fatalf("expected only the welcome ConfigMap recorded, got %+v", objs)
+	}
+
+	if _, err := svc.RemoveProjectBootstrapBundle(c, 7, b.ID, false); err != nil {
+		t.Fatalf("remove bundle: %v", err)
+	}
+	if err := svc.DeleteBootstrapBundle(c, b.ID); err != nil {
+		t.Fatalf("delete bundle: %v", err)
+	}
+	if objs, _ := svc.Repos.Bootstrap.ListObjects(b.ID, ns); len(objs) != 0 {
+		t.Fatalf("expected the records of the deleted bundle dropped, got %+v", objs)
+	}
+	if _, err := dyn.Resource(cmGVR).Namespace(ns).Get(context.Background(), "welcome", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected the object left in place without prune, got %v", err)
+	}
+}
+
+func TestBootstrapBundleObjectsMustBeNamed(t *testing.T) {
+	svc, _, _, _, _, _, _, c := setupMocks(t)
+
+	_, err := svc.CreateBootstrapBundle(c, configfile.CreateBootstrapBundleInput{
+		Name:    "unnamed",
+		RawYaml: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  generateName: welcome-\n",
+	})
+	if err == nil {
+		t.Fatal("expected a bundle with an unnamed object to be refused")
+	}
+}
@@ -0,0 +1,246 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/pkg/mail"
+	"github.com/linskybing/platform-go/pkg/types"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrApprovalNotFound       = errors.New("instance approval request not found")
+	ErrApprovalDecided        = errors.New("instance approval request was already decided")
+	ErrApprovalExpired        = errors.New("instance approval request expired")
+	ErrApprovalAlreadyPending = errors.New("an instance of this config file is already waiting for approval")
+)
+
+// requestInstanceApproval stores the rendered documents of an instance for a project manager to
+// review instead of applying them.
+func (s *ConfigFileService) requestInstanceApproval(c *gin.Context, cf *configfile.ConfigFile, resources []resource.Resource, rendered *instanceRender) (*configfile.InstanceApprovalRequest, error) {
+	claims := rendered.claims
+	pending, err := s.Repos.Approval.FindPending(cf.CFID, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("%w: request %d", ErrApprovalAlreadyPending, pending.ID)
+	}
+
+	docs := make([]configfile.ApprovalDocument, len(rendered.objects))
+	for i, obj := range rendered.objects {
+		docs[i] = configfile.ApprovalDocument{ResourceID: resources[i].RID, Resource: rendered.refs[i], Object: obj}
+	}
+	documents, err := json.Marshal(docs)
+	if err != nil {
+		return nil, err
+	}
+	req := &configfile.InstanceApprovalRequest{
+		ConfigFileID:        cf.CFID,
+		ProjectID:           cf.ProjectID,
+		RequesterID:         claims.UserID,
+		RequesterUsername:   claims.Username,
+		Namespace:           rendered.namespace,
+		Documents:           documents,
+		UsesHarborImage:     rendered.usesHarborImage,
+		UsesProjectRegistry: rendered.usesProjectRegistry,
+		Status:              configfile.ApprovalStatusPending,
+		ExpiresAt:           time.Now().Add(config.DurationSetting(config.SettingInstanceApprovalTTL)),
+	}
+	if err := s.Repos.Approval.Create(req); err != nil {
+		return nil, err
+	}
+	utils.LogAuditWithConsole(c, "request", "instance_approval", fmt.Sprintf("id=%d", req.ID), nil, *req, "", s.Repos.Audit)
+	return req, nil
+}
+
+// ListInstanceApprovals lists the approval requests of a project, newest first; an empty status
+// lists them all.
+func (s *ConfigFileService) ListInstanceApprovals(projectID uint, status string) ([]configfile.InstanceApprovalRequest, error) {
+	return s.Repos.Approval.ListByProject(projectID, status)
+}
+
+// pendingApproval returns the request id of the project if it can still be decided on. A request
+// found past its expiry is expired on the spot.
+func (s *ConfigFileService) pendingApproval(projectID, id uint) (*configfile.InstanceApprovalRequest, error) {
+	req, err := s.Repos.Approval.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && req.ProjectID != projectID) {
+		return nil, ErrApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != configfile.ApprovalStatusPending {
+		return nil, fmt.Errorf("%w: it is %s", ErrApprovalDecided, req.Status)
+	}
+	if now := time.Now(); now.After(req.ExpiresAt) {
+		if _, err := s.expireApproval(req, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrApprovalExpired
+	}
+	return req, nil
+}
+
+// ApproveInstance applies the documents of a pending request as they were rendered for the
+// requester: into the requester's namespace, owned by the requester and checked against the
+// current ConfigMap and Secret limits of that namespace. When the apply fails the request is
+// pending again, so it can be retried or rejected.
+func (s *ConfigFileService) ApproveInstance(c *gin.Context, projectID, id uint) (*configfile.InstanceApprovalRequest, error) {
+	req, err := s.pendingApproval(projectID, id)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := s.Repos.ConfigFile.GetConfigFileByID(req.ConfigFileID)
+	if err != nil {
+		return nil, ErrConfigFileNotFound
+	}
+	reviewer, _ := c.MustGet("claims").(*types.Claims)
+
+	// Claim the request first, so two managers approving at once do not both apply it
+	old := *req
+	now := time.Now()
+	req.Status, req.ReviewerID, req.DecidedAt = configfile.ApprovalStatusApproved, &reviewer.UserID, &now
+	if ok, err := s.Repos.Approval.Decide(req); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrApprovalDecided
+	}
+
+	if err := s.applyApprovedInstance(cf, req); err != nil {
+		if rerr := s.Repos.Approval.Reopen(req.ID); rerr != nil {
+			log.Printf("[Warning] Failed to reopen instance approval %d after its apply failed: %v", req.ID, rerr)
+		}
+		return nil, err
+	}
+
+	utils.LogAuditWithConsole(c, "approve", "instance_approval", fmt.Sprintf("id=%d", req.ID), old, *req, "", s.Repos.Audit)
+	// The deployment itself is the requester's, as if they had created it
+	if err := utils.LogAudit(req.RequesterID, c.ClientIP(), c.GetHeader("User-Agent"), "create", "instance", fmt.Sprintf("cf_id=%d", cf.CFID),
+		nil, req.DecodeDocuments(), fmt.Sprintf("approved by %s in request %d", reviewer.Username, req.ID), s.Repos.Audit); err != nil {
+		log.Printf("[Warning] Failed to audit the approved instance %d: %v", req.ID, err)
+	}
+	s.notifyRequester(req, fmt.Sprintf("was approved by %s and deployed to %s.", reviewer.Username, req.Namespace))
+	return req, nil
+}
+
+// applyApprovedInstance deploys the stored documents of req under the identity of its requester.
+func (s *ConfigFileService) applyApprovedInstance(cf *configfile.ConfigFile, req *configfile.InstanceApprovalRequest) error {
+	docs := req.DecodeDocuments()
+	rendered := &instanceRender{
+		namespace:           req.Namespace,
+		claims:              &types.Claims{UserID: req.RequesterID, Username: req.RequesterUsername},
+		objects:             make([][]byte, len(docs)),
+		refs:                make([]string, len(docs)),
+		usesHarborImage:     req.UsesHarborImage,
+		usesProjectRegistry: req.UsesProjectRegistry,
+	}
+	rIDs := make([]uint, len(docs))
+	for i, doc := range docs {
+		rendered.objects[i], rendered.refs[i], rIDs[i] = doc.Object, doc.Resource, doc.ResourceID
+	}
+	// Credentials are not part of the review; the current ones are used
+	if rendered.usesProjectRegistry {
+		auths, err := projectRegistryAuths(s.Repos.Registry, cf.ProjectID)
+		if err != nil {
+			return err
+		}
+		rendered.registryAuths = auths
+	}
+	if err := s.checkInstanceDataLimits(rendered.namespace, rendered.claims, rendered.objects); err != nil {
+		return err
+	}
+	return s.deployInstance(cf, rendered, rIDs)
+}
+
+// RejectInstance turns down a pending request, leaving the requester note.
+func (s *ConfigFileService) RejectInstance(c *gin.Context, projectID, id uint, note string) (*configfile.InstanceApprovalRequest, error) {
+	req, err := s.pendingApproval(projectID, id)
+	if err != nil {
+		return nil, err
+	}
+	reviewer, _ := c.MustGet("claims").(*types.Claims)
+
+	old := *req
+	now := time.Now()
+	req.Status, req.ReviewerID, req.Note, req.DecidedAt = configfile.ApprovalStatusRejected, &reviewer.UserID, note, &now
+	if ok, err := s.Repos.Approval.Decide(req); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrApprovalDecided
+	}
+	utils.LogAuditWithConsole(c, "reject", "instance_approval", fmt.Sprintf("id=%d", req.ID), old, *req, note, s.Repos.Audit)
+
+	outcome := fmt.Sprintf("was rejected by %s.", reviewer.Username)
+	if note != "" {
+		outcome = fmt.Sprintf("was rejected by %s:\n\n%s", reviewer.Username, note)
+	}
+	s.notifyRequester(req, outcome)
+	return req, nil
+}
+
+// ExpireInstanceApprovals expires the pending requests no manager decided on within
+// config.SettingInstanceApprovalTTL and tells their requesters.
+func (s *ConfigFileService) ExpireInstanceApprovals() (int, error) {
+	now := time.Now()
+	reqs, err := s.Repos.Approval.ListExpired(now)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for i := range reqs {
+		ok, err := s.expireApproval(&reqs[i], now)
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// expireApproval expires req unless someone decided on it meanwhile, and reports whether it did.
+func (s *ConfigFileService) expireApproval(req *configfile.InstanceApprovalRequest, now time.Time) (bool, error) {
+	req.Status, req.DecidedAt = configfile.ApprovalStatusExpired, &now
+	ok, err := s.Repos.Approval.Decide(req)
+	if err != nil || !ok {
+		return false, err
+	}
+	s.notifyRequester(req, "expired before a project manager reviewed it. Deploy it again to request a new review.")
+	return true, nil
+}
+
+// notifyRequester emails the requester of req the outcome of their instance. Failed emails are logged.
+func (s *ConfigFileService) notifyRequester(req *configfile.InstanceApprovalRequest, outcome string) {
+	if s.sender == nil {
+		return
+	}
+	requester, err := s.Repos.User.GetUserRawByID(req.RequesterID)
+	if err != nil || requester.Email == nil || *requester.Email == "" {
+		return
+	}
+	name := fmt.Sprintf("config file %d", req.ConfigFileID)
+	if cf, err := s.Repos.ConfigFile.GetConfigFileByID(req.ConfigFileID); err == nil {
+		name = cf.Filename
+	}
+	link := config.PlatformURL + fmt.Sprintf("/config-files/%d", req.ConfigFileID)
+	text := fmt.Sprintf("Hello %s,\n\nYour instance of %s %s\n\n%s\n", requester.Username, name, outcome, link)
+	msg := mail.Message{To: []string{*requester.Email}, Subject: fmt.Sprintf("Your instance of %s was %s", name, req.Status), Text: text}
+
+	ctx, cancel := context.WithTimeout(context.Background(), approvalSendTimeout)
+	defer cancel()
+	if err := s.sender.Send(ctx, msg); err != nil {
+		log.Printf("Failed to notify %s about instance approval %d: %v", requester.Username, req.ID, err)
+	}
+}
@@ -0,0 +1,158 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/datatypes"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func managerContext(userID uint, username string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("POST", "/", nil)
+	c.Set("claims", &types.Claims{Username: username, UserID: userID})
+	return c
+}
+
+func TestApprovedInstanceAppliesTheReviewedDocuments(t *testing.T) {
+	svc, mockCF, mockRes, _, _, mockProject, mockUserGroup, c := setupMocks(t)
+	origLogAudit := utils.LogAudit
+	t.Cleanup(func() { utils.LogAudit = origLogAudit })
+	var auditedUser uint
+	utils.LogAudit = func(userID uint, ip, ua, action, resourceType, resourceID string, before, after any, description string, repos repository.AuditRepo) error {
+		auditedUser = userID
+		return nil
+	}
+
+	cmGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.AddSpecific(cmGVR.GroupVersion().WithKind("ConfigMap"), cmGVR, cmGVR.GroupVersion().WithResource("configmap"), meta.RESTScopeNamespace)
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	origMapper, origDyn := k8s.Mapper, k8s.DynamicClient
+	t.Cleanup(func() { k8s.Mapper, k8s.DynamicClient = origMapper, origDyn })
+	k8s.Mapper, k8s.DynamicClient = mapper, dyn
+
+	docs := []resource.Resource{
+		{RID: 4, Type: "ConfigMap", Name: "lab", ParsedYAML: datatypes.JSON(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"lab"},"data":{"user":"{{username}}"}}`)},
+	}
+	// Rendered at the two submissions only: the approval must not render the config file again
+	mockRes.EXPECT().ListResourcesByConfigFileID(uint(1)).Return(docs, nil).Times(2)
+	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{CFID: 1, ProjectID: 1, Filename: "lab.yaml"}, nil).AnyTimes()
+	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10, RequireInstanceApproval: true}, nil).AnyTimes()
+	mockUserGroup.EXPECT().GetUserGroup(uint(1), uint(10)).Return(group.UserGroup{UID: 1, GID: 10, Role: "user"}, nil).AnyTimes()
+
+	req, err := svc.CreateInstance(c, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req == nil || req.Status != configfile.ApprovalStatusPending || req.RequesterID != 1 {
+		t.Fatalf("expected a pending request of the member, got %+v", req)
+	}
+	if len(dyn.Actions()) != 0 {
+		t.Fatalf("expected nothing applied before the approval, got %v", dyn.Actions())
+	}
+	if _, err := svc.CreateInstance(c, 1); !errors.Is(err, application.ErrApprovalAlreadyPending) {
+		t.Fatalf("expected a second submission to be refused, got %v", err)
+	}
+
+	stored := req.DecodeDocuments()
+	if len(stored) != 1 || stored[0].ResourceID != 4 || stored[0].Resource != "ConfigMap/lab" {
+		t.Fatalf("unexpected snapshot %+v", stored)
+	}
+	var reviewed map[string]interface{}
+	if err := json.Unmarshal(stored[0].Object, &reviewed); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+
+	approved, err := svc.ApproveInstance(managerContext(2, "ta"), 1, req.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if approved.Status != configfile.ApprovalStatusApproved || approved.ReviewerID == nil || *approved.ReviewerID != 2 {
+		t.Fatalf("unexpected decision %+v", approved)
+	}
+
+	ns := k8s.FormatNamespaceName(1, "testuser")
+	live, err := dyn.Resource(cmGVR).Namespace(ns).Get(context.Background(), "lab", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the ConfigMap in the requester's namespace, got %v", err)
+	}
+	if !reflect.DeepEqual(live.Object["data"], reviewed["data"]) || live.Object["data"].(map[string]interface{})["user"] != "testuser" {
+		t.Fatalf("expected the reviewed data %v, got %v", reviewed["data"], live.Object["data"])
+	}
+	if owner := live.GetLabels()[k8s.LabelUserID]; owner != "1" {
+		t.Fatalf("expected the object owned by the requester, got user %q", owner)
+	}
+	if auditedUser != 1 {
+		t.Fatalf("expected the deployment audited as the requester, got user %d", auditedUser)
+	}
+	if recorded, _ := svc.Repos.DeployedObject.ListByNamespace(ns, []uint{4}); len(recorded) != 1 {
+		t.Fatalf("expected the deployed object to be recorded, got %+v", recorded)
+	}
+	if _, err := svc.ApproveInstance(managerContext(2, "ta"), 1, req.ID); !errors.Is(err, application.ErrApprovalDecided) {
+		t.Fatalf("expected a decided request not to apply twice, got %v", err)
+	}
+}
+
+func TestInstanceApprovalsExpire(t *testing.T) {
+	svc, mockCF, _, _, _, _, _, _ := setupMocks(t)
+	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{CFID: 1, ProjectID: 1}, nil).AnyTimes()
+
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	newRequest := func(requester uint, expiresAt time.Time) *configfile.InstanceApprovalRequest {
+		req := &configfile.InstanceApprovalRequest{ConfigFileID: 1, ProjectID: 1, RequesterID: requester, RequesterUsername: "u",
+			Namespace: "proj-1-u", Status: configfile.ApprovalStatusPending, ExpiresAt: expiresAt}
+		if err := svc.Repos.Approval.Create(req); err != nil {
+			t.Fatalf("create request: %v", err)
+		}
+		return req
+	}
+	stale, fresh, older := newRequest(1, past), newRequest(2, future), newRequest(3, past.Add(-time.Hour))
+
+	n, err := svc.ExpireInstanceApprovals()
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 expired requests, got %d (%v)", n, err)
+	}
+	for id, want := range map[uint]string{stale.ID: configfile.ApprovalStatusExpired, older.ID: configfile.ApprovalStatusExpired, fresh.ID: configfile.ApprovalStatusPending} {
+		got, err := svc.Repos.Approval.GetByID(id)
+		if err != nil || got.Status != want {
+			t.Fatalf("request %d: expected %s, got %+v (%v)", id, want, got, err)
+		}
+	}
+	if _, err := svc.ApproveInstance(managerContext(2, "ta"), 1, stale.ID); !errors.Is(err, application.ErrApprovalDecided) {
+		t.Fatalf("expected an expired request not to be approvable, got %v", err)
+	}
+
+	// A request past its expiry is expired when a manager gets to it before the sweep
+	late := newRequest(4, past)
+	if _, err := svc.RejectInstance(managerContext(2, "ta"), 1, late.ID, "too late"); !errors.Is(err, application.ErrApprovalExpired) {
+		t.Fatalf("expected ErrApprovalExpired, got %v", err)
+	}
+	if got, _ := svc.Repos.Approval.GetByID(late.ID); got.Status != configfile.ApprovalStatusExpired {
+		t.Fatalf("expected the late request to be expired, got %s", got.Status)
+	}
+	if _, err := svc.ApproveInstance(managerContext(2, "ta"), 2, fresh.ID); !errors.Is(err, application.ErrApprovalNotFound) {
+		t.Fatalf("expected requests of another project to be hidden, got %v", err)
+	}
+}
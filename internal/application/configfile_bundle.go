@@ -0,0 +1,245 @@
+package application
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	imageref "github.com/linskybing/platform-go/pkg/image"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+var ErrInvalidConfigFileBundle = errors.New("invalid config file bundle")
+
+// What an import does with a file whose filename the target project already uses.
+const (
+	ImportSkip   = "skip"
+	ImportRename = "rename"
+)
+
+// Outcomes of one file of an import.
+const (
+	ImportStatusValid   = "valid"
+	ImportStatusCreated = "created"
+	ImportStatusSkipped = "skipped"
+	ImportStatusInvalid = "invalid"
+)
+
+// ConfigFileImportResult is the outcome of one file of a bundle.
+type ConfigFileImportResult struct {
+	Filename string `json:"filename"`
+	// CreatedAs is the filename in the target project when the file was renamed
+	CreatedAs    string `json:"created_as,omitempty"`
+	ConfigFileID uint   `json:"config_file_id,omitempty"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	// Errors lists the YAML problems per document when Status is invalid
+	Errors   []utils.YAMLError `json:"errors,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+	// ImagesToRequest are the images the target project must request before instances of the
+	// file can start
+	ImagesToRequest []string `json:"images_to_request,omitempty"`
+}
+
+// ConfigFileImportReport is the outcome of importing a bundle into a project.
+type ConfigFileImportReport struct {
+	DryRun  bool                     `json:"dry_run"`
+	Created int                      `json:"created"`
+	Skipped int                      `json:"skipped"`
+	Invalid int                      `json:"invalid"`
+	Files   []ConfigFileImportResult `json:"files"`
+}
+
+// ExportConfigFiles returns the config files of the project as a bundle another project can
+// import.
+func (s *ConfigFileService) ExportConfigFiles(projectID uint) (*configfile.ConfigFileBundle, error) {
+	proj, err := s.Repos.Project.GetProjectByID(projectID)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	files, err := s.Repos.ConfigFile.GetConfigFilesByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &configfile.ConfigFileBundle{Project: proj.ProjectName, Files: make([]configfile.ConfigFileExport, 0, len(files))}
+	for _, cf := range files {
+		bundle.Files = append(bundle.Files, configfile.ConfigFileExport{Filename: cf.Filename, RawYaml: cf.Content})
+	}
+	return bundle, nil
+}
+
+// ImportConfigFiles creates the files of bundle in the project through the checks of a new
+// config file. Each file is reported on its own, so one invalid file does not stop the others.
+// A file whose filename is taken is skipped or, with onConflict ImportRename, created under the
+// first free "<name>-<n><ext>". With dryRun nothing is created.
+func (s *ConfigFileService) ImportConfigFiles(c *gin.Context, projectID uint, bundle configfile.ConfigFileBundle, onConflict string, dryRun bool) (*ConfigFileImportReport, error) {
+	if onConflict == "" {
+		onConflict = ImportSkip
+	}
+	if onConflict != ImportSkip && onConflict != ImportRename {
+		return nil, fmt.Errorf("%w: on_conflict must be skip or rename", ErrInvalidConfigFileBundle)
+	}
+	if len(bundle.Files) == 0 {
+		return nil, fmt.Errorf("%w: the bundle has no files", ErrInvalidConfigFileBundle)
+	}
+	if _, err := s.Repos.Project.GetProjectByID(projectID); err != nil {
+		return nil, ErrProjectNotFound
+	}
+	existing, err := s.Repos.ConfigFile.GetConfigFilesByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool, len(existing)+len(bundle.Files))
+	for _, cf := range existing {
+		taken[cf.Filename] = true
+	}
+
+	report := &ConfigFileImportReport{DryRun: dryRun, Files: make([]ConfigFileImportResult, 0, len(bundle.Files))}
+	for _, f := range bundle.Files {
+		result := s.importConfigFile(c, projectID, f, taken, onConflict, dryRun)
+		switch result.Status {
+		case ImportStatusCreated:
+			report.Created++
+		case ImportStatusSkipped:
+			report.Skipped++
+		case ImportStatusInvalid:
+			report.Invalid++
+		}
+		report.Files = append(report.Files, result)
+	}
+	return report, nil
+}
+
+func (s *ConfigFileService) importConfigFile(c *gin.Context, projectID uint, f configfile.ConfigFileExport, taken map[string]bool, onConflict string, dryRun bool) ConfigFileImportResult {
+	result := ConfigFileImportResult{Filename: f.Filename}
+	invalid := func(err error) ConfigFileImportResult {
+		result.Status, result.Error = ImportStatusInvalid, err.Error()
+		var yamlErr *YAMLValidationError
+		if errors.As(err, &yamlErr) {
+			result.Errors = yamlErr.Errors
+		}
+		return result
+	}
+	if len(f.RawYaml) > config.ConfigFileMaxContentBytes {
+		return invalid(fmt.Errorf("raw_yaml is %d bytes, exceeding the %d byte limit", len(f.RawYaml), config.ConfigFileMaxContentBytes))
+	}
+
+	cf := &configfile.ConfigFile{Filename: f.Filename, Content: f.RawYaml, ProjectID: projectID}
+	resources, warnings, err := s.validateNewConfigFile(c, cf)
+	if err != nil {
+		return invalid(err)
+	}
+	result.Warnings = warnings
+	result.ImagesToRequest = s.imagesToRequest(projectID, parsedResourceObjects(resources))
+
+	if taken[cf.Filename] {
+		if onConflict == ImportSkip {
+			result.Status = ImportStatusSkipped
+			result.Error = "a config file with this filename already exists"
+			return result
+		}
+		cf.Filename = freeFilename(cf.Filename, taken)
+	}
+	if cf.Filename != f.Filename {
+		result.CreatedAs = cf.Filename
+	}
+	taken[cf.Filename] = true
+
+	if dryRun {
+		result.Status = ImportStatusValid
+		return result
+	}
+	created, err := s.createConfigFile(c, cf)
+	if err != nil {
+		return invalid(err)
+	}
+	result.Status, result.ConfigFileID = ImportStatusCreated, created.CFID
+	return result
+}
+
+// freeFilename returns "<name>-<n><ext>" for the lowest n from 2 that is not taken.
+func freeFilename(filename string, taken map[string]bool) string {
+	ext := path.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// imagesToRequest returns the container images of objs that are not allowed for the project,
+// which is what launching an instance checks for members. Images that do not parse, such as
+// template placeholders, are left out.
+func (s *ConfigFileService) imagesToRequest(projectID uint, objs []map[string]interface{}) []string {
+	var missing []string
+	for _, obj := range objs {
+		for _, spec := range findPodSpecs(obj) {
+			for _, cont := range getContainersFromPodSpec(spec) {
+				img, _ := cont["image"].(string)
+				ref, err := imageref.Parse(img)
+				if img == "" || err != nil || slices.Contains(missing, ref.String()) {
+					continue
+				}
+				if allowed, err := s.imageService.ValidateImageForProject(ref.FamiliarName(), ref.Version(), &projectID); err == nil && !allowed {
+					missing = append(missing, ref.String())
+				}
+			}
+		}
+	}
+	return missing
+}
+
+// WriteConfigFileBundleTar writes bundle as a tar archive holding one entry per file, named by
+// its filename.
+func WriteConfigFileBundleTar(w io.Writer, bundle *configfile.ConfigFileBundle) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, f := range bundle.Files {
+		hdr := &tar.Header{Name: f.Filename, Mode: 0o644, Size: int64(len(f.RawYaml)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(tw, f.RawYaml); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ReadConfigFileBundleTar reads a bundle written by WriteConfigFileBundleTar. Entries other than
+// regular files are ignored; larger ones than a config file may hold are rejected.
+func ReadConfigFileBundleTar(r io.Reader) (*configfile.ConfigFileBundle, error) {
+	bundle := &configfile.ConfigFileBundle{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfigFileBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Size > int64(config.ConfigFileMaxContentBytes) {
+			return nil, fmt.Errorf("%w: %s is %d bytes, exceeding the %d byte limit", ErrInvalidConfigFileBundle, hdr.Name, hdr.Size, config.ConfigFileMaxContentBytes)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfigFileBundle, err)
+		}
+		bundle.Files = append(bundle.Files, configfile.ConfigFileExport{Filename: hdr.Name, RawYaml: string(content)})
+	}
+	return bundle, nil
+}
@@ -0,0 +1,139 @@
+package application
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/domain/project"
+)
+
+const (
+	trainYAML = `apiVersion: v1
+kind: Pod
+metadata:
+  name: train
+spec:
+  containers:
+  - name: main
+    image: pytorch/pytorch:2.3
+`
+	serveYAML = `apiVersion: v1
+kind: Pod
+metadata:
+  name: serve
+spec:
+  containers:
+  - name: main
+    image: nginx:1.25
+`
+)
+
+// TestConfigFileBundleRoundTrip exports a project into a fresh one: pytorch is allowed for every
+// project, nginx only for the exported one.
+func TestConfigFileBundleRoundTrip(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+	if err := db.AutoMigrate(&image.ContainerRepository{}, &image.ContainerTag{}, &image.ImageAllowList{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	db.Create(&project.Project{PID: 1, ProjectName: "lab-2025", GID: 1})
+	db.Create(&project.Project{PID: 2, ProjectName: "lab-2026", GID: 1})
+	torch := image.ContainerRepository{Name: "pytorch", Namespace: "pytorch", FullName: "pytorch/pytorch"}
+	nginx := image.ContainerRepository{Name: "nginx", Namespace: "library", FullName: "nginx"}
+	db.Create(&torch)
+	db.Create(&nginx)
+	source := uint(1)
+	db.Create(&image.ImageAllowList{RepositoryID: torch.ID, IsEnabled: true})
+	db.Create(&image.ImageAllowList{RepositoryID: nginx.ID, ProjectID: &source, IsEnabled: true})
+
+	for name, content := range map[string]string{"train.yaml": trainYAML, "serve.yaml": serveYAML} {
+		if _, err := svc.CreateConfigFile(c, configfile.CreateConfigFileInput{Filename: name, RawYaml: content, ProjectID: 1}); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+	}
+
+	exported, err := svc.ExportConfigFiles(1)
+	if err != nil || exported.Project != "lab-2025" || len(exported.Files) != 2 {
+		t.Fatalf("expected both files of lab-2025, got %+v (%v)", exported, err)
+	}
+	var buf bytes.Buffer
+	if err := WriteConfigFileBundleTar(&buf, exported); err != nil {
+		t.Fatalf("WriteConfigFileBundleTar: %v", err)
+	}
+	bundle, err := ReadConfigFileBundleTar(&buf)
+	if err != nil || len(bundle.Files) != 2 {
+		t.Fatalf("expected the tar to hold both files, got %+v (%v)", bundle, err)
+	}
+
+	dry, err := svc.ImportConfigFiles(c, 2, *bundle, "", true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	for _, f := range dry.Files {
+		if f.Status != ImportStatusValid {
+			t.Fatalf("expected %s to be valid, got %+v", f.Filename, f)
+		}
+		wantImages := 0
+		if f.Filename == "serve.yaml" {
+			wantImages = 1
+		}
+		if len(f.ImagesToRequest) != wantImages {
+			t.Fatalf("%s: expected %d images to request, got %v", f.Filename, wantImages, f.ImagesToRequest)
+		}
+	}
+	if files, _ := svc.ListConfigFilesByProjectID(2); len(files) != 0 {
+		t.Fatalf("expected the dry run to create nothing, got %d files", len(files))
+	}
+
+	report, err := svc.ImportConfigFiles(c, 2, *bundle, ImportSkip, false)
+	if err != nil || report.Created != 2 {
+		t.Fatalf("expected both files created, got %+v (%v)", report, err)
+	}
+	files, _ := svc.ListConfigFilesByProjectID(2)
+	contents := map[string]string{}
+	for _, f := range files {
+		contents[f.Filename] = f.Content
+	}
+	if contents["train.yaml"] != trainYAML || contents["serve.yaml"] != serveYAML {
+		t.Fatalf("expected the imported files to match the export, got %v", contents)
+	}
+}
+
+func TestImportConfigFilesCollisions(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+	db.Create(&project.Project{PID: 2, ProjectName: "lab-2026", GID: 1})
+	if _, err := svc.CreateConfigFile(c, configfile.CreateConfigFileInput{Filename: "train.yaml", RawYaml: trainYAML, ProjectID: 2}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	bundle := configfile.ConfigFileBundle{Files: []configfile.ConfigFileExport{
+		{Filename: "train.yaml", RawYaml: trainYAML},
+		{Filename: "broken.yaml", RawYaml: "kind: Pod\n"},
+	}}
+
+	skipped, err := svc.ImportConfigFiles(c, 2, bundle, ImportSkip, false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if skipped.Skipped != 1 || skipped.Invalid != 1 || skipped.Created != 0 {
+		t.Fatalf("expected one skipped and one invalid file, got %+v", skipped)
+	}
+	if skipped.Files[1].Status != ImportStatusInvalid || skipped.Files[1].Error == "" {
+		t.Fatalf("expected the broken file to be reported, got %+v", skipped.Files[1])
+	}
+
+	for _, want := range []string{"train-2.yaml", "train-3.yaml"} {
+		renamed, err := svc.ImportConfigFiles(c, 2, bundle, ImportRename, false)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		if got := renamed.Files[0]; got.Status != ImportStatusCreated || got.CreatedAs != want {
+			t.Fatalf("expected train.yaml created as %s, got %+v", want, got)
+		}
+	}
+
+	if _, err := svc.ImportConfigFiles(c, 2, bundle, "overwrite", false); !errors.Is(err, ErrInvalidConfigFileBundle) {
+		t.Fatalf("expected ErrInvalidConfigFileBundle for an unknown strategy, got %v", err)
+	}
+}
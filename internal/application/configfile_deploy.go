@@ -1,36 +1,193 @@
 package application
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/configfile"
 	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
 	"github.com/linskybing/platform-go/pkg/k8s"
 	"github.com/linskybing/platform-go/pkg/types"
-	"github.com/linskybing/platform-go/pkg/utils"
-	"gorm.io/datatypes"
+	corev1 "k8s.io/api/core/v1"
 )
 
-// CreateInstance deploys resources to Kubernetes with a high-performance pipeline.
-func (s *ConfigFileService) CreateInstance(c *gin.Context, id uint) error {
+// RenderedInstance is the manifest set CreateInstance would apply for a config file, with all
+// platform patches applied.
+type RenderedInstance struct {
+	Namespace string                   `json:"namespace"`
+	Objects   []map[string]interface{} `json:"objects"`
+	// InjectedResources lists the CPU and memory defaults given to containers that set none
+	InjectedResources []InjectedResources `json:"injected_resources"`
+	// ExternalChanges lists the fields of the live objects owned outside the platform, which
+	// the next instance update does not know about
+	ExternalChanges []k8s.ObjectDrift `json:"external_changes"`
+}
+
+// instanceRender is the outcome of the patch pipeline for one namespace.
+type instanceRender struct {
+	namespace string
+	claims    *types.Claims
+	objects   [][]byte
+	// refs holds the Kind/name of each object
+	refs            []string
+	usesHarborImage bool
+	// registryAuths are the project's registry credentials, needed when usesProjectRegistry
+	registryAuths       []k8s.RegistryAuth
+	usesProjectRegistry bool
+	injectedResources   []InjectedResources
+	// requiresApproval is set when the project reviews the caller's instances before they are applied
+	requiresApproval bool
+}
+
+// CreateInstance deploys the resources of a config file to the caller's namespace. Every document
+// goes through the steps of instanceSteps and the instance-wide checks before the first one is
+// created. A failing document is reported as an *InstanceError naming it and the step. In projects
+// reviewing their members' instances nothing is applied: the returned request holds the rendered
+// documents until a project manager decides on it.
+func (s *ConfigFileService) CreateInstance(c *gin.Context, id uint) (*configfile.InstanceApprovalRequest, error) {
 	// 1. Fetch Data
 	resources, err := s.Repos.Resource.ListResourcesByConfigFileID(id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	cf, err := s.Repos.ConfigFile.GetConfigFileByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUserStorageHealth(c); err != nil {
+		return nil, err
+	}
+
+	// 2-5. Prepare the namespace and volumes, then patch every resource
+	rendered, err := s.renderInstance(c.Request.Context(), c, cf, resources, false)
+	if err != nil {
+		return nil, err
+	}
+	ns, claims := rendered.namespace, rendered.claims
+
+	// Files uploaded by an admin may still hold host access a regular member must not deploy
+	if err := s.checkInstancePodSecurity(c, cf.ProjectID, rendered.objects); err != nil {
+		return nil, err
+	}
+
+	// 6. Enforce ConfigMap/Secret limits on the final objects, including what the namespace already holds
+	if err := s.checkInstanceDataLimits(ns, claims, rendered.objects); err != nil {
+		return nil, err
+	}
+
+	// Projects reviewing their members' instances keep what was rendered for a manager
+	if rendered.requiresApproval {
+		return s.requestInstanceApproval(c, cf, resources, rendered)
+	}
+
+	rIDs := make([]uint, len(resources))
+	for i, res := range resources {
+		rIDs[i] = res.RID
+	}
+	return nil, s.deployInstance(cf, rendered, rIDs)
+}
+
+// deployInstance provides the pull secrets of a rendered instance and applies it, recording the
+// names its objects got under the resource IDs in rIDs, one per object.
+func (s *ConfigFileService) deployInstance(cf *configfile.ConfigFile, rendered *instanceRender, rIDs []uint) error {
+	ns := rendered.namespace
+
+	// 7. Pods pulling from Harbor need the registry credentials in the target namespace
+	if rendered.usesHarborImage {
+		if err := ensureImagePullSecret(context.Background(), ns); err != nil {
+			return err
+		}
+	}
+
+	// Images from the project's private registries need the project pull secret
+	if rendered.usesProjectRegistry {
+		if err := ensureProjectPullSecret(context.Background(), ns, cf.ProjectID, rendered.registryAuths); err != nil {
+			return err
+		}
+	}
+
+	// 8. Apply to Kubernetes, all or nothing
+	log.Printf("Deploying %d resources to namespace %s", len(rendered.objects), ns)
+	owner := k8s.Ownership{ProjectID: cf.ProjectID, UserID: rendered.claims.UserID, ConfigFileID: cf.CFID}.Labels()
+	created, err := applyInstance(rendered, owner)
 	if err != nil {
 		return err
 	}
 
+	// 9. Remember the names the objects got, so deleting the instance finds generated names
+	deployed := make([]resource.DeployedObject, 0, len(created))
+	for i, obj := range created {
+		if obj.GetName() != "" {
+			deployed = append(deployed, resource.DeployedObject{RID: rIDs[i], Namespace: ns, Name: obj.GetName()})
+		}
+	}
+	if err := s.Repos.DeployedObject.Save(deployed); err != nil {
+		rollbackInstance(rendered, created)
+		return fmt.Errorf("failed to record the deployed objects: %w", err)
+	}
+	return nil
+}
+
+// RenderInstance returns the manifests CreateInstance would apply for the caller, including the
+// injected env defaults and scheduling policy, without changing anything in the cluster.
+func (s *ConfigFileService) RenderInstance(c *gin.Context, id uint) (*RenderedInstance, error) {
+	resources, err := s.Repos.Resource.ListResourcesByConfigFileID(id)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := s.Repos.ConfigFile.GetConfigFileByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := s.renderInstance(c.Request.Context(), c, cf, resources, true)
+	if err != nil {
+		return nil, err
+	}
+	out := &RenderedInstance{
+		Namespace:         rendered.namespace,
+		Objects:           make([]map[string]interface{}, 0, len(rendered.objects)),
+		InjectedResources: rendered.injectedResources,
+	}
+	if out.InjectedResources == nil {
+		out.InjectedResources = []InjectedResources{}
+	}
+	for _, b := range rendered.objects {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(b, &obj); err != nil {
+			return nil, err
+		}
+		out.Objects = append(out.Objects, obj)
+	}
+	out.ExternalChanges = liveDrift(c.Request.Context(), rendered.objects, rendered.namespace)
+	return out, nil
+}
+
+// renderInstance runs the patch pipeline over the resources of cf for the caller's namespace.
+// With dryRun the namespace, volume bindings and env Secret are not created.
+func (s *ConfigFileService) renderInstance(ctx context.Context, c *gin.Context, cf *configfile.ConfigFile, resources []resource.Resource, dryRun bool) (*instanceRender, error) {
 	// 2. Prepare Context (Namespace, Project, Claims)
-	ns, proj, claims, err := s.prepareNamespaceAndProject(c, cf)
+	var (
+		ns     string
+		proj   project.Project
+		claims *types.Claims
+		err    error
+	)
+	if dryRun {
+		claims, _ = c.MustGet("claims").(*types.Claims)
+		proj, err = s.Repos.Project.GetProjectByID(cf.ProjectID)
+		ns = WorkloadNamespace(&proj, claims.Username)
+	} else {
+		ns, proj, claims, err = s.prepareNamespaceAndProject(c, cf)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// 3. Determine Deployment Strategy (Job-only vs Standard)
@@ -39,157 +196,450 @@ func (s *ConfigFileService) CreateInstance(c *gin.Context, id uint) error {
 	// 4. Prepare Variables & Volumes
 	var templateValues map[string]string
 	var shouldEnforceRO bool
-	var projectPVCName string
 
 	// Standard Deployment: Bind Volumes & Check Permissions
-	userPvc, projPvc := s.bindProjectAndUserVolumes(ns, proj, claims)
+	var userPvc, projPvc string
+	var storages map[string]string
+	if dryRun {
+		userPvc, projPvc, storages = s.instanceVolumeNames(proj, claims)
+	} else {
+		userPvc, projPvc, storages = s.bindProjectAndUserVolumes(ns, proj, claims)
+	}
 	shouldEnforceRO, err = s.determineReadOnlyEnforcement(claims, proj)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	projectPVCName = projPvc
-	templateValues = s.buildTemplateValues(cf, ns, userPvc, projPvc, claims)
-
-	// 5. Processing Pipeline (The most compute-intensive part)
-	// We use pre-allocation to avoid slice resizing overhead
-	processedResources := make([][]byte, 0, len(resources))
+	projectPVCNames := []string{projPvc}
+	for _, pvcName := range storages {
+		if pvcName != projPvc {
+			projectPVCNames = append(projectPVCNames, pvcName)
+		}
+	}
+	templateValues = s.buildTemplateValues(cf, ns, userPvc, projPvc, storages, claims)
 
-	for _, res := range resources {
-		// A. Template Replacement (String Level)
-		jsonStr := string(res.ParsedYAML)
-		replacedJSON, err := utils.ReplacePlaceholdersInJSON(jsonStr, templateValues)
+	var envDefaults []corev1.EnvVar
+	if dryRun {
+		defaults, err := s.Repos.ProjectEnv.ListByProject(cf.ProjectID)
 		if err != nil {
-			return fmt.Errorf("failed to replace placeholders for resource %s: %w", res.Name, err)
+			return nil, fmt.Errorf("failed to load project env defaults: %w", err)
 		}
-
-		// B. Unmarshal ONCE (Performance Key)
-		var obj map[string]interface{}
-		if err := json.Unmarshal([]byte(replacedJSON), &obj); err != nil {
-			return fmt.Errorf("failed to unmarshal resource %s: %w", res.Name, err)
+		envDefaults, _ = projectEnvVars(defaults, cf.ProjectID)
+	} else {
+		envDefaults, err = resolveProjectEnv(ctx, s.Repos.ProjectEnv, cf.ProjectID, ns)
+		if err != nil {
+			return nil, err
 		}
+	}
+	scheduling, err := resolveSchedulingPolicy(s.Repos.Scheduling, cf.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	registryAuths, err := projectRegistryAuths(s.Repos.Registry, cf.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	resourceDefaults, err := projectResourceDefaults(proj)
+	if err != nil {
+		return nil, err
+	}
 
-		// C. Apply Patches (In-Memory Map Manipulation)
-		//    All business logic validation and injection happens here without re-marshaling.
-		ctx := &PatchContext{
-			ProjectID:       cf.ProjectID,
-			Project:         proj,
-			UserIsAdmin:     claims.IsAdmin,
-			ShouldEnforceRO: shouldEnforceRO,
-			ProjectPVC:      projectPVCName,
+	// 5. Run every document through the pipeline, collecting the failures of all of them
+	rendered := &instanceRender{namespace: ns, claims: claims, objects: make([][]byte, 0, len(resources)), registryAuths: registryAuths}
+	// Managers, who get write access, review the instances and need no approval themselves
+	rendered.requiresApproval = proj.RequireInstanceApproval && shouldEnforceRO
+	steps := s.instanceSteps()
+	docs := make([][]byte, 0, len(resources))
+	var failures []*InstanceStepError
+	// injectedBy holds the index in docs of the object of each injected resources record
+	var injectedBy []int
+
+	for i, res := range resources {
+		dc := &instanceDocContext{
+			Index:    i,
+			Resource: string(res.Type) + "/" + res.Name,
+			Values:   templateValues,
+			Patch: &PatchContext{
+				ProjectID:        cf.ProjectID,
+				Project:          proj,
+				UserIsAdmin:      claims.IsAdmin,
+				ShouldEnforceRO:  shouldEnforceRO,
+				ProjectPVCs:      projectPVCNames,
+				EnvDefaults:      envDefaults,
+				Scheduling:       scheduling,
+				RegistryAuths:    registryAuths,
+				ResourceDefaults: resourceDefaults,
+			},
 		}
+		doc, failure := runInstanceSteps(steps, res.ParsedYAML, dc)
+		if failure != nil {
+			failures = append(failures, failure)
+			continue
+		}
+		patch := dc.Patch
+		rendered.usesHarborImage = rendered.usesHarborImage || patch.UsesHarborImage
+		rendered.usesProjectRegistry = rendered.usesProjectRegistry || patch.UsesProjectRegistry
+		for _, inj := range patch.InjectedResources {
+			rendered.injectedResources = append(rendered.injectedResources, inj)
+			injectedBy = append(injectedBy, len(docs))
+		}
+		docs = append(docs, doc)
+	}
+	if len(failures) > 0 {
+		return nil, &InstanceError{Failures: failures}
+	}
 
-		if err := s.applyResourcePatches(obj, ctx); err != nil {
-			return fmt.Errorf("failed to patch resource %s: %w", res.Name, err)
+	patched := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		if err := json.Unmarshal(doc, &patched[i]); err != nil {
+			return nil, fmt.Errorf("failed to decode resource %s: %w", resources[i].Name, err)
 		}
+	}
+	// In a shared namespace every member's objects carry their name prefix
+	if proj.SharesNamespace() {
+		prefixInstanceNames(patched, sharedNamePrefix(claims.Username), claims.UserID)
+	}
+	for i, idx := range injectedBy {
+		rendered.injectedResources[i].Resource = objectRef(patched[idx])
+	}
 
-		// D. Marshal ONCE
+	for i, obj := range patched {
 		finalBytes, err := json.Marshal(obj)
 		if err != nil {
-			return fmt.Errorf("failed to marshal final resource %s: %w", res.Name, err)
+			return nil, fmt.Errorf("failed to marshal final resource %s: %w", resources[i].Name, err)
 		}
+		rendered.objects = append(rendered.objects, finalBytes)
+		rendered.refs = append(rendered.refs, objectRef(obj))
+	}
+	return rendered, nil
+}
 
-		processedResources = append(processedResources, finalBytes)
+// ensureImagePullSecret copies the configured Harbor pull secret into ns unless it is already
+// there. An existing secret is left untouched.
+func ensureImagePullSecret(ctx context.Context, ns string) error {
+	if ns == config.HarborPullSecretNamespace {
+		return nil
+	}
+	if err := k8s.CopySecretIfMissing(ctx, config.HarborPullSecretNamespace, config.HarborPullSecretName, ns); err != nil {
+		return fmt.Errorf("failed to provide image pull secret in %s: %w", ns, err)
 	}
+	return nil
+}
+
+// InstanceDeletion is one object, or a whole namespace, handled while tearing down instances.
+type InstanceDeletion struct {
+	Username  string `json:"username"`
+	Namespace string `json:"namespace"`
+	// Resource is Kind/name; it is empty when the whole namespace was skipped
+	Resource string `json:"resource,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// InstanceDeletionReport lists what tearing down instances deleted, skipped because it was
+// already gone, and failed to delete.
+type InstanceDeletionReport struct {
+	Deleted []InstanceDeletion `json:"deleted"`
+	Skipped []InstanceDeletion `json:"skipped"`
+	Failed  []InstanceDeletion `json:"failed"`
+}
+
+func newInstanceDeletionReport() *InstanceDeletionReport {
+	return &InstanceDeletionReport{Deleted: []InstanceDeletion{}, Skipped: []InstanceDeletion{}, Failed: []InstanceDeletion{}}
+}
 
-	// 6. Apply to Kubernetes
-	log.Printf("Deploying %d resources to namespace %s", len(processedResources), ns)
-	for _, jsonBytes := range processedResources {
-		if err := k8s.CreateByJson(datatypes.JSON(jsonBytes), ns); err != nil {
-			return fmt.Errorf("failed to create resource in k8s: %w", err)
+// Partial reports whether some objects could not be deleted.
+func (r *InstanceDeletionReport) Partial() bool {
+	return len(r.Failed) > 0
+}
+
+// deleteResources deletes resources from ns for username, recording each outcome in report.
+// toJSON renders the object to delete; deleteFn deletes it under its name in names, keyed by
+// resource ID, and reports whether it still existed. It returns the IDs of the resources whose
+// object is gone. Only errors meaning the API server is unreachable stop the loop and are
+// returned.
+func (r *InstanceDeletionReport) deleteResources(username, ns string, resources []resource.Resource, names map[uint]string, toJSON func(resource.Resource) ([]byte, error), deleteFn func([]byte, string, string) (bool, error)) ([]uint, error) {
+	var gone []uint
+	for _, res := range resources {
+		entry := InstanceDeletion{Username: username, Namespace: ns, Resource: string(res.Type) + "/" + res.Name}
+		if name := names[res.RID]; name != "" {
+			entry.Resource = string(res.Type) + "/" + name
+		}
+		obj, err := toJSON(res)
+		deleted := false
+		if err == nil {
+			deleted, err = deleteFn(obj, ns, names[res.RID])
+		}
+		switch {
+		case k8s.IsUnreachable(err):
+			entry.Error = err.Error()
+			r.Failed = append(r.Failed, entry)
+			return gone, fmt.Errorf("failed to delete %s in %s: %w", entry.Resource, ns, err)
+		case err != nil:
+			log.Printf("[ConfigFile] failed to delete %s in %s: %v", entry.Resource, ns, err)
+			entry.Error = err.Error()
+			r.Failed = append(r.Failed, entry)
+		case deleted:
+			r.Deleted = append(r.Deleted, entry)
+			gone = append(gone, res.RID)
+		default:
+			r.Skipped = append(r.Skipped, entry)
+			gone = append(gone, res.RID)
 		}
 	}
+	return gone, nil
+}
 
-	return nil
+// deleteInstanceResources deletes the objects of resources from ns by the names they were
+// created under, falling back to the names in the manifests for objects deployed before the
+// names were recorded, and forgets the names of the objects that are gone.
+func (s *ConfigFileService) deleteInstanceResources(report *InstanceDeletionReport, username, ns string, resources []resource.Resource, toJSON func(resource.Resource) ([]byte, error), deleteFn func([]byte, string, string) (bool, error)) error {
+	rIDs := make([]uint, 0, len(resources))
+	for _, res := range resources {
+		rIDs = append(rIDs, res.RID)
+	}
+	names := map[uint]string{}
+	deployed, err := s.Repos.DeployedObject.ListByNamespace(ns, rIDs)
+	if err != nil {
+		log.Printf("[ConfigFile] failed to look up the deployed names in %s, using the manifest names: %v", ns, err)
+	}
+	for _, d := range deployed {
+		names[d.RID] = d.Name
+	}
+
+	gone, err := report.deleteResources(username, ns, resources, names, toJSON, deleteFn)
+	if ferr := s.Repos.DeployedObject.Delete(ns, gone); ferr != nil {
+		log.Printf("[ConfigFile] failed to forget the deleted objects in %s: %v", ns, ferr)
+	}
+	return err
 }
 
-func (s *ConfigFileService) DeleteInstance(c *gin.Context, id uint) error {
+// DeleteInstance removes the caller's instance of config file id. Objects that fail to delete
+// are listed in the report; an error is only returned when nothing could be attempted or the
+// API server became unreachable.
+func (s *ConfigFileService) DeleteInstance(c *gin.Context, id uint) (*InstanceDeletionReport, error) {
 	data, err := s.Repos.Resource.ListResourcesByConfigFileID(id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	configfile, err := s.Repos.ConfigFile.GetConfigFileByID(id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	claims, _ := c.MustGet("claims").(*types.Claims)
 
+	p, err := s.Repos.Project.GetProjectByID(configfile.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	report := newInstanceDeletionReport()
+	if p.SharesNamespace() {
+		// Only the caller's own objects are removed from the shared namespace
+		owner := k8s.Ownership{ProjectID: p.PID, UserID: claims.UserID, ConfigFileID: configfile.CFID}.Labels()
+		err = s.deleteSharedInstance(report, claims.Username, data, ProjectStorageNamespace(&p), owner)
+		return report, err
+	}
+
 	safeUsername := k8s.ToSafeK8sName(claims.Username)
 	ns := k8s.FormatNamespaceName(configfile.ProjectID, safeUsername)
+	err = s.deleteInstanceResources(report, claims.Username, ns, data, rawResourceJSON, k8s.DeleteByJsonIfExists)
+	return report, err
+}
 
-	for _, val := range data {
-		if err := k8s.DeleteByJson(val.ParsedYAML, ns); err != nil {
-			// Continue deleting other resources even if one fails
-			fmt.Printf("[Error] Failed to delete resource %s: %v\n", val.Name, err)
-		}
+// DeleteConfigFileInstance tears down the instances of config file id in every member namespace.
+func (s *ConfigFileService) DeleteConfigFileInstance(id uint) (*InstanceDeletionReport, error) {
+	cf, err := s.Repos.ConfigFile.GetConfigFileByID(id)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return s.deleteConfigFileInstances(cf)
 }
 
-func (s *ConfigFileService) DeleteConfigFileInstance(id uint) error {
-	configfile, err := s.Repos.ConfigFile.GetConfigFileByID(id)
-	if err != nil {
-		return err
+func rawResourceJSON(res resource.Resource) ([]byte, error) {
+	return res.ParsedYAML, nil
+}
+
+// deleteSharedInstance removes the objects of one member's instance from a shared namespace.
+// Objects not carrying the owner labels are skipped.
+func (s *ConfigFileService) deleteSharedInstance(report *InstanceDeletionReport, username string, resources []resource.Resource, ns string, owner map[string]string) error {
+	prefix := sharedNamePrefix(username)
+	toJSON := func(res resource.Resource) ([]byte, error) {
+		return sharedObjectJSON(res.ParsedYAML, prefix)
+	}
+	deleteFn := func(obj []byte, ns, name string) (bool, error) {
+		return true, deleteOwnedByJson(obj, ns, name, owner)
 	}
+	return s.deleteInstanceResources(report, username, ns, resources, toJSON, deleteFn)
+}
 
-	resources, err := s.Repos.Resource.ListResourcesByConfigFileID(id)
+// deleteConfigFileInstances tears down the instances of cf in every member namespace. Namespaces
+// that no longer exist are skipped, and objects that fail to delete do not stop the others; both
+// are listed in the report. It only fails when the API server is unreachable.
+func (s *ConfigFileService) deleteConfigFileInstances(cf *configfile.ConfigFile) (*InstanceDeletionReport, error) {
+	resources, err := s.Repos.Resource.ListResourcesByConfigFileID(cf.CFID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	users, err := s.Repos.User.ListUsersByProjectID(configfile.ProjectID)
+	users, err := s.Repos.User.ListUsersByProjectID(cf.ProjectID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	report := newInstanceDeletionReport()
+	if p, err := s.Repos.Project.GetProjectByID(cf.ProjectID); err == nil && p.SharesNamespace() {
+		ns := ProjectStorageNamespace(&p)
+		owner := k8s.Ownership{ProjectID: p.PID, ConfigFileID: cf.CFID}.Labels()
+		for _, user := range users {
+			if err := s.deleteSharedInstance(report, user.Username, resources, ns, owner); err != nil {
+				return report, err
+			}
+		}
+		return report, nil
 	}
 
 	for _, user := range users {
 		safeUsername := k8s.ToSafeK8sName(user.Username)
-		ns := k8s.FormatNamespaceName(configfile.ProjectID, safeUsername)
-		for _, res := range resources {
-			if err := k8s.DeleteByJson(res.ParsedYAML, ns); err != nil {
-				fmt.Printf("[Warning] Failed to delete instance for user %s: %v\n", user.Username, err)
-			}
+		ns := k8s.FormatNamespaceName(cf.ProjectID, safeUsername)
+		exists, err := k8s.CheckNamespaceExists(ns)
+		if k8s.IsUnreachable(err) {
+			return report, fmt.Errorf("failed to look up namespace %s: %w", ns, err)
+		}
+		if err == nil && !exists {
+			report.Skipped = append(report.Skipped, InstanceDeletion{Username: user.Username, Namespace: ns})
+			continue
+		}
+		if err := s.deleteInstanceResources(report, user.Username, ns, resources, rawResourceJSON, k8s.DeleteByJsonIfExists); err != nil {
+			return report, err
 		}
 	}
 
-	return nil
+	return report, nil
 }
 
 // --- Helpers for Deployment ---
 
+// checkInstanceDataLimits rejects instances whose ConfigMaps or Secrets exceed the limits.
+// Admins are only logged.
+func (s *ConfigFileService) checkInstanceDataLimits(ns string, claims *types.Claims, processed [][]byte) error {
+	objs := make([]map[string]interface{}, 0, len(processed))
+	for _, b := range processed {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(b, &obj); err == nil {
+			objs = append(objs, obj)
+		}
+	}
+	violations := configDataViolations(objs)
+	v, err := namespaceConfigMapViolation(context.Background(), ns, objs)
+	if err != nil {
+		return err
+	}
+	if v != "" {
+		violations = append(violations, v)
+	}
+	warnings, err := enforceConfigDataLimits(claims != nil && claims.IsAdmin, violations)
+	for _, w := range warnings {
+		log.Printf("[ConfigFile] %s", w)
+	}
+	return err
+}
+
 func (s *ConfigFileService) prepareNamespaceAndProject(c *gin.Context, cf *configfile.ConfigFile) (string, project.Project, *types.Claims, error) {
 	claims, _ := c.MustGet("claims").(*types.Claims)
-	safeUsername := k8s.ToSafeK8sName(claims.Username)
-	targetNs := k8s.FormatNamespaceName(cf.ProjectID, safeUsername)
-
-	if err := k8s.EnsureNamespaceExists(targetNs); err != nil {
-		return "", project.Project{}, nil, fmt.Errorf("failed to ensure namespace %s: %w", targetNs, err)
-	}
 
 	p, err := s.Repos.Project.GetProjectByID(cf.ProjectID)
 	if err != nil {
 		return "", project.Project{}, nil, err
 	}
+	targetNs := WorkloadNamespace(&p, claims.Username)
+	owner := claims.Username
+	if p.SharesNamespace() {
+		owner = ""
+	}
+
+	if err := EnsureMemberNamespace(s.Repos, &p, targetNs, owner); err != nil {
+		return "", project.Project{}, nil, fmt.Errorf("failed to ensure namespace %s: %w", targetNs, err)
+	}
 	return targetNs, p, claims, nil
 }
 
-func (s *ConfigFileService) bindProjectAndUserVolumes(targetNs string, project project.Project, claims *types.Claims) (string, string) {
-	safeUsername := k8s.ToSafeK8sName(claims.Username)
-	userStorageNs := fmt.Sprintf(config.UserStorageNs, safeUsername)
-	userPvcName := fmt.Sprintf(config.UserStoragePVC, safeUsername)
-	projectStorageNs := k8s.GenerateSafeResourceName("project", project.ProjectName, project.PID)
-	projectPvcName := fmt.Sprintf("project-%d-disk", project.PID)
+// checkInstancePodSecurity validates the final objects of an instance, after the placeholders
+// were replaced.
+func (s *ConfigFileService) checkInstancePodSecurity(c *gin.Context, projectID uint, processed [][]byte) error {
+	objs := make([]map[string]interface{}, 0, len(processed))
+	for _, b := range processed {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(b, &obj); err == nil {
+			objs = append(objs, obj)
+		}
+	}
+	return s.enforcePodSecurity(c, projectID, objs)
+}
+
+// checkUserStorageHealth fails fast when the caller's storage hub is degraded, since every pod
+// mounting the user volume would then hang in ContainerCreating. A user without a hub is not
+// stopped; the user volume is simply not bound.
+func (s *ConfigFileService) checkUserStorageHealth(c *gin.Context) error {
+	claims, _ := c.MustGet("claims").(*types.Claims)
+	ns, pvcName := userHubNames(userStorageID(s.Repos, claims.Username))
+	health, err := k8s.GetUserStorageHealth(c.Request.Context(), ns, pvcName)
+	if err != nil {
+		return fmt.Errorf("failed to check user storage: %w", err)
+	}
+	if health.Verdict != k8s.HubDegraded {
+		return nil
+	}
+	return fmt.Errorf("%w: %s; see /k8s/users/%s/storage/detail", ErrStorageDegraded, strings.Join(health.Problems, "; "), claims.Username)
+}
+
+// bindProjectAndUserVolumes shares the user and project storages into the target namespace.
+// It returns the user PVC, the default project PVC and the bound PVC of every named project storage.
+func (s *ConfigFileService) bindProjectAndUserVolumes(targetNs string, project project.Project, claims *types.Claims) (string, string, map[string]string) {
+	userStorageNs := k8s.UserHubNamespace(userStorageID(s.Repos, claims.Username))
+	projectStorageNs := ProjectStorageNamespace(&project)
+	userPvcName, projectPvcName, storages := s.instanceVolumeNames(project, claims)
 
 	targetUserPvcName := userPvcName
 	if err := k8s.MountExistingVolumeToProject(userStorageNs, userPvcName, targetNs, targetUserPvcName); err != nil {
-		fmt.Printf("[Warning] Failed to bind user volume: %v\n", err)
+		servicesLog.Warn("failed to bind user volume", "namespace", targetNs, "user", claims.Username, "pvc", userPvcName, "error", err)
 	}
 
 	targetProjectPvcName := projectPvcName
 	if err := k8s.MountExistingVolumeToProject(projectStorageNs, projectPvcName, targetNs, targetProjectPvcName); err != nil {
-		fmt.Printf("[Warning] Failed to bind project volume: %v\n", err)
+		servicesLog.Warn("failed to bind project volume", "namespace", targetNs, "user", claims.Username, "pvc", projectPvcName, "error", err)
 	}
 
-	return targetUserPvcName, targetProjectPvcName
+	for name, pvcName := range storages {
+		if pvcName == projectPvcName {
+			continue
+		}
+		if err := k8s.MountExistingVolumeToProject(projectStorageNs, pvcName, targetNs, pvcName); err != nil {
+			servicesLog.Warn("failed to bind project storage", "namespace", targetNs, "user", claims.Username, "pvc", pvcName, "error", err)
+			delete(storages, name)
+		}
+	}
+
+	return targetUserPvcName, targetProjectPvcName, storages
+}
+
+// instanceVolumeNames returns the PVC names bindProjectAndUserVolumes binds into an instance
+// namespace, without binding them.
+func (s *ConfigFileService) instanceVolumeNames(project project.Project, claims *types.Claims) (string, string, map[string]string) {
+	userPvcName := k8s.UserHubDiskName(userStorageID(s.Repos, claims.Username))
+	projectStorageNs := ProjectStorageNamespace(&project)
+	projectPvcName := k8s.ProjectStoragePVCName(project.PID, k8s.DefaultProjectStorage)
+
+	storages := map[string]string{k8s.DefaultProjectStorage: projectPvcName}
+	pvcs, err := k8s.ListProjectStoragePVCs(context.Background(), projectStorageNs)
+	if err != nil {
+		servicesLog.Warn("failed to list project storages", "namespace", projectStorageNs, "error", err)
+	}
+	for i := range pvcs {
+		pvc := &pvcs[i]
+		if pvc.Name == projectPvcName {
+			continue
+		}
+		storages[k8s.ProjectStorageName(pvc)] = pvc.Name
+	}
+	return userPvcName, projectPvcName, storages
 }
 
 func (s *ConfigFileService) determineReadOnlyEnforcement(claims *types.Claims, project project.Project) (bool, error) {
@@ -206,8 +656,8 @@ func (s *ConfigFileService) determineReadOnlyEnforcement(claims *types.Claims, p
 	return ug.Role != "manager" && ug.Role != "admin", nil
 }
 
-func (s *ConfigFileService) buildTemplateValues(cf *configfile.ConfigFile, namespace, userPvc, projectPvc string, claims *types.Claims) map[string]string {
-	return map[string]string{
+func (s *ConfigFileService) buildTemplateValues(cf *configfile.ConfigFile, namespace, userPvc, projectPvc string, storages map[string]string, claims *types.Claims) map[string]string {
+	values := map[string]string{
 		"username":         k8s.ToSafeK8sName(claims.Username),
 		"originalUsername": claims.Username,
 		"safeUsername":     k8s.ToSafeK8sName(claims.Username),
@@ -216,6 +666,11 @@ func (s *ConfigFileService) buildTemplateValues(cf *configfile.ConfigFile, names
 		"userVolume":       userPvc,
 		"projectVolume":    projectPvc,
 	}
+	// {{projectStorage:<name>}} resolves to the PVC of a named project storage
+	for name, pvcName := range storages {
+		values["projectStorage:"+name] = pvcName
+	}
+	return values
 }
 
 // func (s *ConfigFileService) buildJobTemplateValues(cf *configfile.ConfigFile, namespace string, claims *types.Claims) map[string]string {
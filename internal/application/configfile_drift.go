@@ -0,0 +1,64 @@
+package application
+
+import (
+	"context"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+)
+
+// InstanceDrift summarizes the objects of the caller's instance of cf that were modified
+// outside the platform. It is nil without a caller.
+func (s *ConfigFileService) InstanceDrift(c *gin.Context, cf *configfile.ConfigFile) (*configfile.Drift, error) {
+	claimsVal, _ := c.Get("claims")
+	claims, _ := claimsVal.(*types.Claims)
+	if claims == nil {
+		return nil, nil
+	}
+	p, err := s.Repos.Project.GetProjectByID(cf.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := s.Repos.Resource.ListResourcesByConfigFileID(cf.CFID)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([][]byte, 0, len(resources))
+	for _, res := range resources {
+		obj := []byte(res.ParsedYAML)
+		if p.SharesNamespace() {
+			if obj, err = sharedObjectJSON(res.ParsedYAML, sharedNamePrefix(claims.Username)); err != nil {
+				continue
+			}
+		}
+		objects = append(objects, obj)
+	}
+
+	drift := &configfile.Drift{Objects: []string{}}
+	for _, d := range liveDrift(c.Request.Context(), objects, WorkloadNamespace(&p, claims.Username)) {
+		drift.ExternallyModified++
+		drift.Objects = append(drift.Objects, d.Kind+"/"+d.Name)
+	}
+	return drift, nil
+}
+
+// liveDrift returns the outside modifications of the live objects of the manifests in ns.
+// Objects that cannot be looked up are skipped.
+func liveDrift(ctx context.Context, objects [][]byte, ns string) []k8s.ObjectDrift {
+	drifts := []k8s.ObjectDrift{}
+	for _, obj := range objects {
+		d, err := k8s.LiveObjectDrift(ctx, obj, ns)
+		if err != nil {
+			log.Printf("[WARN] failed to check %s for outside modifications: %v", ns, err)
+			continue
+		}
+		if d != nil {
+			drifts = append(drifts, *d)
+		}
+	}
+	return drifts
+}
@@ -0,0 +1,156 @@
+package application
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var ErrConfigDataLimitExceeded = errors.New("config data limit exceeded")
+
+// rootCAConfigMap is created by Kubernetes in every namespace and is not counted.
+const rootCAConfigMap = "kube-root-ca.crt"
+
+// configDataSize returns the payload size of a ConfigMap or Secret: keys plus decoded values.
+// Secret data and ConfigMap binaryData are base64 in the manifest and counted decoded.
+func configDataSize(obj map[string]interface{}) int {
+	size := 0
+	add := func(field string, encoded bool) {
+		m, _ := obj[field].(map[string]interface{})
+		for k, v := range m {
+			str, _ := v.(string)
+			size += len(k)
+			if encoded {
+				if decoded, err := base64.StdEncoding.DecodeString(str); err == nil {
+					size += len(decoded)
+					continue
+				}
+			}
+			size += len(str)
+		}
+	}
+	switch obj["kind"] {
+	case "ConfigMap":
+		add("data", false)
+		add("binaryData", true)
+	case "Secret":
+		add("data", true)
+		add("stringData", false)
+	}
+	return size
+}
+
+// configDataViolations lists the ConfigMaps and Secrets over the size limit, and the ConfigMap
+// count when a single file defines more than the per-file cap.
+func configDataViolations(objs []map[string]interface{}) []string {
+	var violations []string
+	configMaps := 0
+	for _, obj := range objs {
+		kind, _ := obj["kind"].(string)
+		if kind != "ConfigMap" && kind != "Secret" {
+			continue
+		}
+		if kind == "ConfigMap" {
+			configMaps++
+		}
+		name := ""
+		if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+			name, _ = meta["name"].(string)
+		}
+		if size := configDataSize(obj); size > config.ConfigDataMaxBytes {
+			violations = append(violations, fmt.Sprintf("%s %q holds %d bytes of data (limit %d)", kind, name, size, config.ConfigDataMaxBytes))
+		}
+	}
+	if configMaps > config.ConfigMapMaxPerFile {
+		violations = append(violations, fmt.Sprintf("config file defines %d ConfigMaps (limit %d)", configMaps, config.ConfigMapMaxPerFile))
+	}
+	return violations
+}
+
+// enforceConfigDataLimits rejects violations for regular users. Admins pass, and the violations
+// are returned as warnings for the response.
+func enforceConfigDataLimits(isAdmin bool, violations []string) ([]string, error) {
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	if isAdmin {
+		warnings := make([]string, 0, len(violations))
+		for _, v := range violations {
+			warnings = append(warnings, "limit bypassed by admin: "+v)
+		}
+		return warnings, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrConfigDataLimitExceeded, strings.Join(violations, "; "))
+}
+
+func parsedResourceObjects(resources []*resource.Resource) []map[string]interface{} {
+	objs := make([]map[string]interface{}, 0, len(resources))
+	for _, r := range resources {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(r.ParsedYAML, &obj); err == nil {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// checkConfigFileDataLimits validates the ConfigMaps and Secrets of an uploaded config file.
+func checkConfigFileDataLimits(c *gin.Context, resources []*resource.Resource) ([]string, error) {
+	return enforceConfigDataLimits(claimsIsAdmin(c), configDataViolations(parsedResourceObjects(resources)))
+}
+
+func claimsIsAdmin(c *gin.Context) bool {
+	if c == nil {
+		return false
+	}
+	v, ok := c.Get("claims")
+	if !ok {
+		return false
+	}
+	claims, ok := v.(*types.Claims)
+	return ok && claims.IsAdmin
+}
+
+// namespaceConfigMapViolation counts the ConfigMaps already in ns with a live List, so deploying
+// several instances cannot get around the per-file cap. ConfigMaps being re-applied under the same
+// name are not counted twice.
+func namespaceConfigMapViolation(ctx context.Context, ns string, objs []map[string]interface{}) (string, error) {
+	incoming := map[string]bool{}
+	for _, obj := range objs {
+		if obj["kind"] != "ConfigMap" {
+			continue
+		}
+		if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+			name, _ := meta["name"].(string)
+			incoming[name] = true
+		}
+	}
+	if len(incoming) == 0 || k8s.Clientset == nil {
+		return "", nil
+	}
+
+	list, err := k8s.Clientset.CoreV1().ConfigMaps(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list ConfigMaps in %s: %w", ns, err)
+	}
+	total := len(incoming)
+	for _, cm := range list.Items {
+		if cm.Name != rootCAConfigMap && !incoming[cm.Name] {
+			total++
+		}
+	}
+	if total > config.ConfigMapMaxPerNamespace {
+		return fmt.Sprintf("namespace %s would hold %d ConfigMaps (limit %d)", ns, total, config.ConfigMapMaxPerNamespace), nil
+	}
+	return "", nil
+}
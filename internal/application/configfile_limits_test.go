@@ -0,0 +1,96 @@
+package application
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func configMapObject(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":     "ConfigMap",
+		"metadata": map[string]interface{}{"name": name},
+		"data":     map[string]interface{}{"k": "v"},
+	}
+}
+
+func TestBinarySecretJustOverLimit(t *testing.T) {
+	// The decoded payload is what counts, not the ~33% larger base64 text
+	payload := make([]byte, config.ConfigDataMaxBytes)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	secret := func(n int) map[string]interface{} {
+		return map[string]interface{}{
+			"kind":     "Secret",
+			"metadata": map[string]interface{}{"name": "weights"},
+			"data":     map[string]interface{}{"w": base64.StdEncoding.EncodeToString(payload[:n])},
+		}
+	}
+
+	if v := configDataViolations([]map[string]interface{}{secret(config.ConfigDataMaxBytes - 1)}); len(v) != 0 {
+		t.Fatalf("secret at the limit rejected: %v", v)
+	}
+
+	v := configDataViolations([]map[string]interface{}{secret(config.ConfigDataMaxBytes)})
+	if len(v) != 1 || !strings.Contains(v[0], `Secret "weights"`) || !strings.Contains(v[0], fmt.Sprint(config.ConfigDataMaxBytes+1)) {
+		t.Fatalf("expected the secret and its size to be named, got %v", v)
+	}
+
+	if _, err := enforceConfigDataLimits(false, v); !errors.Is(err, ErrConfigDataLimitExceeded) {
+		t.Fatalf("expected ErrConfigDataLimitExceeded, got %v", err)
+	}
+	warnings, err := enforceConfigDataLimits(true, v)
+	if err != nil || len(warnings) != 1 {
+		t.Fatalf("admins should pass with a warning, got %v %v", warnings, err)
+	}
+}
+
+func TestConfigMapCountPerFile(t *testing.T) {
+	objs := make([]map[string]interface{}, 0, config.ConfigMapMaxPerFile+1)
+	for i := 0; i < config.ConfigMapMaxPerFile; i++ {
+		objs = append(objs, configMapObject(fmt.Sprintf("cm-%d", i)))
+	}
+	if v := configDataViolations(objs); len(v) != 0 {
+		t.Fatalf("unexpected violations %v", v)
+	}
+	objs = append(objs, configMapObject("one-too-many"))
+	if v := configDataViolations(objs); len(v) != 1 || !strings.Contains(v[0], "ConfigMaps") {
+		t.Fatalf("expected ConfigMap count violation, got %v", v)
+	}
+}
+
+func TestNamespaceConfigMapCount(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+
+	existing := []*corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: "ns"}}}
+	for i := 0; i < config.ConfigMapMaxPerNamespace-1; i++ {
+		existing = append(existing, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("old-%d", i), Namespace: "ns"}})
+	}
+	cs := k8sfake.NewSimpleClientset()
+	for _, cm := range existing {
+		if _, err := cs.CoreV1().ConfigMaps("ns").Create(context.Background(), cm, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k8s.Clientset = cs
+
+	// Re-applying an existing name does not add to the count
+	if v, err := namespaceConfigMapViolation(context.Background(), "ns", []map[string]interface{}{configMapObject("old-0"), configMapObject("new")}); err != nil || v != "" {
+		t.Fatalf("expected no violation, got %q %v", v, err)
+	}
+	v, err := namespaceConfigMapViolation(context.Background(), "ns", []map[string]interface{}{configMapObject("new"), configMapObject("newer")})
+	if err != nil || !strings.Contains(v, "namespace ns") {
+		t.Fatalf("expected namespace violation, got %q %v", v, err)
+	}
+}
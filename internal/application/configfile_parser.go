@@ -2,12 +2,14 @@ package application
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linskybing/platform-go/internal/domain/configfile"
 	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/repository"
 	"github.com/linskybing/platform-go/pkg/k8s"
 	"github.com/linskybing/platform-go/pkg/utils"
 	"gorm.io/datatypes"
@@ -15,51 +17,109 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
+// YAMLValidationError lists the problems found in each document of a config file, so the
+// caller can report all of them at once rather than only the first.
+type YAMLValidationError struct {
+	Errors []utils.YAMLError
+}
+
+func (e *YAMLValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	return fmt.Sprintf("%d errors in YAML, first: %s", len(e.Errors), e.Errors[0].Error())
+}
+
 // parseAndValidateResources splits raw YAML into documents, validates them, and prepares Resource structs.
+// Every document is checked; failures are returned together as a *YAMLValidationError.
 func (s *ConfigFileService) parseAndValidateResources(rawYaml string) ([]*resource.Resource, error) {
-	yamlArray := utils.SplitYAMLDocuments(rawYaml)
+	return parseManifests(rawYaml)
+}
+
+// parseManifests implements parseAndValidateResources for callers without a ConfigFileService.
+func parseManifests(rawYaml string) ([]*resource.Resource, error) {
+	yamlArray := utils.SplitYAMLDocumentsWithLines(rawYaml)
 	if len(yamlArray) == 0 {
 		return nil, ErrNoValidYAMLDocument
 	}
 
 	resourcesToCreate := make([]*resource.Resource, 0, len(yamlArray))
+	var problems []utils.YAMLError
 
 	for i, doc := range yamlArray {
-		// Convert YAML to JSON
-		jsonBytes, err := yaml.YAMLToJSON([]byte(doc))
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert YAML to JSON for document %d: %w", i+1, err)
+		res, yerr := parseYAMLDocument(doc)
+		if yerr != nil {
+			yerr.Document = i + 1
+			problems = append(problems, *yerr)
+			continue
 		}
+		resourcesToCreate = append(resourcesToCreate, res)
+	}
+	if len(problems) > 0 {
+		return nil, &YAMLValidationError{Errors: problems}
+	}
+	return resourcesToCreate, nil
+}
 
-		// Parse JSON to map for logical validation
-		var obj map[string]interface{}
-		if err := json.Unmarshal(jsonBytes, &obj); err != nil {
-			return nil, fmt.Errorf("failed to parse JSON for validation in document %d: %w", i+1, err)
-		}
+// parseYAMLDocument validates a single document. Errors carry absolute line numbers where the
+// offending line can be determined.
+func parseYAMLDocument(doc utils.YAMLDocument) (*resource.Resource, *utils.YAMLError) {
+	// Convert YAML to JSON
+	jsonBytes, err := yaml.YAMLToJSON([]byte(doc.Content))
+	if err != nil {
+		return nil, utils.NewYAMLSyntaxError(doc.Content, doc.StartLine, err)
+	}
 
-		// Validate Container Limits (Business Logic)
-		if err := validateContainerLimits(obj); err != nil {
-			return nil, fmt.Errorf("validation failed in document %d: %w", i+1, err)
-		}
+	// Parse JSON to map for logical validation
+	var obj map[string]interface{}
+	if err := json.Unmarshal(jsonBytes, &obj); err != nil {
+		return nil, &utils.YAMLError{Line: doc.StartLine, Message: fmt.Sprintf("document is not a YAML mapping: %v", err)}
+	}
 
-		// // Validate volume mounts and volumes for common pitfalls (subPath leading slash, empty PVC claimName)
-		// if err := validateVolumeMounts(obj); err != nil {
-		// 	return nil, fmt.Errorf("validation failed in document %d: %w", i+1, err)
-		// }
+	// Validate Container Limits (Business Logic)
+	if err := validateContainerLimits(obj); err != nil {
+		return nil, &utils.YAMLError{Line: doc.StartLine, Message: err.Error()}
+	}
 
-		// Validate K8s Spec (Structure)
-		gvk, name, err := k8s.ValidateK8sJSON(jsonBytes)
-		if err != nil {
-			return nil, fmt.Errorf("failed to validate K8s spec for document %d: %w", i+1, err)
-		}
+	// // Validate volume mounts and volumes for common pitfalls (subPath leading slash, empty PVC claimName)
+	// if err := validateVolumeMounts(obj); err != nil {
+	// 	return nil, fmt.Errorf("validation failed in document %d: %w", i+1, err)
+	// }
 
-		resourcesToCreate = append(resourcesToCreate, &resource.Resource{
-			Type:       resource.ResourceType(normalizeResourceKind(gvk.Kind)),
-			Name:       name,
-			ParsedYAML: datatypes.JSON(jsonBytes),
-		})
+	// Validate K8s Spec (Structure)
+	gvk, name, err := k8s.ValidateK8sJSON(jsonBytes)
+	if err != nil {
+		return nil, specErrorToYAMLError(doc, err)
 	}
-	return resourcesToCreate, nil
+
+	return &resource.Resource{
+		Type:       resource.ResourceType(normalizeResourceKind(gvk.Kind)),
+		Name:       name,
+		ParsedYAML: datatypes.JSON(jsonBytes),
+	}, nil
+}
+
+// specErrorToYAMLError points a spec validation failure at the line of its first field.
+func specErrorToYAMLError(doc utils.YAMLDocument, err error) *utils.YAMLError {
+	ye := &utils.YAMLError{Line: doc.StartLine, Message: err.Error()}
+	var specErr *k8s.SpecError
+	if !errors.As(err, &specErr) {
+		return ye
+	}
+	ye.Field = strings.Join(specErr.Fields, ", ")
+	ye.Message = specErr.Message
+	if len(specErr.Fields) > 0 {
+		path := specErr.Fields[0]
+		key := path[strings.LastIndex(path, ".")+1:]
+		if i := strings.Index(key, "["); i >= 0 {
+			key = key[:i]
+		}
+		if line := utils.FindKeyLine(doc.Content, doc.StartLine, key); line > 0 {
+			ye.Line = line
+			ye.Snippet = utils.Snippet(doc.Content, doc.StartLine, line)
+		}
+	}
+	return ye
 }
 
 // validateVolumeMounts checks PodSpecs for common volume/volumeMount mistakes.
@@ -120,9 +180,10 @@ func (s *ConfigFileService) parseAndValidateResources(rawYaml string) ([]*resour
 // }
 
 // syncConfigFileResources manages the diff (create/update/delete) for config file updates.
-func (s *ConfigFileService) syncConfigFileResources(c *gin.Context, cf *configfile.ConfigFile, rawYaml string, newResources []*resource.Resource) error {
+// Every kept or created resource is stamped with the file version it was parsed from.
+func (s *ConfigFileService) syncConfigFileResources(c *gin.Context, resources repository.ResourceRepo, cf *configfile.ConfigFile, newResources []*resource.Resource) error {
 	// 1. Fetch existing resources
-	existingResources, err := s.Repos.Resource.ListResourcesByConfigFileID(cf.CFID)
+	existingResources, err := resources.ListResourcesByConfigFileID(cf.CFID)
 	if err != nil {
 		return err
 	}
@@ -146,17 +207,19 @@ func (s *ConfigFileService) syncConfigFileResources(c *gin.Context, cf *configfi
 			val.Name = name
 			val.ParsedYAML = newRes.ParsedYAML
 			val.Type = newRes.Type // Ensure type is updated if kind changed (rare but possible)
+			val.CFVersion = cf.Version
 
-			fmt.Printf("Updating resource for document %d: %s\n", i+1, name)
-			if err := s.Repos.Resource.UpdateResource(&val); err != nil {
+			servicesLog.Debug("updating config file resource", "config_file_id", cf.CFID, "document", i+1, "resource", name)
+			if err := resources.UpdateResource(&val); err != nil {
 				return fmt.Errorf("failed to update resource %s: %w", name, err)
 			}
 			utils.LogAuditWithConsole(c, "update", "resource", fmt.Sprintf("r_id=%d", val.RID), oldTarget, val, "", s.Repos.Audit)
 		} else {
 			// Create
 			newRes.CFID = cf.CFID
-			fmt.Printf("Creating resource for document %d: %s\n", i+1, name)
-			if err := s.Repos.Resource.CreateResource(newRes); err != nil {
+			newRes.CFVersion = cf.Version
+			servicesLog.Debug("creating config file resource", "config_file_id", cf.CFID, "document", i+1, "resource", name)
+			if err := resources.CreateResource(newRes); err != nil {
 				return fmt.Errorf("failed to create resource %s: %w", name, err)
 			}
 			utils.LogAuditWithConsole(c, "create", "resource", fmt.Sprintf("r_id=%d", newRes.RID), nil, *newRes, "", s.Repos.Audit)
@@ -169,7 +232,7 @@ func (s *ConfigFileService) syncConfigFileResources(c *gin.Context, cf *configfi
 			// Remove from DB (Instance cleanup happens separately via re-deploy usually, or should be handled here if strictly synced)
 			// Note: This logic assumes the instance is cleaned up via deleteConfigFileInstance call in Service before this,
 			// or will be updated by next Apply.
-			if err := s.Repos.Resource.DeleteResource(res.RID); err != nil {
+			if err := resources.DeleteResource(res.RID); err != nil {
 				return fmt.Errorf("failed to delete unused resource %s: %w", name, err)
 			}
 			utils.LogAuditWithConsole(c, "delete", "resource", fmt.Sprintf("r_id=%d", res.RID), res, nil, "", s.Repos.Audit)
@@ -3,11 +3,16 @@ package application
 import (
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/project"
+	imageref "github.com/linskybing/platform-go/pkg/image"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
 )
 
 type PatchContext struct {
@@ -15,42 +20,90 @@ type PatchContext struct {
 	Project         project.Project
 	UserIsAdmin     bool
 	ShouldEnforceRO bool
-	ProjectPVC      string
+	ProjectPVCs     []string
+	EnvDefaults     []corev1.EnvVar
+	Scheduling      *k8s.SchedulingPolicy
+	// RegistryAuths are the project's upstream registry credentials
+	RegistryAuths []k8s.RegistryAuth
+	// UsesHarborImage is set by the patches when any container runs an image from Harbor
+	UsesHarborImage bool
+	// UsesProjectRegistry is set when a container image comes from a registry in RegistryAuths
+	UsesProjectRegistry bool
+	// ResourceDefaults are given to containers without CPU and memory settings; nil disables them
+	ResourceDefaults *ResourceDefaults
+	// InjectedResources is filled by the patches with the defaults each container received
+	InjectedResources []InjectedResources
 }
 
-// applyResourcePatches orchestrates all modifications to the K8s object map.
+// applyResourcePatches runs every pod spec patch of the instance pipeline over obj in place.
 func (s *ConfigFileService) applyResourcePatches(obj map[string]interface{}, ctx *PatchContext) error {
-	// 1. Identify Pod Specs once to avoid traversing the tree multiple times
+	// Identify Pod Specs once to avoid traversing the tree for every patch
 	podSpecs := findPodSpecs(obj)
-
-	if len(podSpecs) == 0 {
-		return nil // Non-workload resources (Services, ConfigMaps) usually don't need patches
-	}
-
-	// 2. Iterate PodSpecs and apply patches
+	steps := s.podSpecSteps()
 	for _, spec := range podSpecs {
-		// A. Validate & Patch Images
-		if err := s.patchImages(spec, ctx); err != nil {
-			return err
-		}
-
-		// B. Enforce ReadOnly PVCs
-		if ctx.ShouldEnforceRO && ctx.ProjectPVC != "" {
-			s.patchReadOnly(spec, ctx.ProjectPVC)
-		}
-
-		// C. Inject GPU Config
-		if err := s.patchGPU(spec, ctx.Project); err != nil {
-			return err
+		for _, step := range steps {
+			if err := step.patch(spec, ctx); err != nil {
+				return err
+			}
 		}
-
-		// D. Inject General Security Context
-		s.patchSecurityContext(spec)
 	}
-
 	return nil
 }
 
+// podSpecSteps returns the patches applied to every pod spec of an instance, in order.
+// Non-workload resources (Services, ConfigMaps) have no pod spec and are left alone.
+func (s *ConfigFileService) podSpecSteps() []podSpecStep {
+	return []podSpecStep{
+		// Validate images and reference the pull secrets they need
+		{InstanceStepImages, func(spec map[string]interface{}, ctx *PatchContext) error {
+			if err := s.patchImages(spec, ctx); err != nil {
+				return err
+			}
+			if patchImagePullSecret(spec) {
+				ctx.UsesHarborImage = true
+			}
+			if patchProjectPullSecret(spec, ctx) {
+				ctx.UsesProjectRegistry = true
+			}
+			return nil
+		}},
+		// Enforce ReadOnly PVCs
+		{InstanceStepReadOnly, func(spec map[string]interface{}, ctx *PatchContext) error {
+			if ctx.ShouldEnforceRO {
+				for _, pvcName := range ctx.ProjectPVCs {
+					s.patchReadOnly(spec, pvcName)
+				}
+			}
+			return nil
+		}},
+		// Inject GPU Config
+		{InstanceStepGPU, func(spec map[string]interface{}, ctx *PatchContext) error {
+			return s.patchGPU(spec, ctx.Project)
+		}},
+		// Inject CPU/memory defaults into containers that set none (GPU fields untouched)
+		{InstanceStepResources, func(spec map[string]interface{}, ctx *PatchContext) error {
+			ctx.InjectedResources = append(ctx.InjectedResources, patchResourceDefaults(spec, ctx.ResourceDefaults)...)
+			return nil
+		}},
+		// Inject General Security Context
+		{InstanceStepSecurity, func(spec map[string]interface{}, ctx *PatchContext) error {
+			s.patchSecurityContext(spec)
+			return nil
+		}},
+		// Inject Project Env Defaults (user-defined keys win)
+		{InstanceStepEnv, func(spec map[string]interface{}, ctx *PatchContext) error {
+			if len(ctx.EnvDefaults) > 0 {
+				s.patchEnvDefaults(spec, ctx.EnvDefaults)
+			}
+			return nil
+		}},
+		// Inject Project Scheduling Policy (user-defined fields win unless forced)
+		{InstanceStepScheduling, func(spec map[string]interface{}, ctx *PatchContext) error {
+			return k8s.ApplySchedulingPolicyToMap(spec, ctx.Scheduling)
+		}},
+	}
+}
+
 func (s *ConfigFileService) patchImages(podSpec map[string]interface{}, ctx *PatchContext) error {
 	containers := getContainersFromPodSpec(podSpec)
 
@@ -60,37 +113,92 @@ func (s *ConfigFileService) patchImages(podSpec map[string]interface{}, ctx *Pat
 			continue
 		}
 
-		// 1. Validation (Skip if Admin)
+		ref, err := imageref.Parse(img)
+		if err != nil {
+			if ctx.UserIsAdmin {
+				continue
+			}
+			return err
+		}
+
+		// 1. Validation (Skip if Admin). A reference without tag is checked as "latest".
 		if !ctx.UserIsAdmin {
-			imageName, imageTag := parseImageNameTag(img)
-			allowed, err := s.imageService.ValidateImageForProject(imageName, imageTag, &ctx.ProjectID)
-			log.Printf("Validating image: %s:%s, Allowed: %v, Error: %v", imageName, imageTag, allowed, err)
+			allowed, err := s.imageService.ValidateImageForProject(ref.FamiliarName(), ref.Version(), &ctx.ProjectID)
+			log.Printf("Validating image: %s, Allowed: %v, Error: %v", ref, allowed, err)
 			if err != nil {
 				return fmt.Errorf("failed to validate image %s: %v", img, err)
 			}
 			if !allowed {
-				return fmt.Errorf("image '%s:%s' is not allowed for this project", imageName, imageTag)
+				return fmt.Errorf("%w: %s", ErrImageNotAllowed, ref)
 			}
 		}
 
-		// 2. Harbor Injection
-		// Logic: Check if image needs pulling from private Harbor
-		// Note: parseImageNameTag is lightweight, safe to recall
-		imageName, imageTag := parseImageNameTag(img)
-
-		// Optimization: Check prefix first
+		// 2. Harbor Injection: run the private copy once it has been pulled
 		if strings.HasPrefix(img, config.HarborPrivatePrefix) {
 			continue
 		}
-
-		allowedImg, err := s.imageService.GetAllowedImage(imageName, imageTag, ctx.ProjectID)
+		allowedImg, err := s.imageService.GetAllowedImage(ref.FamiliarName(), ref.Version(), ctx.ProjectID)
 		if err == nil && allowedImg != nil && allowedImg.IsPulled {
-			cont["image"] = config.HarborPrivatePrefix + img
+			cont["image"] = HarborImage(ref)
 		}
 	}
 	return nil
 }
 
+// patchImagePullSecret references the Harbor pull secret from a pod spec that runs any image
+// (init containers included) from Harbor. It reports whether the spec uses Harbor.
+func patchImagePullSecret(podSpec map[string]interface{}) bool {
+	if config.HarborPrivatePrefix == "" || config.HarborPullSecretName == "" {
+		return false
+	}
+	usesHarbor := false
+	for _, cont := range getContainersFromPodSpec(podSpec) {
+		if img, _ := cont["image"].(string); strings.HasPrefix(img, config.HarborPrivatePrefix) {
+			usesHarbor = true
+			break
+		}
+	}
+	if !usesHarbor {
+		return false
+	}
+
+	secrets, _ := podSpec["imagePullSecrets"].([]interface{})
+	for _, ref := range secrets {
+		if m, ok := ref.(map[string]interface{}); ok && m["name"] == config.HarborPullSecretName {
+			return true
+		}
+	}
+	podSpec["imagePullSecrets"] = append(secrets, map[string]interface{}{"name": config.HarborPullSecretName})
+	return true
+}
+
+// patchProjectPullSecret references the project pull secret from a pod spec running an image
+// from one of the project's credentialed registries. It reports whether it did.
+func patchProjectPullSecret(podSpec map[string]interface{}, ctx *PatchContext) bool {
+	if len(ctx.RegistryAuths) == 0 {
+		return false
+	}
+	var images []string
+	for _, cont := range getContainersFromPodSpec(podSpec) {
+		if img, _ := cont["image"].(string); img != "" {
+			images = append(images, img)
+		}
+	}
+	if len(authsForImages(ctx.RegistryAuths, images...)) == 0 {
+		return false
+	}
+
+	name := ProjectPullSecretName(ctx.ProjectID)
+	secrets, _ := podSpec["imagePullSecrets"].([]interface{})
+	for _, ref := range secrets {
+		if m, ok := ref.(map[string]interface{}); ok && m["name"] == name {
+			return true
+		}
+	}
+	podSpec["imagePullSecrets"] = append(secrets, map[string]interface{}{"name": name})
+	return true
+}
+
 func (s *ConfigFileService) patchReadOnly(podSpec map[string]interface{}, targetPvcName string) {
 	// Identify volumes pointing to the restricted PVC
 	targetVolumes := make(map[string]bool)
@@ -203,19 +311,22 @@ func (s *ConfigFileService) patchGPU(podSpec map[string]interface{}, p project.P
 		}
 		limits["nvidia.com/gpu"] = gpuQtyStr
 
-		// MPS Environment Variables
-		if p.MPSMemory > 0 {
-			env, ok := c["env"].([]interface{})
-			if !ok {
-				env = make([]interface{}, 0)
+		// MPS Environment Variables, replacing any the container sets itself
+		mpsEnv := projectMPSConfig(p, finalGPU).ToEnvVars()
+		env, _ := c["env"].([]interface{})
+		kept := make([]interface{}, 0, len(env)+len(mpsEnv))
+		for _, e := range env {
+			if item, ok := e.(map[string]interface{}); ok {
+				if name, _ := item["name"].(string); mpsEnv[name] != "" {
+					continue
+				}
 			}
-			memoryBytes := int64(p.MPSMemory) * 1024 * 1024
-			env = append(env, map[string]interface{}{
-				"name":  "CUDA_MPS_PINNED_DEVICE_MEM_LIMIT",
-				"value": fmt.Sprintf("%d", memoryBytes),
-			})
-			c["env"] = env
+			kept = append(kept, e)
+		}
+		for _, name := range slices.Sorted(maps.Keys(mpsEnv)) {
+			kept = append(kept, map[string]interface{}{"name": name, "value": mpsEnv[name]})
 		}
+		c["env"] = kept
 	}
 	return nil
 }
@@ -0,0 +1,131 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// pulledImageRepo reports every allowed image as already pulled into Harbor.
+type pulledImageRepo struct {
+	*fakeRepo
+}
+
+func (r *pulledImageRepo) FindAllowListRule(projectID *uint, repoFullName, tagName string) (*image.ImageAllowList, error) {
+	return &image.ImageAllowList{
+		Repository: image.ContainerRepository{Name: repoFullName},
+		Tag:        image.ContainerTag{Name: tagName},
+	}, nil
+}
+
+func (r *pulledImageRepo) GetClusterStatus(tagID uint) (*image.ClusterImageStatus, error) {
+	return &image.ClusterImageStatus{IsPulled: true}, nil
+}
+
+func TestPatchImagesRewritesInitContainers(t *testing.T) {
+	origPrefix, origSecret := config.HarborPrivatePrefix, config.HarborPullSecretName
+	t.Cleanup(func() { config.HarborPrivatePrefix, config.HarborPullSecretName = origPrefix, origSecret })
+	config.HarborPrivatePrefix = "harbor.local/library/"
+	config.HarborPullSecretName = "harbor-regcred"
+
+	svc := &ConfigFileService{imageService: NewImageService(&pulledImageRepo{newFakeRepo()})}
+	podSpec := func() map[string]interface{} {
+		return map[string]interface{}{
+			"initContainers": []interface{}{map[string]interface{}{"name": "fetch", "image": "busybox:1.36"}},
+			"containers":     []interface{}{map[string]interface{}{"name": "main", "image": "harbor.local/library/trainer:v1"}},
+		}
+	}
+	manifests := map[string]map[string]interface{}{
+		"Pod": {"kind": "Pod", "spec": podSpec()},
+		"CronJob": {"kind": "CronJob", "spec": map[string]interface{}{
+			"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{
+				"template": map[string]interface{}{"spec": podSpec()},
+			}},
+		}},
+	}
+
+	for kind, obj := range manifests {
+		ctx := &PatchContext{ProjectID: 1, UserIsAdmin: true}
+		if err := svc.applyResourcePatches(obj, ctx); err != nil {
+			t.Fatalf("%s: applyResourcePatches: %v", kind, err)
+		}
+		specs := findPodSpecs(obj)
+		if len(specs) != 1 {
+			t.Fatalf("%s: expected one pod spec, got %d", kind, len(specs))
+		}
+		spec := specs[0]
+		init := spec["initContainers"].([]interface{})[0].(map[string]interface{})
+		if init["image"] != "harbor.local/library/busybox:1.36" {
+			t.Fatalf("%s: init container image = %v", kind, init["image"])
+		}
+		main := spec["containers"].([]interface{})[0].(map[string]interface{})
+		if main["image"] != "harbor.local/library/trainer:v1" {
+			t.Fatalf("%s: prefixed image was rewritten again: %v", kind, main["image"])
+		}
+		secrets, _ := spec["imagePullSecrets"].([]interface{})
+		if len(secrets) != 1 || secrets[0].(map[string]interface{})["name"] != "harbor-regcred" {
+			t.Fatalf("%s: imagePullSecrets = %v", kind, secrets)
+		}
+		if !ctx.UsesHarborImage {
+			t.Fatalf("%s: expected UsesHarborImage", kind)
+		}
+
+		// Patching again must not add the secret twice
+		if patchImagePullSecret(spec); len(spec["imagePullSecrets"].([]interface{})) != 1 {
+			t.Fatalf("%s: pull secret appended twice", kind)
+		}
+	}
+}
+
+func TestEnsureImagePullSecretIsIdempotent(t *testing.T) {
+	origClient := k8s.Clientset
+	origName, origNs := config.HarborPullSecretName, config.HarborPullSecretNamespace
+	t.Cleanup(func() {
+		k8s.Clientset = origClient
+		config.HarborPullSecretName, config.HarborPullSecretNamespace = origName, origNs
+	})
+	config.HarborPullSecretName = "harbor-regcred"
+	config.HarborPullSecretNamespace = "default"
+
+	client := k8sfake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "harbor-regcred", Namespace: "default"},
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "harbor-regcred", Namespace: "proj-2-bob"},
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"custom":{}}}`)},
+		},
+	)
+	k8s.Clientset = client
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := ensureImagePullSecret(ctx, "proj-1-alice"); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	copied, err := client.CoreV1().Secrets("proj-1-alice").Get(ctx, "harbor-regcred", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("secret not copied: %v", err)
+	}
+	if copied.Type != corev1.SecretTypeDockerConfigJson || string(copied.Data[corev1.DockerConfigJsonKey]) != `{"auths":{}}` {
+		t.Fatalf("unexpected copied secret: %+v", copied)
+	}
+
+	if err := ensureImagePullSecret(ctx, "proj-2-bob"); err != nil {
+		t.Fatalf("existing secret: %v", err)
+	}
+	kept, _ := client.CoreV1().Secrets("proj-2-bob").Get(ctx, "harbor-regcred", metav1.GetOptions{})
+	if string(kept.Data[corev1.DockerConfigJsonKey]) != `{"auths":{"custom":{}}}` {
+		t.Fatal("existing secret was overwritten")
+	}
+}
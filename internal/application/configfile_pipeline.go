@@ -0,0 +1,256 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/datatypes"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Steps of the instance pipeline, as reported in InstanceStepError.Step.
+const (
+	InstanceStepTemplate   = "template"
+	InstanceStepDecode     = "decode"
+	InstanceStepImages     = "images"
+	InstanceStepReadOnly   = "read_only"
+	InstanceStepGPU        = "gpu"
+	InstanceStepResources  = "resource_defaults"
+	InstanceStepSecurity   = "security_context"
+	InstanceStepEnv        = "env_defaults"
+	InstanceStepScheduling = "scheduling"
+	InstanceStepProvenance = "image_provenance"
+	InstanceStepApply      = "apply"
+)
+
+// InstanceStepError is the failure of one document of a config file instance. Index is the
+// position of the document in the config file, from 0.
+type InstanceStepError struct {
+	Index int `json:"index"`
+	// Resource is the Kind/name of the document
+	Resource string `json:"resource"`
+	Step     string `json:"step"`
+	Message  string `json:"message"`
+	Err      error  `json:"-"`
+}
+
+func (e *InstanceStepError) Error() string {
+	return fmt.Sprintf("document %d (%s) failed at %s: %v", e.Index, e.Resource, e.Step, e.Err)
+}
+
+func (e *InstanceStepError) Unwrap() error {
+	return e.Err
+}
+
+// InstanceError lists the documents that stopped an instance. Nothing of the instance is left in
+// the cluster when it is returned.
+type InstanceError struct {
+	Failures []*InstanceStepError
+}
+
+func (e *InstanceError) Error() string {
+	parts := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		parts[i] = f.Error()
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *InstanceError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f
+	}
+	return errs
+}
+
+// instanceDocContext carries the inputs of the steps for one document and what they report back
+// through Patch.
+type instanceDocContext struct {
+	Index int
+	// Resource is the Kind/name of the document, refreshed by the decode step
+	Resource string
+	Values   map[string]string
+	Patch    *PatchContext
+}
+
+// instanceStep is one stage of the instance pipeline. Run receives the document produced by the
+// previous step and returns the one for the next step.
+type instanceStep interface {
+	Name() string
+	Run(doc []byte, dc *instanceDocContext) ([]byte, error)
+}
+
+// templateStep replaces the {{placeholders}} of the document.
+type templateStep struct{}
+
+func (templateStep) Name() string { return InstanceStepTemplate }
+
+func (templateStep) Run(doc []byte, dc *instanceDocContext) ([]byte, error) {
+	out, err := utils.ReplacePlaceholdersInJSON(string(doc), dc.Values)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// decodeStep checks the rendered document is a JSON object and records its Kind/name.
+type decodeStep struct{}
+
+func (decodeStep) Name() string { return InstanceStepDecode }
+
+func (decodeStep) Run(doc []byte, dc *instanceDocContext) ([]byte, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return nil, err
+	}
+	if ref := objectRef(obj); ref != "/" {
+		dc.Resource = ref
+	}
+	return doc, nil
+}
+
+// podSpecStep applies one patch to every pod spec of the document. Documents without pod specs,
+// such as Services and ConfigMaps, pass through unchanged.
+type podSpecStep struct {
+	name  string
+	patch func(spec map[string]interface{}, ctx *PatchContext) error
+}
+
+func (p podSpecStep) Name() string { return p.name }
+
+func (p podSpecStep) Run(doc []byte, dc *instanceDocContext) ([]byte, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return nil, err
+	}
+	specs := findPodSpecs(obj)
+	if len(specs) == 0 {
+		return doc, nil
+	}
+	for _, spec := range specs {
+		if err := p.patch(spec, dc.Patch); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(obj)
+}
+
+// provenanceStep annotates each pod template with the approval behind its image, taken from the
+// first container whose image is allow-listed. Admins bypass the allow list, so their workloads
+// get none.
+type provenanceStep struct {
+	images *ImageService
+}
+
+func (provenanceStep) Name() string { return InstanceStepProvenance }
+
+func (p provenanceStep) Run(doc []byte, dc *instanceDocContext) ([]byte, error) {
+	if dc.Patch.UserIsAdmin || p.images == nil {
+		return doc, nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return nil, err
+	}
+	changed := false
+	for _, tmpl := range findPodTemplates(obj) {
+		spec, _ := tmpl["spec"].(map[string]interface{})
+		for _, cont := range getContainersFromPodSpec(spec) {
+			img, _ := cont["image"].(string)
+			if img == "" {
+				continue
+			}
+			prov, err := p.images.ImageProvenance(img, &dc.Patch.ProjectID)
+			if err != nil {
+				return nil, err
+			}
+			if prov == nil {
+				continue
+			}
+			setTemplateAnnotations(tmpl, prov.Annotations())
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return doc, nil
+	}
+	return json.Marshal(obj)
+}
+
+// setTemplateAnnotations merges annotations into the metadata of a pod template.
+func setTemplateAnnotations(tmpl map[string]interface{}, annotations map[string]string) {
+	meta, _ := tmpl["metadata"].(map[string]interface{})
+	if meta == nil {
+		meta = map[string]interface{}{}
+		tmpl["metadata"] = meta
+	}
+	existing, _ := meta["annotations"].(map[string]interface{})
+	if existing == nil {
+		existing = map[string]interface{}{}
+		meta["annotations"] = existing
+	}
+	for k, v := range annotations {
+		existing[k] = v
+	}
+}
+
+// instanceSteps returns the pipeline every document of an instance goes through, in order.
+func (s *ConfigFileService) instanceSteps() []instanceStep {
+	steps := []instanceStep{templateStep{}, decodeStep{}}
+	for _, p := range s.podSpecSteps() {
+		steps = append(steps, p)
+	}
+	return append(steps, provenanceStep{images: s.imageService})
+}
+
+// runInstanceSteps passes doc through steps, stopping at the first that fails.
+func runInstanceSteps(steps []instanceStep, doc []byte, dc *instanceDocContext) ([]byte, *InstanceStepError) {
+	for _, step := range steps {
+		out, err := step.Run(doc, dc)
+		if err != nil {
+			return nil, &InstanceStepError{Index: dc.Index, Resource: dc.Resource, Step: step.Name(), Message: err.Error(), Err: err}
+		}
+		doc = out
+	}
+	return doc, nil
+}
+
+// applyInstance creates the documents of a rendered instance in order and returns the created
+// objects. When one fails, those already created are deleted again, newest first, so a failed
+// instance leaves nothing behind.
+func applyInstance(rendered *instanceRender, owner map[string]string) ([]*unstructured.Unstructured, error) {
+	ns := rendered.namespace
+	created := make([]*unstructured.Unstructured, 0, len(rendered.objects))
+	for i, doc := range rendered.objects {
+		obj, err := k8s.CreateByJson(datatypes.JSON(doc), ns, owner)
+		if err == nil {
+			created = append(created, obj)
+			continue
+		}
+		rollbackInstance(rendered, created)
+		return nil, &InstanceError{Failures: []*InstanceStepError{{
+			Index:    i,
+			Resource: rendered.refs[i],
+			Step:     InstanceStepApply,
+			Message:  err.Error(),
+			Err:      fmt.Errorf("failed to create resource in k8s: %w", err),
+		}}}
+	}
+	return created, nil
+}
+
+// rollbackInstance deletes the created objects of a rendered instance, newest first, by the
+// names the server gave them.
+func rollbackInstance(rendered *instanceRender, created []*unstructured.Unstructured) {
+	for j := len(created) - 1; j >= 0; j-- {
+		if derr := k8s.DeleteByJson(rendered.objects[j], rendered.namespace, created[j].GetName()); derr != nil {
+			log.Printf("[Warning] Failed to roll back %s in %s: %v", rendered.refs[j], rendered.namespace, derr)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+const pipelinePod = `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"train"},"spec":{
+	"volumes":[{"name":"data","persistentVolumeClaim":{"claimName":"proj-pvc"}}],
+	"containers":[{"name":"main","image":"busybox:1.36","volumeMounts":[{"name":"data","mountPath":"/data"}]}]}}`
+
+func pipelineStep(t *testing.T, svc *ConfigFileService, name string) instanceStep {
+	t.Helper()
+	for _, step := range svc.instanceSteps() {
+		if step.Name() == name {
+			return step
+		}
+	}
+	t.Fatalf("no step %s", name)
+	return nil
+}
+
+// runPodSpecStep runs the named step over doc and returns the first pod spec of the result.
+func runPodSpecStep(t *testing.T, svc *ConfigFileService, name, doc string, ctx *PatchContext) map[string]interface{} {
+	t.Helper()
+	out, err := pipelineStep(t, svc, name).Run([]byte(doc), &instanceDocContext{Patch: ctx})
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(out, &obj); err != nil {
+		t.Fatalf("%s returned invalid JSON: %v", name, err)
+	}
+	return findPodSpecs(obj)[0]
+}
+
+func firstContainer(spec map[string]interface{}) map[string]interface{} {
+	return getContainersFromPodSpec(spec)[0]
+}
+
+func TestTemplateStep(t *testing.T) {
+	dc := &instanceDocContext{Values: map[string]string{"projectVolume": "proj-pvc"}}
+	out, err := templateStep{}.Run([]byte(`{"claimName":"{{projectVolume}}"}`), dc)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var obj map[string]string
+	if err := json.Unmarshal(out, &obj); err != nil || obj["claimName"] != "proj-pvc" {
+		t.Fatalf("unexpected document %s", out)
+	}
+	if _, err := (templateStep{}).Run([]byte(`{`), dc); err == nil {
+		t.Fatal("expected invalid JSON to fail")
+	}
+}
+
+func TestDecodeStep(t *testing.T) {
+	dc := &instanceDocContext{Resource: "pod/stored-name"}
+	if _, err := (decodeStep{}).Run([]byte(pipelinePod), dc); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if dc.Resource != "Pod/train" {
+		t.Fatalf("expected the rendered Kind/name, got %s", dc.Resource)
+	}
+
+	dc = &instanceDocContext{Resource: "pod/stored-name"}
+	if _, err := (decodeStep{}).Run([]byte(`{}`), dc); err != nil || dc.Resource != "pod/stored-name" {
+		t.Fatalf("an object without kind should keep the stored name: %s, %v", dc.Resource, err)
+	}
+	if _, err := (decodeStep{}).Run([]byte(`["not","an","object"]`), dc); err == nil {
+		t.Fatal("expected a non-object document to fail")
+	}
+}
+
+func TestPodSpecStepsLeaveNonWorkloadsAlone(t *testing.T) {
+	svc := &ConfigFileService{imageService: NewImageService(newFakeRepo())}
+	doc := []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cfg"},"data":{"a":"b"}}`)
+	for _, step := range svc.podSpecSteps() {
+		out, err := step.Run(doc, &instanceDocContext{Patch: &PatchContext{}})
+		if err != nil {
+			t.Fatalf("%s: %v", step.Name(), err)
+		}
+		if !bytes.Equal(out, doc) {
+			t.Fatalf("%s changed a ConfigMap: %s", step.Name(), out)
+		}
+	}
+}
+
+func TestImagesStepRejectsImagesNotAllowed(t *testing.T) {
+	svc := &ConfigFileService{imageService: NewImageService(newFakeRepo())}
+	_, err := pipelineStep(t, svc, InstanceStepImages).Run([]byte(pipelinePod), &instanceDocContext{Patch: &PatchContext{ProjectID: 1}})
+	if !errors.Is(err, ErrImageNotAllowed) {
+		t.Fatalf("expected ErrImageNotAllowed, got %v", err)
+	}
+
+	spec := runPodSpecStep(t, svc, InstanceStepImages, pipelinePod, &PatchContext{ProjectID: 1, UserIsAdmin: true})
+	if firstContainer(spec)["image"] != "busybox:1.36" {
+		t.Fatalf("admin image should be kept, got %v", firstContainer(spec)["image"])
+	}
+}
+
+func TestReadOnlyStep(t *testing.T) {
+	svc := &ConfigFileService{}
+	mount := func(spec map[string]interface{}) map[string]interface{} {
+		return firstContainer(spec)["volumeMounts"].([]interface{})[0].(map[string]interface{})
+	}
+
+	spec := runPodSpecStep(t, svc, InstanceStepReadOnly, pipelinePod, &PatchContext{ShouldEnforceRO: true, ProjectPVCs: []string{"proj-pvc"}})
+	if mount(spec)["readOnly"] != true {
+		t.Fatalf("expected the project volume to be mounted read-only, got %v", mount(spec))
+	}
+	spec = runPodSpecStep(t, svc, InstanceStepReadOnly, pipelinePod, &PatchContext{ProjectPVCs: []string{"proj-pvc"}})
+	if _, set := mount(spec)["readOnly"]; set {
+		t.Fatalf("managers should keep write access, got %v", mount(spec))
+	}
+}
+
+func TestGPUStepRequiresQuota(t *testing.T) {
+	svc := &ConfigFileService{}
+	doc := `{"kind":"Pod","metadata":{"name":"gpu"},"spec":{"containers":[{"name":"main","resources":{"requests":{"nvidia.com/gpu":"1"}}}]}}`
+	_, err := pipelineStep(t, svc, InstanceStepGPU).Run([]byte(doc), &instanceDocContext{Patch: &PatchContext{Project: project.Project{PID: 1}}})
+	if err == nil {
+		t.Fatal("expected a GPU request without project quota to fail")
+	}
+	runPodSpecStep(t, svc, InstanceStepGPU, doc, &PatchContext{Project: project.Project{PID: 1, GPUQuota: 2}})
+}
+
+func TestResourceDefaultsStep(t *testing.T) {
+	cpu, mem := k8sresource.MustParse("500m"), k8sresource.MustParse("1Gi")
+	ctx := &PatchContext{ResourceDefaults: &ResourceDefaults{CPU: &cpu, Memory: &mem, Multiplier: 1}}
+	spec := runPodSpecStep(t, &ConfigFileService{}, InstanceStepResources, pipelinePod, ctx)
+	if _, ok := firstContainer(spec)["resources"].(map[string]interface{}); !ok {
+		t.Fatalf("expected resources to be injected, got %v", firstContainer(spec))
+	}
+	if len(ctx.InjectedResources) != 1 {
+		t.Fatalf("expected one injected resources record, got %+v", ctx.InjectedResources)
+	}
+}
+
+func TestSecurityContextStep(t *testing.T) {
+	spec := runPodSpecStep(t, &ConfigFileService{}, InstanceStepSecurity, pipelinePod, &PatchContext{})
+	sc, _ := spec["securityContext"].(map[string]interface{})
+	if sc["runAsUser"] == nil || sc["fsGroup"] == nil {
+		t.Fatalf("expected the pod security context with fsGroup, got %v", sc)
+	}
+}
+
+func TestEnvDefaultsStep(t *testing.T) {
+	ctx := &PatchContext{EnvDefaults: []corev1.EnvVar{{Name: "WANDB_MODE", Value: "offline"}}}
+	spec := runPodSpecStep(t, &ConfigFileService{}, InstanceStepEnv, pipelinePod, ctx)
+	env, _ := firstContainer(spec)["env"].([]interface{})
+	if len(env) != 1 || env[0].(map[string]interface{})["name"] != "WANDB_MODE" {
+		t.Fatalf("expected the project env default, got %v", env)
+	}
+}
+
+func TestSchedulingStep(t *testing.T) {
+	ctx := &PatchContext{Scheduling: &k8s.SchedulingPolicy{NodeSelector: map[string]string{"pool": "gpu"}}}
+	spec := runPodSpecStep(t, &ConfigFileService{}, InstanceStepScheduling, pipelinePod, ctx)
+	if sel, _ := spec["nodeSelector"].(map[string]interface{}); sel["pool"] != "gpu" {
+		t.Fatalf("expected the project node selector, got %v", spec["nodeSelector"])
+	}
+}
+
+func TestRunInstanceStepsReportsDocumentAndStep(t *testing.T) {
+	svc := &ConfigFileService{imageService: NewImageService(newFakeRepo())}
+	doc := `{"kind":"Pod","metadata":{"name":"{{username}}-gpu"},"spec":{"containers":[{"name":"main","resources":{"requests":{"nvidia.com/gpu":"1"}}}]}}`
+	dc := &instanceDocContext{
+		Index:    3,
+		Resource: "pod/gpu",
+		Values:   map[string]string{"username": "alice"},
+		Patch:    &PatchContext{ProjectID: 1, UserIsAdmin: true, Project: project.Project{PID: 1}},
+	}
+	_, failure := runInstanceSteps(svc.instanceSteps(), []byte(doc), dc)
+	if failure == nil {
+		t.Fatal("expected the GPU step to fail")
+	}
+	if failure.Index != 3 || failure.Resource != "Pod/alice-gpu" || failure.Step != InstanceStepGPU || failure.Message == "" {
+		t.Fatalf("unexpected failure %+v", failure)
+	}
+
+	err := error(&InstanceError{Failures: []*InstanceStepError{failure, {Index: 4, Step: InstanceStepImages, Err: ErrImageNotAllowed}}})
+	if !errors.Is(err, ErrImageNotAllowed) {
+		t.Fatalf("InstanceError should unwrap to the step errors: %v", err)
+	}
+}
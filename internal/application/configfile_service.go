@@ -8,7 +8,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/linskybing/platform-go/internal/domain/configfile"
 	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
 	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/mail"
 	"github.com/linskybing/platform-go/pkg/utils"
 )
 
@@ -19,17 +21,37 @@ var (
 	ErrUploadYAMLFailed     = errors.New("failed to upload YAML file")
 	ErrInvalidResourceLimit = errors.New("invalid resource limit specified in YAML")
 	ErrInvalidVolumeMounts  = errors.New("invalid volume/volumeMount definition in YAML")
+
+	ErrConfigFileVersionRequired = errors.New("version is required to update a config file")
+	ErrConfigFileVersionConflict = errors.New("config file was modified by someone else")
 )
 
+// ConfigFileVersionConflictError is returned when an update was based on an outdated version.
+// Current is the file as it is stored now, so the client can show it and merge.
+type ConfigFileVersionConflictError struct {
+	Current *configfile.ConfigFile
+}
+
+func (e *ConfigFileVersionConflictError) Error() string {
+	return fmt.Sprintf("%s: the current version is %d", ErrConfigFileVersionConflict, e.Current.Version)
+}
+
+func (e *ConfigFileVersionConflictError) Unwrap() error {
+	return ErrConfigFileVersionConflict
+}
+
 type ConfigFileService struct {
 	Repos        *repository.Repos
 	imageService *ImageService
+	// sender emails requesters about the decisions on their instances; nil disables the emails
+	sender mail.Sender
 }
 
 func NewConfigFileService(repos *repository.Repos) *ConfigFileService {
 	return &ConfigFileService{
 		Repos:        repos,
 		imageService: NewImageService(repos.Image),
+		sender:       configuredMailSender(),
 	}
 }
 
@@ -46,12 +68,25 @@ func (s *ConfigFileService) ListConfigFilesByProjectID(projectID uint) ([]config
 }
 
 func (s *ConfigFileService) CreateConfigFile(c *gin.Context, cf configfile.CreateConfigFileInput) (*configfile.ConfigFile, error) {
+	return s.createConfigFile(c, &configfile.ConfigFile{
+		Filename:  cf.Filename,
+		Content:   cf.RawYaml,
+		ProjectID: cf.ProjectID,
+	})
+}
+
+// createConfigFile validates the content and stores the file with its parsed resources.
+func (s *ConfigFileService) createConfigFile(c *gin.Context, createdCF *configfile.ConfigFile) (*configfile.ConfigFile, error) {
 	// Performance: Parse and validate BEFORE opening a DB transaction
-	resourcesToCreate, err := s.parseAndValidateResources(cf.RawYaml)
+	resourcesToCreate, warnings, err := s.validateNewConfigFile(c, createdCF)
 	if err != nil {
 		return nil, err
 	}
 
+	if createdCF.Version == 0 {
+		createdCF.Version = 1
+	}
+
 	tx := s.Repos.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -59,12 +94,6 @@ func (s *ConfigFileService) CreateConfigFile(c *gin.Context, cf configfile.Creat
 		}
 	}()
 
-	createdCF := &configfile.ConfigFile{
-		Filename:  cf.Filename,
-		Content:   cf.RawYaml,
-		ProjectID: cf.ProjectID,
-	}
-
 	if err := s.Repos.ConfigFile.WithTx(tx).CreateConfigFile(createdCF); err != nil {
 		tx.Rollback()
 		return nil, err
@@ -72,6 +101,7 @@ func (s *ConfigFileService) CreateConfigFile(c *gin.Context, cf configfile.Creat
 
 	for _, res := range resourcesToCreate {
 		res.CFID = createdCF.CFID
+		res.CFVersion = createdCF.Version
 		if err := s.Repos.Resource.WithTx(tx).CreateResource(res); err != nil {
 			tx.Rollback()
 			return nil, fmt.Errorf("failed to create resource %s/%s: %w", res.Type, res.Name, err)
@@ -82,76 +112,130 @@ func (s *ConfigFileService) CreateConfigFile(c *gin.Context, cf configfile.Creat
 		return nil, fmt.Errorf("transaction commit failed: %w", res.Error)
 	}
 
+	createdCF.Warnings = warnings
+
+	// The audit goroutine gets its own copy, since the caller keeps using createdCF
 	logFn := utils.LogAuditWithConsole
-	go func(fn func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo)) {
-		fn(c, "create", "config_file", fmt.Sprintf("cf_id=%d", createdCF.CFID), nil, *createdCF, "", s.Repos.Audit)
-	}(logFn)
+	go func(fn func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo), cf configfile.ConfigFile) {
+		fn(c, "create", "config_file", fmt.Sprintf("cf_id=%d", cf.CFID), nil, cf, "", s.Repos.Audit)
+	}(logFn, *createdCF)
 
 	return createdCF, nil
 }
 
+// validateNewConfigFile normalizes the filename of cf and runs the checks of a new config file,
+// returning its parsed resources and the limits an admin upload was allowed to exceed.
+func (s *ConfigFileService) validateNewConfigFile(c *gin.Context, cf *configfile.ConfigFile) ([]*resource.Resource, []string, error) {
+	filename, err := utils.NormalizeFilename(cf.Filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	cf.Filename = filename
+
+	resources, err := s.parseAndValidateResources(cf.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings, err := checkConfigFileDataLimits(c, resources)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkConfigFilePodSecurity(c, cf.ProjectID, resources); err != nil {
+		return nil, nil, err
+	}
+	return resources, warnings, nil
+}
+
+// UpdateConfigFile applies an update made against input.Version. The version check, the version
+// bump and the resource sync happen in one transaction, so of two concurrent updates based on the
+// same version only the first is applied and the second gets a *ConfigFileVersionConflictError.
 func (s *ConfigFileService) UpdateConfigFile(c *gin.Context, id uint, input configfile.ConfigFileUpdateDTO) (*configfile.ConfigFile, error) {
+	if input.Version == nil {
+		return nil, ErrConfigFileVersionRequired
+	}
 	existing, err := s.Repos.ConfigFile.GetConfigFileByID(id)
 	if err != nil {
 		return nil, ErrConfigFileNotFound
 	}
+	if existing.Version != *input.Version {
+		return nil, &ConfigFileVersionConflictError{Current: existing}
+	}
 
 	oldCF := *existing
+	var warnings []string
+	var newResources []*resource.Resource
 
 	if input.Filename != nil {
-		existing.Filename = *input.Filename
+		filename, err := utils.NormalizeFilename(*input.Filename)
+		if err != nil {
+			return nil, err
+		}
+		existing.Filename = filename
 	}
 
 	if input.RawYaml != nil {
 		// Prepare new resources first
-		newResources, err := s.parseAndValidateResources(*input.RawYaml)
+		newResources, err = s.parseAndValidateResources(*input.RawYaml)
 		if err != nil {
 			return nil, err
 		}
-
-		// Use helper to handle the diff logic (delete old, create/update new)
-		// We pass the parsed resources to avoid re-parsing inside the helper
-		if err = s.syncConfigFileResources(c, existing, *input.RawYaml, newResources); err != nil {
+		if warnings, err = checkConfigFileDataLimits(c, newResources); err != nil {
+			return nil, err
+		}
+		if err := s.checkConfigFilePodSecurity(c, existing.ProjectID, newResources); err != nil {
 			return nil, err
 		}
 		existing.Content = *input.RawYaml
 	}
 
-	err = s.Repos.ConfigFile.UpdateConfigFile(existing)
+	tx := s.Repos.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	updated, err := s.Repos.ConfigFile.WithTx(tx).UpdateConfigFileIfVersion(existing, *input.Version)
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
+	if !updated {
+		tx.Rollback()
+		current, err := s.Repos.ConfigFile.GetConfigFileByID(id)
+		if err != nil {
+			return nil, ErrConfigFileNotFound
+		}
+		return nil, &ConfigFileVersionConflictError{Current: current}
+	}
+
+	if input.RawYaml != nil {
+		// Use helper to handle the diff logic (delete old, create/update new)
+		// We pass the parsed resources to avoid re-parsing inside the helper
+		if err = s.syncConfigFileResources(c, s.Repos.Resource.WithTx(tx), existing, newResources); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if res := tx.Commit(); res.Error != nil {
+		return nil, fmt.Errorf("transaction commit failed: %w", res.Error)
+	}
 
 	utils.LogAuditWithConsole(c, "update", "config_file", fmt.Sprintf("cf_id=%d", existing.CFID), oldCF, *existing, "", s.Repos.Audit)
 
+	existing.Warnings = warnings
 	return existing, nil
 }
 
+// DeleteConfigFile moves the file to the trash. Deployed instances are left running until the
+// file is purged, so an accidental delete can be undone with RestoreConfigFile.
 func (s *ConfigFileService) DeleteConfigFile(c *gin.Context, id uint) error {
 	cf, err := s.Repos.ConfigFile.GetConfigFileByID(id)
 	if err != nil {
 		return ErrConfigFileNotFound
 	}
 
-	// 1. Clean up K8s resources
-	if err := s.DeleteConfigFileInstance(id); err != nil {
-		// Log warning but proceed to delete DB records if possible, or return error depending on policy
-		fmt.Printf("[Warning] Failed to cleanup K8s resources for CF %d: %v\n", id, err)
-	}
-
-	// 2. Clean up DB resources
-	resources, err := s.Repos.Resource.ListResourcesByConfigFileID(id)
-	if err != nil {
-		return err
-	}
-
-	for _, res := range resources {
-		if err := s.Repos.Resource.DeleteResource(res.RID); err != nil {
-			return err
-		}
-	}
-
-	// 3. Delete ConfigFile
 	if err := s.Repos.ConfigFile.DeleteConfigFile(id); err != nil {
 		return err
 	}
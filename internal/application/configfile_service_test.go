@@ -1,11 +1,13 @@
 package application_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +17,7 @@ import (
 	"github.com/linskybing/platform-go/internal/domain/group"
 	"github.com/linskybing/platform-go/internal/domain/project"
 	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/domain/user"
 	"github.com/linskybing/platform-go/internal/domain/view"
 	"github.com/linskybing/platform-go/internal/repository"
 	"github.com/linskybing/platform-go/internal/repository/mock"
@@ -24,6 +27,15 @@ import (
 	"gorm.io/datatypes"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func setupMocks(t *testing.T) (*application.ConfigFileService, *mock.MockConfigFileRepo,
@@ -39,8 +51,16 @@ func setupMocks(t *testing.T) (*application.ConfigFileService, *mock.MockConfigF
 	mockProject := mock.NewMockProjectRepo(ctrl)
 	mockUserGroup := mock.NewMockUserGroupRepo(ctrl)
 	mockUser := mock.NewMockUserRepo(ctrl)
+	// Users already have their storage ID, named after the username
+	mockUser.EXPECT().GetUserByUsername(gomock.Any()).DoAndReturn(func(name string) (user.User, error) {
+		return user.User{Username: name, StorageID: name}, nil
+	}).AnyTimes()
+	mockEnv := mock.NewMockProjectEnvRepo(ctrl)
+	mockEnv.EXPECT().ListByProject(gomock.Any()).Return(nil, nil).AnyTimes()
 	// create base repos with an in-memory sqlite gorm DB so Begin() is safe, then inject mocks
 	dbConn, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	_ = dbConn.AutoMigrate(&project.SchedulingPolicy{}, &project.RegistryCredential{}, &resource.DeployedObject{}, &configfile.InstanceApprovalRequest{},
+		&configfile.BootstrapBundle{}, &configfile.ProjectBootstrapBundle{}, &configfile.BootstrapObject{})
 	baseRepos := repository.NewRepositories(dbConn)
 	baseRepos.ConfigFile = mockCF
 	baseRepos.Resource = mockRes
@@ -48,6 +68,7 @@ func setupMocks(t *testing.T) (*application.ConfigFileService, *mock.MockConfigF
 	baseRepos.Project = mockProject
 	baseRepos.UserGroup = mockUserGroup
 	baseRepos.User = mockUser
+	baseRepos.ProjectEnv = mockEnv
 	repos := baseRepos
 	// (db already set in baseRepos)
 	svc := application.NewConfigFileService(repos)
@@ -63,7 +84,9 @@ func setupMocks(t *testing.T) (*application.ConfigFileService, *mock.MockConfigF
 	c.Set("claims", &types.Claims{Username: "testuser", UserID: 1})
 
 	// mock utils (k8s functions use mock behavior when Mapper/DynamicClient/Clientset are nil)
-	utils.SplitYAMLDocuments = func(yamlStr string) []string { return []string{yamlStr} }
+	utils.SplitYAMLDocumentsWithLines = func(yamlStr string) []utils.YAMLDocument {
+		return []utils.YAMLDocument{{Content: yamlStr, StartLine: 1}}
+	}
 	utils.LogAuditWithConsole = func(c *gin.Context, action, resourceType, resourceID string, oldData, newData interface{}, msg string, repos repository.AuditRepo) {
 	}
 
@@ -105,7 +128,7 @@ func TestCreateConfigFile_Success(t *testing.T) {
 func TestCreateConfigFile_NoYAMLDocuments(t *testing.T) {
 	svc, _, _, _, _, _, _, c := setupMocks(t)
 
-	utils.SplitYAMLDocuments = func(yamlStr string) []string { return []string{} }
+	utils.SplitYAMLDocumentsWithLines = func(yamlStr string) []utils.YAMLDocument { return nil }
 
 	input := configfile.CreateConfigFileInput{
 		Filename:  "test.yaml",
@@ -119,6 +142,37 @@ func TestCreateConfigFile_NoYAMLDocuments(t *testing.T) {
 	}
 }
 
+// realSplitYAMLDocuments is captured before setupMocks replaces the splitter.
+var realSplitYAMLDocuments = utils.SplitYAMLDocumentsWithLines
+
+func TestCreateConfigFile_ReportsErrorsPerDocument(t *testing.T) {
+	svc, _, _, _, _, _, _, c := setupMocks(t)
+	utils.SplitYAMLDocumentsWithLines = realSplitYAMLDocuments
+
+	input := configfile.CreateConfigFileInput{
+		Filename: "test.yaml",
+		RawYaml: "apiVersion: v1\nkind: Pod\nmetadata:\n  name: ok\n" +
+			"---\napiVersion: v1\nmetadata:\n  name: nokind\n" +
+			"---\napiVersion: v1\nkind: Pod\nmetadata:\n  name: typo\nspec:\n  containers:\n  - name: c\n    imagee: nginx\n",
+		ProjectID: 1,
+	}
+
+	_, err := svc.CreateConfigFile(c, input)
+	var yamlErr *application.YAMLValidationError
+	if !errors.As(err, &yamlErr) {
+		t.Fatalf("expected YAMLValidationError, got %v", err)
+	}
+	if len(yamlErr.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %+v", yamlErr.Errors)
+	}
+	if e := yamlErr.Errors[0]; e.Document != 2 || e.Field != "kind" || e.Line != 6 {
+		t.Fatalf("unexpected missing kind error %+v", e)
+	}
+	if e := yamlErr.Errors[1]; e.Document != 3 || e.Field != "spec.containers[0].imagee" || e.Line != 17 || e.Snippet == "" {
+		t.Fatalf("unexpected unknown field error %+v", e)
+	}
+}
+
 func TestUpdateConfigFile_Success(t *testing.T) {
 	svc, mockCF, mockRes, mockAudit, _, _, _, c := setupMocks(t)
 
@@ -127,9 +181,13 @@ func TestUpdateConfigFile_Success(t *testing.T) {
 		CFID:      1,
 		ProjectID: 1,
 		Filename:  "old.yaml",
+		Version:   3,
 	}
 	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(existingCF, nil)
-	mockCF.EXPECT().UpdateConfigFile(gomock.Any()).Return(nil)
+	mockCF.EXPECT().UpdateConfigFileIfVersion(gomock.Any(), 3).DoAndReturn(func(cf *configfile.ConfigFile, version int) (bool, error) {
+		cf.Version = version + 1
+		return true, nil
+	})
 
 	// Mock Resource
 	mockRes.EXPECT().ListResourcesByConfigFileID(uint(1)).Return([]resource.Resource{}, nil)
@@ -144,8 +202,8 @@ func TestUpdateConfigFile_Success(t *testing.T) {
 	mockAudit.EXPECT().CreateAuditLog(gomock.Any()).Return(nil).AnyTimes()
 
 	// Mock utils: keep split behavior consistent so actual YAML is processed
-	utils.SplitYAMLDocuments = func(yamlStr string) []string {
-		return []string{yamlStr}
+	utils.SplitYAMLDocumentsWithLines = func(yamlStr string) []utils.YAMLDocument {
+		return []utils.YAMLDocument{{Content: yamlStr, StartLine: 1}}
 	}
 	// use actual k8s.ValidateK8sJSON implementation (pure function)
 	utils.LogAuditWithConsole = func(c *gin.Context, action, resourceType, resourceID string, oldData, newData interface{}, msg string, repos repository.AuditRepo) {
@@ -154,9 +212,11 @@ func TestUpdateConfigFile_Success(t *testing.T) {
 
 	filename := "new.yaml"
 	rawYaml := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: testpod"
+	version := 3
 	input := configfile.ConfigFileUpdateDTO{
 		Filename: &filename,
 		RawYaml:  &rawYaml,
+		Version:  &version,
 	}
 
 	cf, err := svc.UpdateConfigFile(c, 1, input)
@@ -166,32 +226,141 @@ func TestUpdateConfigFile_Success(t *testing.T) {
 	if cf.Filename != "new.yaml" {
 		t.Fatalf("expected filename new.yaml, got %s", cf.Filename)
 	}
+	if cf.Version != 4 {
+		t.Fatalf("expected version 4, got %d", cf.Version)
+	}
 }
 
 func TestDeleteConfigFile_Success(t *testing.T) {
-	svc, mockCF, mockRes, mockAudit, mockUser, _, _, c := setupMocks(t)
+	svc, mockCF, _, mockAudit, _, _, _, c := setupMocks(t)
 
 	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{
 		CFID: 1, ProjectID: 1, Filename: "test.yaml",
 	}, nil).AnyTimes()
 
+	// Deleting only moves the file to the trash: no resources or instances are touched
+	mockCF.EXPECT().DeleteConfigFile(uint(1)).Return(nil)
+	mockAudit.EXPECT().CreateAuditLog(gomock.Any()).Return(nil).AnyTimes()
+
+	err := svc.DeleteConfigFile(c, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPurgeExpiredConfigFilesContinuesAfterFailure(t *testing.T) {
+	svc, mockCF, mockRes, mockAudit, mockUser, mockProject, _, _ := setupMocks(t)
+	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10}, nil).AnyTimes()
+
+	mockCF.EXPECT().ListConfigFilesTrashedBefore(gomock.Any()).Return([]configfile.ConfigFile{
+		{CFID: 1, ProjectID: 1},
+		{CFID: 2, ProjectID: 1},
+	}, nil)
+	mockRes.EXPECT().ListResourcesByConfigFileID(uint(1)).Return(nil, errors.New("db down"))
+	mockRes.EXPECT().ListResourcesByConfigFileID(uint(2)).Return([]resource.Resource{{RID: 20, Name: "res2"}}, nil).Times(2)
+	// The member's namespace is gone; the purge must still go through
+	mockUser.EXPECT().ListUsersByProjectID(uint(1)).Return([]view.ProjectUserView{{Username: "left-the-course"}}, nil)
+	mockRes.EXPECT().DeleteResource(uint(20)).Return(nil)
+	mockCF.EXPECT().PurgeConfigFile(uint(2)).Return(nil)
+	mockAudit.EXPECT().CreateAuditLog(gomock.Any()).Return(nil).AnyTimes()
+
+	n, err := svc.PurgeExpiredConfigFiles()
+	if n != 1 {
+		t.Fatalf("expected the healthy file to be purged, got %d", n)
+	}
+	if err == nil {
+		t.Fatalf("expected the failed purge to be reported")
+	}
+}
+
+// setupMemberInstances serves the pod lab of config file 1 to alice, bob and carol. bob has
+// left and his namespace is gone; the others still run the pod.
+func setupMemberInstances(t *testing.T, mockRes *mock.MockResourceRepo, mockUser *mock.MockUserRepo) (*dynamicfake.FakeDynamicClient, schema.GroupVersionResource) {
+	t.Helper()
+	podGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.AddSpecific(podGVR.GroupVersion().WithKind("Pod"), podGVR, podGVR.GroupVersion().WithResource("pod"), meta.RESTScopeNamespace)
+
+	ctx := context.Background()
+	var pods []runtime.Object
+	for _, name := range []string{"alice", "carol"} {
+		ns := k8s.FormatNamespaceName(1, name)
+		_, _ = k8s.Clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}, metav1.CreateOptions{})
+		pod := &unstructured.Unstructured{}
+		pod.SetAPIVersion("v1")
+		pod.SetKind("Pod")
+		pod.SetName("lab")
+		pod.SetNamespace(ns)
+		pods = append(pods, pod)
+	}
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pods...)
+	origMapper, origDyn := k8s.Mapper, k8s.DynamicClient
+	t.Cleanup(func() { k8s.Mapper, k8s.DynamicClient = origMapper, origDyn })
+	k8s.Mapper, k8s.DynamicClient = mapper, dyn
+
 	mockRes.EXPECT().ListResourcesByConfigFileID(uint(1)).Return([]resource.Resource{
-		{RID: 10, Name: "res1"},
+		{RID: 10, CFID: 1, Type: "Pod", Name: "lab", ParsedYAML: datatypes.JSON(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"lab"}}`)},
 	}, nil).AnyTimes()
-
 	mockUser.EXPECT().ListUsersByProjectID(uint(1)).Return([]view.ProjectUserView{
-		{Username: "user1"},
+		{Username: "alice"}, {Username: "bob"}, {Username: "carol"},
 	}, nil)
+	return dyn, podGVR
+}
 
-	mockRes.EXPECT().DeleteResource(uint(10)).Return(nil).AnyTimes()
-	mockCF.EXPECT().DeleteConfigFile(uint(1)).Return(nil).AnyTimes()
+func TestPurgeConfigFileSkipsMissingNamespace(t *testing.T) {
+	svc, mockCF, mockRes, mockAudit, mockUser, mockProject, _, _ := setupMocks(t)
+	dyn, podGVR := setupMemberInstances(t, mockRes, mockUser)
+	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10}, nil).AnyTimes()
+	mockCF.EXPECT().ListConfigFilesTrashedBefore(gomock.Any()).Return([]configfile.ConfigFile{{CFID: 1, ProjectID: 1}}, nil)
+	mockRes.EXPECT().DeleteResource(uint(10)).Return(nil)
+	mockCF.EXPECT().PurgeConfigFile(uint(1)).Return(nil)
 	mockAudit.EXPECT().CreateAuditLog(gomock.Any()).Return(nil).AnyTimes()
 
-	// k8s.DeleteByJson is a function that uses mock behavior when k8s clients are nil, so no override needed
+	if n, err := svc.PurgeExpiredConfigFiles(); n != 1 || err != nil {
+		t.Fatalf("expected the file to be purged despite bob's missing namespace, got %d (%v)", n, err)
+	}
+	for _, name := range []string{"alice", "carol"} {
+		ns := k8s.FormatNamespaceName(1, name)
+		if _, err := dyn.Resource(podGVR).Namespace(ns).Get(context.Background(), "lab", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Fatalf("expected the pod of %s to be deleted, got %v", name, err)
+		}
+	}
+}
+
+func TestDeleteConfigFileInstanceReportsFailures(t *testing.T) {
+	svc, mockCF, mockRes, _, mockUser, mockProject, _, _ := setupMocks(t)
+	dyn, _ := setupMemberInstances(t, mockRes, mockUser)
+	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{CFID: 1, ProjectID: 1}, nil).Times(2)
+	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10}, nil).AnyTimes()
+	dyn.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == k8s.FormatNamespaceName(1, "alice") {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "lab", errors.New("denied by policy"))
+		}
+		return false, nil, nil
+	})
 
-	err := svc.DeleteConfigFile(c, 1)
+	report, err := svc.DeleteConfigFileInstance(1)
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatalf("a rejected delete must not stop the others, got %v", err)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Username != "alice" || report.Failed[0].Resource != "Pod/lab" || !report.Partial() {
+		t.Fatalf("expected alice's pod to fail, got %+v", report.Failed)
+	}
+	if len(report.Deleted) != 1 || report.Deleted[0].Username != "carol" {
+		t.Fatalf("expected carol's pod to be deleted, got %+v", report.Deleted)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Username != "bob" || report.Skipped[0].Resource != "" {
+		t.Fatalf("expected bob's namespace to be skipped, got %+v", report.Skipped)
+	}
+
+	// An unreachable API server stops the teardown instead of failing every object in turn
+	mockUser.EXPECT().ListUsersByProjectID(uint(1)).Return([]view.ProjectUserView{{Username: "alice"}, {Username: "carol"}}, nil)
+	dyn.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("apiserver is shutting down")
+	})
+	report, err = svc.DeleteConfigFileInstance(1)
+	if err == nil || len(report.Failed) != 1 {
+		t.Fatalf("expected the teardown to stop after the first object, got %+v (%v)", report, err)
 	}
 }
 
@@ -203,26 +372,160 @@ func TestCreateInstance_Success(t *testing.T) {
 	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10}, nil).AnyTimes()
 	mockUserGroup.EXPECT().GetUserGroup(uint(1), uint(10)).Return(group.UserGroup{UID: 1, GID: 10, Role: "admin"}, nil).AnyTimes()
 
-	err := svc.CreateInstance(c, 1)
+	_, err := svc.CreateInstance(c, 1)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
-func TestDeleteInstance_Success(t *testing.T) {
+func TestCreateInstance_FailsFastOnDegradedStorage(t *testing.T) {
 	svc, mockCF, mockRes, _, _, _, _, c := setupMocks(t)
+	ctx := context.Background()
+	// The hub namespace exists but its PVC never bound and the NFS deployment is gone
+	_, _ = k8s.Clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "user-testuser-storage"}}, metav1.CreateOptions{})
+	_, _ = k8s.Clientset.CoreV1().PersistentVolumeClaims("user-testuser-storage").Create(ctx, &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "user-testuser-disk"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}, metav1.CreateOptions{})
 
 	mockRes.EXPECT().ListResourcesByConfigFileID(uint(1)).Return([]resource.Resource{{RID: 1, ParsedYAML: datatypes.JSON([]byte("{}"))}}, nil)
 	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{CFID: 1, ProjectID: 1}, nil)
 
-	err := svc.DeleteInstance(c, 1)
+	_, err := svc.CreateInstance(c, 1)
+	if !errors.Is(err, application.ErrStorageDegraded) {
+		t.Fatalf("expected the degraded storage to stop the instance, got %v", err)
+	}
+}
+
+func TestCreateInstance_RollsBackPartialApply(t *testing.T) {
+	svc, mockCF, mockRes, _, _, mockProject, mockUserGroup, c := setupMocks(t)
+
+	cmGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	svcGVR := schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for kind, gvr := range map[string]schema.GroupVersionResource{"ConfigMap": cmGVR, "Service": svcGVR, "Pod": {Version: "v1", Resource: "pods"}} {
+		mapper.AddSpecific(gvr.GroupVersion().WithKind(kind), gvr, gvr.GroupVersion().WithResource(strings.TrimSuffix(gvr.Resource, "s")), meta.RESTScopeNamespace)
+	}
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dyn.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("admission webhook denied the request")
+	})
+	origMapper, origDyn := k8s.Mapper, k8s.DynamicClient
+	t.Cleanup(func() { k8s.Mapper, k8s.DynamicClient = origMapper, origDyn })
+	k8s.Mapper, k8s.DynamicClient = mapper, dyn
+
+	docs := []resource.Resource{
+		{RID: 1, Type: "ConfigMap", Name: "cfg", ParsedYAML: datatypes.JSON(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cfg"},"data":{"a":"b"}}`)},
+		{RID: 2, Type: "Service", Name: "web", ParsedYAML: datatypes.JSON(`{"apiVersion":"v1","kind":"Service","metadata":{"name":"web"},"spec":{"ports":[{"port":80}]}}`)},
+		{RID: 3, Type: "Pod", Name: "web", ParsedYAML: datatypes.JSON(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web"},"spec":{"containers":[{"name":"main","image":"nginx"}]}}`)},
+	}
+	mockRes.EXPECT().ListResourcesByConfigFileID(uint(1)).Return(docs, nil)
+	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{CFID: 1, ProjectID: 1}, nil)
+	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10}, nil).AnyTimes()
+	mockUserGroup.EXPECT().GetUserGroup(uint(1), uint(10)).Return(group.UserGroup{UID: 1, GID: 10, Role: "admin"}, nil).AnyTimes()
+	c.Set("claims", &types.Claims{Username: "testuser", UserID: 1, IsAdmin: true})
+
+	_, err := svc.CreateInstance(c, 1)
+	var instErr *application.InstanceError
+	if !errors.As(err, &instErr) || len(instErr.Failures) != 1 {
+		t.Fatalf("expected an InstanceError, got %v", err)
+	}
+	if f := instErr.Failures[0]; f.Index != 2 || f.Resource != "Pod/web" || f.Step != application.InstanceStepApply {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+
+	// The ConfigMap and Service created before the Pod failed were removed again
+	ns := k8s.FormatNamespaceName(1, "testuser")
+	for gvr, name := range map[schema.GroupVersionResource]string{cmGVR: "cfg", svcGVR: "web"} {
+		if _, err := dyn.Resource(gvr).Namespace(ns).Get(context.Background(), name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Fatalf("expected %s/%s to be rolled back, got %v", gvr.Resource, name, err)
+		}
+	}
+	var creates, deletes int
+	for _, action := range dyn.Actions() {
+		switch action.GetVerb() {
+		case "create":
+			creates++
+		case "delete":
+			deletes++
+		}
+	}
+	if creates != 3 || deletes != 2 {
+		t.Fatalf("expected 3 creates and 2 deletes, got %d and %d", creates, deletes)
+	}
+}
+
+func TestInstanceWithGenerateNameIsDeletedByItsGeneratedName(t *testing.T) {
+	svc, mockCF, mockRes, _, _, mockProject, mockUserGroup, c := setupMocks(t)
+
+	podGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.AddSpecific(podGVR.GroupVersion().WithKind("Pod"), podGVR, podGVR.GroupVersion().WithResource("pod"), meta.RESTScopeNamespace)
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	// The fake client does not generate names like the API server does
+	dyn.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if obj.GetName() == "" {
+			obj.SetName(obj.GetGenerateName() + "x7k2q")
+		}
+		return false, nil, nil
+	})
+	origMapper, origDyn := k8s.Mapper, k8s.DynamicClient
+	t.Cleanup(func() { k8s.Mapper, k8s.DynamicClient = origMapper, origDyn })
+	k8s.Mapper, k8s.DynamicClient = mapper, dyn
+
+	docs := []resource.Resource{
+		{RID: 3, Type: "Pod", Name: "lab-", ParsedYAML: datatypes.JSON(`{"apiVersion":"v1","kind":"Pod","metadata":{"generateName":"lab-"},"spec":{"containers":[{"name":"main","image":"nginx"}]}}`)},
+	}
+	mockRes.EXPECT().ListResourcesByConfigFileID(uint(1)).Return(docs, nil).Times(2)
+	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{CFID: 1, ProjectID: 1}, nil).Times(2)
+	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10}, nil).AnyTimes()
+	mockUserGroup.EXPECT().GetUserGroup(uint(1), uint(10)).Return(group.UserGroup{UID: 1, GID: 10, Role: "admin"}, nil).AnyTimes()
+	c.Set("claims", &types.Claims{Username: "testuser", UserID: 1, IsAdmin: true})
+
+	if _, err := svc.CreateInstance(c, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ns := k8s.FormatNamespaceName(1, "testuser")
+	if _, err := dyn.Resource(podGVR).Namespace(ns).Get(context.Background(), "lab-x7k2q", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected the pod under its generated name, got %v", err)
+	}
+	recorded, err := svc.Repos.DeployedObject.ListByNamespace(ns, []uint{3})
+	if err != nil || len(recorded) != 1 || recorded[0].Name != "lab-x7k2q" {
+		t.Fatalf("expected the generated name to be recorded, got %+v (%v)", recorded, err)
+	}
+
+	report, err := svc.DeleteInstance(c, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Deleted) != 1 || report.Deleted[0].Resource != "Pod/lab-x7k2q" {
+		t.Fatalf("expected the generated pod to be deleted, got %+v", report)
+	}
+	if _, err := dyn.Resource(podGVR).Namespace(ns).Get(context.Background(), "lab-x7k2q", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the pod to be gone, got %v", err)
+	}
+	if recorded, _ := svc.Repos.DeployedObject.ListByNamespace(ns, []uint{3}); len(recorded) != 0 {
+		t.Fatalf("expected the recorded name to be forgotten, got %+v", recorded)
+	}
+}
+
+func TestDeleteInstance_Success(t *testing.T) {
+	svc, mockCF, mockRes, _, _, mockProject, _, c := setupMocks(t)
+
+	mockRes.EXPECT().ListResourcesByConfigFileID(uint(1)).Return([]resource.Resource{{RID: 1, ParsedYAML: datatypes.JSON([]byte("{}"))}}, nil)
+	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{CFID: 1, ProjectID: 1}, nil)
+	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10}, nil)
+
+	_, err := svc.DeleteInstance(c, 1)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
 func TestDeleteConfigFileInstance_Success(t *testing.T) {
-	svc, mockCF, mockRes, _, mockUser, _, _, _ := setupMocks(t)
+	svc, mockCF, mockRes, _, mockUser, mockProject, _, _ := setupMocks(t)
+	mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, GID: 10}, nil)
 
 	// Mock ConfigFile
 	mockCF.EXPECT().GetConfigFileByID(uint(1)).Return(&configfile.ConfigFile{
@@ -243,7 +546,7 @@ func TestDeleteConfigFileInstance_Success(t *testing.T) {
 
 	// k8s.FormatNamespaceName and k8s.DeleteByJson use deterministic behavior / mock when clients are nil
 
-	err := svc.DeleteConfigFileInstance(1)
+	_, err := svc.DeleteConfigFileInstance(1)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -366,6 +669,16 @@ func TestValidateAndInjectGPUConfig(t *testing.T) {
 		if !foundMemory {
 			t.Fatalf("CUDA_MPS_PINNED_DEVICE_MEM_LIMIT env not injected or incorrect")
 		}
+		// One unit is below the floor of 10%
+		foundThreads := false
+		for _, e := range env {
+			if item, ok := e.(map[string]interface{}); ok && item["name"] == "CUDA_MPS_ACTIVE_THREAD_PERCENTAGE" {
+				foundThreads = item["value"] == "10"
+			}
+		}
+		if !foundThreads {
+			t.Fatalf("CUDA_MPS_ACTIVE_THREAD_PERCENTAGE env not injected or incorrect")
+		}
 	})
 
 	t.Run("GPUConfig_InvalidGPUQuota", func(t *testing.T) {
@@ -0,0 +1,179 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+var (
+	ErrTemplateNotFound    = errors.New("config template not found")
+	ErrTemplateRequiresGPU = errors.New("template requires a GPU")
+)
+
+func (s *ConfigFileService) ListTemplates(category string) ([]configfile.ConfigTemplate, error) {
+	return s.Repos.Template.ListTemplates(category)
+}
+
+func (s *ConfigFileService) GetTemplate(id uint) (*configfile.ConfigTemplate, error) {
+	t, err := s.Repos.Template.GetTemplateByID(id)
+	if err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+// CreateTemplate validates the template YAML with the same rules as config files. Templates
+// whose YAML requests nvidia.com/gpu are always flagged as requiring a GPU.
+func (s *ConfigFileService) CreateTemplate(c *gin.Context, input configfile.CreateConfigTemplateInput) (*configfile.ConfigTemplate, error) {
+	resources, err := s.parseAndValidateResources(input.RawYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &configfile.ConfigTemplate{
+		Name:        input.Name,
+		Description: input.Description,
+		Category:    input.Category,
+		Content:     input.RawYaml,
+		RequiresGPU: input.RequiresGPU || resourcesRequestGPU(resources),
+		Version:     1,
+	}
+	if err := s.Repos.Template.CreateTemplate(t); err != nil {
+		return nil, err
+	}
+
+	utils.LogAuditWithConsole(c, "create", "config_template", fmt.Sprintf("template_id=%d", t.ID), nil, *t, "", s.Repos.Audit)
+	return t, nil
+}
+
+// UpdateTemplate applies the changed fields and bumps the version when the content changes.
+func (s *ConfigFileService) UpdateTemplate(c *gin.Context, id uint, input configfile.UpdateConfigTemplateInput) (*configfile.ConfigTemplate, error) {
+	existing, err := s.Repos.Template.GetTemplateByID(id)
+	if err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	old := *existing
+
+	if input.Name != nil {
+		existing.Name = *input.Name
+	}
+	if input.Description != nil {
+		existing.Description = *input.Description
+	}
+	if input.Category != nil {
+		existing.Category = *input.Category
+	}
+	if input.RequiresGPU != nil {
+		existing.RequiresGPU = *input.RequiresGPU
+	}
+	if input.RawYaml != nil && *input.RawYaml != existing.Content {
+		resources, err := s.parseAndValidateResources(*input.RawYaml)
+		if err != nil {
+			return nil, err
+		}
+		existing.Content = *input.RawYaml
+		existing.Version++
+		if resourcesRequestGPU(resources) {
+			existing.RequiresGPU = true
+		}
+	}
+
+	if err := s.Repos.Template.UpdateTemplate(existing); err != nil {
+		return nil, err
+	}
+
+	utils.LogAuditWithConsole(c, "update", "config_template", fmt.Sprintf("template_id=%d", existing.ID), old, *existing, "", s.Repos.Audit)
+	return existing, nil
+}
+
+func (s *ConfigFileService) DeleteTemplate(c *gin.Context, id uint) error {
+	t, err := s.Repos.Template.GetTemplateByID(id)
+	if err != nil {
+		return ErrTemplateNotFound
+	}
+	if err := s.Repos.Template.DeleteTemplate(id); err != nil {
+		return err
+	}
+
+	utils.LogAuditWithConsole(c, "delete", "config_template", fmt.Sprintf("template_id=%d", t.ID), *t, nil, "", s.Repos.Audit)
+	return nil
+}
+
+// InstantiateTemplate copies a template into the project as a regular config file, recording the
+// template ID and version it came from, and optionally launches the instance. A failed launch
+// keeps the created config file and is reported in the output instead of as an error.
+func (s *ConfigFileService) InstantiateTemplate(c *gin.Context, projectID, templateID uint, input configfile.InstantiateTemplateInput) (*configfile.InstantiateTemplateOutput, error) {
+	t, err := s.Repos.Template.GetTemplateByID(templateID)
+	if err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	proj, err := s.Repos.Project.GetProjectByID(projectID)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	if t.RequiresGPU && !projectHasGPU(proj) {
+		return nil, fmt.Errorf("%w: project %q has no GPU quota for template %q", ErrTemplateRequiresGPU, proj.ProjectName, t.Name)
+	}
+
+	filename := input.Filename
+	if filename == "" {
+		filename = t.Name
+	}
+	version := t.Version
+	cf, err := s.createConfigFile(c, &configfile.ConfigFile{
+		Filename:        filename,
+		Content:         t.Content,
+		ProjectID:       proj.PID,
+		TemplateID:      &t.ID,
+		TemplateVersion: &version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &configfile.InstantiateTemplateOutput{ConfigFile: cf}
+	if input.Launch {
+		if req, err := s.CreateInstance(c, cf.CFID); err != nil {
+			out.LaunchError = err.Error()
+		} else if req != nil {
+			out.ApprovalRequestID = &req.ID
+		} else {
+			out.Launched = true
+		}
+	}
+	return out, nil
+}
+
+func projectHasGPU(p project.Project) bool {
+	return p.GPUQuota > 0 && (p.CanUseMPS() || p.CanUseDedicatedGPU())
+}
+
+// resourcesRequestGPU reports whether any container requests or limits nvidia.com/gpu.
+func resourcesRequestGPU(resources []*resource.Resource) bool {
+	for _, res := range resources {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(res.ParsedYAML, &obj); err != nil {
+			continue
+		}
+		for _, spec := range findPodSpecs(obj) {
+			for _, c := range getContainersFromPodSpec(spec) {
+				containerResources, _ := c["resources"].(map[string]interface{})
+				for _, key := range []string{"requests", "limits"} {
+					if values, ok := containerResources[key].(map[string]interface{}); ok {
+						if _, exists := values["nvidia.com/gpu"]; exists {
+							return true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package application
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const gpuTemplateYAML = `apiVersion: v1
+kind: Pod
+metadata:
+  name: pytorch
+spec:
+  containers:
+  - name: main
+    image: pytorch/pytorch:latest
+    resources:
+      requests:
+        nvidia.com/gpu: 1
+      limits:
+        cpu: "2"
+        memory: 4Gi
+        nvidia.com/gpu: 1
+`
+
+func setupTemplateService(t *testing.T) (*ConfigFileService, *gorm.DB, *gin.Context) {
+	t.Helper()
+	db := dbtest.Open(t, &project.Project{}, &configfile.ConfigFile{}, &configfile.ConfigTemplate{}, &resource.Resource{})
+
+	origLog := utils.LogAuditWithConsole
+	t.Cleanup(func() { utils.LogAuditWithConsole = origLog })
+	utils.LogAuditWithConsole = func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo) {}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodPost, "/", nil)
+	return NewConfigFileService(repository.NewRepositories(db)), db, c
+}
+
+func TestInstantiateGPUTemplateWithoutQuota(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+
+	tpl, err := svc.CreateTemplate(c, configfile.CreateConfigTemplateInput{Name: "pytorch", Category: "training", RawYaml: gpuTemplateYAML})
+	if err != nil {
+		t.Fatalf("create template failed: %v", err)
+	}
+	if !tpl.RequiresGPU {
+		t.Fatalf("a template requesting nvidia.com/gpu must be flagged as requiring a GPU")
+	}
+
+	noGPU := project.Project{ProjectName: "intro-course", GID: 1, GPUQuota: 0}
+	db.Create(&noGPU)
+
+	_, err = svc.InstantiateTemplate(c, noGPU.PID, tpl.ID, configfile.InstantiateTemplateInput{Launch: true})
+	if !errors.Is(err, ErrTemplateRequiresGPU) {
+		t.Fatalf("expected ErrTemplateRequiresGPU, got %v", err)
+	}
+	var count int64
+	db.Model(&configfile.ConfigFile{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("no config file should be created on failure, found %d", count)
+	}
+}
+
+func TestInstantiateTemplateRecordsProvenance(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+
+	tpl, err := svc.CreateTemplate(c, configfile.CreateConfigTemplateInput{Name: "pytorch", Category: "training", RawYaml: gpuTemplateYAML})
+	if err != nil {
+		t.Fatalf("create template failed: %v", err)
+	}
+	edited := gpuTemplateYAML + "  restartPolicy: Never\n"
+	if tpl, err = svc.UpdateTemplate(c, tpl.ID, configfile.UpdateConfigTemplateInput{RawYaml: &edited}); err != nil || tpl.Version != 2 {
+		t.Fatalf("expected content change to bump version, got %+v (%v)", tpl, err)
+	}
+
+	withGPU := project.Project{ProjectName: "lab", GID: 1, GPUQuota: 4}
+	db.Create(&withGPU)
+
+	out, err := svc.InstantiateTemplate(c, withGPU.PID, tpl.ID, configfile.InstantiateTemplateInput{})
+	if err != nil {
+		t.Fatalf("instantiate failed: %v", err)
+	}
+	cf := out.ConfigFile
+	if cf.ProjectID != withGPU.PID || cf.Filename != "pytorch" || cf.Content != edited {
+		t.Fatalf("unexpected config file %+v", cf)
+	}
+	if cf.TemplateID == nil || *cf.TemplateID != tpl.ID || cf.TemplateVersion == nil || *cf.TemplateVersion != 2 {
+		t.Fatalf("expected provenance template %d v2, got %v / %v", tpl.ID, cf.TemplateID, cf.TemplateVersion)
+	}
+	if out.Launched {
+		t.Fatalf("instance should only launch on request")
+	}
+
+	var resources int64
+	db.Model(&resource.Resource{}).Where("cf_id = ?", cf.CFID).Count(&resources)
+	if resources != 1 {
+		t.Fatalf("expected the parsed pod resource to be stored, got %d", resources)
+	}
+
+	list, err := svc.ListTemplates("inference")
+	if err != nil || len(list) != 0 {
+		t.Fatalf("expected no inference templates, got %v (%v)", list, err)
+	}
+}
@@ -0,0 +1,98 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// ListTrashedConfigFiles lists the project's deleted config files, most recently deleted first.
+func (s *ConfigFileService) ListTrashedConfigFiles(projectID uint) ([]configfile.ConfigFile, error) {
+	return s.Repos.ConfigFile.ListTrashedConfigFiles(projectID)
+}
+
+// RestoreConfigFile takes a config file out of the trash.
+func (s *ConfigFileService) RestoreConfigFile(c *gin.Context, id uint) (*configfile.ConfigFile, error) {
+	cf, err := s.Repos.ConfigFile.GetTrashedConfigFileByID(id)
+	if err != nil {
+		return nil, ErrConfigFileNotFound
+	}
+	if err := s.Repos.ConfigFile.RestoreConfigFile(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConfigFileNotFound
+		}
+		return nil, err
+	}
+	trashed := *cf
+	cf.DeletedAt = gorm.DeletedAt{}
+
+	utils.LogAuditWithConsole(c, "restore", "config_file", fmt.Sprintf("cf_id=%d", cf.CFID), trashed, *cf, "", s.Repos.Audit)
+	return cf, nil
+}
+
+// PurgeExpiredConfigFiles permanently deletes config files trashed longer than
+// the config_file_trash_retention_days setting, tearing down their instances first. A file that fails
+// to purge is logged and retried on the next run; the others are still purged.
+func (s *ConfigFileService) PurgeExpiredConfigFiles() (int, error) {
+	days := config.IntSetting(config.SettingConfigFileTrashRetentionDays)
+	if days <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	files, err := s.Repos.ConfigFile.ListConfigFilesTrashedBefore(cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var errs []error
+	for i := range files {
+		if err := s.purgeConfigFile(&files[i], "trash retention expired"); err != nil {
+			log.Printf("[ConfigFile] failed to purge config file %d: %v", files[i].CFID, err)
+			errs = append(errs, fmt.Errorf("config file %d: %w", files[i].CFID, err))
+			continue
+		}
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+// purgeConfigFile permanently deletes cf with its resources and instances. reason is recorded
+// in the audit log.
+func (s *ConfigFileService) purgeConfigFile(cf *configfile.ConfigFile, reason string) error {
+	report, err := s.deleteConfigFileInstances(cf)
+	if err != nil {
+		return fmt.Errorf("failed to tear down instances: %w", err)
+	}
+	// Missing namespaces are only skipped, but objects left behind keep the file for the next run
+	if report.Partial() {
+		return fmt.Errorf("failed to tear down %d instance objects, first %s in %s: %s",
+			len(report.Failed), report.Failed[0].Resource, report.Failed[0].Namespace, report.Failed[0].Error)
+	}
+
+	resources, err := s.Repos.Resource.ListResourcesByConfigFileID(cf.CFID)
+	if err != nil {
+		return err
+	}
+	for _, res := range resources {
+		if err := s.Repos.Resource.DeleteResource(res.RID); err != nil {
+			return err
+		}
+	}
+	if err := s.Repos.ConfigFile.PurgeConfigFile(cf.CFID); err != nil {
+		return err
+	}
+
+	// No request context here; the purge is recorded as a system action
+	if err := utils.LogAudit(0, "", "", "purge", "config_file", fmt.Sprintf("cf_id=%d", cf.CFID), *cf, nil, reason, s.Repos.Audit); err != nil {
+		log.Printf("[ConfigFile] failed to audit purge of config file %d: %v", cf.CFID, err)
+	}
+	return nil
+}
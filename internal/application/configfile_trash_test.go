@@ -0,0 +1,56 @@
+package application
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"gorm.io/datatypes"
+)
+
+func TestRestoreConfigFileAfterTrash(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+
+	cf := configfile.ConfigFile{Filename: "train.yaml", Content: gpuTemplateYAML, ProjectID: 7}
+	db.Create(&cf)
+	db.Create(&resource.Resource{CFID: cf.CFID, Type: resource.ResourcePod, Name: "pytorch", ParsedYAML: datatypes.JSON(`{}`)})
+
+	if err := svc.DeleteConfigFile(c, cf.CFID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := svc.GetConfigFile(cf.CFID); err == nil {
+		t.Fatalf("a trashed file must not be returned by normal lookups")
+	}
+	if files, _ := svc.ListConfigFilesByProjectID(7); len(files) != 0 {
+		t.Fatalf("trashed files must be excluded from listings, got %d", len(files))
+	}
+	trash, err := svc.ListTrashedConfigFiles(7)
+	if err != nil || len(trash) != 1 || trash[0].CFID != cf.CFID {
+		t.Fatalf("expected the file in the trash, got %v (err %v)", trash, err)
+	}
+
+	restored, err := svc.RestoreConfigFile(c, cf.CFID)
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if restored.DeletedAt.Valid {
+		t.Fatalf("restored file should not be marked deleted")
+	}
+	got, err := svc.GetConfigFile(cf.CFID)
+	if err != nil || got.Filename != "train.yaml" {
+		t.Fatalf("expected the file back, got %v (err %v)", got, err)
+	}
+	var resources int64
+	db.Model(&resource.Resource{}).Where("cf_id = ?", cf.CFID).Count(&resources)
+	if resources != 1 {
+		t.Fatalf("resources must survive the trash, found %d", resources)
+	}
+	if trash, _ := svc.ListTrashedConfigFiles(7); len(trash) != 0 {
+		t.Fatalf("trash should be empty after restore, got %d", len(trash))
+	}
+
+	if _, err := svc.RestoreConfigFile(c, cf.CFID); !errors.Is(err, ErrConfigFileNotFound) {
+		t.Fatalf("restoring a file that is not in the trash should fail, got %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package application
 
 import (
 	"fmt"
-	"strings"
 )
 
 // findPodSpecs recursively looks for objects that look like PodSpecs.
@@ -32,6 +31,33 @@ func findPodSpecs(obj map[string]interface{}) []map[string]interface{} {
 	return results
 }
 
+// findPodTemplates returns the objects whose spec is a PodSpec: the manifest itself for a Pod, and
+// the pod templates of controllers. Their metadata is what the pods are created with.
+func findPodTemplates(obj map[string]interface{}) []map[string]interface{} {
+	if spec, ok := obj["spec"].(map[string]interface{}); ok {
+		if _, hasContainers := spec["containers"]; hasContainers {
+			return []map[string]interface{}{obj}
+		}
+	}
+	var results []map[string]interface{}
+	for _, key := range []string{"spec", "template", "jobTemplate"} {
+		if subMap, ok := obj[key].(map[string]interface{}); ok {
+			results = append(results, findPodTemplates(subMap)...)
+		}
+	}
+	return results
+}
+
+// objectRef returns the Kind/name of a manifest object.
+func objectRef(obj map[string]interface{}) string {
+	kind, _ := obj["kind"].(string)
+	name := ""
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		name, _ = meta["name"].(string)
+	}
+	return kind + "/" + name
+}
+
 func getContainersFromPodSpec(podSpec map[string]interface{}) []map[string]interface{} {
 	var containers []map[string]interface{}
 
@@ -50,17 +76,6 @@ func getContainersFromPodSpec(podSpec map[string]interface{}) []map[string]inter
 	return containers
 }
 
-func parseImageNameTag(img string) (name string, tag string) {
-	lastColon := strings.LastIndex(img, ":")
-	lastSlash := strings.LastIndex(img, "/")
-
-	if lastColon == -1 || lastColon < lastSlash {
-		return img, "latest"
-	}
-
-	return img[:lastColon], img[lastColon+1:]
-}
-
 func getStringValue(m map[string]interface{}, key string) (string, bool) {
 	val, ok := m[key]
 	if !ok {
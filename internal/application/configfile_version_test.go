@@ -0,0 +1,84 @@
+package application
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+)
+
+func configMapYAML(value string) string {
+	return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  value: \"" + value + "\"\n"
+}
+
+func TestUpdateConfigFileRejectsStaleVersion(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+
+	cf, err := svc.CreateConfigFile(c, configfile.CreateConfigFileInput{Filename: "settings.yaml", RawYaml: configMapYAML("a"), ProjectID: 7})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if cf.Version != 1 {
+		t.Fatalf("a new file should start at version 1, got %d", cf.Version)
+	}
+
+	// Both users open the file at version 1
+	alice, bob := configMapYAML("alice"), configMapYAML("bob")
+	read := cf.Version
+
+	updated, err := svc.UpdateConfigFile(c, cf.CFID, configfile.ConfigFileUpdateDTO{RawYaml: &alice, Version: &read})
+	if err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("expected the update to bump the version to 2, got %d", updated.Version)
+	}
+
+	_, err = svc.UpdateConfigFile(c, cf.CFID, configfile.ConfigFileUpdateDTO{RawYaml: &bob, Version: &read})
+	var conflict *ConfigFileVersionConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConfigFileVersionConflict) {
+		t.Fatalf("expected a version conflict, got %v", err)
+	}
+	if conflict.Current.Version != 2 || conflict.Current.Content != alice {
+		t.Fatalf("the conflict should carry the stored file, got version %d", conflict.Current.Version)
+	}
+
+	stored, _ := svc.GetConfigFile(cf.CFID)
+	if stored.Content != alice || stored.Version != 2 {
+		t.Fatalf("the stale update must not be applied, got version %d %q", stored.Version, stored.Content)
+	}
+	var res resource.Resource
+	db.Where("cf_id = ?", cf.CFID).First(&res)
+	if res.CFVersion != 2 {
+		t.Fatalf("resources should reference the version they were parsed from, got %d", res.CFVersion)
+	}
+
+	if _, err := svc.UpdateConfigFile(c, cf.CFID, configfile.ConfigFileUpdateDTO{RawYaml: &bob}); !errors.Is(err, ErrConfigFileVersionRequired) {
+		t.Fatalf("an update without a version should be refused, got %v", err)
+	}
+}
+
+// A writer that commits between another writer's read and its update must still be detected.
+func TestUpdateConfigFileConflictAfterConcurrentWrite(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+
+	cf, err := svc.CreateConfigFile(c, configfile.CreateConfigFileInput{Filename: "settings.yaml", RawYaml: configMapYAML("a"), ProjectID: 7})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	// The conditional write is the only guard once the version check has passed
+	ok, err := svc.Repos.ConfigFile.UpdateConfigFileIfVersion(&configfile.ConfigFile{CFID: cf.CFID, Filename: "other.yaml", Content: cf.Content}, 1)
+	if err != nil || !ok {
+		t.Fatalf("expected the first conditional write to apply, got %v %v", ok, err)
+	}
+	ok, err = svc.Repos.ConfigFile.UpdateConfigFileIfVersion(&configfile.ConfigFile{CFID: cf.CFID, Filename: "late.yaml", Content: cf.Content}, 1)
+	if err != nil || ok {
+		t.Fatalf("expected the second conditional write to be refused, got %v %v", ok, err)
+	}
+	var stored configfile.ConfigFile
+	db.First(&stored, cf.CFID)
+	if stored.Filename != "other.yaml" || stored.Version != 2 {
+		t.Fatalf("unexpected stored file %q at version %d", stored.Filename, stored.Version)
+	}
+}
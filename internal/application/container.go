@@ -6,31 +6,47 @@ import (
 )
 
 type Services struct {
-	Audit      *AuditService
-	ConfigFile *ConfigFileService
-	Group      *GroupService
-	Project    *ProjectService
-	Resource   *ResourceService
-	UserGroup  *UserGroupService
-	User       *UserService
-	K8s        *K8sService
-	Form       *FormService
-	Job        *job.Service
-	Image      *ImageService
+	Audit       *AuditService
+	ConfigFile  *ConfigFileService
+	Group       *GroupService
+	Project     *ProjectService
+	Resource    *ResourceService
+	UserGroup   *UserGroupService
+	User        *UserService
+	UserImport  *UserImportService
+	K8s         *K8sService
+	Form        *FormService
+	Job         *job.Service
+	JobProgress *JobProgressService
+	Image       *ImageService
+	APIToken    *APITokenService
+	Maintenance *MaintenanceService
+	Approvals   *ApprovalNotifier
+	Settings    *SettingsService
+	Activity    *ActivityService
+	Search      *SearchService
 }
 
 func New(repos *repository.Repos) *Services {
 	return &Services{
-		Audit:      NewAuditService(repos),
-		ConfigFile: NewConfigFileService(repos),
-		Group:      NewGroupService(repos),
-		Project:    NewProjectService(repos),
-		Resource:   NewResourceService(repos),
-		UserGroup:  NewUserGroupService(repos),
-		User:       NewUserService(repos),
-		K8s:        NewK8sService(repos),
-		Form:       NewFormService(repos.Form),
-		Job:        job.NewService(repos.Job, repos.User, repos.Project),
-		Image:      NewImageService(repos.Image),
+		Audit:       NewAuditService(repos),
+		ConfigFile:  NewConfigFileService(repos),
+		Group:       NewGroupService(repos),
+		Project:     NewProjectService(repos),
+		Resource:    NewResourceService(repos),
+		UserGroup:   NewUserGroupService(repos),
+		User:        NewUserService(repos),
+		UserImport:  NewUserImportService(repos, configuredMailSender()),
+		K8s:         NewK8sService(repos),
+		Form:        NewFormService(repos.Form),
+		Job:         job.NewService(repos.Job, repos.User, repos.Project),
+		JobProgress: NewJobProgressService(repos),
+		Image:       NewImageService(repos.Image).WithRegistryCredentials(repos.Registry).WithProjectAccess(repos.Project, repos.UserGroup),
+		APIToken:    NewAPITokenService(repos),
+		Maintenance: NewMaintenanceService(repos),
+		Approvals:   NewApprovalNotifier(repos, configuredMailSender()),
+		Settings:    NewSettingsService(repos),
+		Activity:    NewActivityService(repos),
+		Search:      NewSearchService(repos),
 	}
 }
@@ -0,0 +1,51 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+)
+
+// ErrGangNotPlaceable is returned when a gang job cannot have all its pods running at once.
+var ErrGangNotPlaceable = errors.New("gang cannot be placed")
+
+// CheckGangPlacement verifies that every pod of a gang job can start right away: the project
+// quota has room for the GPUs of all pods together, and the cluster has enough free GPUs on
+// its nodes to place them simultaneously.
+func (s *K8sService) CheckGangPlacement(ctx context.Context, projectID uint, spec k8s.JobSpec) error {
+	if spec.GPUCount <= 0 {
+		return nil
+	}
+	pods := max(int(spec.Parallelism), 1)
+	resource, unitsPerGPU := k8s.GPUResource, 10
+	if spec.GPUType == job.GPUTypeShared {
+		resource, unitsPerGPU = k8s.SharedGPUResource, 1
+	}
+
+	if projectID != 0 {
+		p, err := s.repos.Project.GetProjectByID(projectID)
+		if err != nil {
+			return err
+		}
+		usage, err := s.CountProjectGPUUsage(ctx, projectID)
+		if err != nil {
+			return err
+		}
+		requested := spec.GPUCount * pods * unitsPerGPU
+		if usage+requested > p.GPUQuota {
+			return fmt.Errorf("%w: project GPU quota in use %d, gang needs %d, quota %d", ErrGangNotPlaceable, usage, requested, p.GPUQuota)
+		}
+	}
+
+	capacity, err := k8s.GetGPUCapacity(ctx, resource)
+	if err != nil {
+		return err
+	}
+	if !capacity.FitsGang(int64(spec.GPUCount), pods) {
+		return fmt.Errorf("%w: %d pods need %d %s each, %d free in the cluster", ErrGangNotPlaceable, pods, spec.GPUCount, resource, capacity.Free)
+	}
+	return nil
+}
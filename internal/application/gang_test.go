@@ -0,0 +1,51 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckGangPlacementGatesOnQuotaAndCapacity(t *testing.T) {
+	db := dbtest.Open(t, &project.Project{})
+	db.Create(&project.Project{PID: 1, ProjectName: "ddp", GID: 1, GPUQuota: 40})
+	svc := NewK8sService(repository.NewRepositories(db))
+
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	node := func(name string, gpus int64) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Allocatable: corev1.ResourceList{k8s.GPUResource: *resource.NewQuantity(gpus, resource.DecimalSI)}},
+		}
+	}
+	k8s.Clientset = k8sfake.NewSimpleClientset(node("gpu-a", 2), node("gpu-b", 1))
+	ctx := context.Background()
+
+	// 3 dedicated GPUs = 30 quota units, and one GPU fits on each free slot
+	fits := k8s.JobSpec{Name: "ddp", GPUCount: 1, GPUType: "dedicated", Parallelism: 3, Gang: true}
+	if err := svc.CheckGangPlacement(ctx, 1, fits); err != nil {
+		t.Fatalf("expected the gang to fit, got %v", err)
+	}
+
+	// The cluster has 3 free GPUs, but no node can host a 2-GPU pod twice
+	tooWide := k8s.JobSpec{Name: "ddp", GPUCount: 2, GPUType: "dedicated", Parallelism: 2, Gang: true}
+	if err := svc.CheckGangPlacement(ctx, 1, tooWide); !errors.Is(err, ErrGangNotPlaceable) {
+		t.Fatalf("expected ErrGangNotPlaceable for capacity, got %v", err)
+	}
+
+	// 5 GPUs exceed the 40 unit quota even before looking at the cluster
+	overQuota := k8s.JobSpec{Name: "ddp", GPUCount: 1, GPUType: "dedicated", Parallelism: 5, Gang: true}
+	if err := svc.CheckGangPlacement(ctx, 1, overQuota); !errors.Is(err, ErrGangNotPlaceable) {
+		t.Fatalf("expected ErrGangNotPlaceable for quota, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/mps"
+)
+
+var ErrInvalidMPSConfig = errors.New("invalid MPS configuration")
+
+// GPUConfig is the MPS setup a shared-GPU pod of a project receives, so members can check it
+// before submitting.
+type GPUConfig struct {
+	ProjectID uint `json:"project_id"`
+	GPUQuota  int  `json:"gpu_quota"`
+	// Units is what the pod holds: its request capped at the quota
+	Units            int               `json:"units"`
+	ThreadPercentage int               `json:"thread_percentage"`
+	MemoryLimitMB    int               `json:"memory_limit_mb"`
+	Env              map[string]string `json:"env"`
+	NodeVRAMMB       int               `json:"node_vram_mb"`
+	MaxSharedPods    int               `json:"max_shared_pods"`
+	// Valid is false when pods of the project could not be placed as configured
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	// Warnings report settings that are accepted but that new values would not be, such as an
+	// MPS memory limit set before it was checked against the VRAM of a GPU
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// projectMPSConfig returns the MPS configuration of a pod of p holding units of its quota.
+func projectMPSConfig(p project.Project, units int) *mps.MPSConfig {
+	return &mps.MPSConfig{
+		GPUQuota:         p.GPUQuota,
+		ThreadPercentage: mps.ThreadPercentage(units, config.MPSThreadPercentPerUnit, config.MPSThreadPercentFloor),
+		MemoryLimitMB:    p.MPSMemory,
+	}
+}
+
+// validateMPSMemory rejects a per-pod memory limit that oversubscribes a GPU's VRAM once the
+// most pods allowed share it. It is enforced when the limit of a project is set; limits stored
+// before are reported by GPUConfig rather than breaking the project's running workloads.
+func validateMPSMemory(memoryLimitMB int) error {
+	if !mps.FitsVRAM(memoryLimitMB, config.MPSMaxSharedPods, config.GPUNodeVRAMMB) {
+		return fmt.Errorf("%w: %dMB per pod for up to %d pods exceeds the %dMB of a GPU",
+			ErrInvalidMPSConfig, memoryLimitMB, config.MPSMaxSharedPods, config.GPUNodeVRAMMB)
+	}
+	return nil
+}
+
+// GPUConfig returns the MPS settings a shared-GPU pod of the project requesting units would
+// receive; units of 0 or more than the quota use the whole quota.
+func (s *ProjectService) GPUConfig(id uint, units int) (*GPUConfig, error) {
+	p, err := s.Repos.Project.GetProjectByID(id)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	if units <= 0 || units > p.GPUQuota {
+		units = p.GPUQuota
+	}
+	cfg := projectMPSConfig(p, units)
+	out := &GPUConfig{
+		ProjectID:        p.PID,
+		GPUQuota:         p.GPUQuota,
+		Units:            units,
+		ThreadPercentage: cfg.ThreadPercentage,
+		MemoryLimitMB:    cfg.MemoryLimitMB,
+		Env:              cfg.ToEnvVars(),
+		NodeVRAMMB:       config.GPUNodeVRAMMB,
+		MaxSharedPods:    config.MPSMaxSharedPods,
+		Valid:            true,
+	}
+	if p.GPUQuota <= 0 {
+		out.Valid, out.Error = false, "the project has no GPU quota"
+	}
+	if err := validateMPSMemory(p.MPSMemory); err != nil {
+		out.Warnings = append(out.Warnings, err.Error())
+	}
+	return out, nil
+}
@@ -78,6 +78,23 @@ func (s *GroupService) UpdateGroup(c *gin.Context, id uint, input group.GroupUpd
 	if input.Description != nil {
 		grp.Description = *input.Description
 	}
+	// Storage defaults only apply to hubs and storages created afterwards; nothing is resized here
+	for _, size := range []*string{input.HubStorageSize, input.ProjectStorageSize} {
+		if size != nil {
+			if err := validateStorageSize(*size); err != nil {
+				return group.Group{}, err
+			}
+		}
+	}
+	if input.HubStorageSize != nil {
+		grp.HubStorageSize = *input.HubStorageSize
+	}
+	if input.StorageClassName != nil {
+		grp.StorageClassName = *input.StorageClassName
+	}
+	if input.ProjectStorageSize != nil {
+		grp.ProjectStorageSize = *input.ProjectStorageSize
+	}
 
 	err = s.Repos.Group.UpdateGroup(&grp)
 	if err != nil {
@@ -0,0 +1,113 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+const dashboardRecentEvents = 5
+
+// dashboardBucket maps a job status to its dashboard counter, or nil for finished jobs.
+func dashboardBucket(counts *group.DashboardJobCounts, status string) *int64 {
+	switch job.JobStatus(status) {
+	case job.StatusRunning:
+		return &counts.Running
+	case job.StatusPending, job.StatusQueued, job.StatusScheduling:
+		return &counts.Pending
+	case job.StatusFailed, job.StatusDependencyFailed, job.StatusLostFromCluster, job.StatusTimedOut, job.StatusInterrupted:
+		return &counts.Failed
+	}
+	return nil
+}
+
+func addJobCounts(dst *group.DashboardJobCounts, src group.DashboardJobCounts) {
+	dst.Running += src.Running
+	dst.Pending += src.Pending
+	dst.Failed += src.Failed
+}
+
+// GetGroupDashboard aggregates the projects of a group. It runs one query each for the projects,
+// the job counts and the audit events, plus one label-selector PVC list for the storage.
+func (s *GroupService) GetGroupDashboard(ctx context.Context, gid uint) (*group.GroupDashboardDTO, error) {
+	grp, err := s.Repos.Group.GetGroupByID(gid)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := s.Repos.Project.ListProjectsByGroup(gid)
+	if err != nil {
+		return nil, err
+	}
+
+	dash := &group.GroupDashboardDTO{
+		GroupID:   grp.GID,
+		GroupName: grp.GroupName,
+		Projects:  make([]group.ProjectDashboardDTO, 0, len(projects)),
+	}
+	ids := make([]uint, 0, len(projects))
+	rows := make(map[uint]*group.ProjectDashboardDTO, len(projects))
+	resourceIDs := []string{fmt.Sprintf("g_id=%d", gid)}
+	for _, p := range projects {
+		dash.Projects = append(dash.Projects, group.ProjectDashboardDTO{
+			ProjectID:   p.PID,
+			ProjectName: p.ProjectName,
+			GPUQuota:    p.GPUQuota,
+		})
+		ids = append(ids, p.PID)
+		resourceIDs = append(resourceIDs, fmt.Sprintf("p_id=%d", p.PID))
+		dash.GPUQuota += p.GPUQuota
+	}
+	for i := range dash.Projects {
+		rows[dash.Projects[i].ProjectID] = &dash.Projects[i]
+	}
+
+	counts, err := s.Repos.Job.CountByProjects(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	for _, c := range counts {
+		row, ok := rows[c.ProjectID]
+		if !ok {
+			continue
+		}
+		bucket := dashboardBucket(&row.Jobs, c.Status)
+		if bucket == nil {
+			continue
+		}
+		*bucket += c.Count
+		// Same rule as the quota check on submission: running and pending jobs hold GPUs
+		if c.Status == string(job.StatusRunning) || c.Status == string(job.StatusPending) {
+			row.GPUUnitsInUse += c.GPUUnits
+		}
+	}
+
+	storages, err := (&K8sService{repos: s.Repos}).ListProjectStoragesByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project storages: %w", err)
+	}
+	for _, st := range storages {
+		if row, ok := rows[st.ProjectID]; ok {
+			row.StorageBytes += st.CapacityBytes
+		}
+	}
+	dash.StorageCount = len(storages)
+
+	for _, row := range dash.Projects {
+		addJobCounts(&dash.Jobs, row.Jobs)
+		dash.GPUUnitsInUse += row.GPUUnitsInUse
+		dash.StorageBytes += row.StorageBytes
+	}
+
+	events, err := s.Repos.Audit.GetAuditLogs(repository.AuditQueryParams{
+		ResourceIDs: resourceIDs,
+		Limit:       dashboardRecentEvents,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit events: %w", err)
+	}
+	dash.RecentEvents = events
+	return dash, nil
+}
@@ -0,0 +1,87 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/audit"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func TestGetGroupDashboardAggregatesProjects(t *testing.T) {
+	db := dbtest.Open(t, &group.Group{}, &project.Project{}, &job.Job{}, &audit.AuditLog{})
+	// Each of projects 1-3 has two 1000Mi storages; project 3 belongs to another group
+	client := useFakePVCs(t, fakeProjectPVCs(3, 2))
+
+	db.Create(&group.Group{GID: 1, GroupName: "vision"})
+	db.Create(&group.Group{GID: 2, GroupName: "nlp"})
+	db.Create(&project.Project{PID: 1, ProjectName: "p1", GID: 1, GPUQuota: 20})
+	db.Create(&project.Project{PID: 2, ProjectName: "p2", GID: 1, GPUQuota: 5})
+	db.Create(&project.Project{PID: 3, ProjectName: "p3", GID: 2, GPUQuota: 50})
+
+	pid := func(id uint) *uint { return &id }
+	for i, j := range []job.Job{
+		{ProjectID: pid(1), Status: string(job.StatusRunning), GPUCount: 1, GPUType: job.GPUTypeDedicated},
+		{ProjectID: pid(1), Status: string(job.StatusPending), GPUCount: 3, GPUType: job.GPUTypeShared},
+		{ProjectID: pid(1), Status: string(job.StatusFailed), GPUCount: 2, GPUType: job.GPUTypeShared},
+		{ProjectID: pid(1), Status: string(job.StatusCompleted), GPUCount: 4, GPUType: job.GPUTypeShared},
+		{ProjectID: pid(2), Status: string(job.StatusQueued)},
+		{ProjectID: pid(2), Status: string(job.StatusRunning), GPUCount: 2, GPUType: job.GPUTypeShared},
+		{ProjectID: pid(2), Status: string(job.StatusDependencyFailed)},
+		{ProjectID: pid(3), Status: string(job.StatusRunning), GPUCount: 5, GPUType: job.GPUTypeShared},
+	} {
+		j.UserID, j.Name, j.Namespace, j.Image = 1, fmt.Sprintf("job-%d", i), "ns", "img"
+		j.K8sJobName = j.Name
+		if err := db.Create(&j).Error; err != nil {
+			t.Fatalf("seed job %d: %v", i, err)
+		}
+	}
+
+	base := time.Now().Add(-time.Hour)
+	for i, rid := range []string{"g_id=1", "p_id=1", "p_id=2", "p_id=1", "p_id=2", "g_id=1", "p_id=3", "g_id=2"} {
+		db.Create(&audit.AuditLog{UserID: 1, Action: "update", ResourceType: "project", ResourceID: rid, CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	svc := NewGroupService(repository.NewRepositories(db))
+	dash, err := svc.GetGroupDashboard(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetGroupDashboard: %v", err)
+	}
+
+	if dash.Jobs != (group.DashboardJobCounts{Running: 2, Pending: 2, Failed: 2}) {
+		t.Fatalf("unexpected job counts: %+v", dash.Jobs)
+	}
+	// 10 units for the dedicated GPU running in p1, 3 pending and 2 running shared units
+	if dash.GPUUnitsInUse != 15 || dash.GPUQuota != 25 {
+		t.Fatalf("unexpected GPU usage: %d of %d", dash.GPUUnitsInUse, dash.GPUQuota)
+	}
+	if dash.StorageCount != 4 || dash.StorageBytes != 4*1000<<20 {
+		t.Fatalf("unexpected storage: %d PVCs, %d bytes", dash.StorageCount, dash.StorageBytes)
+	}
+	if len(dash.Projects) != 2 || dash.Projects[0].GPUUnitsInUse != 13 || dash.Projects[1].StorageBytes != 2*1000<<20 {
+		t.Fatalf("unexpected per-project rows: %+v", dash.Projects)
+	}
+
+	if len(dash.RecentEvents) != 5 {
+		t.Fatalf("expected five recent events, got %d", len(dash.RecentEvents))
+	}
+	if dash.RecentEvents[0].ResourceID != "g_id=1" || dash.RecentEvents[4].ResourceID != "p_id=1" {
+		t.Fatalf("expected the group's newest events first, got %+v", dash.RecentEvents)
+	}
+	for _, e := range dash.RecentEvents {
+		if e.ResourceID == "p_id=3" || e.ResourceID == "g_id=2" {
+			t.Fatalf("event of another group leaked: %+v", e)
+		}
+	}
+
+	// Storage comes from one label-selector list, not a list per project
+	if n := countPVCLists(client); n != 1 {
+		t.Fatalf("expected a single PVC list, got %d", n)
+	}
+}
@@ -2,10 +2,9 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -13,50 +12,95 @@ import (
 	cfg "github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/image"
 	"github.com/linskybing/platform-go/internal/repository"
+	imageref "github.com/linskybing/platform-go/pkg/image"
 	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/pagination"
+	"github.com/linskybing/platform-go/pkg/registry"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
 )
 
 func ptrTime(t time.Time) *time.Time { return &t }
 
 type PullJobStatus struct {
-	JobID     string    `json:"job_id"`
-	ImageName string    `json:"image_name"`
-	ImageTag  string    `json:"image_tag"`
-	Status    string    `json:"status"`
-	Progress  int       `json:"progress"`
-	Message   string    `json:"message"`
-	UpdatedAt time.Time `json:"updated_at"`
+	JobID       string             `json:"job_id"`
+	ImageName   string             `json:"image_name"`
+	ImageTag    string             `json:"image_tag"`
+	Status      string             `json:"status"`
+	Progress    int                `json:"progress"`
+	Message     string             `json:"message"`
+	RequestedBy uint               `json:"requested_by,omitempty"`
+	CancelledBy uint               `json:"cancelled_by,omitempty"`
+	Logs        []k8s.ContainerLog `json:"logs,omitempty"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+
+	// Phase is "preload" while the pulled image is being warmed on the nodes listed in Nodes
+	Phase string              `json:"phase,omitempty"`
+	Nodes []NodePreloadStatus `json:"nodes,omitempty"`
 }
 
+var (
+	ErrPullJobNotFound  = errors.New("pull job not found")
+	ErrPullJobFinished  = errors.New("pull job already finished")
+	ErrPullJobForbidden = errors.New("only admins or the original requester can cancel this pull")
+
+	ErrImageNotAllowed     = errors.New("image is not allowed for this project")
+	ErrImageAlreadyAllowed = errors.New("image is already allowed for this project")
+	ErrImageProjectDenied  = errors.New("only members of the project can list its images")
+)
+
 type PullJobTracker struct {
 	mu         sync.RWMutex
 	jobs       map[string]*PullJobStatus
 	chans      map[string][]chan *PullJobStatus
+	cancels    map[string]context.CancelFunc
+	ctxs       map[string]context.Context
 	failedJobs []*PullJobStatus
+	finished   []*PullJobStatus
 	maxHistory int
 }
 
 var pullTracker = &PullJobTracker{
 	jobs:       make(map[string]*PullJobStatus),
 	chans:      make(map[string][]chan *PullJobStatus),
+	cancels:    make(map[string]context.CancelFunc),
+	ctxs:       make(map[string]context.Context),
 	failedJobs: make([]*PullJobStatus, 0),
 	maxHistory: 50,
 }
 
-func (pt *PullJobTracker) AddJob(jobID, imageName, imageTag string) {
+func (pt *PullJobTracker) AddJob(jobID, imageName, imageTag string, requestedBy uint) {
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
 	pt.jobs[jobID] = &PullJobStatus{
-		JobID:     jobID,
-		ImageName: imageName,
-		ImageTag:  imageTag,
-		Status:    "pending",
-		Progress:  0,
-		UpdatedAt: time.Now(),
+		JobID:       jobID,
+		ImageName:   imageName,
+		ImageTag:    imageTag,
+		Status:      "pending",
+		Progress:    0,
+		RequestedBy: requestedBy,
+		UpdatedAt:   time.Now(),
 	}
+	ctx, cancel := context.WithCancel(pullMonitors.ctx)
+	pt.ctxs[jobID] = ctx
+	pt.cancels[jobID] = cancel
+}
+
+// Context returns the job's context, which is cancelled when the job is cancelled or removed.
+func (pt *PullJobTracker) Context(jobID string) context.Context {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	if ctx, ok := pt.ctxs[jobID]; ok {
+		return ctx
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
 }
 
 func (pt *PullJobTracker) GetJob(jobID string) *PullJobStatus {
@@ -74,16 +118,66 @@ func (pt *PullJobTracker) UpdateJob(jobID string, status string, progress int, m
 		job.Progress = progress
 		job.Message = message
 		job.UpdatedAt = time.Now()
+		pt.notifyLocked(jobID, job)
+	}
+}
 
-		if chans, ok := pt.chans[jobID]; ok {
-			for _, ch := range chans {
-				select {
-				case ch <- job:
-				default:
-				}
-			}
+func (pt *PullJobTracker) notifyLocked(jobID string, job *PullJobStatus) {
+	snapshot := *job
+	for _, ch := range pt.chans[jobID] {
+		select {
+		case ch <- &snapshot:
+		default:
+		}
+	}
+}
+
+// Fail marks an active job as failed with the given logs and removes it.
+func (pt *PullJobTracker) Fail(jobID, message string, logs []k8s.ContainerLog) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	job, ok := pt.jobs[jobID]
+	if !ok {
+		return
+	}
+	job.Status = "failed"
+	job.Progress = 0
+	job.Message = message
+	job.Logs = logs
+	job.UpdatedAt = time.Now()
+	pt.notifyLocked(jobID, job)
+	pt.removeLocked(jobID)
+}
+
+// Cancel marks an active job as cancelled, notifies subscribers with a final event and removes it.
+func (pt *PullJobTracker) Cancel(jobID string, cancelledBy uint) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	job, ok := pt.jobs[jobID]
+	if !ok {
+		return false
+	}
+	job.Status = "cancelled"
+	job.CancelledBy = cancelledBy
+	job.Message = fmt.Sprintf("Cancelled by user %d", cancelledBy)
+	job.UpdatedAt = time.Now()
+	pt.notifyLocked(jobID, job)
+	pt.removeLocked(jobID)
+	return true
+}
+
+// FindFinished returns a recently finished job (completed, failed or cancelled).
+func (pt *PullJobTracker) FindFinished(jobID string) *PullJobStatus {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	for i := len(pt.finished) - 1; i >= 0; i-- {
+		if pt.finished[i].JobID == jobID {
+			return pt.finished[i]
 		}
 	}
+	return nil
 }
 
 func (pt *PullJobTracker) Subscribe(jobID string) <-chan *PullJobStatus {
@@ -98,17 +192,55 @@ func (pt *PullJobTracker) Subscribe(jobID string) <-chan *PullJobStatus {
 func (pt *PullJobTracker) RemoveJob(jobID string) {
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
+	pt.removeLocked(jobID)
+}
 
-	if job, ok := pt.jobs[jobID]; ok && job.Status == "failed" {
-		pt.failedJobs = append(pt.failedJobs, job)
-		if len(pt.failedJobs) > pt.maxHistory {
-			pt.failedJobs = pt.failedJobs[len(pt.failedJobs)-pt.maxHistory:]
-		}
+// removeLocked archives the job, closes its subscriber channels and cancels its context.
+func (pt *PullJobTracker) removeLocked(jobID string) {
+	job, ok := pt.jobs[jobID]
+	if !ok {
+		return
+	}
+	if job.Status == "failed" {
+		pt.archiveFailedLocked(job)
+	}
+	pt.finished = append(pt.finished, job)
+	if len(pt.finished) > pt.maxHistory {
+		pt.finished = pt.finished[len(pt.finished)-pt.maxHistory:]
 	}
 
+	for _, ch := range pt.chans[jobID] {
+		close(ch)
+	}
+	delete(pt.chans, jobID)
+	if cancel, ok := pt.cancels[jobID]; ok {
+		cancel()
+	}
+	delete(pt.cancels, jobID)
+	delete(pt.ctxs, jobID)
 	delete(pt.jobs, jobID)
 }
 
+// archiveFailedLocked keeps job in the failed history, dropping the oldest failure of the same
+// image once it holds cfg.ImagePullFailedPerImage of them, so one image failing repeatedly does
+// not push the failures of the others out.
+func (pt *PullJobTracker) archiveFailedLocked(job *PullJobStatus) {
+	pt.failedJobs = append(pt.failedJobs, job)
+	same := 0
+	for _, f := range pt.failedJobs {
+		if f.ImageName == job.ImageName && f.ImageTag == job.ImageTag {
+			same++
+		}
+	}
+	for i := 0; same > cfg.ImagePullFailedPerImage && i < len(pt.failedJobs); i++ {
+		if f := pt.failedJobs[i]; f.ImageName == job.ImageName && f.ImageTag == job.ImageTag {
+			pt.failedJobs = append(pt.failedJobs[:i], pt.failedJobs[i+1:]...)
+			same--
+			i--
+		}
+	}
+}
+
 func (pt *PullJobTracker) GetFailedJobs(limit int) []*PullJobStatus {
 	pt.mu.RLock()
 	defer pt.mu.RUnlock()
@@ -135,29 +267,240 @@ func (pt *PullJobTracker) GetActiveJobs() []*PullJobStatus {
 	return result
 }
 
+type pullRequest struct {
+	jobID string
+	name  string
+	tag   string
+	// projectID is the project the pull was requested for, whose registry credentials apply
+	projectID uint
+}
+
+// pullGate caps how many puller Jobs run at once; overflow requests wait in FIFO order.
+type pullGate struct {
+	mu      sync.Mutex
+	running int
+	queue   []pullRequest
+}
+
+var pullSlots = &pullGate{}
+
+const (
+	pullPollInterval       = 2 * time.Second
+	pullMonitorCallTimeout = 10 * time.Second
+)
+
+// pullMonitorGroup owns the root context of all pull monitors. Shutdown cancels it, which also
+// cancels every job context derived from it, and waits for the monitors to flush their state.
+type pullMonitorGroup struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var pullMonitors = newPullMonitorGroup()
+
+func newPullMonitorGroup() *pullMonitorGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &pullMonitorGroup{ctx: ctx, cancel: cancel}
+}
+
+// start runs fn in a tracked goroutine. It refuses once shutdown has begun.
+func (g *pullMonitorGroup) start(fn func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.ctx.Err() != nil {
+		return false
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// shutdown cancels the monitors and waits up to timeout for them to exit. It reports whether
+// all of them did.
+func (g *pullMonitorGroup) shutdown(timeout time.Duration) bool {
+	g.mu.Lock()
+	g.cancel()
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (g *pullMonitorGroup) stopping() bool {
+	return g.ctx.Err() != nil
+}
+
+// ShutdownPullMonitors stops all image pull monitors, waiting at most timeout for them to record
+// their last status. Pull Jobs keep running in the cluster.
+func ShutdownPullMonitors(timeout time.Duration) bool {
+	return pullMonitors.shutdown(timeout)
+}
+
+// tryAcquire takes a slot when one is free, otherwise queues req. max <= 0 means unlimited.
+func (g *pullGate) tryAcquire(max int, req pullRequest) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if max > 0 && g.running >= max {
+		g.queue = append(g.queue, req)
+		return false
+	}
+	g.running++
+	return true
+}
+
+// remove drops a request that is still waiting in the queue.
+func (g *pullGate) remove(jobID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, req := range g.queue {
+		if req.jobID == jobID {
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// release returns the next queued request, which inherits the slot, or frees the slot.
+func (g *pullGate) release() (pullRequest, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.queue) > 0 {
+		next := g.queue[0]
+		g.queue = g.queue[1:]
+		return next, true
+	}
+	if g.running > 0 {
+		g.running--
+	}
+	return pullRequest{}, false
+}
+
 type ImageService struct {
-	repo repository.ImageRepo
+	repo     repository.ImageRepo
+	verifier imageVerifier
+	usage    *ImageUsageScanner
+	// registryCreds holds the projects' upstream registry credentials used by their pulls
+	registryCreds repository.RegistryCredentialRepo
+	// projects and userGroups decide who may list the images and requests of a project
+	projects   repository.ProjectRepo
+	userGroups repository.UserGroupRepo
 }
 
 func NewImageService(repo repository.ImageRepo) *ImageService {
-	return &ImageService{repo: repo}
+	return &ImageService{repo: repo, verifier: registry.NewClient(nil), usage: NewImageUsageScanner(repo)}
+}
+
+// WithRegistryCredentials lets pulls requested for a project use its registry credentials.
+func (s *ImageService) WithRegistryCredentials(repo repository.RegistryCredentialRepo) *ImageService {
+	s.registryCreds = repo
+	return s
+}
+
+// WithProjectAccess lets the listings check that callers belong to the project they ask for.
+func (s *ImageService) WithProjectAccess(projects repository.ProjectRepo, userGroups repository.UserGroupRepo) *ImageService {
+	s.projects = projects
+	s.userGroups = userGroups
+	return s
+}
+
+// CheckProjectAccess returns ErrImageProjectDenied unless uid may list the images and requests
+// of projectID: super admins may list any project, other users only the projects of groups they
+// belong to. The global listing (nil projectID) is open to every user.
+func (s *ImageService) CheckProjectAccess(uid uint, projectID *uint) error {
+	if projectID == nil {
+		return nil
+	}
+	if isAdmin, err := s.isSuperAdmin(uid); err != nil || isAdmin {
+		return err
+	}
+	if s.projects == nil || s.userGroups == nil {
+		return ErrImageProjectDenied
+	}
+	gid, err := s.projects.GetGroupIDByProjectID(*projectID)
+	if err != nil {
+		return ErrProjectNotFound
+	}
+	role, err := s.userGroups.GetUserRoleInGroup(uid, gid)
+	if err != nil {
+		return ErrImageProjectDenied
+	}
+	for _, allowed := range cfg.GroupAccessRoles {
+		if strings.EqualFold(role, allowed) {
+			return nil
+		}
+	}
+	return ErrImageProjectDenied
+}
+
+func (s *ImageService) isSuperAdmin(uid uint) (bool, error) {
+	if s.userGroups == nil {
+		return false, nil
+	}
+	return utils.IsSuperAdmin(uid, s.userGroups)
+}
+
+// ListAllowedImagesFor is ListAllowedImages on behalf of uid. Without a project, users other
+// than super admins only see the global images, not the rules of every project.
+func (s *ImageService) ListAllowedImagesFor(uid uint, projectID *uint, search string) ([]image.AllowedImageDTO, error) {
+	if err := s.CheckProjectAccess(uid, projectID); err != nil {
+		return nil, err
+	}
+	imgs, err := s.ListAllowedImages(projectID, search)
+	if err != nil || projectID != nil {
+		return imgs, err
+	}
+	if isAdmin, err := s.isSuperAdmin(uid); err != nil || isAdmin {
+		return imgs, err
+	}
+	global := imgs[:0]
+	for _, img := range imgs {
+		if img.IsGlobal {
+			global = append(global, img)
+		}
+	}
+	return global, nil
 }
 
 func (s *ImageService) SubmitRequest(userID uint, registry, name, tag string, projectID *uint) (*image.ImageRequest, error) {
-	// If caller didn't provide a registry, try to parse it out from the name
+	// If caller didn't provide a registry, it is parsed out from the name
 	// (e.g. "192.168.110.1:30003/library/pros-cameraapi" -> registry: "192.168.110.1:30003", name: "library/pros-cameraapi").
-	// If no registry is found, leave InputRegistry empty to represent Docker Hub/default behavior.
-	parsedRegistry := registry
-	parsedName := name
-	if parsedRegistry == "" {
-		parts := strings.SplitN(name, "/", 2)
-		first := parts[0]
-		hasDomain := strings.Contains(first, ".") || strings.Contains(first, ":") || first == "localhost"
-		if hasDomain && len(parts) == 2 {
-			parsedRegistry = first
-			parsedName = parts[1]
-		}
+	// Docker Hub images keep InputRegistry empty and are stored under their familiar name ("nginx").
+	if warn := s.validateNameAndTag(name, tag); warn != "" {
+		log.Printf("[image-validate] warning: %s", warn)
+	}
+	refStr := name
+	if registry != "" {
+		refStr = strings.TrimSuffix(registry, "/") + "/" + name
 	}
+	if tag != "" {
+		refStr = imageWithVersion(refStr, tag)
+	}
+	ref, err := imageref.Parse(refStr)
+	if err != nil {
+		return nil, err
+	}
+	parsedRegistry := ""
+	parsedName := ref.FamiliarName()
+	if ref.Registry != imageref.DefaultRegistry {
+		parsedRegistry = ref.Registry
+		parsedName = ref.Repository
+	}
+	tag = ref.Version()
 
 	req := &image.ImageRequest{
 		UserID:         userID,
@@ -168,15 +511,8 @@ func (s *ImageService) SubmitRequest(userID uint, registry, name, tag string, pr
 		Status:         "pending",
 	}
 
-	if warn := s.validateNameAndTag(name, tag); warn != "" {
-		log.Printf("[image-validate] warning: %s", warn)
-	}
-
 	// Build fullname for allow-list checks
-	fullName := parsedName
-	if parsedRegistry != "" && parsedRegistry != "docker.io" {
-		fullName = fmt.Sprintf("%s/%s", parsedRegistry, parsedName)
-	}
+	fullName := ref.FamiliarName()
 
 	// If an enabled allow-list rule already exists (global or project-scoped),
 	// do not create a duplicate request.
@@ -185,7 +521,7 @@ func (s *ImageService) SubmitRequest(userID uint, registry, name, tag string, pr
 		return req, err
 	}
 	if allowed {
-		return nil, fmt.Errorf("image '%s:%s' is already allowed for this project", fullName, tag)
+		return nil, fmt.Errorf("%w: %s:%s", ErrImageAlreadyAllowed, fullName, tag)
 	}
 
 	if err := s.repo.CreateRequest(req); err != nil {
@@ -199,7 +535,14 @@ func (s *ImageService) ListRequests(projectID *uint, status string) ([]image.Ima
 	return s.repo.ListRequests(projectID, status)
 }
 
-func (s *ImageService) ApproveRequest(id uint, note string, isGlobal bool, approverID uint) error {
+// ListRequestsPage returns one page of the requests ListRequests returns.
+func (s *ImageService) ListRequestsPage(projectID *uint, status string, p pagination.Params) (*pagination.Page[image.ImageRequest], error) {
+	return s.repo.ListRequestsPage(projectID, status, p)
+}
+
+// ApproveRequest approves a request and allows its image. preload, when set, chooses whether
+// pulls of the image warm the node caches, overriding config.ImagePreloadEnabled.
+func (s *ImageService) ApproveRequest(id uint, note string, isGlobal bool, approverID uint, preload *bool) error {
 	req, err := s.repo.FindRequestByID(id)
 	if err != nil {
 		return err
@@ -220,10 +563,10 @@ func (s *ImageService) ApproveRequest(id uint, note string, isGlobal bool, appro
 		return err
 	}
 
-	return s.createCoreAndPolicyFromRequest(req, approverID)
+	return s.createCoreAndPolicyFromRequest(req, approverID, preload)
 }
 
-func (s *ImageService) createCoreAndPolicyFromRequest(req *image.ImageRequest, adminID uint) error {
+func (s *ImageService) createCoreAndPolicyFromRequest(req *image.ImageRequest, adminID uint, preload *bool) error {
 	fullName := req.InputImageName
 	if req.InputRegistry != "" && req.InputRegistry != "docker.io" {
 		fullName = fmt.Sprintf("%s/%s", req.InputRegistry, req.InputImageName)
@@ -256,6 +599,11 @@ func (s *ImageService) createCoreAndPolicyFromRequest(req *image.ImageRequest, a
 	if err := s.repo.FindOrCreateTag(tagEntity); err != nil {
 		return err
 	}
+	if preload != nil {
+		if err := s.repo.SetTagPreload(tagEntity.ID, preload); err != nil {
+			return err
+		}
+	}
 
 	rule := &image.ImageAllowList{
 		ProjectID:    req.ProjectID,
@@ -328,7 +676,9 @@ func (s *ImageService) GetAllowedImage(name, tag string, projectID uint) (*image
 	}, nil
 }
 
-func (s *ImageService) ListAllowedImages(projectID *uint) ([]image.AllowedImageDTO, error) {
+// ListAllowedImages lists the global rules plus the rules of projectID. A non-empty search
+// keeps the images whose name contains it, ignoring case.
+func (s *ImageService) ListAllowedImages(projectID *uint, search string) ([]image.AllowedImageDTO, error) {
 	rules, err := s.repo.ListAllowedImages(projectID)
 	if err != nil {
 		return nil, err
@@ -338,12 +688,6 @@ func (s *ImageService) ListAllowedImages(projectID *uint) ([]image.AllowedImageD
 	for _, rule := range rules {
 		isGlobal := rule.ProjectID == nil
 
-		status, _ := s.repo.GetClusterStatus(rule.Tag.ID)
-		isPulled := false
-		if status != nil {
-			isPulled = status.IsPulled
-		}
-
 		displayImageName := rule.Repository.FullName
 		if displayImageName == "" {
 			if rule.Repository.Namespace != "" {
@@ -352,6 +696,15 @@ func (s *ImageService) ListAllowedImages(projectID *uint) ([]image.AllowedImageD
 				displayImageName = rule.Repository.Name
 			}
 		}
+		if !containsFold(displayImageName, search) {
+			continue
+		}
+
+		status, _ := s.repo.GetClusterStatus(rule.Tag.ID)
+		isPulled := false
+		if status != nil {
+			isPulled = status.IsPulled
+		}
 
 		dtos = append(dtos, image.AllowedImageDTO{
 			ID:        rule.ID,
@@ -367,6 +720,26 @@ func (s *ImageService) ListAllowedImages(projectID *uint) ([]image.AllowedImageD
 	return dtos, nil
 }
 
+// ListPendingRequestsOf lists the pending requests uid submitted for projectID, or for any
+// project when projectID is nil, whose image name contains search.
+func (s *ImageService) ListPendingRequestsOf(uid uint, projectID *uint, search string) ([]image.ImageRequest, error) {
+	reqs, err := s.repo.ListRequests(projectID, "pending")
+	if err != nil {
+		return nil, err
+	}
+	own := []image.ImageRequest{}
+	for _, r := range reqs {
+		if r.UserID == uid && containsFold(r.InputImageName, search) {
+			own = append(own, r)
+		}
+	}
+	return own, nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
 func (s *ImageService) AddProjectImage(userID uint, projectID uint, name, tag string) error {
 	if warn := s.validateNameAndTag(name, tag); warn != "" {
 		return fmt.Errorf("invalid image format: %s", warn)
@@ -399,44 +772,222 @@ func (s *ImageService) ValidateImageForProject(name, tag string, projectID *uint
 	return s.repo.CheckImageAllowed(projectID, fullName, tag)
 }
 
-func (s *ImageService) PullImageAsync(name, tag string) (string, error) {
+// HarborImage is where the pulled copy of ref lives in the private Harbor registry.
+func HarborImage(ref imageref.Reference) string {
+	return cfg.HarborPrivatePrefix + ref.String()
+}
+
+// PrefixAllowedImage returns the Harbor copy of img when img is allowed for the project, and
+// img itself otherwise. A reference without tag is checked as "latest".
+func (s *ImageService) PrefixAllowedImage(img string, projectID *uint) (string, error) {
+	ref, err := imageref.Parse(img)
+	if err != nil {
+		return img, err
+	}
+	allowed, err := s.ValidateImageForProject(ref.FamiliarName(), ref.Version(), projectID)
+	if err != nil || !allowed {
+		return img, err
+	}
+	if prefix := cfg.HarborPrivatePrefix; prefix == "" || strings.HasPrefix(img, prefix) {
+		return img, nil
+	}
+	return HarborImage(ref), nil
+}
+
+// ImageProvenance returns the approval behind img for the project: the image request that
+// added its allow-list rule, or the admin who added the rule directly. img may be the Harbor
+// copy. It returns nil when img is not allow-listed.
+func (s *ImageService) ImageProvenance(img string, projectID *uint) (*k8s.ImageProvenance, error) {
+	if prefix := cfg.HarborPrivatePrefix; prefix != "" {
+		img = strings.TrimPrefix(img, prefix)
+	}
+	ref, err := imageref.Parse(img)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.repo.FindAllowListRule(projectID, ref.FamiliarName(), ref.Version())
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && rule == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &k8s.ImageProvenance{ApprovedBy: rule.CreatedBy, ApprovedAt: rule.CreatedAt}
+	if rule.RequestID != nil {
+		p.RequestID = *rule.RequestID
+		if req, err := s.repo.FindRequestByID(*rule.RequestID); err == nil && req.ReviewerID != nil {
+			p.ApprovedBy = *req.ReviewerID
+			if req.ReviewedAt != nil {
+				p.ApprovedAt = *req.ReviewedAt
+			}
+		}
+	}
+	return p, nil
+}
+
+func (s *ImageService) PullImageAsync(name, tag string, requestedBy uint) (string, error) {
+	return s.PullProjectImageAsync(name, tag, requestedBy, 0)
+}
+
+// PullProjectImageAsync is PullImageAsync for a pull requested on behalf of a project; the
+// puller then also logs in with the project's credentials for the image's registry.
+func (s *ImageService) PullProjectImageAsync(name, tag string, requestedBy, projectID uint) (string, error) {
 	if warn := s.validateNameAndTag(name, tag); warn != "" {
 		log.Printf("[image-validate] warning on pull: %s", warn)
 	}
 
-	// --- [修正] 映像檔名稱正規化邏輯 ---
-	// 解決 codercom/code-server 變成 code-server:latest 或找不到 Registry 的問題
-	normalizedName := name
-	parts := strings.Split(name, "/")
+	req := pullRequest{
+		jobID:     "image-puller-" + utilrand.String(5),
+		name:      name,
+		tag:       tag,
+		projectID: projectID,
+	}
+	pullTracker.AddJob(req.jobID, name, tag, requestedBy)
 
-	// 檢查第一部分是否包含 Domain 特徵 (. 或 :) 或為 localhost
-	hasDomain := strings.Contains(parts[0], ".") ||
-		strings.Contains(parts[0], ":") ||
-		parts[0] == "localhost"
+	if !pullSlots.tryAcquire(cfg.IntSetting(cfg.SettingImagePullMaxConcurrent), req) {
+		pullTracker.UpdateJob(req.jobID, "queued", 0, "Waiting for a free pull slot...")
+		log.Printf("Queued pull job %s for image %s:%s", req.jobID, name, tag)
+		return req.jobID, nil
+	}
 
-	if !hasDomain {
-		if len(parts) == 1 {
-			// Case: "nginx" -> "docker.io/library/nginx"
-			normalizedName = "docker.io/library/" + name
-		} else {
-			// Case: "codercom/code-server" -> "docker.io/codercom/code-server"
-			normalizedName = "docker.io/" + name
+	if err := s.startPullJob(req); err != nil {
+		log.Printf("Failed to create image pull job: %v", err)
+		pullTracker.UpdateJob(req.jobID, "failed", 0, err.Error())
+		pullTracker.RemoveJob(req.jobID)
+		s.releasePullSlot()
+		return "", err
+	}
+	return req.jobID, nil
+}
+
+// startPullJob creates the puller Job for a request that holds a pull slot.
+func (s *ImageService) startPullJob(req pullRequest) error {
+	k8sJob, err := buildPullJob(req.jobID, req.name, req.tag)
+	if err != nil {
+		return err
+	}
+
+	if pullMonitors.stopping() {
+		return errors.New("image pulls are unavailable while the server shuts down")
+	}
+
+	ctx, cancel := context.WithTimeout(pullMonitors.ctx, pullMonitorCallTimeout)
+	defer cancel()
+	secretName, err := s.projectPullSecret(ctx, req)
+	if err != nil {
+		return err
+	}
+	if secretName != "" {
+		useProjectPullSecret(k8sJob, secretName)
+	}
+	if _, err := k8s.Clientset.BatchV1().Jobs(cfg.ImagePullNamespace).Create(ctx, k8sJob, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+
+	pullTracker.UpdateJob(req.jobID, "pulling", 10, "Starting image pull...")
+	jobCtx := pullTracker.Context(req.jobID)
+	if !pullMonitors.start(func() { s.monitorPullJob(jobCtx, req.jobID, req.name, req.tag) }) {
+		return errors.New("image pulls are unavailable while the server shuts down")
+	}
+
+	log.Printf("Created pull job %s for image: %s:%s", req.jobID, req.name, req.tag)
+	return nil
+}
+
+// releasePullSlot frees the caller's slot, handing it to the next queued request if any.
+func (s *ImageService) releasePullSlot() {
+	for {
+		next, ok := pullSlots.release()
+		if !ok {
+			return
+		}
+		if err := s.startPullJob(next); err != nil {
+			log.Printf("Failed to start queued pull job %s: %v", next.jobID, err)
+			pullTracker.UpdateJob(next.jobID, "failed", 0, err.Error())
+			pullTracker.RemoveJob(next.jobID)
+			continue
 		}
+		return
+	}
+}
+
+// projectPullSecret writes the docker config for a project pull into the pull namespace: the
+// Harbor credentials merged with the project's credentials for the image's registry. It
+// returns "" when the project has none for that registry.
+func (s *ImageService) projectPullSecret(ctx context.Context, req pullRequest) (string, error) {
+	auths, err := projectRegistryAuths(s.registryCreds, req.projectID)
+	if err != nil {
+		return "", err
+	}
+	matched := authsForImages(auths, req.name)
+	if len(matched) == 0 {
+		return "", nil
+	}
+	base, err := k8s.GetDockerConfig(ctx, cfg.ImagePullNamespace, cfg.HarborPullSecretName)
+	if err != nil {
+		return "", err
+	}
+	dockerConfig, err := k8s.DockerConfigJSON(base, matched)
+	if err != nil {
+		return "", err
+	}
+	name := ProjectPullSecretName(req.projectID)
+	labels := map[string]string{"project-id": fmt.Sprintf("%d", req.projectID)}
+	if err := k8s.EnsureDockerConfigSecret(ctx, cfg.ImagePullNamespace, name, dockerConfig, labels); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// useProjectPullSecret makes a pull Job use the merged project docker config, both for the
+// kubelet pulling the source image and for crane pushing it to Harbor.
+func useProjectPullSecret(j *batchv1.Job, secretName string) {
+	spec := &j.Spec.Template.Spec
+	spec.ImagePullSecrets = append(spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secretName})
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == "docker-config" && spec.Volumes[i].Secret != nil {
+			spec.Volumes[i].Secret.SecretName = secretName
+		}
+	}
+}
+
+// EnsurePullNamespace creates the image pull namespace and copies the Harbor credentials into it.
+func (s *ImageService) EnsurePullNamespace(ctx context.Context) error {
+	if err := k8s.EnsureNamespaceExists(cfg.ImagePullNamespace); err != nil {
+		return err
+	}
+	return k8s.CopySecretIfMissing(ctx, cfg.HarborPullSecretNamespace, cfg.HarborPullSecretName, cfg.ImagePullNamespace)
+}
+
+// buildPullJob builds the Job that pulls the source image and copies it into Harbor.
+func buildPullJob(jobID, name, tag string) (*batchv1.Job, error) {
+	// --- 映像檔名稱正規化邏輯 ---
+	// "nginx" -> "docker.io/library/nginx", "codercom/code-server" -> "docker.io/codercom/code-server"
+	ref, err := imageref.Parse(imageWithVersion(name, tag))
+	if err != nil {
+		return nil, err
 	}
 
 	// 這是用於 K8s InitContainer 拉取以及 crane copy 來源的完整位址
-	fullImage := fmt.Sprintf("%s:%s", normalizedName, tag)
+	fullImage := imageWithVersion(ref.Name(), tag)
 
 	// 這是推送到內部 Harbor 的位址 (維持原始路徑結構)
-	harborImage := fmt.Sprintf("%s%s:%s", cfg.HarborPrivatePrefix, name, tag)
+	harborImage := cfg.HarborPrivatePrefix + imageWithVersion(name, tag)
 	// --------------------------------
 
+	resources, err := k8s.BuildResourceRequirements(cfg.ImagePullCPURequest, cfg.ImagePullCPULimit,
+		cfg.ImagePullMemoryRequest, cfg.ImagePullMemoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image pull resources: %w", err)
+	}
+
 	ttl := int32(300)
 
-	k8sJob := &batchv1.Job{
+	return &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: "image-puller-",
-			Namespace:    "default",
+			Name:      jobID,
+			Namespace: cfg.ImagePullNamespace,
 		},
 		Spec: batchv1.JobSpec{
 			TTLSecondsAfterFinished: &ttl,
@@ -450,6 +1001,7 @@ func (s *ImageService) PullImageAsync(name, tag string) (string, error) {
 							Image:           fullImage,
 							ImagePullPolicy: corev1.PullAlways,
 							Command:         []string{"/bin/sh", "-c", "echo 'Image pulled successfully'"},
+							Resources:       resources,
 						},
 					},
 					Containers: []corev1.Container{
@@ -458,6 +1010,7 @@ func (s *ImageService) PullImageAsync(name, tag string) (string, error) {
 							Image:           "gcr.io/go-containerregistry/crane:latest",
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Command:         []string{"crane", "copy", fullImage, harborImage, "--insecure"},
+							Resources:       resources,
 							Env: []corev1.EnvVar{
 								{
 									Name:  "DOCKER_CONFIG",
@@ -477,7 +1030,7 @@ func (s *ImageService) PullImageAsync(name, tag string) (string, error) {
 							Name: "docker-config",
 							VolumeSource: corev1.VolumeSource{
 								Secret: &corev1.SecretVolumeSource{
-									SecretName: "harbor-regcred",
+									SecretName: cfg.HarborPullSecretName,
 									Items: []corev1.KeyToPath{
 										{
 											Key:  ".dockerconfigjson",
@@ -491,51 +1044,53 @@ func (s *ImageService) PullImageAsync(name, tag string) (string, error) {
 				},
 			},
 		},
-	}
-
-	createdJob, err := k8s.Clientset.BatchV1().Jobs("default").Create(context.TODO(), k8sJob, metav1.CreateOptions{})
-	if err != nil {
-		log.Printf("Failed to create image pull job: %v", err)
-		return "", err
-	}
-
-	jobID := createdJob.Name
-	pullTracker.AddJob(jobID, name, tag)
-	pullTracker.UpdateJob(jobID, "pulling", 10, "Starting image pull...")
-
-	go s.monitorPullJob(jobID, name, tag)
-
-	log.Printf("Created pull job %s for image: %s", jobID, fullImage)
-	return jobID, nil
+	}, nil
 }
 
-func (s *ImageService) monitorPullJob(jobID, imageName, imageTag string) {
-	ticker := time.NewTicker(2 * time.Second)
+// monitorPullJob polls the pull Job until it finishes or ctx, the job's tracker context, is
+// cancelled by a user cancellation or by server shutdown.
+func (s *ImageService) monitorPullJob(ctx context.Context, jobID, imageName, imageTag string) {
+	defer s.releasePullSlot()
+
+	ticker := time.NewTicker(pullPollInterval)
 	defer ticker.Stop()
 
 	maxRetries := 600
 	retries := 0
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			if pullMonitors.stopping() {
+				if st := pullTracker.GetJob(jobID); st != nil {
+					pullTracker.UpdateJob(jobID, "interrupted", st.Progress, "Server shut down before the pull finished; the pull Job keeps running in the cluster")
+				}
+				pullTracker.RemoveJob(jobID)
+			}
+			log.Printf("Stopped monitoring pull job %s", jobID)
+			return
+		case <-ticker.C:
+		}
+
 		retries++
 		if retries > maxRetries {
-			logs := s.getPodLogsForJob(jobID)
-			errMsg := fmt.Sprintf("Job timeout. Logs: %s", logs)
-			pullTracker.UpdateJob(jobID, "failed", 0, errMsg)
-			pullTracker.RemoveJob(jobID)
+			s.failPullJob(ctx, jobID, "Job timeout")
 			return
 		}
 
-		k8sJob, err := k8s.Clientset.BatchV1().Jobs("default").Get(context.TODO(), jobID, metav1.GetOptions{})
+		callCtx, cancel := context.WithTimeout(ctx, pullMonitorCallTimeout)
+		k8sJob, err := k8s.Clientset.BatchV1().Jobs(cfg.ImagePullNamespace).Get(callCtx, jobID, metav1.GetOptions{})
 		if err != nil {
+			cancel()
 			log.Printf("Error getting job %s: %v", jobID, err)
 			continue
 		}
 
 		labelSelector := fmt.Sprintf("job-name=%s", jobID)
-		pods, err := k8s.Clientset.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{
+		pods, err := k8s.Clientset.CoreV1().Pods(cfg.ImagePullNamespace).List(callCtx, metav1.ListOptions{
 			LabelSelector: labelSelector,
 		})
+		cancel()
 
 		var statusMsg string
 		var progress int
@@ -553,10 +1108,7 @@ func (s *ImageService) monitorPullJob(jobID, imageName, imageTag string) {
 						statusMsg = "Source image pulled, pushing to Harbor..."
 						progress = 60
 					} else {
-						logs := s.getPodLogsForJob(jobID)
-						errMsg := fmt.Sprintf("Failed to pull source image. Logs: %s", logs)
-						pullTracker.UpdateJob(jobID, "failed", 0, errMsg)
-						pullTracker.RemoveJob(jobID)
+						s.failPullJob(ctx, jobID, "Failed to pull source image")
 						return
 					}
 				} else if initStatus.State.Waiting != nil {
@@ -590,10 +1142,7 @@ func (s *ImageService) monitorPullJob(jobID, imageName, imageTag string) {
 					progress = 50
 				}
 			case corev1.PodFailed:
-				logs := s.getPodLogsForJob(jobID)
-				errMsg := fmt.Sprintf("Pod failed. Logs: %s", logs)
-				pullTracker.UpdateJob(jobID, "failed", 0, errMsg)
-				pullTracker.RemoveJob(jobID)
+				s.failPullJob(ctx, jobID, "Pod failed")
 				return
 			}
 		} else {
@@ -602,19 +1151,19 @@ func (s *ImageService) monitorPullJob(jobID, imageName, imageTag string) {
 		}
 
 		if k8sJob.Status.Succeeded > 0 {
-			pullTracker.UpdateJob(jobID, "completed", 100, "Image pushed to Harbor successfully")
-
-			s.markImageAsPulled(imageName, imageTag)
+			tag := s.markImageAsPulled(imageName, imageTag)
+			// The preload runs on its own so this pull's slot is freed for the next one
+			if shouldPreload(tag) && pullMonitors.start(func() { s.preloadImage(ctx, jobID, imageName, imageTag) }) {
+				return
+			}
 
+			pullTracker.UpdateJob(jobID, "completed", 100, "Image pushed to Harbor successfully")
 			pullTracker.RemoveJob(jobID)
 			return
 		}
 
 		if k8sJob.Status.Failed > 0 {
-			logs := s.getPodLogsForJob(jobID)
-			errMsg := fmt.Sprintf("Job failed. Logs: %s", logs)
-			pullTracker.UpdateJob(jobID, "failed", 0, errMsg)
-			pullTracker.RemoveJob(jobID)
+			s.failPullJob(ctx, jobID, "Job failed")
 			return
 		}
 
@@ -622,7 +1171,9 @@ func (s *ImageService) monitorPullJob(jobID, imageName, imageTag string) {
 	}
 }
 
-func (s *ImageService) markImageAsPulled(name, tag string) {
+// markImageAsPulled records the image as available in Harbor and returns its tag, or nil when
+// it could not be recorded.
+func (s *ImageService) markImageAsPulled(name, tag string) *image.ContainerTag {
 	parts := strings.Split(name, "/")
 	var namespace, repoName string
 	if len(parts) >= 2 {
@@ -640,7 +1191,7 @@ func (s *ImageService) markImageAsPulled(name, tag string) {
 	}
 	if err := s.repo.FindOrCreateRepository(repo); err != nil {
 		log.Printf("Failed to find repo for status update: %v", err)
-		return
+		return nil
 	}
 
 	tagEntity := &image.ContainerTag{
@@ -649,7 +1200,7 @@ func (s *ImageService) markImageAsPulled(name, tag string) {
 	}
 	if err := s.repo.FindOrCreateTag(tagEntity); err != nil {
 		log.Printf("Failed to find tag for status update: %v", err)
-		return
+		return nil
 	}
 
 	status := &image.ClusterImageStatus{
@@ -663,50 +1214,55 @@ func (s *ImageService) markImageAsPulled(name, tag string) {
 	} else {
 		log.Printf("Cluster status updated for %s:%s", name, tag)
 	}
+	return tagEntity
 }
 
-func (s *ImageService) getPodLogsForJob(jobName string) string {
-	ctx := context.TODO()
-	labelSelector := fmt.Sprintf("job-name=%s", jobName)
-
-	pods, err := k8s.Clientset.CoreV1().Pods("default").List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
+// failPullJob marks the job failed with its containers' sanitized logs attached and stops tracking it.
+func (s *ImageService) failPullJob(ctx context.Context, jobID, reason string) {
+	ctx, cancel := context.WithTimeout(ctx, pullMonitorCallTimeout)
+	defer cancel()
+	logs, err := k8s.GetJobPodLogs(ctx, cfg.ImagePullNamespace, jobID, k8s.JobLogOptions{
+		TailLines: 100,
+		MaxBytes:  cfg.JobLogMaxBytes,
 	})
 	if err != nil {
-		return fmt.Sprintf("Error listing pods: %v", err)
+		log.Printf("Failed to fetch logs for pull job %s: %v", jobID, err)
 	}
 
-	if len(pods.Items) == 0 {
-		return "No pods found for this job"
+	msg := reason
+	if summary := k8s.SummarizeContainerLogs(logs); summary != "" {
+		msg = fmt.Sprintf("%s: %s", reason, summary)
 	}
+	pullTracker.Fail(jobID, msg, logs)
+}
 
-	var logBuilder strings.Builder
-	for i := range pods.Items {
-		pod := &pods.Items[i]
-		logBuilder.WriteString(fmt.Sprintf("=== Pod: %s ===\n", pod.Name))
-
-		req := k8s.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
-			TailLines: func() *int64 { i := int64(100); return &i }(),
-		})
-
-		stream, err := req.Stream(ctx)
-		if err != nil {
-			logBuilder.WriteString(fmt.Sprintf("Error getting logs: %v\n", err))
-			continue
+// CancelPullJob stops an in-progress or queued pull. Only admins and the original requester may cancel.
+func (s *ImageService) CancelPullJob(ctx context.Context, jobID string, userID uint, isAdmin bool) error {
+	st := pullTracker.GetJob(jobID)
+	if st == nil {
+		if pullTracker.FindFinished(jobID) != nil {
+			return ErrPullJobFinished
 		}
+		return ErrPullJobNotFound
+	}
+	if !isAdmin && st.RequestedBy != userID {
+		return ErrPullJobForbidden
+	}
 
-		data, err := io.ReadAll(stream)
-		_ = stream.Close()
-		if err != nil {
-			logBuilder.WriteString(fmt.Sprintf("Error reading logs: %v\n", err))
-			continue
+	if !pullSlots.remove(jobID) {
+		// The Job exists in the cluster; delete it together with its pods
+		propagation := metav1.DeletePropagationForeground
+		err := k8s.Clientset.BatchV1().Jobs(cfg.ImagePullNamespace).Delete(ctx, jobID, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete pull job %s: %w", jobID, err)
 		}
-
-		logBuilder.Write(data)
-		logBuilder.WriteString("\n")
 	}
 
-	return logBuilder.String()
+	if !pullTracker.Cancel(jobID, userID) {
+		return ErrPullJobFinished
+	}
+	log.Printf("Pull job %s cancelled by user %d", jobID, userID)
+	return nil
 }
 
 func (s *ImageService) GetPullJobStatus(jobID string) *PullJobStatus {
@@ -731,20 +1287,28 @@ func (s *ImageService) validateNameAndTag(name, tag string) string {
 	if name == "" || tag == "" {
 		return "image name/tag should not be empty"
 	}
-
-	nameRe := regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$`)
-	if !nameRe.MatchString(name) {
-		return "image name format looks invalid"
+	if _, err := imageref.Parse(imageWithVersion(name, tag)); err != nil {
+		return err.Error()
 	}
+	return ""
+}
 
-	tagRe := regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
-	if !tagRe.MatchString(tag) {
-		return "image tag format looks invalid"
+// imageWithVersion joins a repository name with a tag, or with the digest of a reference
+// pinned only by digest. Tags cannot contain ":", digests always do.
+func imageWithVersion(name, version string) string {
+	if strings.Contains(version, ":") {
+		return name + "@" + version
 	}
-
-	return ""
+	return name + ":" + version
 }
 
 func (s *ImageService) DisableAllowListRule(id uint) error {
+	rule, err := s.repo.GetAllowListRule(id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkImageNotInUse(rule); err != nil {
+		return err
+	}
 	return s.repo.DisableAllowListRule(id)
 }
@@ -0,0 +1,259 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Per-node states of a preload.
+const (
+	PreloadPending = "pending"
+	PreloadPulling = "pulling"
+	PreloadReady   = "ready"
+	PreloadFailed  = "failed"
+)
+
+// NodePreloadStatus is how far warming the image cache of one node got.
+type NodePreloadStatus struct {
+	Node    string `json:"node"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// preloadPollInterval is how often a preload checks its pods; tests shorten it.
+var preloadPollInterval = 5 * time.Second
+
+// UpdatePreload moves a job to the preload phase with the given per-node states.
+func (pt *PullJobTracker) UpdatePreload(jobID string, nodes []NodePreloadStatus, message string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	job, ok := pt.jobs[jobID]
+	if !ok {
+		return
+	}
+	done := 0
+	for _, n := range nodes {
+		if n.Status == PreloadReady || n.Status == PreloadFailed {
+			done++
+		}
+	}
+	job.Status = "preloading"
+	job.Phase = "preload"
+	job.Nodes = nodes
+	job.Progress = 100
+	if len(nodes) > 0 {
+		job.Progress = done * 100 / len(nodes)
+	}
+	job.Message = message
+	job.UpdatedAt = time.Now()
+	pt.notifyLocked(jobID, job)
+}
+
+// shouldPreload reports whether a pulled tag is warmed on the nodes: the choice made at
+// approval, or config.ImagePreloadEnabled when there was none.
+func shouldPreload(tag *image.ContainerTag) bool {
+	if tag != nil && tag.Preload != nil {
+		return *tag.Preload
+	}
+	return cfg.ImagePreloadEnabled
+}
+
+func preloadDaemonSetName(jobID string) string {
+	return jobID + "-preload"
+}
+
+// buildPreloadDaemonSet builds the DaemonSet that pulls the mirrored image on every node
+// matching config.ImagePreloadNodeSelector, GPU nodes included despite their taint. The image
+// runs as an init container only to be pulled; the pause container keeps the pod alive until
+// the preload deletes the DaemonSet.
+func buildPreloadDaemonSet(jobID, name, tag string) (*appsv1.DaemonSet, error) {
+	resources, err := k8s.BuildResourceRequirements("10m", "100m", "16Mi", "64Mi")
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{"app": "image-preload", "image-preload": jobID}
+	grace := int64(0)
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      preloadDaemonSetName(jobID),
+			Namespace: cfg.ImagePullNamespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeSelector: cfg.ImagePreloadNodeSelector,
+					Tolerations: []corev1.Toleration{
+						{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists},
+					},
+					ImagePullSecrets:              []corev1.LocalObjectReference{{Name: cfg.HarborPullSecretName}},
+					TerminationGracePeriodSeconds: &grace,
+					InitContainers: []corev1.Container{
+						{
+							Name:            "preload",
+							Image:           cfg.HarborPrivatePrefix + imageWithVersion(name, tag),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							// Only the pull matters; images without a shell fail here harmlessly
+							Command:   []string{"sh", "-c", "exit 0"},
+							Resources: resources,
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:            "pause",
+							Image:           cfg.ImagePreloadPauseImage,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Resources:       resources,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// preloadImage warms the node caches with a pulled image and then completes the pull job. The
+// DaemonSet is removed when every targeted node is done, at config.ImagePreloadTimeout, and
+// when ctx is cancelled by a user cancellation or by server shutdown.
+func (s *ImageService) preloadImage(ctx context.Context, jobID, name, tag string) {
+	ds, err := buildPreloadDaemonSet(jobID, name, tag)
+	if err == nil {
+		callCtx, cancel := context.WithTimeout(ctx, pullMonitorCallTimeout)
+		_, err = k8s.Clientset.AppsV1().DaemonSets(ds.Namespace).Create(callCtx, ds, metav1.CreateOptions{})
+		cancel()
+	}
+	if err != nil {
+		log.Printf("Failed to start preload for pull job %s: %v", jobID, err)
+		pullTracker.UpdateJob(jobID, "completed", 100, fmt.Sprintf("Image pushed to Harbor successfully; node preload could not start: %v", err))
+		pullTracker.RemoveJob(jobID)
+		return
+	}
+	defer deletePreloadDaemonSet(ds.Namespace, ds.Name)
+
+	pullTracker.UpdatePreload(jobID, nil, "Image pushed to Harbor, preloading nodes...")
+	deadline := time.NewTimer(cfg.ImagePreloadTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(preloadPollInterval)
+	defer ticker.Stop()
+
+	var nodes []NodePreloadStatus
+	for {
+		var done bool
+		nodes, done = preloadProgress(ctx, ds)
+		if done {
+			break
+		}
+		pullTracker.UpdatePreload(jobID, nodes, fmt.Sprintf("Preloading %d nodes...", len(nodes)))
+
+		select {
+		case <-ctx.Done():
+			if pullMonitors.stopping() {
+				pullTracker.UpdateJob(jobID, "completed", 100, "Image pushed to Harbor successfully; the server shut down before the node preload finished")
+				pullTracker.RemoveJob(jobID)
+			}
+			return
+		case <-deadline.C:
+			pullTracker.UpdatePreload(jobID, nodes, "")
+			pullTracker.UpdateJob(jobID, "completed", 100, fmt.Sprintf("Image pushed to Harbor successfully; preloaded %s before the timeout", preloadSummary(nodes)))
+			pullTracker.RemoveJob(jobID)
+			return
+		case <-ticker.C:
+		}
+	}
+
+	pullTracker.UpdatePreload(jobID, nodes, "")
+	pullTracker.UpdateJob(jobID, "completed", 100, fmt.Sprintf("Image pushed to Harbor and preloaded on %s", preloadSummary(nodes)))
+	pullTracker.RemoveJob(jobID)
+}
+
+// preloadProgress returns the state of each node targeted by the DaemonSet and whether all of
+// them are done, including when no node matches the selector.
+func preloadProgress(ctx context.Context, ds *appsv1.DaemonSet) ([]NodePreloadStatus, bool) {
+	callCtx, cancel := context.WithTimeout(ctx, pullMonitorCallTimeout)
+	defer cancel()
+	current, err := k8s.Clientset.AppsV1().DaemonSets(ds.Namespace).Get(callCtx, ds.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, false
+	}
+	pods, err := k8s.Clientset.CoreV1().Pods(ds.Namespace).List(callCtx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("image-preload=%s", ds.Labels["image-preload"]),
+	})
+	if err != nil {
+		return nil, false
+	}
+
+	var nodes []NodePreloadStatus
+	done := 0
+	for i := range pods.Items {
+		n := nodePreloadStatus(&pods.Items[i])
+		if n.Node == "" {
+			continue
+		}
+		if n.Status == PreloadReady || n.Status == PreloadFailed {
+			done++
+		}
+		nodes = append(nodes, n)
+	}
+	desired := int(current.Status.DesiredNumberScheduled)
+	observed := current.Status.ObservedGeneration >= current.Generation
+	return nodes, observed && len(nodes) >= desired && done == len(nodes)
+}
+
+// nodePreloadStatus reads the state of the preload init container of a pod: the image is on
+// the node once the container has an image ID or has started at all.
+func nodePreloadStatus(pod *corev1.Pod) NodePreloadStatus {
+	n := NodePreloadStatus{Node: pod.Spec.NodeName, Status: PreloadPending}
+	for _, cs := range pod.Status.InitContainerStatuses {
+		if cs.Name != "preload" {
+			continue
+		}
+		switch {
+		case cs.ImageID != "" || cs.State.Running != nil || cs.State.Terminated != nil:
+			n.Status = PreloadReady
+		case cs.State.Waiting != nil:
+			switch cs.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName":
+				n.Status, n.Message = PreloadFailed, cs.State.Waiting.Message
+			default:
+				n.Status = PreloadPulling
+			}
+		}
+	}
+	return n
+}
+
+func preloadSummary(nodes []NodePreloadStatus) string {
+	ready := 0
+	for _, n := range nodes {
+		if n.Status == PreloadReady {
+			ready++
+		}
+	}
+	return fmt.Sprintf("%d/%d nodes", ready, len(nodes))
+}
+
+// deletePreloadDaemonSet removes a preload with its pods. It does not use the job context,
+// which is already cancelled when the pull was cancelled or the server is shutting down.
+func deletePreloadDaemonSet(ns, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), pullMonitorCallTimeout)
+	defer cancel()
+	propagation := metav1.DeletePropagationForeground
+	err := k8s.Clientset.AppsV1().DaemonSets(ns).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("Failed to clean up preload %s: %v", name, err)
+	}
+}
@@ -0,0 +1,200 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestBuildPreloadDaemonSet(t *testing.T) {
+	origPrefix, origSelector := cfg.HarborPrivatePrefix, cfg.ImagePreloadNodeSelector
+	t.Cleanup(func() { cfg.HarborPrivatePrefix, cfg.ImagePreloadNodeSelector = origPrefix, origSelector })
+	cfg.HarborPrivatePrefix = "harbor.local/library/"
+	cfg.ImagePreloadNodeSelector = map[string]string{"nvidia.com/gpu.present": "true"}
+
+	ds, err := buildPreloadDaemonSet("image-puller-abcde", "pytorch/pytorch", "2.3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ds.Name != "image-puller-abcde-preload" || ds.Namespace != cfg.ImagePullNamespace {
+		t.Fatalf("unexpected name %s/%s", ds.Namespace, ds.Name)
+	}
+	spec := ds.Spec.Template.Spec
+	if spec.NodeSelector["nvidia.com/gpu.present"] != "true" || len(spec.NodeSelector) != 1 {
+		t.Fatalf("unexpected node selector %v", spec.NodeSelector)
+	}
+	if len(spec.Tolerations) != 1 || spec.Tolerations[0].Key != "nvidia.com/gpu" || spec.Tolerations[0].Operator != corev1.TolerationOpExists {
+		t.Fatalf("expected the GPU taint to be tolerated, got %+v", spec.Tolerations)
+	}
+	if spec.Tolerations[0].Effect != "" {
+		t.Fatalf("the toleration should match every effect, got %s", spec.Tolerations[0].Effect)
+	}
+	preload := spec.InitContainers[0]
+	if preload.Image != "harbor.local/library/pytorch/pytorch:2.3" || preload.ImagePullPolicy != corev1.PullIfNotPresent {
+		t.Fatalf("unexpected preload container %s (%s)", preload.Image, preload.ImagePullPolicy)
+	}
+	if len(spec.ImagePullSecrets) != 1 || spec.ImagePullSecrets[0].Name != cfg.HarborPullSecretName {
+		t.Fatalf("expected the Harbor pull secret, got %+v", spec.ImagePullSecrets)
+	}
+	if got := ds.Spec.Selector.MatchLabels["image-preload"]; got != "image-puller-abcde" || ds.Spec.Template.Labels["image-preload"] != got {
+		t.Fatalf("selector and pod labels must match, got %v / %v", ds.Spec.Selector.MatchLabels, ds.Spec.Template.Labels)
+	}
+}
+
+func TestShouldPreload(t *testing.T) {
+	orig := cfg.ImagePreloadEnabled
+	t.Cleanup(func() { cfg.ImagePreloadEnabled = orig })
+	yes, no := true, false
+
+	cfg.ImagePreloadEnabled = false
+	if shouldPreload(nil) || shouldPreload(&image.ContainerTag{}) {
+		t.Fatal("expected the config default when the approval did not choose")
+	}
+	if !shouldPreload(&image.ContainerTag{Preload: &yes}) {
+		t.Fatal("expected the approval to enable preload")
+	}
+	cfg.ImagePreloadEnabled = true
+	if shouldPreload(&image.ContainerTag{Preload: &no}) {
+		t.Fatal("expected the approval to disable preload")
+	}
+}
+
+func TestApproveRequestStoresPreloadChoice(t *testing.T) {
+	repo := newFakeRepo()
+	svc := NewImageService(repo)
+	_ = repo.CreateRequest(&image.ImageRequest{InputImageName: "nginx", InputTag: "1.25", Status: "pending"})
+	yes := true
+
+	if err := svc.ApproveRequest(1, "", true, 9, &yes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	found := false
+	for _, tag := range repo.tags {
+		if tag.Name == "1.25" {
+			found = tag.Preload != nil && *tag.Preload
+		}
+	}
+	if !found {
+		t.Fatalf("expected the tag to keep the preload choice, got %+v", repo.tags)
+	}
+}
+
+// preloadCluster serves the DaemonSet as scheduled on desired nodes, since the fake clientset
+// runs no controller.
+func preloadCluster(desired int32, objects ...runtime.Object) *k8sfake.Clientset {
+	client := k8sfake.NewSimpleClientset(objects...)
+	client.PrependReactor("get", "daemonsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		obj, err := client.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("daemonsets"), get.GetNamespace(), get.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		ds := obj.(*appsv1.DaemonSet).DeepCopy()
+		ds.Status.DesiredNumberScheduled = desired
+		return true, ds, nil
+	})
+	return client
+}
+
+func preloadPod(jobID, node string, init corev1.ContainerStatus) *corev1.Pod {
+	init.Name = "preload"
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: jobID + "-" + node, Namespace: cfg.ImagePullNamespace, Labels: map[string]string{"image-preload": jobID}},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status:     corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{init}},
+	}
+}
+
+func TestPreloadImageTracksNodesAndCleansUp(t *testing.T) {
+	origClient, origPoll := k8s.Clientset, preloadPollInterval
+	t.Cleanup(func() { k8s.Clientset, preloadPollInterval = origClient, origPoll })
+	preloadPollInterval = 5 * time.Millisecond
+
+	jobID := "image-puller-warm1"
+	client := preloadCluster(2,
+		preloadPod(jobID, "gpu-1", corev1.ContainerStatus{ImageID: "harbor.local/library/nginx@sha256:abc"}),
+		preloadPod(jobID, "gpu-2", corev1.ContainerStatus{State: corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "no space left on device"},
+		}}),
+	)
+	k8s.Clientset = client
+	pullTracker.AddJob(jobID, "nginx", "1.25", 1)
+	events := pullTracker.Subscribe(jobID)
+
+	NewImageService(newFakeRepo()).preloadImage(pullTracker.Context(jobID), jobID, "nginx", "1.25")
+
+	sawPreload := false
+	for st := range events {
+		sawPreload = sawPreload || st.Phase == "preload"
+	}
+	if !sawPreload {
+		t.Fatal("expected the preload to be reported as its own phase")
+	}
+	final := pullTracker.FindFinished(jobID)
+	if final == nil || final.Status != "completed" || len(final.Nodes) != 2 {
+		t.Fatalf("expected a completed job with both nodes, got %+v", final)
+	}
+	states := map[string]string{}
+	for _, n := range final.Nodes {
+		states[n.Node] = n.Status
+	}
+	if states["gpu-1"] != PreloadReady || states["gpu-2"] != PreloadFailed {
+		t.Fatalf("unexpected node states %v", states)
+	}
+	list, _ := client.AppsV1().DaemonSets(cfg.ImagePullNamespace).List(context.Background(), metav1.ListOptions{})
+	if len(list.Items) != 0 {
+		t.Fatalf("expected the preload DaemonSet to be deleted, found %d", len(list.Items))
+	}
+}
+
+func TestPreloadImageCleansUpOnCancel(t *testing.T) {
+	origClient, origPoll := k8s.Clientset, preloadPollInterval
+	t.Cleanup(func() { k8s.Clientset, preloadPollInterval = origClient, origPoll })
+	preloadPollInterval = 5 * time.Millisecond
+
+	jobID := "image-puller-warm2"
+	client := preloadCluster(1, preloadPod(jobID, "gpu-1", corev1.ContainerStatus{State: corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"},
+	}}))
+	k8s.Clientset = client
+	pullTracker.AddJob(jobID, "nginx", "1.25", 1)
+	events := pullTracker.Subscribe(jobID)
+
+	done := make(chan struct{})
+	go func() {
+		NewImageService(newFakeRepo()).preloadImage(pullTracker.Context(jobID), jobID, "nginx", "1.25")
+		close(done)
+	}()
+	timeout := time.After(time.Second)
+	for pulling := false; !pulling; {
+		select {
+		case st := <-events:
+			pulling = len(st.Nodes) == 1 && st.Nodes[0].Status == PreloadPulling
+		case <-timeout:
+			t.Fatal("preload did not report the pulling node")
+		}
+	}
+
+	if !pullTracker.Cancel(jobID, 1) {
+		t.Fatal("expected the preloading job to be cancellable")
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("preload did not stop after the cancellation")
+	}
+	list, _ := client.AppsV1().DaemonSets(cfg.ImagePullNamespace).List(context.Background(), metav1.ListOptions{})
+	if len(list.Items) != 0 {
+		t.Fatalf("expected the preload DaemonSet to be deleted, found %d", len(list.Items))
+	}
+}
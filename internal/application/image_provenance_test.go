@@ -0,0 +1,140 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var provenanceReviewedAt = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+// approvedImageRepo allow-lists pytorch/pytorch through request 9, reviewed by user 4, and
+// python:3.11 through a rule admin 1 added directly.
+type approvedImageRepo struct {
+	*fakeRepo
+}
+
+func newApprovedImageRepo() *approvedImageRepo {
+	repo := &approvedImageRepo{newFakeRepo()}
+	reviewer := uint(4)
+	repo.reqs[9] = &image.ImageRequest{Model: gorm.Model{ID: 9}, ReviewerID: &reviewer, ReviewedAt: &provenanceReviewedAt}
+	return repo
+}
+
+func (r *approvedImageRepo) FindAllowListRule(projectID *uint, repoFullName, tagName string) (*image.ImageAllowList, error) {
+	switch repoFullName {
+	case "pytorch/pytorch":
+		requestID := uint(9)
+		return &image.ImageAllowList{RequestID: &requestID, CreatedBy: 1}, nil
+	case "python":
+		return &image.ImageAllowList{Model: gorm.Model{CreatedAt: provenanceReviewedAt.Add(-time.Hour)}, CreatedBy: 1}, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *approvedImageRepo) CheckImageAllowed(projectID *uint, repoFullName string, tagName string) (bool, error) {
+	rule, _ := r.FindAllowListRule(projectID, repoFullName, tagName)
+	return rule != nil, nil
+}
+
+func TestImageProvenance(t *testing.T) {
+	origPrefix := config.HarborPrivatePrefix
+	t.Cleanup(func() { config.HarborPrivatePrefix = origPrefix })
+	config.HarborPrivatePrefix = "harbor.local/library/"
+	svc := NewImageService(newApprovedImageRepo())
+	pid := uint(1)
+
+	for _, img := range []string{"pytorch/pytorch:2.1", "docker.io/pytorch/pytorch:2.1", "harbor.local/library/pytorch/pytorch:2.1"} {
+		p, err := svc.ImageProvenance(img, &pid)
+		if err != nil || p == nil {
+			t.Fatalf("%s: expected provenance, got %v, %v", img, p, err)
+		}
+		if p.RequestID != 9 || p.ApprovedBy != 4 || !p.ApprovedAt.Equal(provenanceReviewedAt) {
+			t.Fatalf("%s: expected the reviewer of request 9, got %+v", img, p)
+		}
+	}
+
+	p, err := svc.ImageProvenance("python:3.11", &pid)
+	if err != nil || p == nil || p.RequestID != 0 || p.ApprovedBy != 1 || !p.ApprovedAt.Equal(provenanceReviewedAt.Add(-time.Hour)) {
+		t.Fatalf("expected the admin who added the rule, got %+v, %v", p, err)
+	}
+	if _, ok := p.Annotations()[k8s.AnnotationImageRequestID]; ok {
+		t.Fatal("expected no request ID for a rule added directly")
+	}
+
+	if p, err := svc.ImageProvenance("busybox:1.36", &pid); err != nil || p != nil {
+		t.Fatalf("expected no provenance for an image not allow-listed, got %+v, %v", p, err)
+	}
+}
+
+func TestProvenanceStepAnnotatesPodTemplates(t *testing.T) {
+	svc := &ConfigFileService{imageService: NewImageService(newApprovedImageRepo())}
+	doc := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"train"},"spec":{"template":{
+		"metadata":{"annotations":{"team":"vision"}},
+		"spec":{"containers":[{"name":"sidecar","image":"busybox:1.36"},{"name":"main","image":"pytorch/pytorch:2.1"}]}}}}`
+
+	run := func(admin bool) map[string]interface{} {
+		out, err := pipelineStep(t, svc, InstanceStepProvenance).Run([]byte(doc), &instanceDocContext{Patch: &PatchContext{ProjectID: 1, UserIsAdmin: admin}})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(out, &obj); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		meta := findPodTemplates(obj)[0]["metadata"].(map[string]interface{})
+		return meta["annotations"].(map[string]interface{})
+	}
+
+	annotations := run(false)
+	if annotations[k8s.AnnotationImageRequestID] != "9" || annotations[k8s.AnnotationApprovedBy] != "4" ||
+		annotations[k8s.AnnotationApprovedAt] != "2026-03-02T10:00:00Z" || annotations["team"] != "vision" {
+		t.Fatalf("unexpected annotations %v", annotations)
+	}
+
+	// Admins bypass the allow list
+	annotations = run(true)
+	if _, ok := annotations[k8s.AnnotationApprovedBy]; ok || len(annotations) != 1 {
+		t.Fatalf("expected no provenance for an admin, got %v", annotations)
+	}
+}
+
+func TestCreateJobRecordsImageProvenance(t *testing.T) {
+	origClient := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = origClient })
+	client := k8sfake.NewSimpleClientset()
+	k8s.Clientset = client
+	svc, _ := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1})
+	svc.imageService = NewImageService(newApprovedImageRepo())
+
+	for name, img := range map[string]string{"approved": "pytorch/pytorch:2.1", "unlisted": "busybox:latest"} {
+		if _, err := svc.CreateJob(context.Background(), 2, job.JobSubmission{Name: name, Namespace: "proj-7-user2", Image: img}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	approved, err := client.BatchV1().Jobs("proj-7-user2").Get(context.Background(), "approved", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if got := k8s.ProvenanceFromAnnotations(approved.Spec.Template.Annotations); got == nil || got.RequestID != 9 || got.ApprovedBy != 4 {
+		t.Fatalf("expected the provenance on the pod template, got %v", approved.Spec.Template.Annotations)
+	}
+	unlisted, err := client.BatchV1().Jobs("proj-7-user2").Get(context.Background(), "unlisted", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if _, ok := unlisted.Spec.Template.Annotations[k8s.AnnotationApprovedBy]; ok {
+		t.Fatalf("expected no provenance for an image not allow-listed, got %v", unlisted.Spec.Template.Annotations)
+	}
+}
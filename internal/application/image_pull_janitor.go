@@ -0,0 +1,94 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PullSweepResult is a tracked pull the janitor settled.
+type PullSweepResult struct {
+	JobID  string `json:"job_id"`
+	Image  string `json:"image"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// staleJobs returns copies of the active jobs not updated since cutoff.
+func (pt *PullJobTracker) staleJobs(cutoff time.Time) []PullJobStatus {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	var stale []PullJobStatus
+	for _, job := range pt.jobs {
+		if job.UpdatedAt.Before(cutoff) {
+			stale = append(stale, *job)
+		}
+	}
+	return stale
+}
+
+// queued reports whether the request of jobID is waiting for a slot.
+func (g *pullGate) queued(jobID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, req := range g.queue {
+		if req.jobID == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// SweepPullJobs settles tracked pulls without a status update for cfg.ImagePullStaleAfter,
+// whose monitor is gone, by checking their Job: pulls whose Job is missing are failed as lost,
+// and pulls whose Job finished get its outcome. Pulls still waiting for a slot, still running,
+// or warming node caches are left alone.
+func (s *ImageService) SweepPullJobs(ctx context.Context) ([]PullSweepResult, error) {
+	results := []PullSweepResult{}
+	if k8s.Clientset == nil {
+		return results, nil
+	}
+	for _, st := range pullTracker.staleJobs(time.Now().Add(-cfg.ImagePullStaleAfter)) {
+		if st.Phase == "preload" || pullSlots.queued(st.JobID) {
+			continue
+		}
+		callCtx, cancel := context.WithTimeout(ctx, pullMonitorCallTimeout)
+		k8sJob, err := k8s.Clientset.BatchV1().Jobs(cfg.ImagePullNamespace).Get(callCtx, st.JobID, metav1.GetOptions{})
+		cancel()
+
+		result := PullSweepResult{JobID: st.JobID, Image: imageWithVersion(st.ImageName, st.ImageTag)}
+		switch {
+		case apierrors.IsNotFound(err):
+			result.Status = "failed"
+			if st.Status == "queued" {
+				result.Reason = "lost: the pull left the queue without starting"
+			} else {
+				result.Reason = "lost: the pull Job no longer exists"
+			}
+			pullTracker.Fail(st.JobID, result.Reason, nil)
+		case err != nil:
+			return results, fmt.Errorf("failed to look up pull job %s: %w", st.JobID, err)
+		case k8sJob.Status.Succeeded > 0:
+			s.markImageAsPulled(st.ImageName, st.ImageTag)
+			result.Status = "completed"
+			result.Reason = "the pull Job succeeded after its monitor stopped"
+			pullTracker.UpdateJob(st.JobID, "completed", 100, "Image pushed to Harbor successfully")
+			pullTracker.RemoveJob(st.JobID)
+		case k8sJob.Status.Failed > 0:
+			result.Status = "failed"
+			result.Reason = "the pull Job failed after its monitor stopped"
+			s.failPullJob(ctx, st.JobID, result.Reason)
+		default:
+			continue
+		}
+		log.Printf("[image-pull] settled stale pull %s of %s as %s: %s", result.JobID, result.Image, result.Status, result.Reason)
+		results = append(results, result)
+	}
+	return results, nil
+}
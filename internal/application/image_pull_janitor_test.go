@@ -0,0 +1,89 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// trackStalePull tracks a pull last updated an hour ago with the given status.
+func trackStalePull(t *testing.T, jobID, status string) {
+	t.Helper()
+	pullTracker.AddJob(jobID, "nginx", "1.25", 1)
+	pullTracker.UpdateJob(jobID, status, 10, "")
+	pullTracker.mu.Lock()
+	pullTracker.jobs[jobID].UpdatedAt = time.Now().Add(-time.Hour)
+	pullTracker.mu.Unlock()
+	t.Cleanup(func() { pullTracker.RemoveJob(jobID) })
+}
+
+func TestSweepPullJobsSettlesLostAndFinishedPulls(t *testing.T) {
+	origClient := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = origClient })
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "image-puller-done1", Namespace: cfg.ImagePullNamespace}, Status: batchv1.JobStatus{Succeeded: 1}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "image-puller-busy1", Namespace: cfg.ImagePullNamespace}, Status: batchv1.JobStatus{Active: 1}},
+	)
+
+	trackStalePull(t, "image-puller-lost1", "pulling")
+	trackStalePull(t, "image-puller-done1", "pulling")
+	trackStalePull(t, "image-puller-busy1", "pulling")
+	trackStalePull(t, "image-puller-wait1", "queued")
+	pullSlots.mu.Lock()
+	pullSlots.queue = append(pullSlots.queue, pullRequest{jobID: "image-puller-wait1"})
+	pullSlots.mu.Unlock()
+	t.Cleanup(func() { pullSlots.remove("image-puller-wait1") })
+	pullTracker.AddJob("image-puller-new1", "nginx", "1.25", 1)
+	t.Cleanup(func() { pullTracker.RemoveJob("image-puller-new1") })
+
+	results, err := NewImageService(newFakeRepo()).SweepPullJobs(context.Background())
+	if err != nil {
+		t.Fatalf("SweepPullJobs: %v", err)
+	}
+	settled := map[string]string{}
+	for _, r := range results {
+		settled[r.JobID] = r.Status
+	}
+	if len(settled) != 2 || settled["image-puller-lost1"] != "failed" || settled["image-puller-done1"] != "completed" {
+		t.Fatalf("expected only the lost and the finished pull to be settled, got %+v", results)
+	}
+
+	lost := pullTracker.FindFinished("image-puller-lost1")
+	if lost == nil || lost.Status != "failed" || lost.Message != "lost: the pull Job no longer exists" {
+		t.Fatalf("expected the lost pull to fail, got %+v", lost)
+	}
+	if failed := pullTracker.GetFailedJobs(0); len(failed) == 0 || failed[0].JobID != "image-puller-lost1" {
+		t.Fatalf("expected the lost pull in the failed list, got %+v", failed)
+	}
+	if done := pullTracker.FindFinished("image-puller-done1"); done == nil || done.Status != "completed" {
+		t.Fatalf("expected the finished pull to complete, got %+v", done)
+	}
+	for _, id := range []string{"image-puller-busy1", "image-puller-wait1", "image-puller-new1"} {
+		if pullTracker.GetJob(id) == nil {
+			t.Fatalf("expected %s to stay active", id)
+		}
+	}
+}
+
+func TestFailedPullHistoryIsCappedPerImage(t *testing.T) {
+	tracker := &PullJobTracker{}
+	for i := 0; i < cfg.ImagePullFailedPerImage+3; i++ {
+		tracker.archiveFailedLocked(&PullJobStatus{JobID: fmt.Sprintf("nginx-%d", i), ImageName: "nginx", ImageTag: "1.25"})
+	}
+	tracker.archiveFailedLocked(&PullJobStatus{JobID: "redis-0", ImageName: "redis", ImageTag: "7"})
+
+	failed := tracker.GetFailedJobs(0)
+	if len(failed) != cfg.ImagePullFailedPerImage+1 {
+		t.Fatalf("expected %d nginx failures and the redis one, got %d", cfg.ImagePullFailedPerImage, len(failed))
+	}
+	if failed[0].JobID != "redis-0" || failed[len(failed)-1].JobID != "nginx-3" {
+		t.Fatalf("expected the oldest nginx failures to be dropped, got newest %s and oldest %s", failed[0].JobID, failed[len(failed)-1].JobID)
+	}
+}
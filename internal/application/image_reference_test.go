@@ -0,0 +1,174 @@
+package application
+
+import (
+	"errors"
+	"testing"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	imageref "github.com/linskybing/platform-go/pkg/image"
+)
+
+const testImageDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// allowingImageRepo allows the "name|tag" pairs in allowed and records every check.
+type allowingImageRepo struct {
+	*fakeRepo
+	allowed map[string]bool
+	checked []string
+}
+
+func (r *allowingImageRepo) CheckImageAllowed(projectID *uint, repoFullName string, tagName string) (bool, error) {
+	key := repoFullName + "|" + tagName
+	r.checked = append(r.checked, key)
+	return r.allowed[key], nil
+}
+
+func TestPatchImagesValidatesEveryReference(t *testing.T) {
+	cases := []struct {
+		image   string
+		checked string
+		allowed bool
+		wantErr error
+	}{
+		{"nginx", "nginx|latest", true, nil},
+		{"nginx", "nginx|latest", false, ErrImageNotAllowed},
+		{"docker.io/library/nginx:1.25", "nginx|1.25", true, nil},
+		{"nginx@" + testImageDigest, "nginx|" + testImageDigest, false, ErrImageNotAllowed},
+		{"registry.lab.edu:5000/team/app", "registry.lab.edu:5000/team/app|latest", true, nil},
+		{"Team/App:v1", "", false, imageref.ErrInvalidReference},
+	}
+	for _, tc := range cases {
+		repo := &allowingImageRepo{fakeRepo: newFakeRepo(), allowed: map[string]bool{}}
+		if tc.checked != "" {
+			repo.allowed[tc.checked] = tc.allowed
+		}
+		svc := &ConfigFileService{imageService: NewImageService(repo)}
+		spec := map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "main", "image": tc.image}},
+		}
+
+		err := svc.patchImages(spec, &PatchContext{ProjectID: 1})
+		if tc.wantErr == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.image, err)
+		}
+		if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected %v, got %v", tc.image, tc.wantErr, err)
+		}
+		if tc.checked != "" && (len(repo.checked) != 1 || repo.checked[0] != tc.checked) {
+			t.Errorf("%s: checked %v, want [%s]", tc.image, repo.checked, tc.checked)
+		}
+	}
+}
+
+func TestPrefixAllowedImage(t *testing.T) {
+	orig := cfg.HarborPrivatePrefix
+	t.Cleanup(func() { cfg.HarborPrivatePrefix = orig })
+	cfg.HarborPrivatePrefix = "harbor.local/library/"
+
+	repo := &allowingImageRepo{fakeRepo: newFakeRepo(), allowed: map[string]bool{
+		"nginx|latest":                  true,
+		"nginx|1.25":                    true,
+		"nginx|" + testImageDigest:      true,
+		"ghcr.io/lab/trainer|v1":        true,
+		"harbor.local/library/app|v2":   true,
+		"registry.lab.edu:5000/app|dev": true,
+	}}
+	svc := NewImageService(repo)
+	projectID := uint(1)
+
+	cases := []struct {
+		image string
+		want  string
+	}{
+		{"nginx", "harbor.local/library/nginx:latest"},
+		{"docker.io/library/nginx:1.25", "harbor.local/library/nginx:1.25"},
+		{"nginx@" + testImageDigest, "harbor.local/library/nginx@" + testImageDigest},
+		{"ghcr.io/lab/trainer:v1", "harbor.local/library/ghcr.io/lab/trainer:v1"},
+		{"registry.lab.edu:5000/app:dev", "harbor.local/library/registry.lab.edu:5000/app:dev"},
+		{"harbor.local/library/app:v2", "harbor.local/library/app:v2"},
+		{"busybox:1.36", "busybox:1.36"},
+	}
+	for _, tc := range cases {
+		got, err := svc.PrefixAllowedImage(tc.image, &projectID)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.image, err)
+		}
+		if got != tc.want {
+			t.Errorf("PrefixAllowedImage(%q) = %q, want %q", tc.image, got, tc.want)
+		}
+	}
+
+	if got, err := svc.PrefixAllowedImage("nginx:", &projectID); !errors.Is(err, imageref.ErrInvalidReference) || got != "nginx:" {
+		t.Errorf("expected an invalid reference to be returned unchanged with an error, got %q, %v", got, err)
+	}
+}
+
+func TestSubmitRequestNormalizesReference(t *testing.T) {
+	cases := []struct {
+		registry, name, tag string
+		wantRegistry        string
+		wantName            string
+		wantTag             string
+		wantChecked         string
+	}{
+		{"", "nginx", "1.25", "", "nginx", "1.25", "nginx|1.25"},
+		{"", "docker.io/library/nginx", "1.25", "", "nginx", "1.25", "nginx|1.25"},
+		{"docker.io", "pytorch/pytorch", "2.3", "", "pytorch/pytorch", "2.3", "pytorch/pytorch|2.3"},
+		{"", "192.168.110.1:30003/library/pros-cameraapi", "1.0", "192.168.110.1:30003", "library/pros-cameraapi", "1.0", "192.168.110.1:30003/library/pros-cameraapi|1.0"},
+		{"Registry.Lab.edu:5000", "team/app", "", "registry.lab.edu:5000", "team/app", "latest", "registry.lab.edu:5000/team/app|latest"},
+	}
+	for _, tc := range cases {
+		repo := &allowingImageRepo{fakeRepo: newFakeRepo(), allowed: map[string]bool{}}
+		req, err := NewImageService(repo).SubmitRequest(1, tc.registry, tc.name, tc.tag, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+		if req.InputRegistry != tc.wantRegistry || req.InputImageName != tc.wantName || req.InputTag != tc.wantTag {
+			t.Errorf("%s: stored %q %q %q, want %q %q %q", tc.name, req.InputRegistry, req.InputImageName, req.InputTag,
+				tc.wantRegistry, tc.wantName, tc.wantTag)
+		}
+		if len(repo.checked) != 1 || repo.checked[0] != tc.wantChecked {
+			t.Errorf("%s: checked %v, want [%s]", tc.name, repo.checked, tc.wantChecked)
+		}
+	}
+
+	repo := &allowingImageRepo{fakeRepo: newFakeRepo(), allowed: map[string]bool{}}
+	if _, err := NewImageService(repo).SubmitRequest(1, "", "Not Valid", "1", nil); !errors.Is(err, imageref.ErrInvalidReference) {
+		t.Fatalf("expected ErrInvalidReference, got %v", err)
+	}
+}
+
+func TestBuildPullJobNormalizesSource(t *testing.T) {
+	orig := cfg.HarborPrivatePrefix
+	t.Cleanup(func() { cfg.HarborPrivatePrefix = orig })
+	cfg.HarborPrivatePrefix = "harbor.local/library/"
+
+	cases := []struct {
+		name, tag string
+		source    string
+		target    string
+	}{
+		{"nginx", "1.25", "docker.io/library/nginx:1.25", "harbor.local/library/nginx:1.25"},
+		{"codercom/code-server", "latest", "docker.io/codercom/code-server:latest", "harbor.local/library/codercom/code-server:latest"},
+		{"registry.lab.edu:5000/app", "dev", "registry.lab.edu:5000/app:dev", "harbor.local/library/registry.lab.edu:5000/app:dev"},
+		{"nginx", testImageDigest, "docker.io/library/nginx@" + testImageDigest, "harbor.local/library/nginx@" + testImageDigest},
+	}
+	for _, tc := range cases {
+		j, err := buildPullJob("image-puller-test", tc.name, tc.tag)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+		spec := j.Spec.Template.Spec
+		if got := spec.InitContainers[0].Image; got != tc.source {
+			t.Errorf("%s: source image = %q, want %q", tc.name, got, tc.source)
+		}
+		cmd := spec.Containers[0].Command
+		if cmd[2] != tc.source || cmd[3] != tc.target {
+			t.Errorf("%s: copy command = %v", tc.name, cmd)
+		}
+	}
+
+	if _, err := buildPullJob("image-puller-test", "nginx", "bad tag"); !errors.Is(err, imageref.ErrInvalidReference) {
+		t.Fatalf("expected ErrInvalidReference, got %v", err)
+	}
+}
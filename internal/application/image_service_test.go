@@ -1,11 +1,20 @@
 package application
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
+	cfg "github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/pagination"
+	"github.com/linskybing/platform-go/pkg/registry"
 	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 )
 
 // fakeRepo implements the minimal methods used by ApproveRequest
@@ -71,6 +80,9 @@ func (f *fakeRepo) CreateAllowListRule(rule *image.ImageAllowList) error {
 func (f *fakeRepo) ListRequests(projectID *uint, status string) ([]image.ImageRequest, error) {
 	return nil, nil
 }
+func (f *fakeRepo) ListRequestsPage(*uint, string, pagination.Params) (*pagination.Page[image.ImageRequest], error) {
+	return &pagination.Page[image.ImageRequest]{}, nil
+}
 func (f *fakeRepo) FindAllRequests() ([]image.ImageRequest, error)                 { return nil, nil }
 func (f *fakeRepo) FindRequestsByUserID(userID uint) ([]image.ImageRequest, error) { return nil, nil }
 func (f *fakeRepo) ListAllowedImages(projectID *uint) ([]image.ImageAllowList, error) {
@@ -110,6 +122,20 @@ func (f *fakeRepo) WithTx(tx *gorm.DB) image.Repository
 func (f *fakeRepo) GetTagByDigest(repoID uint, digest string) (*image.ContainerTag, error) {
 	return nil, nil
 }
+func (f *fakeRepo) FindRepositoryByFullName(fullName string) (*image.ContainerRepository, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+func (f *fakeRepo) ListTagsByRepository(repoID uint) ([]image.ContainerTag, error) { return nil, nil }
+func (f *fakeRepo) MarkTagSeenRunning(tagID uint, at time.Time) error              { return nil }
+func (f *fakeRepo) SetTagPreload(tagID uint, preload *bool) error {
+	if t, ok := f.tags[tagID]; ok {
+		t.Preload = preload
+	}
+	return nil
+}
+func (f *fakeRepo) GetAllowListRule(id uint) (*image.ImageAllowList, error) {
+	return nil, gorm.ErrRecordNotFound
+}
 
 func TestApproveRequest(t *testing.T) {
 	repo := newFakeRepo()
@@ -129,7 +155,7 @@ func TestApproveRequest(t *testing.T) {
 
 	approver := uint(99)
 
-	err := svc.ApproveRequest(1, "ok", false, approver)
+	err := svc.ApproveRequest(1, "ok", false, approver, nil)
 	if err != nil {
 		t.Fatalf("ApproveRequest returned error: %v", err)
 	}
@@ -157,3 +183,263 @@ func TestApproveRequest(t *testing.T) {
 		t.Fatalf("allowlist rule not enabled")
 	}
 }
+
+func TestBuildPullJobResourceLimits(t *testing.T) {
+	j, err := buildPullJob("image-puller-test", "nginx", "1.25")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if j.Namespace != cfg.ImagePullNamespace {
+		t.Fatalf("expected namespace %s, got %s", cfg.ImagePullNamespace, j.Namespace)
+	}
+
+	podSpec := j.Spec.Template.Spec
+	for _, c := range append(podSpec.InitContainers, podSpec.Containers...) {
+		if got := c.Resources.Limits[corev1.ResourceCPU]; got.String() != cfg.ImagePullCPULimit {
+			t.Errorf("%s: expected cpu limit %s, got %s", c.Name, cfg.ImagePullCPULimit, got.String())
+		}
+		if got := c.Resources.Limits[corev1.ResourceMemory]; got.String() != cfg.ImagePullMemoryLimit {
+			t.Errorf("%s: expected memory limit %s, got %s", c.Name, cfg.ImagePullMemoryLimit, got.String())
+		}
+		if got := c.Resources.Requests[corev1.ResourceCPU]; got.String() != cfg.ImagePullCPURequest {
+			t.Errorf("%s: expected cpu request %s, got %s", c.Name, cfg.ImagePullCPURequest, got.String())
+		}
+		if got := c.Resources.Requests[corev1.ResourceMemory]; got.String() != cfg.ImagePullMemoryRequest {
+			t.Errorf("%s: expected memory request %s, got %s", c.Name, cfg.ImagePullMemoryRequest, got.String())
+		}
+	}
+}
+
+// isolatePulls gives the test its own pull slots and monitors, one slot wide. Cleanup stops the
+// monitors and waits for them before restoring the globals: a monitor releases its slot after
+// the tracker dropped its job, so a test seeing the job gone may still race with it.
+func isolatePulls(t *testing.T) *k8sfake.Clientset {
+	t.Helper()
+	origClient, origMax, origSlots, origMonitors := k8s.Clientset, cfg.ImagePullMaxConcurrent, pullSlots, pullMonitors
+	t.Cleanup(func() {
+		if !pullMonitors.shutdown(5 * time.Second) {
+			t.Error("pull monitors did not exit")
+		}
+		k8s.Clientset, cfg.ImagePullMaxConcurrent, pullSlots, pullMonitors = origClient, origMax, origSlots, origMonitors
+	})
+	client := k8sfake.NewSimpleClientset()
+	k8s.Clientset = client
+	cfg.ImagePullMaxConcurrent = 1
+	pullSlots = &pullGate{}
+	pullMonitors = newPullMonitorGroup()
+	return client
+}
+
+func TestPullImageAsyncQueuesWhenSlotsFull(t *testing.T) {
+	client := isolatePulls(t)
+
+	svc := NewImageService(newFakeRepo())
+	ctx := context.Background()
+
+	first, err := svc.PullImageAsync("nginx", "1.25", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := svc.PullImageAsync("redis", "7", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if st := svc.GetPullJobStatus(second); st == nil || st.Status != "queued" {
+		t.Fatalf("expected second pull to be queued, got %+v", st)
+	}
+	jobs, _ := client.BatchV1().Jobs(cfg.ImagePullNamespace).List(ctx, metav1.ListOptions{})
+	if len(jobs.Items) != 1 || jobs.Items[0].Name != first {
+		t.Fatalf("expected only the first pull job to be created, got %d jobs", len(jobs.Items))
+	}
+
+	// Finishing the first pull hands its slot to the queued one
+	finishPullJob(t, svc, first)
+
+	deadline := time.Now().Add(6 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := client.BatchV1().Jobs(cfg.ImagePullNamespace).Get(ctx, second, metav1.GetOptions{}); err == nil {
+			if st := svc.GetPullJobStatus(second); st == nil || st.Status == "queued" {
+				t.Fatalf("expected queued pull to start, got %+v", st)
+			}
+			finishPullJob(t, svc, second)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("queued pull job was not started after a slot was released")
+}
+
+// finishPullJob marks the job succeeded and waits for its monitor to exit
+func finishPullJob(t *testing.T, svc *ImageService, jobID string) {
+	t.Helper()
+	ctx := context.Background()
+	j, err := k8s.Clientset.BatchV1().Jobs(cfg.ImagePullNamespace).Get(ctx, jobID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get job: %v", err)
+	}
+	j.Status.Succeeded = 1
+	if _, err := k8s.Clientset.BatchV1().Jobs(cfg.ImagePullNamespace).UpdateStatus(ctx, j, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update job status: %v", err)
+	}
+	deadline := time.Now().Add(6 * time.Second)
+	for time.Now().Before(deadline) {
+		if svc.GetPullJobStatus(jobID) == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("monitor for %s did not finish", jobID)
+}
+
+func TestCancelPullJobStopsMonitor(t *testing.T) {
+	client := isolatePulls(t)
+
+	svc := NewImageService(newFakeRepo())
+	ctx := context.Background()
+
+	jobID, err := svc.PullImageAsync("pytorch/pytorch", "2.3.0", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	queuedID, err := svc.PullImageAsync("nginx", "1.25", 6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	events := svc.SubscribeToPullJob(jobID)
+
+	if err := svc.CancelPullJob(ctx, jobID, 6, false); !errors.Is(err, ErrPullJobForbidden) {
+		t.Fatalf("expected other users to be rejected, got %v", err)
+	}
+
+	// The queued pull is dropped without ever creating a Job
+	if err := svc.CancelPullJob(ctx, queuedID, 6, false); err != nil {
+		t.Fatalf("requester should be able to cancel a queued pull: %v", err)
+	}
+
+	if err := svc.CancelPullJob(ctx, jobID, 5, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.BatchV1().Jobs(cfg.ImagePullNamespace).Get(ctx, jobID, metav1.GetOptions{}); err == nil {
+		t.Fatalf("expected the pull Job to be deleted")
+	}
+
+	var last *PullJobStatus
+	for st := range events {
+		last = st
+	}
+	if last == nil || last.Status != "cancelled" || last.CancelledBy != 5 {
+		t.Fatalf("expected a final cancelled event, got %+v", last)
+	}
+
+	// The monitor releases its slot well before its next poll
+	deadline := time.Now().Add(time.Second)
+	for {
+		pullSlots.mu.Lock()
+		running, queued := pullSlots.running, len(pullSlots.queue)
+		pullSlots.mu.Unlock()
+		if running == 0 && queued == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("monitor did not exit after cancellation (running=%d queued=%d)", running, queued)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	jobs, _ := client.BatchV1().Jobs(cfg.ImagePullNamespace).List(ctx, metav1.ListOptions{})
+	if len(jobs.Items) != 0 {
+		t.Fatalf("cancelled queued pull should not start, found %d jobs", len(jobs.Items))
+	}
+
+	if err := svc.CancelPullJob(ctx, jobID, 5, true); !errors.Is(err, ErrPullJobFinished) {
+		t.Fatalf("expected ErrPullJobFinished for a finished job, got %v", err)
+	}
+	if err := svc.CancelPullJob(ctx, "image-puller-missing", 5, true); !errors.Is(err, ErrPullJobNotFound) {
+		t.Fatalf("expected ErrPullJobNotFound, got %v", err)
+	}
+}
+
+func TestShutdownStopsPullMonitors(t *testing.T) {
+	isolatePulls(t)
+
+	svc := NewImageService(newFakeRepo())
+	jobID, err := svc.PullImageAsync("nginx", "1.25", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	queuedID, err := svc.PullImageAsync("redis", "7", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	events := svc.SubscribeToPullJob(jobID)
+
+	// Shutdown lands mid-monitor, long before the next poll
+	start := time.Now()
+	if !ShutdownPullMonitors(time.Second) {
+		t.Fatal("monitor did not exit on shutdown")
+	}
+	if time.Since(start) >= pullPollInterval {
+		t.Fatalf("shutdown waited for a poll: %s", time.Since(start))
+	}
+
+	var last *PullJobStatus
+	for st := range events {
+		last = st
+	}
+	if last == nil || last.Status != "interrupted" {
+		t.Fatalf("expected a final interrupted event, got %+v", last)
+	}
+	if st := svc.GetPullJobStatus(queuedID); st != nil {
+		t.Fatalf("queued pull should not start during shutdown, got %+v", st)
+	}
+	if _, err := svc.PullImageAsync("busybox", "1.36", 1); err == nil {
+		t.Fatal("expected new pulls to be refused after shutdown")
+	}
+}
+
+type stubVerifier struct {
+	res *registry.Result
+	err error
+}
+
+func (v stubVerifier) Verify(context.Context, string, string, string) (*registry.Result, error) {
+	return v.res, v.err
+}
+
+func TestApproveVerifiedRequest(t *testing.T) {
+	cases := []struct {
+		name        string
+		verifier    stubVerifier
+		force       bool
+		wantErr     error
+		wantStatus  string
+		wantApprove bool
+	}{
+		{"exists", stubVerifier{res: &registry.Result{Exists: true, Digest: "sha256:abc", Size: 42}}, false, nil, VerifyStatusVerified, true},
+		{"typo blocks approval", stubVerifier{res: &registry.Result{Exists: false}}, false, ErrImageNotFoundUpstream, VerifyStatusNotFound, false},
+		{"force overrides not found", stubVerifier{res: &registry.Result{Exists: false}}, true, nil, VerifyStatusNotFound, true},
+		{"rate limit only warns", stubVerifier{res: &registry.Result{}, err: registry.ErrRateLimited}, false, nil, VerifyStatusRateLimited, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			svc := NewImageService(repo)
+			svc.verifier = tc.verifier
+			repo.reqs[1] = &image.ImageRequest{Model: gorm.Model{ID: 1}, InputImageName: "pytorhc/pytorch", InputTag: "latest", Status: "pending"}
+
+			req, err := svc.ApproveVerifiedRequest(context.Background(), 1, "", false, 99, tc.force, nil)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if req.VerifyStatus != tc.wantStatus || req.VerifiedAt == nil {
+				t.Fatalf("verification not recorded: %+v", req)
+			}
+			if approved := repo.reqs[1].Status == "approved"; approved != tc.wantApprove {
+				t.Fatalf("expected approved=%v, got status %s", tc.wantApprove, repo.reqs[1].Status)
+			}
+			if tc.wantStatus == VerifyStatusVerified && (req.VerifiedDigest != "sha256:abc" || req.VerifiedSize != 42) {
+				t.Fatalf("digest and size not stored: %+v", req)
+			}
+		})
+	}
+}
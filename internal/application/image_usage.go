@@ -0,0 +1,245 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"gorm.io/gorm"
+)
+
+var ErrImageInUse = errors.New("image is in use by running workloads")
+
+// Sort orders accepted by ListImageUsage.
+const (
+	ImageUsageSortName   = "name"
+	ImageUsageSortStale  = "stale"
+	ImageUsageSortRecent = "recent"
+)
+
+// ImageUsageScanner records which Harbor images running pods use. Each scan stamps
+// LastSeenRunningAt on the matching tags and keeps the running pod count per tag until the next one.
+type ImageUsageScanner struct {
+	repo repository.ImageRepo
+	now  func() time.Time
+
+	mu      sync.RWMutex
+	running map[uint]int
+}
+
+func NewImageUsageScanner(repo repository.ImageRepo) *ImageUsageScanner {
+	return &ImageUsageScanner{repo: repo, now: time.Now, running: map[uint]int{}}
+}
+
+// Scan lists the running pods in the platform namespaces and records the Harbor images they use.
+// Images of repositories the platform does not know are ignored.
+func (s *ImageUsageScanner) Scan(ctx context.Context) error {
+	counts, err := k8s.CountRunningPodImages(ctx)
+	if err != nil {
+		return err
+	}
+	return s.record(counts)
+}
+
+func (s *ImageUsageScanner) record(counts map[string]int) error {
+	now := s.now()
+	running := map[uint]int{}
+	var errs []error
+	for ref, pods := range counts {
+		name, tag, digest, ok := parseHarborImage(ref, cfg.HarborPrivatePrefix)
+		if !ok {
+			continue
+		}
+		tagID, err := s.upsertSeen(name, tag, digest, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ref, err))
+			continue
+		}
+		if tagID != 0 {
+			running[tagID] += pods
+		}
+	}
+
+	s.mu.Lock()
+	s.running = running
+	s.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// upsertSeen stamps the tag of name as seen running, creating the tag row if the repository is
+// known. Digest-only references are matched against the recorded digests. It returns 0 when
+// there is nothing to stamp.
+func (s *ImageUsageScanner) upsertSeen(name, tag, digest string, at time.Time) (uint, error) {
+	repo, err := s.repo.FindRepositoryByFullName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var tagID uint
+	if tag != "" {
+		t := &image.ContainerTag{RepositoryID: repo.ID, Name: tag}
+		if err := s.repo.FindOrCreateTag(t); err != nil {
+			return 0, err
+		}
+		tagID = t.ID
+	} else {
+		t, err := s.repo.GetTagByDigest(repo.ID, digest)
+		if errors.Is(err, gorm.ErrRecordNotFound) || t == nil {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		tagID = t.ID
+	}
+	return tagID, s.repo.MarkTagSeenRunning(tagID, at)
+}
+
+// Running returns the number of running pods that used the tag at the last scan.
+func (s *ImageUsageScanner) Running(tagID uint) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.running[tagID]
+}
+
+// parseHarborImage splits a container image reference into the repository name below the
+// Harbor prefix, its tag and its digest. A reference without tag or digest uses "latest".
+// ok is false for images outside the Harbor registry.
+func parseHarborImage(ref, prefix string) (name, tag, digest string, ok bool) {
+	if prefix == "" || !strings.HasPrefix(strings.ToLower(ref), strings.ToLower(prefix)) {
+		return "", "", "", false
+	}
+	rest := ref[len(prefix):]
+	if i := strings.Index(rest, "@"); i >= 0 {
+		rest, digest = rest[:i], rest[i+1:]
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, tag = rest[:i], rest[i+1:]
+	}
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+	if rest == "" {
+		return "", "", "", false
+	}
+	return rest, tag, digest, true
+}
+
+// ScanImageUsage runs one usage scan.
+func (s *ImageService) ScanImageUsage(ctx context.Context) error {
+	return s.usage.Scan(ctx)
+}
+
+// ListImageUsage reports every allow-listed image with its pull status, when it was last seen
+// running and how many pods ran it at the last scan. Rules for the same image are merged.
+// order is one of the ImageUsageSort orders; "stale" lists never seen images first.
+func (s *ImageService) ListImageUsage(order string) ([]image.ImageUsageDTO, error) {
+	rules, err := s.repo.ListAllowedImages(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ repoID, tagID uint }
+	byImage := map[key]*image.ImageUsageDTO{}
+	var keys []key
+	for _, rule := range rules {
+		k := key{repoID: rule.RepositoryID}
+		if rule.TagID != nil {
+			k.tagID = *rule.TagID
+		}
+		if u, ok := byImage[k]; ok {
+			u.RuleIDs = append(u.RuleIDs, rule.ID)
+			continue
+		}
+		u, err := s.imageUsage(rule)
+		if err != nil {
+			return nil, err
+		}
+		byImage[k] = u
+		keys = append(keys, k)
+	}
+
+	usage := make([]image.ImageUsageDTO, 0, len(keys))
+	for _, k := range keys {
+		usage = append(usage, *byImage[k])
+	}
+	sortImageUsage(usage, order)
+	return usage, nil
+}
+
+// imageUsage builds the usage of the image a rule allows.
+func (s *ImageService) imageUsage(rule image.ImageAllowList) (*image.ImageUsageDTO, error) {
+	u := &image.ImageUsageDTO{ImageName: rule.Repository.FullName, RuleIDs: []uint{rule.ID}}
+	tags := []image.ContainerTag{rule.Tag}
+	if rule.TagID == nil {
+		var err error
+		if tags, err = s.repo.ListTagsByRepository(rule.RepositoryID); err != nil {
+			return nil, err
+		}
+	} else {
+		u.Tag = rule.Tag.Name
+	}
+
+	for _, t := range tags {
+		if t.ID == 0 {
+			continue
+		}
+		if status, _ := s.repo.GetClusterStatus(t.ID); status != nil && status.IsPulled {
+			u.IsPulled = true
+		}
+		if t.LastSeenRunningAt != nil && (u.LastSeenRunningAt == nil || t.LastSeenRunningAt.After(*u.LastSeenRunningAt)) {
+			u.LastSeenRunningAt = t.LastSeenRunningAt
+		}
+		u.RunningPods += s.usage.Running(t.ID)
+	}
+	return u, nil
+}
+
+func sortImageUsage(usage []image.ImageUsageDTO, order string) {
+	seenBefore := func(a, b *time.Time) bool {
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	}
+	sort.SliceStable(usage, func(i, j int) bool {
+		a, b := usage[i], usage[j]
+		switch order {
+		case ImageUsageSortStale:
+			return seenBefore(a.LastSeenRunningAt, b.LastSeenRunningAt)
+		case ImageUsageSortRecent:
+			return seenBefore(b.LastSeenRunningAt, a.LastSeenRunningAt)
+		}
+		if a.ImageName != b.ImageName {
+			return a.ImageName < b.ImageName
+		}
+		return a.Tag < b.Tag
+	})
+}
+
+// checkImageNotInUse refuses to remove a global rule, which is how mirrored images are retired,
+// while the image was seen running within cfg.ImageInUseWindow.
+func (s *ImageService) checkImageNotInUse(rule *image.ImageAllowList) error {
+	if rule.ProjectID != nil || cfg.ImageInUseWindow <= 0 {
+		return nil
+	}
+	u, err := s.imageUsage(*rule)
+	if err != nil {
+		return err
+	}
+	if u.LastSeenRunningAt != nil && time.Since(*u.LastSeenRunningAt) < cfg.ImageInUseWindow {
+		return fmt.Errorf("%w: %s was last seen running at %s", ErrImageInUse, u.ImageName, u.LastSeenRunningAt.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseHarborImage(t *testing.T) {
+	const prefix = "harbor.local:30003/library/"
+	cases := []struct {
+		ref               string
+		name, tag, digest string
+		ok                bool
+	}{
+		{"harbor.local:30003/library/pytorch/pytorch:2.1", "pytorch/pytorch", "2.1", "", true},
+		{"HARBOR.local:30003/library/nginx:1.25", "nginx", "1.25", "", true},
+		{"harbor.local:30003/library/nginx", "nginx", "latest", "", true},
+		{"harbor.local:30003/library/nginx@sha256:abc", "nginx", "", "sha256:abc", true},
+		{"harbor.local:30003/library/nginx:1.25@sha256:abc", "nginx", "1.25", "sha256:abc", true},
+		{"docker.io/library/nginx:1.25", "", "", "", false},
+		{"nginx:1.25", "", "", "", false},
+		{"harbor.local:30003/library/", "", "", "", false},
+	}
+	for _, tc := range cases {
+		name, tag, digest, ok := parseHarborImage(tc.ref, prefix)
+		if ok != tc.ok || name != tc.name || tag != tc.tag || digest != tc.digest {
+			t.Errorf("parseHarborImage(%q) = %q %q %q %v, want %q %q %q %v", tc.ref, name, tag, digest, ok, tc.name, tc.tag, tc.digest, tc.ok)
+		}
+	}
+	if _, _, _, ok := parseHarborImage("harbor.local:30003/library/nginx", ""); ok {
+		t.Errorf("an empty prefix must not match")
+	}
+}
+
+func runningPod(ns, name string, images ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	for i, img := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: string(rune('a' + i)), Image: img})
+	}
+	return pod
+}
+
+func TestImageUsageScanUpsertsLastSeen(t *testing.T) {
+	db := dbtest.Open(t, &image.ContainerRepository{}, &image.ContainerTag{}, &image.ImageAllowList{}, &image.ClusterImageStatus{})
+	origPrefix, origClient := cfg.HarborPrivatePrefix, k8s.Clientset
+	t.Cleanup(func() { cfg.HarborPrivatePrefix, k8s.Clientset = origPrefix, origClient })
+	cfg.HarborPrivatePrefix = "harbor.local/library/"
+
+	torch := image.ContainerRepository{Name: "pytorch", Namespace: "pytorch", FullName: "pytorch/pytorch"}
+	nginx := image.ContainerRepository{Name: "nginx", Namespace: "library", FullName: "nginx"}
+	db.Create(&torch)
+	db.Create(&nginx)
+	pinned := image.ContainerTag{RepositoryID: nginx.ID, Name: "1.25", Digest: "sha256:abc"}
+	stale := image.ContainerTag{RepositoryID: nginx.ID, Name: "1.19"}
+	db.Create(&pinned)
+	db.Create(&stale)
+	db.Create(&image.ImageAllowList{RepositoryID: torch.ID, IsEnabled: true})
+	db.Create(&image.ImageAllowList{RepositoryID: nginx.ID, TagID: &pinned.ID, IsEnabled: true})
+	staleRule := image.ImageAllowList{RepositoryID: nginx.ID, TagID: &stale.ID, IsEnabled: true}
+	db.Create(&staleRule)
+
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "proj-1-alice"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		runningPod("proj-1-alice", "train", "harbor.local/library/pytorch/pytorch:2.1", "harbor.local/library/pytorch/pytorch:2.1"),
+		runningPod("proj-1-alice", "web", "harbor.local/library/nginx@sha256:abc"),
+		runningPod("proj-1-alice", "web-2", "harbor.local/library/nginx:1.25"),
+		runningPod("proj-1-alice", "other", "harbor.local/library/unknown:1", "docker.io/library/redis:7"),
+		runningPod("kube-system", "dns", "harbor.local/library/nginx:1.19"),
+	)
+
+	svc := NewImageService(repository.NewImageRepo(db))
+	if err := svc.ScanImageUsage(context.Background()); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+
+	var torchTags []image.ContainerTag
+	db.Where("repository_id = ?", torch.ID).Find(&torchTags)
+	if len(torchTags) != 1 || torchTags[0].Name != "2.1" || torchTags[0].LastSeenRunningAt == nil {
+		t.Fatalf("expected the running torch tag to be created and stamped, got %+v", torchTags)
+	}
+	db.First(&pinned, pinned.ID)
+	db.First(&stale, stale.ID)
+	if pinned.LastSeenRunningAt == nil || stale.LastSeenRunningAt != nil {
+		t.Fatalf("expected only the pinned nginx tag to be stamped, got pinned=%v stale=%v", pinned.LastSeenRunningAt, stale.LastSeenRunningAt)
+	}
+	var repos int64
+	db.Model(&image.ContainerRepository{}).Count(&repos)
+	if repos != 2 {
+		t.Fatalf("unknown repositories must not be created, found %d", repos)
+	}
+
+	usage, err := svc.ListImageUsage(ImageUsageSortStale)
+	if err != nil {
+		t.Fatalf("list usage failed: %v", err)
+	}
+	if len(usage) != 3 || usage[0].Tag != "1.19" || usage[0].LastSeenRunningAt != nil {
+		t.Fatalf("expected the never seen tag first, got %+v", usage)
+	}
+	running := map[string]int{}
+	for _, u := range usage {
+		running[u.ImageName+":"+u.Tag] = u.RunningPods
+	}
+	if running["pytorch/pytorch:"] != 1 || running["nginx:1.25"] != 2 || running["nginx:1.19"] != 0 {
+		t.Fatalf("unexpected running pod counts %v", running)
+	}
+
+	for _, u := range usage {
+		if u.ImageName == "nginx" && u.Tag == "1.25" {
+			if err := svc.DisableAllowListRule(u.RuleIDs[0]); !errors.Is(err, ErrImageInUse) {
+				t.Fatalf("expected a recently running image to be kept, got %v", err)
+			}
+		}
+	}
+	if err := svc.DisableAllowListRule(staleRule.ID); err != nil {
+		t.Fatalf("expected an unused image to be removable, got %v", err)
+	}
+
+	old := time.Now().Add(-2 * cfg.ImageInUseWindow)
+	db.Model(&image.ContainerTag{}).Where("id = ?", pinned.ID).Update("last_seen_running_at", old)
+	for _, u := range usage {
+		if u.ImageName == "nginx" && u.Tag == "1.25" {
+			if err := svc.DisableAllowListRule(u.RuleIDs[0]); err != nil {
+				t.Fatalf("expected an image outside the window to be removable, got %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/pkg/registry"
+)
+
+// Values stored in ImageRequest.VerifyStatus
+const (
+	VerifyStatusVerified    = "verified"
+	VerifyStatusNotFound    = "not_found"
+	VerifyStatusRateLimited = "rate_limited"
+	VerifyStatusError       = "error"
+)
+
+var ErrImageNotFoundUpstream = errors.New("image not found in upstream registry")
+
+type imageVerifier interface {
+	Verify(ctx context.Context, registry, name, tag string) (*registry.Result, error)
+}
+
+// VerifyRequest looks up the requested image in its upstream registry and stores the outcome
+// on the request. A failed lookup is recorded, not returned; errors come from the repository.
+func (s *ImageService) VerifyRequest(ctx context.Context, id uint) (*image.ImageRequest, error) {
+	req, err := s.repo.FindRequestByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, verr := s.verifier.Verify(ctx, req.InputRegistry, req.InputImageName, req.InputTag)
+	req.VerifiedAt = ptrTime(time.Now())
+	req.VerifiedDigest = ""
+	req.VerifiedSize = 0
+	req.VerifyError = ""
+	switch {
+	case errors.Is(verr, registry.ErrRateLimited):
+		req.VerifyStatus = VerifyStatusRateLimited
+		req.VerifyError = verr.Error()
+	case verr != nil:
+		req.VerifyStatus = VerifyStatusError
+		req.VerifyError = verr.Error()
+	case !res.Exists:
+		req.VerifyStatus = VerifyStatusNotFound
+	default:
+		req.VerifyStatus = VerifyStatusVerified
+		req.VerifiedDigest = res.Digest
+		req.VerifiedSize = res.Size
+	}
+
+	if err := s.repo.UpdateRequest(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// ApproveVerifiedRequest verifies the image before approving it. A definitive "not found"
+// blocks approval unless force is set; rate limiting and other lookup failures only warn,
+// and the caller can read the outcome from the returned request.
+func (s *ImageService) ApproveVerifiedRequest(ctx context.Context, id uint, note string, isGlobal bool, approverID uint, force bool, preload *bool) (*image.ImageRequest, error) {
+	req, err := s.VerifyRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.VerifyStatus == VerifyStatusNotFound && !force {
+		return req, ErrImageNotFoundUpstream
+	}
+	if err := s.ApproveRequest(id, note, isGlobal, approverID, preload); err != nil {
+		return req, err
+	}
+	return s.repo.FindRequestByID(id)
+}
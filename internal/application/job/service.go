@@ -11,8 +11,17 @@ import (
 	"github.com/linskybing/platform-go/internal/domain/job"
 	"github.com/linskybing/platform-go/internal/domain/project"
 	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/pagination"
 )
 
+// ResumeCheckpointEnv holds the path of the checkpoint a restarted job resumes from.
+const ResumeCheckpointEnv = "RESUME_CHECKPOINT_PATH"
+
+// StopJob stops the work of a started job through the executor of its type. Set at startup;
+// while nil, cancelling only records the status.
+var StopJob func(ctx context.Context, j *job.Job) error
+
 // Service handles job-related business logic
 type Service struct {
 	jobRepo     job.Repository
@@ -63,6 +72,8 @@ type CreateJobRequest struct {
 	EnableCheckpoint   bool              `json:"enable_checkpoint"`
 	CheckpointInterval int               `json:"checkpoint_interval"`
 	Volumes            []job.VolumeMount `json:"volumes"`
+	// RestartOnEviction defaults to EnableCheckpoint
+	RestartOnEviction *bool `json:"restart_on_eviction,omitempty"`
 }
 
 // CreateJob creates a new job in pending state
@@ -146,6 +157,7 @@ func (s *Service) CreateJob(ctx context.Context, userID uint, req CreateJobReque
 		EnableCheckpoint:   req.EnableCheckpoint,
 		CheckpointInterval: req.CheckpointInterval,
 		Volumes:            string(volumesJSON),
+		RestartOnEviction:  job.RestartsOnEviction(req.RestartOnEviction, req.EnableCheckpoint),
 	}
 
 	if err := s.jobRepo.Create(newJob); err != nil {
@@ -173,6 +185,14 @@ func (s *Service) ListJobs(ctx context.Context, userID uint, isAdmin bool) ([]jo
 	return s.jobRepo.FindByUserID(userID)
 }
 
+// ListJobsPage returns one page of the jobs ListJobs returns, newest first
+func (s *Service) ListJobsPage(ctx context.Context, userID uint, isAdmin bool, p pagination.Params) (*pagination.Page[job.Job], error) {
+	if isAdmin {
+		return s.jobRepo.FindPage(nil, p)
+	}
+	return s.jobRepo.FindPage(&userID, p)
+}
+
 // GetJob returns a specific job
 func (s *Service) GetJob(ctx context.Context, jobID uint) (*job.Job, error) {
 	return s.jobRepo.FindByID(jobID)
@@ -185,9 +205,16 @@ func (s *Service) CancelJob(ctx context.Context, jobID uint) error {
 		return fmt.Errorf("job not found: %w", err)
 	}
 
-	if j.Status == string(job.StatusCompleted) || j.Status == string(job.StatusFailed) || j.Status == string(job.StatusCancelled) {
+	if j.Status == string(job.StatusCompleted) || j.Status == string(job.StatusFailed) || j.Status == string(job.StatusCancelled) ||
+		j.Status == string(job.StatusTimedOut) {
 		return fmt.Errorf("cannot cancel job in status: %s", j.Status)
 	}
+	// Queued jobs have not been handed to an executor yet
+	if StopJob != nil && j.Status != string(job.JobStatusQueued) {
+		if err := StopJob(ctx, j); err != nil {
+			return fmt.Errorf("failed to stop job: %w", err)
+		}
+	}
 
 	j.Status = string(job.StatusCancelled)
 	now := time.Now()
@@ -196,7 +223,8 @@ func (s *Service) CancelJob(ctx context.Context, jobID uint) error {
 	return s.jobRepo.Update(j)
 }
 
-// RestartJob restarts a job from checkpoint
+// RestartJob restarts a job from checkpoint: the one given, or the latest the job wrote. Its
+// path is passed to the job in ResumeCheckpointEnv.
 func (s *Service) RestartJob(ctx context.Context, jobID uint, checkpointID *uint) error {
 	j, err := s.jobRepo.FindByID(jobID)
 	if err != nil {
@@ -207,6 +235,16 @@ func (s *Service) RestartJob(ctx context.Context, jobID uint, checkpointID *uint
 		return fmt.Errorf("cannot restart running job")
 	}
 
+	checkpoint, err := s.resumeCheckpoint(j.ID, checkpointID)
+	if err != nil {
+		return err
+	}
+	if checkpoint != nil {
+		if err := setJobEnv(j, ResumeCheckpointEnv, checkpoint.Path); err != nil {
+			return err
+		}
+	}
+
 	j.Status = string(job.JobStatusQueued)
 	j.RestartCount++
 	j.CompletedAt = nil
@@ -217,6 +255,61 @@ func (s *Service) RestartJob(ctx context.Context, jobID uint, checkpointID *uint
 	return s.jobRepo.Update(j)
 }
 
+// resumeCheckpoint returns the checkpoint of the job with checkpointID, or its latest one when
+// checkpointID is nil. A job without checkpoints resumes from none.
+func (s *Service) resumeCheckpoint(jobID uint, checkpointID *uint) (*job.JobCheckpoint, error) {
+	checkpoints, err := s.jobRepo.FindCheckpoints(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if checkpointID == nil {
+		if len(checkpoints) == 0 {
+			return nil, nil
+		}
+		return &checkpoints[len(checkpoints)-1], nil
+	}
+	for i := range checkpoints {
+		if checkpoints[i].ID == *checkpointID {
+			return &checkpoints[i], nil
+		}
+	}
+	return nil, fmt.Errorf("checkpoint %d not found for job %d", *checkpointID, jobID)
+}
+
+// setJobEnv sets an environment variable of the job, in its stored spec when it has one.
+func setJobEnv(j *job.Job, key, value string) error {
+	if j.Spec != "" {
+		var spec k8s.JobSpec
+		if err := json.Unmarshal([]byte(j.Spec), &spec); err != nil {
+			return fmt.Errorf("invalid stored spec for job %d: %w", j.ID, err)
+		}
+		if spec.EnvVars == nil {
+			spec.EnvVars = map[string]string{}
+		}
+		spec.EnvVars[key] = value
+		raw, err := json.Marshal(spec)
+		if err != nil {
+			return err
+		}
+		j.Spec = string(raw)
+		return nil
+	}
+	env := map[string]string{}
+	if j.EnvVars != "" {
+		_ = json.Unmarshal([]byte(j.EnvVars), &env)
+	}
+	if env == nil {
+		env = map[string]string{}
+	}
+	env[key] = value
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	j.EnvVars = string(raw)
+	return nil
+}
+
 // GetJobLogs returns logs for a job
 func (s *Service) GetJobLogs(ctx context.Context, jobID uint, limit, offset int) ([]job.JobLog, error) {
 	return s.jobRepo.FindLogs(jobID)
@@ -0,0 +1,82 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/storage"
+)
+
+var (
+	ErrInvalidArtifactUpload = errors.New("invalid artifact upload")
+	ErrArtifactStoreDisabled = errors.New("artifact storage is not configured")
+)
+
+var artifactPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]*$`)
+
+// JobArtifact is an uploaded output file of a job with a time-limited download URL.
+type JobArtifact struct {
+	Name         string    `json:"name"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// artifactUploadSpec validates the requested upload. The glob is expanded by the uploader's
+// shell, so whitespace, which would split it, is rejected.
+func artifactUploadSpec(in *job.ArtifactUpload) (*k8s.ArtifactUpload, error) {
+	glob := strings.TrimSpace(in.Glob)
+	if glob == "" || strings.ContainsAny(glob, " \t\n\"'`$;|&") {
+		return nil, fmt.Errorf("%w: glob %q", ErrInvalidArtifactUpload, in.Glob)
+	}
+	prefix := strings.Trim(in.Prefix, "/")
+	if !artifactPrefixPattern.MatchString(prefix) {
+		return nil, fmt.Errorf("%w: prefix %q", ErrInvalidArtifactUpload, in.Prefix)
+	}
+	for _, part := range strings.Split(prefix, "/") {
+		if part == ".." {
+			return nil, fmt.Errorf("%w: prefix %q", ErrInvalidArtifactUpload, in.Prefix)
+		}
+	}
+	return &k8s.ArtifactUpload{Prefix: prefix, Glob: glob}, nil
+}
+
+// ListJobArtifacts lists the objects uploaded for the job, each with a presigned download URL
+// valid for config.ArtifactURLExpiry.
+func (s *K8sService) ListJobArtifacts(ctx context.Context, jobID uint) ([]JobArtifact, error) {
+	if storage.Objects == nil {
+		return nil, ErrArtifactStoreDisabled
+	}
+	prefix := k8s.ArtifactObjectPrefix(jobID, "")
+	objects, err := storage.Objects.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts of job %d: %w", jobID, err)
+	}
+
+	expires := time.Now().Add(config.ArtifactURLExpiry)
+	artifacts := make([]JobArtifact, 0, len(objects))
+	for _, obj := range objects {
+		url, err := storage.Objects.PresignGet(ctx, obj.Key, config.ArtifactURLExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign artifact %s: %w", obj.Key, err)
+		}
+		artifacts = append(artifacts, JobArtifact{
+			Name:         strings.TrimPrefix(obj.Key, prefix),
+			Key:          obj.Key,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+			URL:          url,
+			URLExpiresAt: expires,
+		})
+	}
+	return artifacts, nil
+}
@@ -0,0 +1,73 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/storage"
+)
+
+type fakeObjectStore struct {
+	objects []storage.ObjectInfo
+	prefix  string
+}
+
+func (f *fakeObjectStore) ListObjects(_ context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	f.prefix = prefix
+	return f.objects, nil
+}
+
+func (f *fakeObjectStore) PresignGet(_ context.Context, key string, expiry time.Duration) (string, error) {
+	return "https://minio.local/platform-bucket/" + key + "?expires=" + expiry.String(), nil
+}
+
+func TestListJobArtifactsSignsEachObject(t *testing.T) {
+	orig := storage.Objects
+	defer func() { storage.Objects = orig }()
+	svc := NewK8sService(&repository.Repos{})
+
+	storage.Objects = nil
+	if _, err := svc.ListJobArtifacts(context.Background(), 42); !errors.Is(err, ErrArtifactStoreDisabled) {
+		t.Fatalf("expected ErrArtifactStoreDisabled without object storage, got %v", err)
+	}
+
+	store := &fakeObjectStore{objects: []storage.ObjectInfo{
+		{Key: "jobs/42/run-1/model.pt", Size: 2048},
+		{Key: "jobs/42/metrics.json", Size: 12},
+	}}
+	storage.Objects = store
+	artifacts, err := svc.ListJobArtifacts(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.prefix != "jobs/42/" {
+		t.Fatalf("expected the listing to be scoped to the job, got prefix %q", store.prefix)
+	}
+	if len(artifacts) != 2 || artifacts[0].Name != "run-1/model.pt" || artifacts[0].Size != 2048 {
+		t.Fatalf("unexpected artifacts %+v", artifacts)
+	}
+	if artifacts[1].URL != "https://minio.local/platform-bucket/jobs/42/metrics.json?expires=1h0m0s" {
+		t.Fatalf("unexpected presigned url %s", artifacts[1].URL)
+	}
+}
+
+func TestArtifactUploadSpecValidation(t *testing.T) {
+	upload, err := artifactUploadSpec(&job.ArtifactUpload{Prefix: "/run-1/", Glob: "out/*.pt"})
+	if err != nil || upload.Prefix != "run-1" || upload.Glob != "out/*.pt" {
+		t.Fatalf("expected a normalised upload, got %+v (err %v)", upload, err)
+	}
+	for _, in := range []job.ArtifactUpload{
+		{Glob: ""},
+		{Glob: "a.pt b.pt"},
+		{Glob: "$(id)"},
+		{Glob: "*.pt", Prefix: "../other-job"},
+	} {
+		if _, err := artifactUploadSpec(&in); !errors.Is(err, ErrInvalidArtifactUpload) {
+			t.Fatalf("expected %+v to be rejected, got %v", in, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+)
+
+var (
+	ErrInvalidJobDependency = errors.New("invalid job dependency")
+	ErrJobDependencyCycle   = errors.New("job dependency cycle")
+)
+
+// validateJobDependencies checks that every dependency is one of the submitter's own jobs and
+// that the dependency graph reachable from them is acyclic.
+func (s *K8sService) validateJobDependencies(userID uint, deps []uint) error {
+	seen := make(map[uint]bool, len(deps))
+	for _, id := range deps {
+		if seen[id] {
+			return fmt.Errorf("%w: job %d is listed twice", ErrInvalidJobDependency, id)
+		}
+		seen[id] = true
+		dep, err := s.repos.Job.FindByID(id)
+		if err != nil || dep.UserID != userID {
+			return fmt.Errorf("%w: job %d not found", ErrInvalidJobDependency, id)
+		}
+	}
+
+	cycle := findDependencyCycle(deps, func(id uint) []uint {
+		j, err := s.repos.Job.FindByID(id)
+		if err != nil {
+			return nil
+		}
+		return j.DependencyIDs()
+	})
+	if cycle != nil {
+		return fmt.Errorf("%w: %s", ErrJobDependencyCycle, formatDependencyCycle(cycle))
+	}
+	return nil
+}
+
+// findDependencyCycle walks the graph from roots and returns the first cycle found as a path
+// that starts and ends with the same job ID, or nil.
+func findDependencyCycle(roots []uint, dependsOn func(id uint) []uint) []uint {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[uint]int)
+	var path []uint
+
+	var visit func(id uint) []uint
+	visit = func(id uint) []uint {
+		switch state[id] {
+		case done:
+			return nil
+		case visiting:
+			for i, p := range path {
+				if p == id {
+					return append(append([]uint{}, path[i:]...), id)
+				}
+			}
+		}
+		state[id] = visiting
+		path = append(path, id)
+		for _, next := range dependsOn(id) {
+			if cycle := visit(next); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, id := range roots {
+		if cycle := visit(id); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func formatDependencyCycle(cycle []uint) string {
+	parts := make([]string, len(cycle))
+	for i, id := range cycle {
+		parts[i] = fmt.Sprintf("job %d", id)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// deferJob stores a job with dependencies as queued instead of creating it in Kubernetes.
+// The scheduler starts it from the saved spec, through the executor of its type, once its
+// dependencies allow.
+func (s *K8sService) deferJob(record *job.Job, spec k8s.JobSpec, projectID uint, input job.JobSubmission) error {
+	deps, err := json.Marshal(input.DependsOn)
+	if err != nil {
+		return err
+	}
+	rawSpec, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	record.ProjectID = &projectID
+	if record.JobType == "" {
+		record.JobType = job.JobTypeNormal
+	}
+	record.Status = string(job.JobStatusQueued)
+	record.DependsOn = string(deps)
+	record.RunOnDependencyFailure = input.RunOnDependencyFailure
+	record.Spec = string(rawSpec)
+	record.GPUCount = spec.GPUCount
+	record.GPUType = spec.GPUType
+	return s.repos.Job.Create(record)
+}
+
+// queueWarning reports the queued jobs a job of the priority would wait behind: those of the
+// same or a higher priority.
+func (s *K8sService) queueWarning(priority string) (job.Warning, bool) {
+	queued, err := s.repos.Job.GetQueuedJobs()
+	if err != nil {
+		log.Printf("failed to list queued jobs: %v", err)
+		return job.Warning{}, false
+	}
+	ahead := 0
+	for _, q := range queued {
+		if job.PriorityRank(q.Priority) >= job.PriorityRank(priority) {
+			ahead++
+		}
+	}
+	if ahead == 0 {
+		return job.Warning{}, false
+	}
+	return job.Warning{Code: job.WarningQueued, Message: fmt.Sprintf("queued behind %d jobs", ahead)}, true
+}
+
+// GetJobDetail returns a job along with the current status of each of its dependencies.
+func (s *K8sService) GetJobDetail(id uint) (*job.JobDetail, error) {
+	j, err := s.repos.Job.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	detail := &job.JobDetail{Job: *j, RemainingRuntimeSeconds: remainingRuntime(j, time.Now())}
+	for _, depID := range j.DependencyIDs() {
+		st := job.JobDependencyStatus{JobID: depID, Status: "missing"}
+		if dep, err := s.repos.Job.FindByID(depID); err == nil {
+			st.Name = dep.Name
+			st.Status = dep.Status
+		}
+		detail.Dependencies = append(detail.Dependencies, st)
+	}
+	return detail, nil
+}
@@ -0,0 +1,49 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func TestValidateJobDependencies(t *testing.T) {
+	db := dbtest.Open(t, &job.Job{})
+	for _, j := range []job.Job{
+		{ID: 1, UserID: 7, Name: "prep", Status: "completed"},
+		{ID: 2, UserID: 7, Name: "train", DependsOn: "[1]"},
+		{ID: 3, UserID: 8, Name: "other-user"},
+		// A cycle left behind in existing rows
+		{ID: 4, UserID: 7, Name: "a", DependsOn: "[5]"},
+		{ID: 5, UserID: 7, Name: "b", DependsOn: "[6]"},
+		{ID: 6, UserID: 7, Name: "c", DependsOn: "[4]"},
+	} {
+		j := j
+		j.Namespace, j.Image, j.K8sJobName = "proj-1-u", "busybox:latest", j.Name
+		if err := db.Create(&j).Error; err != nil {
+			t.Fatalf("seed job %d: %v", j.ID, err)
+		}
+	}
+	svc := NewK8sService(repository.NewRepositories(db))
+
+	if err := svc.validateJobDependencies(7, []uint{1, 2}); err != nil {
+		t.Fatalf("valid dependencies rejected: %v", err)
+	}
+	if err := svc.validateJobDependencies(7, []uint{3}); !errors.Is(err, ErrInvalidJobDependency) {
+		t.Fatalf("another user's job must be rejected, got %v", err)
+	}
+	if err := svc.validateJobDependencies(7, []uint{99}); !errors.Is(err, ErrInvalidJobDependency) {
+		t.Fatalf("unknown job must be rejected, got %v", err)
+	}
+
+	err := svc.validateJobDependencies(7, []uint{2, 4})
+	if !errors.Is(err, ErrJobDependencyCycle) {
+		t.Fatalf("expected ErrJobDependencyCycle, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "job 4 -> job 5 -> job 6 -> job 4") {
+		t.Fatalf("error should name the cycle, got %q", err)
+	}
+}
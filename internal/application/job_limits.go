@@ -0,0 +1,119 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+var ErrJobLimitExceeded = errors.New("concurrent job limit reached")
+
+// Scopes of a concurrent job limit
+const (
+	JobLimitScopeProject = "project"
+	JobLimitScopeUser    = "user"
+)
+
+// startedJobStatuses are the unfinished states of a job that already holds cluster capacity.
+// Queued jobs are not started yet; the scheduler holds them back while a limit is reached.
+var startedJobStatuses = []string{
+	string(job.StatusPending),
+	string(job.JobStatusScheduling),
+	string(job.JobStatusRunning),
+}
+
+// JobLimitError is returned when a project, or one of its members, already has as many
+// unfinished jobs as the project allows.
+type JobLimitError struct {
+	Scope   string
+	Current int
+	Limit   int
+}
+
+func (e *JobLimitError) Error() string {
+	return fmt.Sprintf("%s: the %s already has %d unfinished jobs, limit %d", ErrJobLimitExceeded, e.Scope, e.Current, e.Limit)
+}
+
+func (e *JobLimitError) Unwrap() error {
+	return ErrJobLimitExceeded
+}
+
+// checkJobLimits compares the project's jobs in statuses with its limits. A job stops counting
+// as soon as its status leaves statuses, so one marked completed frees its slot at once.
+// discount is subtracted from the counts, for a job that is itself among them.
+func (s *K8sService) checkJobLimits(projectID, userID uint, statuses []string, discount int) error {
+	p, err := s.repos.Project.GetProjectByID(projectID)
+	if err != nil || (p.MaxConcurrentJobs == 0 && p.MaxConcurrentJobsPerUser == 0) {
+		return nil
+	}
+	limits := []struct {
+		scope string
+		user  uint
+		limit int
+	}{
+		{JobLimitScopeProject, 0, p.MaxConcurrentJobs},
+		{JobLimitScopeUser, userID, p.MaxConcurrentJobsPerUser},
+	}
+	for _, l := range limits {
+		if l.limit <= 0 {
+			continue
+		}
+		n, err := s.repos.Job.CountProjectJobs(projectID, l.user, statuses)
+		if err != nil {
+			return fmt.Errorf("failed to count running jobs: %w", err)
+		}
+		if current := int(n) - discount; current >= l.limit {
+			return &JobLimitError{Scope: l.scope, Current: current, Limit: l.limit}
+		}
+	}
+	return nil
+}
+
+// CheckJobDispatch is the scheduler gate: a queued job starts only while its project and owner
+// are below their limits.
+func (s *K8sService) CheckJobDispatch(j *job.Job) error {
+	if j.ProjectID == nil {
+		return nil
+	}
+	discount := 0
+	for _, st := range startedJobStatuses {
+		if strings.EqualFold(j.Status, st) {
+			discount = 1
+			break
+		}
+	}
+	return s.checkJobLimits(*j.ProjectID, j.UserID, startedJobStatuses, discount)
+}
+
+// SetJobLimits changes the job limits of a project. Lowering a concurrency limit does not stop
+// running jobs; new ones wait until the project is below it. A new runtime limit applies to jobs
+// submitted afterwards.
+func (s *ProjectService) SetJobLimits(c *gin.Context, projectID uint, input project.JobLimitsDTO) (*project.Project, error) {
+	p, err := s.Repos.Project.GetProjectByID(projectID)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	old := p
+	if input.MaxConcurrentJobs != nil {
+		p.MaxConcurrentJobs = *input.MaxConcurrentJobs
+	}
+	if input.MaxConcurrentJobsPerUser != nil {
+		p.MaxConcurrentJobsPerUser = *input.MaxConcurrentJobsPerUser
+	}
+	if input.MaxJobRuntimeMinutes != nil {
+		p.MaxJobRuntimeMinutes = *input.MaxJobRuntimeMinutes
+	}
+	if input.StrictVolumeChecks != nil {
+		p.StrictVolumeChecks = *input.StrictVolumeChecks
+	}
+	if err := s.Repos.Project.UpdateProject(&p); err != nil {
+		return nil, err
+	}
+	utils.LogAuditWithConsole(c, "update", "project_job_limits", fmt.Sprintf("p_id=%d", projectID), old, p, "", s.Repos.Audit)
+	return &p, nil
+}
@@ -0,0 +1,102 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func setupJobLimits(t *testing.T, p project.Project) (*K8sService, *repository.Repos) {
+	t.Helper()
+	db := dbtest.Open(t, &project.Project{}, &project.ProjectEnvDefault{}, &project.SchedulingPolicy{}, &project.RegistryCredential{}, &job.Job{})
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatalf("failed to seed project: %v", err)
+	}
+	repos := repository.NewRepositories(db)
+	return NewK8sService(repos), repos
+}
+
+func submitCPUJob(svc *K8sService, userID uint, name string) error {
+	_, err := svc.CreateJob(context.Background(), userID, job.JobSubmission{
+		Name:      name,
+		Namespace: fmt.Sprintf("proj-7-user%d", userID),
+		Image:     "busybox:latest",
+	})
+	return err
+}
+
+func TestCreateJobEnforcesProjectJobLimit(t *testing.T) {
+	svc, repos := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1, MaxConcurrentJobs: 2})
+
+	// One under the limit is accepted
+	for _, name := range []string{"a", "b"} {
+		if err := submitCPUJob(svc, 1, name); err != nil {
+			t.Fatalf("job %s under the limit rejected: %v", name, err)
+		}
+	}
+
+	err := submitCPUJob(svc, 2, "c")
+	var limitErr *JobLimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrJobLimitExceeded) {
+		t.Fatalf("expected a job limit error at the limit, got %v", err)
+	}
+	if limitErr.Scope != JobLimitScopeProject || limitErr.Current != 2 || limitErr.Limit != 2 {
+		t.Fatalf("unexpected limit error %+v", limitErr)
+	}
+
+	// A job marked completed frees its slot right away
+	jobs, _ := repos.Job.FindByProjectID(7)
+	if err := repos.Job.UpdateStatus(jobs[0].ID, string(job.StatusCompleted)); err != nil {
+		t.Fatalf("failed to complete job: %v", err)
+	}
+	if err := submitCPUJob(svc, 2, "c"); err != nil {
+		t.Fatalf("completed jobs should free capacity, got %v", err)
+	}
+}
+
+func TestCreateJobEnforcesPerUserJobLimit(t *testing.T) {
+	svc, _ := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1, MaxConcurrentJobsPerUser: 1})
+
+	if err := submitCPUJob(svc, 1, "a"); err != nil {
+		t.Fatalf("first job rejected: %v", err)
+	}
+	var limitErr *JobLimitError
+	if err := submitCPUJob(svc, 1, "b"); !errors.As(err, &limitErr) || limitErr.Scope != JobLimitScopeUser {
+		t.Fatalf("expected the per-user limit, got %v", err)
+	}
+	if err := submitCPUJob(svc, 2, "b"); err != nil {
+		t.Fatalf("another member is not limited by the first one's jobs: %v", err)
+	}
+}
+
+func TestCheckJobDispatchCountsStartedJobs(t *testing.T) {
+	svc, repos := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1, MaxConcurrentJobs: 1})
+	pid := uint(7)
+	running := &job.Job{UserID: 1, ProjectID: &pid, Name: "r", Namespace: "proj-7-u", Image: "busybox", K8sJobName: "r", Status: string(job.JobStatusRunning)}
+	queued := &job.Job{UserID: 1, ProjectID: &pid, Name: "q", Namespace: "proj-7-u", Image: "busybox", K8sJobName: "q", Status: string(job.JobStatusQueued)}
+	for _, j := range []*job.Job{running, queued} {
+		if err := repos.Job.Create(j); err != nil {
+			t.Fatalf("seed job: %v", err)
+		}
+	}
+
+	if err := svc.CheckJobDispatch(queued); !errors.Is(err, ErrJobLimitExceeded) {
+		t.Fatalf("a queued job must wait while the project is at its limit, got %v", err)
+	}
+	// The running job is the only started one, so it does not block itself
+	if err := svc.CheckJobDispatch(running); err != nil {
+		t.Fatalf("a job must not count against itself, got %v", err)
+	}
+	if err := repos.Job.UpdateStatus(running.ID, string(job.StatusCompleted)); err != nil {
+		t.Fatalf("failed to complete job: %v", err)
+	}
+	if err := svc.CheckJobDispatch(queued); err != nil {
+		t.Fatalf("the queued job should start once capacity is free, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package application
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+var (
+	ErrJobNotFound            = errors.New("job not found")
+	ErrJobFinished            = errors.New("job has already finished")
+	ErrJobTokenInvalid        = errors.New("invalid job token")
+	ErrJobProgressInvalid     = errors.New("invalid job progress")
+	ErrJobProgressTooFrequent = errors.New("job progress reported too frequently")
+)
+
+// Environment variables through which a job finds its ID and the token to report progress with.
+const (
+	JobIDEnv    = "PLATFORM_JOB_ID"
+	JobTokenEnv = "PLATFORM_JOB_TOKEN"
+)
+
+// Limits of a progress report.
+const (
+	MaxProgressMetrics      = 32
+	MaxProgressMetricName   = 64
+	MaxProgressMetricsBytes = 2048
+)
+
+// JobProgressInterval is the shortest time between two accepted progress reports of a job.
+var JobProgressInterval = 10 * time.Second
+
+// JobToken returns the token a job authenticates its own progress reports with.
+func JobToken(jobID uint) string {
+	m := hmac.New(sha256.New, []byte(config.JwtSecret))
+	fmt.Fprintf(m, "job-progress\n%d", jobID)
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// JobProgressEnv is the environment given to the containers of a job so its scripts can report
+// progress.
+func JobProgressEnv(jobID uint) map[string]string {
+	return map[string]string{
+		JobIDEnv:    strconv.FormatUint(uint64(jobID), 10),
+		JobTokenEnv: JobToken(jobID),
+	}
+}
+
+// JobProgressService stores the progress jobs report about themselves and pushes it to
+// subscribers of the job's events.
+type JobProgressService struct {
+	Repos *repository.Repos
+
+	mu       sync.Mutex
+	reported map[uint]time.Time
+	subs     map[uint]map[chan job.Progress]struct{}
+	now      func() time.Time
+}
+
+func NewJobProgressService(repos *repository.Repos) *JobProgressService {
+	return &JobProgressService{
+		Repos:    repos,
+		reported: map[uint]time.Time{},
+		subs:     map[uint]map[chan job.Progress]struct{}{},
+		now:      time.Now,
+	}
+}
+
+// Report records the progress of job jobID, authenticated by token. Each report replaces the
+// previous one; reports arriving within JobProgressInterval of the last accepted one are
+// rejected, as are reports for finished jobs.
+func (s *JobProgressService) Report(jobID uint, token string, p job.Progress) (*job.Progress, error) {
+	if !hmac.Equal([]byte(token), []byte(JobToken(jobID))) {
+		return nil, ErrJobTokenInvalid
+	}
+	if err := validateProgress(&p); err != nil {
+		return nil, err
+	}
+	j, err := s.Repos.Job.FindByID(jobID)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	if !j.IsActive() {
+		s.mu.Lock()
+		delete(s.reported, jobID)
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrJobFinished, j.Status)
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	if last, ok := s.reported[jobID]; ok && now.Sub(last) < JobProgressInterval {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: retry in %s", ErrJobProgressTooFrequent, (JobProgressInterval - now.Sub(last)).Round(time.Second))
+	}
+	s.reported[jobID] = now
+	s.mu.Unlock()
+
+	p.ReportedAt = now
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repos.Job.UpdateProgress(jobID, p.Percent, raw); err != nil {
+		s.mu.Lock()
+		delete(s.reported, jobID)
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.publish(jobID, p)
+	return &p, nil
+}
+
+// validateProgress derives the percent from the steps when only those are given and checks the
+// report against the limits.
+func validateProgress(p *job.Progress) error {
+	if p.Step != nil && p.TotalSteps != nil {
+		if *p.Step < 0 || *p.TotalSteps <= 0 || *p.Step > *p.TotalSteps {
+			return fmt.Errorf("%w: step must be between 0 and total_steps", ErrJobProgressInvalid)
+		}
+		if p.Percent == 0 {
+			p.Percent = math.Round(float64(*p.Step)/float64(*p.TotalSteps)*10000) / 100
+		}
+	}
+	if math.IsNaN(p.Percent) || p.Percent < 0 || p.Percent > 100 {
+		return fmt.Errorf("%w: percent must be between 0 and 100", ErrJobProgressInvalid)
+	}
+	if len(p.Metrics) > MaxProgressMetrics {
+		return fmt.Errorf("%w: at most %d metrics", ErrJobProgressInvalid, MaxProgressMetrics)
+	}
+	for name, v := range p.Metrics {
+		if name == "" || len(name) > MaxProgressMetricName {
+			return fmt.Errorf("%w: metric names must be 1 to %d characters", ErrJobProgressInvalid, MaxProgressMetricName)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%w: metric %s is not a finite number", ErrJobProgressInvalid, name)
+		}
+	}
+	if raw, _ := json.Marshal(p.Metrics); len(raw) > MaxProgressMetricsBytes {
+		return fmt.Errorf("%w: metrics exceed %d bytes", ErrJobProgressInvalid, MaxProgressMetricsBytes)
+	}
+	return nil
+}
+
+// Subscribe returns the progress reports of job jobID as they are accepted. cancel must be
+// called once the subscriber is gone. Reports are dropped for subscribers not keeping up.
+func (s *JobProgressService) Subscribe(jobID uint) (<-chan job.Progress, func()) {
+	ch := make(chan job.Progress, 4)
+	s.mu.Lock()
+	if s.subs[jobID] == nil {
+		s.subs[jobID] = map[chan job.Progress]struct{}{}
+	}
+	s.subs[jobID][ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.subs[jobID], ch)
+			if len(s.subs[jobID]) == 0 {
+				delete(s.subs, jobID)
+			}
+		})
+	}
+}
+
+func (s *JobProgressService) publish(jobID uint, p job.Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs[jobID] {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
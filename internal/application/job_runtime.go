@@ -0,0 +1,156 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/mail"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+var ErrInvalidMaxRuntime = errors.New("invalid max runtime")
+
+// projectMaxRuntime is the longest a job of the project may run: the limit admins set on it, or
+// config.JobMaxRuntime. 0 is unlimited.
+func projectMaxRuntime(p *project.Project) time.Duration {
+	if p != nil && p.MaxJobRuntimeMinutes > 0 {
+		return time.Duration(p.MaxJobRuntimeMinutes) * time.Minute
+	}
+	return config.JobMaxRuntime
+}
+
+// resolveMaxRuntime turns the max_runtime of a submission into the limit of the job. An empty
+// request gets the project limit and a longer one is clamped to it, unless the submitter is an
+// admin; only admins may ask for "0", no limit at all.
+func resolveMaxRuntime(requested string, projectMax time.Duration, admin bool) (time.Duration, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return projectMax, nil
+	}
+	d, err := time.ParseDuration(requested)
+	if requested == "0" {
+		d, err = 0, nil
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %q, expected a duration such as 8h or 90m", ErrInvalidMaxRuntime, requested)
+	}
+	if admin {
+		return d, nil
+	}
+	if projectMax > 0 && (d == 0 || d > projectMax) {
+		return projectMax, nil
+	}
+	return d, nil
+}
+
+// jobMaxRuntime resolves the runtime limit of a job the user submits to the project.
+func (s *K8sService) jobMaxRuntime(userID, projectID uint, requested string) (time.Duration, error) {
+	var p *project.Project
+	if found, err := s.repos.Project.GetProjectByID(projectID); err == nil {
+		p = &found
+	}
+	admin, _ := utils.IsSuperAdmin(userID, s.repos.UserGroup)
+	return resolveMaxRuntime(requested, projectMaxRuntime(p), admin)
+}
+
+// JobRuntimeLimit is the limit the scheduler's runtime sweep applies to a job; 0 is unlimited.
+// Jobs submitted before runtime limits existed get the current limit of their project.
+func (s *K8sService) JobRuntimeLimit(j *job.Job) time.Duration {
+	if j.MaxRuntimeSeconds != nil {
+		return time.Duration(*j.MaxRuntimeSeconds) * time.Second
+	}
+	if j.ProjectID == nil {
+		return config.JobMaxRuntime
+	}
+	p, err := s.repos.Project.GetProjectByID(*j.ProjectID)
+	if err != nil {
+		return config.JobMaxRuntime
+	}
+	return projectMaxRuntime(&p)
+}
+
+// remainingRuntime is how long an unfinished job may still run, or nil when it has no limit.
+func remainingRuntime(j *job.Job, now time.Time) *int64 {
+	if j.MaxRuntimeSeconds == nil || *j.MaxRuntimeSeconds == 0 || j.CompletedAt != nil || !isActiveStatus(j.Status) {
+		return nil
+	}
+	left := int64(j.RunningSince().Add(time.Duration(*j.MaxRuntimeSeconds) * time.Second).Sub(now).Seconds())
+	left = max(left, 0)
+	return &left
+}
+
+func isActiveStatus(status string) bool {
+	for _, st := range job.ActiveStatuses {
+		if strings.EqualFold(status, st) {
+			return true
+		}
+	}
+	return false
+}
+
+// JobNotifier emails owners about their jobs. Failed emails are logged; a nil sender disables
+// the emails.
+type JobNotifier struct {
+	repos  *repository.Repos
+	sender mail.Sender
+}
+
+// NewJobNotifier sends through the SMTP relay from config.
+func NewJobNotifier(repos *repository.Repos) *JobNotifier {
+	return &JobNotifier{repos: repos, sender: configuredMailSender()}
+}
+
+// JobTimedOut tells the owner that their job was stopped at its runtime limit.
+func (n *JobNotifier) JobTimedOut(ctx context.Context, j *job.Job) {
+	if n.sender == nil || j.UserID == 0 {
+		return
+	}
+	owner, err := n.repos.User.GetUserRawByID(j.UserID)
+	if err != nil || owner.Email == nil || *owner.Email == "" {
+		return
+	}
+	link := config.PlatformURL + fmt.Sprintf("/jobs/%d", j.ID)
+	text := fmt.Sprintf("Hello %s,\n\nYour job %s was stopped because it reached its runtime limit.\n\n%s\n",
+		owner.Username, j.Name, link)
+	msg := mail.Message{To: []string{*owner.Email}, Subject: fmt.Sprintf("Job %s reached its runtime limit", j.Name), Text: text}
+
+	sendCtx, cancel := context.WithTimeout(ctx, approvalSendTimeout)
+	defer cancel()
+	if err := n.sender.Send(sendCtx, msg); err != nil {
+		log.Printf("Failed to notify %s about timed out job %d: %v", owner.Username, j.ID, err)
+	}
+}
+
+// JobInterrupted tells the owner that their job stopped because its pod was evicted, and whether
+// it was queued again.
+func (n *JobNotifier) JobInterrupted(ctx context.Context, j *job.Job, resubmitted bool) {
+	if n.sender == nil || j.UserID == 0 {
+		return
+	}
+	owner, err := n.repos.User.GetUserRawByID(j.UserID)
+	if err != nil || owner.Email == nil || *owner.Email == "" {
+		return
+	}
+	next := "Restart it when you are ready; it was not restarted automatically."
+	if resubmitted {
+		next = "It was queued again and resumes from its latest checkpoint."
+	}
+	link := config.PlatformURL + fmt.Sprintf("/jobs/%d", j.ID)
+	text := fmt.Sprintf("Hello %s,\n\nYour job %s was interrupted: %s.\n%s\n\n%s\n",
+		owner.Username, j.Name, j.ErrorMessage, next, link)
+	msg := mail.Message{To: []string{*owner.Email}, Subject: fmt.Sprintf("Job %s was interrupted", j.Name), Text: text}
+
+	sendCtx, cancel := context.WithTimeout(ctx, approvalSendTimeout)
+	defer cancel()
+	if err := n.sender.Send(sendCtx, msg); err != nil {
+		log.Printf("Failed to notify %s about interrupted job %d: %v", owner.Username, j.ID, err)
+	}
+}
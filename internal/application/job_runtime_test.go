@@ -0,0 +1,85 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveMaxRuntime(t *testing.T) {
+	projectMax := 24 * time.Hour
+	cases := []struct {
+		name      string
+		requested string
+		max       time.Duration
+		admin     bool
+		want      time.Duration
+	}{
+		{"empty gets the project limit", "", projectMax, false, projectMax},
+		{"shorter is kept", "2h", projectMax, false, 2 * time.Hour},
+		{"longer is clamped", "48h", projectMax, false, projectMax},
+		{"users cannot go unlimited", "0", projectMax, false, projectMax},
+		{"admins may go unlimited", "0", projectMax, true, 0},
+		{"admins are not clamped", "48h", projectMax, true, 48 * time.Hour},
+		{"unlimited project", "48h", 0, false, 48 * time.Hour},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := resolveMaxRuntime(tc.requested, tc.max, tc.admin)
+			if err != nil || got != tc.want {
+				t.Fatalf("expected %s, got %s (%v)", tc.want, got, err)
+			}
+		})
+	}
+
+	for _, bad := range []string{"forever", "-1h"} {
+		if _, err := resolveMaxRuntime(bad, projectMax, false); !errors.Is(err, ErrInvalidMaxRuntime) {
+			t.Fatalf("expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestCreateJobSetsRuntimeDeadline(t *testing.T) {
+	origClient := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = origClient })
+	client := k8sfake.NewSimpleClientset()
+	k8s.Clientset = client
+	svc, repos := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1, MaxJobRuntimeMinutes: 120})
+
+	_, err := svc.CreateJob(context.Background(), 2, job.JobSubmission{
+		Name:       "train",
+		Namespace:  "proj-7-user2",
+		Image:      "busybox:latest",
+		MaxRuntime: "8h",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list, _ := client.BatchV1().Jobs("proj-7-user2").List(context.Background(), metav1.ListOptions{})
+	if len(list.Items) != 1 {
+		t.Fatalf("expected one K8s job, got %d", len(list.Items))
+	}
+	if d := list.Items[0].Spec.ActiveDeadlineSeconds; d == nil || *d != 7200 {
+		t.Fatalf("expected the project maximum of 7200s as deadline, got %v", d)
+	}
+
+	jobs, _ := repos.Job.FindByProjectID(7)
+	if len(jobs) != 1 || jobs[0].MaxRuntimeSeconds == nil || *jobs[0].MaxRuntimeSeconds != 7200 {
+		t.Fatalf("expected the clamped limit on the row, got %+v", jobs)
+	}
+	detail, err := svc.GetJobDetail(jobs[0].ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if left := detail.RemainingRuntimeSeconds; left == nil || *left < 7100 || *left > 7200 {
+		t.Fatalf("expected about two hours left, got %v", left)
+	}
+}
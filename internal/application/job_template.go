@@ -0,0 +1,122 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"gorm.io/datatypes"
+)
+
+var (
+	ErrJobTemplateNotFound = errors.New("job template not found")
+	ErrInvalidJobOverrides = errors.New("invalid job template overrides")
+)
+
+func (s *K8sService) ListJobTemplates(userID uint, projectID *uint) ([]job.JobTemplate, error) {
+	return s.repos.JobTemplate.ListJobTemplatesByUser(userID, projectID)
+}
+
+// GetJobTemplate returns a template owned by userID. Templates of other users are reported as
+// not found so their existence is not revealed.
+func (s *K8sService) GetJobTemplate(userID, id uint) (*job.JobTemplate, error) {
+	t, err := s.repos.JobTemplate.GetJobTemplateByID(id)
+	if err != nil || t.UserID != userID {
+		return nil, ErrJobTemplateNotFound
+	}
+	return t, nil
+}
+
+// CreateJobTemplate stores the submission as-is. Images are deliberately not validated here:
+// the allow-list is checked when the template is submitted, so later changes to it apply.
+func (s *K8sService) CreateJobTemplate(userID uint, input job.JobTemplateInput) (*job.JobTemplate, error) {
+	raw, err := json.Marshal(input.Submission)
+	if err != nil {
+		return nil, err
+	}
+	t := &job.JobTemplate{
+		UserID:     userID,
+		ProjectID:  input.ProjectID,
+		Name:       input.Name,
+		Submission: datatypes.JSON(raw),
+	}
+	if err := s.repos.JobTemplate.CreateJobTemplate(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *K8sService) UpdateJobTemplate(userID, id uint, input job.JobTemplateInput) (*job.JobTemplate, error) {
+	t, err := s.GetJobTemplate(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(input.Submission)
+	if err != nil {
+		return nil, err
+	}
+	t.Name = input.Name
+	t.ProjectID = input.ProjectID
+	t.Submission = datatypes.JSON(raw)
+	if err := s.repos.JobTemplate.UpdateJobTemplate(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *K8sService) DeleteJobTemplate(userID, id uint) error {
+	if _, err := s.GetJobTemplate(userID, id); err != nil {
+		return err
+	}
+	return s.repos.JobTemplate.DeleteJobTemplate(id)
+}
+
+// ResolveJobSubmission turns a POST /k8s/jobs body into the submission to run. Inline
+// submissions are returned unchanged; template submissions load the caller's template and
+// deep-merge the overrides on top of it.
+func (s *K8sService) ResolveJobSubmission(userID uint, req job.JobSubmissionRequest) (job.JobSubmission, error) {
+	if req.TemplateID == nil {
+		return req.JobSubmission, nil
+	}
+	t, err := s.GetJobTemplate(userID, *req.TemplateID)
+	if err != nil {
+		return job.JobSubmission{}, err
+	}
+	return mergeJobSubmission(t.Submission, req.Overrides)
+}
+
+// mergeJobSubmission applies overrides to a stored submission. Nested objects such as env are
+// merged key by key with the override winning; arrays such as command are replaced, not appended.
+func mergeJobSubmission(base []byte, overrides map[string]interface{}) (job.JobSubmission, error) {
+	var merged map[string]interface{}
+	if err := json.Unmarshal(base, &merged); err != nil {
+		return job.JobSubmission{}, fmt.Errorf("corrupt job template: %w", err)
+	}
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+	deepMerge(merged, overrides)
+
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return job.JobSubmission{}, err
+	}
+	var out job.JobSubmission
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return job.JobSubmission{}, fmt.Errorf("%w: %v", ErrInvalidJobOverrides, err)
+	}
+	return out, nil
+}
+
+func deepMerge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			deepMerge(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,78 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func TestMergeJobSubmissionOverrides(t *testing.T) {
+	base, _ := json.Marshal(job.JobSubmission{
+		Name:     "train",
+		Image:    "pytorch/pytorch:2.1",
+		Command:  []string{"python", "train.py", "--epochs", "10"},
+		GPUCount: 1,
+		Env:      map[string]string{"LR": "0.1", "SEED": "42"},
+	})
+
+	got, err := mergeJobSubmission(base, map[string]interface{}{
+		"gpu_count": 4,
+		"command":   []interface{}{"python", "eval.py"},
+		"env":       map[string]interface{}{"LR": "0.01", "WANDB": "off"},
+	})
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+
+	if got.GPUCount != 4 {
+		t.Errorf("override should win for scalars, got gpu_count %d", got.GPUCount)
+	}
+	if !reflect.DeepEqual(got.Command, []string{"python", "eval.py"}) {
+		t.Errorf("arrays must be replaced, not appended, got %v", got.Command)
+	}
+	wantEnv := map[string]string{"LR": "0.01", "SEED": "42", "WANDB": "off"}
+	if !reflect.DeepEqual(got.Env, wantEnv) {
+		t.Errorf("env should be merged key by key, got %v", got.Env)
+	}
+	if got.Name != "train" || got.Image != "pytorch/pytorch:2.1" {
+		t.Errorf("fields without overrides must be kept, got %+v", got)
+	}
+
+	if _, err := mergeJobSubmission(base, map[string]interface{}{"gpu_count": "many"}); !errors.Is(err, ErrInvalidJobOverrides) {
+		t.Errorf("expected ErrInvalidJobOverrides for a mistyped override, got %v", err)
+	}
+}
+
+func TestResolveJobSubmissionScopesTemplatesToOwner(t *testing.T) {
+	db := dbtest.Open(t, &job.JobTemplate{})
+	svc := NewK8sService(repository.NewRepositories(db))
+
+	// The image is not checked against the allow-list when the template is saved
+	tpl, err := svc.CreateJobTemplate(1, job.JobTemplateInput{
+		Name:       "nightly",
+		Submission: job.JobSubmission{Name: "nightly", Image: "unlisted/image:latest", GPUCount: 1},
+	})
+	if err != nil {
+		t.Fatalf("create template failed: %v", err)
+	}
+
+	sub, err := svc.ResolveJobSubmission(1, job.JobSubmissionRequest{
+		TemplateID: &tpl.ID,
+		Overrides:  map[string]interface{}{"name": "nightly-2"},
+	})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if sub.Name != "nightly-2" || sub.Image != "unlisted/image:latest" {
+		t.Fatalf("unexpected resolved submission: %+v", sub)
+	}
+
+	if _, err := svc.ResolveJobSubmission(2, job.JobSubmissionRequest{TemplateID: &tpl.ID}); !errors.Is(err, ErrJobTemplateNotFound) {
+		t.Fatalf("other users must not use the template, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+// JobAuditResource is the audit resource type of the actions taken on jobs, whose resource ID
+// is JobAuditID of the job.
+const JobAuditResource = "job"
+
+// JobAuditID is the audit resource ID of job id.
+func JobAuditID(id uint) string {
+	return fmt.Sprintf("id=%d", id)
+}
+
+// TimelineEntry is one event of the timeline of a job. Repeated Kubernetes events are merged
+// into one entry at their first occurrence, with their count and last occurrence.
+type TimelineEntry struct {
+	Time     time.Time  `json:"time"`
+	Source   string     `json:"source"`
+	Severity string     `json:"severity"`
+	Type     string     `json:"type"`
+	Message  string     `json:"message,omitempty"`
+	Object   string     `json:"object,omitempty"`
+	Count    int        `json:"count,omitempty"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// JobTimeline merges what happened to the job, oldest first: its submission and dispatch from
+// the job row, the actions recorded in the audit log, and the Kubernetes events and status
+// transitions the status controller stored. For jobs the controller recorded no status of,
+// the start and the final status come from the job row.
+func (s *K8sService) JobTimeline(j *job.Job) ([]TimelineEntry, error) {
+	entries := []TimelineEntry{{
+		Time: j.CreatedAt, Source: job.EventSourcePlatform, Severity: job.SeverityInfo, Type: "submitted",
+		Message: fmt.Sprintf("submitted with %s priority", j.Priority),
+	}}
+	if j.DispatchedAt != nil {
+		entries = append(entries, TimelineEntry{
+			Time: *j.DispatchedAt, Source: job.EventSourcePlatform, Severity: job.SeverityInfo, Type: "dispatched",
+			Message: "handed to the cluster",
+		})
+	}
+
+	if s.repos.Audit != nil {
+		resource := JobAuditResource
+		logs, err := s.repos.Audit.GetAuditLogs(repository.AuditQueryParams{ResourceType: &resource, ResourceIDs: []string{JobAuditID(j.ID)}})
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range logs {
+			entries = append(entries, TimelineEntry{
+				Time: l.CreatedAt, Source: job.EventSourceAudit, Severity: job.SeverityInfo, Type: l.Action,
+				Message: l.Description,
+			})
+		}
+	}
+
+	events, err := s.repos.Job.FindEvents(j.ID)
+	if err != nil {
+		return nil, err
+	}
+	recordedStatus := false
+	for _, e := range events {
+		entry := TimelineEntry{Time: e.FirstSeen, Source: e.Source, Severity: e.Severity, Type: e.Reason, Message: e.Message}
+		if e.Source == job.EventSourceStatus {
+			recordedStatus = true
+		} else {
+			entry.Object = strings.ToLower(e.ObjectKind) + "/" + e.ObjectName
+			entry.Count = e.Count
+			if e.Count > 1 {
+				last := e.LastSeen
+				entry.LastSeen = &last
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if !recordedStatus {
+		if j.StartedAt != nil {
+			entries = append(entries, TimelineEntry{
+				Time: *j.StartedAt, Source: job.EventSourceStatus, Severity: job.SeverityInfo, Type: string(job.JobStatusRunning),
+			})
+		}
+		if j.CompletedAt != nil {
+			entry := TimelineEntry{Time: *j.CompletedAt, Source: job.EventSourceStatus, Severity: job.StatusSeverity(j.Status), Type: j.Status}
+			if entry.Severity != job.SeverityInfo {
+				entry.Message = j.ErrorMessage
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].Time.Before(entries[b].Time) })
+	return entries, nil
+}
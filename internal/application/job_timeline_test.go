@@ -0,0 +1,80 @@
+package application
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/audit"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func TestJobTimelineMergesSourcesInOrder(t *testing.T) {
+	db := dbtest.Open(t, &job.Job{}, &job.JobEvent{}, &audit.AuditLog{})
+	t0 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) *time.Time {
+		v := t0.Add(time.Duration(minutes) * time.Minute)
+		return &v
+	}
+	j := job.Job{
+		ID: 1, UserID: 7, Name: "train", Namespace: "proj-1-u", Image: "busybox", K8sJobName: "train", Priority: "high",
+		Status: "failed", ErrorMessage: "BackOff: container crashed", CreatedAt: t0, DispatchedAt: at(5), StartedAt: at(30), CompletedAt: at(40),
+	}
+	db.Create(&j)
+	repos := repository.NewRepositories(db)
+	for _, e := range []job.JobEvent{
+		{Source: job.EventSourceStatus, Fingerprint: "s1", Reason: "running", Severity: job.SeverityInfo, FirstSeen: *at(30), LastSeen: *at(30)},
+		{Source: job.EventSourceKubernetes, Fingerprint: "k1", ObjectKind: "Pod", ObjectName: "train-x", Reason: "Scheduled", Severity: job.SeverityInfo, Count: 1, FirstSeen: *at(6), LastSeen: *at(6)},
+		{Source: job.EventSourceKubernetes, Fingerprint: "k2", ObjectKind: "Pod", ObjectName: "train-x", Reason: "Pulled", Message: `Successfully pulled image "busybox" in 22m59s`, Severity: job.SeverityInfo, Count: 1, FirstSeen: *at(29), LastSeen: *at(29)},
+		{Source: job.EventSourceKubernetes, Fingerprint: "k3", ObjectKind: "Pod", ObjectName: "train-x", Reason: "BackOff", Severity: job.SeverityWarning, Count: 3, FirstSeen: *at(31), LastSeen: *at(38)},
+		{Source: job.EventSourceStatus, Fingerprint: "s2", Reason: "failed", Message: j.ErrorMessage, Severity: job.SeverityError, FirstSeen: *at(40), LastSeen: *at(40)},
+	} {
+		e := e
+		e.JobID = j.ID
+		if err := repos.Job.SaveEvent(&e); err != nil {
+			t.Fatalf("save event %s: %v", e.Reason, err)
+		}
+	}
+	db.Create(&audit.AuditLog{UserID: 1, Action: "restart", ResourceType: JobAuditResource, ResourceID: JobAuditID(j.ID), Description: "requeued", CreatedAt: *at(45)})
+	db.Create(&audit.AuditLog{UserID: 1, Action: "restart", ResourceType: JobAuditResource, ResourceID: JobAuditID(2), CreatedAt: *at(46)})
+
+	timeline, err := NewK8sService(repos).JobTimeline(&j)
+	if err != nil {
+		t.Fatalf("JobTimeline: %v", err)
+	}
+	want := []string{"submitted", "dispatched", "Scheduled", "Pulled", "running", "BackOff", "failed", "restart"}
+	if len(timeline) != len(want) {
+		t.Fatalf("expected %d entries without the row statuses repeated, got %+v", len(want), timeline)
+	}
+	for i, entry := range timeline {
+		if entry.Type != want[i] {
+			t.Fatalf("entry %d: expected %s, got %s (%+v)", i, want[i], entry.Type, timeline)
+		}
+	}
+	backOff := timeline[5]
+	if backOff.Count != 3 || backOff.LastSeen == nil || !backOff.LastSeen.Equal(*at(38)) || backOff.Object != "pod/train-x" || backOff.Severity != job.SeverityWarning {
+		t.Fatalf("unexpected BackOff entry %+v", backOff)
+	}
+	if failed := timeline[6]; failed.Source != job.EventSourceStatus || failed.Severity != job.SeverityError || failed.Message != j.ErrorMessage {
+		t.Fatalf("unexpected final status %+v", failed)
+	}
+	if restart := timeline[7]; restart.Source != job.EventSourceAudit || restart.Message != "requeued" {
+		t.Fatalf("unexpected audit entry %+v", restart)
+	}
+}
+
+func TestJobTimelineFallsBackToRowStatuses(t *testing.T) {
+	db := dbtest.Open(t, &job.Job{}, &job.JobEvent{})
+	t0 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	started, completed := t0.Add(time.Minute), t0.Add(time.Hour)
+	j := job.Job{ID: 1, Status: "completed", CreatedAt: t0, StartedAt: &started, CompletedAt: &completed}
+
+	timeline, err := NewK8sService(&repository.Repos{Job: repository.NewJobRepo(db)}).JobTimeline(&j)
+	if err != nil {
+		t.Fatalf("JobTimeline: %v", err)
+	}
+	if len(timeline) != 3 || timeline[1].Type != "running" || timeline[2].Type != "completed" || timeline[2].Severity != job.SeverityInfo {
+		t.Fatalf("expected the start and the completion from the row, got %+v", timeline)
+	}
+}
@@ -0,0 +1,47 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+)
+
+// ErrJobTypeUnavailable is returned for external jobs when no external cluster is configured.
+var ErrJobTypeUnavailable = errors.New("job type is not available")
+
+// JobTypeNotAllowedError is returned when a user submits a job type their role may not use.
+type JobTypeNotAllowedError struct {
+	Requested string
+	Allowed   []string
+}
+
+func (e *JobTypeNotAllowedError) Error() string {
+	return fmt.Sprintf("job type '%s' is not allowed for your role. Allowed: %s", e.Requested, strings.Join(e.Allowed, ", "))
+}
+
+func allowedJobTypes(role string) []string {
+	if types, ok := config.RoleJobTypes[role]; ok {
+		return types
+	}
+	return config.RoleJobTypes["user"]
+}
+
+// resolveJobType validates the requested job type against the role. An empty request is a
+// normal job.
+func resolveJobType(requested string, role string) (job.JobType, error) {
+	jobType := job.JobType(strings.ToLower(strings.TrimSpace(requested)))
+	if jobType == "" {
+		return job.JobTypeNormal, nil
+	}
+	allowed := allowedJobTypes(role)
+	if (jobType != job.JobTypeNormal && jobType != job.JobTypeExternal) || !containsString(allowed, string(jobType)) {
+		return "", &JobTypeNotAllowedError{Requested: requested, Allowed: allowed}
+	}
+	if jobType == job.JobTypeExternal && config.ExternalExecutorURL == "" {
+		return "", fmt.Errorf("%w: no external cluster is configured", ErrJobTypeUnavailable)
+	}
+	return jobType, nil
+}
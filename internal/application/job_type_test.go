@@ -0,0 +1,40 @@
+package application
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+)
+
+func TestResolveJobTypeRoleGate(t *testing.T) {
+	orig := config.ExternalExecutorURL
+	defer func() { config.ExternalExecutorURL = orig }()
+	config.ExternalExecutorURL = "http://slurm.example"
+
+	if jobType, err := resolveJobType("", "user"); err != nil || jobType != job.JobTypeNormal {
+		t.Fatalf("expected a normal job by default, got %s %v", jobType, err)
+	}
+
+	_, err := resolveJobType("external", "user")
+	var notAllowed *JobTypeNotAllowedError
+	if !errors.As(err, &notAllowed) {
+		t.Fatalf("expected JobTypeNotAllowedError, got %v", err)
+	}
+	if len(notAllowed.Allowed) != 1 || notAllowed.Allowed[0] != "normal" {
+		t.Fatalf("expected allowed [normal], got %v", notAllowed.Allowed)
+	}
+
+	if jobType, err := resolveJobType("External", "manager"); err != nil || jobType != job.JobTypeExternal {
+		t.Fatalf("expected a manager to submit external jobs, got %s %v", jobType, err)
+	}
+	if _, err := resolveJobType("mpi", "admin"); !errors.As(err, &notAllowed) {
+		t.Fatalf("expected an unknown type to be rejected, got %v", err)
+	}
+
+	config.ExternalExecutorURL = ""
+	if _, err := resolveJobType("external", "admin"); !errors.Is(err, ErrJobTypeUnavailable) {
+		t.Fatalf("expected external jobs to be unavailable without a cluster, got %v", err)
+	}
+}
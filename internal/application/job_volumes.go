@@ -0,0 +1,201 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	ErrJobVolumeNotFound = errors.New("job volume claim not found")
+	ErrJobVolumeNotReady = errors.New("job volume claim cannot be mounted")
+)
+
+// Reasons of a VolumeWarning
+const (
+	VolumeWarningUnbound   = "unbound"
+	VolumeWarningRWOInUse  = "rwo_in_use"
+	VolumeWarningUnchecked = "unchecked"
+)
+
+// VolumeWarning is a PVC mounted by a job that would likely keep the job's pods Pending.
+type VolumeWarning struct {
+	Volume  string `json:"volume"`
+	PVC     string `json:"pvc"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// VolumeCheckError rejects a job whose volumes have warnings, in projects with strict volume checks.
+type VolumeCheckError struct {
+	Warnings []VolumeWarning
+}
+
+func (e *VolumeCheckError) Error() string {
+	parts := make([]string, len(e.Warnings))
+	for i, w := range e.Warnings {
+		parts[i] = w.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrJobVolumeNotReady, strings.Join(parts, "; "))
+}
+
+func (e *VolumeCheckError) Unwrap() error {
+	return ErrJobVolumeNotReady
+}
+
+// checkJobVolumes verifies the PVCs mounted by a job exist and returns those that cannot be
+// mounted yet: claims still Pending, unless their class binds on first consumer, and
+// ReadWriteOnce claims another running pod of the namespace already mounts.
+func checkJobVolumes(ctx context.Context, ns string, volumes []k8s.VolumeSpec) ([]VolumeWarning, error) {
+	if k8s.Clientset == nil || len(volumes) == 0 {
+		return nil, nil
+	}
+	claims := k8s.Clientset.CoreV1().PersistentVolumeClaims(ns)
+	var (
+		warnings []VolumeWarning
+		mounters map[string][]string
+		classes  map[string]*storagev1.StorageClass
+	)
+	for _, v := range volumes {
+		if v.PVCName == "" {
+			continue
+		}
+		pvc, err := claims.Get(ctx, v.PVCName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s in %s", ErrJobVolumeNotFound, v.PVCName, ns)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get PVC %s: %w", v.PVCName, err)
+		}
+		warn := func(reason, msg string) {
+			warnings = append(warnings, VolumeWarning{Volume: v.Name, PVC: v.PVCName, Reason: reason, Message: msg})
+		}
+
+		if pvc.Status.Phase != corev1.ClaimBound {
+			if classes == nil {
+				if classes, err = storageClassesByName(ctx); err != nil {
+					warn(VolumeWarningUnchecked, fmt.Sprintf("PVC %s is %s and its storage class could not be read: %v", pvc.Name, pvc.Status.Phase, err))
+					continue
+				}
+			}
+			if sc := claimStorageClass(pvc, classes); sc == nil || !bindsOnFirstConsumer(sc) {
+				warn(VolumeWarningUnbound, fmt.Sprintf("PVC %s is %s, not Bound; the job will wait until it is", pvc.Name, pvc.Status.Phase))
+			}
+			continue
+		}
+
+		if !isReadWriteOnce(pvc) {
+			continue
+		}
+		if mounters == nil {
+			if mounters, err = runningPodClaims(ctx, ns); err != nil {
+				warn(VolumeWarningUnchecked, fmt.Sprintf("pods mounting ReadWriteOnce PVC %s could not be listed: %v", pvc.Name, err))
+				mounters = map[string][]string{}
+				continue
+			}
+		}
+		if pods := mounters[pvc.Name]; len(pods) > 0 {
+			warn(VolumeWarningRWOInUse, fmt.Sprintf("PVC %s is ReadWriteOnce and already mounted by %s; the job can only start on the same node",
+				pvc.Name, strings.Join(pods, ", ")))
+		}
+	}
+	return warnings, nil
+}
+
+// volumeJobWarnings returns the volume warnings as warnings of the job, on the volume of the
+// submission each concerns.
+func volumeJobWarnings(volumes []k8s.VolumeSpec, warnings []VolumeWarning) []job.Warning {
+	codes := map[string]string{
+		VolumeWarningUnbound:   job.WarningVolumeUnbound,
+		VolumeWarningRWOInUse:  job.WarningVolumeRWOInUse,
+		VolumeWarningUnchecked: job.WarningVolumeUnchecked,
+	}
+	out := make([]job.Warning, 0, len(warnings))
+	for _, w := range warnings {
+		jw := job.Warning{Code: codes[w.Reason], Message: w.Message}
+		for i, v := range volumes {
+			if v.Name == w.Volume && v.PVCName == w.PVC {
+				jw.Field = fmt.Sprintf("volumes[%d].pvc_name", i)
+				break
+			}
+		}
+		out = append(out, jw)
+	}
+	return out
+}
+
+func storageClassesByName(ctx context.Context) (map[string]*storagev1.StorageClass, error) {
+	list, err := k8s.ListStorageClasses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	classes := make(map[string]*storagev1.StorageClass, len(list))
+	for i := range list {
+		classes[list[i].Name] = &list[i]
+	}
+	return classes, nil
+}
+
+// claimStorageClass returns the class of pvc, the cluster default when it names none.
+func claimStorageClass(pvc *corev1.PersistentVolumeClaim, classes map[string]*storagev1.StorageClass) *storagev1.StorageClass {
+	if pvc.Spec.StorageClassName != nil {
+		return classes[*pvc.Spec.StorageClassName]
+	}
+	for _, sc := range classes {
+		if k8s.IsDefaultStorageClass(sc) {
+			return sc
+		}
+	}
+	return nil
+}
+
+// bindsOnFirstConsumer reports whether claims of sc stay Pending until a pod uses them.
+func bindsOnFirstConsumer(sc *storagev1.StorageClass) bool {
+	return sc.VolumeBindingMode != nil && *sc.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer
+}
+
+// isReadWriteOnce reports whether pvc can only be attached to one node (or pod) at a time.
+func isReadWriteOnce(pvc *corev1.PersistentVolumeClaim) bool {
+	single := false
+	for _, m := range pvc.Spec.AccessModes {
+		switch m {
+		case corev1.ReadWriteMany, corev1.ReadOnlyMany:
+			return false
+		case corev1.ReadWriteOnce, corev1.ReadWriteOncePod:
+			single = true
+		}
+	}
+	return single
+}
+
+// runningPodClaims maps each claim mounted by a running pod of ns to those pods.
+func runningPodClaims(ctx context.Context, ns string) (map[string][]string, error) {
+	pods, err := k8s.Clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string][]string{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil {
+				claims[v.PersistentVolumeClaim.ClaimName] = append(claims[v.PersistentVolumeClaim.ClaimName], pod.Name)
+			}
+		}
+	}
+	for _, names := range claims {
+		sort.Strings(names)
+	}
+	return claims, nil
+}
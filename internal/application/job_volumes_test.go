@@ -0,0 +1,160 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+const volumeTestNS = "proj-7-user2"
+
+func testPVC(name, class string, phase corev1.PersistentVolumeClaimPhase, modes ...corev1.PersistentVolumeAccessMode) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: volumeTestNS},
+		Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &class, AccessModes: modes},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func testStorageClass(name string, mode storagev1.VolumeBindingMode) *storagev1.StorageClass {
+	return &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: name}, VolumeBindingMode: &mode}
+}
+
+func podMountingPVC(name, claim string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: volumeTestNS},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+		}}},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func useVolumeCluster(t *testing.T, objs ...runtime.Object) {
+	t.Helper()
+	orig := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = orig })
+	k8s.Clientset = k8sfake.NewSimpleClientset(objs...)
+}
+
+func TestCheckJobVolumes(t *testing.T) {
+	useVolumeCluster(t,
+		testStorageClass("immediate", storagev1.VolumeBindingImmediate),
+		testStorageClass("local-path", storagev1.VolumeBindingWaitForFirstConsumer),
+		testPVC("unbound", "immediate", corev1.ClaimPending, corev1.ReadWriteOnce),
+		testPVC("first-consumer", "local-path", corev1.ClaimPending, corev1.ReadWriteOnce),
+		testPVC("rwo", "immediate", corev1.ClaimBound, corev1.ReadWriteOnce),
+		testPVC("rwx", "immediate", corev1.ClaimBound, corev1.ReadWriteMany),
+		testPVC("rwo-idle", "immediate", corev1.ClaimBound, corev1.ReadWriteOnce),
+		podMountingPVC("notebook-0", "rwo", corev1.PodRunning),
+		podMountingPVC("other-0", "rwx", corev1.PodRunning),
+		podMountingPVC("done-0", "rwo-idle", corev1.PodSucceeded),
+	)
+	ctx := context.Background()
+
+	cases := []struct {
+		pvc    string
+		reason string
+	}{
+		{"unbound", VolumeWarningUnbound},
+		{"first-consumer", ""},
+		{"rwo", VolumeWarningRWOInUse},
+		{"rwx", ""},
+		{"rwo-idle", ""},
+	}
+	for _, tc := range cases {
+		warnings, err := checkJobVolumes(ctx, volumeTestNS, []k8s.VolumeSpec{{Name: "data", PVCName: tc.pvc, MountPath: "/data"}})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.pvc, err)
+		}
+		if tc.reason == "" {
+			if len(warnings) != 0 {
+				t.Fatalf("%s: expected no warning, got %+v", tc.pvc, warnings)
+			}
+			continue
+		}
+		if len(warnings) != 1 || warnings[0].Reason != tc.reason || warnings[0].PVC != tc.pvc || warnings[0].Volume != "data" {
+			t.Fatalf("%s: expected a %s warning, got %+v", tc.pvc, tc.reason, warnings)
+		}
+	}
+
+	_, err := checkJobVolumes(ctx, volumeTestNS, []k8s.VolumeSpec{{Name: "data", PVCName: "missing"}})
+	if !errors.Is(err, ErrJobVolumeNotFound) {
+		t.Fatalf("expected ErrJobVolumeNotFound, got %v", err)
+	}
+}
+
+func TestCreateJobVolumeWarnings(t *testing.T) {
+	useVolumeCluster(t,
+		testStorageClass("immediate", storagev1.VolumeBindingImmediate),
+		testPVC("unbound", "immediate", corev1.ClaimPending, corev1.ReadWriteOnce),
+	)
+	submission := job.JobSubmission{
+		Name:      "train",
+		Namespace: volumeTestNS,
+		Image:     "busybox:latest",
+		Volumes:   []job.VolumeSpec{{Name: "data", PVCName: "unbound", MountPath: "/data"}},
+	}
+
+	// Warn and allow by default
+	svc, repos := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1})
+	warnings, err := svc.CreateJob(context.Background(), 2, submission)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Code != job.WarningVolumeUnbound || warnings[0].Field != "volumes[0].pvc_name" {
+		t.Fatalf("expected an unbound warning, got %+v", warnings)
+	}
+	created, err := repos.Job.FindByNamespace(volumeTestNS)
+	if err != nil || len(created) != 1 {
+		t.Fatalf("expected the job created despite the warning, got %v %v", created, err)
+	}
+	if recorded := created[0].JobWarnings(); len(recorded) != 1 || recorded[0] != warnings[0] {
+		t.Fatalf("expected the warning recorded on the job, got %+v", recorded)
+	}
+
+	// Strict projects reject the job
+	p, _ := repos.Project.GetProjectByID(7)
+	p.StrictVolumeChecks = true
+	if err := repos.Project.UpdateProject(&p); err != nil {
+		t.Fatalf("failed to update project: %v", err)
+	}
+	submission.Name = "train-2"
+	_, err = svc.CreateJob(context.Background(), 2, submission)
+	var volumeErr *VolumeCheckError
+	if !errors.As(err, &volumeErr) || !errors.Is(err, ErrJobVolumeNotReady) || len(volumeErr.Warnings) != 1 {
+		t.Fatalf("expected a VolumeCheckError, got %v", err)
+	}
+}
+
+func TestDeferredJobWarnsOfTheQueueAhead(t *testing.T) {
+	svc, repos := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1})
+	for i, priority := range []string{job.PriorityLow, job.PriorityHigh, job.PriorityMedium} {
+		queued := job.Job{UserID: 1, Name: fmt.Sprintf("q%d", i), Namespace: volumeTestNS, Image: "busybox", K8sJobName: "q", Status: string(job.JobStatusQueued), Priority: priority}
+		if err := repos.Job.Create(&queued); err != nil {
+			t.Fatalf("failed to seed queued job: %v", err)
+		}
+	}
+
+	// Gangs are dispatched by the scheduler; a low priority job waits behind all three
+	warnings, err := svc.CreateJob(context.Background(), 2, job.JobSubmission{
+		Name: "gang", Namespace: volumeTestNS, Image: "busybox:latest", Parallelism: 2, Gang: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Code != job.WarningQueued || warnings[0].Message != "queued behind 3 jobs" {
+		t.Fatalf("expected a queue warning, got %+v", warnings)
+	}
+}
@@ -2,23 +2,46 @@ package application
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/job"
 	"github.com/linskybing/platform-go/internal/domain/project"
 	"github.com/linskybing/platform-go/internal/repository"
+	imageref "github.com/linskybing/platform-go/pkg/image"
 	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/pagination"
 	"github.com/linskybing/platform-go/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+var ErrUnsupportedAccessMode = errors.New("unsupported access mode")
+
+var (
+	ErrInvalidStorageName = errors.New("invalid storage name")
+	ErrStorageNotFound    = errors.New("project storage not found")
+	ErrStorageNotReady    = errors.New("storage did not become ready")
+	ErrStorageDegraded    = errors.New("user storage hub is degraded")
+)
+
+var (
+	ErrGPUAccessNotAllowed = errors.New("GPU access type not allowed")
+	ErrGPUQuotaExceeded    = errors.New("GPU quota exceeded")
+)
+
+// maxStorageNameLength keeps project-{pid}-{name} well within the 63 character label limit.
+const maxStorageNameLength = 40
+
 type K8sService struct {
 	repos        *repository.Repos
 	imageService *ImageService
@@ -31,14 +54,20 @@ func NewK8sService(repos *repository.Repos) *K8sService {
 	}
 }
 
-func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSubmission) error {
-	// Extract image name and tag
-	imageParts := strings.Split(input.Image, ":")
-	if len(imageParts) != 2 {
-		return fmt.Errorf("invalid image format, expected name:tag")
+// CreateJob validates and submits a job, or defers it to the scheduler. It returns the findings
+// that did not stop the job, such as PVCs that cannot be mounted yet, which are also recorded on
+// the job.
+func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSubmission) ([]job.Warning, error) {
+	if len(input.DependsOn) > 0 {
+		if err := s.validateJobDependencies(userID, input.DependsOn); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reject malformed references up front; "nginx" and "name@sha256:..." are fine
+	if _, err := imageref.Parse(input.Image); err != nil {
+		return nil, err
 	}
-	imageName := imageParts[0]
-	imageTag := imageParts[1]
 
 	// Parse Project ID from Namespace.
 	// Accepts either:
@@ -51,11 +80,11 @@ func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSu
 			pidStr := parts[1]
 			pid, err := strconv.Atoi(pidStr)
 			if err != nil {
-				return fmt.Errorf("invalid namespace format: %w", err)
+				return nil, fmt.Errorf("invalid namespace format: %w", err)
 			}
 			projectID = uint(pid)
 		} else {
-			return fmt.Errorf("invalid namespace format, expected proj-<pid>-<username>")
+			return nil, fmt.Errorf("invalid namespace format, expected proj-<pid>-<username>")
 		}
 	} else {
 		parts := strings.Split(input.Namespace, "-")
@@ -63,24 +92,41 @@ func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSu
 			pidStr := parts[0]
 			pid, err := strconv.Atoi(pidStr)
 			if err != nil {
-				return fmt.Errorf("invalid namespace format: %w", err)
+				return nil, fmt.Errorf("invalid namespace format: %w", err)
 			}
 			projectID = uint(pid)
 		} else {
-			return fmt.Errorf("invalid namespace format, expected proj-<pid>-<username> or pid-username")
+			return nil, fmt.Errorf("invalid namespace format, expected proj-<pid>-<username> or pid-username")
 		}
 	}
 
-	// Check if image is in allowed list. If so, prepend Harbor private prefix.
-	// If not allowed, we don't block it (non-mandatory), but we don't add the prefix.
-	isAllowed, _ := s.imageService.ValidateImageForProject(imageName, imageTag, &projectID)
-	if isAllowed {
-		prefix := config.HarborPrivatePrefix
-		if prefix != "" && !strings.HasPrefix(input.Image, prefix) {
-			input.Image = fmt.Sprintf("%s%s", prefix, input.Image)
+	// Projects sharing one namespace run the job there, under the member's name prefix
+	var costCenter string
+	var strictVolumes bool
+	if p, err := s.repos.Project.GetProjectByID(projectID); err == nil {
+		costCenter = p.CostCenter
+		strictVolumes = p.StrictVolumeChecks
+		if p.SharesNamespace() {
+			if _, owner, ok := k8s.ParseProjectNamespace(input.Namespace); ok {
+				input.Name = sharedName(owner+"-", input.Name)
+			}
+			input.Namespace = ProjectStorageNamespace(&p)
 		}
 	}
 
+	// Every unfinished job counts, GPU or not, so CPU-only jobs cannot exhaust the cluster's pods
+	if err := s.checkJobLimits(projectID, userID, job.ActiveStatuses, 0); err != nil {
+		return nil, err
+	}
+	maxRuntime, err := s.jobMaxRuntime(userID, projectID, input.MaxRuntime)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if image is in allowed list. If so, prepend Harbor private prefix.
+	// If not allowed, we don't block it (non-mandatory), but we don't add the prefix.
+	input.Image, _ = s.imageService.PrefixAllowedImage(input.Image, &projectID)
+
 	// Convert input volumes to k8s.VolumeSpec
 	var volumes []k8s.VolumeSpec
 	for _, v := range input.Volumes {
@@ -90,9 +136,27 @@ func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSu
 			MountPath: v.MountPath,
 		})
 	}
+	// Claims that cannot be mounted yet leave the pods Pending; warn, or reject in strict projects
+	volumeWarnings, err := checkJobVolumes(ctx, input.Namespace, volumes)
+	if err != nil {
+		return nil, err
+	}
+	if strictVolumes && len(volumeWarnings) > 0 {
+		return nil, &VolumeCheckError{Warnings: volumeWarnings}
+	}
+	warnings := volumeJobWarnings(volumes, volumeWarnings)
 
 	envVars := make(map[string]string)
+	for k, v := range input.Env {
+		envVars[k] = v
+	}
 	annotations := make(map[string]string)
+	// Record on the pods who approved an allow-listed image
+	if p, err := s.imageService.ImageProvenance(input.Image, &projectID); err == nil && p != nil {
+		for k, v := range p.Annotations() {
+			annotations[k] = v
+		}
+	}
 
 	// Check GPU Quota and Access
 	if input.GPUCount > 0 {
@@ -115,13 +179,13 @@ func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSu
 			}
 
 			if !isAllowed {
-				return fmt.Errorf("GPU access type '%s' is not allowed for this project. Allowed: %s", requestedType, project.GPUAccess)
+				return nil, fmt.Errorf("%w: '%s' is not allowed for this project. Allowed: %s", ErrGPUAccessNotAllowed, requestedType, project.GPUAccess)
 			}
 
 			// Check Quota
 			currentUsage, err := s.CountProjectGPUUsage(ctx, projectID)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			// Calculate requested quota units
@@ -130,9 +194,13 @@ func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSu
 				// Assuming 1 dedicated GPU = 10 shared units
 				requestedUnits = input.GPUCount * 10
 			}
+			// Every pod of a gang holds its GPUs at the same time
+			if input.Gang && input.Parallelism > 1 {
+				requestedUnits *= int(input.Parallelism)
+			}
 
 			if currentUsage+requestedUnits > project.GPUQuota {
-				return fmt.Errorf("GPU quota exceeded. Current: %d, Requested: %d, Quota: %d", currentUsage, requestedUnits, project.GPUQuota)
+				return nil, fmt.Errorf("%w. Current: %d, Requested: %d, Quota: %d", ErrGPUQuotaExceeded, currentUsage, requestedUnits, project.GPUQuota)
 			}
 
 			// Handle Dedicated on Shared Node (Emulation)
@@ -142,29 +210,57 @@ func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSu
 
 				// Set MPS limits to Max for "dedicated" usage via Annotations
 				annotations["mps.nvidia.com/threads"] = "100"
-				annotations["mps.nvidia.com/vram"] = "48000M"
+				annotations["mps.nvidia.com/vram"] = fmt.Sprintf("%dM", config.GPUNodeVRAMMB)
 			}
 
-			// Inject MPS Annotations if shared
+			// Inject MPS Annotations and environment if shared; the environment is what the
+			// CUDA runtime enforces, so it replaces values the user set
 			if requestedType == "shared" {
 				if project.GPUQuota > 0 {
-					// System will auto-inject CUDA_MPS_ACTIVE_THREAD_PERCENTAGE
-					// Use GPU quota as a reference for MPS configuration
 					annotations["gpu.quota"] = strconv.Itoa(project.GPUQuota)
 				}
 				if project.MPSMemory > 0 {
 					annotations["mps.nvidia.com/vram"] = fmt.Sprintf("%dM", project.MPSMemory)
 				}
+				for k, v := range projectMPSConfig(project, input.GPUCount).ToEnvVars() {
+					envVars[k] = v
+				}
 			}
 		}
 	}
 
-	// Determine PriorityClassName
-	priorityClassName := "low-priority"
-	// Force low priority for now as per requirement
-	// if input.Priority == "high" {
-	// 	priorityClassName = "high-priority"
-	// }
+	// Determine PriorityClassName from the requested level and the job type, gated by the user's role
+	role := s.priorityRole(userID, projectID)
+	priorityLevel, priorityClassName, err := resolvePriorityClass(input.Priority, role)
+	if err != nil {
+		return nil, err
+	}
+	jobType, err := resolveJobType(input.Type, role)
+	if err != nil {
+		return nil, err
+	}
+
+	// Project env defaults; keys the user set explicitly take precedence
+	projectEnv, err := resolveProjectEnv(ctx, s.repos.ProjectEnv, projectID, input.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	scheduling, err := resolveSchedulingPolicy(s.repos.Scheduling, projectID)
+	if err != nil {
+		return nil, err
+	}
+	// Images from the project's private registries are pulled with the project pull secret
+	var pullSecrets []string
+	registryAuths, err := projectRegistryAuths(s.repos.Registry, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(authsForImages(registryAuths, input.Image)) > 0 {
+		if err := ensureProjectPullSecret(ctx, input.Namespace, projectID, registryAuths); err != nil {
+			return nil, err
+		}
+		pullSecrets = []string{ProjectPullSecretName(projectID)}
+	}
 
 	spec := k8s.JobSpec{
 		Name:              input.Name,
@@ -178,7 +274,11 @@ func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSu
 		GPUCount:          input.GPUCount,
 		GPUType:           input.GPUType,
 		EnvVars:           envVars,
+		Env:               projectEnv,
 		Annotations:       annotations,
+		Labels:            k8s.Ownership{ProjectID: projectID, UserID: userID}.Labels(),
+		Scheduling:        scheduling,
+		ImagePullSecrets:  pullSecrets,
 	}
 
 	// Default values if not provided
@@ -188,34 +288,89 @@ func (s *K8sService) CreateJob(ctx context.Context, userID uint, input job.JobSu
 	if spec.Completions == 0 {
 		spec.Completions = 1
 	}
+	spec.Gang = input.Gang && spec.Parallelism > 1
+	if err := s.checkJobPodSecurity(userID, projectID, spec); err != nil {
+		return nil, err
+	}
+	runtimeSeconds := int64(maxRuntime / time.Second)
+	if runtimeSeconds > 0 {
+		spec.ActiveDeadlineSeconds = &runtimeSeconds
+	}
+	if input.ArtifactUpload != nil {
+		upload, err := artifactUploadSpec(input.ArtifactUpload)
+		if err != nil {
+			return nil, err
+		}
+		spec.Artifacts = upload
+	}
 
 	jobRecord := job.Job{
 		UserID:     userID,
+		ProjectID:  &projectID,
 		Name:       input.Name,
 		Namespace:  input.Namespace,
 		Image:      input.Image,
 		K8sJobName: input.Name,
-		Priority:   "low", // Force low priority in DB record
+		JobType:    jobType,
+		Priority:   priorityLevel,
 		Status:     "Pending",
+		// Recorded even when unlimited, so the runtime sweep does not apply the project limit
+		MaxRuntimeSeconds: &runtimeSeconds,
+		CostCenter:        costCenter,
+		RestartOnEviction: job.RestartsOnEviction(input.RestartOnEviction, false),
 	}
 
-	// Skip K8s creation when no client is configured (tests); still record DB entry.
-	if k8s.Clientset == nil {
-		return s.repos.Job.Create(&jobRecord)
+	// Jobs with run-after dependencies, gangs waiting for capacity and external jobs are
+	// dispatched later by the scheduler
+	if len(input.DependsOn) > 0 || spec.Gang || jobType == job.JobTypeExternal {
+		if w, ok := s.queueWarning(priorityLevel); ok {
+			warnings = append(warnings, w)
+		}
+		jobRecord.AddWarnings(warnings...)
+		if err := s.deferJob(&jobRecord, spec, projectID, input); err != nil {
+			return nil, err
+		}
+		return warnings, nil
 	}
+	jobRecord.AddWarnings(warnings...)
 
-	if err := k8s.CreateJob(ctx, spec); err != nil {
-		return err
+	// A job queued again after an eviction is dispatched by the scheduler from its stored spec
+	if jobRecord.RestartOnEviction {
+		rawSpec, err := json.Marshal(spec)
+		if err != nil {
+			return nil, err
+		}
+		jobRecord.Spec = string(rawSpec)
+	}
+
+	// Skip K8s creation when no client is configured (tests); still record DB entry.
+	if k8s.Clientset == nil {
+		if err := s.repos.Job.Create(&jobRecord); err != nil {
+			return nil, err
+		}
+		return warnings, nil
 	}
 
-	// Record job in database
+	// Record the job first so its ID can label the K8s objects; drop the row if creation fails.
+	// Nothing follows a job submitted directly, so it counts as started when it is dispatched.
+	started := time.Now()
+	jobRecord.DispatchedAt = &started
+	jobRecord.StartedAt = &started
 	if err := s.repos.Job.Create(&jobRecord); err != nil {
-		// Note: Job is created in K8s but DB record failed.
-		// In a real system, we might want to rollback or handle this inconsistency.
-		return err
+		return nil, err
+	}
+	spec.Labels = k8s.Ownership{ProjectID: projectID, UserID: userID, JobID: jobRecord.ID}.Labels()
+	for k, v := range JobProgressEnv(jobRecord.ID) {
+		spec.EnvVars[k] = v
+	}
+	if err := k8s.CreateJob(ctx, spec); err != nil {
+		if delErr := s.repos.Job.Delete(jobRecord.ID); delErr != nil {
+			log.Printf("failed to remove job record %d after create error: %v", jobRecord.ID, delErr)
+		}
+		return nil, err
 	}
 
-	return nil
+	return warnings, nil
 }
 
 func (s *K8sService) ListJobs(userID uint, isAdmin bool) ([]job.Job, error) {
@@ -225,13 +380,20 @@ func (s *K8sService) ListJobs(userID uint, isAdmin bool) ([]job.Job, error) {
 	return s.repos.Job.FindByUserID(userID)
 }
 
+// ListJobsPage returns one page of the jobs ListJobs returns, newest first.
+func (s *K8sService) ListJobsPage(userID uint, isAdmin bool, p pagination.Params) (*pagination.Page[job.Job], error) {
+	if isAdmin {
+		return s.repos.Job.FindPage(nil, p)
+	}
+	return s.repos.Job.FindPage(&userID, p)
+}
+
 func (s *K8sService) GetJob(id uint) (*job.Job, error) {
 	return s.repos.Job.FindByID(id)
 }
 
 func (s *K8sService) CountProjectGPUUsage(ctx context.Context, projectID uint) (int, error) {
-	// Project namespaces are prefixed with "proj-<pid>-"; search for that pattern.
-	namespaces, err := k8s.GetFilteredNamespaces(fmt.Sprintf("proj-%d-", projectID))
+	namespaces, err := k8s.ListProjectNamespaces(ctx, projectID)
 	if err != nil {
 		return 0, err
 	}
@@ -261,23 +423,21 @@ func (s *K8sService) CountProjectGPUUsage(ctx context.Context, projectID uint) (
 	return totalGPU, nil
 }
 
-// GetProjectPVCNames returns PVC names within a namespace that are tagged as project storage.
-// Falls back to all PVCs if no labeled ones found for backward compatibility.
+// GetProjectPVCNames returns PVC names within a namespace that are tagged as project storage,
+// ordered by storage name. Falls back to all PVCs if no labeled ones found for backward compatibility.
 func (s *K8sService) GetProjectPVCNames(ctx context.Context, namespace string) ([]string, error) {
 	if k8s.Clientset == nil {
 		return []string{}, nil
 	}
 
 	// First try to find PVCs with the project storage label
-	list, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: "storage-type=project",
-	})
+	pvcs, err := k8s.ListProjectStoragePVCs(ctx, namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	names := make([]string, 0, len(list.Items))
-	for _, pvc := range list.Items {
+	names := make([]string, 0, len(pvcs))
+	for _, pvc := range pvcs {
 		names = append(names, pvc.Name)
 	}
 
@@ -289,44 +449,104 @@ func (s *K8sService) GetProjectPVCNames(ctx context.Context, namespace string) (
 			for _, pvc := range fallback.Items {
 				names = append(names, pvc.Name)
 			}
+			sort.Strings(names)
 		}
 	}
 
 	return names, nil
 }
 
+// ListPVCsByProject returns the PVCs in the namespace the user's instances of the project run
+// in, the same namespace CreateInstance deploys to. A namespace that does not exist yet, because
+// the user never started anything, has no PVCs.
+func (s *K8sService) ListPVCsByProject(ctx context.Context, p *project.Project, username string) ([]job.PVC, error) {
+	out := []job.PVC{}
+	if k8s.Clientset == nil {
+		return out, nil
+	}
+	ns := WorkloadNamespace(p, username)
+	list, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(ns).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range list.Items {
+		capacity := ""
+		if q, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+			capacity = q.String()
+		}
+		size := ""
+		if q, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			size = q.String()
+		}
+		out = append(out, job.PVC{
+			Name:      pvc.Name,
+			Namespace: pvc.Namespace,
+			Capacity:  capacity,
+			Status:    string(pvc.Status.Phase),
+			PVCName:   pvc.Name,
+			Size:      size,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
 // StartFileBrowser provisions a FileBrowser instance with specific access permissions.
-// It mounts all provided PVCs under /srv/<pvcName>.
-func (s *K8sService) StartFileBrowser(ctx context.Context, ns string, pvcNames []string, readOnly bool, baseURL string) (string, error) {
+// It mounts all provided PVCs under /srv/<storageName>. When the pod is not Ready within
+// config.StorageReadyTimeout the error wraps ErrStorageNotReady and a *k8s.PodNotReadyError.
+// forceRecreate restarts a running instance even when it already mounts pvcNames.
+func (s *K8sService) StartFileBrowser(ctx context.Context, projectID uint, ns string, pvcNames []string, readOnly bool, baseURL string, forceRecreate bool) (string, error) {
 	if len(pvcNames) == 0 {
 		return "", fmt.Errorf("no PVCs available to start filebrowser")
 	}
 
 	// 1. Create Pod with dynamic read-only configuration
-	_, err := k8s.CreateFileBrowserPod(ctx, ns, pvcNames, readOnly, baseURL)
+	owner := k8s.Ownership{ProjectID: projectID}.Labels()
+	podName, err := k8s.CreateFileBrowserPod(ctx, ns, pvcNames, readOnly, baseURL, owner, forceRecreate)
 	if err != nil {
 		return "", err
 	}
 
 	// 2. Create Service
-	nodePort, err := k8s.CreateFileBrowserService(ctx, ns)
+	nodePort, err := k8s.CreateFileBrowserService(ctx, ns, owner)
 	if err != nil {
 		return "", err
 	}
 
+	// 3. Wait for the pod, so a volume that cannot attach is reported instead of a dead link
+	if err := k8s.WaitForPodReady(ctx, ns, podName, config.StorageReadyTimeout); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrStorageNotReady, err)
+	}
+
 	return nodePort, nil
 }
 
-// EnsureProjectHub creates/ensures the project-level storage infrastructure.
+// EnsureProjectHub creates/ensures the project-level storage infrastructure. Concurrent calls
+// for the same project run one at a time.
 func (s *K8sService) EnsureProjectHub(p *project.Project) error {
-	ns := k8s.GenerateSafeResourceName("project", p.ProjectName, p.PID)
-	pvcName := fmt.Sprintf("project-%d-disk", p.PID)
+	ns := ProjectStorageNamespace(p)
+	return s.repos.WithNamespaceLock(ns, func() error {
+		return ensureProjectHub(p, ns)
+	})
+}
 
-	if err := k8s.CreateNamespace(ns); err != nil {
+func ensureProjectHub(p *project.Project, ns string) error {
+	pvcName := k8s.ProjectStoragePVCName(p.PID, k8s.DefaultProjectStorage)
+
+	nsLabels := k8s.MergeLabels(map[string]string{
+		"managed-by":   "nthucscc",
+		"type":         "project-space",
+		"project-id":   fmt.Sprintf("%d", p.PID),
+		"project-name": p.ProjectName,
+	}, k8s.Ownership{ProjectID: p.PID}.Labels())
+	if err := k8s.CreateNamespace(ns, nsLabels); err != nil {
 		log.Printf("[ProjectHub] Namespace check: %v", err)
 	}
 
-	if err := k8s.CreateHubPVC(ns, pvcName, config.DefaultStorageClassName, config.ProjectPVSize); err != nil {
+	if err := k8s.CreateHubPVC(ns, pvcName, config.DefaultStorageClassName, config.StringSetting(config.SettingProjectPVSize)); err != nil {
 		return fmt.Errorf("failed to ensure project pvc: %w", err)
 	}
 
@@ -341,45 +561,62 @@ func (s *K8sService) StopFileBrowser(ctx context.Context, ns string) error {
 	return k8s.DeleteFileBrowserResources(ctx, ns)
 }
 
-func (s *K8sService) CheckUserStorageExists(ctx context.Context, username string) (bool, error) {
-	safeUser := strings.ToLower(username)
-	nsName := fmt.Sprintf("user-%s-storage", safeUser)
-
-	return k8s.CheckNamespaceExists(nsName)
+// GetUserStorageHealth reports whether the user's storage hub can serve project instances.
+func (s *K8sService) GetUserStorageHealth(ctx context.Context, username string) (*k8s.HubHealth, error) {
+	nsName, pvcName := userHubNames(s.UserStorageID(username))
+	return k8s.GetUserStorageHealth(ctx, nsName, pvcName)
 }
 
 // InitializeUserStorageHub orchestrates the creation of a per-user storage infrastructure.
-func (s *K8sService) InitializeUserStorageHub(username string) error {
-	safeUser := strings.ToLower(username)
-	if reg, err := regexp.Compile("[^a-z0-9-]+"); err == nil {
-		safeUser = reg.ReplaceAllString(safeUser, "-")
-	}
-
-	nsName := fmt.Sprintf("user-%s-storage", safeUser)
-	pvcName := fmt.Sprintf("user-%s-disk", safeUser)
+// The hub is sized by the largest entitlement across the user's groups; components that already
+// exist are left as is, so a re-run after a partial failure only creates the missing ones.
+// The returned error joins the failures of the components that could not be created. The hub is
+// named after the user's stored storage identifier, which is recorded here on first use.
+func (s *K8sService) InitializeUserStorageHub(ctx context.Context, username string) (*k8s.HubStatus, error) {
+	nsName, pvcName := userHubNames(s.UserStorageID(username))
 
 	log.Printf("[StorageHub] Initializing for user: %s (ns: %s)", username, nsName)
 
-	if err := k8s.CreateNamespace(nsName); err != nil {
-		log.Printf("[StorageHub] Namespace creation warning: %v", err)
+	ent := StorageEntitlement{HubSize: config.StringSetting(config.SettingUserPVSize), StorageClassName: config.DefaultStorageClassName}
+	if u, err := s.repos.User.GetUserByUsername(username); err == nil {
+		if resolved, err := s.ResolveStorageEntitlement(u.UID); err == nil {
+			ent = resolved
+		} else {
+			log.Printf("[StorageHub] Falling back to default size for %s: %v", username, err)
+		}
 	}
 
-	if err := k8s.CreateHubPVC(nsName, pvcName, config.DefaultStorageClassName, config.UserPVSize); err != nil {
-		return fmt.Errorf("failed to create hub pvc: %w", err)
+	// A concurrent initialization, e.g. a double click, waits and then finds the hub created
+	var status *k8s.HubStatus
+	if err := s.repos.WithNamespaceLock(nsName, func() error {
+		status = k8s.EnsureUserStorageHub(ctx, k8s.UserHubSpec{
+			Namespace:        nsName,
+			PVCName:          pvcName,
+			StorageClassName: ent.StorageClassName,
+			Size:             ent.HubSize,
+			NamespaceLabels:  k8s.MergeLabels(map[string]string{"managed-by": "nthucscc", "type": "user-storage"}, k8s.Ownership{}.Labels()),
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := status.Err(); err != nil {
+		log.Printf("[StorageHub] Partially initialized for %s: %v", username, err)
+		return status, err
 	}
-
-	// if err := utils.CreateStorageHub(nsName, pvcName); err != nil {
-	// 	return fmt.Errorf("failed to create storage hub: %w", err)
-	// }
 
 	log.Printf("[Storage] Successfully initialized resources for %s", username)
-	return nil
+	return status, nil
+}
+
+// GetUserStorageHubStatus reports the state of each component of a user's storage hub.
+func (s *K8sService) GetUserStorageHubStatus(ctx context.Context, username string) *k8s.HubStatus {
+	nsName, pvcName := userHubNames(s.UserStorageID(username))
+	return k8s.GetUserStorageHubStatus(ctx, nsName, pvcName)
 }
 
 func (s *K8sService) ExpandUserStorageHub(username, newSize string) error {
-	safeUser := strings.ToLower(username)
-	nsName := fmt.Sprintf("user-%s-storage", safeUser)
-	pvcName := fmt.Sprintf("user-%s-disk", safeUser)
+	nsName, pvcName := userHubNames(s.UserStorageID(username))
 
 	return k8s.ExpandPVC(nsName, pvcName, newSize)
 }
@@ -387,9 +624,7 @@ func (s *K8sService) ExpandUserStorageHub(username, newSize string) error {
 // DeleteUserStorageHub completely removes a user's storage infrastructure.
 // It deletes the dedicated namespace, which automatically cleans up the PVC, NFS Server, and Services inside it.
 func (s *K8sService) DeleteUserStorageHub(ctx context.Context, username string) error {
-	safeUser := strings.ToLower(username)
-
-	nsName := fmt.Sprintf("user-%s-storage", safeUser)
+	nsName, _ := userHubNames(s.UserStorageID(username))
 
 	if err := k8s.DeleteNamespace(nsName); err != nil {
 		return fmt.Errorf("failed to delete user storage namespace '%s': %w", nsName, err)
@@ -400,8 +635,7 @@ func (s *K8sService) DeleteUserStorageHub(ctx context.Context, username string)
 
 func (s *K8sService) OpenUserGlobalFileBrowser(ctx context.Context, username string) (string, error) {
 
-	safeUser := strings.ToLower(username)
-	port, err := utils.StartUserHubBrowser(ctx, safeUser)
+	port, err := utils.StartUserHubBrowser(ctx, s.UserStorageID(username))
 	if err != nil {
 		return "", err
 	}
@@ -410,34 +644,56 @@ func (s *K8sService) OpenUserGlobalFileBrowser(ctx context.Context, username str
 }
 
 func (s *K8sService) StopUserGlobalFileBrowser(ctx context.Context, username string) error {
-	safeUser := strings.ToLower(username)
-	// safeUser = regexp.MustCompile("[^a-z0-9-]").ReplaceAllString(safeUser, "-")
-	return utils.StopUserHubBrowser(ctx, safeUser)
+	return utils.StopUserHubBrowser(ctx, s.UserStorageID(username))
 }
 
+// CreateProjectPVC provisions a named project storage. An empty req.Name creates the default storage.
 func (s *K8sService) CreateProjectPVC(ctx context.Context, req job.VolumeSpec) (*corev1.PersistentVolumeClaim, error) {
+	storageName, err := normalizeStorageName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStorageRequest(req.StorageClassName, req.Size); err != nil {
+		return nil, err
+	}
+	// Omitted capacity and class fall back to the defaults of the project's group
+	var scName string
+	if req.Size == "" || req.StorageClassName == "" {
+		defaultSize, defaultClass := s.projectStorageDefaults(req.ProjectID)
+		if req.Size == "" {
+			req.Size = defaultSize
+		}
+		scName = defaultClass
+	}
+	if req.StorageClassName != "" {
+		scName = req.StorageClassName
+	}
+	accessMode, err := resolveStorageAccessMode(scName, req.AccessMode)
+	if err != nil {
+		return nil, err
+	}
+
 	if k8s.Clientset == nil {
 		return &corev1.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      "mock-pvc",
-				Namespace: k8s.GenerateSafeResourceName("project", req.ProjectName, req.ProjectID),
+				Name:      k8s.ProjectStoragePVCName(req.ProjectID, storageName),
+				Namespace: volumeSpecNamespace(req),
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{accessMode},
 			},
 		}, nil
 	}
 
-	ns := k8s.GenerateSafeResourceName("project", req.ProjectName, req.ProjectID)
-	// [TODO] Enable multiple PVCs per project
-	pvcName := fmt.Sprintf("project-%d-disk", req.ProjectID)
-	if pvcName == "" {
-		pvcName = fmt.Sprintf("pvc-%s", ns)
-	}
+	ns := volumeSpecNamespace(req)
+	pvcName := k8s.ProjectStoragePVCName(req.ProjectID, storageName)
 
-	nsLabels := map[string]string{
+	nsLabels := k8s.MergeLabels(map[string]string{
 		"managed-by":   "nthucscc",
 		"type":         "project-space",
 		"project-id":   fmt.Sprintf("%d", req.ProjectID),
 		"project-name": req.ProjectName,
-	}
+	}, k8s.Ownership{ProjectID: req.ProjectID}.Labels())
 
 	if err := s.ensureNamespaceWithLabels(ctx, ns, nsLabels); err != nil {
 		return nil, fmt.Errorf("failed to ensure namespace: %v", err)
@@ -448,15 +704,15 @@ func (s *K8sService) CreateProjectPVC(ctx context.Context, req job.VolumeSpec) (
 		return nil, fmt.Errorf("invalid capacity: %v", err)
 	}
 
-	pvcLabels := map[string]string{
+	pvcLabels := k8s.MergeLabels(map[string]string{
 		"app.kubernetes.io/name":       "filebrowser-storage",
 		"app.kubernetes.io/managed-by": "nthu-cscc",
 		"storage-type":                 "project",
 		"project-id":                   fmt.Sprintf("%d", req.ProjectID),
 		"project-name":                 req.ProjectName,
-	}
+		k8s.ProjectStorageNameLabel:    storageName,
+	}, k8s.Ownership{ProjectID: req.ProjectID}.Labels())
 
-	scName := config.DefaultStorageClassName
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      pvcName,
@@ -464,7 +720,7 @@ func (s *K8sService) CreateProjectPVC(ctx context.Context, req job.VolumeSpec) (
 			Labels:    pvcLabels,
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			AccessModes: []corev1.PersistentVolumeAccessMode{accessMode},
 			Resources: corev1.VolumeResourceRequirements{
 				Requests: corev1.ResourceList{
 					corev1.ResourceStorage: qty,
@@ -478,6 +734,7 @@ func (s *K8sService) CreateProjectPVC(ctx context.Context, req job.VolumeSpec) (
 	if err != nil {
 		return nil, fmt.Errorf("failed to create pvc: %w", err)
 	}
+	projectStorageCache.invalidate()
 
 	// if err := k8s.CreateStorageHub(ns, pvcName); err != nil {
 	// 	return nil, fmt.Errorf("failed to create storage hub: %w", err)
@@ -486,13 +743,94 @@ func (s *K8sService) CreateProjectPVC(ctx context.Context, req job.VolumeSpec) (
 	return result, nil
 }
 
+// normalizeStorageName converts a requested storage name into the label/PVC suffix form.
+func normalizeStorageName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return k8s.DefaultProjectStorage, nil
+	}
+	safe := k8s.ToSafeK8sName(name)
+	if len(safe) > maxStorageNameLength {
+		return "", fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidStorageName, name, maxStorageNameLength)
+	}
+	return safe, nil
+}
+
+// resolveStorageAccessMode validates the requested access mode against the storage class.
+// An empty request defaults to ReadWriteMany when the class supports it, otherwise ReadWriteOnce.
+func resolveStorageAccessMode(storageClass string, requested string) (corev1.PersistentVolumeAccessMode, error) {
+	supportsRWX := config.StorageClassRWXSupport[storageClass]
+
+	switch strings.ToLower(strings.TrimSpace(requested)) {
+	case "":
+		if supportsRWX {
+			return corev1.ReadWriteMany, nil
+		}
+		return corev1.ReadWriteOnce, nil
+	case "rwo", "readwriteonce":
+		return corev1.ReadWriteOnce, nil
+	case "rwx", "readwritemany":
+		if !supportsRWX {
+			return "", fmt.Errorf("%w: storage class %s does not support ReadWriteMany", ErrUnsupportedAccessMode, storageClass)
+		}
+		return corev1.ReadWriteMany, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedAccessMode, requested)
+	}
+}
+
+// volumeSpecNamespace is the project storage namespace of a volume request: the stored
+// namespace when the caller set it, otherwise the one derived from the project name.
+func volumeSpecNamespace(req job.VolumeSpec) string {
+	return ProjectStorageNamespace(&project.Project{PID: req.ProjectID, ProjectName: req.ProjectName, StorageNamespace: req.Namespace})
+}
+
 // DeleteProjectAllPVC removes the entire project namespace, cleaning up all PVCs and resources inside.
-func (s *K8sService) DeleteProjectAllPVC(ctx context.Context, projectName string, projectID uint) error {
-	ns := k8s.GenerateSafeResourceName("project", projectName, projectID)
+func (s *K8sService) DeleteProjectAllPVC(ctx context.Context, p *project.Project) error {
+	ns := ProjectStorageNamespace(p)
+	defer projectStorageCache.invalidate()
 	// Return the error to the caller instead of ignoring it
 	return k8s.DeleteNamespace(ns)
 }
 
+// DeleteProjectStorage removes a single named storage. The project namespace is only
+// deleted once no other project storage remains in it.
+func (s *K8sService) DeleteProjectStorage(ctx context.Context, p *project.Project, storageName string) error {
+	storageName, err := normalizeStorageName(storageName)
+	if err != nil {
+		return err
+	}
+	if k8s.Clientset == nil {
+		return nil
+	}
+	ns := ProjectStorageNamespace(p)
+	pvcName := k8s.ProjectStoragePVCName(p.PID, storageName)
+
+	// The FileBrowser pod mounts every storage; drop it so the PVC can be released
+	if err := k8s.DeleteFileBrowserResources(ctx, ns); err != nil {
+		log.Printf("[ProjectStorage] failed to stop filebrowser in %s: %v", ns, err)
+	}
+
+	if err := k8s.Clientset.CoreV1().PersistentVolumeClaims(ns).Delete(ctx, pvcName, metav1.DeleteOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrStorageNotFound, storageName)
+		}
+		return fmt.Errorf("failed to delete pvc %s: %w", pvcName, err)
+	}
+	projectStorageCache.invalidate()
+
+	remaining, err := k8s.ListProjectStoragePVCs(ctx, ns)
+	if err != nil {
+		return fmt.Errorf("failed to list remaining storages: %w", err)
+	}
+	for _, pvc := range remaining {
+		if pvc.Name != pvcName && pvc.DeletionTimestamp == nil {
+			return nil
+		}
+	}
+	return k8s.DeleteNamespace(ns)
+}
+
 // ensureNamespaceWithLabels checks if a namespace exists, creates it if not.
 func (s *K8sService) ensureNamespaceWithLabels(ctx context.Context, name string, labels map[string]string) error {
 	_, err := k8s.Clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
@@ -512,13 +850,36 @@ func (s *K8sService) ensureNamespaceWithLabels(ctx context.Context, name string,
 
 // ListAllProjectStorages retrieves all project-related PVCs across the cluster.
 func (s *K8sService) ListAllProjectStorages(ctx context.Context) ([]job.VolumeSpec, error) {
+	return s.listProjectStorages(ctx, k8s.ProjectStorageSelector)
+}
+
+// ListProjectStoragesByIDs retrieves the storages of the given projects only, so callers that
+// need a few projects do not list every project PVC in the cluster.
+func (s *K8sService) ListProjectStoragesByIDs(ctx context.Context, projectIDs []uint) ([]job.VolumeSpec, error) {
+	if len(projectIDs) == 0 {
+		return []job.VolumeSpec{}, nil
+	}
+	ids := make([]string, 0, len(projectIDs))
+	for _, id := range projectIDs {
+		ids = append(ids, strconv.FormatUint(uint64(id), 10))
+	}
+	sort.Strings(ids)
+	return s.listProjectStorages(ctx, fmt.Sprintf("%s,%s in (%s)", k8s.ProjectStorageSelector, k8s.LabelProjectID, strings.Join(ids, ",")))
+}
+
+// listProjectStorages lists project PVCs matching selector. Results are cached per selector for
+// a short TTL; storage create and delete operations invalidate the cache.
+func (s *K8sService) listProjectStorages(ctx context.Context, selector string) ([]job.VolumeSpec, error) {
 	if k8s.Clientset == nil {
 		return []job.VolumeSpec{}, nil
 	}
+	if cached, ok := projectStorageCache.get(selector); ok {
+		return cached, nil
+	}
 
 	// Server-side filtering using labels
 	listOpts := metav1.ListOptions{
-		LabelSelector: "storage-type=project,app.kubernetes.io/managed-by=nthu-cscc",
+		LabelSelector: selector,
 	}
 
 	pvcs, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, listOpts)
@@ -526,21 +887,16 @@ func (s *K8sService) ListAllProjectStorages(ctx context.Context) ([]job.VolumeSp
 		return nil, err
 	}
 
-	var result []job.VolumeSpec
+	result := make([]job.VolumeSpec, 0, len(pvcs.Items))
 
 	for _, pvc := range pvcs.Items {
-		projectIDStr := pvc.Labels["project-id"]
-		projectName := pvc.Labels["project-name"]
-
-		if projectIDStr == "" {
+		projectID, ok := k8s.ProjectIDFromLabels(pvc.Labels)
+		if !ok {
 			continue
 		}
+		projectName := pvc.Labels["project-name"]
 
-		projectID, _ := strconv.ParseUint(projectIDStr, 10, 32)
-
-		// Use K8s native scaling to get accurate GB value
 		qty := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
-		capacityGB := int(qty.ScaledValue(resource.Giga))
 
 		accessMode := ""
 		if len(pvc.Spec.AccessModes) > 0 {
@@ -548,19 +904,50 @@ func (s *K8sService) ListAllProjectStorages(ctx context.Context) ([]job.VolumeSp
 		}
 
 		result = append(result, job.VolumeSpec{
-			ID:          uint(projectID),
-			ProjectID:   uint(projectID),
-			Name:        pvc.Name,
-			PVCName:     pvc.Name,
-			ProjectName: projectName,
-			Namespace:   pvc.Namespace,
-			Capacity:    capacityGB,
-			Size:        fmt.Sprintf("%dGi", capacityGB),
-			Status:      string(pvc.Status.Phase),
-			AccessMode:  accessMode,
-			CreatedAt:   pvc.CreationTimestamp.Time,
+			ID:            uint(projectID),
+			ProjectID:     uint(projectID),
+			Name:          pvc.Name,
+			StorageName:   k8s.ProjectStorageName(&pvc),
+			PVCName:       pvc.Name,
+			ProjectName:   projectName,
+			Namespace:     pvc.Namespace,
+			Capacity:      capacityGi(qty),
+			CapacityBytes: qty.Value(),
+			Size:          qty.String(),
+			Status:        string(pvc.Status.Phase),
+			AccessMode:    accessMode,
+			CreatedAt:     pvc.CreationTimestamp.Time,
 		})
 	}
 
+	// Keep storages of the same project together
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].ProjectID != result[j].ProjectID {
+			return result[i].ProjectID < result[j].ProjectID
+		}
+		return result[i].StorageName < result[j].StorageName
+	})
+
+	projectStorageCache.set(selector, result)
 	return result, nil
 }
+
+// capacityGi returns the capacity in whole Gi, rounded up so a 1000Mi PVC reports 1 and not 0.
+func capacityGi(qty resource.Quantity) int {
+	const gi = 1 << 30
+	return int((qty.Value() + gi - 1) / gi)
+}
+
+// BackfillNamespaceLabels labels legacy project namespaces so label-selector lookups can find them.
+func (s *K8sService) BackfillNamespaceLabels(ctx context.Context) ([]string, error) {
+	return k8s.BackfillNamespaceLabels(ctx)
+}
+
+// BackfillOwnershipLabels adds the ownership labels to platform objects created before they existed.
+func (s *K8sService) BackfillOwnershipLabels(ctx context.Context) ([]string, error) {
+	updated, err := k8s.BackfillOwnershipLabels(ctx)
+	if len(updated) > 0 {
+		projectStorageCache.invalidate()
+	}
+	return updated, err
+}
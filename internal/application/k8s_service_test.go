@@ -0,0 +1,236 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	"github.com/linskybing/platform-go/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCreateProjectPVCAccessMode(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	k8s.Clientset = k8sfake.NewSimpleClientset()
+
+	svc := &K8sService{}
+	ctx := context.Background()
+
+	pvc, err := svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 1, ProjectName: "demo", Size: "1Gi", StorageClassName: "longhorn"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pvc.Spec.AccessModes[0] != corev1.ReadWriteOnce {
+		t.Fatalf("expected longhorn to default to RWO, got %s", pvc.Spec.AccessModes[0])
+	}
+	if pvc.Labels[k8s.LabelManagedBy] != k8s.ManagedByPlatform || pvc.Labels[k8s.LabelProjectID] != "1" {
+		t.Fatalf("expected ownership labels on the pvc, got %v", pvc.Labels)
+	}
+
+	pvc, err = svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 2, ProjectName: "shared", Size: "1Gi", StorageClassName: "nfs-client"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pvc.Spec.AccessModes[0] != corev1.ReadWriteMany {
+		t.Fatalf("expected nfs-client to default to RWX, got %s", pvc.Spec.AccessModes[0])
+	}
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != "nfs-client" {
+		t.Fatalf("expected requested storage class to be used")
+	}
+
+	_, err = svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 3, ProjectName: "bad", Size: "1Gi", StorageClassName: "longhorn", AccessMode: "ReadWriteMany"})
+	if !errors.Is(err, ErrUnsupportedAccessMode) {
+		t.Fatalf("expected ErrUnsupportedAccessMode, got %v", err)
+	}
+
+	_, err = svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 4, ProjectName: "odd", Size: "1Gi", AccessMode: "ReadOnlyMany"})
+	if !errors.Is(err, ErrUnsupportedAccessMode) {
+		t.Fatalf("expected unknown access mode to be rejected, got %v", err)
+	}
+}
+
+func TestProjectWithTwoNamedStorages(t *testing.T) {
+	orig, origWait := k8s.Clientset, config.StorageReadyTimeout
+	defer func() { k8s.Clientset, config.StorageReadyTimeout = orig, origWait }()
+	k8s.Clientset = k8sfake.NewSimpleClientset()
+	// The fake cluster never runs the FileBrowser pod
+	config.StorageReadyTimeout = 0
+
+	svc := &K8sService{}
+	ctx := context.Background()
+	ns := k8s.GenerateSafeResourceName("project", "demo", 5)
+
+	for _, name := range []string{"models", "Datasets"} {
+		if _, err := svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 5, ProjectName: "demo", Name: name, Size: "1Gi"}); err != nil {
+			t.Fatalf("failed to create storage %s: %v", name, err)
+		}
+	}
+	if _, err := svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 5, ProjectName: "demo", Name: "models", Size: "1Gi"}); err == nil {
+		t.Fatalf("expected duplicate storage name to be rejected")
+	}
+
+	storages, err := svc.ListAllProjectStorages(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(storages) != 2 || storages[0].StorageName != "datasets" || storages[1].StorageName != "models" {
+		t.Fatalf("expected storages grouped by name, got %+v", storages)
+	}
+	if storages[0].PVCName != "project-5-datasets" {
+		t.Fatalf("unexpected pvc name %s", storages[0].PVCName)
+	}
+
+	pvcNames, err := svc.GetProjectPVCNames(ctx, ns)
+	if err != nil || len(pvcNames) != 2 {
+		t.Fatalf("expected both PVCs, got %v (%v)", pvcNames, err)
+	}
+	if _, err := svc.StartFileBrowser(ctx, 5, ns, pvcNames, false, "/fb", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod, err := k8s.Clientset.CoreV1().Pods(ns).Get(ctx, "filebrowser-project", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("filebrowser pod missing: %v", err)
+	}
+	mounts := pod.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 2 || mounts[0].MountPath != "/srv/datasets" || mounts[1].MountPath != "/srv/models" {
+		t.Fatalf("expected one folder per storage, got %+v", mounts)
+	}
+
+	// Deleting one storage keeps the namespace and the other storage
+	if err := svc.DeleteProjectStorage(ctx, &project.Project{PID: 5, ProjectName: "demo"}, "models"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := k8s.Clientset.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{}); err != nil {
+		t.Fatalf("namespace must survive while storages remain: %v", err)
+	}
+	if _, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(ns).Get(ctx, "project-5-datasets", metav1.GetOptions{}); err != nil {
+		t.Fatalf("remaining storage must be kept: %v", err)
+	}
+	if err := svc.DeleteProjectStorage(ctx, &project.Project{PID: 5, ProjectName: "demo"}, "models"); !errors.Is(err, ErrStorageNotFound) {
+		t.Fatalf("expected ErrStorageNotFound, got %v", err)
+	}
+
+	// Removing the last storage drops the namespace
+	if err := svc.DeleteProjectStorage(ctx, &project.Project{PID: 5, ProjectName: "demo"}, "datasets"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := k8s.Clientset.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected namespace to be deleted, got %v", err)
+	}
+}
+
+func TestProjectStorageTemplateVariable(t *testing.T) {
+	svc := &ConfigFileService{}
+	values := svc.buildTemplateValues(&configfile.ConfigFile{ProjectID: 5}, "proj-5-alice", "user-alice-disk", "project-5-disk",
+		map[string]string{"disk": "project-5-disk", "datasets": "project-5-datasets"}, &types.Claims{Username: "alice"})
+
+	got := utils.ReplacePlaceholders(`{"claimName":"{{projectStorage:datasets}}","default":"{{projectVolume}}"}`, values)
+	if got != `{"claimName":"project-5-datasets","default":"project-5-disk"}` {
+		t.Fatalf("unexpected rendering %s", got)
+	}
+}
+
+func TestStartFileBrowserReportsWhyThePodIsNotReady(t *testing.T) {
+	orig, origWait := k8s.Clientset, config.StorageReadyTimeout
+	defer func() { k8s.Clientset, config.StorageReadyTimeout = orig, origWait }()
+	ns := "project-demo-5"
+	k8s.Clientset = k8sfake.NewSimpleClientset(&corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "filebrowser-project.1", Namespace: ns},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "filebrowser-project", Namespace: ns},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "FailedAttachVolume",
+		Message:        "Multi-Attach error for volume project-5-disk",
+	})
+	config.StorageReadyTimeout = 20 * time.Millisecond
+
+	svc := &K8sService{}
+	_, err := svc.StartFileBrowser(context.Background(), 5, ns, []string{"project-5-disk"}, false, "/fb", false)
+	if !errors.Is(err, ErrStorageNotReady) {
+		t.Fatalf("expected ErrStorageNotReady, got %v", err)
+	}
+	var notReady *k8s.PodNotReadyError
+	if !errors.As(err, &notReady) || len(notReady.Reasons) != 1 || notReady.Reasons[0] != "FailedAttachVolume: Multi-Attach error for volume project-5-disk" {
+		t.Fatalf("expected the event to explain the failure, got %v", err)
+	}
+}
+
+func TestConcurrentUserHubInitializationCreatesOnce(t *testing.T) {
+	orig, origWait := k8s.Clientset, config.StorageReadyTimeout
+	defer func() { k8s.Clientset, config.StorageReadyTimeout = orig, origWait }()
+	config.StorageReadyTimeout = 0
+	client := k8sfake.NewSimpleClientset()
+	// Slow lookups widen the window in which both calls would find nothing and create everything
+	client.PrependReactor("get", "*", func(k8stesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(10 * time.Millisecond)
+		return false, nil, nil
+	})
+	k8s.Clientset = client
+	svc := newStorageEntitlementService(t)
+
+	var wg sync.WaitGroup
+	statuses := make([]*k8s.HubStatus, 2)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], _ = svc.InitializeUserStorageHub(context.Background(), "alice")
+		}()
+	}
+	wg.Wait()
+
+	creates := map[string]int{}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "create" {
+			creates[action.GetResource().Resource]++
+		}
+	}
+	for _, res := range []string{"namespaces", "persistentvolumeclaims", "deployments", "services"} {
+		if creates[res] != 1 {
+			t.Fatalf("expected exactly one %s to be created, got %v", res, creates)
+		}
+	}
+	for _, st := range statuses {
+		if st == nil || !st.Ready {
+			t.Fatalf("expected both calls to report a ready hub, got %+v", st)
+		}
+	}
+}
+
+func TestListPVCsByProjectUsesSanitizedNamespace(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "proj-3-alice-smith"}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "proj-3-bob"}},
+	)
+	svc := &K8sService{}
+	p := &project.Project{PID: 3, ProjectName: "demo"}
+
+	pvcs, err := svc.ListPVCsByProject(context.Background(), p, "Alice.Smith")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pvcs) != 1 || pvcs[0].Name != "data" || pvcs[0].Namespace != "proj-3-alice-smith" {
+		t.Fatalf("expected the PVC of the sanitized namespace, got %+v", pvcs)
+	}
+
+	// A member who never started anything has no namespace yet
+	pvcs, err = svc.ListPVCsByProject(context.Background(), p, "carol")
+	if err != nil || pvcs == nil || len(pvcs) != 0 {
+		t.Fatalf("expected an empty list, got %+v (%v)", pvcs, err)
+	}
+}
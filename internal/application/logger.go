@@ -0,0 +1,5 @@
+package application
+
+import "github.com/linskybing/platform-go/pkg/logger"
+
+var servicesLog = logger.For(logger.ComponentServices)
@@ -0,0 +1,95 @@
+package application
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/maintenance"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// defaultMaintenanceMessage is shown when maintenance was turned on without a message.
+const defaultMaintenanceMessage = "The platform is under maintenance, please try again later"
+
+// MaintenanceService reads and toggles the platform maintenance flag. The flag lives in the
+// database so every process sees it; each process caches it for config.MaintenanceCacheTTL.
+type MaintenanceService struct {
+	Repos *repository.Repos
+	now   func() time.Time
+
+	mu        sync.Mutex
+	cached    *maintenance.Maintenance
+	fetchedAt time.Time
+}
+
+func NewMaintenanceService(repos *repository.Repos) *MaintenanceService {
+	return &MaintenanceService{Repos: repos, now: time.Now}
+}
+
+// Current returns the maintenance flag, read again once the cached copy is older than the TTL.
+// When the read fails the last known flag is kept, so a database hiccup does not lift maintenance.
+func (s *MaintenanceService) Current() maintenance.Maintenance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached != nil && s.now().Sub(s.fetchedAt) < config.MaintenanceCacheTTL {
+		return *s.cached
+	}
+	m, err := s.Repos.Maintenance.Get()
+	if err != nil {
+		log.Printf("Failed to read the maintenance flag: %v", err)
+		if s.cached == nil {
+			return maintenance.Maintenance{}
+		}
+		return *s.cached
+	}
+	s.cached, s.fetchedAt = m, s.now()
+	return *m
+}
+
+// Active reports whether maintenance is in effect.
+func (s *MaintenanceService) Active() bool {
+	m := s.Current()
+	return m.InEffect(s.now())
+}
+
+// Status is the public view of the flag for the frontend banner.
+func (s *MaintenanceService) Status() maintenance.StatusDTO {
+	m := s.Current()
+	if !m.InEffect(s.now()) {
+		return maintenance.StatusDTO{}
+	}
+	return maintenance.StatusDTO{Maintenance: true, Message: m.Message, EndsAt: m.EndsAt}
+}
+
+// Set turns maintenance on or off. The change is visible in this process at once and in the
+// others within the cache TTL.
+func (s *MaintenanceService) Set(c *gin.Context, adminID uint, input maintenance.SetMaintenanceInput) (*maintenance.Maintenance, error) {
+	old, err := s.Repos.Maintenance.Get()
+	if err != nil {
+		return nil, err
+	}
+	m := &maintenance.Maintenance{
+		Active:    *input.Active,
+		Message:   strings.TrimSpace(input.Message),
+		EndsAt:    input.EndsAt,
+		UpdatedBy: adminID,
+	}
+	if m.Active && m.Message == "" {
+		m.Message = defaultMaintenanceMessage
+	}
+	if err := s.Repos.Maintenance.Save(m); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cached, s.fetchedAt = m, s.now()
+	s.mu.Unlock()
+
+	utils.LogAuditWithConsole(c, "update", "maintenance", "platform", *old, *m, m.Message, s.Repos.Audit)
+	return m, nil
+}
@@ -0,0 +1,52 @@
+package application
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/maintenance"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+func TestMaintenanceFlagSharedThroughCache(t *testing.T) {
+	db := dbtest.Open(t, &maintenance.Maintenance{})
+	origLog := utils.LogAuditWithConsole
+	t.Cleanup(func() { utils.LogAuditWithConsole = origLog })
+	utils.LogAuditWithConsole = func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo) {}
+
+	// api toggles the flag; scheduler is the other process reading the same table
+	api := NewMaintenanceService(repository.NewRepositories(db))
+	scheduler := NewMaintenanceService(repository.NewRepositories(db))
+	now := time.Now()
+	scheduler.now = func() time.Time { return now }
+
+	if scheduler.Active() {
+		t.Fatalf("maintenance should be off when never set")
+	}
+
+	on := true
+	ends := now.Add(time.Hour)
+	if _, err := api.Set(nil, 1, maintenance.SetMaintenanceInput{Active: &on, EndsAt: &ends}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if st := api.Status(); !st.Maintenance || st.Message != defaultMaintenanceMessage {
+		t.Fatalf("expected the toggling process to see maintenance at once, got %+v", st)
+	}
+	if scheduler.Active() {
+		t.Fatalf("the other process should keep its cached flag until the TTL expires")
+	}
+
+	now = now.Add(config.MaintenanceCacheTTL)
+	if !scheduler.Active() {
+		t.Fatalf("expected the other process to pick up maintenance after the TTL")
+	}
+
+	now = ends
+	if scheduler.Active() {
+		t.Fatalf("maintenance should lift at its scheduled end")
+	}
+}
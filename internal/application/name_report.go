@@ -0,0 +1,89 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+// NameIssue is a stored name that would not be accepted, or not be stored as is, today.
+type NameIssue struct {
+	Entity  string `json:"entity"`
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	Problem string `json:"problem"`
+}
+
+// ReportInvalidNames checks the project names and config file names stored before they were
+// validated, and the usernames whose K8s name changed when names that sanitize to too little got
+// a slug. Nothing is changed: the rows are reported so admins can rename them or remove the
+// namespaces left under the old K8s name.
+func (s *ProjectService) ReportInvalidNames() ([]NameIssue, error) {
+	var issues []NameIssue
+	check := func(entity string, id uint, name string, normalize func(string) (string, error)) {
+		normalized, err := normalize(name)
+		switch {
+		case err != nil:
+			issues = append(issues, NameIssue{Entity: entity, ID: id, Name: name, Problem: err.Error()})
+		case normalized != name:
+			issues = append(issues, NameIssue{Entity: entity, ID: id, Name: name, Problem: fmt.Sprintf("would now be stored as %q", normalized)})
+		}
+	}
+
+	projects, err := s.Repos.Project.ListProjects()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	for _, p := range projects {
+		check("project", p.PID, p.ProjectName, utils.NormalizeProjectName)
+	}
+	files, err := s.Repos.ConfigFile.ListConfigFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config files: %w", err)
+	}
+	for _, cf := range files {
+		check("config_file", cf.CFID, cf.Filename, utils.NormalizeFilename)
+	}
+	users, err := s.Repos.User.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	for _, u := range users {
+		if legacy, current := k8s.LegacySafeK8sName(u.Username), k8s.ToSafeK8sName(u.Username); legacy != current {
+			issues = append(issues, NameIssue{Entity: "user", ID: u.UID, Name: u.Username,
+				Problem: fmt.Sprintf("K8s name changed from %q to %q; namespaces proj-<id>-%s are no longer managed", legacy, current, legacy)})
+		}
+	}
+	return issues, nil
+}
+
+// MigrateUserK8sNames provisions the project namespaces of the users whose K8s name changed when
+// names that sanitize to too little got a slug, under the new name, and binds their hub into
+// them. Namespaces under the old name are left alone, since they may run workloads or be shared
+// by every user whose name sanitized to nothing; ReportInvalidNames lists them. Provisioning is
+// idempotent, so running it on every start only creates what is missing. It returns the number
+// of users migrated.
+func (s *UserGroupService) MigrateUserK8sNames() (int, error) {
+	users, err := s.Repos.User.GetAllUsers()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	migrated := 0
+	for _, u := range users {
+		if k8s.LegacySafeK8sName(u.Username) == k8s.ToSafeK8sName(u.Username) {
+			continue
+		}
+		memberships, err := s.Repos.UserGroup.GetUserGroupsByUID(u.UID)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to list groups of user %d: %w", u.UID, err)
+		}
+		for _, m := range memberships {
+			if err := s.AllocateGroupResource(m.GID, u.Username); err != nil {
+				return migrated, err
+			}
+		}
+		migrated++
+	}
+	return migrated, nil
+}
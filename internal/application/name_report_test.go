@@ -0,0 +1,42 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func TestReportInvalidNamesLeavesRowsAlone(t *testing.T) {
+	db := dbtest.Open(t, &project.Project{}, &configfile.ConfigFile{}, &user.User{}, &group.Group{}, &group.UserGroup{})
+	db.Create(&project.Project{PID: 1, ProjectName: "影像辨識", GID: 1})
+	db.Create(&project.Project{PID: 2, ProjectName: "   ", GID: 1})
+	db.Create(&project.Project{PID: 3, ProjectName: "cafe\u0301", GID: 1})
+	db.Create(&configfile.ConfigFile{CFID: 1, Filename: "訓練設定 v2 (final).yaml", ProjectID: 1})
+	db.Create(&configfile.ConfigFile{CFID: 2, Filename: "train\n.yaml", ProjectID: 1})
+	// 李a was "a" before it got a slug; alice keeps the same K8s name
+	db.Create(&user.User{UID: 4, Username: "李a", Password: "x"})
+	db.Create(&user.User{UID: 5, Username: "alice", Password: "x"})
+
+	issues, err := NewProjectService(repository.NewRepositories(db)).ReportInvalidNames()
+	if err != nil {
+		t.Fatalf("ReportInvalidNames: %v", err)
+	}
+	reported := map[string]uint{}
+	for _, issue := range issues {
+		reported[issue.Entity] += issue.ID
+	}
+	if len(issues) != 4 || reported["project"] != 2+3 || reported["config_file"] != 2 || reported["user"] != 4 {
+		t.Fatalf("expected the blank and the decomposed project, the filename with a newline and the renamed user, got %+v", issues)
+	}
+
+	var p project.Project
+	db.First(&p, 3)
+	if p.ProjectName != "cafe\u0301" {
+		t.Fatalf("expected the stored name to be left alone, got %q", p.ProjectName)
+	}
+}
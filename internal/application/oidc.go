@@ -0,0 +1,300 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/linskybing/platform-go/internal/api/middleware"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/pkg/oidc"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCDisabled           = errors.New("oidc login is not enabled")
+	ErrLocalLoginDisabled     = errors.New("local password login is disabled")
+	ErrOIDCMissingClaim       = errors.New("id_token is missing a required claim")
+	ErrOIDCUserNotProvisioned = errors.New("no platform account is linked to this identity")
+	ErrOIDCAccountConflict    = errors.New("username is already linked to a different identity")
+	ErrOIDCLinkRequired       = errors.New("an account with this username exists; sign in and link the identity from your profile")
+	ErrOIDCLinkExpired        = errors.New("the identity link request is invalid or has expired")
+)
+
+// oidcLinkTTL bounds how long a link started from a session waits for the issuer callback
+const oidcLinkTTL = 5 * time.Minute
+
+// maxOIDCUsername is the length of the username column
+const maxOIDCUsername = 50
+
+// oidcLink is a pending link of an identity to the user who started it from a session.
+type oidcLink struct {
+	uid     uint
+	expires time.Time
+}
+
+// oidcProvider discovers the configured issuer on first use so the API starts even if the issuer is down.
+func (s *UserService) oidcProvider(ctx context.Context) (*oidc.Provider, error) {
+	if !config.OIDCEnabled {
+		return nil, ErrOIDCDisabled
+	}
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+	if s.oidc != nil {
+		return s.oidc, nil
+	}
+	p, err := oidc.NewProvider(ctx, oidc.Config{
+		IssuerURL:    config.OIDCIssuerURL,
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  config.OIDCRedirectURL,
+		Scopes:       config.OIDCScopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.oidc = p
+	return p, nil
+}
+
+// OIDCLoginURL returns the issuer authorization URL bound to state and nonce.
+func (s *UserService) OIDCLoginURL(ctx context.Context, state, nonce string) (string, error) {
+	p, err := s.oidcProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.AuthCodeURL(state, nonce), nil
+}
+
+// LoginWithOIDC exchanges the authorization code, maps the identity to a platform user and
+// issues the regular platform JWT.
+func (s *UserService) LoginWithOIDC(ctx context.Context, code, nonce string) (user.User, string, bool, error) {
+	p, err := s.oidcProvider(ctx)
+	if err != nil {
+		return user.User{}, "", false, err
+	}
+	claims, err := p.Exchange(ctx, code, nonce)
+	if err != nil {
+		return user.User{}, "", false, err
+	}
+	usr, err := s.resolveOIDCUser(claims)
+	if err != nil {
+		return user.User{}, "", false, err
+	}
+
+	token, isAdmin, err := middleware.GenerateToken(usr.UID, usr.Username, 24*time.Hour, s.Repos.UserGroup)
+	if err != nil {
+		return user.User{}, "", false, err
+	}
+	return usr, token, isAdmin, nil
+}
+
+// resolveOIDCUser finds the user bound to the token subject. An unbound account with the mapped
+// username is linked on first login only when the issuer verified an email equal to the
+// account's; otherwise the user links it with StartOIDCLink. Unknown identities are created
+// only with auto-provisioning.
+func (s *UserService) resolveOIDCUser(claims oidc.Claims) (user.User, error) {
+	subject := claims.String("sub")
+	if subject == "" {
+		return user.User{}, fmt.Errorf("%w: sub", ErrOIDCMissingClaim)
+	}
+
+	usr, err := s.Repos.User.GetUserByExternalSubject(user.AuthProviderOIDC, subject)
+	if err == nil {
+		return usr, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user.User{}, err
+	}
+
+	username := oidcUsername(claims)
+	if username == "" {
+		return user.User{}, fmt.Errorf("%w: %s", ErrOIDCMissingClaim, config.OIDCUsernameClaim)
+	}
+
+	usr, err = s.Repos.User.GetUserByUsername(username)
+	switch {
+	case err == nil:
+		// The reserved admin must never be claimable by an external identity
+		if usr.Username == config.ReservedAdminUsername || (usr.ExternalSubject != nil && *usr.ExternalSubject != subject) {
+			return user.User{}, ErrOIDCAccountConflict
+		}
+		// A matching username alone proves nothing; anyone may register it at the issuer
+		if !oidcEmailMatches(usr, claims) {
+			return user.User{}, ErrOIDCLinkRequired
+		}
+		if err := s.linkOIDCSubject(&usr, subject); err != nil {
+			return user.User{}, err
+		}
+		return usr, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return user.User{}, err
+	case !config.OIDCAutoProvision:
+		return user.User{}, ErrOIDCUserNotProvisioned
+	}
+
+	return s.provisionOIDCUser(username, subject, claims)
+}
+
+func (s *UserService) provisionOIDCUser(username, subject string, claims oidc.Claims) (user.User, error) {
+	// SSO accounts never log in with a password; store an unguessable hash to satisfy NOT NULL
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return user.User{}, err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	if err != nil {
+		return user.User{}, ErrPasswordHashFailure
+	}
+
+	usr := user.User{
+		Username:        username,
+		Password:        string(hashed),
+		Type:            string(user.UserTypeOauth2),
+		Status:          string(user.UserStatusOffline),
+		AuthProvider:    user.AuthProviderOIDC,
+		ExternalSubject: &subject,
+	}
+	if email := claims.String(config.OIDCEmailClaim); email != "" {
+		usr.Email = &email
+	}
+	if name := claims.String("name"); name != "" {
+		usr.FullName = &name
+	}
+	if err := s.Repos.User.SaveUser(&usr); err != nil {
+		return user.User{}, err
+	}
+
+	if config.OIDCDefaultGroupID != 0 {
+		if err := s.Repos.UserGroup.CreateUserGroup(&group.UserGroup{
+			UID:  usr.UID,
+			GID:  config.OIDCDefaultGroupID,
+			Role: config.OIDCDefaultRole,
+		}); err != nil {
+			return user.User{}, fmt.Errorf("failed to assign default group: %w", err)
+		}
+	}
+	return usr, nil
+}
+
+// oidcEmailMatches reports whether the issuer asserts a verified email equal to the account's.
+func oidcEmailMatches(usr user.User, claims oidc.Claims) bool {
+	email := claims.String(config.OIDCEmailClaim)
+	verified, _ := claims["email_verified"].(bool)
+	if s, ok := claims["email_verified"].(string); ok {
+		verified = s == "true"
+	}
+	return verified && email != "" && usr.Email != nil && strings.EqualFold(*usr.Email, email)
+}
+
+// linkOIDCSubject binds the identity to usr.
+func (s *UserService) linkOIDCSubject(usr *user.User, subject string) error {
+	usr.AuthProvider = user.AuthProviderOIDC
+	usr.ExternalSubject = &subject
+	return s.Repos.User.SaveUser(usr)
+}
+
+// StartOIDCLink returns the issuer URL that links an identity to the user of the session, bound
+// to state and nonce. The link completes in CompleteOIDCLink when the issuer redirects back.
+func (s *UserService) StartOIDCLink(ctx context.Context, uid uint, state, nonce string) (string, error) {
+	authURL, err := s.OIDCLoginURL(ctx, state, nonce)
+	if err != nil {
+		return "", err
+	}
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+	now := time.Now()
+	if s.oidcLinks == nil {
+		s.oidcLinks = map[string]oidcLink{}
+	}
+	for k, l := range s.oidcLinks {
+		if now.After(l.expires) {
+			delete(s.oidcLinks, k)
+		}
+	}
+	s.oidcLinks[state] = oidcLink{uid: uid, expires: now.Add(oidcLinkTTL)}
+	return authURL, nil
+}
+
+// IsOIDCLink reports whether state belongs to a pending link rather than a login.
+func (s *UserService) IsOIDCLink(state string) bool {
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+	_, ok := s.oidcLinks[state]
+	return ok
+}
+
+// CompleteOIDCLink exchanges the code of a link started with StartOIDCLink and binds the
+// identity to the user who started it. An identity bound to another account is a conflict.
+func (s *UserService) CompleteOIDCLink(ctx context.Context, state, code, nonce string) (user.User, error) {
+	s.oidcMu.Lock()
+	link, ok := s.oidcLinks[state]
+	delete(s.oidcLinks, state)
+	s.oidcMu.Unlock()
+	if !ok || time.Now().After(link.expires) {
+		return user.User{}, ErrOIDCLinkExpired
+	}
+
+	p, err := s.oidcProvider(ctx)
+	if err != nil {
+		return user.User{}, err
+	}
+	claims, err := p.Exchange(ctx, code, nonce)
+	if err != nil {
+		return user.User{}, err
+	}
+	subject := claims.String("sub")
+	if subject == "" {
+		return user.User{}, fmt.Errorf("%w: sub", ErrOIDCMissingClaim)
+	}
+
+	if bound, err := s.Repos.User.GetUserByExternalSubject(user.AuthProviderOIDC, subject); err == nil {
+		if bound.UID != link.uid {
+			return user.User{}, ErrOIDCAccountConflict
+		}
+		return bound, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user.User{}, err
+	}
+
+	usr, err := s.Repos.User.GetUserRawByID(link.uid)
+	if err != nil {
+		return user.User{}, err
+	}
+	if usr.Username == config.ReservedAdminUsername || (usr.ExternalSubject != nil && *usr.ExternalSubject != subject) {
+		return user.User{}, ErrOIDCAccountConflict
+	}
+	if err := s.linkOIDCSubject(&usr, subject); err != nil {
+		return user.User{}, err
+	}
+	return usr, nil
+}
+
+// oidcUsername maps the configured claim to a username; email claims use the local part. Names
+// longer than the username column end in a hash of the full name instead of being cut, so two
+// long names sharing a prefix do not map to the same username.
+func oidcUsername(claims oidc.Claims) string {
+	name := claims.String(config.OIDCUsernameClaim)
+	if config.OIDCUsernameClaim == config.OIDCEmailClaim {
+		name, _, _ = strings.Cut(name, "@")
+	}
+	if len(name) <= maxOIDCUsername {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := "-" + hex.EncodeToString(sum[:4])
+	prefix := name[:maxOIDCUsername-len(suffix)]
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix + suffix
+}
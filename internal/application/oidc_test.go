@@ -0,0 +1,179 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/linskybing/platform-go/internal/api/middleware"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/oidc/oidctest"
+)
+
+func setupOIDCService(t *testing.T) (*UserService, *repository.Repos, *oidctest.Server) {
+	t.Helper()
+	db := dbtest.Open(t, &user.User{}, &group.UserGroup{})
+	repos := repository.NewRepositories(db)
+
+	issuer := oidctest.NewServer("platform")
+	t.Cleanup(issuer.Close)
+
+	origEnabled, origIssuer, origClient := config.OIDCEnabled, config.OIDCIssuerURL, config.OIDCClientID
+	origClaim, origProvision, origGroup, origRole := config.OIDCUsernameClaim, config.OIDCAutoProvision, config.OIDCDefaultGroupID, config.OIDCDefaultRole
+	origGen := middleware.GenerateToken
+	t.Cleanup(func() {
+		config.OIDCEnabled, config.OIDCIssuerURL, config.OIDCClientID = origEnabled, origIssuer, origClient
+		config.OIDCUsernameClaim, config.OIDCAutoProvision, config.OIDCDefaultGroupID, config.OIDCDefaultRole = origClaim, origProvision, origGroup, origRole
+		middleware.GenerateToken = origGen
+	})
+	config.OIDCEnabled, config.OIDCIssuerURL, config.OIDCClientID = true, issuer.URL, "platform"
+	config.OIDCUsernameClaim = "preferred_username"
+	middleware.GenerateToken = func(uid uint, username string, exp time.Duration, view repository.UserGroupRepo) (string, bool, error) {
+		return "jwt-for-" + username, false, nil
+	}
+
+	return NewUserService(repos), repos, issuer
+}
+
+func TestLoginWithOIDCLinksExistingUser(t *testing.T) {
+	svc, repos, issuer := setupOIDCService(t)
+	ctx := context.Background()
+	email := "alice@uni.edu"
+	if err := repos.User.SaveUser(&user.User{Username: "alice", Password: "x", Email: &email}); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+
+	// The username alone, or an unverified email, does not take over the account
+	issuer.AddCode("c0", map[string]interface{}{"sub": "sso-alice", "preferred_username": "alice", "email": email, "nonce": "n0"})
+	if _, _, _, err := svc.LoginWithOIDC(ctx, "c0", "n0"); !errors.Is(err, ErrOIDCLinkRequired) {
+		t.Fatalf("expected ErrOIDCLinkRequired, got %v", err)
+	}
+
+	issuer.AddCode("c1", map[string]interface{}{"sub": "sso-alice", "preferred_username": "alice", "email": "Alice@uni.edu", "email_verified": true, "nonce": "n1"})
+	usr, token, _, err := svc.LoginWithOIDC(ctx, "c1", "n1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usr.Username != "alice" || token != "jwt-for-alice" {
+		t.Fatalf("unexpected login result %s / %s", usr.Username, token)
+	}
+	linked, err := repos.User.GetUserByExternalSubject(user.AuthProviderOIDC, "sso-alice")
+	if err != nil || linked.UID != usr.UID {
+		t.Fatalf("expected subject to be linked, got %+v (%v)", linked, err)
+	}
+
+	// Later logins resolve by subject even if the username claim changes
+	issuer.AddCode("c2", map[string]interface{}{"sub": "sso-alice", "preferred_username": "alice.renamed", "nonce": "n2"})
+	if usr, _, _, err = svc.LoginWithOIDC(ctx, "c2", "n2"); err != nil || usr.Username != "alice" {
+		t.Fatalf("expected subject lookup to win, got %q (%v)", usr.Username, err)
+	}
+
+	// A different identity cannot take over an already linked account
+	issuer.AddCode("c3", map[string]interface{}{"sub": "someone-else", "preferred_username": "alice", "nonce": "n3"})
+	if _, _, _, err = svc.LoginWithOIDC(ctx, "c3", "n3"); !errors.Is(err, ErrOIDCAccountConflict) {
+		t.Fatalf("expected ErrOIDCAccountConflict, got %v", err)
+	}
+}
+
+func TestOIDCLinkFromSession(t *testing.T) {
+	svc, repos, issuer := setupOIDCService(t)
+	ctx := context.Background()
+	alice := user.User{Username: "alice", Password: "x"}
+	if err := repos.User.SaveUser(&alice); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+	bob := user.User{Username: "bob", Password: "x"}
+	if err := repos.User.SaveUser(&bob); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+
+	if _, err := svc.CompleteOIDCLink(ctx, "unknown", "c0", "n0"); !errors.Is(err, ErrOIDCLinkExpired) {
+		t.Fatalf("expected ErrOIDCLinkExpired for a state no session started, got %v", err)
+	}
+
+	if _, err := svc.StartOIDCLink(ctx, alice.UID, "s1", "n1"); err != nil {
+		t.Fatalf("StartOIDCLink: %v", err)
+	}
+	if !svc.IsOIDCLink("s1") {
+		t.Fatalf("expected s1 to be a pending link")
+	}
+	issuer.AddCode("c1", map[string]interface{}{"sub": "sso-alice", "preferred_username": "someone", "nonce": "n1"})
+	if usr, err := svc.CompleteOIDCLink(ctx, "s1", "c1", "n1"); err != nil || usr.UID != alice.UID {
+		t.Fatalf("expected the identity linked to alice, got %+v (%v)", usr, err)
+	}
+	issuer.AddCode("c2", map[string]interface{}{"sub": "sso-alice", "nonce": "n2"})
+	if usr, _, _, err := svc.LoginWithOIDC(ctx, "c2", "n2"); err != nil || usr.UID != alice.UID {
+		t.Fatalf("expected the linked identity to log in as alice, got %+v (%v)", usr, err)
+	}
+
+	// The same identity cannot be linked to a second account
+	if _, err := svc.StartOIDCLink(ctx, bob.UID, "s3", "n3"); err != nil {
+		t.Fatalf("StartOIDCLink: %v", err)
+	}
+	issuer.AddCode("c3", map[string]interface{}{"sub": "sso-alice", "nonce": "n3"})
+	if _, err := svc.CompleteOIDCLink(ctx, "s3", "c3", "n3"); !errors.Is(err, ErrOIDCAccountConflict) {
+		t.Fatalf("expected ErrOIDCAccountConflict, got %v", err)
+	}
+}
+
+func TestOIDCUsernameKeepsLongNamesApart(t *testing.T) {
+	orig := config.OIDCUsernameClaim
+	defer func() { config.OIDCUsernameClaim = orig }()
+	config.OIDCUsernameClaim = "preferred_username"
+
+	prefix := strings.Repeat("a", 60)
+	first := oidcUsername(map[string]interface{}{"preferred_username": prefix + "1"})
+	second := oidcUsername(map[string]interface{}{"preferred_username": prefix + "2"})
+	if len(first) > 50 || len(second) > 50 || first == second {
+		t.Fatalf("expected distinct usernames of at most 50 bytes, got %q and %q", first, second)
+	}
+	if got := oidcUsername(map[string]interface{}{"preferred_username": strings.Repeat("學", 30)}); !utf8.ValidString(got) || len(got) > 50 {
+		t.Fatalf("expected a valid UTF-8 username of at most 50 bytes, got %q", got)
+	}
+	if got := oidcUsername(map[string]interface{}{"preferred_username": "alice"}); got != "alice" {
+		t.Fatalf("expected short names unchanged, got %q", got)
+	}
+}
+
+func TestLoginWithOIDCProvisioning(t *testing.T) {
+	svc, repos, issuer := setupOIDCService(t)
+	ctx := context.Background()
+
+	issuer.AddCode("c1", map[string]interface{}{"sub": "sso-bob", "email": "bob@uni.edu", "nonce": "n1"})
+	config.OIDCUsernameClaim = "email"
+	if _, _, _, err := svc.LoginWithOIDC(ctx, "c1", "n1"); !errors.Is(err, ErrOIDCUserNotProvisioned) {
+		t.Fatalf("expected ErrOIDCUserNotProvisioned without auto-provisioning, got %v", err)
+	}
+
+	config.OIDCAutoProvision, config.OIDCDefaultGroupID, config.OIDCDefaultRole = true, 3, "user"
+	issuer.AddCode("c2", map[string]interface{}{"sub": "sso-bob", "email": "bob@uni.edu", "name": "Bob", "nonce": "n2"})
+	usr, _, _, err := svc.LoginWithOIDC(ctx, "c2", "n2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usr.Username != "bob" || usr.Email == nil || *usr.Email != "bob@uni.edu" || usr.Type != string(user.UserTypeOauth2) {
+		t.Fatalf("unexpected provisioned user %+v", usr)
+	}
+	ug, err := repos.UserGroup.GetUserGroup(usr.UID, 3)
+	if err != nil || ug.Role != "user" {
+		t.Fatalf("expected default group membership, got %+v (%v)", ug, err)
+	}
+}
+
+func TestLoginUserLocalDisabled(t *testing.T) {
+	orig := config.LocalLoginEnabled
+	defer func() { config.LocalLoginEnabled = orig }()
+	config.LocalLoginEnabled = false
+
+	svc := NewUserService(&repository.Repos{})
+	if _, _, _, err := svc.LoginUser("alice", "pw"); !errors.Is(err, ErrLocalLoginDisabled) {
+		t.Fatalf("expected ErrLocalLoginDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	ErrPodSecurityViolation = errors.New("workload requests node-level access")
+	ErrInvalidHostPath      = errors.New("invalid host path")
+)
+
+// PodSecurityViolation is a setting of a pod spec that gives its containers access to the node.
+type PodSecurityViolation struct {
+	// Resource is the Kind/name of the object holding the pod spec, when known
+	Resource string `json:"resource,omitempty"`
+	// Field is the path of the setting within the pod spec, e.g. volumes[docker].hostPath
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (v PodSecurityViolation) String() string {
+	if v.Resource != "" {
+		return fmt.Sprintf("%s: %s %s", v.Resource, v.Field, v.Message)
+	}
+	return fmt.Sprintf("%s %s", v.Field, v.Message)
+}
+
+// PodSecurityError lists every violation found in a workload submitted by a non-admin.
+type PodSecurityError struct {
+	Violations []PodSecurityViolation
+}
+
+func (e *PodSecurityError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return fmt.Sprintf("%s: %s. Only platform admins may use host namespaces and privileged containers; "+
+		"to mount a host path, ask a platform admin to add it to the project's host path exceptions "+
+		"(PUT /projects/{id}/host-path-exceptions)", ErrPodSecurityViolation, strings.Join(parts, "; "))
+}
+
+func (e *PodSecurityError) Unwrap() error {
+	return ErrPodSecurityViolation
+}
+
+// CheckPodSecurity returns the settings of spec that give its containers access to the node:
+// hostPath volumes, the host network, PID and IPC namespaces, and privileged containers. Host
+// paths in allowedHostPaths, or below them, are accepted.
+func CheckPodSecurity(spec *corev1.PodSpec, allowedHostPaths []string) []PodSecurityViolation {
+	var violations []PodSecurityViolation
+	add := func(field, msg string) {
+		violations = append(violations, PodSecurityViolation{Field: field, Message: msg})
+	}
+
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil && !hostPathAllowed(v.HostPath.Path, allowedHostPaths) {
+			add(fmt.Sprintf("volumes[%s].hostPath", v.Name), fmt.Sprintf("mounts host path %s", v.HostPath.Path))
+		}
+	}
+	if spec.HostNetwork {
+		add("hostNetwork", "uses the host network")
+	}
+	if spec.HostPID {
+		add("hostPID", "uses the host PID namespace")
+	}
+	if spec.HostIPC {
+		add("hostIPC", "uses the host IPC namespace")
+	}
+	check := func(kind string, containers []corev1.Container) {
+		for _, c := range containers {
+			if c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+				add(fmt.Sprintf("%s[%s].securityContext.privileged", kind, c.Name), "runs a privileged container")
+			}
+		}
+	}
+	check("initContainers", spec.InitContainers)
+	check("containers", spec.Containers)
+	return violations
+}
+
+// hostPathAllowed reports whether p is one of the allowed paths or below one of them.
+func hostPathAllowed(p string, allowed []string) bool {
+	p = path.Clean(p)
+	for _, a := range allowed {
+		if p == a || strings.HasPrefix(p, strings.TrimSuffix(a, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// objectPodSecurityViolations checks every pod spec of a manifest object.
+func objectPodSecurityViolations(obj map[string]interface{}, allowedHostPaths []string) ([]PodSecurityViolation, error) {
+	ref := objectRef(obj)
+	var violations []PodSecurityViolation
+	for _, m := range findPodSpecs(obj) {
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		var spec corev1.PodSpec
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return nil, fmt.Errorf("failed to read the pod spec of %s: %w", ref, err)
+		}
+		for _, v := range CheckPodSecurity(&spec, allowedHostPaths) {
+			v.Resource = ref
+			violations = append(violations, v)
+		}
+	}
+	return violations, nil
+}
+
+// enforcePodSecurity rejects the objects of a config file that give access to the node, unless
+// the caller is a platform admin.
+func (s *ConfigFileService) enforcePodSecurity(c *gin.Context, projectID uint, objs []map[string]interface{}) error {
+	if claimsIsAdmin(c) {
+		return nil
+	}
+	check := func(allowed []string) ([]PodSecurityViolation, error) {
+		var violations []PodSecurityViolation
+		for _, obj := range objs {
+			found, err := objectPodSecurityViolations(obj, allowed)
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, found...)
+		}
+		return violations, nil
+	}
+
+	return podSecurityError(s.Repos.Project, projectID, check)
+}
+
+// podSecurityError runs check without exceptions and, when it finds violations, again with the
+// host path exceptions of the project; what remains is returned as a *PodSecurityError.
+func podSecurityError(projects repository.ProjectRepo, projectID uint, check func(allowed []string) ([]PodSecurityViolation, error)) error {
+	// The project's exceptions are only read for workloads that need one
+	violations, err := check(nil)
+	if err != nil || len(violations) == 0 {
+		return err
+	}
+	if p, err := projects.GetProjectByID(projectID); err == nil && len(p.HostPathExceptions()) > 0 {
+		if violations, err = check(p.HostPathExceptions()); err != nil || len(violations) == 0 {
+			return err
+		}
+	}
+	return &PodSecurityError{Violations: violations}
+}
+
+// checkJobPodSecurity rejects a job submission whose pods would get access to the node, unless
+// the submitter is a platform admin. Jobs get the same checks as config file instances.
+func (s *K8sService) checkJobPodSecurity(userID, projectID uint, spec k8s.JobSpec) error {
+	podSpec := k8s.JobPodSpec(spec)
+	err := podSecurityError(s.repos.Project, projectID, func(allowed []string) ([]PodSecurityViolation, error) {
+		violations := CheckPodSecurity(&podSpec, allowed)
+		for i := range violations {
+			violations[i].Resource = "Job/" + spec.Name
+		}
+		return violations, nil
+	})
+	// The role is only looked up for jobs that need an admin
+	if err == nil {
+		return nil
+	}
+	if isAdmin, adminErr := utils.IsSuperAdmin(userID, s.repos.UserGroup); adminErr == nil && isAdmin {
+		return nil
+	}
+	return err
+}
+
+// checkConfigFilePodSecurity validates the workloads of an uploaded config file.
+func (s *ConfigFileService) checkConfigFilePodSecurity(c *gin.Context, projectID uint, resources []*resource.Resource) error {
+	return s.enforcePodSecurity(c, projectID, parsedResourceObjects(resources))
+}
+
+// SetHostPathExceptions replaces the host paths members of the project may mount. Paths must be
+// absolute; the root directory cannot be granted.
+func (s *ProjectService) SetHostPathExceptions(c *gin.Context, projectID uint, input project.HostPathExceptionsDTO) (*project.Project, error) {
+	p, err := s.Repos.Project.GetProjectByID(projectID)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	old := p
+
+	paths := []string{}
+	seen := map[string]bool{}
+	for _, raw := range input.Paths {
+		clean := path.Clean(strings.TrimSpace(raw))
+		if !strings.HasPrefix(clean, "/") || clean == "/" {
+			return nil, fmt.Errorf("%w: %q must be an absolute path below /", ErrInvalidHostPath, raw)
+		}
+		if !seen[clean] {
+			seen[clean] = true
+			paths = append(paths, clean)
+		}
+	}
+	encoded, err := json.Marshal(paths)
+	if err != nil {
+		return nil, err
+	}
+	p.AllowedHostPaths = encoded
+	if err := s.Repos.Project.UpdateProject(&p); err != nil {
+		return nil, err
+	}
+	utils.LogAuditWithConsole(c, "update", "project_host_paths", fmt.Sprintf("p_id=%d", projectID), old.HostPathExceptions(), paths, "", s.Repos.Audit)
+	return &p, nil
+}
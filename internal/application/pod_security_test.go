@@ -0,0 +1,131 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCheckPodSecurity(t *testing.T) {
+	privileged := true
+	hostPath := func(name, p string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: p}}}
+	}
+	cases := map[string]struct {
+		spec  corev1.PodSpec
+		field string
+	}{
+		"hostPath":    {corev1.PodSpec{Volumes: []corev1.Volume{hostPath("docker", "/var/run/docker.sock")}}, "volumes[docker].hostPath"},
+		"hostNetwork": {corev1.PodSpec{HostNetwork: true}, "hostNetwork"},
+		"hostPID":     {corev1.PodSpec{HostPID: true}, "hostPID"},
+		"hostIPC":     {corev1.PodSpec{HostIPC: true}, "hostIPC"},
+		"privileged": {corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "main", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}},
+		}}, "containers[main].securityContext.privileged"},
+		"privileged init": {corev1.PodSpec{InitContainers: []corev1.Container{
+			{Name: "setup", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}},
+		}}, "initContainers[setup].securityContext.privileged"},
+	}
+	for name, tc := range cases {
+		violations := CheckPodSecurity(&tc.spec, nil)
+		if len(violations) != 1 || violations[0].Field != tc.field {
+			t.Fatalf("%s: expected a violation of %s, got %+v", name, tc.field, violations)
+		}
+	}
+
+	allowed := []string{"/dev/infiniband"}
+	spec := corev1.PodSpec{Volumes: []corev1.Volume{
+		hostPath("ib", "/dev/infiniband"),
+		hostPath("uverbs", "/dev/infiniband/uverbs0"),
+		hostPath("other", "/dev/infiniband2"),
+	}}
+	violations := CheckPodSecurity(&spec, allowed)
+	if len(violations) != 1 || violations[0].Field != "volumes[other].hostPath" {
+		t.Fatalf("expected only the path outside the exception to be rejected, got %+v", violations)
+	}
+}
+
+const hostPathPodYAML = `apiVersion: v1
+kind: Pod
+metadata:
+  name: ib-test
+spec:
+  containers:
+  - name: main
+    image: busybox
+  volumes:
+  - name: ib
+    hostPath:
+      path: /dev/infiniband/uverbs0
+`
+
+func TestConfigFilePodSecurity(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+	p := project.Project{ProjectName: "hpc", GID: 1}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+
+	_, err := svc.CreateConfigFile(c, configfile.CreateConfigFileInput{Filename: "ib.yaml", RawYaml: hostPathPodYAML, ProjectID: p.PID})
+	var secErr *PodSecurityError
+	if !errors.As(err, &secErr) || !errors.Is(err, ErrPodSecurityViolation) {
+		t.Fatalf("expected a pod security error for a non-admin, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "volumes[ib].hostPath") || !strings.Contains(err.Error(), "host-path-exceptions") {
+		t.Fatalf("expected the error to name the field and how to request an exception, got %q", err.Error())
+	}
+
+	projects := NewProjectService(repository.NewRepositories(db))
+	if _, err := projects.SetHostPathExceptions(c, p.PID, project.HostPathExceptionsDTO{Paths: []string{"/"}}); !errors.Is(err, ErrInvalidHostPath) {
+		t.Fatalf("expected the root directory to be refused, got %v", err)
+	}
+	if _, err := projects.SetHostPathExceptions(c, p.PID, project.HostPathExceptionsDTO{Paths: []string{"/dev/infiniband/"}}); err != nil {
+		t.Fatalf("failed to grant the exception: %v", err)
+	}
+	if _, err := svc.CreateConfigFile(c, configfile.CreateConfigFileInput{Filename: "ib.yaml", RawYaml: hostPathPodYAML, ProjectID: p.PID}); err != nil {
+		t.Fatalf("expected a granted host path to be accepted, got %v", err)
+	}
+
+	admin := &types.Claims{IsAdmin: true}
+	c.Set("claims", admin)
+	privileged := strings.Replace(hostPathPodYAML, "    image: busybox\n", "    image: busybox\n    securityContext:\n      privileged: true\n", 1)
+	if _, err := svc.CreateConfigFile(c, configfile.CreateConfigFileInput{Filename: "admin.yaml", RawYaml: privileged, ProjectID: p.PID}); err != nil {
+		t.Fatalf("expected an admin to be allowed a privileged container, got %v", err)
+	}
+}
+
+func TestJobPodSecurity(t *testing.T) {
+	db := dbtest.Open(t, &project.Project{}, &group.Group{}, &group.UserGroup{})
+	p := project.Project{ProjectName: "hpc", GID: 1}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	svc := NewK8sService(repository.NewRepositories(db))
+	spec := k8s.JobSpec{Name: "ib-train", Volumes: []k8s.VolumeSpec{{Name: "ib", HostPath: "/dev/infiniband/uverbs0", MountPath: "/dev/infiniband"}}}
+
+	err := svc.checkJobPodSecurity(5, p.PID, spec)
+	var secErr *PodSecurityError
+	if !errors.As(err, &secErr) || len(secErr.Violations) != 1 || secErr.Violations[0].Resource != "Job/ib-train" {
+		t.Fatalf("expected the host path of the job to be rejected for a member, got %v", err)
+	}
+	if err := svc.checkJobPodSecurity(1, p.PID, spec); err != nil {
+		t.Fatalf("expected an admin to be allowed a host path, got %v", err)
+	}
+
+	db.Model(&p).Update("allowed_host_paths", `["/dev/infiniband"]`)
+	if err := svc.checkJobPodSecurity(5, p.PID, spec); err != nil {
+		t.Fatalf("expected a granted host path to be accepted, got %v", err)
+	}
+	if err := svc.checkJobPodSecurity(5, p.PID, k8s.JobSpec{Name: "train", Volumes: []k8s.VolumeSpec{{Name: "data", PVCName: "data", MountPath: "/data"}}}); err != nil {
+		t.Fatalf("expected a job mounting only claims to pass, got %v", err)
+	}
+}
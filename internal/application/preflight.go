@@ -0,0 +1,206 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Outcomes of a preflight check
+const (
+	PreflightPass = "pass"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+)
+
+// gpuResourceName is the extended resource advertised by the NVIDIA (MPS) device plugin.
+const gpuResourceName corev1.ResourceName = "nvidia.com/gpu"
+
+// PreflightCheck is the outcome of one platform prerequisite check, with how to fix it when it
+// did not pass.
+type PreflightCheck struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// PreflightReport lists the prerequisite checks; Status is the worst outcome among them.
+type PreflightReport struct {
+	Status string           `json:"status"`
+	Checks []PreflightCheck `json:"checks"`
+}
+
+// Failed reports whether any check failed.
+func (r *PreflightReport) Failed() bool {
+	return r.Status == PreflightFail
+}
+
+// Preflight verifies the cluster prerequisites the platform relies on at runtime: the Harbor
+// pull secret, the PriorityClasses, the default StorageClass, a GPU device plugin and the
+// storage browser service name. It only reads the cluster.
+func (s *K8sService) Preflight(ctx context.Context) *PreflightReport {
+	report := &PreflightReport{Status: PreflightPass}
+	add := func(c PreflightCheck) {
+		report.Checks = append(report.Checks, c)
+		if c.Status == PreflightFail || (c.Status == PreflightWarn && report.Status == PreflightPass) {
+			report.Status = c.Status
+		}
+	}
+
+	add(checkStorageBrowserServiceName())
+	if k8s.Clientset == nil {
+		add(PreflightCheck{
+			Name:        "cluster",
+			Status:      PreflightFail,
+			Message:     "no Kubernetes client is configured",
+			Remediation: "check the kubeconfig or in-cluster service account of the API",
+		})
+		return report
+	}
+	add(checkHarborPullSecret(ctx))
+	add(checkPriorityClasses(ctx))
+	add(checkDefaultStorageClass(ctx))
+	add(checkGPUResource(ctx))
+	return report
+}
+
+// LogPreflight runs the preflight checks and logs every check that did not pass. It returns
+// the report so the caller can stop on failures in strict mode.
+func (s *K8sService) LogPreflight(ctx context.Context) *PreflightReport {
+	report := s.Preflight(ctx)
+	for _, c := range report.Checks {
+		if c.Status == PreflightPass {
+			continue
+		}
+		log.Printf("[Preflight] %s %s: %s (%s)", strings.ToUpper(c.Status), c.Name, c.Message, c.Remediation)
+	}
+	if report.Status == PreflightPass {
+		log.Printf("[Preflight] all %d checks passed", len(report.Checks))
+	}
+	return report
+}
+
+func checkHarborPullSecret(ctx context.Context) PreflightCheck {
+	c := PreflightCheck{Name: "harbor-pull-secret"}
+	if config.HarborPullSecretName == "" {
+		c.Status, c.Message = PreflightWarn, "HARBOR_PULL_SECRET_NAME is empty, images cannot be pulled from Harbor"
+		c.Remediation = "set HARBOR_PULL_SECRET_NAME to the docker-registry Secret holding the Harbor credentials"
+		return c
+	}
+	secrets := k8s.Clientset.CoreV1().Secrets(config.ImagePullNamespace)
+	_, err := secrets.Get(ctx, config.HarborPullSecretName, metav1.GetOptions{})
+	if err == nil {
+		c.Status, c.Message = PreflightPass, fmt.Sprintf("secret %s/%s exists", config.ImagePullNamespace, config.HarborPullSecretName)
+		return c
+	}
+	if !apierrors.IsNotFound(err) {
+		c.Status, c.Message = PreflightFail, fmt.Sprintf("failed to read secret %s/%s: %v", config.ImagePullNamespace, config.HarborPullSecretName, err)
+		c.Remediation = "grant the API service account get on secrets in the image pull namespace"
+		return c
+	}
+
+	// The API copies the secret from its source namespace into the pull namespace on startup
+	if _, err := k8s.Clientset.CoreV1().Secrets(config.HarborPullSecretNamespace).Get(ctx, config.HarborPullSecretName, metav1.GetOptions{}); err == nil {
+		c.Status = PreflightWarn
+		c.Message = fmt.Sprintf("secret %s is missing in %s but exists in %s", config.HarborPullSecretName, config.ImagePullNamespace, config.HarborPullSecretNamespace)
+		c.Remediation = "restart the API to copy it, or check that it may create secrets in " + config.ImagePullNamespace
+		return c
+	}
+	c.Status = PreflightFail
+	c.Message = fmt.Sprintf("secret %s exists neither in %s nor in %s", config.HarborPullSecretName, config.ImagePullNamespace, config.HarborPullSecretNamespace)
+	c.Remediation = fmt.Sprintf("kubectl create secret docker-registry %s -n %s --docker-server=<harbor> --docker-username=<user> --docker-password=<token>",
+		config.HarborPullSecretName, config.HarborPullSecretNamespace)
+	return c
+}
+
+func checkPriorityClasses(ctx context.Context) PreflightCheck {
+	c := PreflightCheck{Name: "priority-classes"}
+	var missing []string
+	for _, spec := range PlatformPriorityClasses() {
+		_, err := k8s.Clientset.SchedulingV1().PriorityClasses().Get(ctx, spec.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			missing = append(missing, spec.Name)
+		} else if err != nil {
+			c.Status, c.Message = PreflightFail, fmt.Sprintf("failed to read priority class %s: %v", spec.Name, err)
+			c.Remediation = "grant the API service account get and create on priorityclasses"
+			return c
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		c.Status, c.Message = PreflightFail, "missing priority classes: "+strings.Join(missing, ", ")
+		c.Remediation = "grant the API service account create on priorityclasses so it creates them on startup, or create them with the values of the PRIORITY_VALUE_<LEVEL> settings"
+		return c
+	}
+	c.Status, c.Message = PreflightPass, fmt.Sprintf("%d priority classes exist", len(config.PriorityClassNames))
+	return c
+}
+
+func checkDefaultStorageClass(ctx context.Context) PreflightCheck {
+	c := PreflightCheck{Name: "storage-class"}
+	classes, err := k8s.ListStorageClasses(ctx)
+	if err != nil {
+		c.Status, c.Message = PreflightFail, fmt.Sprintf("failed to list storage classes: %v", err)
+		c.Remediation = "grant the API service account list on storageclasses"
+		return c
+	}
+	for _, sc := range classes {
+		if sc.Name == config.DefaultStorageClassName {
+			c.Status, c.Message = PreflightPass, fmt.Sprintf("storage class %s exists", sc.Name)
+			return c
+		}
+	}
+	c.Status = PreflightFail
+	c.Message = fmt.Sprintf("storage class %s does not exist, user and project storage cannot be provisioned", config.DefaultStorageClassName)
+	c.Remediation = "install the storage provisioner, e.g. Longhorn, or set DEFAULT_STORAGE_CLASS_NAME to an existing class"
+	return c
+}
+
+func checkGPUResource(ctx context.Context) PreflightCheck {
+	c := PreflightCheck{Name: "gpu-device-plugin"}
+	nodes, err := k8s.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		c.Status, c.Message = PreflightFail, fmt.Sprintf("failed to list nodes: %v", err)
+		c.Remediation = "grant the API service account list on nodes"
+		return c
+	}
+	gpuNodes := 0
+	for _, n := range nodes.Items {
+		if q, ok := n.Status.Allocatable[gpuResourceName]; ok && !q.IsZero() {
+			gpuNodes++
+		}
+	}
+	if gpuNodes == 0 {
+		c.Status, c.Message = PreflightWarn, fmt.Sprintf("no node has allocatable %s, GPU jobs and instances will stay pending", gpuResourceName)
+		c.Remediation = "install the NVIDIA device plugin with MPS sharing on the GPU nodes"
+		return c
+	}
+	c.Status, c.Message = PreflightPass, fmt.Sprintf("%d nodes have allocatable %s", gpuNodes, gpuResourceName)
+	return c
+}
+
+func checkStorageBrowserServiceName() PreflightCheck {
+	c := PreflightCheck{Name: "storage-browser-service"}
+	name := config.ProjectStorageBrowserSVCName
+	if errs := validation.IsDNS1035Label(name); name == "" || len(errs) > 0 {
+		c.Status = PreflightFail
+		c.Message = fmt.Sprintf("PROJECT_STORAGE_BROWSER_SVC_NAME %q is not a valid service name", name)
+		if len(errs) > 0 {
+			c.Message += ": " + strings.Join(errs, "; ")
+		}
+		c.Remediation = "set PROJECT_STORAGE_BROWSER_SVC_NAME to a DNS-1035 label, e.g. filebrowser-project-svc"
+		return c
+	}
+	c.Status, c.Message = PreflightPass, fmt.Sprintf("storage browser service name %s is valid", name)
+	return c
+}
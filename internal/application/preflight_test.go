@@ -0,0 +1,95 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func preflightStatuses(r *PreflightReport) map[string]string {
+	statuses := map[string]string{}
+	for _, c := range r.Checks {
+		statuses[c.Name] = c.Status
+	}
+	return statuses
+}
+
+func TestPreflightReportsMissingPrerequisites(t *testing.T) {
+	origSecret, origPullNS, origSC := config.HarborPullSecretName, config.ImagePullNamespace, config.DefaultStorageClassName
+	origBrowser := config.ProjectStorageBrowserSVCName
+	t.Cleanup(func() {
+		config.HarborPullSecretName, config.ImagePullNamespace, config.DefaultStorageClassName = origSecret, origPullNS, origSC
+		config.ProjectStorageBrowserSVCName = origBrowser
+	})
+	config.HarborPullSecretName, config.ImagePullNamespace, config.DefaultStorageClassName = "harbor-regcred", "image-puller", "longhorn"
+	config.ProjectStorageBrowserSVCName = "filebrowser-project-svc"
+
+	origClient := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = origClient })
+
+	objects := []runtime.Object{
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "gpu-1"},
+			Status:     corev1.NodeStatus{Allocatable: corev1.ResourceList{gpuResourceName: resource.MustParse("4")}},
+		},
+	}
+	for _, spec := range PlatformPriorityClasses() {
+		objects = append(objects, &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: spec.Name}, Value: spec.Value})
+	}
+	// No Harbor pull secret anywhere and no longhorn StorageClass
+	objects = append(objects, &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "local-path"}})
+	k8s.Clientset = k8sfake.NewSimpleClientset(objects...)
+
+	svc := &K8sService{}
+	report := svc.Preflight(context.Background())
+	statuses := preflightStatuses(report)
+	if !report.Failed() {
+		t.Fatalf("expected the report to fail, got %+v", report)
+	}
+	want := map[string]string{
+		"harbor-pull-secret":      PreflightFail,
+		"storage-class":           PreflightFail,
+		"priority-classes":        PreflightPass,
+		"gpu-device-plugin":       PreflightPass,
+		"storage-browser-service": PreflightPass,
+	}
+	for name, status := range want {
+		if statuses[name] != status {
+			t.Fatalf("%s: expected %s, got %s (%+v)", name, status, statuses[name], report.Checks)
+		}
+	}
+	for _, c := range report.Checks {
+		if c.Status != PreflightPass && c.Remediation == "" {
+			t.Fatalf("%s: expected a remediation hint", c.Name)
+		}
+	}
+
+	// Once the secret and class exist only the missing GPU nodes warn
+	ctx := context.Background()
+	_, _ = k8s.Clientset.CoreV1().Secrets("image-puller").Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "harbor-regcred"}}, metav1.CreateOptions{})
+	_, _ = k8s.Clientset.StorageV1().StorageClasses().Create(ctx, &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "longhorn"}}, metav1.CreateOptions{})
+	_ = k8s.Clientset.CoreV1().Nodes().Delete(ctx, "gpu-1", metav1.DeleteOptions{})
+	report = svc.Preflight(ctx)
+	if report.Status != PreflightWarn || preflightStatuses(report)["gpu-device-plugin"] != PreflightWarn {
+		t.Fatalf("expected only a GPU warning, got %+v", report)
+	}
+}
+
+func TestPreflightRejectsInvalidBrowserServiceName(t *testing.T) {
+	orig := config.ProjectStorageBrowserSVCName
+	t.Cleanup(func() { config.ProjectStorageBrowserSVCName = orig })
+	config.ProjectStorageBrowserSVCName = "File_Browser"
+
+	if c := checkStorageBrowserServiceName(); c.Status != PreflightFail {
+		t.Fatalf("expected an invalid service name to fail, got %+v", c)
+	}
+}
@@ -0,0 +1,148 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PriorityNotAllowedError is returned when a user requests a priority level their role may not use.
+type PriorityNotAllowedError struct {
+	Requested string
+	Allowed   []string
+}
+
+func (e *PriorityNotAllowedError) Error() string {
+	return fmt.Sprintf("priority '%s' is not allowed for your role. Allowed: %s", e.Requested, strings.Join(e.Allowed, ", "))
+}
+
+// PlatformPriorityClasses returns the PriorityClasses the platform relies on, built from config.
+func PlatformPriorityClasses() []k8s.PriorityClassSpec {
+	specs := make([]k8s.PriorityClassSpec, 0, len(config.PriorityClassNames))
+	for level, name := range config.PriorityClassNames {
+		policy := corev1.PreemptLowerPriority
+		if level == job.PriorityLow {
+			policy = corev1.PreemptNever
+		}
+		specs = append(specs, k8s.PriorityClassSpec{
+			Name:             name,
+			Value:            config.PriorityClassValues[level],
+			PreemptionPolicy: policy,
+			Description:      fmt.Sprintf("Platform %s priority workloads", level),
+		})
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Value < specs[j].Value })
+	return specs
+}
+
+// ReconcilePriorityClasses creates the platform PriorityClasses that are missing in the cluster.
+func (s *K8sService) ReconcilePriorityClasses(ctx context.Context) error {
+	created, err := k8s.EnsurePriorityClasses(ctx, PlatformPriorityClasses())
+	if err != nil {
+		return err
+	}
+	for _, name := range created {
+		log.Printf("created priority class %s", name)
+	}
+	return nil
+}
+
+// ListPriorityClasses lists the platform priority levels and whether the user may request each of them.
+// projectID is optional; without it only platform admins get more than the lowest level.
+func (s *K8sService) ListPriorityClasses(ctx context.Context, userID uint, projectID uint) ([]job.PriorityClassOption, error) {
+	existing, err := k8s.ListPriorityClasses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(existing))
+	for _, pc := range existing {
+		found[pc.Name] = true
+	}
+
+	allowed := allowedPriorityLevels(s.priorityRole(userID, projectID))
+	options := []job.PriorityClassOption{}
+	for _, spec := range PlatformPriorityClasses() {
+		level := priorityLevelForClass(spec.Name)
+		options = append(options, job.PriorityClassOption{
+			Level:            level,
+			ClassName:        spec.Name,
+			Value:            spec.Value,
+			PreemptionPolicy: string(spec.PreemptionPolicy),
+			Exists:           found[spec.Name] || k8s.Clientset == nil,
+			Allowed:          containsString(allowed, level),
+		})
+	}
+	return options, nil
+}
+
+// priorityRole returns the role used to gate priority levels and job types: "admin" for platform admins,
+// otherwise the user's role in the project's group.
+func (s *K8sService) priorityRole(userID uint, projectID uint) string {
+	if isSuper, err := utils.IsSuperAdmin(userID, s.repos.UserGroup); err == nil && isSuper {
+		return "admin"
+	}
+	if projectID == 0 {
+		return "user"
+	}
+	gid, err := s.repos.Project.GetGroupIDByProjectID(projectID)
+	if err != nil {
+		return "user"
+	}
+	role, err := s.repos.UserGroup.GetUserRoleInGroup(userID, gid)
+	if err != nil || role == "" {
+		return "user"
+	}
+	return role
+}
+
+func allowedPriorityLevels(role string) []string {
+	if levels, ok := config.RolePriorityLevels[role]; ok {
+		return levels
+	}
+	return config.RolePriorityLevels["user"]
+}
+
+// resolvePriorityClass validates the requested level against the role and returns the
+// normalized level and PriorityClass name. An empty request defaults to low.
+func resolvePriorityClass(requested string, role string) (string, string, error) {
+	level := strings.ToLower(strings.TrimSpace(requested))
+	switch level {
+	case "":
+		level = job.PriorityLow
+	case "normal":
+		level = job.PriorityMedium
+	}
+
+	allowed := allowedPriorityLevels(role)
+	className, known := config.PriorityClassNames[level]
+	if !known || !containsString(allowed, level) {
+		return "", "", &PriorityNotAllowedError{Requested: requested, Allowed: allowed}
+	}
+	return level, className, nil
+}
+
+func priorityLevelForClass(className string) string {
+	for level, name := range config.PriorityClassNames {
+		if name == className {
+			return level
+		}
+	}
+	return ""
+}
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if strings.TrimSpace(item) == v {
+			return true
+		}
+	}
+	return false
+}
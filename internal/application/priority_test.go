@@ -0,0 +1,38 @@
+package application
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+)
+
+func TestResolvePriorityClassRoleGate(t *testing.T) {
+	level, class, err := resolvePriorityClass("", "user")
+	if err != nil || level != "low" || class != config.PriorityClassNames["low"] {
+		t.Fatalf("expected default low priority, got %s %s %v", level, class, err)
+	}
+
+	_, _, err = resolvePriorityClass("high", "user")
+	var notAllowed *PriorityNotAllowedError
+	if !errors.As(err, &notAllowed) {
+		t.Fatalf("expected PriorityNotAllowedError, got %v", err)
+	}
+	if len(notAllowed.Allowed) != 1 || notAllowed.Allowed[0] != "low" {
+		t.Fatalf("expected allowed [low], got %v", notAllowed.Allowed)
+	}
+
+	level, _, err = resolvePriorityClass("normal", "manager")
+	if err != nil || level != "medium" {
+		t.Fatalf("expected manager to use normal priority, got %s %v", level, err)
+	}
+
+	level, class, err = resolvePriorityClass("high", "admin")
+	if err != nil || level != "high" || class != config.PriorityClassNames["high"] {
+		t.Fatalf("expected admin to use high priority, got %s %s %v", level, class, err)
+	}
+
+	if _, _, err := resolvePriorityClass("urgent", "admin"); err == nil {
+		t.Fatalf("expected unknown priority to be rejected")
+	}
+}
@@ -27,6 +27,32 @@ func NewProjectService(repos *repository.Repos) *ProjectService {
 	}
 }
 
+// ProjectStorageNamespace is the namespace holding the project's storage. It is the namespace
+// recorded at creation, so it survives renames; rows not yet backfilled fall back to deriving
+// it from the current name.
+func ProjectStorageNamespace(p *project.Project) string {
+	if p.StorageNamespace != "" {
+		return p.StorageNamespace
+	}
+	return k8s.GenerateSafeResourceName("project", p.ProjectName, p.PID)
+}
+
+// BackfillStorageNamespaces records the storage namespace of projects created before it was
+// stored, deriving it from their current name as every call site used to.
+func (s *ProjectService) BackfillStorageNamespaces() (int, error) {
+	projects, err := s.Repos.Project.ListProjectsWithoutStorageNamespace()
+	if err != nil {
+		return 0, err
+	}
+	for i := range projects {
+		p := &projects[i]
+		if err := s.Repos.Project.SetStorageNamespace(p.PID, ProjectStorageNamespace(p)); err != nil {
+			return i, fmt.Errorf("failed to backfill the storage namespace of project %d: %w", p.PID, err)
+		}
+	}
+	return len(projects), nil
+}
+
 func (s *ProjectService) GetProject(id uint) (*project.Project, error) {
 	p, err := s.Repos.Project.GetProjectByID(id)
 	if err != nil {
@@ -75,8 +101,12 @@ func (s *ProjectService) CreateProject(c *gin.Context, input project.CreateProje
 		return nil, fmt.Errorf("group with ID %d not found", input.GID)
 	}
 
+	name, err := utils.NormalizeProjectName(input.ProjectName)
+	if err != nil {
+		return nil, err
+	}
 	p := &project.Project{
-		ProjectName: input.ProjectName,
+		ProjectName: name,
 		GID:         input.GID,
 	}
 	if input.Description != nil {
@@ -89,10 +119,15 @@ func (s *ProjectService) CreateProject(c *gin.Context, input project.CreateProje
 		p.GPUAccess = *input.GPUAccess
 	}
 	if input.MPSMemory != nil {
+		if err := validateMPSMemory(*input.MPSMemory); err != nil {
+			return nil, err
+		}
 		p.MPSMemory = *input.MPSMemory
 	}
-	err := s.Repos.Project.CreateProject(p)
-	if err != nil {
+	if input.NamespaceMode != nil {
+		p.NamespaceMode = *input.NamespaceMode
+	}
+	if err := s.Repos.Project.CreateProject(p); err != nil {
 		return nil, err
 	}
 
@@ -102,6 +137,11 @@ func (s *ProjectService) CreateProject(c *gin.Context, input project.CreateProje
 		return nil, errors.New("failed to get project ID from database")
 	}
 
+	p.StorageNamespace = ProjectStorageNamespace(p)
+	if err := s.Repos.Project.SetStorageNamespace(p.PID, p.StorageNamespace); err != nil {
+		return nil, err
+	}
+
 	logFn := utils.LogAuditWithConsole
 	go func(fn func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo)) {
 		fn(c, "create", "project", fmt.Sprintf("p_id=%d", p.PID), nil, p, "", s.Repos.Audit)
@@ -119,7 +159,11 @@ func (s *ProjectService) UpdateProject(c *gin.Context, id uint, input project.Up
 	oldProject := p
 
 	if input.ProjectName != nil {
-		p.ProjectName = *input.ProjectName
+		name, err := utils.NormalizeProjectName(*input.ProjectName)
+		if err != nil {
+			return nil, err
+		}
+		p.ProjectName = name
 	}
 	if input.Description != nil {
 		p.Description = *input.Description
@@ -134,8 +178,17 @@ func (s *ProjectService) UpdateProject(c *gin.Context, id uint, input project.Up
 		p.GPUAccess = *input.GPUAccess
 	}
 	if input.MPSMemory != nil {
+		if err := validateMPSMemory(*input.MPSMemory); err != nil {
+			return nil, err
+		}
 		p.MPSMemory = *input.MPSMemory
 	}
+	if input.NamespaceMode != nil {
+		p.NamespaceMode = *input.NamespaceMode
+	}
+	if input.RequireInstanceApproval != nil {
+		p.RequireInstanceApproval = *input.RequireInstanceApproval
+	}
 
 	err = s.Repos.Project.UpdateProject(&p)
 	if err == nil {
@@ -184,7 +237,7 @@ func (s *ProjectService) RemoveProjectResources(projectID uint) error {
 		}
 	}
 
-	projectStorageNs := k8s.GenerateSafeResourceName("project", project.ProjectName, project.PID)
+	projectStorageNs := ProjectStorageNamespace(&project)
 
 	log.Printf("Cleaning up project storage namespace: %s", projectStorageNs)
 	if err := k8s.DeleteNamespace(projectStorageNs); err != nil {
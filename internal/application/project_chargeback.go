@@ -0,0 +1,85 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+var ErrInvalidChargeback = errors.New("invalid chargeback labels")
+
+// relabelTimeout bounds the background relabel after a cost center change.
+const relabelTimeout = 5 * time.Minute
+
+// chargebackLabels are the labels the cost center and tags of p add to its objects.
+func chargebackLabels(p *project.Project) map[string]string {
+	return k8s.ChargebackLabels(p.CostCenter, p.CostTagMap())
+}
+
+// ProjectChargebackLabels returns the function k8s.ProjectLabels is set to, which reads the
+// chargeback labels of a project from the database. A project that cannot be read gets none.
+func ProjectChargebackLabels(repos *repository.Repos) func(projectID uint) map[string]string {
+	return func(projectID uint) map[string]string {
+		p, err := repos.Project.GetProjectByID(projectID)
+		if err != nil {
+			return nil
+		}
+		return chargebackLabels(&p)
+	}
+}
+
+// SetChargeback changes the cost center and cost tags of a project. Objects created afterwards
+// carry the new labels; the namespaces and PVCs the project already has are relabelled in the
+// background.
+func (s *ProjectService) SetChargeback(c *gin.Context, projectID uint, input project.ChargebackDTO) (*project.Project, error) {
+	p, err := s.Repos.Project.GetProjectByID(projectID)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	old := p
+	if input.CostCenter != nil {
+		p.CostCenter = strings.TrimSpace(*input.CostCenter)
+	}
+	if input.Tags != nil {
+		tags, err := json.Marshal(input.Tags)
+		if err != nil {
+			return nil, err
+		}
+		p.CostTags = tags
+	}
+	labels := chargebackLabels(&p)
+	if err := k8s.ValidateChargebackLabels(labels); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidChargeback, err)
+	}
+	if err := s.Repos.Project.UpdateProject(&p); err != nil {
+		return nil, err
+	}
+	utils.LogAuditWithConsole(c, "update", "project_chargeback", fmt.Sprintf("p_id=%d", projectID), old, p, "", s.Repos.Audit)
+
+	if !maps.Equal(labels, chargebackLabels(&old)) {
+		go relabelProject(projectID, labels)
+	}
+	return &p, nil
+}
+
+func relabelProject(projectID uint, labels map[string]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), relabelTimeout)
+	defer cancel()
+	updated, err := k8s.RelabelProject(ctx, projectID, labels)
+	if err != nil {
+		log.Printf("[Chargeback] relabel of project %d stopped after %d objects: %v", projectID, len(updated), err)
+		return
+	}
+	log.Printf("[Chargeback] relabelled %d objects of project %d", len(updated), projectID)
+}
@@ -0,0 +1,76 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/datatypes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateJobCarriesChargebackLabels(t *testing.T) {
+	origClient, origLabels := k8s.Clientset, k8s.ProjectLabels
+	t.Cleanup(func() { k8s.Clientset, k8s.ProjectLabels = origClient, origLabels })
+	client := k8sfake.NewSimpleClientset()
+	k8s.Clientset = client
+	svc, repos := setupJobLimits(t, project.Project{
+		PID: 7, ProjectName: "p", GID: 1,
+		CostCenter: "nsc-112-2221", CostTags: datatypes.JSON(`{"grant":"ai-2026"}`),
+	})
+	k8s.ProjectLabels = ProjectChargebackLabels(repos)
+
+	if err := submitCPUJob(svc, 2, "train"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	created, err := client.BatchV1().Jobs("proj-7-user2").Get(context.Background(), "train", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("job not created: %v", err)
+	}
+	for what, labels := range map[string]map[string]string{"job": created.Labels, "pod template": created.Spec.Template.Labels} {
+		if labels[k8s.LabelCostCenter] != "nsc-112-2221" || labels[k8s.CostTagLabelPrefix+"grant"] != "ai-2026" {
+			t.Fatalf("%s: expected the chargeback labels, got %v", what, labels)
+		}
+	}
+	jobs, _ := repos.Job.FindByProjectID(7)
+	if len(jobs) != 1 || jobs[0].CostCenter != "nsc-112-2221" {
+		t.Fatalf("expected the cost center on the job row for chargeback, got %+v", jobs)
+	}
+}
+
+func TestSetChargebackRejectsInvalidLabels(t *testing.T) {
+	origLog := utils.LogAuditWithConsole
+	t.Cleanup(func() { utils.LogAuditWithConsole = origLog })
+	utils.LogAuditWithConsole = func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo) {}
+	_, repos := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1})
+	svc := NewProjectService(repos)
+
+	bad := "grant #1"
+	if _, err := svc.SetChargeback(nil, 7, project.ChargebackDTO{CostCenter: &bad}); !errors.Is(err, ErrInvalidChargeback) {
+		t.Fatalf("expected an invalid cost center to be rejected, got %v", err)
+	}
+
+	good := "nsc-112-2221"
+	p, err := svc.SetChargeback(nil, 7, project.ChargebackDTO{CostCenter: &good, Tags: map[string]string{"grant": "ai-2026"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored, _ := repos.Project.GetProjectByID(7)
+	if p.CostCenter != good || stored.CostCenter != good || stored.CostTagMap()["grant"] != "ai-2026" {
+		t.Fatalf("expected the chargeback to be stored, got %+v", stored)
+	}
+
+	// Omitted tags are kept
+	if _, err := svc.SetChargeback(nil, 7, project.ChargebackDTO{CostCenter: &good}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored, _ := repos.Project.GetProjectByID(7); stored.CostTagMap()["grant"] != "ai-2026" {
+		t.Fatalf("expected the tags to be kept, got %s", stored.CostTags)
+	}
+}
@@ -0,0 +1,274 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var ErrProjectHasRunningJobs = errors.New("project has running jobs")
+
+const projectDeletedNote = "project deleted"
+
+// DeleteProjectCascade deletes a project together with everything that belongs to it: running
+// jobs, config files and their instances, the storage namespace, member namespaces and the
+// project-scoped image rules and pending requests. Each step is recorded on a ProjectDeletion;
+// calling it again after a failure resumes from the first step that did not complete.
+// Running jobs make it fail with ErrProjectHasRunningJobs unless force is set, in which case
+// they are cancelled first.
+func (s *ProjectService) DeleteProjectCascade(c *gin.Context, id uint, force bool) (*project.ProjectDeletion, error) {
+	d, err := s.Repos.ProjectDeletion.GetUnfinishedByProject(id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	resumed := d != nil
+	if !resumed {
+		p, err := s.Repos.Project.GetProjectByID(id)
+		if err != nil {
+			return nil, ErrProjectNotFound
+		}
+		d = &project.ProjectDeletion{ProjectID: p.PID, ProjectName: p.ProjectName, StorageNamespace: ProjectStorageNamespace(&p)}
+		if uid, err := utils.GetUserIDFromContext(c); err == nil {
+			d.RequestedBy = uid
+		}
+	}
+	d.Force = force
+
+	if !force && !d.StepDone(project.DeletionStepJobs) {
+		active, err := s.activeProjectJobs(d.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		if len(active) > 0 {
+			return nil, fmt.Errorf("%w: %d job(s) still active", ErrProjectHasRunningJobs, len(active))
+		}
+	}
+
+	d.Status = project.DeletionRunning
+	d.Error = ""
+	if resumed {
+		err = s.Repos.ProjectDeletion.Update(d)
+	} else {
+		d.SetStepList(d.StepList())
+		err = s.Repos.ProjectDeletion.Create(d)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	steps := d.StepList()
+	for i := range steps {
+		if steps[i].Status == project.DeletionDone {
+			continue
+		}
+		stepErr := s.runDeletionStep(ctx, d, steps[i].Name)
+		now := time.Now()
+		steps[i].FinishedAt = &now
+		if stepErr != nil {
+			steps[i].Status = project.DeletionFailed
+			steps[i].Error = stepErr.Error()
+			d.Status = project.DeletionFailed
+			d.Error = fmt.Sprintf("%s: %v", steps[i].Name, stepErr)
+		} else {
+			steps[i].Status = project.DeletionDone
+			steps[i].Error = ""
+		}
+		d.SetStepList(steps)
+		if stepErr != nil {
+			if err := s.Repos.ProjectDeletion.Update(d); err != nil {
+				log.Printf("[ProjectDeletion] failed to record failure of deletion %d: %v", d.ID, err)
+			}
+			return d, fmt.Errorf("project deletion step %s failed: %w", steps[i].Name, stepErr)
+		}
+		if err := s.Repos.ProjectDeletion.Update(d); err != nil {
+			return d, err
+		}
+	}
+
+	now := time.Now()
+	d.Status = project.DeletionDone
+	d.FinishedAt = &now
+	if err := s.Repos.ProjectDeletion.Update(d); err != nil {
+		return d, err
+	}
+	utils.LogAuditWithConsole(c, "delete", "project", fmt.Sprintf("p_id=%d", d.ProjectID), project.Project{PID: d.ProjectID, ProjectName: d.ProjectName}, nil, "cascade", s.Repos.Audit)
+	return d, nil
+}
+
+func (s *ProjectService) runDeletionStep(ctx context.Context, d *project.ProjectDeletion, step string) error {
+	switch step {
+	case project.DeletionStepJobs:
+		return s.cancelProjectJobs(ctx, d.ProjectID)
+	case project.DeletionStepConfigFiles:
+		return s.purgeProjectConfigFiles(d.ProjectID)
+	case project.DeletionStepStorage:
+		p := &project.Project{PID: d.ProjectID, ProjectName: d.ProjectName, StorageNamespace: d.StorageNamespace}
+		err := NewK8sService(s.Repos).DeleteProjectAllPVC(ctx, p)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	case project.DeletionStepNamespaces:
+		return s.deleteMemberNamespaces(d.ProjectID)
+	case project.DeletionStepImages:
+		return s.revokeProjectImages(d.ProjectID)
+	case project.DeletionStepProject:
+		return s.Repos.Project.DeleteProject(d.ProjectID)
+	}
+	return fmt.Errorf("unknown deletion step %q", step)
+}
+
+func (s *ProjectService) memberNamespaces(projectID uint) ([]string, error) {
+	users, err := s.Repos.User.ListUsersByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make([]string, 0, len(users))
+	for _, u := range users {
+		namespaces = append(namespaces, k8s.FormatNamespaceName(projectID, k8s.ToSafeK8sName(u.Username)))
+	}
+	return namespaces, nil
+}
+
+// activeProjectJobs returns the unfinished jobs of the project. Jobs submitted directly are only
+// linked to the project through their namespace, so member namespaces are searched as well.
+func (s *ProjectService) activeProjectJobs(projectID uint) ([]job.Job, error) {
+	jobs, err := s.Repos.Job.GetByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := s.memberNamespaces(projectID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range namespaces {
+		nsJobs, err := s.Repos.Job.FindByNamespace(ns)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, nsJobs...)
+	}
+
+	seen := map[uint]bool{}
+	active := make([]job.Job, 0, len(jobs))
+	for _, j := range jobs {
+		if seen[j.ID] || !isActiveJobStatus(j.Status) {
+			continue
+		}
+		seen[j.ID] = true
+		active = append(active, j)
+	}
+	return active, nil
+}
+
+func isActiveJobStatus(status string) bool {
+	for _, s := range job.ActiveStatuses {
+		if strings.EqualFold(status, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ProjectService) cancelProjectJobs(ctx context.Context, projectID uint) error {
+	active, err := s.activeProjectJobs(projectID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, j := range active {
+		if k8s.Clientset != nil && j.K8sJobName != "" {
+			if err := k8s.DeleteJob(ctx, j.Namespace, j.K8sJobName); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("job %d: %w", j.ID, err))
+				continue
+			}
+		}
+		if err := s.Repos.Job.UpdateStatus(j.ID, string(job.StatusCancelled)); err != nil {
+			errs = append(errs, fmt.Errorf("job %d: %w", j.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// purgeProjectConfigFiles permanently deletes the project's config files, trashed ones included.
+func (s *ProjectService) purgeProjectConfigFiles(projectID uint) error {
+	files, err := s.Repos.ConfigFile.GetConfigFilesByProjectID(projectID)
+	if err != nil {
+		return err
+	}
+	trashed, err := s.Repos.ConfigFile.ListTrashedConfigFiles(projectID)
+	if err != nil {
+		return err
+	}
+	files = append(files, trashed...)
+
+	cfs := &ConfigFileService{Repos: s.Repos}
+	var errs []error
+	for i := range files {
+		if err := cfs.purgeConfigFile(&files[i], projectDeletedNote); err != nil {
+			errs = append(errs, fmt.Errorf("config file %d: %w", files[i].CFID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ProjectService) deleteMemberNamespaces(projectID uint) error {
+	namespaces, err := s.memberNamespaces(projectID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ns := range namespaces {
+		if err := k8s.DeleteNamespace(ns); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// revokeProjectImages disables the project's own allow-list rules and rejects its pending image
+// requests. Global rules are left alone.
+func (s *ProjectService) revokeProjectImages(projectID uint) error {
+	rules, err := s.Repos.Image.ListAllowedImages(&projectID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.ProjectID == nil || *rule.ProjectID != projectID {
+			continue
+		}
+		if err := s.Repos.Image.DisableAllowListRule(rule.ID); err != nil {
+			return err
+		}
+	}
+
+	requests, err := s.Repos.Image.ListRequests(&projectID, "pending")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range requests {
+		requests[i].Status = "rejected"
+		requests[i].ReviewedAt = &now
+		requests[i].ReviewerNote = projectDeletedNote
+		if err := s.Repos.Image.UpdateRequest(&requests[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
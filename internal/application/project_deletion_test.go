@@ -0,0 +1,165 @@
+package application
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/audit"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/domain/view"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/internal/repository/mock"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func setupProjectDeletion(t *testing.T) (*ProjectService, *gorm.DB, *gin.Context, *project.Project) {
+	t.Helper()
+	db := dbtest.Open(t, &project.Project{}, &project.ProjectDeletion{}, &configfile.ConfigFile{}, &resource.Resource{},
+		&job.Job{}, &audit.AuditLog{}, &image.ContainerRepository{}, &image.ContainerTag{}, &image.ImageAllowList{}, &image.ImageRequest{})
+
+	ctrl := gomock.NewController(t)
+	users := mock.NewMockUserRepo(ctrl)
+	users.EXPECT().ListUsersByProjectID(gomock.Any()).Return([]view.ProjectUserView{{Username: "bob"}}, nil).AnyTimes()
+	repos := repository.NewRepositories(db)
+	repos.User = users
+
+	origLog := utils.LogAuditWithConsole
+	t.Cleanup(func() { utils.LogAuditWithConsole = origLog })
+	utils.LogAuditWithConsole = func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo) {}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodDelete, "/", nil)
+
+	p := &project.Project{ProjectName: "vision", GID: 1}
+	db.Create(p)
+	return NewProjectService(repos), db, c, p
+}
+
+func TestDeleteProjectCascadeRefusesRunningJobs(t *testing.T) {
+	svc, db, c, p := setupProjectDeletion(t)
+	// Submitted directly, so only the member namespace links it to the project
+	running := job.Job{UserID: 1, Name: "train", Namespace: k8s.FormatNamespaceName(p.PID, "bob"), Image: "busybox", K8sJobName: "train", Status: "Running"}
+	db.Create(&running)
+
+	if _, err := svc.DeleteProjectCascade(c, p.PID, false); !errors.Is(err, ErrProjectHasRunningJobs) {
+		t.Fatalf("expected ErrProjectHasRunningJobs, got %v", err)
+	}
+	var deletions int64
+	db.Model(&project.ProjectDeletion{}).Count(&deletions)
+	if deletions != 0 {
+		t.Fatalf("a refused deletion must not be recorded, found %d", deletions)
+	}
+	if _, err := svc.GetProject(p.PID); err != nil {
+		t.Fatalf("project must survive a refused deletion: %v", err)
+	}
+
+	d, err := svc.DeleteProjectCascade(c, p.PID, true)
+	if err != nil {
+		t.Fatalf("forced deletion failed: %v", err)
+	}
+	if d.Status != project.DeletionDone {
+		t.Fatalf("expected deletion to be done, got %s", d.Status)
+	}
+	var cancelled job.Job
+	db.First(&cancelled, running.ID)
+	if cancelled.Status != string(job.StatusCancelled) {
+		t.Fatalf("expected the running job to be cancelled, got %s", cancelled.Status)
+	}
+	if _, err := svc.GetProject(p.PID); !errors.Is(err, ErrProjectNotFound) {
+		t.Fatalf("expected the project to be deleted, got %v", err)
+	}
+}
+
+func TestDeleteProjectCascadeResumesAfterFailure(t *testing.T) {
+	svc, db, c, p := setupProjectDeletion(t)
+	cf := configfile.ConfigFile{Filename: "train.yaml", Content: "{}", ProjectID: p.PID}
+	db.Create(&cf)
+	repo := image.ContainerRepository{FullName: "pytorch/pytorch", Name: "pytorch"}
+	db.Create(&repo)
+	pid := p.PID
+	rule := image.ImageAllowList{ProjectID: &pid, RepositoryID: repo.ID, IsEnabled: true}
+	global := image.ImageAllowList{RepositoryID: repo.ID, IsEnabled: true}
+	db.Create(&rule)
+	db.Create(&global)
+	req := image.ImageRequest{UserID: 1, ProjectID: &pid, InputImageName: "pytorch/pytorch", Status: "pending"}
+	db.Create(&req)
+
+	storageNs := ProjectStorageNamespace(p)
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	fake := k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: storageNs}})
+	failures := 1
+	fake.PrependReactor("delete", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.DeleteAction).GetName() == storageNs && failures > 0 {
+			failures--
+			return true, nil, errors.New("etcd unavailable")
+		}
+		return false, nil, nil
+	})
+	k8s.Clientset = fake
+
+	d, err := svc.DeleteProjectCascade(c, p.PID, false)
+	if err == nil {
+		t.Fatalf("expected the storage step to fail")
+	}
+	if d.Status != project.DeletionFailed {
+		t.Fatalf("expected a failed deletion, got %s", d.Status)
+	}
+	steps := d.StepList()
+	if steps[0].Status != project.DeletionDone || steps[1].Status != project.DeletionDone || steps[2].Status != project.DeletionFailed || steps[3].Status != project.DeletionPending {
+		t.Fatalf("unexpected step states %+v", steps)
+	}
+	if _, err := svc.GetProject(p.PID); err != nil {
+		t.Fatalf("project must remain until every step succeeded: %v", err)
+	}
+	var files int64
+	db.Unscoped().Model(&configfile.ConfigFile{}).Where("project_id = ?", p.PID).Count(&files)
+	if files != 0 {
+		t.Fatalf("config files should already be purged, found %d", files)
+	}
+
+	resumed, err := svc.DeleteProjectCascade(c, p.PID, false)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if resumed.ID != d.ID || resumed.Status != project.DeletionDone {
+		t.Fatalf("expected deletion %d to be resumed and finished, got %d %s", d.ID, resumed.ID, resumed.Status)
+	}
+	for _, step := range resumed.StepList() {
+		if step.Status != project.DeletionDone {
+			t.Fatalf("step %s not done: %+v", step.Name, step)
+		}
+	}
+	if _, err := fake.CoreV1().Namespaces().Get(c.Request.Context(), storageNs, metav1.GetOptions{}); err == nil {
+		t.Fatalf("storage namespace should be deleted")
+	}
+	if _, err := svc.GetProject(p.PID); !errors.Is(err, ErrProjectNotFound) {
+		t.Fatalf("expected the project to be deleted, got %v", err)
+	}
+
+	db.First(&rule, rule.ID)
+	db.First(&global, global.ID)
+	if rule.IsEnabled || !global.IsEnabled {
+		t.Fatalf("only the project rule should be revoked, got project=%v global=%v", rule.IsEnabled, global.IsEnabled)
+	}
+	db.First(&req, req.ID)
+	if req.Status != "rejected" {
+		t.Fatalf("pending request should be rejected, got %s", req.Status)
+	}
+}
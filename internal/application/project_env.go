@@ -0,0 +1,158 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var ErrInvalidEnvKey = errors.New("invalid environment variable name")
+
+const maskedEnvValue = "******"
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ProjectEnvSecretName is the Secret generated in each project namespace for secret env defaults.
+func ProjectEnvSecretName(projectID uint) string {
+	return fmt.Sprintf("project-%d-env", projectID)
+}
+
+// ListEnvDefaults returns the project's env defaults with secret values masked.
+func (s *ProjectService) ListEnvDefaults(projectID uint) ([]project.ProjectEnvDefault, error) {
+	envs, err := s.Repos.ProjectEnv.ListByProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range envs {
+		if envs[i].Secret {
+			envs[i].Value = maskedEnvValue
+		}
+	}
+	return envs, nil
+}
+
+// SetEnvDefault creates or overwrites a project env default.
+func (s *ProjectService) SetEnvDefault(c *gin.Context, projectID uint, input project.UpsertProjectEnvDefaultDTO) (*project.ProjectEnvDefault, error) {
+	if _, err := s.Repos.Project.GetProjectByID(projectID); err != nil {
+		return nil, ErrProjectNotFound
+	}
+	if !envKeyPattern.MatchString(input.Key) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidEnvKey, input.Key)
+	}
+
+	env := &project.ProjectEnvDefault{
+		ProjectID: projectID,
+		Key:       input.Key,
+		Value:     input.Value,
+		Secret:    input.Secret,
+	}
+	if err := s.Repos.ProjectEnv.Upsert(env); err != nil {
+		return nil, err
+	}
+
+	logged := *env
+	if logged.Secret {
+		logged.Value = maskedEnvValue
+	}
+	utils.LogAuditWithConsole(c, "update", "project_env", fmt.Sprintf("p_id=%d,key=%s", projectID, env.Key), nil, logged, "", s.Repos.Audit)
+	return &logged, nil
+}
+
+// DeleteEnvDefault removes a project env default. Running workloads keep their current env.
+func (s *ProjectService) DeleteEnvDefault(c *gin.Context, projectID uint, key string) error {
+	if err := s.Repos.ProjectEnv.Delete(projectID, key); err != nil {
+		return err
+	}
+	utils.LogAuditWithConsole(c, "delete", "project_env", fmt.Sprintf("p_id=%d,key=%s", projectID, key), nil, nil, "", s.Repos.Audit)
+	return nil
+}
+
+// resolveProjectEnv loads the project's env defaults and returns them as container env vars.
+// Secret values are written to the project env Secret in ns and referenced through valueFrom.
+func resolveProjectEnv(ctx context.Context, repo repository.ProjectEnvRepo, projectID uint, ns string) ([]corev1.EnvVar, error) {
+	defaults, err := repo.ListByProject(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project env defaults: %w", err)
+	}
+	if len(defaults) == 0 {
+		return nil, nil
+	}
+
+	envs, secretData := projectEnvVars(defaults, projectID)
+	if len(secretData) > 0 {
+		labels := map[string]string{"project-id": fmt.Sprintf("%d", projectID)}
+		if err := k8s.EnsureOpaqueSecret(ctx, ns, ProjectEnvSecretName(projectID), secretData, labels); err != nil {
+			return nil, err
+		}
+	}
+	return envs, nil
+}
+
+// projectEnvVars turns env defaults into container env vars. Secret values are returned
+// separately and referenced from the project env Secret.
+func projectEnvVars(defaults []project.ProjectEnvDefault, projectID uint) ([]corev1.EnvVar, map[string]string) {
+	secretName := ProjectEnvSecretName(projectID)
+	secretData := make(map[string]string)
+	envs := make([]corev1.EnvVar, 0, len(defaults))
+	for _, d := range defaults {
+		if !d.Secret {
+			envs = append(envs, corev1.EnvVar{Name: d.Key, Value: d.Value})
+			continue
+		}
+		secretData[d.Key] = d.Value
+		envs = append(envs, corev1.EnvVar{
+			Name: d.Key,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  d.Key,
+				},
+			},
+		})
+	}
+	return envs, secretData
+}
+
+// patchEnvDefaults appends project env defaults to every container that does not already define the key.
+func (s *ConfigFileService) patchEnvDefaults(podSpec map[string]interface{}, defaults []corev1.EnvVar) {
+	for _, c := range getContainersFromPodSpec(podSpec) {
+		env, _ := c["env"].([]interface{})
+		defined := make(map[string]bool, len(env))
+		for _, e := range env {
+			if m, ok := e.(map[string]interface{}); ok {
+				if name, _ := m["name"].(string); name != "" {
+					defined[name] = true
+				}
+			}
+		}
+
+		for _, d := range defaults {
+			if defined[d.Name] {
+				continue
+			}
+			entry := map[string]interface{}{"name": d.Name}
+			if d.ValueFrom != nil && d.ValueFrom.SecretKeyRef != nil {
+				entry["valueFrom"] = map[string]interface{}{
+					"secretKeyRef": map[string]interface{}{
+						"name": d.ValueFrom.SecretKeyRef.Name,
+						"key":  d.ValueFrom.SecretKeyRef.Key,
+					},
+				}
+			} else {
+				entry["value"] = d.Value
+			}
+			env = append(env, entry)
+		}
+		if len(env) > 0 {
+			c["env"] = env
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func setupProjectEnvRepos(t *testing.T) *repository.Repos {
+	t.Helper()
+	db := dbtest.Open(t, &project.ProjectEnvDefault{}, &project.SchedulingPolicy{}, &project.RegistryCredential{}, &job.Job{})
+	repos := repository.NewRepositories(db)
+	for _, e := range []project.ProjectEnvDefault{
+		{ProjectID: 7, Key: "DATASET_ROOT", Value: "/data/shared"},
+		{ProjectID: 7, Key: "WANDB_PROJECT", Value: "default-project"},
+		{ProjectID: 7, Key: "WANDB_API_KEY", Value: "s3cr3t", Secret: true},
+	} {
+		e := e
+		if err := repos.ProjectEnv.Upsert(&e); err != nil {
+			t.Fatalf("failed to seed env default: %v", err)
+		}
+	}
+	return repos
+}
+
+func envByName(env []interface{}) map[string]map[string]interface{} {
+	out := make(map[string]map[string]interface{})
+	for _, e := range env {
+		m := e.(map[string]interface{})
+		out[m["name"].(string)] = m
+	}
+	return out
+}
+
+func TestPatchEnvDefaultsAcrossWorkloadKinds(t *testing.T) {
+	container := `{"name":"main","image":"busybox","env":[{"name":"WANDB_PROJECT","value":"mine"}]}`
+	podSpec := `{"containers":[` + container + `]}`
+	manifests := map[string]string{
+		"Pod":         `{"kind":"Pod","spec":` + podSpec + `}`,
+		"Deployment":  `{"kind":"Deployment","spec":{"template":{"spec":` + podSpec + `}}}`,
+		"StatefulSet": `{"kind":"StatefulSet","spec":{"template":{"spec":` + podSpec + `}}}`,
+		"Job":         `{"kind":"Job","spec":{"template":{"spec":` + podSpec + `}}}`,
+		"CronJob":     `{"kind":"CronJob","spec":{"jobTemplate":{"spec":{"template":{"spec":` + podSpec + `}}}}}`,
+	}
+
+	defaults := []corev1.EnvVar{
+		{Name: "WANDB_PROJECT", Value: "default-project"},
+		{Name: "DATASET_ROOT", Value: "/data/shared"},
+		{Name: "WANDB_API_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: ProjectEnvSecretName(7)},
+			Key:                  "WANDB_API_KEY",
+		}}},
+	}
+
+	svc := &ConfigFileService{}
+	for kind, manifest := range manifests {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(manifest), &obj); err != nil {
+			t.Fatalf("%s: bad manifest: %v", kind, err)
+		}
+		specs := findPodSpecs(obj)
+		if len(specs) != 1 {
+			t.Fatalf("%s: expected 1 pod spec, got %d", kind, len(specs))
+		}
+		svc.patchEnvDefaults(specs[0], defaults)
+
+		env := envByName(getContainersFromPodSpec(specs[0])[0]["env"].([]interface{}))
+		if len(env) != 3 {
+			t.Fatalf("%s: expected 3 env vars, got %v", kind, env)
+		}
+		if env["WANDB_PROJECT"]["value"] != "mine" {
+			t.Errorf("%s: user value should win, got %v", kind, env["WANDB_PROJECT"])
+		}
+		if env["DATASET_ROOT"]["value"] != "/data/shared" {
+			t.Errorf("%s: default not injected, got %v", kind, env["DATASET_ROOT"])
+		}
+		ref, ok := env["WANDB_API_KEY"]["valueFrom"].(map[string]interface{})
+		if !ok || env["WANDB_API_KEY"]["value"] != nil {
+			t.Fatalf("%s: secret default must use valueFrom, got %v", kind, env["WANDB_API_KEY"])
+		}
+		keyRef := ref["secretKeyRef"].(map[string]interface{})
+		if keyRef["name"] != ProjectEnvSecretName(7) || keyRef["key"] != "WANDB_API_KEY" {
+			t.Errorf("%s: unexpected secretKeyRef %v", kind, keyRef)
+		}
+	}
+}
+
+func TestResolveProjectEnvCreatesSecret(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	k8s.Clientset = k8sfake.NewSimpleClientset()
+
+	repos := setupProjectEnvRepos(t)
+	ctx := context.Background()
+	ns := "proj-7-alice"
+
+	envs, err := resolveProjectEnv(ctx, repos.ProjectEnv, 7, ns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(envs) != 3 {
+		t.Fatalf("expected 3 env vars, got %d", len(envs))
+	}
+	for _, e := range envs {
+		if e.Name == "WANDB_API_KEY" {
+			if e.Value != "" || e.ValueFrom == nil || e.ValueFrom.SecretKeyRef.Name != ProjectEnvSecretName(7) {
+				t.Fatalf("secret value must not be inline: %+v", e)
+			}
+		}
+	}
+
+	secret, err := k8s.Clientset.CoreV1().Secrets(ns).Get(ctx, ProjectEnvSecretName(7), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected env secret to be created: %v", err)
+	}
+	if secret.StringData["WANDB_API_KEY"] != "s3cr3t" || len(secret.StringData) != 1 {
+		t.Fatalf("secret should hold only secret-flagged keys, got %v", secret.StringData)
+	}
+
+	// Rotating the value updates the existing secret
+	if err := repos.ProjectEnv.Upsert(&project.ProjectEnvDefault{ProjectID: 7, Key: "WANDB_API_KEY", Value: "rotated", Secret: true}); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if _, err := resolveProjectEnv(ctx, repos.ProjectEnv, 7, ns); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secret, _ = k8s.Clientset.CoreV1().Secrets(ns).Get(ctx, ProjectEnvSecretName(7), metav1.GetOptions{})
+	if secret.StringData["WANDB_API_KEY"] != "rotated" {
+		t.Fatalf("expected rotated secret value, got %v", secret.StringData)
+	}
+}
+
+func TestCreateJobInjectsProjectEnv(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	k8s.Clientset = k8sfake.NewSimpleClientset()
+
+	repos := setupProjectEnvRepos(t)
+	svc := NewK8sService(repos)
+	ctx := context.Background()
+
+	_, err := svc.CreateJob(ctx, 1, job.JobSubmission{
+		Name:      "train",
+		Namespace: "proj-7-alice",
+		Image:     "busybox:latest",
+		Env:       map[string]string{"DATASET_ROOT": "/data/mine"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	created, err := k8s.Clientset.BatchV1().Jobs("proj-7-alice").Get(ctx, "train", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("job not created: %v", err)
+	}
+	env := map[string]corev1.EnvVar{}
+	for _, e := range created.Spec.Template.Spec.Containers[0].Env {
+		if _, dup := env[e.Name]; dup {
+			t.Fatalf("duplicate env var %s", e.Name)
+		}
+		env[e.Name] = e
+	}
+	if env["DATASET_ROOT"].Value != "/data/mine" {
+		t.Errorf("user value should win, got %q", env["DATASET_ROOT"].Value)
+	}
+	if env["WANDB_PROJECT"].Value != "default-project" {
+		t.Errorf("default not injected, got %q", env["WANDB_PROJECT"].Value)
+	}
+	if env["WANDB_API_KEY"].ValueFrom == nil || env["WANDB_API_KEY"].Value != "" {
+		t.Errorf("secret default must use valueFrom, got %+v", env["WANDB_API_KEY"])
+	}
+}
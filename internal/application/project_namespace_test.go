@@ -0,0 +1,64 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBackfillStorageNamespaces(t *testing.T) {
+	svc, db, _, p := setupProjectDeletion(t)
+	stored := project.Project{ProjectName: "nlp", GID: 1, StorageNamespace: "project-nlp-legacy"}
+	db.Create(&stored)
+
+	n, err := svc.BackfillStorageNamespaces()
+	if err != nil || n != 1 {
+		t.Fatalf("expected one project to be backfilled, got %d (%v)", n, err)
+	}
+	got, _ := svc.GetProject(p.PID)
+	if want := k8s.GenerateSafeResourceName("project", "vision", p.PID); got.StorageNamespace != want {
+		t.Fatalf("expected the namespace derived from the current name %q, got %q", want, got.StorageNamespace)
+	}
+	other, _ := svc.GetProject(stored.PID)
+	if other.StorageNamespace != "project-nlp-legacy" {
+		t.Fatalf("a recorded namespace must not be recomputed, got %q", other.StorageNamespace)
+	}
+	if n, _ := svc.BackfillStorageNamespaces(); n != 0 {
+		t.Fatalf("expected the backfill to be a no-op the second time, got %d", n)
+	}
+}
+
+func TestRenameKeepsStorageNamespace(t *testing.T) {
+	svc, _, c, p := setupProjectDeletion(t)
+	if _, err := svc.BackfillStorageNamespaces(); err != nil {
+		t.Fatalf("backfill failed: %v", err)
+	}
+	before, _ := svc.GetProject(p.PID)
+	original := ProjectStorageNamespace(before)
+
+	renamed := "vision-2026"
+	if _, err := svc.UpdateProject(c, p.PID, project.UpdateProjectDTO{ProjectName: &renamed}); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	after, _ := svc.GetProject(p.PID)
+	if ns := ProjectStorageNamespace(after); ns != original {
+		t.Fatalf("a rename must not move the storage namespace: %q became %q", original, ns)
+	}
+
+	// Cleanup after the rename still finds the original namespace
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	k8s.Clientset = k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: original}})
+	if err := NewK8sService(svc.Repos).DeleteProjectAllPVC(context.Background(), after); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := k8s.Clientset.CoreV1().Namespaces().Get(context.Background(), original, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the original storage namespace to be deleted, got %v", err)
+	}
+}
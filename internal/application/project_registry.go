@@ -0,0 +1,207 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/registry"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRegistryHost        = errors.New("invalid registry host")
+	ErrRegistryCredentialNotFound = errors.New("registry credential not found")
+	ErrRegistryLoginFailed        = errors.New("registry rejected the credentials")
+)
+
+// registryLogin checks credentials against a registry's auth endpoint; tests replace it.
+var registryLogin = registry.NewClient(nil).CheckCredentials
+
+// ProjectPullSecretName is the image pull secret generated from a project's registry credentials,
+// in each project namespace and in the image pull namespace.
+func ProjectPullSecretName(projectID uint) string {
+	return fmt.Sprintf("project-%d-registry", projectID)
+}
+
+func credentialKey() string {
+	if config.CredentialEncryptionKey != "" {
+		return config.CredentialEncryptionKey
+	}
+	return config.JwtSecret
+}
+
+// maskRegistryToken hides a token, keeping the last four characters of long ones so
+// managers can tell tokens apart.
+func maskRegistryToken(token string) string {
+	if len(token) <= 8 {
+		return maskedEnvValue
+	}
+	return maskedEnvValue + token[len(token)-4:]
+}
+
+func registryCredentialDTO(cred project.RegistryCredential) project.RegistryCredentialDTO {
+	token, err := utils.DecryptString(credentialKey(), cred.EncryptedToken)
+	if err != nil {
+		token = ""
+	}
+	return project.RegistryCredentialDTO{
+		ID:        cred.ID,
+		ProjectID: cred.ProjectID,
+		Registry:  cred.Registry,
+		Username:  cred.Username,
+		Token:     maskRegistryToken(token),
+		CreatedBy: cred.CreatedBy,
+		CreatedAt: cred.CreatedAt,
+		UpdatedAt: cred.UpdatedAt,
+	}
+}
+
+// ListRegistryCredentials returns the project's registry credentials with the tokens masked.
+func (s *ProjectService) ListRegistryCredentials(projectID uint) ([]project.RegistryCredentialDTO, error) {
+	creds, err := s.Repos.Registry.ListByProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]project.RegistryCredentialDTO, 0, len(creds))
+	for _, cred := range creds {
+		out = append(out, registryCredentialDTO(cred))
+	}
+	return out, nil
+}
+
+// SetRegistryCredential stores, or replaces, the project's credentials for a registry host.
+// Workloads pick them up the next time they are created.
+func (s *ProjectService) SetRegistryCredential(c *gin.Context, projectID, uid uint, input project.UpsertRegistryCredentialDTO) (*project.RegistryCredentialDTO, error) {
+	if _, err := s.Repos.Project.GetProjectByID(projectID); err != nil {
+		return nil, ErrProjectNotFound
+	}
+	host := k8s.NormalizeRegistryHost(input.Registry)
+	if host == "" || strings.ContainsAny(host, " \t") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRegistryHost, input.Registry)
+	}
+	encrypted, err := utils.EncryptString(credentialKey(), input.Token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt registry token: %w", err)
+	}
+
+	cred := &project.RegistryCredential{
+		ProjectID:      projectID,
+		Registry:       host,
+		Username:       strings.TrimSpace(input.Username),
+		EncryptedToken: encrypted,
+		CreatedBy:      uid,
+	}
+	if err := s.Repos.Registry.Upsert(cred); err != nil {
+		return nil, err
+	}
+
+	dto := registryCredentialDTO(*cred)
+	utils.LogAuditWithConsole(c, "update", "project_registry", fmt.Sprintf("p_id=%d,registry=%s", projectID, host), nil, dto, "", s.Repos.Audit)
+	return &dto, nil
+}
+
+// DeleteRegistryCredential removes a credential and the pull secrets derived from the project's
+// credentials. Secrets for the remaining credentials are recreated by the next workload.
+func (s *ProjectService) DeleteRegistryCredential(c *gin.Context, projectID, id uint) error {
+	if err := s.Repos.Registry.Delete(projectID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRegistryCredentialNotFound
+		}
+		return err
+	}
+
+	ctx := c.Request.Context()
+	name := ProjectPullSecretName(projectID)
+	namespaces, err := k8s.ListProjectNamespaces(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("credential deleted but its pull secrets were not removed: %w", err)
+	}
+	targets := []string{config.ImagePullNamespace}
+	for _, ns := range namespaces {
+		targets = append(targets, ns.Name)
+	}
+	var errs []error
+	for _, ns := range targets {
+		if err := k8s.DeleteSecretIfExists(ctx, ns, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	utils.LogAuditWithConsole(c, "delete", "project_registry", fmt.Sprintf("p_id=%d,id=%d", projectID, id), nil, nil, "", s.Repos.Audit)
+	return errors.Join(errs...)
+}
+
+// ValidateRegistryCredential logs in to the credential's registry with the stored token.
+func (s *ProjectService) ValidateRegistryCredential(ctx context.Context, projectID, id uint) error {
+	cred, err := s.Repos.Registry.GetByID(projectID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRegistryCredentialNotFound
+		}
+		return err
+	}
+	token, err := utils.DecryptString(credentialKey(), cred.EncryptedToken)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt registry token: %w", err)
+	}
+	if err := registryLogin(ctx, cred.Registry, cred.Username, token); err != nil {
+		if errors.Is(err, registry.ErrUnauthorized) {
+			return fmt.Errorf("%w: %s", ErrRegistryLoginFailed, cred.Registry)
+		}
+		return fmt.Errorf("failed to reach %s: %w", cred.Registry, err)
+	}
+	return nil
+}
+
+// projectRegistryAuths decrypts the project's registry credentials.
+func projectRegistryAuths(repo repository.RegistryCredentialRepo, projectID uint) ([]k8s.RegistryAuth, error) {
+	if repo == nil || projectID == 0 {
+		return nil, nil
+	}
+	creds, err := repo.ListByProject(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registry credentials: %w", err)
+	}
+	auths := make([]k8s.RegistryAuth, 0, len(creds))
+	for _, cred := range creds {
+		token, err := utils.DecryptString(credentialKey(), cred.EncryptedToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt the credential for %s: %w", cred.Registry, err)
+		}
+		auths = append(auths, k8s.RegistryAuth{Registry: cred.Registry, Username: cred.Username, Password: token})
+	}
+	return auths, nil
+}
+
+// authsForImages keeps the credentials whose registry hosts one of images.
+func authsForImages(auths []k8s.RegistryAuth, images ...string) []k8s.RegistryAuth {
+	hosts := make(map[string]bool, len(images))
+	for _, img := range images {
+		hosts[k8s.ImageRegistryHost(img)] = true
+	}
+	var matched []k8s.RegistryAuth
+	for _, a := range auths {
+		if hosts[k8s.NormalizeRegistryHost(a.Registry)] {
+			matched = append(matched, a)
+		}
+	}
+	return matched
+}
+
+// ensureProjectPullSecret writes the project pull secret holding auths into ns.
+func ensureProjectPullSecret(ctx context.Context, ns string, projectID uint, auths []k8s.RegistryAuth) error {
+	dockerConfig, err := k8s.DockerConfigJSON(nil, auths)
+	if err != nil {
+		return err
+	}
+	labels := map[string]string{"project-id": fmt.Sprintf("%d", projectID)}
+	return k8s.EnsureDockerConfigSecret(ctx, ns, ProjectPullSecretName(projectID), dockerConfig, labels)
+}
@@ -0,0 +1,74 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+func seedRegistryCredential(t *testing.T, repos *repository.Repos, projectID uint, host, user, token string) {
+	t.Helper()
+	if credentialKey() == "" {
+		old := config.CredentialEncryptionKey
+		config.CredentialEncryptionKey = "test-credential-key"
+		t.Cleanup(func() { config.CredentialEncryptionKey = old })
+	}
+	encrypted, err := utils.EncryptString(credentialKey(), token)
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	cred := &project.RegistryCredential{ProjectID: projectID, Registry: host, Username: user, EncryptedToken: encrypted}
+	if err := repos.Registry.Upsert(cred); err != nil {
+		t.Fatalf("failed to seed credential: %v", err)
+	}
+}
+
+func TestListRegistryCredentialsMasksTokens(t *testing.T) {
+	repos := setupProjectEnvRepos(t)
+	seedRegistryCredential(t, repos, 7, "ghcr.io", "bot", "ghp_abcdefgh1234")
+	seedRegistryCredential(t, repos, 7, "quay.io", "robot", "short")
+	seedRegistryCredential(t, repos, 8, "ghcr.io", "other", "ghp_zzzzzzzz9999")
+
+	creds, err := NewProjectService(repos).ListRegistryCredentials(7)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(creds) != 2 {
+		t.Fatalf("expected the project's two credentials, got %+v", creds)
+	}
+	if creds[0].Registry != "ghcr.io" || creds[0].Token != "******1234" {
+		t.Fatalf("expected a long token to keep its last four characters, got %+v", creds[0])
+	}
+	if creds[1].Token != "******" {
+		t.Fatalf("expected a short token to be fully masked, got %+v", creds[1])
+	}
+}
+
+func TestPatchProjectPullSecretMatchesImageRegistry(t *testing.T) {
+	repos := setupProjectEnvRepos(t)
+	seedRegistryCredential(t, repos, 7, "ghcr.io", "bot", "token")
+	auths, err := projectRegistryAuths(repos.Registry, 7)
+	if err != nil || len(auths) != 1 || auths[0].Password != "token" {
+		t.Fatalf("expected the decrypted credential, got %+v (%v)", auths, err)
+	}
+	ctx := &PatchContext{ProjectID: 7, RegistryAuths: auths}
+
+	public := map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "main", "image": "nginx:1.25"}}}
+	if patchProjectPullSecret(public, ctx) || public["imagePullSecrets"] != nil {
+		t.Fatalf("images on other registries must not get the project secret, got %v", public["imagePullSecrets"])
+	}
+
+	private := map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "main", "image": "ghcr.io/lab/trainer:v1"}}}
+	for i := 0; i < 2; i++ {
+		if !patchProjectPullSecret(private, ctx) {
+			t.Fatal("expected the private image to use the project secret")
+		}
+	}
+	secrets, _ := private["imagePullSecrets"].([]interface{})
+	if len(secrets) != 1 || secrets[0].(map[string]interface{})["name"] != ProjectPullSecretName(7) {
+		t.Fatalf("expected one reference to the project secret, got %v", secrets)
+	}
+}
@@ -0,0 +1,186 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/utils"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+var ErrInvalidResourceDefaults = errors.New("invalid resource defaults")
+
+// ResourceDefaults are the CPU and memory requests given to containers that set none. An unset
+// request is not injected; a Multiplier of 0 sets no limits.
+type ResourceDefaults struct {
+	CPU        *k8sresource.Quantity
+	Memory     *k8sresource.Quantity
+	Multiplier float64
+}
+
+// InjectedResources records the requests and limits given to one container of a rendered instance.
+type InjectedResources struct {
+	// Resource is the Kind/name of the object holding the container
+	Resource  string            `json:"resource"`
+	Container string            `json:"container"`
+	Requests  map[string]string `json:"requests"`
+	Limits    map[string]string `json:"limits,omitempty"`
+}
+
+// projectResourceDefaults returns the defaults for the containers of p, falling back to the
+// platform defaults, or nil when the project turned injection off.
+func projectResourceDefaults(p project.Project) (*ResourceDefaults, error) {
+	if p.ResourceDefaultsDisabled {
+		return nil, nil
+	}
+	cpu, memory, multiplier := p.DefaultCPURequest, p.DefaultMemoryRequest, p.ResourceLimitMultiplier
+	if cpu == "" {
+		cpu = config.DefaultContainerCPURequest
+	}
+	if memory == "" {
+		memory = config.DefaultContainerMemoryRequest
+	}
+	if multiplier == 0 {
+		multiplier = config.DefaultResourceLimitMultiplier
+	}
+
+	d := &ResourceDefaults{Multiplier: multiplier}
+	var err error
+	if d.CPU, err = parseResourceDefault("cpu", cpu); err != nil {
+		return nil, err
+	}
+	if d.Memory, err = parseResourceDefault("memory", memory); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// parseResourceDefault parses a default request; an empty one is not injected.
+func parseResourceDefault(name, value string) (*k8sresource.Quantity, error) {
+	if value == "" {
+		return nil, nil
+	}
+	q, err := k8sresource.ParseQuantity(value)
+	if err != nil || q.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %s request %q must be a positive quantity", ErrInvalidResourceDefaults, name, value)
+	}
+	return &q, nil
+}
+
+// scaleQuantity multiplies q, rounding up to the next millicore for CPU and byte for memory.
+func scaleQuantity(q k8sresource.Quantity, multiplier float64, milli bool) k8sresource.Quantity {
+	if milli {
+		return *k8sresource.NewMilliQuantity(int64(math.Ceil(float64(q.MilliValue())*multiplier)), q.Format)
+	}
+	return *k8sresource.NewQuantity(int64(math.Ceil(float64(q.Value())*multiplier)), q.Format)
+}
+
+// containerSetsResources reports whether a container requests or limits CPU or memory itself.
+// GPU requests alone do not count: the GPU fields are left to the MPS patch.
+func containerSetsResources(c map[string]interface{}) bool {
+	res, _ := c["resources"].(map[string]interface{})
+	for _, key := range []string{"requests", "limits"} {
+		m, _ := res[key].(map[string]interface{})
+		if _, ok := m["cpu"]; ok {
+			return true
+		}
+		if _, ok := m["memory"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// patchResourceDefaults gives every container of podSpec that sets no CPU or memory the default
+// requests, and limits of Multiplier times them. It returns what it injected, without Resource set.
+func patchResourceDefaults(podSpec map[string]interface{}, d *ResourceDefaults) []InjectedResources {
+	if d == nil || (d.CPU == nil && d.Memory == nil) {
+		return nil
+	}
+	var injected []InjectedResources
+	for _, c := range getContainersFromPodSpec(podSpec) {
+		if containerSetsResources(c) {
+			continue
+		}
+		res, ok := c["resources"].(map[string]interface{})
+		if !ok {
+			res = map[string]interface{}{}
+			c["resources"] = res
+		}
+		requests, ok := res["requests"].(map[string]interface{})
+		if !ok {
+			requests = map[string]interface{}{}
+			res["requests"] = requests
+		}
+		limits, _ := res["limits"].(map[string]interface{})
+
+		name, _ := c["name"].(string)
+		record := InjectedResources{Container: name, Requests: map[string]string{}}
+		set := func(key string, q *k8sresource.Quantity, milli bool) {
+			if q == nil {
+				return
+			}
+			requests[key] = q.String()
+			record.Requests[key] = q.String()
+			if d.Multiplier <= 0 {
+				return
+			}
+			if limits == nil {
+				limits = map[string]interface{}{}
+				res["limits"] = limits
+			}
+			limit := scaleQuantity(*q, d.Multiplier, milli)
+			limits[key] = limit.String()
+			if record.Limits == nil {
+				record.Limits = map[string]string{}
+			}
+			record.Limits[key] = limit.String()
+		}
+		set("cpu", d.CPU, true)
+		set("memory", d.Memory, false)
+		injected = append(injected, record)
+	}
+	return injected
+}
+
+// SetResourceDefaults changes the CPU and memory defaults of the project's instance containers.
+// They apply to instances created afterwards.
+func (s *ProjectService) SetResourceDefaults(c *gin.Context, projectID uint, input project.ResourceDefaultsDTO) (*project.Project, error) {
+	p, err := s.Repos.Project.GetProjectByID(projectID)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	old := p
+	if input.CPURequest != nil {
+		p.DefaultCPURequest = strings.TrimSpace(*input.CPURequest)
+	}
+	if input.MemoryRequest != nil {
+		p.DefaultMemoryRequest = strings.TrimSpace(*input.MemoryRequest)
+	}
+	if input.LimitMultiplier != nil {
+		if m := *input.LimitMultiplier; m != 0 && m < 1 {
+			return nil, fmt.Errorf("%w: a limit multiplier below 1 would set limits under the requests", ErrInvalidResourceDefaults)
+		}
+		p.ResourceLimitMultiplier = *input.LimitMultiplier
+	}
+	if input.Disabled != nil {
+		p.ResourceDefaultsDisabled = *input.Disabled
+	}
+	if _, err := parseResourceDefault("cpu", p.DefaultCPURequest); err != nil {
+		return nil, err
+	}
+	if _, err := parseResourceDefault("memory", p.DefaultMemoryRequest); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repos.Project.UpdateProject(&p); err != nil {
+		return nil, err
+	}
+	utils.LogAuditWithConsole(c, "update", "project_resource_defaults", fmt.Sprintf("p_id=%d", projectID), old, p, "", s.Repos.Audit)
+	return &p, nil
+}
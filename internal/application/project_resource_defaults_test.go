@@ -0,0 +1,192 @@
+package application
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/datatypes"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func containerResources(t *testing.T, spec map[string]interface{}, name string) (map[string]interface{}, map[string]interface{}) {
+	t.Helper()
+	for _, c := range getContainersFromPodSpec(spec) {
+		if c["name"] == name {
+			res, _ := c["resources"].(map[string]interface{})
+			requests, _ := res["requests"].(map[string]interface{})
+			limits, _ := res["limits"].(map[string]interface{})
+			return requests, limits
+		}
+	}
+	t.Fatalf("container %s not found", name)
+	return nil, nil
+}
+
+func TestPatchResourceDefaultsSkipsContainersWithResources(t *testing.T) {
+	cpu, memory := k8sresource.MustParse("500m"), k8sresource.MustParse("1Gi")
+	defaults := &ResourceDefaults{CPU: &cpu, Memory: &memory, Multiplier: 2}
+	spec := map[string]interface{}{
+		"initContainers": []interface{}{map[string]interface{}{"name": "fetch"}},
+		"containers": []interface{}{
+			map[string]interface{}{"name": "bare"},
+			map[string]interface{}{"name": "sized", "resources": map[string]interface{}{
+				"requests": map[string]interface{}{"memory": "8Gi"},
+			}},
+			map[string]interface{}{"name": "capped", "resources": map[string]interface{}{
+				"limits": map[string]interface{}{"cpu": "4"},
+			}},
+			map[string]interface{}{"name": "gpu", "resources": map[string]interface{}{
+				"requests": map[string]interface{}{"nvidia.com/gpu": "1"},
+				"limits":   map[string]interface{}{"nvidia.com/gpu": "1"},
+			}},
+		},
+	}
+
+	injected := patchResourceDefaults(spec, defaults)
+	got := map[string]bool{}
+	for _, inj := range injected {
+		got[inj.Container] = true
+	}
+	if len(injected) != 3 || !got["fetch"] || !got["bare"] || !got["gpu"] {
+		t.Fatalf("expected defaults for fetch, bare and gpu only, got %+v", injected)
+	}
+
+	if requests, limits := containerResources(t, spec, "bare"); requests["cpu"] != "500m" || requests["memory"] != "1Gi" ||
+		limits["cpu"] != "1" || limits["memory"] != "2Gi" {
+		t.Fatalf("unexpected defaults %v %v", requests, limits)
+	}
+	if requests, limits := containerResources(t, spec, "sized"); len(requests) != 1 || requests["memory"] != "8Gi" || limits != nil {
+		t.Fatalf("a container setting its own memory must be left alone, got %v %v", requests, limits)
+	}
+	if requests, limits := containerResources(t, spec, "capped"); requests != nil || len(limits) != 1 {
+		t.Fatalf("a container setting its own CPU limit must be left alone, got %v %v", requests, limits)
+	}
+	requests, limits := containerResources(t, spec, "gpu")
+	if requests["nvidia.com/gpu"] != "1" || limits["nvidia.com/gpu"] != "1" || requests["cpu"] != "500m" || limits["memory"] != "2Gi" {
+		t.Fatalf("expected defaults next to the untouched GPU fields, got %v %v", requests, limits)
+	}
+}
+
+func TestResourceLimitMultiplier(t *testing.T) {
+	cases := []struct {
+		request    string
+		multiplier float64
+		milli      bool
+		want       string
+	}{
+		{"250m", 2, true, "500m"},
+		{"250m", 1.5, true, "375m"},
+		{"1", 1.5, true, "1500m"},
+		{"333m", 1.5, true, "500m"}, // 499.5m rounds up
+		{"512Mi", 2, false, "1Gi"},
+		{"1Gi", 1.5, false, "1536Mi"},
+		{"1G", 1.25, false, "1250M"},
+		{"512Mi", 1, false, "512Mi"},
+	}
+	for _, tc := range cases {
+		got := scaleQuantity(k8sresource.MustParse(tc.request), tc.multiplier, tc.milli)
+		if got.String() != tc.want {
+			t.Fatalf("%s x %g: expected %s, got %s", tc.request, tc.multiplier, tc.want, got.String())
+		}
+	}
+
+	// A multiplier of 0 sets requests only
+	cpu := k8sresource.MustParse("250m")
+	spec := map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "main"}}}
+	injected := patchResourceDefaults(spec, &ResourceDefaults{CPU: &cpu})
+	if requests, limits := containerResources(t, spec, "main"); requests["cpu"] != "250m" || limits != nil || injected[0].Limits != nil {
+		t.Fatalf("expected no limits without a multiplier, got %v %v", requests, limits)
+	}
+}
+
+func TestProjectResourceDefaults(t *testing.T) {
+	if d, err := projectResourceDefaults(project.Project{ResourceDefaultsDisabled: true}); err != nil || d != nil {
+		t.Fatalf("expected no defaults for a project that disabled them, got %+v %v", d, err)
+	}
+	d, err := projectResourceDefaults(project.Project{DefaultCPURequest: "2", ResourceLimitMultiplier: 1.5})
+	if err != nil || d.CPU.String() != "2" || d.Memory == nil || d.Multiplier != 1.5 {
+		t.Fatalf("expected the project CPU and multiplier over the platform memory default, got %+v %v", d, err)
+	}
+	if _, err := projectResourceDefaults(project.Project{DefaultMemoryRequest: "lots"}); !errors.Is(err, ErrInvalidResourceDefaults) {
+		t.Fatalf("expected an invalid quantity to be rejected, got %v", err)
+	}
+}
+
+func TestRenderInstanceShowsInjectedResources(t *testing.T) {
+	db := dbtest.Open(t, &project.Project{}, &project.ProjectEnvDefault{}, &project.SchedulingPolicy{}, &project.RegistryCredential{}, &configfile.ConfigFile{}, &resource.Resource{})
+	origLog := utils.LogAuditWithConsole
+	t.Cleanup(func() { utils.LogAuditWithConsole = origLog })
+	utils.LogAuditWithConsole = func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo) {}
+
+	p := project.Project{ProjectName: "course", GID: 1}
+	db.Create(&p)
+	cf := configfile.ConfigFile{Filename: "lab.yaml", Content: "{}", ProjectID: p.PID}
+	db.Create(&cf)
+	container := `{"containers":[{"name":"main","image":"python:3.12"}]}`
+	for name, manifest := range map[string]string{
+		"web":     `{"kind":"Deployment","metadata":{"name":"web"},"spec":{"template":{"spec":` + container + `}}}`,
+		"db":      `{"kind":"StatefulSet","metadata":{"name":"db"},"spec":{"template":{"spec":` + container + `}}}`,
+		"train":   `{"kind":"Job","metadata":{"name":"train"},"spec":{"template":{"spec":` + container + `}}}`,
+		"nightly": `{"kind":"CronJob","metadata":{"name":"nightly"},"spec":{"jobTemplate":{"spec":{"template":{"spec":` + container + `}}}}}`,
+	} {
+		db.Create(&resource.Resource{CFID: cf.CFID, Name: name, ParsedYAML: datatypes.JSON(manifest)})
+	}
+	repos := repository.NewRepositories(db)
+
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	k8s.Clientset = k8sfake.NewSimpleClientset()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+	c.Set("claims", &types.Claims{UserID: 2, Username: "bob", IsAdmin: true})
+
+	projects := NewProjectService(repos)
+	if _, err := projects.SetResourceDefaults(c, p.PID, project.ResourceDefaultsDTO{LimitMultiplier: floatPtr(0.5)}); !errors.Is(err, ErrInvalidResourceDefaults) {
+		t.Fatalf("expected a multiplier below 1 to be rejected, got %v", err)
+	}
+	cpu := "1"
+	if _, err := projects.SetResourceDefaults(c, p.PID, project.ResourceDefaultsDTO{CPURequest: &cpu, LimitMultiplier: floatPtr(1)}); err != nil {
+		t.Fatalf("SetResourceDefaults: %v", err)
+	}
+
+	svc := NewConfigFileService(repos)
+	rendered, err := svc.RenderInstance(c, cf.CFID)
+	if err != nil {
+		t.Fatalf("RenderInstance: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, inj := range rendered.InjectedResources {
+		seen[inj.Resource] = true
+		if inj.Container != "main" || inj.Requests["cpu"] != "1" || inj.Limits["cpu"] != "1" {
+			t.Fatalf("unexpected injection %+v", inj)
+		}
+	}
+	for _, ref := range []string{"Deployment/web", "StatefulSet/db", "Job/train", "CronJob/nightly"} {
+		if !seen[ref] {
+			t.Fatalf("expected defaults for %s, got %+v", ref, rendered.InjectedResources)
+		}
+	}
+
+	disabled := true
+	if _, err := projects.SetResourceDefaults(c, p.PID, project.ResourceDefaultsDTO{Disabled: &disabled}); err != nil {
+		t.Fatalf("SetResourceDefaults: %v", err)
+	}
+	if rendered, err = svc.RenderInstance(c, cf.CFID); err != nil || len(rendered.InjectedResources) != 0 {
+		t.Fatalf("expected no defaults once disabled, got %+v %v", rendered, err)
+	}
+}
+
+func floatPtr(f float64) *float64 { return &f }
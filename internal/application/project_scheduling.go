@@ -0,0 +1,111 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	ErrInvalidSchedulingPolicy  = errors.New("invalid scheduling policy")
+	ErrSchedulingPolicyNotFound = errors.New("scheduling policy not found")
+)
+
+// GetSchedulingPolicy returns the scheduling policy of a project.
+func (s *ProjectService) GetSchedulingPolicy(projectID uint) (*project.SchedulingPolicy, error) {
+	p, err := s.Repos.Scheduling.GetByProject(projectID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSchedulingPolicyNotFound
+	}
+	return p, err
+}
+
+// SetSchedulingPolicy creates or replaces the scheduling policy of a project. It applies to
+// instances and jobs created afterwards; running workloads keep their placement.
+func (s *ProjectService) SetSchedulingPolicy(c *gin.Context, projectID uint, input project.SchedulingPolicyDTO) (*project.SchedulingPolicy, error) {
+	if _, err := s.Repos.Project.GetProjectByID(projectID); err != nil {
+		return nil, ErrProjectNotFound
+	}
+
+	policy := &project.SchedulingPolicy{
+		ProjectID:   projectID,
+		Tolerations: input.Tolerations,
+		Affinity:    input.Affinity,
+		Force:       input.Force,
+	}
+	if len(input.NodeSelector) > 0 {
+		raw, err := json.Marshal(input.NodeSelector)
+		if err != nil {
+			return nil, err
+		}
+		policy.NodeSelector = datatypes.JSON(raw)
+	}
+	if _, err := schedulingPolicyFor(policy); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repos.Scheduling.Upsert(policy); err != nil {
+		return nil, err
+	}
+	utils.LogAuditWithConsole(c, "update", "project_scheduling", fmt.Sprintf("p_id=%d", projectID), nil, *policy, "", s.Repos.Audit)
+	return s.GetSchedulingPolicy(projectID)
+}
+
+// DeleteSchedulingPolicy removes the scheduling policy of a project.
+func (s *ProjectService) DeleteSchedulingPolicy(c *gin.Context, projectID uint) error {
+	if err := s.Repos.Scheduling.Delete(projectID); err != nil {
+		return err
+	}
+	utils.LogAuditWithConsole(c, "delete", "project_scheduling", fmt.Sprintf("p_id=%d", projectID), nil, nil, "", s.Repos.Audit)
+	return nil
+}
+
+// resolveSchedulingPolicy loads the project's scheduling policy for injection into pod specs.
+// It returns nil when the project has none.
+func resolveSchedulingPolicy(repo repository.ProjectSchedulingRepo, projectID uint) (*k8s.SchedulingPolicy, error) {
+	p, err := repo.GetByProject(projectID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project scheduling policy: %w", err)
+	}
+	return schedulingPolicyFor(p)
+}
+
+// schedulingPolicyFor decodes the stored fields into their Kubernetes types.
+func schedulingPolicyFor(p *project.SchedulingPolicy) (*k8s.SchedulingPolicy, error) {
+	policy := &k8s.SchedulingPolicy{Force: p.Force}
+	decode := func(field string, raw datatypes.JSON, into any) error {
+		if len(raw) == 0 || string(raw) == "null" {
+			return nil
+		}
+		if err := json.Unmarshal(raw, into); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidSchedulingPolicy, field, err)
+		}
+		return nil
+	}
+	var affinity corev1.Affinity
+	if err := decode("tolerations", p.Tolerations, &policy.Tolerations); err != nil {
+		return nil, err
+	}
+	if err := decode("node_selector", p.NodeSelector, &policy.NodeSelector); err != nil {
+		return nil, err
+	}
+	if err := decode("affinity", p.Affinity, &affinity); err != nil {
+		return nil, err
+	}
+	if affinity != (corev1.Affinity{}) {
+		policy.Affinity = &affinity
+	}
+	return policy, nil
+}
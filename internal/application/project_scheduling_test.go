@@ -0,0 +1,145 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	"gorm.io/datatypes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApplyResourcePatchesInjectsSchedulingPolicy(t *testing.T) {
+	policy, err := schedulingPolicyFor(&project.SchedulingPolicy{
+		Tolerations:  datatypes.JSON(`[{"key":"course","operator":"Equal","value":"true","effect":"NoSchedule"}]`),
+		NodeSelector: datatypes.JSON(`{"pool":"course"}`),
+		Affinity:     datatypes.JSON(`{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"course","operator":"In","values":["true"]}]}]}}}`),
+	})
+	if err != nil {
+		t.Fatalf("schedulingPolicyFor: %v", err)
+	}
+
+	svc := &ConfigFileService{imageService: NewImageService(&pulledImageRepo{newFakeRepo()})}
+	podSpec := func() map[string]interface{} {
+		return map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "main", "image": "python:3.12"}}}
+	}
+	manifests := map[string]map[string]interface{}{
+		"Deployment": {"kind": "Deployment", "spec": map[string]interface{}{"template": map[string]interface{}{"spec": podSpec()}}},
+		"Job":        {"kind": "Job", "spec": map[string]interface{}{"template": map[string]interface{}{"spec": podSpec()}}},
+		"CronJob": {"kind": "CronJob", "spec": map[string]interface{}{
+			"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{
+				"template": map[string]interface{}{"spec": podSpec()},
+			}},
+		}},
+	}
+
+	for kind, obj := range manifests {
+		ctx := &PatchContext{ProjectID: 1, UserIsAdmin: true, Scheduling: policy}
+		if err := svc.applyResourcePatches(obj, ctx); err != nil {
+			t.Fatalf("%s: applyResourcePatches: %v", kind, err)
+		}
+		spec := findPodSpecs(obj)[0]
+		tolerations, _ := spec["tolerations"].([]interface{})
+		if len(tolerations) != 1 || tolerations[0].(map[string]interface{})["key"] != "course" {
+			t.Fatalf("%s: expected the course toleration, got %v", kind, spec["tolerations"])
+		}
+		if selector, _ := spec["nodeSelector"].(map[string]interface{}); selector["pool"] != "course" {
+			t.Fatalf("%s: expected the course node selector, got %v", kind, spec["nodeSelector"])
+		}
+		if _, ok := spec["affinity"].(map[string]interface{})["nodeAffinity"]; !ok {
+			t.Fatalf("%s: expected the node affinity, got %v", kind, spec["affinity"])
+		}
+	}
+}
+
+func TestApplyResourcePatchesKeepsUserScheduling(t *testing.T) {
+	policy := &k8s.SchedulingPolicy{
+		Tolerations:  []corev1.Toleration{{Key: "course", Operator: corev1.TolerationOpExists}},
+		NodeSelector: map[string]string{"pool": "course"},
+	}
+	svc := &ConfigFileService{imageService: NewImageService(&pulledImageRepo{newFakeRepo()})}
+	newObj := func() map[string]interface{} {
+		return map[string]interface{}{"kind": "Pod", "spec": map[string]interface{}{
+			"containers":   []interface{}{map[string]interface{}{"name": "main", "image": "python:3.12"}},
+			"nodeSelector": map[string]interface{}{"pool": "research"},
+		}}
+	}
+
+	obj := newObj()
+	if err := svc.applyResourcePatches(obj, &PatchContext{ProjectID: 1, UserIsAdmin: true, Scheduling: policy}); err != nil {
+		t.Fatalf("applyResourcePatches: %v", err)
+	}
+	spec := obj["spec"].(map[string]interface{})
+	if spec["nodeSelector"].(map[string]interface{})["pool"] != "research" {
+		t.Fatalf("the user's node selector must win, got %v", spec["nodeSelector"])
+	}
+	if tolerations, _ := spec["tolerations"].([]interface{}); len(tolerations) != 1 {
+		t.Fatalf("fields the user left unset are still injected, got %v", spec["tolerations"])
+	}
+
+	policy.Force = true
+	obj = newObj()
+	if err := svc.applyResourcePatches(obj, &PatchContext{ProjectID: 1, UserIsAdmin: true, Scheduling: policy}); err != nil {
+		t.Fatalf("applyResourcePatches: %v", err)
+	}
+	if pool := obj["spec"].(map[string]interface{})["nodeSelector"].(map[string]interface{})["pool"]; pool != "course" {
+		t.Fatalf("a forced policy must override the user's node selector, got %v", pool)
+	}
+}
+
+func TestSchedulingPolicyForRejectsInvalidFields(t *testing.T) {
+	_, err := schedulingPolicyFor(&project.SchedulingPolicy{Tolerations: datatypes.JSON(`{"key":"course"}`)})
+	if !errors.Is(err, ErrInvalidSchedulingPolicy) {
+		t.Fatalf("expected tolerations that are not a list to be rejected, got %v", err)
+	}
+}
+
+func TestRenderInstanceShowsSchedulingPolicy(t *testing.T) {
+	db := dbtest.Open(t, &project.Project{}, &project.ProjectEnvDefault{}, &project.SchedulingPolicy{}, &project.RegistryCredential{}, &configfile.ConfigFile{}, &resource.Resource{})
+	p := project.Project{ProjectName: "course", GID: 1}
+	db.Create(&p)
+	cf := configfile.ConfigFile{Filename: "lab.yaml", Content: "{}", ProjectID: p.PID}
+	db.Create(&cf)
+	db.Create(&resource.Resource{CFID: cf.CFID, Name: "lab", Type: resource.ResourceJob, ParsedYAML: datatypes.JSON(
+		`{"kind":"Job","metadata":{"name":"lab"},"spec":{"template":{"spec":{"containers":[{"name":"main","image":"python:3.12"}]}}}}`)})
+	repos := repository.NewRepositories(db)
+	if err := repos.Scheduling.Upsert(&project.SchedulingPolicy{ProjectID: p.PID, NodeSelector: datatypes.JSON(`{"pool":"course"}`)}); err != nil {
+		t.Fatalf("failed to seed policy: %v", err)
+	}
+
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	fake := k8sfake.NewSimpleClientset()
+	k8s.Clientset = fake
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+	c.Set("claims", &types.Claims{UserID: 2, Username: "bob", IsAdmin: true})
+
+	rendered, err := NewConfigFileService(repos).RenderInstance(c, cf.CFID)
+	if err != nil {
+		t.Fatalf("RenderInstance: %v", err)
+	}
+	if rendered.Namespace != k8s.FormatNamespaceName(p.PID, "bob") || len(rendered.Objects) != 1 {
+		t.Fatalf("unexpected render %+v", rendered)
+	}
+	spec := findPodSpecs(rendered.Objects[0])[0]
+	if selector, _ := spec["nodeSelector"].(map[string]interface{}); selector["pool"] != "course" {
+		t.Fatalf("expected the injected node selector in the rendered manifest, got %v", spec["nodeSelector"])
+	}
+	if ns, _ := fake.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{}); len(ns.Items) != 0 {
+		t.Fatalf("rendering must not create the namespace, found %d", len(ns.Items))
+	}
+}
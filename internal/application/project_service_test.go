@@ -12,6 +12,7 @@ import (
 	"github.com/linskybing/platform-go/internal/domain/view"
 	"github.com/linskybing/platform-go/internal/repository"
 	"github.com/linskybing/platform-go/internal/repository/mock"
+	"github.com/linskybing/platform-go/pkg/k8s"
 	"github.com/linskybing/platform-go/pkg/utils"
 )
 
@@ -64,6 +65,7 @@ func TestProjectServiceCRUD(t *testing.T) {
 			// Simulate GORM's behavior of setting the PID after successful CREATE
 			p.PID = 1
 		}).Return(nil)
+		mockProject.EXPECT().SetStorageNamespace(uint(1), k8s.GenerateSafeResourceName("project", "proj1", 1)).Return(nil)
 
 		// CreateProject calls AllocateProjectResources which may call GetProjectByID; make optional
 		mockProject.EXPECT().GetProjectByID(uint(1)).Return(project.Project{PID: 1, ProjectName: "proj1", GID: 1}, nil).AnyTimes()
@@ -119,6 +121,14 @@ func TestProjectServiceCRUD(t *testing.T) {
 		}
 	})
 
+	t.Run("UpdateProject oversubscribed MPS memory", func(t *testing.T) {
+		memory := 8000 // 10 shared pods need more than the 48000MB of a GPU
+		_, err := svc.UpdateProject(c, 1, project.UpdateProjectDTO{MPSMemory: &memory})
+		if !errors.Is(err, application.ErrInvalidMPSConfig) {
+			t.Fatalf("expected ErrInvalidMPSConfig, got %v", err)
+		}
+	})
+
 	t.Run("UpdateProject not found", func(t *testing.T) {
 		mockProject.EXPECT().GetProjectByID(uint(99)).Return(project.Project{}, errors.New("not found")).AnyTimes()
 		newName := "test"
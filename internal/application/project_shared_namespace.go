@@ -0,0 +1,160 @@
+package application
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+)
+
+// deleteOwnedByJson deletes an object only when it carries the given ownership labels; tests replace it.
+var deleteOwnedByJson = k8s.DeleteOwnedByJson
+
+// WorkloadNamespace is where a member's instances and jobs run: their own proj-<pid>-<user>
+// namespace, or the project storage namespace when the project shares one.
+func WorkloadNamespace(p *project.Project, username string) string {
+	if p.SharesNamespace() {
+		return ProjectStorageNamespace(p)
+	}
+	return k8s.FormatNamespaceName(p.PID, k8s.ToSafeK8sName(username))
+}
+
+// sharedNamePrefix is prepended to the names of what a member creates in a shared namespace.
+func sharedNamePrefix(username string) string {
+	return k8s.ToSafeK8sName(username) + "-"
+}
+
+// sharedName prefixes name, keeping it within the 63 characters of a DNS label. Names the user
+// already prefixed, for example through {{safeUsername}}, are kept.
+func sharedName(prefix, name string) string {
+	if name == "" || strings.HasPrefix(name, prefix) {
+		return name
+	}
+	name = prefix + name
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
+// podSpecRefs lists, per pod spec field, the kind of object a name inside it refers to.
+var podSpecRefs = []struct {
+	kind string
+	path []string
+}{
+	{"ConfigMap", []string{"configMap", "name"}},
+	{"Secret", []string{"secret", "secretName"}},
+	{"PersistentVolumeClaim", []string{"persistentVolumeClaim", "claimName"}},
+}
+
+// prefixInstanceNames makes the objects of one instance safe to share a namespace with other
+// members: their names get the member's prefix, references between them follow the rename,
+// and selectors are narrowed to the member's pods so Services and controllers of two students
+// using the same labels do not pick up each other's pods.
+func prefixInstanceNames(objs []map[string]interface{}, prefix string, userID uint) {
+	renamed := make(map[string]string)
+	for _, obj := range objs {
+		meta, _ := obj["metadata"].(map[string]interface{})
+		if meta == nil {
+			continue
+		}
+		kind, _ := obj["kind"].(string)
+		name, _ := meta["name"].(string)
+		if name == "" {
+			// The server appends a suffix to the prefixed generateName
+			if generate, _ := meta["generateName"].(string); generate != "" {
+				meta["generateName"] = sharedName(prefix, generate)
+			}
+			continue
+		}
+		meta["name"] = sharedName(prefix, name)
+		renamed[kind+"/"+name] = meta["name"].(string)
+	}
+	rename := func(kind string, m map[string]interface{}, key string) {
+		if name, ok := m[key].(string); ok {
+			if to, ok := renamed[kind+"/"+name]; ok {
+				m[key] = to
+			}
+		}
+	}
+
+	owner := strconv.FormatUint(uint64(userID), 10)
+	for _, obj := range objs {
+		kind, _ := obj["kind"].(string)
+		spec, _ := obj["spec"].(map[string]interface{})
+		switch kind {
+		case "Service":
+			if selector, ok := spec["selector"].(map[string]interface{}); ok && len(selector) > 0 {
+				selector[k8s.LabelUserID] = owner
+			}
+		case "Deployment", "StatefulSet", "ReplicaSet", "DaemonSet":
+			if selector, ok := spec["selector"].(map[string]interface{}); ok {
+				if match, ok := selector["matchLabels"].(map[string]interface{}); ok {
+					match[k8s.LabelUserID] = owner
+				}
+			}
+			if kind == "StatefulSet" {
+				rename("Service", spec, "serviceName")
+			}
+		}
+
+		for _, podSpec := range findPodSpecs(obj) {
+			if volumes, ok := podSpec["volumes"].([]interface{}); ok {
+				for _, v := range volumes {
+					vol, _ := v.(map[string]interface{})
+					for _, ref := range podSpecRefs {
+						if src, ok := vol[ref.path[0]].(map[string]interface{}); ok {
+							rename(ref.kind, src, ref.path[1])
+						}
+					}
+				}
+			}
+			for _, cont := range getContainersFromPodSpec(podSpec) {
+				renameContainerRefs(cont, rename)
+			}
+		}
+	}
+}
+
+// renameContainerRefs follows renamed ConfigMaps and Secrets in a container's env and envFrom.
+func renameContainerRefs(cont map[string]interface{}, rename func(kind string, m map[string]interface{}, key string)) {
+	if envFrom, ok := cont["envFrom"].([]interface{}); ok {
+		for _, e := range envFrom {
+			src, _ := e.(map[string]interface{})
+			if ref, ok := src["configMapRef"].(map[string]interface{}); ok {
+				rename("ConfigMap", ref, "name")
+			}
+			if ref, ok := src["secretRef"].(map[string]interface{}); ok {
+				rename("Secret", ref, "name")
+			}
+		}
+	}
+	if env, ok := cont["env"].([]interface{}); ok {
+		for _, e := range env {
+			item, _ := e.(map[string]interface{})
+			from, _ := item["valueFrom"].(map[string]interface{})
+			if ref, ok := from["configMapKeyRef"].(map[string]interface{}); ok {
+				rename("ConfigMap", ref, "name")
+			}
+			if ref, ok := from["secretKeyRef"].(map[string]interface{}); ok {
+				rename("Secret", ref, "name")
+			}
+		}
+	}
+}
+
+// sharedObjectJSON returns the manifest of a config file resource under the member's prefixed name.
+func sharedObjectJSON(parsed []byte, prefix string) ([]byte, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(parsed, &obj); err != nil {
+		return nil, err
+	}
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		if name, ok := meta["name"].(string); ok {
+			meta["name"] = sharedName(prefix, name)
+		}
+	}
+	return json.Marshal(obj)
+}
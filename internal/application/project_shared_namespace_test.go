@@ -0,0 +1,150 @@
+package application
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/types"
+	"gorm.io/datatypes"
+)
+
+const sharedInstanceJSON = `[
+	{"kind":"ConfigMap","metadata":{"name":"settings"}},
+	{"kind":"PersistentVolumeClaim","metadata":{"name":"scratch"}},
+	{"kind":"Service","metadata":{"name":"web"},"spec":{"selector":{"app":"web"}}},
+	{"kind":"Deployment","metadata":{"name":"web"},"spec":{
+		"selector":{"matchLabels":{"app":"web"}},
+		"template":{"metadata":{"labels":{"app":"web"}},"spec":{
+			"containers":[{"name":"main","image":"nginx","envFrom":[{"configMapRef":{"name":"settings"}}]}],
+			"volumes":[
+				{"name":"cfg","configMap":{"name":"settings"}},
+				{"name":"tmp","persistentVolumeClaim":{"claimName":"scratch"}},
+				{"name":"data","persistentVolumeClaim":{"claimName":"project-7-default"}}
+			]}}}}
+]`
+
+func sharedInstance(t *testing.T, username string, uid uint) []map[string]interface{} {
+	t.Helper()
+	var objs []map[string]interface{}
+	if err := json.Unmarshal([]byte(sharedInstanceJSON), &objs); err != nil {
+		t.Fatalf("bad fixture: %v", err)
+	}
+	prefixInstanceNames(objs, sharedNamePrefix(username), uid)
+	return objs
+}
+
+func objectName(obj map[string]interface{}) string {
+	return obj["metadata"].(map[string]interface{})["name"].(string)
+}
+
+func TestPrefixInstanceNamesAvoidsCollisions(t *testing.T) {
+	alice := sharedInstance(t, "Alice", 3)
+	bob := sharedInstance(t, "bob", 4)
+
+	for i := range alice {
+		a, b := objectName(alice[i]), objectName(bob[i])
+		if a == b {
+			t.Fatalf("both students got %q", a)
+		}
+		if !strings.HasPrefix(a, "alice-") || !strings.HasPrefix(b, "bob-") {
+			t.Fatalf("expected the username prefixes, got %q and %q", a, b)
+		}
+	}
+
+	deploy := alice[3]["spec"].(map[string]interface{})
+	if deploy["selector"].(map[string]interface{})["matchLabels"].(map[string]interface{})[k8s.LabelUserID] != "3" {
+		t.Fatalf("the Deployment must only select the student's own pods, got %v", deploy["selector"])
+	}
+	if alice[2]["spec"].(map[string]interface{})["selector"].(map[string]interface{})[k8s.LabelUserID] != "3" {
+		t.Fatalf("the Service must only select the student's own pods, got %v", alice[2]["spec"])
+	}
+
+	podSpec := deploy["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	envFrom := podSpec["containers"].([]interface{})[0].(map[string]interface{})["envFrom"].([]interface{})
+	if ref := envFrom[0].(map[string]interface{})["configMapRef"].(map[string]interface{})["name"]; ref != "alice-settings" {
+		t.Fatalf("envFrom should follow the renamed ConfigMap, got %v", ref)
+	}
+	volumes := podSpec["volumes"].([]interface{})
+	claim := func(i int) interface{} {
+		return volumes[i].(map[string]interface{})["persistentVolumeClaim"].(map[string]interface{})["claimName"]
+	}
+	if cm := volumes[0].(map[string]interface{})["configMap"].(map[string]interface{})["name"]; cm != "alice-settings" {
+		t.Fatalf("the ConfigMap volume should follow the rename, got %v", cm)
+	}
+	if claim(1) != "alice-scratch" {
+		t.Fatalf("the instance's own PVC should follow the rename, got %v", claim(1))
+	}
+	if claim(2) != "project-7-default" {
+		t.Fatalf("platform PVCs outside the instance must keep their name, got %v", claim(2))
+	}
+}
+
+func TestSharedNameFitsDNSLabel(t *testing.T) {
+	name := sharedName("a-very-long-student-username-", strings.Repeat("worker", 10))
+	if len(name) > 63 || strings.HasSuffix(name, "-") {
+		t.Fatalf("expected a valid DNS label, got %q", name)
+	}
+	if got := sharedName("bob-", "bob-notebook"); got != "bob-notebook" {
+		t.Fatalf("an already prefixed name should be kept, got %q", got)
+	}
+}
+
+func TestDeleteInstanceInSharedNamespaceIsScoped(t *testing.T) {
+	svc, db, c := setupTemplateService(t)
+	p := project.Project{ProjectName: "os-course", GID: 1, NamespaceMode: project.NamespaceModeShared}
+	db.Create(&p)
+	cf := configfile.ConfigFile{Filename: "lab1.yaml", Content: "{}", ProjectID: p.PID}
+	db.Create(&cf)
+	db.Create(&resource.Resource{CFID: cf.CFID, Type: "Pod", Name: "lab", ParsedYAML: datatypes.JSON(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"lab"}}`)})
+	c.Set("claims", &types.Claims{Username: "alice", UserID: 3})
+
+	type deletion struct {
+		name, ns string
+		owner    map[string]string
+	}
+	var got []deletion
+	orig := deleteOwnedByJson
+	t.Cleanup(func() { deleteOwnedByJson = orig })
+	deleteOwnedByJson = func(obj []byte, ns, name string, owner map[string]string) error {
+		if name == "" {
+			var m map[string]interface{}
+			_ = json.Unmarshal(obj, &m)
+			name = objectName(m)
+		}
+		got = append(got, deletion{name: name, ns: ns, owner: owner})
+		return nil
+	}
+
+	if _, err := svc.DeleteInstance(c, cf.CFID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected one scoped deletion, got %+v", got)
+	}
+	if got[0].ns != ProjectStorageNamespace(&p) || got[0].name != "alice-lab" {
+		t.Fatalf("expected alice-lab in the shared namespace, got %+v", got[0])
+	}
+	if got[0].owner[k8s.LabelUserID] != "3" {
+		t.Fatalf("the deletion must be limited to the caller's objects, got %v", got[0].owner)
+	}
+	if !k8s.HasLabels(k8s.Ownership{ProjectID: p.PID, UserID: 3, ConfigFileID: cf.CFID}.Labels(), got[0].owner) ||
+		k8s.HasLabels(k8s.Ownership{ProjectID: p.PID, UserID: 4, ConfigFileID: cf.CFID}.Labels(), got[0].owner) {
+		t.Fatalf("only objects created by the caller should match %v", got[0].owner)
+	}
+}
+
+func TestWorkloadNamespaceFollowsMode(t *testing.T) {
+	p := &project.Project{PID: 7, ProjectName: "os-course"}
+	if ns := WorkloadNamespace(p, "Alice"); ns != k8s.FormatNamespaceName(7, "alice") {
+		t.Fatalf("per-user projects deploy to the member namespace, got %s", ns)
+	}
+	p.NamespaceMode = project.NamespaceModeShared
+	if ns := WorkloadNamespace(p, "Alice"); ns != ProjectStorageNamespace(p) {
+		t.Fatalf("shared projects deploy to the project namespace, got %s", ns)
+	}
+}
@@ -0,0 +1,223 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	ErrSnapshotNotFound    = errors.New("snapshot not found")
+	ErrSnapshotNotReady    = errors.New("snapshot is not ready to use")
+	ErrRestoreTargetExists = errors.New("restore target already exists")
+)
+
+const (
+	restoredStorageSuffix = "-restored"
+	snapshotNameLayout    = "20060102-150405"
+)
+
+var snapshotNow = time.Now
+
+func projectSnapshotSelector(projectID uint) string {
+	return fmt.Sprintf("project-id=%d", projectID)
+}
+
+// CreateProjectSnapshots takes a snapshot of every storage PVC of the project. Afterwards the
+// oldest snapshots are pruned so the project keeps at most config.ProjectSnapshotMax.
+func (s *K8sService) CreateProjectSnapshots(ctx context.Context, p *project.Project) ([]k8s.VolumeSnapshotInfo, error) {
+	ns := ProjectStorageNamespace(p)
+	pvcs, err := k8s.ListProjectStoragePVCs(ctx, ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project storages: %w", err)
+	}
+	if len(pvcs) == 0 {
+		return nil, fmt.Errorf("%w: project %d has no storage", ErrStorageNotFound, p.PID)
+	}
+
+	stamp := snapshotNow().UTC().Format(snapshotNameLayout)
+	created := make([]k8s.VolumeSnapshotInfo, 0, len(pvcs))
+	keep := map[string]bool{}
+	for _, pvc := range pvcs {
+		labels := k8s.MergeLabels(map[string]string{
+			"project-id":                fmt.Sprintf("%d", p.PID),
+			k8s.ProjectStorageNameLabel: k8s.ProjectStorageName(&pvc),
+		}, k8s.Ownership{ProjectID: p.PID}.Labels())
+		info, err := k8s.CreateVolumeSnapshot(ctx, ns, pvc.Name+"-"+stamp, pvc.Name, config.VolumeSnapshotClassName, labels)
+		if err != nil {
+			return created, err
+		}
+		created = append(created, *info)
+		keep[info.Name] = true
+	}
+
+	if err := pruneProjectSnapshots(ctx, ns, p.PID, config.IntSetting(config.SettingProjectSnapshotMax), keep); err != nil {
+		log.Printf("[ProjectSnapshot] failed to prune snapshots of project %d: %v", p.PID, err)
+	}
+	return created, nil
+}
+
+// pruneProjectSnapshots deletes the oldest snapshots of the project beyond max. Snapshots in
+// keep, the ones just taken, are never pruned.
+func pruneProjectSnapshots(ctx context.Context, ns string, projectID uint, max int, keep map[string]bool) error {
+	if max <= 0 {
+		return nil
+	}
+	snaps, err := k8s.ListVolumeSnapshots(ctx, ns, projectSnapshotSelector(projectID))
+	if err != nil {
+		return err
+	}
+	excess := len(snaps) - max
+	for _, snap := range snaps {
+		if excess <= 0 {
+			break
+		}
+		if keep[snap.Name] {
+			continue
+		}
+		if err := k8s.DeleteVolumeSnapshot(ctx, ns, snap.Name); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		excess--
+	}
+	return nil
+}
+
+// ListProjectSnapshots lists the project's snapshots, oldest first.
+func (s *K8sService) ListProjectSnapshots(ctx context.Context, p *project.Project) ([]k8s.VolumeSnapshotInfo, error) {
+	return k8s.ListVolumeSnapshots(ctx, ProjectStorageNamespace(p), projectSnapshotSelector(p.PID))
+}
+
+// DeleteProjectSnapshot removes one snapshot of the project.
+func (s *K8sService) DeleteProjectSnapshot(ctx context.Context, p *project.Project, name string) error {
+	ns := ProjectStorageNamespace(p)
+	if _, err := s.getProjectSnapshot(ctx, p, name); err != nil {
+		return err
+	}
+	if err := k8s.DeleteVolumeSnapshot(ctx, ns, name); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// RestoreProjectSnapshot creates a new storage PVC from a snapshot, named after the source PVC
+// with a "-restored" suffix. The original PVC is never overwritten.
+func (s *K8sService) RestoreProjectSnapshot(ctx context.Context, p *project.Project, name string) (*corev1.PersistentVolumeClaim, error) {
+	snap, err := s.getProjectSnapshot(ctx, p, name)
+	if err != nil {
+		return nil, err
+	}
+	if !snap.ReadyToUse {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotReady, name)
+	}
+	if k8s.Clientset == nil {
+		return nil, fmt.Errorf("k8s client not available")
+	}
+
+	ns := ProjectStorageNamespace(p)
+	source, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(ns).Get(ctx, snap.SourcePVC, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get source pvc %s: %w", snap.SourcePVC, err)
+	}
+	if apierrors.IsNotFound(err) {
+		source = nil
+	}
+
+	pvc := restoredPVC(p, ns, snap, source)
+	created, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(ns).Create(ctx, pvc, metav1.CreateOptions{})
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("%w: %s", ErrRestoreTargetExists, pvc.Name)
+		}
+		return nil, fmt.Errorf("failed to create pvc %s: %w", pvc.Name, err)
+	}
+	projectStorageCache.invalidate()
+	return created, nil
+}
+
+func (s *K8sService) getProjectSnapshot(ctx context.Context, p *project.Project, name string) (*k8s.VolumeSnapshotInfo, error) {
+	snap, labels, err := k8s.GetVolumeSnapshot(ctx, ProjectStorageNamespace(p), name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+		}
+		return nil, err
+	}
+	if labels["project-id"] != fmt.Sprintf("%d", p.PID) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+	return snap, nil
+}
+
+// restoredPVC builds the PVC restoring snap. Class, access modes and labels follow the source PVC
+// when it still exists; the restored copy is listed as its own project storage.
+func restoredPVC(p *project.Project, ns string, snap *k8s.VolumeSnapshotInfo, source *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+	storageName := k8s.StorageNameFromPVCName(snap.SourcePVC)
+	labels := map[string]string{
+		"app.kubernetes.io/name":       "filebrowser-storage",
+		"app.kubernetes.io/managed-by": "nthu-cscc",
+		"storage-type":                 "project",
+		"project-id":                   fmt.Sprintf("%d", p.PID),
+		"project-name":                 p.ProjectName,
+	}
+	scName := config.DefaultStorageClassName
+	accessModes := []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	size := snap.RestoreSize
+	if source != nil {
+		for k, v := range source.Labels {
+			labels[k] = v
+		}
+		storageName = k8s.ProjectStorageName(source)
+		if source.Spec.StorageClassName != nil {
+			scName = *source.Spec.StorageClassName
+		}
+		if len(source.Spec.AccessModes) > 0 {
+			accessModes = source.Spec.AccessModes
+		}
+		if req, ok := source.Spec.Resources.Requests[corev1.ResourceStorage]; ok && size == "" {
+			size = req.String()
+		}
+	}
+	qty, err := resource.ParseQuantity(size)
+	if err != nil {
+		qty = resource.MustParse(config.StringSetting(config.SettingProjectPVSize))
+	}
+	storageName = strings.TrimSuffix(storageName, restoredStorageSuffix) + restoredStorageSuffix
+	labels[k8s.ProjectStorageNameLabel] = storageName
+	labels = k8s.MergeLabels(labels, k8s.Ownership{ProjectID: p.PID}.Labels())
+
+	apiGroup := k8s.VolumeSnapshotGVR.Group
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      k8s.ProjectStoragePVCName(p.PID, storageName),
+			Namespace: ns,
+			Labels:    labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: &scName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: qty},
+			},
+			DataSource: &corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "VolumeSnapshot",
+				Name:     snap.Name,
+			},
+		},
+	}
+}
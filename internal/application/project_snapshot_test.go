@@ -0,0 +1,142 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func snapshotObject(ns, name, pvc string, created time.Time, ready bool) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]interface{}{
+			"name":              name,
+			"namespace":         ns,
+			"labels":            map[string]interface{}{"project-id": "3", k8s.SnapshotSourceLabel: pvc},
+			"creationTimestamp": created.Format(time.RFC3339),
+		},
+		"spec":   map[string]interface{}{"source": map[string]interface{}{"persistentVolumeClaimName": pvc}},
+		"status": map[string]interface{}{"readyToUse": ready, "restoreSize": "20Gi"},
+	}}
+}
+
+func setupSnapshotCluster(t *testing.T, p *project.Project, snaps ...runtime.Object) {
+	t.Helper()
+	origClient, origSnaps, origMax := k8s.Clientset, k8s.Snapshots, config.ProjectSnapshotMax
+	t.Cleanup(func() {
+		k8s.Clientset, k8s.Snapshots, config.ProjectSnapshotMax = origClient, origSnaps, origMax
+	})
+
+	ns := ProjectStorageNamespace(p)
+	sc := "longhorn"
+	pvc := func(storage string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      k8s.ProjectStoragePVCName(p.PID, storage),
+				Namespace: ns,
+				Labels: k8s.MergeLabels(map[string]string{"storage-type": "project", "project-id": "3", k8s.ProjectStorageNameLabel: storage},
+					k8s.Ownership{ProjectID: 3}.Labels()),
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				StorageClassName: &sc,
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("20Gi")},
+				},
+			},
+		}
+	}
+	k8s.Clientset = k8sfake.NewSimpleClientset(pvc("disk"), pvc("datasets"))
+	k8s.Snapshots = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{k8s.VolumeSnapshotGVR: "VolumeSnapshotList"}, snaps...)
+}
+
+func TestCreateProjectSnapshotsPrunesOldest(t *testing.T) {
+	p := &project.Project{PID: 3, ProjectName: "vision"}
+	ns := ProjectStorageNamespace(p)
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	setupSnapshotCluster(t, p,
+		snapshotObject(ns, "project-3-disk-a", "project-3-disk", base, true),
+		snapshotObject(ns, "project-3-disk-b", "project-3-disk", base.Add(time.Hour), true),
+		snapshotObject(ns, "project-3-disk-c", "project-3-disk", base.Add(2*time.Hour), true),
+	)
+	config.ProjectSnapshotMax = 4
+
+	created, err := (&K8sService{}).CreateProjectSnapshots(context.Background(), p)
+	if err != nil {
+		t.Fatalf("CreateProjectSnapshots: %v", err)
+	}
+	if len(created) != 2 {
+		t.Fatalf("expected a snapshot per storage, got %+v", created)
+	}
+
+	snaps, err := (&K8sService{}).ListProjectSnapshots(context.Background(), p)
+	if err != nil {
+		t.Fatalf("ListProjectSnapshots: %v", err)
+	}
+	names := map[string]bool{}
+	for _, s := range snaps {
+		names[s.Name] = true
+	}
+	if len(snaps) != 4 || names["project-3-disk-a"] || !names["project-3-disk-b"] || !names["project-3-disk-c"] {
+		t.Fatalf("expected the oldest snapshot pruned, got %v", names)
+	}
+	for _, c := range created {
+		if !names[c.Name] {
+			t.Fatalf("new snapshot %s was pruned", c.Name)
+		}
+	}
+}
+
+func TestRestoreProjectSnapshotCreatesSuffixedPVC(t *testing.T) {
+	p := &project.Project{PID: 3, ProjectName: "vision"}
+	ns := ProjectStorageNamespace(p)
+	now := time.Now()
+	setupSnapshotCluster(t, p,
+		snapshotObject(ns, "project-3-disk-ready", "project-3-disk", now, true),
+		snapshotObject(ns, "project-3-disk-pending", "project-3-disk", now, false),
+	)
+	svc := &K8sService{}
+	ctx := context.Background()
+
+	pvc, err := svc.RestoreProjectSnapshot(ctx, p, "project-3-disk-ready")
+	if err != nil {
+		t.Fatalf("RestoreProjectSnapshot: %v", err)
+	}
+	if pvc.Name != "project-3-disk-restored" || pvc.Labels[k8s.ProjectStorageNameLabel] != "disk-restored" {
+		t.Fatalf("unexpected restored pvc %s labels %v", pvc.Name, pvc.Labels)
+	}
+	if ds := pvc.Spec.DataSource; ds == nil || ds.Kind != "VolumeSnapshot" || ds.Name != "project-3-disk-ready" {
+		t.Fatalf("unexpected data source %+v", pvc.Spec.DataSource)
+	}
+	if pvc.Spec.AccessModes[0] != corev1.ReadWriteMany {
+		t.Fatalf("access mode not taken from source: %v", pvc.Spec.AccessModes)
+	}
+	if _, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(ns).Get(ctx, "project-3-disk", metav1.GetOptions{}); err != nil {
+		t.Fatalf("original pvc touched: %v", err)
+	}
+
+	if _, err := svc.RestoreProjectSnapshot(ctx, p, "project-3-disk-ready"); !errors.Is(err, ErrRestoreTargetExists) {
+		t.Fatalf("expected ErrRestoreTargetExists, got %v", err)
+	}
+	if _, err := svc.RestoreProjectSnapshot(ctx, p, "project-3-disk-pending"); !errors.Is(err, ErrSnapshotNotReady) {
+		t.Fatalf("expected ErrSnapshotNotReady, got %v", err)
+	}
+	if err := svc.DeleteProjectSnapshot(ctx, p, "missing"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Fatalf("expected ErrSnapshotNotFound, got %v", err)
+	}
+}
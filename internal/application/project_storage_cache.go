@@ -0,0 +1,65 @@
+package application
+
+import (
+	"sync"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+)
+
+// projectStorageCacheTTL bounds how stale the storage lists on the dashboards may be. Storage
+// operations of the platform invalidate the cache right away; the TTL covers changes made
+// directly in the cluster.
+const projectStorageCacheTTL = 15 * time.Second
+
+type storageListEntry struct {
+	items   []job.VolumeSpec
+	expires time.Time
+}
+
+// storageListCache caches project storage lists keyed by label selector.
+type storageListCache struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	ttl     time.Duration
+	entries map[string]storageListEntry
+}
+
+var projectStorageCache = newStorageListCache(projectStorageCacheTTL)
+
+func newStorageListCache(ttl time.Duration) *storageListCache {
+	return &storageListCache{
+		now:     time.Now,
+		ttl:     ttl,
+		entries: make(map[string]storageListEntry),
+	}
+}
+
+// get returns a copy of the cached list, so callers may modify it freely.
+func (c *storageListCache) get(selector string) ([]job.VolumeSpec, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[selector]
+	if !ok || !c.now().Before(e.expires) {
+		delete(c.entries, selector)
+		return nil, false
+	}
+	return append([]job.VolumeSpec(nil), e.items...), true
+}
+
+func (c *storageListCache) set(selector string, items []job.VolumeSpec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[selector] = storageListEntry{
+		items:   append([]job.VolumeSpec(nil), items...),
+		expires: c.now().Add(c.ttl),
+	}
+}
+
+// invalidate drops every cached list. Storage changes affect both the cluster-wide list and
+// the per-project lists, so all entries go.
+func (c *storageListCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]storageListEntry)
+}
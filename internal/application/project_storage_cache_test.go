@@ -0,0 +1,136 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeProjectPVCs returns perProject storages for each of projects projects.
+func fakeProjectPVCs(projects, perProject int) []runtime.Object {
+	objs := make([]runtime.Object, 0, projects*perProject)
+	for pid := 1; pid <= projects; pid++ {
+		ns := k8s.GenerateSafeResourceName("project", fmt.Sprintf("p%d", pid), uint(pid))
+		for i := 0; i < perProject; i++ {
+			storage := fmt.Sprintf("s%d", i)
+			objs = append(objs, &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      k8s.ProjectStoragePVCName(uint(pid), storage),
+					Namespace: ns,
+					Labels: k8s.MergeLabels(map[string]string{
+						"storage-type":                 "project",
+						"app.kubernetes.io/managed-by": "nthu-cscc",
+						"project-id":                   fmt.Sprintf("%d", pid),
+						"project-name":                 fmt.Sprintf("p%d", pid),
+						k8s.ProjectStorageNameLabel:    storage,
+					}, k8s.Ownership{ProjectID: uint(pid)}.Labels()),
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1000Mi")},
+					},
+				},
+			})
+		}
+	}
+	return objs
+}
+
+func countPVCLists(client *k8sfake.Clientset) int {
+	n := 0
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "list" && a.GetResource().Resource == "persistentvolumeclaims" {
+			n++
+		}
+	}
+	return n
+}
+
+func useFakePVCs(t testing.TB, objs []runtime.Object) *k8sfake.Clientset {
+	orig := k8s.Clientset
+	t.Cleanup(func() {
+		k8s.Clientset = orig
+		projectStorageCache.invalidate()
+	})
+	client := k8sfake.NewSimpleClientset(objs...)
+	k8s.Clientset = client
+	projectStorageCache.invalidate()
+	return client
+}
+
+func TestListProjectStoragesCachesAndScopes(t *testing.T) {
+	client := useFakePVCs(t, fakeProjectPVCs(100, 3))
+	svc := &K8sService{}
+	ctx := context.Background()
+
+	all, err := svc.ListAllProjectStorages(ctx)
+	if err != nil || len(all) != 300 {
+		t.Fatalf("expected 300 storages, got %d (%v)", len(all), err)
+	}
+	if _, err := svc.ListAllProjectStorages(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := countPVCLists(client); n != 1 {
+		t.Fatalf("expected the second call to hit the cache, got %d lists", n)
+	}
+
+	mine, err := svc.ListProjectStoragesByIDs(ctx, []uint{7, 42})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mine) != 6 || mine[0].ProjectID != 7 || mine[5].ProjectID != 42 {
+		t.Fatalf("expected storages of projects 7 and 42 only, got %+v", mine)
+	}
+
+	// Creating a storage invalidates the cached lists
+	if _, err := svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 7, ProjectName: "p7", Name: "extra", Size: "1Gi"}); err != nil {
+		t.Fatalf("CreateProjectPVC: %v", err)
+	}
+	mine, err = svc.ListProjectStoragesByIDs(ctx, []uint{7, 42})
+	if err != nil || len(mine) != 7 {
+		t.Fatalf("expected the new storage after invalidation, got %d (%v)", len(mine), err)
+	}
+}
+
+func TestListProjectStoragesCapacityUsesBinaryUnits(t *testing.T) {
+	useFakePVCs(t, fakeProjectPVCs(1, 1))
+
+	storages, err := (&K8sService{}).ListAllProjectStorages(context.Background())
+	if err != nil || len(storages) != 1 {
+		t.Fatalf("expected one storage, got %v (%v)", storages, err)
+	}
+	s := storages[0]
+	if s.CapacityBytes != 1000*1024*1024 || s.Size != "1000Mi" || s.Capacity != 1 {
+		t.Fatalf("unexpected capacity: bytes=%d size=%s gi=%d", s.CapacityBytes, s.Size, s.Capacity)
+	}
+}
+
+func BenchmarkListAllProjectStorages(b *testing.B) {
+	useFakePVCs(b, fakeProjectPVCs(100, 3))
+	svc := &K8sService{}
+	ctx := context.Background()
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			projectStorageCache.invalidate()
+			if _, err := svc.ListAllProjectStorages(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := svc.ListAllProjectStorages(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
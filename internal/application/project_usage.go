@@ -0,0 +1,160 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	ErrUsageMetricInvalid = errors.New("metric must be gpu, cpu or memory")
+	ErrUsageRangeInvalid  = errors.New("hours is out of range")
+)
+
+// usageColumns maps the metrics of a usage history to their sample columns.
+var usageColumns = map[string]string{
+	project.UsageMetricGPU:    "gpu",
+	project.UsageMetricCPU:    "cpu_millicores",
+	project.UsageMetricMemory: "memory_bytes",
+}
+
+var usageNow = time.Now
+
+// SampleUsage records what the running pods of every project namespace request, a zero sample
+// for namespaces without any, and drops the samples older than cfg.UsageRetention. Project
+// storage namespaces, where projects sharing one namespace run their workloads, are mapped to
+// their project by the project-id label. It returns the number of samples recorded.
+func (s *ProjectService) SampleUsage(ctx context.Context) (int, error) {
+	if k8s.Clientset == nil {
+		return 0, nil
+	}
+	namespaces, err := k8s.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: "type in (project-user,project-space)"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list project namespaces: %w", err)
+	}
+	pods, err := k8s.Clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list running pods: %w", err)
+	}
+
+	now := usageNow()
+	projectOf := make(map[string]uint, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		if ns.Labels["type"] == "project-space" {
+			if pid, ok := k8s.ProjectIDFromLabels(ns.Labels); ok {
+				projectOf[ns.Name] = pid
+			}
+		}
+	}
+	byNamespace := map[string]*project.UsageSample{}
+	sampleOf := func(ns string) *project.UsageSample {
+		if sample, ok := byNamespace[ns]; ok {
+			return sample
+		}
+		pid, ok := projectOf[ns]
+		if !ok {
+			if pid, _, ok = k8s.ParseProjectNamespace(ns); !ok {
+				return nil
+			}
+		}
+		sample := &project.UsageSample{PID: pid, Namespace: ns, SampledAt: now, SampledUnix: now.Unix()}
+		byNamespace[ns] = sample
+		return sample
+	}
+	for _, ns := range namespaces.Items {
+		sampleOf(ns.Name)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		sample := sampleOf(pod.Namespace)
+		if sample == nil {
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			req := c.Resources.Requests
+			if qty, ok := req[k8s.GPUResource]; ok {
+				sample.GPU += qty.Value()
+			}
+			if qty, ok := req[k8s.SharedGPUResource]; ok {
+				sample.GPU += qty.Value()
+			}
+			sample.CPUMillicores += req.Cpu().MilliValue()
+			sample.MemoryBytes += req.Memory().Value()
+		}
+	}
+
+	samples := make([]project.UsageSample, 0, len(byNamespace))
+	for _, sample := range byNamespace {
+		samples = append(samples, *sample)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Namespace < samples[j].Namespace })
+	if err := s.Repos.ProjectUsage.CreateSamples(samples); err != nil {
+		return 0, fmt.Errorf("failed to record usage samples: %w", err)
+	}
+	if _, err := s.Repos.ProjectUsage.DeleteSamplesBefore(now.Add(-cfg.UsageRetention).Unix()); err != nil {
+		return len(samples), fmt.Errorf("failed to drop old usage samples: %w", err)
+	}
+	return len(samples), nil
+}
+
+// UsageHistory downsamples the metric of a project over the last hours hours into at most
+// cfg.UsageHistoryPoints buckets, never narrower than cfg.UsageSampleInterval. Buckets
+// without samples are gaps, not zeros.
+func (s *ProjectService) UsageHistory(projectID uint, hours int, metric string) (*project.UsageHistory, error) {
+	if metric == "" {
+		metric = project.UsageMetricGPU
+	}
+	column, ok := usageColumns[metric]
+	if !ok {
+		return nil, ErrUsageMetricInvalid
+	}
+	if maxHours := int(cfg.UsageRetention / time.Hour); hours < 1 || hours > maxHours {
+		return nil, fmt.Errorf("%w: must be between 1 and %d", ErrUsageRangeInvalid, maxHours)
+	}
+
+	span := int64(hours) * 3600
+	width := span / int64(cfg.UsageHistoryPoints)
+	if interval := int64(cfg.UsageSampleInterval / time.Second); width < interval {
+		width = interval
+	}
+	if width < 1 {
+		width = 1
+	}
+	points := (span + width - 1) / width
+	end := usageNow().Unix() + 1
+	start := end - points*width
+
+	buckets, err := s.Repos.ProjectUsage.Buckets(projectID, column, start, end, width)
+	if err != nil {
+		return nil, err
+	}
+	history := &project.UsageHistory{
+		ProjectID:     projectID,
+		Metric:        metric,
+		Hours:         hours,
+		BucketSeconds: width,
+		Points:        make([]project.UsagePoint, points),
+	}
+	for i := range history.Points {
+		history.Points[i].Start = time.Unix(start+int64(i)*width, 0).UTC()
+	}
+	for _, b := range buckets {
+		if b.Bucket < 0 || b.Bucket >= points {
+			continue
+		}
+		maxValue, avgValue := b.MaxValue, b.AvgValue
+		history.Points[b.Bucket].Max = &maxValue
+		history.Points[b.Bucket].Avg = &avgValue
+	}
+	return history, nil
+}
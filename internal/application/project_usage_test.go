@@ -0,0 +1,136 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cfg "github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// setupUsageHistory downsamples histories to 4 points at a clock stopped at now, so an hour
+// is split in buckets of 15 minutes.
+func setupUsageHistory(t *testing.T, now time.Time) (*ProjectService, *gorm.DB) {
+	t.Helper()
+	db := dbtest.Open(t, &project.UsageSample{})
+	origNow, origPoints, origInterval := usageNow, cfg.UsageHistoryPoints, cfg.UsageSampleInterval
+	t.Cleanup(func() { usageNow, cfg.UsageHistoryPoints, cfg.UsageSampleInterval = origNow, origPoints, origInterval })
+	usageNow = func() time.Time { return now }
+	cfg.UsageHistoryPoints = 4
+	cfg.UsageSampleInterval = 5 * time.Minute
+	return NewProjectService(&repository.Repos{ProjectUsage: repository.NewProjectUsageRepo(db)}), db
+}
+
+func seedUsage(db *gorm.DB, pid uint, ns string, at int64, gpu int64) {
+	db.Create(&project.UsageSample{PID: pid, Namespace: ns, SampledAt: time.Unix(at, 0), SampledUnix: at, GPU: gpu})
+}
+
+func TestUsageHistoryBuckets(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	svc, db := setupUsageHistory(t, now)
+	start := now.Unix() + 1 - 3600
+
+	// Bucket 0: two samplings, the first over two namespaces
+	seedUsage(db, 1, "proj-1-alice", start+10, 2)
+	seedUsage(db, 1, "proj-1-bob", start+10, 1)
+	seedUsage(db, 1, "proj-1-alice", start+310, 1)
+	// Bucket 1 only has samples of another project
+	seedUsage(db, 2, "proj-2-carol", start+1000, 8)
+	// Bucket 2 and 3, the latter sampled with nothing running
+	seedUsage(db, 1, "proj-1-alice", start+1900, 4)
+	seedUsage(db, 1, "proj-1-alice", now.Unix(), 0)
+	// Before the window
+	seedUsage(db, 1, "proj-1-alice", start-1, 16)
+
+	history, err := svc.UsageHistory(1, 1, "")
+	if err != nil {
+		t.Fatalf("UsageHistory: %v", err)
+	}
+	if history.Metric != project.UsageMetricGPU || history.BucketSeconds != 900 || len(history.Points) != 4 {
+		t.Fatalf("expected 4 gpu buckets of 900s, got %+v", history)
+	}
+	if !history.Points[0].Start.Equal(time.Unix(start, 0)) {
+		t.Fatalf("expected the first bucket to start at %d, got %v", start, history.Points[0].Start)
+	}
+
+	want := []struct{ max, avg float64 }{{3, 2}, {}, {4, 4}, {0, 0}}
+	for i, p := range history.Points {
+		if i == 1 {
+			if p.Max != nil || p.Avg != nil {
+				t.Fatalf("expected bucket 1 to be a gap, got %+v", p)
+			}
+			continue
+		}
+		if p.Max == nil || p.Avg == nil || *p.Max != want[i].max || *p.Avg != want[i].avg {
+			t.Fatalf("bucket %d: expected max %v avg %v, got %+v", i, want[i].max, want[i].avg, p)
+		}
+	}
+
+	if _, err := svc.UsageHistory(1, 24, "disk"); err != ErrUsageMetricInvalid {
+		t.Fatalf("expected an unknown metric to be rejected, got %v", err)
+	}
+	if _, err := svc.UsageHistory(1, int(cfg.UsageRetention/time.Hour)+1, "cpu"); err == nil {
+		t.Fatal("expected a range beyond the retention to be rejected")
+	}
+}
+
+func TestSampleUsageRecordsProjectNamespaces(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	svc, db := setupUsageHistory(t, now)
+	seedUsage(db, 1, "proj-1-alice", now.Add(-cfg.UsageRetention).Unix()-1, 1)
+
+	origClient := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = origClient })
+	pod := func(ns, name string, phase corev1.PodPhase, requests corev1.ResourceList) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Resources: corev1.ResourceRequirements{Requests: requests}}}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	train := corev1.ResourceList{
+		k8s.GPUResource:       resource.MustParse("1"),
+		corev1.ResourceCPU:    resource.MustParse("500m"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "proj-1-alice", Labels: k8s.ProjectNamespaceLabels("proj-1-alice")}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "proj-1-bob", Labels: k8s.ProjectNamespaceLabels("proj-1-bob")}},
+		// Project 2 shares its storage namespace between members
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "course-2", Labels: k8s.MergeLabels(
+			map[string]string{"type": "project-space"}, k8s.Ownership{ProjectID: 2}.Labels())}},
+		pod("proj-1-alice", "train", corev1.PodRunning, train),
+		pod("proj-1-alice", "queued", corev1.PodPending, train),
+		pod("course-2", "alice-train", corev1.PodRunning, train),
+		pod("kube-system", "dns", corev1.PodRunning, train),
+	)
+
+	n, err := svc.SampleUsage(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("expected a sample per project namespace, got %d, %v", n, err)
+	}
+	var samples []project.UsageSample
+	db.Order("namespace").Find(&samples)
+	if len(samples) != 3 {
+		t.Fatalf("expected the sample past the retention to be dropped, got %+v", samples)
+	}
+	if shared := samples[0]; shared.Namespace != "course-2" || shared.PID != 2 || shared.GPU != 1 {
+		t.Fatalf("expected the shared namespace sampled for project 2, got %+v", shared)
+	}
+	alice, bob := samples[1], samples[2]
+	if alice.GPU != 1 || alice.CPUMillicores != 500 || alice.MemoryBytes != 1<<30 || alice.SampledUnix != now.Unix() {
+		t.Fatalf("expected only the running pod to count, got %+v", alice)
+	}
+	if bob.PID != 1 || bob.GPU != 0 || bob.CPUMillicores != 0 {
+		t.Fatalf("expected a zero sample for the idle namespace, got %+v", bob)
+	}
+}
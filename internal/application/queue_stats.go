@@ -0,0 +1,152 @@
+package application
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+var ErrInvalidStatsRange = errors.New("invalid statistics range")
+
+const (
+	// maxStatsRange caps the range of one queue statistics request
+	maxStatsRange = 90 * 24 * time.Hour
+	// defaultStatsRange is used when the request gives no start
+	defaultStatsRange = 30 * 24 * time.Hour
+)
+
+// statsBucket is the width of a bucket and how far its start lies after a multiple of the
+// width in Unix time; weeks start on Monday (1970-01-05, four days in) in UTC.
+type statsBucket struct {
+	width, offset int64
+}
+
+var statsBuckets = map[string]statsBucket{
+	"hour": {width: 3600},
+	"day":  {width: 86400},
+	"week": {width: 7 * 86400, offset: 4 * 86400},
+}
+
+// waitingStatuses are the states of a job that has not started yet
+var waitingStatuses = []string{
+	string(job.StatusPending),
+	string(job.JobStatusQueued),
+	string(job.JobStatusScheduling),
+}
+
+// GetQueueStats aggregates the jobs submitted in [from, to) per bucket ("hour", "day" or
+// "week") with the current queue depth and GPU use. A zero to is now and a zero from is 30
+// days before to; the range may not exceed 90 days.
+func (s *K8sService) GetQueueStats(from, to time.Time, bucket string) (*job.QueueStats, error) {
+	now := time.Now()
+	if to.IsZero() {
+		to = now
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultStatsRange)
+	}
+	if bucket == "" {
+		bucket = "day"
+	}
+	b, ok := statsBuckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("%w: bucket must be hour, day or week", ErrInvalidStatsRange)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidStatsRange)
+	}
+	if to.Sub(from) > maxStatsRange {
+		return nil, fmt.Errorf("%w: at most %d days per request", ErrInvalidStatsRange, int(maxStatsRange/(24*time.Hour)))
+	}
+
+	rows, err := s.repos.Job.StatsByBucket(from, to, now, b.width, b.offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate jobs: %w", err)
+	}
+	waits, err := s.repos.Job.WaitTimeCounts(from, to, b.width, b.offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate wait times: %w", err)
+	}
+
+	stats := &job.QueueStats{
+		From:    from,
+		To:      to,
+		Bucket:  bucket,
+		Buckets: make([]job.QueueStatsBucket, 0, len(rows)),
+		Current: job.CurrentQueueStats{QueueDepth: map[string]int64{}},
+	}
+	waitsByBucket := make(map[int64][]repository.WaitTimeCount)
+	for _, w := range waits {
+		waitsByBucket[w.Bucket] = append(waitsByBucket[w.Bucket], w)
+	}
+	for _, r := range rows {
+		bucketWaits := waitsByBucket[r.Bucket]
+		stats.Buckets = append(stats.Buckets, job.QueueStatsBucket{
+			Start:             time.Unix(r.Bucket, 0).UTC(),
+			Jobs:              r.Jobs,
+			Started:           r.Started,
+			WaitP50Seconds:    waitPercentile(bucketWaits, 50),
+			WaitP95Seconds:    waitPercentile(bucketWaits, 95),
+			GPUHoursRequested: gpuHours(r.RequestedGPUUnitSeconds),
+			GPUHoursGranted:   gpuHours(r.GrantedGPUUnitSeconds),
+		})
+	}
+	stats.WaitP50Seconds = waitPercentile(waits, 50)
+	stats.WaitP95Seconds = waitPercentile(waits, 95)
+
+	depth, err := s.repos.Job.CountActiveByPriority(waitingStatuses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count queued jobs: %w", err)
+	}
+	for _, d := range depth {
+		stats.Current.QueueDepth[d.Priority] += d.Count
+	}
+	units, err := s.repos.Job.SumGPUUnits([]string{string(job.JobStatusRunning)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count running GPUs: %w", err)
+	}
+	stats.Current.RunningGPUs = float64(units) / 10
+	return stats, nil
+}
+
+// waitPercentile returns the nearest-rank p-th percentile of the wait times in counts, or nil
+// when there are none.
+func waitPercentile(counts []repository.WaitTimeCount, p float64) *int64 {
+	var total int64
+	for _, c := range counts {
+		total += c.Count
+	}
+	if total == 0 {
+		return nil
+	}
+	sorted := slices.Clone(counts)
+	slices.SortFunc(sorted, func(a, b repository.WaitTimeCount) int {
+		return cmp.Compare(a.WaitSeconds, b.WaitSeconds)
+	})
+
+	rank := int64(math.Ceil(p / 100 * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	wait := sorted[len(sorted)-1].WaitSeconds
+	for _, c := range sorted {
+		seen += c.Count
+		if seen >= rank {
+			wait = c.WaitSeconds
+			break
+		}
+	}
+	return &wait
+}
+
+// gpuHours converts GPU unit seconds (dedicated GPUs count as 10 units) to GPU-hours.
+func gpuHours(unitSeconds int64) float64 {
+	return math.Round(float64(unitSeconds)/10/3600*100) / 100
+}
@@ -0,0 +1,127 @@
+package application
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func TestGetQueueStats(t *testing.T) {
+	svc, repos := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1})
+	day1 := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC) // a Monday
+	day2 := day1.Add(24 * time.Hour)
+	at := func(base time.Time, d time.Duration) *time.Time {
+		t := base.Add(d)
+		return &t
+	}
+
+	seed := []job.Job{
+		{Name: "w60", CreatedAt: day1, StartedAt: at(day1, time.Minute), CompletedAt: at(day1, 2*time.Hour),
+			GPUCount: 1, GPUType: job.GPUTypeDedicated, Status: string(job.StatusCompleted)},
+		{Name: "w120", CreatedAt: day1, StartedAt: at(day1, 2*time.Minute), CompletedAt: at(day1, time.Hour), Status: string(job.StatusCompleted)},
+		{Name: "w180", CreatedAt: day1, StartedAt: at(day1, 3*time.Minute), CompletedAt: at(day1, time.Hour), Status: string(job.StatusFailed)},
+		{Name: "w240", CreatedAt: day1, StartedAt: at(day1, 4*time.Minute), CompletedAt: at(day1, time.Hour), Status: string(job.StatusCompleted)},
+		{Name: "waiting", CreatedAt: day1, Priority: job.PriorityHigh, Status: string(job.JobStatusQueued)},
+		// Only the dispatch was recorded
+		{Name: "w600", CreatedAt: day2, DispatchedAt: at(day2, 10*time.Minute), CompletedAt: at(day2, time.Hour), Status: string(job.StatusCompleted)},
+		// Outside the range, but part of the current state
+		{Name: "old-running", CreatedAt: day1.AddDate(0, -2, 0), GPUCount: 5, GPUType: job.GPUTypeShared, Status: string(job.JobStatusRunning)},
+		{Name: "old-pending", CreatedAt: day1.AddDate(0, -2, 0), Priority: job.PriorityLow, Status: "Pending"},
+	}
+	for i := range seed {
+		seed[i].Namespace, seed[i].Image, seed[i].K8sJobName = "proj-7-user1", "busybox", seed[i].Name
+		if err := repos.Job.Create(&seed[i]); err != nil {
+			t.Fatalf("failed to seed job: %v", err)
+		}
+	}
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	stats, err := svc.GetQueueStats(from, to, "day")
+	if err != nil {
+		t.Fatalf("GetQueueStats: %v", err)
+	}
+	if len(stats.Buckets) != 2 {
+		t.Fatalf("expected two daily buckets, got %+v", stats.Buckets)
+	}
+	first, second := stats.Buckets[0], stats.Buckets[1]
+	if !first.Start.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) || first.Jobs != 5 || first.Started != 4 {
+		t.Fatalf("unexpected first bucket %+v", first)
+	}
+	// Nearest rank over 60, 120, 180, 240 seconds
+	if *first.WaitP50Seconds != 120 || *first.WaitP95Seconds != 240 {
+		t.Fatalf("expected p50 120s and p95 240s, got %d and %d", *first.WaitP50Seconds, *first.WaitP95Seconds)
+	}
+	// One dedicated GPU from submission (2h) and from its start (1h59m) to completion
+	if first.GPUHoursRequested != 2 || first.GPUHoursGranted != 1.98 {
+		t.Fatalf("expected 2 GPU-hours requested and 1.98 granted, got %v and %v", first.GPUHoursRequested, first.GPUHoursGranted)
+	}
+	if second.Jobs != 1 || *second.WaitP50Seconds != 600 || second.GPUHoursRequested != 0 {
+		t.Fatalf("unexpected second bucket %+v", second)
+	}
+	// Over the range: 60, 120, 180, 240, 600 seconds
+	if *stats.WaitP50Seconds != 180 || *stats.WaitP95Seconds != 600 {
+		t.Fatalf("expected p50 180s and p95 600s, got %d and %d", *stats.WaitP50Seconds, *stats.WaitP95Seconds)
+	}
+	if stats.Current.QueueDepth[job.PriorityHigh] != 1 || stats.Current.QueueDepth[job.PriorityLow] != 1 || stats.Current.RunningGPUs != 0.5 {
+		t.Fatalf("unexpected current stats %+v", stats.Current)
+	}
+
+	weekly, err := svc.GetQueueStats(from, to, "week")
+	if err != nil {
+		t.Fatalf("GetQueueStats: %v", err)
+	}
+	if len(weekly.Buckets) != 1 || weekly.Buckets[0].Jobs != 6 || !weekly.Buckets[0].Start.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected one week starting on Monday, got %+v", weekly.Buckets)
+	}
+	if weekly.Buckets[0].WaitP50Seconds == nil || *weekly.Buckets[0].WaitP50Seconds != 180 {
+		t.Fatalf("expected the weekly median to be 180s, got %+v", weekly.Buckets[0])
+	}
+}
+
+func TestGetQueueStatsRejectsInvalidRange(t *testing.T) {
+	svc, _ := setupJobLimits(t, project.Project{PID: 7, ProjectName: "p", GID: 1})
+	to := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		from   time.Time
+		bucket string
+	}{
+		"over 90 days":  {from: to.AddDate(0, 0, -91), bucket: "day"},
+		"from after to": {from: to.Add(time.Hour), bucket: "day"},
+		"bad bucket":    {from: to.AddDate(0, 0, -1), bucket: "month"},
+	}
+	for name, tc := range cases {
+		if _, err := svc.GetQueueStats(tc.from, to, tc.bucket); !errors.Is(err, ErrInvalidStatsRange) {
+			t.Errorf("%s: expected ErrInvalidStatsRange, got %v", name, err)
+		}
+	}
+	if _, err := svc.GetQueueStats(to.AddDate(0, 0, -90), to, ""); err != nil {
+		t.Fatalf("expected a 90 day range to be accepted, got %v", err)
+	}
+}
+
+func TestWaitPercentileNearestRank(t *testing.T) {
+	if waitPercentile(nil, 50) != nil {
+		t.Fatal("expected no percentile without wait times")
+	}
+	// Ten jobs: nine waited 10s and one 1000s, counted across two buckets
+	counts := []repository.WaitTimeCount{
+		{Bucket: 1, WaitSeconds: 1000, Count: 1},
+		{Bucket: 0, WaitSeconds: 10, Count: 5},
+		{Bucket: 1, WaitSeconds: 10, Count: 4},
+	}
+	if p := waitPercentile(counts, 50); *p != 10 {
+		t.Fatalf("expected the median to be 10s, got %d", *p)
+	}
+	if p := waitPercentile(counts, 90); *p != 10 {
+		t.Fatalf("expected the 90th percentile to be 10s, got %d", *p)
+	}
+	if p := waitPercentile(counts, 95); *p != 1000 {
+		t.Fatalf("expected the 95th percentile to be 1000s, got %d", *p)
+	}
+}
@@ -0,0 +1,100 @@
+package application
+
+import (
+	"log"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+)
+
+// RetentionReport describes the rows of one table that are past their retention window.
+type RetentionReport struct {
+	Table         string    `json:"table"`
+	RetentionDays int       `json:"retention_days"`
+	Cutoff        time.Time `json:"cutoff"`
+	Rows          int64     `json:"rows"`
+	DryRun        bool      `json:"dry_run"`
+}
+
+type retentionTarget struct {
+	table  string
+	days   int
+	count  func(cutoff time.Time) (int64, error)
+	delete func(cutoff time.Time, limit int) (int64, error)
+}
+
+func (s *AuditService) retentionTargets() []retentionTarget {
+	return []retentionTarget{
+		{
+			table:  "audit_logs",
+			days:   config.IntSetting(config.SettingAuditLogRetentionDays),
+			count:  s.Repos.Audit.CountAuditLogsBefore,
+			delete: s.Repos.Audit.DeleteAuditLogsBefore,
+		},
+		{
+			// Logs of jobs that are still pending or running are never pruned
+			table:  "job_logs",
+			days:   config.IntSetting(config.SettingJobLogRetentionDays),
+			count:  s.Repos.Job.CountExpiredLogs,
+			delete: s.Repos.Job.DeleteExpiredLogs,
+		},
+		{
+			table:  "user_activities",
+			days:   config.UserActivityRetentionDays,
+			count:  s.Repos.Activity.CountBefore,
+			delete: s.Repos.Activity.DeleteBefore,
+		},
+	}
+}
+
+// PruneExpired deletes rows older than each table's retention window in batches of
+// config.RetentionBatchSize. With dryRun it only reports how many rows would be removed.
+// Tables with a retention of 0 days are kept forever.
+func (s *AuditService) PruneExpired(dryRun bool) ([]RetentionReport, error) {
+	now := time.Now()
+	reports := make([]RetentionReport, 0, 3)
+	for _, t := range s.retentionTargets() {
+		if t.days <= 0 {
+			continue
+		}
+		report := RetentionReport{
+			Table:         t.table,
+			RetentionDays: t.days,
+			Cutoff:        now.AddDate(0, 0, -t.days),
+			DryRun:        dryRun,
+		}
+
+		var err error
+		if dryRun {
+			report.Rows, err = t.count(report.Cutoff)
+		} else {
+			report.Rows, err = pruneInBatches(config.RetentionBatchSize, func(limit int) (int64, error) {
+				return t.delete(report.Cutoff, limit)
+			})
+			log.Printf("Retention: pruned %d rows from %s older than %s", report.Rows, t.table, report.Cutoff.Format(time.RFC3339))
+		}
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// pruneInBatches calls deleteBatch until a batch removes fewer rows than the limit.
+func pruneInBatches(batchSize int, deleteBatch func(limit int) (int64, error)) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	var total int64
+	for {
+		n, err := deleteBatch(batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package application
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/activity"
+	"github.com/linskybing/platform-go/internal/domain/audit"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+func TestPruneInBatches(t *testing.T) {
+	remaining := int64(25)
+	var calls []int
+	total, err := pruneInBatches(10, func(limit int) (int64, error) {
+		calls = append(calls, limit)
+		n := min(remaining, int64(limit))
+		remaining -= n
+		return n, nil
+	})
+	if err != nil || total != 25 || len(calls) != 3 {
+		t.Fatalf("expected 25 rows in 3 batches, got %d rows in %v (%v)", total, calls, err)
+	}
+
+	// Exactly full batches need one extra empty call to detect the end
+	remaining, calls = 20, nil
+	if total, _ = pruneInBatches(10, func(limit int) (int64, error) {
+		calls = append(calls, limit)
+		n := min(remaining, int64(limit))
+		remaining -= n
+		return n, nil
+	}); total != 20 || len(calls) != 3 {
+		t.Fatalf("expected 20 rows in 3 batches, got %d rows in %v", total, calls)
+	}
+
+	boom := errors.New("lock timeout")
+	if total, err = pruneInBatches(10, func(limit int) (int64, error) { return 4, boom }); !errors.Is(err, boom) || total != 4 {
+		t.Fatalf("expected partial count and error, got %d (%v)", total, err)
+	}
+}
+
+func TestPruneExpiredSkipsActiveJobs(t *testing.T) {
+	db := dbtest.Open(t, &audit.AuditLog{}, &job.Job{}, &job.JobLog{}, &activity.UserActivity{})
+
+	origAudit, origJob, origBatch := config.AuditLogRetentionDays, config.JobLogRetentionDays, config.RetentionBatchSize
+	defer func() {
+		config.AuditLogRetentionDays, config.JobLogRetentionDays, config.RetentionBatchSize = origAudit, origJob, origBatch
+	}()
+	config.AuditLogRetentionDays, config.JobLogRetentionDays, config.RetentionBatchSize = 30, 30, 2
+
+	old := time.Now().AddDate(0, 0, -60)
+	running := job.Job{Name: "train", Namespace: "ns", Image: "img", K8sJobName: "train", Status: string(job.StatusRunning)}
+	done := job.Job{Name: "eval", Namespace: "ns", Image: "img", K8sJobName: "eval", Status: string(job.StatusCompleted)}
+	db.Create(&running)
+	db.Create(&done)
+	for i := 0; i < 5; i++ {
+		db.Create(&job.JobLog{JobID: running.ID, Content: "step", CreatedAt: old})
+		db.Create(&job.JobLog{JobID: done.ID, Content: "step", CreatedAt: old})
+		db.Create(&audit.AuditLog{UserID: 1, Action: "create", ResourceType: "job", ResourceID: "1", CreatedAt: old})
+	}
+	db.Create(&activity.UserActivity{UserID: 2, EntityType: activity.EntityProject, EntityID: 1, Action: activity.ActionView, CreatedAt: old})
+	db.Create(&job.JobLog{JobID: done.ID, Content: "fresh"})
+	db.Create(&audit.AuditLog{UserID: 1, Action: "create", ResourceType: "job", ResourceID: "1"})
+
+	svc := NewAuditService(repository.NewRepositories(db))
+
+	preview, err := svc.PruneExpired(true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(preview) != 3 || preview[0].Rows != 5 || preview[1].Rows != 5 || preview[2].Rows != 1 {
+		t.Fatalf("unexpected dry run report %+v", preview)
+	}
+	var count int64
+	db.Model(&job.JobLog{}).Count(&count)
+	if count != 11 {
+		t.Fatalf("dry run must not delete rows, %d job logs left", count)
+	}
+
+	if _, err := svc.PruneExpired(false); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	db.Model(&job.JobLog{}).Where("job_id = ?", running.ID).Count(&count)
+	if count != 5 {
+		t.Fatalf("logs of the running job must be kept, %d left", count)
+	}
+	db.Model(&job.JobLog{}).Where("job_id = ?", done.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("only the fresh log of the finished job should remain, %d left", count)
+	}
+	db.Model(&audit.AuditLog{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("only the fresh audit log should remain, %d left", count)
+	}
+}
+
+// TestBackfillLogTimestampsDatesLegacyLogs writes a log without created_at, as rows from before
+// the column existed are; the backfill dates it with its job and the next prune removes it.
+func TestBackfillLogTimestampsDatesLegacyLogs(t *testing.T) {
+	db := dbtest.Open(t, &audit.AuditLog{}, &job.Job{}, &job.JobLog{}, &activity.UserActivity{})
+	orig := config.JobLogRetentionDays
+	defer func() { config.JobLogRetentionDays = orig }()
+	config.JobLogRetentionDays = 30
+
+	completed := time.Now().AddDate(0, 0, -60)
+	done := job.Job{Name: "eval", Namespace: "ns", Image: "img", K8sJobName: "eval", Status: string(job.StatusCompleted), CompletedAt: &completed}
+	db.Create(&done)
+	db.Exec("INSERT INTO job_logs (job_id, content, created_at) VALUES (?, ?, NULL)", done.ID, "legacy")
+
+	repos := repository.NewRepositories(db)
+	n, err := repos.Job.BackfillLogTimestamps()
+	if err != nil || n != 1 {
+		t.Fatalf("expected one log dated, got %d (%v)", n, err)
+	}
+	var log job.JobLog
+	db.First(&log)
+	if log.CreatedAt.Sub(completed).Abs() > time.Second {
+		t.Fatalf("expected the log dated at the job completion %v, got %v", completed, log.CreatedAt)
+	}
+
+	if _, err := NewAuditService(repos).PruneExpired(false); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	var count int64
+	db.Model(&job.JobLog{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected the legacy log pruned, %d left", count)
+	}
+}
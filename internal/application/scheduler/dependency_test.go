@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/scheduler/executor"
+	"github.com/linskybing/platform-go/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+// memJobRepo keeps jobs by pointer so the scheduler and the test observe the same rows
+type memJobRepo struct {
+	jobs map[uint]*job.Job
+}
+
+func newMemJobRepo(jobs ...*job.Job) *memJobRepo {
+	r := &memJobRepo{jobs: make(map[uint]*job.Job)}
+	for _, j := range jobs {
+		r.jobs[j.ID] = j
+	}
+	return r
+}
+
+func (r *memJobRepo) Create(j *job.Job) error {
+	if j.ID == 0 {
+		j.ID = uint(len(r.jobs) + 100)
+	}
+	r.jobs[j.ID] = j
+	return nil
+}
+func (r *memJobRepo) GetByID(id uint) (*job.Job, error) {
+	if j, ok := r.jobs[id]; ok {
+		return j, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (r *memJobRepo) FindByID(id uint) (*job.Job, error)      { return r.GetByID(id) }
+func (r *memJobRepo) GetByUserID(uint) ([]job.Job, error)     { return nil, nil }
+func (r *memJobRepo) FindByUserID(uint) ([]job.Job, error)    { return nil, nil }
+func (r *memJobRepo) GetByProjectID(uint) ([]job.Job, error)  { return nil, nil }
+func (r *memJobRepo) FindByProjectID(uint) ([]job.Job, error) { return nil, nil }
+func (r *memJobRepo) GetByStatus(string) ([]job.Job, error)   { return nil, nil }
+func (r *memJobRepo) GetQueuedJobs() ([]job.Job, error)       { return nil, nil }
+func (r *memJobRepo) FindAll() ([]job.Job, error) {
+	all := make([]job.Job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		all = append(all, *j)
+	}
+	return all, nil
+}
+func (r *memJobRepo) FindPage(*uint, pagination.Params) (*pagination.Page[job.Job], error) {
+	return &pagination.Page[job.Job]{}, nil
+}
+func (r *memJobRepo) UpdateProgress(uint, float64, []byte) error { return nil }
+func (r *memJobRepo) UpdateWarnings(id uint, warnings []byte) error {
+	r.jobs[id].Warnings = warnings
+	return nil
+}
+func (r *memJobRepo) SaveEvent(*job.JobEvent) error                     { return nil }
+func (r *memJobRepo) SaveK8sEvent(*job.JobEvent, string) error          { return nil }
+func (r *memJobRepo) FindEvents(uint) ([]job.JobEvent, error)           { return nil, nil }
+func (r *memJobRepo) FindLogs(uint) ([]job.JobLog, error)               { return nil, nil }
+func (r *memJobRepo) SaveLog(*job.JobLog) error                         { return nil }
+func (r *memJobRepo) FindCheckpoints(uint) ([]job.JobCheckpoint, error) { return nil, nil }
+func (r *memJobRepo) Update(j *job.Job) error                           { r.jobs[j.ID] = j; return nil }
+func (r *memJobRepo) Delete(id uint) error                              { delete(r.jobs, id); return nil }
+func (r *memJobRepo) UpdateStatus(id uint, status string) error {
+	r.jobs[id].Status = status
+	return nil
+}
+func (r *memJobRepo) GetPreemptibleJobs() ([]job.Job, error)          { return nil, nil }
+func (r *memJobRepo) CountExpiredLogs(time.Time) (int64, error)       { return 0, nil }
+func (r *memJobRepo) DeleteExpiredLogs(time.Time, int) (int64, error) { return 0, nil }
+func (r *memJobRepo) BackfillLogTimestamps() (int64, error)           { return 0, nil }
+
+func dependentJob(id uint, runOnFailure bool, deps ...uint) *job.Job {
+	raw, _ := json.Marshal(deps)
+	return &job.Job{
+		ID:                     id,
+		JobType:                "test",
+		Priority:               "high",
+		Status:                 string(job.JobStatusQueued),
+		DependsOn:              string(raw),
+		RunOnDependencyFailure: runOnFailure,
+	}
+}
+
+func newChainScheduler(t *testing.T, jobs ...*job.Job) (*Scheduler, *memJobRepo) {
+	t.Helper()
+	registry := executor.NewExecutorRegistry()
+	registry.Register("test", &MockJobExecutor{})
+	repo := newMemJobRepo(jobs...)
+	sched := NewScheduler(registry, repo)
+	for _, j := range jobs {
+		sched.EnqueueJob(j)
+	}
+	return sched, repo
+}
+
+func TestSchedulerRunsThreeJobChainInOrder(t *testing.T) {
+	a := &job.Job{ID: 1, JobType: "test", Priority: "low", Status: string(job.JobStatusQueued)}
+	b := dependentJob(2, false, 1)
+	c := dependentJob(3, false, 2)
+	sched, _ := newChainScheduler(t, c, b, a)
+	ctx := context.Background()
+
+	// b and c outrank a but must wait for it
+	sched.processQueue(ctx)
+	if a.Status != string(job.StatusRunning) || b.Status != string(job.JobStatusQueued) || c.Status != string(job.JobStatusQueued) {
+		t.Fatalf("round 1: expected only a to run, got a=%s b=%s c=%s", a.Status, b.Status, c.Status)
+	}
+	if sched.GetQueueSize() != 2 {
+		t.Fatalf("waiting jobs must stay queued, got %d", sched.GetQueueSize())
+	}
+
+	// Still running: nothing else is dispatched
+	sched.processQueue(ctx)
+	if b.Status != string(job.JobStatusQueued) {
+		t.Fatalf("b started before a finished: %s", b.Status)
+	}
+
+	a.Status = string(job.StatusCompleted)
+	sched.processQueue(ctx)
+	if b.Status != string(job.StatusRunning) || c.Status != string(job.JobStatusQueued) {
+		t.Fatalf("round 3: expected b to run, got b=%s c=%s", b.Status, c.Status)
+	}
+
+	b.Status = string(job.StatusCompleted)
+	sched.processQueue(ctx)
+	if c.Status != string(job.StatusRunning) || sched.GetQueueSize() != 0 {
+		t.Fatalf("round 4: expected c to run, got c=%s queue=%d", c.Status, sched.GetQueueSize())
+	}
+}
+
+func TestSchedulerFailedMiddleJob(t *testing.T) {
+	a := &job.Job{ID: 1, Status: string(job.StatusCompleted)}
+	b := &job.Job{ID: 2, Status: string(job.StatusFailed)}
+	c := dependentJob(3, false, 1, 2)
+	d := dependentJob(4, false, 3)
+	anyway := dependentJob(5, true, 2)
+	sched, repo := newChainScheduler(t, c, anyway)
+	_ = repo.Create(a)
+	_ = repo.Create(b)
+	_ = repo.Create(d)
+	sched.EnqueueJob(d)
+	ctx := context.Background()
+
+	sched.processQueue(ctx)
+	if c.Status != string(job.StatusDependencyFailed) || c.CompletedAt == nil {
+		t.Fatalf("expected c to be DependencyFailed, got %s", c.Status)
+	}
+	if anyway.Status != string(job.StatusRunning) {
+		t.Fatalf("run-anyway job should start once its dependency finished, got %s", anyway.Status)
+	}
+
+	// The failure propagates down the chain
+	sched.processQueue(ctx)
+	if d.Status != string(job.StatusDependencyFailed) {
+		t.Fatalf("expected d to be DependencyFailed, got %s", d.Status)
+	}
+	if sched.GetQueueSize() != 0 {
+		t.Fatalf("expected empty queue, got %d", sched.GetQueueSize())
+	}
+}
@@ -0,0 +1,5 @@
+package scheduler
+
+import "github.com/linskybing/platform-go/pkg/logger"
+
+var schedulerLog = logger.For(logger.ComponentScheduler)
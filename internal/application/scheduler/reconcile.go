@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/scheduler/executor"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReconcileResult counts what the startup reconciliation found and repaired.
+type ReconcileResult struct {
+	ClusterJobs int `json:"cluster_jobs"`
+	// K8s Jobs without a row; a row was created for each
+	RecordsCreated int `json:"records_created"`
+	// Active rows whose K8s Job is gone; they were marked lost_from_cluster
+	RecordsLost int       `json:"records_lost"`
+	Errors      int       `json:"errors"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// Reconcile repairs the drift a crash between creating a K8s Job and writing its row leaves
+// behind. Platform Jobs in the cluster are matched to rows by their job-id label (falling back to
+// namespace and name); a Job without a row gets one, with the status read from the object. An
+// active row older than config.JobReconcileGracePeriod whose Job is missing is marked lost.
+// Queued rows are skipped: they have no K8s Job until the scheduler dispatches them. So are rows
+// whose executor the scheduler polls, such as external jobs, which never have one.
+func (s *Scheduler) Reconcile(ctx context.Context) (ReconcileResult, error) {
+	var result ReconcileResult
+	if s.jobRepo == nil || k8s.Clientset == nil {
+		return result, nil
+	}
+	list, err := k8s.Clientset.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: k8s.ManagedSelector})
+	if err != nil {
+		return result, fmt.Errorf("failed to list cluster jobs: %w", err)
+	}
+	rows, err := s.jobRepo.FindAll()
+	if err != nil {
+		return result, fmt.Errorf("failed to list job records: %w", err)
+	}
+	result.ClusterJobs = len(list.Items)
+
+	byID := make(map[uint]bool, len(rows))
+	byName := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		byID[r.ID] = true
+		byName[r.Namespace+"/"+r.K8sJobName] = true
+	}
+
+	inCluster := make(map[uint]bool, len(list.Items))
+	inClusterByName := make(map[string]bool, len(list.Items))
+	for i := range list.Items {
+		obj := &list.Items[i]
+		inClusterByName[obj.Namespace+"/"+obj.Name] = true
+		id, labelled := labelID(obj.Labels, k8s.LabelJobID)
+		if labelled {
+			inCluster[id] = true
+		}
+		if (labelled && byID[id]) || (!labelled && byName[obj.Namespace+"/"+obj.Name]) {
+			continue
+		}
+		if err := s.adoptClusterJob(ctx, obj, id); err != nil {
+			schedulerLog.Error("reconcile failed to record cluster job", "namespace", obj.Namespace, "name", obj.Name, "error", err)
+			result.Errors++
+			continue
+		}
+		result.RecordsCreated++
+	}
+
+	cutoff := s.now().Add(-config.JobReconcileGracePeriod)
+	for i := range rows {
+		r := &rows[i]
+		if !expectsClusterJob(r.Status) || r.CreatedAt.After(cutoff) || s.registry.Polled(r.JobType) {
+			continue
+		}
+		if inCluster[r.ID] || inClusterByName[r.Namespace+"/"+r.K8sJobName] {
+			continue
+		}
+		r.Status = string(job.JobStatusLostFromCluster)
+		r.ErrorMessage = "the Kubernetes job no longer exists"
+		now := s.now()
+		r.CompletedAt = &now
+		if err := s.jobRepo.Update(r); err != nil {
+			schedulerLog.Error("reconcile failed to mark job lost", "job_id", r.ID, "namespace", r.Namespace, "error", err)
+			result.Errors++
+			continue
+		}
+		result.RecordsLost++
+	}
+
+	result.FinishedAt = s.now()
+	s.mu.Lock()
+	s.lastReconcile = result
+	s.mu.Unlock()
+	schedulerLog.Info("reconciled jobs", "cluster_jobs", result.ClusterJobs, "records_created", result.RecordsCreated,
+		"records_lost", result.RecordsLost, "errors", result.Errors)
+	return result, nil
+}
+
+// adoptClusterJob writes the row of a K8s Job that has none. A Job labelled with the ID of a
+// row that no longer exists keeps that ID; an unlabelled one is labelled with its new row ID.
+func (s *Scheduler) adoptClusterJob(ctx context.Context, obj *batchv1.Job, id uint) error {
+	record := &job.Job{
+		ID:         id,
+		Name:       obj.Name,
+		Namespace:  obj.Namespace,
+		K8sJobName: obj.Name,
+		Priority:   job.PriorityLow,
+		JobType:    job.JobTypeNormal,
+		Status:     string(executor.InferJobStatus(obj)),
+	}
+	if uid, ok := labelID(obj.Labels, k8s.LabelUserID); ok {
+		record.UserID = uid
+	}
+	if pid, ok := k8s.ProjectIDFromLabels(obj.Labels); ok {
+		record.ProjectID = &pid
+	}
+	if containers := obj.Spec.Template.Spec.Containers; len(containers) > 0 {
+		record.Image = containers[0].Image
+	}
+	if obj.Status.StartTime != nil {
+		started := obj.Status.StartTime.Time
+		record.StartedAt = &started
+	}
+	if obj.Status.CompletionTime != nil {
+		completed := obj.Status.CompletionTime.Time
+		record.CompletedAt = &completed
+	}
+	if err := s.jobRepo.Create(record); err != nil {
+		return err
+	}
+	if id != 0 {
+		return nil
+	}
+
+	obj.Labels = k8s.MergeLabels(obj.Labels, k8s.Ownership{JobID: record.ID}.Labels())
+	if _, err := k8s.Clientset.BatchV1().Jobs(obj.Namespace).Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		schedulerLog.Warn("reconcile failed to label cluster job", "namespace", obj.Namespace, "name", obj.Name, "job_id", record.ID, "error", err)
+	}
+	return nil
+}
+
+// LastReconcile returns the result of the most recent reconciliation.
+func (s *Scheduler) LastReconcile() ReconcileResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastReconcile
+}
+
+// expectsClusterJob reports whether a row in this status should have a K8s Job.
+func expectsClusterJob(status string) bool {
+	switch job.JobStatus(strings.ToLower(status)) {
+	case job.StatusPending, job.JobStatusScheduling, job.JobStatusRunning:
+		return true
+	}
+	return false
+}
+
+func labelID(labels map[string]string, key string) (uint, bool) {
+	id, err := strconv.ParseUint(labels[key], 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
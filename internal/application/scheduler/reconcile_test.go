@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/scheduler/executor"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func clusterJob(name string, owner k8s.Ownership, status batchv1.JobStatus) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "proj-1-bob", Labels: owner.Labels()},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "main", Image: "pytorch:2.3"}},
+		}}},
+		Status: status,
+	}
+}
+
+func TestReconcileRepairsBothDirections(t *testing.T) {
+	orig, origGrace := k8s.Clientset, config.JobReconcileGracePeriod
+	defer func() { k8s.Clientset, config.JobReconcileGracePeriod = orig, origGrace }()
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		// Matched by label to row 1
+		clusterJob("tracked", k8s.Ownership{ProjectID: 1, UserID: 2, JobID: 1}, batchv1.JobStatus{Active: 1}),
+		// Row 7 was lost before it was written: recreated with the same ID
+		clusterJob("orphan", k8s.Ownership{ProjectID: 1, UserID: 2, JobID: 7}, batchv1.JobStatus{Succeeded: 1}),
+		// Created before job IDs were labelled: gets a new row and the label
+		clusterJob("legacy", k8s.Ownership{ProjectID: 1, UserID: 3}, batchv1.JobStatus{Active: 1}),
+	)
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-time.Hour)
+	repo := newMemJobRepo(
+		&job.Job{ID: 1, Namespace: "proj-1-bob", K8sJobName: "tracked", Status: string(job.JobStatusRunning), CreatedAt: old},
+		&job.Job{ID: 2, Namespace: "proj-1-bob", K8sJobName: "vanished", Status: string(job.JobStatusRunning), CreatedAt: old},
+		&job.Job{ID: 3, Namespace: "proj-1-bob", K8sJobName: "creating", Status: "Pending", CreatedAt: now.Add(-time.Minute)},
+		&job.Job{ID: 4, Namespace: "proj-1-bob", K8sJobName: "waiting", Status: string(job.JobStatusQueued), CreatedAt: old},
+		&job.Job{ID: 5, Namespace: "proj-1-bob", K8sJobName: "done", Status: string(job.StatusCompleted), CreatedAt: old},
+	)
+	sched := NewScheduler(executor.NewExecutorRegistry(), repo)
+	sched.now = func() time.Time { return now }
+	config.JobReconcileGracePeriod = 10 * time.Minute
+
+	result, err := sched.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ClusterJobs != 3 || result.RecordsCreated != 2 || result.RecordsLost != 1 || result.Errors != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if sched.LastReconcile() != result {
+		t.Fatalf("expected the result to be kept for the metrics, got %+v", sched.LastReconcile())
+	}
+
+	if got := repo.jobs[2].Status; got != string(job.JobStatusLostFromCluster) {
+		t.Fatalf("expected the vanished job to be lost, got %s", got)
+	}
+	for id, want := range map[uint]string{1: "running", 3: "Pending", 4: "queued", 5: "completed"} {
+		if repo.jobs[id].Status != want {
+			t.Fatalf("job %d: expected status %s to be kept, got %s", id, want, repo.jobs[id].Status)
+		}
+	}
+
+	orphan := repo.jobs[7]
+	if orphan == nil || orphan.Status != string(job.StatusCompleted) || orphan.UserID != 2 || orphan.ProjectID == nil || *orphan.ProjectID != 1 || orphan.Image != "pytorch:2.3" {
+		t.Fatalf("expected the orphan to be recorded from the object, got %+v", orphan)
+	}
+
+	var legacy *job.Job
+	for _, j := range repo.jobs {
+		if j.K8sJobName == "legacy" {
+			legacy = j
+		}
+	}
+	if legacy == nil || legacy.Status != string(job.JobStatusRunning) || legacy.UserID != 3 {
+		t.Fatalf("expected a row for the unlabelled job, got %+v", legacy)
+	}
+	obj, _ := k8s.Clientset.BatchV1().Jobs("proj-1-bob").Get(context.Background(), "legacy", metav1.GetOptions{})
+	if id, _ := labelID(obj.Labels, k8s.LabelJobID); id != legacy.ID {
+		t.Fatalf("expected the cluster job to be labelled with row %d, got %v", legacy.ID, obj.Labels)
+	}
+
+	// A second pass finds nothing left to repair
+	result, err = sched.Reconcile(context.Background())
+	if err != nil || result.RecordsCreated != 0 || result.RecordsLost != 0 {
+		t.Fatalf("expected reconciliation to be idempotent, got %+v (err %v)", result, err)
+	}
+}
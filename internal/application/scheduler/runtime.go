@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/scheduler/executor"
+)
+
+// runtimeSweepInterval is how often active jobs are checked against their runtime limit.
+const runtimeSweepInterval = time.Minute
+
+// WithRuntimeLimits enables the runtime sweep. limit returns the longest a job may run, 0 for
+// no limit; onTimeout is told about each job the sweep stopped, e.g. to notify its owner.
+func (s *Scheduler) WithRuntimeLimits(limit func(j *job.Job) time.Duration, onTimeout func(ctx context.Context, j *job.Job)) *Scheduler {
+	s.runtimeLimit = limit
+	s.onTimeout = onTimeout
+	return s
+}
+
+// SweepRuntimeLimits stops active jobs that ran past their runtime limit through the executor of
+// their type and marks them timed out. Kubernetes stops most of them itself through
+// ActiveDeadlineSeconds; the sweep catches those whose pods linger past the deadline, those
+// submitted before runtime limits existed and those on external clusters. Queued jobs are not running yet and are skipped. It returns how many jobs it
+// stopped.
+func (s *Scheduler) SweepRuntimeLimits(ctx context.Context) int {
+	if s.jobRepo == nil || s.runtimeLimit == nil {
+		return 0
+	}
+	rows, err := s.jobRepo.FindAll()
+	if err != nil {
+		schedulerLog.Error("runtime sweep failed to list jobs", "error", err)
+		return 0
+	}
+
+	stopped := 0
+	for i := range rows {
+		r := &rows[i]
+		if !expectsClusterJob(r.Status) {
+			continue
+		}
+		limit := s.runtimeLimit(r)
+		if limit <= 0 || s.now().Before(r.RunningSince().Add(limit)) {
+			continue
+		}
+		if err := s.registry.Cancel(ctx, r); err != nil && !errors.Is(err, executor.ErrExecutorNotFound) {
+			schedulerLog.Error("runtime sweep failed to stop job", "job_id", r.ID, "namespace", r.Namespace, "error", err)
+			continue
+		}
+		r.MarkTimedOut(s.now())
+		if err := s.jobRepo.Update(r); err != nil {
+			schedulerLog.Error("runtime sweep failed to mark job timed out", "job_id", r.ID, "namespace", r.Namespace, "error", err)
+			continue
+		}
+		schedulerLog.Info("stopped job after its runtime limit", "job_id", r.ID, "namespace", r.Namespace, "limit", limit)
+		stopped++
+		if s.onTimeout != nil {
+			s.onTimeout(ctx, r)
+		}
+	}
+	return stopped
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/scheduler/executor"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSweepRuntimeLimitsStopsOverdueJobs(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	client := k8sfake.NewSimpleClientset(
+		clusterJob("overdue", k8s.Ownership{ProjectID: 1, UserID: 2, JobID: 1}, batchv1.JobStatus{Active: 1}),
+		clusterJob("fresh", k8s.Ownership{ProjectID: 1, UserID: 2, JobID: 2}, batchv1.JobStatus{Active: 1}),
+	)
+	k8s.Clientset = client
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	started := now.Add(-3 * time.Hour)
+	hour, unlimited := int64(3600), int64(0)
+	repo := newMemJobRepo(
+		&job.Job{ID: 1, Namespace: "proj-1-bob", K8sJobName: "overdue", Status: string(job.JobStatusRunning), StartedAt: &started, MaxRuntimeSeconds: &hour},
+		&job.Job{ID: 2, Namespace: "proj-1-bob", K8sJobName: "fresh", Status: string(job.JobStatusRunning), CreatedAt: now.Add(-time.Minute), MaxRuntimeSeconds: &hour},
+		&job.Job{ID: 3, Namespace: "proj-1-bob", K8sJobName: "waiting", Status: string(job.JobStatusQueued), CreatedAt: started, MaxRuntimeSeconds: &hour},
+		&job.Job{ID: 4, Namespace: "proj-1-bob", K8sJobName: "endless", Status: string(job.JobStatusRunning), StartedAt: &started, MaxRuntimeSeconds: &unlimited},
+		// Submitted before runtime limits existed: gets the default of the limit function
+		&job.Job{ID: 5, Namespace: "proj-1-bob", K8sJobName: "legacy", Status: string(job.JobStatusRunning), CreatedAt: started},
+	)
+	var notified []uint
+	registry := executor.NewExecutorRegistry()
+	registry.Register(job.JobTypeNormal, executor.NewK8sExecutor(repo, nil))
+	sched := NewScheduler(registry, repo).WithRuntimeLimits(
+		func(j *job.Job) time.Duration {
+			if j.MaxRuntimeSeconds != nil {
+				return time.Duration(*j.MaxRuntimeSeconds) * time.Second
+			}
+			return 2 * time.Hour
+		},
+		func(_ context.Context, j *job.Job) { notified = append(notified, j.ID) },
+	)
+	sched.now = func() time.Time { return now }
+
+	if stopped := sched.SweepRuntimeLimits(context.Background()); stopped != 2 {
+		t.Fatalf("expected two jobs to be stopped, got %d", stopped)
+	}
+	for id, want := range map[uint]string{1: "timed_out", 2: "running", 3: "queued", 4: "running", 5: "timed_out"} {
+		if repo.jobs[id].Status != want {
+			t.Fatalf("job %d: expected status %s, got %s", id, want, repo.jobs[id].Status)
+		}
+	}
+	if repo.jobs[1].CompletedAt == nil || !repo.jobs[1].CompletedAt.Equal(now) || repo.jobs[1].ErrorMessage == "" {
+		t.Fatalf("expected the timed out job to be completed with a reason, got %+v", repo.jobs[1])
+	}
+	if len(notified) != 2 {
+		t.Fatalf("expected both owners to be notified, got %v", notified)
+	}
+
+	list, _ := client.BatchV1().Jobs("proj-1-bob").List(context.Background(), metav1.ListOptions{})
+	if len(list.Items) != 1 || list.Items[0].Name != "fresh" {
+		t.Fatalf("expected only the job within its limit to remain, got %+v", list.Items)
+	}
+}
@@ -2,12 +2,21 @@ package scheduler
 
 import (
 	"context"
-	"log"
+	"errors"
+	"sync"
 	"time"
 
+	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/job"
 	"github.com/linskybing/platform-go/internal/scheduler/executor"
 	"github.com/linskybing/platform-go/internal/scheduler/queue"
+	"gorm.io/gorm"
+)
+
+// Backoff between dispatch attempts of a job that asked to be retried, doubling per attempt.
+const (
+	retryBaseDelay = 15 * time.Second
+	retryMaxDelay  = 5 * time.Minute
 )
 
 // Scheduler manages job execution with priority queue
@@ -17,36 +26,80 @@ type Scheduler struct {
 	running  bool
 	jobRepo  job.Repository
 	enqueued map[uint]bool
+	// Jobs re-queued after executor.ErrRetryLater: attempts so far and the earliest next attempt
+	retries   map[uint]int
+	notBefore map[uint]time.Time
+	now       func() time.Time
+	// paused stops dispatching while it reports true, e.g. during platform maintenance
+	paused func() bool
+	// dispatchGate keeps a job queued while it returns an error, e.g. a concurrent job limit
+	dispatchGate func(j *job.Job) error
+	// runtimeLimit and onTimeout drive the runtime sweep; see WithRuntimeLimits
+	runtimeLimit func(j *job.Job) time.Duration
+	onTimeout    func(ctx context.Context, j *job.Job)
+
+	mu            sync.Mutex
+	lastReconcile ReconcileResult
 }
 
 // NewScheduler creates a new scheduler
 func NewScheduler(registry *executor.ExecutorRegistry, jobRepo job.Repository) *Scheduler {
 	return &Scheduler{
-		jobQueue: queue.NewJobQueue(),
-		registry: registry,
-		running:  false,
-		jobRepo:  jobRepo,
-		enqueued: make(map[uint]bool),
+		jobQueue:  queue.NewJobQueue(),
+		registry:  registry,
+		running:   false,
+		jobRepo:   jobRepo,
+		enqueued:  make(map[uint]bool),
+		retries:   make(map[uint]int),
+		notBefore: make(map[uint]time.Time),
+		now:       time.Now,
 	}
 }
 
+// WithPauseGate sets the check consulted before each dispatch round. While it reports true
+// queued jobs stay queued; jobs already running are not affected.
+func (s *Scheduler) WithPauseGate(paused func() bool) *Scheduler {
+	s.paused = paused
+	return s
+}
+
+// WithDispatchGate sets the check a job must pass before it is dispatched. Jobs it rejects
+// stay queued and are checked again on the next round.
+func (s *Scheduler) WithDispatchGate(gate func(j *job.Job) error) *Scheduler {
+	s.dispatchGate = gate
+	return s
+}
+
 // Start begins scheduling
 func (s *Scheduler) Start(ctx context.Context) error {
 	s.running = true
-	log.Println("Scheduler started")
+	schedulerLog.Info("scheduler started")
+
+	// Repair drift left by a crash before dispatching anything
+	if _, err := s.Reconcile(ctx); err != nil {
+		schedulerLog.Error("job reconciliation failed", "error", err)
+	}
 
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
+	sweep := time.NewTicker(runtimeSweepInterval)
+	defer sweep.Stop()
+	poll := time.NewTicker(config.ExternalJobPollInterval)
+	defer poll.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			s.running = false
-			log.Println("Scheduler stopped")
+			schedulerLog.Info("scheduler stopped")
 			return ctx.Err()
 		case <-ticker.C:
 			s.syncQueued()
 			s.processQueue(ctx)
+		case <-sweep.C:
+			s.SweepRuntimeLimits(ctx)
+		case <-poll.C:
+			s.SyncPolledStatuses(ctx)
 		}
 	}
 }
@@ -59,13 +112,130 @@ func (s *Scheduler) EnqueueJob(j *job.Job) {
 	}
 }
 
-// processQueue processes pending jobs
+// processQueue dispatches the highest-priority job whose dependencies allow it to start.
+// Jobs still waiting on a dependency are put back; jobs whose dependency failed are marked
+// DependencyFailed without running.
 func (s *Scheduler) processQueue(ctx context.Context) {
-	j := s.jobQueue.Pop()
-	if j == nil {
+	if s.paused != nil && s.paused() {
+		return
+	}
+	var waiting []*job.Job
+	defer func() {
+		for _, w := range waiting {
+			s.jobQueue.Push(w)
+		}
+	}()
+
+	for {
+		j := s.jobQueue.Pop()
+		if j == nil {
+			return
+		}
+		if until, ok := s.notBefore[j.ID]; ok && s.now().Before(until) {
+			waiting = append(waiting, j)
+			continue
+		}
+		j, ok := s.refresh(j)
+		if !ok {
+			continue
+		}
+		switch s.dependencyState(ctx, j) {
+		case job.DependenciesPending:
+			waiting = append(waiting, j)
+			continue
+		case job.DependenciesFailed:
+			s.failOnDependency(j)
+			continue
+		}
+		if s.dispatchGate != nil {
+			if err := s.dispatchGate(j); err != nil {
+				s.warn(j, job.Warning{Code: job.WarningDispatchHeld, Message: err.Error()})
+				waiting = append(waiting, j)
+				continue
+			}
+		}
+		s.dispatch(ctx, j)
 		return
 	}
+}
+
+// dependencyState looks up the current status of each of the job's dependencies.
+func (s *Scheduler) dependencyState(ctx context.Context, j *job.Job) job.DependencyState {
+	deps := j.DependencyIDs()
+	if len(deps) == 0 {
+		return job.DependenciesMet
+	}
+	if s.jobRepo == nil {
+		return job.DependenciesPending
+	}
 
+	statuses := make([]string, 0, len(deps))
+	for _, id := range deps {
+		dep, err := s.jobRepo.FindByID(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// A deleted dependency can never complete
+			statuses = append(statuses, string(job.StatusFailed))
+			continue
+		}
+		if err != nil {
+			return job.DependenciesPending
+		}
+		// Still active: pick up a completion that nothing has recorded yet
+		if job.EvaluateDependencies([]string{dep.Status}, false) == job.DependenciesPending {
+			if s.registry != nil && s.registry.Polled(dep.JobType) {
+				s.syncStatus(ctx, dep)
+			} else {
+				executor.RefreshJobStatus(ctx, s.jobRepo, dep)
+			}
+		}
+		statuses = append(statuses, dep.Status)
+	}
+	return job.EvaluateDependencies(statuses, j.RunOnDependencyFailure)
+}
+
+// refresh reloads a queued job before it is held or dispatched, since it may have been
+// cancelled or deleted since it was queued. A job that no longer waits leaves the queue and
+// refresh reports false.
+func (s *Scheduler) refresh(j *job.Job) (*job.Job, bool) {
+	if s.jobRepo == nil {
+		return j, true
+	}
+	current, err := s.jobRepo.FindByID(j.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return j, true
+	}
+	if err != nil || (current.Status != string(job.StatusPending) && current.Status != string(job.JobStatusQueued)) {
+		delete(s.enqueued, j.ID)
+		delete(s.retries, j.ID)
+		delete(s.notBefore, j.ID)
+		return nil, false
+	}
+	return current, true
+}
+
+// warn records a warning of the scheduler on the job, for the job detail view. Only the
+// warnings are written, so a status changed meanwhile, e.g. by a cancel, is kept.
+func (s *Scheduler) warn(j *job.Job, w job.Warning) {
+	if j.AddWarnings(w) && s.jobRepo != nil {
+		if err := s.jobRepo.UpdateWarnings(j.ID, j.Warnings); err != nil {
+			schedulerLog.Error("failed to record job warning", "job_id", j.ID, "error", err)
+		}
+	}
+}
+
+func (s *Scheduler) failOnDependency(j *job.Job) {
+	schedulerLog.Info("job not started: a dependency did not succeed", "job_id", j.ID, "namespace", j.Namespace)
+	j.Status = string(job.StatusDependencyFailed)
+	j.ErrorMessage = "a run-after dependency did not complete successfully"
+	now := time.Now()
+	j.CompletedAt = &now
+	delete(s.enqueued, j.ID)
+	if s.jobRepo != nil {
+		_ = s.jobRepo.Update(j)
+	}
+}
+
+func (s *Scheduler) dispatch(ctx context.Context, j *job.Job) {
 	if s.jobRepo != nil {
 		j.Status = string(job.JobStatusScheduling)
 		_ = s.jobRepo.Update(j)
@@ -74,23 +244,58 @@ func (s *Scheduler) processQueue(ctx context.Context) {
 	err := s.registry.Execute(ctx, j)
 	if err == executor.ErrExecutorNotFound {
 		// Don't change status for unregistered job types
-		log.Printf("Job executor not found for type: %s", j.JobType)
+		schedulerLog.Warn("job executor not found", "job_id", j.ID, "job_type", j.JobType)
+		return
+	}
+	if errors.Is(err, executor.ErrRetryLater) {
+		s.retryLater(j, err)
 		return
 	}
+	delete(s.retries, j.ID)
+	delete(s.notBefore, j.ID)
+	// The job left the queue; a restart may queue it again
+	delete(s.enqueued, j.ID)
 	if err != nil {
-		log.Printf("Job error: %v", err)
+		schedulerLog.Error("job dispatch failed", "job_id", j.ID, "namespace", j.Namespace, "error", err)
 		j.Status = string(job.JobStatusFailed)
 		if s.jobRepo != nil {
 			_ = s.jobRepo.Update(j)
 		}
 	} else {
 		j.Status = string(job.JobStatusRunning)
+		if j.DispatchedAt == nil {
+			now := s.now()
+			j.DispatchedAt = &now
+		}
 		if s.jobRepo != nil {
 			_ = s.jobRepo.Update(j)
 		}
 	}
 }
 
+// retryLater puts the job back in the queue, to be dispatched again after the backoff.
+func (s *Scheduler) retryLater(j *job.Job, err error) {
+	s.retries[j.ID]++
+	delay := retryBackoff(s.retries[j.ID])
+	s.notBefore[j.ID] = s.now().Add(delay)
+	schedulerLog.Info("job not started, retrying", "job_id", j.ID, "namespace", j.Namespace, "delay", delay, "error", err)
+
+	j.Status = string(job.JobStatusQueued)
+	j.ErrorMessage = err.Error()
+	if s.jobRepo != nil {
+		_ = s.jobRepo.Update(j)
+	}
+	s.jobQueue.Push(j)
+}
+
+func retryBackoff(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
 // IsRunning returns if active
 func (s *Scheduler) IsRunning() bool {
 	return s.running
@@ -108,7 +313,7 @@ func (s *Scheduler) syncQueued() {
 	}
 	jbs, err := s.jobRepo.GetQueuedJobs()
 	if err != nil {
-		log.Printf("syncQueued error: %v", err)
+		schedulerLog.Error("failed to list queued jobs", "error", err)
 		return
 	}
 	for i := range jbs {
@@ -22,15 +22,15 @@ func (m *MockJobExecutor) Execute(ctx context.Context, j *job.Job) error {
 	return nil
 }
 
-func (m *MockJobExecutor) Cancel(ctx context.Context, jobID uint) error {
+func (m *MockJobExecutor) Cancel(ctx context.Context, j *job.Job) error {
 	return nil
 }
 
-func (m *MockJobExecutor) GetStatus(ctx context.Context, jobID uint) (job.JobStatus, error) {
-	return job.StatusRunning, nil
+func (m *MockJobExecutor) Status(ctx context.Context, j *job.Job) (job.JobStatus, bool, error) {
+	return job.StatusRunning, false, nil
 }
 
-func (m *MockJobExecutor) GetLogs(ctx context.Context, jobID uint) (string, error) {
+func (m *MockJobExecutor) GetLogs(ctx context.Context, j *job.Job) (string, error) {
 	return "", nil
 }
 
@@ -146,6 +146,9 @@ func TestProcessQueueWithJob(t *testing.T) {
 	if j.Status != string(job.StatusRunning) {
 		t.Fatalf("expected job status %s, got %s", job.StatusRunning, j.Status)
 	}
+	if j.DispatchedAt == nil {
+		t.Fatal("expected the dispatch time to be recorded")
+	}
 }
 
 func TestProcessQueueWithExecutorError(t *testing.T) {
@@ -287,3 +290,148 @@ func TestSchedulerContextCancellation(t *testing.T) {
 		t.Fatal("timeout waiting for scheduler to stop")
 	}
 }
+
+// retryExecutor asks to be retried until it has been called fail times.
+type retryExecutor struct {
+	MockJobExecutor
+	fail  int
+	calls int
+}
+
+func (r *retryExecutor) Execute(ctx context.Context, j *job.Job) error {
+	r.calls++
+	if r.calls <= r.fail {
+		return executor.ErrRetryLater
+	}
+	return nil
+}
+
+func TestProcessQueueRetriesWithBackoff(t *testing.T) {
+	registry := executor.NewExecutorRegistry()
+	exec := &retryExecutor{fail: 2}
+	registry.Register("test", exec)
+
+	sched := NewScheduler(registry, nil)
+	now := time.Unix(1000, 0)
+	sched.now = func() time.Time { return now }
+
+	j := &job.Job{ID: 1, JobType: "test", Priority: "low"}
+	sched.EnqueueJob(j)
+	ctx := context.Background()
+
+	sched.processQueue(ctx)
+	if j.Status != string(job.JobStatusQueued) || sched.GetQueueSize() != 1 {
+		t.Fatalf("expected the job back in the queue, status %s size %d", j.Status, sched.GetQueueSize())
+	}
+
+	// Within the backoff the job is not dispatched again
+	now = now.Add(retryBaseDelay - time.Second)
+	sched.processQueue(ctx)
+	if exec.calls != 1 {
+		t.Fatalf("expected no attempt during backoff, got %d calls", exec.calls)
+	}
+
+	now = now.Add(time.Second)
+	sched.processQueue(ctx)
+	if exec.calls != 2 || j.Status != string(job.JobStatusQueued) {
+		t.Fatalf("expected a second attempt, got %d calls and status %s", exec.calls, j.Status)
+	}
+
+	// The second retry waits twice as long
+	now = now.Add(2*retryBaseDelay - time.Second)
+	sched.processQueue(ctx)
+	if exec.calls != 2 {
+		t.Fatalf("expected the backoff to double, got %d calls", exec.calls)
+	}
+	now = now.Add(time.Second)
+	sched.processQueue(ctx)
+	if exec.calls != 3 || j.Status != string(job.StatusRunning) || sched.GetQueueSize() != 0 {
+		t.Fatalf("expected the job to start, got %d calls, status %s", exec.calls, j.Status)
+	}
+}
+
+func TestProcessQueuePausedDuringMaintenance(t *testing.T) {
+	registry := executor.NewExecutorRegistry()
+	registry.Register("test", &MockJobExecutor{})
+
+	paused := true
+	sched := NewScheduler(registry, nil).WithPauseGate(func() bool { return paused })
+
+	j := &job.Job{ID: 1, JobType: "test", Priority: "medium", Status: string(job.JobStatusQueued)}
+	sched.EnqueueJob(j)
+
+	sched.processQueue(context.Background())
+	if sched.GetQueueSize() != 1 || j.Status != string(job.JobStatusQueued) {
+		t.Fatalf("expected the job to stay queued while paused, queue=%d status=%s", sched.GetQueueSize(), j.Status)
+	}
+
+	paused = false
+	sched.processQueue(context.Background())
+	if sched.GetQueueSize() != 0 || j.Status != string(job.StatusRunning) {
+		t.Fatalf("expected the job to start once resumed, queue=%d status=%s", sched.GetQueueSize(), j.Status)
+	}
+}
+
+func TestProcessQueueHoldsJobsRejectedByDispatchGate(t *testing.T) {
+	registry := executor.NewExecutorRegistry()
+	registry.Register("test", &MockJobExecutor{})
+
+	full := true
+	sched := NewScheduler(registry, nil).WithDispatchGate(func(j *job.Job) error {
+		if full {
+			return errors.New("limit reached")
+		}
+		return nil
+	})
+	j := &job.Job{ID: 1, JobType: "test", Status: string(job.JobStatusQueued)}
+	sched.EnqueueJob(j)
+
+	sched.processQueue(context.Background())
+	if sched.GetQueueSize() != 1 || j.Status != string(job.JobStatusQueued) {
+		t.Fatalf("a rejected job must stay queued, got queue %d status %s", sched.GetQueueSize(), j.Status)
+	}
+	if ws := j.JobWarnings(); len(ws) != 1 || ws[0].Code != job.WarningDispatchHeld || ws[0].Message != "limit reached" {
+		t.Fatalf("expected the hold recorded as a warning, got %+v", ws)
+	}
+	sched.processQueue(context.Background())
+	if ws := j.JobWarnings(); len(ws) != 1 {
+		t.Fatalf("expected a repeated hold not to add warnings, got %+v", ws)
+	}
+
+	full = false
+	sched.processQueue(context.Background())
+	if sched.GetQueueSize() != 0 || j.Status != string(job.StatusRunning) {
+		t.Fatalf("the job should start once the gate allows it, got queue %d status %s", sched.GetQueueSize(), j.Status)
+	}
+}
+
+func TestProcessQueueDropsJobsCancelledWhileQueued(t *testing.T) {
+	registry := executor.NewExecutorRegistry()
+	exec := &retryExecutor{}
+	registry.Register("test", exec)
+
+	stored := &job.Job{ID: 1, JobType: "test", Status: string(job.JobStatusQueued)}
+	repo := newMemJobRepo(stored)
+	held := true
+	sched := NewScheduler(registry, repo).WithDispatchGate(func(j *job.Job) error {
+		if held {
+			return errors.New("limit reached")
+		}
+		return nil
+	})
+	// The queue holds its own copy of the row, as after syncQueued
+	queued := *stored
+	sched.EnqueueJob(&queued)
+	sched.processQueue(context.Background())
+	if len(stored.JobWarnings()) != 1 {
+		t.Fatalf("expected the hold recorded on the row, got %+v", stored.JobWarnings())
+	}
+
+	// Cancelled while held: recording another hold must not bring it back
+	stored.Status = string(job.StatusCancelled)
+	held = false
+	sched.processQueue(context.Background())
+	if exec.calls != 0 || stored.Status != string(job.StatusCancelled) || sched.GetQueueSize() != 0 {
+		t.Fatalf("expected the cancelled job to leave the queue, got %d calls, status %s, queue %d", exec.calls, stored.Status, sched.GetQueueSize())
+	}
+}
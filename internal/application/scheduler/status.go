@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+)
+
+// SyncPolledStatuses polls the executors of active jobs that nothing else watches, such as
+// jobs on an external cluster, and records the status they report. It returns how many jobs
+// it found finished.
+func (s *Scheduler) SyncPolledStatuses(ctx context.Context) int {
+	if s.jobRepo == nil || s.registry == nil {
+		return 0
+	}
+	rows, err := s.jobRepo.FindAll()
+	if err != nil {
+		schedulerLog.Error("status sync failed to list jobs", "error", err)
+		return 0
+	}
+
+	finished := 0
+	for i := range rows {
+		r := &rows[i]
+		if s.registry.Polled(r.JobType) && s.syncStatus(ctx, r) {
+			finished++
+		}
+	}
+	return finished
+}
+
+// syncStatus records the status the executor of a polled job reports and reports whether the
+// job has finished. Jobs not started yet are left alone.
+func (s *Scheduler) syncStatus(ctx context.Context, r *job.Job) bool {
+	if !expectsClusterJob(r.Status) {
+		return false
+	}
+	status, done, err := s.registry.Status(ctx, r)
+	if err != nil {
+		schedulerLog.Warn("status sync failed to get job status", "job_id", r.ID, "namespace", r.Namespace, "error", err)
+		return false
+	}
+	if !done {
+		if status == "" || string(status) == r.Status {
+			return false
+		}
+		r.Status = string(status)
+	} else if status == job.JobStatusTimedOut {
+		r.MarkTimedOut(s.now())
+	} else {
+		now := s.now()
+		r.Status = string(status)
+		r.CompletedAt = &now
+	}
+	if err := s.jobRepo.Update(r); err != nil {
+		schedulerLog.Error("status sync failed to record job status", "job_id", r.ID, "namespace", r.Namespace, "error", err)
+		return false
+	}
+	if done && status == job.JobStatusTimedOut && s.onTimeout != nil {
+		s.onTimeout(ctx, r)
+	}
+	return done
+}
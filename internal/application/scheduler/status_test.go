@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/scheduler/executor"
+)
+
+func TestExternalJobLifecycle(t *testing.T) {
+	var mu sync.Mutex
+	state := "PENDING"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPost:
+			_ = json.NewEncoder(w).Encode(map[string]string{"id": "slurm-1", "state": state})
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]string{"id": "slurm-1", "state": state})
+		}
+	}))
+	defer srv.Close()
+	setState := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		state = s
+	}
+
+	external := &job.Job{ID: 1, JobType: job.JobTypeExternal, Priority: "low", Status: string(job.JobStatusQueued), Spec: "{}"}
+	// Watched by its executor: left alone by the status sync
+	normal := &job.Job{ID: 2, JobType: job.JobTypeNormal, Status: string(job.JobStatusRunning)}
+	repo := newMemJobRepo(external, normal)
+	registry := executor.NewExecutorRegistry()
+	registry.Register(job.JobTypeNormal, executor.NewK8sExecutor(repo, nil))
+	registry.Register(job.JobTypeExternal, executor.NewHTTPExecutor(repo, srv.URL, ""))
+	sched := NewScheduler(registry, repo)
+	ctx := context.Background()
+
+	sched.EnqueueJob(external)
+	sched.processQueue(ctx)
+	if repo.jobs[1].ExternalID != "slurm-1" || repo.jobs[1].Status != string(job.JobStatusRunning) {
+		t.Fatalf("expected the job to be submitted to the external cluster, got %+v", repo.jobs[1])
+	}
+
+	setState("PENDING")
+	if finished := sched.SyncPolledStatuses(ctx); finished != 0 || repo.jobs[1].Status != string(job.StatusPending) {
+		t.Fatalf("expected the remote pending state to be recorded, got %d %s", finished, repo.jobs[1].Status)
+	}
+
+	setState("COMPLETED")
+	if finished := sched.SyncPolledStatuses(ctx); finished != 1 {
+		t.Fatalf("expected one job to finish, got %d", finished)
+	}
+	if repo.jobs[1].Status != string(job.StatusCompleted) || repo.jobs[1].CompletedAt == nil {
+		t.Fatalf("expected the job to be completed, got %+v", repo.jobs[1])
+	}
+	if repo.jobs[2].Status != string(job.JobStatusRunning) {
+		t.Fatalf("expected the K8s job to be left to its watcher, got %s", repo.jobs[2].Status)
+	}
+}
@@ -0,0 +1,212 @@
+package application
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/linskybing/platform-go/internal/domain/search"
+	"github.com/linskybing/platform-go/internal/repository"
+)
+
+var (
+	ErrInvalidSearchQuery  = errors.New("search query must be 2 to 100 characters")
+	ErrInvalidSearchType   = errors.New("search type must be project, configfile, job or image")
+	ErrInvalidSearchCursor = errors.New("invalid search cursor")
+)
+
+const (
+	// SearchHitsPerType caps the hits returned per kind and page
+	SearchHitsPerType = 20
+	searchMinQueryLen = 2
+	searchMaxQueryLen = 100
+	// Characters of text kept on each side of a match in a snippet
+	searchSnippetContext = 40
+)
+
+// SearchService finds the projects, config files, jobs and allow-listed images a user can see.
+type SearchService struct {
+	Repos *repository.Repos
+}
+
+func NewSearchService(repos *repository.Repos) *SearchService {
+	return &SearchService{Repos: repos}
+}
+
+// Search returns the hits of each kind in types, or of every kind when types is empty. A cursor
+// from a previous result continues that result's kind alone, whatever types says.
+func (s *SearchService) Search(scope search.Scope, query string, types []string, cursor string) (*search.Result, error) {
+	query = strings.TrimSpace(query)
+	if n := utf8.RuneCountInString(query); n < searchMinQueryLen || n > searchMaxQueryLen {
+		return nil, ErrInvalidSearchQuery
+	}
+
+	var after *search.Cursor
+	if cursor != "" {
+		t, c, err := decodeSearchCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		types, after = []string{t}, c
+	}
+	if len(types) == 0 {
+		types = search.Types
+	}
+	for _, t := range types {
+		if !search.ValidType(t) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSearchType, t)
+		}
+	}
+
+	result := &search.Result{Query: query, Results: []search.TypeResult{}}
+	seen := map[string]bool{}
+	for _, t := range search.Types {
+		if !containsString(types, t) || seen[t] {
+			continue
+		}
+		seen[t] = true
+		// One extra hit tells whether there is a next page
+		hits, err := s.find(t, scope, query, after, SearchHitsPerType+1)
+		if err != nil {
+			return nil, err
+		}
+		page := search.TypeResult{Type: t, Hits: hits}
+		if len(hits) > SearchHitsPerType {
+			page.Hits = hits[:SearchHitsPerType]
+			last := page.Hits[len(page.Hits)-1]
+			page.NextCursor = encodeSearchCursor(t, last.UpdatedAt, last.ID)
+		}
+		for i := range page.Hits {
+			highlightHit(&page.Hits[i], query)
+		}
+		if page.Hits == nil {
+			page.Hits = []search.Hit{}
+		}
+		result.Results = append(result.Results, page)
+	}
+	return result, nil
+}
+
+func (s *SearchService) find(t string, scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error) {
+	switch t {
+	case search.TypeProject:
+		return s.Repos.Search.Projects(scope, query, after, limit)
+	case search.TypeConfigFile:
+		return s.Repos.Search.ConfigFiles(scope, query, after, limit)
+	case search.TypeJob:
+		return s.Repos.Search.Jobs(scope, query, after, limit)
+	case search.TypeImage:
+		hits, err := s.Repos.Search.Images(scope, query, after, limit)
+		for i := range hits {
+			// The text of an image hit is its allowed tag
+			if hits[i].Text != "" {
+				hits[i].Name += ":" + hits[i].Text
+				hits[i].Text = ""
+			}
+		}
+		return hits, err
+	}
+	return nil, ErrInvalidSearchType
+}
+
+// highlightHit sets the snippet of a hit: its name when the name matches, otherwise the part of
+// its text around the first match.
+func highlightHit(h *search.Hit, query string) {
+	q := lowerRunes(query)
+	name := []rune(h.Name)
+	if ranges := matchRanges(lowerRunes(h.Name), q); len(ranges) > 0 || h.Text == "" {
+		h.Snippet, h.Highlights = h.Name, ranges
+		if h.Highlights == nil {
+			h.Highlights = []search.Highlight{}
+		}
+		return
+	}
+
+	text := []rune(strings.Join(strings.Fields(h.Text), " "))
+	ranges := matchRanges(lowerRunes(string(text)), q)
+	if len(ranges) == 0 {
+		h.Snippet, h.Highlights = string(name), []search.Highlight{}
+		return
+	}
+	start := ranges[0].Start - searchSnippetContext
+	if start < 0 {
+		start = 0
+	}
+	end := ranges[0].End + searchSnippetContext
+	if end > len(text) {
+		end = len(text)
+	}
+
+	prefix := ""
+	if start > 0 {
+		prefix = "…"
+	}
+	suffix := ""
+	if end < len(text) {
+		suffix = "…"
+	}
+	h.Snippet = prefix + string(text[start:end]) + suffix
+	shift := utf8.RuneCountInString(prefix) - start
+	h.Highlights = []search.Highlight{}
+	for _, r := range ranges {
+		if r.Start >= start && r.End <= end {
+			h.Highlights = append(h.Highlights, search.Highlight{Start: r.Start + shift, End: r.End + shift})
+		}
+	}
+}
+
+// lowerRunes lowercases s rune by rune, so offsets into the result are offsets into s.
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// matchRanges returns the non-overlapping occurrences of q in s.
+func matchRanges(s, q []rune) []search.Highlight {
+	var ranges []search.Highlight
+	if len(q) == 0 {
+		return nil
+	}
+	for i := 0; i+len(q) <= len(s); {
+		if string(s[i:i+len(q)]) == string(q) {
+			ranges = append(ranges, search.Highlight{Start: i, End: i + len(q)})
+			i += len(q)
+			continue
+		}
+		i++
+	}
+	return ranges
+}
+
+func encodeSearchCursor(t string, updatedAt time.Time, id uint) string {
+	raw := fmt.Sprintf("%s:%d:%d", t, updatedAt.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string) (string, *search.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", nil, ErrInvalidSearchCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || !search.ValidType(parts[0]) {
+		return "", nil, ErrInvalidSearchCursor
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", nil, ErrInvalidSearchCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return "", nil, ErrInvalidSearchCursor
+	}
+	return parts[0], &search.Cursor{UpdatedAt: time.Unix(0, nanos).UTC(), ID: uint(id)}, nil
+}
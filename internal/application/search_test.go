@@ -0,0 +1,169 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/search"
+	"github.com/linskybing/platform-go/internal/repository"
+	"gorm.io/gorm"
+)
+
+func setupSearch(t *testing.T) (*SearchService, *gorm.DB) {
+	t.Helper()
+	db := dbtest.Open(t, &project.Project{}, &group.UserGroup{}, &configfile.ConfigFile{}, &job.Job{},
+		&image.ContainerRepository{}, &image.ContainerTag{}, &image.ImageAllowList{})
+	return NewSearchService(repository.NewRepositories(db)), db
+}
+
+func hitNames(r *search.Result, t string) []string {
+	names := []string{}
+	for _, res := range r.Results {
+		if res.Type == t {
+			for _, h := range res.Hits {
+				names = append(names, h.Name)
+			}
+		}
+	}
+	return names
+}
+
+func TestSearchScopesHitsToTheCaller(t *testing.T) {
+	svc, db := setupSearch(t)
+	mine := project.Project{ProjectName: "vision", GID: 1, Description: "Tensorboard dashboards for the vision course"}
+	foreign := project.Project{ProjectName: "nlp", GID: 2, Description: "tensorboard too"}
+	db.Create(&mine)
+	db.Create(&foreign)
+	db.Create(&group.UserGroup{UID: 2, GID: 1, Role: "user"})
+	db.Create(&group.UserGroup{UID: 3, GID: 2, Role: "user"})
+
+	db.Create(&configfile.ConfigFile{Filename: "board.yaml", Content: "kind: Deployment\nimage: tensorflow/tensorboard:2.15", ProjectID: mine.PID})
+	db.Create(&configfile.ConfigFile{Filename: "tensorboard.yaml", Content: "kind: Pod", ProjectID: foreign.PID})
+	trashed := configfile.ConfigFile{Filename: "old-tensorboard.yaml", ProjectID: mine.PID}
+	db.Create(&trashed)
+	db.Delete(&trashed)
+
+	db.Create(&job.Job{UserID: 2, ProjectID: &mine.PID, Name: "tensorboard-export", Namespace: "ns", Image: "python:3.12", K8sJobName: "a"})
+	db.Create(&job.Job{UserID: 3, ProjectID: &mine.PID, Name: "tensorboard-other", Namespace: "ns", Image: "python:3.12", K8sJobName: "b"})
+
+	global := image.ContainerRepository{Name: "tensorboard", FullName: "tensorflow/tensorboard"}
+	private := image.ContainerRepository{Name: "tensorboard", FullName: "nlp/tensorboard"}
+	db.Create(&global)
+	db.Create(&private)
+	tag := image.ContainerTag{RepositoryID: global.ID, Name: "2.15"}
+	db.Create(&tag)
+	db.Create(&image.ImageAllowList{RepositoryID: global.ID, TagID: &tag.ID, IsEnabled: true})
+	db.Create(&image.ImageAllowList{RepositoryID: private.ID, ProjectID: &foreign.PID, IsEnabled: true})
+
+	result, err := svc.Search(search.Scope{UserID: 2}, "TensorBoard", nil, "")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if got := hitNames(result, search.TypeProject); len(got) != 1 || got[0] != "vision" {
+		t.Fatalf("expected only the member's project, got %v", got)
+	}
+	if got := hitNames(result, search.TypeConfigFile); len(got) != 1 || got[0] != "board.yaml" {
+		t.Fatalf("the config file of a foreign project must not appear, got %v", got)
+	}
+	if got := hitNames(result, search.TypeJob); len(got) != 1 || got[0] != "tensorboard-export" {
+		t.Fatalf("expected only the caller's job, got %v", got)
+	}
+	if got := hitNames(result, search.TypeImage); len(got) != 1 || got[0] != "tensorflow/tensorboard:2.15" {
+		t.Fatalf("expected only the globally allowed image, got %v", got)
+	}
+
+	all, err := svc.Search(search.Scope{UserID: 1, All: true}, "tensorboard", []string{search.TypeConfigFile}, "")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(all.Results) != 1 || len(hitNames(all, search.TypeConfigFile)) != 2 {
+		t.Fatalf("expected an admin to find both live config files, got %+v", all.Results)
+	}
+
+	// LIKE wildcards in the query match themselves only
+	if none, _ := svc.Search(search.Scope{UserID: 2}, "%_", nil, ""); len(hitNames(none, search.TypeConfigFile)) != 0 {
+		t.Fatalf("expected wildcards to be escaped, got %v", hitNames(none, search.TypeConfigFile))
+	}
+}
+
+func TestSearchPaginatesPerType(t *testing.T) {
+	svc, db := setupSearch(t)
+	p := project.Project{ProjectName: "course", GID: 1}
+	db.Create(&p)
+	db.Create(&group.UserGroup{UID: 2, GID: 1, Role: "user"})
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 25; i++ {
+		// Pairs of files share an update time, ordered by ID
+		db.Create(&configfile.ConfigFile{Filename: fmt.Sprintf("lab-%02d.yaml", i), ProjectID: p.PID, UpdatedAt: base.Add(time.Duration(i/2) * time.Minute)})
+	}
+
+	first, err := svc.Search(search.Scope{UserID: 2}, "lab-", []string{search.TypeConfigFile}, "")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	page := first.Results[0]
+	if len(page.Hits) != SearchHitsPerType || page.NextCursor == "" || page.Hits[0].Name != "lab-24.yaml" {
+		t.Fatalf("expected a full first page starting with the newest file, got %d hits, cursor %q", len(page.Hits), page.NextCursor)
+	}
+
+	// The cursor continues the config files even when other kinds are asked for
+	second, err := svc.Search(search.Scope{UserID: 2}, "lab-", []string{search.TypeProject}, page.NextCursor)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	rest := second.Results[0]
+	if len(second.Results) != 1 || rest.Type != search.TypeConfigFile || len(rest.Hits) != 5 || rest.NextCursor != "" {
+		t.Fatalf("expected the last 5 config files, got %+v", second.Results)
+	}
+	seen := map[string]bool{}
+	for _, h := range append(page.Hits, rest.Hits...) {
+		if seen[h.Name] {
+			t.Fatalf("%s listed twice", h.Name)
+		}
+		seen[h.Name] = true
+	}
+	if len(seen) != 25 {
+		t.Fatalf("expected every file once, got %d", len(seen))
+	}
+
+	if _, err := svc.Search(search.Scope{UserID: 2}, "lab-", nil, "not-a-cursor"); !errors.Is(err, ErrInvalidSearchCursor) {
+		t.Fatalf("expected an invalid cursor error, got %v", err)
+	}
+	if _, err := svc.Search(search.Scope{UserID: 2}, "lab-", []string{"user"}, ""); !errors.Is(err, ErrInvalidSearchType) {
+		t.Fatalf("expected an invalid type error, got %v", err)
+	}
+	if _, err := svc.Search(search.Scope{UserID: 2}, " a ", nil, ""); !errors.Is(err, ErrInvalidSearchQuery) {
+		t.Fatalf("expected a too short query to be rejected, got %v", err)
+	}
+}
+
+func TestHighlightHit(t *testing.T) {
+	h := search.Hit{Name: "TensorBoard.yaml"}
+	highlightHit(&h, "board")
+	if h.Snippet != "TensorBoard.yaml" || len(h.Highlights) != 1 || h.Highlights[0] != (search.Highlight{Start: 6, End: 11}) {
+		t.Fatalf("expected the name to be highlighted, got %+v", h)
+	}
+
+	text := strings.Repeat("x", 100) + "\n  image: tensorboard\n" + strings.Repeat("y", 100)
+	h = search.Hit{Name: "lab.yaml", Text: text}
+	highlightHit(&h, "TENSORBOARD")
+	runes := []rune(h.Snippet)
+	if !strings.HasPrefix(h.Snippet, "…") || !strings.HasSuffix(h.Snippet, "…") || len(h.Highlights) != 1 {
+		t.Fatalf("expected a trimmed snippet around the match, got %+v", h)
+	}
+	if got := string(runes[h.Highlights[0].Start:h.Highlights[0].End]); got != "tensorboard" {
+		t.Fatalf("expected the highlight to cover the match, got %q in %q", got, h.Snippet)
+	}
+	if strings.Contains(h.Snippet, "\n") {
+		t.Fatalf("expected whitespace to be collapsed, got %q", h.Snippet)
+	}
+}
@@ -0,0 +1,192 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/setting"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+var ErrUnknownSetting = errors.New("unknown setting")
+
+// SettingsService stores the runtime settings admins change and loads them into config, where
+// the code reads them through config.IntSetting and friends. Other processes pick a change up
+// at their next Refresh, every config.SettingsRefreshInterval.
+type SettingsService struct {
+	Repos *repository.Repos
+
+	mu        sync.Mutex
+	loaded    map[string]string
+	listeners []func(keys []string)
+}
+
+func NewSettingsService(repos *repository.Repos) *SettingsService {
+	return &SettingsService{Repos: repos, loaded: map[string]string{}}
+}
+
+// OnChange registers fn to be called with the keys whose value changed at a refresh.
+func (s *SettingsService) OnChange(fn func(keys []string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Refresh loads the stored settings. A stored value that is no longer valid is logged and
+// ignored, so the environment default applies.
+func (s *SettingsService) Refresh() error {
+	rows, err := s.Repos.Setting.List()
+	if err != nil {
+		return err
+	}
+	raw := make(map[string]string, len(rows))
+	values := make(map[string]any, len(rows))
+	for _, row := range rows {
+		def, ok := config.LookupSetting(row.Key)
+		if !ok {
+			continue
+		}
+		v, err := def.Parse(row.Value)
+		if err != nil {
+			log.Printf("Ignoring stored setting %s: %v", row.Key, err)
+			continue
+		}
+		raw[row.Key], values[row.Key] = row.Value, v
+	}
+
+	s.mu.Lock()
+	var changed []string
+	for _, def := range config.SettingDefs() {
+		old, hadOld := s.loaded[def.Key]
+		now, hasNow := raw[def.Key]
+		if hadOld != hasNow || old != now {
+			changed = append(changed, def.Key)
+		}
+	}
+	config.SetSettingOverrides(values)
+	s.loaded = raw
+	listeners := append([]func([]string){}, s.listeners...)
+	s.mu.Unlock()
+
+	if len(changed) > 0 {
+		for _, fn := range listeners {
+			fn(changed)
+		}
+	}
+	return nil
+}
+
+// List returns every runtime setting with its current value.
+func (s *SettingsService) List() ([]setting.SettingDTO, error) {
+	rows, err := s.Repos.Setting.List()
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]setting.Setting, len(rows))
+	for _, row := range rows {
+		stored[row.Key] = row
+	}
+
+	defs := config.SettingDefs()
+	out := make([]setting.SettingDTO, 0, len(defs))
+	for _, def := range defs {
+		dto := setting.SettingDTO{
+			Key:         def.Key,
+			Type:        string(def.Type),
+			Description: def.Description,
+			Default:     def.EnvValue(),
+			Value:       def.EnvValue(),
+		}
+		if row, ok := stored[def.Key]; ok {
+			if _, err := def.Parse(row.Value); err == nil {
+				updatedAt := row.UpdatedAt
+				dto.Value, dto.Overridden = row.Value, true
+				dto.UpdatedBy, dto.UpdatedAt = row.UpdatedBy, &updatedAt
+			}
+		}
+		out = append(out, dto)
+	}
+	return out, nil
+}
+
+// Update stores the given settings, or removes them for nil values, and applies them in this
+// process at once. Nothing is stored unless every value is valid.
+func (s *SettingsService) Update(c *gin.Context, adminID uint, input setting.UpdateSettingsInput) ([]setting.SettingDTO, error) {
+	keys := make([]string, 0, len(input.Settings))
+	for key := range input.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make(map[string]*string, len(keys))
+	for _, key := range keys {
+		def, ok := config.LookupSetting(key)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSetting, key)
+		}
+		raw := input.Settings[key]
+		if raw == nil {
+			values[key] = nil
+			continue
+		}
+		v, err := def.Parse(strings.TrimSpace(*raw))
+		if err != nil {
+			return nil, err
+		}
+		canonical := formatSettingValue(v)
+		values[key] = &canonical
+	}
+
+	old, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	err = s.Repos.ExecTx(func(tx *repository.Repos) error {
+		for _, key := range keys {
+			if values[key] == nil {
+				if err := tx.Setting.Delete(key); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Setting.Save(&setting.Setting{Key: key, Value: *values[key], UpdatedBy: adminID}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	utils.LogAuditWithConsole(c, "update", "settings", strings.Join(keys, ","), old, updated, "", s.Repos.Audit)
+	return updated, nil
+}
+
+func formatSettingValue(v any) string {
+	switch v := v.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case time.Duration:
+		return v.String()
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
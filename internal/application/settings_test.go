@@ -0,0 +1,167 @@
+package application
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/setting"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+)
+
+func newSettingsTestService(t *testing.T) (*SettingsService, *repository.Repos) {
+	t.Helper()
+	db := dbtest.Open(t, &setting.Setting{})
+	origLog := utils.LogAuditWithConsole
+	t.Cleanup(func() {
+		utils.LogAuditWithConsole = origLog
+		config.SetSettingOverrides(map[string]any{})
+	})
+	utils.LogAuditWithConsole = func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo) {}
+	repos := repository.NewRepositories(db)
+	return NewSettingsService(repos), repos
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestStoredSettingOverridesEnv(t *testing.T) {
+	origDays, origTTL, origSize := config.AuditLogRetentionDays, config.PortForwardIdleTimeout, config.UserPVSize
+	t.Cleanup(func() {
+		config.AuditLogRetentionDays, config.PortForwardIdleTimeout, config.UserPVSize = origDays, origTTL, origSize
+	})
+	config.AuditLogRetentionDays, config.PortForwardIdleTimeout, config.UserPVSize = 30, 10*time.Minute, "10Gi"
+	svc, _ := newSettingsTestService(t)
+
+	if got := config.IntSetting(config.SettingAuditLogRetentionDays); got != 30 {
+		t.Fatalf("expected the env value while nothing is stored, got %d", got)
+	}
+
+	_, err := svc.Update(nil, 1, setting.UpdateSettingsInput{Settings: map[string]*string{
+		config.SettingAuditLogRetentionDays:  strPtr("7"),
+		config.SettingPortForwardIdleTimeout: strPtr(" 90s "),
+		config.SettingUserPVSize:             strPtr("20Gi"),
+	}})
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if got := config.IntSetting(config.SettingAuditLogRetentionDays); got != 7 {
+		t.Errorf("expected the stored retention to win, got %d", got)
+	}
+	if got := config.DurationSetting(config.SettingPortForwardIdleTimeout); got != 90*time.Second {
+		t.Errorf("expected the stored idle timeout to win, got %s", got)
+	}
+	if got := config.StringSetting(config.SettingUserPVSize); got != "20Gi" {
+		t.Errorf("expected the stored size to win, got %s", got)
+	}
+	if config.AuditLogRetentionDays != 30 {
+		t.Errorf("the env value must stay untouched, got %d", config.AuditLogRetentionDays)
+	}
+
+	list, err := svc.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	for _, s := range list {
+		if s.Key == config.SettingPortForwardIdleTimeout && (s.Value != "1m30s" || s.Default != "10m0s" || !s.Overridden) {
+			t.Errorf("unexpected listing %+v", s)
+		}
+		if s.Key == config.SettingJobLogRetentionDays && s.Overridden {
+			t.Errorf("a setting never stored should not be overridden: %+v", s)
+		}
+	}
+
+	// A null value returns the setting to its env default
+	if _, err := svc.Update(nil, 1, setting.UpdateSettingsInput{Settings: map[string]*string{
+		config.SettingAuditLogRetentionDays: nil,
+	}}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if got := config.IntSetting(config.SettingAuditLogRetentionDays); got != 30 {
+		t.Errorf("expected the env value after the reset, got %d", got)
+	}
+}
+
+func TestSettingsRefreshPicksUpOtherProcesses(t *testing.T) {
+	svc, repos := newSettingsTestService(t)
+	var changes [][]string
+	svc.OnChange(func(keys []string) { changes = append(changes, keys) })
+
+	// Another API process stores a value directly
+	if err := repos.Setting.Save(&setting.Setting{Key: config.SettingImagePullMaxConcurrent, Value: "8"}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := svc.Refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if got := config.IntSetting(config.SettingImagePullMaxConcurrent); got != 8 {
+		t.Fatalf("expected the refreshed value, got %d", got)
+	}
+	if len(changes) != 1 || len(changes[0]) != 1 || changes[0][0] != config.SettingImagePullMaxConcurrent {
+		t.Fatalf("expected one change notification, got %v", changes)
+	}
+
+	if err := svc.Refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("an unchanged refresh should not notify, got %v", changes)
+	}
+
+	// A stored value that became invalid is ignored instead of breaking the process
+	if err := repos.Setting.Save(&setting.Setting{Key: config.SettingImagePullMaxConcurrent, Value: "zero"}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := svc.Refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if got := config.IntSetting(config.SettingImagePullMaxConcurrent); got != config.ImagePullMaxConcurrent {
+		t.Fatalf("expected the env value for an invalid stored value, got %d", got)
+	}
+}
+
+func TestUpdateSettingsValidatesTypes(t *testing.T) {
+	svc, repos := newSettingsTestService(t)
+	cases := []struct {
+		key, value string
+		wantErr    error
+	}{
+		{config.SettingAuditLogRetentionDays, "seven", config.ErrInvalidSetting},
+		{config.SettingAuditLogRetentionDays, "-1", config.ErrInvalidSetting},
+		{config.SettingImagePullMaxConcurrent, "0", config.ErrInvalidSetting},
+		{config.SettingPortForwardIdleTimeout, "10", config.ErrInvalidSetting},
+		{config.SettingTerminalShareTokenTTL, "0s", config.ErrInvalidSetting},
+		{config.SettingUserPVSize, "big", config.ErrInvalidSetting},
+		{config.SettingProjectPVSize, "-5Gi", config.ErrInvalidSetting},
+		{"db_dsn", "postgres://", ErrUnknownSetting},
+		{"jwt_secret", "x", ErrUnknownSetting},
+	}
+	for _, tc := range cases {
+		_, err := svc.Update(nil, 1, setting.UpdateSettingsInput{Settings: map[string]*string{
+			config.SettingJobLogRetentionDays: strPtr("10"),
+			tc.key:                            strPtr(tc.value),
+		}})
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s=%q: expected %v, got %v", tc.key, tc.value, tc.wantErr, err)
+		}
+	}
+	rows, err := repos.Setting.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("nothing should be stored when a value is invalid, got %+v", rows)
+	}
+
+	if _, err := svc.Update(nil, 1, setting.UpdateSettingsInput{Settings: map[string]*string{
+		config.SettingProjectPVSize: strPtr("1536Mi"),
+	}}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if got := config.StringSetting(config.SettingProjectPVSize); got != "1536Mi" {
+		t.Fatalf("expected the quantity in canonical form, got %s", got)
+	}
+}
@@ -0,0 +1,106 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var ErrInvalidStorageRequest = errors.New("invalid storage request")
+
+// Fields of a storage request a StorageRequestError points at.
+const (
+	StorageFieldClass = "storage_class"
+	StorageFieldSize  = "size"
+)
+
+// StorageRequestError is returned when a storage request names a class users may not use or a
+// size outside the configured bounds. Allowed, MinSize and MaxSize tell the form what would be
+// accepted.
+type StorageRequestError struct {
+	Field   string
+	Value   string
+	Allowed []string
+	MinSize string
+	MaxSize string
+}
+
+func (e *StorageRequestError) Error() string {
+	if e.Field == StorageFieldClass {
+		return fmt.Sprintf("%s: storage class %q is not available, choose one of %s", ErrInvalidStorageRequest, e.Value, strings.Join(e.Allowed, ", "))
+	}
+	return fmt.Sprintf("%s: size %s is outside the allowed range %s", ErrInvalidStorageRequest, e.Value, sizeRange(e.MinSize, e.MaxSize))
+}
+
+func (e *StorageRequestError) Unwrap() error {
+	return ErrInvalidStorageRequest
+}
+
+func sizeRange(minSize, maxSize string) string {
+	switch {
+	case minSize != "" && maxSize != "":
+		return minSize + " to " + maxSize
+	case maxSize != "":
+		return "up to " + maxSize
+	}
+	return "from " + minSize
+}
+
+// storageClassAllowed reports whether users may request the class, per config.UserStorageClasses.
+func storageClassAllowed(name string) bool {
+	return len(config.UserStorageClasses) == 0 || containsString(config.UserStorageClasses, name)
+}
+
+// ListStorageClasses returns the StorageClasses of the cluster with whether users may request
+// them and the size bounds of a request.
+func (s *K8sService) ListStorageClasses(ctx context.Context) ([]job.StorageClassOption, error) {
+	classes, err := k8s.ListStorageClasses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	options := make([]job.StorageClassOption, 0, len(classes))
+	for i := range classes {
+		sc := &classes[i]
+		options = append(options, job.StorageClassOption{
+			Name:                 sc.Name,
+			Provisioner:          sc.Provisioner,
+			AllowVolumeExpansion: sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion,
+			IsDefault:            k8s.IsDefaultStorageClass(sc),
+			SupportsRWX:          config.StorageClassRWXSupport[sc.Name],
+			AllowedForUsers:      storageClassAllowed(sc.Name),
+			MinSize:              config.StorageMinSize,
+			MaxSize:              config.StorageMaxSize,
+		})
+	}
+	return options, nil
+}
+
+// checkStorageRequest validates what a user asked for: the class against the allowed classes
+// and the size against config.StorageMinSize and config.StorageMaxSize. Empty values are the
+// group defaults set by admins and are not checked.
+func checkStorageRequest(class, size string) error {
+	if class != "" && !storageClassAllowed(class) {
+		return &StorageRequestError{Field: StorageFieldClass, Value: class, Allowed: config.UserStorageClasses}
+	}
+	if size == "" {
+		return nil
+	}
+	requested, err := resource.ParseQuantity(size)
+	if err != nil {
+		return fmt.Errorf("%w: invalid size %q", ErrInvalidStorageRequest, size)
+	}
+	outOfRange := &StorageRequestError{Field: StorageFieldSize, Value: size, MinSize: config.StorageMinSize, MaxSize: config.StorageMaxSize}
+	if minSize, err := resource.ParseQuantity(config.StorageMinSize); err == nil && requested.Cmp(minSize) < 0 {
+		return outOfRange
+	}
+	if maxSize, err := resource.ParseQuantity(config.StorageMaxSize); err == nil && requested.Cmp(maxSize) > 0 {
+		return outOfRange
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func restoreStorageLimits(t *testing.T) {
+	origClasses, origMin, origMax := config.UserStorageClasses, config.StorageMinSize, config.StorageMaxSize
+	t.Cleanup(func() {
+		config.UserStorageClasses, config.StorageMinSize, config.StorageMaxSize = origClasses, origMin, origMax
+	})
+}
+
+func TestListStorageClasses(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	restoreStorageLimits(t)
+	expand := true
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		&storagev1.StorageClass{
+			ObjectMeta:           metav1.ObjectMeta{Name: "longhorn", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}},
+			Provisioner:          "driver.longhorn.io",
+			AllowVolumeExpansion: &expand,
+		},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "nfs-client"}, Provisioner: "nfs-subdir-external-provisioner"},
+	)
+	config.UserStorageClasses = []string{"longhorn"}
+	config.StorageMinSize, config.StorageMaxSize = "1Gi", "500Gi"
+
+	options, err := (&K8sService{}).ListStorageClasses(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byName := map[string]job.StorageClassOption{}
+	for _, o := range options {
+		byName[o.Name] = o
+	}
+	lh, nfs := byName["longhorn"], byName["nfs-client"]
+	if !lh.IsDefault || !lh.AllowVolumeExpansion || !lh.AllowedForUsers || lh.Provisioner != "driver.longhorn.io" || lh.MaxSize != "500Gi" {
+		t.Fatalf("unexpected longhorn option: %+v", lh)
+	}
+	if nfs.IsDefault || nfs.AllowedForUsers || !nfs.SupportsRWX {
+		t.Fatalf("unexpected nfs-client option: %+v", nfs)
+	}
+}
+
+func TestCreateProjectPVCValidatesStorageRequest(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	restoreStorageLimits(t)
+	k8s.Clientset = k8sfake.NewSimpleClientset()
+	config.UserStorageClasses = []string{"longhorn"}
+	config.StorageMinSize, config.StorageMaxSize = "1Gi", "100Gi"
+	svc := &K8sService{}
+	ctx := context.Background()
+
+	_, err := svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 1, ProjectName: "demo", Size: "10Gi", StorageClassName: "nfs-client"})
+	var storageErr *StorageRequestError
+	if !errors.As(err, &storageErr) || storageErr.Field != StorageFieldClass || len(storageErr.Allowed) != 1 || storageErr.Allowed[0] != "longhorn" {
+		t.Fatalf("expected the disallowed class to be rejected with the allowed ones, got %v", err)
+	}
+
+	for _, size := range []string{"500Mi", "200Gi"} {
+		_, err = svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 1, ProjectName: "demo", Size: size, StorageClassName: "longhorn"})
+		if !errors.As(err, &storageErr) || storageErr.Field != StorageFieldSize || storageErr.MinSize != "1Gi" || storageErr.MaxSize != "100Gi" {
+			t.Fatalf("expected size %s to be rejected with the bounds, got %v", size, err)
+		}
+		if !errors.Is(err, ErrInvalidStorageRequest) {
+			t.Fatalf("expected ErrInvalidStorageRequest, got %v", err)
+		}
+	}
+
+	if _, err := svc.CreateProjectPVC(ctx, job.VolumeSpec{ProjectID: 1, ProjectName: "demo", Size: "100Gi", StorageClassName: "longhorn"}); err != nil {
+		t.Fatalf("expected a request within the bounds to succeed, got %v", err)
+	}
+}
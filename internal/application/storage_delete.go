@@ -0,0 +1,216 @@
+package application
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"github.com/linskybing/platform-go/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StorageDeleteConfirmWindow is how long a storage delete confirmation token stays valid.
+const StorageDeleteConfirmWindow = 2 * time.Minute
+
+var (
+	ErrDeleteConfirmationInvalid = errors.New("delete confirmation token is invalid for this resource")
+	ErrDeleteConfirmationExpired = errors.New("delete confirmation token has expired")
+)
+
+// StorageDeleteTarget is a storage a destructive endpoint is about to remove. Confirmation
+// tokens are bound to Resource.
+type StorageDeleteTarget struct {
+	// Resource identifies the storage, e.g. "user-storage/alice" or "project-storage/7/data"
+	Resource  string
+	Namespace string
+	// PVC is set when one claim is deleted; otherwise the whole namespace goes
+	PVC string
+}
+
+// UserStorageDeleteTarget is the storage hub of a user, removed with its namespace. storageID is
+// the identifier the hub is named with, see K8sService.UserStorageID.
+func UserStorageDeleteTarget(username, storageID string) StorageDeleteTarget {
+	nsName, _ := userHubNames(storageID)
+	return StorageDeleteTarget{
+		Resource:  "user-storage/" + strings.ToLower(username),
+		Namespace: nsName,
+	}
+}
+
+// ProjectStorageDeleteTarget is every storage of a project, removed with its namespace.
+func ProjectStorageDeleteTarget(p *project.Project) StorageDeleteTarget {
+	return StorageDeleteTarget{
+		Resource:  fmt.Sprintf("project-storage/%d", p.PID),
+		Namespace: ProjectStorageNamespace(p),
+	}
+}
+
+// NamedProjectStorageDeleteTarget is one named storage of a project.
+func NamedProjectStorageDeleteTarget(p *project.Project, storageName string) (StorageDeleteTarget, error) {
+	storageName, err := normalizeStorageName(storageName)
+	if err != nil {
+		return StorageDeleteTarget{}, err
+	}
+	return StorageDeleteTarget{
+		Resource:  fmt.Sprintf("project-storage/%d/%s", p.PID, storageName),
+		Namespace: ProjectStorageNamespace(p),
+		PVC:       k8s.ProjectStoragePVCName(p.PID, storageName),
+	}, nil
+}
+
+func (t StorageDeleteTarget) auditType() string {
+	resourceType, _, _ := strings.Cut(t.Resource, "/")
+	return strings.ReplaceAll(resourceType, "-", "_")
+}
+
+// StorageDeletePVC is a claim a storage delete destroys.
+type StorageDeletePVC struct {
+	Name     string `json:"name"`
+	Capacity string `json:"capacity,omitempty"`
+}
+
+// StorageDeleteSummary is what a storage delete destroys.
+type StorageDeleteSummary struct {
+	Resource         string             `json:"resource"`
+	Namespace        string             `json:"namespace"`
+	DeletesNamespace bool               `json:"deletesNamespace"`
+	PVCs             []StorageDeletePVC `json:"pvcs"`
+	// Pods counts the pods deleted with the namespace, or those mounting the claim
+	Pods int `json:"pods"`
+}
+
+// StorageDeleteConfirmation is the answer to the first DELETE of a storage: repeating the DELETE
+// with Token before ExpiresAt carries it out.
+type StorageDeleteConfirmation struct {
+	Token     string               `json:"token"`
+	ExpiresAt time.Time            `json:"expiresAt"`
+	Summary   StorageDeleteSummary `json:"summary"`
+}
+
+// RequestStorageDelete describes what deleting target destroys and issues the token with which
+// actorID confirms it.
+func (s *K8sService) RequestStorageDelete(c *gin.Context, target StorageDeleteTarget, actorID uint) (*StorageDeleteConfirmation, error) {
+	summary, err := s.storageDeleteSummary(c.Request.Context(), target)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(StorageDeleteConfirmWindow).Truncate(time.Second)
+	conf := &StorageDeleteConfirmation{
+		Token:     signStorageDeleteToken(target, actorID, expires),
+		ExpiresAt: expires,
+		Summary:   *summary,
+	}
+	utils.LogAuditWithConsole(c, "delete_request", target.auditType(), target.Resource, nil, summary, "delete confirmation requested", s.repos.Audit)
+	return conf, nil
+}
+
+// ConfirmStorageDelete checks the token actorID presents for deleting target.
+func (s *K8sService) ConfirmStorageDelete(c *gin.Context, target StorageDeleteTarget, actorID uint, token string) error {
+	if err := verifyStorageDeleteToken(token, target, actorID, time.Now()); err != nil {
+		return err
+	}
+	utils.LogAuditWithConsole(c, "delete_confirm", target.auditType(), target.Resource, nil, nil, "delete confirmed", s.repos.Audit)
+	return nil
+}
+
+// ForceStorageDelete records a delete that skips the confirmation.
+func (s *K8sService) ForceStorageDelete(c *gin.Context, target StorageDeleteTarget) {
+	utils.LogAuditWithConsole(c, "delete_force", target.auditType(), target.Resource, nil, nil, "delete forced without confirmation", s.repos.Audit)
+}
+
+func (s *K8sService) storageDeleteSummary(ctx context.Context, target StorageDeleteTarget) (*StorageDeleteSummary, error) {
+	summary := &StorageDeleteSummary{Resource: target.Resource, Namespace: target.Namespace, PVCs: []StorageDeletePVC{}}
+	if k8s.Clientset == nil {
+		summary.DeletesNamespace = target.PVC == ""
+		return summary, nil
+	}
+	core := k8s.Clientset.CoreV1()
+
+	pvcs, err := core.PersistentVolumeClaims(target.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list pvcs: %w", err)
+	}
+	remaining := 0
+	if pvcs != nil {
+		for _, pvc := range pvcs.Items {
+			if target.PVC != "" && pvc.Name != target.PVC {
+				if pvc.DeletionTimestamp == nil {
+					remaining++
+				}
+				continue
+			}
+			capacity := ""
+			if q, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+				capacity = q.String()
+			}
+			summary.PVCs = append(summary.PVCs, StorageDeletePVC{Name: pvc.Name, Capacity: capacity})
+		}
+	}
+	if target.PVC != "" && len(summary.PVCs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrStorageNotFound, target.PVC)
+	}
+	// Deleting the last storage of a project also deletes its namespace
+	summary.DeletesNamespace = target.PVC == "" || remaining == 0
+
+	pods, err := core.Pods(target.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	if pods != nil {
+		for i := range pods.Items {
+			if summary.DeletesNamespace || podMountsClaim(&pods.Items[i], target.PVC) {
+				summary.Pods++
+			}
+		}
+	}
+	return summary, nil
+}
+
+func podMountsClaim(pod *corev1.Pod, claim string) bool {
+	for _, v := range pod.Spec.Volumes {
+		if v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName == claim {
+			return true
+		}
+	}
+	return false
+}
+
+// signStorageDeleteToken returns "<expiry unix>.<HMAC>", the HMAC covering the resource, the
+// actor and the expiry.
+func signStorageDeleteToken(target StorageDeleteTarget, actorID uint, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + storageDeleteMAC(target, actorID, exp)
+}
+
+func verifyStorageDeleteToken(token string, target StorageDeleteTarget, actorID uint, now time.Time) error {
+	exp, mac, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(storageDeleteMAC(target, actorID, exp))) {
+		return ErrDeleteConfirmationInvalid
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrDeleteConfirmationInvalid
+	}
+	if now.After(time.Unix(unix, 0)) {
+		return ErrDeleteConfirmationExpired
+	}
+	return nil
+}
+
+func storageDeleteMAC(target StorageDeleteTarget, actorID uint, exp string) string {
+	m := hmac.New(sha256.New, []byte(config.JwtSecret))
+	fmt.Fprintf(m, "storage-delete\n%s\n%s\n%d\n%s", target.Resource, target.Namespace, actorID, exp)
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,104 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStorageDeleteToken(t *testing.T) {
+	alice := UserStorageDeleteTarget("Alice", "alice")
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	token := signStorageDeleteToken(alice, 1, now.Add(StorageDeleteConfirmWindow))
+
+	if err := verifyStorageDeleteToken(token, alice, 1, now.Add(time.Minute)); err != nil {
+		t.Fatalf("expected the token to be accepted, got %v", err)
+	}
+	if err := verifyStorageDeleteToken(token, alice, 1, now.Add(3*time.Minute)); !errors.Is(err, ErrDeleteConfirmationExpired) {
+		t.Fatalf("expected an expired token after two minutes, got %v", err)
+	}
+
+	_, sig, _ := strings.Cut(token, ".")
+	later := strconv.FormatInt(now.Add(time.Hour).Unix(), 10)
+	mismatches := map[string]struct {
+		token  string
+		target StorageDeleteTarget
+		actor  uint
+	}{
+		"other user":          {token, UserStorageDeleteTarget("bob", "bob"), 1},
+		"other actor":         {token, alice, 2},
+		"other resource":      {token, ProjectStorageDeleteTarget(&project.Project{PID: 7, ProjectName: "p"}), 1},
+		"extended expiry":     {later + "." + sig, alice, 1},
+		"truncated signature": {token[:len(token)-2], alice, 1},
+		"malformed":           {"not-a-token", alice, 1},
+	}
+	for name, tc := range mismatches {
+		if err := verifyStorageDeleteToken(tc.token, tc.target, tc.actor, now); !errors.Is(err, ErrDeleteConfirmationInvalid) {
+			t.Errorf("%s: expected ErrDeleteConfirmationInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestStorageDeleteSummary(t *testing.T) {
+	origClient := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = origClient })
+	p := &project.Project{PID: 7, ProjectName: "p"}
+	ns := ProjectStorageNamespace(p)
+	pvc := func(name, size string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			}},
+		}
+	}
+	pod := func(name string, claims ...string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}}
+		for _, claim := range claims {
+			p.Spec.Volumes = append(p.Spec.Volumes, corev1.Volume{Name: claim, VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+			}})
+		}
+		return p
+	}
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		pvc("project-7-data", "50Gi"), pvc("project-7-models", "10Gi"),
+		pod("filebrowser", "project-7-data", "project-7-models"), pod("train", "project-7-data"), pod("web"),
+	)
+	svc := &K8sService{}
+
+	all, err := svc.storageDeleteSummary(context.Background(), ProjectStorageDeleteTarget(p))
+	if err != nil {
+		t.Fatalf("storageDeleteSummary: %v", err)
+	}
+	if !all.DeletesNamespace || len(all.PVCs) != 2 || all.Pods != 3 {
+		t.Fatalf("expected the namespace with both claims and every pod, got %+v", all)
+	}
+
+	target, err := NamedProjectStorageDeleteTarget(p, "models")
+	if err != nil {
+		t.Fatalf("NamedProjectStorageDeleteTarget: %v", err)
+	}
+	one, err := svc.storageDeleteSummary(context.Background(), target)
+	if err != nil {
+		t.Fatalf("storageDeleteSummary: %v", err)
+	}
+	if one.DeletesNamespace || len(one.PVCs) != 1 || one.PVCs[0].Capacity != "10Gi" || one.Pods != 1 {
+		t.Fatalf("expected only the models claim and the pod mounting it, got %+v", one)
+	}
+
+	missing, _ := NamedProjectStorageDeleteTarget(p, "scratch")
+	if _, err := svc.storageDeleteSummary(context.Background(), missing); !errors.Is(err, ErrStorageNotFound) {
+		t.Fatalf("expected ErrStorageNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var ErrInvalidStorageSize = errors.New("invalid storage size")
+
+// StorageEntitlement is the storage a user is entitled to through their group memberships.
+type StorageEntitlement struct {
+	HubSize          string `json:"hub_size"`
+	StorageClassName string `json:"storage_class_name"`
+	// GID of the group granting HubSize, 0 when the platform default applies
+	SourceGID uint `json:"source_gid"`
+}
+
+// HubEntitlementGap reports a user whose existing hub is smaller than their entitlement.
+type HubEntitlementGap struct {
+	UID          uint   `json:"uid"`
+	Username     string `json:"username"`
+	CurrentSize  string `json:"current_size"`
+	EntitledSize string `json:"entitled_size"`
+}
+
+// validateStorageSize accepts "" (use the platform default) or a positive quantity such as "20Gi".
+func validateStorageSize(size string) error {
+	if size == "" {
+		return nil
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil || q.Sign() <= 0 {
+		return fmt.Errorf("%w: %q", ErrInvalidStorageSize, size)
+	}
+	return nil
+}
+
+// ResolveStorageEntitlement picks the largest hub size across the user's groups. The storage
+// class comes from the same group, so a large hub is not placed on a class sized for small ones.
+func (s *K8sService) ResolveStorageEntitlement(uid uint) (StorageEntitlement, error) {
+	ent := StorageEntitlement{HubSize: config.StringSetting(config.SettingUserPVSize), StorageClassName: config.DefaultStorageClassName}
+	best, err := resource.ParseQuantity(ent.HubSize)
+	if err != nil {
+		return ent, fmt.Errorf("invalid default hub size %q: %w", ent.HubSize, err)
+	}
+
+	memberships, err := s.repos.UserGroup.GetUserGroupsByUID(uid)
+	if err != nil {
+		return ent, err
+	}
+	for _, m := range memberships {
+		g, err := s.repos.Group.GetGroupByID(m.GID)
+		if err != nil || g.HubStorageSize == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(g.HubStorageSize)
+		if err != nil || q.Cmp(best) <= 0 {
+			continue
+		}
+		best = q
+		ent.HubSize = g.HubStorageSize
+		ent.SourceGID = g.GID
+		ent.StorageClassName = config.DefaultStorageClassName
+		if g.StorageClassName != "" {
+			ent.StorageClassName = g.StorageClassName
+		}
+	}
+	return ent, nil
+}
+
+// projectStorageDefaults returns the default capacity and storage class for new storages of a
+// project, taken from the project's group.
+func (s *K8sService) projectStorageDefaults(projectID uint) (size, storageClass string) {
+	size, storageClass = config.StringSetting(config.SettingProjectPVSize), config.DefaultStorageClassName
+	if s.repos == nil || s.repos.Project == nil || s.repos.Group == nil {
+		return size, storageClass
+	}
+	p, err := s.repos.Project.GetProjectByID(projectID)
+	if err != nil {
+		return size, storageClass
+	}
+	g, err := s.repos.Group.GetGroupByID(p.GID)
+	if err != nil {
+		return size, storageClass
+	}
+	if g.ProjectStorageSize != "" {
+		size = g.ProjectStorageSize
+	}
+	if g.StorageClassName != "" {
+		storageClass = g.StorageClassName
+	}
+	return size, storageClass
+}
+
+// ListHubEntitlementGaps lists users whose hub PVC is below their current entitlement. Hubs are
+// never shrunk or grown automatically; admins expand the listed ones explicitly.
+func (s *K8sService) ListHubEntitlementGaps(ctx context.Context) ([]HubEntitlementGap, error) {
+	gaps := []HubEntitlementGap{}
+	if k8s.Clientset == nil {
+		return gaps, nil
+	}
+	users, err := s.repos.User.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		ent, err := s.ResolveStorageEntitlement(u.UID)
+		if err != nil {
+			return nil, err
+		}
+		nsName, pvcName := userHubNames(s.UserStorageID(u.Username))
+		pvc, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(nsName).Get(ctx, pvcName, metav1.GetOptions{})
+		if err != nil {
+			// Users without a hub get the entitlement when it is created
+			continue
+		}
+		current := pvc.Spec.Resources.Requests.Storage()
+		if c, ok := pvc.Status.Capacity["storage"]; ok && c.Cmp(*current) > 0 {
+			current = &c
+		}
+		entitled := resource.MustParse(ent.HubSize)
+		if current.Cmp(entitled) < 0 {
+			gaps = append(gaps, HubEntitlementGap{
+				UID:          u.UID,
+				Username:     u.Username,
+				CurrentSize:  current.String(),
+				EntitledSize: ent.HubSize,
+			})
+		}
+	}
+	return gaps, nil
+}
@@ -0,0 +1,106 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newStorageEntitlementService(t *testing.T) *K8sService {
+	t.Helper()
+	db := dbtest.Open(t, &user.User{}, &group.Group{}, &group.UserGroup{}, &project.Project{})
+	seed := []interface{}{
+		&user.User{UID: 1, Username: "alice", Password: "x"},
+		&user.User{UID: 2, Username: "bob", Password: "x"},
+		&group.Group{GID: 1, GroupName: "course", HubStorageSize: "20Gi", StorageClassName: "nfs-client", ProjectStorageSize: "5Gi"},
+		&group.Group{GID: 2, GroupName: "ml", HubStorageSize: "500Gi", StorageClassName: "longhorn-big"},
+		&group.Group{GID: 3, GroupName: "plain"},
+		&group.UserGroup{UID: 1, GID: 1, Role: "user"},
+		&group.UserGroup{UID: 1, GID: 2, Role: "user"},
+		&group.UserGroup{UID: 1, GID: 3, Role: "user"},
+		&group.UserGroup{UID: 2, GID: 1, Role: "user"},
+		&group.UserGroup{UID: 3, GID: 3, Role: "user"},
+		&project.Project{PID: 10, ProjectName: "hw", GID: 1},
+		&project.Project{PID: 11, ProjectName: "misc", GID: 3},
+	}
+	for _, row := range seed {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	return NewK8sService(repository.NewRepositories(db))
+}
+
+func TestResolveStorageEntitlementAcrossGroups(t *testing.T) {
+	svc := newStorageEntitlementService(t)
+
+	cases := []struct {
+		uid   uint
+		size  string
+		class string
+		gid   uint
+	}{
+		{uid: 1, size: "500Gi", class: "longhorn-big", gid: 2},
+		{uid: 2, size: "20Gi", class: "nfs-client", gid: 1},
+		// Groups without a hub size, and users without groups, get the platform default
+		{uid: 3, size: config.UserPVSize, class: config.DefaultStorageClassName},
+		{uid: 4, size: config.UserPVSize, class: config.DefaultStorageClassName},
+	}
+	for _, tc := range cases {
+		ent, err := svc.ResolveStorageEntitlement(tc.uid)
+		if err != nil {
+			t.Fatalf("uid %d: %v", tc.uid, err)
+		}
+		if ent.HubSize != tc.size || ent.StorageClassName != tc.class || ent.SourceGID != tc.gid {
+			t.Errorf("uid %d: got %+v", tc.uid, ent)
+		}
+	}
+}
+
+func TestProjectStorageDefaultsFromGroup(t *testing.T) {
+	svc := newStorageEntitlementService(t)
+
+	if size, class := svc.projectStorageDefaults(10); size != "5Gi" || class != "nfs-client" {
+		t.Fatalf("expected group defaults, got %s %s", size, class)
+	}
+	if size, class := svc.projectStorageDefaults(11); size != config.ProjectPVSize || class != config.DefaultStorageClassName {
+		t.Fatalf("expected platform defaults, got %s %s", size, class)
+	}
+}
+
+func TestListHubEntitlementGaps(t *testing.T) {
+	svc := newStorageEntitlementService(t)
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+
+	hub := func(username, size string) *corev1.PersistentVolumeClaim {
+		ns, name := userHubNames(UserStorageIdentifier(username))
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			}},
+		}
+	}
+	k8s.Clientset = k8sfake.NewSimpleClientset(hub("alice", "10Gi"), hub("bob", "50Gi"))
+
+	gaps, err := svc.ListHubEntitlementGaps(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// bob's hub is larger than his entitlement and must not be reported for shrinking
+	if len(gaps) != 1 || gaps[0].Username != "alice" || gaps[0].CurrentSize != "10Gi" || gaps[0].EntitledSize != "500Gi" {
+		t.Fatalf("unexpected gaps %+v", gaps)
+	}
+}
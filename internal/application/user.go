@@ -2,12 +2,14 @@ package application
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/linskybing/platform-go/internal/api/middleware"
 	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/user"
 	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/oidc"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -23,6 +25,11 @@ var (
 
 type UserService struct {
 	Repos *repository.Repos
+
+	oidcMu sync.Mutex
+	oidc   *oidc.Provider
+	// oidcLinks are the identity links started from a session, by state
+	oidcLinks map[string]oidcLink
 }
 
 func NewUserService(repos *repository.Repos) *UserService {
@@ -64,6 +71,9 @@ func (s *UserService) RegisterUser(input user.CreateUserInput) error {
 }
 
 func (s *UserService) LoginUser(username, password string) (user.User, string, bool, error) {
+	if !config.LocalLoginEnabled {
+		return user.User{}, "", false, ErrLocalLoginDisabled
+	}
 	usr, err := s.Repos.User.GetUserByUsername(username)
 	if err != nil {
 		return user.User{}, "", false, errors.New("invalid credentials")
@@ -1,17 +1,24 @@
 package application
 
 import (
+	"context"
 	"errors"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
+	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/internal/domain/group"
 	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/user"
 	"github.com/linskybing/platform-go/internal/repository"
 	"github.com/linskybing/platform-go/internal/repository/mock"
+	"github.com/linskybing/platform-go/pkg/k8s"
 	"github.com/linskybing/platform-go/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 )
 
 func setupUserGroupMocks(t *testing.T) (*UserGroupService,
@@ -27,6 +34,10 @@ func setupUserGroupMocks(t *testing.T) (*UserGroupService,
 
 	mockUG := mock.NewMockUserGroupRepo(ctrl)
 	mockUser := mock.NewMockUserRepo(ctrl)
+	// Users already have their storage ID, named after the username
+	mockUser.EXPECT().GetUserByUsername(gomock.Any()).DoAndReturn(func(name string) (user.User, error) {
+		return user.User{Username: name, StorageID: name}, nil
+	}).AnyTimes()
 	mockProject := mock.NewMockProjectRepo(ctrl)
 	mockGroup := mock.NewMockGroupRepo(ctrl)
 	mockAudit := mock.NewMockAuditRepo(ctrl)
@@ -274,3 +285,132 @@ func TestFormatByGID_Empty(t *testing.T) {
 	res := svc.FormatByGID([]group.UserGroup{})
 	assert.Len(t, res, 0)
 }
+
+// ---------- User hub binding ----------
+func setupUserHubCluster(t *testing.T, withHub bool) {
+	origClient := k8s.Clientset
+	origSvcName := config.PersonalStorageServiceName
+	t.Cleanup(func() {
+		k8s.Clientset = origClient
+		config.PersonalStorageServiceName = origSvcName
+	})
+
+	config.PersonalStorageServiceName = "storage-svc"
+	fake := k8sfake.NewSimpleClientset()
+	k8s.Clientset = fake
+	if withHub {
+		createUserHubService(t)
+	}
+}
+
+func createUserHubService(t *testing.T) {
+	_, err := k8s.Clientset.CoreV1().Services("user-alice-storage").Create(context.Background(), &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage-svc", Namespace: "user-alice-storage", Labels: map[string]string{"app": "storage-hub"}},
+		Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.10"},
+	}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+}
+
+func userHubPVCount(t *testing.T) int {
+	pvs, err := k8s.Clientset.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{LabelSelector: "storage-type=user-hub"})
+	assert.NoError(t, err)
+	return len(pvs.Items)
+}
+
+func TestUserHubBinding_JoinLeaveRejoin(t *testing.T) {
+	setupUserHubCluster(t, true)
+	svc, ugRepo, userRepo, projectRepo, groupRepo, _, ctx := setupUserGroupMocks(t)
+
+	ug := &group.UserGroup{UID: 2, GID: 3}
+	projects := []project.Project{{PID: 7, GID: 3}}
+
+	join := func() {
+		ugRepo.EXPECT().CreateUserGroup(ug).Return(nil)
+		userRepo.EXPECT().GetUsernameByID(uint(2)).Return("alice", nil)
+		projectRepo.EXPECT().ListProjectsByGroup(uint(3)).Return(projects, nil)
+		_, err := svc.CreateUserGroup(ctx, ug)
+		assert.NoError(t, err)
+	}
+
+	// Join
+	join()
+	pvc, err := k8s.Clientset.CoreV1().PersistentVolumeClaims("proj-7-alice").Get(context.Background(), "user-alice-pv", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pvc.Spec.VolumeName)
+	pv, err := k8s.Clientset.CoreV1().PersistentVolumes().Get(context.Background(), pvc.Spec.VolumeName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.10", pv.Spec.NFS.Server)
+	assert.Equal(t, "user-alice-pv", pv.Spec.ClaimRef.Name)
+
+	// Leave
+	ugRepo.EXPECT().GetUserGroup(uint(2), uint(3)).Return(*ug, nil)
+	groupRepo.EXPECT().GetGroupByID(uint(3)).Return(group.Group{}, nil)
+	ugRepo.EXPECT().DeleteUserGroup(uint(2), uint(3)).Return(nil)
+	userRepo.EXPECT().GetUsernameByID(uint(2)).Return("alice", nil)
+	projectRepo.EXPECT().ListProjectsByGroup(uint(3)).Return(projects, nil)
+	assert.NoError(t, svc.DeleteUserGroup(ctx, 2, 3))
+	assert.Equal(t, 0, userHubPVCount(t))
+
+	// Re-join
+	join()
+	assert.Equal(t, 1, userHubPVCount(t))
+	_, err = k8s.Clientset.CoreV1().PersistentVolumeClaims("proj-7-alice").Get(context.Background(), "user-alice-pv", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestUserHubBinding_RetriedBySweep(t *testing.T) {
+	setupUserHubCluster(t, false)
+	svc, ugRepo, userRepo, projectRepo, _, _, _ := setupUserGroupMocks(t)
+
+	projects := []project.Project{{PID: 7, GID: 3}}
+	projectRepo.EXPECT().ListProjectsByGroup(uint(3)).Return(projects, nil)
+	assert.NoError(t, svc.AllocateGroupResource(3, "alice"))
+	assert.Equal(t, 0, userHubPVCount(t))
+
+	createUserHubService(t)
+
+	projectRepo.EXPECT().ListProjects().Return(projects, nil)
+	ugRepo.EXPECT().GetUserGroupsByGID(uint(3)).Return([]group.UserGroup{{UID: 2, GID: 3}}, nil)
+	userRepo.EXPECT().GetUsernameByID(uint(2)).Return("alice", nil)
+	assert.NoError(t, svc.ReconcileUserHubBindings())
+	assert.Equal(t, 1, userHubPVCount(t))
+}
+
+func TestUserHubBinding_SweepListsOnce(t *testing.T) {
+	setupUserHubCluster(t, true)
+	svc, ugRepo, userRepo, projectRepo, _, _, _ := setupUserGroupMocks(t)
+
+	// Two projects of the same group: members and usernames are looked up once
+	projects := []project.Project{{PID: 7, GID: 3}, {PID: 8, GID: 3}}
+	projectRepo.EXPECT().ListProjects().Return(projects, nil).Times(2)
+	ugRepo.EXPECT().GetUserGroupsByGID(uint(3)).Return([]group.UserGroup{{UID: 2, GID: 3}, {UID: 5, GID: 3}}, nil).Times(2)
+	userRepo.EXPECT().GetUsernameByID(uint(2)).Return("alice", nil).Times(2)
+	userRepo.EXPECT().GetUsernameByID(uint(5)).Return("bob", nil).Times(2)
+
+	assert.NoError(t, svc.ReconcileUserHubBindings())
+	assert.Equal(t, 2, userHubPVCount(t), "alice is bound into both projects; bob has no hub")
+
+	// Once bound, a sweep only lists the hub services and PVCs
+	fake := k8s.Clientset.(*k8sfake.Clientset)
+	fake.ClearActions()
+	assert.NoError(t, svc.ReconcileUserHubBindings())
+	assert.Len(t, fake.Actions(), 2)
+}
+
+func TestMigrateUserK8sNamesProvisionsTheNewNamespaces(t *testing.T) {
+	setupUserHubCluster(t, false)
+	svc, ugRepo, userRepo, projectRepo, _, _, _ := setupUserGroupMocks(t)
+
+	userRepo.EXPECT().GetAllUsers().Return([]user.UserWithSuperAdmin{
+		{UID: 2, Username: "alice"},
+		{UID: 4, Username: "李a"},
+	}, nil)
+	ugRepo.EXPECT().GetUserGroupsByUID(uint(4)).Return([]group.UserGroup{{UID: 4, GID: 3}}, nil)
+	projectRepo.EXPECT().ListProjectsByGroup(uint(3)).Return([]project.Project{{PID: 7, GID: 3}}, nil)
+
+	n, err := svc.MigrateUserK8sNames()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = k8s.Clientset.CoreV1().Namespaces().Get(context.Background(), "proj-7-"+k8s.ToSafeK8sName("李a"), metav1.GetOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,502 @@
+package application
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	netmail "net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/mail"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// MaxUserImportRows bounds one CSV import.
+const MaxUserImportRows = 1000
+
+var (
+	ErrUserImportEmpty    = errors.New("the CSV has no users")
+	ErrUserImportTooLarge = fmt.Errorf("the CSV has more than %d users", MaxUserImportRows)
+	ErrUserImportNotFound = errors.New("user import not found")
+)
+
+// Per-row outcomes of an import.
+const (
+	ImportRowValid   = "valid"
+	ImportRowInvalid = "invalid"
+	ImportRowCreated = "created"
+	ImportRowFailed  = "failed"
+)
+
+// userImportColumns is the column order of a CSV without a header row.
+var userImportColumns = []string{"username", "email", "full_name", "group", "role"}
+
+// userImportHeaders maps the accepted header spellings to userImportColumns.
+var userImportHeaders = map[string]string{
+	"username":   "username",
+	"email":      "email",
+	"e-mail":     "email",
+	"full_name":  "full_name",
+	"full name":  "full_name",
+	"fullname":   "full_name",
+	"name":       "full_name",
+	"group":      "group",
+	"group_name": "group",
+	"group name": "group",
+	"role":       "role",
+}
+
+// UserImportRow is one user of an import CSV. Line is the line it starts on.
+type UserImportRow struct {
+	Line     int
+	Username string
+	Email    string
+	FullName string
+	Group    string
+	Role     string
+
+	problem string
+}
+
+// ParseUserImportCSV reads the rows of an import CSV: username, email, full name, group name
+// and role, either in that order or in any order under a header row. A UTF-8 byte order mark,
+// as written by Excel, is skipped and blank lines are ignored.
+func ParseUserImportCSV(r io.Reader) ([]UserImportRow, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+		_, _ = br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	columns := userImportColumns
+	var rows []UserImportRow
+	headerChecked := false
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		if !headerChecked {
+			headerChecked = true
+			if header, ok := importHeader(record); ok {
+				columns = header
+				continue
+			}
+		}
+		if len(rows) == MaxUserImportRows {
+			return nil, ErrUserImportTooLarge
+		}
+		row := UserImportRow{Line: line}
+		if len(record) > len(columns) {
+			row.problem = fmt.Sprintf("expected at most %d columns, got %d", len(columns), len(record))
+		}
+		for i, value := range record {
+			if i >= len(columns) {
+				break
+			}
+			value = strings.TrimSpace(value)
+			switch columns[i] {
+			case "username":
+				row.Username = value
+			case "email":
+				row.Email = value
+			case "full_name":
+				row.FullName = value
+			case "group":
+				row.Group = value
+			case "role":
+				row.Role = strings.ToLower(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, ErrUserImportEmpty
+	}
+	return rows, nil
+}
+
+// importHeader returns the columns named by record when it is a header row, that is when one
+// of its cells is "username".
+func importHeader(record []string) ([]string, bool) {
+	columns := make([]string, len(record))
+	header := false
+	for i, name := range record {
+		name = strings.ToLower(strings.TrimSpace(name))
+		header = header || name == "username"
+		// Unknown headers leave their column unread
+		columns[i] = userImportHeaders[name]
+	}
+	return columns, header
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// UserImportOptions selects what an import does beyond validating.
+type UserImportOptions struct {
+	// Execute creates the users; otherwise the import is a dry run
+	Execute bool
+	// EmailPasswords sends the initial passwords to the users instead of returning them
+	EmailPasswords bool
+	// InitHubs creates the storage hubs of the created users in the background
+	InitHubs bool
+}
+
+// UserImportRowResult is the outcome of one row.
+type UserImportRowResult struct {
+	Line     int      `json:"line"`
+	Username string   `json:"username"`
+	Group    string   `json:"group,omitempty"`
+	Role     string   `json:"role,omitempty"`
+	Status   string   `json:"status"`
+	Errors   []string `json:"errors,omitempty"`
+	// Password is the initial password, returned only once when it was not emailed
+	Password string `json:"password,omitempty"`
+	Emailed  bool   `json:"emailed,omitempty"`
+}
+
+// UserImportReport is the outcome of an import, row by row.
+type UserImportReport struct {
+	DryRun  bool                  `json:"dry_run"`
+	Total   int                   `json:"total"`
+	Valid   int                   `json:"valid"`
+	Created int                   `json:"created"`
+	Failed  int                   `json:"failed"`
+	Rows    []UserImportRowResult `json:"rows"`
+	// HubImportID follows the creation of the storage hubs at GET /admin/users/import/{id}
+	HubImportID string `json:"hub_import_id,omitempty"`
+}
+
+// UserHubImportStatus is the state of the storage hub of one imported user.
+type UserHubImportStatus struct {
+	Username string `json:"username"`
+	// Status is pending, ready or failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UserHubImportProgress follows the creation of the storage hubs of an import.
+type UserHubImportProgress struct {
+	ID         string                `json:"id"`
+	Total      int                   `json:"total"`
+	Done       int                   `json:"done"`
+	Failed     int                   `json:"failed"`
+	Users      []UserHubImportStatus `json:"users"`
+	StartedAt  time.Time             `json:"started_at"`
+	FinishedAt *time.Time            `json:"finished_at,omitempty"`
+}
+
+// userHubImportRetention is how long the progress of a finished hub creation stays available.
+const userHubImportRetention = time.Hour
+
+// UserImportService creates accounts in bulk, typically for a course at the start of a semester.
+type UserImportService struct {
+	Repos      *repository.Repos
+	userGroups *UserGroupService
+	sender     mail.Sender
+	// initHub creates the storage hub of a user; replaced in tests
+	initHub func(ctx context.Context, username string) error
+
+	mu       sync.Mutex
+	progress map[string]*UserHubImportProgress
+}
+
+// NewUserImportService returns the import service; a nil sender returns every password in
+// the report.
+func NewUserImportService(repos *repository.Repos, sender mail.Sender) *UserImportService {
+	hubs := NewK8sService(repos)
+	return &UserImportService{
+		Repos:      repos,
+		userGroups: NewUserGroupService(repos),
+		sender:     sender,
+		initHub: func(ctx context.Context, username string) error {
+			_, err := hubs.InitializeUserStorageHub(ctx, username)
+			return err
+		},
+		progress: make(map[string]*UserHubImportProgress),
+	}
+}
+
+// ImportUsers validates every row and, with opts.Execute, creates the valid ones with a
+// generated password and adds them to their group. A failing row never stops the others.
+func (s *UserImportService) ImportUsers(c *gin.Context, rows []UserImportRow, opts UserImportOptions) (*UserImportReport, error) {
+	groups, err := s.Repos.Group.GetAllGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+	byName := make(map[string]group.Group, len(groups))
+	for _, g := range groups {
+		byName[strings.ToLower(g.GroupName)] = g
+	}
+
+	report := &UserImportReport{DryRun: !opts.Execute, Total: len(rows), Rows: make([]UserImportRowResult, 0, len(rows))}
+	seen := make(map[string]int, len(rows))
+	var created []importedUser
+	for _, row := range rows {
+		res := UserImportRowResult{Line: row.Line, Username: row.Username, Group: row.Group, Role: row.Role}
+		g, problems := s.validateImportRow(&row, byName, seen)
+		res.Role = row.Role
+		if len(problems) > 0 {
+			res.Status, res.Errors = ImportRowInvalid, problems
+			report.Failed++
+			report.Rows = append(report.Rows, res)
+			continue
+		}
+		report.Valid++
+		if !opts.Execute {
+			res.Status = ImportRowValid
+			report.Rows = append(report.Rows, res)
+			continue
+		}
+
+		u, password, err := s.createImportedUser(row)
+		if err != nil {
+			res.Status, res.Errors = ImportRowFailed, []string{err.Error()}
+			report.Failed++
+			report.Rows = append(report.Rows, res)
+			continue
+		}
+		res.Status = ImportRowCreated
+		report.Created++
+		if g != nil {
+			membership := &group.UserGroup{UID: u.UID, GID: g.GID, Role: row.Role}
+			if _, err := s.userGroups.CreateUserGroup(c, membership); err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("failed to add to group %s: %v", g.GroupName, err))
+			}
+		}
+		res.Password = password
+		if opts.EmailPasswords && u.Email != nil {
+			if err := s.emailPassword(c.Request.Context(), u, password); err != nil {
+				res.Errors = append(res.Errors, err.Error())
+			} else {
+				res.Password, res.Emailed = "", true
+			}
+		}
+		report.Rows = append(report.Rows, res)
+		created = append(created, importedUser{username: u.Username, group: g})
+	}
+
+	if opts.Execute {
+		utils.LogAuditWithConsole(c, "import", "user", "csv", nil, importedUsernames(created),
+			fmt.Sprintf("imported %d of %d users", report.Created, report.Total), s.Repos.Audit)
+		if opts.InitHubs && len(created) > 0 {
+			report.HubImportID = s.startHubCreation(created)
+		}
+	}
+	return report, nil
+}
+
+// validateImportRow checks row against the existing users and groups and the rows before it,
+// defaulting its role to user. It returns the group to join, if any.
+func (s *UserImportService) validateImportRow(row *UserImportRow, groups map[string]group.Group, seen map[string]int) (*group.Group, []string) {
+	var problems []string
+	if row.problem != "" {
+		problems = append(problems, row.problem)
+	}
+
+	switch n := len(row.Username); {
+	case n == 0:
+		problems = append(problems, "username is required")
+	case n < 3 || n > 50:
+		problems = append(problems, "username must be 3 to 50 characters")
+	default:
+		// Usernames differing only in case would share namespaces on the cluster
+		key := strings.ToLower(row.Username)
+		if line, ok := seen[key]; ok {
+			problems = append(problems, fmt.Sprintf("duplicate of line %d", line))
+		} else {
+			seen[key] = row.Line
+			if _, err := s.Repos.User.GetUserByUsername(row.Username); err == nil {
+				problems = append(problems, ErrUsernameTaken.Error())
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				problems = append(problems, "failed to check the username")
+			}
+		}
+	}
+
+	if row.Email != "" {
+		if addr, err := netmail.ParseAddress(row.Email); err != nil || addr.Address != row.Email {
+			problems = append(problems, "invalid email")
+		}
+	}
+	if len(row.FullName) > 50 {
+		problems = append(problems, "full name must be at most 50 characters")
+	}
+
+	if row.Role == "" {
+		row.Role = string(user.UserRoleUser)
+	}
+	switch user.UserRole(row.Role) {
+	case user.UserRoleAdmin, user.UserRoleManager, user.UserRoleUser:
+	default:
+		problems = append(problems, fmt.Sprintf("invalid role %q, expected admin, manager or user", row.Role))
+	}
+
+	var target *group.Group
+	if row.Group != "" {
+		if g, ok := groups[strings.ToLower(row.Group)]; ok {
+			target = &g
+		} else {
+			problems = append(problems, fmt.Sprintf("unknown group %q", row.Group))
+		}
+	}
+	return target, problems
+}
+
+func (s *UserImportService) createImportedUser(row UserImportRow) (*user.User, string, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, "", err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, "", ErrPasswordHashFailure
+	}
+	u := &user.User{Username: row.Username, Password: string(hashed), Type: "origin", Status: "offline"}
+	if row.Email != "" {
+		u.Email = &row.Email
+	}
+	if row.FullName != "" {
+		u.FullName = &row.FullName
+	}
+	if err := s.Repos.User.SaveUser(u); err != nil {
+		return nil, "", fmt.Errorf("failed to create user: %w", err)
+	}
+	return u, password, nil
+}
+
+func (s *UserImportService) emailPassword(ctx context.Context, u *user.User, password string) error {
+	if s.sender == nil {
+		return errors.New("email is not configured; the password is returned instead")
+	}
+	text := fmt.Sprintf("An account was created for you on the platform.\n\nUsername: %s\nInitial password: %s\n", u.Username, password)
+	if config.PlatformURL != "" {
+		text += "\nSign in at " + config.PlatformURL + " and change your password.\n"
+	} else {
+		text += "\nPlease change your password after signing in.\n"
+	}
+	msg := mail.Message{To: []string{*u.Email}, Subject: "Your platform account", Text: text}
+	if err := s.sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to email the password; it is returned instead: %w", err)
+	}
+	return nil
+}
+
+type importedUser struct {
+	username string
+	group    *group.Group
+}
+
+func importedUsernames(users []importedUser) []string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.username)
+	}
+	return names
+}
+
+// startHubCreation creates the storage hubs of users one after the other in the background,
+// binding each ready hub into the projects of the user's group, and returns the progress ID.
+func (s *UserImportService) startHubCreation(users []importedUser) string {
+	var raw [8]byte
+	_, _ = rand.Read(raw[:])
+	p := &UserHubImportProgress{ID: hex.EncodeToString(raw[:]), Total: len(users), StartedAt: time.Now()}
+	for _, u := range users {
+		p.Users = append(p.Users, UserHubImportStatus{Username: u.username, Status: "pending"})
+	}
+
+	s.mu.Lock()
+	for id, old := range s.progress {
+		if old.FinishedAt != nil && time.Since(*old.FinishedAt) > userHubImportRetention {
+			delete(s.progress, id)
+		}
+	}
+	s.progress[p.ID] = p
+	s.mu.Unlock()
+
+	go func() {
+		ctx := context.Background()
+		for i, u := range users {
+			err := s.initHub(ctx, u.username)
+			if err == nil && u.group != nil {
+				err = s.userGroups.AllocateGroupResource(u.group.GID, u.username)
+			}
+			s.mu.Lock()
+			p.Done++
+			if err != nil {
+				log.Printf("[UserImport] Failed to create the storage hub of %s: %v", u.username, err)
+				p.Failed++
+				p.Users[i].Status, p.Users[i].Error = "failed", err.Error()
+			} else {
+				p.Users[i].Status = "ready"
+			}
+			s.mu.Unlock()
+		}
+		s.mu.Lock()
+		now := time.Now()
+		p.FinishedAt = &now
+		s.mu.Unlock()
+	}()
+	return p.ID
+}
+
+// HubProgress returns a copy of the progress of the hub creation id.
+func (s *UserImportService) HubProgress(id string) (*UserHubImportProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.progress[id]
+	if !ok {
+		return nil, ErrUserImportNotFound
+	}
+	out := *p
+	out.Users = append([]UserHubImportStatus(nil), p.Users...)
+	return &out, nil
+}
+
+// passwordAlphabet leaves out characters easily confused when read from a handout.
+const passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz23456789"
+
+func generatePassword() (string, error) {
+	out := make([]byte, 14)
+	for i := range out {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		out[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(out), nil
+}
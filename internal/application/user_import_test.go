@@ -0,0 +1,194 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/group"
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestParseUserImportCSV(t *testing.T) {
+	csv := "\ufeffusername,email,full name,group,role\n" +
+		"alice,alice@example.com,\"Chen, Alice\",CS101,user\n" +
+		"\n" +
+		"bob,,\"Bob \"\"The TA\"\" Lin\",cs101, Manager \n"
+	rows, err := ParseUserImportCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ParseUserImportCSV: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected the header and blank line to be skipped, got %+v", rows)
+	}
+	// The byte order mark must not end up in the first header, or the header row is read as a user
+	if rows[0].Username != "alice" || rows[0].FullName != "Chen, Alice" || rows[0].Group != "CS101" || rows[0].Line != 2 {
+		t.Fatalf("unexpected first row %+v", rows[0])
+	}
+	if rows[1].FullName != `Bob "The TA" Lin` || rows[1].Role != "manager" || rows[1].Email != "" || rows[1].Line != 4 {
+		t.Fatalf("unexpected second row %+v", rows[1])
+	}
+}
+
+func TestParseUserImportCSVColumns(t *testing.T) {
+	// Under a header the columns may come in any order
+	rows, err := ParseUserImportCSV(strings.NewReader("role,group,username\nmanager,CS101,carol\n"))
+	if err != nil || len(rows) != 1 || rows[0].Username != "carol" || rows[0].Role != "manager" || rows[0].Group != "CS101" {
+		t.Fatalf("expected the header order to be followed, got %+v (%v)", rows, err)
+	}
+
+	// Without one they are positional
+	rows, err = ParseUserImportCSV(strings.NewReader("dave,dave@example.com\nerin,e@example.com,Erin,CS101,user,extra\n"))
+	if err != nil || len(rows) != 2 || rows[0].Email != "dave@example.com" {
+		t.Fatalf("expected positional columns, got %+v (%v)", rows, err)
+	}
+	if rows[1].problem == "" {
+		t.Fatal("expected a row with too many columns to be reported")
+	}
+
+	for name, bad := range map[string]string{
+		"unterminated quote": "frank,\"frank@example.com\n",
+		"only a header":      "username,email\n",
+		"empty":              "\ufeff\n\n",
+	} {
+		if _, err := ParseUserImportCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// setupUserImport serves imports over a database holding the user taken and the group CS101.
+func setupUserImport(t *testing.T) (*UserImportService, *gorm.DB, *gin.Context) {
+	t.Helper()
+	db := dbtest.Open(t, &user.User{}, &group.Group{}, &group.UserGroup{}, &project.Project{})
+	db.Create(&user.User{Username: "taken", Password: "x"})
+	db.Create(&group.Group{GID: 4, GroupName: "CS101"})
+
+	origLog := utils.LogAuditWithConsole
+	t.Cleanup(func() { utils.LogAuditWithConsole = origLog })
+	utils.LogAuditWithConsole = func(*gin.Context, string, string, string, interface{}, interface{}, string, repository.AuditRepo) {}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/admin/users/import", nil)
+	return NewUserImportService(repository.NewRepositories(db), nil), db, c
+}
+
+const importCSV = "username,email,full name,group,role\n" +
+	"alice,alice@example.com,Alice,CS101,user\n" +
+	"taken,,,CS101,user\n" +
+	"bob,not-an-email,Bob,CS102,owner\n" +
+	"Alice,,,,\n" +
+	"carol,,Carol,cs101,manager\n"
+
+func TestImportUsersDryRun(t *testing.T) {
+	svc, db, c := setupUserImport(t)
+	rows, _ := ParseUserImportCSV(strings.NewReader(importCSV))
+
+	report, err := svc.ImportUsers(c, rows, UserImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if !report.DryRun || report.Total != 5 || report.Valid != 2 || report.Failed != 3 || report.Created != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	want := map[string][]string{
+		"taken": {"username already taken"},
+		"bob":   {"invalid email", `invalid role "owner", expected admin, manager or user`, `unknown group "CS102"`},
+		"Alice": {"duplicate of line 2"},
+	}
+	for _, row := range report.Rows {
+		if problems, ok := want[row.Username]; ok {
+			if row.Status != ImportRowInvalid || strings.Join(row.Errors, "; ") != strings.Join(problems, "; ") {
+				t.Errorf("%s: expected %v, got %s %v", row.Username, problems, row.Status, row.Errors)
+			}
+		} else if row.Status != ImportRowValid || row.Password != "" {
+			t.Errorf("%s: expected a valid row without password, got %+v", row.Username, row)
+		}
+	}
+	var count int64
+	db.Model(&user.User{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("a dry run must not create users, found %d", count)
+	}
+}
+
+func TestImportUsersExecute(t *testing.T) {
+	svc, db, c := setupUserImport(t)
+	sender := &recordingSender{}
+	svc.sender = sender
+	var mu sync.Mutex
+	var hubs []string
+	svc.initHub = func(_ context.Context, username string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		hubs = append(hubs, username)
+		if username == "carol" {
+			return errors.New("storage class not found")
+		}
+		return nil
+	}
+	rows, _ := ParseUserImportCSV(strings.NewReader(importCSV))
+
+	report, err := svc.ImportUsers(c, rows, UserImportOptions{Execute: true, EmailPasswords: true, InitHubs: true})
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if report.DryRun || report.Created != 2 || report.Failed != 3 || report.HubImportID == "" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	results := map[string]UserImportRowResult{}
+	for _, row := range report.Rows {
+		results[row.Username] = row
+	}
+	// alice has an email, so her password is sent rather than returned
+	if r := results["alice"]; r.Status != ImportRowCreated || !r.Emailed || r.Password != "" {
+		t.Fatalf("expected alice to be created and emailed, got %+v", r)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "alice@example.com" {
+		t.Fatalf("expected one email to alice, got %+v", sender.sent)
+	}
+	carol := results["carol"]
+	if carol.Status != ImportRowCreated || carol.Password == "" || carol.Emailed {
+		t.Fatalf("expected carol's password in the report, got %+v", carol)
+	}
+	var stored user.User
+	db.Where("username = ?", "carol").First(&stored)
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte(carol.Password)) != nil {
+		t.Fatal("expected the returned password to sign carol in")
+	}
+	var membership group.UserGroup
+	if err := db.Where("u_id = ? AND g_id = ?", stored.UID, 4).First(&membership).Error; err != nil || membership.Role != "manager" {
+		t.Fatalf("expected carol to manage CS101, got %+v (%v)", membership, err)
+	}
+
+	var progress *UserHubImportProgress
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if progress, err = svc.HubProgress(report.HubImportID); err != nil {
+			t.Fatalf("HubProgress: %v", err)
+		}
+		if progress.FinishedAt != nil {
+			break
+		}
+	}
+	if progress.FinishedAt == nil || progress.Done != 2 || progress.Failed != 1 {
+		t.Fatalf("expected both hubs attempted and carol's to fail, got %+v", progress)
+	}
+	if progress.Users[1].Username != "carol" || progress.Users[1].Status != "failed" || progress.Users[0].Status != "ready" {
+		t.Fatalf("unexpected hub states %+v", progress.Users)
+	}
+	if _, err := svc.HubProgress("unknown"); !errors.Is(err, ErrUserImportNotFound) {
+		t.Fatalf("expected ErrUserImportNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s"
+)
+
+// StartUserSFTP starts the SFTP server of a user's storage hub with the user's SSH keys, or
+// refreshes a running one. A running server whose keys changed is restarted. When the pod is not
+// Ready within config.StorageReadyTimeout the error wraps ErrStorageNotReady.
+func (s *K8sService) StartUserSFTP(ctx context.Context, username string) (*k8s.SFTPEndpoint, error) {
+	u, err := s.repos.User.GetUserByUsername(username)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	keys := storedSSHKeys(&u)
+	if len(keys) == 0 {
+		return nil, ErrNoSSHKeys
+	}
+	authorized := make([]string, len(keys))
+	for i, k := range keys {
+		authorized[i] = k.Key
+	}
+
+	nsName, pvcName := userHubNames(s.UserStorageID(username))
+	spec := k8s.SFTPServerSpec{
+		Namespace:      nsName,
+		PVCName:        pvcName,
+		Username:       k8s.ToSafeK8sName(username),
+		AuthorizedKeys: authorized,
+		Labels:         k8s.Ownership{UserID: u.UID}.Labels(),
+	}
+	var endpoint *k8s.SFTPEndpoint
+	if err := s.repos.WithNamespaceLock(nsName, func() error {
+		endpoint, err = k8s.EnsureSFTPServer(ctx, spec)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := k8s.WaitForPodReady(ctx, nsName, k8s.SFTPPodName, config.StorageReadyTimeout); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStorageNotReady, err)
+	}
+	return endpoint, nil
+}
+
+// StopUserSFTP removes the SFTP server of a user's storage hub.
+func (s *K8sService) StopUserSFTP(ctx context.Context, username string) error {
+	nsName, _ := userHubNames(s.UserStorageID(username))
+	return k8s.DeleteSFTPServer(ctx, nsName)
+}
@@ -0,0 +1,130 @@
+package application
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrInvalidSSHKey  = errors.New("invalid SSH public key")
+	ErrTooManySSHKeys = errors.New("too many SSH public keys")
+	ErrNoSSHKeys      = errors.New("no SSH public keys registered")
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for SFTP logins.
+const minRSAKeyBits = 2048
+
+// SSHKeyError reports which submitted key was rejected. Index is its position in the request.
+type SSHKeyError struct {
+	Index  int
+	Reason string
+}
+
+func (e *SSHKeyError) Error() string {
+	return fmt.Sprintf("%s at index %d: %s", ErrInvalidSSHKey, e.Index, e.Reason)
+}
+
+func (e *SSHKeyError) Unwrap() error {
+	return ErrInvalidSSHKey
+}
+
+// ParseSSHKeys validates OpenSSH public keys in authorized_keys format and returns them
+// normalized to "type base64 [comment]". Keys with options, DSA keys and RSA keys shorter than
+// 2048 bits are rejected; a key given twice is kept once.
+func ParseSSHKeys(keys []string) ([]user.SSHKey, error) {
+	if len(keys) > config.SSHKeysMaxPerUser {
+		return nil, fmt.Errorf("%w: at most %d", ErrTooManySSHKeys, config.SSHKeysMaxPerUser)
+	}
+	out := make([]user.SSHKey, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for i, raw := range keys {
+		raw = strings.TrimSpace(raw)
+		if strings.ContainsAny(raw, "\r\n") {
+			return nil, &SSHKeyError{Index: i, Reason: "one key per entry"}
+		}
+		pub, comment, options, rest, err := ssh.ParseAuthorizedKey([]byte(raw))
+		if err != nil {
+			return nil, &SSHKeyError{Index: i, Reason: "not an OpenSSH public key"}
+		}
+		if len(options) > 0 || len(rest) > 0 {
+			return nil, &SSHKeyError{Index: i, Reason: "options are not allowed"}
+		}
+		if pub.Type() == ssh.KeyAlgoDSA {
+			return nil, &SSHKeyError{Index: i, Reason: "DSA keys are not supported"}
+		}
+		if ck, ok := pub.(ssh.CryptoPublicKey); ok {
+			if rsaKey, ok := ck.CryptoPublicKey().(*rsa.PublicKey); ok && rsaKey.N.BitLen() < minRSAKeyBits {
+				return nil, &SSHKeyError{Index: i, Reason: fmt.Sprintf("RSA keys need at least %d bits", minRSAKeyBits)}
+			}
+		}
+
+		fingerprint := ssh.FingerprintSHA256(pub)
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+		line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+		if comment != "" {
+			line += " " + comment
+		}
+		out = append(out, user.SSHKey{Type: pub.Type(), Fingerprint: fingerprint, Comment: comment, Key: line})
+	}
+	return out, nil
+}
+
+// storedSSHKeys returns the keys of u, skipping lines that no longer parse.
+func storedSSHKeys(u *user.User) []user.SSHKey {
+	var keys []user.SSHKey
+	for _, line := range strings.Split(u.SSHPublicKeys, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parsed, err := ParseSSHKeys([]string{line})
+		if err != nil || len(parsed) == 0 {
+			continue
+		}
+		keys = append(keys, parsed[0])
+	}
+	return keys
+}
+
+// GetSSHKeys lists the SSH public keys of a user.
+func (s *UserService) GetSSHKeys(uid uint) ([]user.SSHKey, error) {
+	usr, err := s.Repos.User.GetUserRawByID(uid)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	keys := storedSSHKeys(&usr)
+	if keys == nil {
+		keys = []user.SSHKey{}
+	}
+	return keys, nil
+}
+
+// SetSSHKeys replaces the SSH public keys of a user. A running SFTP server picks them up when
+// it is started again.
+func (s *UserService) SetSSHKeys(uid uint, keys []string) ([]user.SSHKey, error) {
+	parsed, err := ParseSSHKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	usr, err := s.Repos.User.GetUserRawByID(uid)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	lines := make([]string, len(parsed))
+	for i, k := range parsed {
+		lines[i] = k.Key
+	}
+	usr.SSHPublicKeys = strings.Join(lines, "\n")
+	if err := s.Repos.User.SaveUser(&usr); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
@@ -0,0 +1,120 @@
+package application
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+)
+
+var (
+	unsafeHubNameChars = regexp.MustCompile("[^a-z0-9-]+")
+	hubNameChars       = regexp.MustCompile("[a-z0-9]")
+)
+
+// maxStorageIDLength keeps "user-<id>-storage" within the 63 characters of a namespace name;
+// storage IDs derived from a hash keep a sanitized prefix of up to 41 characters before it.
+const (
+	maxStorageIDLength = 50
+	maxStorageIDPrefix = maxStorageIDLength - 9
+)
+
+// UserStorageIdentifier derives the name segment of a user's storage hub objects from a username.
+// A username that already is a valid segment is used as is. Any other username is sanitized and
+// gets a hash of the original appended, so usernames that sanitize alike, such as ones written
+// only in characters K8s names do not allow, get hubs of their own.
+func UserStorageIdentifier(username string) string {
+	if legacyUserStorageIdentifier(username) == username && len(username) <= maxStorageIDLength {
+		return username
+	}
+	return hashedUserStorageIdentifier(username)
+}
+
+func hashedUserStorageIdentifier(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	suffix := hex.EncodeToString(sum[:4])
+	prefix := strings.Trim(legacyUserStorageIdentifier(username), "-")
+	if len(prefix) > maxStorageIDPrefix {
+		prefix = strings.TrimRight(prefix[:maxStorageIDPrefix], "-")
+	}
+	if prefix == "" {
+		return suffix
+	}
+	return prefix + "-" + suffix
+}
+
+// legacyUserStorageIdentifier is the identifier hubs were named with before storage IDs were
+// stored: the lowercased username with every run of characters K8s names do not allow replaced
+// by a hyphen.
+func legacyUserStorageIdentifier(username string) string {
+	return unsafeHubNameChars.ReplaceAllString(strings.ToLower(username), "-")
+}
+
+// userHubNames returns the namespace and PVC of the storage hub with the given identifier.
+func userHubNames(storageID string) (nsName, pvcName string) {
+	return k8s.UserHubNamespace(storageID), k8s.UserHubDiskName(storageID)
+}
+
+// UserStorageID returns the identifier the storage hub of a user is named with. It is stored on
+// the user row, so the hub is found after the username changes; users whose hub predates the
+// column get it assigned on first access. Unknown users get the computed identifier.
+func (s *K8sService) UserStorageID(username string) string {
+	return userStorageID(s.repos, username)
+}
+
+// userStorageID is UserStorageID for services other than K8sService; every hub name derives from
+// it, so all of them find the same hub.
+func userStorageID(repos *repository.Repos, username string) string {
+	if repos == nil || repos.User == nil {
+		return UserStorageIdentifier(username)
+	}
+	u, err := repos.User.GetUserByUsername(username)
+	if err != nil {
+		return UserStorageIdentifier(username)
+	}
+	if u.StorageID != "" {
+		return u.StorageID
+	}
+
+	candidates := storageIDCandidates(u.Username)
+	for _, storageID := range candidates {
+		set, err := repos.User.SetStorageID(u.UID, storageID)
+		if err != nil {
+			log.Printf("[StorageHub] Failed to save the storage identifier of %s: %v", username, err)
+			return storageID
+		}
+		if set {
+			return storageID
+		}
+		// Either another request stored it first or another user holds this identifier
+		if stored, err := repos.User.GetUserRawByID(u.UID); err == nil && stored.StorageID != "" {
+			return stored.StorageID
+		}
+	}
+	log.Printf("[StorageHub] Every storage identifier of %s is held by another user", username)
+	return candidates[len(candidates)-1]
+}
+
+// storageIDCandidates lists the identifiers a user without a stored one may get, in order of
+// preference. A hub created under the legacy identifier is kept unless that identifier is
+// nothing but hyphens, which every such user shared. The hashed identifier comes last, for
+// when another user already holds the plain username, e.g. after a rename.
+func storageIDCandidates(username string) []string {
+	var candidates []string
+	preferred, legacy := UserStorageIdentifier(username), legacyUserStorageIdentifier(username)
+	if legacy != preferred && hubNameChars.MatchString(legacy) && len(legacy) <= maxStorageIDLength {
+		nsName, _ := userHubNames(legacy)
+		if exists, err := k8s.CheckNamespaceExists(nsName); err == nil && exists {
+			candidates = append(candidates, legacy)
+		}
+	}
+	candidates = append(candidates, preferred)
+	if hashed := hashedUserStorageIdentifier(username); hashed != preferred {
+		candidates = append(candidates, hashed)
+	}
+	return candidates
+}
@@ -0,0 +1,115 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUserStorageIDSurvivesSanitizationAndRenames(t *testing.T) {
+	svc := newStorageEntitlementService(t)
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	k8s.Clientset = k8sfake.NewSimpleClientset()
+
+	// A user whose hub predates the stored identifier
+	jan := &user.User{UID: 5, Username: "Jan.Müller_2024", Password: "x"}
+	if err := svc.repos.User.SaveUser(jan); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	want := UserStorageIdentifier(jan.Username)
+	if !strings.HasPrefix(want, "jan-m-ller-2024-") || len(want) != len("jan-m-ller-2024-")+8 {
+		t.Fatalf("expected the sanitized name with a hash suffix, got %q", want)
+	}
+	if id := svc.UserStorageID(jan.Username); id != want {
+		t.Fatalf("expected storage id %q, got %q", want, id)
+	}
+	stored, err := svc.repos.User.GetUserRawByID(jan.UID)
+	if err != nil || stored.StorageID != want {
+		t.Fatalf("expected the storage id to be backfilled, got %q (%v)", stored.StorageID, err)
+	}
+
+	// The browser service is where the proxy sends the user's traffic
+	if _, err := svc.OpenUserGlobalFileBrowser(context.Background(), jan.Username); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := k8s.Clientset.CoreV1().Services("user-"+want+"-storage").Get(context.Background(), "fb-hub-svc-"+want, metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected the hub browser service under the sanitized id, got %v", err)
+	}
+
+	// A renamed user keeps the hub it already has
+	stored.Username = "jan.mueller"
+	if err := svc.repos.User.SaveUser(&stored); err != nil {
+		t.Fatalf("rename user: %v", err)
+	}
+	if id := svc.UserStorageID("jan.mueller"); id != want {
+		t.Fatalf("expected the renamed user to keep %q, got %q", want, id)
+	}
+	if target := UserStorageDeleteTarget("jan.mueller", svc.UserStorageID("jan.mueller")); target.Namespace != "user-"+want+"-storage" {
+		t.Fatalf("expected the delete target to be the hub namespace, got %q", target.Namespace)
+	}
+}
+
+func TestUserStorageIDKeepsUsersApart(t *testing.T) {
+	svc := newStorageEntitlementService(t)
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	// A hub created under the legacy identifier before storage IDs were stored
+	k8s.Clientset = k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "user-li-wei-storage"}})
+
+	seed := []*user.User{
+		{UID: 5, Username: "王小明", Password: "x"},
+		{UID: 6, Username: "李大華", Password: "x"},
+		{UID: 7, Username: "Li.Wei", Password: "x"},
+	}
+	for _, u := range seed {
+		if err := svc.repos.User.SaveUser(u); err != nil {
+			t.Fatalf("seed user: %v", err)
+		}
+	}
+
+	first, second := svc.UserStorageID("王小明"), svc.UserStorageID("李大華")
+	if first == second || len(first) != 8 || len(second) != 8 {
+		t.Fatalf("expected distinct hash identifiers for CJK usernames, got %q and %q", first, second)
+	}
+	if id := svc.UserStorageID("Li.Wei"); id != "li-wei" {
+		t.Fatalf("expected the existing legacy hub to be kept, got %q", id)
+	}
+
+	// alice keeps the identifier after a rename, so a new alice gets another one
+	if id := svc.UserStorageID("alice"); id != "alice" {
+		t.Fatalf("expected alice to keep the username, got %q", id)
+	}
+	alice, _ := svc.repos.User.GetUserByUsername("alice")
+	alice.Username = "alice.old"
+	if err := svc.repos.User.SaveUser(&alice); err != nil {
+		t.Fatalf("rename user: %v", err)
+	}
+	if err := svc.repos.User.SaveUser(&user.User{UID: 8, Username: "alice", Password: "x"}); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	if id := svc.UserStorageID("alice"); id == "alice" || !strings.HasPrefix(id, "alice-") {
+		t.Fatalf("expected the new alice to get a hashed identifier, got %q", id)
+	}
+}
+
+// TestStorageIDIsUniqueInTheDatabase stores the storage ID of one user directly on another; the
+// index AutoMigrate creates rejects it while users without one may share the empty value.
+func TestStorageIDIsUniqueInTheDatabase(t *testing.T) {
+	svc := newStorageEntitlementService(t)
+	if ok, err := svc.repos.User.SetStorageID(1, "alice"); err != nil || !ok {
+		t.Fatalf("expected alice's storage ID to be stored, got %v (%v)", ok, err)
+	}
+	bob, _ := svc.repos.User.GetUserByUsername("bob")
+	bob.StorageID = "alice"
+	if err := svc.repos.User.SaveUser(&bob); err == nil {
+		t.Fatal("expected the unique index to reject a second user with the same storage ID")
+	}
+}
@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -41,16 +42,81 @@ func (s *UserGroupService) AllocateGroupResource(gid uint, userName string) erro
 		ns := k8s.FormatNamespaceName(project.PID, safeUsername)
 
 		log.Printf("[Allocate] Ensuring namespace %s exists for user %s", ns, safeUsername)
-		if err := k8s.EnsureNamespaceExists(ns); err != nil {
+		if err := EnsureMemberNamespace(s.Repos, &project, ns, userName); err != nil {
 			log.Printf("[Error] Failed to create namespace %s: %v", ns, err)
 			continue
 		}
 
+		s.bindUserHub(userName, project.PID)
 	}
 
 	return nil
 }
 
+// bindUserHub mounts the user's hub volume into the project namespace.
+// A hub that is not ready yet is picked up later by ReconcileUserHubBindings.
+func (s *UserGroupService) bindUserHub(userName string, projectID uint) {
+	err := k8s.BindUserHubToProject(context.Background(), userName, userStorageID(s.Repos, userName), projectID)
+	logUserHubBinding(userName, projectID, err)
+}
+
+func logUserHubBinding(userName string, projectID uint, err error) {
+	if err == nil {
+		return
+	}
+	if errors.Is(err, k8s.ErrUserHubNotReady) {
+		log.Printf("[Allocate] User hub for %s not ready, project %d binding will be retried", userName, projectID)
+		return
+	}
+	log.Printf("[Error] Failed to bind user hub for %s into project %d: %v", userName, projectID, err)
+}
+
+// ReconcileUserHubBindings ensures every member of a project-owning group has their hub volume bound
+// into the project namespace. The hub services and bound PVCs are listed once per sweep, so only the
+// missing bindings cost API calls; groups and users are looked up once each.
+func (s *UserGroupService) ReconcileUserHubBindings() error {
+	ctx := context.Background()
+	projects, err := s.Repos.Project.ListProjects()
+	if err != nil {
+		return fmt.Errorf("failed to list projects: %w", err)
+	}
+	bindings, err := k8s.ListUserHubBindings(ctx)
+	if err != nil {
+		return err
+	}
+
+	type member struct{ name, storageID string }
+	members := map[uint][]member{}
+	users := map[uint]*member{}
+	for _, project := range projects {
+		groupMembers, ok := members[project.GID]
+		if !ok {
+			userGroups, err := s.Repos.UserGroup.GetUserGroupsByGID(project.GID)
+			if err != nil {
+				log.Printf("[Reconcile] Failed to list members of group %d: %v", project.GID, err)
+				continue
+			}
+			for _, ug := range userGroups {
+				u, ok := users[ug.UID]
+				if !ok {
+					if name, err := s.Repos.User.GetUsernameByID(ug.UID); err == nil {
+						u = &member{name: name, storageID: userStorageID(s.Repos, name)}
+					}
+					users[ug.UID] = u
+				}
+				if u != nil {
+					groupMembers = append(groupMembers, *u)
+				}
+			}
+			members[project.GID] = groupMembers
+		}
+		for _, m := range groupMembers {
+			logUserHubBinding(m.name, project.PID, bindings.Bind(ctx, m.name, m.storageID, project.PID))
+		}
+	}
+	return nil
+}
+
 func (s *UserGroupService) RemoveGroupResource(gid uint, userName string) error {
 	projects, err := s.Repos.Project.ListProjectsByGroup(gid)
 	if err != nil {
@@ -65,6 +131,10 @@ func (s *UserGroupService) RemoveGroupResource(gid uint, userName string) error
 
 		log.Printf("[Cleanup] Removing resource namespace %s for user %s", ns, safeUsername)
 
+		if err := k8s.UnbindUserHubFromProject(context.Background(), userName, project.PID); err != nil {
+			log.Printf("[Warning] Failed to unbind user hub from %s: %v", ns, err)
+		}
+
 		if err := k8s.DeleteNamespace(ns); err != nil {
 			log.Printf("[Warning] Failed to delete namespace %s: %v", ns, err)
 			lastErr = err
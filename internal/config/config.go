@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	appsv1 "k8s.io/api/apps/v1"
@@ -13,8 +15,13 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// APIV1Prefix is the canonical prefix of every route; the same routes are also served
+// unversioned while LegacyRoutesEnabled is set.
+const APIV1Prefix = "/api/v1"
+
 var (
 	JwtSecret               string
+	CredentialEncryptionKey string // Encrypts credentials stored in the database; JwtSecret when unset
 	DbHost                  string
 	DbPort                  string
 	DbUser                  string
@@ -38,18 +45,240 @@ var (
 	ProjectPVSize           = "10Gi"
 	// Environment
 	IsProduction bool
+	// Stop the API at startup when a cluster prerequisite check fails, instead of logging it
+	PreflightStrict bool
+	// Keep serving the unversioned routes next to APIV1Prefix, answering with Deprecation and
+	// Sunset headers, until clients have moved; LegacyRoutesSunset is the announced removal date
+	LegacyRoutesEnabled = true
+	LegacyRoutesSunset  = "Wed, 30 Jun 2027 00:00:00 GMT"
 	// Reserved names that cannot be deleted or downgraded
 	ReservedGroupName     = "super"
 	ReservedAdminUsername = "admin"
 	// Storage Pattern
-	UserStorageNs  = "user-%s-storage" // user-{username}-storage
-	UserStoragePVC = "user-%s-disk"    // user-{username}-disk
+	UserStorageNs  = "user-%s-storage" // user-{storage_id}-storage
+	UserStoragePVC = "user-%s-disk"    // user-{storage_id}-disk
 	// K8s Service Names
 	PersonalStorageServiceName   string
 	ProjectStorageServiceName    string
 	ProjectStorageBrowserSVCName string
 	ProjectNfsServiceName        string
 	HarborPrivatePrefix          string
+	// Pull secret for the Harbor registry, copied into namespaces running Harbor images
+	HarborPullSecretName      string
+	HarborPullSecretNamespace string
+	// Priority Classes (job priority level -> PriorityClass)
+	PriorityClassNames = map[string]string{
+		"low":    "low-priority",
+		"medium": "normal-priority",
+		"high":   "high-priority",
+	}
+	PriorityClassValues = map[string]int32{
+		"low":    100,
+		"medium": 500,
+		"high":   1000,
+	}
+	// Gang scheduling of multi-pod jobs: "volcano", "coscheduling" or "" for the default
+	// scheduler; GangSchedulerName overrides the schedulerName set on the pods. A gang whose pods
+	// are not all bound within GangPlacementTimeout is removed and retried later (0 skips the wait).
+	GangScheduler        = ""
+	GangSchedulerName    = ""
+	GangPlacementTimeout = 2 * time.Minute
+	// Active job rows younger than this are not marked lost when their K8s Job is missing at
+	// scheduler startup; the API may still be creating it
+	JobReconcileGracePeriod = 10 * time.Minute
+	// Longest a job may run in projects without their own runtime limit (0 is unlimited)
+	JobMaxRuntime = 24 * time.Hour
+	// Priority levels each group role may request
+	RolePriorityLevels = map[string][]string{
+		"user":    {"low"},
+		"manager": {"low", "medium"},
+		"admin":   {"low", "medium", "high"},
+	}
+	// Job types each group role may submit; "external" also needs ExternalExecutorURL
+	RoleJobTypes = map[string][]string{
+		"user":    {"normal"},
+		"manager": {"normal", "external"},
+		"admin":   {"normal", "external"},
+	}
+	// REST endpoint and bearer token of the external cluster (e.g. a Slurm REST gateway) that
+	// runs external jobs, and how often their status is polled
+	ExternalExecutorURL     = ""
+	ExternalExecutorToken   = ""
+	ExternalJobPollInterval = 15 * time.Second
+	// Storage classes that support ReadWriteMany (others default to ReadWriteOnce)
+	StorageClassRWXSupport = map[string]bool{
+		"longhorn":   false,
+		"nfs-client": true,
+		"nfs-csi":    true,
+		"cephfs":     true,
+	}
+	// Storage classes users may request for project storage; empty allows every class
+	UserStorageClasses []string
+	// Bounds on the size of a project storage request; an empty bound is not checked
+	StorageMinSize = "1Gi"
+	StorageMaxSize = ""
+	// Also match unlabeled proj-<pid>-* namespaces by name, which lists every namespace of the
+	// cluster per lookup. Startup labels the legacy namespaces, so it is only needed for ones
+	// created by hand since.
+	LegacyNamespaceFallback = false
+	// Upper bound on graceful shutdown: draining HTTP requests and flushing pull monitors
+	ShutdownTimeout = 15 * time.Second
+	// Port Forward
+	PortForwardIdleTimeout       = 10 * time.Minute
+	PortForwardMaxTunnelsPerUser = 3
+	// Per-request upload cap for the FileBrowser storage proxies (0 disables it)
+	StorageProxyMaxUploadBytes int64 = 10 << 30
+	// Request body limits per route group: small for auth and forms, medium for config files and
+	// job submissions. Admins skip them on the "METHOD /route" entries of BodyLimitExemptRoutes.
+	BodyLimitSmall        int64 = 64 << 10
+	BodyLimitMedium       int64 = 4 << 20
+	BodyLimitExemptRoutes       = map[string]bool{}
+	// Payload limits checked after binding: config file YAML, and the total bytes of a job's
+	// command and args and of its env keys and values
+	ConfigFileMaxContentBytes = 1 << 20
+	JobCommandMaxBytes        = 64 << 10
+	JobEnvMaxBytes            = 64 << 10
+	// CPU and memory requests given to instance containers that set none, unless the project
+	// overrides them, and the multiple of the request set as their limit (0 sets no limit)
+	DefaultContainerCPURequest     = "250m"
+	DefaultContainerMemoryRequest  = "512Mi"
+	DefaultResourceLimitMultiplier = 2.0
+	// MPS sharing of a GPU: the CUDA_MPS_ACTIVE_THREAD_PERCENTAGE given per quota unit of a pod and
+	// the least it is given, the VRAM of a node's GPU in MB and the most pods sharing one GPU
+	MPSThreadPercentPerUnit = 10
+	MPSThreadPercentFloor   = 10
+	GPUNodeVRAMMB           = 48000
+	MPSMaxSharedPods        = 10
+	// Lifetime of read-only terminal share tokens
+	TerminalShareTokenTTL = 15 * time.Minute
+	// Resource watch WebSockets: messages buffered per connection before new ones are dropped,
+	// and how often the client is told about drops so it can refetch
+	WatchBufferSize    = 200
+	WatchStatsInterval = 10 * time.Second
+	// Initial snapshot of a watch: objects per LIST page and the pause between pages
+	WatchListPageSize   = 100
+	WatchListBatchDelay = 50 * time.Millisecond
+	// Job artifact upload: the uploader container image, the Secret holding its MinIO
+	// credentials in each job namespace, the directory shared with the main container, and how
+	// long listed download URLs stay valid. An empty uploader key falls back to the platform key.
+	ArtifactUploaderImage = "minio/mc:latest"
+	ArtifactSecretName    = "platform-artifact-credentials"
+	ArtifactMountPath     = "/artifacts"
+	ArtifactURLExpiry     = time.Hour
+	ArtifactAccessKey     = ""
+	ArtifactSecretKey     = ""
+	// Image Pull Jobs
+	ImagePullNamespace     = "image-puller"
+	ImagePullMaxConcurrent = 3
+	ImagePullCPURequest    = "100m"
+	ImagePullCPULimit      = "1"
+	ImagePullMemoryRequest = "128Mi"
+	ImagePullMemoryLimit   = "1Gi"
+	// Warming the node caches after a pull: whether it runs when the approval did not choose,
+	// the nodes it targets, how long it may take, and the image that keeps each preload pod alive
+	ImagePreloadEnabled      = false
+	ImagePreloadNodeSelector = map[string]string{"nvidia.com/gpu.present": "true"}
+	ImagePreloadTimeout      = 30 * time.Minute
+	ImagePreloadPauseImage   = "registry.k8s.io/pause:3.9"
+	// How often running pods are scanned for the Harbor images they use, and how long after
+	// last being seen running a global image rule stays protected from removal (0 disables it)
+	ImageUsageScanInterval = 5 * time.Minute
+	ImageInUseWindow       = 7 * 24 * time.Hour
+	// How often tracked pulls are checked against their Jobs, and how long a pull may go without
+	// a status update before it is checked
+	ImagePullJanitorInterval = 5 * time.Minute
+	ImagePullStaleAfter      = 10 * time.Minute
+	// Failed pulls kept for the failed-pulls listing, per image
+	ImagePullFailedPerImage = 5
+	// How long the API and the scheduler reuse the maintenance flag before reading it again
+	MaintenanceCacheTTL = 10 * time.Second
+	// How often the resources requested in project namespaces are sampled, how long samples
+	// are kept, and how many points a usage history is downsampled to
+	UsageSampleInterval = 5 * time.Minute
+	UsageRetention      = 14 * 24 * time.Hour
+	UsageHistoryPoints  = 48
+	// FileBrowser pod resources
+	FileBrowserCPURequest    = "50m"
+	FileBrowserCPULimit      = "500m"
+	FileBrowserMemoryRequest = "64Mi"
+	FileBrowserMemoryLimit   = "256Mi"
+	// Storage hub NFS server pod resources
+	StorageHubCPURequest    = "100m"
+	StorageHubCPULimit      = "1"
+	StorageHubMemoryRequest = "128Mi"
+	StorageHubMemoryLimit   = "512Mi"
+	// ConfigMap/Secret limits for config files; admins may exceed them with a warning
+	ConfigDataMaxBytes       = 512 * 1024
+	ConfigMapMaxPerFile      = 10
+	ConfigMapMaxPerNamespace = 10
+	// Upper bound on pod log bytes attached to job failure messages
+	JobLogMaxBytes = 8 * 1024
+	// VolumeSnapshotClass for project storage snapshots and the number kept per project
+	VolumeSnapshotClassName = "longhorn-snapshot-vsc"
+	ProjectSnapshotMax      = 5
+	// Retention in days per table (0 keeps rows forever) and rows deleted per pruning batch
+	AuditLogRetentionDays = 30
+	JobLogRetentionDays   = 90
+	RetentionBatchSize    = 1000
+	// Days the recent items of users are kept (0 keeps them forever)
+	UserActivityRetentionDays = 30
+	// Days a deleted config file stays in the trash before it is purged (0 never purges)
+	ConfigFileTrashRetentionDays = 14
+	// Authentication
+	LocalLoginEnabled     = true
+	OIDCEnabled           = false
+	OIDCIssuerURL         string
+	OIDCClientID          string
+	OIDCClientSecret      string
+	OIDCRedirectURL       string
+	OIDCScopes            = []string{"openid", "profile", "email"}
+	OIDCUsernameClaim     = "preferred_username"
+	OIDCEmailClaim        = "email"
+	OIDCAutoProvision     = false
+	OIDCDefaultGroupID    uint
+	OIDCDefaultRole       = "user"
+	OIDCPostLoginRedirect string
+	// Lifetime of the tokens super admins are issued to act as another user
+	ImpersonationTokenTTL = 15 * time.Minute
+	// Outgoing email; notifications are disabled while SMTPHost is empty
+	SMTPHost     string
+	SMTPPort     = 587
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// Base URL of the web frontend, used for the links in emails
+	PlatformURL string
+	// Admin digest of pending approvals: how often it is sent and how old a request must be to be listed
+	ApprovalDigestInterval = 24 * time.Hour
+	ApprovalDigestMinAge   = 24 * time.Hour
+	// How long an instance waiting for a project manager's approval may wait before it expires
+	InstanceApprovalTTL = 7 * 24 * time.Hour
+	// How often runtime setting changes made by admins are picked up by each process
+	SettingsRefreshInterval = 30 * time.Second
+	// Serve the bodies and status codes the K8s, storage and job endpoints had before they moved
+	// to the SuccessResponse envelope, for clients not updated yet. To be removed next release.
+	LegacyResponseBodies = false
+	// How long starting a FileBrowser or storage hub waits for its pod to be Ready (0 does not wait)
+	StorageReadyTimeout = 60 * time.Second
+	// Image of the storage hub pods: an NFS server exporting the hub volume, which user hubs are
+	// bound into project namespaces through
+	StorageHubImage = "itsthenetwork/nfs-server-alpine:12"
+	// How long recreating a FileBrowser or SFTP pod waits for the previous pod to be gone
+	FileBrowserDeleteTimeout = 30 * time.Second
+	// SFTP access to user storage hubs: the server image, how its Service is exposed ("NodePort",
+	// or "LoadBalancer" sharing SFTPLoadBalancerIP), the ports handed out to users, the host name
+	// shown to them (defaults to SFTPLoadBalancerIP) and how many keys a user may register
+	SFTPImage          = "atmoz/sftp:alpine"
+	SFTPServiceType    = "NodePort"
+	SFTPPortMin        = 30022
+	SFTPPortMax        = 30121
+	SFTPLoadBalancerIP string
+	SFTPPublicHost     string
+	SSHKeysMaxPerUser  = 10
+	// Log level of every component and per-component overrides such as "k8s=debug,scheduler=warn";
+	// components are k8s, scheduler, services and api
+	LogLevel  = "info"
+	LogLevels = ""
 )
 
 func LoadConfig() {
@@ -59,6 +288,7 @@ func LoadConfig() {
 	}
 
 	JwtSecret = getEnv("JWT_SECRET", "defaultsecret")
+	CredentialEncryptionKey = getEnv("CREDENTIAL_ENCRYPTION_KEY", "")
 	DbHost = getEnv("DB_HOST", "localhost")
 	DbPort = getEnv("DB_PORT", "5432")
 	DbUser = getEnv("DB_USER", "postgres")
@@ -74,10 +304,15 @@ func LoadConfig() {
 	MinioUseSSL, _ = strconv.ParseBool(getEnv("MINIO_USE_SSL", "true"))
 
 	DefaultStorageClassName = getEnv("DEFAULT_STORAGE_CLASS_NAME", "longhorn")
+	UserPVSize = getEnv("USER_PV_SIZE", UserPVSize)
+	ProjectPVSize = getEnv("PROJECT_PV_SIZE", ProjectPVSize)
 
 	// Environment
 	env := getEnv("GO_ENV", "development")
 	IsProduction = env == "production" || env == "release"
+	PreflightStrict, _ = strconv.ParseBool(getEnv("PREFLIGHT_STRICT", "false"))
+	LegacyRoutesEnabled, _ = strconv.ParseBool(getEnv("LEGACY_ROUTES_ENABLED", "true"))
+	LegacyRoutesSunset = getEnv("LEGACY_ROUTES_SUNSET", LegacyRoutesSunset)
 
 	// K8s Service Names
 	PersonalStorageServiceName = getEnv("PERSONAL_STORAGE_SERVICE_NAME", "storage-svc")
@@ -85,6 +320,325 @@ func LoadConfig() {
 	ProjectStorageBrowserSVCName = getEnv("PROJECT_STORAGE_BROWSER_SVC_NAME", "filebrowser-project-svc")
 	ProjectNfsServiceName = getEnv("PROJECT_NFS_SERVICE_NAME", "storage-svc")
 	HarborPrivatePrefix = getEnv("HARBOR_PRIVATE_PREFIX", "192.168.110.1:30003/library/")
+	HarborPullSecretName = getEnv("HARBOR_PULL_SECRET_NAME", "harbor-regcred")
+	HarborPullSecretNamespace = getEnv("HARBOR_PULL_SECRET_NAMESPACE", "default")
+
+	for _, sc := range strings.Split(getEnv("RWX_STORAGE_CLASSES", ""), ",") {
+		if sc = strings.TrimSpace(sc); sc != "" {
+			StorageClassRWXSupport[sc] = true
+		}
+	}
+	UserStorageClasses = nil
+	for _, sc := range strings.Split(getEnv("USER_STORAGE_CLASSES", ""), ",") {
+		if sc = strings.TrimSpace(sc); sc != "" {
+			UserStorageClasses = append(UserStorageClasses, sc)
+		}
+	}
+	StorageMinSize = getEnv("STORAGE_MIN_SIZE", StorageMinSize)
+	StorageMaxSize = getEnv("STORAGE_MAX_SIZE", StorageMaxSize)
+
+	LegacyNamespaceFallback, _ = strconv.ParseBool(getEnv("LEGACY_NAMESPACE_FALLBACK", "false"))
+
+	if d, err := time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", "")); err == nil {
+		ShutdownTimeout = d
+	}
+
+	// Port Forward
+	if d, err := time.ParseDuration(getEnv("PORT_FORWARD_IDLE_TIMEOUT", "")); err == nil {
+		PortForwardIdleTimeout = d
+	}
+	if n, err := strconv.Atoi(getEnv("PORT_FORWARD_MAX_TUNNELS_PER_USER", "")); err == nil {
+		PortForwardMaxTunnelsPerUser = n
+	}
+
+	if n, err := strconv.ParseInt(getEnv("STORAGE_PROXY_MAX_UPLOAD_BYTES", ""), 10, 64); err == nil {
+		StorageProxyMaxUploadBytes = n
+	}
+	if n, err := strconv.ParseInt(getEnv("BODY_LIMIT_SMALL", ""), 10, 64); err == nil {
+		BodyLimitSmall = n
+	}
+	if n, err := strconv.ParseInt(getEnv("BODY_LIMIT_MEDIUM", ""), 10, 64); err == nil {
+		BodyLimitMedium = n
+	}
+	for _, route := range strings.Split(getEnv("BODY_LIMIT_EXEMPT_ROUTES", ""), ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			BodyLimitExemptRoutes[route] = true
+		}
+	}
+	if n, err := strconv.Atoi(getEnv("CONFIG_FILE_MAX_CONTENT_BYTES", "")); err == nil && n > 0 {
+		ConfigFileMaxContentBytes = n
+	}
+	if n, err := strconv.Atoi(getEnv("JOB_COMMAND_MAX_BYTES", "")); err == nil && n > 0 {
+		JobCommandMaxBytes = n
+	}
+	if n, err := strconv.Atoi(getEnv("JOB_ENV_MAX_BYTES", "")); err == nil && n > 0 {
+		JobEnvMaxBytes = n
+	}
+	DefaultContainerCPURequest = getEnv("DEFAULT_CONTAINER_CPU_REQUEST", DefaultContainerCPURequest)
+	DefaultContainerMemoryRequest = getEnv("DEFAULT_CONTAINER_MEMORY_REQUEST", DefaultContainerMemoryRequest)
+	if f, err := strconv.ParseFloat(getEnv("DEFAULT_RESOURCE_LIMIT_MULTIPLIER", ""), 64); err == nil && f >= 0 {
+		DefaultResourceLimitMultiplier = f
+	}
+	if n, err := strconv.Atoi(getEnv("MPS_THREAD_PERCENT_PER_UNIT", "")); err == nil && n > 0 {
+		MPSThreadPercentPerUnit = n
+	}
+	if n, err := strconv.Atoi(getEnv("MPS_THREAD_PERCENT_FLOOR", "")); err == nil && n > 0 && n <= 100 {
+		MPSThreadPercentFloor = n
+	}
+	if n, err := strconv.Atoi(getEnv("GPU_NODE_VRAM_MB", "")); err == nil && n > 0 {
+		GPUNodeVRAMMB = n
+	}
+	if n, err := strconv.Atoi(getEnv("MPS_MAX_SHARED_PODS", "")); err == nil && n > 0 {
+		MPSMaxSharedPods = n
+	}
+	LogLevel = getEnv("LOG_LEVEL", LogLevel)
+	LogLevels = getEnv("LOG_LEVELS", LogLevels)
+	if n, err := strconv.Atoi(getEnv("WATCH_BUFFER_SIZE", "")); err == nil && n > 0 {
+		WatchBufferSize = n
+	}
+	if d, err := time.ParseDuration(getEnv("WATCH_STATS_INTERVAL", "")); err == nil && d > 0 {
+		WatchStatsInterval = d
+	}
+	if n, err := strconv.Atoi(getEnv("WATCH_LIST_PAGE_SIZE", "")); err == nil && n > 0 {
+		WatchListPageSize = n
+	}
+	if d, err := time.ParseDuration(getEnv("WATCH_LIST_BATCH_DELAY", "")); err == nil && d >= 0 {
+		WatchListBatchDelay = d
+	}
+	ArtifactUploaderImage = getEnv("ARTIFACT_UPLOADER_IMAGE", ArtifactUploaderImage)
+	ArtifactSecretName = getEnv("ARTIFACT_SECRET_NAME", ArtifactSecretName)
+	ArtifactMountPath = getEnv("ARTIFACT_MOUNT_PATH", ArtifactMountPath)
+	ArtifactAccessKey = getEnv("ARTIFACT_ACCESS_KEY", MinioAccessKey)
+	ArtifactSecretKey = getEnv("ARTIFACT_SECRET_KEY", MinioSecretKey)
+	if d, err := time.ParseDuration(getEnv("ARTIFACT_URL_EXPIRY", "")); err == nil && d > 0 {
+		ArtifactURLExpiry = d
+	}
+	if d, err := time.ParseDuration(getEnv("TERMINAL_SHARE_TOKEN_TTL", "")); err == nil {
+		TerminalShareTokenTTL = d
+	}
+
+	if d, err := time.ParseDuration(getEnv("MAINTENANCE_CACHE_TTL", "")); err == nil && d >= 0 {
+		MaintenanceCacheTTL = d
+	}
+
+	// Project usage history
+	if d, err := time.ParseDuration(getEnv("USAGE_SAMPLE_INTERVAL", "")); err == nil && d > 0 {
+		UsageSampleInterval = d
+	}
+	if d, err := time.ParseDuration(getEnv("USAGE_RETENTION", "")); err == nil && d >= time.Hour {
+		UsageRetention = d
+	}
+	if n, err := strconv.Atoi(getEnv("USAGE_HISTORY_POINTS", "")); err == nil && n > 0 {
+		UsageHistoryPoints = n
+	}
+
+	// Image Pull Jobs
+	ImagePullNamespace = getEnv("IMAGE_PULL_NAMESPACE", ImagePullNamespace)
+	if d, err := time.ParseDuration(getEnv("IMAGE_USAGE_SCAN_INTERVAL", "")); err == nil && d > 0 {
+		ImageUsageScanInterval = d
+	}
+	if d, err := time.ParseDuration(getEnv("IMAGE_IN_USE_WINDOW", "")); err == nil {
+		ImageInUseWindow = d
+	}
+	if d, err := time.ParseDuration(getEnv("IMAGE_PULL_JANITOR_INTERVAL", "")); err == nil && d > 0 {
+		ImagePullJanitorInterval = d
+	}
+	if d, err := time.ParseDuration(getEnv("IMAGE_PULL_STALE_AFTER", "")); err == nil && d > 0 {
+		ImagePullStaleAfter = d
+	}
+	if n, err := strconv.Atoi(getEnv("IMAGE_PULL_FAILED_PER_IMAGE", "")); err == nil && n > 0 {
+		ImagePullFailedPerImage = n
+	}
+	if n, err := strconv.Atoi(getEnv("IMAGE_PULL_MAX_CONCURRENT", "")); err == nil {
+		ImagePullMaxConcurrent = n
+	}
+	ImagePullCPURequest = getEnv("IMAGE_PULL_CPU_REQUEST", ImagePullCPURequest)
+	ImagePullCPULimit = getEnv("IMAGE_PULL_CPU_LIMIT", ImagePullCPULimit)
+	ImagePullMemoryRequest = getEnv("IMAGE_PULL_MEMORY_REQUEST", ImagePullMemoryRequest)
+	ImagePullMemoryLimit = getEnv("IMAGE_PULL_MEMORY_LIMIT", ImagePullMemoryLimit)
+	if v, err := strconv.ParseBool(getEnv("IMAGE_PRELOAD_ENABLED", "")); err == nil {
+		ImagePreloadEnabled = v
+	}
+	if v := getEnv("IMAGE_PRELOAD_NODE_SELECTOR", ""); v != "" {
+		// Comma separated key=value pairs; "-" targets every node
+		selector := map[string]string{}
+		for _, pair := range strings.Split(v, ",") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && key != "" {
+				selector[key] = value
+			}
+		}
+		ImagePreloadNodeSelector = selector
+	}
+	if d, err := time.ParseDuration(getEnv("IMAGE_PRELOAD_TIMEOUT", "")); err == nil && d > 0 {
+		ImagePreloadTimeout = d
+	}
+	ImagePreloadPauseImage = getEnv("IMAGE_PRELOAD_PAUSE_IMAGE", ImagePreloadPauseImage)
+
+	FileBrowserCPURequest = getEnv("FILEBROWSER_CPU_REQUEST", FileBrowserCPURequest)
+	FileBrowserCPULimit = getEnv("FILEBROWSER_CPU_LIMIT", FileBrowserCPULimit)
+	FileBrowserMemoryRequest = getEnv("FILEBROWSER_MEMORY_REQUEST", FileBrowserMemoryRequest)
+	FileBrowserMemoryLimit = getEnv("FILEBROWSER_MEMORY_LIMIT", FileBrowserMemoryLimit)
+	StorageHubCPURequest = getEnv("STORAGE_HUB_CPU_REQUEST", StorageHubCPURequest)
+	StorageHubCPULimit = getEnv("STORAGE_HUB_CPU_LIMIT", StorageHubCPULimit)
+	StorageHubMemoryRequest = getEnv("STORAGE_HUB_MEMORY_REQUEST", StorageHubMemoryRequest)
+	StorageHubMemoryLimit = getEnv("STORAGE_HUB_MEMORY_LIMIT", StorageHubMemoryLimit)
+
+	if n, err := strconv.Atoi(getEnv("CONFIG_DATA_MAX_BYTES", "")); err == nil && n > 0 {
+		ConfigDataMaxBytes = n
+	}
+	if n, err := strconv.Atoi(getEnv("CONFIGMAP_MAX_PER_FILE", "")); err == nil && n > 0 {
+		ConfigMapMaxPerFile = n
+	}
+	if n, err := strconv.Atoi(getEnv("CONFIGMAP_MAX_PER_NAMESPACE", "")); err == nil && n > 0 {
+		ConfigMapMaxPerNamespace = n
+	}
+
+	if n, err := strconv.Atoi(getEnv("JOB_LOG_MAX_BYTES", "")); err == nil {
+		JobLogMaxBytes = n
+	}
+
+	VolumeSnapshotClassName = getEnv("VOLUME_SNAPSHOT_CLASS_NAME", VolumeSnapshotClassName)
+	if n, err := strconv.Atoi(getEnv("PROJECT_SNAPSHOT_MAX", "")); err == nil && n > 0 {
+		ProjectSnapshotMax = n
+	}
+
+	// Retention
+	if n, err := strconv.Atoi(getEnv("AUDIT_LOG_RETENTION_DAYS", "")); err == nil {
+		AuditLogRetentionDays = n
+	}
+	if n, err := strconv.Atoi(getEnv("JOB_LOG_RETENTION_DAYS", "")); err == nil {
+		JobLogRetentionDays = n
+	}
+	if n, err := strconv.Atoi(getEnv("USER_ACTIVITY_RETENTION_DAYS", "")); err == nil {
+		UserActivityRetentionDays = n
+	}
+	if n, err := strconv.Atoi(getEnv("RETENTION_BATCH_SIZE", "")); err == nil && n > 0 {
+		RetentionBatchSize = n
+	}
+	if n, err := strconv.Atoi(getEnv("CONFIG_FILE_TRASH_RETENTION_DAYS", "")); err == nil {
+		ConfigFileTrashRetentionDays = n
+	}
+
+	// Authentication
+	LocalLoginEnabled, _ = strconv.ParseBool(getEnv("LOCAL_LOGIN_ENABLED", "true"))
+	OIDCEnabled, _ = strconv.ParseBool(getEnv("OIDC_ENABLED", "false"))
+	OIDCIssuerURL = getEnv("OIDC_ISSUER_URL", "")
+	OIDCClientID = getEnv("OIDC_CLIENT_ID", "")
+	OIDCClientSecret = getEnv("OIDC_CLIENT_SECRET", "")
+	OIDCRedirectURL = getEnv("OIDC_REDIRECT_URL", "")
+	if scopes := getEnv("OIDC_SCOPES", ""); scopes != "" {
+		OIDCScopes = strings.Split(scopes, ",")
+	}
+	OIDCUsernameClaim = getEnv("OIDC_USERNAME_CLAIM", OIDCUsernameClaim)
+	OIDCEmailClaim = getEnv("OIDC_EMAIL_CLAIM", OIDCEmailClaim)
+	OIDCAutoProvision, _ = strconv.ParseBool(getEnv("OIDC_AUTO_PROVISION", "false"))
+	if n, err := strconv.ParseUint(getEnv("OIDC_DEFAULT_GROUP_ID", ""), 10, 32); err == nil {
+		OIDCDefaultGroupID = uint(n)
+	}
+	OIDCDefaultRole = getEnv("OIDC_DEFAULT_ROLE", OIDCDefaultRole)
+	OIDCPostLoginRedirect = getEnv("OIDC_POST_LOGIN_REDIRECT", "")
+	if d, err := time.ParseDuration(getEnv("IMPERSONATION_TOKEN_TTL", "")); err == nil && d > 0 {
+		ImpersonationTokenTTL = d
+	}
+
+	// Email
+	SMTPHost = getEnv("SMTP_HOST", "")
+	if n, err := strconv.Atoi(getEnv("SMTP_PORT", "")); err == nil && n > 0 {
+		SMTPPort = n
+	}
+	SMTPUsername = getEnv("SMTP_USERNAME", "")
+	SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	SMTPFrom = getEnv("SMTP_FROM", SMTPUsername)
+	PlatformURL = strings.TrimRight(getEnv("PLATFORM_URL", ""), "/")
+	if d, err := time.ParseDuration(getEnv("APPROVAL_DIGEST_INTERVAL", "")); err == nil && d > 0 {
+		ApprovalDigestInterval = d
+	}
+	if d, err := time.ParseDuration(getEnv("APPROVAL_DIGEST_MIN_AGE", "")); err == nil && d >= 0 {
+		ApprovalDigestMinAge = d
+	}
+	if d, err := time.ParseDuration(getEnv("INSTANCE_APPROVAL_TTL", "")); err == nil && d >= time.Hour {
+		InstanceApprovalTTL = d
+	}
+
+	if d, err := time.ParseDuration(getEnv("SETTINGS_REFRESH_INTERVAL", "")); err == nil && d > 0 {
+		SettingsRefreshInterval = d
+	}
+	if v, err := strconv.ParseBool(getEnv("LEGACY_RESPONSE_BODIES", "")); err == nil {
+		LegacyResponseBodies = v
+	}
+	if d, err := time.ParseDuration(getEnv("STORAGE_READY_TIMEOUT", "")); err == nil && d >= 0 {
+		StorageReadyTimeout = d
+	}
+	if d, err := time.ParseDuration(getEnv("FILEBROWSER_DELETE_TIMEOUT", "")); err == nil && d > 0 {
+		FileBrowserDeleteTimeout = d
+	}
+	StorageHubImage = getEnv("STORAGE_HUB_IMAGE", StorageHubImage)
+	SFTPImage = getEnv("SFTP_IMAGE", SFTPImage)
+	SFTPServiceType = getEnv("SFTP_SERVICE_TYPE", SFTPServiceType)
+	if n, err := strconv.Atoi(getEnv("SFTP_PORT_MIN", "")); err == nil && n > 0 {
+		SFTPPortMin = n
+	}
+	if n, err := strconv.Atoi(getEnv("SFTP_PORT_MAX", "")); err == nil && n >= SFTPPortMin {
+		SFTPPortMax = n
+	}
+	SFTPLoadBalancerIP = getEnv("SFTP_LOADBALANCER_IP", SFTPLoadBalancerIP)
+	SFTPPublicHost = getEnv("SFTP_PUBLIC_HOST", SFTPPublicHost)
+	if n, err := strconv.Atoi(getEnv("SSH_KEYS_MAX_PER_USER", "")); err == nil && n > 0 {
+		SSHKeysMaxPerUser = n
+	}
+
+	// Priority Classes
+	for level, name := range PriorityClassNames {
+		key := strings.ToUpper(level)
+		PriorityClassNames[level] = getEnv("PRIORITY_CLASS_"+key, name)
+		if v, err := strconv.ParseInt(getEnv("PRIORITY_VALUE_"+key, ""), 10, 32); err == nil {
+			PriorityClassValues[level] = int32(v)
+		}
+	}
+	// Gang scheduling
+	GangScheduler = getEnv("GANG_SCHEDULER", GangScheduler)
+	GangSchedulerName = getEnv("GANG_SCHEDULER_NAME", GangSchedulerName)
+	if d, err := time.ParseDuration(getEnv("GANG_PLACEMENT_TIMEOUT", "")); err == nil {
+		GangPlacementTimeout = d
+	}
+	if d, err := time.ParseDuration(getEnv("JOB_RECONCILE_GRACE_PERIOD", "")); err == nil {
+		JobReconcileGracePeriod = d
+	}
+	if d, err := time.ParseDuration(getEnv("JOB_MAX_RUNTIME", "")); err == nil && d >= 0 {
+		JobMaxRuntime = d
+	}
+	for role := range RolePriorityLevels {
+		if levels := getEnv("PRIORITY_LEVELS_"+strings.ToUpper(role), ""); levels != "" {
+			RolePriorityLevels[role] = strings.Split(levels, ",")
+		}
+	}
+	for role := range RoleJobTypes {
+		if types := getEnv("JOB_TYPES_"+strings.ToUpper(role), ""); types != "" {
+			RoleJobTypes[role] = strings.Split(types, ",")
+		}
+	}
+	ExternalExecutorURL = strings.TrimRight(getEnv("EXTERNAL_EXECUTOR_URL", ""), "/")
+	ExternalExecutorToken = getEnv("EXTERNAL_EXECUTOR_TOKEN", "")
+	if d, err := time.ParseDuration(getEnv("EXTERNAL_JOB_POLL_INTERVAL", "")); err == nil && d > 0 {
+		ExternalJobPollInterval = d
+	}
+}
+
+// FileBrowserBasePath is the base URL a FileBrowser pod is started with for the proxy route
+// path. FileBrowser links to its base URL, so it stays on the legacy path while that is served.
+func FileBrowserBasePath(path string) string {
+	if LegacyRoutesEnabled {
+		return path
+	}
+	return APIV1Prefix + path
+}
+
+// FileBrowserUpstreamPath maps a proxied request path to the path under FileBrowserBasePath.
+func FileBrowserUpstreamPath(path string) string {
+	if LegacyRoutesEnabled {
+		return strings.TrimPrefix(path, APIV1Prefix)
+	}
+	return path
 }
 
 func getEnv(key, fallback string) string {
@@ -26,6 +26,30 @@ func createEnums() {
 	}
 }
 
+// CreateSearchIndexes adds the trigram indexes serving the case-insensitive substring search
+// of repository.SearchRepo, and dates config files saved before they had an update time.
+// Without the pg_trgm extension the search still works, through sequential scans.
+func CreateSearchIndexes() {
+	statements := []string{
+		`UPDATE config_files SET update_at = create_at WHERE update_at IS NULL`,
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_project_list_name_trgm ON project_list USING gin (lower(project_name) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_project_list_description_trgm ON project_list USING gin (lower(description) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_config_files_filename_trgm ON config_files USING gin (lower(filename) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_config_files_content_trgm ON config_files USING gin (lower(content) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_jobs_name_trgm ON jobs USING gin (lower(name) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_jobs_image_trgm ON jobs USING gin (lower(image) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_container_repositories_full_name_trgm ON container_repositories USING gin (lower(full_name) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_container_tags_name_trgm ON container_tags USING gin (lower(name) gin_trgm_ops)`,
+	}
+
+	for _, stmt := range statements {
+		if err := DB.Exec(stmt).Error; err != nil {
+			log.Printf("Failed to create search index: %s, error: %v", stmt, err)
+		}
+	}
+}
+
 func Init() {
 	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var ErrInvalidSetting = errors.New("invalid setting value")
+
+// SettingType is how the value of a runtime setting is written and checked.
+type SettingType string
+
+const (
+	SettingTypeInt      SettingType = "int"
+	SettingTypeDuration SettingType = "duration" // Go duration, e.g. "10m"
+	SettingTypeQuantity SettingType = "quantity" // Kubernetes quantity, e.g. "10Gi"
+)
+
+// Keys of the settings admins can change at runtime
+const (
+	SettingPortForwardIdleTimeout       = "port_forward_idle_timeout"
+	SettingPortForwardMaxTunnelsPerUser = "port_forward_max_tunnels_per_user"
+	SettingTerminalShareTokenTTL        = "terminal_share_token_ttl"
+	SettingAuditLogRetentionDays        = "audit_log_retention_days"
+	SettingJobLogRetentionDays          = "job_log_retention_days"
+	SettingConfigFileTrashRetentionDays = "config_file_trash_retention_days"
+	SettingUserPVSize                   = "user_pv_size"
+	SettingProjectPVSize                = "project_pv_size"
+	SettingImagePullMaxConcurrent       = "image_pull_max_concurrent"
+	SettingProjectSnapshotMax           = "project_snapshot_max"
+	SettingApprovalDigestMinAge         = "approval_digest_min_age"
+	SettingInstanceApprovalTTL          = "instance_approval_ttl"
+)
+
+// SettingDef describes a tunable setting. Its value comes from the settings table when an admin
+// stored one, and from the environment otherwise; infrastructure settings such as the database
+// DSN or the JWT secret are environment only and never listed here.
+type SettingDef struct {
+	Key         string
+	Type        SettingType
+	Description string
+
+	min         int64 // lowest accepted int, or duration in nanoseconds
+	intVar      *int
+	durationVar *time.Duration
+	stringVar   *string
+}
+
+var settingDefs = map[string]SettingDef{
+	SettingPortForwardIdleTimeout: {Key: SettingPortForwardIdleTimeout, Type: SettingTypeDuration,
+		Description: "Idle time after which a port forward tunnel is closed (0 never closes it)", durationVar: &PortForwardIdleTimeout},
+	SettingPortForwardMaxTunnelsPerUser: {Key: SettingPortForwardMaxTunnelsPerUser, Type: SettingTypeInt,
+		Description: "Port forward tunnels a user may have open at once", min: 1, intVar: &PortForwardMaxTunnelsPerUser},
+	SettingTerminalShareTokenTTL: {Key: SettingTerminalShareTokenTTL, Type: SettingTypeDuration,
+		Description: "Lifetime of terminal share links", min: int64(time.Second), durationVar: &TerminalShareTokenTTL},
+	SettingAuditLogRetentionDays: {Key: SettingAuditLogRetentionDays, Type: SettingTypeInt,
+		Description: "Days audit logs are kept (0 keeps them forever)", intVar: &AuditLogRetentionDays},
+	SettingJobLogRetentionDays: {Key: SettingJobLogRetentionDays, Type: SettingTypeInt,
+		Description: "Days job logs are kept (0 keeps them forever)", intVar: &JobLogRetentionDays},
+	SettingConfigFileTrashRetentionDays: {Key: SettingConfigFileTrashRetentionDays, Type: SettingTypeInt,
+		Description: "Days a deleted config file stays in the trash (0 never purges)", intVar: &ConfigFileTrashRetentionDays},
+	SettingUserPVSize: {Key: SettingUserPVSize, Type: SettingTypeQuantity,
+		Description: "Default size of a personal hub volume", stringVar: &UserPVSize},
+	SettingProjectPVSize: {Key: SettingProjectPVSize, Type: SettingTypeQuantity,
+		Description: "Default size of a project storage", stringVar: &ProjectPVSize},
+	SettingImagePullMaxConcurrent: {Key: SettingImagePullMaxConcurrent, Type: SettingTypeInt,
+		Description: "Image pull jobs running at once; further pulls are queued", min: 1, intVar: &ImagePullMaxConcurrent},
+	SettingProjectSnapshotMax: {Key: SettingProjectSnapshotMax, Type: SettingTypeInt,
+		Description: "Storage snapshots kept per project", min: 1, intVar: &ProjectSnapshotMax},
+	SettingApprovalDigestMinAge: {Key: SettingApprovalDigestMinAge, Type: SettingTypeDuration,
+		Description: "How long a request waits before it is listed in the approval digest", durationVar: &ApprovalDigestMinAge},
+	SettingInstanceApprovalTTL: {Key: SettingInstanceApprovalTTL, Type: SettingTypeDuration,
+		Description: "How long an instance waits for a project manager's approval before it expires", min: int64(time.Hour), durationVar: &InstanceApprovalTTL},
+}
+
+// SettingDefs lists the runtime settings sorted by key.
+func SettingDefs() []SettingDef {
+	defs := make([]SettingDef, 0, len(settingDefs))
+	for _, d := range settingDefs {
+		defs = append(defs, d)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Key < defs[j].Key })
+	return defs
+}
+
+func LookupSetting(key string) (SettingDef, bool) {
+	d, ok := settingDefs[key]
+	return d, ok
+}
+
+// Parse checks raw against the setting's type and bounds and returns the typed value:
+// an int, a time.Duration, or the canonical form of a quantity.
+func (d SettingDef) Parse(raw string) (any, error) {
+	switch d.Type {
+	case SettingTypeInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be an integer", ErrInvalidSetting, d.Key)
+		}
+		if int64(n) < d.min {
+			return nil, fmt.Errorf("%w: %s must be at least %d", ErrInvalidSetting, d.Key, d.min)
+		}
+		return n, nil
+	case SettingTypeDuration:
+		v, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a duration such as 10m", ErrInvalidSetting, d.Key)
+		}
+		if v < time.Duration(d.min) {
+			return nil, fmt.Errorf("%w: %s must be at least %s", ErrInvalidSetting, d.Key, time.Duration(d.min))
+		}
+		return v, nil
+	case SettingTypeQuantity:
+		q, err := resource.ParseQuantity(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a quantity such as 10Gi", ErrInvalidSetting, d.Key)
+		}
+		if q.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: %s must be positive", ErrInvalidSetting, d.Key)
+		}
+		return q.String(), nil
+	}
+	return nil, fmt.Errorf("%w: %s has unknown type %q", ErrInvalidSetting, d.Key, d.Type)
+}
+
+// EnvValue is the value loaded from the environment, used while no override is stored.
+func (d SettingDef) EnvValue() string {
+	switch {
+	case d.intVar != nil:
+		return strconv.Itoa(*d.intVar)
+	case d.durationVar != nil:
+		return d.durationVar.String()
+	case d.stringVar != nil:
+		return *d.stringVar
+	}
+	return ""
+}
+
+var (
+	settingsMu       sync.RWMutex
+	settingOverrides = map[string]any{}
+)
+
+// SetSettingOverrides replaces the stored values, keyed by setting and already parsed.
+func SetSettingOverrides(values map[string]any) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	settingOverrides = values
+}
+
+func settingOverride(key string) (any, bool) {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	v, ok := settingOverrides[key]
+	return v, ok
+}
+
+// IntSetting returns the current value of an int setting.
+func IntSetting(key string) int {
+	if v, ok := settingOverride(key); ok {
+		if n, ok := v.(int); ok {
+			return n
+		}
+	}
+	return *settingDefs[key].intVar
+}
+
+// DurationSetting returns the current value of a duration setting.
+func DurationSetting(key string) time.Duration {
+	if v, ok := settingOverride(key); ok {
+		if d, ok := v.(time.Duration); ok {
+			return d
+		}
+	}
+	return *settingDefs[key].durationVar
+}
+
+// StringSetting returns the current value of a quantity setting.
+func StringSetting(key string) string {
+	if v, ok := settingOverride(key); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return *settingDefs[key].stringVar
+}
@@ -1,31 +1,159 @@
 package cron
 
 import (
+	"context"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/linskybing/platform-go/internal/application"
+	"github.com/linskybing/platform-go/internal/config"
 )
 
-func StartCleanupTask(auditService *application.AuditService) {
+// StartSettingsRefresh loads the runtime settings admins stored, then reloads them every
+// config.SettingsRefreshInterval so changes made through another API process apply here too.
+func StartSettingsRefresh(settingsService *application.SettingsService) {
+	settingsService.OnChange(func(keys []string) {
+		log.Printf("Runtime settings changed: %s", strings.Join(keys, ", "))
+	})
+	if err := settingsService.Refresh(); err != nil {
+		log.Printf("Failed to load runtime settings, using environment values: %v", err)
+	}
 	go func() {
-		log.Println("Starting background cleanup task (retention: 30 days)")
+		ticker := time.NewTicker(config.SettingsRefreshInterval)
+		defer ticker.Stop()
 
-		// Run immediately on startup
-		if err := auditService.CleanupOldLogs(30); err != nil {
-			log.Printf("Failed to cleanup old audit logs: %v", err)
+		for range ticker.C {
+			if err := settingsService.Refresh(); err != nil {
+				log.Printf("Failed to refresh runtime settings: %v", err)
+			}
 		}
+	}()
+}
+
+// StartCleanupTask prunes audit and job logs past their configured retention once a day.
+func StartCleanupTask(auditService *application.AuditService) {
+	go func() {
+		log.Println("Starting background retention task")
 
-		// Then run every 24 hours
+		// Run immediately on startup, then every 24 hours
 		ticker := time.NewTicker(24 * time.Hour)
 		defer ticker.Stop()
 
+		for {
+			if _, err := auditService.PruneExpired(false); err != nil {
+				log.Printf("Failed to prune expired logs: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StartConfigFileTrashPurge permanently deletes config files whose trash retention has expired.
+func StartConfigFileTrashPurge(configFileService *application.ConfigFileService) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			if n, err := configFileService.PurgeExpiredConfigFiles(); err != nil {
+				log.Printf("Failed to purge trashed config files: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d trashed config files", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StartInstanceApprovalExpiry expires the instance approval requests no project manager decided on in time.
+func StartInstanceApprovalExpiry(configFileService *application.ConfigFileService) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			if n, err := configFileService.ExpireInstanceApprovals(); err != nil {
+				log.Printf("Failed to expire instance approvals: %v", err)
+			} else if n > 0 {
+				log.Printf("Expired %d instance approval requests", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StartImageUsageScan records which Harbor images running pods use, so stale mirrors can be found.
+func StartImageUsageScan(imageService *application.ImageService) {
+	go func() {
+		ticker := time.NewTicker(config.ImageUsageScanInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := imageService.ScanImageUsage(context.Background()); err != nil {
+				log.Printf("Failed to scan image usage: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StartImagePullJanitor settles tracked image pulls whose monitor stopped reporting.
+func StartImagePullJanitor(imageService *application.ImageService) {
+	go func() {
+		ticker := time.NewTicker(config.ImagePullJanitorInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := imageService.SweepPullJobs(context.Background()); err != nil {
+				log.Printf("Failed to sweep image pulls: %v", err)
+			}
+		}
+	}()
+}
+
+// StartUsageSampler records the resources requested in project namespaces for their usage
+// history.
+func StartUsageSampler(projectService *application.ProjectService) {
+	go func() {
+		ticker := time.NewTicker(config.UsageSampleInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := projectService.SampleUsage(context.Background()); err != nil {
+				log.Printf("Failed to sample project usage: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StartApprovalDigest emails admins the requests still waiting for approval. The first digest
+// goes out one interval after startup so restarts do not resend it.
+func StartApprovalDigest(notifier *application.ApprovalNotifier) {
+	go func() {
+		ticker := time.NewTicker(config.ApprovalDigestInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if n, err := notifier.SendDigest(context.Background()); err != nil {
+				log.Printf("Failed to send the approval digest: %v", err)
+			} else if n > 0 {
+				log.Printf("Sent the approval digest to %d admins", n)
+			}
+		}
+	}()
+}
+
+// StartUserHubBindingSweep retries user hub bindings that failed because the hub was not ready yet.
+func StartUserHubBindingSweep(userGroupService *application.UserGroupService) {
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+
 		for range ticker.C {
-			log.Println("Running scheduled audit log cleanup...")
-			if err := auditService.CleanupOldLogs(30); err != nil {
-				log.Printf("Failed to cleanup old audit logs: %v", err)
-			} else {
-				log.Println("Audit log cleanup completed successfully")
+			if err := userGroupService.ReconcileUserHubBindings(); err != nil {
+				log.Printf("Failed to reconcile user hub bindings: %v", err)
 			}
 		}
 	}()
@@ -2,6 +2,7 @@ package cron
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	"github.com/linskybing/platform-go/pkg/k8s"
@@ -76,8 +77,7 @@ func CreateDockerCleanupCronJob() error {
 		// CronJob already exists, update it
 		_, err = k8s.Clientset.BatchV1().CronJobs("default").Update(context.TODO(), cronJob, metav1.UpdateOptions{})
 		if err != nil {
-			log.Printf("Failed to update Docker cleanup CronJob: %v", err)
-			return err
+			return fmt.Errorf("failed to update Docker cleanup CronJob: %w", err)
 		}
 		log.Println("Updated Docker cleanup CronJob successfully")
 		return nil
@@ -86,8 +86,7 @@ func CreateDockerCleanupCronJob() error {
 	// Create new CronJob
 	_, err = k8s.Clientset.BatchV1().CronJobs("default").Create(context.TODO(), cronJob, metav1.CreateOptions{})
 	if err != nil {
-		log.Printf("Failed to create Docker cleanup CronJob: %v", err)
-		return err
+		return fmt.Errorf("failed to create Docker cleanup CronJob: %w", err)
 	}
 
 	log.Println("Created Docker cleanup CronJob successfully")
@@ -98,8 +97,7 @@ func CreateDockerCleanupCronJob() error {
 func DeleteDockerCleanupCronJob() error {
 	err := k8s.Clientset.BatchV1().CronJobs("default").Delete(context.TODO(), "docker-image-cleanup", metav1.DeleteOptions{})
 	if err != nil {
-		log.Printf("Failed to delete Docker cleanup CronJob: %v", err)
-		return err
+		return fmt.Errorf("failed to delete Docker cleanup CronJob: %w", err)
 	}
 
 	log.Println("Deleted Docker cleanup CronJob successfully")
@@ -0,0 +1,19 @@
+package activity
+
+import "time"
+
+// Item is a recent or starred entity with the name it has now.
+type Item struct {
+	EntityType string    `json:"entity_type"`
+	EntityID   uint      `json:"entity_id"`
+	Name       string    `json:"name"`
+	ProjectID  uint      `json:"project_id,omitempty"`
+	Action     string    `json:"action,omitempty"` // last action, for recent items
+	At         time.Time `json:"at"`               // last use, or when it was starred
+}
+
+// FavoriteInput names the entity to star.
+type FavoriteInput struct {
+	EntityType string `json:"entity_type" binding:"required"`
+	EntityID   uint   `json:"entity_id" binding:"required"`
+}
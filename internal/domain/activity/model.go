@@ -0,0 +1,54 @@
+package activity
+
+import "time"
+
+// Kinds of entity the dashboard lists as recent or starred
+const (
+	EntityProject    = "project"
+	EntityConfigFile = "configfile"
+	EntityJob        = "job"
+)
+
+// Actions recorded for an entity
+const (
+	ActionView   = "view"
+	ActionCreate = "create"
+)
+
+// UserActivity is one visit or creation of an entity by a user, written in the background by
+// the activity middleware and pruned after config.UserActivityRetentionDays.
+type UserActivity struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;index:idx_user_activity_user_entity" json:"user_id"`
+	EntityType string    `gorm:"size:20;not null;index:idx_user_activity_user_entity" json:"entity_type"`
+	EntityID   uint      `gorm:"not null;index:idx_user_activity_user_entity" json:"entity_id"`
+	Action     string    `gorm:"size:20;not null" json:"action"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the database table name
+func (UserActivity) TableName() string {
+	return "user_activities"
+}
+
+// Favorite is an entity a user starred.
+type Favorite struct {
+	UserID     uint      `gorm:"primaryKey" json:"user_id"`
+	EntityType string    `gorm:"primaryKey;size:20" json:"entity_type"`
+	EntityID   uint      `gorm:"primaryKey" json:"entity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the database table name
+func (Favorite) TableName() string {
+	return "user_favorites"
+}
+
+// ValidEntityType reports whether t is one of the Entity* kinds.
+func ValidEntityType(t string) bool {
+	switch t {
+	case EntityProject, EntityConfigFile, EntityJob:
+		return true
+	}
+	return false
+}
@@ -3,6 +3,8 @@ package configfile
 type ConfigFileUpdateDTO struct {
 	Filename *string `form:"filename"`
 	RawYaml  *string `form:"raw_yaml"`
+	// Version the client last read; the update is rejected if the file changed since
+	Version *int `form:"version"`
 }
 
 type CreateConfigFileInput struct {
@@ -16,6 +18,67 @@ func (d CreateConfigFileInput) GetProjectID() uint {
 	return d.ProjectID
 }
 
+type CreateConfigTemplateInput struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	RawYaml     string `json:"raw_yaml" binding:"required"`
+	RequiresGPU bool   `json:"requires_gpu"`
+}
+
+type UpdateConfigTemplateInput struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Category    *string `json:"category"`
+	RawYaml     *string `json:"raw_yaml"`
+	RequiresGPU *bool   `json:"requires_gpu"`
+}
+
+type CreateBootstrapBundleInput struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	RawYaml     string `json:"raw_yaml" binding:"required"`
+}
+
+type UpdateBootstrapBundleInput struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	RawYaml     *string `json:"raw_yaml"`
+}
+
+// InstantiateTemplateInput copies a template into a project. Filename defaults to the
+// template name; Launch also creates the instance right away.
+type InstantiateTemplateInput struct {
+	Filename string `json:"filename"`
+	Launch   bool   `json:"launch"`
+}
+
+type InstantiateTemplateOutput struct {
+	ConfigFile  *ConfigFile `json:"config_file"`
+	Launched    bool        `json:"launched"`
+	LaunchError string      `json:"launch_error,omitempty"`
+	// Set instead of Launched when the project reviews instances before they go live
+	ApprovalRequestID *uint `json:"approval_request_id,omitempty"`
+}
+
+// ConfigFileBundle is the portable form of a project's config files, used to copy them into
+// another project. It carries only filenames and raw YAML: no IDs, versions or resources.
+type ConfigFileBundle struct {
+	// Project is the name of the exported project, for reference only
+	Project string             `json:"project,omitempty"`
+	Files   []ConfigFileExport `json:"files"`
+}
+
+type ConfigFileExport struct {
+	Filename string `json:"filename"`
+	RawYaml  string `json:"raw_yaml"`
+}
+
+// RejectInstanceInput is the note a project manager leaves the requester of a rejected instance.
+type RejectInstanceInput struct {
+	Note string `json:"note"`
+}
+
 type ProjectGetter interface {
 	GetGroupIDByProjectID(projectID uint) uint
 }
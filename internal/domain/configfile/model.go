@@ -1,11 +1,160 @@
 package configfile
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
 
 type ConfigFile struct {
 	CFID      uint      `gorm:"primaryKey;column:cf_id"`
-	Filename  string    `gorm:"size:200;not null"`
+	Filename  string    `gorm:"size:255;not null"`
 	Content   string    `gorm:"size:10000"`
 	ProjectID uint      `gorm:"not null"`
 	CreatedAt time.Time `gorm:"column:create_at"`
+	UpdatedAt time.Time `gorm:"column:update_at"`
+	// Bumped on every update; clients send it back so concurrent edits are detected
+	Version int `gorm:"not null;default:1" json:"version"`
+	// Provenance when the file was instantiated from a ConfigTemplate
+	TemplateID      *uint `gorm:"column:template_id" json:"template_id,omitempty"`
+	TemplateVersion *int  `gorm:"column:template_version" json:"template_version,omitempty"`
+	// Set while the file is in the trash; GORM leaves trashed files out of normal queries
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string"`
+	// Limits an admin upload was allowed to exceed; only set in create/update responses
+	Warnings []string `gorm:"-" json:"warnings,omitempty"`
+	// Objects of the caller's instance edited outside the platform; only set in detail responses
+	Drift *Drift `gorm:"-" json:"drift,omitempty"`
+}
+
+// Drift summarizes the live objects of an instance that were modified outside the platform,
+// for example with kubectl edit, and will not match the config file on the next update.
+type Drift struct {
+	ExternallyModified int `json:"externally_modified"`
+	// Objects are "Kind/name" of the modified objects
+	Objects []string `json:"objects"`
+}
+
+// ConfigTemplate is an admin-managed config file that users copy into their projects.
+// Version is bumped on every content change so instantiated copies can be traced back.
+type ConfigTemplate struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Category    string    `gorm:"size:50;index" json:"category"`
+	Content     string    `gorm:"size:10000" json:"content"`
+	RequiresGPU bool      `gorm:"default:false" json:"requires_gpu"`
+	Version     int       `gorm:"default:1" json:"version"`
+	CreatedAt   time.Time `gorm:"column:create_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:update_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the database table name
+func (ConfigTemplate) TableName() string {
+	return "config_templates"
+}
+
+// BootstrapBundle is an admin-managed set of manifests, such as a NetworkPolicy, a ConfigMap of
+// documentation links or a ServiceAccount, applied to the member namespaces of the projects
+// selecting it. Content takes the namespace placeholders of config files; Version is bumped on
+// every content change so namespaces provisioned earlier get the new content.
+type BootstrapBundle struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Content     string    `gorm:"size:10000" json:"content"`
+	Version     int       `gorm:"default:1" json:"version"`
+	CreatedAt   time.Time `gorm:"column:create_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:update_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the database table name
+func (BootstrapBundle) TableName() string {
+	return "bootstrap_bundles"
+}
+
+// ProjectBootstrapBundle selects a bundle for the member namespaces of a project.
+type ProjectBootstrapBundle struct {
+	ProjectID uint      `gorm:"primaryKey;column:p_id" json:"project_id"`
+	BundleID  uint      `gorm:"primaryKey;column:bundle_id" json:"bundle_id"`
+	CreatedAt time.Time `gorm:"column:create_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the database table name
+func (ProjectBootstrapBundle) TableName() string {
+	return "project_bootstrap_bundles"
+}
+
+// BootstrapObject is an object a bundle created in a namespace, with the manifest and bundle
+// version it was rendered from. Provisioning the namespace again skips objects already at the
+// bundle's version, and removing the bundle from the project can delete them.
+type BootstrapObject struct {
+	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	BundleID      uint           `gorm:"not null;uniqueIndex:idx_bootstrap_object;column:bundle_id" json:"bundle_id"`
+	ProjectID     uint           `gorm:"not null;index;column:p_id" json:"project_id"`
+	Namespace     string         `gorm:"size:63;not null;uniqueIndex:idx_bootstrap_object" json:"namespace"`
+	Kind          string         `gorm:"size:100;not null;uniqueIndex:idx_bootstrap_object" json:"kind"`
+	Name          string         `gorm:"size:253;not null;uniqueIndex:idx_bootstrap_object" json:"name"`
+	Manifest      datatypes.JSON `gorm:"type:jsonb" json:"-"`
+	BundleVersion int            `gorm:"not null;column:bundle_version" json:"bundle_version"`
+	CreatedAt     time.Time      `gorm:"column:create_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the database table name
+func (BootstrapObject) TableName() string {
+	return "bootstrap_objects"
+}
+
+// Statuses of an InstanceApprovalRequest
+const (
+	ApprovalStatusPending  = "pending"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusRejected = "rejected"
+	ApprovalStatusExpired  = "expired"
+)
+
+// InstanceApprovalRequest is an instance of a project requiring review, held until a project
+// manager decides on it. Documents keeps the manifests as rendered for the requester; an
+// approval applies them as stored, so what was reviewed is what runs.
+type InstanceApprovalRequest struct {
+	ID                uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	ConfigFileID      uint           `gorm:"not null;index;column:cf_id" json:"config_file_id"`
+	ProjectID         uint           `gorm:"not null;index:idx_instance_approval_project_status;column:p_id" json:"project_id"`
+	RequesterID       uint           `gorm:"not null;column:requester_id" json:"requester_id"`
+	RequesterUsername string         `gorm:"size:100;not null;column:requester_username" json:"requester_username"`
+	Namespace         string         `gorm:"size:63;not null" json:"namespace"`
+	Documents         datatypes.JSON `gorm:"type:jsonb" json:"documents" swaggertype:"array,object"`
+	// Whether the documents pull from Harbor or the project's private registries, so the
+	// approval provides the pull secrets
+	UsesHarborImage     bool       `gorm:"default:false" json:"-"`
+	UsesProjectRegistry bool       `gorm:"default:false" json:"-"`
+	Status              string     `gorm:"size:20;default:'pending';index:idx_instance_approval_project_status" json:"status"`
+	ReviewerID          *uint      `gorm:"column:reviewer_id" json:"reviewer_id,omitempty"`
+	Note                string     `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt           time.Time  `gorm:"column:create_at;autoCreateTime" json:"created_at"`
+	ExpiresAt           time.Time  `gorm:"column:expires_at;index" json:"expires_at"`
+	DecidedAt           *time.Time `gorm:"column:decided_at" json:"decided_at,omitempty"`
+}
+
+// TableName specifies the database table name
+func (InstanceApprovalRequest) TableName() string {
+	return "instance_approval_requests"
+}
+
+// ApprovalDocument is one rendered manifest of an approval request, with the resource of the
+// config file it was rendered from.
+type ApprovalDocument struct {
+	ResourceID uint            `json:"resource_id"`
+	Resource   string          `json:"resource"`
+	Object     json.RawMessage `json:"object" swaggertype:"object"`
+}
+
+// DecodeDocuments decodes Documents; malformed values yield none.
+func (r *InstanceApprovalRequest) DecodeDocuments() []ApprovalDocument {
+	var docs []ApprovalDocument
+	if len(r.Documents) > 0 {
+		_ = json.Unmarshal(r.Documents, &docs)
+	}
+	return docs
 }
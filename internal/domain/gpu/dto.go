@@ -5,6 +5,7 @@ type CreateGPURequestDTO struct {
 	RequestedQuota      *int    `json:"requested_quota,omitempty"`
 	RequestedAccessType *string `json:"requested_access_type,omitempty"`
 	Reason              string  `json:"reason" binding:"required"`
+	Urgent              bool    `json:"urgent"`
 }
 
 type UpdateGPURequestStatusDTO struct {
@@ -26,6 +26,7 @@ type GPURequest struct {
 	RequestedAccessType string           `gorm:"size:20;column:requested_access_type"`
 	Reason              string           `gorm:"type:text;column:reason"`
 	Status              GPURequestStatus `gorm:"default:'pending';type:varchar(20);column:status"`
+	Urgent              bool             `gorm:"default:false;column:urgent"` // Admins are emailed at once instead of in the daily digest
 	CreatedAt           time.Time        `gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt           time.Time        `gorm:"column:updated_at;autoUpdateTime"`
 }
@@ -1,8 +1,13 @@
 package group
 
+import "github.com/linskybing/platform-go/internal/domain/audit"
+
 type GroupUpdateDTO struct {
-	GroupName   *string `json:"group_name" form:"group_name"`
-	Description *string `json:"description" form:"description"`
+	GroupName          *string `json:"group_name" form:"group_name"`
+	Description        *string `json:"description" form:"description"`
+	HubStorageSize     *string `json:"hub_storage_size" form:"hub_storage_size"`
+	StorageClassName   *string `json:"storage_class_name" form:"storage_class_name"`
+	ProjectStorageSize *string `json:"project_storage_size" form:"project_storage_size"`
 }
 
 type GroupCreateDTO struct {
@@ -28,3 +33,36 @@ func (d UserGroupInputDTO) GetGID() uint {
 func (d UserGroupDeleteDTO) GetGID() uint {
 	return d.GID
 }
+
+// DashboardJobCounts groups job statuses into the buckets shown on dashboards. Pending covers
+// pending, queued and scheduling jobs; failed includes jobs whose dependencies failed.
+type DashboardJobCounts struct {
+	Running int64 `json:"running"`
+	Pending int64 `json:"pending"`
+	Failed  int64 `json:"failed"`
+}
+
+// ProjectDashboardDTO is one project's share of a group dashboard.
+type ProjectDashboardDTO struct {
+	ProjectID     uint               `json:"project_id"`
+	ProjectName   string             `json:"project_name"`
+	Jobs          DashboardJobCounts `json:"jobs"`
+	GPUUnitsInUse int64              `json:"gpu_units_in_use"`
+	GPUQuota      int                `json:"gpu_quota"`
+	StorageBytes  int64              `json:"storage_bytes"`
+}
+
+// GroupDashboardDTO aggregates jobs, GPU and storage over all projects owned by a group.
+// GPU figures are in the units of project GPU quotas. RecentEvents holds the five latest audit
+// events on the group and its projects, newest first.
+type GroupDashboardDTO struct {
+	GroupID       uint                  `json:"group_id"`
+	GroupName     string                `json:"group_name"`
+	Jobs          DashboardJobCounts    `json:"jobs"`
+	GPUUnitsInUse int64                 `json:"gpu_units_in_use"`
+	GPUQuota      int                   `json:"gpu_quota"`
+	StorageBytes  int64                 `json:"storage_bytes"`
+	StorageCount  int                   `json:"storage_count"`
+	Projects      []ProjectDashboardDTO `json:"projects"`
+	RecentEvents  []audit.AuditLog      `json:"recent_events"`
+}
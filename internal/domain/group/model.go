@@ -3,11 +3,14 @@ package group
 import "time"
 
 type Group struct {
-	GID         uint      `gorm:"primaryKey;column:g_id"`
-	GroupName   string    `gorm:"size:100;not null"`
-	Description string    `gorm:"type:text"`
-	CreatedAt   time.Time `gorm:"column:create_at"`
-	UpdatedAt   time.Time `gorm:"column:update_at"`
+	GID                uint      `gorm:"primaryKey;column:g_id"`
+	GroupName          string    `gorm:"size:100;not null"`
+	Description        string    `gorm:"type:text"`
+	HubStorageSize     string    `gorm:"size:20"`  // Personal hub size for members, e.g. "500Gi"; empty uses config.UserPVSize
+	StorageClassName   string    `gorm:"size:100"` // Storage class for hubs and project storages; empty uses the platform default
+	ProjectStorageSize string    `gorm:"size:20"`  // Default capacity of new project storages; empty uses config.ProjectPVSize
+	CreatedAt          time.Time `gorm:"column:create_at"`
+	UpdatedAt          time.Time `gorm:"column:update_at"`
 }
 
 func (Group) TableName() string {
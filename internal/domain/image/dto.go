@@ -1,5 +1,7 @@
 package image
 
+import "time"
+
 type CreateImageRequestDTO struct {
 	Registry  string `json:"registry"`
 	ImageName string `json:"image_name" binding:"required"`
@@ -22,3 +24,14 @@ type AllowedImageDTO struct {
 	IsGlobal  bool   `json:"is_global"`
 	IsPulled  bool   `json:"is_pulled"`
 }
+
+// ImageUsageDTO reports how an allow-listed image is used by running workloads. Rules without
+// a tag cover the whole repository, so their usage is summed over its tags.
+type ImageUsageDTO struct {
+	ImageName         string     `json:"image_name"`
+	Tag               string     `json:"tag"`
+	IsPulled          bool       `json:"is_pulled"`
+	LastSeenRunningAt *time.Time `json:"last_seen_running_at"`
+	RunningPods       int        `json:"running_pods"`
+	RuleIDs           []uint     `json:"rule_ids"`
+}
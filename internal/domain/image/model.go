@@ -22,6 +22,10 @@ type ContainerTag struct {
 	Digest       string `gorm:"size:255"`
 	Size         int64
 	PushedAt     *time.Time
+	// Last time the image scanner saw a running pod using this tag
+	LastSeenRunningAt *time.Time `gorm:"index"`
+	// Whether a completed pull warms the node caches; nil follows config.ImagePreloadEnabled
+	Preload *bool
 }
 
 type ImageAllowList struct {
@@ -47,6 +51,12 @@ type ImageRequest struct {
 	ReviewerID     *uint
 	ReviewedAt     *time.Time
 	ReviewerNote   string
+	// Result of the last upstream registry lookup, see ImageService.VerifyRequest
+	VerifyStatus   string `gorm:"size:32"`
+	VerifiedDigest string `gorm:"size:255"`
+	VerifiedSize   int64
+	VerifyError    string
+	VerifiedAt     *time.Time
 }
 
 type ClusterImageStatus struct {
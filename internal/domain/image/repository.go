@@ -1,21 +1,32 @@
 package image
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"github.com/linskybing/platform-go/pkg/pagination"
+	"gorm.io/gorm"
+)
 
 type Repository interface {
 	FindOrCreateRepository(repo *ContainerRepository) error
 	FindOrCreateTag(tag *ContainerTag) error
 	GetTagByDigest(repoID uint, digest string) (*ContainerTag, error)
+	FindRepositoryByFullName(fullName string) (*ContainerRepository, error)
+	ListTagsByRepository(repoID uint) ([]ContainerTag, error)
+	MarkTagSeenRunning(tagID uint, at time.Time) error
+	SetTagPreload(tagID uint, preload *bool) error
 
 	CreateRequest(req *ImageRequest) error
 	FindRequestByID(id uint) (*ImageRequest, error)
 	ListRequests(projectID *uint, status string) ([]ImageRequest, error)
+	ListRequestsPage(projectID *uint, status string, p pagination.Params) (*pagination.Page[ImageRequest], error)
 	UpdateRequest(req *ImageRequest) error
 
 	CreateAllowListRule(rule *ImageAllowList) error
 	ListAllowedImages(projectID *uint) ([]ImageAllowList, error)
 	FindAllowListRule(projectID *uint, repoFullName, tagName string) (*ImageAllowList, error)
 	CheckImageAllowed(projectID *uint, repoFullName string, tagName string) (bool, error)
+	GetAllowListRule(id uint) (*ImageAllowList, error)
 	DisableAllowListRule(id uint) error
 
 	UpdateClusterStatus(status *ClusterImageStatus) error
@@ -0,0 +1,44 @@
+package job
+
+import "strings"
+
+// DependencyState summarizes whether a job's run-after dependencies allow it to start.
+type DependencyState int
+
+const (
+	DependenciesMet     DependencyState = iota // All dependencies completed (or failed with run-anyway set)
+	DependenciesPending                        // At least one dependency has not finished yet
+	DependenciesFailed                         // A dependency failed and the job must not run
+)
+
+// IsDependencyFailure reports whether a dependency in this status can no longer succeed.
+func IsDependencyFailure(status string) bool {
+	switch JobStatus(strings.ToLower(status)) {
+	case StatusFailed, StatusCancelled, StatusDependencyFailed, StatusLostFromCluster, StatusTimedOut, StatusInterrupted:
+		return true
+	}
+	return false
+}
+
+// EvaluateDependencies decides the state of a job from the statuses of its dependencies.
+// Without runOnFailure a single failure is final even while other dependencies still run;
+// with it, the job waits until every dependency has finished either way.
+func EvaluateDependencies(statuses []string, runOnFailure bool) DependencyState {
+	pending, failed := false, false
+	for _, st := range statuses {
+		switch {
+		case JobStatus(strings.ToLower(st)) == StatusCompleted:
+		case IsDependencyFailure(st):
+			failed = true
+		default:
+			pending = true
+		}
+	}
+	if failed && !runOnFailure {
+		return DependenciesFailed
+	}
+	if pending {
+		return DependenciesPending
+	}
+	return DependenciesMet
+}
@@ -4,37 +4,76 @@ import "time"
 
 // CreateJobDTO represents a job creation request
 type CreateJobDTO struct {
-	Name        string       `json:"name" binding:"required"`
-	Namespace   string       `json:"namespace" binding:"required"`
-	Image       string       `json:"image" binding:"required"`
-	Command     []string     `json:"command"`
-	Args        []string     `json:"args"`
-	Volumes     []VolumeSpec `json:"volumes"`
-	GPUCount    int          `json:"gpu_count"`
-	GPUType     string       `json:"gpu_type"`
-	CPURequest  string       `json:"cpu_request"`
-	Memory      string       `json:"memory"`
-	Priority    string       `json:"priority"`
-	Parallelism int32        `json:"parallelism"`
-	Completions int32        `json:"completions"`
+	Name        string            `json:"name" binding:"required"`
+	Namespace   string            `json:"namespace" binding:"required"`
+	Image       string            `json:"image" binding:"required"`
+	Command     []string          `json:"command"`
+	Args        []string          `json:"args"`
+	Volumes     []VolumeSpec      `json:"volumes"`
+	GPUCount    int               `json:"gpu_count"`
+	GPUType     string            `json:"gpu_type"`
+	CPURequest  string            `json:"cpu_request"`
+	Memory      string            `json:"memory"`
+	Priority    string            `json:"priority"`
+	Parallelism int32             `json:"parallelism"`
+	Completions int32             `json:"completions"`
+	Env         map[string]string `json:"env"`
 }
 
 // JobSubmission represents a job submission request
 type JobSubmission struct {
-	Name        string       `json:"name"`
-	Namespace   string       `json:"namespace"`
-	Image       string       `json:"image"`
-	Command     []string     `json:"command"`
-	Args        []string     `json:"args"`
-	Volumes     []VolumeSpec `json:"volumes"`
-	GPUCount    int          `json:"gpu_count"`
-	GPU         int          `json:"gpu"`
-	GPUType     string       `json:"gpu_type"`
-	CPURequest  string       `json:"cpu_request"`
-	Memory      string       `json:"memory"`
-	Priority    string       `json:"priority"`
-	Parallelism int32        `json:"parallelism"`
-	Completions int32        `json:"completions"`
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Image       string            `json:"image"`
+	Command     []string          `json:"command"`
+	Args        []string          `json:"args"`
+	Volumes     []VolumeSpec      `json:"volumes"`
+	GPUCount    int               `json:"gpu_count"`
+	GPU         int               `json:"gpu"`
+	GPUType     string            `json:"gpu_type"`
+	CPURequest  string            `json:"cpu_request"`
+	Memory      string            `json:"memory"`
+	Priority    string            `json:"priority"`
+	Parallelism int32             `json:"parallelism"`
+	Completions int32             `json:"completions"`
+	Env         map[string]string `json:"env"`
+	// Run-after dependencies: IDs of the submitter's jobs that must complete first
+	DependsOn              []uint `json:"depends_on"`
+	RunOnDependencyFailure bool   `json:"run_on_dependency_failure"`
+	// Gang starts all Parallelism pods together or none; such jobs are always dispatched by the scheduler
+	Gang bool `json:"gang"`
+	// ArtifactUpload copies matching files to object storage once the main container exits
+	ArtifactUpload *ArtifactUpload `json:"artifact_upload,omitempty"`
+	// MaxRuntime stops the job after it has run this long, as a duration such as "8h". Empty uses
+	// the project limit, which also caps longer requests; "0" is unlimited for admins only.
+	MaxRuntime string `json:"max_runtime,omitempty"`
+	// Type is "normal" (the default) or "external" for a job run on the external cluster; the
+	// types each group role may submit are configured
+	Type string `json:"type,omitempty"`
+	// RestartOnEviction queues the job again when its pod is evicted, e.g. by a node drain
+	RestartOnEviction *bool `json:"restart_on_eviction,omitempty"`
+}
+
+// ArtifactUpload selects the output files of a job to keep. Glob is relative to the shared
+// artifact directory (ARTIFACT_DIR in the job) unless absolute; Prefix is appended to jobs/{id}/.
+type ArtifactUpload struct {
+	Prefix string `json:"prefix"`
+	Glob   string `json:"glob"`
+}
+
+// JobSubmissionRequest is the body of POST /k8s/jobs. When TemplateID is set the saved
+// template is loaded and Overrides is deep-merged on top of it; the inline fields are ignored.
+type JobSubmissionRequest struct {
+	JobSubmission
+	TemplateID *uint                  `json:"template_id"`
+	Overrides  map[string]interface{} `json:"overrides"`
+}
+
+// JobTemplateInput creates or replaces a saved job template
+type JobTemplateInput struct {
+	Name       string        `json:"name" binding:"required"`
+	ProjectID  *uint         `json:"project_id"`
+	Submission JobSubmission `json:"submission"`
 }
 
 // PVC represents a Persistent Volume Claim
@@ -73,12 +112,14 @@ type ExpandStorageInput struct {
 // VolumeSpec defines a volume specification
 type VolumeSpec struct {
 	Name             string    `json:"name"`
+	StorageName      string    `json:"storage_name"`
 	PVCName          string    `json:"pvc_name"`
 	MountPath        string    `json:"mount_path"`
 	Namespace        string    `json:"namespace"`
 	StorageClassName string    `json:"storage_class_name"`
 	Size             string    `json:"size"`
 	Capacity         int       `json:"capacity"`
+	CapacityBytes    int64     `json:"capacity_bytes"`
 	Status           string    `json:"status"`
 	AccessMode       string    `json:"access_mode"`
 	ProjectID        uint      `json:"project_id"`
@@ -94,18 +135,91 @@ type CreateProjectStorageRequest struct {
 	Capacity     int    `json:"capacity"`
 	Name         string `json:"name"`
 	StorageClass string `json:"storage_class"`
+	AccessMode   string `json:"access_mode"`
 }
 
 // ProjectPVCOutput represents a project PVC output
 type ProjectPVCOutput struct {
-	ID          uint      `json:"id"`
-	ProjectID   uint      `json:"project_id"`
-	ProjectName string    `json:"project_name"`
-	Namespace   string    `json:"namespace"`
-	Name        string    `json:"name"`
-	Capacity    string    `json:"capacity"`
-	Status      string    `json:"status"`
-	AccessMode  string    `json:"access_mode"`
-	CreatedAt   time.Time `json:"created_at"`
-	Role        string    `json:"role"`
+	ID            uint      `json:"id"`
+	ProjectID     uint      `json:"project_id"`
+	ProjectName   string    `json:"project_name"`
+	Namespace     string    `json:"namespace"`
+	Name          string    `json:"name"`
+	StorageName   string    `json:"storage_name"`
+	Capacity      string    `json:"capacity"`
+	CapacityBytes int64     `json:"capacity_bytes"`
+	Status        string    `json:"status"`
+	AccessMode    string    `json:"access_mode"`
+	CreatedAt     time.Time `json:"created_at"`
+	Role          string    `json:"role"`
+}
+
+// PriorityClassOption describes a job priority level offered by the platform
+type PriorityClassOption struct {
+	Level            string `json:"level"`
+	ClassName        string `json:"class_name"`
+	Value            int32  `json:"value"`
+	PreemptionPolicy string `json:"preemption_policy"`
+	Exists           bool   `json:"exists"`
+	Allowed          bool   `json:"allowed"`
+}
+
+// StorageClassOption describes a StorageClass of the cluster for the storage forms
+type StorageClassOption struct {
+	Name                 string `json:"name"`
+	Provisioner          string `json:"provisioner"`
+	AllowVolumeExpansion bool   `json:"allow_volume_expansion"`
+	IsDefault            bool   `json:"is_default"`
+	SupportsRWX          bool   `json:"supports_rwx"`
+	// AllowedForUsers is whether users may request the class for project storage
+	AllowedForUsers bool   `json:"allowed_for_users"`
+	MinSize         string `json:"min_size,omitempty"`
+	MaxSize         string `json:"max_size,omitempty"`
+}
+
+// JobDependencyStatus is the current state of one run-after dependency
+type JobDependencyStatus struct {
+	JobID  uint   `json:"job_id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// JobDetail is a job together with the status of its dependencies
+type JobDetail struct {
+	Job
+	Dependencies []JobDependencyStatus `json:"dependencies,omitempty"`
+	// Seconds left before the runtime limit stops the job; absent when it is unlimited or finished
+	RemainingRuntimeSeconds *int64 `json:"remaining_runtime_seconds,omitempty"`
+}
+
+// QueueStats aggregates the jobs submitted in [From, To) per bucket, for capacity planning
+type QueueStats struct {
+	From    time.Time          `json:"from"`
+	To      time.Time          `json:"to"`
+	Bucket  string             `json:"bucket"`
+	Buckets []QueueStatsBucket `json:"buckets"`
+	// Wait time percentiles over the whole range
+	WaitP50Seconds *int64            `json:"wait_p50_seconds"`
+	WaitP95Seconds *int64            `json:"wait_p95_seconds"`
+	Current        CurrentQueueStats `json:"current"`
+}
+
+// QueueStatsBucket holds the jobs submitted in one bucket. Wait times run from submission to
+// the start of the first pod and are absent when no job of the bucket started. GPU-hours are
+// requested from submission and granted from the start, both until completion or now.
+type QueueStatsBucket struct {
+	Start             time.Time `json:"start"`
+	Jobs              int64     `json:"jobs"`
+	Started           int64     `json:"started"`
+	WaitP50Seconds    *int64    `json:"wait_p50_seconds"`
+	WaitP95Seconds    *int64    `json:"wait_p95_seconds"`
+	GPUHoursRequested float64   `json:"gpu_hours_requested"`
+	GPUHoursGranted   float64   `json:"gpu_hours_granted"`
+}
+
+// CurrentQueueStats is the state of the queue when the stats were computed
+type CurrentQueueStats struct {
+	// Jobs waiting to start, by priority
+	QueueDepth  map[string]int64 `json:"queue_depth"`
+	RunningGPUs float64          `json:"running_gpus"`
 }
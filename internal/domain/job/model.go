@@ -1,6 +1,12 @@
 package job
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 // JobType defines the type of job execution
 type JobType string
@@ -9,6 +15,8 @@ const (
 	JobTypeNormal JobType = "normal" // Standard containerized job
 	JobTypeMPI    JobType = "mpi"    // MPI distributed computing job
 	JobTypeGPU    JobType = "gpu"    // GPU-accelerated job
+	// Job run on an external cluster, such as Slurm, through its REST API
+	JobTypeExternal JobType = "external"
 )
 
 // JobStatus represents the current state of a job
@@ -21,6 +29,14 @@ const (
 	JobStatusCompleted  JobStatus = "completed"  // Finished successfully
 	JobStatusFailed     JobStatus = "failed"     // Execution failed
 	JobStatusPreempted  JobStatus = "preempted"  // Terminated by higher priority
+	// A run-after dependency did not succeed
+	JobStatusDependencyFailed JobStatus = "dependency_failed"
+	// The row was active but its K8s Job no longer exists
+	JobStatusLostFromCluster JobStatus = "lost_from_cluster"
+	// Stopped after running longer than its runtime limit
+	JobStatusTimedOut JobStatus = "timed_out"
+	// Its pod was evicted or deleted while it ran, e.g. when its node was drained
+	JobStatusInterrupted JobStatus = "interrupted"
 )
 
 // Status aliases for backward compatibility
@@ -33,8 +49,21 @@ const (
 	StatusQueued     JobStatus = JobStatusQueued
 	StatusScheduling JobStatus = JobStatusScheduling
 	StatusPreempted  JobStatus = JobStatusPreempted
+	// Dependency status alias
+	StatusDependencyFailed = JobStatusDependencyFailed
+	StatusLostFromCluster  = JobStatusLostFromCluster
+	StatusTimedOut         = JobStatusTimedOut
+	StatusInterrupted      = JobStatusInterrupted
 )
 
+// ActiveStatuses lists the states of a job that has not finished yet
+var ActiveStatuses = []string{
+	string(StatusPending),
+	string(JobStatusQueued),
+	string(JobStatusScheduling),
+	string(JobStatusRunning),
+}
+
 // Priority constants
 const (
 	PriorityHigh   = "high"
@@ -57,9 +86,10 @@ type VolumeMount struct {
 
 // JobLog represents a job's log entry
 type JobLog struct {
-	ID      uint   `gorm:"primaryKey;column:id"`
-	JobID   uint   `gorm:"not null;column:job_id"`
-	Content string `gorm:"type:text"`
+	ID        uint      `gorm:"primaryKey;column:id"`
+	JobID     uint      `gorm:"not null;index;column:job_id"`
+	Content   string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;autoCreateTime;index"`
 }
 
 // JobCheckpoint represents a job's checkpoint data
@@ -71,17 +101,67 @@ type JobCheckpoint struct {
 	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime"`
 }
 
+// Sources and severities of the events of the job timeline
+const (
+	EventSourcePlatform   = "platform"
+	EventSourceAudit      = "audit"
+	EventSourceKubernetes = "kubernetes"
+	EventSourceStatus     = "status"
+
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// JobEvent is an event of a job seen by the status controller: a Kubernetes event of its Job or
+// one of its pods, kept after Kubernetes expires it, or a status the controller recorded.
+// Repeats of an event, such as BackOff while a container crash-loops, share one row: Count is
+// how often it happened between FirstSeen and LastSeen.
+type JobEvent struct {
+	ID    uint `gorm:"primaryKey;column:id" json:"id"`
+	JobID uint `gorm:"not null;column:job_id;uniqueIndex:idx_job_events_fingerprint" json:"job_id"`
+	// Hash of the object, reason and message identifying the repeats of an event
+	Fingerprint string    `gorm:"size:64;not null;uniqueIndex:idx_job_events_fingerprint" json:"-"`
+	Source      string    `gorm:"size:20;default:'kubernetes'" json:"source"`
+	ObjectKind  string    `gorm:"size:50" json:"object_kind"`
+	ObjectName  string    `gorm:"size:255" json:"object_name"`
+	Reason      string    `gorm:"size:100" json:"reason"`
+	Message     string    `gorm:"type:text" json:"message"`
+	Severity    string    `gorm:"size:20" json:"severity"`
+	Count       int       `gorm:"default:1" json:"count"`
+	FirstSeen   time.Time `gorm:"column:first_seen;index" json:"first_seen"`
+	LastSeen    time.Time `gorm:"column:last_seen" json:"last_seen"`
+}
+
+// TableName specifies the database table name
+func (JobEvent) TableName() string {
+	return "job_events"
+}
+
+// JobEventSeen is how many repeats of one Kubernetes event, by its UID, are merged into the
+// timeline of a job, so watching the job again after a restart only adds newer repeats.
+type JobEventSeen struct {
+	JobID    uint   `gorm:"primaryKey;column:job_id"`
+	EventUID string `gorm:"primaryKey;size:64;column:event_uid"`
+	Count    int    `gorm:"not null;default:0"`
+}
+
+// TableName specifies the database table name
+func (JobEventSeen) TableName() string {
+	return "job_event_seen"
+}
+
 // Job represents a batch job execution request
 type Job struct {
 	ID                 uint       `gorm:"primaryKey;column:id"`
 	UserID             uint       `gorm:"not null;column:user_id"`
-	ProjectID          *uint      `gorm:"column:project_id"`
+	ProjectID          *uint      `gorm:"column:project_id;index:idx_jobs_project_status"`
 	Name               string     `gorm:"size:100;not null"`
 	Namespace          string     `gorm:"size:100;not null"`
 	Image              string     `gorm:"size:255;not null"`
-	Status             string     `gorm:"size:50;default:'pending'"`
+	Status             string     `gorm:"size:50;default:'pending';index:idx_jobs_project_status;index:idx_jobs_status_priority"`
 	JobType            JobType    `gorm:"size:20;default:'normal'"`
-	Priority           string     `gorm:"size:20;default:'low'"`
+	Priority           string     `gorm:"size:20;default:'low';index:idx_jobs_status_priority"`
 	K8sJobName         string     `gorm:"size:100;not null"`
 	Command            string     `gorm:"type:text"`
 	Args               string     `gorm:"type:text"`
@@ -101,10 +181,110 @@ type Job struct {
 	RestartCount       int        `gorm:"default:0"`
 	ExitCode           *int       `gorm:"column:exit_code"`
 	ErrorMessage       string     `gorm:"type:text"`
-	CreatedAt          time.Time  `gorm:"column:created_at;autoCreateTime"`
+	CreatedAt          time.Time  `gorm:"column:created_at;autoCreateTime;index:idx_jobs_created_at"`
 	UpdatedAt          time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 	StartedAt          *time.Time `gorm:"column:started_at"`
 	CompletedAt        *time.Time `gorm:"column:completed_at"`
+	// When the job was handed to the cluster. StartedAt is when its first pod started, or the
+	// dispatch time for executors that cannot tell.
+	DispatchedAt *time.Time `gorm:"column:dispatched_at"`
+	// Run-after dependencies: JSON list of job IDs that must complete first
+	DependsOn              string `gorm:"type:text"`
+	RunOnDependencyFailure bool   `gorm:"default:false"`
+	// Spec is the fully resolved k8s.JobSpec (JSON) of a job deferred until its dependencies finish,
+	// or of a job that may be queued again after an eviction
+	Spec string `gorm:"type:text"`
+	// Longest the job may run, in seconds; 0 is unlimited. Nil for jobs submitted before runtime
+	// limits existed, which get the limit of their project.
+	MaxRuntimeSeconds *int64 `gorm:"column:max_runtime_seconds"`
+	// Cost center of the project when the job was submitted, for chargeback
+	CostCenter string `gorm:"size:63;column:cost_center"`
+	// ID the external cluster gave an external job when it was submitted
+	ExternalID string `gorm:"size:255;column:external_id"`
+	// Latest progress the job reported about itself. The percent is kept apart so listings
+	// can render progress bars without decoding the report.
+	ProgressPercent *float64       `gorm:"column:progress_percent"`
+	Progress        datatypes.JSON `gorm:"column:progress"`
+	// Queue the job again, from its latest checkpoint, when its pod is evicted
+	RestartOnEviction bool `gorm:"default:false;column:restart_on_eviction"`
+	// Warnings are the non-fatal findings of the submission and the scheduler, a JSON list of
+	// Warning
+	Warnings datatypes.JSON `gorm:"column:warnings"`
+}
+
+// RestartsOnEviction resolves the restart_on_eviction of a submission: the requested value, or
+// whether the job writes checkpoints it can resume from.
+func RestartsOnEviction(requested *bool, checkpointable bool) bool {
+	if requested != nil {
+		return *requested
+	}
+	return checkpointable
+}
+
+// Progress is the progress a running job reports about itself.
+type Progress struct {
+	Percent    float64            `json:"percent"`
+	Step       *int64             `json:"step,omitempty"`
+	TotalSteps *int64             `json:"total_steps,omitempty"`
+	Metrics    map[string]float64 `json:"metrics,omitempty"`
+	ReportedAt time.Time          `json:"reported_at"`
+}
+
+// RunningSince is when the runtime limit of the job started counting: its start, its dispatch
+// while no pod has started, or its creation when neither was recorded.
+func (j *Job) RunningSince() time.Time {
+	if j.StartedAt != nil {
+		return *j.StartedAt
+	}
+	if j.DispatchedAt != nil {
+		return *j.DispatchedAt
+	}
+	return j.CreatedAt
+}
+
+// MarkTimedOut records that the job was stopped at its runtime limit.
+func (j *Job) MarkTimedOut(now time.Time) {
+	j.Status = string(JobStatusTimedOut)
+	j.ErrorMessage = "the job was stopped after reaching its runtime limit"
+	j.CompletedAt = &now
+}
+
+// DependencyIDs decodes DependsOn. Malformed values yield no dependencies.
+func (j *Job) DependencyIDs() []uint {
+	if j.DependsOn == "" {
+		return nil
+	}
+	var ids []uint
+	_ = json.Unmarshal([]byte(j.DependsOn), &ids)
+	return ids
+}
+
+// StatusSeverity is the timeline severity of a job reaching status: failures are errors and
+// jobs stopped by someone else, such as a cancel or a preemption, are warnings.
+func StatusSeverity(status string) string {
+	switch JobStatus(strings.ToLower(status)) {
+	case StatusCancelled, JobStatusPreempted, JobStatusInterrupted:
+		return SeverityWarning
+	case JobStatusFailed, JobStatusDependencyFailed, JobStatusLostFromCluster, JobStatusTimedOut:
+		return SeverityError
+	}
+	return SeverityInfo
+}
+
+// JobTemplate is a saved JobSubmission preset owned by a user, optionally scoped to a project.
+type JobTemplate struct {
+	ID         uint           `gorm:"primaryKey;column:id" json:"id"`
+	UserID     uint           `gorm:"not null;uniqueIndex:idx_job_template_owner_name;column:user_id" json:"user_id"`
+	ProjectID  *uint          `gorm:"index;column:project_id" json:"project_id"`
+	Name       string         `gorm:"size:100;not null;uniqueIndex:idx_job_template_owner_name" json:"name"`
+	Submission datatypes.JSON `gorm:"type:jsonb" json:"submission"`
+	CreatedAt  time.Time      `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time      `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the database table name
+func (JobTemplate) TableName() string {
+	return "job_templates"
 }
 
 // TableName specifies the database table name
@@ -112,6 +292,17 @@ func (Job) TableName() string {
 	return "jobs"
 }
 
+// IsActive reports whether the job has not finished yet.
+func (j *Job) IsActive() bool {
+	status := strings.ToLower(j.Status)
+	for _, active := range ActiveStatuses {
+		if status == active {
+			return true
+		}
+	}
+	return false
+}
+
 // IsMPI checks if this is an MPI job
 func (j *Job) IsMPI() bool {
 	return j.JobType == JobTypeMPI
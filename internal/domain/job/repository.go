@@ -1,5 +1,11 @@
 package job
 
+import (
+	"time"
+
+	"github.com/linskybing/platform-go/pkg/pagination"
+)
+
 // Repository defines data access interface for jobs
 type Repository interface {
 	Create(job *Job) error
@@ -19,4 +25,20 @@ type Repository interface {
 	Delete(id uint) error
 	UpdateStatus(id uint, status string) error
 	GetPreemptibleJobs() ([]Job, error)
+	// Retention: logs of finished jobs older than cutoff
+	CountExpiredLogs(cutoff time.Time) (int64, error)
+	DeleteExpiredLogs(cutoff time.Time, limit int) (int64, error)
+	BackfillLogTimestamps() (int64, error)
+	// Pagination: jobs of userID newest first, of every user when userID is nil
+	FindPage(userID *uint, p pagination.Params) (*pagination.Page[Job], error)
+	// Progress: replaces the progress report of a job, leaving its other columns alone
+	UpdateProgress(id uint, percent float64, progress []byte) error
+	// Warnings: replaces the warnings of a job, leaving its other columns alone
+	UpdateWarnings(id uint, warnings []byte) error
+	// Events: stores an event, adding its count to an earlier repeat of it. SaveK8sEvent takes the
+	// total count Kubernetes reports for the event with eventUID and adds only the repeats not
+	// stored before.
+	SaveEvent(event *JobEvent) error
+	SaveK8sEvent(event *JobEvent, eventUID string) error
+	FindEvents(jobID uint) ([]JobEvent, error)
 }
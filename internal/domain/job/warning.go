@@ -0,0 +1,70 @@
+package job
+
+import "encoding/json"
+
+// Codes of a Warning
+const (
+	WarningVolumeUnbound   = "volume_unbound"
+	WarningVolumeRWOInUse  = "volume_rwo_in_use"
+	WarningVolumeUnchecked = "volume_unchecked"
+	// The job waits in the scheduler queue behind other jobs
+	WarningQueued = "queued"
+	// The scheduler keeps the job queued, e.g. at a concurrent job limit
+	WarningDispatchHeld = "dispatch_held"
+)
+
+// Warning is a finding about a submitted job that does not stop it from being created, such as a
+// PVC that is not bound yet. Field is the path of the submission field it concerns, if any.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+// JobWarnings returns the warnings recorded on the job.
+func (j *Job) JobWarnings() []Warning {
+	var ws []Warning
+	if len(j.Warnings) > 0 {
+		_ = json.Unmarshal(j.Warnings, &ws)
+	}
+	return ws
+}
+
+// AddWarnings records warnings on the job. A warning replaces the one of the same code and field,
+// so a check repeated while the job waits keeps only its latest message. It reports whether the
+// recorded warnings changed.
+func (j *Job) AddWarnings(ws ...Warning) bool {
+	current := j.JobWarnings()
+	changed := false
+	for _, w := range ws {
+		i := 0
+		for i < len(current) && (current[i].Code != w.Code || current[i].Field != w.Field) {
+			i++
+		}
+		switch {
+		case i == len(current):
+			current = append(current, w)
+		case current[i].Message != w.Message:
+			current[i] = w
+		default:
+			continue
+		}
+		changed = true
+	}
+	if changed {
+		j.Warnings, _ = json.Marshal(current)
+	}
+	return changed
+}
+
+// PriorityRank orders the priorities of jobs, the highest first to be dispatched.
+func PriorityRank(priority string) int {
+	switch priority {
+	case PriorityHigh:
+		return 3
+	case PriorityMedium:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,17 @@
+package maintenance
+
+import "time"
+
+// SetMaintenanceInput turns maintenance on or off. EndsAt is the optional scheduled end.
+type SetMaintenanceInput struct {
+	Active  *bool      `json:"active" binding:"required"`
+	Message string     `json:"message"`
+	EndsAt  *time.Time `json:"ends_at"`
+}
+
+// StatusDTO is the public platform status shown as a banner by the frontend.
+type StatusDTO struct {
+	Maintenance bool       `json:"maintenance"`
+	Message     string     `json:"message,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+}
@@ -0,0 +1,27 @@
+package maintenance
+
+import "time"
+
+// Maintenance is the platform-wide maintenance flag. There is a single row, shared by the API
+// and the scheduler; while it is in effect new submissions are refused and no queued job is started.
+type Maintenance struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	Active    bool       `gorm:"not null;default:false" json:"active"`
+	Message   string     `gorm:"type:text" json:"message"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	UpdatedBy uint       `json:"updated_by"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the database table name
+func (Maintenance) TableName() string {
+	return "platform_maintenance"
+}
+
+// InEffect reports whether maintenance is active at now. A scheduled end lifts it by itself.
+func (m *Maintenance) InEffect(now time.Time) bool {
+	if m == nil || !m.Active {
+		return false
+	}
+	return m.EndsAt == nil || now.Before(*m.EndsAt)
+}
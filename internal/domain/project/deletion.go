@@ -0,0 +1,101 @@
+package project
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Steps of a cascading project deletion, in the order they run
+const (
+	DeletionStepJobs        = "cancel_jobs"
+	DeletionStepConfigFiles = "config_files"
+	DeletionStepStorage     = "storage"
+	DeletionStepNamespaces  = "namespaces"
+	DeletionStepImages      = "image_rules"
+	DeletionStepProject     = "project"
+)
+
+// DeletionSteps lists every step of a cascading deletion in execution order
+var DeletionSteps = []string{
+	DeletionStepJobs,
+	DeletionStepConfigFiles,
+	DeletionStepStorage,
+	DeletionStepNamespaces,
+	DeletionStepImages,
+	DeletionStepProject,
+}
+
+// Status of a deletion and of each of its steps
+const (
+	DeletionPending = "pending"
+	DeletionRunning = "running"
+	DeletionFailed  = "failed"
+	DeletionDone    = "done"
+)
+
+// DeletionStep is the outcome of one step of a project deletion
+type DeletionStep struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ProjectDeletion records a cascading project deletion so it can resume after a partial failure.
+// The project name is kept because the project row is the last thing deleted.
+type ProjectDeletion struct {
+	ID               uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID        uint           `gorm:"not null;index;column:p_id" json:"project_id"`
+	ProjectName      string         `gorm:"size:100;not null" json:"project_name"`
+	RequestedBy      uint           `json:"requested_by"`
+	Force            bool           `gorm:"default:false" json:"force"`
+	Status           string         `gorm:"size:20;not null;index" json:"status"`
+	Steps            datatypes.JSON `gorm:"type:jsonb" json:"steps"`
+	Error            string         `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt        time.Time      `gorm:"column:create_at;autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time      `gorm:"column:update_at;autoUpdateTime" json:"updated_at"`
+	FinishedAt       *time.Time     `json:"finished_at,omitempty"`
+	StorageNamespace string         `gorm:"size:63" json:"storage_namespace"` // Copied from the project, which is gone by the storage step
+}
+
+// TableName specifies the database table name
+func (ProjectDeletion) TableName() string {
+	return "project_deletions"
+}
+
+// StepList decodes the recorded steps. Steps missing from the record are reported as pending.
+func (d *ProjectDeletion) StepList() []DeletionStep {
+	var recorded []DeletionStep
+	_ = json.Unmarshal(d.Steps, &recorded)
+	byName := make(map[string]DeletionStep, len(recorded))
+	for _, step := range recorded {
+		byName[step.Name] = step
+	}
+	steps := make([]DeletionStep, 0, len(DeletionSteps))
+	for _, name := range DeletionSteps {
+		step, ok := byName[name]
+		if !ok {
+			step = DeletionStep{Name: name, Status: DeletionPending}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// SetStepList encodes steps into the record.
+func (d *ProjectDeletion) SetStepList(steps []DeletionStep) {
+	b, _ := json.Marshal(steps)
+	d.Steps = datatypes.JSON(b)
+}
+
+// StepDone reports whether the named step already completed.
+func (d *ProjectDeletion) StepDone(name string) bool {
+	for _, step := range d.StepList() {
+		if step.Name == name {
+			return step.Status == DeletionDone
+		}
+	}
+	return false
+}
@@ -1,5 +1,11 @@
 package project
 
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
 type CreateProjectDTO struct {
 	ProjectName string  `json:"project_name" form:"project_name" binding:"required"`
 	Description *string `json:"description,omitempty" form:"description,omitempty"`
@@ -7,6 +13,8 @@ type CreateProjectDTO struct {
 	GPUQuota    *int    `json:"gpu_quota,omitempty" form:"gpu_quota,omitempty"` // GPU quota in integer units
 	GPUAccess   *string `json:"gpu_access,omitempty" form:"gpu_access,omitempty"`
 	MPSMemory   *int    `json:"mps_memory,omitempty" form:"mps_memory,omitempty"` // MPS memory limit in MB (optional)
+	// NamespaceMode is "per-user" (default) or "shared"
+	NamespaceMode *string `json:"namespace_mode,omitempty" form:"namespace_mode,omitempty" binding:"omitempty,oneof=per-user shared"`
 }
 
 type UpdateProjectDTO struct {
@@ -16,6 +24,42 @@ type UpdateProjectDTO struct {
 	GPUQuota    *int    `json:"gpu_quota,omitempty" form:"gpu_quota,omitempty"` // GPU quota in integer units
 	GPUAccess   *string `json:"gpu_access,omitempty" form:"gpu_access,omitempty"`
 	MPSMemory   *int    `json:"mps_memory,omitempty" form:"mps_memory,omitempty"` // MPS memory limit in MB (optional)
+	// NamespaceMode is "per-user" or "shared"; existing instances stay where they were deployed
+	NamespaceMode *string `json:"namespace_mode,omitempty" form:"namespace_mode,omitempty" binding:"omitempty,oneof=per-user shared"`
+	// RequireInstanceApproval holds members' instances for a manager's review; turning it off
+	// leaves the pending requests to be decided
+	RequireInstanceApproval *bool `json:"require_instance_approval,omitempty" form:"require_instance_approval,omitempty"`
+}
+
+// JobLimitsDTO sets the job limits of a project; omitted fields are kept. 0 means unlimited for
+// the concurrency limits and the platform default for the runtime limit.
+type JobLimitsDTO struct {
+	MaxConcurrentJobs        *int  `json:"max_concurrent_jobs" binding:"omitempty,min=0"`
+	MaxConcurrentJobsPerUser *int  `json:"max_concurrent_jobs_per_user" binding:"omitempty,min=0"`
+	MaxJobRuntimeMinutes     *int  `json:"max_job_runtime_minutes" binding:"omitempty,min=0"`
+	StrictVolumeChecks       *bool `json:"strict_volume_checks"`
+}
+
+// ChargebackDTO sets the cost center and cost tags of a project; omitted fields are kept and an
+// empty value clears them. Tags replace the previous set as a whole.
+type ChargebackDTO struct {
+	CostCenter *string           `json:"cost_center"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// HostPathExceptionsDTO replaces the host paths members of a project may mount; an empty list
+// revokes them all.
+type HostPathExceptionsDTO struct {
+	Paths []string `json:"paths"`
+}
+
+// ResourceDefaultsDTO sets the CPU and memory defaults of a project's instance containers;
+// omitted fields are kept. An empty request or a multiplier of 0 falls back to the platform default.
+type ResourceDefaultsDTO struct {
+	CPURequest      *string  `json:"cpu_request"`
+	MemoryRequest   *string  `json:"memory_request"`
+	LimitMultiplier *float64 `json:"limit_multiplier" binding:"omitempty,min=0"`
+	Disabled        *bool    `json:"disabled"`
 }
 
 type CreateProjectPVCDTO struct {
@@ -23,6 +67,40 @@ type CreateProjectPVCDTO struct {
 	Size string `json:"size" binding:"required"`
 }
 
+type UpsertProjectEnvDefaultDTO struct {
+	Key    string `json:"key" binding:"required"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret"`
+}
+
+// UpsertRegistryCredentialDTO registers, or replaces, the credentials of a project for a registry host.
+type UpsertRegistryCredentialDTO struct {
+	Registry string `json:"registry" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Token    string `json:"token" binding:"required"`
+}
+
+// RegistryCredentialDTO is a registry credential as listed to project managers, with the token masked.
+type RegistryCredentialDTO struct {
+	ID        uint      `json:"id"`
+	ProjectID uint      `json:"project_id"`
+	Registry  string    `json:"registry"`
+	Username  string    `json:"username"`
+	Token     string    `json:"token"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SchedulingPolicyDTO sets a project's scheduling policy. Tolerations and affinity use the
+// Kubernetes pod spec format.
+type SchedulingPolicyDTO struct {
+	Tolerations  datatypes.JSON    `json:"tolerations" swaggertype:"array,object"`
+	NodeSelector map[string]string `json:"node_selector"`
+	Affinity     datatypes.JSON    `json:"affinity" swaggertype:"object"`
+	Force        bool              `json:"force"`
+}
+
 type GIDGetter interface {
 	GetGID() uint
 }
@@ -1,6 +1,11 @@
 package project
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 // GPUAccessType defines the type of GPU access allowed for a project
 type GPUAccessType string
@@ -11,17 +16,70 @@ const (
 	GPUAccessDedicated GPUAccessType = "dedicated" // Dedicated GPU
 )
 
+// Namespace modes of a project
+const (
+	NamespaceModePerUser = "per-user" // Every member deploys into their own proj-<pid>-<user> namespace
+	NamespaceModeShared  = "shared"   // All members deploy into the project storage namespace
+)
+
 // Project represents a user project with resource quotas
 type Project struct {
-	PID         uint      `gorm:"primaryKey;column:p_id;autoIncrement"`
-	ProjectName string    `gorm:"size:100;not null"`
-	Description string    `gorm:"type:text"`
-	GID         uint      `gorm:"not null"`                   // Group ID
-	GPUQuota    int       `gorm:"default:0;column:gpu_quota"` // GPU quota in integer units (system auto-injects CUDA_MPS_ACTIVE_THREAD_PERCENTAGE)
-	GPUAccess   string    `gorm:"default:'shared';column:gpu_access"`
-	MPSMemory   int       `gorm:"default:0;column:mps_memory"` // MPS memory limit in MB (optional)
-	CreatedAt   time.Time `gorm:"column:create_at;autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"column:update_at;autoUpdateTime"`
+	PID              uint      `gorm:"primaryKey;column:p_id;autoIncrement"`
+	ProjectName      string    `gorm:"size:100;not null"`
+	Description      string    `gorm:"type:text"`
+	GID              uint      `gorm:"not null"`                   // Group ID
+	GPUQuota         int       `gorm:"default:0;column:gpu_quota"` // GPU quota in integer units; sets CUDA_MPS_ACTIVE_THREAD_PERCENTAGE of shared pods
+	GPUAccess        string    `gorm:"default:'shared';column:gpu_access"`
+	MPSMemory        int       `gorm:"default:0;column:mps_memory"`      // MPS memory limit in MB (optional)
+	StorageNamespace string    `gorm:"size:63;column:storage_namespace"` // Fixed at creation so a rename does not move the project storage
+	NamespaceMode    string    `gorm:"size:20;default:'per-user';column:namespace_mode"`
+	CreatedAt        time.Time `gorm:"column:create_at;autoCreateTime"`
+	UpdatedAt        time.Time `gorm:"column:update_at;autoUpdateTime"`
+
+	// Unfinished jobs allowed in the project and per member; 0 means unlimited
+	MaxConcurrentJobs        int `gorm:"default:0;column:max_concurrent_jobs"`
+	MaxConcurrentJobsPerUser int `gorm:"default:0;column:max_concurrent_jobs_per_user"`
+	// Longest a member's job may run, in minutes; 0 uses config.JobMaxRuntime
+	MaxJobRuntimeMinutes int `gorm:"default:0;column:max_job_runtime_minutes"`
+	// Reject jobs mounting a PVC that is unbound or attached elsewhere, instead of warning
+	StrictVolumeChecks bool `gorm:"default:false;column:strict_volume_checks"`
+
+	// Chargeback: the grant the project's cluster usage is billed to and free-form cost tags
+	// (JSON object of strings), labelled onto every object of the project
+	CostCenter string         `gorm:"size:63;column:cost_center"`
+	CostTags   datatypes.JSON `gorm:"type:jsonb;column:cost_tags" swaggertype:"object"`
+
+	// Host paths (JSON list) members may mount as hostPath volumes, with their subdirectories,
+	// e.g. /dev/infiniband for RDMA; granted by admins
+	AllowedHostPaths datatypes.JSON `gorm:"type:jsonb;column:allowed_host_paths" swaggertype:"array,string"`
+
+	// CPU and memory requests given to instance containers that set none, and the multiple of
+	// them set as limits; empty or 0 uses the platform defaults. Disabled turns injection off
+	DefaultCPURequest        string  `gorm:"size:32;column:default_cpu_request"`
+	DefaultMemoryRequest     string  `gorm:"size:32;column:default_memory_request"`
+	ResourceLimitMultiplier  float64 `gorm:"default:0;column:resource_limit_multiplier"`
+	ResourceDefaultsDisabled bool    `gorm:"default:false;column:resource_defaults_disabled"`
+
+	// Instances of members other than managers wait for a manager's approval before they are applied
+	RequireInstanceApproval bool `gorm:"default:false;column:require_instance_approval"`
+}
+
+// CostTagMap decodes CostTags; unset or malformed tags give an empty map.
+func (p *Project) CostTagMap() map[string]string {
+	tags := map[string]string{}
+	if len(p.CostTags) > 0 {
+		_ = json.Unmarshal(p.CostTags, &tags)
+	}
+	return tags
+}
+
+// HostPathExceptions decodes AllowedHostPaths; unset or malformed values give none.
+func (p *Project) HostPathExceptions() []string {
+	var paths []string
+	if len(p.AllowedHostPaths) > 0 {
+		_ = json.Unmarshal(p.AllowedHostPaths, &paths)
+	}
+	return paths
 }
 
 // TableName specifies the database table name
@@ -29,6 +87,11 @@ func (Project) TableName() string {
 	return "project_list"
 }
 
+// SharesNamespace reports whether members deploy into one shared namespace.
+func (p *Project) SharesNamespace() bool {
+	return p.NamespaceMode == NamespaceModeShared
+}
+
 // CanUseDedicatedGPU checks if project can use dedicated GPU
 func (p *Project) CanUseDedicatedGPU() bool {
 	return p.hasAccessType(GPUAccessDedicated)
@@ -68,3 +131,57 @@ func (p *Project) HasGPUQuota() bool {
 func (p *Project) GetMPSUnits() int {
 	return p.GPUQuota * 10
 }
+
+// ProjectEnvDefault is an environment variable injected into every workload of a project.
+// Secret values are delivered through a generated Secret instead of inline.
+type ProjectEnvDefault struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	ProjectID uint      `gorm:"not null;uniqueIndex:idx_project_env_key;column:p_id"`
+	Key       string    `gorm:"size:255;not null;uniqueIndex:idx_project_env_key"`
+	Value     string    `gorm:"type:text"`
+	Secret    bool      `gorm:"default:false"`
+	CreatedAt time.Time `gorm:"column:create_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:update_at;autoUpdateTime"`
+}
+
+// TableName specifies the database table name
+func (ProjectEnvDefault) TableName() string {
+	return "project_env_defaults"
+}
+
+// SchedulingPolicy holds the tolerations, node selector and affinity injected into the pods of
+// a project's instances and jobs. The fields are stored as their Kubernetes JSON form.
+type SchedulingPolicy struct {
+	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID    uint           `gorm:"not null;uniqueIndex;column:p_id" json:"project_id"`
+	Tolerations  datatypes.JSON `json:"tolerations,omitempty" swaggertype:"array,object"`
+	NodeSelector datatypes.JSON `json:"node_selector,omitempty" swaggertype:"object"`
+	Affinity     datatypes.JSON `json:"affinity,omitempty" swaggertype:"object"`
+	// Force makes the policy override scheduling fields the workload sets itself
+	Force     bool      `gorm:"default:false" json:"force"`
+	CreatedAt time.Time `gorm:"column:create_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:update_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the database table name
+func (SchedulingPolicy) TableName() string {
+	return "project_scheduling_policies"
+}
+
+// RegistryCredential lets a project pull private images from an upstream registry. The token
+// is stored encrypted and only decrypted to build image pull secrets.
+type RegistryCredential struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID      uint      `gorm:"not null;uniqueIndex:idx_project_registry;column:p_id" json:"project_id"`
+	Registry       string    `gorm:"size:255;not null;uniqueIndex:idx_project_registry" json:"registry"`
+	Username       string    `gorm:"size:255;not null" json:"username"`
+	EncryptedToken string    `gorm:"type:text;not null" json:"-"`
+	CreatedBy      uint      `json:"created_by"`
+	CreatedAt      time.Time `gorm:"column:create_at;autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:update_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the database table name
+func (RegistryCredential) TableName() string {
+	return "project_registry_credentials"
+}
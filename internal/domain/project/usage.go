@@ -0,0 +1,42 @@
+package project
+
+import "time"
+
+// Metrics of a usage history
+const (
+	UsageMetricGPU    = "gpu"
+	UsageMetricCPU    = "cpu"
+	UsageMetricMemory = "memory"
+)
+
+// UsageSample is what the running pods of one project namespace requested at one sampling.
+// SampledUnix repeats SampledAt in seconds so histories can be bucketed with integer math
+// on any database.
+type UsageSample struct {
+	ID            uint      `gorm:"primaryKey"`
+	PID           uint      `gorm:"column:p_id;not null;index:idx_usage_sample_project,priority:1"`
+	Namespace     string    `gorm:"size:253;not null"`
+	SampledAt     time.Time `gorm:"not null"`
+	SampledUnix   int64     `gorm:"not null;index:idx_usage_sample_project,priority:2;index"`
+	GPU           int64     `gorm:"column:gpu;not null;default:0"`
+	CPUMillicores int64     `gorm:"not null;default:0"`
+	MemoryBytes   int64     `gorm:"not null;default:0"`
+}
+
+// UsagePoint is one bucket of a usage history. Max and Avg are over the project totals of the
+// samplings in the bucket and null when there were none.
+type UsagePoint struct {
+	Start time.Time `json:"start"`
+	Max   *float64  `json:"max"`
+	Avg   *float64  `json:"avg"`
+}
+
+// UsageHistory is the downsampled usage of a project over the last Hours hours. GPUs are
+// counted in devices or MPS shares, cpu in millicores and memory in bytes.
+type UsageHistory struct {
+	ProjectID     uint         `json:"project_id"`
+	Metric        string       `json:"metric"`
+	Hours         int          `json:"hours"`
+	BucketSeconds int64        `json:"bucket_seconds"`
+	Points        []UsagePoint `json:"points"`
+}
@@ -20,7 +20,8 @@ const (
 // Resource represents a Kubernetes resource configuration
 type Resource struct {
 	RID         uint           `gorm:"primaryKey;column:r_id"`
-	CFID        uint           `gorm:"not null;column:cf_id"` // ConfigFile ID
+	CFID        uint           `gorm:"not null;column:cf_id"`                // ConfigFile ID
+	CFVersion   int            `gorm:"not null;default:1;column:cf_version"` // ConfigFile version it was parsed from
 	Type        ResourceType   `gorm:"type:resource_type;not null"`
 	Name        string         `gorm:"size:50;not null"`
 	ParsedYAML  datatypes.JSON `gorm:"type:jsonb;not null;"`
@@ -48,9 +49,25 @@ func (r *Resource) IsDeployment() bool {
 	return r.Type == ResourceDeployment
 }
 
+// DeployedObject records the name a resource was created under in a namespace. It differs from
+// Name when the manifest sets metadata.generateName, so the server picked the name.
+type DeployedObject struct {
+	ID        uint      `gorm:"primaryKey;column:id"`
+	RID       uint      `gorm:"not null;column:r_id;uniqueIndex:idx_deployed_object"`
+	Namespace string    `gorm:"size:100;not null;uniqueIndex:idx_deployed_object"`
+	Name      string    `gorm:"size:253;not null"`
+	CreatedAt time.Time `gorm:"column:create_at;autoCreateTime"`
+}
+
+// TableName specifies the database table name
+func (DeployedObject) TableName() string {
+	return "resource_deployed_objects"
+}
+
 type ResourceSwagger struct {
 	RID         uint                   `json:"r_id"`
 	CFID        uint                   `json:"cf_id"`
+	CFVersion   int                    `json:"cf_version"`
 	Type        string                 `json:"type"`
 	Name        string                 `json:"name"`
 	ParsedYAML  map[string]interface{} `json:"parsedYAML" swaggertype:"object"`
@@ -0,0 +1,14 @@
+package search
+
+// TypeResult is a page of hits of one kind. NextCursor fetches the next page of that kind alone.
+type TypeResult struct {
+	Type       string `json:"type"`
+	Hits       []Hit  `json:"hits"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Result lists the hits of each requested kind.
+type Result struct {
+	Query   string       `json:"query"`
+	Results []TypeResult `json:"results"`
+}
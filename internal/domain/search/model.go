@@ -0,0 +1,59 @@
+package search
+
+import "time"
+
+// Kinds of entity the search covers
+const (
+	TypeProject    = "project"
+	TypeConfigFile = "configfile"
+	TypeJob        = "job"
+	TypeImage      = "image"
+)
+
+// Types lists every searchable kind in the order results are returned.
+var Types = []string{TypeProject, TypeConfigFile, TypeJob, TypeImage}
+
+// ValidType reports whether t is one of the Type* kinds.
+func ValidType(t string) bool {
+	for _, v := range Types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope limits a search to what a user may see. With All the search is not limited, for admins.
+type Scope struct {
+	UserID uint
+	All    bool
+}
+
+// Cursor is the position after the last hit of a page: hits are ordered by UpdatedAt, newest
+// first, then by ID.
+type Cursor struct {
+	UpdatedAt time.Time
+	ID        uint
+}
+
+// Hit is an entity whose name or text matched the query.
+type Hit struct {
+	Type      string    `json:"type" gorm:"-"`
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	ProjectID uint      `json:"project_id,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Snippet is the part of the name or text around the match; Highlights are the matched
+	// ranges in it, as character offsets
+	Snippet    string      `json:"snippet" gorm:"-"`
+	Highlights []Highlight `json:"highlights" gorm:"-"`
+
+	// Text is the longer field searched besides the name, e.g. a config file's YAML
+	Text string `json:"-" gorm:"column:text"`
+}
+
+// Highlight is a matched range of a snippet, from Start up to End.
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
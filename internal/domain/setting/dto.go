@@ -0,0 +1,21 @@
+package setting
+
+import "time"
+
+// SettingDTO is a runtime setting with the value in effect and where it comes from.
+type SettingDTO struct {
+	Key         string     `json:"key"`
+	Type        string     `json:"type"`
+	Description string     `json:"description"`
+	Value       string     `json:"value"`
+	Default     string     `json:"default"`
+	Overridden  bool       `json:"overridden"`
+	UpdatedBy   uint       `json:"updated_by,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// UpdateSettingsInput sets settings by key; a null value returns the setting to its
+// environment default.
+type UpdateSettingsInput struct {
+	Settings map[string]*string `json:"settings" binding:"required"`
+}
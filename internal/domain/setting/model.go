@@ -0,0 +1,17 @@
+package setting
+
+import "time"
+
+// Setting is a runtime setting an admin changed from its environment default. Keys are the
+// config.Setting* constants; values are stored as written, e.g. "10m" or "20Gi".
+type Setting struct {
+	Key       string    `gorm:"primaryKey;size:100" json:"key"`
+	Value     string    `gorm:"type:text;not null" json:"value"`
+	UpdatedBy uint      `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the database table name
+func (Setting) TableName() string {
+	return "platform_settings"
+}
@@ -0,0 +1,117 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix marks personal access tokens so the auth middleware can tell them from JWTs.
+const APITokenPrefix = "pat_"
+
+// Scopes an API token can be granted
+const (
+	ScopeJobsRead         = "jobs:read"
+	ScopeJobsWrite        = "jobs:write"
+	ScopeConfigFilesRead  = "configfiles:read"
+	ScopeConfigFilesWrite = "configfiles:write"
+	ScopeInstancesWrite   = "instances:write"
+	ScopeStorageDelete    = "storage:delete"
+)
+
+// APITokenScopes lists every scope accepted when creating a token.
+var APITokenScopes = []string{
+	ScopeJobsRead,
+	ScopeJobsWrite,
+	ScopeConfigFilesRead,
+	ScopeConfigFilesWrite,
+	ScopeInstancesWrite,
+	ScopeStorageDelete,
+}
+
+// APIToken is a long-lived credential for automation such as CI pipelines. Only the SHA-256
+// hash of the secret is stored; the token itself is shown once when it is created.
+type APIToken struct {
+	ID     uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID uint `gorm:"not null;index;column:user_id" json:"user_id"`
+	// ProjectID restricts the token to one project, nil for tokens acting on all of the user's projects
+	ProjectID  *uint      `gorm:"index;column:project_id" json:"project_id,omitempty"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"type:text;not null" json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the database table name
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// ScopeList returns the scopes stored on the token.
+func (t *APIToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// Active reports whether the token is neither revoked nor expired at now.
+func (t *APIToken) Active(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// HashAPIToken returns the hex SHA-256 of a raw token as stored in TokenHash.
+func HashAPIToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+type CreateAPITokenInput struct {
+	Name      string   `json:"name" binding:"required,max=100" example:"gitlab-ci"`
+	Scopes    []string `json:"scopes" binding:"required,min=1" example:"jobs:write"`
+	ProjectID *uint    `json:"project_id" example:"3"`
+	// ExpiresInDays of 0 creates a token that does not expire
+	ExpiresInDays int `json:"expires_in_days" binding:"min=0" example:"90"`
+}
+
+// APITokenDTO is a token as listed to its owner; the secret is never returned again.
+type APITokenDTO struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	ProjectID  *uint      `json:"project_id,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedAPITokenDTO carries the raw token, returned only by the create call.
+type CreatedAPITokenDTO struct {
+	APITokenDTO
+	Token string `json:"token"`
+}
+
+// ToDTO converts the token for API responses.
+func (t *APIToken) ToDTO() APITokenDTO {
+	return APITokenDTO{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		ProjectID:  t.ProjectID,
+		Scopes:     t.ScopeList(),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		RevokedAt:  t.RevokedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
@@ -9,6 +9,19 @@ type UpdateUserInput struct {
 	Status      *string `form:"status" binding:"omitempty,oneof=online offline delete" example:"online"`
 }
 
+// SSHKeysInput replaces the caller's SSH public keys; an empty list removes them all.
+type SSHKeysInput struct {
+	Keys []string `json:"keys" example:"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI... alice@laptop"`
+}
+
+// SSHKey is one stored SSH public key.
+type SSHKey struct {
+	Type        string `json:"type" example:"ssh-ed25519"`
+	Fingerprint string `json:"fingerprint" example:"SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s"`
+	Comment     string `json:"comment,omitempty" example:"alice@laptop"`
+	Key         string `json:"key"`
+}
+
 type CreateUserInput struct {
 	Username string  `form:"username" binding:"required,min=3,max=50" example:"johndoe"`
 	Password string  `form:"password" binding:"required,min=6" example:"password123"`
@@ -17,6 +17,12 @@ const (
 	UserTypeOauth2 UserType = "oauth2"
 )
 
+// Authentication providers a user account can be bound to
+const (
+	AuthProviderLocal = "local"
+	AuthProviderOIDC  = "oidc"
+)
+
 type UserRole string
 
 const (
@@ -26,13 +32,21 @@ const (
 )
 
 type User struct {
-	UID       uint      `gorm:"primaryKey;column:u_id"`
-	Username  string    `gorm:"size:50;not null;unique" json:"Username"`
-	Password  string    `gorm:"size:255;not null" json:"-"`
-	Email     *string   `gorm:"size:100"`
-	FullName  *string   `gorm:"size:50"`
-	Type      string    `gorm:"type:user_type;default:'origin';not null"`
-	Status    string    `gorm:"type:user_status;default:'offline';not null"`
+	UID      uint    `gorm:"primaryKey;column:u_id"`
+	Username string  `gorm:"size:50;not null;unique" json:"Username"`
+	Password string  `gorm:"size:255;not null" json:"-"`
+	Email    *string `gorm:"size:100"`
+	FullName *string `gorm:"size:50"`
+	Type     string  `gorm:"type:user_type;default:'origin';not null"`
+	Status   string  `gorm:"type:user_status;default:'offline';not null"`
+	// AuthProvider and ExternalSubject identify the account at an external identity provider
+	AuthProvider    string  `gorm:"size:20;default:'local';not null;uniqueIndex:idx_users_external_subject" json:"AuthProvider"`
+	ExternalSubject *string `gorm:"size:255;uniqueIndex:idx_users_external_subject" json:"-"`
+	// SSHPublicKeys holds the OpenSSH public keys allowed into the user's SFTP server, one per line
+	SSHPublicKeys string `gorm:"type:text;not null;default:'';column:ssh_public_keys" json:"-"`
+	// StorageID names the user's storage hub objects; it is set when the hub is first used and kept
+	// when the username changes. The partial unique index keeps two users from storing the same one.
+	StorageID string    `gorm:"size:63;not null;default:'';column:storage_id;uniqueIndex:idx_users_storage_id,where:storage_id <> ''" json:"-"`
 	CreatedAt time.Time `gorm:"column:create_at;autoCreateTime"`
 	UpdatedAt time.Time `gorm:"column:update_at;autoUpdateTime"`
 }
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/activity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ActivityRepo interface {
+	Record(rows []activity.UserActivity) error
+	ListRecent(userID uint, entityType string, limit int) ([]activity.UserActivity, error)
+	CountBefore(cutoff time.Time) (int64, error)
+	DeleteBefore(cutoff time.Time, limit int) (int64, error)
+	AddFavorite(f *activity.Favorite) error
+	RemoveFavorite(userID uint, entityType string, entityID uint) error
+	ListFavorites(userID uint) ([]activity.Favorite, error)
+	WithTx(tx *gorm.DB) ActivityRepo
+}
+
+type DBActivityRepo struct {
+	db *gorm.DB
+}
+
+func NewActivityRepo(db *gorm.DB) *DBActivityRepo {
+	return &DBActivityRepo{
+		db: db,
+	}
+}
+
+// Record inserts a batch of activity rows.
+func (r *DBActivityRepo) Record(rows []activity.UserActivity) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return r.db.Create(&rows).Error
+}
+
+// ListRecent returns the latest row of each entity the user used, most recent first. An empty
+// entityType covers every kind. Rows are appended in time order, so the highest ID per entity
+// is its last use.
+func (r *DBActivityRepo) ListRecent(userID uint, entityType string, limit int) ([]activity.UserActivity, error) {
+	latest := r.db.Model(&activity.UserActivity{}).Select("MAX(id)").Where("user_id = ?", userID)
+	if entityType != "" {
+		latest = latest.Where("entity_type = ?", entityType)
+	}
+	latest = latest.Group("entity_type, entity_id")
+
+	var rows []activity.UserActivity
+	query := r.db.Where("id IN (?)", latest).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&rows).Error
+	return rows, err
+}
+
+func (r *DBActivityRepo) CountBefore(cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&activity.UserActivity{}).Where("created_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
+
+// DeleteBefore removes at most limit rows older than cutoff.
+func (r *DBActivityRepo) DeleteBefore(cutoff time.Time, limit int) (int64, error) {
+	batch := r.db.Model(&activity.UserActivity{}).Select("id").Where("created_at < ?", cutoff).Order("id").Limit(limit)
+	res := r.db.Where("id IN (?)", batch).Delete(&activity.UserActivity{})
+	return res.RowsAffected, res.Error
+}
+
+// AddFavorite stars the entity; starring it again keeps the original time.
+func (r *DBActivityRepo) AddFavorite(f *activity.Favorite) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(f).Error
+}
+
+// RemoveFavorite unstars the entity; removing one that was never starred is not an error.
+func (r *DBActivityRepo) RemoveFavorite(userID uint, entityType string, entityID uint) error {
+	return r.db.Where("user_id = ? AND entity_type = ? AND entity_id = ?", userID, entityType, entityID).
+		Delete(&activity.Favorite{}).Error
+}
+
+// ListFavorites returns the user's favorites, most recently starred first.
+func (r *DBActivityRepo) ListFavorites(userID uint) ([]activity.Favorite, error) {
+	var favorites []activity.Favorite
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&favorites).Error
+	return favorites, err
+}
+
+func (r *DBActivityRepo) WithTx(tx *gorm.DB) ActivityRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBActivityRepo{
+		db: tx,
+	}
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/user"
+	"gorm.io/gorm"
+)
+
+type APITokenRepo interface {
+	Create(t *user.APIToken) error
+	GetByID(id uint) (*user.APIToken, error)
+	GetByHash(hash string) (*user.APIToken, error)
+	ListByUser(uid uint) ([]user.APIToken, error)
+	Revoke(id uint, at time.Time) error
+	TouchLastUsed(id uint, at time.Time) error
+	WithTx(tx *gorm.DB) APITokenRepo
+}
+
+type DBAPITokenRepo struct {
+	db *gorm.DB
+}
+
+func NewAPITokenRepo(db *gorm.DB) *DBAPITokenRepo {
+	return &DBAPITokenRepo{
+		db: db,
+	}
+}
+
+func (r *DBAPITokenRepo) Create(t *user.APIToken) error {
+	return r.db.Create(t).Error
+}
+
+func (r *DBAPITokenRepo) GetByID(id uint) (*user.APIToken, error) {
+	var t user.APIToken
+	if err := r.db.First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *DBAPITokenRepo) GetByHash(hash string) (*user.APIToken, error) {
+	var t user.APIToken
+	if err := r.db.Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *DBAPITokenRepo) ListByUser(uid uint) ([]user.APIToken, error) {
+	var tokens []user.APIToken
+	err := r.db.Where("user_id = ?", uid).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *DBAPITokenRepo) Revoke(id uint, at time.Time) error {
+	return r.db.Model(&user.APIToken{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at).Error
+}
+
+// TouchLastUsed records the time of the token's latest authenticated request.
+func (r *DBAPITokenRepo) TouchLastUsed(id uint, at time.Time) error {
+	return r.db.Model(&user.APIToken{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+func (r *DBAPITokenRepo) WithTx(tx *gorm.DB) APITokenRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBAPITokenRepo{
+		db: tx,
+	}
+}
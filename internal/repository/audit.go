@@ -10,6 +10,7 @@ import (
 type AuditQueryParams struct {
 	UserID       *uint
 	ResourceType *string
+	ResourceIDs  []string
 	Action       *string
 	StartTime    *time.Time
 	EndTime      *time.Time
@@ -21,6 +22,8 @@ type AuditRepo interface {
 	GetAuditLogs(params AuditQueryParams) ([]audit.AuditLog, error)
 	CreateAuditLog(audit *audit.AuditLog) error
 	DeleteOldAuditLogs(retentionDays int) error
+	CountAuditLogsBefore(cutoff time.Time) (int64, error)
+	DeleteAuditLogsBefore(cutoff time.Time, limit int) (int64, error)
 	WithTx(tx *gorm.DB) AuditRepo
 }
 
@@ -39,6 +42,19 @@ func (r *DBAuditRepo) DeleteOldAuditLogs(retentionDays int) error {
 	return r.db.Where("created_at < ?", cutoff).Delete(&audit.AuditLog{}).Error
 }
 
+func (r *DBAuditRepo) CountAuditLogsBefore(cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&audit.AuditLog{}).Where("created_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
+
+// DeleteAuditLogsBefore removes at most limit logs older than cutoff so a single statement never holds long locks.
+func (r *DBAuditRepo) DeleteAuditLogsBefore(cutoff time.Time, limit int) (int64, error) {
+	batch := r.db.Model(&audit.AuditLog{}).Select("id").Where("created_at < ?", cutoff).Order("id").Limit(limit)
+	res := r.db.Where("id IN (?)", batch).Delete(&audit.AuditLog{})
+	return res.RowsAffected, res.Error
+}
+
 func (r *DBAuditRepo) GetAuditLogs(params AuditQueryParams) ([]audit.AuditLog, error) {
 	var logs []audit.AuditLog
 	query := r.db.Model(&audit.AuditLog{})
@@ -49,6 +65,9 @@ func (r *DBAuditRepo) GetAuditLogs(params AuditQueryParams) ([]audit.AuditLog, e
 	if params.ResourceType != nil {
 		query = query.Where("resource_type = ?", *params.ResourceType)
 	}
+	if len(params.ResourceIDs) > 0 {
+		query = query.Where("resource_id IN ?", params.ResourceIDs)
+	}
 	if params.Action != nil {
 		query = query.Where("action = ?", *params.Action)
 	}
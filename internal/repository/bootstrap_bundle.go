@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BootstrapBundleRepo keeps the bootstrap bundles, the projects selecting them and the objects
+// they created in member namespaces.
+type BootstrapBundleRepo interface {
+	CreateBundle(b *configfile.BootstrapBundle) error
+	GetBundleByID(id uint) (*configfile.BootstrapBundle, error)
+	UpdateBundle(b *configfile.BootstrapBundle) error
+	DeleteBundle(id uint) error
+	ListBundles() ([]configfile.BootstrapBundle, error)
+	CountBundleProjects(bundleID uint) (int64, error)
+
+	ListProjectBundles(projectID uint) ([]configfile.BootstrapBundle, error)
+	AddProjectBundle(projectID, bundleID uint) error
+	RemoveProjectBundle(projectID, bundleID uint) error
+
+	ListObjects(bundleID uint, ns string) ([]configfile.BootstrapObject, error)
+	ListProjectObjects(projectID, bundleID uint) ([]configfile.BootstrapObject, error)
+	SaveObject(obj *configfile.BootstrapObject) error
+	DeleteObject(id uint) error
+	WithTx(tx *gorm.DB) BootstrapBundleRepo
+}
+
+type DBBootstrapBundleRepo struct {
+	db *gorm.DB
+}
+
+func NewBootstrapBundleRepo(db *gorm.DB) *DBBootstrapBundleRepo {
+	return &DBBootstrapBundleRepo{
+		db: db,
+	}
+}
+
+func (r *DBBootstrapBundleRepo) CreateBundle(b *configfile.BootstrapBundle) error {
+	return r.db.Create(b).Error
+}
+
+func (r *DBBootstrapBundleRepo) GetBundleByID(id uint) (*configfile.BootstrapBundle, error) {
+	var b configfile.BootstrapBundle
+	if err := r.db.First(&b, id).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *DBBootstrapBundleRepo) UpdateBundle(b *configfile.BootstrapBundle) error {
+	return r.db.Save(b).Error
+}
+
+// DeleteBundle deletes the bundle and the records of the objects it created.
+func (r *DBBootstrapBundleRepo) DeleteBundle(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_id = ?", id).Delete(&configfile.BootstrapObject{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&configfile.BootstrapBundle{}, id).Error
+	})
+}
+
+func (r *DBBootstrapBundleRepo) ListBundles() ([]configfile.BootstrapBundle, error) {
+	var list []configfile.BootstrapBundle
+	err := r.db.Order("name").Find(&list).Error
+	return list, err
+}
+
+// CountBundleProjects returns the number of projects selecting the bundle.
+func (r *DBBootstrapBundleRepo) CountBundleProjects(bundleID uint) (int64, error) {
+	var n int64
+	err := r.db.Model(&configfile.ProjectBootstrapBundle{}).Where("bundle_id = ?", bundleID).Count(&n).Error
+	return n, err
+}
+
+// ListProjectBundles returns the bundles the project selected, by name.
+func (r *DBBootstrapBundleRepo) ListProjectBundles(projectID uint) ([]configfile.BootstrapBundle, error) {
+	var list []configfile.BootstrapBundle
+	err := r.db.
+		Joins("JOIN project_bootstrap_bundles pb ON pb.bundle_id = bootstrap_bundles.id").
+		Where("pb.p_id = ?", projectID).
+		Order("bootstrap_bundles.name").
+		Find(&list).Error
+	return list, err
+}
+
+// AddProjectBundle selects the bundle for the project; selecting it again changes nothing.
+func (r *DBBootstrapBundleRepo) AddProjectBundle(projectID, bundleID uint) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&configfile.ProjectBootstrapBundle{ProjectID: projectID, BundleID: bundleID}).Error
+}
+
+func (r *DBBootstrapBundleRepo) RemoveProjectBundle(projectID, bundleID uint) error {
+	return r.db.Where("p_id = ? AND bundle_id = ?", projectID, bundleID).
+		Delete(&configfile.ProjectBootstrapBundle{}).Error
+}
+
+// ListObjects returns the objects the bundle created in the namespace.
+func (r *DBBootstrapBundleRepo) ListObjects(bundleID uint, ns string) ([]configfile.BootstrapObject, error) {
+	var objs []configfile.BootstrapObject
+	err := r.db.Where("bundle_id = ? AND namespace = ?", bundleID, ns).Find(&objs).Error
+	return objs, err
+}
+
+// ListProjectObjects returns the objects the bundle created in the namespaces of the project.
+func (r *DBBootstrapBundleRepo) ListProjectObjects(projectID, bundleID uint) ([]configfile.BootstrapObject, error) {
+	var objs []configfile.BootstrapObject
+	err := r.db.Where("p_id = ? AND bundle_id = ?", projectID, bundleID).Order("id DESC").Find(&objs).Error
+	return objs, err
+}
+
+// SaveObject records the object, replacing the record of the same object from an older version.
+func (r *DBBootstrapBundleRepo) SaveObject(obj *configfile.BootstrapObject) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "bundle_id"}, {Name: "namespace"}, {Name: "kind"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"manifest", "bundle_version", "create_at"}),
+	}).Create(obj).Error
+}
+
+func (r *DBBootstrapBundleRepo) DeleteObject(id uint) error {
+	return r.db.Delete(&configfile.BootstrapObject{}, id).Error
+}
+
+func (r *DBBootstrapBundleRepo) WithTx(tx *gorm.DB) BootstrapBundleRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBBootstrapBundleRepo{
+		db: tx,
+	}
+}
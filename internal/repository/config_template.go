@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"gorm.io/gorm"
+)
+
+type ConfigTemplateRepo interface {
+	CreateTemplate(t *configfile.ConfigTemplate) error
+	GetTemplateByID(id uint) (*configfile.ConfigTemplate, error)
+	UpdateTemplate(t *configfile.ConfigTemplate) error
+	DeleteTemplate(id uint) error
+	ListTemplates(category string) ([]configfile.ConfigTemplate, error)
+	WithTx(tx *gorm.DB) ConfigTemplateRepo
+}
+
+type DBConfigTemplateRepo struct {
+	db *gorm.DB
+}
+
+func NewConfigTemplateRepo(db *gorm.DB) *DBConfigTemplateRepo {
+	return &DBConfigTemplateRepo{
+		db: db,
+	}
+}
+
+func (r *DBConfigTemplateRepo) CreateTemplate(t *configfile.ConfigTemplate) error {
+	return r.db.Create(t).Error
+}
+
+func (r *DBConfigTemplateRepo) GetTemplateByID(id uint) (*configfile.ConfigTemplate, error) {
+	var t configfile.ConfigTemplate
+	if err := r.db.First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *DBConfigTemplateRepo) UpdateTemplate(t *configfile.ConfigTemplate) error {
+	return r.db.Save(t).Error
+}
+
+func (r *DBConfigTemplateRepo) DeleteTemplate(id uint) error {
+	return r.db.Delete(&configfile.ConfigTemplate{}, id).Error
+}
+
+// ListTemplates returns all templates, or only those of category when it is not empty.
+func (r *DBConfigTemplateRepo) ListTemplates(category string) ([]configfile.ConfigTemplate, error) {
+	var list []configfile.ConfigTemplate
+	query := r.db.Order("category, name")
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if err := query.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *DBConfigTemplateRepo) WithTx(tx *gorm.DB) ConfigTemplateRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBConfigTemplateRepo{
+		db: tx,
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/linskybing/platform-go/internal/domain/configfile"
 	"gorm.io/gorm"
@@ -11,7 +12,13 @@ type ConfigFileRepo interface {
 	CreateConfigFile(cf *configfile.ConfigFile) error
 	GetConfigFileByID(id uint) (*configfile.ConfigFile, error)
 	UpdateConfigFile(cf *configfile.ConfigFile) error
+	UpdateConfigFileIfVersion(cf *configfile.ConfigFile, version int) (bool, error)
 	DeleteConfigFile(id uint) error
+	GetTrashedConfigFileByID(id uint) (*configfile.ConfigFile, error)
+	ListTrashedConfigFiles(projectID uint) ([]configfile.ConfigFile, error)
+	ListConfigFilesTrashedBefore(cutoff time.Time) ([]configfile.ConfigFile, error)
+	RestoreConfigFile(id uint) error
+	PurgeConfigFile(id uint) error
 	ListConfigFiles() ([]configfile.ConfigFile, error)
 	GetConfigFilesByProjectID(projectID uint) ([]configfile.ConfigFile, error)
 	GetGroupIDByConfigFileID(cfID uint) (uint, error)
@@ -47,10 +54,77 @@ func (r *DBConfigFileRepo) UpdateConfigFile(cf *configfile.ConfigFile) error {
 	return r.db.Save(cf).Error
 }
 
+// UpdateConfigFileIfVersion saves the file only if its stored version is still version, bumping it
+// in the same statement. It returns false without writing when another update got there first.
+func (r *DBConfigFileRepo) UpdateConfigFileIfVersion(cf *configfile.ConfigFile, version int) (bool, error) {
+	if cf.CFID == 0 {
+		return false, errors.New("missing ConfigFile ID")
+	}
+	res := r.db.Model(&configfile.ConfigFile{}).
+		Where("cf_id = ? AND version = ?", cf.CFID, version).
+		Updates(map[string]interface{}{
+			"filename": cf.Filename,
+			"content":  cf.Content,
+			"version":  gorm.Expr("version + 1"),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	cf.Version = version + 1
+	return true, nil
+}
+
+// DeleteConfigFile moves the file to the trash. PurgeConfigFile removes it for good.
 func (r *DBConfigFileRepo) DeleteConfigFile(id uint) error {
 	return r.db.Delete(&configfile.ConfigFile{}, id).Error
 }
 
+func (r *DBConfigFileRepo) GetTrashedConfigFileByID(id uint) (*configfile.ConfigFile, error) {
+	var cf configfile.ConfigFile
+	if err := r.db.Unscoped().Where("deleted_at IS NOT NULL").First(&cf, id).Error; err != nil {
+		return nil, err
+	}
+	return &cf, nil
+}
+
+func (r *DBConfigFileRepo) ListTrashedConfigFiles(projectID uint) ([]configfile.ConfigFile, error) {
+	var files []configfile.ConfigFile
+	err := r.db.Unscoped().
+		Where("project_id = ? AND deleted_at IS NOT NULL", projectID).
+		Order("deleted_at DESC").
+		Find(&files).Error
+	return files, err
+}
+
+func (r *DBConfigFileRepo) ListConfigFilesTrashedBefore(cutoff time.Time) ([]configfile.ConfigFile, error) {
+	var files []configfile.ConfigFile
+	err := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Order("deleted_at").
+		Find(&files).Error
+	return files, err
+}
+
+func (r *DBConfigFileRepo) RestoreConfigFile(id uint) error {
+	res := r.db.Unscoped().Model(&configfile.ConfigFile{}).
+		Where("cf_id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *DBConfigFileRepo) PurgeConfigFile(id uint) error {
+	return r.db.Unscoped().Delete(&configfile.ConfigFile{}, id).Error
+}
+
 func (r *DBConfigFileRepo) ListConfigFiles() ([]configfile.ConfigFile, error) {
 	var list []configfile.ConfigFile
 	if err := r.db.Find(&list).Error; err != nil {
@@ -5,33 +5,65 @@ import (
 )
 
 type Repos struct {
-	ConfigFile ConfigFileRepo
-	Group      GroupRepo
-	Project    ProjectRepo
-	Resource   ResourceRepo
-	UserGroup  UserGroupRepo
-	User       UserRepo
-	Audit      AuditRepo
-	Form       FormRepo
-	Job        JobRepo
-	Image      ImageRepo
+	ConfigFile      ConfigFileRepo
+	Template        ConfigTemplateRepo
+	Bootstrap       BootstrapBundleRepo
+	Group           GroupRepo
+	Project         ProjectRepo
+	ProjectEnv      ProjectEnvRepo
+	ProjectDeletion ProjectDeletionRepo
+	ProjectUsage    ProjectUsageRepo
+	Scheduling      ProjectSchedulingRepo
+	Resource        ResourceRepo
+	DeployedObject  DeployedObjectRepo
+	Approval        InstanceApprovalRepo
+	UserGroup       UserGroupRepo
+	User            UserRepo
+	Audit           AuditRepo
+	Form            FormRepo
+	Job             JobRepo
+	JobTemplate     JobTemplateRepo
+	Image           ImageRepo
+	APIToken        APITokenRepo
+	Maintenance     MaintenanceRepo
+	Registry        RegistryCredentialRepo
+	GPURequest      GPURequestRepo
+	Setting         SettingRepo
+	Activity        ActivityRepo
+	Search          SearchRepo
 
 	db *gorm.DB
 }
 
 func NewRepositories(db *gorm.DB) *Repos {
 	return &Repos{
-		ConfigFile: NewConfigFileRepo(db),
-		Group:      NewGroupRepo(db),
-		Project:    NewProjectRepo(db),
-		Resource:   NewResourceRepo(db),
-		UserGroup:  NewUserGroupRepo(db),
-		User:       NewUserRepo(db),
-		Audit:      NewAuditRepo(db),
-		Form:       NewFormRepo(db),
-		Job:        NewJobRepo(db),
-		Image:      NewImageRepo(db),
-		db:         db,
+		ConfigFile:      NewConfigFileRepo(db),
+		Template:        NewConfigTemplateRepo(db),
+		Bootstrap:       NewBootstrapBundleRepo(db),
+		Group:           NewGroupRepo(db),
+		Project:         NewProjectRepo(db),
+		ProjectEnv:      NewProjectEnvRepo(db),
+		ProjectDeletion: NewProjectDeletionRepo(db),
+		ProjectUsage:    NewProjectUsageRepo(db),
+		Scheduling:      NewProjectSchedulingRepo(db),
+		Resource:        NewResourceRepo(db),
+		DeployedObject:  NewDeployedObjectRepo(db),
+		Approval:        NewInstanceApprovalRepo(db),
+		UserGroup:       NewUserGroupRepo(db),
+		User:            NewUserRepo(db),
+		Audit:           NewAuditRepo(db),
+		Form:            NewFormRepo(db),
+		Job:             NewJobRepo(db),
+		JobTemplate:     NewJobTemplateRepo(db),
+		Image:           NewImageRepo(db),
+		APIToken:        NewAPITokenRepo(db),
+		Maintenance:     NewMaintenanceRepo(db),
+		Registry:        NewRegistryCredentialRepo(db),
+		GPURequest:      NewGPURequestRepo(db),
+		Setting:         NewSettingRepo(db),
+		Activity:        NewActivityRepo(db),
+		Search:          NewSearchRepo(db),
+		db:              db,
 	}
 }
 
@@ -41,17 +73,33 @@ func (r *Repos) Begin() *gorm.DB {
 
 func (r *Repos) WithTx(tx *gorm.DB) *Repos {
 	return &Repos{
-		ConfigFile: r.ConfigFile.WithTx(tx),
-		Group:      r.Group.WithTx(tx),
-		Project:    r.Project.WithTx(tx),
-		Resource:   r.Resource.WithTx(tx),
-		UserGroup:  r.UserGroup.WithTx(tx),
-		User:       r.User.WithTx(tx),
-		Audit:      r.Audit.WithTx(tx),
-		Form:       r.Form.WithTx(tx),
-		Job:        r.Job.WithTx(tx),
-		Image:      r.Image.WithTx(tx),
-		db:         tx,
+		ConfigFile:      r.ConfigFile.WithTx(tx),
+		Template:        r.Template.WithTx(tx),
+		Bootstrap:       r.Bootstrap.WithTx(tx),
+		Group:           r.Group.WithTx(tx),
+		Project:         r.Project.WithTx(tx),
+		ProjectEnv:      r.ProjectEnv.WithTx(tx),
+		ProjectDeletion: r.ProjectDeletion.WithTx(tx),
+		ProjectUsage:    r.ProjectUsage.WithTx(tx),
+		Scheduling:      r.Scheduling.WithTx(tx),
+		Resource:        r.Resource.WithTx(tx),
+		DeployedObject:  r.DeployedObject.WithTx(tx),
+		Approval:        r.Approval.WithTx(tx),
+		UserGroup:       r.UserGroup.WithTx(tx),
+		User:            r.User.WithTx(tx),
+		Audit:           r.Audit.WithTx(tx),
+		Form:            r.Form.WithTx(tx),
+		Job:             r.Job.WithTx(tx),
+		JobTemplate:     r.JobTemplate.WithTx(tx),
+		Image:           r.Image.WithTx(tx),
+		APIToken:        r.APIToken.WithTx(tx),
+		Maintenance:     r.Maintenance.WithTx(tx),
+		Registry:        r.Registry.WithTx(tx),
+		GPURequest:      r.GPURequest.WithTx(tx),
+		Setting:         r.Setting.WithTx(tx),
+		Activity:        r.Activity.WithTx(tx),
+		Search:          r.Search.WithTx(tx),
+		db:              tx,
 	}
 }
 
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/resource"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeployedObjectRepo keeps the names the resources of config file instances were created under.
+type DeployedObjectRepo interface {
+	Save(objs []resource.DeployedObject) error
+	ListByNamespace(ns string, rIDs []uint) ([]resource.DeployedObject, error)
+	Delete(ns string, rIDs []uint) error
+	WithTx(tx *gorm.DB) DeployedObjectRepo
+}
+
+type DBDeployedObjectRepo struct {
+	db *gorm.DB
+}
+
+func NewDeployedObjectRepo(db *gorm.DB) *DBDeployedObjectRepo {
+	return &DBDeployedObjectRepo{
+		db: db,
+	}
+}
+
+// Save records the objects, replacing the name recorded earlier for a resource in a namespace.
+func (r *DBDeployedObjectRepo) Save(objs []resource.DeployedObject) error {
+	if len(objs) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "r_id"}, {Name: "namespace"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "create_at"}),
+	}).Create(&objs).Error
+}
+
+func (r *DBDeployedObjectRepo) ListByNamespace(ns string, rIDs []uint) ([]resource.DeployedObject, error) {
+	var objs []resource.DeployedObject
+	if len(rIDs) == 0 {
+		return objs, nil
+	}
+	err := r.db.Where("namespace = ? AND r_id IN ?", ns, rIDs).Find(&objs).Error
+	return objs, err
+}
+
+func (r *DBDeployedObjectRepo) Delete(ns string, rIDs []uint) error {
+	if len(rIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("namespace = ? AND r_id IN ?", ns, rIDs).Delete(&resource.DeployedObject{}).Error
+}
+
+func (r *DBDeployedObjectRepo) WithTx(tx *gorm.DB) DeployedObjectRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBDeployedObjectRepo{
+		db: tx,
+	}
+}
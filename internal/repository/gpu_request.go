@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/gpu"
+	"gorm.io/gorm"
+)
+
+type GPURequestRepo interface {
+	Create(req *gpu.GPURequest) error
+	ListByStatus(status gpu.GPURequestStatus) ([]gpu.GPURequest, error)
+	WithTx(tx *gorm.DB) GPURequestRepo
+}
+
+type DBGPURequestRepo struct {
+	db *gorm.DB
+}
+
+func NewGPURequestRepo(db *gorm.DB) *DBGPURequestRepo {
+	return &DBGPURequestRepo{
+		db: db,
+	}
+}
+
+func (r *DBGPURequestRepo) Create(req *gpu.GPURequest) error {
+	return r.db.Create(req).Error
+}
+
+// ListByStatus returns the requests in status, oldest first.
+func (r *DBGPURequestRepo) ListByStatus(status gpu.GPURequestStatus) ([]gpu.GPURequest, error) {
+	var reqs []gpu.GPURequest
+	err := r.db.Where("status = ?", status).Order("created_at").Find(&reqs).Error
+	return reqs, err
+}
+
+func (r *DBGPURequestRepo) WithTx(tx *gorm.DB) GPURequestRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBGPURequestRepo{
+		db: tx,
+	}
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/linskybing/platform-go/internal/domain/image"
+	"github.com/linskybing/platform-go/pkg/pagination"
 	"gorm.io/gorm"
 )
 
@@ -42,6 +44,28 @@ func (r *DBImageRepo) GetTagByDigest(repoID uint, digest string) (*image.Contain
 	return &tag, err
 }
 
+func (r *DBImageRepo) FindRepositoryByFullName(fullName string) (*image.ContainerRepository, error) {
+	var repo image.ContainerRepository
+	if err := r.db.Where("full_name = ?", fullName).First(&repo).Error; err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+func (r *DBImageRepo) ListTagsByRepository(repoID uint) ([]image.ContainerTag, error) {
+	var tags []image.ContainerTag
+	err := r.db.Where("repository_id = ?", repoID).Order("id").Find(&tags).Error
+	return tags, err
+}
+
+func (r *DBImageRepo) MarkTagSeenRunning(tagID uint, at time.Time) error {
+	return r.db.Model(&image.ContainerTag{}).Where("id = ?", tagID).Update("last_seen_running_at", at).Error
+}
+
+func (r *DBImageRepo) SetTagPreload(tagID uint, preload *bool) error {
+	return r.db.Model(&image.ContainerTag{}).Where("id = ?", tagID).Update("preload", preload).Error
+}
+
 func (r *DBImageRepo) CreateRequest(req *image.ImageRequest) error {
 	return r.db.Create(req).Error
 }
@@ -54,17 +78,28 @@ func (r *DBImageRepo) FindRequestByID(id uint) (*image.ImageRequest, error) {
 
 func (r *DBImageRepo) ListRequests(projectID *uint, status string) ([]image.ImageRequest, error) {
 	var reqs []image.ImageRequest
-	query := r.db.Model(&image.ImageRequest{})
+	err := r.requestsQuery(projectID, status).Order("created_at DESC").Find(&reqs).Error
+	return reqs, err
+}
 
+func (r *DBImageRepo) ListRequestsPage(projectID *uint, status string, p pagination.Params) (*pagination.Page[image.ImageRequest], error) {
+	if projectID == nil && status == "" {
+		p = p.EstimateFrom("image_requests")
+	}
+	return pagination.Find(r.requestsQuery(projectID, status), p, func(req *image.ImageRequest) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: req.CreatedAt, ID: req.ID}
+	})
+}
+
+func (r *DBImageRepo) requestsQuery(projectID *uint, status string) *gorm.DB {
+	query := r.db.Model(&image.ImageRequest{})
 	if projectID != nil {
 		query = query.Where("project_id = ?", projectID)
 	}
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
-
-	err := query.Order("created_at DESC").Find(&reqs).Error
-	return reqs, err
+	return query
 }
 
 func (r *DBImageRepo) UpdateRequest(req *image.ImageRequest) error {
@@ -101,7 +136,8 @@ func (r *DBImageRepo) CheckImageAllowed(projectID *uint, repoFullName string, ta
 		query = query.Where("image_allow_lists.project_id IS NULL")
 	}
 
-	query = query.Where("(t.name = ? OR image_allow_lists.tag_id IS NULL)", tagName)
+	// tagName may be a digest for references pinned only by digest; tag names cannot contain ":"
+	query = query.Where("(t.name = ? OR t.digest = ? OR image_allow_lists.tag_id IS NULL)", tagName, tagName)
 
 	err := query.Count(&count).Error
 	if err != nil {
@@ -110,6 +146,14 @@ func (r *DBImageRepo) CheckImageAllowed(projectID *uint, repoFullName string, ta
 	return count > 0, nil
 }
 
+func (r *DBImageRepo) GetAllowListRule(id uint) (*image.ImageAllowList, error) {
+	var rule image.ImageAllowList
+	if err := r.db.Preload("Repository").Preload("Tag").First(&rule, id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
 func (r *DBImageRepo) DisableAllowListRule(id uint) error {
 	return r.db.Model(&image.ImageAllowList{}).Where("id = ?", id).Update("is_enabled", false).Error
 }
@@ -150,7 +194,8 @@ func (r *DBImageRepo) FindAllowListRule(projectID *uint, repoFullName, tagName s
 		query = query.Where("image_allow_lists.project_id IS NULL")
 	}
 
-	query = query.Where("(t.name = ? OR image_allow_lists.tag_id IS NULL)", tagName)
+	// tagName may be a digest for references pinned only by digest; tag names cannot contain ":"
+	query = query.Where("(t.name = ? OR t.digest = ? OR image_allow_lists.tag_id IS NULL)", tagName, tagName)
 
 	err := query.First(&rule).Error
 	if err != nil {
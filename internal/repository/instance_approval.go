@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/configfile"
+	"gorm.io/gorm"
+)
+
+type InstanceApprovalRepo interface {
+	Create(req *configfile.InstanceApprovalRequest) error
+	GetByID(id uint) (*configfile.InstanceApprovalRequest, error)
+	ListByProject(projectID uint, status string) ([]configfile.InstanceApprovalRequest, error)
+	FindPending(cfID, requesterID uint) (*configfile.InstanceApprovalRequest, error)
+	ListExpired(now time.Time) ([]configfile.InstanceApprovalRequest, error)
+	Decide(req *configfile.InstanceApprovalRequest) (bool, error)
+	Reopen(id uint) error
+	WithTx(tx *gorm.DB) InstanceApprovalRepo
+}
+
+type DBInstanceApprovalRepo struct {
+	db *gorm.DB
+}
+
+func NewInstanceApprovalRepo(db *gorm.DB) *DBInstanceApprovalRepo {
+	return &DBInstanceApprovalRepo{
+		db: db,
+	}
+}
+
+func (r *DBInstanceApprovalRepo) Create(req *configfile.InstanceApprovalRequest) error {
+	return r.db.Create(req).Error
+}
+
+func (r *DBInstanceApprovalRepo) GetByID(id uint) (*configfile.InstanceApprovalRequest, error) {
+	var req configfile.InstanceApprovalRequest
+	if err := r.db.First(&req, id).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ListByProject returns the requests of a project, newest first; an empty status lists them all.
+func (r *DBInstanceApprovalRepo) ListByProject(projectID uint, status string) ([]configfile.InstanceApprovalRequest, error) {
+	var reqs []configfile.InstanceApprovalRequest
+	q := r.db.Where("p_id = ?", projectID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Order("create_at DESC, id DESC").Find(&reqs).Error
+	return reqs, err
+}
+
+// FindPending returns the pending request of a requester for a config file, or nil.
+func (r *DBInstanceApprovalRepo) FindPending(cfID, requesterID uint) (*configfile.InstanceApprovalRequest, error) {
+	var reqs []configfile.InstanceApprovalRequest
+	err := r.db.Where("cf_id = ? AND requester_id = ? AND status = ?", cfID, requesterID, configfile.ApprovalStatusPending).
+		Limit(1).Find(&reqs).Error
+	if err != nil || len(reqs) == 0 {
+		return nil, err
+	}
+	return &reqs[0], nil
+}
+
+// ListExpired returns the pending requests that expired before now.
+func (r *DBInstanceApprovalRepo) ListExpired(now time.Time) ([]configfile.InstanceApprovalRequest, error) {
+	var reqs []configfile.InstanceApprovalRequest
+	err := r.db.Where("status = ? AND expires_at < ?", configfile.ApprovalStatusPending, now).
+		Order("expires_at").Find(&reqs).Error
+	return reqs, err
+}
+
+// Decide stores the status, reviewer, note and decision time of req if it is still pending. It
+// returns false without writing when someone else decided on it first.
+func (r *DBInstanceApprovalRepo) Decide(req *configfile.InstanceApprovalRequest) (bool, error) {
+	res := r.db.Model(&configfile.InstanceApprovalRequest{}).
+		Where("id = ? AND status = ?", req.ID, configfile.ApprovalStatusPending).
+		Updates(map[string]interface{}{
+			"status":      req.Status,
+			"reviewer_id": req.ReviewerID,
+			"note":        req.Note,
+			"decided_at":  req.DecidedAt,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// Reopen puts an approved request back to pending, for an approval whose apply failed.
+func (r *DBInstanceApprovalRepo) Reopen(id uint) error {
+	return r.db.Model(&configfile.InstanceApprovalRequest{}).
+		Where("id = ? AND status = ?", id, configfile.ApprovalStatusApproved).
+		Updates(map[string]interface{}{
+			"status":      configfile.ApprovalStatusPending,
+			"reviewer_id": nil,
+			"decided_at":  nil,
+		}).Error
+}
+
+func (r *DBInstanceApprovalRepo) WithTx(tx *gorm.DB) InstanceApprovalRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBInstanceApprovalRepo{
+		db: tx,
+	}
+}
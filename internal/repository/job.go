@@ -1,16 +1,62 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/pagination"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // JobRepo matches the domain job repository contract.
 type JobRepo interface {
 	job.Repository
+	CountByProjects(projectIDs []uint) ([]JobStatusCount, error)
+	CountProjectJobs(projectID, userID uint, statuses []string) (int64, error)
+	FindByNamespace(namespace string) ([]job.Job, error)
+	StatsByBucket(from, to, now time.Time, width, offset int64) ([]JobStatsBucket, error)
+	WaitTimeCounts(from, to time.Time, width, offset int64) ([]WaitTimeCount, error)
+	CountActiveByPriority(statuses []string) ([]PriorityCount, error)
+	SumGPUUnits(statuses []string) (int64, error)
 	WithTx(tx *gorm.DB) JobRepo
 }
 
+// JobStatusCount is the number of jobs of a project in one status, with the GPU units they
+// request (dedicated GPUs count as 10 MPS units each).
+type JobStatusCount struct {
+	ProjectID uint
+	Status    string
+	Count     int64
+	GPUUnits  int64
+}
+
+// JobStatsBucket aggregates the jobs submitted in one time bucket, which starts at Bucket
+// (Unix seconds). The GPU unit seconds are GPU units (dedicated GPUs count as 10) times seconds.
+type JobStatsBucket struct {
+	Bucket                  int64
+	Jobs                    int64
+	Started                 int64
+	RequestedGPUUnitSeconds int64
+	GrantedGPUUnitSeconds   int64
+}
+
+// WaitTimeCount is the number of jobs submitted in a bucket that waited WaitSeconds to start.
+type WaitTimeCount struct {
+	Bucket      int64
+	WaitSeconds int64
+	Count       int64
+}
+
+// PriorityCount is the number of jobs of one priority.
+type PriorityCount struct {
+	Priority string
+	Count    int64
+}
+
 type DBJobRepo struct {
 	db *gorm.DB
 }
@@ -31,6 +77,18 @@ func (r *DBJobRepo) FindAll() ([]job.Job, error) {
 	return jobs, err
 }
 
+func (r *DBJobRepo) FindPage(userID *uint, p pagination.Params) (*pagination.Page[job.Job], error) {
+	query := r.db.Model(&job.Job{})
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	} else {
+		p = p.EstimateFrom("jobs")
+	}
+	return pagination.Find(query, p, func(j *job.Job) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: j.CreatedAt, ID: j.ID}
+	})
+}
+
 func (r *DBJobRepo) FindByID(id uint) (*job.Job, error) {
 	var j job.Job
 	err := r.db.First(&j, id).Error
@@ -47,6 +105,15 @@ func (r *DBJobRepo) FindByUserID(userID uint) ([]job.Job, error) {
 	return jobs, err
 }
 
+func (r *DBJobRepo) UpdateProgress(id uint, percent float64, progress []byte) error {
+	return r.db.Model(&job.Job{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"progress_percent": percent, "progress": datatypes.JSON(progress)}).Error
+}
+
+func (r *DBJobRepo) UpdateWarnings(id uint, warnings []byte) error {
+	return r.db.Model(&job.Job{}).Where("id = ?", id).UpdateColumn("warnings", datatypes.JSON(warnings)).Error
+}
+
 func (r *DBJobRepo) GetByUserID(userID uint) ([]job.Job, error) {
 	return r.FindByUserID(userID)
 }
@@ -90,6 +157,79 @@ func (r *DBJobRepo) SaveLog(entry *job.JobLog) error {
 	return r.db.Create(entry).Error
 }
 
+// SaveEvent inserts the event, or adds its count to the stored repeat with the same
+// fingerprint and moves the last seen time forward.
+func (r *DBJobRepo) SaveEvent(event *job.JobEvent) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "job_id"}, {Name: "fingerprint"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "count"}, Value: gorm.Expr("job_events.count + excluded.count")},
+			{Column: clause.Column{Name: "last_seen"}, Value: gorm.Expr("CASE WHEN excluded.last_seen > job_events.last_seen THEN excluded.last_seen ELSE job_events.last_seen END")},
+		},
+	}).Create(event).Error
+}
+
+// SaveK8sEvent stores the repeats of a Kubernetes event not stored before. The count stored per
+// event UID only grows, so two observations of the same count never add it twice.
+func (r *DBJobRepo) SaveK8sEvent(event *job.JobEvent, eventUID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var seen job.JobEventSeen
+		err := tx.Where("job_id = ? AND event_uid = ?", event.JobID, eventUID).Take(&seen).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if event.Count <= seen.Count {
+			return nil
+		}
+		err = tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "job_id"}, {Name: "event_uid"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "count"}, Value: gorm.Expr("CASE WHEN excluded.count > job_event_seen.count THEN excluded.count ELSE job_event_seen.count END")},
+			},
+		}).Create(&job.JobEventSeen{JobID: event.JobID, EventUID: eventUID, Count: event.Count}).Error
+		if err != nil {
+			return err
+		}
+		event.Count -= seen.Count
+		return (&DBJobRepo{db: tx}).SaveEvent(event)
+	})
+}
+
+func (r *DBJobRepo) FindEvents(jobID uint) ([]job.JobEvent, error) {
+	var events []job.JobEvent
+	err := r.db.Where("job_id = ?", jobID).Order("first_seen ASC, id ASC").Find(&events).Error
+	return events, err
+}
+
+// expiredLogs selects logs older than cutoff, skipping logs of jobs that are still active.
+func (r *DBJobRepo) expiredLogs(cutoff time.Time) *gorm.DB {
+	active := r.db.Model(&job.Job{}).Select("id").Where("LOWER(status) IN ?", job.ActiveStatuses)
+	return r.db.Model(&job.JobLog{}).Where("created_at < ?", cutoff).Where("job_id NOT IN (?)", active)
+}
+
+func (r *DBJobRepo) CountExpiredLogs(cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.expiredLogs(cutoff).Count(&count).Error
+	return count, err
+}
+
+// DeleteExpiredLogs removes at most limit expired logs so a single statement never holds long locks.
+func (r *DBJobRepo) DeleteExpiredLogs(cutoff time.Time, limit int) (int64, error) {
+	batch := r.expiredLogs(cutoff).Select("id").Order("id").Limit(limit)
+	res := r.db.Where("id IN (?)", batch).Delete(&job.JobLog{})
+	return res.RowsAffected, res.Error
+}
+
+// BackfillLogTimestamps dates the logs written before job_logs had created_at, which retention
+// never prunes otherwise, at the completion of their job, or its creation when it has none.
+// Logs of jobs that are gone are dated now.
+func (r *DBJobRepo) BackfillLogTimestamps() (int64, error) {
+	jobTime := r.db.Model(&job.Job{}).Select("COALESCE(completed_at, created_at)").Where("jobs.id = job_logs.job_id")
+	res := r.db.Model(&job.JobLog{}).Where("created_at IS NULL").
+		UpdateColumn("created_at", gorm.Expr("COALESCE((?), CURRENT_TIMESTAMP)", jobTime))
+	return res.RowsAffected, res.Error
+}
+
 func (r *DBJobRepo) FindCheckpoints(jobID uint) ([]job.JobCheckpoint, error) {
 	var checkpoints []job.JobCheckpoint
 	err := r.db.Where("job_id = ?", jobID).Order("checkpoint_num ASC").Find(&checkpoints).Error
@@ -116,6 +256,105 @@ func (r *DBJobRepo) GetPreemptibleJobs() ([]job.Job, error) {
 	return jobs, err
 }
 
+// CountByProjects aggregates the jobs of the given projects per project and status in one query.
+func (r *DBJobRepo) CountByProjects(projectIDs []uint) ([]JobStatusCount, error) {
+	var counts []JobStatusCount
+	if len(projectIDs) == 0 {
+		return counts, nil
+	}
+	err := r.db.Model(&job.Job{}).
+		Select("project_id, status, COUNT(*) AS count, "+
+			"COALESCE(SUM("+gpuUnitsExpr+"), 0) AS gpu_units", job.GPUTypeDedicated).
+		Where("project_id IN ?", projectIDs).
+		Group("project_id, status").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// CountProjectJobs counts the project's jobs in one of statuses, compared case-insensitively.
+// A non-zero userID counts only that user's jobs.
+func (r *DBJobRepo) CountProjectJobs(projectID, userID uint, statuses []string) (int64, error) {
+	var count int64
+	q := r.db.Model(&job.Job{}).Where("project_id = ?", projectID).Where("LOWER(status) IN ?", statuses)
+	if userID != 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	err := q.Count(&count).Error
+	return count, err
+}
+
+const gpuUnitsExpr = "CASE WHEN gpu_type = ? THEN gpu_count * 10 ELSE gpu_count END"
+
+// epochExpr converts a timestamp expression to Unix seconds in the SQL of the database.
+func (r *DBJobRepo) epochExpr(expr string) string {
+	if r.db.Dialector.Name() == "postgres" {
+		return "CAST(EXTRACT(EPOCH FROM " + expr + ") AS BIGINT)"
+	}
+	return "CAST(strftime('%s', " + expr + ") AS INTEGER)"
+}
+
+// bucketExpr maps created_at to the start of its bucket: buckets are width seconds long and
+// start offset seconds after a multiple of width.
+func (r *DBJobRepo) bucketExpr(width, offset int64) string {
+	return fmt.Sprintf("((%s - %d) / %d * %d + %d)", r.epochExpr("created_at"), offset, width, width, offset)
+}
+
+// StatsByBucket aggregates the jobs created in [from, to) per bucket. Requested GPU time runs
+// from submission and granted GPU time from the start to completion, or to now for unfinished
+// jobs.
+func (r *DBJobRepo) StatsByBucket(from, to, now time.Time, width, offset int64) ([]JobStatsBucket, error) {
+	var rows []JobStatsBucket
+	start := r.epochExpr("COALESCE(started_at, dispatched_at)")
+	end := fmt.Sprintf("COALESCE(%s, %d)", r.epochExpr("completed_at"), now.Unix())
+	err := r.db.Model(&job.Job{}).
+		Select(r.bucketExpr(width, offset)+" AS bucket, COUNT(*) AS jobs, "+
+			"COUNT(COALESCE(started_at, dispatched_at)) AS started, "+
+			"COALESCE(SUM(("+gpuUnitsExpr+") * ("+end+" - "+r.epochExpr("created_at")+")), 0) AS requested_gpu_unit_seconds, "+
+			"COALESCE(SUM(CASE WHEN "+start+" IS NULL THEN 0 ELSE ("+gpuUnitsExpr+") * ("+end+" - "+start+") END), 0) AS granted_gpu_unit_seconds",
+			job.GPUTypeDedicated, job.GPUTypeDedicated).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// WaitTimeCounts counts the started jobs created in [from, to) per bucket and wait time in
+// seconds, from submission to the start (or the dispatch when no start was recorded).
+func (r *DBJobRepo) WaitTimeCounts(from, to time.Time, width, offset int64) ([]WaitTimeCount, error) {
+	var rows []WaitTimeCount
+	err := r.db.Model(&job.Job{}).
+		Select(r.bucketExpr(width, offset)+" AS bucket, "+
+			"("+r.epochExpr("COALESCE(started_at, dispatched_at)")+" - "+r.epochExpr("created_at")+") AS wait_seconds, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Where("COALESCE(started_at, dispatched_at) IS NOT NULL").
+		Group("bucket, wait_seconds").
+		Order("bucket, wait_seconds").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CountActiveByPriority counts the jobs in one of statuses, compared case-insensitively, per priority.
+func (r *DBJobRepo) CountActiveByPriority(statuses []string) ([]PriorityCount, error) {
+	var rows []PriorityCount
+	err := r.db.Model(&job.Job{}).
+		Select("priority, COUNT(*) AS count").
+		Where("LOWER(status) IN ?", statuses).
+		Group("priority").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// SumGPUUnits adds up the GPU units requested by the jobs in one of statuses.
+func (r *DBJobRepo) SumGPUUnits(statuses []string) (int64, error) {
+	var units int64
+	err := r.db.Model(&job.Job{}).
+		Select("COALESCE(SUM("+gpuUnitsExpr+"), 0)", job.GPUTypeDedicated).
+		Where("LOWER(status) IN ?", statuses).
+		Scan(&units).Error
+	return units, err
+}
+
 func (r *DBJobRepo) WithTx(tx *gorm.DB) JobRepo {
 	if tx == nil {
 		return r
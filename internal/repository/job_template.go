@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"gorm.io/gorm"
+)
+
+type JobTemplateRepo interface {
+	CreateJobTemplate(t *job.JobTemplate) error
+	GetJobTemplateByID(id uint) (*job.JobTemplate, error)
+	UpdateJobTemplate(t *job.JobTemplate) error
+	DeleteJobTemplate(id uint) error
+	ListJobTemplatesByUser(userID uint, projectID *uint) ([]job.JobTemplate, error)
+	WithTx(tx *gorm.DB) JobTemplateRepo
+}
+
+type DBJobTemplateRepo struct {
+	db *gorm.DB
+}
+
+func NewJobTemplateRepo(db *gorm.DB) *DBJobTemplateRepo {
+	return &DBJobTemplateRepo{
+		db: db,
+	}
+}
+
+func (r *DBJobTemplateRepo) CreateJobTemplate(t *job.JobTemplate) error {
+	return r.db.Create(t).Error
+}
+
+func (r *DBJobTemplateRepo) GetJobTemplateByID(id uint) (*job.JobTemplate, error) {
+	var t job.JobTemplate
+	if err := r.db.First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *DBJobTemplateRepo) UpdateJobTemplate(t *job.JobTemplate) error {
+	return r.db.Save(t).Error
+}
+
+func (r *DBJobTemplateRepo) DeleteJobTemplate(id uint) error {
+	return r.db.Delete(&job.JobTemplate{}, id).Error
+}
+
+// ListJobTemplatesByUser returns the user's templates, limited to one project when projectID is set.
+func (r *DBJobTemplateRepo) ListJobTemplatesByUser(userID uint, projectID *uint) ([]job.JobTemplate, error) {
+	var list []job.JobTemplate
+	query := r.db.Where("user_id = ?", userID)
+	if projectID != nil {
+		query = query.Where("project_id = ?", *projectID)
+	}
+	if err := query.Order("name").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *DBJobTemplateRepo) WithTx(tx *gorm.DB) JobTemplateRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBJobTemplateRepo{
+		db: tx,
+	}
+}
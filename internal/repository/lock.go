@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// namespaceLocks serializes provisioning within this process, where the database cannot: the
+// sqlite of the tests has no advisory locks.
+var namespaceLocks = &keyedMutex{locks: make(map[string]*keyedLock)}
+
+// WithNamespaceLock runs fn while holding an advisory lock on the namespace, so concurrent
+// provisioning of the same namespace, from this replica or another one, runs one at a time and
+// the later call finds what the first one created. On Postgres the lock is a transaction-level
+// pg_advisory_xact_lock released when fn returns; other databases only lock within the process.
+func (r *Repos) WithNamespaceLock(ns string, fn func() error) error {
+	unlock := namespaceLocks.lock(ns)
+	defer unlock()
+
+	if r == nil || r.db == nil || r.db.Dialector.Name() != "postgres" {
+		return fn()
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", namespaceLockKey(ns)).Error; err != nil {
+			return fmt.Errorf("failed to lock namespace %s: %w", ns, err)
+		}
+		return fn()
+	})
+}
+
+// namespaceLockKey maps a namespace onto the bigint key space of Postgres advisory locks.
+func namespaceLockKey(ns string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("namespace:" + ns))
+	return int64(h.Sum64())
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// keyedMutex hands out one mutex per key and forgets it once nobody holds or waits for it.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
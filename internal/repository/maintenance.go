@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/maintenance"
+	"gorm.io/gorm"
+)
+
+// maintenanceRowID is the primary key of the single maintenance row.
+const maintenanceRowID = 1
+
+type MaintenanceRepo interface {
+	Get() (*maintenance.Maintenance, error)
+	Save(m *maintenance.Maintenance) error
+	WithTx(tx *gorm.DB) MaintenanceRepo
+}
+
+type DBMaintenanceRepo struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceRepo(db *gorm.DB) *DBMaintenanceRepo {
+	return &DBMaintenanceRepo{
+		db: db,
+	}
+}
+
+// Get returns the maintenance flag, inactive if it was never set.
+func (r *DBMaintenanceRepo) Get() (*maintenance.Maintenance, error) {
+	var m maintenance.Maintenance
+	if err := r.db.Where("id = ?", maintenanceRowID).Limit(1).Find(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *DBMaintenanceRepo) Save(m *maintenance.Maintenance) error {
+	m.ID = maintenanceRowID
+	return r.db.Save(m).Error
+}
+
+func (r *DBMaintenanceRepo) WithTx(tx *gorm.DB) MaintenanceRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBMaintenanceRepo{
+		db: tx,
+	}
+}
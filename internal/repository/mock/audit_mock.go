@@ -6,6 +6,7 @@ package mock
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	audit "github.com/linskybing/platform-go/internal/domain/audit"
@@ -36,6 +37,21 @@ func (m *MockAuditRepo) EXPECT() *MockAuditRepoMockRecorder {
 	return m.recorder
 }
 
+// CountAuditLogsBefore mocks base method.
+func (m *MockAuditRepo) CountAuditLogsBefore(cutoff time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountAuditLogsBefore", cutoff)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountAuditLogsBefore indicates an expected call of CountAuditLogsBefore.
+func (mr *MockAuditRepoMockRecorder) CountAuditLogsBefore(cutoff interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountAuditLogsBefore", reflect.TypeOf((*MockAuditRepo)(nil).CountAuditLogsBefore), cutoff)
+}
+
 // CreateAuditLog mocks base method.
 func (m *MockAuditRepo) CreateAuditLog(audit *audit.AuditLog) error {
 	m.ctrl.T.Helper()
@@ -50,19 +66,19 @@ func (mr *MockAuditRepoMockRecorder) CreateAuditLog(audit interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuditLog", reflect.TypeOf((*MockAuditRepo)(nil).CreateAuditLog), audit)
 }
 
-// GetAuditLogs mocks base method.
-func (m *MockAuditRepo) GetAuditLogs(params repository.AuditQueryParams) ([]audit.AuditLog, error) {
+// DeleteAuditLogsBefore mocks base method.
+func (m *MockAuditRepo) DeleteAuditLogsBefore(cutoff time.Time, limit int) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAuditLogs", params)
-	ret0, _ := ret[0].([]audit.AuditLog)
+	ret := m.ctrl.Call(m, "DeleteAuditLogsBefore", cutoff, limit)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAuditLogs indicates an expected call of GetAuditLogs.
-func (mr *MockAuditRepoMockRecorder) GetAuditLogs(params interface{}) *gomock.Call {
+// DeleteAuditLogsBefore indicates an expected call of DeleteAuditLogsBefore.
+func (mr *MockAuditRepoMockRecorder) DeleteAuditLogsBefore(cutoff, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditLogs", reflect.TypeOf((*MockAuditRepo)(nil).GetAuditLogs), params)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAuditLogsBefore", reflect.TypeOf((*MockAuditRepo)(nil).DeleteAuditLogsBefore), cutoff, limit)
 }
 
 // DeleteOldAuditLogs mocks base method.
@@ -79,6 +95,21 @@ func (mr *MockAuditRepoMockRecorder) DeleteOldAuditLogs(retentionDays interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOldAuditLogs", reflect.TypeOf((*MockAuditRepo)(nil).DeleteOldAuditLogs), retentionDays)
 }
 
+// GetAuditLogs mocks base method.
+func (m *MockAuditRepo) GetAuditLogs(params repository.AuditQueryParams) ([]audit.AuditLog, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditLogs", params)
+	ret0, _ := ret[0].([]audit.AuditLog)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditLogs indicates an expected call of GetAuditLogs.
+func (mr *MockAuditRepoMockRecorder) GetAuditLogs(params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditLogs", reflect.TypeOf((*MockAuditRepo)(nil).GetAuditLogs), params)
+}
+
 // WithTx mocks base method.
 func (m *MockAuditRepo) WithTx(tx *gorm.DB) repository.AuditRepo {
 	m.ctrl.T.Helper()
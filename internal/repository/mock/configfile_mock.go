@@ -6,6 +6,7 @@ package mock
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	configfile "github.com/linskybing/platform-go/internal/domain/configfile"
@@ -79,6 +80,21 @@ func (mr *MockConfigFileRepoMockRecorder) UpdateConfigFile(cf interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConfigFile", reflect.TypeOf((*MockConfigFileRepo)(nil).UpdateConfigFile), cf)
 }
 
+// UpdateConfigFileIfVersion mocks base method.
+func (m *MockConfigFileRepo) UpdateConfigFileIfVersion(cf *configfile.ConfigFile, version int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConfigFileIfVersion", cf, version)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateConfigFileIfVersion indicates an expected call of UpdateConfigFileIfVersion.
+func (mr *MockConfigFileRepoMockRecorder) UpdateConfigFileIfVersion(cf, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConfigFileIfVersion", reflect.TypeOf((*MockConfigFileRepo)(nil).UpdateConfigFileIfVersion), cf, version)
+}
+
 // DeleteConfigFile mocks base method.
 func (m *MockConfigFileRepo) DeleteConfigFile(id uint) error {
 	m.ctrl.T.Helper()
@@ -93,6 +109,79 @@ func (mr *MockConfigFileRepoMockRecorder) DeleteConfigFile(id interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConfigFile", reflect.TypeOf((*MockConfigFileRepo)(nil).DeleteConfigFile), id)
 }
 
+// GetTrashedConfigFileByID mocks base method.
+func (m *MockConfigFileRepo) GetTrashedConfigFileByID(id uint) (*configfile.ConfigFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrashedConfigFileByID", id)
+	ret0, _ := ret[0].(*configfile.ConfigFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrashedConfigFileByID indicates an expected call of GetTrashedConfigFileByID.
+func (mr *MockConfigFileRepoMockRecorder) GetTrashedConfigFileByID(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrashedConfigFileByID", reflect.TypeOf((*MockConfigFileRepo)(nil).GetTrashedConfigFileByID), id)
+}
+
+// ListTrashedConfigFiles mocks base method.
+func (m *MockConfigFileRepo) ListTrashedConfigFiles(projectID uint) ([]configfile.ConfigFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrashedConfigFiles", projectID)
+	ret0, _ := ret[0].([]configfile.ConfigFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrashedConfigFiles indicates an expected call of ListTrashedConfigFiles.
+func (mr *MockConfigFileRepoMockRecorder) ListTrashedConfigFiles(projectID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrashedConfigFiles", reflect.TypeOf((*MockConfigFileRepo)(nil).ListTrashedConfigFiles), projectID)
+}
+
+// ListConfigFilesTrashedBefore mocks base method.
+func (m *MockConfigFileRepo) ListConfigFilesTrashedBefore(cutoff time.Time) ([]configfile.ConfigFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListConfigFilesTrashedBefore", cutoff)
+	ret0, _ := ret[0].([]configfile.ConfigFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListConfigFilesTrashedBefore indicates an expected call of ListConfigFilesTrashedBefore.
+func (mr *MockConfigFileRepoMockRecorder) ListConfigFilesTrashedBefore(cutoff interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListConfigFilesTrashedBefore", reflect.TypeOf((*MockConfigFileRepo)(nil).ListConfigFilesTrashedBefore), cutoff)
+}
+
+// RestoreConfigFile mocks base method.
+func (m *MockConfigFileRepo) RestoreConfigFile(id uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreConfigFile", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreConfigFile indicates an expected call of RestoreConfigFile.
+func (mr *MockConfigFileRepoMockRecorder) RestoreConfigFile(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreConfigFile", reflect.TypeOf((*MockConfigFileRepo)(nil).RestoreConfigFile), id)
+}
+
+// PurgeConfigFile mocks base method.
+func (m *MockConfigFileRepo) PurgeConfigFile(id uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeConfigFile", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeConfigFile indicates an expected call of PurgeConfigFile.
+func (mr *MockConfigFileRepoMockRecorder) PurgeConfigFile(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeConfigFile", reflect.TypeOf((*MockConfigFileRepo)(nil).PurgeConfigFile), id)
+}
+
 // ListConfigFiles mocks base method.
 func (m *MockConfigFileRepo) ListConfigFiles() ([]configfile.ConfigFile, error) {
 	m.ctrl.T.Helper()
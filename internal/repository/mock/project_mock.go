@@ -139,6 +139,50 @@ func (mr *MockProjectRepoMockRecorder) UpdateProject(p interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProject", reflect.TypeOf((*MockProjectRepo)(nil).UpdateProject), p)
 }
 
+// SetStorageNamespace mocks base method.
+func (m *MockProjectRepo) SetStorageNamespace(pID uint, ns string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStorageNamespace", pID, ns)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetStorageNamespace indicates an expected call of SetStorageNamespace.
+func (mr *MockProjectRepoMockRecorder) SetStorageNamespace(pID, ns interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStorageNamespace", reflect.TypeOf((*MockProjectRepo)(nil).SetStorageNamespace), pID, ns)
+}
+
+// GetProjectByStorageNamespace mocks base method.
+func (m *MockProjectRepo) GetProjectByStorageNamespace(ns string) (project.Project, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProjectByStorageNamespace", ns)
+	ret0, _ := ret[0].(project.Project)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProjectByStorageNamespace indicates an expected call of GetProjectByStorageNamespace.
+func (mr *MockProjectRepoMockRecorder) GetProjectByStorageNamespace(ns interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProjectByStorageNamespace", reflect.TypeOf((*MockProjectRepo)(nil).GetProjectByStorageNamespace), ns)
+}
+
+// ListProjectsWithoutStorageNamespace mocks base method.
+func (m *MockProjectRepo) ListProjectsWithoutStorageNamespace() ([]project.Project, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListProjectsWithoutStorageNamespace")
+	ret0, _ := ret[0].([]project.Project)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProjectsWithoutStorageNamespace indicates an expected call of ListProjectsWithoutStorageNamespace.
+func (mr *MockProjectRepoMockRecorder) ListProjectsWithoutStorageNamespace() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProjectsWithoutStorageNamespace", reflect.TypeOf((*MockProjectRepo)(nil).ListProjectsWithoutStorageNamespace))
+}
+
 // GetAllProjectGroupViews mocks base method.
 func (m *MockProjectRepo) GetAllProjectGroupViews() ([]view.ProjectGroupView, error) {
 	m.ctrl.T.Helper()
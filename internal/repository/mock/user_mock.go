@@ -66,6 +66,21 @@ func (mr *MockUserRepoMockRecorder) GetAllUsers() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsers", reflect.TypeOf((*MockUserRepo)(nil).GetAllUsers))
 }
 
+// GetUserByExternalSubject mocks base method.
+func (m *MockUserRepo) GetUserByExternalSubject(provider, subject string) (user.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByExternalSubject", provider, subject)
+	ret0, _ := ret[0].(user.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByExternalSubject indicates an expected call of GetUserByExternalSubject.
+func (mr *MockUserRepoMockRecorder) GetUserByExternalSubject(provider, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByExternalSubject", reflect.TypeOf((*MockUserRepo)(nil).GetUserByExternalSubject), provider, subject)
+}
+
 // GetUserByID mocks base method.
 func (m *MockUserRepo) GetUserByID(id uint) (user.UserWithSuperAdmin, error) {
 	m.ctrl.T.Helper()
@@ -96,6 +111,36 @@ func (mr *MockUserRepoMockRecorder) GetUserByUsername(username interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUsername", reflect.TypeOf((*MockUserRepo)(nil).GetUserByUsername), username)
 }
 
+// SetStorageID mocks base method.
+func (m *MockUserRepo) SetStorageID(id uint, storageID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStorageID", id, storageID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetStorageID indicates an expected call of SetStorageID.
+func (mr *MockUserRepoMockRecorder) SetStorageID(id, storageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStorageID", reflect.TypeOf((*MockUserRepo)(nil).SetStorageID), id, storageID)
+}
+
+// ListSuperAdmins mocks base method.
+func (m *MockUserRepo) ListSuperAdmins() ([]user.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSuperAdmins")
+	ret0, _ := ret[0].([]user.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSuperAdmins indicates an expected call of ListSuperAdmins.
+func (mr *MockUserRepoMockRecorder) ListSuperAdmins() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSuperAdmins", reflect.TypeOf((*MockUserRepo)(nil).ListSuperAdmins))
+}
+
 // GetUserRawByID mocks base method.
 func (m *MockUserRepo) GetUserRawByID(id uint) (user.User, error) {
 	m.ctrl.T.Helper()
@@ -126,6 +171,21 @@ func (mr *MockUserRepoMockRecorder) GetUsernameByID(id interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsernameByID", reflect.TypeOf((*MockUserRepo)(nil).GetUsernameByID), id)
 }
 
+// ListUsersByProjectID mocks base method.
+func (m *MockUserRepo) ListUsersByProjectID(projectID uint) ([]view.ProjectUserView, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsersByProjectID", projectID)
+	ret0, _ := ret[0].([]view.ProjectUserView)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsersByProjectID indicates an expected call of ListUsersByProjectID.
+func (mr *MockUserRepoMockRecorder) ListUsersByProjectID(projectID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersByProjectID", reflect.TypeOf((*MockUserRepo)(nil).ListUsersByProjectID), projectID)
+}
+
 // ListUsersPaging mocks base method.
 func (m *MockUserRepo) ListUsersPaging(page, limit int) ([]user.UserWithSuperAdmin, error) {
 	m.ctrl.T.Helper()
@@ -155,21 +215,6 @@ func (mr *MockUserRepoMockRecorder) SaveUser(user interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveUser", reflect.TypeOf((*MockUserRepo)(nil).SaveUser), user)
 }
 
-// ListUsersByProjectID mocks base method.
-func (m *MockUserRepo) ListUsersByProjectID(projectID uint) ([]view.ProjectUserView, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListUsersByProjectID", projectID)
-	ret0, _ := ret[0].([]view.ProjectUserView)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// ListUsersByProjectID indicates an expected call of ListUsersByProjectID.
-func (mr *MockUserRepoMockRecorder) ListUsersByProjectID(projectID interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersByProjectID", reflect.TypeOf((*MockUserRepo)(nil).ListUsersByProjectID), projectID)
-}
-
 // WithTx mocks base method.
 func (m *MockUserRepo) WithTx(tx *gorm.DB) repository.UserRepo {
 	m.ctrl.T.Helper()
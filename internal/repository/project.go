@@ -11,6 +11,9 @@ type ProjectRepo interface {
 	GetGroupIDByProjectID(pID uint) (uint, error)
 	CreateProject(p *project.Project) error
 	UpdateProject(p *project.Project) error
+	SetStorageNamespace(pID uint, ns string) error
+	ListProjectsWithoutStorageNamespace() ([]project.Project, error)
+	GetProjectByStorageNamespace(ns string) (project.Project, error)
 	DeleteProject(id uint) error
 	ListProjects() ([]project.Project, error)
 	ListProjectsByGroup(id uint) ([]project.Project, error)
@@ -64,6 +67,25 @@ func (r *DBProjectRepo) UpdateProject(p *project.Project) error {
 	return r.db.Save(p).Error
 }
 
+// SetStorageNamespace records the storage namespace of a project.
+func (r *DBProjectRepo) SetStorageNamespace(pID uint, ns string) error {
+	return r.db.Model(&project.Project{}).Where("p_id = ?", pID).Update("storage_namespace", ns).Error
+}
+
+// ListProjectsWithoutStorageNamespace returns the projects created before storage namespaces were recorded.
+func (r *DBProjectRepo) ListProjectsWithoutStorageNamespace() ([]project.Project, error) {
+	var projects []project.Project
+	err := r.db.Where("storage_namespace IS NULL OR storage_namespace = ''").Find(&projects).Error
+	return projects, err
+}
+
+// GetProjectByStorageNamespace returns the project whose storage namespace is ns.
+func (r *DBProjectRepo) GetProjectByStorageNamespace(ns string) (project.Project, error) {
+	var p project.Project
+	err := r.db.Where("storage_namespace = ?", ns).First(&p).Error
+	return p, err
+}
+
 func (r *DBProjectRepo) DeleteProject(id uint) error {
 	return r.db.Delete(&project.Project{}, id).Error
 }
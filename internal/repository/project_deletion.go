@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"gorm.io/gorm"
+)
+
+type ProjectDeletionRepo interface {
+	Create(d *project.ProjectDeletion) error
+	Update(d *project.ProjectDeletion) error
+	GetUnfinishedByProject(pID uint) (*project.ProjectDeletion, error)
+	ListByProject(pID uint) ([]project.ProjectDeletion, error)
+	WithTx(tx *gorm.DB) ProjectDeletionRepo
+}
+
+type DBProjectDeletionRepo struct {
+	db *gorm.DB
+}
+
+func NewProjectDeletionRepo(db *gorm.DB) *DBProjectDeletionRepo {
+	return &DBProjectDeletionRepo{
+		db: db,
+	}
+}
+
+func (r *DBProjectDeletionRepo) Create(d *project.ProjectDeletion) error {
+	return r.db.Create(d).Error
+}
+
+func (r *DBProjectDeletionRepo) Update(d *project.ProjectDeletion) error {
+	return r.db.Save(d).Error
+}
+
+// GetUnfinishedByProject returns the latest deletion of the project that has not completed.
+func (r *DBProjectDeletionRepo) GetUnfinishedByProject(pID uint) (*project.ProjectDeletion, error) {
+	var d project.ProjectDeletion
+	err := r.db.Where("p_id = ? AND status <> ?", pID, project.DeletionDone).Order("id DESC").First(&d).Error
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DBProjectDeletionRepo) ListByProject(pID uint) ([]project.ProjectDeletion, error) {
+	var deletions []project.ProjectDeletion
+	err := r.db.Where("p_id = ?", pID).Order("id DESC").Find(&deletions).Error
+	return deletions, err
+}
+
+func (r *DBProjectDeletionRepo) WithTx(tx *gorm.DB) ProjectDeletionRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBProjectDeletionRepo{
+		db: tx,
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProjectEnvRepo interface {
+	ListByProject(pID uint) ([]project.ProjectEnvDefault, error)
+	Upsert(e *project.ProjectEnvDefault) error
+	Delete(pID uint, key string) error
+	WithTx(tx *gorm.DB) ProjectEnvRepo
+}
+
+type DBProjectEnvRepo struct {
+	db *gorm.DB
+}
+
+func NewProjectEnvRepo(db *gorm.DB) *DBProjectEnvRepo {
+	return &DBProjectEnvRepo{
+		db: db,
+	}
+}
+
+func (r *DBProjectEnvRepo) ListByProject(pID uint) ([]project.ProjectEnvDefault, error) {
+	var envs []project.ProjectEnvDefault
+	err := r.db.Where("p_id = ?", pID).Order("key").Find(&envs).Error
+	return envs, err
+}
+
+// Upsert creates the key for the project or overwrites its value and secret flag.
+func (r *DBProjectEnvRepo) Upsert(e *project.ProjectEnvDefault) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "p_id"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "secret", "update_at"}),
+	}).Create(e).Error
+}
+
+func (r *DBProjectEnvRepo) Delete(pID uint, key string) error {
+	return r.db.Where("p_id = ? AND key = ?", pID, key).Delete(&project.ProjectEnvDefault{}).Error
+}
+
+func (r *DBProjectEnvRepo) WithTx(tx *gorm.DB) ProjectEnvRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBProjectEnvRepo{
+		db: tx,
+	}
+}
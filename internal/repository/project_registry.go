@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RegistryCredentialRepo interface {
+	ListByProject(pID uint) ([]project.RegistryCredential, error)
+	GetByID(pID, id uint) (*project.RegistryCredential, error)
+	Upsert(cred *project.RegistryCredential) error
+	Delete(pID, id uint) error
+	WithTx(tx *gorm.DB) RegistryCredentialRepo
+}
+
+type DBRegistryCredentialRepo struct {
+	db *gorm.DB
+}
+
+func NewRegistryCredentialRepo(db *gorm.DB) *DBRegistryCredentialRepo {
+	return &DBRegistryCredentialRepo{
+		db: db,
+	}
+}
+
+func (r *DBRegistryCredentialRepo) ListByProject(pID uint) ([]project.RegistryCredential, error) {
+	var creds []project.RegistryCredential
+	err := r.db.Where("p_id = ?", pID).Order("registry").Find(&creds).Error
+	return creds, err
+}
+
+func (r *DBRegistryCredentialRepo) GetByID(pID, id uint) (*project.RegistryCredential, error) {
+	var cred project.RegistryCredential
+	if err := r.db.Where("p_id = ?", pID).First(&cred, id).Error; err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// Upsert creates the credential for the project and registry or replaces its username and token.
+func (r *DBRegistryCredentialRepo) Upsert(cred *project.RegistryCredential) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "p_id"}, {Name: "registry"}},
+		DoUpdates: clause.AssignmentColumns([]string{"username", "encrypted_token", "created_by", "update_at"}),
+	}).Create(cred).Error
+}
+
+func (r *DBRegistryCredentialRepo) Delete(pID, id uint) error {
+	res := r.db.Where("p_id = ?", pID).Delete(&project.RegistryCredential{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *DBRegistryCredentialRepo) WithTx(tx *gorm.DB) RegistryCredentialRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBRegistryCredentialRepo{
+		db: tx,
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProjectSchedulingRepo interface {
+	GetByProject(pID uint) (*project.SchedulingPolicy, error)
+	Upsert(p *project.SchedulingPolicy) error
+	Delete(pID uint) error
+	WithTx(tx *gorm.DB) ProjectSchedulingRepo
+}
+
+type DBProjectSchedulingRepo struct {
+	db *gorm.DB
+}
+
+func NewProjectSchedulingRepo(db *gorm.DB) *DBProjectSchedulingRepo {
+	return &DBProjectSchedulingRepo{
+		db: db,
+	}
+}
+
+func (r *DBProjectSchedulingRepo) GetByProject(pID uint) (*project.SchedulingPolicy, error) {
+	var p project.SchedulingPolicy
+	if err := r.db.Where("p_id = ?", pID).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Upsert creates the project's policy or replaces all of its fields.
+func (r *DBProjectSchedulingRepo) Upsert(p *project.SchedulingPolicy) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "p_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"tolerations", "node_selector", "affinity", "force", "update_at"}),
+	}).Create(p).Error
+}
+
+func (r *DBProjectSchedulingRepo) Delete(pID uint) error {
+	return r.db.Where("p_id = ?", pID).Delete(&project.SchedulingPolicy{}).Error
+}
+
+func (r *DBProjectSchedulingRepo) WithTx(tx *gorm.DB) ProjectSchedulingRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBProjectSchedulingRepo{
+		db: tx,
+	}
+}
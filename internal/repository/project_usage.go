@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/project"
+	"gorm.io/gorm"
+)
+
+// UsageBucket aggregates the project totals of the samplings falling in one bucket.
+type UsageBucket struct {
+	Bucket   int64
+	MaxValue float64
+	AvgValue float64
+}
+
+type ProjectUsageRepo interface {
+	CreateSamples(samples []project.UsageSample) error
+	DeleteSamplesBefore(unix int64) (int64, error)
+	// Buckets sums column over the namespaces of the project at each sampling in [start, end)
+	// and aggregates those totals in buckets of width seconds counted from start.
+	Buckets(pID uint, column string, start, end, width int64) ([]UsageBucket, error)
+	WithTx(tx *gorm.DB) ProjectUsageRepo
+}
+
+type DBProjectUsageRepo struct {
+	db *gorm.DB
+}
+
+func NewProjectUsageRepo(db *gorm.DB) *DBProjectUsageRepo {
+	return &DBProjectUsageRepo{
+		db: db,
+	}
+}
+
+func (r *DBProjectUsageRepo) CreateSamples(samples []project.UsageSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(samples, 200).Error
+}
+
+func (r *DBProjectUsageRepo) DeleteSamplesBefore(unix int64) (int64, error) {
+	res := r.db.Where("sampled_unix < ?", unix).Delete(&project.UsageSample{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *DBProjectUsageRepo) Buckets(pID uint, column string, start, end, width int64) ([]UsageBucket, error) {
+	totals := r.db.Model(&project.UsageSample{}).
+		Select("sampled_unix, SUM("+column+") AS total").
+		Where("p_id = ? AND sampled_unix >= ? AND sampled_unix < ?", pID, start, end).
+		Group("sampled_unix")
+
+	var buckets []UsageBucket
+	err := r.db.Table("(?) AS totals", totals).
+		Select("(sampled_unix - ?) / ? AS bucket, MAX(total) AS max_value, AVG(total) AS avg_value", start, width).
+		Group("bucket").
+		Order("bucket").
+		Scan(&buckets).Error
+	return buckets, err
+}
+
+func (r *DBProjectUsageRepo) WithTx(tx *gorm.DB) ProjectUsageRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBProjectUsageRepo{
+		db: tx,
+	}
+}
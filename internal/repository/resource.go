@@ -60,7 +60,7 @@ func (r *DBResourceRepo) ListResourcesByProjectID(pid uint) ([]resource.Resource
 	var resources []resource.Resource
 	err := r.db.
 		Joins("JOIN config_files cf ON cf.cf_id = resources.cf_id").
-		Where("cf.project_id = ?", pid).
+		Where("cf.project_id = ? AND cf.deleted_at IS NULL", pid).
 		Find(&resources).Error
 	return resources, err
 }
@@ -97,7 +97,7 @@ func (r *DBResourceRepo) GetProjectResourcesByGroupID(groupID uint) ([]view.Proj
             r.r_id, r.type, r.name, 
             cf.filename, r.create_at AS resource_create_at
         `).
-		Joins("JOIN config_files cf ON cf.project_id = p.p_id").
+		Joins("JOIN config_files cf ON cf.project_id = p.p_id AND cf.deleted_at IS NULL").
 		Joins("JOIN resource_list r ON r.cf_id = cf.cf_id").
 		Where("p.g_id = ?", groupID).
 		Scan(&results).Error
@@ -116,7 +116,7 @@ func (r *DBResourceRepo) GetGroupResourcesByGroupID(groupID uint) ([]view.GroupR
             cf.filename, r.create_at AS resource_create_at
         `).
 		Joins("LEFT JOIN project_list p ON p.g_id = g.g_id").
-		Joins("LEFT JOIN config_files cf ON cf.project_id = p.p_id").
+		Joins("LEFT JOIN config_files cf ON cf.project_id = p.p_id AND cf.deleted_at IS NULL").
 		Joins("LEFT JOIN resource_list r ON r.cf_id = cf.cf_id").
 		Where("g.g_id = ? AND r.r_id IS NOT NULL", groupID).
 		Scan(&results).Error
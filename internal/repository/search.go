@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/domain/search"
+	"gorm.io/gorm"
+)
+
+// SearchRepo finds entities whose name or text contains a query, case-insensitively. Every
+// method returns at most limit hits of the scope, newest first, after the cursor when given.
+// The LOWER(col) LIKE conditions are served by the pg_trgm indexes of db.CreateSearchIndexes.
+type SearchRepo interface {
+	Projects(scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error)
+	ConfigFiles(scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error)
+	Jobs(scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error)
+	Images(scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error)
+	WithTx(tx *gorm.DB) SearchRepo
+}
+
+type DBSearchRepo struct {
+	db *gorm.DB
+}
+
+func NewSearchRepo(db *gorm.DB) *DBSearchRepo {
+	return &DBSearchRepo{
+		db: db,
+	}
+}
+
+// likePattern matches query anywhere in a lowercased column, with LIKE wildcards in it escaped.
+func likePattern(query string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(query))
+	return "%" + escaped + "%"
+}
+
+// memberProjects selects the IDs of the projects of the groups the user belongs to.
+func (r *DBSearchRepo) memberProjects(userID uint) *gorm.DB {
+	return r.db.Table("project_list mp").
+		Select("mp.p_id").
+		Joins("JOIN user_group mug ON mug.g_id = mp.g_id").
+		Where("mug.u_id = ?", userID)
+}
+
+// page orders hits newest first and applies the cursor and limit, given the columns holding
+// each hit's update time and ID.
+func page(q *gorm.DB, updatedCol, idCol string, after *search.Cursor, limit int) *gorm.DB {
+	if after != nil {
+		q = q.Where("("+updatedCol+" < ? OR ("+updatedCol+" = ? AND "+idCol+" < ?))", after.UpdatedAt, after.UpdatedAt, after.ID)
+	}
+	return q.Order(updatedCol + " DESC").Order(idCol + " DESC").Limit(limit)
+}
+
+// Projects searches the names and descriptions of the projects the user is a member of.
+func (r *DBSearchRepo) Projects(scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error) {
+	pattern := likePattern(query)
+	q := r.db.Table("project_list p").
+		Select("p.p_id AS id, p.project_name AS name, p.p_id AS project_id, p.update_at AS updated_at, p.description AS text").
+		Where(`(LOWER(p.project_name) LIKE ? ESCAPE '\' OR LOWER(p.description) LIKE ? ESCAPE '\')`, pattern, pattern)
+	if !scope.All {
+		q = q.Where("p.p_id IN (?)", r.memberProjects(scope.UserID))
+	}
+	return scanHits(page(q, "p.update_at", "p.p_id", after, limit), search.TypeProject)
+}
+
+// ConfigFiles searches the file names and YAML of the config files of the user's projects;
+// files in the trash are left out.
+func (r *DBSearchRepo) ConfigFiles(scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error) {
+	pattern := likePattern(query)
+	q := r.db.Table("config_files cf").
+		Select("cf.cf_id AS id, cf.filename AS name, cf.project_id AS project_id, cf.update_at AS updated_at, cf.content AS text").
+		Where("cf.deleted_at IS NULL").
+		Where(`(LOWER(cf.filename) LIKE ? ESCAPE '\' OR LOWER(cf.content) LIKE ? ESCAPE '\')`, pattern, pattern)
+	if !scope.All {
+		q = q.Where("cf.project_id IN (?)", r.memberProjects(scope.UserID))
+	}
+	return scanHits(page(q, "cf.update_at", "cf.cf_id", after, limit), search.TypeConfigFile)
+}
+
+// Jobs searches the names and images of the user's own jobs.
+func (r *DBSearchRepo) Jobs(scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error) {
+	pattern := likePattern(query)
+	q := r.db.Table("jobs j").
+		Select("j.id AS id, j.name AS name, COALESCE(j.project_id, 0) AS project_id, j.updated_at AS updated_at, j.image AS text").
+		Where(`(LOWER(j.name) LIKE ? ESCAPE '\' OR LOWER(j.image) LIKE ? ESCAPE '\')`, pattern, pattern)
+	if !scope.All {
+		q = q.Where("j.user_id = ?", scope.UserID)
+	}
+	return scanHits(page(q, "j.updated_at", "j.id", after, limit), search.TypeJob)
+}
+
+// Images searches the repositories and tags of the enabled allow-list rules that are global or
+// belong to one of the user's projects. The hit's text is the allowed tag, empty when every tag is.
+func (r *DBSearchRepo) Images(scope search.Scope, query string, after *search.Cursor, limit int) ([]search.Hit, error) {
+	pattern := likePattern(query)
+	q := r.db.Table("image_allow_lists a").
+		Select("a.id AS id, cr.full_name AS name, COALESCE(a.project_id, 0) AS project_id, a.updated_at AS updated_at, COALESCE(ct.name, '') AS text").
+		Joins("JOIN container_repositories cr ON cr.id = a.repository_id AND cr.deleted_at IS NULL").
+		Joins("LEFT JOIN container_tags ct ON ct.id = a.tag_id AND ct.deleted_at IS NULL").
+		Where("a.deleted_at IS NULL AND a.is_enabled = ?", true).
+		Where(`(LOWER(cr.full_name) LIKE ? ESCAPE '\' OR LOWER(ct.name) LIKE ? ESCAPE '\')`, pattern, pattern)
+	if !scope.All {
+		q = q.Where("(a.project_id IS NULL OR a.project_id IN (?))", r.memberProjects(scope.UserID))
+	}
+	return scanHits(page(q, "a.updated_at", "a.id", after, limit), search.TypeImage)
+}
+
+func scanHits(q *gorm.DB, hitType string) ([]search.Hit, error) {
+	var hits []search.Hit
+	if err := q.Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		hits[i].Type = hitType
+	}
+	return hits, nil
+}
+
+func (r *DBSearchRepo) WithTx(tx *gorm.DB) SearchRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBSearchRepo{
+		db: tx,
+	}
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"github.com/linskybing/platform-go/internal/domain/setting"
+	"gorm.io/gorm"
+)
+
+type SettingRepo interface {
+	List() ([]setting.Setting, error)
+	Save(s *setting.Setting) error
+	Delete(key string) error
+	WithTx(tx *gorm.DB) SettingRepo
+}
+
+type DBSettingRepo struct {
+	db *gorm.DB
+}
+
+func NewSettingRepo(db *gorm.DB) *DBSettingRepo {
+	return &DBSettingRepo{
+		db: db,
+	}
+}
+
+// List returns every stored setting.
+func (r *DBSettingRepo) List() ([]setting.Setting, error) {
+	var settings []setting.Setting
+	err := r.db.Order("key").Find(&settings).Error
+	return settings, err
+}
+
+// Save inserts the setting or replaces its value.
+func (r *DBSettingRepo) Save(s *setting.Setting) error {
+	return r.db.Save(s).Error
+}
+
+// Delete removes a stored setting; deleting one that was never stored is not an error.
+func (r *DBSettingRepo) Delete(key string) error {
+	return r.db.Where("key = ?", key).Delete(&setting.Setting{}).Error
+}
+
+func (r *DBSettingRepo) WithTx(tx *gorm.DB) SettingRepo {
+	if tx == nil {
+		return r
+	}
+	return &DBSettingRepo{
+		db: tx,
+	}
+}
@@ -12,10 +12,13 @@ type UserRepo interface {
 	GetUserByID(id uint) (user.UserWithSuperAdmin, error)
 	GetUsernameByID(id uint) (string, error)
 	GetUserByUsername(username string) (user.User, error)
+	GetUserByExternalSubject(provider, subject string) (user.User, error)
 	GetUserRawByID(id uint) (user.User, error)
 	SaveUser(user *user.User) error
+	SetStorageID(id uint, storageID string) (bool, error)
 	DeleteUser(id uint) error
 	ListUsersByProjectID(projectID uint) ([]view.ProjectUserView, error)
+	ListSuperAdmins() ([]user.User, error)
 	WithTx(tx *gorm.DB) UserRepo
 }
 
@@ -52,6 +55,14 @@ func (r *DBUserRepo) GetUserByUsername(username string) (user.User, error) {
 	return u, nil
 }
 
+func (r *DBUserRepo) GetUserByExternalSubject(provider, subject string) (user.User, error) {
+	var u user.User
+	if err := r.db.Where("auth_provider = ? AND external_subject = ?", provider, subject).First(&u).Error; err != nil {
+		return u, err
+	}
+	return u, nil
+}
+
 func (r *DBUserRepo) ListUsersPaging(page, limit int) ([]user.UserWithSuperAdmin, error) {
 	var users []user.UserWithSuperAdmin
 
@@ -99,6 +110,17 @@ func (r *DBUserRepo) SaveUser(user *user.User) error {
 	return r.db.Save(user).Error
 }
 
+// SetStorageID stores the storage identifier of a user that has none yet and reports whether it
+// did. It is not stored when another user holds it. Only the storage_id column is written, so
+// concurrent edits of the row are kept.
+func (r *DBUserRepo) SetStorageID(id uint, storageID string) (bool, error) {
+	res := r.db.Model(&user.User{}).
+		Where("u_id = ? AND storage_id = ''", id).
+		Where("NOT EXISTS (SELECT 1 FROM users holder WHERE holder.storage_id = ?)", storageID).
+		UpdateColumn("storage_id", storageID)
+	return res.RowsAffected > 0, res.Error
+}
+
 func (r *DBUserRepo) DeleteUser(id uint) error {
 	return r.db.Delete(&user.User{}, id).Error
 }
@@ -121,6 +143,16 @@ func (r *DBUserRepo) ListUsersByProjectID(projectID uint) ([]view.ProjectUserVie
 	return results, err
 }
 
+// ListSuperAdmins returns the admins of the super group and user 1, who is always a super admin.
+func (r *DBUserRepo) ListSuperAdmins() ([]user.User, error) {
+	var users []user.User
+	admins := r.db.Table("user_group ug").Select("ug.u_id").
+		Joins("JOIN group_list g ON g.g_id = ug.g_id").
+		Where("g.group_name = ? AND ug.role = ?", "super", "admin")
+	err := r.db.Where("u_id = ? OR u_id IN (?)", 1, admins).Order("u_id").Find(&users).Error
+	return users, err
+}
+
 func (r *DBUserRepo) WithTx(tx *gorm.DB) UserRepo {
 	if tx == nil {
 		return r
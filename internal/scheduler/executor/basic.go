@@ -25,28 +25,23 @@ func (e *BasicExecutor) Execute(ctx context.Context, j *job.Job) error {
 	return nil
 }
 
-func (e *BasicExecutor) Cancel(ctx context.Context, jobID uint) error {
-	if e.jobRepo != nil {
-		if obj, err := e.jobRepo.FindByID(jobID); err == nil {
-			obj.Status = string(job.StatusCancelled)
-			return e.jobRepo.Update(obj)
-		}
-	}
+func (e *BasicExecutor) Cancel(ctx context.Context, j *job.Job) error {
+	// Placeholder: nothing runs, so there is nothing to stop.
 	return nil
 }
 
-func (e *BasicExecutor) GetStatus(ctx context.Context, jobID uint) (job.JobStatus, error) {
+func (e *BasicExecutor) Status(ctx context.Context, j *job.Job) (job.JobStatus, bool, error) {
 	if e.jobRepo == nil {
-		return job.StatusPending, nil
+		return job.JobStatus(j.Status), false, nil
 	}
-	obj, err := e.jobRepo.FindByID(jobID)
+	obj, err := e.jobRepo.FindByID(j.ID)
 	if err != nil {
-		return job.StatusPending, err
+		return job.StatusPending, false, err
 	}
-	return job.JobStatus(obj.Status), nil
+	return job.JobStatus(obj.Status), false, nil
 }
 
-func (e *BasicExecutor) GetLogs(ctx context.Context, jobID uint) (string, error) {
+func (e *BasicExecutor) GetLogs(ctx context.Context, j *job.Job) (string, error) {
 	// Not implemented: return empty
 	return "", nil
 }
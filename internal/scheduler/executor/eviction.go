@@ -0,0 +1,204 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// evictionWatchRetry is how long WatchEvictions waits before watching again after the watch ended.
+var evictionWatchRetry = 5 * time.Second
+
+// Pod status reasons of a pod stopped because of its node rather than its own work
+var evictionReasons = map[string]bool{
+	"Evicted":      true,
+	"NodeShutdown": true,
+}
+
+// WithEvictionHooks sets what happens to a job interrupted by the eviction of its pod:
+// onInterrupted is told about it, e.g. to notify the owner, and resubmit queues a job that
+// restarts on eviction again.
+func (e *K8sExecutor) WithEvictionHooks(onInterrupted func(ctx context.Context, j *job.Job, resubmitted bool), resubmit func(ctx context.Context, j *job.Job) error) *K8sExecutor {
+	e.onInterrupted = onInterrupted
+	e.resubmit = resubmit
+	return e
+}
+
+// WatchEvictions follows the pods of platform jobs and interrupts each job whose pod is evicted,
+// or deleted while the job runs, e.g. when its node is drained. It returns when ctx ends.
+func (e *K8sExecutor) WatchEvictions(ctx context.Context) {
+	if k8s.Clientset == nil || e.jobRepo == nil {
+		return
+	}
+	for {
+		w, err := k8s.Clientset.CoreV1().Pods(metav1.NamespaceAll).Watch(ctx, metav1.ListOptions{LabelSelector: k8s.ManagedSelector})
+		if err != nil {
+			log.Printf("watch job pods err: %v", err)
+		} else {
+			e.handlePodEvents(ctx, w)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(evictionWatchRetry):
+		}
+	}
+}
+
+// handlePodEvents handles the events of w until it ends or ctx does.
+func (e *K8sExecutor) handlePodEvents(ctx context.Context, w watch.Interface) {
+	defer w.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			if pod, isPod := ev.Object.(*corev1.Pod); isPod {
+				e.handlePodEvent(ctx, ev.Type, pod)
+			}
+		}
+	}
+}
+
+// handlePodEvent interrupts the job of pod when the event shows the pod was evicted or deleted
+// before it finished.
+func (e *K8sExecutor) handlePodEvent(ctx context.Context, eventType watch.EventType, pod *corev1.Pod) {
+	id, ok := labelJobID(pod.Labels)
+	if !ok {
+		return
+	}
+	reason, message := podInterruption(eventType, pod)
+	if reason == "" {
+		return
+	}
+	j, err := e.jobRepo.FindByID(id)
+	if err != nil || !isRunning(j.Status) {
+		return
+	}
+	// Pods of the run a restart replaced are deleted while the new run starts
+	if !e.ownedByCurrentRun(ctx, j, pod) {
+		return
+	}
+	e.interrupt(ctx, j, pod, reason, message)
+}
+
+// podInterruption returns the reason and message of a pod stopped before it finished, or empty
+// strings for any other event.
+func podInterruption(eventType watch.EventType, pod *corev1.Pod) (string, string) {
+	node := pod.Spec.NodeName
+	if node == "" {
+		node = "its node"
+	}
+	if evictionReasons[pod.Status.Reason] {
+		msg := fmt.Sprintf("pod %s was stopped on %s (%s)", pod.Name, node, pod.Status.Reason)
+		if pod.Status.Message != "" {
+			msg += ": " + pod.Status.Message
+		}
+		return pod.Status.Reason, msg
+	}
+	if eventType != watch.Deleted || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return "", ""
+	}
+	// Drains evict through the eviction API, which marks the pod before deleting it
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.DisruptionTarget && c.Status == corev1.ConditionTrue {
+			msg := fmt.Sprintf("pod %s was evicted from %s (%s)", pod.Name, node, c.Reason)
+			if c.Message != "" {
+				msg += ": " + c.Message
+			}
+			return "Evicted", msg
+		}
+	}
+	return "PodDeleted", fmt.Sprintf("pod %s was deleted from %s while the job ran", pod.Name, node)
+}
+
+// ownedByCurrentRun reports whether pod belongs to the K8s Job j currently runs as.
+func (e *K8sExecutor) ownedByCurrentRun(ctx context.Context, j *job.Job, pod *corev1.Pod) bool {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "Job" {
+		return true
+	}
+	current, err := k8s.Clientset.BatchV1().Jobs(j.Namespace).Get(ctx, j.K8sJobName, metav1.GetOptions{})
+	return err == nil && current.UID == owner.UID
+}
+
+// interrupt records that j stopped with its pod, stops the K8s Job so Kubernetes does not start
+// a replacement on its own, and queues j again when it restarts on eviction.
+func (e *K8sExecutor) interrupt(ctx context.Context, j *job.Job, pod *corev1.Pod, reason, message string) {
+	now := time.Now()
+	j.Status = string(job.JobStatusInterrupted)
+	j.ErrorMessage = message
+	j.CompletedAt = &now
+	if err := e.jobRepo.Update(j); err != nil {
+		log.Printf("update job %d interrupted status failed: %v", j.ID, err)
+		return
+	}
+	log.Printf("Job %d interrupted: %s", j.ID, message)
+
+	sum := sha256.Sum256([]byte("interruption/" + pod.Name + "/" + reason))
+	event := &job.JobEvent{
+		JobID:       j.ID,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		Source:      job.EventSourcePlatform,
+		ObjectKind:  "Pod",
+		ObjectName:  pod.Name,
+		Reason:      reason,
+		Message:     message,
+		Severity:    job.SeverityWarning,
+		Count:       1,
+		FirstSeen:   now,
+		LastSeen:    now,
+	}
+	if err := e.jobRepo.SaveEvent(event); err != nil {
+		log.Printf("save eviction of job %d failed: %v", j.ID, err)
+	}
+	recordStatus(e.jobRepo, j, now)
+
+	if err := e.Cancel(ctx, j); err != nil {
+		log.Printf("failed to stop interrupted job %d: %v", j.ID, err)
+	}
+
+	resubmitted := false
+	if j.RestartOnEviction && e.resubmit != nil {
+		if err := e.resubmit(ctx, j); err != nil {
+			log.Printf("failed to resubmit interrupted job %d: %v", j.ID, err)
+		} else {
+			resubmitted = true
+		}
+	}
+	if e.onInterrupted != nil {
+		e.onInterrupted(ctx, j, resubmitted)
+	}
+}
+
+// labelJobID reads the job ID of a platform pod.
+func labelJobID(labels map[string]string) (uint, bool) {
+	id, err := strconv.ParseUint(labels[k8s.LabelJobID], 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// isRunning reports whether a job in this status has been handed to the cluster and not finished.
+func isRunning(status string) bool {
+	switch job.JobStatus(strings.ToLower(status)) {
+	case job.StatusPending, job.JobStatusScheduling, job.JobStatusRunning:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	jobapp "github.com/linskybing/platform-go/internal/application/job"
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
+	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+type interruption struct {
+	job         job.Job
+	resubmitted bool
+}
+
+// startEvictionWatch runs WatchEvictions over a cluster where job 1 runs as the K8s Job "train"
+// with the pod "train-abc" on node-a. It returns the client and the interruptions reported.
+func startEvictionWatch(t *testing.T, row *job.Job) (*k8sfake.Clientset, job.Repository, <-chan interruption) {
+	t.Helper()
+	db := dbtest.Open(t, &job.Job{}, &job.JobEvent{}, &job.JobCheckpoint{})
+	repo := repository.NewJobRepo(db)
+	if err := repo.Create(row); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	for i, path := range []string{"/ckpt/1", "/ckpt/2"} {
+		if err := db.Create(&job.JobCheckpoint{JobID: row.ID, CheckpointNum: i + 1, Path: path}).Error; err != nil {
+			t.Fatalf("create checkpoint: %v", err)
+		}
+	}
+
+	orig := k8s.Clientset
+	t.Cleanup(func() { k8s.Clientset = orig })
+	isController := true
+	client := k8sfake.NewSimpleClientset(
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "proj-1", UID: "run-1"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "train-abc", Namespace: "proj-1",
+				Labels:          k8s.Ownership{UserID: 7, JobID: row.ID}.Labels(),
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Job", Name: "train", UID: "run-1", Controller: &isController}},
+			},
+			Spec:   corev1.PodSpec{NodeName: "node-a"},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+	)
+	k8s.Clientset = client
+
+	interrupted := make(chan interruption, 1)
+	e := NewK8sExecutor(repo, nil).WithEvictionHooks(
+		func(ctx context.Context, j *job.Job, resubmitted bool) {
+			interrupted <- interruption{*j, resubmitted}
+		},
+		func(ctx context.Context, j *job.Job) error {
+			return jobapp.NewService(repo, nil, nil).RestartJob(ctx, j.ID, nil)
+		},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go e.WatchEvictions(ctx)
+
+	// Events are only delivered once the watch is established
+	deadline := time.Now().Add(5 * time.Second)
+	for !hasWatch(client) {
+		if time.Now().After(deadline) {
+			t.Fatal("the pod watch was not started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return client, repo, interrupted
+}
+
+func hasWatch(client *k8sfake.Clientset) bool {
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "watch" && a.GetResource().Resource == "pods" {
+			return true
+		}
+	}
+	return false
+}
+
+func waitForInterruption(t *testing.T, interrupted <-chan interruption) interruption {
+	t.Helper()
+	select {
+	case got := <-interrupted:
+		return got
+	case <-time.After(5 * time.Second):
+		t.Fatal("the eviction was not reported")
+	}
+	return interruption{}
+}
+
+func TestEvictedJobIsResubmittedFromItsLatestCheckpoint(t *testing.T) {
+	spec, _ := json.Marshal(k8s.JobSpec{Name: "train", Namespace: "proj-1", Image: "trainer", EnvVars: map[string]string{"EPOCHS": "10"}})
+	row := &job.Job{UserID: 7, Name: "train", Namespace: "proj-1", K8sJobName: "train", Image: "trainer",
+		Status: string(job.JobStatusRunning), RestartOnEviction: true, Spec: string(spec)}
+	client, repo, interrupted := startEvictionWatch(t, row)
+
+	pod, _ := client.CoreV1().Pods("proj-1").Get(context.Background(), "train-abc", metav1.GetOptions{})
+	pod.Status = corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted", Message: "The node was low on resource: memory."}
+	if _, err := client.CoreV1().Pods("proj-1").UpdateStatus(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("evict pod: %v", err)
+	}
+
+	got := waitForInterruption(t, interrupted)
+	if !got.resubmitted || got.job.Status != string(job.JobStatusInterrupted) {
+		t.Fatalf("expected the owner told about an interrupted, resubmitted job, got %+v", got)
+	}
+
+	stored, err := repo.FindByID(row.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if stored.Status != string(job.JobStatusQueued) || stored.RestartCount != 1 {
+		t.Fatalf("expected the job queued again, got status %s after %d restarts", stored.Status, stored.RestartCount)
+	}
+	var resumed k8s.JobSpec
+	if err := json.Unmarshal([]byte(stored.Spec), &resumed); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if resumed.EnvVars[jobapp.ResumeCheckpointEnv] != "/ckpt/2" || resumed.EnvVars["EPOCHS"] != "10" {
+		t.Fatalf("expected the latest checkpoint injected into the spec, got %v", resumed.EnvVars)
+	}
+	if _, err := client.BatchV1().Jobs("proj-1").Get(context.Background(), "train", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the interrupted K8s Job to be stopped, got %v", err)
+	}
+
+	events, _ := repo.FindEvents(row.ID)
+	var evicted, status bool
+	for _, ev := range events {
+		evicted = evicted || (ev.Source == job.EventSourcePlatform && ev.Reason == "Evicted" && ev.ObjectName == "train-abc")
+		status = status || (ev.Source == job.EventSourceStatus && ev.Reason == string(job.JobStatusInterrupted))
+	}
+	if !evicted || !status {
+		t.Fatalf("expected the eviction and the interrupted status on the timeline, got %+v", events)
+	}
+}
+
+func TestDrainedJobWithoutRestartStaysInterrupted(t *testing.T) {
+	row := &job.Job{UserID: 7, Name: "train", Namespace: "proj-1", K8sJobName: "train", Image: "trainer",
+		Status: string(job.JobStatusRunning)}
+	client, repo, interrupted := startEvictionWatch(t, row)
+
+	// kubectl drain evicts through the eviction API: the pod is marked, then deleted
+	pod, _ := client.CoreV1().Pods("proj-1").Get(context.Background(), "train-abc", metav1.GetOptions{})
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.DisruptionTarget, Status: corev1.ConditionTrue, Reason: "EvictionByEvictionAPI"}}
+	if _, err := client.CoreV1().Pods("proj-1").UpdateStatus(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("mark pod: %v", err)
+	}
+	if err := client.CoreV1().Pods("proj-1").Delete(context.Background(), "train-abc", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete pod: %v", err)
+	}
+
+	got := waitForInterruption(t, interrupted)
+	if got.resubmitted {
+		t.Fatal("a job without restart_on_eviction must not be resubmitted")
+	}
+	stored, _ := repo.FindByID(row.ID)
+	if stored.Status != string(job.JobStatusInterrupted) || stored.RestartCount != 0 || stored.CompletedAt == nil {
+		t.Fatalf("expected the job to stay interrupted, got %+v", stored)
+	}
+	if want := "pod train-abc was evicted from node-a (EvictionByEvictionAPI)"; stored.ErrorMessage != want {
+		t.Fatalf("expected %q, got %q", want, stored.ErrorMessage)
+	}
+}
@@ -10,15 +10,30 @@ import (
 // ErrExecutorNotFound is returned when an executor is not registered for a job type
 var ErrExecutorNotFound = errors.New("executor not found")
 
-// JobExecutor defines the interface for executing different job types
+// ErrRetryLater marks a dispatch that cannot succeed yet, such as a gang that does not fit.
+// The scheduler puts the job back in the queue with backoff instead of failing it.
+var ErrRetryLater = errors.New("job cannot be started yet")
+
+// JobExecutor defines the interface for executing different job types. The scheduler reaches
+// every executor through it, so a new backend only needs to be registered for its job type.
 type JobExecutor interface {
+	// Execute starts the job and records where it runs on the row.
 	Execute(ctx context.Context, j *job.Job) error
-	Cancel(ctx context.Context, jobID uint) error
-	GetStatus(ctx context.Context, jobID uint) (job.JobStatus, error)
-	GetLogs(ctx context.Context, jobID uint) (string, error)
+	// Cancel stops the work of a started job; the caller records the status it ends in.
+	Cancel(ctx context.Context, j *job.Job) error
+	// Status reports the current status of a started job and whether it has finished.
+	Status(ctx context.Context, j *job.Job) (status job.JobStatus, done bool, err error)
+	GetLogs(ctx context.Context, j *job.Job) (string, error)
 	SupportsType(jobType job.JobType) bool
 }
 
+// Watcher is implemented by executors that follow the jobs they start until they finish and
+// record the final status themselves, as the K8s executor does. The scheduler polls the Status
+// of jobs run by any other executor.
+type Watcher interface {
+	WatchesJobs() bool
+}
+
 // ExecutorRegistry manages different job executors
 type ExecutorRegistry struct {
 	executors map[job.JobType]JobExecutor
@@ -36,8 +51,11 @@ func (r *ExecutorRegistry) Register(jobType job.JobType, executor JobExecutor) {
 	r.executors[jobType] = executor
 }
 
-// GetExecutor returns the executor for a job type
+// GetExecutor returns the executor for a job type. Rows without a type are normal jobs.
 func (r *ExecutorRegistry) GetExecutor(jobType job.JobType) (JobExecutor, bool) {
+	if jobType == "" {
+		jobType = job.JobTypeNormal
+	}
 	executor, exists := r.executors[jobType]
 	return executor, exists
 }
@@ -50,3 +68,32 @@ func (r *ExecutorRegistry) Execute(ctx context.Context, j *job.Job) error {
 	}
 	return executor.Execute(ctx, j)
 }
+
+// Cancel stops a started job through the executor of its type.
+func (r *ExecutorRegistry) Cancel(ctx context.Context, j *job.Job) error {
+	executor, exists := r.GetExecutor(j.JobType)
+	if !exists {
+		return ErrExecutorNotFound
+	}
+	return executor.Cancel(ctx, j)
+}
+
+// Status asks the executor of the job's type for its current status.
+func (r *ExecutorRegistry) Status(ctx context.Context, j *job.Job) (job.JobStatus, bool, error) {
+	executor, exists := r.GetExecutor(j.JobType)
+	if !exists {
+		return "", false, ErrExecutorNotFound
+	}
+	return executor.Status(ctx, j)
+}
+
+// Polled reports whether the job's status must be polled by the scheduler: its executor is
+// registered but does not watch its own jobs. Such jobs have no K8s Job.
+func (r *ExecutorRegistry) Polled(jobType job.JobType) bool {
+	executor, exists := r.GetExecutor(jobType)
+	if !exists {
+		return false
+	}
+	w, ok := executor.(Watcher)
+	return !ok || !w.WatchesJobs()
+}
@@ -4,8 +4,17 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/linskybing/platform-go/internal/config/db/dbtest"
 	"github.com/linskybing/platform-go/internal/domain/job"
+	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 )
 
 // MockExecutor for testing
@@ -24,18 +33,18 @@ func (m *MockExecutor) Execute(ctx context.Context, j *job.Job) error {
 	return nil
 }
 
-func (m *MockExecutor) Cancel(ctx context.Context, jobID uint) error {
+func (m *MockExecutor) Cancel(ctx context.Context, j *job.Job) error {
 	if m.cancelErr {
 		return errors.New("cancel failed")
 	}
 	return nil
 }
 
-func (m *MockExecutor) GetStatus(ctx context.Context, jobID uint) (job.JobStatus, error) {
-	return m.statusVal, nil
+func (m *MockExecutor) Status(ctx context.Context, j *job.Job) (job.JobStatus, bool, error) {
+	return m.statusVal, false, nil
 }
 
-func (m *MockExecutor) GetLogs(ctx context.Context, jobID uint) (string, error) {
+func (m *MockExecutor) GetLogs(ctx context.Context, j *job.Job) (string, error) {
 	return m.logs, nil
 }
 
@@ -157,3 +166,164 @@ func TestExecuteWithContextCancellation(t *testing.T) {
 		t.Fatalf("expected no error with cancelled context, got %v", err)
 	}
 }
+
+func TestRecordFailureReasonUsesPodEvents(t *testing.T) {
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	k8s.Clientset = k8sfake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "train-abc", Namespace: "proj-1", Labels: map[string]string{"job-name": "train"}}},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "train-abc.1", Namespace: "proj-1"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "train-abc", Namespace: "proj-1"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "FailedMount",
+			Message:        "MountVolume.SetUp failed",
+		},
+	)
+
+	j := &job.Job{Namespace: "proj-1", K8sJobName: "train"}
+	recordFailureReason(context.Background(), j, job.StatusFailed)
+	if j.ErrorMessage != "FailedMount: MountVolume.SetUp failed" {
+		t.Fatalf("unexpected error message %q", j.ErrorMessage)
+	}
+
+	done := &job.Job{Namespace: "proj-1", K8sJobName: "train"}
+	recordFailureReason(context.Background(), done, job.StatusCompleted)
+	if done.ErrorMessage != "" {
+		t.Fatalf("a completed job should keep an empty message, got %q", done.ErrorMessage)
+	}
+}
+
+func TestRecordEventsMergesRepeatedBackOff(t *testing.T) {
+	db := dbtest.Open(t, &job.JobEvent{}, &job.JobEventSeen{})
+	orig := k8s.Clientset
+	defer func() { k8s.Clientset = orig }()
+	t0 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	backOff := func(name string, count int32, first, last int) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "proj-1", UID: types.UID(name)},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "train-abc", Namespace: "proj-1"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container main in pod train-abc",
+			Count:          count,
+			FirstTimestamp: metav1.NewTime(t0.Add(time.Duration(first) * time.Minute)),
+			LastTimestamp:  metav1.NewTime(t0.Add(time.Duration(last) * time.Minute)),
+		}
+	}
+	client := k8sfake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "train-abc", Namespace: "proj-1", Labels: map[string]string{"job-name": "train"}}},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "train-abc.0", Namespace: "proj-1", UID: "scheduled"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "train-abc", Namespace: "proj-1"},
+			Type:           corev1.EventTypeNormal,
+			Reason:         "Scheduled",
+			FirstTimestamp: metav1.NewTime(t0),
+			LastTimestamp:  metav1.NewTime(t0),
+		},
+		backOff("train-abc.1", 3, 1, 5),
+		backOff("train-abc.2", 2, 6, 8),
+		// An event of another job's pod in the same namespace
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "eval-xyz.0", Namespace: "proj-1", UID: "other"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "eval-xyz", Namespace: "proj-1"},
+			Reason:         "Scheduled",
+		},
+	)
+	k8s.Clientset = client
+
+	e := NewK8sExecutor(repository.NewJobRepo(db), nil)
+	j := &job.Job{ID: 1, Namespace: "proj-1", K8sJobName: "train"}
+	seen := map[types.UID]int32{}
+	e.recordEvents(context.Background(), j, seen)
+	e.recordEvents(context.Background(), j, seen)
+	if _, err := client.CoreV1().Events("proj-1").Update(context.Background(), backOff("train-abc.1", 5, 1, 9), metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update event: %v", err)
+	}
+	e.recordEvents(context.Background(), j, seen)
+	// A restarted watch starts without the counts it saw
+	e.recordEvents(context.Background(), j, map[types.UID]int32{})
+
+	events, err := e.jobRepo.FindEvents(j.ID)
+	if err != nil {
+		t.Fatalf("FindEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].Reason != "Scheduled" || events[1].Reason != "BackOff" {
+		t.Fatalf("expected the scheduling and one BackOff event, got %+v", events)
+	}
+	merged := events[1]
+	if merged.Count != 7 || merged.Severity != job.SeverityWarning || merged.Source != job.EventSourceKubernetes {
+		t.Fatalf("expected the 7 BackOff repeats merged, got %+v", merged)
+	}
+	if !merged.FirstSeen.Equal(t0.Add(time.Minute)) || !merged.LastSeen.Equal(t0.Add(9*time.Minute)) {
+		t.Fatalf("expected the BackOff from 09:01 to 09:09, got %s to %s", merged.FirstSeen, merged.LastSeen)
+	}
+}
+
+func TestWatchJobRequeuesUnplacedGang(t *testing.T) {
+	db := dbtest.Open(t, &job.Job{}, &job.JobEvent{})
+	orig, origInterval := k8s.Clientset, jobWatchInterval
+	defer func() { k8s.Clientset, jobWatchInterval = orig, origInterval }()
+	jobWatchInterval = time.Millisecond
+	pod := func(name, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "proj-1", Labels: map[string]string{"job-name": "ddp"}},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+	}
+	client := k8sfake.NewSimpleClientset(
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "ddp", Namespace: "proj-1"}},
+		pod("ddp-0", "gpu-a"), pod("ddp-1", ""),
+	)
+	k8s.Clientset = client
+
+	repo := repository.NewJobRepo(db)
+	j := &job.Job{Namespace: "proj-1", K8sJobName: "ddp", Status: string(job.JobStatusRunning)}
+	if err := repo.Create(j); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	e := NewK8sExecutor(repo, nil)
+	e.watchJob(context.Background(), j, &gangPlacement{members: 2, deadline: time.Now().Add(-time.Second)})
+
+	stored, err := repo.FindByID(j.ID)
+	if err != nil || stored.Status != string(job.JobStatusQueued) || stored.ErrorMessage == "" {
+		t.Fatalf("expected the gang to be queued again, got %+v (%v)", stored, err)
+	}
+	if _, err := client.BatchV1().Jobs("proj-1").Get(context.Background(), "ddp", metav1.GetOptions{}); err == nil {
+		t.Fatalf("expected the K8s Job of the unplaced gang to be removed")
+	}
+}
+
+func TestEvaluateJobStatusReportsDeadlineAsTimedOut(t *testing.T) {
+	timedOut := &batchv1.Job{Status: batchv1.JobStatus{
+		Failed: 1,
+		Conditions: []batchv1.JobCondition{{
+			Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: batchv1.JobReasonDeadlineExceeded,
+		}},
+	}}
+	if status, done := evaluateJobStatus(timedOut); !done || status != job.JobStatusTimedOut {
+		t.Fatalf("expected a timed out job, got %s (done=%v)", status, done)
+	}
+
+	failed := &batchv1.Job{Status: batchv1.JobStatus{Failed: 1}}
+	if status, _ := evaluateJobStatus(failed); status != job.StatusFailed {
+		t.Fatalf("expected an ordinary failure, got %s", status)
+	}
+}
+
+func TestBuildSpecAppliesRowRuntimeLimit(t *testing.T) {
+	limit := int64(3600)
+	spec, err := NewK8sExecutor(nil, nil).buildSpec(&job.Job{K8sJobName: "train", Namespace: "proj-1", MaxRuntimeSeconds: &limit})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spec.ActiveDeadlineSeconds == nil || *spec.ActiveDeadlineSeconds != limit {
+		t.Fatalf("expected a deadline of %d seconds, got %v", limit, spec.ActiveDeadlineSeconds)
+	}
+
+	unlimited := int64(0)
+	spec, _ = NewK8sExecutor(nil, nil).buildSpec(&job.Job{K8sJobName: "train", Namespace: "proj-1", MaxRuntimeSeconds: &unlimited})
+	if spec.ActiveDeadlineSeconds != nil {
+		t.Fatalf("an unlimited job should have no deadline, got %d", *spec.ActiveDeadlineSeconds)
+	}
+}
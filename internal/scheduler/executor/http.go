@@ -0,0 +1,239 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+)
+
+// maxRemoteLogBytes caps how much of a remote job's log is read at once.
+const maxRemoteLogBytes = 1 << 20
+
+// errRemoteNotFound is returned when the external cluster does not know a job.
+var errRemoteNotFound = errors.New("external job not found")
+
+// remoteStates maps the job states of the external cluster, as reported by Slurm, to job
+// statuses, and whether the job has finished in that state.
+var remoteStates = map[string]struct {
+	status job.JobStatus
+	done   bool
+}{
+	"PENDING":       {job.StatusPending, false},
+	"QUEUED":        {job.StatusPending, false},
+	"CONFIGURING":   {job.StatusPending, false},
+	"REQUEUED":      {job.StatusPending, false},
+	"SUSPENDED":     {job.StatusPending, false},
+	"RUNNING":       {job.JobStatusRunning, false},
+	"COMPLETING":    {job.JobStatusRunning, false},
+	"COMPLETED":     {job.StatusCompleted, true},
+	"FAILED":        {job.StatusFailed, true},
+	"NODE_FAIL":     {job.StatusFailed, true},
+	"OUT_OF_MEMORY": {job.StatusFailed, true},
+	"BOOT_FAIL":     {job.StatusFailed, true},
+	"CANCELLED":     {job.StatusCancelled, true},
+	"PREEMPTED":     {job.JobStatusPreempted, true},
+	"TIMEOUT":       {job.JobStatusTimedOut, true},
+	"DEADLINE":      {job.JobStatusTimedOut, true},
+}
+
+// MapRemoteState returns the job status of a state reported by the external cluster and
+// whether the job has finished. Unknown states are not mapped.
+func MapRemoteState(state string) (job.JobStatus, bool, bool) {
+	s, ok := remoteStates[strings.ToUpper(strings.TrimSpace(state))]
+	return s.status, s.done, ok
+}
+
+// remoteJob is how the external cluster describes a job.
+type remoteJob struct {
+	ID       string `json:"id"`
+	State    string `json:"state"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// remoteSubmission is the body of a submission to the external cluster.
+type remoteSubmission struct {
+	JobID     uint            `json:"job_id"`
+	Name      string          `json:"name"`
+	UserID    uint            `json:"user_id"`
+	ProjectID *uint           `json:"project_id,omitempty"`
+	Spec      json.RawMessage `json:"spec"`
+}
+
+// HTTPExecutor runs jobs on an external cluster through its REST API:
+//
+//	POST   {base}/jobs           submits the job spec and answers {"id", "state"}
+//	GET    {base}/jobs/{id}      answers {"id", "state", "exit_code", "message"}
+//	DELETE {base}/jobs/{id}      cancels the job
+//	GET    {base}/jobs/{id}/logs answers the job output as text
+//
+// Every request carries the token as a bearer token. The executor does not watch its jobs;
+// the scheduler polls their Status.
+type HTTPExecutor struct {
+	jobRepo job.Repository
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPExecutor constructs an executor for the cluster at baseURL.
+func NewHTTPExecutor(jobRepo job.Repository, baseURL, token string) *HTTPExecutor {
+	return &HTTPExecutor{
+		jobRepo: jobRepo,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Execute submits the saved spec of the job and records the ID the cluster gave it. A cluster
+// that cannot be reached or answers with a server error is retried later.
+func (e *HTTPExecutor) Execute(ctx context.Context, j *job.Job) error {
+	spec := json.RawMessage(j.Spec)
+	if len(spec) == 0 {
+		spec = json.RawMessage("{}")
+	}
+	body, err := json.Marshal(remoteSubmission{JobID: j.ID, Name: j.Name, UserID: j.UserID, ProjectID: j.ProjectID, Spec: spec})
+	if err != nil {
+		return err
+	}
+	var remote remoteJob
+	if err := e.do(ctx, http.MethodPost, "/jobs", body, &remote); err != nil {
+		return err
+	}
+	if remote.ID == "" {
+		return fmt.Errorf("%s: the external cluster returned no job id", ErrJobExecutionFailed)
+	}
+
+	now := time.Now()
+	j.ExternalID = remote.ID
+	// The external cluster does not report when the job starts running
+	j.DispatchedAt = &now
+	j.StartedAt = &now
+	j.Status = string(job.JobStatusRunning)
+	if status, _, ok := MapRemoteState(remote.State); ok {
+		j.Status = string(status)
+	}
+	if e.jobRepo != nil {
+		if err := e.jobRepo.Update(j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cancel cancels the job on the external cluster; one the cluster no longer knows counts as stopped.
+func (e *HTTPExecutor) Cancel(ctx context.Context, j *job.Job) error {
+	if j.ExternalID == "" {
+		return nil
+	}
+	err := e.do(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(j.ExternalID), nil, nil)
+	if errors.Is(err, errRemoteNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Status asks the external cluster for the state of the job. A finished job's exit code and
+// message are set on j for the caller to record.
+func (e *HTTPExecutor) Status(ctx context.Context, j *job.Job) (job.JobStatus, bool, error) {
+	if j.ExternalID == "" {
+		return job.JobStatus(j.Status), false, nil
+	}
+	var remote remoteJob
+	if err := e.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(j.ExternalID), nil, &remote); err != nil {
+		return "", false, err
+	}
+	status, done, ok := MapRemoteState(remote.State)
+	if !ok {
+		return "", false, fmt.Errorf("unknown state %q of external job %s", remote.State, j.ExternalID)
+	}
+	if done {
+		j.ExitCode = remote.ExitCode
+		if status != job.StatusCompleted && remote.Message != "" && j.ErrorMessage == "" {
+			j.ErrorMessage = remote.Message
+		}
+	}
+	return status, done, nil
+}
+
+func (e *HTTPExecutor) GetLogs(ctx context.Context, j *job.Job) (string, error) {
+	if j.ExternalID == "" {
+		return "", nil
+	}
+	resp, err := e.send(ctx, http.MethodGet, "/jobs/"+url.PathEscape(j.ExternalID)+"/logs", nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ErrLogRetrievalFailed, err)
+	}
+	defer resp.Body.Close()
+	logs, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteLogBytes))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ErrLogRetrievalFailed, err)
+	}
+	return string(logs), nil
+}
+
+func (e *HTTPExecutor) SupportsType(jobType job.JobType) bool {
+	return jobType == job.JobTypeExternal
+}
+
+// do sends a request and decodes a JSON answer into out, when given.
+func (e *HTTPExecutor) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	resp, err := e.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode the external cluster's answer: %w", err)
+	}
+	return nil
+}
+
+// send sends a request and returns a 2xx response. Unreachable clusters and server errors
+// wrap ErrRetryLater; a 404 is errRemoteNotFound.
+func (e *HTTPExecutor) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, e.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if e.token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: external cluster unreachable: %v", ErrRetryLater, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errRemoteNotFound
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("%w: external cluster answered %d: %s", ErrRetryLater, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil, fmt.Errorf("%s: external cluster answered %d: %s", ErrJobExecutionFailed, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
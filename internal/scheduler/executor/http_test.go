@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/domain/job"
+)
+
+// fakeCluster is a minimal external cluster REST API holding one job.
+type fakeCluster struct {
+	mu        sync.Mutex
+	state     string
+	exitCode  *int
+	submitted map[string]interface{}
+	cancelled bool
+	failWith  int
+}
+
+func (f *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.failWith != 0 {
+		w.WriteHeader(f.failWith)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/jobs":
+		_ = json.NewDecoder(r.Body).Decode(&f.submitted)
+		f.state = "PENDING"
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "4711", "state": f.state})
+	case r.Method == http.MethodGet && r.URL.Path == "/jobs/4711":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "4711", "state": f.state, "exit_code": f.exitCode, "message": "node lost"})
+	case r.Method == http.MethodGet && r.URL.Path == "/jobs/4711/logs":
+		_, _ = w.Write([]byte("epoch 1 done\n"))
+	case r.Method == http.MethodDelete && r.URL.Path == "/jobs/4711":
+		f.cancelled = true
+		f.state = "CANCELLED"
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeCluster) set(state string, exitCode *int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = state
+	f.exitCode = exitCode
+}
+
+func TestHTTPExecutorLifecycle(t *testing.T) {
+	cluster := &fakeCluster{}
+	srv := httptest.NewServer(cluster)
+	defer srv.Close()
+	exec := NewHTTPExecutor(nil, srv.URL+"/", "secret")
+	ctx := context.Background()
+
+	j := &job.Job{ID: 7, Name: "train", UserID: 2, JobType: job.JobTypeExternal, Spec: `{"image":"pytorch:2"}`}
+	if err := exec.Execute(ctx, j); err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+	if j.ExternalID != "4711" || j.Status != string(job.StatusPending) || j.StartedAt == nil {
+		t.Fatalf("expected the remote id and pending status to be recorded, got %+v", j)
+	}
+	if spec, _ := cluster.submitted["spec"].(map[string]interface{}); spec["image"] != "pytorch:2" || cluster.submitted["job_id"] != float64(7) {
+		t.Fatalf("expected the spec to be submitted, got %+v", cluster.submitted)
+	}
+
+	cluster.set("RUNNING", nil)
+	if status, done, err := exec.Status(ctx, j); err != nil || done || status != job.JobStatusRunning {
+		t.Fatalf("expected running, got %s %v %v", status, done, err)
+	}
+	if logs, err := exec.GetLogs(ctx, j); err != nil || logs != "epoch 1 done\n" {
+		t.Fatalf("expected the remote logs, got %q %v", logs, err)
+	}
+
+	code := 0
+	cluster.set("COMPLETED", &code)
+	status, done, err := exec.Status(ctx, j)
+	if err != nil || !done || status != job.StatusCompleted || j.ExitCode == nil || *j.ExitCode != 0 {
+		t.Fatalf("expected completed with exit code 0, got %s %v %v %+v", status, done, err, j.ExitCode)
+	}
+	if j.ErrorMessage != "" {
+		t.Fatalf("a completed job must not get an error message, got %q", j.ErrorMessage)
+	}
+
+	code = 1
+	cluster.set("NODE_FAIL", &code)
+	if status, done, _ := exec.Status(ctx, j); !done || status != job.StatusFailed || j.ErrorMessage != "node lost" {
+		t.Fatalf("expected a failure with the remote message, got %s %v %q", status, done, j.ErrorMessage)
+	}
+}
+
+func TestHTTPExecutorCancel(t *testing.T) {
+	cluster := &fakeCluster{}
+	srv := httptest.NewServer(cluster)
+	defer srv.Close()
+	exec := NewHTTPExecutor(nil, srv.URL, "secret")
+	ctx := context.Background()
+
+	// Never submitted: nothing to cancel remotely
+	if err := exec.Cancel(ctx, &job.Job{ID: 1}); err != nil || cluster.cancelled {
+		t.Fatalf("expected a no-op, got %v", err)
+	}
+	j := &job.Job{ID: 1, ExternalID: "4711"}
+	if err := exec.Cancel(ctx, j); err != nil || !cluster.cancelled {
+		t.Fatalf("expected the remote job to be cancelled, got %v", err)
+	}
+	if status, done, _ := exec.Status(ctx, j); !done || status != job.StatusCancelled {
+		t.Fatalf("expected cancelled, got %s %v", status, done)
+	}
+	// Already gone on the cluster
+	if err := exec.Cancel(ctx, &job.Job{ID: 2, ExternalID: "9999"}); err != nil {
+		t.Fatalf("expected an unknown remote job to count as stopped, got %v", err)
+	}
+}
+
+func TestHTTPExecutorErrors(t *testing.T) {
+	cluster := &fakeCluster{failWith: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(cluster)
+	defer srv.Close()
+	ctx := context.Background()
+
+	if err := NewHTTPExecutor(nil, srv.URL, "secret").Execute(ctx, &job.Job{ID: 1}); !errors.Is(err, ErrRetryLater) {
+		t.Fatalf("expected a server error to be retried later, got %v", err)
+	}
+	cluster.failWith = 0
+	err := NewHTTPExecutor(nil, srv.URL, "wrong").Execute(ctx, &job.Job{ID: 1})
+	if err == nil || errors.Is(err, ErrRetryLater) {
+		t.Fatalf("expected a rejected token to fail the job, got %v", err)
+	}
+
+	cluster.set("EXPLODED", nil)
+	if _, _, err := NewHTTPExecutor(nil, srv.URL, "secret").Status(ctx, &job.Job{ID: 1, ExternalID: "4711"}); err == nil {
+		t.Fatal("expected an unknown remote state to be an error")
+	}
+}
+
+func TestMapRemoteState(t *testing.T) {
+	cases := map[string]struct {
+		status job.JobStatus
+		done   bool
+	}{
+		"pending":   {job.StatusPending, false},
+		"RUNNING":   {job.JobStatusRunning, false},
+		"COMPLETED": {job.StatusCompleted, true},
+		"FAILED":    {job.StatusFailed, true},
+		"CANCELLED": {job.StatusCancelled, true},
+		"TIMEOUT":   {job.JobStatusTimedOut, true},
+		"PREEMPTED": {job.JobStatusPreempted, true},
+	}
+	for state, want := range cases {
+		status, done, ok := MapRemoteState(state)
+		if !ok || status != want.status || done != want.done {
+			t.Fatalf("%s: expected %s %v, got %s %v %v", state, want.status, want.done, status, done, ok)
+		}
+	}
+	if _, _, ok := MapRemoteState("EXPLODED"); ok {
+		t.Fatal("expected an unknown state not to be mapped")
+	}
+}
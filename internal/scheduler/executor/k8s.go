@@ -3,7 +3,10 @@ package executor
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,13 +19,32 @@ import (
 	"github.com/linskybing/platform-go/pkg/k8s"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// GangGate reports whether all pods of a gang job can start now. Errors wrapping
+// application.ErrGangNotPlaceable are retried later.
+type GangGate func(ctx context.Context, projectID uint, spec k8s.JobSpec) error
+
+// jobWatchInterval is how often watchJob polls the K8s Job of a running job, and
+// jobEventInterval how often it stores the job's events; they are stored once more when the
+// job finishes.
+var (
+	jobWatchInterval = 3 * time.Second
+	jobEventInterval = 30 * time.Second
 )
 
 // K8sExecutor runs jobs on Kubernetes.
 type K8sExecutor struct {
 	jobRepo      job.Repository
 	imageService *application.ImageService
+	gangGate     GangGate
+	onTimeout    func(ctx context.Context, j *job.Job)
+	// See WithEvictionHooks
+	onInterrupted func(ctx context.Context, j *job.Job, resubmitted bool)
+	resubmit      func(ctx context.Context, j *job.Job) error
 }
 
 // NewK8sExecutor constructs a Kubernetes-backed executor.
@@ -33,7 +55,124 @@ func NewK8sExecutor(jobRepo job.Repository, imageService *application.ImageServi
 	}
 }
 
+// WithGangGate sets the capacity check run before a gang job is created.
+func (e *K8sExecutor) WithGangGate(gate GangGate) *K8sExecutor {
+	e.gangGate = gate
+	return e
+}
+
+// WithTimeoutHook sets what is told about each job Kubernetes stopped at its runtime limit,
+// e.g. to notify the owner.
+func (e *K8sExecutor) WithTimeoutHook(hook func(ctx context.Context, j *job.Job)) *K8sExecutor {
+	e.onTimeout = hook
+	return e
+}
+
 func (e *K8sExecutor) Execute(ctx context.Context, j *job.Job) error {
+	spec, err := e.buildSpec(j)
+	if err != nil {
+		return err
+	}
+	owner := k8s.Ownership{UserID: j.UserID, JobID: j.ID}
+	if j.ProjectID != nil {
+		owner.ProjectID = *j.ProjectID
+	}
+	spec.Labels = k8s.MergeLabels(spec.Labels, owner.Labels())
+	if spec.EnvVars == nil {
+		spec.EnvVars = map[string]string{}
+	}
+	for k, v := range application.JobProgressEnv(j.ID) {
+		spec.EnvVars[k] = v
+	}
+
+	if spec.Gang && e.gangGate != nil {
+		if err := e.gangGate(ctx, owner.ProjectID, spec); err != nil {
+			if errors.Is(err, application.ErrGangNotPlaceable) {
+				return fmt.Errorf("%w: %v", ErrRetryLater, err)
+			}
+			return err
+		}
+	}
+
+	if err := k8s.CreateJob(ctx, spec); err != nil {
+		// The K8s Job of the run a restart replaces, or of a gang put back in the queue, may
+		// still be terminating
+		if apierrors.IsAlreadyExists(err) && (j.RestartCount > 0 || spec.Gang) {
+			return fmt.Errorf("%w: %v", ErrRetryLater, err)
+		}
+		return err
+	}
+
+	var gang *gangPlacement
+	if spec.Gang && config.GangPlacementTimeout > 0 {
+		gang = &gangPlacement{members: int(spec.Parallelism), deadline: time.Now().Add(config.GangPlacementTimeout)}
+	}
+
+	if e.jobRepo != nil {
+		j.Status = string(job.JobStatusRunning)
+		dispatched := time.Now()
+		j.DispatchedAt = &dispatched
+		if err := e.jobRepo.Update(j); err != nil {
+			log.Printf("update job status failed: %v", err)
+		}
+	}
+
+	// Watch job completion and collect logs asynchronously
+	go e.watchJob(ctx, j, gang)
+	go e.followLogs(ctx, j)
+	return nil
+}
+
+// gangPlacement is the placement a gang job still waits for: members pods bound to nodes before
+// deadline. watchJob checks it on each poll instead of Execute blocking the scheduler on it.
+type gangPlacement struct {
+	members  int
+	deadline time.Time
+}
+
+// placedPods counts the pods of the K8s Job bound to a node.
+func placedPods(ctx context.Context, ns, jobName string) (int, error) {
+	pods, err := k8s.Clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("job-name=%s", jobName)})
+	if err != nil {
+		return 0, err
+	}
+	placed := 0
+	for i := range pods.Items {
+		if pods.Items[i].Spec.NodeName != "" {
+			placed++
+		}
+	}
+	return placed, nil
+}
+
+// requeueUnplacedGang puts a gang job whose pods were not all placed in time back in the queue
+// and removes its K8s Job, so the pods that did get placed do not hold GPUs while the rest wait.
+// The row is queued first, so the deleted pods do not count as an interruption.
+func (e *K8sExecutor) requeueUnplacedGang(ctx context.Context, j *job.Job, placed, members int) {
+	j.Status = string(job.JobStatusQueued)
+	j.ErrorMessage = fmt.Sprintf("only %d of %d gang pods placed within %s", placed, members, config.GangPlacementTimeout)
+	j.DispatchedAt = nil
+	if e.jobRepo != nil {
+		if err := e.jobRepo.Update(j); err != nil {
+			log.Printf("requeue unplaced gang job %d failed: %v", j.ID, err)
+		}
+	}
+	if err := k8s.DeleteJob(ctx, j.Namespace, j.K8sJobName); err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("failed to remove unplaced gang job %s/%s: %v", j.Namespace, j.K8sJobName, err)
+	}
+}
+
+// buildSpec uses the spec resolved at submission time when the job was deferred, and
+// otherwise derives one from the job row.
+func (e *K8sExecutor) buildSpec(j *job.Job) (k8s.JobSpec, error) {
+	if j.Spec != "" {
+		var spec k8s.JobSpec
+		if err := json.Unmarshal([]byte(j.Spec), &spec); err != nil {
+			return k8s.JobSpec{}, fmt.Errorf("invalid stored spec for job %d: %w", j.ID, err)
+		}
+		return spec, nil
+	}
+
 	var cmd []string
 	if j.Command != "" {
 		_ = json.Unmarshal([]byte(j.Command), &cmd)
@@ -45,18 +184,7 @@ func (e *K8sExecutor) Execute(ctx context.Context, j *job.Job) error {
 
 	// Handle Image Prefixing
 	if e.imageService != nil && j.ProjectID != nil {
-		parts := strings.Split(j.Image, ":")
-		if len(parts) == 2 {
-			name := parts[0]
-			tag := parts[1]
-			isAllowed, _ := e.imageService.ValidateImageForProject(name, tag, j.ProjectID)
-			if isAllowed {
-				prefix := config.HarborPrivatePrefix
-				if prefix != "" && !strings.HasPrefix(j.Image, prefix) {
-					j.Image = fmt.Sprintf("%s%s", prefix, j.Image)
-				}
-			}
-		}
+		j.Image, _ = e.imageService.PrefixAllowedImage(j.Image, j.ProjectID)
 	}
 
 	var envVars map[string]string
@@ -64,12 +192,17 @@ func (e *K8sExecutor) Execute(ctx context.Context, j *job.Job) error {
 		_ = json.Unmarshal([]byte(j.EnvVars), &envVars)
 	}
 
+	priorityClassName, ok := config.PriorityClassNames[j.Priority]
+	if !ok {
+		priorityClassName = config.PriorityClassNames[job.PriorityLow]
+	}
+
 	spec := k8s.JobSpec{
 		Name:              j.K8sJobName,
 		Namespace:         j.Namespace,
 		Image:             j.Image,
 		Command:           append(cmd, args...),
-		PriorityClassName: "low-priority",
+		PriorityClassName: priorityClassName,
 		Parallelism:       1,
 		Completions:       1,
 		GPUCount:          j.GPUCount,
@@ -79,53 +212,46 @@ func (e *K8sExecutor) Execute(ctx context.Context, j *job.Job) error {
 		EnvVars:           envVars,
 		Annotations:       map[string]string{},
 	}
-
-	if err := k8s.CreateJob(ctx, spec); err != nil {
-		return err
-	}
-
-	if e.jobRepo != nil {
-		j.Status = string(job.JobStatusRunning)
-		if err := e.jobRepo.Update(j); err != nil {
-			log.Printf("update job status failed: %v", err)
-		}
+	if j.MaxRuntimeSeconds != nil && *j.MaxRuntimeSeconds > 0 {
+		spec.ActiveDeadlineSeconds = j.MaxRuntimeSeconds
 	}
-
-	// Watch job completion and collect logs asynchronously
-	go e.watchJob(ctx, j)
-	go e.followLogs(ctx, j)
-	return nil
+	return spec, nil
 }
 
-func (e *K8sExecutor) Cancel(ctx context.Context, jobID uint) error {
-	if e.jobRepo == nil {
+// Cancel deletes the K8s Job; one that is already gone counts as stopped.
+func (e *K8sExecutor) Cancel(ctx context.Context, j *job.Job) error {
+	if k8s.Clientset == nil || j.K8sJobName == "" {
 		return nil
 	}
-	obj, err := e.jobRepo.FindByID(jobID)
-	if err != nil {
-		return err
-	}
-	if err := k8s.DeleteJob(ctx, obj.Namespace, obj.K8sJobName); err != nil {
+	if err := k8s.DeleteJob(ctx, j.Namespace, j.K8sJobName); err != nil && !apierrors.IsNotFound(err) {
 		return err
 	}
-	obj.Status = string(job.StatusCancelled)
-	return e.jobRepo.Update(obj)
+	return nil
 }
 
-func (e *K8sExecutor) GetStatus(ctx context.Context, jobID uint) (job.JobStatus, error) {
-	if e.jobRepo == nil {
-		return job.StatusPending, nil
+// Status reads the status of the job from its K8s Job.
+func (e *K8sExecutor) Status(ctx context.Context, j *job.Job) (job.JobStatus, bool, error) {
+	if k8s.Clientset == nil || j.K8sJobName == "" {
+		return job.JobStatus(j.Status), false, nil
 	}
-	obj, err := e.jobRepo.FindByID(jobID)
+	obj, err := k8s.Clientset.BatchV1().Jobs(j.Namespace).Get(ctx, j.K8sJobName, metav1.GetOptions{})
 	if err != nil {
-		return job.StatusPending, err
+		return "", false, err
+	}
+	status, done := evaluateJobStatus(obj)
+	return status, done, nil
+}
+
+func (e *K8sExecutor) GetLogs(ctx context.Context, j *job.Job) (string, error) {
+	if k8s.Clientset == nil || j.K8sJobName == "" {
+		return "", nil
 	}
-	return job.JobStatus(obj.Status), nil
+	return e.collectLogs(ctx, j.Namespace, j.K8sJobName), nil
 }
 
-func (e *K8sExecutor) GetLogs(ctx context.Context, jobID uint) (string, error) {
-	// Placeholder: log streaming to be added; return empty for now.
-	return "", nil
+// WatchesJobs reports that Execute follows each job until it finishes.
+func (e *K8sExecutor) WatchesJobs() bool {
+	return true
 }
 
 func (e *K8sExecutor) SupportsType(jobType job.JobType) bool {
@@ -182,42 +308,271 @@ func (e *K8sExecutor) pickPodForJob(ctx context.Context, ns, jobName string) str
 	return ""
 }
 
-func (e *K8sExecutor) watchJob(ctx context.Context, j *job.Job) {
+// watchJob follows the K8s Job of j until it finishes and records its final status. A gang job
+// whose pods are not all placed by the deadline of gang is put back in the queue instead.
+func (e *K8sExecutor) watchJob(ctx context.Context, j *job.Job, gang *gangPlacement) {
+	seen := map[types.UID]int32{}
+	var eventsAt time.Time
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(3 * time.Second):
+		case <-time.After(jobWatchInterval):
 		}
 
 		jobObj, err := k8s.Clientset.BatchV1().Jobs(j.Namespace).Get(ctx, j.K8sJobName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			// Cancelled or interrupted; whoever deleted it recorded the status
+			return
+		}
 		if err != nil {
 			log.Printf("watch job get err: %v", err)
 			continue
 		}
 
 		status, done := evaluateJobStatus(jobObj)
+		if done || time.Since(eventsAt) >= jobEventInterval {
+			e.recordEvents(ctx, j, seen)
+			eventsAt = time.Now()
+		}
+		if gang != nil && !done {
+			placed, err := placedPods(ctx, j.Namespace, j.K8sJobName)
+			switch {
+			case err == nil && placed >= gang.members:
+				gang = nil
+			case time.Now().After(gang.deadline):
+				e.requeueUnplacedGang(ctx, j, placed, gang.members)
+				return
+			}
+		}
+		if j.StartedAt == nil {
+			j.StartedAt = podStartTime(ctx, j.Namespace, j.K8sJobName)
+			if j.StartedAt != nil && !done && e.jobRepo != nil {
+				if err := e.jobRepo.Update(j); err != nil {
+					log.Printf("update job start time failed: %v", err)
+				}
+				recordStatus(e.jobRepo, j, *j.StartedAt)
+			}
+		}
 		if !done {
 			continue
 		}
 
 		logs := e.collectLogs(ctx, j.Namespace, j.K8sJobName)
 		if e.jobRepo != nil {
-			j.Status = string(status)
 			now := time.Now()
-			j.CompletedAt = &now
+			if status == job.JobStatusTimedOut {
+				j.MarkTimedOut(now)
+			} else {
+				j.Status = string(status)
+				j.CompletedAt = &now
+			}
+			recordFailureReason(ctx, j, status)
 			if err := e.jobRepo.Update(j); err != nil {
 				log.Printf("update job final status failed: %v", err)
 			}
+			recordStatus(e.jobRepo, j, now)
 			if logs != "" {
 				_ = e.jobRepo.SaveLog(&job.JobLog{JobID: j.ID, Content: logs})
 			}
 		}
+		if status == job.JobStatusTimedOut && e.onTimeout != nil {
+			e.onTimeout(ctx, j)
+		}
+		return
+	}
+}
+
+// RefreshJobStatus records the final status of a job that has finished in Kubernetes while its
+// row is still active, e.g. a job submitted directly instead of through the scheduler.
+func RefreshJobStatus(ctx context.Context, repo job.Repository, j *job.Job) {
+	if k8s.Clientset == nil || repo == nil || j.K8sJobName == "" {
+		return
+	}
+	obj, err := k8s.Clientset.BatchV1().Jobs(j.Namespace).Get(ctx, j.K8sJobName, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+	status, done := evaluateJobStatus(obj)
+	if !done {
+		return
+	}
+	now := time.Now()
+	if status == job.JobStatusTimedOut {
+		j.MarkTimedOut(now)
+	} else {
+		j.Status = string(status)
+		j.CompletedAt = &now
+	}
+	if j.StartedAt == nil {
+		j.StartedAt = podStartTime(ctx, j.Namespace, j.K8sJobName)
+	}
+	recordFailureReason(ctx, j, status)
+	if err := repo.Update(j); err != nil {
+		log.Printf("update job %d final status failed: %v", j.ID, err)
+	}
+	recordStatus(repo, j, now)
+}
+
+// podStartTime returns when the first pod of a K8s Job started, or nil while none has.
+func podStartTime(ctx context.Context, ns, jobName string) *time.Time {
+	pods, err := k8s.Clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("job-name=%s", jobName)})
+	if err != nil {
+		return nil
+	}
+	var first *time.Time
+	for i := range pods.Items {
+		started := pods.Items[i].Status.StartTime
+		if started != nil && (first == nil || started.Time.Before(*first)) {
+			t := started.Time
+			first = &t
+		}
+	}
+	return first
+}
+
+// recordFailureReason sets the error message of a failed job, unless one is already set, from
+// the events of its pods, e.g. an image that could not be pulled or a volume that did not attach.
+func recordFailureReason(ctx context.Context, j *job.Job, status job.JobStatus) {
+	if status != job.StatusFailed || j.ErrorMessage != "" {
+		return
+	}
+	pods, err := k8s.Clientset.CoreV1().Pods(j.Namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("job-name=%s", j.K8sJobName)})
+	if err != nil {
+		return
+	}
+	for _, p := range pods.Items {
+		if reasons := k8s.ExplainPodFailure(ctx, j.Namespace, p.Name); len(reasons) > 0 {
+			j.ErrorMessage = strings.Join(reasons, "; ")
+			return
+		}
+	}
+}
+
+// recordEvents stores the events of the K8s Job of j and of its pods for the job timeline, as
+// Kubernetes drops events after an hour. It lists the events of the namespace once and keeps
+// those of the job's objects. The repository tracks the count stored per event, so an event
+// observed again only adds the repeats that happened since, also after a restart; seen skips
+// events whose count did not change since this watch stored them.
+func (e *K8sExecutor) recordEvents(ctx context.Context, j *job.Job, seen map[types.UID]int32) {
+	if e.jobRepo == nil {
+		return
+	}
+	objects := map[string]bool{"Job/" + j.K8sJobName: true}
+	pods, err := k8s.Clientset.CoreV1().Pods(j.Namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("job-name=%s", j.K8sJobName)})
+	if err == nil {
+		for _, p := range pods.Items {
+			objects["Pod/"+p.Name] = true
+		}
+	}
+	list, err := k8s.Clientset.CoreV1().Events(j.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Printf("list events of job %d failed: %v", j.ID, err)
 		return
 	}
+	for i := range list.Items {
+		ev := &list.Items[i]
+		if !objects[ev.InvolvedObject.Kind+"/"+ev.InvolvedObject.Name] {
+			continue
+		}
+		count := eventCount(ev)
+		if count <= seen[ev.UID] {
+			continue
+		}
+		if err := e.jobRepo.SaveK8sEvent(jobEventFrom(j.ID, ev, count), string(ev.UID)); err != nil {
+			log.Printf("save event of job %d failed: %v", j.ID, err)
+			continue
+		}
+		seen[ev.UID] = count
+	}
+}
+
+// eventCount is how often a K8s event happened, from its series when Kubernetes aggregated it.
+func eventCount(ev *corev1.Event) int32 {
+	count := ev.Count
+	if ev.Series != nil && ev.Series.Count > count {
+		count = ev.Series.Count
+	}
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// jobEventFrom converts a K8s event of job jobID that happened count more times.
+func jobEventFrom(jobID uint, ev *corev1.Event, count int32) *job.JobEvent {
+	first := ev.FirstTimestamp.Time
+	if first.IsZero() {
+		first = ev.EventTime.Time
+	}
+	if first.IsZero() {
+		first = ev.CreationTimestamp.Time
+	}
+	last := ev.LastTimestamp.Time
+	if ev.Series != nil && ev.Series.LastObservedTime.After(last) {
+		last = ev.Series.LastObservedTime.Time
+	}
+	if last.Before(first) {
+		last = first
+	}
+	severity := job.SeverityInfo
+	if ev.Type == corev1.EventTypeWarning {
+		severity = job.SeverityWarning
+	}
+	sum := sha256.Sum256([]byte(ev.InvolvedObject.Kind + "/" + ev.InvolvedObject.Name + "/" + ev.Reason + "/" + ev.Message))
+	return &job.JobEvent{
+		JobID:       jobID,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		ObjectKind:  ev.InvolvedObject.Kind,
+		ObjectName:  ev.InvolvedObject.Name,
+		Reason:      ev.Reason,
+		Message:     ev.Message,
+		Source:      job.EventSourceKubernetes,
+		Severity:    severity,
+		Count:       int(count),
+		FirstSeen:   first,
+		LastSeen:    last,
+	}
+}
+
+// recordStatus stores for the job timeline that j reached its current status at.
+func recordStatus(repo job.Repository, j *job.Job, at time.Time) {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("status/%s/%d", j.Status, at.UnixNano())))
+	event := &job.JobEvent{
+		JobID:       j.ID,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		Source:      job.EventSourceStatus,
+		Reason:      j.Status,
+		Severity:    job.StatusSeverity(j.Status),
+		Count:       1,
+		FirstSeen:   at,
+		LastSeen:    at,
+	}
+	if event.Severity != job.SeverityInfo {
+		event.Message = j.ErrorMessage
+	}
+	if err := repo.SaveEvent(event); err != nil {
+		log.Printf("save status of job %d failed: %v", j.ID, err)
+	}
+}
+
+// InferJobStatus derives the job status from a K8s Job object alone.
+func InferJobStatus(obj *batchv1.Job) job.JobStatus {
+	if status, done := evaluateJobStatus(obj); done {
+		return status
+	}
+	if obj.Status.Active > 0 {
+		return job.JobStatusRunning
+	}
+	return job.StatusPending
 }
 
 func evaluateJobStatus(obj *batchv1.Job) (job.JobStatus, bool) {
+	for _, c := range obj.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue && c.Reason == batchv1.JobReasonDeadlineExceeded {
+			return job.JobStatusTimedOut, true
+		}
+	}
 	if obj.Status.Succeeded > 0 {
 		return job.StatusCompleted, true
 	}
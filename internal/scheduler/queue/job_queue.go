@@ -26,16 +26,7 @@ func NewJobQueue() *JobQueue {
 func (jq *JobQueue) Push(j *job.Job) {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
-	p := 0
-	switch j.Priority {
-	case "high":
-		p = 3
-	case "medium":
-		p = 2
-	default:
-		p = 1
-	}
-	heap.Push(&jq.items, &queueItem{job: j, priority: p})
+	heap.Push(&jq.items, &queueItem{job: j, priority: job.PriorityRank(j.Priority)})
 }
 
 // Pop removes and returns the highest priority job
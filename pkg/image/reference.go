@@ -0,0 +1,134 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidReference means a string is not a valid container image reference.
+var ErrInvalidReference = errors.New("invalid image reference")
+
+const (
+	// DefaultRegistry hosts references that name no registry.
+	DefaultRegistry = "docker.io"
+	// DefaultTag is used by references with neither a tag nor a digest.
+	DefaultTag = "latest"
+
+	officialNamespace = "library"
+)
+
+var (
+	pathComponentRe = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagRe           = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	digestRe        = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`)
+	registryRe      = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9.-]*[a-z0-9])?(?::[0-9]+)?$`)
+)
+
+// Reference is a parsed image reference such as "registry.lab.edu:5000/team/app:v1" or
+// "nginx@sha256:...", with the Docker defaults filled in.
+type Reference struct {
+	// Registry is the lowercased registry host, with its port; DefaultRegistry when omitted.
+	Registry string
+	// Repository is the path within the registry. Official Docker Hub images are under "library/".
+	Repository string
+	// Tag is DefaultTag when neither a tag nor a digest was given, and empty for a reference
+	// pinned only by digest.
+	Tag    string
+	Digest string
+}
+
+// Parse splits ref into its registry, repository, tag and digest. Like Docker, it reads the
+// first path segment as a registry host only when it contains "." or ":" or is "localhost".
+func Parse(ref string) (Reference, error) {
+	s := strings.TrimSpace(ref)
+	if s == "" {
+		return Reference{}, fmt.Errorf("%w: empty reference", ErrInvalidReference)
+	}
+
+	var r Reference
+	if i := strings.Index(s, "@"); i >= 0 {
+		s, r.Digest = s[:i], s[i+1:]
+		if !digestRe.MatchString(r.Digest) {
+			return Reference{}, fmt.Errorf("%w: %q has an invalid digest", ErrInvalidReference, ref)
+		}
+	}
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		s, r.Tag = s[:i], s[i+1:]
+		if !tagRe.MatchString(r.Tag) {
+			return Reference{}, fmt.Errorf("%w: %q has an invalid tag", ErrInvalidReference, ref)
+		}
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = DefaultTag
+	}
+
+	r.Registry = DefaultRegistry
+	if first, rest, ok := strings.Cut(s, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry = normalizeRegistry(first)
+		s = rest
+		if !registryRe.MatchString(r.Registry) {
+			return Reference{}, fmt.Errorf("%w: %q has an invalid registry host", ErrInvalidReference, ref)
+		}
+	}
+	if s == "" {
+		return Reference{}, fmt.Errorf("%w: %q has no repository", ErrInvalidReference, ref)
+	}
+	for _, component := range strings.Split(s, "/") {
+		if !pathComponentRe.MatchString(component) {
+			return Reference{}, fmt.Errorf("%w: %q has an invalid repository name", ErrInvalidReference, ref)
+		}
+	}
+	if r.Registry == DefaultRegistry && !strings.Contains(s, "/") {
+		s = officialNamespace + "/" + s
+	}
+	r.Repository = s
+	return r, nil
+}
+
+// normalizeRegistry lowercases a registry host and maps the Docker Hub aliases to docker.io.
+func normalizeRegistry(host string) string {
+	host = strings.ToLower(host)
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return DefaultRegistry
+	}
+	return host
+}
+
+// Name is the fully qualified repository name, "docker.io/library/nginx" for "nginx".
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// FamiliarName is the repository name in the short form users write and the image allow list
+// stores: Docker Hub images lose "docker.io/" and "library/", other registries are kept.
+func (r Reference) FamiliarName() string {
+	if r.Registry != DefaultRegistry {
+		return r.Name()
+	}
+	return strings.TrimPrefix(r.Repository, officialNamespace+"/")
+}
+
+// Version is what the reference selects within its repository: the tag, or the digest of a
+// reference pinned only by digest.
+func (r Reference) Version() string {
+	if r.Tag != "" {
+		return r.Tag
+	}
+	return r.Digest
+}
+
+// String is the familiar form of the reference with its tag and digest, "nginx:latest" for
+// "nginx".
+func (r Reference) String() string {
+	s := r.FamiliarName()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
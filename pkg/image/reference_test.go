@@ -0,0 +1,118 @@
+package image
+
+import (
+	"errors"
+	"testing"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		ref      string
+		want     Reference
+		familiar string
+		str      string
+	}{
+		{"nginx", Reference{"docker.io", "library/nginx", "latest", ""}, "nginx", "nginx:latest"},
+		{"nginx:1.25", Reference{"docker.io", "library/nginx", "1.25", ""}, "nginx", "nginx:1.25"},
+		{" nginx:1.25 ", Reference{"docker.io", "library/nginx", "1.25", ""}, "nginx", "nginx:1.25"},
+		{"pytorch/pytorch:2.1-cuda12", Reference{"docker.io", "pytorch/pytorch", "2.1-cuda12", ""}, "pytorch/pytorch", "pytorch/pytorch:2.1-cuda12"},
+		{"docker.io/library/nginx", Reference{"docker.io", "library/nginx", "latest", ""}, "nginx", "nginx:latest"},
+		{"docker.io/nginx:1", Reference{"docker.io", "library/nginx", "1", ""}, "nginx", "nginx:1"},
+		{"index.docker.io/library/nginx:1", Reference{"docker.io", "library/nginx", "1", ""}, "nginx", "nginx:1"},
+		{"ghcr.io/lab/trainer:v1", Reference{"ghcr.io", "lab/trainer", "v1", ""}, "ghcr.io/lab/trainer", "ghcr.io/lab/trainer:v1"},
+		{"registry.lab.edu:5000/team/app", Reference{"registry.lab.edu:5000", "team/app", "latest", ""}, "registry.lab.edu:5000/team/app", "registry.lab.edu:5000/team/app:latest"},
+		{"Registry.Lab.edu:5000/team/app:v2", Reference{"registry.lab.edu:5000", "team/app", "v2", ""}, "registry.lab.edu:5000/team/app", "registry.lab.edu:5000/team/app:v2"},
+		{"192.168.110.1:30003/library/pros-cameraapi:1.0", Reference{"192.168.110.1:30003", "library/pros-cameraapi", "1.0", ""}, "192.168.110.1:30003/library/pros-cameraapi", "192.168.110.1:30003/library/pros-cameraapi:1.0"},
+		{"localhost/app", Reference{"localhost", "app", "latest", ""}, "localhost/app", "localhost/app:latest"},
+		{"localhost:5000/app:dev", Reference{"localhost:5000", "app", "dev", ""}, "localhost:5000/app", "localhost:5000/app:dev"},
+		{"nginx@" + testDigest, Reference{"docker.io", "library/nginx", "", testDigest}, "nginx", "nginx@" + testDigest},
+		{"nginx:1.25@" + testDigest, Reference{"docker.io", "library/nginx", "1.25", testDigest}, "nginx", "nginx:1.25@" + testDigest},
+		{"registry.lab.edu:5000/app@" + testDigest, Reference{"registry.lab.edu:5000", "app", "", testDigest}, "registry.lab.edu:5000/app", "registry.lab.edu:5000/app@" + testDigest},
+		{"my_org/my.app__x-y:V_1.0", Reference{"docker.io", "my_org/my.app__x-y", "V_1.0", ""}, "my_org/my.app__x-y", "my_org/my.app__x-y:V_1.0"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.ref, func(t *testing.T) {
+			got, err := Parse(tc.ref)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned %v", tc.ref, err)
+			}
+			if got != tc.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tc.ref, got, tc.want)
+			}
+			if f := got.FamiliarName(); f != tc.familiar {
+				t.Errorf("FamiliarName() = %q, want %q", f, tc.familiar)
+			}
+			if s := got.String(); s != tc.str {
+				t.Errorf("String() = %q, want %q", s, tc.str)
+			}
+			if again, err := Parse(got.String()); err != nil || again != got {
+				t.Errorf("String() does not parse back: %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidReferences(t *testing.T) {
+	for _, ref := range []string{
+		"",
+		"   ",
+		"Nginx",
+		"nginx:",
+		":latest",
+		"nginx:bad tag",
+		"nginx:-dash",
+		"nginx@sha256:abc",
+		"nginx@" + testDigest + "@" + testDigest,
+		"nginx@latest",
+		"registry.lab.edu:5000/",
+		"registry.lab.edu:port/app",
+		"team//app",
+		"team/app/",
+		"-team/app",
+		"team/app-",
+		"ghcr.io/Lab/app",
+	} {
+		if got, err := Parse(ref); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("Parse(%q) = %+v, %v; want ErrInvalidReference", ref, got, err)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                             "latest",
+		"nginx:1.25":                        "1.25",
+		"nginx@" + testDigest:               testDigest,
+		"nginx:1.25@" + testDigest:          "1.25",
+		"ghcr.io/lab/trainer@" + testDigest: testDigest,
+	}
+	for ref, want := range cases {
+		r, err := Parse(ref)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned %v", ref, err)
+		}
+		if got := r.Version(); got != want {
+			t.Errorf("Version() of %q = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                  "docker.io/library/nginx",
+		"codercom/code-server":   "docker.io/codercom/code-server",
+		"ghcr.io/lab/trainer:v1": "ghcr.io/lab/trainer",
+		"localhost:5000/app":     "localhost:5000/app",
+	}
+	for ref, want := range cases {
+		r, err := Parse(ref)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned %v", ref, err)
+		}
+		if got := r.Name(); got != want {
+			t.Errorf("Name() of %q = %q, want %q", ref, got, want)
+		}
+	}
+}
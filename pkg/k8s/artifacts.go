@@ -0,0 +1,129 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ArtifactUpload asks for files matching Glob to be uploaded to the platform bucket under
+// jobs/{jobID}/{Prefix} once the main container exits. A relative Glob is resolved against
+// config.ArtifactMountPath, an emptyDir shared by both containers; absolute paths may point into
+// the job's other volumes, which the uploader mounts read-only.
+type ArtifactUpload struct {
+	Prefix string `json:",omitempty"`
+	Glob   string
+}
+
+const (
+	artifactUploaderName = "artifact-uploader"
+	artifactVolumeName   = "artifacts"
+	// Set on the main container so the uploader can tell when its processes are gone
+	artifactOwnerEnv = "PLATFORM_ARTIFACT_OWNER"
+	// Keys of the per-namespace credentials Secret
+	artifactAccessKeyKey = "accessKey"
+	artifactSecretKeyKey = "secretKey"
+)
+
+// artifactUploadScript waits for the main container's processes to appear and then to exit,
+// visible through the shared process namespace, and copies the matching files with mc. It exits
+// non-zero when an upload fails so the container is restarted and the upload retried. The owner
+// marker is assembled in the script so the uploader's own environment never matches it.
+const artifactUploadScript = `set -u
+find_main() { grep -lsa "` + artifactOwnerEnv + `=${ARTIFACT_OWNER}" /proc/[0-9]*/environ >/dev/null 2>&1; }
+waited=0
+until find_main || [ "$waited" -ge 60 ]; do sleep 1; waited=$((waited+1)); done
+while find_main; do sleep 2; done
+mc --insecure alias set artifacts "$ARTIFACT_ENDPOINT" "$ARTIFACT_ACCESS_KEY" "$ARTIFACT_SECRET_KEY" >/dev/null || exit 1
+cd "$ARTIFACT_DIR" || exit 1
+found=0
+for f in $ARTIFACT_GLOB; do
+  [ -f "$f" ] || continue
+  mc --insecure cp "$f" "artifacts/$ARTIFACT_BUCKET/$ARTIFACT_PREFIX${f#/}" || exit 1
+  found=1
+done
+[ "$found" -eq 1 ] || echo "no files matched $ARTIFACT_GLOB"
+exit 0
+`
+
+// ArtifactObjectPrefix is the key prefix under which the artifacts of a job are stored.
+func ArtifactObjectPrefix(jobID uint, prefix string) string {
+	p := fmt.Sprintf("jobs/%d/", jobID)
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		p += prefix + "/"
+	}
+	return p
+}
+
+func artifactEndpoint() string {
+	scheme := "http"
+	if config.MinioUseSSL {
+		scheme = "https"
+	}
+	return scheme + "://" + config.MinioEndpoint
+}
+
+// applyArtifactUpload adds the uploader container to the pod of a job and shares the artifact
+// directory and process namespace with the main container at index 0.
+func applyArtifactUpload(pod *corev1.PodSpec, upload *ArtifactUpload, objectPrefix string) {
+	main := &pod.Containers[0]
+	shared := true
+	pod.ShareProcessNamespace = &shared
+	pod.Volumes = append(pod.Volumes, corev1.Volume{
+		Name:         artifactVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+
+	main.VolumeMounts = append(main.VolumeMounts, corev1.VolumeMount{Name: artifactVolumeName, MountPath: config.ArtifactMountPath})
+	main.Env = append(main.Env,
+		corev1.EnvVar{Name: artifactOwnerEnv, Value: main.Name},
+		corev1.EnvVar{Name: "ARTIFACT_DIR", Value: config.ArtifactMountPath},
+	)
+
+	mounts := []corev1.VolumeMount{{Name: artifactVolumeName, MountPath: config.ArtifactMountPath}}
+	for _, m := range main.VolumeMounts {
+		if m.Name == artifactVolumeName {
+			continue
+		}
+		m.ReadOnly = true
+		mounts = append(mounts, m)
+	}
+	secretRef := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: config.ArtifactSecretName},
+			Key:                  key,
+		}}
+	}
+	pod.Containers = append(pod.Containers, corev1.Container{
+		Name:         artifactUploaderName,
+		Image:        config.ArtifactUploaderImage,
+		Command:      []string{"/bin/sh", "-c", artifactUploadScript},
+		VolumeMounts: mounts,
+		Env: []corev1.EnvVar{
+			{Name: "ARTIFACT_OWNER", Value: main.Name},
+			{Name: "ARTIFACT_DIR", Value: config.ArtifactMountPath},
+			{Name: "ARTIFACT_GLOB", Value: upload.Glob},
+			{Name: "ARTIFACT_BUCKET", Value: config.MinioBucket},
+			{Name: "ARTIFACT_PREFIX", Value: objectPrefix},
+			{Name: "ARTIFACT_ENDPOINT", Value: artifactEndpoint()},
+			{Name: "ARTIFACT_ACCESS_KEY", ValueFrom: secretRef(artifactAccessKeyKey)},
+			{Name: "ARTIFACT_SECRET_KEY", ValueFrom: secretRef(artifactSecretKeyKey)},
+			{Name: "MC_CONFIG_DIR", Value: "/tmp/.mc"},
+		},
+	})
+}
+
+// EnsureArtifactSecret writes the uploader credentials into the job namespace.
+func EnsureArtifactSecret(ctx context.Context, ns string) error {
+	accessKey, secretKey := config.ArtifactAccessKey, config.ArtifactSecretKey
+	if accessKey == "" {
+		accessKey, secretKey = config.MinioAccessKey, config.MinioSecretKey
+	}
+	return EnsureOpaqueSecret(ctx, ns, config.ArtifactSecretName, map[string]string{
+		artifactAccessKeyKey: accessKey,
+		artifactSecretKeyKey: secretKey,
+	}, Ownership{}.Labels())
+}
@@ -0,0 +1,87 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateJobAddsArtifactUploader(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+
+	spec := JobSpec{
+		Name:      "train",
+		Namespace: "proj-1-bob",
+		Image:     "pytorch:2.3",
+		Volumes:   []VolumeSpec{{Name: "data", PVCName: "project-1-disk", MountPath: "/workspace"}},
+		Labels:    Ownership{ProjectID: 1, JobID: 42}.Labels(),
+		Artifacts: &ArtifactUpload{Prefix: "run-1", Glob: "/workspace/out/*.pt"},
+	}
+	if err := CreateJob(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	job, err := Clientset.BatchV1().Jobs("proj-1-bob").Get(ctx, "train", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("job not created: %v", err)
+	}
+	pod := job.Spec.Template.Spec
+	if pod.ShareProcessNamespace == nil || !*pod.ShareProcessNamespace {
+		t.Fatalf("expected the process namespace to be shared")
+	}
+	if len(pod.Containers) != 2 || pod.Containers[1].Name != artifactUploaderName {
+		t.Fatalf("expected main and uploader containers, got %+v", pod.Containers)
+	}
+
+	main, uploader := pod.Containers[0], pod.Containers[1]
+	if !hasEnv(main.Env, artifactOwnerEnv, "train") {
+		t.Fatalf("main container must carry the owner marker, got %v", main.Env)
+	}
+	if !hasEnv(uploader.Env, "ARTIFACT_PREFIX", "jobs/42/run-1/") || !hasEnv(uploader.Env, "ARTIFACT_GLOB", "/workspace/out/*.pt") {
+		t.Fatalf("unexpected uploader env %v", uploader.Env)
+	}
+	for _, e := range uploader.Env {
+		if e.Name == "ARTIFACT_SECRET_KEY" && (e.ValueFrom == nil || e.ValueFrom.SecretKeyRef.Name != config.ArtifactSecretName) {
+			t.Fatalf("credentials must come from the namespace secret, got %+v", e)
+		}
+		if strings.Contains(e.Value, artifactOwnerEnv+"=") {
+			t.Fatalf("the uploader environment must not match the owner marker: %+v", e)
+		}
+	}
+	mounted := map[string]bool{}
+	for _, m := range uploader.VolumeMounts {
+		mounted[m.MountPath] = true
+		if m.Name == "data" && !m.ReadOnly {
+			t.Fatalf("job volumes must be mounted read-only in the uploader")
+		}
+	}
+	if !mounted["/workspace"] || !mounted[config.ArtifactMountPath] {
+		t.Fatalf("expected job volume and artifact dir in uploader, got %v", uploader.VolumeMounts)
+	}
+
+	if _, err := Clientset.CoreV1().Secrets("proj-1-bob").Get(ctx, config.ArtifactSecretName, metav1.GetOptions{}); err != nil {
+		t.Fatalf("artifact credentials secret not created: %v", err)
+	}
+
+	spec.Name = "unlabelled"
+	spec.Labels = nil
+	if err := CreateJob(ctx, spec); err == nil {
+		t.Fatalf("an artifact upload without a job id must be rejected")
+	}
+}
+
+func hasEnv(env []corev1.EnvVar, name, value string) bool {
+	for _, e := range env {
+		if e.Name == name && e.Value == value {
+			return true
+		}
+	}
+	return false
+}
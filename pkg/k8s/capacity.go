@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GPU resource names: whole GPUs and MPS shares of one.
+const (
+	GPUResource       corev1.ResourceName = "nvidia.com/gpu"
+	SharedGPUResource corev1.ResourceName = "nvidia.com/gpu.shared"
+)
+
+// NodeGPUCapacity is the allocatable and requested amount of one GPU resource on a node.
+type NodeGPUCapacity struct {
+	Node        string `json:"node"`
+	Allocatable int64  `json:"allocatable"`
+	Requested   int64  `json:"requested"`
+	Free        int64  `json:"free"`
+}
+
+// GPUCapacity sums one GPU resource over the schedulable nodes of the cluster.
+type GPUCapacity struct {
+	Resource    string            `json:"resource"`
+	Allocatable int64             `json:"allocatable"`
+	Requested   int64             `json:"requested"`
+	Free        int64             `json:"free"`
+	Nodes       []NodeGPUCapacity `json:"nodes"`
+}
+
+// GetGPUCapacity reports how much of resource is free on each schedulable node. Requests of
+// pods that have finished are not counted; pending pods not yet bound to a node are not either.
+func GetGPUCapacity(ctx context.Context, resource corev1.ResourceName) (*GPUCapacity, error) {
+	if Clientset == nil {
+		return nil, fmt.Errorf("k8s client not available")
+	}
+	nodes, err := Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	pods, err := Clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	requested := map[string]int64{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			if qty, ok := c.Resources.Requests[resource]; ok {
+				requested[pod.Spec.NodeName] += qty.Value()
+			}
+		}
+	}
+
+	capacity := &GPUCapacity{Resource: string(resource), Nodes: []NodeGPUCapacity{}}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		qty, ok := node.Status.Allocatable[resource]
+		if !ok || qty.Value() == 0 {
+			continue
+		}
+		n := NodeGPUCapacity{Node: node.Name, Allocatable: qty.Value(), Requested: requested[node.Name]}
+		n.Free = max(n.Allocatable-n.Requested, 0)
+		capacity.Allocatable += n.Allocatable
+		capacity.Requested += n.Requested
+		capacity.Free += n.Free
+		capacity.Nodes = append(capacity.Nodes, n)
+	}
+	sort.Slice(capacity.Nodes, func(i, j int) bool { return capacity.Nodes[i].Node < capacity.Nodes[j].Node })
+	return capacity, nil
+}
+
+// FitsGang reports whether pods pods of perPod units each can all be placed at the same time.
+// A pod has to fit on a single node, so the free units are counted per node.
+func (c *GPUCapacity) FitsGang(perPod int64, pods int) bool {
+	if perPod <= 0 || pods <= 0 {
+		return true
+	}
+	placeable := int64(0)
+	for _, n := range c.Nodes {
+		placeable += n.Free / perPod
+	}
+	return placeable >= int64(pods)
+}
@@ -3,14 +3,17 @@ package k8s
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 	"github.com/linskybing/platform-go/internal/config"
@@ -24,7 +27,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
@@ -46,8 +49,21 @@ type WebSocketIO struct {
 	sizeChan    chan remotecommand.TerminalSize
 	once        sync.Once
 	mu          sync.Mutex // Protects concurrent writes (Ping vs Stdout)
+	session     *TerminalSession
+
+	outMu      sync.Mutex  // Protects the stdout buffer below
+	pending    []byte      // Stdout not sent yet: coalesced small writes and a split UTF-8 tail
+	flushTimer *time.Timer // Sends pending once the coalescing window ends
+	outErr     error       // Error of a timer flush, returned by the next Write
 }
 
+// Small stdout writes are held back until stdoutCoalesceBytes are pending or stdoutCoalesceDelay
+// passed, so chatty programs do not send one WebSocket message per character.
+var (
+	stdoutCoalesceBytes = 64
+	stdoutCoalesceDelay = 5 * time.Millisecond
+)
+
 type TerminalMessage struct {
 	Type string `json:"type"`
 	Data string `json:"data,omitempty"` // For stdin/stdout
@@ -61,7 +77,7 @@ var (
 	Dc            *discovery.DiscoveryClient
 	Resources     []*restmapper.APIGroupResources
 	Mapper        meta.RESTMapper
-	DynamicClient *dynamic.DynamicClient
+	DynamicClient dynamic.Interface
 )
 
 func InitTestCluster() {
@@ -103,6 +119,7 @@ func InitTestCluster() {
 	if err != nil {
 		log.Fatalf("failed to create dynamic client: %v", err)
 	}
+	Snapshots = DynamicClient
 }
 
 func Init() {
@@ -119,6 +136,8 @@ func Init() {
 	if err != nil {
 		log.Fatalf("failed to load kube config: %v", err)
 	}
+	// Records the platform as the field manager of what it writes, see ExternalChanges
+	Config.UserAgent = FieldManager
 	Clientset, err = kubernetes.NewForConfig(Config)
 	if err != nil {
 		log.Fatalf("failed to create kubernetes clientset: %v", err)
@@ -132,16 +151,23 @@ func Init() {
 		log.Fatalf("failed to get api group resources: %v", err)
 	}
 	Mapper = restmapper.NewDiscoveryRESTMapper(Resources)
+	Metrics = NewMetricsClient(Dc)
 	Config.QPS = 50
 	Config.Burst = 100
 	DynamicClient, err = dynamic.NewForConfig(Config)
 	if err != nil {
 		log.Fatalf("failed to create dynamic client: %v", err)
 	}
+	Snapshots = DynamicClient
 }
 
 // NewWebSocketIO creates a new WebSocketIO handler and starts loops
 func NewWebSocketIO(conn *websocket.Conn) *WebSocketIO {
+	return newWebSocketIO(conn, nil)
+}
+
+// newWebSocketIO sets the session before the loops start so they never race on it
+func newWebSocketIO(conn *websocket.Conn, session *TerminalSession) *WebSocketIO {
 	pr, pw := io.Pipe()
 
 	// Context for internal coordination
@@ -152,6 +178,7 @@ func NewWebSocketIO(conn *websocket.Conn) *WebSocketIO {
 		stdinPipe:   pr,
 		stdinWriter: pw,
 		sizeChan:    make(chan remotecommand.TerminalSize),
+		session:     session,
 		// cancel:      cancel,
 	}
 
@@ -195,25 +222,91 @@ func (h *WebSocketIO) Read(p []byte) (n int, err error) {
 	return h.stdinPipe.Read(p)
 }
 
-// Write writes data to WebSocket (stdout from Pod)
+// Write writes data to WebSocket (stdout from Pod). The SPDY stream may split a multi-byte
+// UTF-8 character across calls, so an incomplete character at the end of p is kept and sent
+// with the next write instead of being turned into replacement characters by string(p).
 func (h *WebSocketIO) Write(p []byte) (n int, err error) {
+	h.outMu.Lock()
+	defer h.outMu.Unlock()
+	if h.outErr != nil {
+		return 0, h.outErr
+	}
+
+	h.pending = append(h.pending, p...)
+	if len(h.pending) < stdoutCoalesceBytes {
+		if h.flushTimer == nil {
+			h.flushTimer = time.AfterFunc(stdoutCoalesceDelay, h.flush)
+		}
+		return len(p), nil
+	}
+	if err := h.sendPendingLocked(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flush sends the pending stdout; it runs when the coalescing window ends and after the stream.
+func (h *WebSocketIO) flush() {
+	h.outMu.Lock()
+	defer h.outMu.Unlock()
+	if h.outErr != nil {
+		return
+	}
+	h.outErr = h.sendPendingLocked()
+}
+
+// sendPendingLocked sends every complete character of the pending stdout. Callers hold outMu.
+func (h *WebSocketIO) sendPendingLocked() error {
+	if h.flushTimer != nil {
+		h.flushTimer.Stop()
+		h.flushTimer = nil
+	}
+	n := completeUTF8Prefix(h.pending)
+	if n == 0 {
+		return nil
+	}
+	err := h.sendStdout(h.pending[:n])
+	h.pending = append(h.pending[:0], h.pending[n:]...)
+	return err
+}
+
+func (h *WebSocketIO) sendStdout(p []byte) error {
 	msg, err := json.Marshal(TerminalMessage{
 		Type: "stdout",
 		Data: string(p),
 	})
 	if err != nil {
-		return 0, err
+		return err
 	}
 
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	// Update WriteDeadline before writing
 	_ = h.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	if err := h.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-		return 0, err
+	err = h.conn.WriteMessage(websocket.TextMessage, msg)
+	h.mu.Unlock()
+	if err != nil {
+		return err
 	}
-	return len(p), nil
+
+	// Observers get the same frame through their own queues
+	if h.session != nil {
+		h.session.broadcast(msg)
+	}
+	return nil
+}
+
+// completeUTF8Prefix returns the length of b without a trailing incomplete UTF-8 character.
+// Invalid bytes count as complete; they cannot be fixed by waiting for more output.
+func completeUTF8Prefix(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
 }
 
 // Next is called by executor to wait for a resize event (implements remotecommand.TerminalSizeQueue)
@@ -244,6 +337,9 @@ func (h *WebSocketIO) readLoop() {
 		h.Close()          // Close pipes
 		close(h.sizeChan)  // Close channel safely (ONLY here)
 		_ = h.conn.Close() // Ensure underlying TCP connection is closed
+		if h.session != nil {
+			h.session.Close() // Owner left: detach observers
+		}
 	}()
 
 	const pongWait = 60 * time.Second
@@ -305,8 +401,13 @@ func ExecToPodViaWebSocket(
 	namespace, podName, container string,
 	command []string,
 	tty bool,
+	session *TerminalSession,
 ) error {
-	wsIO := NewWebSocketIO(conn)
+	wsIO := newWebSocketIO(conn, session)
+	if session != nil {
+		session.bind(wsIO)
+		defer session.Close()
+	}
 
 	// DO NOT call defer wsIO.Close() here.
 	// Lifecycle is managed by NewWebSocketIO's goroutines.
@@ -338,31 +439,46 @@ func ExecToPodViaWebSocket(
 	}
 
 	// This blocks until the command finishes
-	return executor.StreamWithContext(context.Background(), remotecommand.StreamOptions{
+	err = executor.StreamWithContext(context.Background(), remotecommand.StreamOptions{
 		Stdin:             wsIO,
 		Stdout:            wsIO,
 		Stderr:            wsIO,
 		Tty:               tty,
 		TerminalSizeQueue: wsIO,
 	})
+	// Send the last coalesced output before the caller closes the connection
+	wsIO.flush()
+	return err
 }
 
-// WatchNamespaceResources monitors resources for a specific namespace
+// WatchNamespaceResources monitors resources for a specific namespace. writeChan is bounded; a
+// slow client loses messages instead of stalling the watchers, and is told to resync.
 func WatchNamespaceResources(ctx context.Context, writeChan chan<- []byte, namespace string) {
-	gvrs := []schema.GroupVersionResource{
-		{Group: "", Version: "v1", Resource: "pods"},
-		{Group: "", Version: "v1", Resource: "services"},
-		{Group: "apps", Version: "v1", Resource: "deployments"},
+	WatchNamespaceResourcesFor(ctx, writeChan, namespace, 0)
+}
+
+// WatchNamespaceResourcesFor is WatchNamespaceResources for a known viewer: every object is sent
+// with "mine" telling whether the viewer owns it, which matters in shared project namespaces.
+func WatchNamespaceResourcesFor(ctx context.Context, writeChan chan<- []byte, namespace string, viewerID uint) {
+	gvrs := NamespaceGVRs
+	sender := newWatchSender(writeChan)
+	if viewerID != 0 {
+		sender.viewer = strconv.FormatUint(uint64(viewerID), 10)
 	}
 
 	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sender.run(ctx, config.WatchStatsInterval)
+	}()
 	for _, gvr := range gvrs {
 		wg.Add(1)
 		time.Sleep(50 * time.Millisecond) // Stagger start to be gentle on APIServer
 
 		go func(gvr schema.GroupVersionResource) {
 			defer wg.Done()
-			watchAndSend(ctx, DynamicClient, gvr, namespace, writeChan)
+			watchAndSend(ctx, DynamicClient, gvr, namespace, sender)
 		}(gvr)
 	}
 
@@ -376,18 +492,20 @@ func WatchNamespaceResources(ctx context.Context, writeChan chan<- []byte, names
 
 // WatchNamespaceResources monitors resource changes in a single namespace
 func WatchUserNamespaceResources(ctx context.Context, namespace string, writeChan chan<- []byte) {
-	gvrs := []schema.GroupVersionResource{
-		{Group: "", Version: "v1", Resource: "pods"},
-		{Group: "", Version: "v1", Resource: "services"},
-		{Group: "apps", Version: "v1", Resource: "deployments"},
-	}
+	gvrs := NamespaceGVRs
+	sender := newWatchSender(writeChan)
 
 	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sender.run(ctx, config.WatchStatsInterval)
+	}()
 	for _, gvr := range gvrs {
 		wg.Add(1)
 		go func(gvr schema.GroupVersionResource) {
 			defer wg.Done()
-			watchUserAndSend(ctx, namespace, gvr, writeChan)
+			watchUserAndSend(ctx, namespace, gvr, sender)
 		}(gvr)
 	}
 
@@ -395,52 +513,16 @@ func WatchUserNamespaceResources(ctx context.Context, namespace string, writeCha
 	wg.Wait()
 }
 
-func watchUserAndSend(ctx context.Context, namespace string, gvr schema.GroupVersionResource, writeChan chan<- []byte) {
-	// lastSnapshot holds last sent status signature per resource name
-	lastSnapshot := make(map[string]string)
-
-	sendObject := func(eventType string, obj *unstructured.Unstructured) error {
-		name := obj.GetName()
-
-		// Always send deletes
-		if eventType != "DELETED" {
-			// Compute compact snapshot to decide whether to send
-			snap := statusSnapshotString(obj)
-			if prev, ok := lastSnapshot[name]; ok {
-				if prev == snap {
-					// No meaningful status change, skip sending
-					return nil
-				}
-			}
-			lastSnapshot[name] = snap
-		} else {
-			// remove snapshot on delete
-			delete(lastSnapshot, name)
-		}
-
-		data := buildDataMap(eventType, obj)
-		msg, err := json.Marshal(data)
-		if err != nil {
-			return err
-		}
-
-		select {
-		case writeChan <- msg:
-			return nil
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			// Prevent blocking if client is slow
-			return fmt.Errorf("client buffer full, dropping message for %s", name)
-		}
-	}
-
-	list, err := DynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
-	if err == nil {
-		for _, item := range list.Items {
-			_ = sendObject("ADDED", &item)
+func watchUserAndSend(ctx context.Context, namespace string, gvr schema.GroupVersionResource, sender *watchSender) {
+	st := newGVRStream(ctx, gvr, sender)
+	client := DynamicClient.Resource(gvr).Namespace(namespace)
+	resourceVersion := ""
+	relist := func() {
+		if rv, err := st.sendSnapshot(client.List, metav1.ListOptions{}); err == nil {
+			resourceVersion = rv
 		}
 	}
+	relist()
 
 	for {
 		select {
@@ -448,23 +530,39 @@ func watchUserAndSend(ctx context.Context, namespace string, gvr schema.GroupVer
 			return
 		case <-time.After(time.Second * 30):
 			// Simple reconnection logic
-			watcher, err := DynamicClient.Resource(gvr).Namespace(namespace).Watch(ctx, metav1.ListOptions{})
+			watcher, err := client.Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
 			if err != nil {
 				continue
 			}
 
 			func() {
 				defer watcher.Stop()
+				check := time.NewTicker(pendingCheckInterval)
+				defer check.Stop()
+				resync := time.NewTicker(config.WatchStatsInterval)
+				defer resync.Stop()
 				for {
 					select {
 					case <-ctx.Done():
 						return
+					case <-check.C:
+						st.pending.emitUnschedulable(ctx, DynamicClient, gvr, namespace, st.push)
+					case <-resync.C:
+						if st.needsResync() {
+							relist()
+						}
 					case event, ok := <-watcher.ResultChan():
 						if !ok {
 							return
 						}
+						if event.Type == watch.Error {
+							// Usually 410 Gone: the version is too old to resume from
+							relist()
+							return
+						}
 						if obj, ok := event.Object.(*unstructured.Unstructured); ok {
-							_ = sendObject(string(event.Type), obj)
+							resourceVersion = obj.GetResourceVersion()
+							_ = st.sendObject(string(event.Type), obj)
 						}
 					}
 				}
@@ -478,52 +576,32 @@ func watchAndSend(
 	dynClient dynamic.Interface,
 	gvr schema.GroupVersionResource,
 	ns string,
-	writeChan chan<- []byte,
+	sender *watchSender,
 ) {
-	// lastSnapshot holds last sent status signature per resource name
-	lastSnapshot := make(map[string]string)
-
-	sendObject := func(eventType string, obj *unstructured.Unstructured) error {
-		name := obj.GetName()
-
-		if eventType != "DELETED" {
-			snap := statusSnapshotString(obj)
-			if prev, ok := lastSnapshot[name]; ok {
-				if prev == snap {
-					return nil
-				}
-			}
-			lastSnapshot[name] = snap
-		} else {
-			delete(lastSnapshot, name)
-		}
-
-		data := buildDataMap(eventType, obj)
-		msg, err := json.Marshal(data)
+	st := newGVRStream(ctx, gvr, sender)
+	client := dynClient.Resource(gvr).Namespace(ns)
+
+	// Only platform-created objects are streamed; run the ownership label backfill for older ones
+	listOpts := metav1.ListOptions{LabelSelector: ManagedSelector}
+	// The watch resumes from the version of the last list or event so that nothing that changed
+	// in between is missed or replayed
+	resourceVersion := ""
+	relist := func() bool {
+		rv, err := st.sendSnapshot(client.List, listOpts)
 		if err != nil {
-			return err
-		}
-
-		select {
-		case writeChan <- msg:
-			return nil
-		case <-ctx.Done():
-			return ctx.Err()
+			if ctx.Err() == context.Canceled {
+				return false
+			}
+			k8sLog.Warn("failed to list watched resources", "namespace", ns, "resource", gvr.Resource, "group", gvr.Group, "error", err)
+			return true
 		}
+		resourceVersion = rv
+		return true
 	}
 
 	// Initial List
-	list, err := dynClient.Resource(gvr).Namespace(ns).List(ctx, metav1.ListOptions{})
-	if err == nil {
-		for _, item := range list.Items {
-			if err := sendObject("ADDED", &item); err != nil && ctx.Err() != context.Canceled {
-				fmt.Printf("Failed to send list item: %v\n", err)
-			}
-		}
-	} else if ctx.Err() == context.Canceled {
+	if !relist() {
 		return
-	} else {
-		fmt.Printf("List error for %s.%s: %v\n", gvr.Resource, gvr.Group, err)
 	}
 
 	// Watch Loop
@@ -534,7 +612,9 @@ func watchAndSend(
 		default:
 		}
 
-		watcher, err := dynClient.Resource(gvr).Namespace(ns).Watch(ctx, metav1.ListOptions{})
+		watchOpts := listOpts
+		watchOpts.ResourceVersion = resourceVersion
+		watcher, err := client.Watch(ctx, watchOpts)
 		if err != nil {
 			if ctx.Err() == context.Canceled {
 				return
@@ -545,22 +625,39 @@ func watchAndSend(
 
 		func() {
 			defer watcher.Stop()
+			check := time.NewTicker(pendingCheckInterval)
+			defer check.Stop()
+			resync := time.NewTicker(config.WatchStatsInterval)
+			defer resync.Stop()
 			for {
 				select {
 				case <-ctx.Done():
 					return
+				case <-check.C:
+					st.pending.emitUnschedulable(ctx, dynClient, gvr, ns, st.push)
+				case <-resync.C:
+					// Messages were dropped: the client state is stale, send the full list again
+					if st.needsResync() {
+						relist()
+					}
 				case event, ok := <-watcher.ResultChan():
 					if !ok {
 						return
 					}
+					if event.Type == watch.Error {
+						// Usually 410 Gone: the version is too old to resume from, list again
+						relist()
+						return
+					}
 
 					obj, ok := event.Object.(*unstructured.Unstructured)
 					if !ok {
 						continue
 					}
+					resourceVersion = obj.GetResourceVersion()
 
-					if err := sendObject(string(event.Type), obj); err != nil && ctx.Err() != context.Canceled {
-						fmt.Printf("Failed to send watch event: %v\n", err)
+					if err := st.sendObject(string(event.Type), obj); err != nil && !errors.Is(err, ErrWatchBufferFull) && ctx.Err() != context.Canceled {
+						k8sLog.Warn("failed to send watch event", "namespace", ns, "resource", gvr.Resource, "error", err)
 					}
 				}
 			}
@@ -568,333 +665,6 @@ func watchAndSend(
 	}
 }
 
-// buildDataMap extracts comprehensive details from K8s resources for the frontend
-func buildDataMap(eventType string, obj *unstructured.Unstructured) map[string]interface{} {
-	data := map[string]interface{}{
-		"type": eventType,
-		"kind": obj.GetKind(),
-		"name": obj.GetName(),
-		"ns":   obj.GetNamespace(),
-	}
-
-	metadata := map[string]interface{}{}
-	if ts, found, _ := unstructured.NestedString(obj.Object, "metadata", "creationTimestamp"); found {
-		metadata["creationTimestamp"] = ts
-	}
-	if labels, found, _ := unstructured.NestedStringMap(obj.Object, "metadata", "labels"); found {
-		metadata["labels"] = labels
-	}
-	if dts, found, _ := unstructured.NestedString(obj.Object, "metadata", "deletionTimestamp"); found {
-		metadata["deletionTimestamp"] = dts
-	}
-	data["metadata"] = metadata
-
-	for k, v := range extractStatusFields(obj) {
-		data[k] = v
-	}
-
-	// Provide helpful lifecycle hints for the frontend when events indicate creation/termination
-	// - DELETED: ensure deletionTimestamp exists and mark status as Terminating so UI can show it
-	// - ADDED for Pods: if phase is Pending or no status yet, mark as Creating
-	if eventType == "DELETED" {
-		if _, ok := metadata["deletionTimestamp"]; !ok {
-			metadata["deletionTimestamp"] = time.Now().Format(time.RFC3339)
-			data["metadata"] = metadata
-		}
-		// Only set status if not already set by extractStatusFields
-		if _, exists := data["status"]; !exists {
-			data["status"] = "Terminating"
-		}
-	}
-
-	if eventType == "ADDED" && obj.GetKind() == "Pod" {
-		// If status/phase is missing or Pending, surface a Creating hint
-		if s, ok := data["status"].(string); !ok || strings.EqualFold(s, "Pending") || s == "" {
-			data["status"] = "Creating"
-		}
-	}
-
-	if obj.GetKind() == "Pod" {
-		if containers, found, _ := unstructured.NestedSlice(obj.Object, "spec", "containers"); found {
-			var containerNames []string
-			var images []string
-			for _, c := range containers {
-				if m, ok := c.(map[string]interface{}); ok {
-					if name, ok := m["name"].(string); ok {
-						containerNames = append(containerNames, name)
-					}
-					if image, ok := m["image"].(string); ok {
-						images = append(images, image)
-					}
-				}
-			}
-			if len(containerNames) > 0 {
-				data["containers"] = containerNames
-			}
-			if len(images) > 0 {
-				data["images"] = images
-			}
-		}
-
-		if containerStatuses, found, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses"); found {
-			var totalRestarts int64 = 0
-			for _, cs := range containerStatuses {
-				if m, ok := cs.(map[string]interface{}); ok {
-					if rc, ok := m["restartCount"].(int64); ok {
-						totalRestarts += rc
-					}
-				}
-			}
-			data["restartCount"] = totalRestarts
-		}
-	}
-
-	// Attach recent events for Pods to help frontend show `kubectl describe` style information
-	if obj.GetKind() == "Pod" {
-		if evs := fetchPodEvents(obj.GetNamespace(), obj.GetName()); len(evs) > 0 {
-			data["events"] = evs
-		}
-	}
-
-	if isService(obj) {
-		if ips := extractServiceExternalIPs(obj); len(ips) > 0 {
-			data["externalIPs"] = ips
-		}
-		if ports := extractServiceNodePorts(obj); len(ports) > 0 {
-			data["nodePorts"] = ports
-		}
-		if ports := extractServicePorts(obj); len(ports) > 0 {
-			data["ports"] = ports
-		}
-	}
-
-	return data
-}
-
-func extractServicePorts(obj *unstructured.Unstructured) []string {
-	var servicePorts []string
-	ports, found, err := unstructured.NestedSlice(obj.Object, "spec", "ports")
-	if !found || err != nil {
-		return servicePorts
-	}
-
-	for _, port := range ports {
-		if m, ok := port.(map[string]interface{}); ok {
-			p, okPort := m["port"].(int64)
-			proto, okProto := m["protocol"].(string)
-
-			if okPort {
-				portStr := fmt.Sprintf("%d", p)
-				if okProto {
-					portStr = fmt.Sprintf("%d/%s", p, proto)
-				}
-				servicePorts = append(servicePorts, portStr)
-			}
-		}
-	}
-	return servicePorts
-}
-
-func isService(obj *unstructured.Unstructured) bool {
-	return obj.GetKind() == "Service"
-}
-
-func extractServiceExternalIPs(obj *unstructured.Unstructured) []string {
-	var externalIPs []string
-
-	specExternalIPs, found, err := unstructured.NestedSlice(obj.Object, "spec", "externalIPs")
-	if found && err == nil {
-		for _, ip := range specExternalIPs {
-			if s, ok := ip.(string); ok {
-				externalIPs = append(externalIPs, s)
-			}
-		}
-	}
-
-	ingressList, found, err := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
-	if found && err == nil {
-		for _, ingress := range ingressList {
-			if m, ok := ingress.(map[string]interface{}); ok {
-				if ip, ok := m["ip"].(string); ok {
-					externalIPs = append(externalIPs, ip)
-				}
-			}
-		}
-	}
-
-	return externalIPs
-}
-
-func extractServiceNodePorts(obj *unstructured.Unstructured) []int64 {
-	var nodePorts []int64
-
-	ports, found, err := unstructured.NestedSlice(obj.Object, "spec", "ports")
-	if !found || err != nil {
-		return nodePorts
-	}
-
-	for _, port := range ports {
-		if m, ok := port.(map[string]interface{}); ok {
-			if np, ok := m["nodePort"].(int64); ok {
-				nodePorts = append(nodePorts, np)
-			} else if npf, ok := m["nodePort"].(float64); ok {
-				nodePorts = append(nodePorts, int64(npf))
-			}
-		}
-	}
-
-	return nodePorts
-}
-
-func extractStatusFields(obj *unstructured.Unstructured) map[string]interface{} {
-	kind := obj.GetKind()
-	result := map[string]interface{}{}
-
-	switch kind {
-	case "Pod":
-		if phase, found, _ := unstructured.NestedString(obj.Object, "status", "phase"); found {
-			result["status"] = phase
-		}
-
-		// Detect CrashLoopBackOff by inspecting containerStatuses.state.waiting.reason
-		if containerStatuses, found, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses"); found {
-			var crashContainers []string
-			for _, cs := range containerStatuses {
-				m, ok := cs.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				// container name
-				name := ""
-				if n, ok := m["name"].(string); ok {
-					name = n
-				}
-
-				if state, ok := m["state"].(map[string]interface{}); ok {
-					if waiting, ok := state["waiting"].(map[string]interface{}); ok {
-						if reason, ok := waiting["reason"].(string); ok {
-							if strings.Contains(reason, "CrashLoopBackOff") {
-								crashContainers = append(crashContainers, name)
-								// prefer reporting CrashLoopBackOff as the pod status for UI clarity
-								result["status"] = "CrashLoopBackOff"
-								result["statusReason"] = reason
-								break
-							}
-						}
-						// also check message if reason not present
-						if msg, ok := waiting["message"].(string); ok && strings.Contains(msg, "CrashLoopBackOff") {
-							crashContainers = append(crashContainers, name)
-							result["status"] = "CrashLoopBackOff"
-							result["statusReason"] = msg
-							break
-						}
-					}
-				}
-			}
-
-			if len(crashContainers) > 0 {
-				result["crashLoopContainers"] = crashContainers
-			}
-		}
-	case "Service":
-		if clusterIP, found, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); found {
-			result["clusterIP"] = clusterIP
-		}
-		if externalIPs, found, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress"); found && len(externalIPs) > 0 {
-			if ingressMap, ok := externalIPs[0].(map[string]interface{}); ok {
-				if ip, ok := ingressMap["ip"].(string); ok {
-					result["externalIP"] = ip
-				}
-				if hostname, ok := ingressMap["hostname"].(string); ok {
-					result["externalHostname"] = hostname
-				}
-			}
-		}
-	case "Ingress":
-		if externalIPs, found, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress"); found && len(externalIPs) > 0 {
-			if ingressMap, ok := externalIPs[0].(map[string]interface{}); ok {
-				if ip, ok := ingressMap["ip"].(string); ok {
-					result["externalIP"] = ip
-				}
-				if hostname, ok := ingressMap["hostname"].(string); ok {
-					result["externalHostname"] = hostname
-				}
-			}
-		}
-	case "Deployment", "ReplicaSet":
-		if availableReplicas, found, _ := unstructured.NestedInt64(obj.Object, "status", "availableReplicas"); found {
-			result["availableReplicas"] = availableReplicas
-		}
-	case "Job":
-		if succeeded, found, _ := unstructured.NestedInt64(obj.Object, "status", "succeeded"); found {
-			result["succeeded"] = succeeded
-		}
-	}
-
-	return result
-}
-
-// statusSnapshotString produces a compact, stable string representing the
-// resource's status-related fields used for change detection. Keep this small
-// to avoid expensive allocations; it's used to deduplicate frequent identical
-// events (e.g. unrelated metadata updates).
-func statusSnapshotString(obj *unstructured.Unstructured) string {
-	m := map[string]interface{}{}
-
-	// include kind/name for clarity (not strictly necessary for map key)
-	m["kind"] = obj.GetKind()
-	m["name"] = obj.GetName()
-
-	// metadata.deletionTimestamp if present
-	if dts, found, _ := unstructured.NestedString(obj.Object, "metadata", "deletionTimestamp"); found {
-		m["deletionTimestamp"] = dts
-	}
-
-	// include extractStatusFields output (only status-related keys)
-	for k, v := range extractStatusFields(obj) {
-		m[k] = v
-	}
-
-	// For Pods, also include container restart counts and waiting reasons
-	if obj.GetKind() == "Pod" {
-		if containerStatuses, found, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses"); found {
-			var csSnap []map[string]interface{}
-			for _, cs := range containerStatuses {
-				if cm, ok := cs.(map[string]interface{}); ok {
-					entry := map[string]interface{}{}
-					if name, ok := cm["name"].(string); ok {
-						entry["name"] = name
-					}
-					if rc, ok := cm["restartCount"].(int64); ok {
-						entry["restartCount"] = rc
-					} else if rcf, ok := cm["restartCount"].(float64); ok {
-						entry["restartCount"] = int64(rcf)
-					}
-					if state, ok := cm["state"].(map[string]interface{}); ok {
-						if waiting, ok := state["waiting"].(map[string]interface{}); ok {
-							if reason, ok := waiting["reason"].(string); ok {
-								entry["waitingReason"] = reason
-							}
-							if msg, ok := waiting["message"].(string); ok {
-								entry["waitingMessage"] = msg
-							}
-						}
-					}
-					csSnap = append(csSnap, entry)
-				}
-			}
-			if len(csSnap) > 0 {
-				m["containerStatuses"] = csSnap
-			}
-		}
-	}
-
-	// Marshal into compact JSON string for easy equality checks
-	bs, _ := json.Marshal(m)
-	return string(bs)
-}
-
 // fetchPodEvents retrieves recent events related to a Pod (namespace/name).
 // Returns a compact serializable slice of event objects for frontend consumption.
 func fetchPodEvents(namespace, name string) []map[string]interface{} {
@@ -931,22 +701,6 @@ func fetchPodEvents(namespace, name string) []map[string]interface{} {
 	return evs
 }
 
-func GetFilteredNamespaces(filter string) ([]corev1.Namespace, error) {
-	namespaces, err := Clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list namespaces: %v", err)
-	}
-
-	var filteredNamespaces []corev1.Namespace
-	for _, ns := range namespaces.Items {
-		if strings.Contains(ns.Name, filter) {
-			filteredNamespaces = append(filteredNamespaces, ns)
-		}
-	}
-
-	return filteredNamespaces, nil
-}
-
 type JobSpec struct {
 	Name              string
 	Namespace         string
@@ -961,7 +715,20 @@ type JobSpec struct {
 	CPURequest        string
 	MemoryRequest     string
 	EnvVars           map[string]string
+	Env               []corev1.EnvVar // Appended after EnvVars; keys already in EnvVars are skipped
 	Annotations       map[string]string
+	// Labels are set on the Job and its pod template, typically Ownership.Labels()
+	Labels map[string]string
+	// Gang marks a job whose pods must all start together; see applyGangScheduling
+	Gang bool `json:",omitempty"`
+	// Artifacts adds an uploader container; the object keys use the job-id label
+	Artifacts *ArtifactUpload `json:",omitempty"`
+	// Scheduling holds the project's placement defaults; see ApplySchedulingPolicy
+	Scheduling *SchedulingPolicy `json:",omitempty"`
+	// ImagePullSecrets name secrets in Namespace used to pull Image
+	ImagePullSecrets []string `json:",omitempty"`
+	// ActiveDeadlineSeconds stops the job once it has run this long; nil lets it run until it ends
+	ActiveDeadlineSeconds *int64 `json:",omitempty"`
 }
 
 type VolumeSpec struct {
@@ -971,8 +738,9 @@ type VolumeSpec struct {
 	MountPath string
 }
 
-// CreateJob creates a Kubernetes Job with flexible configuration
-func CreateJob(ctx context.Context, spec JobSpec) error {
+// JobPodSpec returns the pod spec CreateJob gives the pods of spec, before gang scheduling and
+// the artifact uploader are added.
+func JobPodSpec(spec JobSpec) corev1.PodSpec {
 	var volumes []corev1.Volume
 	var volumeMounts []corev1.VolumeMount
 
@@ -1009,6 +777,12 @@ func CreateJob(ctx context.Context, spec JobSpec) error {
 			Value: v,
 		})
 	}
+	for _, e := range spec.Env {
+		if _, exists := spec.EnvVars[e.Name]; exists {
+			continue
+		}
+		env = append(env, e)
+	}
 
 	container := corev1.Container{
 		Name:         spec.Name,
@@ -1048,30 +822,58 @@ func CreateJob(ctx context.Context, spec JobSpec) error {
 
 	container.Resources = resources
 
+	podSpec := corev1.PodSpec{
+		RestartPolicy:     corev1.RestartPolicyOnFailure,
+		PriorityClassName: spec.PriorityClassName,
+		Volumes:           volumes,
+		Containers: []corev1.Container{
+			container,
+		},
+	}
+	for _, name := range spec.ImagePullSecrets {
+		podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+	ApplySchedulingPolicy(&podSpec, spec.Scheduling)
+	return podSpec
+}
+
+// CreateJob creates a Kubernetes Job with flexible configuration
+func CreateJob(ctx context.Context, spec JobSpec) error {
+	labels := MergeLabels(MergeLabels(nil, spec.Labels), Ownership{}.Labels())
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      spec.Name,
 			Namespace: spec.Namespace,
+			Labels:    labels,
 		},
 		Spec: batchv1.JobSpec{
-			Parallelism: &spec.Parallelism,
-			Completions: &spec.Completions,
+			Parallelism:           &spec.Parallelism,
+			Completions:           &spec.Completions,
+			ActiveDeadlineSeconds: spec.ActiveDeadlineSeconds,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
 					Annotations: spec.Annotations,
 				},
-				Spec: corev1.PodSpec{
-					RestartPolicy:     corev1.RestartPolicyOnFailure,
-					PriorityClassName: spec.PriorityClassName,
-					Volumes:           volumes,
-					Containers: []corev1.Container{
-						container,
-					},
-				},
+				Spec: JobPodSpec(spec),
 			},
 		},
 	}
 
+	if spec.Gang {
+		applyGangScheduling(&job.Spec.Template, spec.Name, spec.Parallelism)
+	}
+	if spec.Artifacts != nil {
+		jobID, err := strconv.ParseUint(labels[LabelJobID], 10, 64)
+		if err != nil {
+			return fmt.Errorf("artifact upload for job %s requires the %s label", spec.Name, LabelJobID)
+		}
+		if err := EnsureArtifactSecret(ctx, spec.Namespace); err != nil {
+			return err
+		}
+		applyArtifactUpload(&job.Spec.Template.Spec, spec.Artifacts, ArtifactObjectPrefix(uint(jobID), spec.Artifacts.Prefix))
+	}
+
 	_, err := Clientset.BatchV1().Jobs(spec.Namespace).Create(ctx, job, metav1.CreateOptions{})
 	return err
 }
@@ -1082,41 +884,17 @@ func DeleteJob(ctx context.Context, namespace, name string) error {
 	return Clientset.BatchV1().Jobs(namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
 }
 
-// CreateFileBrowserPod creates a pod running filebrowser with multiple PVC mounts
-func CreateFileBrowserPod(ctx context.Context, ns string, pvcNames []string, readOnly bool, baseURL string) (string, error) {
+// CreateFileBrowserPod creates a pod running filebrowser with each PVC mounted at /srv/{storageName}.
+// labels, typically Ownership.Labels(), are added next to the selector labels. A running pod is
+// reused when it mounts the same claims the same way and its spec did not drift; otherwise, or
+// with forceRecreate, it is deleted and recreated once gone.
+func CreateFileBrowserPod(ctx context.Context, ns string, pvcNames []string, readOnly bool, baseURL string, labels map[string]string, forceRecreate bool) (string, error) {
 	if len(pvcNames) == 0 {
 		return "", fmt.Errorf("no PVCs provided for filebrowser")
 	}
 
 	podName := "filebrowser-project"
 
-	existingPod, err := Clientset.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
-	if err == nil {
-		matches := true
-		if len(existingPod.Spec.Containers) > 0 {
-			for _, m := range existingPod.Spec.Containers[0].VolumeMounts {
-				if m.ReadOnly != readOnly {
-					matches = false
-					break
-				}
-			}
-		}
-
-		if matches {
-			return podName, nil
-		}
-
-		grace := int64(0)
-		_ = Clientset.CoreV1().Pods(ns).Delete(ctx, podName, metav1.DeleteOptions{GracePeriodSeconds: &grace})
-		_ = wait.PollUntilContextTimeout(ctx, 200*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
-			_, err := Clientset.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
-			if apierrors.IsNotFound(err) {
-				return true, nil
-			}
-			return false, nil
-		})
-	}
-
 	var volumes []corev1.Volume
 	var mounts []corev1.VolumeMount
 	for idx, pvc := range pvcNames {
@@ -1127,42 +905,60 @@ func CreateFileBrowserPod(ctx context.Context, ns string, pvcNames []string, rea
 				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc},
 			},
 		})
+		// Each storage gets its own folder so multiple PVCs do not shadow each other
 		mounts = append(mounts, corev1.VolumeMount{
 			Name:      volName,
-			MountPath: "/srv",
+			MountPath: "/srv/" + StorageNameFromPVCName(pvc),
 			ReadOnly:  readOnly,
 		})
 	}
 
+	container := corev1.Container{
+		Name:  "filebrowser",
+		Image: "filebrowser/filebrowser:latest",
+		Args: []string{
+			"--noauth",
+			"--database", "/tmp/filebrowser.db",
+			"--root", "/srv",
+			"--port", "80",
+			"--address", "0.0.0.0",
+			"--baseURL", baseURL,
+		},
+		Ports:        []corev1.ContainerPort{{ContainerPort: 80}},
+		VolumeMounts: mounts,
+	}
+	ApplyFileBrowserDefaults(&container, baseURL)
+
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      podName,
 			Namespace: ns,
-			Labels: map[string]string{
+			Labels: MergeLabels(MergeLabels(map[string]string{
 				"app":  "filebrowser",
 				"role": "project-storage",
-			},
+			}, labels), Ownership{}.Labels()),
 		},
 		Spec: corev1.PodSpec{
-			Containers: []corev1.Container{
-				{
-					Name:  "filebrowser",
-					Image: "filebrowser/filebrowser:latest",
-					Args: []string{
-						"--noauth",
-						"--database", "/tmp/filebrowser.db",
-						"--root", "/srv",
-						"--port", "80",
-						"--address", "0.0.0.0",
-						"--baseURL", baseURL,
-					},
-					Ports:        []corev1.ContainerPort{{ContainerPort: 80}},
-					VolumeMounts: mounts,
-				},
-			},
-			Volumes: volumes,
+			Containers: []corev1.Container{container},
+			Volumes:    volumes,
 		},
 	}
+	specHash := PodSpecHash(pod.Spec)
+	pod.Annotations = map[string]string{SpecHashAnnotation: specHash}
+
+	existingPod, err := Clientset.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
+	if err == nil {
+		// Recreate when the claims, probes or resources differ from the desired spec
+		if !forceRecreate && existingPod.DeletionTimestamp == nil &&
+			existingPod.Annotations[SpecHashAnnotation] == specHash && sameClaimMounts(podClaimMounts(existingPod), podClaimMounts(pod)) {
+			return podName, nil
+		}
+		if err := deletePodAndWait(ctx, ns, podName, config.FileBrowserDeleteTimeout); err != nil {
+			return "", err
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get filebrowser pod: %w", err)
+	}
 
 	_, err = Clientset.CoreV1().Pods(ns).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
@@ -1171,7 +967,7 @@ func CreateFileBrowserPod(ctx context.Context, ns string, pvcNames []string, rea
 	return podName, nil
 }
 
-func CreateFileBrowserService(ctx context.Context, ns string) (string, error) {
+func CreateFileBrowserService(ctx context.Context, ns string, labels map[string]string) (string, error) {
 	svcName := config.ProjectStorageBrowserSVCName
 
 	svc, err := Clientset.CoreV1().Services(ns).Get(ctx, svcName, metav1.GetOptions{})
@@ -1186,6 +982,7 @@ func CreateFileBrowserService(ctx context.Context, ns string) (string, error) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      svcName,
 			Namespace: ns,
+			Labels:    MergeLabels(MergeLabels(nil, labels), Ownership{}.Labels()),
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{
@@ -0,0 +1,171 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// FieldManager is the field manager of every write the platform makes; Init sets it as the
+// user agent, from which the API server derives the manager recorded in managedFields.
+const FieldManager = "platform-go"
+
+// clusterManagers write to platform objects as part of running them, for example the revision
+// annotation of a Deployment, and never count as outside modifications.
+var clusterManagers = map[string]bool{
+	"kube-controller-manager": true,
+	"kube-scheduler":          true,
+	"kubelet":                 true,
+}
+
+// ExternalChange is the set of fields of an object one manager other than the platform owns.
+type ExternalChange struct {
+	Manager   string       `json:"manager"`
+	Operation string       `json:"operation"`
+	Time      *metav1.Time `json:"time,omitempty"`
+	// Fields are paths such as "spec.template.spec.containers[name=app].image"
+	Fields []string `json:"fields"`
+}
+
+// ObjectDrift lists the outside modifications of one live object.
+type ObjectDrift struct {
+	Kind    string           `json:"kind"`
+	Name    string           `json:"name"`
+	Changes []ExternalChange `json:"changes"`
+}
+
+// ExternalChanges returns the fields of obj owned by managers other than the platform, for
+// example after a kubectl edit, scale or apply. Status writes and the control plane are
+// ignored, as are objects the platform did not create.
+func ExternalChanges(obj metav1.Object) []ExternalChange {
+	entries := obj.GetManagedFields()
+	created := false
+	for _, e := range entries {
+		if e.Manager == FieldManager {
+			created = true
+			break
+		}
+	}
+	if !created {
+		return nil
+	}
+
+	var changes []ExternalChange
+	for _, e := range entries {
+		if e.Manager == FieldManager || e.Subresource == "status" || clusterManagers[e.Manager] {
+			continue
+		}
+		fields := FieldPaths(e.FieldsV1)
+		if len(fields) == 0 {
+			continue
+		}
+		changes = append(changes, ExternalChange{Manager: e.Manager, Operation: string(e.Operation), Time: e.Time, Fields: fields})
+	}
+	return changes
+}
+
+// IsExternallyModified reports whether obj has fields owned outside the platform.
+func IsExternallyModified(obj metav1.Object) bool {
+	return len(ExternalChanges(obj)) > 0
+}
+
+// FieldPaths flattens a managedFields fieldsV1 set into sorted leaf paths. List items keyed by
+// name read "containers[name=app]", set values "finalizers[=value]" and positions "args[0]".
+func FieldPaths(f *metav1.FieldsV1) []string {
+	if f == nil || len(f.Raw) == 0 {
+		return nil
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(f.Raw, &root); err != nil {
+		return nil
+	}
+	var paths []string
+	collectFieldPaths("", root, &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+func collectFieldPaths(prefix string, node map[string]interface{}, paths *[]string) {
+	leaf := true
+	for key, child := range node {
+		if key == "." {
+			continue
+		}
+		leaf = false
+		sub, _ := child.(map[string]interface{})
+		collectFieldPaths(prefix+fieldPathElement(prefix, key), sub, paths)
+	}
+	if leaf && prefix != "" {
+		*paths = append(*paths, prefix)
+	}
+}
+
+func fieldPathElement(prefix, key string) string {
+	kind, value, _ := strings.Cut(key, ":")
+	switch kind {
+	case "f":
+		if prefix == "" {
+			return value
+		}
+		return "." + value
+	case "k":
+		var keys map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &keys); err != nil {
+			return "[" + value + "]"
+		}
+		parts := make([]string, 0, len(keys))
+		for k, v := range keys {
+			parts = append(parts, fmt.Sprintf("%s=%v", k, v))
+		}
+		sort.Strings(parts)
+		return "[" + strings.Join(parts, ",") + "]"
+	case "v":
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err == nil {
+			return fmt.Sprintf("[=%v]", v)
+		}
+		return "[=" + value + "]"
+	case "i":
+		return "[" + value + "]"
+	}
+	return "." + key
+}
+
+// LiveObjectDrift looks up the live object of the manifest objJSON in ns and returns its
+// outside modifications, or nil when it is missing, unmodified or has a generated name.
+func LiveObjectDrift(ctx context.Context, objJSON []byte, ns string) (*ObjectDrift, error) {
+	if Mapper == nil || DynamicClient == nil {
+		return nil, nil
+	}
+	var obj unstructured.Unstructured
+	if err := json.Unmarshal(objJSON, &obj.Object); err != nil {
+		return nil, err
+	}
+	// A manifest using generateName gets a new object each time, so it has no live counterpart
+	if obj.GetName() == "" {
+		return nil, nil
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	live, err := DynamicClient.Resource(mapping.Resource).Namespace(ns).Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	changes := ExternalChanges(live)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return &ObjectDrift{Kind: live.GetKind(), Name: live.GetName(), Changes: changes}, nil
+}
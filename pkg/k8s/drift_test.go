@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// loadManagedFieldsFixture reads an object as returned by the API server, managedFields
+// included.
+func loadManagedFieldsFixture(t *testing.T, name string) *unstructured.Unstructured {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "managedfields", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(raw); err != nil {
+		t.Fatalf("failed to decode fixture %s: %v", name, err)
+	}
+	return obj
+}
+
+func TestExternalChangesKubectlEdit(t *testing.T) {
+	changes := ExternalChanges(loadManagedFieldsFixture(t, "deployment-edited.json"))
+	if len(changes) != 1 {
+		t.Fatalf("expected only the kubectl edit, got %+v", changes)
+	}
+	got := changes[0]
+	want := []string{"spec.replicas", "spec.template.spec.containers[name=jupyter].image"}
+	if got.Manager != "kubectl-edit" || got.Operation != "Update" || got.Time == nil || !reflect.DeepEqual(got.Fields, want) {
+		t.Fatalf("expected kubectl-edit owning %v, got %+v", want, got)
+	}
+}
+
+func TestExternalChangesServerSideApply(t *testing.T) {
+	changes := ExternalChanges(loadManagedFieldsFixture(t, "service-applied.json"))
+	want := []string{"metadata.finalizers[=example.com/protect]", "spec.externalTrafficPolicy", "spec.type"}
+	if len(changes) != 1 || changes[0].Operation != "Apply" || !reflect.DeepEqual(changes[0].Fields, want) {
+		t.Fatalf("expected the applied fields %v, got %+v", want, changes)
+	}
+}
+
+func TestExternalChangesIgnoresClusterWrites(t *testing.T) {
+	// The revision annotation and status of the controller are not outside modifications
+	if obj := loadManagedFieldsFixture(t, "deployment-unmodified.json"); IsExternallyModified(obj) {
+		t.Fatalf("expected no outside modification, got %+v", ExternalChanges(obj))
+	}
+	// Pods of a ReplicaSet were not created by the platform, so their labels are not judged
+	if obj := loadManagedFieldsFixture(t, "pod-from-replicaset.json"); IsExternallyModified(obj) {
+		t.Fatalf("expected objects the platform did not create to be skipped, got %+v", ExternalChanges(obj))
+	}
+}
+
+func TestObjectDataFlagsExternallyModified(t *testing.T) {
+	if data := objectData("ADDED", loadManagedFieldsFixture(t, "deployment-edited.json"), ""); data["externallyModified"] != true {
+		t.Fatalf("expected the edited deployment to be flagged, got %v", data["externallyModified"])
+	}
+	if data := objectData("ADDED", loadManagedFieldsFixture(t, "deployment-unmodified.json"), ""); data["externallyModified"] != nil {
+		t.Fatalf("expected no hint on an unmodified object, got %v", data["externallyModified"])
+	}
+}
@@ -0,0 +1,33 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// IsGone reports whether err means the target of a request no longer exists: the object is not
+// found, or its namespace is missing or being terminated.
+func IsGone(err error) bool {
+	return apierrors.IsNotFound(err) || apierrors.HasStatusCause(err, corev1.NamespaceTerminatingCause)
+}
+
+// IsUnreachable reports whether err means the API server could not be reached or could not
+// answer, as opposed to rejecting the request. Retrying other objects is pointless then.
+func IsUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if apierrors.IsServiceUnavailable(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) || utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,170 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ErrPodDeleteTimeout is returned when a pod being replaced is still present after the timeout.
+var ErrPodDeleteTimeout = errors.New("previous pod is still terminating")
+
+// SpecHashAnnotation records the hash of the desired pod spec so drift can be detected
+// without being confused by fields the API server defaults.
+const SpecHashAnnotation = "platform.nthu-cscc/spec-hash"
+
+const fileBrowserPort = 80
+
+// BuildResourceRequirements parses request/limit quantities; empty values are left unset.
+func BuildResourceRequirements(cpuRequest, cpuLimit, memRequest, memLimit string) (corev1.ResourceRequirements, error) {
+	res := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+	for _, q := range []struct {
+		list  corev1.ResourceList
+		name  corev1.ResourceName
+		value string
+	}{
+		{res.Requests, corev1.ResourceCPU, cpuRequest},
+		{res.Requests, corev1.ResourceMemory, memRequest},
+		{res.Limits, corev1.ResourceCPU, cpuLimit},
+		{res.Limits, corev1.ResourceMemory, memLimit},
+	} {
+		if q.value == "" {
+			continue
+		}
+		qty, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			return res, fmt.Errorf("invalid %s quantity %q: %w", q.name, q.value, err)
+		}
+		q.list[q.name] = qty
+	}
+	return res, nil
+}
+
+// ApplyFileBrowserDefaults sets the platform probes, resources and security context on a FileBrowser container.
+// The health endpoint is served under baseURL.
+func ApplyFileBrowserDefaults(c *corev1.Container, baseURL string) {
+	res, err := BuildResourceRequirements(config.FileBrowserCPURequest, config.FileBrowserCPULimit,
+		config.FileBrowserMemoryRequest, config.FileBrowserMemoryLimit)
+	if err != nil {
+		log.Printf("[Warning] FileBrowser resources ignored: %v", err)
+	} else {
+		c.Resources = res
+	}
+
+	healthPath := strings.TrimRight(baseURL, "/") + "/health"
+	probe := func(initialDelay, period int32) *corev1.Probe {
+		// Every field the API server would default is set explicitly so the spec hash stays stable
+		return &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path:   healthPath,
+					Port:   intstr.FromInt(fileBrowserPort),
+					Scheme: corev1.URISchemeHTTP,
+				},
+			},
+			InitialDelaySeconds: initialDelay,
+			PeriodSeconds:       period,
+			TimeoutSeconds:      2,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+		}
+	}
+	c.ReadinessProbe = probe(2, 10)
+	c.LivenessProbe = probe(15, 20)
+
+	// The image binds :80 and must write PVC files owned by root, so it stays root with a minimal capability set
+	allowEscalation := false
+	c.SecurityContext = &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+			Add:  []corev1.Capability{"NET_BIND_SERVICE", "CHOWN", "DAC_OVERRIDE", "FOWNER"},
+		},
+	}
+}
+
+// PodSpecHash returns a stable hash of the pod spec as rendered by the platform.
+func PodSpecHash(spec corev1.PodSpec) string {
+	data, _ := json.Marshal(spec)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// podClaimMounts maps each PVC mounted by the containers of pod to whether every mount of it is
+// read-only.
+func podClaimMounts(pod *corev1.Pod) map[string]bool {
+	claims := map[string]string{}
+	for _, v := range pod.Spec.Volumes {
+		if v.PersistentVolumeClaim != nil {
+			claims[v.Name] = v.PersistentVolumeClaim.ClaimName
+		}
+	}
+	mounts := map[string]bool{}
+	for _, c := range pod.Spec.Containers {
+		for _, m := range c.VolumeMounts {
+			claim, ok := claims[m.Name]
+			if !ok {
+				continue
+			}
+			if ro, seen := mounts[claim]; seen {
+				mounts[claim] = ro && m.ReadOnly
+			} else {
+				mounts[claim] = m.ReadOnly
+			}
+		}
+	}
+	return mounts
+}
+
+func sameClaimMounts(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for claim, ro := range a {
+		if other, ok := b[claim]; !ok || other != ro {
+			return false
+		}
+	}
+	return true
+}
+
+// deletePodAndWait deletes a pod and waits until the API server no longer has it, so that a pod
+// of the same name can be created. It wraps ErrPodDeleteTimeout when the pod outlives timeout.
+func deletePodAndWait(ctx context.Context, ns, name string, timeout time.Duration) error {
+	pods := Clientset.CoreV1().Pods(ns)
+	grace := int64(0)
+	if err := pods.Delete(ctx, name, metav1.DeleteOptions{GracePeriodSeconds: &grace}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete pod %s/%s: %w", ns, name, err)
+	}
+	err := wait.PollUntilContextTimeout(ctx, 200*time.Millisecond, timeout, true, func(ctx context.Context) (bool, error) {
+		_, err := pods.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %s/%s still exists after %s, try again shortly", ErrPodDeleteTimeout, ns, name, timeout)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCreateFileBrowserPodRendersProbesAndLimits(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+
+	if _, err := CreateFileBrowserPod(ctx, "proj-1", []string{"project-1-disk"}, false, "/k8s/storage/projects/1/proxy/", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod, err := Clientset.CoreV1().Pods("proj-1").Get(ctx, "filebrowser-project", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("pod not created: %v", err)
+	}
+	c := pod.Spec.Containers[0]
+
+	for name, probe := range map[string]*corev1.Probe{"readiness": c.ReadinessProbe, "liveness": c.LivenessProbe} {
+		if probe == nil || probe.HTTPGet == nil {
+			t.Fatalf("expected %s HTTP probe", name)
+		}
+		if probe.HTTPGet.Path != "/k8s/storage/projects/1/proxy/health" || probe.HTTPGet.Port.IntValue() != 80 {
+			t.Fatalf("%s probe should target the baseURL health endpoint, got %s:%d", name, probe.HTTPGet.Path, probe.HTTPGet.Port.IntValue())
+		}
+	}
+
+	if got := c.Resources.Limits[corev1.ResourceMemory]; got.String() != config.FileBrowserMemoryLimit {
+		t.Fatalf("expected memory limit %s, got %s", config.FileBrowserMemoryLimit, got.String())
+	}
+	if got := c.Resources.Requests[corev1.ResourceCPU]; got.String() != config.FileBrowserCPURequest {
+		t.Fatalf("expected cpu request %s, got %s", config.FileBrowserCPURequest, got.String())
+	}
+
+	sc := c.SecurityContext
+	if sc == nil || sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation || sc.Capabilities == nil || len(sc.Capabilities.Drop) != 1 || sc.Capabilities.Drop[0] != "ALL" {
+		t.Fatalf("expected capabilities to be dropped, got %+v", sc)
+	}
+	if pod.Annotations[SpecHashAnnotation] == "" {
+		t.Fatalf("expected spec hash annotation")
+	}
+}
+
+func TestCreateFileBrowserPodRecreatesOnSpecDrift(t *testing.T) {
+	orig, origLimit := Clientset, config.FileBrowserMemoryLimit
+	defer func() { Clientset, config.FileBrowserMemoryLimit = orig, origLimit }()
+	Clientset = k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+
+	get := func() *corev1.Pod {
+		pod, err := Clientset.CoreV1().Pods("proj-2").Get(ctx, "filebrowser-project", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("pod missing: %v", err)
+		}
+		return pod
+	}
+
+	if _, err := CreateFileBrowserPod(ctx, "proj-2", []string{"pvc-a"}, false, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := get().Annotations[SpecHashAnnotation]
+
+	if _, err := CreateFileBrowserPod(ctx, "proj-2", []string{"pvc-a"}, false, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if get().Annotations[SpecHashAnnotation] != first {
+		t.Fatalf("identical spec should reuse the pod")
+	}
+
+	if _, err := CreateFileBrowserPod(ctx, "proj-2", []string{"pvc-a"}, true, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod := get()
+	if !pod.Spec.Containers[0].VolumeMounts[0].ReadOnly || pod.Annotations[SpecHashAnnotation] == first {
+		t.Fatalf("read-only change should recreate the pod")
+	}
+
+	config.FileBrowserMemoryLimit = "512Mi"
+	if _, err := CreateFileBrowserPod(ctx, "proj-2", []string{"pvc-a"}, true, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := get().Spec.Containers[0].Resources.Limits[corev1.ResourceMemory]; got.String() != "512Mi" {
+		t.Fatalf("resource change should recreate the pod, got limit %s", got.String())
+	}
+}
+
+func TestCreateFileBrowserPodFollowsPVCSet(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+
+	claims := func() map[string]bool {
+		pod, err := Clientset.CoreV1().Pods("proj-3").Get(ctx, "filebrowser-project", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("pod missing: %v", err)
+		}
+		return podClaimMounts(pod)
+	}
+
+	if _, err := CreateFileBrowserPod(ctx, "proj-3", []string{"pvc-a"}, false, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Added storage
+	if _, err := CreateFileBrowserPod(ctx, "proj-3", []string{"pvc-a", "pvc-b"}, false, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := claims(); !sameClaimMounts(got, map[string]bool{"pvc-a": false, "pvc-b": false}) {
+		t.Fatalf("added PVC should recreate the pod, mounts %v", got)
+	}
+	// Removed storage
+	if _, err := CreateFileBrowserPod(ctx, "proj-3", []string{"pvc-b"}, false, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := claims(); !sameClaimMounts(got, map[string]bool{"pvc-b": false}) {
+		t.Fatalf("removed PVC should recreate the pod, mounts %v", got)
+	}
+
+	// A stale pod whose hash annotation matches but whose volumes do not is not reused
+	pod, _ := Clientset.CoreV1().Pods("proj-3").Get(ctx, "filebrowser-project", metav1.GetOptions{})
+	pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName = "pvc-old"
+	if _, err := Clientset.CoreV1().Pods("proj-3").Update(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update pod: %v", err)
+	}
+	if _, err := CreateFileBrowserPod(ctx, "proj-3", []string{"pvc-b"}, false, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := claims(); !sameClaimMounts(got, map[string]bool{"pvc-b": false}) {
+		t.Fatalf("mismatched volumes should recreate the pod, mounts %v", got)
+	}
+}
+
+func TestCreateFileBrowserPodForceRecreate(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	client := k8sfake.NewSimpleClientset()
+	Clientset = client
+	ctx := context.Background()
+
+	if _, err := CreateFileBrowserPod(ctx, "proj-4", []string{"pvc-a"}, false, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deletes := 0
+	client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deletes++
+		return false, nil, nil
+	})
+	if _, err := CreateFileBrowserPod(ctx, "proj-4", []string{"pvc-a"}, false, "/fb", nil, false); err != nil || deletes != 0 {
+		t.Fatalf("an up-to-date pod should be reused: err %v, %d deletes", err, deletes)
+	}
+	if _, err := CreateFileBrowserPod(ctx, "proj-4", []string{"pvc-a"}, false, "/fb", nil, true); err != nil || deletes != 1 {
+		t.Fatalf("forceRecreate should replace the pod: err %v, %d deletes", err, deletes)
+	}
+}
+
+func TestCreateFileBrowserPodSlowDeletion(t *testing.T) {
+	orig, origTimeout := Clientset, config.FileBrowserDeleteTimeout
+	defer func() { Clientset, config.FileBrowserDeleteTimeout = orig, origTimeout }()
+	client := k8sfake.NewSimpleClientset()
+	Clientset = client
+	config.FileBrowserDeleteTimeout = 500 * time.Millisecond
+	ctx := context.Background()
+
+	if _, err := CreateFileBrowserPod(ctx, "proj-5", []string{"pvc-a"}, false, "/fb", nil, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The pod lingers in Terminating: the delete is accepted but the object stays
+	client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	_, err := CreateFileBrowserPod(ctx, "proj-5", []string{"pvc-a", "pvc-b"}, false, "/fb", nil, false)
+	if !errors.Is(err, ErrPodDeleteTimeout) {
+		t.Fatalf("expected ErrPodDeleteTimeout instead of an AlreadyExists create, got %v", err)
+	}
+}
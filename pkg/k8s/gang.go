@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"strconv"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Gang scheduler integrations selectable with config.GangScheduler.
+const (
+	GangSchedulerVolcano      = "volcano"
+	GangSchedulerCoscheduling = "coscheduling"
+)
+
+// Pod group metadata understood by Volcano and by the scheduler-plugins coscheduling plugin.
+const (
+	volcanoGroupNameAnnotation    = "scheduling.k8s.io/group-name"
+	volcanoMinMemberAnnotation    = "scheduling.volcano.sh/group-min-member"
+	coschedulingGroupLabel        = "pod-group.scheduling.sigs.k8s.io/name"
+	coschedulingMinAvailableLabel = "pod-group.scheduling.sigs.k8s.io/min-available"
+)
+
+var defaultGangSchedulerNames = map[string]string{
+	GangSchedulerVolcano:      "volcano",
+	GangSchedulerCoscheduling: "scheduler-plugins-scheduler",
+}
+
+// applyGangScheduling hands the pods of a gang job to the configured gang scheduler, grouped
+// under the job name with all members required. Without a gang scheduler it does nothing and
+// the platform's capacity gate is the only protection.
+func applyGangScheduling(tmpl *corev1.PodTemplateSpec, group string, members int32) {
+	schedulerName := config.GangSchedulerName
+	if schedulerName == "" {
+		schedulerName = defaultGangSchedulerNames[config.GangScheduler]
+	}
+	min := strconv.Itoa(int(members))
+
+	switch config.GangScheduler {
+	case GangSchedulerVolcano:
+		// Copy first: the map may be shared with the caller's spec
+		tmpl.Annotations = MergeLabels(MergeLabels(nil, tmpl.Annotations), map[string]string{
+			volcanoGroupNameAnnotation: group,
+			volcanoMinMemberAnnotation: min,
+		})
+	case GangSchedulerCoscheduling:
+		// Copy first: the template shares its label map with the Job metadata
+		tmpl.Labels = MergeLabels(MergeLabels(nil, tmpl.Labels), map[string]string{
+			coschedulingGroupLabel:        group,
+			coschedulingMinAvailableLabel: min,
+		})
+	default:
+		return
+	}
+	tmpl.Spec.SchedulerName = schedulerName
+}
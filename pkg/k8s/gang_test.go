@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func gpuNode(name string, gpus int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			GPUResource: *resource.NewQuantity(gpus, resource.DecimalSI),
+		}},
+	}
+}
+
+func gpuPod(name, node string, gpus int64, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "proj-1-bob"},
+		Spec: corev1.PodSpec{NodeName: node, Containers: []corev1.Container{{
+			Name:      "main",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{GPUResource: *resource.NewQuantity(gpus, resource.DecimalSI)}},
+		}}},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestGetGPUCapacityCountsFreeGPUsPerNode(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	cordoned := gpuNode("gpu-c", 8)
+	cordoned.Spec.Unschedulable = true
+	Clientset = k8sfake.NewSimpleClientset(
+		gpuNode("gpu-a", 4),
+		gpuNode("gpu-b", 4),
+		cordoned,
+		gpuPod("train-0", "gpu-a", 3, corev1.PodRunning),
+		gpuPod("done", "gpu-b", 4, corev1.PodSucceeded),
+		gpuPod("unbound", "", 4, corev1.PodPending),
+	)
+
+	capacity, err := GetGPUCapacity(context.Background(), GPUResource)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if capacity.Allocatable != 8 || capacity.Requested != 3 || capacity.Free != 5 || len(capacity.Nodes) != 2 {
+		t.Fatalf("unexpected capacity %+v", capacity)
+	}
+	// 5 GPUs are free in total, but gpu-a has only one left: two 2-GPU pods fit, three do not
+	if !capacity.FitsGang(2, 2) {
+		t.Fatalf("expected two 2-GPU pods to fit on gpu-b")
+	}
+	if capacity.FitsGang(2, 3) {
+		t.Fatalf("three 2-GPU pods cannot be placed at the same time")
+	}
+	if !capacity.FitsGang(1, 5) {
+		t.Fatalf("expected five 1-GPU pods to fit")
+	}
+}
+
+func TestCreateJobInjectsGangScheduling(t *testing.T) {
+	orig, origScheduler, origName := Clientset, config.GangScheduler, config.GangSchedulerName
+	defer func() { Clientset, config.GangScheduler, config.GangSchedulerName = orig, origScheduler, origName }()
+	Clientset = k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+	config.GangSchedulerName = ""
+
+	config.GangScheduler = GangSchedulerVolcano
+	spec := JobSpec{Name: "ddp", Namespace: "proj-1-bob", Image: "pytorch", Parallelism: 4, Completions: 4, Gang: true, Annotations: map[string]string{"keep": "me"}}
+	if err := CreateJob(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	j, _ := Clientset.BatchV1().Jobs("proj-1-bob").Get(ctx, "ddp", metav1.GetOptions{})
+	tmpl := j.Spec.Template
+	if tmpl.Spec.SchedulerName != "volcano" || tmpl.Annotations[volcanoGroupNameAnnotation] != "ddp" || tmpl.Annotations[volcanoMinMemberAnnotation] != "4" || tmpl.Annotations["keep"] != "me" {
+		t.Fatalf("expected volcano gang metadata, got scheduler %q annotations %v", tmpl.Spec.SchedulerName, tmpl.Annotations)
+	}
+	if len(spec.Annotations) != 1 {
+		t.Fatalf("the caller's annotations must not be modified, got %v", spec.Annotations)
+	}
+
+	config.GangScheduler = GangSchedulerCoscheduling
+	config.GangSchedulerName = "my-scheduler"
+	spec = JobSpec{Name: "ddp2", Namespace: "proj-1-bob", Image: "pytorch", Parallelism: 2, Completions: 2, Gang: true}
+	if err := CreateJob(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	j, _ = Clientset.BatchV1().Jobs("proj-1-bob").Get(ctx, "ddp2", metav1.GetOptions{})
+	tmpl = j.Spec.Template
+	if tmpl.Spec.SchedulerName != "my-scheduler" || tmpl.Labels[coschedulingGroupLabel] != "ddp2" || tmpl.Labels[coschedulingMinAvailableLabel] != "2" {
+		t.Fatalf("expected coscheduling gang metadata, got scheduler %q labels %v", tmpl.Spec.SchedulerName, tmpl.Labels)
+	}
+	if _, ok := j.Labels[coschedulingGroupLabel]; ok {
+		t.Fatalf("pod group labels belong on the pod template only, got %v", j.Labels)
+	}
+
+	config.GangScheduler = ""
+	spec = JobSpec{Name: "plain", Namespace: "proj-1-bob", Image: "pytorch", Parallelism: 2, Completions: 2, Gang: true}
+	if err := CreateJob(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	j, _ = Clientset.BatchV1().Jobs("proj-1-bob").Get(ctx, "plain", metav1.GetOptions{})
+	if j.Spec.Template.Spec.SchedulerName != "" {
+		t.Fatalf("without a gang scheduler the default scheduler is kept, got %q", j.Spec.Template.Spec.SchedulerName)
+	}
+}
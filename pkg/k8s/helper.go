@@ -5,40 +5,91 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
 )
 
-func ToSafeK8sName(rawName string) string {
-	safeName := strings.ToLower(rawName)
+var (
+	invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+	multiHyphen      = regexp.MustCompile(`-+`)
+)
 
-	reg := regexp.MustCompile(`[^a-z0-9]+`)
-	safeName = reg.ReplaceAllString(safeName, "-")
+// minSafeNameLength is the shortest sanitized name kept when sanitizing dropped letters or
+// digits; shorter ones collide too easily, e.g. "李a" and "王a" both sanitize to "a".
+const minSafeNameLength = 3
 
+// sanitizeName lowercases the NFC form of rawName and replaces every run of characters outside
+// [a-z0-9] with a hyphen. lossy reports whether letters or digits were dropped, as happens to
+// non-ASCII names.
+func sanitizeName(rawName string) (safeName string, lossy bool) {
+	rawName = norm.NFC.String(rawName)
+	safeName = invalidNameChars.ReplaceAllString(strings.ToLower(rawName), "-")
 	safeName = strings.Trim(safeName, "-")
+	lossy = strings.ContainsFunc(rawName, func(r rune) bool {
+		return r > unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsNumber(r))
+	})
+	return safeName, lossy
+}
+
+// needsFallbackName reports whether a sanitized name says too little about the original to be
+// used, in which case a deterministic slug replaces it.
+func needsFallbackName(safeName string, lossy bool) bool {
+	return safeName == "" || (lossy && len(safeName) < minSafeNameLength)
+}
+
+// UsesFallbackName reports whether ToSafeK8sName replaces rawName with a slug derived from its
+// hash instead of sanitizing it.
+func UsesFallbackName(rawName string) bool {
+	return needsFallbackName(sanitizeName(rawName))
+}
+
+// ToSafeK8sName turns rawName into a DNS label. Names that sanitize to nothing or, once their
+// non-ASCII letters are dropped, to fewer than minSafeNameLength characters become "n" followed
+// by a hash of the NFC-normalized name, so different names keep different labels.
+func ToSafeK8sName(rawName string) string {
+	safeName, lossy := sanitizeName(rawName)
+	if needsFallbackName(safeName, lossy) {
+		hash := sha256.Sum256([]byte(norm.NFC.String(rawName)))
+		return fmt.Sprintf("n%x", hash)[:11]
+	}
 
-	multiHyphenReg := regexp.MustCompile(`-+`)
-	safeName = multiHyphenReg.ReplaceAllString(safeName, "-")
+	safeName = multiHyphen.ReplaceAllString(safeName, "-")
 
 	if len(safeName) > 63 {
 		safeName = safeName[:63]
 		safeName = strings.TrimRight(safeName, "-")
 	}
 
+	return safeName
+}
+
+// LegacySafeK8sName is what ToSafeK8sName returned before names that sanitize to too little got
+// a slug: the name without NFC normalization, sanitized and cut the same way, with "unnamed" for
+// an empty result. Namespaces created for such names back then are still named with it.
+func LegacySafeK8sName(rawName string) string {
+	safeName := invalidNameChars.ReplaceAllString(strings.ToLower(rawName), "-")
+	safeName = multiHyphen.ReplaceAllString(strings.Trim(safeName, "-"), "-")
+	if len(safeName) > 63 {
+		safeName = strings.TrimRight(safeName[:63], "-")
+	}
 	if safeName == "" {
 		safeName = "unnamed"
 	}
-
 	return safeName
 }
 
 // GenerateSafeResourceName generates a unique and K8s-compliant resource name.
 // Format: prefix-{sanitized_name}-{short_hash}
 // Constraint: Kubernetes names must be max 63 characters, lowercase, alphanumeric, or hyphen.
+// Names that sanitize to nothing or too little, such as CJK or emoji names, use "p{id}" instead.
 func GenerateSafeResourceName(prefix string, name string, id uint) string {
 	// 1. Sanitize Name: Keep only lowercase alphanumeric characters and hyphens.
 	// Replace invalid characters with a hyphen.
-	reg := regexp.MustCompile("[^a-z0-9]+")
-	safeName := reg.ReplaceAllString(strings.ToLower(name), "-")
-	safeName = strings.Trim(safeName, "-") // Remove leading/trailing hyphens
+	safeName, lossy := sanitizeName(name)
+	if needsFallbackName(safeName, lossy) {
+		safeName = fmt.Sprintf("p%d", id)
+	}
 
 	// 2. Generate Short Hash from ID to ensure uniqueness.
 	// Using the ID as a seed ensures that the same Project ID always generates the same namespace name.
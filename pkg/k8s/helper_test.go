@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSafeResourceNameTruncation(t *testing.T) {
+	long := strings.Repeat("deep-learning-research-", 5)
+	a := GenerateSafeResourceName("project", long+"alpha", 41)
+	b := GenerateSafeResourceName("project", long+"beta", 42)
+
+	for _, name := range []string{a, b} {
+		if len(name) > 63 {
+			t.Fatalf("expected at most 63 characters, got %d: %s", len(name), name)
+		}
+		if strings.Contains(name, "--") || strings.HasSuffix(name, "-") {
+			t.Fatalf("expected a clean DNS label, got %s", name)
+		}
+	}
+	// Both names truncate to the same prefix; the ID hash keeps them apart
+	if a == b {
+		t.Fatalf("projects with different IDs must not share a namespace: %s", a)
+	}
+	if got := GenerateSafeResourceName("project", long+"alpha", 41); got != a {
+		t.Fatalf("expected the name to be stable, got %s and %s", a, got)
+	}
+}
+
+func TestToSafeK8sNameFallsBackForUnicodeNames(t *testing.T) {
+	label := regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+	// Names that sanitize well keep their current labels, or existing namespaces would move
+	for raw, want := range map[string]string{"Alice Smith": "alice-smith", "bo": "bo", "José": "jos", "dev_01": "dev-01"} {
+		if got := ToSafeK8sName(raw); got != want {
+			t.Fatalf("expected %q to stay %q, got %q", raw, want, got)
+		}
+	}
+
+	names := []string{"訓練設定", "推論設定", "李a", "王a", "🚀", "🔥", "   ", "\t", ""}
+	seen := map[string]string{}
+	for _, raw := range names {
+		got := ToSafeK8sName(raw)
+		if !label.MatchString(got) || len(got) > 63 {
+			t.Fatalf("expected a DNS label for %q, got %q", raw, got)
+		}
+		if !UsesFallbackName(raw) {
+			t.Fatalf("expected %q to use the fallback slug, got %q", raw, got)
+		}
+		if other, ok := seen[got]; ok {
+			t.Fatalf("%q and %q collide on %q", other, raw, got)
+		}
+		seen[got] = raw
+		if again := ToSafeK8sName(raw); again != got {
+			t.Fatalf("expected the slug of %q to be stable, got %q and %q", raw, got, again)
+		}
+	}
+	// Composed and decomposed forms of the same name share a slug
+	if ToSafeK8sName("\u00e9\u00e9") != ToSafeK8sName("e\u0301e\u0301") {
+		t.Fatal("expected NFC and NFD spellings to map to the same label")
+	}
+}
+
+func TestGenerateSafeResourceNameFallsBackToID(t *testing.T) {
+	a := GenerateSafeResourceName("project", "影像辨識", 7)
+	b := GenerateSafeResourceName("project", "語音辨識", 8)
+	if !strings.HasPrefix(a, "project-p7-") || !strings.HasPrefix(b, "project-p8-") {
+		t.Fatalf("expected the project IDs in place of the names, got %s and %s", a, b)
+	}
+	if a == b {
+		t.Fatalf("projects with different IDs must not share a namespace: %s", a)
+	}
+	for _, name := range []string{"🚀🚀", "   ", "李a"} {
+		if got := GenerateSafeResourceName("project", name, 9); !strings.HasPrefix(got, "project-p9-") {
+			t.Fatalf("expected %q to fall back to the ID, got %s", name, got)
+		}
+	}
+	if got := GenerateSafeResourceName("project", "Vision 影像", 7); !strings.HasPrefix(got, "project-vision-") {
+		t.Fatalf("expected the ASCII part of a mixed name to be kept, got %s", got)
+	}
+}
+
+func TestLegacySafeK8sName(t *testing.T) {
+	for raw, want := range map[string]string{"Alice Smith": "alice-smith", "李a": "a", "訓練設定": "unnamed", "": "unnamed", "e\u0301e": "e-e"} {
+		if got := LegacySafeK8sName(raw); got != want {
+			t.Fatalf("expected %q to map to %q, got %q", raw, want, got)
+		}
+	}
+	// Names the slug does not apply to map as before
+	if LegacySafeK8sName("dev_01") != ToSafeK8sName("dev_01") {
+		t.Fatalf("expected names that sanitize well to keep their K8s name")
+	}
+}
@@ -0,0 +1,51 @@
+package k8s
+
+import (
+	"strconv"
+	"time"
+)
+
+// Image provenance annotations, set on the pod template of workloads whose image comes from
+// the allow list. They tell who approved the image and through which request.
+const (
+	AnnotationImageRequestID = "platform.linskybing.io/image-request-id"
+	AnnotationApprovedBy     = "platform.linskybing.io/approved-by"
+	AnnotationApprovedAt     = "platform.linskybing.io/approved-at"
+)
+
+// ImageProvenance is the approval behind an allow-listed image. RequestID is zero for rules an
+// admin added directly; ApprovedBy is the ID of the approving user.
+type ImageProvenance struct {
+	RequestID  uint      `json:"requestId,omitempty"`
+	ApprovedBy uint      `json:"approvedBy"`
+	ApprovedAt time.Time `json:"approvedAt"`
+}
+
+// Annotations returns the provenance annotations of p.
+func (p ImageProvenance) Annotations() map[string]string {
+	annotations := map[string]string{
+		AnnotationApprovedBy: strconv.FormatUint(uint64(p.ApprovedBy), 10),
+		AnnotationApprovedAt: p.ApprovedAt.UTC().Format(time.RFC3339),
+	}
+	if p.RequestID != 0 {
+		annotations[AnnotationImageRequestID] = strconv.FormatUint(uint64(p.RequestID), 10)
+	}
+	return annotations
+}
+
+// ProvenanceFromAnnotations reads the provenance annotations back; it returns nil when the
+// object carries none.
+func ProvenanceFromAnnotations(annotations map[string]string) *ImageProvenance {
+	at, err := time.Parse(time.RFC3339, annotations[AnnotationApprovedAt])
+	if err != nil {
+		return nil
+	}
+	p := &ImageProvenance{ApprovedAt: at}
+	if by, err := strconv.ParseUint(annotations[AnnotationApprovedBy], 10, 64); err == nil {
+		p.ApprovedBy = uint(by)
+	}
+	if id, err := strconv.ParseUint(annotations[AnnotationImageRequestID], 10, 64); err == nil {
+		p.RequestID = uint(id)
+	}
+	return p
+}
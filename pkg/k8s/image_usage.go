@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isPlatformNamespace reports whether a namespace was created by the platform: labeled with
+// the ownership labels, a labeled project user namespace, or a legacy proj-<pid>-* one.
+func isPlatformNamespace(ns corev1.Namespace) bool {
+	if ns.Labels[LabelManagedBy] == ManagedByPlatform || ns.Labels["type"] == "project-user" {
+		return true
+	}
+	_, _, ok := ParseProjectNamespace(ns.Name)
+	return ok
+}
+
+// CountRunningPodImages counts the running pods per container image across the platform
+// namespaces. A pod running several containers from the same image is counted once.
+func CountRunningPodImages(ctx context.Context) (map[string]int, error) {
+	counts := map[string]int{}
+	if Clientset == nil {
+		return counts, nil
+	}
+
+	namespaces, err := Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	for _, ns := range namespaces.Items {
+		if !isPlatformNamespace(ns) {
+			continue
+		}
+		pods, err := Clientset.CoreV1().Pods(ns.Name).List(ctx, metav1.ListOptions{FieldSelector: "status.phase=Running"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in %s: %w", ns.Name, err)
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			seen := map[string]bool{}
+			for _, c := range pod.Spec.Containers {
+				if !seen[c.Image] {
+					seen[c.Image] = true
+					counts[c.Image]++
+				}
+			}
+		}
+	}
+	return counts, nil
+}
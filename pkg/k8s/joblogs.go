@@ -0,0 +1,180 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultJobLogMaxBytes bounds the total log bytes returned by GetJobPodLogs when no limit is given.
+const DefaultJobLogMaxBytes = 8 * 1024
+
+const truncationMarker = "\n... [truncated] ...\n"
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// JobLogOptions controls how much of each container's log GetJobPodLogs fetches.
+type JobLogOptions struct {
+	TailLines int64 // Lines fetched per container (0 = server default)
+	MaxBytes  int   // Total budget across all containers (0 = DefaultJobLogMaxBytes)
+}
+
+// ContainerLog is the sanitized log of one container in a Job pod.
+type ContainerLog struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Init      bool   `json:"init,omitempty"`
+	ExitCode  *int32 `json:"exit_code,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Log       string `json:"log"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// GetJobPodLogs fetches the logs of every container of the Job's pods, sanitized and
+// truncated (head and tail kept) so that the combined size stays within opts.MaxBytes.
+func GetJobPodLogs(ctx context.Context, ns, jobName string, opts JobLogOptions) ([]ContainerLog, error) {
+	if Clientset == nil {
+		return nil, fmt.Errorf("k8s client not available")
+	}
+
+	pods, err := Clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for job %s: %w", jobName, err)
+	}
+
+	var logs []ContainerLog
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		for _, c := range pod.Spec.InitContainers {
+			logs = append(logs, containerLogEntry(pod, c.Name, true, pod.Status.InitContainerStatuses))
+		}
+		for _, c := range pod.Spec.Containers {
+			logs = append(logs, containerLogEntry(pod, c.Name, false, pod.Status.ContainerStatuses))
+		}
+	}
+	if len(logs) == 0 {
+		return logs, nil
+	}
+
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultJobLogMaxBytes
+	}
+	perContainer := maxBytes / len(logs)
+
+	for i := range logs {
+		logOpts := &corev1.PodLogOptions{Container: logs[i].Container}
+		if opts.TailLines > 0 {
+			tail := opts.TailLines
+			logOpts.TailLines = &tail
+		}
+		// Read a bit more than the budget so the tail is still visible after truncation
+		limit := int64(perContainer) * 4
+		logOpts.LimitBytes = &limit
+
+		stream, err := Clientset.CoreV1().Pods(ns).GetLogs(logs[i].Pod, logOpts).Stream(ctx)
+		if err != nil {
+			logs[i].Error = err.Error()
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(stream, limit))
+		_ = stream.Close()
+		if err != nil {
+			logs[i].Error = err.Error()
+			continue
+		}
+		logs[i].Log, logs[i].Truncated = TruncateHeadTail(SanitizeLog(string(data)), perContainer)
+	}
+	return logs, nil
+}
+
+func containerLogEntry(pod *corev1.Pod, name string, init bool, statuses []corev1.ContainerStatus) ContainerLog {
+	entry := ContainerLog{Pod: pod.Name, Container: name, Init: init}
+	for _, st := range statuses {
+		if st.Name != name {
+			continue
+		}
+		term := st.State.Terminated
+		if term == nil {
+			term = st.LastTerminationState.Terminated
+		}
+		if term != nil {
+			code := term.ExitCode
+			entry.ExitCode = &code
+			entry.Reason = term.Reason
+		} else if st.State.Waiting != nil {
+			entry.Reason = st.State.Waiting.Reason
+		}
+	}
+	return entry
+}
+
+// SanitizeLog strips ANSI escape sequences, invalid UTF-8 and control characters other than newline and tab.
+func SanitizeLog(s string) string {
+	s = ansiEscape.ReplaceAllString(s, "")
+	s = strings.ToValidUTF8(s, "")
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r == '\r' || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// TruncateHeadTail keeps the beginning and end of s so the result is at most maxBytes long.
+// Cuts never split a UTF-8 sequence.
+func TruncateHeadTail(s string, maxBytes int) (string, bool) {
+	if maxBytes <= 0 || len(s) <= maxBytes {
+		return s, false
+	}
+	if maxBytes <= len(truncationMarker) {
+		return trimToRuneBoundary(s, maxBytes), true
+	}
+
+	budget := maxBytes - len(truncationMarker)
+	head := trimToRuneBoundary(s, budget/2)
+	tailStart := len(s) - (budget - len(head))
+	for tailStart < len(s) && !utf8.RuneStart(s[tailStart]) {
+		tailStart++
+	}
+	return head + truncationMarker + s[tailStart:], true
+}
+
+func trimToRuneBoundary(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// SummarizeContainerLogs renders a short one-line-per-container summary for error messages.
+func SummarizeContainerLogs(logs []ContainerLog) string {
+	var parts []string
+	for _, l := range logs {
+		if l.ExitCode == nil || *l.ExitCode == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%s exited with code %d", l.Container, *l.ExitCode)
+		if l.Reason != "" {
+			part += " (" + l.Reason + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
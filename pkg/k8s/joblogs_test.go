@@ -0,0 +1,93 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSanitizeLog(t *testing.T) {
+	in := "\x1b[31mERROR\x1b[0m pull failed\r\n\x00\x07bad\xff\xfebytes\ttab\x1b]0;title\x07done"
+	got := SanitizeLog(in)
+	want := "ERROR pull failed\nbadbytes\ttabdone"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if !utf8.ValidString(got) {
+		t.Fatalf("sanitized log is not valid UTF-8")
+	}
+}
+
+func TestTruncateHeadTail(t *testing.T) {
+	if got, truncated := TruncateHeadTail("short", 100); got != "short" || truncated {
+		t.Fatalf("short input should be untouched, got %q (truncated=%v)", got, truncated)
+	}
+
+	in := "HEAD" + strings.Repeat("x", 10000) + "TAIL"
+	got, truncated := TruncateHeadTail(in, 200)
+	if !truncated {
+		t.Fatalf("expected truncation")
+	}
+	if len(got) > 200 {
+		t.Fatalf("expected at most 200 bytes, got %d", len(got))
+	}
+	if !strings.HasPrefix(got, "HEAD") || !strings.HasSuffix(got, "TAIL") || !strings.Contains(got, "[truncated]") {
+		t.Fatalf("expected head, marker and tail to be kept, got %q", got)
+	}
+
+	multi := strings.Repeat("日本語", 200)
+	got, _ = TruncateHeadTail(multi, 101)
+	if !utf8.ValidString(got) || len(got) > 101 {
+		t.Fatalf("truncation must not split runes: len=%d valid=%v", len(got), utf8.ValidString(got))
+	}
+}
+
+func TestGetJobPodLogsStructuredFields(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "puller-abc", Namespace: "image-puller", Labels: map[string]string{"job-name": "puller"}},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "pull-source"}},
+			Containers:     []corev1.Container{{Name: "push-to-harbor"}},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "pull-source",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+			}},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "push-to-harbor",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}},
+			}},
+		},
+	})
+
+	logs, err := GetJobPodLogs(context.Background(), "image-puller", "puller", JobLogOptions{TailLines: 10, MaxBytes: 64})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("expected one entry per container, got %d", len(logs))
+	}
+	init := logs[0]
+	if init.Container != "pull-source" || !init.Init || init.ExitCode == nil || *init.ExitCode != 1 || init.Reason != "Error" {
+		t.Fatalf("unexpected init container entry: %+v", init)
+	}
+	if logs[1].ExitCode != nil || logs[1].Reason != "PodInitializing" {
+		t.Fatalf("unexpected container entry: %+v", logs[1])
+	}
+	for _, l := range logs {
+		if len(l.Log) > 32 {
+			t.Fatalf("each container should get half of the byte budget, got %d bytes", len(l.Log))
+		}
+	}
+	if got := SummarizeContainerLogs(logs); got != "pull-source exited with code 1 (Error)" {
+		t.Fatalf("unexpected summary %q", got)
+	}
+}
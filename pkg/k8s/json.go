@@ -4,77 +4,161 @@ import (
 	"context"
 	applyJson "encoding/json"
 	"fmt"
+	"regexp"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
 )
 
-func ValidateK8sJSON(jsonBytes []byte) (*schema.GroupVersionKind, string, error) {
-	obj := &unstructured.Unstructured{}
+// SpecError is a manifest validation failure. Fields lists the offending field paths, such as
+// "spec.containers[0].imagePullPolicyy" for an unknown field, when they are known.
+type SpecError struct {
+	Fields  []string
+	Message string
+}
+
+func (e *SpecError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(e.Fields, ", "), e.Message)
+}
 
-	if err := obj.UnmarshalJSON(jsonBytes); err != nil {
-		return nil, "", fmt.Errorf("failed to unmarshal JSON to Kubernetes object: %w", err)
+var strictFieldPattern = regexp.MustCompile(`field "([^"]+)"`)
+
+// strictDecoder rejects unknown and duplicate fields for the built-in kinds.
+var strictDecoder = k8sjson.NewSerializerWithOptions(k8sjson.DefaultMetaFactory, scheme.Scheme, scheme.Scheme,
+	k8sjson.SerializerOptions{Strict: true})
+
+// ValidateK8sJSON checks that jsonBytes is a Kubernetes object with kind, apiVersion and
+// metadata.name or metadata.generateName, which is returned as the name then. Kinds known to the client-go scheme are also decoded strictly, so typos in
+// field names are reported instead of silently dropped. Failures are returned as *SpecError.
+func ValidateK8sJSON(jsonBytes []byte) (*schema.GroupVersionKind, string, error) {
+	var raw map[string]interface{}
+	if err := applyJson.Unmarshal(jsonBytes, &raw); err != nil {
+		return nil, "", &SpecError{Message: fmt.Sprintf("failed to unmarshal JSON to Kubernetes object: %v", err)}
+	}
+	for _, f := range []string{"apiVersion", "kind"} {
+		if v, _ := raw[f].(string); v == "" {
+			return nil, "", &SpecError{Fields: []string{f}, Message: "is required"}
+		}
 	}
 
+	obj := &unstructured.Unstructured{Object: raw}
 	gvk := obj.GroupVersionKind()
-	if gvk.Kind == "" || gvk.Version == "" {
-		return nil, "", fmt.Errorf("object is missing kind or apiVersion")
+	if gvk.Version == "" {
+		return nil, "", &SpecError{Fields: []string{"apiVersion"}, Message: fmt.Sprintf("invalid apiVersion %q", raw["apiVersion"])}
 	}
 
+	// With generateName the server picks the name when the object is created
 	name := obj.GetName()
 	if name == "" {
-		return nil, "", fmt.Errorf("object is missing metadata.name")
+		name = obj.GetGenerateName()
+	}
+	if name == "" {
+		return nil, "", &SpecError{Fields: []string{"metadata.name"}, Message: "is required unless metadata.generateName is set"}
+	}
+
+	if scheme.Scheme.Recognizes(gvk) {
+		if _, _, err := strictDecoder.Decode(jsonBytes, &gvk, nil); err != nil {
+			if strictErr, ok := runtime.AsStrictDecodingError(err); ok {
+				se := &SpecError{Message: "strict decoding failed"}
+				msgs := make([]string, 0, len(strictErr.Errors()))
+				for _, e := range strictErr.Errors() {
+					msgs = append(msgs, e.Error())
+					if m := strictFieldPattern.FindStringSubmatch(e.Error()); m != nil {
+						se.Fields = append(se.Fields, m[1])
+					}
+				}
+				se.Message = strings.Join(msgs, "; ")
+				if len(se.Fields) == len(msgs) && allUnknown(msgs) {
+					se.Message = "unknown field"
+				}
+				return nil, "", se
+			}
+			return nil, "", &SpecError{Message: fmt.Sprintf("invalid %s: %v", gvk.Kind, err)}
+		}
 	}
 
 	return &gvk, name, nil
 }
 
-func CreateByJson(jsonStr []byte, ns string) error {
-	if Mapper == nil || DynamicClient == nil {
-		fmt.Printf("[MOCK] Created resource by JSON in namespace %s\n", ns)
-		return nil
+func allUnknown(msgs []string) bool {
+	for _, m := range msgs {
+		if !strings.HasPrefix(m, "unknown field") {
+			return false
+		}
 	}
+	return true
+}
+
+// CreateByJson creates the object in ns with labels merged into its metadata and, for workload
+// kinds, its pod template (see ApplyOwnershipLabels). It returns the object the API server
+// created, whose name the server generated when the manifest only sets metadata.generateName.
+func CreateByJson(jsonStr []byte, ns string, labels map[string]string) (*unstructured.Unstructured, error) {
 	// decode
 	var obj unstructured.Unstructured
 	if err := applyJson.Unmarshal(jsonStr, &obj.Object); err != nil {
-		return err
+		return nil, err
+	}
+	if Mapper == nil || DynamicClient == nil {
+		k8sLog.Debug("mock: created resource by JSON", "namespace", ns)
+		return &obj, nil
 	}
+	ApplyOwnershipLabels(obj.Object, labels)
 
 	gvk := obj.GroupVersionKind()
 	mapping, err := Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if ns == "" {
 		ns = "default"
 	}
 	resourceClient := DynamicClient.Resource(mapping.Resource).Namespace(ns)
-	result, err := resourceClient.Create(context.TODO(), &obj, metav1.CreateOptions{})
+	result, err := resourceClient.Create(context.TODO(), &obj, metav1.CreateOptions{FieldManager: FieldManager})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("Created %s/%s\n", result.GetKind(), result.GetName())
-	return nil
+	k8sLog.Debug("created resource", "namespace", ns, "kind", result.GetKind(), "name", result.GetName())
+	return result, nil
+}
+
+// DeleteByJson deletes the object of the kind described by jsonStr named name from ns. An
+// empty name falls back to metadata.name, which is empty for objects created with
+// metadata.generateName, so callers pass the name the object was created under.
+func DeleteByJson(jsonStr []byte, ns, name string) error {
+	_, err := DeleteByJsonIfExists(jsonStr, ns, name)
+	return err
 }
 
-func DeleteByJson(jsonStr []byte, ns string) error {
+// DeleteByJsonIfExists deletes the object like DeleteByJson and reports whether it was still
+// there. An object or namespace that is already gone is not an error.
+func DeleteByJsonIfExists(jsonStr []byte, ns, name string) (bool, error) {
 	if Mapper == nil || DynamicClient == nil {
-		fmt.Printf("[MOCK] Deleted resource by JSON in namespace %s\n", ns)
-		return nil
+		k8sLog.Debug("mock: deleted resource by JSON", "namespace", ns, "name", name)
+		return true, nil
 	}
 	// decode
 	var obj unstructured.Unstructured
 	if err := applyJson.Unmarshal(jsonStr, &obj.Object); err != nil {
-		return err
+		return false, err
+	}
+	if name == "" {
+		name = obj.GetName()
 	}
 
 	gvk := obj.GroupVersionKind()
 	mapping, err := Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if ns == "" {
@@ -82,31 +166,88 @@ func DeleteByJson(jsonStr []byte, ns string) error {
 	}
 	resourceClient := DynamicClient.Resource(mapping.Resource).Namespace(ns)
 	policy := metav1.DeletePropagationBackground
-	err = resourceClient.Delete(context.TODO(), obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &policy})
+	err = resourceClient.Delete(context.TODO(), name, metav1.DeleteOptions{PropagationPolicy: &policy})
+	if err != nil {
+		if IsGone(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteOwnedByJson deletes the object like DeleteByJson, but only when it carries every label
+// in owner, so members of a shared namespace cannot remove each other's objects. Objects that
+// are missing or owned by someone else are left alone.
+func DeleteOwnedByJson(jsonStr []byte, ns, name string, owner map[string]string) error {
+	if Mapper == nil || DynamicClient == nil {
+		k8sLog.Debug("mock: deleted owned resource by JSON", "namespace", ns, "name", name)
+		return nil
+	}
+	var obj unstructured.Unstructured
+	if err := applyJson.Unmarshal(jsonStr, &obj.Object); err != nil {
+		return err
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = obj.GetName()
+	}
+	resourceClient := DynamicClient.Resource(mapping.Resource).Namespace(ns)
+	current, err := resourceClient.Get(context.TODO(), name, metav1.GetOptions{})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return nil
 		}
 		return err
 	}
+	if !HasLabels(current.GetLabels(), owner) {
+		k8sLog.Info("skipped deleting resource not owned by the caller", "namespace", ns, "kind", current.GetKind(), "name", current.GetName())
+		return nil
+	}
+
+	policy := metav1.DeletePropagationBackground
+	err = resourceClient.Delete(context.TODO(), current.GetName(), metav1.DeleteOptions{PropagationPolicy: &policy})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
 	return nil
 }
 
-func UpdateByJson(jsonStr []byte, ns string) error {
-	if Mapper == nil || DynamicClient == nil {
-		fmt.Printf("[MOCK] Updated resource by JSON in namespace %s\n", ns)
-		return nil
+// HasLabels reports whether labels contains every key and value of want.
+func HasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
 	}
+	return true
+}
+
+// UpdateByJson replaces the object described by jsonStr in ns named name, or metadata.name when
+// name is empty, and returns the object the API server stored.
+func UpdateByJson(jsonStr []byte, ns, name string) (*unstructured.Unstructured, error) {
 	// decode
 	var obj unstructured.Unstructured
 	if err := applyJson.Unmarshal(jsonStr, &obj.Object); err != nil {
-		return err
+		return nil, err
+	}
+	if name != "" {
+		obj.SetName(name)
+	}
+	if Mapper == nil || DynamicClient == nil {
+		k8sLog.Debug("mock: updated resource by JSON", "namespace", ns, "name", name)
+		return &obj, nil
 	}
 
 	gvk := obj.GroupVersionKind()
 	mapping, err := Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if ns == "" {
@@ -115,8 +256,8 @@ func UpdateByJson(jsonStr []byte, ns string) error {
 	resourceClient := DynamicClient.Resource(mapping.Resource).Namespace(ns)
 	result, err := resourceClient.Update(context.TODO(), &obj, metav1.UpdateOptions{})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("Updated %s/%s\n", result.GetKind(), result.GetName())
-	return nil
+	k8sLog.Debug("updated resource", "namespace", ns, "kind", result.GetKind(), "name", result.GetName())
+	return result, nil
 }
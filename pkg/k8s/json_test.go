@@ -0,0 +1,48 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateK8sJSONReportsUnknownField(t *testing.T) {
+	doc := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"p"},
+		"spec":{"containers":[{"name":"c","image":"nginx","imagePullPolicyy":"Always"}]}}`
+
+	_, _, err := ValidateK8sJSON([]byte(doc))
+	var specErr *SpecError
+	if !errors.As(err, &specErr) {
+		t.Fatalf("expected *SpecError, got %v", err)
+	}
+	if len(specErr.Fields) != 1 || specErr.Fields[0] != "spec.containers[0].imagePullPolicyy" {
+		t.Fatalf("unexpected fields %v (%v)", specErr.Fields, err)
+	}
+}
+
+func TestValidateK8sJSONMissingKind(t *testing.T) {
+	_, _, err := ValidateK8sJSON([]byte(`{"apiVersion":"v1","metadata":{"name":"p"}}`))
+	var specErr *SpecError
+	if !errors.As(err, &specErr) || len(specErr.Fields) != 1 || specErr.Fields[0] != "kind" {
+		t.Fatalf("expected missing kind, got %v", err)
+	}
+}
+
+func TestValidateK8sJSONAllowsUnknownKinds(t *testing.T) {
+	gvk, name, err := ValidateK8sJSON([]byte(`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w"},"spec":{"anything":1}}`))
+	if err != nil || gvk.Kind != "Widget" || name != "w" {
+		t.Fatalf("custom resources must not be strictly decoded: %v", err)
+	}
+}
+
+func TestValidateK8sJSONAcceptsGenerateName(t *testing.T) {
+	_, name, err := ValidateK8sJSON([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"generateName":"lab-"},"spec":{"containers":[{"name":"c","image":"nginx"}]}}`))
+	if err != nil || name != "lab-" {
+		t.Fatalf("expected the generateName to stand in for the name, got %q (%v)", name, err)
+	}
+
+	_, _, err = ValidateK8sJSON([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{}}`))
+	var specErr *SpecError
+	if !errors.As(err, &specErr) || len(specErr.Fields) != 1 || specErr.Fields[0] != "metadata.name" {
+		t.Fatalf("expected a missing name, got %v", err)
+	}
+}
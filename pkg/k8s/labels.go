@@ -0,0 +1,406 @@
+package k8s
+
+import (
+	"context"
+	applyJson "encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Ownership labels carried by every object the platform creates. Listing and accounting code
+// selects on these instead of guessing from names.
+const (
+	LabelManagedBy    = "platform.linskybing.io/managed-by"
+	LabelProjectID    = "platform.linskybing.io/project-id"
+	LabelUserID       = "platform.linskybing.io/user-id"
+	LabelConfigFileID = "platform.linskybing.io/configfile-id"
+	LabelJobID        = "platform.linskybing.io/job-id"
+
+	ManagedByPlatform = "platform-go"
+	// ManagedSelector matches every platform-created object.
+	ManagedSelector = LabelManagedBy + "=" + ManagedByPlatform
+	// legacyProjectIDLabel is the project label used before the ownership labels existed.
+	legacyProjectIDLabel = "project-id"
+)
+
+// Chargeback labels attribute the cost of a project's objects to a grant.
+const (
+	LabelCostCenter = "platform.linskybing.io/cost-center"
+	// CostTagLabelPrefix is followed by the key of each free-form cost tag of the project
+	CostTagLabelPrefix = "tag.platform.linskybing.io/"
+)
+
+// ProjectLabels returns the chargeback labels of a project, which Ownership.Labels adds to every
+// object of the project. It is set at startup; nil adds none.
+var ProjectLabels func(projectID uint) map[string]string
+
+// Ownership identifies what an object belongs to. Zero fields are left out of the labels.
+type Ownership struct {
+	ProjectID    uint
+	UserID       uint
+	ConfigFileID uint
+	JobID        uint
+}
+
+// Labels returns the ownership label set, always including the managed-by label, and the
+// chargeback labels of the project when there is one.
+func (o Ownership) Labels() map[string]string {
+	labels := map[string]string{LabelManagedBy: ManagedByPlatform}
+	if o.ProjectID != 0 && ProjectLabels != nil {
+		labels = MergeLabels(labels, ProjectLabels(o.ProjectID))
+	}
+	for key, id := range map[string]uint{
+		LabelProjectID:    o.ProjectID,
+		LabelUserID:       o.UserID,
+		LabelConfigFileID: o.ConfigFileID,
+		LabelJobID:        o.JobID,
+	} {
+		if id != 0 {
+			labels[key] = strconv.FormatUint(uint64(id), 10)
+		}
+	}
+	return labels
+}
+
+// MergeLabels copies extra into labels, allocating it if needed. Keys in extra win, so user
+// manifests cannot override the ownership labels.
+func MergeLabels(labels, extra map[string]string) map[string]string {
+	if labels == nil {
+		labels = make(map[string]string, len(extra))
+	}
+	for k, v := range extra {
+		labels[k] = v
+	}
+	return labels
+}
+
+// ChargebackLabels returns the labels of a cost center and free-form cost tags; empty values are
+// left out.
+func ChargebackLabels(costCenter string, tags map[string]string) map[string]string {
+	labels := make(map[string]string, len(tags)+1)
+	if costCenter != "" {
+		labels[LabelCostCenter] = costCenter
+	}
+	for k, v := range tags {
+		if v != "" {
+			labels[CostTagLabelPrefix+k] = v
+		}
+	}
+	return labels
+}
+
+// ValidateChargebackLabels checks that chargeback labels are valid Kubernetes label keys and
+// values, so they can be applied to every object of the project.
+func ValidateChargebackLabels(labels map[string]string) error {
+	var errs []error
+	for k, v := range labels {
+		if msgs := validation.IsQualifiedName(k); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("key %q: %s", strings.TrimPrefix(k, CostTagLabelPrefix), strings.Join(msgs, "; ")))
+		}
+		if msgs := validation.IsValidLabelValue(v); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("value %q: %s", v, strings.Join(msgs, "; ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isChargebackLabel(key string) bool {
+	return key == LabelCostCenter || strings.HasPrefix(key, CostTagLabelPrefix)
+}
+
+// RelabelProject replaces the chargeback labels on the namespaces and PVCs of a project with
+// labels, removing those no longer set. Only metadata is patched. It returns "kind ns/name" of
+// every object that was updated.
+func RelabelProject(ctx context.Context, projectID uint, labels map[string]string) ([]string, error) {
+	updated := []string{}
+	// Read once: the relabel runs in the background and may outlive a swap of the client.
+	client := Clientset
+	if client == nil {
+		return updated, nil
+	}
+	selector := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%d", LabelProjectID, projectID)}
+
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, selector)
+	if err != nil {
+		return updated, fmt.Errorf("failed to list namespaces of project %d: %w", projectID, err)
+	}
+	for _, ns := range namespaces.Items {
+		patch, changed := chargebackPatch(ns.Labels, labels)
+		if !changed {
+			continue
+		}
+		if _, err := client.CoreV1().Namespaces().Patch(ctx, ns.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return updated, fmt.Errorf("failed to relabel namespace %s: %w", ns.Name, err)
+		}
+		updated = append(updated, "Namespace "+ns.Name)
+	}
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, selector)
+	if err != nil {
+		return updated, fmt.Errorf("failed to list PVCs of project %d: %w", projectID, err)
+	}
+	for _, pvc := range pvcs.Items {
+		patch, changed := chargebackPatch(pvc.Labels, labels)
+		if !changed {
+			continue
+		}
+		if _, err := client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return updated, fmt.Errorf("failed to relabel PVC %s/%s: %w", pvc.Namespace, pvc.Name, err)
+		}
+		updated = append(updated, fmt.Sprintf("PersistentVolumeClaim %s/%s", pvc.Namespace, pvc.Name))
+	}
+	return updated, nil
+}
+
+// chargebackPatch is the merge patch that turns the chargeback labels of have into want, and
+// whether anything changes.
+func chargebackPatch(have, want map[string]string) ([]byte, bool) {
+	changes := map[string]interface{}{}
+	for k := range have {
+		if _, keep := want[k]; isChargebackLabel(k) && !keep {
+			changes[k] = nil
+		}
+	}
+	for k, v := range want {
+		if have[k] != v {
+			changes[k] = v
+		}
+	}
+	if len(changes) == 0 {
+		return nil, false
+	}
+	patch, _ := applyJson.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": changes},
+	})
+	return patch, true
+}
+
+// ProjectIDFromLabels reads the project of an object, accepting the legacy "project-id" label.
+func ProjectIDFromLabels(labels map[string]string) (uint, bool) {
+	for _, key := range []string{LabelProjectID, legacyProjectIDLabel} {
+		if v, ok := labels[key]; ok {
+			if id, err := strconv.ParseUint(v, 10, 64); err == nil {
+				return uint(id), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// ApplyOwnershipLabels merges labels into metadata.labels of a decoded manifest and into the pod
+// template labels of workload kinds, so the pods they spawn carry them too. Selectors are left
+// alone, which keeps the change safe for existing Deployments.
+func ApplyOwnershipLabels(obj map[string]interface{}, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	mergeMetadataLabels(obj, labels)
+
+	spec, _ := obj["spec"].(map[string]interface{})
+	if spec == nil {
+		return
+	}
+	kind, _ := obj["kind"].(string)
+	switch kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job":
+		if tmpl, ok := spec["template"].(map[string]interface{}); ok {
+			mergeMetadataLabels(tmpl, labels)
+		}
+	case "CronJob":
+		jobTmpl, ok := spec["jobTemplate"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		mergeMetadataLabels(jobTmpl, labels)
+		if jobSpec, ok := jobTmpl["spec"].(map[string]interface{}); ok {
+			if tmpl, ok := jobSpec["template"].(map[string]interface{}); ok {
+				mergeMetadataLabels(tmpl, labels)
+			}
+		}
+	}
+}
+
+func mergeMetadataLabels(obj map[string]interface{}, labels map[string]string) {
+	meta, _ := obj["metadata"].(map[string]interface{})
+	if meta == nil {
+		meta = map[string]interface{}{}
+		obj["metadata"] = meta
+	}
+	existing, _ := meta["labels"].(map[string]interface{})
+	if existing == nil {
+		existing = make(map[string]interface{}, len(labels))
+	}
+	for k, v := range labels {
+		existing[k] = v
+	}
+	meta["labels"] = existing
+}
+
+// BackfillOwnershipLabels labels objects created before the ownership labels existed. It finds
+// them with the old heuristics: proj-<pid>-<user> namespaces and namespaces carrying the legacy
+// project-id label, plus the pods, services, workloads and PVCs inside them. Only metadata labels
+// are patched, so running workloads are not restarted. It returns "kind ns/name" of every object
+// that was updated.
+func BackfillOwnershipLabels(ctx context.Context) ([]string, error) {
+	if Clientset == nil {
+		return []string{}, nil
+	}
+
+	namespaces, err := Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	updated := []string{}
+	for _, ns := range namespaces.Items {
+		pid, ok := ProjectIDFromLabels(ns.Labels)
+		if !ok {
+			pid, _, ok = ParseProjectNamespace(ns.Name)
+		}
+		if !ok {
+			continue
+		}
+		labels := Ownership{ProjectID: pid}.Labels()
+
+		if hasLabels(ns.Labels, labels) {
+			// Already migrated; the objects inside were labelled in the same pass
+			continue
+		}
+		for _, target := range backfillTargets {
+			names, err := target.list(ctx, ns.Name, labels)
+			if err != nil {
+				return updated, fmt.Errorf("failed to list %s in %s: %w", target.kind, ns.Name, err)
+			}
+			for _, name := range names {
+				if err := target.patch(ctx, ns.Name, name, labelPatch(labels)); err != nil && !apierrors.IsNotFound(err) {
+					return updated, fmt.Errorf("failed to label %s %s/%s: %w", target.kind, ns.Name, name, err)
+				}
+				updated = append(updated, fmt.Sprintf("%s %s/%s", target.kind, ns.Name, name))
+			}
+		}
+		// The namespace goes last so an interrupted run is picked up again
+		if _, err := Clientset.CoreV1().Namespaces().Patch(ctx, ns.Name, types.MergePatchType, labelPatch(labels), metav1.PatchOptions{}); err != nil {
+			return updated, fmt.Errorf("failed to label namespace %s: %w", ns.Name, err)
+		}
+		updated = append(updated, "Namespace "+ns.Name)
+	}
+	return updated, nil
+}
+
+func hasLabels(have, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func labelPatch(labels map[string]string) []byte {
+	patch, _ := applyJson.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": labels},
+	})
+	return patch
+}
+
+// backfillTarget lists the objects of one kind missing labels and patches them.
+type backfillTarget struct {
+	kind  string
+	list  func(ctx context.Context, ns string, labels map[string]string) ([]string, error)
+	patch func(ctx context.Context, ns, name string, patch []byte) error
+}
+
+// unlabeled returns the names of objects whose labels do not include want.
+func unlabeled[T any](items []T, meta func(*T) metav1.Object, want map[string]string) []string {
+	names := []string{}
+	for i := range items {
+		m := meta(&items[i])
+		if !hasLabels(m.GetLabels(), want) {
+			names = append(names, m.GetName())
+		}
+	}
+	return names
+}
+
+var backfillTargets = []backfillTarget{
+	{
+		kind: "PersistentVolumeClaim",
+		list: func(ctx context.Context, ns string, want map[string]string) ([]string, error) {
+			l, err := Clientset.CoreV1().PersistentVolumeClaims(ns).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return unlabeled(l.Items, func(o *corev1.PersistentVolumeClaim) metav1.Object { return o }, want), nil
+		},
+		patch: func(ctx context.Context, ns, name string, patch []byte) error {
+			_, err := Clientset.CoreV1().PersistentVolumeClaims(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+	},
+	{
+		kind: "Pod",
+		list: func(ctx context.Context, ns string, want map[string]string) ([]string, error) {
+			l, err := Clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return unlabeled(l.Items, func(o *corev1.Pod) metav1.Object { return o }, want), nil
+		},
+		patch: func(ctx context.Context, ns, name string, patch []byte) error {
+			_, err := Clientset.CoreV1().Pods(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+	},
+	{
+		kind: "Service",
+		list: func(ctx context.Context, ns string, want map[string]string) ([]string, error) {
+			l, err := Clientset.CoreV1().Services(ns).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return unlabeled(l.Items, func(o *corev1.Service) metav1.Object { return o }, want), nil
+		},
+		patch: func(ctx context.Context, ns, name string, patch []byte) error {
+			_, err := Clientset.CoreV1().Services(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+	},
+	{
+		kind: "Deployment",
+		list: func(ctx context.Context, ns string, want map[string]string) ([]string, error) {
+			l, err := Clientset.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return unlabeled(l.Items, func(o *appsv1.Deployment) metav1.Object { return o }, want), nil
+		},
+		patch: func(ctx context.Context, ns, name string, patch []byte) error {
+			_, err := Clientset.AppsV1().Deployments(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+	},
+	{
+		kind: "Job",
+		list: func(ctx context.Context, ns string, want map[string]string) ([]string, error) {
+			l, err := Clientset.BatchV1().Jobs(ns).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return unlabeled(l.Items, func(o *batchv1.Job) metav1.Object { return o }, want), nil
+		},
+		patch: func(ctx context.Context, ns, name string, patch []byte) error {
+			_, err := Clientset.BatchV1().Jobs(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+	},
+}
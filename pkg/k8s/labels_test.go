@@ -0,0 +1,227 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func assertOwnership(t *testing.T, what string, got map[string]string, want Ownership) {
+	t.Helper()
+	for k, v := range want.Labels() {
+		if got[k] != v {
+			t.Fatalf("%s: expected label %s=%s, got %v", what, k, v, got)
+		}
+	}
+}
+
+func TestOwnershipLabelsOmitZeroIDs(t *testing.T) {
+	labels := Ownership{ProjectID: 3, JobID: 7}.Labels()
+	if len(labels) != 3 || labels[LabelManagedBy] != ManagedByPlatform || labels[LabelProjectID] != "3" || labels[LabelJobID] != "7" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if id, ok := ProjectIDFromLabels(map[string]string{"project-id": "4"}); !ok || id != 4 {
+		t.Fatalf("expected legacy project-id label to be accepted, got %d %v", id, ok)
+	}
+}
+
+func TestApplyOwnershipLabelsWorkloadTemplates(t *testing.T) {
+	owner := Ownership{ProjectID: 1, UserID: 2, ConfigFileID: 3}
+	deploy := map[string]interface{}{
+		"kind":     "Deployment",
+		"metadata": map[string]interface{}{"name": "web", "labels": map[string]interface{}{"app": "web", LabelProjectID: "99"}},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"template": map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}}},
+		},
+	}
+	ApplyOwnershipLabels(deploy, owner.Labels())
+
+	meta := deploy["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+	if meta["app"] != "web" || meta[LabelProjectID] != "1" || meta[LabelConfigFileID] != "3" {
+		t.Fatalf("expected manifest labels kept and ownership to win, got %v", meta)
+	}
+	spec := deploy["spec"].(map[string]interface{})
+	tmpl := spec["template"].(map[string]interface{})["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+	if tmpl[LabelUserID] != "2" || tmpl["app"] != "web" {
+		t.Fatalf("expected template labels, got %v", tmpl)
+	}
+	if sel := spec["selector"].(map[string]interface{})["matchLabels"].(map[string]interface{}); len(sel) != 1 {
+		t.Fatalf("selector must not change, got %v", sel)
+	}
+
+	cron := map[string]interface{}{
+		"kind": "CronJob",
+		"spec": map[string]interface{}{
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{"template": map[string]interface{}{}},
+			},
+		},
+	}
+	ApplyOwnershipLabels(cron, owner.Labels())
+	podTmpl := cron["spec"].(map[string]interface{})["jobTemplate"].(map[string]interface{})["spec"].(map[string]interface{})["template"].(map[string]interface{})
+	if podTmpl["metadata"].(map[string]interface{})["labels"].(map[string]interface{})[LabelManagedBy] != ManagedByPlatform {
+		t.Fatalf("expected cronjob pod template to be labelled, got %v", podTmpl)
+	}
+}
+
+func TestCreateJobSetsOwnershipLabels(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+
+	owner := Ownership{ProjectID: 1, UserID: 2, JobID: 5}
+	if err := CreateJob(ctx, JobSpec{Name: "train", Namespace: "proj-1-bob", Image: "busybox", Labels: owner.Labels()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	job, err := Clientset.BatchV1().Jobs("proj-1-bob").Get(ctx, "train", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("job not created: %v", err)
+	}
+	assertOwnership(t, "job", job.Labels, owner)
+	assertOwnership(t, "job pod template", job.Spec.Template.Labels, owner)
+}
+
+func TestFileBrowserObjectsCarryOwnershipLabels(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+
+	owner := Ownership{ProjectID: 1}
+	if _, err := CreateFileBrowserPod(ctx, "proj-1", []string{"project-1-disk"}, true, "/", owner.Labels(), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := CreateFileBrowserService(ctx, "proj-1", owner.Labels()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod, err := Clientset.CoreV1().Pods("proj-1").Get(ctx, "filebrowser-project", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("pod not created: %v", err)
+	}
+	assertOwnership(t, "filebrowser pod", pod.Labels, owner)
+	svcs, err := Clientset.CoreV1().Services("proj-1").List(ctx, metav1.ListOptions{LabelSelector: ManagedSelector})
+	if err != nil || len(svcs.Items) != 1 {
+		t.Fatalf("expected one labelled service, got %v (err %v)", svcs, err)
+	}
+	assertOwnership(t, "filebrowser service", svcs.Items[0].Labels, owner)
+}
+
+func TestBackfillOwnershipLabels(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset(
+		newNamespace("proj-1-bob", nil),
+		newNamespace("kube-system", nil),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "proj-1-bob", Labels: map[string]string{"app": "worker"}}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "proj-1-bob"}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "done", Namespace: "proj-1-bob", Labels: Ownership{ProjectID: 1}.Labels()}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"}},
+	)
+	ctx := context.Background()
+
+	updated, err := BackfillOwnershipLabels(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updated) != 3 {
+		t.Fatalf("expected the pvc, pod and namespace to be labelled, got %v", updated)
+	}
+
+	want := Ownership{ProjectID: 1}
+	pod, _ := Clientset.CoreV1().Pods("proj-1-bob").Get(ctx, "worker", metav1.GetOptions{})
+	if pod.Labels["app"] != "worker" {
+		t.Fatalf("existing labels must be kept, got %v", pod.Labels)
+	}
+	assertOwnership(t, "pod", pod.Labels, want)
+	pvc, _ := Clientset.CoreV1().PersistentVolumeClaims("proj-1-bob").Get(ctx, "data", metav1.GetOptions{})
+	assertOwnership(t, "pvc", pvc.Labels, want)
+	ns, _ := Clientset.CoreV1().Namespaces().Get(ctx, "proj-1-bob", metav1.GetOptions{})
+	assertOwnership(t, "namespace", ns.Labels, want)
+	other, _ := Clientset.CoreV1().Pods("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+	if other.Labels[LabelManagedBy] != "" {
+		t.Fatalf("objects outside project namespaces must not be labelled")
+	}
+
+	updated, err = BackfillOwnershipLabels(ctx)
+	if err != nil || len(updated) != 0 {
+		t.Fatalf("expected a second run to be a no-op, got %v (err %v)", updated, err)
+	}
+}
+
+func TestChargebackLabels(t *testing.T) {
+	labels := ChargebackLabels("nsc-112-2221", map[string]string{"grant": "ai-2026", "empty": ""})
+	if len(labels) != 2 || labels[LabelCostCenter] != "nsc-112-2221" || labels[CostTagLabelPrefix+"grant"] != "ai-2026" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if err := ValidateChargebackLabels(labels); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateChargebackLabels(ChargebackLabels("grant #1", map[string]string{"bad key": "x"})); err == nil {
+		t.Fatal("expected an invalid value and key to be rejected")
+	}
+
+	orig := ProjectLabels
+	defer func() { ProjectLabels = orig }()
+	ProjectLabels = func(uint) map[string]string {
+		return map[string]string{LabelCostCenter: "nsc", LabelProjectID: "99"}
+	}
+	project := Ownership{ProjectID: 3}.Labels()
+	if project[LabelCostCenter] != "nsc" || project[LabelProjectID] != "3" {
+		t.Fatalf("expected the chargeback labels without overriding ownership, got %v", project)
+	}
+	if _, ok := (Ownership{UserID: 2}).Labels()[LabelCostCenter]; ok {
+		t.Fatal("objects without a project have no cost center")
+	}
+}
+
+func TestRelabelProjectPatchesNamespacesAndPVCs(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	stale := MergeLabels(Ownership{ProjectID: 1}.Labels(), map[string]string{
+		LabelCostCenter:            "old-grant",
+		CostTagLabelPrefix + "lab": "vision",
+		"team":                     "kept",
+	})
+	Clientset = k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "proj-1-bob", Labels: stale}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "proj-2-bob", Labels: Ownership{ProjectID: 2}.Labels()}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "project-1-data", Namespace: "project-1", Labels: Ownership{ProjectID: 1}.Labels()}},
+	)
+	ctx := context.Background()
+
+	want := ChargebackLabels("new-grant", nil)
+	updated, err := RelabelProject(ctx, 1, want)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updated) != 2 {
+		t.Fatalf("expected the namespace and PVC of project 1 to be updated, got %v", updated)
+	}
+
+	ns, _ := Clientset.CoreV1().Namespaces().Get(ctx, "proj-1-bob", metav1.GetOptions{})
+	if ns.Labels[LabelCostCenter] != "new-grant" || ns.Labels["team"] != "kept" || ns.Labels[LabelProjectID] != "1" {
+		t.Fatalf("unexpected namespace labels %v", ns.Labels)
+	}
+	if _, ok := ns.Labels[CostTagLabelPrefix+"lab"]; ok {
+		t.Fatalf("expected the dropped tag to be removed, got %v", ns.Labels)
+	}
+	pvc, _ := Clientset.CoreV1().PersistentVolumeClaims("project-1").Get(ctx, "project-1-data", metav1.GetOptions{})
+	if pvc.Labels[LabelCostCenter] != "new-grant" {
+		t.Fatalf("expected the PVC to be relabelled, got %v", pvc.Labels)
+	}
+	other, _ := Clientset.CoreV1().Namespaces().Get(ctx, "proj-2-bob", metav1.GetOptions{})
+	if _, ok := other.Labels[LabelCostCenter]; ok {
+		t.Fatalf("other projects must not be relabelled, got %v", other.Labels)
+	}
+
+	// Nothing is patched when the labels already match
+	if again, _ := RelabelProject(ctx, 1, want); len(again) != 0 {
+		t.Fatalf("expected no changes on a re-run, got %v", again)
+	}
+}
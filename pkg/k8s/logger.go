@@ -0,0 +1,5 @@
+package k8s
+
+import "github.com/linskybing/platform-go/pkg/logger"
+
+var k8sLog = logger.For(logger.ComponentK8s)
@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// maxLogLineBytes bounds one followed log line; a longer line ends the stream with bufio.ErrTooLong.
+const maxLogLineBytes = 64 * 1024
+
+// FollowPodLogs follows the log of one container from its last tailLines lines and calls onLine
+// with every sanitized line until ctx is done, the container exits or onLine fails. An empty
+// container selects the pod's only container.
+func FollowPodLogs(ctx context.Context, ns, pod, container string, tailLines int64, onLine func(line string) error) error {
+	if Clientset == nil {
+		return fmt.Errorf("k8s client not available")
+	}
+	opts := &corev1.PodLogOptions{Container: container, Follow: true}
+	if tailLines > 0 {
+		opts.TailLines = &tailLines
+	}
+	stream, err := Clientset.CoreV1().Pods(ns).GetLogs(pod, opts).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open log stream of %s: %w", pod, err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 4096), maxLogLineBytes)
+	for scanner.Scan() {
+		if err := onLine(SanitizeLog(scanner.Text())); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,164 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+const metricsGroupVersion = "metrics.k8s.io/v1beta1"
+
+// ErrMetricsUnavailable is returned when the cluster does not serve the metrics.k8s.io API,
+// typically because metrics-server is not installed.
+var ErrMetricsUnavailable = errors.New("metrics unavailable: metrics-server is not installed")
+
+// ContainerUsage is the current usage of one container as reported by metrics-server.
+type ContainerUsage struct {
+	CPUMillicores int64
+	MemoryBytes   int64
+}
+
+// PodMetricsSource reads live pod usage; the default implementation talks to metrics.k8s.io.
+type PodMetricsSource interface {
+	Available(ctx context.Context) (bool, error)
+	PodUsage(ctx context.Context, namespace, name string) (map[string]ContainerUsage, time.Time, error)
+}
+
+// Metrics is initialized by Init with a metrics.k8s.io client; tests swap it for a fake.
+var Metrics PodMetricsSource
+
+// ContainerResourceUsage pairs a container's live usage with the requests/limits from its spec.
+// Zero requests or limits mean the field is not set.
+type ContainerResourceUsage struct {
+	Name                 string `json:"name"`
+	CPUUsageMillicores   int64  `json:"cpu_usage_millicores"`
+	CPURequestMillicores int64  `json:"cpu_request_millicores"`
+	CPULimitMillicores   int64  `json:"cpu_limit_millicores"`
+	MemoryUsageBytes     int64  `json:"memory_usage_bytes"`
+	MemoryRequestBytes   int64  `json:"memory_request_bytes"`
+	MemoryLimitBytes     int64  `json:"memory_limit_bytes"`
+}
+
+type PodResourceUsage struct {
+	Namespace  string                   `json:"namespace"`
+	Pod        string                   `json:"pod"`
+	Timestamp  time.Time                `json:"timestamp"`
+	Containers []ContainerResourceUsage `json:"containers"`
+}
+
+type metricsClient struct {
+	disc discovery.DiscoveryInterface
+}
+
+// NewMetricsClient returns a PodMetricsSource that reads metrics.k8s.io through disc.
+func NewMetricsClient(disc discovery.DiscoveryInterface) PodMetricsSource {
+	return &metricsClient{disc: disc}
+}
+
+func (m *metricsClient) Available(ctx context.Context) (bool, error) {
+	list, err := m.disc.ServerResourcesForGroupVersion(metricsGroupVersion)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, r := range list.APIResources {
+		if r.Name == "pods" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *metricsClient) PodUsage(ctx context.Context, namespace, name string) (map[string]ContainerUsage, time.Time, error) {
+	raw, err := m.disc.RESTClient().Get().
+		AbsPath("/apis", metricsGroupVersion, "namespaces", namespace, "pods", name).
+		Do(ctx).Raw()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var pm struct {
+		Timestamp  metav1.Time `json:"timestamp"`
+		Containers []struct {
+			Name  string              `json:"name"`
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	}
+	if err := json.Unmarshal(raw, &pm); err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid pod metrics for %s/%s: %w", namespace, name, err)
+	}
+	usage := make(map[string]ContainerUsage, len(pm.Containers))
+	for _, c := range pm.Containers {
+		usage[c.Name] = ContainerUsage{
+			CPUMillicores: quantityMilli(c.Usage, corev1.ResourceCPU),
+			MemoryBytes:   quantityValue(c.Usage, corev1.ResourceMemory),
+		}
+	}
+	return usage, pm.Timestamp.Time, nil
+}
+
+// GetPodResourceUsage returns per-container CPU and memory usage of a pod next to the
+// requests and limits declared in its spec.
+func GetPodResourceUsage(ctx context.Context, namespace, name string) (*PodResourceUsage, error) {
+	if Clientset == nil {
+		return nil, fmt.Errorf("k8s client not available")
+	}
+	if Metrics == nil {
+		return nil, ErrMetricsUnavailable
+	}
+	ok, err := Metrics.Available(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover metrics API: %w", err)
+	}
+	if !ok {
+		return nil, ErrMetricsUnavailable
+	}
+
+	pod, err := Clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	usage, ts, err := Metrics.PodUsage(ctx, namespace, name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	// A pod that just started has no metrics sample yet; report its spec with zero usage
+	out := &PodResourceUsage{Namespace: namespace, Pod: name, Timestamp: ts}
+	for _, c := range pod.Spec.Containers {
+		u := usage[c.Name]
+		out.Containers = append(out.Containers, ContainerResourceUsage{
+			Name:                 c.Name,
+			CPUUsageMillicores:   u.CPUMillicores,
+			CPURequestMillicores: quantityMilli(c.Resources.Requests, corev1.ResourceCPU),
+			CPULimitMillicores:   quantityMilli(c.Resources.Limits, corev1.ResourceCPU),
+			MemoryUsageBytes:     u.MemoryBytes,
+			MemoryRequestBytes:   quantityValue(c.Resources.Requests, corev1.ResourceMemory),
+			MemoryLimitBytes:     quantityValue(c.Resources.Limits, corev1.ResourceMemory),
+		})
+	}
+	return out, nil
+}
+
+func quantityMilli(list corev1.ResourceList, name corev1.ResourceName) int64 {
+	if q, ok := list[name]; ok {
+		return q.MilliValue()
+	}
+	return 0
+}
+
+func quantityValue(list corev1.ResourceList, name corev1.ResourceName) int64 {
+	if q, ok := list[name]; ok {
+		return q.Value()
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeMetrics struct {
+	usage map[string]ContainerUsage
+	ts    time.Time
+}
+
+func (f *fakeMetrics) Available(context.Context) (bool, error) { return true, nil }
+
+func (f *fakeMetrics) PodUsage(context.Context, string, string) (map[string]ContainerUsage, time.Time, error) {
+	return f.usage, f.ts, nil
+}
+
+func withMetricsClients(t *testing.T, pod *corev1.Pod) *k8sfake.Clientset {
+	t.Helper()
+	origClient, origMetrics := Clientset, Metrics
+	t.Cleanup(func() { Clientset, Metrics = origClient, origMetrics })
+	cs := k8sfake.NewSimpleClientset(pod)
+	Clientset = cs
+	return cs
+}
+
+func TestGetPodResourceUsage(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "train-0", Namespace: "proj-3-alice"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "main", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("8Gi")},
+			}},
+			{Name: "sidecar"},
+		}},
+	}
+	withMetricsClients(t, pod)
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	Metrics = &fakeMetrics{ts: ts, usage: map[string]ContainerUsage{
+		"main": {CPUMillicores: 1500, MemoryBytes: 3 << 30},
+	}}
+
+	got, err := GetPodResourceUsage(context.Background(), "proj-3-alice", "train-0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Timestamp.Equal(ts) || len(got.Containers) != 2 {
+		t.Fatalf("unexpected usage %+v", got)
+	}
+	main := got.Containers[0]
+	if main.CPUUsageMillicores != 1500 || main.CPURequestMillicores != 2000 || main.CPULimitMillicores != 4000 {
+		t.Errorf("unexpected cpu figures %+v", main)
+	}
+	if main.MemoryUsageBytes != 3<<30 || main.MemoryRequestBytes != 4<<30 || main.MemoryLimitBytes != 8<<30 {
+		t.Errorf("unexpected memory figures %+v", main)
+	}
+	// Containers without a sample or resources are reported with zeros
+	if sc := got.Containers[1]; sc.Name != "sidecar" || sc.CPUUsageMillicores != 0 || sc.MemoryLimitBytes != 0 {
+		t.Errorf("unexpected sidecar figures %+v", sc)
+	}
+}
+
+func TestGetPodResourceUsageWithoutMetricsServer(t *testing.T) {
+	cs := withMetricsClients(t, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "ns"}})
+	// The fake discovery advertises no metrics.k8s.io group
+	Metrics = NewMetricsClient(cs.Discovery().(*fakediscovery.FakeDiscovery))
+
+	if _, err := GetPodResourceUsage(context.Background(), "ns", "p"); !errors.Is(err, ErrMetricsUnavailable) {
+		t.Fatalf("expected ErrMetricsUnavailable, got %v", err)
+	}
+}
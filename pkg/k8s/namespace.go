@@ -3,16 +3,20 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/linskybing/platform-go/internal/config"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func CreateNamespace(name string) error {
+// CreateNamespace creates a namespace with the given labels (may be nil).
+func CreateNamespace(name string, labels map[string]string) error {
 	if Clientset == nil {
-		fmt.Printf("[MOCK] create Namespace: %s successfully\n", name)
+		k8sLog.Debug("mock: created namespace", "namespace", name)
 		return nil
 	}
 	_, err := Clientset.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{})
@@ -22,7 +26,8 @@ func CreateNamespace(name string) error {
 
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
+			Name:   name,
+			Labels: labels,
 		},
 	}
 
@@ -32,13 +37,13 @@ func CreateNamespace(name string) error {
 		return fmt.Errorf("failed create namespace: %v", err)
 	}
 
-	fmt.Printf("create Namespace: %s successfully\n", name)
+	k8sLog.Info("created namespace", "namespace", name)
 	return nil
 }
 
 func DeleteNamespace(name string) error {
 	if Clientset == nil {
-		fmt.Printf("[MOCK] Deleted namespace: %s\n", name)
+		k8sLog.Debug("mock: deleted namespace", "namespace", name)
 		return nil
 	}
 	err := Clientset.CoreV1().Namespaces().Delete(context.TODO(), name, metav1.DeleteOptions{})
@@ -46,7 +51,7 @@ func DeleteNamespace(name string) error {
 		return fmt.Errorf("failed to delete namespace %s: %w", name, err)
 	}
 
-	fmt.Printf("Deleted namespace: %s\n", name)
+	k8sLog.Info("deleted namespace", "namespace", name)
 	return nil
 }
 
@@ -55,7 +60,7 @@ func EnsureNamespaceExists(nsName string) error {
 	if Clientset == nil {
 		// In unit tests or environments without a k8s client, behave as a no-op
 		// and assume the namespace exists / can be created.
-		fmt.Printf("[MOCK] ensure namespace exists: %s\n", nsName)
+		k8sLog.Debug("mock: ensured namespace exists", "namespace", nsName)
 		return nil
 	}
 
@@ -68,13 +73,20 @@ func EnsureNamespaceExists(nsName string) error {
 		return err
 	}
 
+	labels := map[string]string{
+		"managed-by": "gpu-platform",
+		"created-at": time.Now().Format("20060102-150405"),
+	}
+	for k, v := range ProjectNamespaceLabels(nsName) {
+		labels[k] = v
+	}
+	pid, _, _ := ParseProjectNamespace(nsName)
+	labels = MergeLabels(labels, Ownership{ProjectID: pid}.Labels())
+
 	newNs := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: nsName,
-			Labels: map[string]string{
-				"managed-by": "gpu-platform",
-				"created-at": time.Now().Format("20060102-150405"),
-			},
+			Name:   nsName,
+			Labels: labels,
 		},
 	}
 
@@ -99,3 +111,109 @@ func CheckNamespaceExists(name string) (bool, error) {
 func FormatNamespaceName(projectID uint, userName string) string {
 	return fmt.Sprintf("proj-%d-%s", projectID, userName)
 }
+
+// ParseProjectNamespace extracts the project ID and username from a proj-<pid>-<user> namespace.
+func ParseProjectNamespace(ns string) (uint, string, bool) {
+	parts := strings.SplitN(ns, "-", 3)
+	if len(parts) != 3 || parts[0] != "proj" || parts[2] == "" {
+		return 0, "", false
+	}
+	pid, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return uint(pid), parts[2], true
+}
+
+// ProjectNamespaceLabels returns the labels identifying a proj-<pid>-<user> namespace,
+// or nil if the name is not a project user namespace.
+func ProjectNamespaceLabels(ns string) map[string]string {
+	pid, _, ok := ParseProjectNamespace(ns)
+	if !ok {
+		return nil
+	}
+	return map[string]string{
+		"project-id": strconv.FormatUint(uint64(pid), 10),
+		"type":       "project-user",
+	}
+}
+
+// ListProjectNamespaces returns the user namespaces of a project via a server-side label selector.
+// While config.LegacyNamespaceFallback is enabled, unlabeled namespaces named proj-<pid>-* are included too.
+func ListProjectNamespaces(ctx context.Context, projectID uint) ([]corev1.Namespace, error) {
+	if Clientset == nil {
+		return []corev1.Namespace{}, nil
+	}
+
+	selector := fmt.Sprintf("project-id=%d,type=project-user", projectID)
+	labeled, err := Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	result := labeled.Items
+
+	if !config.LegacyNamespaceFallback {
+		return result, nil
+	}
+
+	seen := make(map[string]bool, len(result))
+	for _, ns := range result {
+		seen[ns.Name] = true
+	}
+
+	all, err := Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	for _, ns := range all.Items {
+		if seen[ns.Name] {
+			continue
+		}
+		if pid, _, ok := ParseProjectNamespace(ns.Name); ok && pid == projectID {
+			result = append(result, ns)
+		}
+	}
+	return result, nil
+}
+
+// BackfillNamespaceLabels adds the project labels to legacy proj-<pid>-<user> namespaces missing them.
+// It returns the names of the namespaces that were updated.
+func BackfillNamespaceLabels(ctx context.Context) ([]string, error) {
+	if Clientset == nil {
+		return []string{}, nil
+	}
+
+	all, err := Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	updated := []string{}
+	for i := range all.Items {
+		ns := &all.Items[i]
+		labels := ProjectNamespaceLabels(ns.Name)
+		if labels == nil {
+			continue
+		}
+
+		changed := false
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		for k, v := range labels {
+			if ns.Labels[k] != v {
+				ns.Labels[k] = v
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if _, err := Clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+			return updated, fmt.Errorf("failed to label namespace %s: %w", ns.Name, err)
+		}
+		updated = append(updated, ns.Name)
+	}
+	return updated, nil
+}
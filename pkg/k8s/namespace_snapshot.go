@@ -0,0 +1,111 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/linskybing/platform-go/pkg/k8s/resourceview"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ErrUnknownKind is returned when a snapshot asks for a resource that is not watched.
+var ErrUnknownKind = errors.New("unknown resource kind")
+
+// NamespaceGVRs are the resources the namespace watch streams and the snapshot lists.
+var NamespaceGVRs = []schema.GroupVersionResource{
+	{Group: "", Version: "v1", Resource: "pods"},
+	{Group: "", Version: "v1", Resource: "services"},
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+}
+
+// NamespaceSnapshot is the current state of the platform objects of a namespace, keyed by
+// resource ("pods", "services", ...). Each item has the fields of a watch event; the resource
+// versions let a client continue with a watch.
+type NamespaceSnapshot struct {
+	Namespace        string                              `json:"namespace"`
+	Resources        map[string][]map[string]interface{} `json:"resources"`
+	ResourceVersions map[string]string                   `json:"resourceVersions"`
+}
+
+// objectData is the document sent for obj by the watch and the snapshot. With a viewer, it
+// tells whether the viewer owns the object. Pods started from an allow-listed image carry the
+// approval behind it, and objects edited outside the platform are flagged externallyModified.
+func objectData(eventType string, obj *unstructured.Unstructured, viewer string) map[string]interface{} {
+	data := resourceview.BuildDataMap(eventType, obj, fetchPodEvents)
+	if IsExternallyModified(obj) {
+		data["externallyModified"] = true
+	}
+	if obj.GetKind() == "Pod" {
+		if p := ProvenanceFromAnnotations(obj.GetAnnotations()); p != nil {
+			data["imageProvenance"] = p
+		}
+	}
+	if viewer != "" {
+		data["mine"] = obj.GetLabels()[LabelUserID] == viewer
+	}
+	return data
+}
+
+// snapshotGVRs selects the watched resources named in kinds, or all of them when kinds is empty.
+func snapshotGVRs(kinds []string) ([]schema.GroupVersionResource, error) {
+	if len(kinds) == 0 {
+		return NamespaceGVRs, nil
+	}
+	var gvrs []schema.GroupVersionResource
+	for _, kind := range kinds {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		found := false
+		for _, gvr := range NamespaceGVRs {
+			if gvr.Resource == kind {
+				gvrs = append(gvrs, gvr)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKind, kind)
+		}
+	}
+	return gvrs, nil
+}
+
+// SnapshotNamespace lists the platform objects of namespace, one List per resource, in the
+// shape of the initial events of WatchNamespaceResourcesFor. kinds limits the resources; a
+// non-zero viewerID flags the objects the viewer owns.
+func SnapshotNamespace(ctx context.Context, namespace string, kinds []string, viewerID uint) (*NamespaceSnapshot, error) {
+	gvrs, err := snapshotGVRs(kinds)
+	if err != nil {
+		return nil, err
+	}
+	if DynamicClient == nil {
+		return nil, errors.New("kubernetes dynamic client not initialized")
+	}
+	viewer := ""
+	if viewerID != 0 {
+		viewer = strconv.FormatUint(uint64(viewerID), 10)
+	}
+
+	snap := &NamespaceSnapshot{
+		Namespace:        namespace,
+		Resources:        make(map[string][]map[string]interface{}, len(gvrs)),
+		ResourceVersions: make(map[string]string, len(gvrs)),
+	}
+	for _, gvr := range gvrs {
+		list, err := DynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: ManagedSelector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
+		}
+		items := make([]map[string]interface{}, 0, len(list.Items))
+		for i := range list.Items {
+			items = append(items, objectData("ADDED", &list.Items[i], viewer))
+		}
+		snap.Resources[gvr.Resource] = items
+		snap.ResourceVersions[gvr.Resource] = list.GetResourceVersion()
+	}
+	return snap, nil
+}
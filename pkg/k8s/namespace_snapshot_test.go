@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func snapshotObjects() []runtime.Object {
+	mine := runningPod("train-0", "Running")
+	mine.SetLabels(Ownership{ProjectID: 1, UserID: 5}.Labels())
+	_ = unstructured.SetNestedSlice(mine.Object, []interface{}{
+		map[string]interface{}{"name": "main", "image": "pytorch:2"},
+	}, "spec", "containers")
+	unmanaged := runningPod("stray", "Running")
+	svc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "ns"},
+		"spec": map[string]interface{}{
+			"ports": []interface{}{map[string]interface{}{"port": int64(80), "protocol": "TCP"}},
+		},
+	}}
+	svc.SetLabels(Ownership{ProjectID: 1, UserID: 6}.Labels())
+	return []runtime.Object{mine, unmanaged, svc}
+}
+
+func useSnapshotClient(t *testing.T) {
+	t.Helper()
+	orig := DynamicClient
+	t.Cleanup(func() { DynamicClient = orig })
+	DynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		podsGVR:                               "PodList",
+		{Version: "v1", Resource: "services"}: "ServiceList",
+		{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+	}, snapshotObjects()...)
+}
+
+func TestSnapshotNamespaceListsManagedObjects(t *testing.T) {
+	useSnapshotClient(t)
+
+	snap, err := SnapshotNamespace(context.Background(), "ns", nil, 5)
+	if err != nil {
+		t.Fatalf("SnapshotNamespace: %v", err)
+	}
+	if len(snap.Resources) != len(NamespaceGVRs) {
+		t.Fatalf("expected every watched resource, got %v", snap.Resources)
+	}
+	pods := snap.Resources["pods"]
+	if len(pods) != 1 || pods[0]["name"] != "train-0" || pods[0]["mine"] != true {
+		t.Fatalf("expected only the managed pod, flagged as the viewer's, got %v", pods)
+	}
+	if svcs := snap.Resources["services"]; len(svcs) != 1 || svcs[0]["mine"] != false {
+		t.Fatalf("expected the service of another user, got %v", svcs)
+	}
+	if deps := snap.Resources["deployments"]; deps == nil || len(deps) != 0 {
+		t.Fatalf("expected an empty deployment list, got %v", deps)
+	}
+
+	filtered, err := SnapshotNamespace(context.Background(), "ns", []string{"Services"}, 0)
+	if err != nil {
+		t.Fatalf("SnapshotNamespace: %v", err)
+	}
+	if _, ok := filtered.Resources["pods"]; ok || len(filtered.Resources["services"]) != 1 {
+		t.Fatalf("expected only services, got %v", filtered.Resources)
+	}
+	if _, ok := filtered.Resources["services"][0]["mine"]; ok {
+		t.Fatal("expected no owner flag without a viewer")
+	}
+
+	if _, err := SnapshotNamespace(context.Background(), "ns", []string{"pods", "secrets"}, 0); !errors.Is(err, ErrUnknownKind) {
+		t.Fatalf("expected ErrUnknownKind, got %v", err)
+	}
+}
+
+func TestSnapshotMatchesWatchPayload(t *testing.T) {
+	useSnapshotClient(t)
+	snap, err := SnapshotNamespace(context.Background(), "ns", []string{"pods"}, 5)
+	if err != nil {
+		t.Fatalf("SnapshotNamespace: %v", err)
+	}
+	raw, _ := json.Marshal(snap.Resources["pods"][0])
+	var fromSnapshot map[string]interface{}
+	_ = json.Unmarshal(raw, &fromSnapshot)
+
+	ch := make(chan []byte, 1)
+	sender := newWatchSender(ch)
+	sender.viewer = "5"
+	st := newGVRStream(context.Background(), podsGVR, sender)
+	if err := st.sendObject("ADDED", snapshotObjects()[0].(*unstructured.Unstructured)); err != nil {
+		t.Fatalf("sendObject: %v", err)
+	}
+	var fromWatch map[string]interface{}
+	_ = json.Unmarshal(<-ch, &fromWatch)
+
+	if !reflect.DeepEqual(fieldSet(fromSnapshot), fieldSet(fromWatch)) {
+		t.Fatalf("field sets differ:\nsnapshot %v\nwatch    %v", fieldSet(fromSnapshot), fieldSet(fromWatch))
+	}
+	if !reflect.DeepEqual(fromSnapshot, fromWatch) {
+		t.Fatalf("payloads differ:\nsnapshot %v\nwatch    %v", fromSnapshot, fromWatch)
+	}
+}
+
+func TestObjectDataSurfacesImageProvenance(t *testing.T) {
+	pod := runningPod("train-0", "Running")
+	approvedAt := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	pod.SetAnnotations(ImageProvenance{RequestID: 9, ApprovedBy: 4, ApprovedAt: approvedAt}.Annotations())
+
+	p, ok := objectData("ADDED", pod, "")["imageProvenance"].(*ImageProvenance)
+	if !ok || p.RequestID != 9 || p.ApprovedBy != 4 || !p.ApprovedAt.Equal(approvedAt) {
+		t.Fatalf("expected the provenance of the pod, got %v", p)
+	}
+	if _, ok := objectData("ADDED", runningPod("stray", "Running"), "")["imageProvenance"]; ok {
+		t.Fatal("expected no provenance without the annotations")
+	}
+}
+
+// fieldSet lists the paths of the fields of a decoded JSON document.
+func fieldSet(doc map[string]interface{}) map[string]bool {
+	set := map[string]bool{}
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			set[prefix+k] = true
+			if nested, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".", nested)
+			}
+		}
+	}
+	walk("", doc)
+	return set
+}
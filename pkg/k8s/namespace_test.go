@@ -0,0 +1,92 @@
+package k8s
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func namespaceNames(items []corev1.Namespace) []string {
+	names := make([]string, 0, len(items))
+	for _, ns := range items {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestListProjectNamespaces(t *testing.T) {
+	origClient, origFallback := Clientset, config.LegacyNamespaceFallback
+	defer func() { Clientset, config.LegacyNamespaceFallback = origClient, origFallback }()
+
+	Clientset = k8sfake.NewSimpleClientset(
+		newNamespace("proj-1-alice", ProjectNamespaceLabels("proj-1-alice")),
+		newNamespace("proj-1-bob", nil), // legacy, unlabeled
+		newNamespace("proj-10-carol", ProjectNamespaceLabels("proj-10-carol")),
+		newNamespace("myproj-1-dave", nil), // would match a substring filter
+	)
+	ctx := context.Background()
+
+	config.LegacyNamespaceFallback = false
+	items, err := ListProjectNamespaces(ctx, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := namespaceNames(items); len(got) != 1 || got[0] != "proj-1-alice" {
+		t.Fatalf("expected only labeled namespace, got %v", got)
+	}
+
+	config.LegacyNamespaceFallback = true
+	items, err = ListProjectNamespaces(ctx, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := namespaceNames(items); len(got) != 2 || got[0] != "proj-1-alice" || got[1] != "proj-1-bob" {
+		t.Fatalf("expected labeled and legacy namespace, got %v", got)
+	}
+}
+
+func TestBackfillNamespaceLabels(t *testing.T) {
+	origClient, origFallback := Clientset, config.LegacyNamespaceFallback
+	defer func() { Clientset, config.LegacyNamespaceFallback = origClient, origFallback }()
+
+	Clientset = k8sfake.NewSimpleClientset(
+		newNamespace("proj-1-alice", ProjectNamespaceLabels("proj-1-alice")),
+		newNamespace("proj-1-bob", map[string]string{"managed-by": "gpu-platform"}),
+		newNamespace("kube-system", nil),
+	)
+	ctx := context.Background()
+
+	updated, err := BackfillNamespaceLabels(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updated) != 1 || updated[0] != "proj-1-bob" {
+		t.Fatalf("expected only proj-1-bob to be labeled, got %v", updated)
+	}
+
+	ns, _ := Clientset.CoreV1().Namespaces().Get(ctx, "proj-1-bob", metav1.GetOptions{})
+	if ns.Labels["project-id"] != "1" || ns.Labels["managed-by"] != "gpu-platform" {
+		t.Fatalf("unexpected labels after backfill: %v", ns.Labels)
+	}
+
+	config.LegacyNamespaceFallback = false
+	items, _ := ListProjectNamespaces(ctx, 1)
+	if len(items) != 2 {
+		t.Fatalf("expected both namespaces to be found by label after backfill, got %v", namespaceNames(items))
+	}
+
+	updated, _ = BackfillNamespaceLabels(ctx)
+	if len(updated) != 0 {
+		t.Fatalf("expected second backfill to be a no-op, got %v", updated)
+	}
+}
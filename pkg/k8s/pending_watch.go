@@ -0,0 +1,186 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linskybing/platform-go/pkg/k8s/resourceview"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// unschedulableThreshold is how long a pod may stay Pending before its scheduling failure is reported
+	unschedulableThreshold = 1 * time.Minute
+	// unschedulableReemitInterval limits how often the same pod is reported again
+	unschedulableReemitInterval = 5 * time.Minute
+	// pendingCheckInterval is how often watchers look for pods past the threshold
+	pendingCheckInterval = 30 * time.Second
+)
+
+var insufficientPattern = regexp.MustCompile(`Insufficient ([^\s,.]+(?:\.[^\s,.]+)*)`)
+
+type pendingPod struct {
+	since    time.Time
+	reported time.Time
+}
+
+// pendingTracker remembers, per pod name, since when a watched pod has been waiting to be
+// scheduled. The watch stream deduplicates identical statuses, so a pod stuck in Pending
+// produces a single event; the tracker lets the watcher report the reason later.
+type pendingTracker struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	threshold time.Duration
+	interval  time.Duration
+	pods      map[string]*pendingPod
+}
+
+func newPendingTracker(now func() time.Time) *pendingTracker {
+	return &pendingTracker{
+		now:       now,
+		threshold: unschedulableThreshold,
+		interval:  unschedulableReemitInterval,
+		pods:      make(map[string]*pendingPod),
+	}
+}
+
+// observe updates the state from a watch event. Scheduled and deleted pods are forgotten.
+func (t *pendingTracker) observe(eventType string, obj *unstructured.Unstructured) {
+	if obj.GetKind() != "Pod" {
+		return
+	}
+	name := obj.GetName()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if eventType == "DELETED" || !waitingForScheduling(obj) {
+		delete(t.pods, name)
+		return
+	}
+	if _, ok := t.pods[name]; !ok {
+		t.pods[name] = &pendingPod{since: t.now()}
+	}
+}
+
+func (t *pendingTracker) forget(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pods, name)
+}
+
+// due returns the pods pending past the threshold that were not reported within the interval.
+func (t *pendingTracker) due() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	var names []string
+	for name, p := range t.pods {
+		if now.Sub(p.since) < t.threshold {
+			continue
+		}
+		if !p.reported.IsZero() && now.Sub(p.reported) < t.interval {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func (t *pendingTracker) markReported(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.pods[name]; ok {
+		p.reported = t.now()
+	}
+}
+
+// emitUnschedulable re-reads the due pods and pushes a synthetic MODIFIED event with status
+// "Unschedulable" for those the scheduler could not place.
+func (t *pendingTracker) emitUnschedulable(ctx context.Context, dynClient dynamic.Interface, gvr schema.GroupVersionResource, ns string, push func([]byte) error) {
+	for _, name := range t.due() {
+		obj, err := dynClient.Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				t.forget(name)
+			}
+			continue
+		}
+		if !waitingForScheduling(obj) {
+			t.forget(name)
+			continue
+		}
+		reason, message := podScheduledCondition(obj)
+		if reason != "Unschedulable" {
+			continue
+		}
+
+		data := resourceview.BuildDataMap("MODIFIED", obj, fetchPodEvents)
+		data["status"] = "Unschedulable"
+		data["statusReason"] = summarizeUnschedulable(message)
+		data["statusMessage"] = message
+		msg, err := json.Marshal(data)
+		if err != nil {
+			continue
+		}
+		if err := push(msg); err == nil {
+			t.markReported(name)
+		}
+	}
+}
+
+// waitingForScheduling is true for Pending pods that are not yet bound to a node.
+func waitingForScheduling(obj *unstructured.Unstructured) bool {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase != "" && phase != "Pending" {
+		return false
+	}
+	if node, _, _ := unstructured.NestedString(obj.Object, "spec", "nodeName"); node != "" {
+		return false
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		if m, ok := c.(map[string]interface{}); ok && m["type"] == "PodScheduled" && m["status"] == "True" {
+			return false
+		}
+	}
+	return true
+}
+
+func podScheduledCondition(obj *unstructured.Unstructured) (reason, message string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != "PodScheduled" {
+			continue
+		}
+		reason, _ = m["reason"].(string)
+		message, _ = m["message"].(string)
+		return reason, message
+	}
+	return "", ""
+}
+
+// summarizeUnschedulable turns the scheduler message, e.g. "0/3 nodes are available: 3 Insufficient
+// nvidia.com/gpu.", into a short reason such as "insufficient nvidia.com/gpu".
+func summarizeUnschedulable(message string) string {
+	matches := insufficientPattern.FindAllStringSubmatch(message, -1)
+	if len(matches) == 0 {
+		return message
+	}
+	seen := map[string]bool{}
+	var resources []string
+	for _, m := range matches {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			resources = append(resources, m[1])
+		}
+	}
+	return "insufficient " + strings.Join(resources, ", ")
+}
@@ -0,0 +1,104 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func pendingPodObject(name string, conditions ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "ns"},
+		"status":     map[string]interface{}{"phase": "Pending", "conditions": conditions},
+	}}
+}
+
+var unschedulableCondition = map[string]interface{}{
+	"type":    "PodScheduled",
+	"status":  "False",
+	"reason":  "Unschedulable",
+	"message": "0/3 nodes are available: 3 Insufficient nvidia.com/gpu.",
+}
+
+func TestPendingTrackerThreshold(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	tr := newPendingTracker(clock.now)
+
+	tr.observe("ADDED", pendingPodObject("train"))
+	if due := tr.due(); len(due) != 0 {
+		t.Fatalf("pod reported before threshold: %v", due)
+	}
+
+	// A repeated identical event must not reset the pending start
+	clock.t = clock.t.Add(unschedulableThreshold / 2)
+	tr.observe("MODIFIED", pendingPodObject("train"))
+	clock.t = clock.t.Add(unschedulableThreshold / 2)
+	if due := tr.due(); len(due) != 1 || due[0] != "train" {
+		t.Fatalf("expected train due at threshold, got %v", due)
+	}
+
+	tr.markReported("train")
+	clock.t = clock.t.Add(unschedulableReemitInterval - time.Second)
+	if due := tr.due(); len(due) != 0 {
+		t.Fatalf("pod re-reported within interval: %v", due)
+	}
+	clock.t = clock.t.Add(time.Second)
+	if due := tr.due(); len(due) != 1 {
+		t.Fatalf("expected re-report after interval, got %v", due)
+	}
+
+	scheduled := pendingPodObject("train", map[string]interface{}{"type": "PodScheduled", "status": "True"})
+	tr.observe("MODIFIED", scheduled)
+	if due := tr.due(); len(due) != 0 {
+		t.Fatalf("scheduled pod still tracked: %v", due)
+	}
+
+	tr.observe("ADDED", pendingPodObject("eval"))
+	tr.observe("DELETED", pendingPodObject("eval"))
+	clock.t = clock.t.Add(unschedulableThreshold)
+	if due := tr.due(); len(due) != 0 {
+		t.Fatalf("deleted pod still tracked: %v", due)
+	}
+}
+
+func TestEmitUnschedulable(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	tr := newPendingTracker(clock.now)
+	pod := pendingPodObject("train", unschedulableCondition)
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+	var sent []map[string]interface{}
+	push := func(msg []byte) error {
+		var data map[string]interface{}
+		if err := json.Unmarshal(msg, &data); err != nil {
+			t.Fatalf("invalid message: %v", err)
+		}
+		sent = append(sent, data)
+		return nil
+	}
+
+	tr.observe("ADDED", pod)
+	clock.t = clock.t.Add(unschedulableThreshold)
+	tr.emitUnschedulable(context.Background(), dyn, gvr, "ns", push)
+	tr.emitUnschedulable(context.Background(), dyn, gvr, "ns", push)
+
+	if len(sent) != 1 {
+		t.Fatalf("expected one synthetic event, got %d", len(sent))
+	}
+	if sent[0]["type"] != "MODIFIED" || sent[0]["status"] != "Unschedulable" || sent[0]["statusReason"] != "insufficient nvidia.com/gpu" {
+		t.Fatalf("unexpected event %v", sent[0])
+	}
+}
@@ -0,0 +1,212 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// maxFailureReasons is how many distinct reasons ExplainPodFailure reports.
+const maxFailureReasons = 3
+
+// podReadyPollInterval is how often WaitForPodReady checks the pod; tests shorten it.
+var podReadyPollInterval = 2 * time.Second
+
+// PodNotReadyError is returned when a pod did not become Ready within the wait window. Reasons
+// explains why, as returned by ExplainPodFailure.
+type PodNotReadyError struct {
+	Namespace string
+	Pod       string
+	Reasons   []string
+}
+
+func (e *PodNotReadyError) Error() string {
+	msg := fmt.Sprintf("pod %s/%s is not ready", e.Namespace, e.Pod)
+	if e.Pod == "" {
+		msg = fmt.Sprintf("no pod is ready in %s", e.Namespace)
+	}
+	if len(e.Reasons) > 0 {
+		msg += ": " + strings.Join(e.Reasons, "; ")
+	}
+	return msg
+}
+
+// ExplainPodFailure returns the most recent distinct reasons, as "Reason: message", why a pod is
+// not running. They come from the warning events of the pod and of the PVCs it mounts, such as
+// FailedScheduling, FailedAttachVolume or ProvisioningFailed, and from the waiting or terminated
+// state of its containers when no event explains it.
+func ExplainPodFailure(ctx context.Context, ns, podName string) []string {
+	if Clientset == nil || podName == "" {
+		return nil
+	}
+	names := []string{podName}
+	pod, err := Clientset.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		pod = nil
+	} else {
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil {
+				names = append(names, v.PersistentVolumeClaim.ClaimName)
+			}
+		}
+	}
+
+	reasons := explainEvents(ctx, ns, names)
+	if len(reasons) == 0 && pod != nil {
+		reasons = containerStateReasons(pod)
+	}
+	return reasons
+}
+
+// explainEvents summarizes the warning events of the named objects, newest first.
+func explainEvents(ctx context.Context, ns string, names []string) []string {
+	wanted := make(map[string]bool, len(names))
+	var events []corev1.Event
+	for _, name := range names {
+		if wanted[name] {
+			continue
+		}
+		wanted[name] = true
+		opts := metav1.ListOptions{FieldSelector: fmt.Sprintf("involvedObject.name=%s", name)}
+		list, err := Clientset.CoreV1().Events(ns).List(ctx, opts)
+		if err != nil {
+			continue
+		}
+		for _, e := range list.Items {
+			if e.InvolvedObject.Name == name && e.Type != corev1.EventTypeNormal {
+				events = append(events, e)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return eventTime(events[i]).After(eventTime(events[j])) })
+
+	seen := map[string]bool{}
+	var reasons []string
+	for _, e := range events {
+		if seen[e.Reason] {
+			continue
+		}
+		seen[e.Reason] = true
+		reasons = append(reasons, formatReason(e.Reason, e.Message))
+		if len(reasons) == maxFailureReasons {
+			break
+		}
+	}
+	return reasons
+}
+
+// eventTime is when an event was last seen, whichever of its timestamps is set.
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	case !e.FirstTimestamp.IsZero():
+		return e.FirstTimestamp.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+func containerStateReasons(pod *corev1.Pod) []string {
+	seen := map[string]bool{}
+	var reasons []string
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		reason, message := "", ""
+		switch {
+		case cs.State.Waiting != nil:
+			reason, message = cs.State.Waiting.Reason, cs.State.Waiting.Message
+		case cs.State.Terminated != nil && cs.State.Terminated.ExitCode != 0:
+			reason, message = cs.State.Terminated.Reason, cs.State.Terminated.Message
+		}
+		if reason == "" || seen[reason] {
+			continue
+		}
+		seen[reason] = true
+		reasons = append(reasons, formatReason(reason, message))
+		if len(reasons) == maxFailureReasons {
+			break
+		}
+	}
+	return reasons
+}
+
+func formatReason(reason, message string) string {
+	if message = strings.TrimSpace(message); message == "" {
+		return reason
+	}
+	return reason + ": " + message
+}
+
+// WaitForPodReady waits up to timeout for the pod to be Ready. When it is not, the returned
+// *PodNotReadyError explains why. A timeout of 0 does not wait.
+func WaitForPodReady(ctx context.Context, ns, podName string, timeout time.Duration) error {
+	return waitForPod(ctx, timeout, func() (*corev1.Pod, error) {
+		return Clientset.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
+	}, func() *PodNotReadyError {
+		return &PodNotReadyError{Namespace: ns, Pod: podName, Reasons: ExplainPodFailure(ctx, ns, podName)}
+	})
+}
+
+// WaitForSelectedPodReady is WaitForPodReady for the pod of a workload, found by label selector.
+// While the workload has no pod yet, the events of the fallback objects, such as the deployment
+// and its PVC, explain why.
+func WaitForSelectedPodReady(ctx context.Context, ns, selector string, timeout time.Duration, fallback ...string) error {
+	var last string
+	return waitForPod(ctx, timeout, func() (*corev1.Pod, error) {
+		pods, err := Clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil || len(pods.Items) == 0 {
+			return nil, err
+		}
+		for i := range pods.Items {
+			if isPodReady(&pods.Items[i]) {
+				return &pods.Items[i], nil
+			}
+		}
+		last = pods.Items[0].Name
+		return &pods.Items[0], nil
+	}, func() *PodNotReadyError {
+		if last != "" {
+			return &PodNotReadyError{Namespace: ns, Pod: last, Reasons: ExplainPodFailure(ctx, ns, last)}
+		}
+		return &PodNotReadyError{Namespace: ns, Reasons: explainEvents(ctx, ns, fallback)}
+	})
+}
+
+func waitForPod(ctx context.Context, timeout time.Duration, get func() (*corev1.Pod, error), explain func() *PodNotReadyError) error {
+	if Clientset == nil || timeout <= 0 {
+		return nil
+	}
+	ready := false
+	_ = wait.PollUntilContextTimeout(ctx, podReadyPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := get()
+		if err != nil || pod == nil {
+			return false, nil
+		}
+		ready = isPodReady(pod)
+		return ready || pod.Status.Phase == corev1.PodFailed, nil
+	})
+	if ready {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return explain()
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
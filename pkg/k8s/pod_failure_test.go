@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func podEvent(ns, kind, name, eventType, reason, message string, ago time.Duration) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name + "." + reason, Namespace: ns},
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Name: name, Namespace: ns},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		LastTimestamp:  metav1.NewTime(time.Now().Add(-ago)),
+	}
+}
+
+func storagePod(ns, name, pvcName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "data-0",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName}},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+}
+
+func TestExplainPodFailureReportsRecentDistinctReasons(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	ns := "proj-1"
+	Clientset = k8sfake.NewSimpleClientset(
+		storagePod(ns, "filebrowser-project", "project-1-disk"),
+		podEvent(ns, "Pod", "filebrowser-project", corev1.EventTypeWarning, "FailedMount", "timed out waiting for the condition", 40*time.Minute),
+		podEvent(ns, "Pod", "filebrowser-project", corev1.EventTypeWarning, "FailedScheduling", "0/3 nodes are available", 30*time.Minute),
+		podEvent(ns, "Pod", "filebrowser-project", corev1.EventTypeNormal, "Scheduled", "assigned to node-1", time.Minute),
+		podEvent(ns, "Pod", "filebrowser-project", corev1.EventTypeWarning, "FailedAttachVolume", "Multi-Attach error for volume pvc-1", 2*time.Minute),
+		podEvent(ns, "PersistentVolumeClaim", "project-1-disk", corev1.EventTypeWarning, "ProvisioningFailed", "storageclass not found", 10*time.Second),
+		podEvent(ns, "Pod", "other-pod", corev1.EventTypeWarning, "BackOff", "restarting failed container", 0),
+	)
+
+	got := ExplainPodFailure(context.Background(), ns, "filebrowser-project")
+	want := []string{
+		"ProvisioningFailed: storageclass not found",
+		"FailedAttachVolume: Multi-Attach error for volume pvc-1",
+		"FailedScheduling: 0/3 nodes are available",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExplainPodFailure() = %q, want %q", got, want)
+	}
+}
+
+func TestExplainPodFailureFallsBackToContainerState(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	pod := storagePod("proj-1", "filebrowser-project", "project-1-disk")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "filebrowser",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+	}}
+	Clientset = k8sfake.NewSimpleClientset(pod)
+
+	got := ExplainPodFailure(context.Background(), "proj-1", "filebrowser-project")
+	if want := []string{"ImagePullBackOff: Back-off pulling image"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExplainPodFailure() = %q, want %q", got, want)
+	}
+}
+
+func TestWaitForPodReady(t *testing.T) {
+	orig, origPoll := Clientset, podReadyPollInterval
+	defer func() { Clientset, podReadyPollInterval = orig, origPoll }()
+	podReadyPollInterval = 5 * time.Millisecond
+	ns := "proj-1"
+	ready := storagePod(ns, "ready", "disk")
+	ready.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	Clientset = k8sfake.NewSimpleClientset(
+		ready,
+		storagePod(ns, "stuck", "disk"),
+		podEvent(ns, "Pod", "stuck", corev1.EventTypeWarning, "FailedAttachVolume", "volume is attached to another node", time.Second),
+	)
+	ctx := context.Background()
+
+	if err := WaitForPodReady(ctx, ns, "ready", time.Second); err != nil {
+		t.Fatalf("unexpected error for a ready pod: %v", err)
+	}
+
+	err := WaitForPodReady(ctx, ns, "stuck", 30*time.Millisecond)
+	var notReady *PodNotReadyError
+	if !errors.As(err, &notReady) {
+		t.Fatalf("expected a PodNotReadyError, got %v", err)
+	}
+	if want := []string{"FailedAttachVolume: volume is attached to another node"}; !reflect.DeepEqual(notReady.Reasons, want) {
+		t.Fatalf("reasons = %q, want %q", notReady.Reasons, want)
+	}
+
+	if err := WaitForPodReady(ctx, ns, "stuck", 0); err != nil {
+		t.Fatalf("a zero timeout should not wait, got %v", err)
+	}
+}
+
+func TestEnsureUserStorageHubReportsWhyThePodDidNotStart(t *testing.T) {
+	orig, origWait, origPoll := Clientset, config.StorageReadyTimeout, podReadyPollInterval
+	defer func() { Clientset, config.StorageReadyTimeout, podReadyPollInterval = orig, origWait, origPoll }()
+	config.StorageReadyTimeout, podReadyPollInterval = 30*time.Millisecond, 5*time.Millisecond
+
+	spec := UserHubSpec{Namespace: "user-alice-storage", PVCName: "user-alice-disk", StorageClassName: "longhorn", Size: "10Gi"}
+	deployName := StorageHubDeploymentName(spec.PVCName)
+	Clientset = k8sfake.NewSimpleClientset(
+		podEvent(spec.Namespace, "PersistentVolumeClaim", spec.PVCName, corev1.EventTypeWarning, "ProvisioningFailed", "exceeded quota", time.Second),
+		podEvent(spec.Namespace, "Deployment", deployName, corev1.EventTypeWarning, "FailedCreate", "pods is forbidden", 2*time.Second),
+	)
+
+	status := EnsureUserStorageHub(context.Background(), spec)
+	if status.Ready || status.Err() == nil {
+		t.Fatalf("expected the hub to be reported not ready, got %+v", status)
+	}
+	for _, c := range status.Components {
+		if c.Component != HubDeployment {
+			continue
+		}
+		want := []string{"ProvisioningFailed: exceeded quota", "FailedCreate: pods is forbidden"}
+		if c.Status != HubComponentFailed || !reflect.DeepEqual(c.Reasons, want) {
+			t.Fatalf("deployment = %+v, want failed with %q", c, want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package k8s
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// NewPodPortForwardDialer builds an SPDY dialer for the pod's portforward subresource.
+func NewPodPortForwardDialer(config *rest.Config, clientset *kubernetes.Clientset, namespace, podName string) (httpstream.Dialer, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spdy round tripper: %w", err)
+	}
+
+	req := clientset.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("portforward")
+
+	return spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL()), nil
+}
+
+// PortForwardTunnel copies a single TCP stream to a pod port over a WebSocket.
+// Data is framed as binary WebSocket messages in both directions.
+type PortForwardTunnel struct {
+	conn        *websocket.Conn
+	streamConn  httpstream.Connection
+	dataStream  httpstream.Stream
+	idleTimeout time.Duration
+	lastActive  atomic.Int64
+	mu          sync.Mutex // Protects concurrent writes (Ping vs Data)
+	once        sync.Once
+	done        chan struct{}
+	err         error
+}
+
+// PortForwardViaWebSocket dials the pod and tunnels one stream to the given port.
+// It blocks until either side closes, an error occurs, or the tunnel is idle longer than idleTimeout.
+func PortForwardViaWebSocket(conn *websocket.Conn, dialer httpstream.Dialer, port int, idleTimeout time.Duration) error {
+	streamConn, _, err := dialer.Dial(portforward.PortForwardProtocolV1Name)
+	if err != nil {
+		return fmt.Errorf("failed to dial pod: %w", err)
+	}
+
+	headers := http.Header{}
+	headers.Set(corev1.StreamType, corev1.StreamTypeError)
+	headers.Set(corev1.PortHeader, strconv.Itoa(port))
+	headers.Set(corev1.PortForwardRequestIDHeader, "0")
+	errorStream, err := streamConn.CreateStream(headers)
+	if err != nil {
+		_ = streamConn.Close()
+		return fmt.Errorf("failed to create error stream: %w", err)
+	}
+	// We only read from the error stream
+	_ = errorStream.Close()
+
+	headers.Set(corev1.StreamType, corev1.StreamTypeData)
+	dataStream, err := streamConn.CreateStream(headers)
+	if err != nil {
+		_ = streamConn.Close()
+		return fmt.Errorf("failed to create data stream: %w", err)
+	}
+
+	t := &PortForwardTunnel{
+		conn:        conn,
+		streamConn:  streamConn,
+		dataStream:  dataStream,
+		idleTimeout: idleTimeout,
+		done:        make(chan struct{}),
+	}
+	t.touch()
+
+	go t.watchErrorStream(errorStream)
+	go t.podToClient()
+	go t.clientToPod()
+	go t.keepAlive()
+
+	<-t.done
+	return t.err
+}
+
+func (t *PortForwardTunnel) touch() {
+	t.lastActive.Store(time.Now().UnixNano())
+}
+
+// Close tears down both sides of the tunnel. Safe to call multiple times.
+func (t *PortForwardTunnel) Close(err error) {
+	t.once.Do(func() {
+		t.err = err
+		_ = t.dataStream.Close()
+		_ = t.streamConn.Close()
+		t.mu.Lock()
+		_ = t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		t.mu.Unlock()
+		_ = t.conn.Close()
+		close(t.done)
+	})
+}
+
+func (t *PortForwardTunnel) watchErrorStream(errorStream httpstream.Stream) {
+	buf := make([]byte, 1024)
+	n, _ := errorStream.Read(buf)
+	if n > 0 {
+		t.Close(fmt.Errorf("port forward error: %s", string(buf[:n])))
+	}
+}
+
+// podToClient forwards bytes read from the pod as binary messages
+func (t *PortForwardTunnel) podToClient() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.dataStream.Read(buf)
+		if n > 0 {
+			t.touch()
+			t.mu.Lock()
+			_ = t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			werr := t.conn.WriteMessage(websocket.BinaryMessage, buf[:n])
+			t.mu.Unlock()
+			if werr != nil {
+				t.Close(nil)
+				return
+			}
+		}
+		if err != nil {
+			t.Close(nil)
+			return
+		}
+	}
+}
+
+// clientToPod forwards WebSocket messages into the pod stream
+func (t *PortForwardTunnel) clientToPod() {
+	const pongWait = 60 * time.Second
+
+	t.conn.SetReadLimit(512 * 1024)
+	_ = t.conn.SetReadDeadline(time.Now().Add(pongWait))
+	t.conn.SetPongHandler(func(string) error {
+		return t.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		msgType, message, err := t.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure, websocket.CloseAbnormalClosure) {
+				log.Printf("port forward websocket error: %v", err)
+			}
+			t.Close(nil)
+			return
+		}
+		_ = t.conn.SetReadDeadline(time.Now().Add(pongWait))
+		if msgType != websocket.BinaryMessage && msgType != websocket.TextMessage {
+			continue
+		}
+		t.touch()
+		if _, err := t.dataStream.Write(message); err != nil {
+			t.Close(fmt.Errorf("failed to write to pod: %w", err))
+			return
+		}
+	}
+}
+
+// keepAlive pings the client and closes the tunnel once it has been idle too long
+func (t *PortForwardTunnel) keepAlive() {
+	ticker := time.NewTicker(pingInterval(t.idleTimeout))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-t.streamConn.CloseChan():
+			t.Close(nil)
+			return
+		case <-ticker.C:
+			if t.idleTimeout > 0 && time.Since(time.Unix(0, t.lastActive.Load())) > t.idleTimeout {
+				log.Printf("closing idle port forward tunnel after %s", t.idleTimeout)
+				t.Close(nil)
+				return
+			}
+			t.mu.Lock()
+			_ = t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			err := t.conn.WriteMessage(websocket.PingMessage, nil)
+			t.mu.Unlock()
+			if err != nil {
+				t.Close(nil)
+				return
+			}
+		}
+	}
+}
+
+func pingInterval(idleTimeout time.Duration) time.Duration {
+	const maxInterval = 50 * time.Second
+	if idleTimeout > 0 && idleTimeout/2 < maxInterval {
+		return idleTimeout / 2
+	}
+	return maxInterval
+}
@@ -0,0 +1,170 @@
+package k8s
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// echoStream returns whatever is written to it
+type echoStream struct {
+	headers http.Header
+	pr      *io.PipeReader
+	pw      *io.PipeWriter
+}
+
+func newEchoStream(headers http.Header) *echoStream {
+	pr, pw := io.Pipe()
+	return &echoStream{headers: headers, pr: pr, pw: pw}
+}
+
+func (s *echoStream) Read(p []byte) (int, error)  { return s.pr.Read(p) }
+func (s *echoStream) Write(p []byte) (int, error) { return s.pw.Write(p) }
+func (s *echoStream) Close() error {
+	_ = s.pw.Close()
+	return s.pr.Close()
+}
+func (s *echoStream) Reset() error         { return s.Close() }
+func (s *echoStream) Headers() http.Header { return s.headers }
+func (s *echoStream) Identifier() uint32   { return 0 }
+
+// blockingStream never produces data until the connection closes
+type blockingStream struct {
+	*echoStream
+}
+
+func (s *blockingStream) Close() error { return nil }
+
+type fakeStreamConn struct {
+	mu      sync.Mutex
+	streams []*echoStream
+	closeCh chan bool
+	once    sync.Once
+}
+
+func (c *fakeStreamConn) CreateStream(headers http.Header) (httpstream.Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := newEchoStream(headers.Clone())
+	c.streams = append(c.streams, s)
+	if headers.Get(corev1.StreamType) == corev1.StreamTypeError {
+		return &blockingStream{s}, nil
+	}
+	return s, nil
+}
+
+func (c *fakeStreamConn) Close() error {
+	c.once.Do(func() {
+		c.mu.Lock()
+		for _, s := range c.streams {
+			_ = s.Close()
+		}
+		c.mu.Unlock()
+		close(c.closeCh)
+	})
+	return nil
+}
+
+func (c *fakeStreamConn) CloseChan() <-chan bool             { return c.closeCh }
+func (c *fakeStreamConn) SetIdleTimeout(time.Duration)       {}
+func (c *fakeStreamConn) RemoveStreams(...httpstream.Stream) {}
+
+type fakeDialer struct {
+	conn *fakeStreamConn
+}
+
+func (d *fakeDialer) Dial(protocols ...string) (httpstream.Connection, string, error) {
+	return d.conn, protocols[0], nil
+}
+
+func startPortForwardServer(t *testing.T, dialer httpstream.Dialer, idle time.Duration) (*httptest.Server, chan error) {
+	t.Helper()
+	errCh := make(chan error, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		errCh <- PortForwardViaWebSocket(conn, dialer, 8888, idle)
+	}))
+	return srv, errCh
+}
+
+func TestPortForwardViaWebSocketEchoesBytes(t *testing.T) {
+	streamConn := &fakeStreamConn{closeCh: make(chan bool)}
+	srv, errCh := startPortForwardServer(t, &fakeDialer{conn: streamConn}, time.Minute)
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	payload := []byte("GET / HTTP/1.1\r\n\r\n")
+	if err := client.WriteMessage(websocket.BinaryMessage, payload); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	msgType, got, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if msgType != websocket.BinaryMessage || string(got) != string(payload) {
+		t.Fatalf("expected echoed binary payload, got type %d %q", msgType, got)
+	}
+
+	streamConn.mu.Lock()
+	if len(streamConn.streams) != 2 {
+		t.Fatalf("expected error and data streams, got %d", len(streamConn.streams))
+	}
+	if port := streamConn.streams[1].headers.Get(corev1.PortHeader); port != "8888" {
+		t.Fatalf("expected port header 8888, got %s", port)
+	}
+	streamConn.mu.Unlock()
+
+	_ = client.Close()
+	select {
+	case <-errCh:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("tunnel did not shut down after client closed")
+	}
+	select {
+	case <-streamConn.CloseChan():
+	default:
+		t.Fatalf("expected stream connection to be closed")
+	}
+}
+
+func TestPortForwardViaWebSocketIdleTimeout(t *testing.T) {
+	streamConn := &fakeStreamConn{closeCh: make(chan bool)}
+	srv, errCh := startPortForwardServer(t, &fakeDialer{conn: streamConn}, 200*time.Millisecond)
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	select {
+	case <-errCh:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("idle tunnel was not closed")
+	}
+
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatalf("expected client connection to be closed")
+	}
+}
@@ -0,0 +1,74 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PriorityClassSpec describes a PriorityClass the platform expects to exist.
+type PriorityClassSpec struct {
+	Name             string
+	Value            int32
+	PreemptionPolicy corev1.PreemptionPolicy
+	Description      string
+}
+
+// EnsurePriorityClasses creates any of the given PriorityClasses that are missing.
+// Existing classes are left untouched (value is immutable in Kubernetes), so the call is idempotent.
+// It returns the names of the classes that were created.
+func EnsurePriorityClasses(ctx context.Context, specs []PriorityClassSpec) ([]string, error) {
+	if Clientset == nil {
+		k8sLog.Debug("mock: ensured priority classes", "count", len(specs))
+		return nil, nil
+	}
+
+	created := []string{}
+	for _, spec := range specs {
+		_, err := Clientset.SchedulingV1().PriorityClasses().Get(ctx, spec.Name, metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return created, fmt.Errorf("failed to get priority class %s: %w", spec.Name, err)
+		}
+
+		policy := spec.PreemptionPolicy
+		pc := &schedulingv1.PriorityClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: spec.Name,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "nthu-cscc",
+				},
+			},
+			Value:            spec.Value,
+			PreemptionPolicy: &policy,
+			Description:      spec.Description,
+		}
+
+		if _, err := Clientset.SchedulingV1().PriorityClasses().Create(ctx, pc, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			return created, fmt.Errorf("failed to create priority class %s: %w", spec.Name, err)
+		}
+		created = append(created, spec.Name)
+	}
+	return created, nil
+}
+
+// ListPriorityClasses returns all PriorityClasses in the cluster.
+func ListPriorityClasses(ctx context.Context) ([]schedulingv1.PriorityClass, error) {
+	if Clientset == nil {
+		return []schedulingv1.PriorityClass{}, nil
+	}
+	list, err := Clientset.SchedulingV1().PriorityClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEnsurePriorityClassesIdempotent(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset()
+
+	specs := []PriorityClassSpec{
+		{Name: "low-priority", Value: 100, PreemptionPolicy: corev1.PreemptNever},
+		{Name: "high-priority", Value: 1000, PreemptionPolicy: corev1.PreemptLowerPriority},
+	}
+
+	created, err := EnsurePriorityClasses(context.Background(), specs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(created) != 2 {
+		t.Fatalf("expected 2 classes created, got %v", created)
+	}
+
+	pc, err := Clientset.SchedulingV1().PriorityClasses().Get(context.Background(), "low-priority", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected low-priority to exist: %v", err)
+	}
+	if pc.Value != 100 || pc.PreemptionPolicy == nil || *pc.PreemptionPolicy != corev1.PreemptNever {
+		t.Fatalf("unexpected priority class: %+v", pc)
+	}
+
+	created, err = EnsurePriorityClasses(context.Background(), specs)
+	if err != nil {
+		t.Fatalf("unexpected error on second run: %v", err)
+	}
+	if len(created) != 0 {
+		t.Fatalf("expected no classes created on second run, got %v", created)
+	}
+
+	items, err := ListPriorityClasses(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected list error: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 classes, got %d", len(items))
+	}
+}
@@ -0,0 +1,66 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ProjectStorageNameLabel groups project PVCs by their storage name.
+	ProjectStorageNameLabel = "storage-name"
+	// DefaultProjectStorage is the storage name of the project's original "project-{pid}-disk" PVC.
+	DefaultProjectStorage = "disk"
+	// ProjectStorageSelector matches every platform-managed project storage PVC.
+	ProjectStorageSelector = "storage-type=project," + ManagedSelector
+)
+
+var projectPVCPrefix = regexp.MustCompile(`^project-\d+-`)
+
+// ProjectStoragePVCName returns the PVC name of a named project storage: project-{pid}-{storageName}.
+func ProjectStoragePVCName(projectID uint, storageName string) string {
+	if storageName == "" {
+		storageName = DefaultProjectStorage
+	}
+	return fmt.Sprintf("project-%d-%s", projectID, storageName)
+}
+
+// ProjectStorageName returns the storage name of a project PVC.
+// PVCs created before storage names were labelled fall back to the name suffix.
+func ProjectStorageName(pvc *corev1.PersistentVolumeClaim) string {
+	if name := pvc.Labels[ProjectStorageNameLabel]; name != "" {
+		return name
+	}
+	return StorageNameFromPVCName(pvc.Name)
+}
+
+// StorageNameFromPVCName strips the "project-{pid}-" prefix from a PVC name.
+func StorageNameFromPVCName(pvcName string) string {
+	if name := projectPVCPrefix.ReplaceAllString(pvcName, ""); name != "" {
+		return name
+	}
+	return pvcName
+}
+
+// ListProjectStoragePVCs returns the project storage PVCs in a namespace, ordered by storage name.
+func ListProjectStoragePVCs(ctx context.Context, ns string) ([]corev1.PersistentVolumeClaim, error) {
+	if Clientset == nil {
+		k8sLog.Debug("mock: listed project storages", "namespace", ns)
+		return nil, nil
+	}
+	list, err := Clientset.CoreV1().PersistentVolumeClaims(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: ProjectStorageSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pvcs := list.Items
+	sort.Slice(pvcs, func(i, j int) bool {
+		return ProjectStorageName(&pvcs[i]) < ProjectStorageName(&pvcs[j])
+	})
+	return pvcs, nil
+}
@@ -0,0 +1,159 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	imageref "github.com/linskybing/platform-go/pkg/image"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dockerHubHost is the registry host used for images without an explicit registry.
+const dockerHubHost = "docker.io"
+
+// RegistryAuth is one registry login in a docker config.
+type RegistryAuth struct {
+	Registry string
+	Username string
+	Password string
+}
+
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// NormalizeRegistryHost lowercases a registry host and strips the scheme and any path, so
+// "https://GHCR.io/" and "ghcr.io" compare equal. The Docker Hub aliases map to docker.io.
+func NormalizeRegistryHost(registry string) string {
+	host := strings.ToLower(strings.TrimSpace(registry))
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return dockerHubHost
+	}
+	return host
+}
+
+// ImageRegistryHost returns the normalized registry host of an image reference. References
+// without a registry, such as "nginx" or "pytorch/pytorch:2.1", live on Docker Hub; invalid
+// references have no host.
+func ImageRegistryHost(image string) string {
+	ref, err := imageref.Parse(image)
+	if err != nil {
+		return ""
+	}
+	return ref.Registry
+}
+
+// dockerConfigKey is the key of a registry in the auths map. Docker Hub clients look up the
+// legacy index URL.
+func dockerConfigKey(host string) string {
+	if host == dockerHubHost {
+		return "https://index.docker.io/v1/"
+	}
+	return host
+}
+
+// DockerConfigJSON builds a .dockerconfigjson document holding auths on top of base, an
+// existing docker config whose other registries are kept. An entry of auths replaces the base
+// entry for the same registry.
+func DockerConfigJSON(base []byte, auths []RegistryAuth) ([]byte, error) {
+	doc := map[string]json.RawMessage{}
+	if len(base) > 0 {
+		if err := json.Unmarshal(base, &doc); err != nil {
+			return nil, fmt.Errorf("invalid base docker config: %w", err)
+		}
+	}
+	entries := map[string]json.RawMessage{}
+	if raw, ok := doc["auths"]; ok {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, fmt.Errorf("invalid auths in base docker config: %w", err)
+		}
+	}
+	for _, a := range auths {
+		entry, err := json.Marshal(dockerConfigEntry{
+			Username: a.Username,
+			Password: a.Password,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries[dockerConfigKey(NormalizeRegistryHost(a.Registry))] = entry
+	}
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	doc["auths"] = raw
+	return json.Marshal(doc)
+}
+
+// GetDockerConfig returns the .dockerconfigjson of a pull secret, or nil when it does not exist.
+func GetDockerConfig(ctx context.Context, ns, name string) ([]byte, error) {
+	if Clientset == nil {
+		return nil, nil
+	}
+	secret, err := Clientset.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s/%s: %w", ns, name, err)
+	}
+	return secret.Data[corev1.DockerConfigJsonKey], nil
+}
+
+// EnsureDockerConfigSecret creates the image pull secret or replaces its docker config.
+func EnsureDockerConfigSecret(ctx context.Context, ns, name string, dockerConfig []byte, labels map[string]string) error {
+	if Clientset == nil {
+		k8sLog.Debug("mock: ensured pull secret", "namespace", ns, "name", name)
+		return nil
+	}
+
+	secrets := Clientset.CoreV1().Secrets(ns)
+	data := map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig}
+	merged := MergeLabels(MergeLabels(nil, labels), Ownership{}.Labels())
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		existing.Data = data
+		existing.Labels = merged
+		if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update secret %s/%s: %w", ns, name, err)
+		}
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret %s/%s: %w", ns, name, err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: merged},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       data,
+	}
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create secret %s/%s: %w", ns, name, err)
+	}
+	return nil
+}
+
+// DeleteSecretIfExists deletes a secret, treating an already missing one as deleted.
+func DeleteSecretIfExists(ctx context.Context, ns, name string) error {
+	if Clientset == nil {
+		return nil
+	}
+	err := Clientset.CoreV1().Secrets(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete secret %s/%s: %w", ns, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package k8s
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestImageRegistryHost(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                         "docker.io",
+		"pytorch/pytorch:2.1":           "docker.io",
+		"docker.io/library/nginx":       "docker.io",
+		"index.docker.io/library/nginx": "docker.io",
+		"ghcr.io/lab/trainer:v1":        "ghcr.io",
+		"Registry.Lab.edu:5000/app":     "registry.lab.edu:5000",
+		"localhost/app":                 "localhost",
+	}
+	for image, want := range cases {
+		if got := ImageRegistryHost(image); got != want {
+			t.Errorf("ImageRegistryHost(%q) = %q, want %q", image, got, want)
+		}
+	}
+	if got := NormalizeRegistryHost("https://GHCR.io/"); got != "ghcr.io" {
+		t.Errorf("expected the scheme and path to be stripped, got %q", got)
+	}
+}
+
+func TestDockerConfigJSONMergesAuths(t *testing.T) {
+	base := []byte(`{"auths":{"harbor.local":{"auth":"aGFyYm9yOnB3"},"ghcr.io":{"auth":"b2xkOm9sZA=="}},"credsStore":"none"}`)
+	out, err := DockerConfigJSON(base, []RegistryAuth{
+		{Registry: "ghcr.io", Username: "bot", Password: "tok"},
+		{Registry: "registry-1.docker.io", Username: "hub", Password: "pw"},
+	})
+	if err != nil {
+		t.Fatalf("DockerConfigJSON failed: %v", err)
+	}
+
+	var doc struct {
+		Auths      map[string]dockerConfigEntry `json:"auths"`
+		CredsStore string                       `json:"credsStore"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("invalid docker config: %v", err)
+	}
+	if doc.CredsStore != "none" {
+		t.Fatalf("expected other base keys to be kept, got %s", out)
+	}
+	if doc.Auths["harbor.local"].Auth != "aGFyYm9yOnB3" {
+		t.Fatalf("expected the base registry to be kept, got %s", out)
+	}
+	ghcr := doc.Auths["ghcr.io"]
+	if ghcr.Username != "bot" || ghcr.Auth != base64.StdEncoding.EncodeToString([]byte("bot:tok")) {
+		t.Fatalf("expected the project credential to replace the base entry, got %+v", ghcr)
+	}
+	if hub, ok := doc.Auths["https://index.docker.io/v1/"]; !ok || hub.Password != "pw" {
+		t.Fatalf("expected Docker Hub under the index URL, got %s", out)
+	}
+}
+
+func TestDockerConfigJSONWithoutBase(t *testing.T) {
+	out, err := DockerConfigJSON(nil, []RegistryAuth{{Registry: "quay.io", Username: "u", Password: "p"}})
+	if err != nil {
+		t.Fatalf("DockerConfigJSON failed: %v", err)
+	}
+	var doc map[string]map[string]dockerConfigEntry
+	if err := json.Unmarshal(out, &doc); err != nil || doc["auths"]["quay.io"].Username != "u" {
+		t.Fatalf("unexpected docker config %s (%v)", out, err)
+	}
+	if _, err := DockerConfigJSON([]byte("not json"), nil); err == nil {
+		t.Fatal("expected an invalid base config to be rejected")
+	}
+}
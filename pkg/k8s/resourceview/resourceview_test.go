@@ -0,0 +1,121 @@
+package resourceview
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testPod(phase string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":              "train-0",
+			"namespace":         "proj-1-alice",
+			"creationTimestamp": "2026-03-02T10:00:00Z",
+			"labels":            map[string]interface{}{"app": "train"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "main", "image": "pytorch:2"},
+				map[string]interface{}{"name": "sidecar", "image": "busybox"},
+			},
+		},
+		"status": map[string]interface{}{
+			"phase": phase,
+			"containerStatuses": []interface{}{
+				map[string]interface{}{"name": "main", "restartCount": int64(2)},
+				map[string]interface{}{"name": "sidecar", "restartCount": int64(1),
+					"state": map[string]interface{}{"waiting": map[string]interface{}{"reason": "CrashLoopBackOff"}}},
+			},
+		},
+	}}
+}
+
+func TestBuildDataMapPod(t *testing.T) {
+	var asked string
+	events := func(ns, name string) []map[string]interface{} {
+		asked = ns + "/" + name
+		return []map[string]interface{}{{"reason": "BackOff"}}
+	}
+	data := BuildDataMap("MODIFIED", testPod("Running"), events)
+
+	if data["type"] != "MODIFIED" || data["kind"] != "Pod" || data["name"] != "train-0" || data["ns"] != "proj-1-alice" {
+		t.Fatalf("unexpected identity fields %v", data)
+	}
+	if data["status"] != "CrashLoopBackOff" || !reflect.DeepEqual(data["crashLoopContainers"], []string{"sidecar"}) {
+		t.Fatalf("expected the crash loop to be reported, got %v", data)
+	}
+	if !reflect.DeepEqual(data["containers"], []string{"main", "sidecar"}) || !reflect.DeepEqual(data["images"], []string{"pytorch:2", "busybox"}) {
+		t.Fatalf("unexpected containers %v %v", data["containers"], data["images"])
+	}
+	if data["restartCount"] != int64(3) {
+		t.Fatalf("expected 3 restarts, got %v", data["restartCount"])
+	}
+	if asked != "proj-1-alice/train-0" || len(data["events"].([]map[string]interface{})) != 1 {
+		t.Fatalf("expected the pod events, asked for %q", asked)
+	}
+	meta := data["metadata"].(map[string]interface{})
+	if meta["creationTimestamp"] != "2026-03-02T10:00:00Z" || meta["labels"].(map[string]string)["app"] != "train" {
+		t.Fatalf("unexpected metadata %v", meta)
+	}
+
+	if _, ok := BuildDataMap("MODIFIED", testPod("Running"), nil)["events"]; ok {
+		t.Fatal("expected no events without an event source")
+	}
+}
+
+func TestBuildDataMapLifecycleHints(t *testing.T) {
+	pod := testPod("Pending")
+	unstructured.RemoveNestedField(pod.Object, "status", "containerStatuses")
+	if s := BuildDataMap("ADDED", pod, nil)["status"]; s != "Creating" {
+		t.Fatalf("expected a pending pod to be added as Creating, got %v", s)
+	}
+	deleted := BuildDataMap("DELETED", &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Deployment", "metadata": map[string]interface{}{"name": "web"},
+	}}, nil)
+	if deleted["status"] != "Terminating" || deleted["metadata"].(map[string]interface{})["deletionTimestamp"] == nil {
+		t.Fatalf("expected a deleted object to be Terminating, got %v", deleted)
+	}
+}
+
+func TestBuildDataMapService(t *testing.T) {
+	svc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Service",
+		"metadata": map[string]interface{}{"name": "web", "namespace": "ns"},
+		"spec": map[string]interface{}{
+			"clusterIP":   "10.0.0.1",
+			"externalIPs": []interface{}{"192.168.1.5"},
+			"ports": []interface{}{
+				map[string]interface{}{"port": int64(80), "protocol": "TCP", "nodePort": int64(30080)},
+			},
+		},
+		"status": map[string]interface{}{"loadBalancer": map[string]interface{}{
+			"ingress": []interface{}{map[string]interface{}{"ip": "203.0.113.7"}},
+		}},
+	}}
+	data := BuildDataMap("ADDED", svc, nil)
+	if data["clusterIP"] != "10.0.0.1" || data["externalIP"] != "203.0.113.7" {
+		t.Fatalf("unexpected addresses %v", data)
+	}
+	if !reflect.DeepEqual(data["externalIPs"], []string{"192.168.1.5", "203.0.113.7"}) ||
+		!reflect.DeepEqual(data["nodePorts"], []int64{30080}) || !reflect.DeepEqual(data["ports"], []string{"80/TCP"}) {
+		t.Fatalf("unexpected ports %v %v %v", data["externalIPs"], data["nodePorts"], data["ports"])
+	}
+}
+
+func TestStatusSnapshotIgnoresUnrelatedChanges(t *testing.T) {
+	pod := testPod("Running")
+	before := StatusSnapshot(pod)
+	pod.SetAnnotations(map[string]string{"note": "x"})
+	if StatusSnapshot(pod) != before {
+		t.Fatal("an annotation change should not change the status snapshot")
+	}
+	_ = unstructured.SetNestedField(pod.Object, "Succeeded", "status", "phase")
+	unstructured.RemoveNestedField(pod.Object, "status", "containerStatuses")
+	if StatusSnapshot(pod) == before {
+		t.Fatal("a phase change should change the status snapshot")
+	}
+}
@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SchedulingPolicy holds the placement defaults of a project, such as the tolerations that let
+// course workloads onto the tainted course nodes. Pod specs keep the fields they define
+// themselves unless Force is set, in which case the policy wins: its tolerations are added, its
+// node selector keys override and its affinity replaces the pod's.
+type SchedulingPolicy struct {
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Affinity     *corev1.Affinity    `json:"affinity,omitempty"`
+	Force        bool                `json:"force,omitempty"`
+}
+
+// IsEmpty reports whether the policy injects nothing.
+func (p *SchedulingPolicy) IsEmpty() bool {
+	return p == nil || (len(p.Tolerations) == 0 && len(p.NodeSelector) == 0 && p.Affinity == nil)
+}
+
+// ApplySchedulingPolicy injects the policy into pod.
+func ApplySchedulingPolicy(pod *corev1.PodSpec, p *SchedulingPolicy) {
+	if p.IsEmpty() {
+		return
+	}
+
+	if len(p.Tolerations) > 0 {
+		switch {
+		case len(pod.Tolerations) == 0:
+			pod.Tolerations = append([]corev1.Toleration(nil), p.Tolerations...)
+		case p.Force:
+			for _, t := range p.Tolerations {
+				if !hasToleration(pod.Tolerations, t) {
+					pod.Tolerations = append(pod.Tolerations, t)
+				}
+			}
+		}
+	}
+
+	if len(p.NodeSelector) > 0 {
+		switch {
+		case len(pod.NodeSelector) == 0:
+			pod.NodeSelector = maps.Clone(p.NodeSelector)
+		case p.Force:
+			pod.NodeSelector = maps.Clone(pod.NodeSelector)
+			maps.Copy(pod.NodeSelector, p.NodeSelector)
+		}
+	}
+
+	if p.Affinity != nil && (pod.Affinity == nil || p.Force) {
+		pod.Affinity = p.Affinity.DeepCopy()
+	}
+}
+
+func hasToleration(list []corev1.Toleration, t corev1.Toleration) bool {
+	for _, existing := range list {
+		if reflect.DeepEqual(existing, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// schedulingFields are the pod spec keys a SchedulingPolicy touches.
+var schedulingFields = []string{"tolerations", "nodeSelector", "affinity"}
+
+// ApplySchedulingPolicyToMap injects the policy into a decoded pod spec, such as one found in a
+// config file manifest, with the same precedence as ApplySchedulingPolicy.
+func ApplySchedulingPolicyToMap(podSpec map[string]interface{}, p *SchedulingPolicy) error {
+	if p.IsEmpty() {
+		return nil
+	}
+
+	current := map[string]interface{}{}
+	for _, key := range schedulingFields {
+		if v, ok := podSpec[key]; ok {
+			current[key] = v
+		}
+	}
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var pod corev1.PodSpec
+	if err := json.Unmarshal(raw, &pod); err != nil {
+		return fmt.Errorf("invalid scheduling fields in pod spec: %w", err)
+	}
+
+	ApplySchedulingPolicy(&pod, p)
+
+	raw, err = json.Marshal(corev1.PodSpec{Tolerations: pod.Tolerations, NodeSelector: pod.NodeSelector, Affinity: pod.Affinity})
+	if err != nil {
+		return err
+	}
+	var patched map[string]interface{}
+	if err := json.Unmarshal(raw, &patched); err != nil {
+		return err
+	}
+	for _, key := range schedulingFields {
+		if v, ok := patched[key]; ok {
+			podSpec[key] = v
+		}
+	}
+	return nil
+}
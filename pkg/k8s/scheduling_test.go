@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var courseToleration = corev1.Toleration{Key: "course", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule}
+
+func TestCreateJobInjectsSchedulingPolicy(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	Clientset = k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+
+	policy := &SchedulingPolicy{Tolerations: []corev1.Toleration{courseToleration}, NodeSelector: map[string]string{"pool": "course"}}
+	spec := JobSpec{Name: "hw1", Namespace: "proj-1-bob", Image: "python", Parallelism: 1, Completions: 1, Scheduling: policy}
+	if err := CreateJob(ctx, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	j, _ := Clientset.BatchV1().Jobs("proj-1-bob").Get(ctx, "hw1", metav1.GetOptions{})
+	pod := j.Spec.Template.Spec
+	if len(pod.Tolerations) != 1 || pod.Tolerations[0] != courseToleration || pod.NodeSelector["pool"] != "course" {
+		t.Fatalf("expected the course placement, got tolerations %v selector %v", pod.Tolerations, pod.NodeSelector)
+	}
+	if pod.Affinity != nil {
+		t.Fatalf("no affinity was configured, got %v", pod.Affinity)
+	}
+}
+
+func TestApplySchedulingPolicyPrecedence(t *testing.T) {
+	userToleration := corev1.Toleration{Key: "gpu", Operator: corev1.TolerationOpExists}
+	userAffinity := &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}}
+	policyAffinity := &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{}}
+	newPod := func() corev1.PodSpec {
+		return corev1.PodSpec{
+			Tolerations:  []corev1.Toleration{userToleration},
+			NodeSelector: map[string]string{"pool": "research", "zone": "a"},
+			Affinity:     userAffinity,
+		}
+	}
+	policy := &SchedulingPolicy{
+		Tolerations:  []corev1.Toleration{courseToleration},
+		NodeSelector: map[string]string{"pool": "course"},
+		Affinity:     policyAffinity,
+	}
+
+	pod := newPod()
+	ApplySchedulingPolicy(&pod, policy)
+	if len(pod.Tolerations) != 1 || pod.NodeSelector["pool"] != "research" || pod.Affinity != userAffinity {
+		t.Fatalf("explicit fields must win without force, got %+v", pod)
+	}
+
+	policy.Force = true
+	pod = newPod()
+	selector := pod.NodeSelector
+	ApplySchedulingPolicy(&pod, policy)
+	if len(pod.Tolerations) != 2 || pod.Tolerations[1] != courseToleration {
+		t.Fatalf("forced tolerations must be added to the pod's, got %v", pod.Tolerations)
+	}
+	if pod.NodeSelector["pool"] != "course" || pod.NodeSelector["zone"] != "a" {
+		t.Fatalf("forced selector keys must override, got %v", pod.NodeSelector)
+	}
+	if selector["pool"] != "research" {
+		t.Fatalf("the pod's selector map must not be modified in place, got %v", selector)
+	}
+	if pod.Affinity.PodAntiAffinity == nil || pod.Affinity.NodeAffinity != nil {
+		t.Fatalf("forced affinity must replace the pod's, got %+v", pod.Affinity)
+	}
+
+	ApplySchedulingPolicy(&pod, policy)
+	if len(pod.Tolerations) != 2 {
+		t.Fatalf("applying twice must not duplicate tolerations, got %v", pod.Tolerations)
+	}
+}
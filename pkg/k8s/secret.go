@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EnsureOpaqueSecret creates the secret or replaces its data and labels when it already exists.
+func EnsureOpaqueSecret(ctx context.Context, ns, name string, data map[string]string, labels map[string]string) error {
+	if Clientset == nil {
+		k8sLog.Debug("mock: ensured secret", "namespace", ns, "name", name, "keys", len(data))
+		return nil
+	}
+
+	merged := map[string]string{"app.kubernetes.io/managed-by": "nthu-cscc"}
+	for k, v := range labels {
+		merged[k] = v
+	}
+
+	secrets := Clientset.CoreV1().Secrets(ns)
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		existing.StringData = data
+		existing.Data = nil
+		existing.Labels = merged
+		if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update secret %s/%s: %w", ns, name, err)
+		}
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret %s/%s: %w", ns, name, err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    merged,
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: data,
+	}
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create secret %s/%s: %w", ns, name, err)
+	}
+	return nil
+}
+
+// CopySecretIfMissing copies a secret into another namespace unless it already exists there.
+func CopySecretIfMissing(ctx context.Context, srcNs, name, dstNs string) error {
+	if Clientset == nil {
+		k8sLog.Debug("mock: copied secret", "namespace", srcNs, "name", name, "target_namespace", dstNs)
+		return nil
+	}
+
+	if _, err := Clientset.CoreV1().Secrets(dstNs).Get(ctx, name, metav1.GetOptions{}); err == nil {
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret %s/%s: %w", dstNs, name, err)
+	}
+
+	src, err := Clientset.CoreV1().Secrets(srcNs).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get source secret %s/%s: %w", srcNs, name, err)
+	}
+
+	copied := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: dstNs,
+			Labels:    src.Labels,
+		},
+		Type: src.Type,
+		Data: src.Data,
+	}
+	if _, err := Clientset.CoreV1().Secrets(dstNs).Create(ctx, copied, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to copy secret %s to %s: %w", name, dstNs, err)
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ErrNoSFTPPort is returned when every port of the configured SFTP range is taken.
+var ErrNoSFTPPort = errors.New("no free SFTP port left")
+
+// SFTPPodName is the SFTP server pod of a user storage namespace.
+const SFTPPodName = "sftp-hub"
+
+const (
+	sftpServiceName = "sftp-hub-svc"
+	sftpKeysSecret  = "sftp-hub-keys"
+	sftpAppLabel    = "sftp-hub"
+	sftpPort        = 22
+	// sftpUID is the uid and gid of the SFTP login; atmoz/sftp refuses uid 0
+	sftpUID = 1000
+	// sftpKeysHashAnnotation records the authorized keys a running server was started with
+	sftpKeysHashAnnotation = "platform.nthu-cscc/sftp-keys-hash"
+	// sftpSharedIPKey lets every SFTP Service share the one configured LoadBalancer IP
+	sftpSharedIPKey = "metallb.universe.tf/allow-shared-ip"
+)
+
+// SFTPServerSpec describes the SFTP server of one user storage hub.
+type SFTPServerSpec struct {
+	Namespace string
+	PVCName   string
+	// Username is the SFTP login, a DNS label
+	Username string
+	// AuthorizedKeys are the OpenSSH public keys allowed to log in, one per entry
+	AuthorizedKeys []string
+	Labels         map[string]string
+}
+
+// SFTPEndpoint is where a started SFTP server is reachable from outside the cluster.
+type SFTPEndpoint struct {
+	Host     string `json:"host"`
+	Port     int32  `json:"port"`
+	Username string `json:"username"`
+}
+
+// BuildSFTPKeysSecret renders the authorized keys into the Secret mounted by the SFTP pod, one
+// file per key.
+func BuildSFTPKeysSecret(spec SFTPServerSpec) *corev1.Secret {
+	data := make(map[string][]byte, len(spec.AuthorizedKeys))
+	for i, key := range spec.AuthorizedKeys {
+		data[fmt.Sprintf("key-%d.pub", i)] = []byte(key + "\n")
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sftpKeysSecret,
+			Namespace: spec.Namespace,
+			Labels:    sftpLabels(spec.Labels),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+}
+
+// BuildSFTPPod returns the pod serving the hub PVC over SFTP. The user is chrooted to its home,
+// logs in with the mounted keys only, and finds the storage under data/.
+func BuildSFTPPod(spec SFTPServerSpec) *corev1.Pod {
+	home := "/home/" + spec.Username
+	keyMode := int32(0444)
+	fsGroup := int64(sftpUID)
+	probe := &corev1.Probe{
+		ProbeHandler:     corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(sftpPort)}},
+		PeriodSeconds:    10,
+		TimeoutSeconds:   1,
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SFTPPodName,
+			Namespace: spec.Namespace,
+			Labels:    sftpLabels(spec.Labels),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "sftp",
+				Image: config.SFTPImage,
+				// user:password:uid:gid; the empty password disables password logins
+				Args:           []string{fmt.Sprintf("%s::%d:%d", spec.Username, sftpUID, sftpUID)},
+				Ports:          []corev1.ContainerPort{{Name: "sftp", ContainerPort: sftpPort, Protocol: corev1.ProtocolTCP}},
+				ReadinessProbe: probe,
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "user-data", MountPath: home + "/data"},
+					{Name: "authorized-keys", MountPath: home + "/.ssh/keys", ReadOnly: true},
+				},
+			}},
+			Volumes: []corev1.Volume{
+				{
+					Name: "user-data",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: spec.PVCName},
+					},
+				},
+				{
+					Name: "authorized-keys",
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{SecretName: sftpKeysSecret, DefaultMode: &keyMode},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyAlways,
+			// fsGroup keeps the hub PVC writable for the non-root SFTP user
+			SecurityContext: &corev1.PodSecurityContext{FSGroup: &fsGroup},
+		},
+	}
+	// The image reads the keys at startup, so a key change must restart the pod
+	pod.Annotations = map[string]string{
+		SpecHashAnnotation:     PodSpecHash(pod.Spec),
+		sftpKeysHashAnnotation: sftpKeysHash(spec.AuthorizedKeys),
+	}
+	return pod
+}
+
+// EnsureSFTPServer starts the SFTP server of a user storage hub, or updates a running one. The
+// pod is recreated when its spec or authorized keys changed; the Service keeps its port.
+func EnsureSFTPServer(ctx context.Context, spec SFTPServerSpec) (*SFTPEndpoint, error) {
+	if Clientset == nil {
+		return nil, fmt.Errorf("k8s client not available")
+	}
+	if len(spec.AuthorizedKeys) == 0 {
+		return nil, fmt.Errorf("no authorized keys for %s", spec.Username)
+	}
+	if err := applySFTPKeysSecret(ctx, BuildSFTPKeysSecret(spec)); err != nil {
+		return nil, err
+	}
+
+	pod := BuildSFTPPod(spec)
+	pods := Clientset.CoreV1().Pods(spec.Namespace)
+	existing, err := pods.Get(ctx, SFTPPodName, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		if existing.DeletionTimestamp != nil ||
+			existing.Annotations[SpecHashAnnotation] != pod.Annotations[SpecHashAnnotation] ||
+			existing.Annotations[sftpKeysHashAnnotation] != pod.Annotations[sftpKeysHashAnnotation] {
+			if err := deletePodAndWait(ctx, spec.Namespace, SFTPPodName, config.FileBrowserDeleteTimeout); err != nil {
+				return nil, err
+			}
+			existing = nil
+		}
+	case apierrors.IsNotFound(err):
+		existing = nil
+	default:
+		return nil, fmt.Errorf("failed to get sftp pod: %w", err)
+	}
+	if existing == nil {
+		if _, err := pods.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to create sftp pod: %w", err)
+		}
+	}
+
+	port, err := ensureSFTPService(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	host := config.SFTPPublicHost
+	if host == "" {
+		host = config.SFTPLoadBalancerIP
+	}
+	return &SFTPEndpoint{Host: host, Port: port, Username: spec.Username}, nil
+}
+
+// DeleteSFTPServer removes the SFTP server of the hub in ns. Missing parts are skipped.
+func DeleteSFTPServer(ctx context.Context, ns string) error {
+	if Clientset == nil {
+		return fmt.Errorf("k8s client not available")
+	}
+	if err := Clientset.CoreV1().Services(ns).Delete(ctx, sftpServiceName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete sftp service: %w", err)
+	}
+	if err := Clientset.CoreV1().Pods(ns).Delete(ctx, SFTPPodName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete sftp pod: %w", err)
+	}
+	if err := Clientset.CoreV1().Secrets(ns).Delete(ctx, sftpKeysSecret, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete sftp keys: %w", err)
+	}
+	return nil
+}
+
+func sftpLabels(labels map[string]string) map[string]string {
+	return MergeLabels(MergeLabels(map[string]string{"app": sftpAppLabel}, labels), Ownership{}.Labels())
+}
+
+func sftpKeysHash(keys []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func applySFTPKeysSecret(ctx context.Context, secret *corev1.Secret) error {
+	secrets := Clientset.CoreV1().Secrets(secret.Namespace)
+	existing, err := secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+	} else if err == nil {
+		existing.Data = secret.Data
+		existing.Labels = secret.Labels
+		_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store sftp keys: %w", err)
+	}
+	return nil
+}
+
+// ensureSFTPService exposes the SFTP pod on a port of the configured range and returns it. An
+// existing Service keeps its port.
+func ensureSFTPService(ctx context.Context, spec SFTPServerSpec) (int32, error) {
+	services := Clientset.CoreV1().Services(spec.Namespace)
+	if svc, err := services.Get(ctx, sftpServiceName, metav1.GetOptions{}); err == nil {
+		return sftpServicePort(svc), nil
+	} else if !apierrors.IsNotFound(err) {
+		return 0, fmt.Errorf("failed to get sftp service: %w", err)
+	}
+
+	used, err := usedSFTPPorts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for port := int32(config.SFTPPortMin); port <= int32(config.SFTPPortMax); port++ {
+		if used[port] {
+			continue
+		}
+		created, err := services.Create(ctx, BuildSFTPService(spec, port), metav1.CreateOptions{})
+		if apierrors.IsInvalid(err) || apierrors.IsAlreadyExists(err) {
+			// Another server took the port since it was listed
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to create sftp service: %w", err)
+		}
+		return sftpServicePort(created), nil
+	}
+	return 0, ErrNoSFTPPort
+}
+
+// BuildSFTPService returns the Service exposing the SFTP pod on port, as a NodePort or on the
+// shared LoadBalancer IP depending on config.SFTPServiceType.
+func BuildSFTPService(spec SFTPServerSpec, port int32) *corev1.Service {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sftpServiceName,
+			Namespace: spec.Namespace,
+			Labels:    sftpLabels(spec.Labels),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": sftpAppLabel},
+			Ports: []corev1.ServicePort{{
+				Name:       "sftp",
+				Protocol:   corev1.ProtocolTCP,
+				TargetPort: intstr.FromInt(sftpPort),
+			}},
+		},
+	}
+	if strings.EqualFold(config.SFTPServiceType, string(corev1.ServiceTypeLoadBalancer)) {
+		svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+		svc.Spec.Ports[0].Port = port
+		if config.SFTPLoadBalancerIP != "" {
+			svc.Spec.LoadBalancerIP = config.SFTPLoadBalancerIP
+			svc.Annotations = map[string]string{sftpSharedIPKey: "platform-sftp"}
+		}
+		return svc
+	}
+	svc.Spec.Type = corev1.ServiceTypeNodePort
+	svc.Spec.Ports[0].Port = sftpPort
+	svc.Spec.Ports[0].NodePort = port
+	return svc
+}
+
+// sftpServicePort is the port users connect to: the node port, or the Service port on a LoadBalancer.
+func sftpServicePort(svc *corev1.Service) int32 {
+	if len(svc.Spec.Ports) == 0 {
+		return 0
+	}
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		return svc.Spec.Ports[0].Port
+	}
+	return svc.Spec.Ports[0].NodePort
+}
+
+// usedSFTPPorts collects the ports taken by the SFTP Services of all hubs.
+func usedSFTPPorts(ctx context.Context) (map[int32]bool, error) {
+	list, err := Clientset.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: "app=" + sftpAppLabel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sftp services: %w", err)
+	}
+	used := make(map[int32]bool, len(list.Items))
+	for i := range list.Items {
+		used[sftpServicePort(&list.Items[i])] = true
+	}
+	return used, nil
+}
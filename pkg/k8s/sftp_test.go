@@ -0,0 +1,152 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testSFTPSpec(keys ...string) SFTPServerSpec {
+	return SFTPServerSpec{
+		Namespace:      "user-alice-storage",
+		PVCName:        "user-alice-disk",
+		Username:       "alice",
+		AuthorizedKeys: keys,
+	}
+}
+
+func TestBuildSFTPPod(t *testing.T) {
+	spec := testSFTPSpec("ssh-ed25519 AAAA1 laptop", "ssh-ed25519 AAAA2")
+	pod := BuildSFTPPod(spec)
+
+	if pod.Name != SFTPPodName || pod.Namespace != "user-alice-storage" || pod.Labels["app"] != sftpAppLabel {
+		t.Fatalf("unexpected pod metadata %+v", pod.ObjectMeta)
+	}
+	c := pod.Spec.Containers[0]
+	if c.Image != config.SFTPImage || len(c.Args) != 1 || c.Args[0] != "alice::1000:1000" {
+		t.Fatalf("unexpected container %s %v", c.Image, c.Args)
+	}
+	if sc := pod.Spec.SecurityContext; sc == nil || sc.FSGroup == nil || *sc.FSGroup != 1000 {
+		t.Fatalf("expected fsGroup 1000 so the hub PVC stays writable, got %+v", sc)
+	}
+	if c.ReadinessProbe == nil || c.ReadinessProbe.TCPSocket == nil || c.ReadinessProbe.TCPSocket.Port.IntValue() != 22 {
+		t.Fatal("expected a TCP readiness probe on port 22")
+	}
+
+	mounts := map[string]corev1.VolumeMount{}
+	for _, m := range c.VolumeMounts {
+		mounts[m.Name] = m
+	}
+	if m := mounts["user-data"]; m.MountPath != "/home/alice/data" || m.ReadOnly {
+		t.Fatalf("hub PVC should be writable under the home, got %+v", m)
+	}
+	if m := mounts["authorized-keys"]; m.MountPath != "/home/alice/.ssh/keys" || !m.ReadOnly {
+		t.Fatalf("keys should be mounted read-only, got %+v", m)
+	}
+	for _, v := range pod.Spec.Volumes {
+		switch v.Name {
+		case "user-data":
+			if v.PersistentVolumeClaim == nil || v.PersistentVolumeClaim.ClaimName != "user-alice-disk" {
+				t.Fatalf("expected the hub PVC, got %+v", v.VolumeSource)
+			}
+		case "authorized-keys":
+			if v.Secret == nil || v.Secret.SecretName != sftpKeysSecret {
+				t.Fatalf("expected the keys secret, got %+v", v.VolumeSource)
+			}
+		}
+	}
+
+	secret := BuildSFTPKeysSecret(spec)
+	if len(secret.Data) != 2 || string(secret.Data["key-0.pub"]) != "ssh-ed25519 AAAA1 laptop\n" {
+		t.Fatalf("unexpected key files %v", secret.Data)
+	}
+
+	if BuildSFTPPod(testSFTPSpec("ssh-ed25519 AAAA3")).Annotations[sftpKeysHashAnnotation] == pod.Annotations[sftpKeysHashAnnotation] {
+		t.Fatal("other keys should change the keys hash")
+	}
+}
+
+func TestBuildSFTPServiceType(t *testing.T) {
+	origType, origIP := config.SFTPServiceType, config.SFTPLoadBalancerIP
+	t.Cleanup(func() { config.SFTPServiceType, config.SFTPLoadBalancerIP = origType, origIP })
+
+	config.SFTPServiceType = "NodePort"
+	svc := BuildSFTPService(testSFTPSpec(), 30050)
+	if svc.Spec.Type != corev1.ServiceTypeNodePort || svc.Spec.Ports[0].NodePort != 30050 || svc.Spec.Ports[0].Port != 22 {
+		t.Fatalf("unexpected NodePort service %+v", svc.Spec)
+	}
+
+	config.SFTPServiceType, config.SFTPLoadBalancerIP = "LoadBalancer", "10.0.0.50"
+	svc = BuildSFTPService(testSFTPSpec(), 30050)
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || svc.Spec.Ports[0].Port != 30050 || svc.Spec.LoadBalancerIP != "10.0.0.50" {
+		t.Fatalf("unexpected LoadBalancer service %+v", svc.Spec)
+	}
+	if svc.Annotations[sftpSharedIPKey] == "" {
+		t.Fatal("LoadBalancer services should share the configured IP")
+	}
+	if sftpServicePort(svc) != 30050 {
+		t.Fatalf("expected the service port to be reported, got %d", sftpServicePort(svc))
+	}
+}
+
+func TestEnsureSFTPServerAllocatesPortAndRestartsOnKeyChange(t *testing.T) {
+	origMin, origMax, origType, origHost := config.SFTPPortMin, config.SFTPPortMax, config.SFTPServiceType, config.SFTPPublicHost
+	t.Cleanup(func() {
+		config.SFTPPortMin, config.SFTPPortMax, config.SFTPServiceType, config.SFTPPublicHost = origMin, origMax, origType, origHost
+	})
+	config.SFTPPortMin, config.SFTPPortMax, config.SFTPServiceType, config.SFTPPublicHost = 30022, 30023, "NodePort", "sftp.example.org"
+
+	taken := BuildSFTPService(SFTPServerSpec{Namespace: "user-bob-storage"}, 30022)
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	fake := k8sfake.NewSimpleClientset(taken)
+	var podCreates int
+	fake.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		podCreates++
+		return false, nil, nil
+	})
+	Clientset = fake
+	ctx := context.Background()
+
+	endpoint, err := EnsureSFTPServer(ctx, testSFTPSpec("ssh-ed25519 AAAA1"))
+	if err != nil {
+		t.Fatalf("EnsureSFTPServer: %v", err)
+	}
+	if endpoint.Port != 30023 || endpoint.Host != "sftp.example.org" || endpoint.Username != "alice" {
+		t.Fatalf("expected the free port of the range, got %+v", endpoint)
+	}
+
+	// Same keys: the pod is reused
+	if _, err := EnsureSFTPServer(ctx, testSFTPSpec("ssh-ed25519 AAAA1")); err != nil || podCreates != 1 {
+		t.Fatalf("expected the running pod to be reused: %d creates, %v", podCreates, err)
+	}
+	// New keys: the pod restarts with them and the port stays
+	endpoint, err = EnsureSFTPServer(ctx, testSFTPSpec("ssh-ed25519 AAAA2"))
+	if err != nil || podCreates != 2 || endpoint.Port != 30023 {
+		t.Fatalf("expected a restart on the same port: %d creates, %+v, %v", podCreates, endpoint, err)
+	}
+	secret, _ := Clientset.CoreV1().Secrets("user-alice-storage").Get(ctx, sftpKeysSecret, metav1.GetOptions{})
+	if string(secret.Data["key-0.pub"]) != "ssh-ed25519 AAAA2\n" {
+		t.Fatalf("expected the keys secret to be updated, got %v", secret.Data)
+	}
+
+	// The range is used up for another user
+	_, err = EnsureSFTPServer(ctx, SFTPServerSpec{Namespace: "user-carol-storage", PVCName: "user-carol-disk", Username: "carol", AuthorizedKeys: []string{"ssh-ed25519 AAAA3"}})
+	if !errors.Is(err, ErrNoSFTPPort) {
+		t.Fatalf("expected ErrNoSFTPPort, got %v", err)
+	}
+
+	if err := DeleteSFTPServer(ctx, "user-alice-storage"); err != nil {
+		t.Fatalf("DeleteSFTPServer: %v", err)
+	}
+	if _, err := Clientset.CoreV1().Pods("user-alice-storage").Get(ctx, SFTPPodName, metav1.GetOptions{}); err == nil {
+		t.Fatal("expected the pod to be deleted")
+	}
+}
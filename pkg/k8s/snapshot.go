@@ -0,0 +1,126 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// SnapshotSourceLabel records the PVC a VolumeSnapshot was taken from.
+const SnapshotSourceLabel = "snapshot-source-pvc"
+
+// VolumeSnapshotGVR is the CSI snapshot API. There is no typed client for it, so snapshots
+// go through the dynamic client.
+var VolumeSnapshotGVR = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+
+// Snapshots is initialized by Init with the dynamic client; tests swap it for a fake.
+var Snapshots dynamic.Interface
+
+// ErrSnapshotsUnavailable is returned when no client for the snapshot API is configured.
+var ErrSnapshotsUnavailable = errors.New("volume snapshots unavailable")
+
+type VolumeSnapshotInfo struct {
+	Name        string    `json:"name"`
+	Namespace   string    `json:"namespace"`
+	SourcePVC   string    `json:"source_pvc"`
+	ReadyToUse  bool      `json:"ready_to_use"`
+	RestoreSize string    `json:"restore_size,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateVolumeSnapshot snapshots pvcName in ns with the given VolumeSnapshotClass.
+func CreateVolumeSnapshot(ctx context.Context, ns, name, pvcName, className string, labels map[string]string) (*VolumeSnapshotInfo, error) {
+	if Snapshots == nil {
+		return nil, ErrSnapshotsUnavailable
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[SnapshotSourceLabel] = pvcName
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": ns,
+		},
+		"spec": map[string]interface{}{
+			"volumeSnapshotClassName": className,
+			"source": map[string]interface{}{
+				"persistentVolumeClaimName": pvcName,
+			},
+		},
+	}}
+	obj.SetLabels(labels)
+
+	created, err := Snapshots.Resource(VolumeSnapshotGVR).Namespace(ns).Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot of %s/%s: %w", ns, pvcName, err)
+	}
+	info := volumeSnapshotInfo(created)
+	return &info, nil
+}
+
+// ListVolumeSnapshots returns the snapshots in ns matching selector, oldest first.
+func ListVolumeSnapshots(ctx context.Context, ns, selector string) ([]VolumeSnapshotInfo, error) {
+	if Snapshots == nil {
+		return nil, ErrSnapshotsUnavailable
+	}
+	list, err := Snapshots.Resource(VolumeSnapshotGVR).Namespace(ns).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]VolumeSnapshotInfo, 0, len(list.Items))
+	for i := range list.Items {
+		out = append(out, volumeSnapshotInfo(&list.Items[i]))
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// GetVolumeSnapshot returns one snapshot together with its labels.
+func GetVolumeSnapshot(ctx context.Context, ns, name string) (*VolumeSnapshotInfo, map[string]string, error) {
+	if Snapshots == nil {
+		return nil, nil, ErrSnapshotsUnavailable
+	}
+	obj, err := Snapshots.Resource(VolumeSnapshotGVR).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	info := volumeSnapshotInfo(obj)
+	return &info, obj.GetLabels(), nil
+}
+
+func DeleteVolumeSnapshot(ctx context.Context, ns, name string) error {
+	if Snapshots == nil {
+		return ErrSnapshotsUnavailable
+	}
+	return Snapshots.Resource(VolumeSnapshotGVR).Namespace(ns).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func volumeSnapshotInfo(obj *unstructured.Unstructured) VolumeSnapshotInfo {
+	info := VolumeSnapshotInfo{
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		CreatedAt: obj.GetCreationTimestamp().Time,
+	}
+	info.SourcePVC, _, _ = unstructured.NestedString(obj.Object, "spec", "source", "persistentVolumeClaimName")
+	info.ReadyToUse, _, _ = unstructured.NestedBool(obj.Object, "status", "readyToUse")
+	info.RestoreSize, _, _ = unstructured.NestedString(obj.Object, "status", "restoreSize")
+	info.Error, _, _ = unstructured.NestedString(obj.Object, "status", "error", "message")
+	return info
+}
@@ -0,0 +1,84 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newFakeSnapshots(t *testing.T, objs ...runtime.Object) {
+	t.Helper()
+	orig := Snapshots
+	t.Cleanup(func() { Snapshots = orig })
+	Snapshots = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{VolumeSnapshotGVR: "VolumeSnapshotList"}, objs...)
+}
+
+func TestVolumeSnapshotCRUD(t *testing.T) {
+	ready := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]interface{}{
+			"name":              "project-1-disk-old",
+			"namespace":         "proj-ns",
+			"labels":            map[string]interface{}{"project-id": "1"},
+			"creationTimestamp": time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339),
+		},
+		"spec":   map[string]interface{}{"source": map[string]interface{}{"persistentVolumeClaimName": "project-1-disk"}},
+		"status": map[string]interface{}{"readyToUse": true, "restoreSize": "10Gi"},
+	}}
+	newFakeSnapshots(t, ready)
+	ctx := context.Background()
+
+	created, err := CreateVolumeSnapshot(ctx, "proj-ns", "project-1-disk-new", "project-1-disk", "longhorn-snapshot-vsc", map[string]string{"project-id": "1"})
+	if err != nil {
+		t.Fatalf("CreateVolumeSnapshot: %v", err)
+	}
+	if created.SourcePVC != "project-1-disk" || created.ReadyToUse {
+		t.Fatalf("unexpected created snapshot: %+v", created)
+	}
+
+	obj, err := Snapshots.Resource(VolumeSnapshotGVR).Namespace("proj-ns").Get(ctx, "project-1-disk-new", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if class, _, _ := unstructured.NestedString(obj.Object, "spec", "volumeSnapshotClassName"); class != "longhorn-snapshot-vsc" {
+		t.Fatalf("volumeSnapshotClassName = %q", class)
+	}
+	if obj.GetLabels()[SnapshotSourceLabel] != "project-1-disk" {
+		t.Fatalf("labels = %v", obj.GetLabels())
+	}
+
+	list, err := ListVolumeSnapshots(ctx, "proj-ns", "project-id=1")
+	if err != nil {
+		t.Fatalf("ListVolumeSnapshots: %v", err)
+	}
+	if len(list) != 2 || list[1].Name != "project-1-disk-old" || !list[1].ReadyToUse || list[1].RestoreSize != "10Gi" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	if err := DeleteVolumeSnapshot(ctx, "proj-ns", "project-1-disk-old"); err != nil {
+		t.Fatalf("DeleteVolumeSnapshot: %v", err)
+	}
+	if _, _, err := GetVolumeSnapshot(ctx, "proj-ns", "project-1-disk-old"); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected NotFound after delete, got %v", err)
+	}
+}
+
+func TestVolumeSnapshotsUnavailable(t *testing.T) {
+	orig := Snapshots
+	t.Cleanup(func() { Snapshots = orig })
+	Snapshots = nil
+
+	if _, err := ListVolumeSnapshots(context.Background(), "ns", ""); !errors.Is(err, ErrSnapshotsUnavailable) {
+		t.Fatalf("expected ErrSnapshotsUnavailable, got %v", err)
+	}
+}
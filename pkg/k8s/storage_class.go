@@ -0,0 +1,31 @@
+package k8s
+
+import (
+	"context"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations marking the default StorageClass of a cluster.
+const (
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// ListStorageClasses returns the StorageClasses of the cluster.
+func ListStorageClasses(ctx context.Context) ([]storagev1.StorageClass, error) {
+	if Clientset == nil {
+		return []storagev1.StorageClass{}, nil
+	}
+	list, err := Clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// IsDefaultStorageClass reports whether PVCs without a class get sc.
+func IsDefaultStorageClass(sc *storagev1.StorageClass) bool {
+	return sc.Annotations[defaultStorageClassAnnotation] == "true" || sc.Annotations[betaDefaultStorageClassAnnotation] == "true"
+}
@@ -0,0 +1,350 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/linskybing/platform-go/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Component states reported in a HubStatus.
+const (
+	HubComponentReady   = "ready"
+	HubComponentCreated = "created"
+	HubComponentMissing = "missing"
+	HubComponentFailed  = "failed"
+)
+
+// Components of a user storage hub, in the order they are created.
+const (
+	HubNamespace  = "namespace"
+	HubPVC        = "pvc"
+	HubDeployment = "deployment"
+	HubService    = "service"
+)
+
+// HubComponentStatus is the state of one resource of a storage hub.
+type HubComponentStatus struct {
+	Component string `json:"component"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	// Why the pod of the component is not running, when it did not become Ready
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// HubStatus reports every resource of a user's storage hub.
+type HubStatus struct {
+	Namespace  string               `json:"namespace"`
+	PVCName    string               `json:"pvc_name"`
+	Ready      bool                 `json:"ready"`
+	Components []HubComponentStatus `json:"components"`
+}
+
+// Failed returns the components that could not be checked or created.
+func (s *HubStatus) Failed() []HubComponentStatus {
+	var failed []HubComponentStatus
+	for _, c := range s.Components {
+		if c.Status == HubComponentFailed {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of the failed components, or returns nil.
+func (s *HubStatus) Err() error {
+	var errs []error
+	for _, c := range s.Failed() {
+		errs = append(errs, fmt.Errorf("%s %s: %s", c.Component, c.Name, c.Error))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *HubStatus) add(component, name, status string, err error) {
+	c := HubComponentStatus{Component: component, Name: name, Status: status}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	s.Components = append(s.Components, c)
+}
+
+func (s *HubStatus) finish() *HubStatus {
+	s.Ready = true
+	for _, c := range s.Components {
+		if c.Status != HubComponentReady && c.Status != HubComponentCreated {
+			s.Ready = false
+		}
+	}
+	return s
+}
+
+// UserHubSpec describes the storage hub to create for a user.
+type UserHubSpec struct {
+	Namespace        string
+	PVCName          string
+	StorageClassName string
+	Size             string
+	NamespaceLabels  map[string]string
+}
+
+// hubStep pairs a component with its lookup and its creation. update, when set, brings an
+// existing component up to date.
+type hubStep struct {
+	component, name string
+	get             func() error
+	create          func() error
+	update          func() error
+}
+
+func hubComponents(ctx context.Context, spec UserHubSpec) []hubStep {
+	ns, pvcName := spec.Namespace, spec.PVCName
+	deployName := StorageHubDeploymentName(pvcName)
+	svcName := config.PersonalStorageServiceName
+	return []hubStep{
+		{HubNamespace, ns,
+			func() error {
+				_, err := Clientset.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+				return err
+			},
+			func() error { return CreateNamespace(ns, spec.NamespaceLabels) }, nil},
+		{HubPVC, pvcName,
+			func() error {
+				_, err := Clientset.CoreV1().PersistentVolumeClaims(ns).Get(ctx, pvcName, metav1.GetOptions{})
+				return err
+			},
+			func() error { return CreateHubPVC(ns, pvcName, spec.StorageClassName, spec.Size) }, nil},
+		{HubDeployment, deployName,
+			func() error {
+				_, err := Clientset.AppsV1().Deployments(ns).Get(ctx, deployName, metav1.GetOptions{})
+				return err
+			},
+			func() error { return CreateStorageHub(ns, pvcName) },
+			func() error { return syncStorageHub(ctx, ns, pvcName) }},
+		{HubService, svcName,
+			func() error {
+				_, err := Clientset.CoreV1().Services(ns).Get(ctx, svcName, metav1.GetOptions{})
+				return err
+			},
+			func() error { return createStorageHubService(ctx, ns, pvcName) }, nil},
+	}
+}
+
+// EnsureUserStorageHub checks each resource of the hub and creates the missing ones, so it can be
+// re-run after a partial failure to finish the hub. A failing component does not stop the others;
+// the components that depend on a missing namespace fail on their own.
+func EnsureUserStorageHub(ctx context.Context, spec UserHubSpec) *HubStatus {
+	status := &HubStatus{Namespace: spec.Namespace, PVCName: spec.PVCName}
+	for _, c := range hubComponents(ctx, spec) {
+		if Clientset == nil {
+			k8sLog.Debug("mock: ensured storage hub", "namespace", spec.Namespace, "kind", c.component, "name", c.name)
+			status.add(c.component, c.name, HubComponentCreated, nil)
+			continue
+		}
+		err := c.get()
+		switch {
+		case err == nil && c.update != nil:
+			if err := c.update(); err != nil {
+				status.add(c.component, c.name, HubComponentFailed, err)
+			} else {
+				status.add(c.component, c.name, HubComponentReady, nil)
+			}
+		case err == nil:
+			status.add(c.component, c.name, HubComponentReady, nil)
+		case !apierrors.IsNotFound(err):
+			status.add(c.component, c.name, HubComponentFailed, err)
+		default:
+			if err := c.create(); err != nil {
+				status.add(c.component, c.name, HubComponentFailed, err)
+			} else {
+				status.add(c.component, c.name, HubComponentCreated, nil)
+			}
+		}
+	}
+	status.waitForHub(ctx, spec.PVCName)
+	return status.finish()
+}
+
+// waitForHub marks the deployment failed, with the reasons, when its pod is not Ready within
+// config.StorageReadyTimeout.
+func (s *HubStatus) waitForHub(ctx context.Context, pvcName string) {
+	for i, c := range s.Components {
+		if c.Component != HubDeployment || c.Status == HubComponentFailed {
+			continue
+		}
+		selector := fmt.Sprintf("app=storage-hub,pvc=%s", pvcName)
+		err := WaitForSelectedPodReady(ctx, s.Namespace, selector, config.StorageReadyTimeout, c.Name, pvcName)
+		if err == nil {
+			return
+		}
+		s.Components[i].Status, s.Components[i].Error = HubComponentFailed, err.Error()
+		var notReady *PodNotReadyError
+		if errors.As(err, &notReady) {
+			s.Components[i].Reasons = notReady.Reasons
+		}
+	}
+}
+
+// GetUserStorageHubStatus reports which resources of the hub exist without changing anything.
+func GetUserStorageHubStatus(ctx context.Context, ns, pvcName string) *HubStatus {
+	status := &HubStatus{Namespace: ns, PVCName: pvcName}
+	for _, c := range hubComponents(ctx, UserHubSpec{Namespace: ns, PVCName: pvcName}) {
+		if Clientset == nil {
+			status.add(c.component, c.name, HubComponentMissing, nil)
+			continue
+		}
+		err := c.get()
+		switch {
+		case err == nil:
+			status.add(c.component, c.name, HubComponentReady, nil)
+		case apierrors.IsNotFound(err):
+			status.add(c.component, c.name, HubComponentMissing, nil)
+		default:
+			status.add(c.component, c.name, HubComponentFailed, err)
+		}
+	}
+	return status.finish()
+}
+
+// Verdicts of a HubHealth.
+const (
+	HubHealthy  = "Healthy"
+	HubDegraded = "Degraded"
+	HubMissing  = "Missing"
+)
+
+// HubHealth tells whether a user's storage hub can serve the project instances that mount it.
+type HubHealth struct {
+	Verdict string `json:"verdict"`
+	// Exists is whether the hub namespace exists, the whole check before health was reported
+	Exists            bool   `json:"exists"`
+	Namespace         string `json:"namespace"`
+	PVCName           string `json:"pvc_name"`
+	PVCPhase          string `json:"pvc_phase,omitempty"`
+	Capacity          string `json:"capacity,omitempty"`
+	AvailableReplicas int32  `json:"available_replicas"`
+	ServiceClusterIP  string `json:"service_cluster_ip,omitempty"`
+	// The most recent warning event in the hub namespace, as "Reason: message"
+	LastWarning string `json:"last_warning,omitempty"`
+	// Why the hub is degraded
+	Problems []string `json:"problems,omitempty"`
+}
+
+// GetUserStorageHealth checks the hub PVC is Bound, the hub deployment has an available replica
+// and the NFS service has a ClusterIP. It is Missing when the namespace does not exist and
+// Degraded when anything else is wrong; only a failed namespace lookup is returned as an error.
+func GetUserStorageHealth(ctx context.Context, ns, pvcName string) (*HubHealth, error) {
+	health := &HubHealth{Verdict: HubMissing, Namespace: ns, PVCName: pvcName}
+	if Clientset == nil {
+		return health, nil
+	}
+	if _, err := Clientset.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return health, nil
+		}
+		return nil, err
+	}
+	health.Exists = true
+
+	pvc, err := Clientset.CoreV1().PersistentVolumeClaims(ns).Get(ctx, pvcName, metav1.GetOptions{})
+	switch {
+	case err != nil:
+		health.problem(HubPVC, pvcName, err)
+	default:
+		health.PVCPhase = string(pvc.Status.Phase)
+		if size, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+			health.Capacity = size.String()
+		}
+		if pvc.Status.Phase != corev1.ClaimBound {
+			health.Problems = append(health.Problems, fmt.Sprintf("pvc %s is not bound (phase %q)", pvcName, health.PVCPhase))
+		}
+	}
+
+	deployName := StorageHubDeploymentName(pvcName)
+	deploy, err := Clientset.AppsV1().Deployments(ns).Get(ctx, deployName, metav1.GetOptions{})
+	switch {
+	case err != nil:
+		health.problem(HubDeployment, deployName, err)
+	default:
+		health.AvailableReplicas = deploy.Status.AvailableReplicas
+		if deploy.Status.AvailableReplicas == 0 {
+			health.Problems = append(health.Problems, fmt.Sprintf("deployment %s has no available replica", deployName))
+		}
+	}
+
+	svcName := config.PersonalStorageServiceName
+	svc, err := Clientset.CoreV1().Services(ns).Get(ctx, svcName, metav1.GetOptions{})
+	switch {
+	case err != nil:
+		health.problem(HubService, svcName, err)
+	case svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone:
+		health.Problems = append(health.Problems, fmt.Sprintf("service %s has no cluster IP", svcName))
+	default:
+		health.ServiceClusterIP = svc.Spec.ClusterIP
+	}
+
+	health.LastWarning = lastWarningEvent(ctx, ns)
+	health.Verdict = HubHealthy
+	if len(health.Problems) > 0 {
+		health.Verdict = HubDegraded
+	}
+	return health, nil
+}
+
+func (h *HubHealth) problem(component, name string, err error) {
+	if apierrors.IsNotFound(err) {
+		h.Problems = append(h.Problems, fmt.Sprintf("%s %s is missing", component, name))
+		return
+	}
+	h.Problems = append(h.Problems, fmt.Sprintf("%s %s could not be checked: %v", component, name, err))
+}
+
+// lastWarningEvent returns the newest warning event of the namespace, or "" when there is none.
+func lastWarningEvent(ctx context.Context, ns string) string {
+	list, err := Clientset.CoreV1().Events(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return ""
+	}
+	var latest *corev1.Event
+	for i := range list.Items {
+		e := &list.Items[i]
+		if e.Type == corev1.EventTypeWarning && (latest == nil || eventTime(*e).After(eventTime(*latest))) {
+			latest = e
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return formatReason(latest.Reason, latest.Message)
+}
+
+// createStorageHubService exposes the hub deployment under config.PersonalStorageServiceName,
+// the service project bindings mount the user's hub through.
+func createStorageHubService(ctx context.Context, ns, pvcName string) error {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.PersonalStorageServiceName,
+			Namespace: ns,
+			Labels:    MergeLabels(map[string]string{"app": "storage-hub", "pvc": pvcName}, Ownership{}.Labels()),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "storage-hub", "pvc": pvcName},
+			Ports: []corev1.ServicePort{{
+				Name:       "nfs",
+				Port:       2049,
+				TargetPort: intstr.FromInt32(2049),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+	_, err := Clientset.CoreV1().Services(ns).Create(ctx, svc, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create storage hub service: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func componentStates(s *HubStatus) map[string]string {
+	states := map[string]string{}
+	for _, c := range s.Components {
+		states[c.Component] = c.Status
+	}
+	return states
+}
+
+func TestEnsureUserStorageHubResumesAfterFailure(t *testing.T) {
+	orig, origWait := Clientset, config.StorageReadyTimeout
+	defer func() { Clientset, config.StorageReadyTimeout = orig, origWait }()
+	// The fake cluster never runs the hub pod
+	config.StorageReadyTimeout = 0
+	fake := k8sfake.NewSimpleClientset()
+	failures := 1
+	fake.PrependReactor("create", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failures > 0 {
+			failures--
+			return true, nil, errors.New("admission webhook unavailable")
+		}
+		return false, nil, nil
+	})
+	Clientset = fake
+
+	ctx := context.Background()
+	spec := UserHubSpec{Namespace: "user-alice-storage", PVCName: "user-alice-disk", StorageClassName: "longhorn", Size: "10Gi"}
+
+	first := EnsureUserStorageHub(ctx, spec)
+	if first.Ready || first.Err() == nil {
+		t.Fatalf("expected a partial hub, got %+v", first)
+	}
+	want := map[string]string{HubNamespace: HubComponentCreated, HubPVC: HubComponentCreated, HubDeployment: HubComponentFailed, HubService: HubComponentCreated}
+	for component, status := range want {
+		if got := componentStates(first)[component]; got != status {
+			t.Fatalf("first run: %s is %s, want %s", component, got, status)
+		}
+	}
+
+	detail := GetUserStorageHubStatus(ctx, spec.Namespace, spec.PVCName)
+	if detail.Ready || componentStates(detail)[HubDeployment] != HubComponentMissing {
+		t.Fatalf("expected the deployment to be reported missing, got %+v", detail)
+	}
+
+	second := EnsureUserStorageHub(ctx, spec)
+	if !second.Ready || second.Err() != nil {
+		t.Fatalf("expected the re-run to complete the hub, got %+v", second)
+	}
+	want = map[string]string{HubNamespace: HubComponentReady, HubPVC: HubComponentReady, HubDeployment: HubComponentCreated, HubService: HubComponentReady}
+	for component, status := range want {
+		if got := componentStates(second)[component]; got != status {
+			t.Fatalf("second run: %s is %s, want %s", component, got, status)
+		}
+	}
+
+	if detail := GetUserStorageHubStatus(ctx, spec.Namespace, spec.PVCName); !detail.Ready {
+		t.Fatalf("expected every component to be ready, got %+v", detail)
+	}
+}
+
+// TestEnsureUserStorageHubServesNFS checks the hub pod behind the NFS service is an NFS server, and
+// that a hub created as an idle shell is updated to one.
+func TestEnsureUserStorageHubServesNFS(t *testing.T) {
+	orig, origWait := Clientset, config.StorageReadyTimeout
+	defer func() { Clientset, config.StorageReadyTimeout = orig, origWait }()
+	config.StorageReadyTimeout = 0
+	ctx := context.Background()
+	spec := UserHubSpec{Namespace: "user-alice-storage", PVCName: "user-alice-disk", StorageClassName: "longhorn", Size: "10Gi"}
+
+	shell := storageHubDeployment(spec.Namespace, spec.PVCName)
+	shell.Spec.Template.Annotations = nil
+	shell.Spec.Template.Spec.Containers[0].Image = "alpine:latest"
+	Clientset = k8sfake.NewSimpleClientset(shell)
+
+	if status := EnsureUserStorageHub(ctx, spec); !status.Ready {
+		t.Fatalf("expected the hub to be ready, got %+v", status)
+	}
+	deploy, err := Clientset.AppsV1().Deployments(spec.Namespace).Get(ctx, shell.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("hub deployment missing: %v", err)
+	}
+	c := deploy.Spec.Template.Spec.Containers[0]
+	if c.Image != config.StorageHubImage || len(c.Ports) != 1 || c.Ports[0].ContainerPort != 2049 {
+		t.Fatalf("expected the hub to be updated to the NFS server, got %+v", c)
+	}
+	for name, probe := range map[string]*corev1.Probe{"readiness": c.ReadinessProbe, "liveness": c.LivenessProbe} {
+		if probe == nil || probe.TCPSocket == nil || probe.TCPSocket.Port.IntValue() != 2049 {
+			t.Fatalf("expected a %s probe on the NFS port, got %+v", name, probe)
+		}
+	}
+	if got := c.Resources.Limits[corev1.ResourceMemory]; got.String() != config.StorageHubMemoryLimit {
+		t.Fatalf("expected memory limit %s, got %s", config.StorageHubMemoryLimit, got.String())
+	}
+	if got := c.Resources.Requests[corev1.ResourceCPU]; got.String() != config.StorageHubCPURequest {
+		t.Fatalf("expected cpu request %s, got %s", config.StorageHubCPURequest, got.String())
+	}
+	svc, err := Clientset.CoreV1().Services(spec.Namespace).Get(ctx, config.PersonalStorageServiceName, metav1.GetOptions{})
+	if err != nil || svc.Spec.Selector["pvc"] != spec.PVCName || svc.Spec.Ports[0].Port != 2049 {
+		t.Fatalf("expected the NFS service to select the hub pod, got %+v (%v)", svc, err)
+	}
+}
+
+// healthyHub returns the objects of a working hub; the test breaks one of them at a time.
+func healthyHub(ns, pvcName string) (*corev1.Namespace, *corev1.PersistentVolumeClaim, *appsv1.Deployment, *corev1.Service) {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: ns},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase:    corev1.ClaimBound,
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("50Gi")},
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: StorageHubDeploymentName(pvcName), Namespace: ns},
+			Status:     appsv1.DeploymentStatus{AvailableReplicas: 1},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: config.PersonalStorageServiceName, Namespace: ns},
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.20"},
+		}
+}
+
+func TestGetUserStorageHealth(t *testing.T) {
+	orig := Clientset
+	defer func() { Clientset = orig }()
+	ns, pvcName := "user-alice-storage", "user-alice-disk"
+
+	cases := []struct {
+		name     string
+		breakHub func(pvc *corev1.PersistentVolumeClaim, deploy *appsv1.Deployment, svc *corev1.Service) []runtime.Object
+		verdict  string
+		problems int
+	}{
+		{"healthy", func(pvc *corev1.PersistentVolumeClaim, deploy *appsv1.Deployment, svc *corev1.Service) []runtime.Object {
+			return []runtime.Object{pvc, deploy, svc}
+		}, HubHealthy, 0},
+		{"pvc pending", func(pvc *corev1.PersistentVolumeClaim, deploy *appsv1.Deployment, svc *corev1.Service) []runtime.Object {
+			pvc.Status = corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending}
+			return []runtime.Object{pvc, deploy, svc}
+		}, HubDegraded, 1},
+		{"deployment crashlooping", func(pvc *corev1.PersistentVolumeClaim, deploy *appsv1.Deployment, svc *corev1.Service) []runtime.Object {
+			deploy.Status.AvailableReplicas = 0
+			return []runtime.Object{pvc, deploy, svc}
+		}, HubDegraded, 1},
+		{"service without cluster IP", func(pvc *corev1.PersistentVolumeClaim, deploy *appsv1.Deployment, svc *corev1.Service) []runtime.Object {
+			svc.Spec.ClusterIP = corev1.ClusterIPNone
+			return []runtime.Object{pvc, deploy, svc}
+		}, HubDegraded, 1},
+		{"deployment and service missing", func(pvc *corev1.PersistentVolumeClaim, _ *appsv1.Deployment, _ *corev1.Service) []runtime.Object {
+			return []runtime.Object{pvc}
+		}, HubDegraded, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			nsObj, pvc, deploy, svc := healthyHub(ns, pvcName)
+			Clientset = k8sfake.NewSimpleClientset(append(tc.breakHub(pvc, deploy, svc), nsObj)...)
+
+			health, err := GetUserStorageHealth(context.Background(), ns, pvcName)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !health.Exists || health.Verdict != tc.verdict || len(health.Problems) != tc.problems {
+				t.Fatalf("expected %s with %d problems, got %+v", tc.verdict, tc.problems, health)
+			}
+		})
+	}
+
+	t.Run("missing namespace", func(t *testing.T) {
+		Clientset = k8sfake.NewSimpleClientset()
+		health, err := GetUserStorageHealth(context.Background(), ns, pvcName)
+		if err != nil || health.Exists || health.Verdict != HubMissing {
+			t.Fatalf("expected a missing hub, got %+v (%v)", health, err)
+		}
+	})
+
+	t.Run("reports capacity and the latest warning", func(t *testing.T) {
+		nsObj, pvc, deploy, svc := healthyHub(ns, pvcName)
+		deploy.Status.AvailableReplicas = 0
+		now := time.Now()
+		warning := func(name, reason string, at time.Time) *corev1.Event {
+			return &corev1.Event{
+				ObjectMeta:    metav1.ObjectMeta{Name: name, Namespace: ns},
+				Type:          corev1.EventTypeWarning,
+				Reason:        reason,
+				Message:       "back-off restarting failed container",
+				LastTimestamp: metav1.NewTime(at),
+			}
+		}
+		Clientset = k8sfake.NewSimpleClientset(nsObj, pvc, deploy, svc,
+			warning("old", "FailedMount", now.Add(-time.Hour)),
+			warning("new", "BackOff", now),
+		)
+
+		health, _ := GetUserStorageHealth(context.Background(), ns, pvcName)
+		if health.Capacity != "50Gi" || health.PVCPhase != string(corev1.ClaimBound) || health.ServiceClusterIP != "10.96.0.20" {
+			t.Fatalf("unexpected hub details %+v", health)
+		}
+		if health.LastWarning != "BackOff: back-off restarting failed container" {
+			t.Fatalf("expected the newest warning, got %q", health.LastWarning)
+		}
+	})
+}
@@ -0,0 +1,314 @@
+package k8s
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrTerminalSessionNotFound = errors.New("terminal session not found")
+	ErrInvalidShareToken       = errors.New("share token is invalid or expired")
+	ErrTooManyObservers        = errors.New("terminal session has too many observers")
+)
+
+const (
+	// MaxTerminalObservers caps the fan-out of a single terminal session
+	MaxTerminalObservers = 10
+	// observerBuffer is the number of stdout messages queued per observer before it is dropped as too slow
+	observerBuffer = 256
+)
+
+// TerminalSession is an exec session that read-only observers can attach to.
+type TerminalSession struct {
+	ID        string
+	OwnerID   uint
+	Namespace string
+	Pod       string
+	Container string
+
+	owner     *WebSocketIO
+	mu        sync.Mutex
+	observers map[*terminalObserver]struct{}
+	closed    bool
+}
+
+// terminalObserver receives the stdout fan-out of a session. Writes go through its own queue
+// and lock so a slow observer never blocks the owner's stream.
+type terminalObserver struct {
+	conn *websocket.Conn
+	send chan []byte
+	mu   sync.Mutex // Protects concurrent writes (Ping vs Stdout vs Close)
+	done chan struct{}
+	once sync.Once
+
+	closeCode int
+	closeText string
+}
+
+type shareGrant struct {
+	sessionID string
+	expiresAt time.Time
+}
+
+type terminalRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*TerminalSession
+	shares   map[string]shareGrant
+}
+
+var terminalSessions = &terminalRegistry{
+	sessions: make(map[string]*TerminalSession),
+	shares:   make(map[string]shareGrant),
+}
+
+// NewTerminalSession registers a session for the given owner. It is bound to the owner's
+// connection by ExecToPodViaWebSocket and removed when that connection ends.
+func NewTerminalSession(ownerID uint, namespace, pod, container string) *TerminalSession {
+	s := &TerminalSession{
+		ID:        randomHex(12),
+		OwnerID:   ownerID,
+		Namespace: namespace,
+		Pod:       pod,
+		Container: container,
+		observers: make(map[*terminalObserver]struct{}),
+	}
+	terminalSessions.mu.Lock()
+	terminalSessions.sessions[s.ID] = s
+	terminalSessions.mu.Unlock()
+	return s
+}
+
+// GetTerminalSession returns an active session by ID.
+func GetTerminalSession(id string) (*TerminalSession, error) {
+	terminalSessions.mu.Lock()
+	defer terminalSessions.mu.Unlock()
+	s, ok := terminalSessions.sessions[id]
+	if !ok {
+		return nil, ErrTerminalSessionNotFound
+	}
+	return s, nil
+}
+
+// CreateShareToken issues a token that lets other clients attach to the session until it expires.
+func CreateShareToken(sessionID string, ttl time.Duration) (string, time.Time, error) {
+	terminalSessions.mu.Lock()
+	defer terminalSessions.mu.Unlock()
+	if _, ok := terminalSessions.sessions[sessionID]; !ok {
+		return "", time.Time{}, ErrTerminalSessionNotFound
+	}
+
+	now := time.Now()
+	for token, g := range terminalSessions.shares {
+		if now.After(g.expiresAt) {
+			delete(terminalSessions.shares, token)
+		}
+	}
+
+	token := randomHex(24)
+	expiresAt := now.Add(ttl)
+	terminalSessions.shares[token] = shareGrant{sessionID: sessionID, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// SessionForShareToken resolves a share token to its live session.
+func SessionForShareToken(token string) (*TerminalSession, error) {
+	terminalSessions.mu.Lock()
+	defer terminalSessions.mu.Unlock()
+	g, ok := terminalSessions.shares[token]
+	if !ok || time.Now().After(g.expiresAt) {
+		delete(terminalSessions.shares, token)
+		return nil, ErrInvalidShareToken
+	}
+	s, ok := terminalSessions.sessions[g.sessionID]
+	if !ok {
+		return nil, ErrInvalidShareToken
+	}
+	return s, nil
+}
+
+// bind attaches the owner's connection and tells the client its session ID.
+func (s *TerminalSession) bind(owner *WebSocketIO) {
+	s.mu.Lock()
+	s.owner = owner
+	s.mu.Unlock()
+	owner.writeMessage(TerminalMessage{Type: "session", Data: s.ID})
+}
+
+// AddObserver attaches conn as a read-only observer. The returned channel is closed
+// once the observer has been detached.
+func (s *TerminalSession) AddObserver(conn *websocket.Conn, name string) (<-chan struct{}, error) {
+	o := &terminalObserver{
+		conn:      conn,
+		send:      make(chan []byte, observerBuffer),
+		done:      make(chan struct{}),
+		closeCode: websocket.CloseNormalClosure,
+		closeText: "terminal session ended",
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrTerminalSessionNotFound
+	}
+	if len(s.observers) >= MaxTerminalObservers {
+		s.mu.Unlock()
+		return nil, ErrTooManyObservers
+	}
+	s.observers[o] = struct{}{}
+	owner := s.owner
+	s.mu.Unlock()
+
+	go o.writeLoop()
+	go func() {
+		o.readLoop()
+		s.removeObserver(o)
+	}()
+
+	if owner != nil {
+		if name == "" {
+			name = "an observer"
+		}
+		owner.writeMessage(TerminalMessage{Type: "stdout", Data: "\r\n\x1b[33m[" + name + " joined as observer]\x1b[0m\r\n"})
+	}
+	return o.done, nil
+}
+
+// ObserverCount returns the number of attached observers.
+func (s *TerminalSession) ObserverCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.observers)
+}
+
+// broadcast queues an already encoded stdout message for every observer.
+func (s *TerminalSession) broadcast(msg []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for o := range s.observers {
+		select {
+		case o.send <- msg:
+		default:
+			// Never let a slow observer stall the owner's terminal
+			delete(s.observers, o)
+			o.close(websocket.ClosePolicyViolation, "observer is too slow")
+		}
+	}
+}
+
+func (s *TerminalSession) removeObserver(o *terminalObserver) {
+	s.mu.Lock()
+	delete(s.observers, o)
+	s.mu.Unlock()
+	o.close(websocket.CloseNormalClosure, "observer left")
+}
+
+// Close detaches every observer with a close frame and unregisters the session.
+func (s *TerminalSession) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	observers := s.observers
+	s.observers = make(map[*terminalObserver]struct{})
+	s.mu.Unlock()
+
+	for o := range observers {
+		o.close(websocket.CloseNormalClosure, "terminal session ended")
+	}
+
+	terminalSessions.mu.Lock()
+	delete(terminalSessions.sessions, s.ID)
+	for token, g := range terminalSessions.shares {
+		if g.sessionID == s.ID {
+			delete(terminalSessions.shares, token)
+		}
+	}
+	terminalSessions.mu.Unlock()
+}
+
+func (o *terminalObserver) close(code int, text string) {
+	o.once.Do(func() {
+		o.closeCode, o.closeText = code, text
+		close(o.done)
+	})
+}
+
+func (o *terminalObserver) writeLoop() {
+	ticker := time.NewTicker(50 * time.Second)
+	defer ticker.Stop()
+	defer func() { _ = o.conn.Close() }()
+
+	for {
+		select {
+		case msg := <-o.send:
+			if err := o.write(websocket.TextMessage, msg); err != nil {
+				o.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ticker.C:
+			if err := o.write(websocket.PingMessage, nil); err != nil {
+				o.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-o.done:
+			o.mu.Lock()
+			_ = o.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(o.closeCode, o.closeText), time.Now().Add(time.Second))
+			o.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (o *terminalObserver) write(messageType int, data []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_ = o.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return o.conn.WriteMessage(messageType, data)
+}
+
+// readLoop discards everything the observer sends; observers can never write to stdin.
+func (o *terminalObserver) readLoop() {
+	const pongWait = 60 * time.Second
+	o.conn.SetReadLimit(4 * 1024)
+	_ = o.conn.SetReadDeadline(time.Now().Add(pongWait))
+	o.conn.SetPongHandler(func(string) error {
+		return o.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := o.conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure, websocket.CloseAbnormalClosure) {
+				log.Printf("Terminal observer error: %v", err)
+			}
+			return
+		}
+		_ = o.conn.SetReadDeadline(time.Now().Add(pongWait))
+	}
+}
+
+// writeMessage sends a control message (session ID, notices) to the owner only.
+func (h *WebSocketIO) writeMessage(m TerminalMessage) {
+	msg, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_ = h.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_ = h.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,276 @@
+package k8s
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsPair returns the server side of a fresh WebSocket connection and the dialed client side.
+func wsPair(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	t.Helper()
+	serverConns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		serverConns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return <-serverConns, client
+}
+
+func readTerminalMessage(t *testing.T, conn *websocket.Conn) (TerminalMessage, error) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return TerminalMessage{}, err
+	}
+	var msg TerminalMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("bad message %q: %v", data, err)
+	}
+	return msg, nil
+}
+
+// startOwner binds a new session to an owner connection, as ExecToPodViaWebSocket does.
+func startOwner(t *testing.T) (*TerminalSession, *WebSocketIO, *websocket.Conn) {
+	t.Helper()
+	serverConn, client := wsPair(t)
+	session := NewTerminalSession(7, "proj-1-alice", "pod", "main")
+	wsIO := newWebSocketIO(serverConn, session)
+	session.bind(wsIO)
+
+	msg, err := readTerminalMessage(t, client)
+	if err != nil || msg.Type != "session" || msg.Data != session.ID {
+		t.Fatalf("owner should first receive the session id, got %+v (%v)", msg, err)
+	}
+	return session, wsIO, client
+}
+
+func TestTerminalSessionConcurrentFanOut(t *testing.T) {
+	session, wsIO, owner := startOwner(t)
+	defer session.Close()
+
+	const observers, writers, perWriter = 4, 8, 25
+	clients := make([]*websocket.Conn, observers)
+	for i := range clients {
+		serverConn, client := wsPair(t)
+		if _, err := session.AddObserver(serverConn, fmt.Sprintf("viewer-%d", i)); err != nil {
+			t.Fatalf("add observer failed: %v", err)
+		}
+		clients[i] = client
+		notice, err := readTerminalMessage(t, owner)
+		if err != nil || !strings.Contains(notice.Data, fmt.Sprintf("viewer-%d joined as observer", i)) {
+			t.Fatalf("owner should be told about observer %d, got %+v (%v)", i, notice, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if _, err := wsIO.Write([]byte(fmt.Sprintf("w%d-%d;", w, i))); err != nil {
+					t.Errorf("write failed: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	// Every connection must get every write exactly once, whole and unmixed. Small writes are
+	// coalesced, so one frame may carry several of them.
+	expect := func(name string, conn *websocket.Conn) {
+		seen := make(map[string]bool)
+		for len(seen) < writers*perWriter {
+			msg, err := readTerminalMessage(t, conn)
+			if err != nil {
+				t.Errorf("%s: read failed after %d writes: %v", name, len(seen), err)
+				return
+			}
+			if msg.Type != "stdout" || !strings.HasSuffix(msg.Data, ";") {
+				t.Errorf("%s: unexpected frame %+v", name, msg)
+				return
+			}
+			for _, w := range strings.Split(strings.TrimSuffix(msg.Data, ";"), ";") {
+				if seen[w] {
+					t.Errorf("%s: duplicated write %q", name, w)
+					return
+				}
+				seen[w] = true
+			}
+		}
+	}
+	var readers sync.WaitGroup
+	for i, c := range append([]*websocket.Conn{owner}, clients...) {
+		readers.Add(1)
+		go func(i int, c *websocket.Conn) {
+			defer readers.Done()
+			expect(fmt.Sprintf("conn %d", i), c)
+		}(i, c)
+	}
+	wg.Wait()
+	readers.Wait()
+}
+
+func TestTerminalObserverInputIgnored(t *testing.T) {
+	session, wsIO, owner := startOwner(t)
+	defer session.Close()
+
+	serverConn, observer := wsPair(t)
+	if _, err := session.AddObserver(serverConn, ""); err != nil {
+		t.Fatalf("add observer failed: %v", err)
+	}
+	if _, err := readTerminalMessage(t, owner); err != nil {
+		t.Fatalf("missing join notice: %v", err)
+	}
+
+	send := func(conn *websocket.Conn, data string) {
+		b, _ := json.Marshal(TerminalMessage{Type: "stdin", Data: data})
+		if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+			t.Fatalf("send failed: %v", err)
+		}
+	}
+	send(observer, "rm -rf /\n")
+	send(owner, "ls\n")
+
+	buf := make([]byte, 64)
+	n, err := wsIO.Read(buf)
+	if err != nil || string(buf[:n]) != "ls\n" {
+		t.Fatalf("stdin should only carry owner input, got %q (%v)", buf[:n], err)
+	}
+}
+
+func TestTerminalSessionOwnerDisconnectClosesObservers(t *testing.T) {
+	session, _, owner := startOwner(t)
+
+	token, _, err := CreateShareToken(session.ID, time.Minute)
+	if err != nil {
+		t.Fatalf("create share token failed: %v", err)
+	}
+	shared, err := SessionForShareToken(token)
+	if err != nil || shared != session {
+		t.Fatalf("token should resolve to the session, got %v", err)
+	}
+
+	serverConn, observer := wsPair(t)
+	done, err := session.AddObserver(serverConn, "ta")
+	if err != nil {
+		t.Fatalf("add observer failed: %v", err)
+	}
+
+	_ = owner.Close()
+
+	_ = observer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var closeErr *websocket.CloseError
+	for {
+		_, _, err := observer.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseNormalClosure {
+			t.Fatalf("observer should get a normal close frame, got %v", err)
+		}
+		break
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("observer was not detached")
+	}
+
+	if _, err := GetTerminalSession(session.ID); !errors.Is(err, ErrTerminalSessionNotFound) {
+		t.Fatalf("session should be unregistered, got %v", err)
+	}
+	if _, err := SessionForShareToken(token); !errors.Is(err, ErrInvalidShareToken) {
+		t.Fatalf("share token should be revoked, got %v", err)
+	}
+}
+
+func TestShareTokenExpires(t *testing.T) {
+	session := NewTerminalSession(1, "ns", "pod", "c")
+	defer session.Close()
+
+	token, _, err := CreateShareToken(session.ID, -time.Second)
+	if err != nil {
+		t.Fatalf("create share token failed: %v", err)
+	}
+	if _, err := SessionForShareToken(token); !errors.Is(err, ErrInvalidShareToken) {
+		t.Fatalf("expired token must be rejected, got %v", err)
+	}
+	if _, _, err := CreateShareToken("missing", time.Minute); !errors.Is(err, ErrTerminalSessionNotFound) {
+		t.Fatalf("expected ErrTerminalSessionNotFound, got %v", err)
+	}
+}
+
+func TestWriteKeepsSplitUTF8Together(t *testing.T) {
+	serverConn, client := wsPair(t)
+	wsIO := newWebSocketIO(serverConn, nil)
+
+	// "世" is three bytes; the stream splits it after the second one
+	char := []byte("世")
+	first := append([]byte(strings.Repeat("a", stdoutCoalesceBytes)), char[:2]...)
+	second := append(char[2:], []byte("界!")...)
+	for _, p := range [][]byte{first, second} {
+		if n, err := wsIO.Write(p); err != nil || n != len(p) {
+			t.Fatalf("write returned %d, %v", n, err)
+		}
+	}
+
+	var got strings.Builder
+	want := strings.Repeat("a", stdoutCoalesceBytes) + "世界!"
+	for got.Len() < len(want) {
+		msg, err := readTerminalMessage(t, client)
+		if err != nil {
+			t.Fatalf("read failed after %q: %v", got.String(), err)
+		}
+		if strings.ContainsRune(msg.Data, utf8.RuneError) {
+			t.Fatalf("frame %q carries a broken character", msg.Data)
+		}
+		got.WriteString(msg.Data)
+	}
+	if got.String() != want {
+		t.Fatalf("expected %q, got %q", want, got.String())
+	}
+}
+
+func TestCompleteUTF8Prefix(t *testing.T) {
+	euro := []byte("€") // three bytes
+	cases := []struct {
+		in   []byte
+		want int
+	}{
+		{[]byte("plain"), 5},
+		{append([]byte("ab"), euro...), 5},
+		{append([]byte("ab"), euro[:1]...), 2},
+		{append([]byte("ab"), euro[:2]...), 2},
+		{[]byte{'a', 0x80, 0x80}, 3}, // stray continuation bytes are sent as they are
+		{nil, 0},
+	}
+	for _, tc := range cases {
+		if got := completeUTF8Prefix(tc.in); got != tc.want {
+			t.Errorf("completeUTF8Prefix(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/linskybing/platform-go/internal/config"
@@ -10,6 +11,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func parseResourceQuantity(size string) (resource.Quantity, error) {
@@ -22,7 +24,7 @@ func parseResourceQuantity(size string) (resource.Quantity, error) {
 
 func ExpandPVC(ns, pvcName, newSize string) error {
 	if Clientset == nil {
-		fmt.Printf("[MOCK] PVC %s in namespace %s expanded to %s\n", pvcName, ns, newSize)
+		k8sLog.Debug("mock: expanded PVC", "namespace", ns, "name", pvcName, "size", newSize)
 		return nil
 	}
 	if ns == "" {
@@ -56,13 +58,13 @@ func ExpandPVC(ns, pvcName, newSize string) error {
 		return fmt.Errorf("failed to expand PVC: %w", err)
 	}
 
-	fmt.Printf("PVC %s in namespace %s expanded to %s\n", pvcName, ns, newSize)
+	k8sLog.Info("expanded PVC", "namespace", ns, "name", pvcName, "size", newSize)
 	return nil
 }
 
 func CreateHubPVC(ns string, name string, storageClassName string, size string) error {
 	if Clientset == nil {
-		fmt.Printf("[MOCK] Hub PVC %s created in %s\n", name, ns)
+		k8sLog.Debug("mock: created hub PVC", "namespace", ns, "name", name)
 		return nil
 	}
 
@@ -72,7 +74,7 @@ func CreateHubPVC(ns string, name string, storageClassName string, size string)
 	}
 
 	pvc := &corev1.PersistentVolumeClaim{
-		ObjectMeta: metav1.ObjectMeta{Name: name},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: Ownership{}.Labels()},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
 			Resources: corev1.VolumeResourceRequirements{
@@ -86,76 +88,142 @@ func CreateHubPVC(ns string, name string, storageClassName string, size string)
 	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create Hub PVC: %w", err)
 	}
-	fmt.Printf("Hub PVC %s created in namespace %s\n", name, ns)
+	k8sLog.Info("created hub PVC", "namespace", ns, "name", name)
 	return nil
 }
 
-// CreateStorageHub creates a lightweight Alpine pod to mount a PVC.
-// This allows admins or systems to write/debug data in the Longhorn volume via "kubectl cp" or "exec".
+// CreateStorageHub creates the deployment serving a hub PVC over NFS, mounted at /data of its pod
+// so admins can still reach the data with "kubectl cp" or "exec". A hub rendered from an older
+// spec is updated.
 func CreateStorageHub(ns string, pvcName string) error {
+	deploy := storageHubDeployment(ns, pvcName)
+	hubName := deploy.Name
 
-	hubName := fmt.Sprintf("storage-hub-%s", pvcName)
+	_, err := Clientset.AppsV1().Deployments(ns).Create(context.TODO(), deploy, metav1.CreateOptions{})
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return syncStorageHub(context.TODO(), ns, pvcName)
+		}
+		return fmt.Errorf("failed to create Storage Hub: %w", err)
+	}
+
+	k8sLog.Info("created storage hub", "namespace", ns, "name", hubName, "mount_path", "/data")
+	return nil
+}
+
+// syncStorageHub replaces the pod template of an existing hub deployment when it differs from the
+// desired one, such as hubs created as idle shells before they served NFS.
+func syncStorageHub(ctx context.Context, ns string, pvcName string) error {
+	desired := storageHubDeployment(ns, pvcName)
+	current, err := Clientset.AppsV1().Deployments(ns).Get(ctx, desired.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if current.Spec.Template.Annotations[SpecHashAnnotation] == desired.Spec.Template.Annotations[SpecHashAnnotation] {
+		return nil
+	}
+	current.Spec.Template = desired.Spec.Template
+	current.Spec.Strategy = desired.Spec.Strategy
+	if _, err := Clientset.AppsV1().Deployments(ns).Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update storage hub: %w", err)
+	}
+	k8sLog.Info("updated storage hub", "namespace", ns, "name", desired.Name)
+	return nil
+}
+
+// StorageHubDeploymentName is the name of the deployment that mounts a hub PVC.
+func StorageHubDeploymentName(pvcName string) string {
+	return fmt.Sprintf("storage-hub-%s", pvcName)
+}
+
+const nfsPort = 2049
+
+// applyStorageHubDefaults sets the resources and the probes of the hub NFS server: it is Ready
+// and kept alive while it accepts connections on the NFS port.
+func applyStorageHubDefaults(c *corev1.Container) {
+	res, err := BuildResourceRequirements(config.StorageHubCPURequest, config.StorageHubCPULimit,
+		config.StorageHubMemoryRequest, config.StorageHubMemoryLimit)
+	if err != nil {
+		k8sLog.Warn("storage hub resources ignored", "error", err)
+	} else {
+		c.Resources = res
+	}
+
+	probe := func(initialDelay, period int32) *corev1.Probe {
+		// Every field the API server would default is set explicitly so the spec hash stays stable
+		return &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(nfsPort)},
+			},
+			InitialDelaySeconds: initialDelay,
+			PeriodSeconds:       period,
+			TimeoutSeconds:      2,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+		}
+	}
+	c.ReadinessProbe = probe(5, 10)
+	c.LivenessProbe = probe(30, 20)
+}
+
+func storageHubDeployment(ns string, pvcName string) *appsv1.Deployment {
+	hubName := StorageHubDeploymentName(pvcName)
 	replicas := int32(1)
 
-	privileged := false
+	// The kernel NFS server of the image needs a privileged container to export the volume
+	privileged := true
+
+	container := corev1.Container{
+		Name:  "nfs-server",
+		Image: config.StorageHubImage,
+		Env:   []corev1.EnvVar{{Name: "SHARED_DIRECTORY", Value: "/data"}},
+		Ports: []corev1.ContainerPort{{Name: "nfs", ContainerPort: nfsPort, Protocol: corev1.ProtocolTCP}},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "longhorn-vol",
+				MountPath: "/data",
+			},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &privileged,
+		},
+	}
+	applyStorageHubDefaults(&container)
+
+	spec := corev1.PodSpec{
+		TerminationGracePeriodSeconds: new(int64),
+		Containers:                    []corev1.Container{container},
+		Volumes: []corev1.Volume{
+			{
+				Name: "longhorn-vol",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: pvcName,
+					},
+				},
+			},
+		},
+	}
 
-	deploy := &appsv1.Deployment{
+	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      hubName,
 			Namespace: ns,
-			Labels:    map[string]string{"app": "storage-hub", "pvc": pvcName},
+			Labels:    MergeLabels(map[string]string{"app": "storage-hub", "pvc": pvcName}, Ownership{}.Labels()),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &replicas,
 			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
 			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "storage-hub", "pvc": pvcName}},
 			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "storage-hub", "pvc": pvcName}},
-				Spec: corev1.PodSpec{
-					TerminationGracePeriodSeconds: new(int64),
-					Containers: []corev1.Container{
-						{
-							Name:    "hub-client",
-							Image:   "alpine:latest",
-							Command: []string{"/bin/sh", "-c", "echo 'Storage Hub Running...'; sleep infinity"},
-
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "longhorn-vol",
-									MountPath: "/data",
-								},
-							},
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &privileged,
-							},
-						},
-					},
-					Volumes: []corev1.Volume{
-						{
-							Name: "longhorn-vol",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: pvcName,
-								},
-							},
-						},
-					},
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      MergeLabels(map[string]string{"app": "storage-hub", "pvc": pvcName}, Ownership{}.Labels()),
+					Annotations: map[string]string{SpecHashAnnotation: PodSpecHash(spec)},
 				},
+				Spec: spec,
 			},
 		},
 	}
-
-	_, err := Clientset.AppsV1().Deployments(ns).Create(context.TODO(), deploy, metav1.CreateOptions{})
-	if err != nil {
-		if apierrors.IsAlreadyExists(err) {
-			fmt.Printf("Storage Hub %s already exists in %s.\n", hubName, ns)
-			return nil
-		}
-		return fmt.Errorf("failed to create Storage Hub: %w", err)
-	}
-
-	fmt.Printf("Storage Hub created: %s (ns: %s). Mount path: /data\n", hubName, ns)
-	return nil
 }
 
 func MountExistingVolumeToProject(sourceNs, sourcePvcName, targetNs, targetPvcName string) error {
@@ -261,12 +329,10 @@ func MountExistingVolumeToProject(sourceNs, sourcePvcName, targetNs, targetPvcNa
 	return nil
 }
 
-// DeleteProjectStorageCompletely handles the cleanup for a project.
+// DeleteProjectStorageCompletely handles the cleanup for a project storage namespace.
 // It iterates over ALL PVCs in the project namespace to ensure all shared pointers are removed.
-func DeleteProjectStorageCompletely(ctx context.Context, projectName string, projectID uint) error {
-	nsName := GenerateSafeResourceName("project", projectName, projectID)
-
-	fmt.Printf("[Cleanup] Starting cleanup for project: %s (ns: %s)\n", projectName, nsName)
+func DeleteProjectStorageCompletely(ctx context.Context, nsName string) error {
+	k8sLog.Info("cleaning up project storage", "namespace", nsName)
 
 	// 1. List ALL PVCs in the project namespace
 	// We don't guess names like "project-disk", we find whatever exists.
@@ -278,12 +344,12 @@ func DeleteProjectStorageCompletely(ctx context.Context, projectName string, pro
 
 	// 2. Iterate and Clean each PVC tree
 	for _, pvc := range pvcs.Items {
-		fmt.Printf("[Cleanup] Processing project PVC: %s\n", pvc.Name)
+		k8sLog.Debug("cleaning up project PVC", "namespace", nsName, "name", pvc.Name)
 
 		// Call the generic helper for each PVC found
 		if err := cleanUpSinglePVCTree(ctx, nsName, pvc.Name); err != nil {
 			// Log error but continue to try cleaning other PVCs and the Namespace
-			fmt.Printf("[Error] Failed to clean PVC %s: %v\n", pvc.Name, err)
+			k8sLog.Error("failed to clean up project PVC", "namespace", nsName, "name", pvc.Name, "error", err)
 		}
 	}
 
@@ -294,17 +360,26 @@ func DeleteProjectStorageCompletely(ctx context.Context, projectName string, pro
 		return fmt.Errorf("failed to delete project namespace: %w", err)
 	}
 
-	fmt.Printf("[Cleanup] Successfully deleted project resources: %s\n", projectName)
+	k8sLog.Info("deleted project storage", "namespace", nsName)
 	return nil
 }
 
-// DeleteUserStorageCompletely handles the cleanup for a user.
-func DeleteUserStorageCompletely(ctx context.Context, username string) error {
-	safeUser := ToSafeK8sName(username)
-	nsName := fmt.Sprintf(config.UserStorageNs, safeUser)
-	pvcName := fmt.Sprintf(config.UserStoragePVC, safeUser)
+// UserHubNamespace is the namespace of the storage hub named with the user's stored storage ID.
+func UserHubNamespace(storageID string) string {
+	return fmt.Sprintf(config.UserStorageNs, storageID)
+}
+
+// UserHubDiskName is the PVC holding the data of the storage hub named with storageID.
+func UserHubDiskName(storageID string) string {
+	return fmt.Sprintf(config.UserStoragePVC, storageID)
+}
 
-	fmt.Printf("[Cleanup] Starting cleanup for user: %s\n", username)
+// DeleteUserStorageCompletely handles the cleanup of the storage hub named with storageID.
+func DeleteUserStorageCompletely(ctx context.Context, storageID string) error {
+	nsName := UserHubNamespace(storageID)
+	pvcName := UserHubDiskName(storageID)
+
+	k8sLog.Info("cleaning up user storage", "namespace", nsName, "storage_id", storageID)
 
 	if err := cleanUpSinglePVCTree(ctx, nsName, pvcName); err != nil {
 		return fmt.Errorf("failed to clean up user pvc: %w", err)
@@ -364,9 +439,9 @@ func cleanUpSinglePVCTree(ctx context.Context, ns string, pvcName string) error
 	for _, pv := range pointerPVs.Items {
 		err := Clientset.CoreV1().PersistentVolumes().Delete(ctx, pv.Name, metav1.DeleteOptions{})
 		if err != nil {
-			fmt.Printf("[Warning] Failed to delete pointer PV %s: %v\n", pv.Name, err)
+			k8sLog.Warn("failed to delete pointer PV", "name", pv.Name, "pvc", pvcName, "error", err)
 		} else {
-			fmt.Printf("[Cleanup] Deleted pointer PV: %s (was linked to %s)\n", pv.Name, pvcName)
+			k8sLog.Debug("deleted pointer PV", "name", pv.Name, "pvc", pvcName)
 		}
 	}
 
@@ -378,3 +453,208 @@ func cleanUpSinglePVCTree(ctx context.Context, ns string, pvcName string) error
 
 	return nil
 }
+
+// ErrUserHubNotReady is returned when the user's hub NFS service does not exist or has no ClusterIP yet.
+var ErrUserHubNotReady = errors.New("user storage hub service is not ready")
+
+// UserHubPVCName is the PVC name that exposes the user's hub volume inside a project namespace.
+func UserHubPVCName(safeUser string) string {
+	return fmt.Sprintf("user-%s-pv", safeUser)
+}
+
+// userHubPVName is cluster-scoped, so the project ID is folded into the name to avoid collisions.
+func userHubPVName(safeUser string, projectID uint) string {
+	return GenerateSafeResourceName("user-hub", safeUser, projectID)
+}
+
+// CreateNFSPV creates a static NFS PersistentVolume pre-bound to the given claim.
+func CreateNFSPV(ctx context.Context, name, server, path, size string, claimNs, claimName string, labels map[string]string) error {
+	quantity, err := parseResourceQuantity(size)
+	if err != nil {
+		return err
+	}
+
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: quantity,
+			},
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			// Retain keeps the user's data on the hub when the project binding is removed
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			StorageClassName:              "",
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: claimNs,
+				Name:      claimName,
+			},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				NFS: &corev1.NFSVolumeSource{
+					Server: server,
+					Path:   path,
+				},
+			},
+		},
+	}
+
+	_, err = Clientset.CoreV1().PersistentVolumes().Create(ctx, pv, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create nfs pv %s: %w", name, err)
+	}
+	return nil
+}
+
+// BindUserHubToProject wires the user's hub NFS export into their project namespace as a PV and a bound
+// PVC named user-{safeUser}-pv. The hub is the one named with the user's storageID. It is idempotent and
+// returns ErrUserHubNotReady if the hub service is missing.
+func BindUserHubToProject(ctx context.Context, username, storageID string, projectID uint) error {
+	safeUser := ToSafeK8sName(username)
+	targetNs := FormatNamespaceName(projectID, safeUser)
+
+	if Clientset == nil {
+		k8sLog.Debug("mock: bound user hub", "namespace", targetNs, "name", userHubPVName(safeUser, projectID), "user", username)
+		return nil
+	}
+
+	hubNs := UserHubNamespace(storageID)
+	svc, err := Clientset.CoreV1().Services(hubNs).Get(ctx, config.PersonalStorageServiceName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ErrUserHubNotReady
+		}
+		return fmt.Errorf("failed to get user hub service: %w", err)
+	}
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		return ErrUserHubNotReady
+	}
+	return createUserHubBinding(ctx, targetNs, safeUser, projectID, svc.Spec.ClusterIP)
+}
+
+// UserHubBindings is a snapshot of the user hub NFS services and of the hub PVCs bound into
+// project namespaces, so a sweep over every project member only calls the API for the bindings
+// that are missing.
+type UserHubBindings struct {
+	// servers maps a hub namespace to the cluster IP of its NFS service
+	servers map[string]string
+	// bound holds the "<namespace>/<pvc>" of every bound hub PVC
+	bound map[string]bool
+}
+
+// ListUserHubBindings takes the snapshot with one cluster-wide List of services and one of PVCs.
+func ListUserHubBindings(ctx context.Context) (*UserHubBindings, error) {
+	b := &UserHubBindings{servers: map[string]string{}, bound: map[string]bool{}}
+	if Clientset == nil {
+		return b, nil
+	}
+	svcs, err := Clientset.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: "app=storage-hub"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user hub services: %w", err)
+	}
+	for _, svc := range svcs.Items {
+		if svc.Name == config.PersonalStorageServiceName && svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != corev1.ClusterIPNone {
+			b.servers[svc.Namespace] = svc.Spec.ClusterIP
+		}
+	}
+	pvcs, err := Clientset.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: "storage-type=user-hub"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user hub pvcs: %w", err)
+	}
+	for _, pvc := range pvcs.Items {
+		b.bound[pvc.Namespace+"/"+pvc.Name] = true
+	}
+	return b, nil
+}
+
+// Bind is BindUserHubToProject against the snapshot: a binding it holds is not created again.
+func (b *UserHubBindings) Bind(ctx context.Context, username, storageID string, projectID uint) error {
+	safeUser := ToSafeK8sName(username)
+	targetNs := FormatNamespaceName(projectID, safeUser)
+	if Clientset == nil {
+		k8sLog.Debug("mock: bound user hub", "namespace", targetNs, "name", userHubPVName(safeUser, projectID), "user", username)
+		return nil
+	}
+	key := targetNs + "/" + UserHubPVCName(safeUser)
+	if b.bound[key] {
+		return nil
+	}
+	server, ok := b.servers[UserHubNamespace(storageID)]
+	if !ok {
+		return ErrUserHubNotReady
+	}
+	if err := createUserHubBinding(ctx, targetNs, safeUser, projectID, server); err != nil {
+		return err
+	}
+	b.bound[key] = true
+	return nil
+}
+
+// createUserHubBinding creates the PV pointing at the hub NFS server and the PVC bound to it.
+func createUserHubBinding(ctx context.Context, targetNs, safeUser string, projectID uint, server string) error {
+	pvcName := UserHubPVCName(safeUser)
+	pvName := userHubPVName(safeUser, projectID)
+	labels := MergeLabels(map[string]string{
+		"created-by":   "k8s-platform-share",
+		"storage-type": "user-hub",
+		"target-ns":    targetNs,
+		"project-id":   fmt.Sprintf("%d", projectID),
+	}, Ownership{ProjectID: projectID}.Labels())
+
+	// The PV points at the ClusterIP because kubelet mounts NFS from the node, outside cluster DNS
+	size := config.StringSetting(config.SettingUserPVSize)
+	if err := CreateNFSPV(ctx, pvName, server, "/", size, targetNs, pvcName, labels); err != nil {
+		return err
+	}
+
+	quantity, err := parseResourceQuantity(size)
+	if err != nil {
+		return err
+	}
+	emptyClass := ""
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvcName,
+			Namespace: targetNs,
+			Labels:    labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: quantity},
+			},
+			StorageClassName: &emptyClass,
+			VolumeName:       pvName,
+		},
+	}
+
+	_, err = Clientset.CoreV1().PersistentVolumeClaims(targetNs).Create(ctx, pvc, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create user hub pvc: %w", err)
+	}
+	return nil
+}
+
+// UnbindUserHubFromProject removes the user's hub PVC from the project namespace and deletes the backing PV.
+func UnbindUserHubFromProject(ctx context.Context, username string, projectID uint) error {
+	safeUser := ToSafeK8sName(username)
+	targetNs := FormatNamespaceName(projectID, safeUser)
+	pvName := userHubPVName(safeUser, projectID)
+
+	if Clientset == nil {
+		k8sLog.Debug("mock: unbound user hub", "namespace", targetNs, "name", pvName, "user", username)
+		return nil
+	}
+
+	err := Clientset.CoreV1().PersistentVolumeClaims(targetNs).Delete(ctx, UserHubPVCName(safeUser), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete user hub pvc: %w", err)
+	}
+
+	err = Clientset.CoreV1().PersistentVolumes().Delete(ctx, pvName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete user hub pv: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWatchAndSendPagesSnapshotAndResumesFromListVersion(t *testing.T) {
+	origDelay := config.WatchListBatchDelay
+	defer func() { config.WatchListBatchDelay = origDelay }()
+	config.WatchListBatchDelay = time.Millisecond
+	const total = 500
+
+	pods := make([]runtime.Object, 0, total)
+	items := make([]unstructured.Unstructured, 0, total)
+	for i := 0; i < total; i++ {
+		pod := runningPod(fmt.Sprintf("worker-%03d", i), "Running")
+		pod.SetLabels(Ownership{ProjectID: 1}.Labels())
+		pods = append(pods, pod)
+		items = append(items, *pod)
+	}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podsGVR: "PodList"}, pods...)
+
+	// The fake ignores Limit/Continue, so serve the objects one page per call like the API server
+	var mu sync.Mutex
+	pages := 0
+	dyn.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		start := pages * config.WatchListPageSize
+		end := min(start+config.WatchListPageSize, total)
+		pages++
+		list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}}
+		list.SetResourceVersion("777")
+		if end < total {
+			list.SetContinue(strconv.Itoa(end))
+		}
+		list.Items = items[start:end]
+		return true, list, nil
+	})
+	watched := make(chan string, 1)
+	dyn.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		select {
+		case watched <- action.(k8stesting.WatchAction).GetWatchRestrictions().ResourceVersion:
+		default:
+		}
+		return true, watch.NewFake(), nil
+	})
+
+	ch := make(chan []byte, total+10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchAndSend(ctx, dyn, podsGVR, "ns", newWatchSender(ch))
+
+	select {
+	case rv := <-watched:
+		if rv != "777" {
+			t.Fatalf("expected the watch to start at the list version, got %q", rv)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watch was never started")
+	}
+	cancel()
+
+	mu.Lock()
+	if want := (total + config.WatchListPageSize - 1) / config.WatchListPageSize; pages != want {
+		t.Fatalf("expected %d pages, got %d", want, pages)
+	}
+	mu.Unlock()
+
+	added := 0
+	var done syncComplete
+	for len(ch) > 0 {
+		var m map[string]interface{}
+		msg := <-ch
+		if err := json.Unmarshal(msg, &m); err != nil {
+			t.Fatalf("bad message %s", msg)
+		}
+		switch m["type"] {
+		case "ADDED":
+			if done.Type != "" {
+				t.Fatalf("object sent after the sync-complete marker")
+			}
+			added++
+		case "sync-complete":
+			_ = json.Unmarshal(msg, &done)
+		}
+	}
+	if added != total || done.Count != total || done.Resource != "pods" {
+		t.Fatalf("expected %d objects followed by sync-complete, got %d and %+v", total, added, done)
+	}
+}
@@ -0,0 +1,244 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/linskybing/platform-go/pkg/k8s/resourceview"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ErrWatchBufferFull is returned when a watch message is dropped because the client reads too slowly.
+var ErrWatchBufferFull = errors.New("client buffer full, dropping message")
+
+// watchStats is the synthetic message telling the frontend that events were lost and that it
+// should refetch the full list.
+type watchStats struct {
+	Type    string `json:"type"`
+	Dropped int64  `json:"dropped"`
+	Resync  bool   `json:"resync"`
+}
+
+// watchSender delivers the messages of all watchers of one connection to its bounded write
+// channel without ever blocking a watcher. A message that does not fit is dropped and counted;
+// the watcher it came from is asked to re-list, and the next stats tick tells the client.
+type watchSender struct {
+	ch chan<- []byte
+	// viewer is the user id of the client; objects are flagged "mine" when they carry it
+	viewer string
+
+	mu         sync.Mutex
+	dropped    int64
+	unreported bool
+	resync     map[string]bool
+}
+
+func newWatchSender(ch chan<- []byte) *watchSender {
+	return &watchSender{ch: ch, resync: make(map[string]bool)}
+}
+
+// send queues msg from the watcher identified by source.
+func (s *watchSender) send(ctx context.Context, source string, msg []byte) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	select {
+	case s.ch <- msg:
+		return nil
+	default:
+	}
+
+	s.mu.Lock()
+	s.dropped++
+	s.unreported = true
+	s.resync[source] = true
+	s.mu.Unlock()
+	return ErrWatchBufferFull
+}
+
+// pusher binds send to one watcher, in the form pendingTracker expects.
+func (s *watchSender) pusher(ctx context.Context, source string) func([]byte) error {
+	return func(msg []byte) error { return s.send(ctx, source, msg) }
+}
+
+// takeResync reports whether source dropped a message since it last re-listed, clearing the flag.
+func (s *watchSender) takeResync(source string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	need := s.resync[source]
+	delete(s.resync, source)
+	return need
+}
+
+// Dropped returns the number of messages dropped on this connection.
+func (s *watchSender) Dropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// reportStats queues a stats message if messages were dropped since the last one. When even
+// that does not fit it is retried on the next call.
+func (s *watchSender) reportStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.unreported {
+		return
+	}
+	msg, _ := json.Marshal(watchStats{Type: "stats", Dropped: s.dropped, Resync: true})
+	select {
+	case s.ch <- msg:
+		s.unreported = false
+		log.Printf("[Watch] %d messages dropped on a slow connection, client asked to resync", s.dropped)
+	default:
+	}
+}
+
+// run reports stats every interval until ctx is done.
+func (s *watchSender) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reportStats()
+		}
+	}
+}
+
+// syncComplete follows the initial snapshot of one resource so the frontend knows it has
+// everything and can render.
+type syncComplete struct {
+	Type     string `json:"type"`
+	Resource string `json:"resource"`
+	Count    int    `json:"count"`
+}
+
+// gvrStream turns the objects of one watched resource into messages for a sender. It skips
+// updates that do not change the status snapshot, and forgets the snapshots when the sender
+// dropped one of its messages so that the re-list resends everything.
+type gvrStream struct {
+	ctx          context.Context
+	source       string
+	resource     string
+	sender       *watchSender
+	lastSnapshot map[string]string
+	// pending tracks pods waiting for a node, which the dedup would otherwise report only once
+	pending *pendingTracker
+}
+
+func newGVRStream(ctx context.Context, gvr schema.GroupVersionResource, sender *watchSender) *gvrStream {
+	return &gvrStream{
+		ctx:          ctx,
+		source:       gvr.String(),
+		resource:     gvr.Resource,
+		sender:       sender,
+		lastSnapshot: make(map[string]string),
+		pending:      newPendingTracker(time.Now),
+	}
+}
+
+func (st *gvrStream) push(msg []byte) error {
+	return st.sender.send(st.ctx, st.source, msg)
+}
+
+func (st *gvrStream) sendObject(eventType string, obj *unstructured.Unstructured) error {
+	name := obj.GetName()
+	st.pending.observe(eventType, obj)
+
+	// Always send deletes
+	if eventType != "DELETED" {
+		// Compute compact snapshot to decide whether to send
+		snap := resourceview.StatusSnapshot(obj)
+		if prev, ok := st.lastSnapshot[name]; ok && prev == snap {
+			// No meaningful status change, skip sending
+			return nil
+		}
+		st.lastSnapshot[name] = snap
+	} else {
+		delete(st.lastSnapshot, name)
+	}
+
+	msg, err := json.Marshal(objectData(eventType, obj, st.sender.viewer))
+	if err != nil {
+		return err
+	}
+	if err := st.push(msg); err != nil {
+		return fmt.Errorf("%w for %s", err, name)
+	}
+	return nil
+}
+
+// sendList sends every listed object as ADDED, starting from empty snapshots.
+func (st *gvrStream) sendList(list *unstructured.UnstructuredList) {
+	clear(st.lastSnapshot)
+	st.sendItems(list)
+}
+
+func (st *gvrStream) sendItems(list *unstructured.UnstructuredList) {
+	for i := range list.Items {
+		if err := st.sendObject("ADDED", &list.Items[i]); err != nil && !errors.Is(err, ErrWatchBufferFull) && st.ctx.Err() == nil {
+			k8sLog.Warn("failed to send list item", "resource", st.source, "error", err)
+		}
+	}
+}
+
+// listFunc lists one page of the watched resource.
+type listFunc func(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error)
+
+// sendSnapshot lists the resource config.WatchListPageSize objects at a time, pausing
+// config.WatchListBatchDelay between pages so the client can keep up, and ends with a
+// sync-complete marker carrying the number of objects. It returns the resource version of the
+// list, from which the following watch has to start to neither miss nor replay events.
+func (st *gvrStream) sendSnapshot(list listFunc, opts metav1.ListOptions) (string, error) {
+	opts.Limit = int64(config.WatchListPageSize)
+	opts.Continue = ""
+	count := 0
+	resourceVersion := ""
+	for first := true; ; first = false {
+		page, err := list(st.ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		if first {
+			st.sendList(page)
+		} else {
+			st.sendItems(page)
+		}
+		count += len(page.Items)
+		resourceVersion = page.GetResourceVersion()
+
+		opts.Continue = page.GetContinue()
+		if opts.Continue == "" {
+			break
+		}
+		select {
+		case <-st.ctx.Done():
+			return "", st.ctx.Err()
+		case <-time.After(config.WatchListBatchDelay):
+		}
+	}
+
+	msg, _ := json.Marshal(syncComplete{Type: "sync-complete", Resource: st.resource, Count: count})
+	if err := st.push(msg); err != nil && !errors.Is(err, ErrWatchBufferFull) {
+		return resourceVersion, err
+	}
+	return resourceVersion, nil
+}
+
+// needsResync reports whether messages of this stream were dropped since the last re-list.
+func (st *gvrStream) needsResync() bool {
+	return st.sender.takeResync(st.source)
+}
@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var podsGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func runningPod(name, phase string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "ns"},
+		"status":     map[string]interface{}{"phase": phase},
+	}}
+}
+
+func drainNames(t *testing.T, ch chan []byte) []string {
+	t.Helper()
+	var names []string
+	for {
+		select {
+		case msg := <-ch:
+			var m map[string]interface{}
+			if err := json.Unmarshal(msg, &m); err != nil {
+				t.Fatalf("bad message %s", msg)
+			}
+			name, _ := m["name"].(string)
+			names = append(names, name)
+		default:
+			return names
+		}
+	}
+}
+
+func TestWatchSenderCountsDropsAndReportsStats(t *testing.T) {
+	ch := make(chan []byte, 2)
+	s := newWatchSender(ch)
+	ctx := context.Background()
+
+	// The consumer reads nothing: two messages fit, three are dropped without blocking
+	for i := 0; i < 5; i++ {
+		err := s.send(ctx, "pods", []byte(`{}`))
+		if i >= 2 && !errors.Is(err, ErrWatchBufferFull) {
+			t.Fatalf("message %d: expected ErrWatchBufferFull, got %v", i, err)
+		}
+	}
+	if s.Dropped() != 3 {
+		t.Fatalf("expected 3 drops, got %d", s.Dropped())
+	}
+	if s.takeResync("services") {
+		t.Fatalf("only the watcher that dropped needs to resync")
+	}
+	if !s.takeResync("pods") || s.takeResync("pods") {
+		t.Fatalf("expected a single resync request for pods")
+	}
+
+	// Still full: the stats message waits for the next tick
+	s.reportStats()
+	if len(ch) != 2 {
+		t.Fatalf("stats must not block or replace queued messages")
+	}
+	drainNames(t, ch)
+	s.reportStats()
+	var stats watchStats
+	if err := json.Unmarshal(<-ch, &stats); err != nil {
+		t.Fatalf("bad stats message: %v", err)
+	}
+	if stats != (watchStats{Type: "stats", Dropped: 3, Resync: true}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	s.reportStats()
+	if len(ch) != 0 {
+		t.Fatalf("stats are only sent after new drops")
+	}
+}
+
+func TestGVRStreamResyncsAfterDrop(t *testing.T) {
+	ch := make(chan []byte, 1)
+	sender := newWatchSender(ch)
+	st := newGVRStream(context.Background(), podsGVR, sender)
+
+	if err := st.sendObject("ADDED", runningPod("a", "Running")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The slow client has not read "a" yet, so the update of "b" is lost
+	if err := st.sendObject("MODIFIED", runningPod("b", "Running")); !errors.Is(err, ErrWatchBufferFull) {
+		t.Fatalf("expected the message to be dropped, got %v", err)
+	}
+	drainNames(t, ch)
+
+	// Without a resync the unchanged status of "b" would never be sent again
+	if err := st.sendObject("MODIFIED", runningPod("b", "Running")); err != nil || len(ch) != 0 {
+		t.Fatalf("expected the duplicate to be skipped, got %v with %d queued", err, len(ch))
+	}
+	if !st.needsResync() {
+		t.Fatalf("expected the stream to ask for a resync")
+	}
+
+	ch2 := make(chan []byte, 4)
+	sender.ch = ch2
+	st.sendList(&unstructured.UnstructuredList{Items: []unstructured.Unstructured{*runningPod("a", "Running"), *runningPod("b", "Running")}})
+	if names := drainNames(t, ch2); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Fatalf("expected the re-list to resend every object, got %v", names)
+	}
+	if st.needsResync() {
+		t.Fatalf("a complete re-list must not ask for another")
+	}
+}
+
+func TestGVRStreamFlagsViewerObjects(t *testing.T) {
+	ch := make(chan []byte, 4)
+	sender := newWatchSender(ch)
+	sender.viewer = "3"
+	st := newGVRStream(context.Background(), podsGVR, sender)
+
+	mine := runningPod("alice-web", "Running")
+	mine.SetLabels(map[string]string{LabelUserID: "3"})
+	theirs := runningPod("bob-web", "Running")
+	theirs.SetLabels(map[string]string{LabelUserID: "4"})
+	for _, obj := range []*unstructured.Unstructured{mine, theirs} {
+		if err := st.sendObject("ADDED", obj); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	flags := map[string]interface{}{}
+	for len(ch) > 0 {
+		var m map[string]interface{}
+		_ = json.Unmarshal(<-ch, &m)
+		flags[m["name"].(string)] = m["mine"]
+	}
+	if flags["alice-web"] != true || flags["bob-web"] != false {
+		t.Fatalf("expected only the viewer's object to be flagged, got %v", flags)
+	}
+}
@@ -0,0 +1,127 @@
+// Package logger provides leveled, structured loggers per component of the platform. Records go
+// through the standard log package, so they keep its output and timestamps and interleave
+// with the lines of code still using log.Printf.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Components whose level can be set on its own
+const (
+	ComponentK8s       = "k8s"
+	ComponentScheduler = "scheduler"
+	ComponentServices  = "services"
+	ComponentAPI       = "api"
+)
+
+var (
+	mu     sync.Mutex
+	levels = map[string]*slog.LevelVar{}
+	// defaultLevel applies to components without a level of their own
+	defaultLevel slog.LevelVar
+)
+
+func levelOf(component string) *slog.LevelVar {
+	mu.Lock()
+	defer mu.Unlock()
+	if lv, ok := levels[component]; ok {
+		return lv
+	}
+	lv := &slog.LevelVar{}
+	lv.Set(defaultLevel.Level())
+	levels[component] = lv
+	return lv
+}
+
+// For returns the logger of a component. Its records carry the component as an attribute and
+// are dropped below the component's level; the level may change after the logger is created.
+func For(component string) *slog.Logger {
+	return slog.New(&componentHandler{level: levelOf(component)}).With("component", component)
+}
+
+// Configure sets the level of every component, then the levels of the components spec names, a
+// list such as "k8s=debug,scheduler=warn". Levels are debug, info, warn and error.
+func Configure(level, spec string) error {
+	def, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	overrides := map[string]slog.Level{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		component, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("invalid log level %q, expected component=level", part)
+		}
+		l, err := parseLevel(value)
+		if err != nil {
+			return err
+		}
+		overrides[strings.TrimSpace(component)] = l
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	defaultLevel.Set(def)
+	for _, lv := range levels {
+		lv.Set(def)
+	}
+	for component, l := range overrides {
+		lv, ok := levels[component]
+		if !ok {
+			lv = &slog.LevelVar{}
+			levels[component] = lv
+		}
+		lv.Set(l)
+	}
+	return nil
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	return l, nil
+}
+
+// componentHandler filters records by the level of its component and hands the rest to the
+// default slog handler, which writes through the log package. The default is looked up per
+// record, so loggers created before slog.SetDefault follow it.
+type componentHandler struct {
+	level *slog.LevelVar
+	// wrap replays the WithAttrs and WithGroup calls on the default handler
+	wrap []func(slog.Handler) slog.Handler
+}
+
+func (h *componentHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	next := slog.Default().Handler()
+	for _, w := range h.wrap {
+		next = w(next)
+	}
+	return next.Handle(ctx, r)
+}
+
+func (h *componentHandler) with(w func(slog.Handler) slog.Handler) slog.Handler {
+	return &componentHandler{level: h.level, wrap: append(append([]func(slog.Handler) slog.Handler(nil), h.wrap...), w)}
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func captureOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(orig)
+		_ = Configure("info", "")
+	})
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	return &buf
+}
+
+func TestComponentLevels(t *testing.T) {
+	buf := captureOutput(t)
+	k8sLog, schedLog := For("k8s-test"), For("scheduler-test")
+
+	if err := Configure("warn", "k8s-test=debug"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	k8sLog.Debug("created resource", "namespace", "proj-1-alice")
+	schedLog.Info("scheduler started")
+	schedLog.Warn("job executor not found")
+
+	out := buf.String()
+	if !strings.Contains(out, "created resource") || !strings.Contains(out, "component=k8s-test") || !strings.Contains(out, "namespace=proj-1-alice") {
+		t.Fatalf("expected the debug record of the k8s component, got %q", out)
+	}
+	if strings.Contains(out, "scheduler started") || !strings.Contains(out, "job executor not found") {
+		t.Fatalf("expected the scheduler component at the default warn level, got %q", out)
+	}
+
+	// Levels change under loggers already created
+	buf.Reset()
+	if err := Configure("info", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	k8sLog.Debug("created resource")
+	schedLog.Info("scheduler started")
+	if out := buf.String(); strings.Contains(out, "created resource") || !strings.Contains(out, "scheduler started") {
+		t.Fatalf("expected the levels reset to info, got %q", out)
+	}
+}
+
+func TestConfigureRejectsInvalidLevels(t *testing.T) {
+	t.Cleanup(func() { _ = Configure("info", "") })
+	for _, tc := range []struct{ level, spec string }{
+		{"verbose", ""},
+		{"info", "k8s"},
+		{"info", "k8s=loud"},
+	} {
+		if err := Configure(tc.level, tc.spec); err == nil {
+			t.Fatalf("expected %q %q to be rejected", tc.level, tc.spec)
+		}
+	}
+}
+
+// TestNoPrintLogging keeps fmt.Print* logging out of the server code; use a component logger.
+func TestNoPrintLogging(t *testing.T) {
+	fset := token.NewFileSet()
+	for _, root := range []string{"../../internal", "../../pkg"} {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return err
+			}
+			f, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				return err
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "fmt" && strings.HasPrefix(sel.Sel.Name, "Print") {
+					t.Errorf("%s: fmt.%s; log through pkg/logger instead", fset.Position(sel.Pos()), sel.Sel.Name)
+				}
+				return true
+			})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walk %s: %v", root, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildHasTextAndHTMLAlternatives(t *testing.T) {
+	raw, err := Build("platform@example.com", Message{
+		To:      []string{"admin@example.com"},
+		Subject: "3 requests are waiting — 審核",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	}, time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("not a valid message: %v", err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "3 requests are waiting — 審核" {
+		t.Fatalf("subject not encoded correctly, got %q", subject)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative, got %q (%v)", mediaType, err)
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("bad part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Fatalf("expected the plain-text fallback before the HTML part, got %v", types)
+	}
+	if bodies[0] != "plain body" || bodies[1] != "<p>html body</p>" {
+		t.Fatalf("unexpected bodies %q", bodies)
+	}
+}
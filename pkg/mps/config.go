@@ -4,8 +4,9 @@ import "fmt"
 
 // MPSConfig represents MPS configuration for a container
 type MPSConfig struct {
-	GPUQuota      int // GPU quota in integer units (system auto-injects CUDA_MPS_ACTIVE_THREAD_PERCENTAGE)
-	MemoryLimitMB int // Memory limit in MB (0 = no limit)
+	GPUQuota         int // GPU quota in integer units
+	ThreadPercentage int // CUDA_MPS_ACTIVE_THREAD_PERCENTAGE (0 = not set, all SMs)
+	MemoryLimitMB    int // Memory limit in MB (0 = no limit)
 }
 
 // Validate validates the MPS configuration
@@ -20,12 +21,14 @@ func (c *MPSConfig) Validate() error {
 }
 
 // ToEnvVars converts MPS config to environment variables for containers
-// Note: CUDA_MPS_ACTIVE_THREAD_PERCENTAGE is auto-injected by the system
 func (c *MPSConfig) ToEnvVars() map[string]string {
 	env := make(map[string]string)
 	if c.GPUQuota > 0 {
 		env["GPU_QUOTA"] = fmt.Sprintf("%d", c.GPUQuota)
 	}
+	if c.ThreadPercentage > 0 {
+		env["CUDA_MPS_ACTIVE_THREAD_PERCENTAGE"] = fmt.Sprintf("%d", c.ThreadPercentage)
+	}
 	if c.MemoryLimitMB > 0 {
 		// Convert MB to bytes for CUDA_MPS_PINNED_DEVICE_MEM_LIMIT
 		memoryBytes := int64(c.MemoryLimitMB) * 1024 * 1024
@@ -33,3 +36,22 @@ func (c *MPSConfig) ToEnvVars() map[string]string {
 	}
 	return env
 }
+
+// ThreadPercentage is the share of a GPU's SMs a pod holding units of quota may use:
+// percentPerUnit for each unit, at least floor and at most 100.
+func ThreadPercentage(units, percentPerUnit, floor int) int {
+	pct := units * percentPerUnit
+	if pct < floor {
+		pct = floor
+	}
+	if pct > 100 {
+		pct = 100
+	}
+	return pct
+}
+
+// FitsVRAM reports whether maxSharedPods pods, each pinned to memoryLimitMB, fit in the
+// nodeVRAMMB of one GPU. No memory limit always fits.
+func FitsVRAM(memoryLimitMB, maxSharedPods, nodeVRAMMB int) bool {
+	return memoryLimitMB <= 0 || int64(memoryLimitMB)*int64(maxSharedPods) <= int64(nodeVRAMMB)
+}
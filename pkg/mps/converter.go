@@ -2,7 +2,6 @@ package mps
 
 const (
 	MPSUnitsPerGPU = 10 // 1 dedicated GPU = 10 MPS units
-	// GPU quota is now in integer units (system auto-injects CUDA_MPS_ACTIVE_THREAD_PERCENTAGE)
 )
 
 // ConvertGPUToMPS converts dedicated GPU count to MPS units
@@ -19,7 +18,7 @@ func ConvertMPSToGPU(mpsUnits int) int {
 	return gpus
 }
 
-// ValidateGPUQuota validates if GPU quota is positive (system will auto-inject CUDA_MPS_ACTIVE_THREAD_PERCENTAGE)
+// ValidateGPUQuota validates if GPU quota is positive
 func ValidateGPUQuota(quota int) bool {
 	return quota > 0
 }
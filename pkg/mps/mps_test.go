@@ -45,12 +45,17 @@ func TestProjectMPSQuota(t *testing.T) {
 func TestMPSConfigToEnvVars(t *testing.T) {
 	t.Run("GPUQuota and MemoryLimit both set", func(t *testing.T) {
 		cfg := &MPSConfig{
-			GPUQuota:      80,
-			MemoryLimitMB: 2048,
+			GPUQuota:         80,
+			ThreadPercentage: 30,
+			MemoryLimitMB:    2048,
 		}
 
 		env := cfg.ToEnvVars()
 
+		if pct := env["CUDA_MPS_ACTIVE_THREAD_PERCENTAGE"]; pct != "30" {
+			t.Fatalf("expected thread percentage 30, got %q", pct)
+		}
+
 		// Check GPU quota env var
 		if quotaVal, ok := env["GPU_QUOTA"]; !ok {
 			t.Fatalf("expected GPU_QUOTA to be set")
@@ -103,3 +108,30 @@ func TestMPSConfigToEnvVars(t *testing.T) {
 		}
 	})
 }
+
+func TestThreadPercentage(t *testing.T) {
+	cases := []struct{ units, perUnit, floor, want int }{
+		{units: 3, perUnit: 10, floor: 10, want: 30},
+		{units: 0, perUnit: 10, floor: 10, want: 10},   // floor keeps a pod usable
+		{units: 1, perUnit: 5, floor: 10, want: 10},    // floor wins over a small share
+		{units: 10, perUnit: 10, floor: 10, want: 100}, // a whole GPU
+		{units: 40, perUnit: 10, floor: 10, want: 100}, // capped at every SM
+	}
+	for _, c := range cases {
+		if got := ThreadPercentage(c.units, c.perUnit, c.floor); got != c.want {
+			t.Fatalf("ThreadPercentage(%d, %d, %d) = %d, want %d", c.units, c.perUnit, c.floor, got, c.want)
+		}
+	}
+}
+
+func TestFitsVRAM(t *testing.T) {
+	if !FitsVRAM(4800, 10, 48000) {
+		t.Fatalf("10 pods of 4800MB fit in 48000MB")
+	}
+	if FitsVRAM(4801, 10, 48000) {
+		t.Fatalf("10 pods of 4801MB oversubscribe 48000MB")
+	}
+	if !FitsVRAM(0, 10, 48000) {
+		t.Fatalf("no memory limit always fits")
+	}
+}
@@ -0,0 +1,77 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/linskybing/platform-go/pkg/oidc/oidctest"
+)
+
+func TestProviderCodeExchange(t *testing.T) {
+	issuer := oidctest.NewServer("platform")
+	defer issuer.Close()
+	ctx := context.Background()
+
+	p, err := NewProvider(ctx, Config{IssuerURL: issuer.URL, ClientID: "platform", ClientSecret: "s", RedirectURL: "http://app/cb"})
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+
+	authURL, err := url.Parse(p.AuthCodeURL("state-1", "nonce-1"))
+	if err != nil {
+		t.Fatalf("bad auth url: %v", err)
+	}
+	q := authURL.Query()
+	if authURL.Path != "/authorize" || q.Get("state") != "state-1" || q.Get("nonce") != "nonce-1" || q.Get("client_id") != "platform" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	issuer.AddCode("good", map[string]interface{}{"sub": "u-1", "email": "alice@uni.edu", "nonce": "nonce-1"})
+	claims, err := p.Exchange(ctx, "good", "nonce-1")
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if claims.String("sub") != "u-1" || claims.String("email") != "alice@uni.edu" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+
+	if _, err := p.Exchange(ctx, "unknown", "nonce-1"); err == nil {
+		t.Fatalf("expected unknown code to fail")
+	}
+
+	issuer.AddCode("replayed", map[string]interface{}{"sub": "u-1", "nonce": "other"})
+	if _, err := p.Exchange(ctx, "replayed", "nonce-1"); !errors.Is(err, ErrNonceMismatch) {
+		t.Fatalf("expected nonce mismatch, got %v", err)
+	}
+}
+
+func TestProviderVerifyRejectsBadTokens(t *testing.T) {
+	issuer := oidctest.NewServer("platform")
+	defer issuer.Close()
+	ctx := context.Background()
+
+	p, err := NewProvider(ctx, Config{IssuerURL: issuer.URL, ClientID: "platform"})
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+
+	cases := map[string]jwt.MapClaims{
+		"wrong audience": {"sub": "u-1", "aud": "someone-else"},
+		"wrong issuer":   {"sub": "u-1", "iss": "https://evil.example"},
+		"expired":        {"sub": "u-1", "exp": time.Now().Add(-time.Minute).Unix()},
+	}
+	for name, claims := range cases {
+		if _, err := p.Verify(ctx, issuer.Sign(claims), ""); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("%s: expected ErrInvalidIDToken, got %v", name, err)
+		}
+	}
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "u-1", "iss": issuer.URL, "aud": "platform"}).SignedString([]byte("x"))
+	if _, err := p.Verify(ctx, unsigned, ""); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("expected HMAC token to be rejected, got %v", err)
+	}
+}
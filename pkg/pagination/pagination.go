@@ -0,0 +1,221 @@
+// Package pagination implements the cursor pagination shared by the list endpoints. Pages run
+// newest first and are keyed on (created_at, id), so rows inserted while a client pages through
+// a listing never shift the pages it has yet to read.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+	// estimateThreshold is the table size above which an estimated total replaces COUNT(*)
+	estimateThreshold = 100000
+)
+
+// ErrInvalidCursor is returned for a cursor this package did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the key of the last item of a page.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"i"`
+	// Rank leads the order of in-memory listings sorted on something else first, highest first,
+	// such as the restarts of a pod; listings newest first leave it zero
+	Rank int64 `json:"r,omitempty"`
+}
+
+// follows reports whether k comes after c in a listing by rank, then newest first.
+func (k Cursor) follows(c Cursor) bool {
+	if k.Rank != c.Rank {
+		return k.Rank < c.Rank
+	}
+	if !k.CreatedAt.Equal(c.CreatedAt) {
+		return k.CreatedAt.Before(c.CreatedAt)
+	}
+	return k.ID < c.ID
+}
+
+// Encode returns the opaque form of c handed to clients.
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Decode parses a cursor returned by Encode.
+func Decode(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == 0 || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Params selects one page of a listing.
+type Params struct {
+	Limit int
+	// After is the cursor of the last item of the previous page; nil for the first page
+	After *Cursor
+	// WithTotal asks for the number of items of the whole listing
+	WithTotal bool
+
+	// estimateTable lets large unfiltered listings estimate their total
+	estimateTable string
+}
+
+// Requested reports whether the client asked for a paginated listing. Endpoints that returned
+// plain arrays keep doing so for clients that send neither limit nor cursor.
+func Requested(c *gin.Context) bool {
+	return c.Query("limit") != "" || c.Query("cursor") != ""
+}
+
+// FromRequest reads ?limit=, ?cursor= and ?total=true. A missing or invalid limit gets
+// DefaultLimit and larger ones are capped at MaxLimit; an invalid cursor is ErrInvalidCursor.
+func FromRequest(c *gin.Context) (Params, error) {
+	p := Params{Limit: DefaultLimit, WithTotal: c.Query("total") == "true"}
+	if n, err := strconv.Atoi(c.Query("limit")); err == nil && n > 0 {
+		p.Limit = min(n, MaxLimit)
+	}
+	if raw := strings.TrimSpace(c.Query("cursor")); raw != "" {
+		after, err := Decode(raw)
+		if err != nil {
+			return Params{}, err
+		}
+		p.After = after
+	}
+	return p, nil
+}
+
+// EstimateFrom returns p allowing the total to be estimated from the statistics of table when
+// it is large. Only use it for unfiltered listings of the table.
+func (p Params) EstimateFrom(table string) Params {
+	p.estimateTable = table
+	return p
+}
+
+// Scope orders a query newest first, continues it after the cursor and fetches one extra row
+// to tell whether another page follows.
+func (p Params) Scope(db *gorm.DB) *gorm.DB {
+	if p.After != nil {
+		db = db.Where("(created_at < ? OR (created_at = ? AND id < ?))", p.After.CreatedAt, p.After.CreatedAt, p.After.ID)
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return db.Order("created_at DESC").Order("id DESC").Limit(limit + 1)
+}
+
+// Page is the list envelope of the paginated endpoints.
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	// TotalEstimated is set when Total comes from table statistics instead of a count
+	TotalEstimated bool `json:"total_estimated,omitempty"`
+}
+
+// Find runs query, already filtered and bound to its model, for the page p selects. key
+// returns the cursor of an item.
+func Find[T any](query *gorm.DB, p Params, key func(*T) Cursor) (*Page[T], error) {
+	if p.Limit <= 0 {
+		p.Limit = DefaultLimit
+	}
+	query = query.Session(&gorm.Session{})
+	var items []T
+	if err := query.Scopes(p.Scope).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	page := &Page[T]{Items: items}
+	if len(items) > p.Limit {
+		page.Items = items[:p.Limit]
+		page.NextCursor = key(&page.Items[p.Limit-1]).Encode()
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if p.WithTotal {
+		total, estimated, err := countTotal(query, p)
+		if err != nil {
+			return nil, err
+		}
+		page.Total, page.TotalEstimated = &total, estimated
+	}
+	return page, nil
+}
+
+// countTotal counts the rows of query. Large tables listed unfiltered on PostgreSQL report the
+// planner's row estimate instead, which is cheap but may be off by a few percent.
+func countTotal(query *gorm.DB, p Params) (int64, bool, error) {
+	if p.estimateTable != "" && query.Dialector.Name() == "postgres" {
+		var estimate int64
+		err := query.Session(&gorm.Session{NewDB: true}).
+			Raw("SELECT reltuples::bigint FROM pg_class WHERE relname = ?", p.estimateTable).Scan(&estimate).Error
+		if err == nil && estimate >= estimateThreshold {
+			return estimate, true, nil
+		}
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return 0, false, fmt.Errorf("failed to count items: %w", err)
+	}
+	return total, false, nil
+}
+
+// Map converts the items of a page, keeping its metadata.
+func Map[T, U any](page *Page[T], fn func(T) U) *Page[U] {
+	out := &Page[U]{Items: make([]U, 0, len(page.Items)), NextCursor: page.NextCursor, Total: page.Total, TotalEstimated: page.TotalEstimated}
+	for _, item := range page.Items {
+		out.Items = append(out.Items, fn(item))
+	}
+	return out
+}
+
+// Slice returns the page p selects from items listed in memory, such as objects read from the
+// cluster. items must already be in the order of their keys: by Rank, then newest first. The page
+// continues at the first item whose key follows the cursor, so it resumes in place when the item
+// of the cursor is gone.
+func Slice[T any](items []T, p Params, key func(*T) Cursor) *Page[T] {
+	if p.Limit <= 0 {
+		p.Limit = DefaultLimit
+	}
+	start := 0
+	if p.After != nil {
+		start = len(items)
+		for i := range items {
+			if key(&items[i]).follows(*p.After) {
+				start = i
+				break
+			}
+		}
+	}
+	rest := items[start:]
+	page := &Page[T]{Items: rest}
+	if len(rest) > p.Limit {
+		page.Items = rest[:p.Limit]
+		page.NextCursor = key(&page.Items[p.Limit-1]).Encode()
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if p.WithTotal {
+		total := int64(len(items))
+		page.Total = &total
+	}
+	return page
+}
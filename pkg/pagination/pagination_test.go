@@ -0,0 +1,114 @@
+package pagination
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := Cursor{CreatedAt: time.Date(2026, 5, 1, 9, 30, 15, 123456000, time.UTC), ID: 42}
+	got, err := Decode(want.Encode())
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDecodeRejectsForeignCursors(t *testing.T) {
+	for name, raw := range map[string]string{
+		"not base64":   "%%%",
+		"not json":     "bm90LWEtY3Vyc29y",
+		"missing id":   Cursor{CreatedAt: time.Now()}.Encode(),
+		"missing time": Cursor{ID: 3}.Encode(),
+	} {
+		if _, err := Decode(raw); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	parse := func(query string) (Params, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/items?"+query, nil)
+		return FromRequest(c)
+	}
+
+	cases := map[string]int{"": DefaultLimit, "limit=20": 20, "limit=5000": MaxLimit, "limit=-1": DefaultLimit, "limit=abc": DefaultLimit}
+	for query, want := range cases {
+		p, err := parse(query)
+		if err != nil || p.Limit != want {
+			t.Errorf("%q: expected limit %d, got %d (%v)", query, want, p.Limit, err)
+		}
+	}
+
+	after := Cursor{CreatedAt: time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC), ID: 9}
+	p, err := parse("total=true&cursor=" + after.Encode())
+	if err != nil || !p.WithTotal || p.After == nil || p.After.ID != 9 {
+		t.Fatalf("expected the cursor and total to be read, got %+v (%v)", p, err)
+	}
+	if _, err := parse("cursor=garbage"); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	base := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	var items []Cursor
+	for i := 5; i >= 1; i-- {
+		items = append(items, Cursor{CreatedAt: base.Add(time.Duration(i) * time.Minute), ID: uint(i)})
+	}
+	key := func(c *Cursor) Cursor { return *c }
+
+	first := Slice(items, Params{Limit: 2, WithTotal: true}, key)
+	if len(first.Items) != 2 || first.Items[0].ID != 5 || first.NextCursor == "" || first.Total == nil || *first.Total != 5 {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	after, err := Decode(first.NextCursor)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	second := Slice(items, Params{Limit: 2, After: after}, key)
+	if len(second.Items) != 2 || second.Items[0].ID != 3 || second.Items[1].ID != 2 {
+		t.Fatalf("expected items 3 and 2, got %+v", second.Items)
+	}
+
+	// The cursor item is gone: continue at the first older item
+	gone := append(append([]Cursor{}, items[:3]...), items[4:]...)
+	after, _ = Decode(second.NextCursor)
+	last := Slice(gone, Params{Limit: 2, After: after}, key)
+	if len(last.Items) != 1 || last.Items[0].ID != 1 || last.NextCursor != "" {
+		t.Fatalf("expected only item 1 on the last page, got %+v", last)
+	}
+}
+
+func TestSliceByRank(t *testing.T) {
+	base := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	// Ranked 3, 2, 2, 0; the oldest item has the highest rank
+	items := []Cursor{
+		{CreatedAt: base, ID: 1, Rank: 3},
+		{CreatedAt: base.Add(2 * time.Minute), ID: 2, Rank: 2},
+		{CreatedAt: base.Add(time.Minute), ID: 3, Rank: 2},
+		{CreatedAt: base.Add(3 * time.Minute), ID: 4},
+	}
+	key := func(c *Cursor) Cursor { return *c }
+
+	first := Slice(items, Params{Limit: 2}, key)
+	after, err := Decode(first.NextCursor)
+	if err != nil || after.Rank != 2 {
+		t.Fatalf("expected the rank in the cursor, got %+v (%v)", after, err)
+	}
+	// The cursor item is gone: continue with the next item of its rank, not the next older one
+	gone := append([]Cursor{items[0]}, items[2:]...)
+	second := Slice(gone, Params{Limit: 2, After: after}, key)
+	if len(second.Items) != 2 || second.Items[0].ID != 3 || second.Items[1].ID != 4 {
+		t.Fatalf("expected items 3 and 4, got %+v", second.Items)
+	}
+}
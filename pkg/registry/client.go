@@ -0,0 +1,318 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRateLimited means the registry refused the lookup (HTTP 429), so existence is unknown.
+	ErrRateLimited = errors.New("registry rate limit exceeded")
+	// ErrUnauthorized means the registry requires credentials for this repository.
+	ErrUnauthorized = errors.New("registry requires authentication")
+)
+
+const (
+	dockerHubRegistry = "docker.io"
+	dockerHubHost     = "registry-1.docker.io"
+)
+
+// manifestMediaTypes lists the manifest formats accepted from the registry, single-platform first.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// Result describes a manifest lookup. Exists is false only for a definitive 404.
+type Result struct {
+	Exists bool   `json:"exists"`
+	Digest string `json:"digest,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// Client queries the Docker Registry HTTP API v2 anonymously, following the bearer token
+// challenge returned by registries such as Docker Hub.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a client using httpClient, or a client with a 15s timeout when nil.
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Client{http: httpClient}
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform,omitempty"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+// Resolve maps a registry and image name to the API host and repository path. An empty
+// registry means Docker Hub, where single-segment names live under "library/".
+func Resolve(registry, name string) (host, repository string) {
+	host = strings.TrimSuffix(strings.TrimSpace(registry), "/")
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	repository = strings.Trim(name, "/")
+	if host == "" || host == dockerHubRegistry || host == "index.docker.io" || host == dockerHubHost {
+		host = dockerHubHost
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+	return host, repository
+}
+
+// Verify looks up name:tag on registry. The returned Size is the compressed image size (config
+// plus layers); for multi-platform images the linux/amd64 variant, or the first one, is used.
+func (c *Client) Verify(ctx context.Context, registry, name, tag string) (*Result, error) {
+	host, repo := Resolve(registry, name)
+	if tag == "" {
+		tag = "latest"
+	}
+
+	m, digest, token, err := c.fetchManifest(ctx, host, repo, tag, "")
+	if err != nil || m == nil {
+		return &Result{Exists: false}, err
+	}
+	res := &Result{Exists: true, Digest: digest}
+
+	if len(m.Manifests) > 0 {
+		child := pickPlatform(m.Manifests)
+		cm, _, _, err := c.fetchManifest(ctx, host, repo, child.Digest, token)
+		if err != nil {
+			return res, err
+		}
+		if cm != nil {
+			m = cm
+		}
+	}
+	res.Size = m.Config.Size
+	for _, l := range m.Layers {
+		res.Size += l.Size
+	}
+	return res, nil
+}
+
+// fetchManifest returns a nil manifest without error when the registry answers 404.
+func (c *Client) fetchManifest(ctx context.Context, host, repo, reference, token string) (*manifest, string, string, error) {
+	target := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repo, reference)
+
+	resp, err := c.get(ctx, target, token)
+	if err != nil {
+		return nil, "", token, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err = c.fetchToken(ctx, challenge, repo)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if resp, err = c.get(ctx, target, token); err != nil {
+			return nil, "", token, err
+		}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", token, nil
+	case http.StatusTooManyRequests:
+		return nil, "", token, ErrRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		// Docker Hub answers 401 instead of 404 for repositories that do not exist, even with a
+		// valid anonymous token. Private repositories look the same, and neither can be pulled.
+		if host == dockerHubHost && token != "" {
+			return nil, "", token, nil
+		}
+		return nil, "", token, ErrUnauthorized
+	default:
+		return nil, "", token, fmt.Errorf("registry returned %s for %s:%s", resp.Status, repo, reference)
+	}
+
+	var m manifest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&m); err != nil {
+		return nil, "", token, fmt.Errorf("invalid manifest for %s:%s: %w", repo, reference, err)
+	}
+	return &m, resp.Header.Get("Docker-Content-Digest"), token, nil
+}
+
+func (c *Client) get(ctx context.Context, target, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.http.Do(req)
+}
+
+// fetchToken requests an anonymous pull token from the realm named in a Bearer challenge.
+func (c *Client) fetchToken(ctx context.Context, challenge, repo string) (string, error) {
+	params := parseChallenge(challenge)
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", repo)
+	}
+	return c.requestToken(ctx, params, scope, "", "")
+}
+
+// requestToken asks the realm of a parsed Bearer challenge for a token, with basic auth when
+// username is set. An empty scope requests a plain login token.
+func (c *Client) requestToken(ctx context.Context, params map[string]string, scope, username, password string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", ErrUnauthorized
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", realm, err)
+	}
+	q := u.Query()
+	if svc := params["service"]; svc != "" {
+		q.Set("service", svc)
+	}
+	if scope != "" {
+		q.Set("scope", scope)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return "", ErrRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", ErrUnauthorized
+	default:
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", ErrUnauthorized
+}
+
+// CheckCredentials logs in to registry the way docker login does: it requests /v2/ and answers
+// the auth challenge, Bearer or Basic, with username and password. It returns ErrUnauthorized
+// when the registry refuses them.
+func (c *Client) CheckCredentials(ctx context.Context, registry, username, password string) error {
+	host, _ := Resolve(registry, "")
+	target := fmt.Sprintf("https://%s/v2/", host)
+
+	resp, err := c.get(ctx, target, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("registry returned %s", resp.Status)
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if scheme, _, _ := strings.Cut(strings.TrimSpace(challenge), " "); strings.EqualFold(scheme, "basic") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(username, password)
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return nil
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrUnauthorized
+		default:
+			return fmt.Errorf("registry returned %s", resp.Status)
+		}
+	}
+	_, err = c.requestToken(ctx, parseChallenge(challenge), "", username, password)
+	return err
+}
+
+// parseChallenge parses `Bearer realm="...",service="...",scope="..."`.
+func parseChallenge(header string) map[string]string {
+	params := make(map[string]string)
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return params
+	}
+	for rest != "" {
+		var key, val string
+		key, rest, ok = strings.Cut(strings.TrimLeft(rest, ", "), "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(rest, `"`) {
+			val, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			val, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = val
+	}
+	return params
+}
+
+func pickPlatform(manifests []descriptor) descriptor {
+	for _, d := range manifests {
+		if d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == "amd64" {
+			return d
+		}
+	}
+	return manifests[0]
+}
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestRegistry serves a registry that demands an anonymous bearer token, like Docker Hub.
+func newTestRegistry(t *testing.T, manifests map[string]string, status int) (*httptest.Server, *Client) {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:team/app:pull" || r.URL.Query().Get("service") != "test-registry" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token":"anon"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anon" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry",scope="repository:team/app:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		ref := strings.TrimPrefix(r.URL.Path, "/v2/team/app/manifests/")
+		body, ok := manifests[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:"+ref)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, NewClient(srv.Client())
+}
+
+func registryHost(srv *httptest.Server) string {
+	return strings.TrimPrefix(srv.URL, "https://")
+}
+
+func TestVerifyExistingImage(t *testing.T) {
+	srv, c := newTestRegistry(t, map[string]string{
+		"v1": `{"config":{"size":100},"layers":[{"size":1000},{"size":24}]}`,
+	}, 0)
+
+	res, err := c.Verify(context.Background(), registryHost(srv), "team/app", "v1")
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if !res.Exists || res.Digest != "sha256:v1" || res.Size != 1124 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestVerifyMultiPlatformImageUsesAmd64Size(t *testing.T) {
+	srv, c := newTestRegistry(t, map[string]string{
+		"v2": `{"manifests":[
+			{"digest":"arm","platform":{"os":"linux","architecture":"arm64"}},
+			{"digest":"amd","platform":{"os":"linux","architecture":"amd64"}}]}`,
+		"arm": `{"config":{"size":1},"layers":[{"size":1}]}`,
+		"amd": `{"config":{"size":10},"layers":[{"size":500}]}`,
+	}, 0)
+
+	res, err := c.Verify(context.Background(), registryHost(srv), "team/app", "v2")
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if !res.Exists || res.Digest != "sha256:v2" || res.Size != 510 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestVerifyMissingTag(t *testing.T) {
+	srv, c := newTestRegistry(t, map[string]string{}, 0)
+
+	res, err := c.Verify(context.Background(), registryHost(srv), "team/app", "nope")
+	if err != nil {
+		t.Fatalf("a 404 is a definitive answer, not an error: %v", err)
+	}
+	if res.Exists {
+		t.Fatalf("expected missing tag, got %+v", res)
+	}
+}
+
+func TestVerifyRateLimited(t *testing.T) {
+	srv, c := newTestRegistry(t, nil, http.StatusTooManyRequests)
+
+	if _, err := c.Verify(context.Background(), registryHost(srv), "team/app", "v1"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestResolveDockerHub(t *testing.T) {
+	cases := []struct{ registry, name, host, repo string }{
+		{"", "pytorch", "registry-1.docker.io", "library/pytorch"},
+		{"docker.io", "pytorch/pytorch", "registry-1.docker.io", "pytorch/pytorch"},
+		{"harbor.local:30003", "library/app", "harbor.local:30003", "library/app"},
+	}
+	for _, tc := range cases {
+		host, repo := Resolve(tc.registry, tc.name)
+		if host != tc.host || repo != tc.repo {
+			t.Errorf("Resolve(%q, %q) = %s, %s", tc.registry, tc.name, host, repo)
+		}
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "lab" || pass != "ghp_secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"login"}`)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, srv.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.Client())
+
+	if err := c.CheckCredentials(context.Background(), registryHost(srv), "lab", "ghp_secret"); err != nil {
+		t.Fatalf("expected valid credentials to log in, got %v", err)
+	}
+	if err := c.CheckCredentials(context.Background(), registryHost(srv), "lab", "wrong"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized for a wrong token, got %v", err)
+	}
+}
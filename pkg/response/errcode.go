@@ -0,0 +1,189 @@
+package response
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrorCode identifies a user-facing failure. Clients can switch on it; the message shown to the
+// user is looked up in the catalog in the language asked for with Accept-Language.
+type ErrorCode string
+
+const (
+	CodeNotFound            ErrorCode = "NOT_FOUND"
+	CodeInternal            ErrorCode = "INTERNAL_ERROR"
+	CodeImageNotAllowed     ErrorCode = "IMAGE_NOT_ALLOWED"
+	CodeImageAlreadyAllowed ErrorCode = "IMAGE_ALREADY_ALLOWED"
+	CodeInvalidImage        ErrorCode = "INVALID_IMAGE"
+	CodeGPUQuotaExceeded    ErrorCode = "GPU_QUOTA_EXCEEDED"
+	CodeGPUAccessDenied     ErrorCode = "GPU_ACCESS_NOT_ALLOWED"
+	CodeNamespaceNotFound   ErrorCode = "NAMESPACE_NOT_FOUND"
+	CodeStorageConflict     ErrorCode = "STORAGE_CONFLICT"
+	CodeStorageNotFound     ErrorCode = "STORAGE_NOT_FOUND"
+	CodeInvalidStorage      ErrorCode = "INVALID_STORAGE_REQUEST"
+	CodePodNotFound         ErrorCode = "POD_NOT_FOUND"
+	CodePullJobNotFound     ErrorCode = "PULL_JOB_NOT_FOUND"
+	CodePullJobFinished     ErrorCode = "PULL_JOB_FINISHED"
+	CodeImageInUse          ErrorCode = "IMAGE_IN_USE"
+	CodeStorageNotReady     ErrorCode = "STORAGE_NOT_READY"
+	CodeStorageDegraded     ErrorCode = "STORAGE_DEGRADED"
+	CodePodSecurity         ErrorCode = "POD_SECURITY_VIOLATION"
+	CodeConfirmInvalid      ErrorCode = "DELETE_CONFIRMATION_INVALID"
+	CodeConfirmExpired      ErrorCode = "DELETE_CONFIRMATION_EXPIRED"
+	CodeInvalidCursor       ErrorCode = "INVALID_CURSOR"
+)
+
+// Languages the catalog is translated into.
+const (
+	LangEnglish            = "en"
+	LangTraditionalChinese = "zh-TW"
+)
+
+var messageCatalog = map[ErrorCode]map[string]string{
+	CodeNotFound: {
+		LangEnglish:            "The requested item was not found.",
+		LangTraditionalChinese: "找不到指定的項目。",
+	},
+	CodeInternal: {
+		LangEnglish:            "Something went wrong on the server. Please try again later or contact an administrator.",
+		LangTraditionalChinese: "伺服器發生錯誤，請稍後再試或聯絡管理員。",
+	},
+	CodeImageNotAllowed: {
+		LangEnglish:            "This image is not allowed for the project. Submit an image request first.",
+		LangTraditionalChinese: "此專案不允許使用這個映像檔，請先提出映像檔申請。",
+	},
+	CodeImageAlreadyAllowed: {
+		LangEnglish:            "This image is already allowed for the project.",
+		LangTraditionalChinese: "此專案已允許使用這個映像檔。",
+	},
+	CodeInvalidImage: {
+		LangEnglish:            "The image name is invalid. Use a reference such as nginx, nginx:1.25 or registry.example.com:5000/team/app@sha256:....",
+		LangTraditionalChinese: "映像檔名稱格式不正確，請使用如 nginx、nginx:1.25 或 registry.example.com:5000/team/app@sha256:... 的格式。",
+	},
+	CodeGPUQuotaExceeded: {
+		LangEnglish:            "The project's GPU quota is used up. Wait for running jobs to finish or request fewer GPUs.",
+		LangTraditionalChinese: "專案的 GPU 配額已用完，請等待執行中的工作結束或減少 GPU 數量。",
+	},
+	CodeGPUAccessDenied: {
+		LangEnglish:            "The project is not allowed to use this type of GPU.",
+		LangTraditionalChinese: "此專案不允許使用這種 GPU 類型。",
+	},
+	CodeNamespaceNotFound: {
+		LangEnglish:            "Your workspace in this project has not been set up yet. Open the project once or contact an administrator.",
+		LangTraditionalChinese: "您在此專案的工作空間尚未建立，請先開啟專案一次或聯絡管理員。",
+	},
+	CodeStorageConflict: {
+		LangEnglish:            "A storage with this name already exists in the project.",
+		LangTraditionalChinese: "專案中已有相同名稱的儲存空間。",
+	},
+	CodeStorageNotFound: {
+		LangEnglish:            "The storage was not found.",
+		LangTraditionalChinese: "找不到此儲存空間。",
+	},
+	CodeInvalidStorage: {
+		LangEnglish:            "The storage name or access mode is invalid.",
+		LangTraditionalChinese: "儲存空間名稱或存取模式不正確。",
+	},
+	CodePodNotFound: {
+		LangEnglish:            "The pod was not found. It may have finished or been deleted.",
+		LangTraditionalChinese: "找不到此 Pod，可能已結束或被刪除。",
+	},
+	CodePullJobNotFound: {
+		LangEnglish:            "The image pull was not found.",
+		LangTraditionalChinese: "找不到此映像檔下載工作。",
+	},
+	CodePullJobFinished: {
+		LangEnglish:            "The image pull has already finished.",
+		LangTraditionalChinese: "此映像檔下載工作已結束。",
+	},
+	CodeImageInUse: {
+		LangEnglish:            "This image was used by running workloads recently and cannot be removed yet.",
+		LangTraditionalChinese: "此映像檔最近仍被執行中的工作使用，暫時無法移除。",
+	},
+	CodeStorageNotReady: {
+		LangEnglish:            "The storage did not start in time. The reasons reported by the cluster are listed; contact an administrator if they persist.",
+		LangTraditionalChinese: "儲存空間未能及時啟動，以下列出叢集回報的原因；若問題持續請聯絡管理員。",
+	},
+	CodeStorageDegraded: {
+		LangEnglish:            "Your personal storage is not working, so the instance could not be created. Check the storage detail page or contact an administrator.",
+		LangTraditionalChinese: "您的個人儲存空間目前無法運作，因此無法建立實例。請查看儲存空間詳細資訊頁面或聯絡管理員。",
+	},
+	CodePodSecurity: {
+		LangEnglish:            "The workload asks for access to the node (host paths, host namespaces or privileged containers), which only administrators may use. Ask an administrator to grant the host path to the project if you need it.",
+		LangTraditionalChinese: "此工作負載要求存取節點（主機路徑、主機命名空間或特權容器），僅管理員可使用。如有需要，請聯絡管理員將該主機路徑開放給專案使用。",
+	},
+	CodeConfirmInvalid: {
+		LangEnglish:            "The confirmation token does not match this storage. Send the delete again without a token to get a new one.",
+		LangTraditionalChinese: "確認碼與此儲存空間不符，請不帶確認碼重新送出刪除以取得新的確認碼。",
+	},
+	CodeConfirmExpired: {
+		LangEnglish:            "The confirmation token has expired. Send the delete again without a token to get a new one.",
+		LangTraditionalChinese: "確認碼已過期，請不帶確認碼重新送出刪除以取得新的確認碼。",
+	},
+	CodeInvalidCursor: {
+		LangEnglish:            "The page cursor is invalid. Start again from the first page.",
+		LangTraditionalChinese: "分頁游標無效，請從第一頁重新開始。",
+	},
+}
+
+// Localize returns the message for code in the language preferred by acceptLanguage, an
+// Accept-Language header value. Unknown codes fall back to the generic server error.
+func Localize(code ErrorCode, acceptLanguage string) string {
+	messages, ok := messageCatalog[code]
+	if !ok {
+		messages = messageCatalog[CodeInternal]
+	}
+	if msg, ok := messages[NegotiateLanguage(acceptLanguage)]; ok {
+		return msg
+	}
+	return messages[LangEnglish]
+}
+
+// NewErrorResponse builds the body for code, localized for acceptLanguage.
+func NewErrorResponse(code ErrorCode, acceptLanguage string) ErrorResponse {
+	return ErrorResponse{Error: Localize(code, acceptLanguage), Code: code}
+}
+
+// NegotiateLanguage picks the catalog language for an Accept-Language header, honouring q
+// values. Any Chinese tag other than Simplified Chinese maps to zh-TW; the default is English.
+func NegotiateLanguage(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang := catalogLanguage(strings.ToLower(strings.TrimSpace(tag)))
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return LangEnglish
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+func catalogLanguage(tag string) string {
+	switch {
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return LangEnglish
+	case tag == "zh-cn" || tag == "zh-sg" || strings.HasPrefix(tag, "zh-hans"):
+		return ""
+	case tag == "zh" || strings.HasPrefix(tag, "zh-"):
+		return LangTraditionalChinese
+	}
+	return ""
+}
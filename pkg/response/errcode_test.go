@@ -0,0 +1,41 @@
+package response
+
+import "testing"
+
+func TestNegotiateLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                              LangEnglish,
+		"zh-TW":                         LangTraditionalChinese,
+		"zh-Hant-TW,zh;q=0.9":           LangTraditionalChinese,
+		"en-US,en;q=0.9,zh-TW;q=0.8":    LangEnglish,
+		"fr-FR,zh-TW;q=0.5":             LangTraditionalChinese,
+		"zh-CN":                         LangEnglish,
+		"en;q=0.3, zh-tw;q=0.7":         LangTraditionalChinese,
+		"zh-TW;q=0,en":                  LangEnglish,
+		"*":                             LangEnglish,
+		"ja-JP,zh-Hans;q=0.9,zh;q=0.8":  LangTraditionalChinese,
+		"de, en-GB;q=0.9, zh-HK;q=0.95": LangTraditionalChinese,
+	}
+	for header, want := range cases {
+		if got := NegotiateLanguage(header); got != want {
+			t.Errorf("NegotiateLanguage(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
+
+func TestLocalizeReturnsTranslation(t *testing.T) {
+	if got := Localize(CodeGPUQuotaExceeded, "zh-TW,zh;q=0.9,en;q=0.8"); got != messageCatalog[CodeGPUQuotaExceeded][LangTraditionalChinese] {
+		t.Fatalf("expected the zh-TW message, got %q", got)
+	}
+	if got := Localize(CodeGPUQuotaExceeded, "en"); got != messageCatalog[CodeGPUQuotaExceeded][LangEnglish] {
+		t.Fatalf("expected the English message, got %q", got)
+	}
+	if got := Localize("NO_SUCH_CODE", "zh-TW"); got != messageCatalog[CodeInternal][LangTraditionalChinese] {
+		t.Fatalf("expected unknown codes to fall back to the generic error, got %q", got)
+	}
+	for code, messages := range messageCatalog {
+		if messages[LangEnglish] == "" || messages[LangTraditionalChinese] == "" {
+			t.Errorf("%s is missing a translation", code)
+		}
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"github.com/linskybing/platform-go/internal/domain/group"
 )
 
+// ErrorResponse is the body of a failed request. Error is meant for the user; Code, when set,
+// identifies the failure for clients (see ErrorCode). Reasons, when set, are the cluster's
+// explanation of a workload that did not start.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error   string    `json:"error"`
+	Code    ErrorCode `json:"code,omitempty"`
+	Reasons []string  `json:"reasons,omitempty"`
 }
 
 type MessageResponse struct {
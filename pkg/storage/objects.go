@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/linskybing/platform-go/internal/config"
+	minioSDK "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// ObjectStore is the part of MinIO the services use, so they can be tested without a server.
+type ObjectStore interface {
+	ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// Objects is the platform bucket, nil until Connect succeeds.
+var Objects ObjectStore
+
+// Connect sets up Client and Objects for the platform bucket from config. Unlike InitMinio it
+// does not talk to the server or exit on failure, so the API can start without MinIO.
+func Connect() error {
+	client, err := minioSDK.New(config.MinioEndpoint, &minioSDK.Options{
+		Creds:  credentials.NewStaticV4(config.MinioAccessKey, config.MinioSecretKey, ""),
+		Secure: config.MinioUseSSL,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create minio client: %w", err)
+	}
+	Client = client
+	BucketName = config.MinioBucket
+	Objects = &minioObjects{client: client, bucket: BucketName}
+	return nil
+}
+
+type minioObjects struct {
+	client *minioSDK.Client
+	bucket string
+}
+
+func (m *minioObjects) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for obj := range m.client.ListObjects(ctx, m.bucket, minioSDK.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		objects = append(objects, ObjectInfo{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified})
+	}
+	return objects, nil
+}
+
+func (m *minioObjects) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := m.client.PresignedGetObject(ctx, m.bucket, key, expiry, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
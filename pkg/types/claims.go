@@ -1,10 +1,40 @@
 package types
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	IsAdmin  bool   `json:"is_super_admin"`
+	// Scopes, TokenID and ProjectID are only set for requests authenticated with an API token
+	Scopes    []string `json:"scopes,omitempty"`
+	TokenID   uint     `json:"token_id,omitempty"`
+	ProjectID *uint    `json:"project_id,omitempty"`
+	// ImpersonatorID is the super admin acting as UserID; Impersonated tells clients to show a banner
+	ImpersonatorID uint `json:"impersonator_id,omitempty"`
+	Impersonated   bool `json:"impersonated,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// IsImpersonated reports whether the session was issued to an admin acting as another user.
+func (c *Claims) IsImpersonated() bool {
+	return c.ImpersonatorID != 0
+}
+
+// IsAPIToken reports whether the claims were resolved from an API token rather than a login JWT.
+func (c *Claims) IsAPIToken() bool {
+	return c.TokenID != 0
+}
+
+// HasScope reports whether the request may use scope. Login sessions carry no scope set and
+// are not restricted.
+func (c *Claims) HasScope(scope string) bool {
+	if !c.IsAPIToken() {
+		return true
+	}
+	return slices.Contains(c.Scopes, scope)
+}
@@ -2,24 +2,27 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linskybing/platform-go/internal/domain/audit"
 	"github.com/linskybing/platform-go/internal/repository"
+	"github.com/linskybing/platform-go/pkg/logger"
 )
 
+var servicesLog = logger.For(logger.ComponentServices)
+
 var LogAuditWithConsole = func(c *gin.Context, action, resourceType, resourceID string, oldData, newData interface{}, msg string, repos repository.AuditRepo) {
 	// Extract data synchronously to avoid race conditions
 	userID, _ := GetUserIDFromContext(c)
+	impersonatorID := GetImpersonatorIDFromContext(c)
 	ip := c.ClientIP()
 	ua := c.GetHeader("User-Agent")
 
 	// Run DB operation in background
 	go func() {
-		if err := LogAudit(userID, ip, ua, action, resourceType, resourceID, oldData, newData, msg, repos); err != nil {
-			fmt.Printf("[LogAudit] error: %v\n", err)
+		if err := writeAuditLog(userID, impersonatorID, ip, ua, action, resourceType, resourceID, oldData, newData, msg, repos); err != nil {
+			servicesLog.Error("failed to write audit log", "user_id", userID, "action", action, "resource_type", resourceType, "resource_id", resourceID, "error", err)
 		}
 	}()
 }
@@ -36,6 +39,10 @@ var LogAudit = func(
 	description string,
 	repos repository.AuditRepo,
 ) error {
+	return writeAuditLog(userID, nil, ip, ua, action, resourceType, resourceID, before, after, description, repos)
+}
+
+func writeAuditLog(userID uint, impersonatorID *uint, ip, ua, action, resourceType, resourceID string, before, after any, description string, repos repository.AuditRepo) error {
 	var oldData, newData []byte
 	var err error
 
@@ -53,15 +60,16 @@ var LogAudit = func(
 	}
 
 	auditLog := &audit.AuditLog{
-		UserID:       userID,
-		Action:       action,
-		ResourceType: resourceType,
-		ResourceID:   resourceID,
-		OldData:      oldData,
-		NewData:      newData,
-		IPAddress:    ip,
-		UserAgent:    ua,
-		Description:  description,
+		UserID:         userID,
+		ImpersonatorID: impersonatorID,
+		Action:         action,
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+		OldData:        oldData,
+		NewData:        newData,
+		IPAddress:      ip,
+		UserAgent:      ua,
+		Description:    description,
 	}
 
 	return repos.CreateAuditLog(auditLog)
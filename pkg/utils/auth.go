@@ -47,6 +47,20 @@ var GetUserNameFromContext = func(c *gin.Context) (string, error) {
 	return claims.Username, nil
 }
 
+// GetImpersonatorIDFromContext returns the admin behind an impersonated session, or nil.
+func GetImpersonatorIDFromContext(c *gin.Context) *uint {
+	claimsVal, exists := c.Get("claims")
+	if !exists {
+		return nil
+	}
+	claims, ok := claimsVal.(*types.Claims)
+	if !ok || !claims.IsImpersonated() {
+		return nil
+	}
+	id := claims.ImpersonatorID
+	return &id
+}
+
 func HasGroupRole(userID uint, gid uint, roles []string) (bool, error) {
 	var v group.UserGroup
 	err := db.DB.
@@ -3,10 +3,11 @@ package utils
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/linskybing/platform-go/internal/config"
 	"github.com/linskybing/platform-go/pkg/k8s" // 假設這是你的 k8s client wrapper
+	"github.com/linskybing/platform-go/pkg/logger"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,6 +15,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
+var k8sLog = logger.For(logger.ComponentK8s)
+
 // Helper function to generate resource names based on PVC name
 func getFileBrowserNames(pvcName string) (podName, svcName string) {
 	// e.g. fb-pod-mydata, fb-svc-mydata
@@ -23,7 +26,7 @@ func getFileBrowserNames(pvcName string) (podName, svcName string) {
 // CreateFileBrowserPod creates a temporary Pod for file browsing
 func CreateFileBrowserPod(ctx context.Context, ns string, pvcName string) (*corev1.Pod, error) {
 	if k8s.Clientset == nil {
-		fmt.Printf("[MOCK] Created FB Pod for %s in %s\n", pvcName, ns)
+		k8sLog.Debug("mock: created file browser pod", "namespace", ns, "pvc", pvcName)
 		return nil, nil
 	}
 
@@ -34,7 +37,7 @@ func CreateFileBrowserPod(ctx context.Context, ns string, pvcName string) (*core
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      podName,
 			Namespace: ns,
-			Labels:    map[string]string{"app": podName}, // Unique label for Service selector
+			Labels:    k8s.MergeLabels(map[string]string{"app": podName}, k8s.Ownership{}.Labels()), // "app" is the Service selector
 		},
 		Spec: corev1.PodSpec{
 			// Set pod-level security context for consistent file permissions
@@ -71,6 +74,8 @@ func CreateFileBrowserPod(ctx context.Context, ns string, pvcName string) (*core
 		},
 	}
 
+	k8s.ApplyFileBrowserDefaults(&pod.Spec.Containers[0], "")
+
 	createdPod, err := k8s.Clientset.CoreV1().Pods(ns).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
 		if apierrors.IsAlreadyExists(err) {
@@ -145,7 +150,7 @@ func CreateFileBrowserService(ctx context.Context, ns string, pvcName string) (s
 // DeleteFileBrowserResources cleans up both Pod and Service
 func DeleteFileBrowserResources(ctx context.Context, ns string, pvcName string) error {
 	if k8s.Clientset == nil {
-		fmt.Printf("[MOCK] Cleaning up FB resources for %s\n", pvcName)
+		k8sLog.Debug("mock: deleted file browser resources", "namespace", ns, "pvc", pvcName)
 		return nil
 	}
 
@@ -166,21 +171,28 @@ func DeleteFileBrowserResources(ctx context.Context, ns string, pvcName string)
 		return fmt.Errorf("failed to delete pod: %w", errPod)
 	}
 
-	fmt.Printf("Stopped FileBrowser for %s/%s\n", ns, pvcName)
+	k8sLog.Info("stopped file browser", "namespace", ns, "pvc", pvcName)
 	return nil
 }
 
 func int64Ptr(i int64) *int64 { return &i }
 
-func StartUserHubBrowser(ctx context.Context, username string) (string, error) {
+// UserHubBrowserNames returns the pod and service of the file browser of the storage hub named
+// with storageID.
+func UserHubBrowserNames(storageID string) (appName, svcName string) {
+	return fmt.Sprintf("fb-hub-%s", storageID), fmt.Sprintf("fb-hub-svc-%s", storageID)
+}
+
+// StartUserHubBrowser starts the file browser of the storage hub named with storageID.
+func StartUserHubBrowser(ctx context.Context, storageID string) (string, error) {
 	if k8s.Clientset == nil {
 		return "30000", nil
 	}
 
-	ns := fmt.Sprintf("user-%s-storage", username)
-	pvcName := fmt.Sprintf("user-%s-disk", username)
-	appName := fmt.Sprintf("fb-hub-%s", username)
-	svcName := fmt.Sprintf("fb-hub-svc-%s", username)
+	ns := k8s.UserHubNamespace(storageID)
+	pvcName := k8s.UserHubDiskName(storageID)
+	appName, svcName := UserHubBrowserNames(storageID)
+	baseURL := config.FileBrowserBasePath("/k8s/users/proxy")
 
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -198,7 +210,7 @@ func StartUserHubBrowser(ctx context.Context, username string) (string, error) {
 				{
 					Name:  "filebrowser",
 					Image: "filebrowser/filebrowser:v2",
-					Args:  []string{"--noauth", "--root=/srv", "--address=0.0.0.0", "--baseurl=/k8s/users/proxy"},
+					Args:  []string{"--noauth", "--root=/srv", "--address=0.0.0.0", "--baseurl=" + baseURL},
 					Ports: []corev1.ContainerPort{{ContainerPort: 80}},
 					VolumeMounts: []corev1.VolumeMount{
 						{
@@ -222,6 +234,8 @@ func StartUserHubBrowser(ctx context.Context, username string) (string, error) {
 		},
 	}
 
+	k8s.ApplyFileBrowserDefaults(&pod.Spec.Containers[0], baseURL)
+
 	_, err := k8s.Clientset.CoreV1().Pods(ns).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return "", fmt.Errorf("failed to create Hub FB pod: %w", err)
@@ -252,15 +266,15 @@ func StartUserHubBrowser(ctx context.Context, username string) (string, error) {
 	return "80", nil
 }
 
-func StopUserHubBrowser(ctx context.Context, username string) error {
+// StopUserHubBrowser stops the file browser of the storage hub named with storageID.
+func StopUserHubBrowser(ctx context.Context, storageID string) error {
 	if k8s.Clientset == nil {
-		fmt.Printf("[MOCK] Stopped Hub Browser for %s\n", username)
+		k8sLog.Debug("mock: stopped hub browser", "storage_id", storageID)
 		return nil
 	}
 
-	ns := fmt.Sprintf("user-%s-storage", username)
-	appName := fmt.Sprintf("fb-hub-%s", username)
-	svcName := fmt.Sprintf("fb-hub-svc-%s", username)
+	ns := k8s.UserHubNamespace(storageID)
+	appName, svcName := UserHubBrowserNames(storageID)
 
 	gracePeriod := int64(0)
 
@@ -276,6 +290,6 @@ func StopUserHubBrowser(ctx context.Context, username string) error {
 		return fmt.Errorf("failed to delete hub pod: %w", err)
 	}
 
-	log.Printf("[StorageHub] Browser stopped and disk unmounted for user: %s", username)
+	k8sLog.Info("stopped hub browser", "namespace", ns, "storage_id", storageID)
 	return nil
 }
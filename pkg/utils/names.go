@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Limits of user supplied names. Filenames are limited in bytes like on most filesystems,
+// project names in characters to fit their column.
+const (
+	MaxFilenameBytes     = 255
+	MaxProjectNameLength = 100
+)
+
+var ErrInvalidName = errors.New("invalid name")
+
+// NormalizeFilename NFC-normalizes a config file name and trims surrounding whitespace. Names
+// that are empty, longer than MaxFilenameBytes or contain control characters are rejected.
+func NormalizeFilename(name string) (string, error) {
+	name, err := normalizeName("filename", name)
+	if err != nil {
+		return "", err
+	}
+	if len(name) > MaxFilenameBytes {
+		return "", fmt.Errorf("%w: filename must be at most %d bytes", ErrInvalidName, MaxFilenameBytes)
+	}
+	return name, nil
+}
+
+// NormalizeProjectName NFC-normalizes a project name and trims surrounding whitespace. Names
+// that are empty, longer than MaxProjectNameLength characters or contain control characters are
+// rejected.
+func NormalizeProjectName(name string) (string, error) {
+	name, err := normalizeName("project name", name)
+	if err != nil {
+		return "", err
+	}
+	if utf8.RuneCountInString(name) > MaxProjectNameLength {
+		return "", fmt.Errorf("%w: project name must be at most %d characters", ErrInvalidName, MaxProjectNameLength)
+	}
+	return name, nil
+}
+
+func normalizeName(what, name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: %s is not valid UTF-8", ErrInvalidName, what)
+	}
+	name = strings.TrimSpace(norm.NFC.String(name))
+	if name == "" {
+		return "", fmt.Errorf("%w: %s must not be blank", ErrInvalidName, what)
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return "", fmt.Errorf("%w: %s must not contain control characters", ErrInvalidName, what)
+	}
+	return name, nil
+}
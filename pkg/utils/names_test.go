@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeFilename(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		ok    bool
+	}{
+		{name: "cjk with spaces", input: "訓練設定 v2 (final).yaml", want: "訓練設定 v2 (final).yaml", ok: true},
+		{name: "emoji", input: "🚀 launch.yaml", want: "🚀 launch.yaml", ok: true},
+		{name: "trimmed", input: "  train.yaml\t", want: "train.yaml", ok: true},
+		{name: "nfc normalized", input: "cafe\u0301.yaml", want: "caf\u00e9.yaml", ok: true},
+		{name: "255 bytes of cjk", input: strings.Repeat("訓", 85), want: strings.Repeat("訓", 85), ok: true},
+		{name: "256 bytes", input: strings.Repeat("訓", 85) + "a", ok: false},
+		{name: "empty", input: "", ok: false},
+		{name: "whitespace only", input: " \t　 ", ok: false},
+		{name: "newline", input: "train\n.yaml", ok: false},
+		{name: "nul", input: "train\x00.yaml", ok: false},
+		{name: "invalid utf-8", input: "train\xff.yaml", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeFilename(tt.input)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidName) {
+					t.Fatalf("expected %q to be rejected, got %q, %v", tt.input, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("expected %q, got %q, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeProjectName(t *testing.T) {
+	if got, err := NormalizeProjectName(" 影像辨識 🚀 "); err != nil || got != "影像辨識 🚀" {
+		t.Fatalf("expected the trimmed name, got %q, %v", got, err)
+	}
+	// The limit counts characters, not bytes
+	if _, err := NormalizeProjectName(strings.Repeat("影", MaxProjectNameLength)); err != nil {
+		t.Fatalf("expected %d CJK characters to be accepted, got %v", MaxProjectNameLength, err)
+	}
+	for _, name := range []string{strings.Repeat("a", MaxProjectNameLength+1), "   ", "vi\rsion"} {
+		if _, err := NormalizeProjectName(name); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("expected %q to be rejected, got %v", name, err)
+		}
+	}
+}
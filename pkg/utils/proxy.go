@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// storageProxyTransport bounds connection setup and the wait for response headers only;
+// the body copy itself is unbounded so multi-GB transfers are not cut off.
+var storageProxyTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ResponseHeaderTimeout: 60 * time.Second,
+	IdleConnTimeout:       90 * time.Second,
+	MaxIdleConnsPerHost:   16,
+}
+
+// NewStreamingProxy returns a reverse proxy that flushes every write to the client immediately
+// instead of buffering. The outgoing request inherits the incoming request context, so a client
+// that disconnects also cancels the backend request.
+func NewStreamingProxy(target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1
+	proxy.Transport = storageProxyTransport
+	return proxy
+}
+
+// LimitUploadBody caps the body of PUT, POST and PATCH requests at maxBytes (0 disables the cap).
+// It returns false after answering 413 when the declared Content-Length is already too large;
+// chunked uploads are cut off by http.MaxBytesReader while they are being proxied.
+func LimitUploadBody(w http.ResponseWriter, r *http.Request, maxBytes int64) bool {
+	if maxBytes <= 0 {
+		return true
+	}
+	switch r.Method {
+	case http.MethodPut, http.MethodPost, http.MethodPatch:
+	default:
+		return true
+	}
+	if r.ContentLength > maxBytes {
+		http.Error(w, "upload exceeds size limit", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	return true
+}
+
+// ProxyErrorStatus maps a proxy error to the status returned to the client.
+func ProxyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadGateway
+}
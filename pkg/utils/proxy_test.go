@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestProxyServer(t *testing.T, backend http.Handler, maxUpload int64) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(backend)
+	t.Cleanup(upstream.Close)
+	target, _ := url.Parse(upstream.URL)
+
+	proxy := NewStreamingProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(ProxyErrorStatus(err))
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !LimitUploadBody(w, r, maxUpload) {
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamingProxyDeliversBytesBeforeResponseEnds(t *testing.T) {
+	release := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "first-chunk\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = io.WriteString(w, "second-chunk\n")
+	})
+	srv := newTestProxyServer(t, backend, 0)
+
+	resp, err := http.Get(srv.URL + "/files/dataset.tar")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		r := bufio.NewReader(resp.Body)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	select {
+	case line := <-lines:
+		if line != "first-chunk\n" {
+			t.Fatalf("unexpected first line %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("first chunk was buffered by the proxy")
+	}
+
+	close(release)
+	if line := <-lines; line != "second-chunk\n" {
+		t.Fatalf("unexpected second line %q", line)
+	}
+}
+
+func TestStreamingProxyRejectsOversizedUpload(t *testing.T) {
+	var received int64
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(io.Discard, r.Body)
+		received += n
+		if err != nil {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := newTestProxyServer(t, backend, 1024)
+
+	resp, err := http.Post(srv.URL+"/api/resources/big.bin", "application/octet-stream", strings.NewReader(strings.Repeat("x", 4096)))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || received != 0 {
+		t.Fatalf("expected 413 before proxying, got %d with %d bytes forwarded", resp.StatusCode, received)
+	}
+
+	// Without a Content-Length the cap is enforced while the body streams through
+	chunked := io.MultiReader(strings.NewReader(strings.Repeat("x", 4096)))
+	resp, err = http.Post(srv.URL+"/api/resources/big.bin", "application/octet-stream", chunked)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for chunked upload, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/api/resources/small.bin", "application/octet-stream", strings.NewReader("ok"))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected small upload to pass, got %d", resp.StatusCode)
+	}
+}
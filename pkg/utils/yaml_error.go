@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// snippetContext is the number of lines shown before and after the offending line.
+const snippetContext = 2
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+): `)
+
+// YAMLError describes a problem in one document of a YAML file. Line is absolute within the
+// file (0 when unknown) and Snippet shows the surrounding lines with the offending one marked.
+type YAMLError struct {
+	Document int    `json:"document"`
+	Line     int    `json:"line,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+	Snippet  string `json:"snippet,omitempty"`
+}
+
+func (e *YAMLError) Error() string {
+	var b strings.Builder
+	if e.Document > 0 {
+		fmt.Fprintf(&b, "document %d", e.Document)
+	}
+	if e.Line > 0 {
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "line %d", e.Line)
+	}
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&b, "%s: ", e.Field)
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// NewYAMLSyntaxError converts a yaml parser error for content, which starts on startLine of
+// the file, into a *YAMLError with the absolute line and a snippet.
+func NewYAMLSyntaxError(content string, startLine int, err error) *YAMLError {
+	msg := err.Error()
+	msg = strings.TrimPrefix(msg, "error converting YAML to JSON: ")
+	msg = strings.TrimPrefix(msg, "yaml: ")
+
+	ye := &YAMLError{Message: msg}
+	if m := yamlLinePattern.FindStringSubmatchIndex(msg); m != nil {
+		rel, _ := strconv.Atoi(msg[m[2]:m[3]])
+		ye.Message = msg[:m[0]] + msg[m[1]:]
+		ye.Line = startLine + rel - 1
+		ye.Snippet = Snippet(content, startLine, ye.Line)
+		if lines := strings.Split(content, "\n"); rel >= 1 && rel <= len(lines) && strings.Contains(leadingWhitespace(lines[rel-1]), "\t") {
+			ye.Message += " (tabs are not allowed for indentation, use spaces)"
+		}
+	}
+	return ye
+}
+
+// Snippet returns the lines of content around the absolute line, numbered as in the file, with
+// the line itself marked by ">". content starts on startLine.
+func Snippet(content string, startLine, line int) string {
+	lines := strings.Split(content, "\n")
+	idx := line - startLine
+	if idx < 0 || idx >= len(lines) {
+		return ""
+	}
+	from := max(idx-snippetContext, 0)
+	to := min(idx+snippetContext, len(lines)-1)
+	width := len(strconv.Itoa(startLine + to))
+
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		marker := " "
+		if i == idx {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, startLine+i, lines[i])
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// FindKeyLine returns the absolute line of the first "key:" in content, or 0 when absent. It is
+// used to point at a field reported by a later validation step that no longer has positions.
+func FindKeyLine(content string, startLine int, key string) int {
+	if key == "" {
+		return 0
+	}
+	for i, l := range strings.Split(content, "\n") {
+		t := strings.TrimLeft(l, " \t")
+		t = strings.TrimLeft(strings.TrimPrefix(t, "-"), " \t")
+		t = strings.Trim(t, `"'`)
+		if rest, ok := strings.CutPrefix(t, key); ok {
+			rest = strings.TrimLeft(rest, `"'`)
+			if strings.HasPrefix(strings.TrimLeft(rest, " "), ":") {
+				return startLine + i
+			}
+		}
+	}
+	return 0
+}
+
+func leadingWhitespace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
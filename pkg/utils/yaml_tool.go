@@ -13,27 +13,54 @@ func GenerateFilename(projectID uint) string {
 	return fmt.Sprintf("project-%d-%s.yaml", projectID, uuid.New().String())
 }
 
+// YAMLDocument is one document of a multi-document YAML file. StartLine is the 1-based line of
+// the file on which Content begins, so errors inside the document can be reported absolutely.
+type YAMLDocument struct {
+	Content   string
+	StartLine int
+}
+
+// SplitYAMLDocuments splits content on "---" separators and drops empty documents.
 var SplitYAMLDocuments = func(content string) []string {
+	docs := SplitYAMLDocumentsWithLines(content)
+	out := make([]string, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, d.Content)
+	}
+	return out
+}
+
+// SplitYAMLDocumentsWithLines is SplitYAMLDocuments keeping the starting line of each document.
+var SplitYAMLDocumentsWithLines = func(content string) []YAMLDocument {
 	// Split on YAML document separators (--- at start of line)
 	// Use line-by-line processing to match --- that appears at the beginning of a line
 	lines := strings.Split(content, "\n")
-	docs := make([]string, 0)
+	docs := make([]YAMLDocument, 0)
 	var currentDoc []string
+	startLine := 1
 
-	for _, line := range lines {
+	flush := func() {
+		// Leading blank lines are trimmed, so the document starts at its first non-blank line
+		for len(currentDoc) > 0 && strings.TrimSpace(currentDoc[0]) == "" {
+			currentDoc = currentDoc[1:]
+			startLine++
+		}
+		docStr := strings.TrimSpace(strings.Join(currentDoc, "\n"))
+		if docStr != "" {
+			docs = append(docs, YAMLDocument{Content: docStr, StartLine: startLine})
+		}
+		currentDoc = nil
+	}
+
+	for i, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
 		// Check if this line is a document separator (--- at start, possibly with whitespace)
 		// Must be exactly "---" or "--- " followed by space/comment, not part of a string
 		if trimmedLine == "---" || strings.HasPrefix(trimmedLine, "--- ") {
 			// Save the current document if it's not empty
-			if len(currentDoc) > 0 {
-				docStr := strings.TrimSpace(strings.Join(currentDoc, "\n"))
-				if docStr != "" {
-					docs = append(docs, docStr)
-				}
-				currentDoc = nil
-			}
+			flush()
 			// Skip the separator line itself
+			startLine = i + 2
 			continue
 		}
 
@@ -42,12 +69,7 @@ var SplitYAMLDocuments = func(content string) []string {
 	}
 
 	// Don't forget the last document
-	if len(currentDoc) > 0 {
-		docStr := strings.TrimSpace(strings.Join(currentDoc, "\n"))
-		if docStr != "" {
-			docs = append(docs, docStr)
-		}
-	}
+	flush()
 
 	return docs
 }
@@ -99,12 +121,13 @@ func replaceInInterface(v interface{}, values map[string]string) interface{} {
 	}
 }
 
-// YAMLToJSON converts a YAML document (bytes) to JSON bytes.
+// YAMLToJSON converts a YAML document (bytes) to JSON bytes. Syntax errors are returned as a
+// *YAMLError carrying the line number and a snippet of the surrounding lines.
 var YAMLToJSON = func(yamlContent []byte) ([]byte, error) {
 	var yamlObj interface{}
 
 	if err := yaml.Unmarshal(yamlContent, &yamlObj); err != nil {
-		return nil, fmt.Errorf("invalid YAML content: %w", err)
+		return nil, NewYAMLSyntaxError(string(yamlContent), 1, err)
 	}
 
 	// Convert map[interface{}]interface{} to map[string]interface{}
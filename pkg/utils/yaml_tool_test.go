@@ -286,3 +286,28 @@ spec:
 	assert.NoError(t, err)
 	assert.Contains(t, string(jsonBytes), expectedContains)
 }
+
+func TestSplitYAMLDocumentsWithLines(t *testing.T) {
+	input := "\n---\napiVersion: v1\nkind: ConfigMap\n---\n\n\nkind: Pod\n"
+
+	docs := SplitYAMLDocumentsWithLines(input)
+	assert.Equal(t, []YAMLDocument{
+		{Content: "apiVersion: v1\nkind: ConfigMap", StartLine: 3},
+		{Content: "kind: Pod", StartLine: 8},
+	}, docs)
+}
+
+func TestYAMLSyntaxErrorTabIndentation(t *testing.T) {
+	input := "apiVersion: v1\nkind: Pod\n---\napiVersion: v1\nkind: Pod\nmetadata:\n\tname: bad\n"
+	docs := SplitYAMLDocumentsWithLines(input)
+	assert.Len(t, docs, 2)
+
+	_, err := YAMLToJSON([]byte(docs[1].Content))
+	assert.Error(t, err)
+	yerr := NewYAMLSyntaxError(docs[1].Content, docs[1].StartLine, err)
+	assert.Equal(t, 7, yerr.Line)
+	assert.Contains(t, yerr.Message, "tabs are not allowed")
+	assert.Contains(t, yerr.Snippet, "> 7 | \tname: bad")
+	assert.Contains(t, yerr.Snippet, "  5 | kind: Pod")
+	assert.Contains(t, yerr.Error(), "line 7")
+}
@@ -0,0 +1,45 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/linskybing/platform-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIVersionPrefix_Integration(t *testing.T) {
+	ctx := GetTestContext()
+
+	for _, path := range []string{"/groups", "/users", "/k8s/priority-classes"} {
+		t.Run(path, func(t *testing.T) {
+			client := NewHTTPClient(ctx.Router, ctx.AdminToken)
+			versioned, err := client.GET(config.APIV1Prefix + path)
+			require.NoError(t, err)
+			legacy, err := client.GET(path)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusOK, versioned.StatusCode)
+			assert.Equal(t, versioned.StatusCode, legacy.StatusCode)
+			assert.JSONEq(t, string(versioned.Body), string(legacy.Body))
+
+			assert.Empty(t, versioned.Headers.Get("Deprecation"))
+			assert.Equal(t, "true", legacy.Headers.Get("Deprecation"))
+			assert.Equal(t, config.LegacyRoutesSunset, legacy.Headers.Get("Sunset"))
+			assert.Contains(t, legacy.Headers.Get("Link"), config.APIV1Prefix+path)
+		})
+	}
+
+	t.Run("auth applies to both trees", func(t *testing.T) {
+		client := NewHTTPClient(ctx.Router, "")
+		for _, path := range []string{config.APIV1Prefix + "/groups", "/groups"} {
+			resp, err := client.GET(path)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, path)
+		}
+	})
+}
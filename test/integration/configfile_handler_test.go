@@ -162,18 +162,29 @@ func TestConfigFileHandler_Integration(t *testing.T) {
 		}
 
 		client := NewHTTPClient(ctx.Router, ctx.ManagerToken)
+		path := fmt.Sprintf("/config-files/%d", testConfigFileID)
+
+		var current configfile.ConfigFile
+		currentResp, err := client.GET(path)
+		require.NoError(t, err)
+		require.NoError(t, currentResp.DecodeJSON(&current))
 
 		formData := map[string]string{
 			"filename": "updated-config.yaml",
 			"raw_yaml": "apiVersion: v1\nkind: Pod\nmetadata:\n  name: updated-pod",
+			"version":  fmt.Sprintf("%d", current.Version),
 		}
 
-		path := fmt.Sprintf("/config-files/%d", testConfigFileID)
 		resp, err := client.PUTForm(path, formData)
 
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
+		// The same edit based on the old version is now a conflict
+		resp, err = client.PUTForm(path, formData)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
 		// Verify update
 		getResp, err := client.GET(path)
 		require.NoError(t, err)
@@ -16,9 +16,9 @@ import (
 	"github.com/linskybing/platform-go/internal/domain/project"
 	"github.com/linskybing/platform-go/internal/repository"
 	"github.com/linskybing/platform-go/pkg/k8s"
-	"github.com/linskybing/platform-go/pkg/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -218,10 +218,58 @@ func TestK8sHandler_PVC_Integration(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		var pvcs []interface{}
-		err = resp.DecodeJSON(&pvcs)
+		var result struct {
+			Data []interface{} `json:"data"`
+		}
+		err = resp.DecodeJSON(&result)
+		require.NoError(t, err)
+		assert.NotNil(t, result.Data, "Should answer an empty list, not null, for a namespace without PVCs")
+	})
+
+	t.Run("ListPVCsByProject - Non-Member Forbidden", func(t *testing.T) {
+		gen := NewTestDataGenerator()
+		outsider := gen.GenerateUser("outsider")
+		require.NoError(t, gen.CreateTestUser(outsider))
+
+		client := NewHTTPClient(ctx.Router, generateToken(outsider.UID, outsider.Username))
+		path := fmt.Sprintf("/k8s/pvc/by-project/%d", ctx.TestProject.PID)
+		resp, err := client.GET(path)
+
 		require.NoError(t, err)
-		assert.GreaterOrEqual(t, len(pvcs), 0)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, "Users outside the project's group must not list its PVCs")
+	})
+
+	t.Run("ListPVCsByProject - Sanitized Username", func(t *testing.T) {
+		gen := NewTestDataGenerator()
+		member := gen.GenerateUser("Dotted.Name")
+		require.NoError(t, gen.CreateTestUser(member))
+		require.NoError(t, gen.AddUserToGroup(member.UID, ctx.TestGroup.GID, "user"))
+
+		// The namespace CreateInstance would deploy the member's instances to
+		ns := k8s.FormatNamespaceName(ctx.TestProject.PID, k8s.ToSafeK8sName(member.Username))
+		require.NoError(t, createTestNamespace(ns))
+		_, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(ns).Create(context.Background(), &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "member-data", Namespace: ns},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		client := NewHTTPClient(ctx.Router, generateToken(member.UID, member.Username))
+		path := fmt.Sprintf("/k8s/pvc/by-project/%d", ctx.TestProject.PID)
+		resp, err := client.GET(path)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result struct {
+			Data []struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"data"`
+		}
+		require.NoError(t, resp.DecodeJSON(&result))
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, "member-data", result.Data[0].Name)
+		assert.Equal(t, ns, result.Data[0].Namespace)
 	})
 }
 
@@ -256,7 +304,7 @@ func TestK8sHandler_UserStorage_Integration(t *testing.T) {
 		assert.NotEqual(t, http.StatusForbidden, resp.StatusCode)
 
 		// Verify namespace and PVC creation in K8s (only if K8s is available)
-		if resp.StatusCode == http.StatusOK && k8sValidator != nil {
+		if resp.StatusCode == http.StatusCreated && k8sValidator != nil {
 			userNamespace := fmt.Sprintf("user-%s-storage", testUsername)
 
 			time.Sleep(3 * time.Second)
@@ -300,14 +348,14 @@ func TestK8sHandler_UserStorage_Integration(t *testing.T) {
 	t.Run("DeleteUserStorage - Admin Only with K8s Verification", func(t *testing.T) {
 		client := NewHTTPClient(ctx.Router, ctx.AdminToken)
 
-		path := fmt.Sprintf("/k8s/users/%s/storage", testUsername)
+		path := fmt.Sprintf("/k8s/users/%s/storage?force=true", testUsername)
 		resp, err := client.DELETE(path)
 
 		require.NoError(t, err)
 		assert.NotEqual(t, http.StatusForbidden, resp.StatusCode)
 
 		// Verify deletion in K8s (only if K8s is available)
-		if resp.StatusCode == http.StatusOK && k8sValidator != nil {
+		if resp.StatusCode == http.StatusNoContent && k8sValidator != nil {
 			time.Sleep(3 * time.Second)
 			userNamespace := fmt.Sprintf("user-%s", testUsername)
 			exists, _ := k8sValidator.NamespaceExists(userNamespace)
@@ -343,16 +391,18 @@ func TestK8sHandler_ProjectStorage_Integration(t *testing.T) {
 
 		resp, err := client.POST("/k8s/storage/projects", storageDTO)
 		require.NoError(t, err)
-		// Accept 200 (success) or 409 (already exists) or 500 (K8s project namespace may not exist)
-		assert.True(t, resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusInternalServerError,
+		// Accept 201 (success) or 409 (already exists) or 500 (K8s project namespace may not exist)
+		assert.True(t, resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusInternalServerError,
 			"CreateProjectStorage should succeed or return 409/500")
 
 		// Only verify K8s if request succeeded
-		if resp.StatusCode == http.StatusOK {
-			var result map[string]interface{}
+		if resp.StatusCode == http.StatusCreated {
+			var result struct {
+				Data map[string]interface{} `json:"data"`
+			}
 			err = resp.DecodeJSON(&result)
 			if err == nil && k8sValidator != nil {
-				if id, ok := result["id"].(float64); ok {
+				if id, ok := result.Data["id"].(float64); ok {
 					testStorageID = uint(id)
 				}
 			}
@@ -364,7 +414,7 @@ func TestK8sHandler_ProjectStorage_Integration(t *testing.T) {
 		}
 
 		// Verify PVC created in K8s (only if response was successful)
-		if resp.StatusCode == http.StatusOK {
+		if resp.StatusCode == http.StatusCreated {
 			time.Sleep(2 * time.Second)
 			projectNamespace := fmt.Sprintf("proj-%d", storageTestProject.PID)
 			pvcs, err := k8s.Clientset.CoreV1().PersistentVolumeClaims(projectNamespace).List(
@@ -398,8 +448,10 @@ func TestK8sHandler_ProjectStorage_Integration(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		var storages []interface{}
-		err = resp.DecodeJSON(&storages)
+		var result struct {
+			Data []interface{} `json:"data"`
+		}
+		err = resp.DecodeJSON(&result)
 		require.NoError(t, err)
 	})
 
@@ -410,8 +462,10 @@ func TestK8sHandler_ProjectStorage_Integration(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		var storages []interface{}
-		err = resp.DecodeJSON(&storages)
+		var result struct {
+			Data []interface{} `json:"data"`
+		}
+		err = resp.DecodeJSON(&result)
 		require.NoError(t, err)
 	})
 
@@ -457,11 +511,11 @@ func TestK8sHandler_ProjectStorage_Integration(t *testing.T) {
 
 		client := NewHTTPClient(ctx.Router, ctx.AdminToken)
 
-		path := fmt.Sprintf("/k8s/storage/projects/%d", testStorageID)
+		path := fmt.Sprintf("/k8s/storage/projects/%d?force=true", testStorageID)
 		resp, err := client.DELETE(path)
 
 		require.NoError(t, err)
-		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
 	})
 
 	t.Run("DeleteProjectStorage - Forbidden for User", func(t *testing.T) {
@@ -486,7 +540,7 @@ func TestK8sHandler_Jobs_Integration(t *testing.T) {
 
 		// Ensure namespace exists before creating job
 		if k8s.Clientset != nil {
-			_ = utils.CreateNamespace(namespace)
+			_ = k8s.CreateNamespace(namespace, nil)
 			time.Sleep(1 * time.Second)
 		}
 
@@ -499,12 +553,12 @@ func TestK8sHandler_Jobs_Integration(t *testing.T) {
 
 		resp, err := client.POST("/k8s/jobs", jobDTO)
 		require.NoError(t, err)
-		if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode != http.StatusCreated {
 			t.Logf("Error response: %s", string(resp.Body))
 		}
-		// Accept 200/201 (success) or 500 (K8s error) in CI environment
-		assert.True(t, resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusInternalServerError,
-			"CreateJob should succeed (200/201) or return 500")
+		// Accept 201 (success) or 500 (K8s error) in CI environment
+		assert.True(t, resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusInternalServerError,
+			"CreateJob should succeed (201) or return 500")
 	})
 
 	t.Run("CreateJob - Forbidden for User", func(t *testing.T) {
@@ -531,8 +585,10 @@ func TestK8sHandler_Jobs_Integration(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		var jobs []interface{}
-		err = resp.DecodeJSON(&jobs)
+		var result struct {
+			Data []interface{} `json:"data"`
+		}
+		err = resp.DecodeJSON(&result)
 		require.NoError(t, err)
 	})
 
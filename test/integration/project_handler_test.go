@@ -303,8 +303,10 @@ func TestProjectHandler_Integration(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-		var pvcs []interface{}
-		err = resp.DecodeJSON(&pvcs)
+		var result struct {
+			Data []interface{} `json:"data"`
+		}
+		err = resp.DecodeJSON(&result)
 		require.NoError(t, err)
 	})
 